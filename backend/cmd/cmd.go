@@ -1,29 +1,38 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/charmbracelet/log"
+	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/pkg/browser"
 	"github.com/pramodksahoo/kubechat/backend/config"
 	"github.com/pramodksahoo/kubechat/backend/container"
+	"github.com/pramodksahoo/kubechat/backend/internal/database"
+	"github.com/pramodksahoo/kubechat/backend/internal/leader"
+	"github.com/pramodksahoo/kubechat/backend/internal/logging"
+	"github.com/pramodksahoo/kubechat/backend/internal/security"
+	"github.com/pramodksahoo/kubechat/backend/internal/telemetry"
 	"github.com/pramodksahoo/kubechat/backend/routes"
 	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
 func init() {
-	rootCmd.PersistentFlags().String("certFile", "", "absolute path to certificate file")
-	rootCmd.PersistentFlags().String("keyFile", "", "absolute path to key file")
-	rootCmd.PersistentFlags().StringP("port", "p", ":7080", "port to listen on [deprecated, use --listen instead]")
-	rootCmd.PersistentFlags().StringP("listen", "l", "[::]:7080", "IP and port to listen on (e.g., localhost:7080, :7080, or [::]:7080)")
-	rootCmd.PersistentFlags().Int("k8s-client-qps", 100, "maximum QPS to the master from client")
-	rootCmd.PersistentFlags().Int("k8s-client-burst", 200, "Maximum burst for throttle")
-	rootCmd.PersistentFlags().Bool("no-open-browser", false, "Do not open the default browser")
+	config.RegisterFlags(rootCmd.PersistentFlags())
 }
 
 var rootCmd = &cobra.Command{
@@ -43,80 +52,92 @@ func Execute() {
 }
 
 func Serve(cmd *cobra.Command) error {
-	env := config.NewEnv()
-
-	k8sClientQPS, err := cmd.Flags().GetInt("k8s-client-qps")
+	cfg, err := config.Load(cmd.Flags(), Version, nil)
 	if err != nil {
 		return err
 	}
-	k9sClientBurst, err := cmd.Flags().GetInt("k8s-client-burst")
-	if err != nil {
+	if err := logging.Setup(os.Stderr, cfg.Log.Format, cfg.Log.Level, cfg.Log.ModuleLevels); err != nil {
 		return err
 	}
-	// Determine listen address
-	listenAddr, err := cmd.Flags().GetString("listen")
-	if err != nil {
+
+	env := config.NewEnv()
+	cfg.LoadAppConfig()
+
+	if err := checkSecurityPosture(cfg); err != nil {
 		return err
 	}
-	// Backcard compatibility: fallback to --port if --listen is not set
-	port, err := cmd.Flags().GetString("port")
+
+	shutdownTracing, err := telemetry.SetupTracing(cmd.Context(), Version)
 	if err != nil {
 		return err
 	}
-	if port != ":7080" {
-		log.Warn("Flag --port is deprecated, use --listen instead. This will be removed in a future release.")
-		switch {
-		case port == "":
-			listenAddr = "localhost:7080" // default
-		case port[0] == ':':
-			listenAddr = "localhost" + port
-		default:
-			listenAddr = "localhost:" + port
+
+	var db *sqlx.DB
+	if cfg.Security.DatabaseURL != "" {
+		if db, err = database.Open(cmd.Context(), cfg.Security.DatabaseURL); err != nil {
+			return err
 		}
 	}
-	certFile, err := cmd.Flags().GetString("certFile")
-	if err != nil {
-		return err
-	}
-	keyFile, err := cmd.Flags().GetString("keyFile")
-	if err != nil {
-		return err
-	}
-	noOpen, err := cmd.Flags().GetBool("no-open-browser")
-	if err != nil {
-		return err
-	}
-
-	isSecure := certFile != "" || keyFile != ""
-
-	cfg := config.NewAppConfig(Version, listenAddr, k8sClientQPS, k9sClientBurst, isSecure)
-	cfg.LoadAppConfig()
 
-	c := container.NewContainer(env, cfg)
+	c := container.NewContainer(env, cfg, db)
+	// Spans are flushed last, once requests and background work have finished.
+	c.Lifecycle().OnClose("tracing", func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return shutdownTracing(ctx)
+	})
+	if cfg.LeaderElectionNamespace != "" {
+		elector, err := leaseElector(cfg.LeaderElectionNamespace)
+		if err != nil {
+			return fmt.Errorf("leader election: %w", err)
+		}
+		c.Lifecycle().SetElector(elector)
+		log.Info("leader election enabled", "namespace", cfg.LeaderElectionNamespace, "identity", elector.Identity())
+	}
 	e := echo.New()
 	startBanner()
 	routes.ConfigureRoutes(e, c)
+	c.Lifecycle().OnShutdown("http server", cfg.DrainTimeout, e.Shutdown)
 
-	if !noOpen {
+	if cfg.OpenBrowser {
 		openDefaultBrowser(c.Config().IsSecure, c.Config().ListenAddr)
 	}
 
-	if !isSecure && !strings.Contains(c.Config().ListenAddr, "[::]:7080") && !strings.Contains(c.Config().ListenAddr, "localhost") {
+	if !cfg.IsSecure && !strings.Contains(c.Config().ListenAddr, "[::]:7080") && !strings.Contains(c.Config().ListenAddr, "localhost") {
 		log.Warn("SSE may not work properly without TLS. Use --certFile and --keyFile for HTTPS, or bind to localhost with --listen localhost:7080 to avoid issues.")
 	}
 
+	signals, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	if c.Config().IsSecure {
 		e.Pre(middleware.HTTPSRedirect())
-		if err = e.StartTLS(c.Config().ListenAddr, certFile, keyFile); err != nil {
-			return err
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		if c.Config().IsSecure {
+			serveErr <- e.StartTLS(c.Config().ListenAddr, cfg.CertFile, cfg.KeyFile)
+			return
 		}
-		return nil
+		serveErr <- e.Start(c.Config().ListenAddr)
+	}()
+
+	select {
+	case err = <-serveErr:
+		if errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+	case <-signals.Done():
+		log.Info("shutting down", "timeout", cfg.ShutdownTimeout)
 	}
+	stop()
 
-	if err = e.Start(c.Config().ListenAddr); err != nil {
-		return err
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if shutdownErr := c.Lifecycle().Shutdown(ctx); shutdownErr != nil && err == nil {
+		err = shutdownErr
 	}
-	return nil
+	return err
 }
 
 func openDefaultBrowser(isSecure bool, listenAddr string) {
@@ -151,3 +172,38 @@ func startBanner() {
 	fmt.Println("commit:", Commit)
 	fmt.Println("https://github.com/pramodksahoo/kubechat")
 }
+
+// checkSecurityPosture logs the controls the configuration is missing and, in production mode,
+// refuses to start while a mandatory one fails.
+func checkSecurityPosture(cfg *config.AppConfig) error {
+	report := security.NewValidator(cfg).Validate()
+	for _, check := range report.Checks {
+		switch check.Status {
+		case security.StatusFail:
+			log.Error("security control missing", "control", check.ID, "detail", check.Detail)
+		case security.StatusWarn:
+			log.Warn("security control missing", "control", check.ID, "detail", check.Detail)
+		}
+	}
+	if cfg.Security.Production {
+		return report.Enforce()
+	}
+	return nil
+}
+
+// leaseElector campaigns with the pod's service account, or the default kubeconfig outside a cluster.
+func leaseElector(namespace string) (*leader.LeaseElector, error) {
+	restConfig, err := rest.InClusterConfig()
+	if err != nil {
+		restConfig, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			clientcmd.NewDefaultClientConfigLoadingRules(), &clientcmd.ConfigOverrides{}).ClientConfig()
+		if err != nil {
+			return nil, err
+		}
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return leader.NewLeaseElector(client, namespace, "", nil), nil
+}
@@ -1,9 +1,11 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"k8s.io/client-go/util/homedir"
 )
@@ -32,7 +34,203 @@ type AppConfig struct {
 	IsSecure   bool                       `json:"isSecure"`
 	ListenAddr string                     `json:"listenAddr"`
 	KubeConfig map[string]*KubeConfigInfo `json:"kubeConfigs"`
-	mu         sync.Mutex
+	AI         AIConfig                   `json:"-"`
+	Security   SecurityConfig             `json:"-"`
+	// HandoffReportInterval schedules on-call handoff reports when positive.
+	HandoffReportInterval time.Duration `json:"-"`
+	// ApprovalPolicyFile points at the YAML approval policies; the default policy applies when empty.
+	ApprovalPolicyFile string `json:"-"`
+	// ResourcePolicyFile points at the YAML resource allow and deny lists; secrets are denied when empty.
+	ResourcePolicyFile string `json:"-"`
+	// NamespaceTemplateFile points at the YAML namespace templates applied per workspace.
+	NamespaceTemplateFile string `json:"-"`
+	// SafetyPolicyCluster names the cluster whose ConfigMap and SafetyPolicy resources define the
+	// command safety rules; only the built-in heuristics apply when empty.
+	SafetyPolicyCluster string `json:"-"`
+	// SafetyPolicyConfigMap is the namespace/name of the ConfigMap holding safety rules.
+	SafetyPolicyConfigMap string `json:"-"`
+	// OPADecisionURL is the OPA decision consulted before every command execution; no
+	// authorization policy applies when empty.
+	OPADecisionURL string `json:"-"`
+	// OPACacheTTL is how long OPA decisions are reused for identical inputs.
+	OPACacheTTL time.Duration `json:"-"`
+	// PrometheusURL is the in-cluster Prometheus resource usage questions are answered from;
+	// usage questions get no metrics when empty.
+	PrometheusURL string `json:"-"`
+	// PrometheusMaxRange is the longest time range a range query may cover.
+	PrometheusMaxRange time.Duration `json:"-"`
+	// TerraformStateFiles are local state files consulted when detecting Terraform-managed resources.
+	TerraformStateFiles []string `json:"-"`
+	// AttachmentStore is the URL of the store for large execution outputs.
+	AttachmentStore string `json:"-"`
+	// AuditArchiveKey encrypts audit ledger archives; the ledger is never archived when it is empty.
+	AuditArchiveKey string `json:"-"`
+	// AuditArchiveStore is the URL of the store for audit ledger archives and the executions
+	// retention purges, the attachment store when empty.
+	AuditArchiveStore string `json:"-"`
+	// AuditArchiveLifetime is how long archives must be kept, for bucket lifecycle rules.
+	AuditArchiveLifetime time.Duration `json:"-"`
+	// AnomalyRulesFile points at the YAML anomaly rules; the default rules apply when empty.
+	AnomalyRulesFile string `json:"-"`
+	// GeoIPFile is a CSV of networks and their coordinates used to detect impossible travel,
+	// which is not detected when it is empty.
+	GeoIPFile string `json:"-"`
+	// AlertingConfigFile points at the YAML alert channels and routes; alerts are only recorded
+	// when it is empty.
+	AlertingConfigFile string `json:"-"`
+	// RateLimitPolicyFile points at the YAML request limits; the default limits apply when empty.
+	RateLimitPolicyFile string `json:"-"`
+	// RedisURL is the Redis server rate limits and circuit breakers are shared through; each
+	// replica limits requests and trips breakers on its own when empty.
+	RedisURL string `json:"-"`
+	// BreakerFailures failures of an upstream within BreakerWindow open its circuit breaker for
+	// BreakerOpenFor.
+	BreakerFailures int           `json:"-"`
+	BreakerWindow   time.Duration `json:"-"`
+	BreakerOpenFor  time.Duration `json:"-"`
+	// KubernetesCacheTTL is how long responses of expensive Kubernetes reads are cached; they are
+	// not cached when zero.
+	KubernetesCacheTTL time.Duration `json:"-"`
+	// PublicURL is the address users reach KubeChat at, used in the approve and reject links
+	// sent to approvers; notifications carry no links when it is empty.
+	PublicURL string `json:"-"`
+	// ApprovalLinkKey signs approval links; no links are sent when empty.
+	ApprovalLinkKey string `json:"-"`
+	// AttachmentThreshold is the result size in bytes above which outputs become attachments.
+	AttachmentThreshold int `json:"-"`
+	// ChatAttachmentMaxSize bounds files attached to chat prompts, in bytes.
+	ChatAttachmentMaxSize int64 `json:"-"`
+	// ChatAttachmentTTL is how long chat attachments are kept.
+	ChatAttachmentTTL time.Duration `json:"-"`
+	// ChatRetention is how long idle chat sessions are kept for users who did not choose otherwise.
+	ChatRetention time.Duration `json:"-"`
+	// ChatContextTokens bounds the earlier messages of a session sent along with a prompt.
+	ChatContextTokens int `json:"-"`
+	// ClamdAddress adds ClamAV scanning of chat attachments when set.
+	ClamdAddress string `json:"-"`
+	// OpenAPIContract selects response validation against the OpenAPI document: off, log or enforce.
+	OpenAPIContract string `json:"-"`
+	// ExportSigningKey signs export download links; a random key is used when empty.
+	ExportSigningKey string `json:"-"`
+	// RequestSigningKey verifies the HMAC signatures required on the most sensitive admin
+	// operations; those operations are left unsigned when it is empty.
+	RequestSigningKey string `json:"-"`
+	// AdminUsers always hold the KubeChat admin role, which lets them run chaos experiments and
+	// request to impersonate other users for troubleshooting.
+	AdminUsers []string `json:"-"`
+	// DefaultRole is the KubeChat role every user holds in every namespace; empty grants nothing
+	// beyond role bindings.
+	DefaultRole string `json:"-"`
+	// AllowedNamespaces are namespace patterns, such as kubechat-*, every user may use alongside
+	// the namespace rules added through the API; empty leaves every namespace allowed.
+	AllowedNamespaces []string `json:"-"`
+	// MFAStepUpWindow is how long a verified MFA code satisfies step-up checks on high-risk actions
+	// such as approving dangerous commands; step-up is not required when zero.
+	MFAStepUpWindow time.Duration `json:"-"`
+	// CommandDestructivePerHour and CommandMaxConcurrent are the default command limits of each
+	// user; zero leaves a limit off.
+	CommandDestructivePerHour int `json:"-"`
+	CommandMaxConcurrent      int `json:"-"`
+	// BreakGlassUsers are admins who may impersonate without waiting for the user's consent.
+	BreakGlassUsers []string `json:"-"`
+	// ImpersonateUsers runs command executions as the requesting user instead of the service account.
+	ImpersonateUsers bool `json:"-"`
+	// LeaderElectionNamespace holds the Leases for singleton jobs; every replica runs them when empty.
+	LeaderElectionNamespace string `json:"-"`
+	// WatchSubscriptionsPerUser and WatchSubscriptionsTotal bound resource watch subscriptions.
+	WatchSubscriptionsPerUser int `json:"-"`
+	WatchSubscriptionsTotal   int `json:"-"`
+	// AnalyticsAggregateOnly keeps user-level records out of analytics and cost reports, which then
+	// suppress aggregates covering fewer than AnalyticsMinGroupSize distinct users.
+	AnalyticsAggregateOnly bool `json:"-"`
+	AnalyticsMinGroupSize  int  `json:"-"`
+	// CertFile and KeyFile serve the API over TLS when set.
+	CertFile string `json:"-"`
+	KeyFile  string `json:"-"`
+	// OpenBrowser opens the UI in the default browser once the server starts.
+	OpenBrowser bool `json:"-"`
+	// ShutdownTimeout bounds a graceful shutdown, of which DrainTimeout is left for in-flight
+	// HTTP requests.
+	ShutdownTimeout time.Duration `json:"-"`
+	DrainTimeout    time.Duration `json:"-"`
+	Log             LogConfig     `json:"-"`
+	// Disabled lists the optional subsystems switched off.
+	Disabled []Subsystem `json:"-"`
+	mu       sync.Mutex
+}
+
+// AIConfig holds settings for the model providers used by the prompt pipeline.
+type AIConfig struct {
+	OllamaURL string
+	// RiskReviewModel enables the second-opinion risk review when set.
+	RiskReviewModel string
+	// AnthropicAPIKey adds Anthropic to the provider fallback chain when set.
+	AnthropicAPIKey string
+	AnthropicModel  string
+	// GoogleAPIKey adds Gemini to the provider fallback chain when set.
+	GoogleAPIKey string
+	GeminiModel  string
+	// OpenAIAPIKey adds OpenAI to the provider fallback chain when set.
+	OpenAIAPIKey string
+	OpenAIModel  string
+	// AdaptiveRouting reorders the shared providers by their observed latency, error rate and
+	// cost instead of keeping the configured order.
+	AdaptiveRouting bool
+	// QuotaCapacity is the token burst each user may spend on hosted providers; 0 disables quotas.
+	QuotaCapacity int
+	// QuotaRefillPerMinute is the sustained hosted token rate per user.
+	QuotaRefillPerMinute int
+	// CacheTTL is how long plans are served again for the same prompt; prompts are not cached
+	// when zero.
+	CacheTTL time.Duration
+	// CostCurrency is the ISO 4217 code model prices are published and costs reported in.
+	CostCurrency string
+	// UserKeysKey seals the API keys users bring for themselves; users cannot store keys when
+	// it is empty.
+	UserKeysKey string
+	// UserKeysNamespace holds the Secrets user API keys are kept in; they are kept in memory
+	// when it is empty.
+	UserKeysNamespace string
+	// UserKeysBackend is where keys are kept when users do not choose: keyring, vault or
+	// aws-secrets-manager.
+	UserKeysBackend string
+	// VaultAddress makes HashiCorp Vault available to keep user keys in when set.
+	VaultAddress string
+	// VaultPath is the KV version 2 mount and prefix keys are kept under.
+	VaultPath string
+	// VaultRole logs in with the Kubernetes auth method; VaultToken is used when it is empty.
+	VaultRole  string
+	VaultToken string
+	// AWSSecretsRegion makes AWS Secrets Manager available to keep user keys in when set.
+	AWSSecretsRegion string
+	AWSSecretsPrefix string
+	// UserKeysKMS wraps the data keys user keys are sealed with: local, aws-kms, gcp-kms or
+	// vault-transit. Every other KMS configured below still unwraps the data keys it wrapped.
+	UserKeysKMS     string
+	AWSKMSKey       string
+	AWSKMSRegion    string
+	GCPKMSKey       string
+	VaultTransitKey string
+}
+
+// SecurityConfig holds the settings the startup security posture report is built from.
+type SecurityConfig struct {
+	// CORSAllowedOrigins are the browser origins allowed to call the API; "*" allows any origin.
+	CORSAllowedOrigins []string
+	// TrustedProxies are the CIDR ranges of the reverse proxies in front of KubeChat. Only their
+	// X-Forwarded-For and identity headers are trusted for rate limiting.
+	TrustedProxies []string
+	// StorageEncrypted declares that the database and attachment store encrypt data at rest.
+	StorageEncrypted bool
+	// Production refuses to start while mandatory security controls are missing.
+	Production bool
+	// DatabaseURL is inspected for default credentials and never reported.
+	DatabaseURL string
+}
+
+// DefaultAttachmentDir is where execution output attachments are kept when no store is configured.
+func DefaultAttachmentDir() string {
+	return filepath.Join(homedir.HomeDir(), appConfigDir, "attachments")
 }
 
 func NewEnv() *Env {
@@ -126,6 +324,34 @@ func (c *AppConfig) SaveKubeConfig(uuid string) {
 	}
 }
 
+// PutKubeConfig writes data as the app kubeconfig uuid and loads it, replacing any previous
+// version and its clients.
+func (c *AppConfig) PutKubeConfig(uuid string, data []byte) error {
+	dir := filepath.Join(homedir.HomeDir(), appConfigDir, appKubeConfigDir)
+	ensureDirExists(dir)
+	filePath := filepath.Join(dir, uuid)
+	if err := os.WriteFile(filePath, data, 0600); err != nil {
+		return err
+	}
+	clusters, err := LoadK8ConfigFromFile(filePath)
+	if err != nil {
+		return err
+	}
+	if len(clusters) == 0 {
+		return fmt.Errorf("kubeconfig %s has no usable contexts", uuid)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.KubeConfig[uuid] = &KubeConfigInfo{
+		Name:         filePath,
+		AbsolutePath: filePath,
+		FileExists:   true,
+		Clusters:     clusters,
+	}
+	return nil
+}
+
 func createEnvDirAndFile() {
 	ensureDirExists(filepath.Join(homedir.HomeDir(), appConfigDir))
 	ensureDirExists(filepath.Join(homedir.HomeDir(), appConfigDir, appKubeConfigDir))
@@ -2,9 +2,12 @@ package config
 
 import (
 	"fmt"
+	"net/http"
 	"sync"
 
 	"github.com/charmbracelet/log"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/trace"
 	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	apiextensionsinformers "k8s.io/apiextensions-apiserver/pkg/client/informers/externalversions"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -185,6 +188,14 @@ func restConfig(path string, contextName string) (*rest.Config, error) {
 	restConfig.AcceptContentTypes = fmt.Sprintf("%s,%s", runtime.ContentTypeProtobuf, runtime.ContentTypeJSON)
 	restConfig.QPS = float32(K8SQPS)
 	restConfig.Burst = K8SBURST
+	// API calls made on behalf of a traced request or command become client spans of it. Calls
+	// outside a trace, such as informer watches, are not traced.
+	restConfig.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return otelhttp.NewTransport(rt,
+			otelhttp.WithFilter(func(r *http.Request) bool { return trace.SpanContextFromContext(r.Context()).IsValid() }),
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string { return "kubernetes " + r.Method }),
+		)
+	})
 	if restConfig.BearerToken != "" && isTLSClientConfigEmpty(restConfig) {
 		restConfig.Insecure = true
 	}
@@ -0,0 +1,567 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pramodksahoo/kubechat/backend/internal/logging"
+	"github.com/pramodksahoo/kubechat/backend/internal/openapi"
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/yaml"
+)
+
+// EnvPrefix starts the environment variable of every flag: --database-url is read from
+// KUBECHAT_DATABASE_URL when it is not given on the command line.
+const EnvPrefix = "KUBECHAT_"
+
+// envIgnored lists flags never read from the environment. Kubernetes sets KUBECHAT_PORT to the
+// address of a Service named kubechat, which must not be taken for the deprecated --port flag.
+var envIgnored = []string{"port", "help"}
+
+// Subsystem names an optional part of KubeChat that --disable switches off. A disabled subsystem
+// registers no routes and starts no background work.
+type Subsystem string
+
+const (
+	SubsystemSandboxes   Subsystem = "sandboxes"
+	SubsystemWatch       Subsystem = "watch"
+	SubsystemReports     Subsystem = "reports"
+	SubsystemDashboards  Subsystem = "dashboards"
+	SubsystemExports     Subsystem = "exports"
+	SubsystemUpgrades    Subsystem = "upgrades"
+	SubsystemMCP         Subsystem = "mcp"
+	SubsystemDiagnostics Subsystem = "diagnostics"
+)
+
+// Subsystems lists every subsystem --disable accepts.
+var Subsystems = []Subsystem{
+	SubsystemSandboxes, SubsystemWatch, SubsystemReports, SubsystemDashboards,
+	SubsystemExports, SubsystemUpgrades, SubsystemMCP, SubsystemDiagnostics,
+}
+
+// LogConfig holds the format and levels of the structured logs.
+type LogConfig struct {
+	Format logging.Format
+	Level  log.Level
+	// ModuleLevels are the levels of single module loggers, by module name.
+	ModuleLevels map[string]string
+}
+
+type ErrUnknownSetting struct {
+	Source string
+	Name   string
+}
+
+func (e ErrUnknownSetting) Error() string {
+	return fmt.Sprintf("%s: unknown setting %q", e.Source, e.Name)
+}
+
+type ErrInvalidSetting struct {
+	Source string
+	Name   string
+	Value  string
+	Err    error
+}
+
+func (e ErrInvalidSetting) Error() string {
+	return fmt.Sprintf("%s: invalid value %q for %s: %v", e.Source, e.Value, e.Name, e.Err)
+}
+
+func (e ErrInvalidSetting) Unwrap() error {
+	return e.Err
+}
+
+// ErrInvalidConfig lists every problem Validate found, so operators can fix them in one go.
+type ErrInvalidConfig struct {
+	Problems []string
+}
+
+func (e ErrInvalidConfig) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// EnvName returns the environment variable read for a flag.
+func EnvName(flag string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// RegisterFlags defines the flags Load reads, with their defaults.
+func RegisterFlags(flags *pflag.FlagSet) {
+	flags.String("config", "", "path to a YAML file setting flags by name, such as database-url: postgres://... (also read from KUBECHAT_CONFIG)")
+	flags.StringSlice("disable", nil, "optional subsystems to switch off: sandboxes, watch, reports, dashboards, exports, upgrades, mcp or diagnostics")
+	flags.String("certFile", "", "absolute path to certificate file")
+	flags.String("keyFile", "", "absolute path to key file")
+	flags.StringP("port", "p", ":7080", "port to listen on [deprecated, use --listen instead]")
+	flags.StringP("listen", "l", "[::]:7080", "IP and port to listen on (e.g., localhost:7080, :7080, or [::]:7080)")
+	flags.Int("k8s-client-qps", 100, "maximum QPS to the master from client")
+	flags.Int("k8s-client-burst", 200, "Maximum burst for throttle")
+	flags.Bool("no-open-browser", false, "Do not open the default browser")
+	flags.String("ollama-url", "http://localhost:11434", "base URL of the Ollama server used for local models")
+	flags.String("risk-review-model", "", "local model that independently re-classifies plan risk (disabled when empty)")
+	flags.Int("nlp-quota-capacity", 0, "hosted model tokens each user may burst before being throttled to the local model (unlimited when 0)")
+	flags.Int("nlp-quota-refill", 2000, "hosted model tokens per minute added back to each user's quota")
+	flags.Bool("nlp-adaptive-routing", false, "try model providers in the order of their observed p95 latency, error rate and cost rather than the configured order")
+	flags.String("nlp-cost-currency", "USD", "currency code model prices are published and costs reported in; the built-in prices are in USD")
+	flags.String("user-keys-namespace", "", "namespace of the Secrets the model provider API keys users bring for themselves are kept in (in memory when empty; requires KUBECHAT_USER_KEYS_KEY)")
+	flags.String("user-keys-backend", "keyring", "where user API keys are kept unless users choose: keyring (the user-keys-namespace Secrets), vault or aws-secrets-manager")
+	flags.String("vault-address", "", "address of the HashiCorp Vault user API keys may be kept in, such as https://vault.vault:8200 (unavailable when empty)")
+	flags.String("vault-kv-path", "secret/kubechat/user-keys", "KV version 2 secrets engine mount and prefix user API keys are kept under in Vault")
+	flags.String("vault-role", "", "role to log in to Vault as with the Kubernetes auth method (VAULT_TOKEN is used when empty)")
+	flags.String("aws-secrets-region", "", "AWS region of the Secrets Manager user API keys may be kept in, with credentials from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY (unavailable when empty)")
+	flags.String("aws-secrets-prefix", "kubechat/user-keys/", "prefix of the AWS Secrets Manager secrets user API keys are kept in")
+	flags.String("user-keys-kms", "local", "service wrapping the data keys user API keys are sealed with: local (KUBECHAT_USER_KEYS_KEY), aws-kms, gcp-kms or vault-transit")
+	flags.String("aws-kms-key", "", "ARN, ID or alias of the AWS KMS key wrapping user API key data keys, with credentials from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	flags.String("aws-kms-region", "", "AWS region of aws-kms-key")
+	flags.String("gcp-kms-key", "", "resource name of the Cloud KMS key wrapping user API key data keys, such as projects/p/locations/global/keyRings/r/cryptoKeys/k")
+	flags.String("vault-transit-key", "transit/kubechat", "mount and name of the Vault transit key wrapping user API key data keys, at vault-address")
+	flags.Duration("nlp-cache-ttl", 5*time.Minute, "how long the plan generated for a prompt is served again for the same prompt and cluster context (no caching when 0)")
+	flags.String("database-url", "", "PostgreSQL connection URL for persistent state (in-memory when empty)")
+	flags.String("approval-policies", "", "path to a YAML file with command approval policies and approver groups")
+	flags.String("safety-policy-cluster", "", "cluster whose ConfigMap and SafetyPolicy resources define command safety rules (built-in heuristics only when empty)")
+	flags.String("safety-policy-configmap", "kubechat/kubechat-safety-policy", "namespace/name of the ConfigMap holding command safety rules")
+	flags.String("namespace-templates", "", "path to a YAML file with the per-workspace templates applied to namespaces created through kubechat")
+	flags.String("opa-url", "", "OPA decision URL, such as http://opa:8181/v1/data/kubechat/authz, consulted before every command execution")
+	flags.String("prometheus-url", "", "base URL of the in-cluster Prometheus, such as http://prometheus-operated.monitoring:9090, used to answer resource usage questions (disabled when empty)")
+	flags.Duration("prometheus-max-range", 7*24*time.Hour, "longest time range a Prometheus range query may cover")
+	flags.Duration("opa-cache-ttl", time.Minute, "how long OPA decisions are reused for identical command requests (no caching when 0)")
+	flags.String("resource-policy", "", "path to a YAML file with the API resources commands may read and change (everything except secrets when empty)")
+	flags.StringSlice("terraform-state", nil, "Terraform state files used to detect resources managed by Terraform")
+	flags.String("attachment-store", "", "URL of the store for large command outputs, file:///path, s3://bucket/prefix?region=&endpoint= or gs://bucket/prefix (defaults to ~/.kubechat/attachments)")
+	flags.String("audit-archive-store", "", "URL of the store audit ledger archives and purged executions are uploaded to, in the form of --attachment-store (defaults to the attachment store)")
+	flags.Duration("audit-archive-lifetime", 7*365*24*time.Hour, "how long audit archives must be kept, recorded on each archive for bucket lifecycle rules")
+	flags.String("anomaly-rules", "", "path to a YAML file with the suspicious activity rules (built-in rules when empty)")
+	flags.String("geoip-file", "", "CSV file of networks with latitude and longitude, such as GeoLite2 City blocks, used to detect impossible travel")
+	flags.String("alerting-config", "", "path to a YAML file with the Slack, PagerDuty, email and webhook channels security alerts and approval requests are routed to")
+	flags.String("rate-limit-policy", "", "path to a YAML file with the default request limit and the limits of route groups (built-in limits when empty)")
+	flags.Int("breaker-failures", 5, "failures of a model provider within breaker-window that open its circuit breaker")
+	flags.Duration("breaker-window", time.Minute, "window in which circuit breaker failures are counted")
+	flags.Duration("breaker-open-for", 30*time.Second, "how long an open circuit breaker refuses calls before trying the upstream again")
+	flags.Duration("kubernetes-cache-ttl", 15*time.Second, "how long responses of expensive Kubernetes reads such as restarts and right-sizing are cached (no caching when 0)")
+	flags.String("public-url", "", "address users reach kubechat at, such as https://kubechat.example.com, used in the approve and reject links sent to approvers")
+	flags.Int("attachment-threshold", 64<<10, "command output size in bytes above which output is stored as an attachment")
+	flags.Int64("chat-attachment-max-size", 1<<20, "largest file in bytes users may attach to chat prompts")
+	flags.Duration("chat-attachment-ttl", 24*time.Hour, "how long chat attachments are kept before they are deleted")
+	flags.Duration("chat-retention", 30*24*time.Hour, "how long idle chat sessions are kept unless their owner chose a retention")
+	flags.Int("chat-context-tokens", 4000, "token budget for the earlier chat messages sent along with a prompt")
+	flags.String("clamd-address", "", "clamd host:port or unix socket used to scan chat attachments for malware (built-in signatures only when empty)")
+	flags.String("openapi-contract", "off", "validate responses against the OpenAPI document: off, log or enforce")
+	flags.StringSlice("admin-users", nil, "users who hold the admin role and may request to impersonate other users, with their consent")
+	flags.StringSlice("allowed-namespaces", nil, "namespace patterns such as kubechat-* every user may use, alongside namespace rules added through the API (every namespace when empty)")
+	flags.String("default-role", "operator", "role every user holds without a role binding: viewer, operator, approver, admin or empty for none")
+	flags.StringSlice("break-glass-users", nil, "admins who may impersonate users without consent; every such session is audited")
+	flags.Duration("mfa-step-up-window", 5*time.Minute, "how long a verified MFA code allows approving dangerous commands (no step-up required when 0)")
+	flags.Int("command-destructive-per-hour", 0, "deletes and dangerous commands each user may submit per hour (unlimited when 0)")
+	flags.Int("command-max-concurrent", 0, "commands each user may have running at once (unlimited when 0)")
+	flags.Bool("impersonate-users", false, "run command executions as the requesting user through Kubernetes impersonation")
+	flags.String("leader-election-namespace", "", "namespace for the Leases that keep singleton background jobs on one replica (every replica runs them when empty)")
+	flags.Int("watch-subscriptions-per-user", 20, "resource watch subscriptions each user may hold at once")
+	flags.Int("watch-subscriptions-total", 1000, "resource watch subscriptions all users together may hold at once")
+	flags.Bool("analytics-aggregate-only", false, "only export analytics and cost reports as aggregates, never user-level records")
+	flags.Int("analytics-min-group-size", 5, "distinct users an aggregate must cover to be reported in aggregate-only mode")
+	flags.StringSlice("cors-allowed-origins", []string{"*"}, "browser origins allowed to call the API (any origin when *)")
+	flags.StringSlice("trusted-proxies", nil, "CIDR ranges of the reverse proxies whose X-Forwarded-For and identity headers are trusted")
+	flags.Bool("storage-encrypted", false, "declare that the database and attachment store encrypt data at rest, for the security posture report")
+	flags.Bool("production", false, "refuse to start while mandatory security controls (TLS, CORS origins, encryption at rest, no default credentials) are missing")
+	flags.Duration("handoff-report-interval", 0, "interval for scheduled on-call handoff reports (disabled when 0)")
+	flags.String("log-format", "json", "format of log entries: json or text")
+	flags.String("log-level", "info", "level of log entries written: debug, info, warn, error or fatal")
+	flags.StringToString("log-module-levels", nil, "levels of single modules, such as commands=debug,http=warn; admins can change them while the server runs")
+	flags.Duration("shutdown-timeout", 30*time.Second, "overall time allowed for a graceful shutdown after SIGINT or SIGTERM")
+	flags.Duration("drain-timeout", 15*time.Second, "time allowed for in-flight HTTP requests to finish during shutdown")
+}
+
+// Load builds the configuration from the flags defined by RegisterFlags. Flags not given on the
+// command line are read from their environment variable, then from the --config file, and keep
+// their default otherwise. Model provider keys and signing keys are only read from the
+// environment. The configuration is validated before it is returned; kubeconfigs are not loaded.
+func Load(flags *pflag.FlagSet, version string, lookupEnv func(string) (string, bool)) (*AppConfig, error) {
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+	if err := applySources(flags, lookupEnv); err != nil {
+		return nil, err
+	}
+
+	r := flagReader{flags: flags}
+	listenAddr := r.string("listen")
+	// Backward compatibility: --port overrides --listen when it is set.
+	if port := r.string("port"); port != ":7080" {
+		log.Warn("Flag --port is deprecated, use --listen instead. This will be removed in a future release.")
+		switch {
+		case port == "":
+			listenAddr = "localhost:7080"
+		case port[0] == ':':
+			listenAddr = "localhost" + port
+		default:
+			listenAddr = "localhost:" + port
+		}
+	}
+	certFile, keyFile := r.string("certFile"), r.string("keyFile")
+
+	cfg := NewAppConfig(version, listenAddr, r.int("k8s-client-qps"), r.int("k8s-client-burst"), certFile != "" || keyFile != "")
+	cfg.CertFile = certFile
+	cfg.KeyFile = keyFile
+	cfg.OpenBrowser = !r.bool("no-open-browser")
+	cfg.AI = AIConfig{
+		OllamaURL:       r.string("ollama-url"),
+		RiskReviewModel: r.string("risk-review-model"),
+		AnthropicAPIKey: env(lookupEnv, "ANTHROPIC_API_KEY"),
+		AnthropicModel:  env(lookupEnv, "ANTHROPIC_MODEL"),
+		GoogleAPIKey:    env(lookupEnv, "GOOGLE_API_KEY"),
+		GeminiModel:     env(lookupEnv, "GEMINI_MODEL"),
+		OpenAIAPIKey:    env(lookupEnv, "OPENAI_API_KEY"),
+		OpenAIModel:     env(lookupEnv, "OPENAI_MODEL"),
+
+		AdaptiveRouting:      r.bool("nlp-adaptive-routing"),
+		QuotaCapacity:        r.int("nlp-quota-capacity"),
+		QuotaRefillPerMinute: r.int("nlp-quota-refill"),
+		CacheTTL:             r.duration("nlp-cache-ttl"),
+		CostCurrency:         strings.ToUpper(r.string("nlp-cost-currency")),
+		UserKeysKey:          env(lookupEnv, "KUBECHAT_USER_KEYS_KEY"),
+		UserKeysNamespace:    r.string("user-keys-namespace"),
+		UserKeysBackend:      r.string("user-keys-backend"),
+		VaultAddress:         r.string("vault-address"),
+		VaultPath:            r.string("vault-kv-path"),
+		VaultRole:            r.string("vault-role"),
+		VaultToken:           env(lookupEnv, "VAULT_TOKEN"),
+		AWSSecretsRegion:     r.string("aws-secrets-region"),
+		AWSSecretsPrefix:     r.string("aws-secrets-prefix"),
+		UserKeysKMS:          r.string("user-keys-kms"),
+		AWSKMSKey:            r.string("aws-kms-key"),
+		AWSKMSRegion:         r.string("aws-kms-region"),
+		GCPKMSKey:            r.string("gcp-kms-key"),
+		VaultTransitKey:      r.string("vault-transit-key"),
+	}
+	cfg.Security = SecurityConfig{
+		CORSAllowedOrigins: r.stringSlice("cors-allowed-origins"),
+		TrustedProxies:     r.stringSlice("trusted-proxies"),
+		StorageEncrypted:   r.bool("storage-encrypted"),
+		Production:         r.bool("production"),
+		DatabaseURL:        r.string("database-url"),
+	}
+	cfg.HandoffReportInterval = r.duration("handoff-report-interval")
+	cfg.ApprovalPolicyFile = r.string("approval-policies")
+	cfg.ResourcePolicyFile = r.string("resource-policy")
+	cfg.NamespaceTemplateFile = r.string("namespace-templates")
+	cfg.SafetyPolicyCluster = r.string("safety-policy-cluster")
+	cfg.SafetyPolicyConfigMap = r.string("safety-policy-configmap")
+	cfg.OPADecisionURL = r.string("opa-url")
+	cfg.OPACacheTTL = r.duration("opa-cache-ttl")
+	cfg.PrometheusURL = r.string("prometheus-url")
+	cfg.PrometheusMaxRange = r.duration("prometheus-max-range")
+	cfg.TerraformStateFiles = r.stringSlice("terraform-state")
+	cfg.AttachmentStore = r.string("attachment-store")
+	cfg.AttachmentThreshold = r.int("attachment-threshold")
+	cfg.AnomalyRulesFile = r.string("anomaly-rules")
+	cfg.GeoIPFile = r.string("geoip-file")
+	cfg.AlertingConfigFile = r.string("alerting-config")
+	cfg.RateLimitPolicyFile = r.string("rate-limit-policy")
+	cfg.BreakerFailures = r.int("breaker-failures")
+	cfg.BreakerWindow = r.duration("breaker-window")
+	cfg.BreakerOpenFor = r.duration("breaker-open-for")
+	cfg.KubernetesCacheTTL = r.duration("kubernetes-cache-ttl")
+	cfg.PublicURL = r.string("public-url")
+	cfg.AuditArchiveStore = r.string("audit-archive-store")
+	cfg.AuditArchiveLifetime = r.duration("audit-archive-lifetime")
+	cfg.ChatAttachmentMaxSize = r.int64("chat-attachment-max-size")
+	cfg.ChatAttachmentTTL = r.duration("chat-attachment-ttl")
+	cfg.ChatRetention = r.duration("chat-retention")
+	cfg.ChatContextTokens = r.int("chat-context-tokens")
+	cfg.ClamdAddress = r.string("clamd-address")
+	cfg.OpenAPIContract = r.string("openapi-contract")
+	cfg.AdminUsers = r.stringSlice("admin-users")
+	cfg.BreakGlassUsers = r.stringSlice("break-glass-users")
+	cfg.DefaultRole = r.string("default-role")
+	cfg.AllowedNamespaces = r.stringSlice("allowed-namespaces")
+	cfg.MFAStepUpWindow = r.duration("mfa-step-up-window")
+	cfg.CommandDestructivePerHour = r.int("command-destructive-per-hour")
+	cfg.CommandMaxConcurrent = r.int("command-max-concurrent")
+	cfg.ImpersonateUsers = r.bool("impersonate-users")
+	cfg.LeaderElectionNamespace = r.string("leader-election-namespace")
+	cfg.WatchSubscriptionsPerUser = r.int("watch-subscriptions-per-user")
+	cfg.WatchSubscriptionsTotal = r.int("watch-subscriptions-total")
+	cfg.AnalyticsAggregateOnly = r.bool("analytics-aggregate-only")
+	cfg.AnalyticsMinGroupSize = r.int("analytics-min-group-size")
+	cfg.ExportSigningKey = env(lookupEnv, "KUBECHAT_EXPORT_SIGNING_KEY")
+	cfg.RequestSigningKey = env(lookupEnv, "KUBECHAT_REQUEST_SIGNING_KEY")
+	cfg.ApprovalLinkKey = env(lookupEnv, "KUBECHAT_APPROVAL_LINK_KEY")
+	cfg.RedisURL = env(lookupEnv, "KUBECHAT_REDIS_URL")
+	cfg.AuditArchiveKey = env(lookupEnv, "KUBECHAT_AUDIT_ARCHIVE_KEY")
+	cfg.ShutdownTimeout = r.duration("shutdown-timeout")
+	cfg.DrainTimeout = r.duration("drain-timeout")
+	cfg.Log.ModuleLevels = r.stringToString("log-module-levels")
+	for _, name := range r.stringSlice("disable") {
+		cfg.Disabled = append(cfg.Disabled, Subsystem(strings.ToLower(strings.TrimSpace(name))))
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	var problems []string
+	if format, err := logging.ParseFormat(r.string("log-format")); err != nil {
+		problems = append(problems, err.Error())
+	} else {
+		cfg.Log.Format = format
+	}
+	if level, err := logging.ParseLevel(r.string("log-level")); err != nil {
+		problems = append(problems, err.Error())
+	} else {
+		cfg.Log.Level = level
+	}
+	if mode, err := openapi.ParseContractMode(cfg.OpenAPIContract); err != nil {
+		problems = append(problems, err.Error())
+	} else {
+		cfg.OpenAPIContract = string(mode)
+	}
+	if err := cfg.Validate(); err != nil {
+		var invalid ErrInvalidConfig
+		if !errors.As(err, &invalid) {
+			return nil, err
+		}
+		problems = append(problems, invalid.Problems...)
+	}
+	if len(problems) > 0 {
+		return nil, ErrInvalidConfig{Problems: problems}
+	}
+	return cfg, nil
+}
+
+// Validate checks that settings are within range and consistent with each other.
+// isCurrencyCode reports whether code looks like an ISO 4217 code such as USD.
+func isCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *AppConfig) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+	check((c.CertFile == "") == (c.KeyFile == ""), "certFile and keyFile must be set together")
+	check(K8SQPS > 0 && K8SBURST > 0, "k8s-client-qps and k8s-client-burst must be positive")
+	check(c.AI.QuotaCapacity >= 0, "nlp-quota-capacity must not be negative")
+	check(c.AI.QuotaCapacity == 0 || c.AI.QuotaRefillPerMinute > 0, "nlp-quota-refill must be positive when nlp-quota-capacity is set")
+	check(isCurrencyCode(c.AI.CostCurrency), "nlp-cost-currency must be a three-letter currency code")
+	switch c.AI.UserKeysBackend {
+	case "", "keyring":
+	case "vault":
+		check(c.AI.VaultAddress != "", "user-keys-backend vault requires vault-address")
+	case "aws-secrets-manager":
+		check(c.AI.AWSSecretsRegion != "", "user-keys-backend aws-secrets-manager requires aws-secrets-region")
+	default:
+		check(false, "user-keys-backend must be keyring, vault or aws-secrets-manager")
+	}
+	check(c.AI.VaultAddress == "" || c.AI.VaultRole != "" || c.AI.VaultToken != "", "vault-address requires vault-role or VAULT_TOKEN")
+	switch c.AI.UserKeysKMS {
+	case "", "local":
+	case "aws-kms":
+		check(c.AI.AWSKMSKey != "" && c.AI.AWSKMSRegion != "", "user-keys-kms aws-kms requires aws-kms-key and aws-kms-region")
+	case "gcp-kms":
+		check(c.AI.GCPKMSKey != "", "user-keys-kms gcp-kms requires gcp-kms-key")
+	case "vault-transit":
+		check(c.AI.VaultAddress != "", "user-keys-kms vault-transit requires vault-address")
+	default:
+		check(false, "user-keys-kms must be local, aws-kms, gcp-kms or vault-transit")
+	}
+	check(c.AttachmentThreshold > 0, "attachment-threshold must be positive")
+	check(c.ChatAttachmentMaxSize > 0, "chat-attachment-max-size must be positive")
+	check(c.ChatContextTokens > 0, "chat-context-tokens must be positive")
+	check(c.CommandDestructivePerHour >= 0 && c.CommandMaxConcurrent >= 0, "command limits must not be negative")
+	check(c.WatchSubscriptionsPerUser > 0 && c.WatchSubscriptionsTotal > 0, "watch subscription limits must be positive")
+	check(c.WatchSubscriptionsPerUser <= c.WatchSubscriptionsTotal, "watch-subscriptions-per-user must not exceed watch-subscriptions-total")
+	check(c.AnalyticsMinGroupSize >= 1, "analytics-min-group-size must be at least 1")
+	check(c.BreakerFailures > 0, "breaker-failures must be positive")
+	check(c.BreakerWindow > 0 && c.BreakerOpenFor > 0, "breaker-window and breaker-open-for must be positive")
+	check(c.PrometheusMaxRange > 0, "prometheus-max-range must be positive")
+	for name, d := range map[string]time.Duration{
+		"opa-cache-ttl":           c.OPACacheTTL,
+		"chat-attachment-ttl":     c.ChatAttachmentTTL,
+		"chat-retention":          c.ChatRetention,
+		"mfa-step-up-window":      c.MFAStepUpWindow,
+		"handoff-report-interval": c.HandoffReportInterval,
+		"shutdown-timeout":        c.ShutdownTimeout,
+		"drain-timeout":           c.DrainTimeout,
+		"audit-archive-lifetime":  c.AuditArchiveLifetime,
+		"kubernetes-cache-ttl":    c.KubernetesCacheTTL,
+		"nlp-cache-ttl":           c.AI.CacheTTL,
+	} {
+		check(d >= 0, "%s must not be negative", name)
+	}
+	check(c.DrainTimeout <= c.ShutdownTimeout, "drain-timeout must not exceed shutdown-timeout")
+	for _, subsystem := range c.Disabled {
+		check(slices.Contains(Subsystems, subsystem), "cannot disable unknown subsystem %q", subsystem)
+	}
+	if len(problems) > 0 {
+		slices.Sort(problems)
+		return ErrInvalidConfig{Problems: problems}
+	}
+	return nil
+}
+
+// Enabled reports whether a subsystem was left on.
+func (c *AppConfig) Enabled(subsystem Subsystem) bool {
+	return !slices.Contains(c.Disabled, subsystem)
+}
+
+// applySources sets every flag not given on the command line from its environment variable or,
+// failing that, from the --config file.
+func applySources(flags *pflag.FlagSet, lookupEnv func(string) (string, bool)) error {
+	file, err := flags.GetString("config")
+	if err != nil {
+		return err
+	}
+	if file == "" {
+		file, _ = lookupEnv(EnvName("config"))
+	}
+	settings := map[string]string{}
+	if file != "" {
+		if settings, err = readConfigFile(file, flags); err != nil {
+			return err
+		}
+	}
+
+	type setting struct{ source, name, value string }
+	var pending []setting
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if flag.Changed || flag.Name == "config" {
+			return
+		}
+		if !slices.Contains(envIgnored, flag.Name) {
+			if value, ok := lookupEnv(EnvName(flag.Name)); ok {
+				pending = append(pending, setting{EnvName(flag.Name), flag.Name, value})
+				return
+			}
+		}
+		if value, ok := settings[flag.Name]; ok {
+			pending = append(pending, setting{file, flag.Name, value})
+		}
+	})
+	var errs []error
+	for _, s := range pending {
+		if err := flags.Set(s.name, s.value); err != nil {
+			errs = append(errs, ErrInvalidSetting{Source: s.source, Name: s.name, Value: s.value, Err: err})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// readConfigFile reads a YAML file of flag names and values. Lists become comma-separated values
+// and maps key=value pairs, as they are written on the command line.
+func readConfigFile(path string, flags *pflag.FlagSet) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	settings := make(map[string]string, len(raw))
+	for name, value := range raw {
+		if flags.Lookup(name) == nil || name == "config" {
+			return nil, ErrUnknownSetting{Source: path, Name: name}
+		}
+		settings[name] = settingValue(value)
+	}
+	return settings, nil
+}
+
+func settingValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		// JSON numbers decode as floats; print integers without an exponent.
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = settingValue(item)
+		}
+		return strings.Join(items, ",")
+	case map[string]any:
+		pairs := make([]string, 0, len(v))
+		for key, item := range v {
+			pairs = append(pairs, key+"="+settingValue(item))
+		}
+		slices.Sort(pairs)
+		return strings.Join(pairs, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func env(lookupEnv func(string) (string, bool), name string) string {
+	value, _ := lookupEnv(name)
+	return value
+}
+
+// flagReader reads flag values, keeping the first error so Load can check once.
+type flagReader struct {
+	flags *pflag.FlagSet
+	err   error
+}
+
+func (r *flagReader) keep(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *flagReader) string(name string) string {
+	v, err := r.flags.GetString(name)
+	r.keep(err)
+	return v
+}
+
+func (r *flagReader) stringSlice(name string) []string {
+	v, err := r.flags.GetStringSlice(name)
+	r.keep(err)
+	return v
+}
+
+func (r *flagReader) stringToString(name string) map[string]string {
+	v, err := r.flags.GetStringToString(name)
+	r.keep(err)
+	return v
+}
+
+func (r *flagReader) int(name string) int {
+	v, err := r.flags.GetInt(name)
+	r.keep(err)
+	return v
+}
+
+func (r *flagReader) int64(name string) int64 {
+	v, err := r.flags.GetInt64(name)
+	r.keep(err)
+	return v
+}
+
+func (r *flagReader) bool(name string) bool {
+	v, err := r.flags.GetBool(name)
+	r.keep(err)
+	return v
+}
+
+func (r *flagReader) duration(name string) time.Duration {
+	v, err := r.flags.GetDuration(name)
+	r.keep(err)
+	return v
+}
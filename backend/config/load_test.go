@@ -0,0 +1,90 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFlags(t *testing.T, args ...string) *pflag.FlagSet {
+	t.Helper()
+	flags := pflag.NewFlagSet("kubechat", pflag.ContinueOnError)
+	RegisterFlags(flags)
+	require.NoError(t, flags.Parse(args))
+	return flags
+}
+
+func lookup(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	}
+}
+
+func TestLoadTakesFlagsOverEnvironmentOverFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "kubechat.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+database-url: postgres://file
+chat-context-tokens: 8000
+attachment-threshold: 1048576
+opa-cache-ttl: 2m
+admin-users: [alice, bob]
+log-module-levels: {commands: debug}
+disable: [exports, mcp]
+`), 0600))
+
+	flags := newFlags(t, "--config", file, "--chat-context-tokens", "6000")
+	cfg, err := Load(flags, "test", lookup(map[string]string{
+		"KUBECHAT_DATABASE_URL":        "postgres://env",
+		"KUBECHAT_CHAT_CONTEXT_TOKENS": "7000",
+		"KUBECHAT_PORT":                "tcp://10.0.0.1:7080",
+		"ANTHROPIC_API_KEY":            "key",
+		"OPENAI_API_KEY":               "sk-key",
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, 6000, cfg.ChatContextTokens)
+	assert.Equal(t, "postgres://env", cfg.Security.DatabaseURL)
+	assert.Equal(t, 1<<20, cfg.AttachmentThreshold)
+	assert.Equal(t, 2*time.Minute, cfg.OPACacheTTL)
+	assert.Equal(t, []string{"alice", "bob"}, cfg.AdminUsers)
+	assert.Equal(t, map[string]string{"commands": "debug"}, cfg.Log.ModuleLevels)
+	assert.Equal(t, "key", cfg.AI.AnthropicAPIKey)
+	assert.Equal(t, "sk-key", cfg.AI.OpenAIAPIKey)
+	assert.Equal(t, "[::]:7080", cfg.ListenAddr, "the Kubernetes service variable must not be read as --port")
+	assert.Equal(t, 20, cfg.WatchSubscriptionsPerUser)
+	assert.False(t, cfg.Enabled(SubsystemExports))
+	assert.False(t, cfg.Enabled(SubsystemMCP))
+	assert.True(t, cfg.Enabled(SubsystemWatch))
+}
+
+func TestLoadRejectsUnknownAndInvalidSettings(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "kubechat.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("databse-url: postgres://typo\n"), 0600))
+	_, err := Load(newFlags(t, "--config", file), "test", lookup(nil))
+	assert.ErrorAs(t, err, &ErrUnknownSetting{})
+
+	_, err = Load(newFlags(t), "test", lookup(map[string]string{"KUBECHAT_CHAT_RETENTION": "a month"}))
+	var invalid ErrInvalidSetting
+	require.ErrorAs(t, err, &invalid)
+	assert.Equal(t, "KUBECHAT_CHAT_RETENTION", invalid.Source)
+}
+
+func TestLoadValidatesTheConfiguration(t *testing.T) {
+	_, err := Load(newFlags(t,
+		"--watch-subscriptions-per-user", "50", "--watch-subscriptions-total", "10",
+		"--certFile", "tls.crt", "--log-level", "loud", "--disable", "chat",
+	), "test", lookup(nil))
+
+	var invalid ErrInvalidConfig
+	require.True(t, errors.As(err, &invalid), "expected ErrInvalidConfig, got %v", err)
+	assert.Len(t, invalid.Problems, 4)
+	assert.Contains(t, err.Error(), "watch-subscriptions-per-user must not exceed watch-subscriptions-total")
+	assert.Contains(t, err.Error(), `cannot disable unknown subsystem "chat"`)
+}
@@ -1,11 +1,13 @@
 package container
 
 import (
+	"context"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/pramodksahoo/kubechat/backend/event"
+	"github.com/pramodksahoo/kubechat/backend/internal/lifecycle"
 	portforward "github.com/pramodksahoo/kubechat/backend/portfoward"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
@@ -18,6 +20,7 @@ import (
 	apiextensionsinformers "k8s.io/apiextensions-apiserver/pkg/client/informers/externalversions"
 
 	"github.com/gorilla/websocket"
+	"github.com/jmoiron/sqlx"
 	"github.com/pramodksahoo/kubechat/backend/config"
 	"github.com/maypok86/otter/v2"
 	"k8s.io/client-go/informers"
@@ -41,6 +44,8 @@ type Container interface {
 	SocketUpgrader() *websocket.Upgrader
 	EventProcessor() *event.EventProcessor
 	PortForwarder() *portforward.PortForwarder
+	DB() *sqlx.DB
+	Lifecycle() *lifecycle.Manager
 }
 
 // container struct is for sharing data which such as database setting, the setting of application and logger in overall this application.
@@ -52,11 +57,13 @@ type container struct {
 	eventProcessor *event.EventProcessor
 	socketUpgrader *websocket.Upgrader
 	portForwarder  *portforward.PortForwarder
+	db             *sqlx.DB
+	lifecycle      *lifecycle.Manager
 	mu             sync.Mutex
 }
 
-// NewContainer is constructor.
-func NewContainer(env *config.Env, cfg *config.AppConfig) Container {
+// NewContainer is constructor. db may be nil when no database is configured.
+func NewContainer(env *config.Env, cfg *config.AppConfig, db *sqlx.DB) Container {
 	cache := otter.Must(&otter.Options[string, any]{
 		MaximumSize:      5000,
 		ExpiryCalculator: otter.ExpiryAccessing[string, any](4 * time.Hour), // Reset timer on reads/writes
@@ -74,6 +81,20 @@ func NewContainer(env *config.Env, cfg *config.AppConfig) Container {
 
 	e := event.NewEventCounter(time.Millisecond * 250)
 	go e.Run()
+
+	lc := lifecycle.NewManager(nil)
+	lc.OnShutdown("sse streams", 0, func(ctx context.Context) error {
+		// Streams are long-lived requests; close them so the HTTP server can drain.
+		s.Close()
+		return nil
+	})
+	lc.OnClose("event processor", func() error {
+		e.Stop()
+		return nil
+	})
+	if db != nil {
+		lc.OnClose("database", db.Close)
+	}
 	return &container{
 		env:            env,
 		config:         cfg,
@@ -81,6 +102,8 @@ func NewContainer(env *config.Env, cfg *config.AppConfig) Container {
 		sseServer:      s,
 		eventProcessor: e,
 		portForwarder:  pf,
+		db:             db,
+		lifecycle:      lc,
 		socketUpgrader: &websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				return true
@@ -103,6 +126,20 @@ func (c *container) Config() *config.AppConfig {
 	return c.config
 }
 
+func (c *container) DB() *sqlx.DB {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.db
+}
+
+func (c *container) Lifecycle() *lifecycle.Manager {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lifecycle
+}
+
 func (c *container) Cache() *otter.Cache[string, any] {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pramodksahoo/kubechat/backend/handlers/mcp/helpers"
+)
+
+const restartsTemplate = `Summarize containers that keep restarting across the cluster, in JSON format.
+Each entry includes the restart count, the last termination reason and exit code (OOMKilled, error exits),
+the current waiting reason such as CrashLoopBackOff, and whether restarts are rising within the window.
+Use Cases:
+Find crash looping or OOM killed workloads.
+Start a diagnosis before fetching logs with podsLogs.`
+
+func NewRestartsTool(c echo.Context) server.ServerTool {
+	tool := mcp.NewTool("containerRestarts",
+		mcp.WithDescription(restartsTemplate),
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{ReadOnlyHint: mcp.ToBoolPtr(true)}),
+		mcp.WithString("namespace",
+			mcp.Description("Only report containers in this namespace. Leave empty for all namespaces."),
+		),
+		mcp.WithString("min",
+			mcp.Description("Minimum restart count to report, defaults to 3."),
+		),
+		mcp.WithString("window",
+			mcp.Description("Window used to compute the restart trend, such as 1h or 24h."),
+		),
+	)
+
+	handler := func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		target := helpers.BuildURL(c, "containerRestarts", "", request.GetString("namespace", ""))
+		for _, param := range []string{"min", "window"} {
+			if value := request.GetString(param, ""); value != "" {
+				target = fmt.Sprintf("%s&%s=%s", target, param, url.QueryEscape(value))
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			log.Error("failed to fetch restarts", "err", err)
+			return mcp.NewToolResultError(err.Error()), err
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := fmt.Errorf("bad status code: %d", resp.StatusCode)
+			return mcp.NewToolResultError(string(body)), err
+		}
+		return mcp.NewToolResultText(string(body)), nil
+	}
+
+	return NewServerTool(tool, handler)
+}
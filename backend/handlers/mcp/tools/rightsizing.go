@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pramodksahoo/kubechat/backend/handlers/mcp/helpers"
+)
+
+const rightSizingTemplate = `Recommend CPU and memory requests for deployments from their recorded usage, in JSON format.
+Each recommendation compares p95 CPU and peak memory usage with the current requests and limits of every container,
+says whether to increase, decrease or set each request, and carries a patch command. Submitting that command to the
+command executions API applies the change through the usual safety checks and approvals.
+Use Cases:
+Find over- or under-provisioned workloads.
+Explain throttling or OOM kills caused by requests and limits.
+Prepare the patch command that right-sizes a deployment.`
+
+func NewRightSizingTool(c echo.Context) server.ServerTool {
+	tool := mcp.NewTool("workloadRightSizing",
+		mcp.WithDescription(rightSizingTemplate),
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{ReadOnlyHint: mcp.ToBoolPtr(true)}),
+		mcp.WithString("namespace",
+			mcp.Description("Only recommend for deployments in this namespace. Leave empty for all namespaces."),
+		),
+		mcp.WithString("deployment",
+			mcp.Description("Only recommend for the deployment with this name."),
+		),
+		mcp.WithString("window",
+			mcp.Description("Usage history to consider, such as 24h or 168h."),
+		),
+	)
+
+	handler := func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		target := helpers.BuildURL(c, "workloadRightSizing", "", request.GetString("namespace", ""))
+		for _, param := range []string{"deployment", "window"} {
+			if value := request.GetString(param, ""); value != "" {
+				target = fmt.Sprintf("%s&%s=%s", target, param, url.QueryEscape(value))
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			log.Error("failed to fetch right-sizing recommendations", "err", err)
+			return mcp.NewToolResultError(err.Error()), err
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := fmt.Errorf("bad status code: %d", resp.StatusCode)
+			return mcp.NewToolResultError(string(body)), err
+		}
+		return mcp.NewToolResultText(string(body)), nil
+	}
+
+	return NewServerTool(tool, handler)
+}
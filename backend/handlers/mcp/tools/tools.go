@@ -51,6 +51,8 @@ func ListTool(c echo.Context, appContainer container.Container) Toolset {
 
 	// logs specific tool
 	toolset.ReadOnlyTools = append(toolset.ReadOnlyTools, NewLogsTool(c))
+	toolset.ReadOnlyTools = append(toolset.ReadOnlyTools, NewRestartsTool(c))
+	toolset.ReadOnlyTools = append(toolset.ReadOnlyTools, NewRightSizingTool(c))
 
 	return toolset
 }
@@ -0,0 +1,244 @@
+package ai
+
+import (
+	"slices"
+	"time"
+)
+
+// adaptiveSamples bounds the recent latencies kept per provider for its p95.
+const adaptiveSamples = 200
+
+// Floors added to latencies and costs before they are compared, so that free local providers and
+// calls answered within a millisecond do not outweigh every other provider.
+const (
+	latencyFloorMs = 1
+	costFloorUSD   = 0.0001
+)
+
+// AdaptivePolicy tunes how the chain reorders its shared providers from what it observes of them.
+// Smoothing is the EWMA weight of the latest call in the error rate and cost per request. A
+// provider only moves ahead of another when its weight is higher by more than Hysteresis, as a
+// fraction, so providers of similar weight do not trade places on every call.
+type AdaptivePolicy struct {
+	LatencyWeight float64 `json:"latencyWeight"`
+	CostWeight    float64 `json:"costWeight"`
+	Smoothing     float64 `json:"smoothing"`
+	Hysteresis    float64 `json:"hysteresis"`
+	// MinSamples is how many calls a provider serves before it is moved.
+	MinSamples int `json:"minSamples"`
+}
+
+var DefaultAdaptivePolicy = AdaptivePolicy{LatencyWeight: 0.7, CostWeight: 0.3, Smoothing: 0.1, Hysteresis: 0.2, MinSamples: 20}
+
+type ErrInvalidAdaptivePolicy struct {
+	Reason string
+}
+
+func (e ErrInvalidAdaptivePolicy) Error() string {
+	return "invalid adaptive routing policy: " + e.Reason
+}
+
+func (p AdaptivePolicy) validate() error {
+	switch {
+	case p.LatencyWeight < 0 || p.CostWeight < 0 || p.LatencyWeight+p.CostWeight == 0:
+		return ErrInvalidAdaptivePolicy{Reason: "latency and cost weights must not be negative and one must be positive"}
+	case p.Smoothing <= 0 || p.Smoothing > 1:
+		return ErrInvalidAdaptivePolicy{Reason: "smoothing must be in (0, 1]"}
+	case p.Hysteresis < 0:
+		return ErrInvalidAdaptivePolicy{Reason: "hysteresis must not be negative"}
+	case p.MinSamples < 1:
+		return ErrInvalidAdaptivePolicy{Reason: "minimum samples must be positive"}
+	}
+	return nil
+}
+
+// ProviderWeight is the weight the chain computed for a shared provider and the inputs behind it.
+// Providers with fewer than the policy's MinSamples calls have no weight yet and keep their place.
+type ProviderWeight struct {
+	Provider          string  `json:"provider"`
+	Rank              int     `json:"rank"`
+	Weight            float64 `json:"weight"`
+	Samples           int     `json:"samples"`
+	P95LatencyMs      float64 `json:"p95LatencyMs"`
+	ErrorRate         float64 `json:"errorRate"`
+	CostPerRequestUSD float64 `json:"costPerRequestUsd"`
+	LatencyScore      float64 `json:"latencyScore"`
+	CostScore         float64 `json:"costScore"`
+}
+
+// AdaptiveStatus is the live state of adaptive routing. Weights are in the order providers are
+// tried.
+type AdaptiveStatus struct {
+	Enabled     bool             `json:"enabled"`
+	Policy      *AdaptivePolicy  `json:"policy,omitempty"`
+	Weights     []ProviderWeight `json:"weights"`
+	ReorderedAt *time.Time       `json:"reorderedAt,omitempty"`
+}
+
+// providerStats are the observations of one shared provider adaptive routing weighs. Latencies
+// are kept in arrival order and sorted, so the p95 is read without sorting on every call.
+type providerStats struct {
+	samples   int
+	latencies []time.Duration
+	sorted    []time.Duration
+	errorRate float64
+	cost      float64
+}
+
+func (s *providerStats) observe(err error, elapsed time.Duration, cost float64, smoothing float64) {
+	failed := 0.0
+	if err != nil {
+		failed = 1
+	}
+	if s.samples == 0 {
+		s.errorRate, s.cost = failed, cost
+	} else {
+		s.errorRate += smoothing * (failed - s.errorRate)
+		s.cost += smoothing * (cost - s.cost)
+	}
+	s.samples++
+	if len(s.latencies) == adaptiveSamples {
+		oldest, _ := slices.BinarySearch(s.sorted, s.latencies[0])
+		s.sorted = slices.Delete(s.sorted, oldest, oldest+1)
+		s.latencies = s.latencies[1:]
+	}
+	s.latencies = append(s.latencies, elapsed)
+	at, _ := slices.BinarySearch(s.sorted, elapsed)
+	s.sorted = slices.Insert(s.sorted, at, elapsed)
+}
+
+func (s *providerStats) p95() time.Duration {
+	if len(s.sorted) == 0 {
+		return 0
+	}
+	return s.sorted[(len(s.sorted)*95-1)/100]
+}
+
+// SetAdaptive reorders the shared providers by their observed p95 latency, error rate and cost
+// per request instead of keeping the configured order. The configured primary still receives
+// explicitly requested models.
+func (c *FallbackChain) SetAdaptive(policy AdaptivePolicy) error {
+	if err := policy.validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.adaptive = &policy
+	if c.order == nil {
+		c.order = make([]int, len(c.providers))
+		for i := range c.providers {
+			c.order[i] = i
+		}
+		c.stats = make(map[string]*providerStats, len(c.providers))
+	}
+	return nil
+}
+
+// Adaptive returns the weights adaptive routing computed and the order they put providers in.
+func (c *FallbackChain) Adaptive() AdaptiveStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	status := AdaptiveStatus{Enabled: c.adaptive != nil, Weights: []ProviderWeight{}}
+	if c.adaptive == nil {
+		return status
+	}
+	policy := *c.adaptive
+	status.Policy = &policy
+	if !c.reorderedAt.IsZero() {
+		reordered := c.reorderedAt
+		status.ReorderedAt = &reordered
+	}
+	weights := c.weightsLocked()
+	for rank, i := range c.order {
+		weight := weights[i]
+		weight.Rank = rank + 1
+		status.Weights = append(status.Weights, weight)
+	}
+	return status
+}
+
+// orderLocked returns the indexes of the shared providers in the order they are tried.
+func (c *FallbackChain) orderLocked() []int {
+	if c.order != nil {
+		return slices.Clone(c.order)
+	}
+	order := make([]int, len(c.providers))
+	for i := range c.providers {
+		order[i] = i
+	}
+	return order
+}
+
+// adaptLocked records a call to the shared provider and reorders the providers when the weights
+// moved past the hysteresis.
+func (c *FallbackChain) adaptLocked(provider Provider, err error, elapsed time.Duration, cost float64) {
+	if c.adaptive == nil {
+		return
+	}
+	stats, ok := c.stats[provider.Name()]
+	if !ok {
+		stats = &providerStats{}
+		c.stats[provider.Name()] = stats
+	}
+	stats.observe(err, elapsed, cost, c.adaptive.Smoothing)
+
+	weights := c.weightsLocked()
+	margin := 1 + c.adaptive.Hysteresis
+	ranked := func(i int) bool { return weights[i].Samples >= c.adaptive.MinSamples }
+	// beats is transitive, so swapping neighbours until none beats the one before it terminates.
+	beats := func(a, b int) bool { return ranked(a) && ranked(b) && weights[a].Weight > weights[b].Weight*margin }
+	moved := false
+	for swapped := true; swapped; {
+		swapped = false
+		for k := 1; k < len(c.order); k++ {
+			if beats(c.order[k], c.order[k-1]) {
+				c.order[k], c.order[k-1] = c.order[k-1], c.order[k]
+				swapped, moved = true, true
+			}
+		}
+	}
+	if moved {
+		c.reorderedAt = time.Now().UTC()
+	}
+}
+
+// weightsLocked computes the weight of every shared provider, by index. Latency and cost are
+// scored relative to the fastest and cheapest ranked provider and the blend is discounted by the
+// error rate.
+func (c *FallbackChain) weightsLocked() []ProviderWeight {
+	policy := c.adaptive
+	weights := make([]ProviderWeight, len(c.providers))
+	fastest, cheapest := -1.0, -1.0
+	for i, provider := range c.providers {
+		weights[i].Provider = provider.Name()
+		stats, ok := c.stats[provider.Name()]
+		if !ok {
+			continue
+		}
+		weight := &weights[i]
+		weight.Samples = stats.samples
+		weight.P95LatencyMs = float64(stats.p95().Microseconds()) / 1000
+		weight.ErrorRate = stats.errorRate
+		weight.CostPerRequestUSD = stats.cost
+		if weight.Samples < policy.MinSamples {
+			continue
+		}
+		if fastest < 0 || weight.P95LatencyMs < fastest {
+			fastest = weight.P95LatencyMs
+		}
+		if cheapest < 0 || weight.CostPerRequestUSD < cheapest {
+			cheapest = weight.CostPerRequestUSD
+		}
+	}
+	for i := range weights {
+		weight := &weights[i]
+		if weight.Samples < policy.MinSamples {
+			continue
+		}
+		weight.LatencyScore = (fastest + latencyFloorMs) / (weight.P95LatencyMs + latencyFloorMs)
+		weight.CostScore = (cheapest + costFloorUSD) / (weight.CostPerRequestUSD + costFloorUSD)
+		blend := (policy.LatencyWeight*weight.LatencyScore + policy.CostWeight*weight.CostScore) / (policy.LatencyWeight + policy.CostWeight)
+		weight.Weight = (1 - weight.ErrorRate) * blend
+	}
+	return weights
+}
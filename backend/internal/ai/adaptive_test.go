@@ -0,0 +1,79 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAdaptiveRoutingMovesFailingProvidersBack(t *testing.T) {
+	flaky := &modelEcho{name: "flaky", model: "m1", fail: map[string]bool{"m1": true}}
+	steady := &modelEcho{name: "steady", model: "m2"}
+	chain := NewFallbackChain(flaky, steady)
+	if err := chain.SetAdaptive(AdaptivePolicy{LatencyWeight: 1, Smoothing: 0.5, Hysteresis: 0.2, MinSamples: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if status := chain.Adaptive(); !status.Enabled || status.Weights[0].Provider != "flaky" || status.Weights[0].Weight != 0 {
+		t.Fatalf("expected the configured order before any calls, got %+v", status)
+	}
+
+	for range 3 {
+		if _, err := chain.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	status := chain.Adaptive()
+	if status.Weights[0].Provider != "steady" || status.Weights[0].Rank != 1 || status.ReorderedAt == nil {
+		t.Fatalf("expected the steady provider to move ahead, got %+v", status)
+	}
+	if flakyWeight := status.Weights[1]; flakyWeight.ErrorRate != 1 || flakyWeight.Weight != 0 || flakyWeight.Samples != 3 {
+		t.Fatalf("expected the flaky provider's inputs to be reported, got %+v", flakyWeight)
+	}
+	chain.Complete(context.Background(), CompletionRequest{Prompt: "hi"})
+	if len(flaky.seen) != 3 {
+		t.Fatalf("expected the flaky provider not to be tried first any more, got %v", flaky.seen)
+	}
+	if providers := chain.Providers(); providers[0].Name != "steady" || !providers[1].Primary {
+		t.Fatalf("expected providers in the adapted order with the configured primary, got %+v", providers)
+	}
+}
+
+func TestAdaptiveRoutingHoldsOrderWithinHysteresis(t *testing.T) {
+	first := &modelEcho{name: "first", model: "m1", fail: map[string]bool{}}
+	second := &modelEcho{name: "second", model: "m2"}
+	chain := NewFallbackChain(first, second)
+	// Weighing cost alone, which is nothing for both, leaves the error rate to decide the order.
+	if err := chain.SetAdaptive(AdaptivePolicy{CostWeight: 1, Smoothing: 0.1, Hysteresis: 0.2, MinSamples: 1}); err != nil {
+		t.Fatal(err)
+	}
+	complete := func() {
+		if _, err := chain.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	for range 5 {
+		complete()
+	}
+	// One failure in six leaves first 10% behind second, which served it.
+	first.fail["m1"] = true
+	complete()
+	if status := chain.Adaptive(); status.Weights[0].Provider != "first" || status.ReorderedAt != nil {
+		t.Fatalf("expected a provider 10%% behind not to lose its place, got %+v", status)
+	}
+	complete()
+	status := chain.Adaptive()
+	if status.Weights[0].Provider != "second" || status.Weights[1].ErrorRate < 0.18 || status.ReorderedAt == nil {
+		t.Fatalf("expected the provider past the hysteresis to move ahead, got %+v", status)
+	}
+	first.fail["m1"] = false
+	complete()
+	if len(first.seen) != 7 || len(second.seen) != 3 {
+		t.Fatalf("expected the next call to go to second first, got %v and %v", first.seen, second.seen)
+	}
+
+	var invalid ErrInvalidAdaptivePolicy
+	if err := chain.SetAdaptive(AdaptivePolicy{Smoothing: 0.1, MinSamples: 1}); !errors.As(err, &invalid) {
+		t.Fatalf("expected a policy without weights to be refused, got %v", err)
+	}
+}
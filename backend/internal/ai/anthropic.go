@@ -0,0 +1,286 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultAnthropicURL   = "https://api.anthropic.com"
+	DefaultAnthropicModel = "claude-sonnet-4-20250514"
+	anthropicVersion      = "2023-06-01"
+	// anthropicMaxTokens is used when the request does not set MaxTokens; the Messages API requires one.
+	anthropicMaxTokens = 1024
+	// anthropicContextWindow applies to models the Models API lists without their limits.
+	anthropicContextWindow = 200000
+)
+
+type AnthropicProvider struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+func NewAnthropicProvider(baseURL, apiKey, model string, client *http.Client) *AnthropicProvider {
+	if strings.TrimSpace(baseURL) == "" {
+		baseURL = DefaultAnthropicURL
+	}
+	if strings.TrimSpace(model) == "" {
+		model = DefaultAnthropicModel
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+	return &AnthropicProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+		client:  client,
+	}
+}
+
+func (p *AnthropicProvider) Name() string {
+	return "anthropic"
+}
+
+func (p *AnthropicProvider) Model() string {
+	return p.model
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature float64            `json:"temperature"`
+	Stream      bool               `json:"stream,omitempty"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type anthropicResponse struct {
+	Model   string `json:"model"`
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage anthropicUsage `json:"usage"`
+}
+
+// anthropicEvent covers the fields used from the Messages streaming events.
+type anthropicEvent struct {
+	Type    string             `json:"type"`
+	Message *anthropicResponse `json:"message,omitempty"`
+	Delta   struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Usage *anthropicUsage `json:"usage,omitempty"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func (p *AnthropicProvider) Complete(ctx context.Context, req CompletionRequest) (CompletionResponse, error) {
+	resp, err := p.send(ctx, req, false)
+	if err != nil {
+		return CompletionResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	var decoded anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return CompletionResponse{}, err
+	}
+
+	var text strings.Builder
+	for _, block := range decoded.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	return CompletionResponse{
+		Provider: p.Name(),
+		Model:    decoded.Model,
+		Text:     text.String(),
+		Usage: Usage{
+			PromptTokens:     decoded.Usage.InputTokens,
+			CompletionTokens: decoded.Usage.OutputTokens,
+		},
+	}, nil
+}
+
+func (p *AnthropicProvider) Stream(ctx context.Context, req CompletionRequest, onDelta func(delta string) error) (CompletionResponse, error) {
+	resp, err := p.send(ctx, req, true)
+	if err != nil {
+		return CompletionResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	result := CompletionResponse{Provider: p.Name()}
+	var text strings.Builder
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var event anthropicEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			return CompletionResponse{}, fmt.Errorf("decode anthropic stream event: %w", err)
+		}
+
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				result.Model = event.Message.Model
+				result.Usage.PromptTokens = event.Message.Usage.InputTokens
+				result.Usage.CompletionTokens = event.Message.Usage.OutputTokens
+			}
+		case "content_block_delta":
+			if event.Delta.Type != "text_delta" || event.Delta.Text == "" {
+				continue
+			}
+			text.WriteString(event.Delta.Text)
+			if err := onDelta(event.Delta.Text); err != nil {
+				return CompletionResponse{}, err
+			}
+		case "message_delta":
+			// Output token counts in message_delta are cumulative.
+			if event.Usage != nil {
+				result.Usage.CompletionTokens = event.Usage.OutputTokens
+			}
+		case "error":
+			if event.Error != nil {
+				return CompletionResponse{}, fmt.Errorf("anthropic stream error: %s: %s", event.Error.Type, event.Error.Message)
+			}
+			return CompletionResponse{}, fmt.Errorf("anthropic stream error")
+		case "message_stop":
+			result.Text = text.String()
+			return result, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return CompletionResponse{}, err
+	}
+	return CompletionResponse{}, io.ErrUnexpectedEOF
+}
+
+func (p *AnthropicProvider) send(ctx context.Context, req CompletionRequest, stream bool) (*http.Response, error) {
+	model := req.Model
+	if model == "" {
+		model = p.model
+	}
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = anthropicMaxTokens
+	}
+
+	body, err := json.Marshal(anthropicRequest{
+		Model:       model,
+		System:      req.System,
+		Messages:    []anthropicMessage{{Role: "user", Content: req.Prompt}},
+		MaxTokens:   maxTokens,
+		Temperature: req.Temperature,
+		Stream:      stream,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", p.apiKey)
+	httpReq.Header.Set("anthropic-version", anthropicVersion)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		payload, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, ErrProviderStatus{Provider: p.Name(), Status: resp.StatusCode, Body: string(payload)}
+	}
+	return resp, nil
+}
+
+// HealthCheck confirms the API key is accepted by listing models.
+func (p *AnthropicProvider) HealthCheck(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/v1/models?limit=1", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-api-key", p.apiKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+	return checkStatus(p.client, req, p.Name())
+}
+
+type anthropicModelList struct {
+	Data []struct {
+		ID             string `json:"id"`
+		DisplayName    string `json:"display_name"`
+		MaxInputTokens int    `json:"max_input_tokens"`
+		MaxTokens      int    `json:"max_tokens"`
+	} `json:"data"`
+	HasMore bool   `json:"has_more"`
+	LastID  string `json:"last_id"`
+}
+
+// ListModels pages through the Models API. Every Claude model supports tool use.
+func (p *AnthropicProvider) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	var models []ModelInfo
+	query := url.Values{"limit": {"1000"}}
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/v1/models?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("x-api-key", p.apiKey)
+		req.Header.Set("anthropic-version", anthropicVersion)
+		var page anthropicModelList
+		if err := decodeJSON(p.client, req, p.Name(), &page); err != nil {
+			return nil, err
+		}
+		for _, model := range page.Data {
+			info := ModelInfo{
+				ID:              model.ID,
+				DisplayName:     model.DisplayName,
+				ContextWindow:   model.MaxInputTokens,
+				MaxOutputTokens: model.MaxTokens,
+				FunctionCalling: true,
+			}
+			if info.ContextWindow == 0 {
+				info.ContextWindow = anthropicContextWindow
+			}
+			models = append(models, info)
+		}
+		if !page.HasMore || page.LastID == "" {
+			return models, nil
+		}
+		query.Set("after_id", page.LastID)
+	}
+}
@@ -0,0 +1,109 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAnthropicProviderComplete(t *testing.T) {
+	var received anthropicRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "secret" || r.Header.Get("anthropic-version") == "" {
+			t.Fatalf("missing authentication headers: %v", r.Header)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		fmt.Fprint(w, `{"model":"claude-test","content":[{"type":"text","text":"medium"}],"usage":{"input_tokens":30,"output_tokens":2}}`)
+	}))
+	defer server.Close()
+
+	provider := NewAnthropicProvider(server.URL, "secret", "claude-test", server.Client())
+	resp, err := provider.Complete(context.Background(), CompletionRequest{System: "classify", Prompt: "kubectl scale"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if received.Model != "claude-test" || received.MaxTokens != anthropicMaxTokens || received.System != "classify" {
+		t.Fatalf("unexpected request: %+v", received)
+	}
+	if resp.Text != "medium" || resp.Usage.PromptTokens != 30 || resp.Usage.CompletionTokens != 2 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestAnthropicProviderStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"type":"message_start","message":{"model":"claude-test","usage":{"input_tokens":12,"output_tokens":1}}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" there"}}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":4}}`,
+			`{"type":"message_stop"}`,
+		} {
+			fmt.Fprintf(w, "event: x\ndata: %s\n\n", event)
+		}
+	}))
+	defer server.Close()
+
+	provider := NewAnthropicProvider(server.URL, "secret", "", server.Client())
+	var deltas []string
+	resp, err := provider.Stream(context.Background(), CompletionRequest{Prompt: "hi"}, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(deltas, "|") != "Hello| there" || resp.Text != "Hello there" {
+		t.Fatalf("unexpected stream output %v / %q", deltas, resp.Text)
+	}
+	if resp.Model != "claude-test" || resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 4 {
+		t.Fatalf("unexpected usage: %+v", resp)
+	}
+}
+
+func TestFallbackChainFallsBackAndAccountsTokens(t *testing.T) {
+	failing := &scriptedProvider{err: ErrProviderStatus{Provider: "scripted", Status: http.StatusServiceUnavailable}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req anthropicRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Model != "claude-sonnet-4-test" {
+			t.Fatalf("expected fallback provider to use its own model, got %q", req.Model)
+		}
+		fmt.Fprint(w, `{"model":"claude-sonnet-4-test","content":[{"type":"text","text":"low"}],"usage":{"input_tokens":10,"output_tokens":1}}`)
+	}))
+	defer server.Close()
+
+	chain := NewFallbackChain(failing, NewAnthropicProvider(server.URL, "secret", "claude-sonnet-4-test", server.Client()))
+	resp, err := chain.Complete(context.Background(), CompletionRequest{Model: "llama3.2", Prompt: "classify"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Provider != "anthropic" || resp.Text != "low" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+
+	providers := chain.Providers()
+	if len(providers) != 2 || !providers[0].Primary || providers[0].Usage.Failures != 1 {
+		t.Fatalf("unexpected primary accounting: %+v", providers)
+	}
+	if usage := providers[1].Usage; usage.CostUSD == 0 || usage.Requests != 1 || usage.PromptTokens != 10 || usage.CompletionTokens != 1 || !providers[1].Streaming {
+		t.Fatalf("unexpected fallback accounting: %+v", providers[1])
+	}
+}
+
+func TestFallbackChainReportsAllFailures(t *testing.T) {
+	chain := NewFallbackChain(&scriptedProvider{err: fmt.Errorf("down")})
+	if _, err := chain.Stream(context.Background(), CompletionRequest{}, func(string) error { return nil }); err == nil || !strings.Contains(err.Error(), "scripted: down") {
+		t.Fatalf("expected joined provider error, got %v", err)
+	}
+}
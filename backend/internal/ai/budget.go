@@ -0,0 +1,51 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrBudgetExceeded reports a spending budget a request is past. Past a hard limit requests are
+// refused; past a soft limit they are served by local providers only, and refused when none can
+// serve them.
+type ErrBudgetExceeded struct {
+	Budget   string  `json:"budget"`
+	User     string  `json:"user,omitempty"`
+	Spent    float64 `json:"spent"`
+	Limit    float64 `json:"limit"`
+	Currency string  `json:"currency"`
+	Hard     bool    `json:"hard"`
+}
+
+func (e ErrBudgetExceeded) Error() string {
+	kind := "soft"
+	if e.Hard {
+		kind = "hard"
+	}
+	return fmt.Sprintf("model budget %s reached its %s limit: %.2f of %.2f %s spent this month", e.Budget, kind, e.Spent, e.Limit, e.Currency)
+}
+
+// Budgets cap what is spent on hosted providers. Enforce returns ErrBudgetExceeded when a request
+// for user is past a budget, and records what the chain does about it.
+type Budgets interface {
+	Enforce(ctx context.Context, user string) error
+}
+
+// SetBudgets keeps requests past a soft budget limit on local providers and refuses requests past
+// a hard limit.
+func (c *FallbackChain) SetBudgets(budgets Budgets) {
+	c.budgets = budgets
+}
+
+// budget returns the budget a request for user is past, or nil.
+func (c *FallbackChain) budget(ctx context.Context, user string) *ErrBudgetExceeded {
+	if c.budgets == nil {
+		return nil
+	}
+	var exceeded ErrBudgetExceeded
+	if err := c.budgets.Enforce(ctx, user); errors.As(err, &exceeded) {
+		return &exceeded
+	}
+	return nil
+}
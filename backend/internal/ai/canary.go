@@ -0,0 +1,258 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// latencySamples bounds the recent latencies kept per canary arm for percentile estimates.
+const latencySamples = 500
+
+var ErrNoCanary = errors.New("provider has no canary rollout in progress")
+
+type ErrProviderNotFound struct {
+	Name string
+}
+
+func (e ErrProviderNotFound) Error() string {
+	return fmt.Sprintf("model provider %q is not configured", e.Name)
+}
+
+type ErrInvalidCanary struct {
+	Reason string
+}
+
+func (e ErrInvalidCanary) Error() string {
+	return e.Reason
+}
+
+// CanaryConfig sends Percent of a provider's default-model traffic to Model.
+type CanaryConfig struct {
+	Model   string `json:"model"`
+	Percent int    `json:"percent"`
+}
+
+// ArmMetrics compares one side of a canary rollout. Error and empty response rates stand in for
+// quality; latency and cost come from the provider's responses.
+type ArmMetrics struct {
+	Model            string  `json:"model"`
+	Requests         int     `json:"requests"`
+	Failures         int     `json:"failures"`
+	ErrorRate        float64 `json:"errorRate"`
+	EmptyResponses   int     `json:"emptyResponses"`
+	EmptyRate        float64 `json:"emptyRate"`
+	AvgLatencyMs     float64 `json:"avgLatencyMs"`
+	P95LatencyMs     float64 `json:"p95LatencyMs"`
+	PromptTokens     int     `json:"promptTokens"`
+	CompletionTokens int     `json:"completionTokens"`
+	CostUSD          float64 `json:"costUsd"`
+	AvgCostUSD       float64 `json:"avgCostUsd"`
+}
+
+// CanaryStatus reports a rollout with the baseline and candidate metrics side by side.
+type CanaryStatus struct {
+	Provider  string     `json:"provider"`
+	Percent   int        `json:"percent"`
+	StartedAt time.Time  `json:"startedAt"`
+	Baseline  ArmMetrics `json:"baseline"`
+	Candidate ArmMetrics `json:"candidate"`
+}
+
+type canaryArm struct {
+	model      string
+	requests   int
+	failures   int
+	empty      int
+	latency    time.Duration
+	samples    []time.Duration
+	prompt     int
+	completion int
+	cost       float64
+}
+
+func (a *canaryArm) observe(resp CompletionResponse, err error, elapsed time.Duration, cost float64) {
+	a.requests++
+	if err != nil {
+		a.failures++
+		return
+	}
+	if resp.Text == "" {
+		a.empty++
+	}
+	a.latency += elapsed
+	if len(a.samples) == latencySamples {
+		a.samples = a.samples[1:]
+	}
+	a.samples = append(a.samples, elapsed)
+	a.prompt += resp.Usage.PromptTokens
+	a.completion += resp.Usage.CompletionTokens
+	a.cost += cost
+}
+
+func (a *canaryArm) metrics() ArmMetrics {
+	m := ArmMetrics{
+		Model:            a.model,
+		Requests:         a.requests,
+		Failures:         a.failures,
+		EmptyResponses:   a.empty,
+		PromptTokens:     a.prompt,
+		CompletionTokens: a.completion,
+		CostUSD:          a.cost,
+	}
+	if a.requests > 0 {
+		m.ErrorRate = float64(a.failures) / float64(a.requests)
+	}
+	if succeeded := a.requests - a.failures; succeeded > 0 {
+		m.EmptyRate = float64(a.empty) / float64(succeeded)
+		m.AvgLatencyMs = float64(a.latency.Milliseconds()) / float64(succeeded)
+		m.AvgCostUSD = a.cost / float64(succeeded)
+	}
+	if len(a.samples) > 0 {
+		sorted := slices.Clone(a.samples)
+		slices.Sort(sorted)
+		m.P95LatencyMs = float64(sorted[(len(sorted)*95-1)/100].Milliseconds())
+	}
+	return m
+}
+
+type canary struct {
+	percent   int
+	startedAt time.Time
+	baseline  canaryArm
+	candidate canaryArm
+}
+
+func (c *canary) status(provider string) CanaryStatus {
+	return CanaryStatus{
+		Provider:  provider,
+		Percent:   c.percent,
+		StartedAt: c.startedAt,
+		Baseline:  c.baseline.metrics(),
+		Candidate: c.candidate.metrics(),
+	}
+}
+
+// StartCanary begins routing a share of provider's default-model traffic to cfg.Model. Calling it
+// again with the same model only changes the percentage and keeps the collected metrics.
+func (c *FallbackChain) StartCanary(provider string, cfg CanaryConfig) (CanaryStatus, error) {
+	member, err := c.member(provider)
+	if err != nil {
+		return CanaryStatus{}, err
+	}
+	if cfg.Model == "" {
+		return CanaryStatus{}, ErrInvalidCanary{Reason: "model is required"}
+	}
+	if cfg.Percent < 1 || cfg.Percent > 100 {
+		return CanaryStatus{}, ErrInvalidCanary{Reason: "percent must be between 1 and 100"}
+	}
+	if c.catalog != nil && c.catalog.Listed(provider) {
+		if _, ok := c.catalog.Lookup(provider, cfg.Model); !ok {
+			return CanaryStatus{}, ErrInvalidCanary{Reason: fmt.Sprintf("%s does not list model %s", provider, cfg.Model)}
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	baseline := c.modelLocked(member)
+	if cfg.Model == baseline {
+		return CanaryStatus{}, ErrInvalidCanary{Reason: fmt.Sprintf("%s already uses %s", provider, cfg.Model)}
+	}
+	current, ok := c.canaries[provider]
+	if !ok || current.candidate.model != cfg.Model {
+		current = &canary{
+			startedAt: time.Now().UTC(),
+			baseline:  canaryArm{model: baseline},
+			candidate: canaryArm{model: cfg.Model},
+		}
+		c.canaries[provider] = current
+	}
+	current.percent = cfg.Percent
+	return current.status(provider), nil
+}
+
+// Canary reports the rollout in progress for provider.
+func (c *FallbackChain) Canary(provider string) (CanaryStatus, error) {
+	if _, err := c.member(provider); err != nil {
+		return CanaryStatus{}, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	current, ok := c.canaries[provider]
+	if !ok {
+		return CanaryStatus{}, ErrNoCanary
+	}
+	return current.status(provider), nil
+}
+
+// PromoteCanary makes the candidate the provider's default model and ends the rollout. The
+// promotion lasts until restart; the provider's model setting should be updated to keep it.
+func (c *FallbackChain) PromoteCanary(provider string) (CanaryStatus, error) {
+	return c.finishCanary(provider, true)
+}
+
+// AbortCanary ends the rollout and sends all traffic back to the baseline model.
+func (c *FallbackChain) AbortCanary(provider string) (CanaryStatus, error) {
+	return c.finishCanary(provider, false)
+}
+
+func (c *FallbackChain) finishCanary(provider string, promote bool) (CanaryStatus, error) {
+	if _, err := c.member(provider); err != nil {
+		return CanaryStatus{}, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	current, ok := c.canaries[provider]
+	if !ok {
+		return CanaryStatus{}, ErrNoCanary
+	}
+	delete(c.canaries, provider)
+	if promote {
+		c.models[provider] = current.candidate.model
+	}
+	return current.status(provider), nil
+}
+
+func (c *FallbackChain) member(name string) (Provider, error) {
+	for _, provider := range c.providers {
+		if provider.Name() == name {
+			return provider, nil
+		}
+	}
+	return nil, ErrProviderNotFound{Name: name}
+}
+
+// modelLocked returns the default model of provider, including promoted canaries.
+func (c *FallbackChain) modelLocked(provider Provider) string {
+	if model, ok := c.models[provider.Name()]; ok {
+		return model
+	}
+	return providerModel(provider)
+}
+
+// routeLocked picks the model for a request to provider. Requests for a specific model other
+// than the default are left alone; the returned arm is nil when no canary is involved.
+func (c *FallbackChain) routeLocked(provider Provider, req CompletionRequest) (CompletionRequest, *canaryArm) {
+	baseline := c.modelLocked(provider)
+	if req.Model != "" && req.Model != baseline {
+		return req, nil
+	}
+	if promoted, ok := c.models[provider.Name()]; ok {
+		req.Model = promoted
+	}
+	current, ok := c.canaries[provider.Name()]
+	if !ok {
+		return req, nil
+	}
+	if c.roll() < current.percent {
+		req.Model = current.candidate.model
+		return req, &current.candidate
+	}
+	return req, &current.baseline
+}
+
+func defaultRoll() int {
+	return rand.IntN(100)
+}
@@ -0,0 +1,117 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// modelEcho answers with the model it was asked for and a fixed usage.
+type modelEcho struct {
+	name  string
+	model string
+	fail  map[string]bool
+	seen  []string
+}
+
+func (m *modelEcho) Name() string  { return m.name }
+func (m *modelEcho) Model() string { return m.model }
+
+func (m *modelEcho) Complete(ctx context.Context, req CompletionRequest) (CompletionResponse, error) {
+	model := req.Model
+	if model == "" {
+		model = m.model
+	}
+	m.seen = append(m.seen, model)
+	if m.fail[model] {
+		return CompletionResponse{}, errors.New("overloaded")
+	}
+	return CompletionResponse{Provider: m.name, Model: model, Text: "ok", Usage: Usage{PromptTokens: 1000, CompletionTokens: 100}}, nil
+}
+
+func TestCanarySplitsDefaultTrafficAndPromotes(t *testing.T) {
+	provider := &modelEcho{name: "anthropic", model: "claude-3-5-haiku", fail: map[string]bool{}}
+	chain := NewFallbackChain(provider)
+	rolls := 0
+	chain.roll = func() int { rolls++; return (rolls * 10) % 100 }
+
+	if _, err := chain.StartCanary("anthropic", CanaryConfig{Model: "claude-3-5-haiku", Percent: 10}); err == nil {
+		t.Fatalf("expected the current model to be rejected as a candidate")
+	}
+	if _, err := chain.StartCanary("openai", CanaryConfig{Model: "gpt-4o-mini", Percent: 10}); !errors.As(err, &ErrProviderNotFound{}) {
+		t.Fatalf("expected unknown provider, got %v", err)
+	}
+	if _, err := chain.StartCanary("anthropic", CanaryConfig{Model: "claude-sonnet-4", Percent: 30}); err != nil {
+		t.Fatalf("start canary: %v", err)
+	}
+
+	ctx := context.Background()
+	for range 10 {
+		if _, err := chain.Complete(ctx, CompletionRequest{Prompt: "hi"}); err != nil {
+			t.Fatalf("complete: %v", err)
+		}
+	}
+	// Explicit requests for another model bypass the rollout.
+	if _, err := chain.Complete(ctx, CompletionRequest{Model: "claude-opus-4", Prompt: "hi"}); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+
+	status, err := chain.Canary("anthropic")
+	if err != nil {
+		t.Fatalf("canary: %v", err)
+	}
+	if status.Candidate.Requests != 3 || status.Baseline.Requests != 7 {
+		t.Fatalf("expected a 3/7 split, got %+v", status)
+	}
+	if status.Candidate.AvgCostUSD <= status.Baseline.AvgCostUSD || status.Candidate.Model != "claude-sonnet-4" {
+		t.Fatalf("expected the candidate to cost more per request, got %+v", status)
+	}
+	if info := chain.Providers()[0]; info.Canary == nil || info.Model != "claude-3-5-haiku" {
+		t.Fatalf("expected provider info to include the canary, got %+v", info)
+	}
+
+	if _, err := chain.PromoteCanary("anthropic"); err != nil {
+		t.Fatalf("promote: %v", err)
+	}
+	if _, err := chain.Canary("anthropic"); !errors.Is(err, ErrNoCanary) {
+		t.Fatalf("expected the rollout to end, got %v", err)
+	}
+	provider.seen = nil
+	if _, err := chain.Complete(ctx, CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if provider.seen[0] != "claude-sonnet-4" || chain.Providers()[0].Model != "claude-sonnet-4" {
+		t.Fatalf("expected the promoted model to be the default, got %v", provider.seen)
+	}
+}
+
+func TestCanaryAbortRestoresBaseline(t *testing.T) {
+	provider := &modelEcho{name: "gemini", model: "gemini-2.0-flash", fail: map[string]bool{"gemini-2.5-flash": true}}
+	chain := NewFallbackChain(provider)
+	chain.roll = func() int { return 0 }
+
+	if _, err := chain.StartCanary("gemini", CanaryConfig{Model: "gemini-2.5-flash", Percent: 0}); !errors.As(err, &ErrInvalidCanary{}) {
+		t.Fatalf("expected invalid percent, got %v", err)
+	}
+	if _, err := chain.StartCanary("gemini", CanaryConfig{Model: "gemini-2.5-flash", Percent: 50}); err != nil {
+		t.Fatalf("start canary: %v", err)
+	}
+	if _, err := chain.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err == nil {
+		t.Fatalf("expected the failing candidate to surface an error")
+	}
+
+	status, err := chain.AbortCanary("gemini")
+	if err != nil {
+		t.Fatalf("abort: %v", err)
+	}
+	if status.Candidate.Failures != 1 || status.Candidate.ErrorRate != 1 {
+		t.Fatalf("expected the candidate failure to be recorded, got %+v", status.Candidate)
+	}
+	provider.seen = nil
+	if _, err := chain.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if provider.seen[0] != "gemini-2.0-flash" {
+		t.Fatalf("expected baseline after abort, got %v", provider.seen)
+	}
+}
@@ -0,0 +1,122 @@
+package ai
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Capabilities a request may require of the provider serving it.
+const (
+	CapabilityChat      = "chat"
+	CapabilityCode      = "code"
+	CapabilityEmbedding = "embedding"
+)
+
+// Latency classes of requests and providers. Interactive requests have a user waiting on them and
+// are not sent to batch providers; batch requests may be served by any provider.
+const (
+	LatencyInteractive = "interactive"
+	LatencyBatch       = "batch"
+)
+
+// defaultCapabilities are served by providers that do not report theirs: every adapter completes
+// prompts, which covers chat and code generation but not embeddings.
+var defaultCapabilities = []string{CapabilityChat, CapabilityCode}
+
+// ErrNoCapableProvider reports that no provider of the chain satisfies the constraints of a
+// request. Reasons holds why each provider was passed over, keyed by provider name.
+type ErrNoCapableProvider struct {
+	Capability      string
+	Model           string
+	MinOutputTokens int
+	Latency         string
+	Reasons         map[string]string
+}
+
+func (e ErrNoCapableProvider) Error() string {
+	var constraints []string
+	if e.Capability != "" {
+		constraints = append(constraints, "capability "+e.Capability)
+	}
+	if e.Model != "" {
+		constraints = append(constraints, "model "+e.Model)
+	}
+	if e.MinOutputTokens > 0 {
+		constraints = append(constraints, fmt.Sprintf("%d output tokens", e.MinOutputTokens))
+	}
+	if e.Latency != "" {
+		constraints = append(constraints, e.Latency+" latency")
+	}
+	names := make([]string, 0, len(e.Reasons))
+	for name := range e.Reasons {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	reasons := make([]string, 0, len(names))
+	for _, name := range names {
+		reasons = append(reasons, name+": "+e.Reasons[name])
+	}
+	return fmt.Sprintf("no model provider supports %s (%s)", strings.Join(constraints, ", "), strings.Join(reasons, "; "))
+}
+
+// capabilitiesOf returns what provider can serve.
+func capabilitiesOf(provider Provider) []string {
+	if reporter, ok := provider.(interface{ Capabilities() []string }); ok {
+		return reporter.Capabilities()
+	}
+	return defaultCapabilities
+}
+
+// latencyOf returns the latency class of provider; providers that do not report one are
+// interactive.
+func latencyOf(provider Provider) string {
+	if reporter, ok := provider.(interface{ LatencyClass() string }); ok && reporter.LatencyClass() != "" {
+		return reporter.LatencyClass()
+	}
+	return LatencyInteractive
+}
+
+func validateRouting(req CompletionRequest) error {
+	if req.Capability != "" && !slices.Contains([]string{CapabilityChat, CapabilityCode, CapabilityEmbedding}, req.Capability) {
+		return fmt.Errorf("unknown capability %q", req.Capability)
+	}
+	if req.Latency != "" && req.Latency != LatencyInteractive && req.Latency != LatencyBatch {
+		return fmt.Errorf("unknown latency class %q", req.Latency)
+	}
+	if req.MinOutputTokens < 0 {
+		return fmt.Errorf("minimum output tokens must not be negative")
+	}
+	return nil
+}
+
+// unqualified reports why the provider of m cannot serve routed, the request as it would be sent
+// to it, or "" when it can. A model is only known to be unavailable when the catalog listed the
+// provider's models without it.
+func (c *FallbackChain) unqualified(m member, routed CompletionRequest) string {
+	provider := m.provider
+	if routed.Capability != "" && !slices.Contains(capabilitiesOf(provider), routed.Capability) {
+		return "does not serve " + routed.Capability
+	}
+	if routed.Latency == LatencyInteractive && latencyOf(provider) == LatencyBatch {
+		return "serves batch requests only"
+	}
+	model := routed.Model
+	if model == "" {
+		model = providerModel(provider)
+	}
+	if c.catalog == nil || model == "" {
+		return ""
+	}
+	info, ok := c.catalog.Lookup(provider.Name(), model)
+	if !ok {
+		if routed.Model != "" && c.catalog.Listed(provider.Name()) {
+			return "does not serve model " + model
+		}
+		return ""
+	}
+	if routed.MinOutputTokens > 0 && info.MaxOutputTokens > 0 && info.MaxOutputTokens < routed.MinOutputTokens {
+		return fmt.Sprintf("%s produces at most %d output tokens", model, info.MaxOutputTokens)
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/commands"
+)
+
+const eventSummarySystemPrompt = `You are a Kubernetes troubleshooting assistant. Given the events recorded for some objects, explain in at most three sentences what is happening to them and the most likely cause.
+Name the object and the event reason your explanation rests on. If the events only show normal operation, say so in one sentence.
+Do not suggest commands and do not repeat the events verbatim.`
+
+// EventSummarizer asks a model to explain the events correlated with a command's objects, so an
+// answer to "why is my pod crashlooping" leads with the cause rather than a table of events.
+type EventSummarizer struct {
+	provider Provider
+	model    string
+}
+
+func NewEventSummarizer(provider Provider, model string) *EventSummarizer {
+	return &EventSummarizer{provider: provider, model: model}
+}
+
+func (s *EventSummarizer) SummarizeEvents(ctx context.Context, subjects []commands.Resource, events []commands.CorrelatedEvent) (string, error) {
+	var prompt strings.Builder
+	prompt.WriteString("Objects:\n")
+	for _, subject := range subjects {
+		fmt.Fprintf(&prompt, "- %s\n", subject)
+	}
+	prompt.WriteString("Events, warnings first and most recent first:\n")
+	for _, event := range events {
+		fmt.Fprintf(&prompt, "- %s %s on %s", event.Type, event.Reason, event.Object)
+		if event.Count > 1 {
+			fmt.Fprintf(&prompt, " (x%d)", event.Count)
+		}
+		fmt.Fprintf(&prompt, ": %s\n", event.Message)
+	}
+
+	resp, err := s.provider.Complete(ctx, CompletionRequest{
+		Model:       s.model,
+		System:      eventSummarySystemPrompt,
+		Prompt:      prompt.String(),
+		MaxTokens:   200,
+		Temperature: 0,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(resp.Text), nil
+}
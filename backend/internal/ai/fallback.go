@@ -0,0 +1,540 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/pramodksahoo/kubechat/backend/internal/ai"
+
+// ProviderUsage accumulates requests and tokens served by one provider.
+type ProviderUsage struct {
+	Requests         int     `json:"requests"`
+	Failures         int     `json:"failures"`
+	PromptTokens     int     `json:"promptTokens"`
+	CompletionTokens int     `json:"completionTokens"`
+	CostUSD          float64 `json:"costUsd"`
+	// Users is how many distinct users the provider served.
+	Users int `json:"users"`
+}
+
+type ProviderInfo struct {
+	Name      string        `json:"name"`
+	Model     string        `json:"model,omitempty"`
+	Primary   bool          `json:"primary"`
+	Streaming bool          `json:"streaming"`
+	Price     *Price        `json:"price,omitempty"`
+	Usage     ProviderUsage `json:"usage"`
+	Canary    *CanaryStatus `json:"canary,omitempty"`
+	// Capabilities describes the default model as the provider last listed it.
+	Capabilities *ModelInfo `json:"capabilities,omitempty"`
+	// Serves lists the request capabilities the provider is routed for.
+	Serves  []string `json:"serves"`
+	Latency string   `json:"latency"`
+}
+
+// FallbackChain tries providers in order until one succeeds and keeps per-provider token accounting.
+// A model requested explicitly only applies to the primary provider; fallbacks use their own default.
+// A provider with a canary rollout sends a share of its default-model requests to the candidate.
+// With a quota set, users who exhausted theirs are served by local providers only. With a model
+// catalog set, requests are kept within the output limit of the model they are routed to. With
+// breakers set, providers whose breaker is open are skipped. With adaptive routing set, shared
+// providers are tried in the order of their observed latency, error rate and cost. With budgets
+// set, requests past a soft limit are kept on local providers and requests past a hard limit are
+// refused. With user keys set, a user's own API keys are tried before the shared providers.
+// Providers that do not satisfy the capability, model, output and latency constraints of a
+// request are passed over.
+type FallbackChain struct {
+	providers []Provider
+	prices    Pricer
+	quota     *Quota
+	faults    FaultInjector
+	catalog   *ModelCatalog
+	metrics   Metrics
+	breakers  Breakers
+	recorder  UsageRecorder
+	budgets   Budgets
+	keys      UserKeys
+
+	mu    sync.Mutex
+	usage map[string]*ProviderUsage
+	// users holds the distinct users served, keyed by provider name.
+	users map[string]map[string]struct{}
+	// models holds default models replaced by a promoted canary, keyed by provider name.
+	models   map[string]string
+	canaries map[string]*canary
+	roll     func() int
+	// adaptive, when set, reorders the shared providers into order from their stats.
+	adaptive    *AdaptivePolicy
+	order       []int
+	stats       map[string]*providerStats
+	reorderedAt time.Time
+}
+
+func NewFallbackChain(providers ...Provider) *FallbackChain {
+	usage := make(map[string]*ProviderUsage, len(providers))
+	for _, provider := range providers {
+		usage[provider.Name()] = &ProviderUsage{}
+	}
+	return &FallbackChain{
+		providers: providers,
+		prices:    DefaultPrices,
+		usage:     usage,
+		users:     make(map[string]map[string]struct{}),
+		models:    make(map[string]string),
+		canaries:  make(map[string]*canary),
+		roll:      defaultRoll,
+	}
+}
+
+// SetPrices replaces the prices used for cost tracking, such as a PriceTable or a versioned price
+// book.
+func (c *FallbackChain) SetPrices(prices Pricer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prices = prices
+}
+
+// SetQuota charges hosted provider tokens to the user in the request context.
+func (c *FallbackChain) SetQuota(quota *Quota) {
+	c.quota = quota
+}
+
+// SetUsageRecorder reports the tokens of every provider call to recorder, attributed to the user
+// and chat session in the request context.
+func (c *FallbackChain) SetUsageRecorder(recorder UsageRecorder) {
+	c.recorder = recorder
+}
+
+// FaultInjector simulates provider outages during game days. Inject runs before every provider
+// call and may delay it or fail it in place of the provider.
+type FaultInjector interface {
+	Inject(ctx context.Context, provider string) error
+}
+
+// SetFaults routes every provider call through faults first.
+func (c *FallbackChain) SetFaults(faults FaultInjector) {
+	c.faults = faults
+}
+
+// SetModelCatalog checks canary candidates and request limits against the models providers list.
+func (c *FallbackChain) SetModelCatalog(catalog *ModelCatalog) {
+	c.catalog = catalog
+}
+
+// Metrics records provider calls for monitoring, such as Prometheus collectors.
+type Metrics interface {
+	ObserveProviderCall(provider, model string, promptTokens, completionTokens int, d time.Duration, failed bool)
+	// ObserveFallback counts a request passed on to provider to after provider from failed it.
+	ObserveFallback(from, to string)
+}
+
+// SetMetrics reports every provider call and fallback to metrics.
+func (c *FallbackChain) SetMetrics(metrics Metrics) {
+	c.metrics = metrics
+}
+
+// Breakers stop calls to providers that keep failing. Allow refuses a call to an upstream whose
+// breaker is open and Record reports how a call went.
+type Breakers interface {
+	Allow(ctx context.Context, upstream string) error
+	Record(ctx context.Context, upstream string, err error)
+}
+
+// SetBreakers skips providers whose breaker is open. A provider's upstream is BreakerUpstream of
+// its name.
+func (c *FallbackChain) SetBreakers(breakers Breakers) {
+	c.breakers = breakers
+}
+
+// BreakerUpstream names the breaker of the provider called name.
+func BreakerUpstream(name string) string {
+	return "nlp:" + name
+}
+
+func (c *FallbackChain) Name() string {
+	names := make([]string, 0, len(c.providers))
+	for _, provider := range c.providers {
+		names = append(names, provider.Name())
+	}
+	return strings.Join(names, ",")
+}
+
+func (c *FallbackChain) Complete(ctx context.Context, req CompletionRequest) (CompletionResponse, error) {
+	return c.attempt(ctx, req, func(ctx context.Context, provider Provider, req CompletionRequest) (CompletionResponse, error) {
+		return provider.Complete(ctx, req)
+	})
+}
+
+// Stream streams from the first provider that succeeds. Providers without streaming support
+// deliver their full completion as a single delta. Once output has been emitted the chain no
+// longer falls back, so callers never see text from two providers.
+func (c *FallbackChain) Stream(ctx context.Context, req CompletionRequest, onDelta func(delta string) error) (CompletionResponse, error) {
+	emitted := false
+	track := func(delta string) error {
+		emitted = true
+		return onDelta(delta)
+	}
+
+	if err := validateRouting(req); err != nil {
+		return CompletionResponse{}, err
+	}
+	user := identity.FromContext(ctx)
+	members, keysErr := c.members(ctx, user)
+	budget := c.budget(ctx, user)
+	if budget != nil && budget.Hard && !owned(members) {
+		return CompletionResponse{}, *budget
+	}
+	throttled := c.throttled(user) || budget != nil
+	var (
+		errs   []error
+		failed Provider
+		unfit  = ErrNoCapableProvider{Capability: req.Capability, Model: req.Model, MinOutputTokens: req.MinOutputTokens, Latency: req.Latency, Reasons: map[string]string{}}
+	)
+	for _, m := range members {
+		provider := m.provider
+		if m.owner == "" && (budget != nil && budget.Hard || throttled && !isLocal(provider)) {
+			continue
+		}
+		routed, arm := c.requestFor(m, req)
+		if reason := c.unqualified(m, routed); reason != "" {
+			unfit.Reasons[provider.Name()] = reason
+			continue
+		}
+		if err := c.admit(ctx, m); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+			continue
+		}
+		var (
+			resp CompletionResponse
+			err  error
+		)
+		c.fallback(failed, provider)
+		callCtx, span := startProviderSpan(ctx, provider, routed, failed)
+		started := time.Now()
+		err = c.inject(callCtx, provider)
+		if streaming, ok := provider.(StreamingProvider); ok && err == nil {
+			resp, err = streaming.Stream(callCtx, routed, track)
+		} else if err == nil {
+			if resp, err = provider.Complete(callCtx, routed); err == nil && resp.Text != "" {
+				err = track(resp.Text)
+			}
+		}
+		c.record(ctx, user, m, routed, resp, err, arm, time.Since(started))
+		c.trip(ctx, m, err)
+		endProviderSpan(span, resp, err)
+		if err == nil {
+			return resp, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+		failed = provider
+		if emitted || ctx.Err() != nil {
+			break
+		}
+	}
+	if keysErr != nil && len(errs) > 0 {
+		errs = append(errs, keysErr)
+	}
+	return CompletionResponse{}, c.failure(user, throttled, budget, unfit, errs)
+}
+
+func (c *FallbackChain) attempt(ctx context.Context, req CompletionRequest, call func(context.Context, Provider, CompletionRequest) (CompletionResponse, error)) (CompletionResponse, error) {
+	if err := validateRouting(req); err != nil {
+		return CompletionResponse{}, err
+	}
+	user := identity.FromContext(ctx)
+	members, keysErr := c.members(ctx, user)
+	budget := c.budget(ctx, user)
+	if budget != nil && budget.Hard && !owned(members) {
+		return CompletionResponse{}, *budget
+	}
+	throttled := c.throttled(user) || budget != nil
+	var (
+		errs   []error
+		failed Provider
+		unfit  = ErrNoCapableProvider{Capability: req.Capability, Model: req.Model, MinOutputTokens: req.MinOutputTokens, Latency: req.Latency, Reasons: map[string]string{}}
+	)
+	for _, m := range members {
+		provider := m.provider
+		if m.owner == "" && (budget != nil && budget.Hard || throttled && !isLocal(provider)) {
+			continue
+		}
+		routed, arm := c.requestFor(m, req)
+		if reason := c.unqualified(m, routed); reason != "" {
+			unfit.Reasons[provider.Name()] = reason
+			continue
+		}
+		if err := c.admit(ctx, m); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+			continue
+		}
+		c.fallback(failed, provider)
+		callCtx, span := startProviderSpan(ctx, provider, routed, failed)
+		started := time.Now()
+		var resp CompletionResponse
+		err := c.inject(callCtx, provider)
+		if err == nil {
+			resp, err = call(callCtx, provider, routed)
+		}
+		c.record(ctx, user, m, routed, resp, err, arm, time.Since(started))
+		c.trip(ctx, m, err)
+		endProviderSpan(span, resp, err)
+		if err == nil {
+			return resp, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+		failed = provider
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if keysErr != nil && len(errs) > 0 {
+		errs = append(errs, keysErr)
+	}
+	return CompletionResponse{}, c.failure(user, throttled, budget, unfit, errs)
+}
+
+// startProviderSpan starts the client span of one call to provider. A call made after failed,
+// the provider tried before it, failed is marked as a fallback.
+func startProviderSpan(ctx context.Context, provider Provider, req CompletionRequest, failed Provider) (context.Context, trace.Span) {
+	model := req.Model
+	if model == "" {
+		model = providerModel(provider)
+	}
+	attributes := []attribute.KeyValue{
+		attribute.String("gen_ai.system", provider.Name()),
+		attribute.String("gen_ai.request.model", model),
+	}
+	if failed != nil {
+		attributes = append(attributes, attribute.String("kubechat.nlp.fallback_from", failed.Name()))
+	}
+	return otel.Tracer(tracerName).Start(ctx, "nlp "+provider.Name(), trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+}
+
+func endProviderSpan(span trace.Span, resp CompletionResponse, err error) {
+	defer span.End()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+	span.SetAttributes(
+		attribute.String("gen_ai.response.model", resp.Model),
+		attribute.Int("gen_ai.usage.input_tokens", resp.Usage.PromptTokens),
+		attribute.Int("gen_ai.usage.output_tokens", resp.Usage.CompletionTokens),
+	)
+}
+
+// fallback reports that provider is tried because failed, the provider tried before it, failed.
+func (c *FallbackChain) fallback(failed, provider Provider) {
+	if c.metrics == nil || failed == nil {
+		return
+	}
+	c.metrics.ObserveFallback(failed.Name(), provider.Name())
+}
+
+// inject applies any simulated fault to a call to provider.
+func (c *FallbackChain) inject(ctx context.Context, provider Provider) error {
+	if c.faults == nil {
+		return nil
+	}
+	return c.faults.Inject(ctx, provider.Name())
+}
+
+// admit refuses a call to a shared provider while its breaker is open. A user's own key does not
+// share the breaker, so one user's revoked key cannot cut everyone off.
+func (c *FallbackChain) admit(ctx context.Context, m member) error {
+	if c.breakers == nil || m.owner != "" {
+		return nil
+	}
+	return c.breakers.Allow(ctx, BreakerUpstream(m.provider.Name()))
+}
+
+// trip reports a call to a shared provider to its breaker. Calls the caller gave up on are not
+// counted.
+func (c *FallbackChain) trip(ctx context.Context, m member, err error) {
+	if c.breakers == nil || m.owner != "" || ctx.Err() != nil {
+		return
+	}
+	c.breakers.Record(ctx, BreakerUpstream(m.provider.Name()), err)
+}
+
+// throttled reports whether user has exhausted their quota and must stay on local providers.
+func (c *FallbackChain) throttled(user string) bool {
+	return c.quota != nil && user != "" && !c.quota.Allow(user)
+}
+
+// requestFor routes req to m. A user's own key gets an explicitly requested model when it is for
+// the primary provider, and never takes part in canary rollouts.
+func (c *FallbackChain) requestFor(m member, req CompletionRequest) (CompletionRequest, *canaryArm) {
+	provider := m.provider
+	if m.index != 0 && (m.owner == "" || !c.primary(provider)) {
+		req.Model = ""
+	}
+	var (
+		routed = req
+		arm    *canaryArm
+		model  = req.Model
+	)
+	if m.owner == "" {
+		c.mu.Lock()
+		routed, arm = c.routeLocked(provider, req)
+		model = routed.Model
+		if model == "" {
+			model = c.modelLocked(provider)
+		}
+		c.mu.Unlock()
+	} else if model == "" {
+		model = providerModel(provider)
+	}
+
+	if c.catalog != nil {
+		if info, ok := c.catalog.Lookup(provider.Name(), model); ok && info.MaxOutputTokens > 0 && routed.MaxTokens > info.MaxOutputTokens {
+			routed.MaxTokens = info.MaxOutputTokens
+		}
+	}
+	return routed, arm
+}
+
+func (c *FallbackChain) failure(user string, throttled bool, budget *ErrBudgetExceeded, unfit ErrNoCapableProvider, errs []error) error {
+	if len(errs) == 0 && len(unfit.Reasons) > 0 {
+		return unfit
+	}
+	if len(errs) == 0 && budget != nil {
+		return *budget
+	}
+	if len(errs) == 0 && throttled {
+		return ErrQuotaExhausted{User: user, RetryAfter: c.quota.retryAfter(user)}
+	}
+	if len(errs) == 0 {
+		return errors.New("no model providers configured")
+	}
+	// Only the user's own keys were tried when a hard budget holds back the shared providers.
+	if budget != nil && budget.Hard {
+		errs = append(errs, *budget)
+	}
+	return fmt.Errorf("all model providers failed: %w", errors.Join(errs...))
+}
+
+func (c *FallbackChain) record(ctx context.Context, user string, m member, req CompletionRequest, resp CompletionResponse, err error, arm *canaryArm, elapsed time.Duration) {
+	provider := m.provider
+	if c.quota != nil && user != "" && m.owner == "" && !isLocal(provider) {
+		c.quota.Charge(user, resp.Usage.TotalTokens())
+	}
+	model := resp.Model
+	if model == "" {
+		model = req.Model
+	}
+	if model == "" {
+		model = providerModel(provider)
+	}
+	if c.metrics != nil {
+		c.metrics.ObserveProviderCall(provider.Name(), model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens, elapsed, err != nil)
+	}
+
+	var cost float64
+	if m.owner == "" {
+		cost = c.tally(user, provider, model, resp, err, arm, elapsed)
+	} else {
+		cost = c.price(provider, model, resp)
+	}
+	// Recorders may store usage remotely, so they are called without holding the chain's lock.
+	if c.recorder != nil && resp.Usage.TotalTokens() > 0 {
+		c.recorder.RecordUsage(ctx, UsageRecord{
+			At:               time.Now(),
+			User:             user,
+			Session:          SessionFromContext(ctx),
+			Provider:         provider.Name(),
+			Model:            model,
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			CostUSD:          cost,
+			KeyOwner:         m.owner,
+		})
+	}
+}
+
+// tally adds a call to the provider's totals and its canary arm, returning what it cost.
+func (c *FallbackChain) tally(user string, provider Provider, model string, resp CompletionResponse, err error, arm *canaryArm, elapsed time.Duration) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	totals := c.usage[provider.Name()]
+	totals.Requests++
+	if err != nil {
+		totals.Failures++
+	}
+	totals.PromptTokens += resp.Usage.PromptTokens
+	totals.CompletionTokens += resp.Usage.CompletionTokens
+	cost := c.priceLocked(provider, model, resp)
+	totals.CostUSD += cost
+	if user != "" {
+		if c.users[provider.Name()] == nil {
+			c.users[provider.Name()] = make(map[string]struct{})
+		}
+		c.users[provider.Name()][user] = struct{}{}
+		totals.Users = len(c.users[provider.Name()])
+	}
+	c.adaptLocked(provider, err, elapsed, cost)
+	if arm != nil {
+		arm.observe(resp, err, elapsed, cost)
+	}
+	return cost
+}
+
+// Providers describes the chain in fallback order.
+func (c *FallbackChain) Providers() []ProviderInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	infos := make([]ProviderInfo, 0, len(c.providers))
+	for _, i := range c.orderLocked() {
+		provider := c.providers[i]
+		info := ProviderInfo{Name: provider.Name(), Model: c.modelLocked(provider), Primary: i == 0, Usage: *c.usage[provider.Name()]}
+		if price, ok := c.prices.PriceAt(provider.Name(), info.Model, time.Now()); ok {
+			info.Price = &price
+		}
+		_, info.Streaming = provider.(StreamingProvider)
+		info.Serves, info.Latency = capabilitiesOf(provider), latencyOf(provider)
+		if current, ok := c.canaries[provider.Name()]; ok {
+			status := current.status(provider.Name())
+			info.Canary = &status
+		}
+		if c.catalog != nil {
+			if capabilities, ok := c.catalog.Lookup(provider.Name(), info.Model); ok {
+				info.Capabilities = &capabilities
+			}
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// Members returns the providers of the chain in fallback order.
+func (c *FallbackChain) Members() []Provider {
+	return append([]Provider(nil), c.providers...)
+}
+
+// isLocal reports whether provider runs on infrastructure kubechat operates, so its use is not
+// charged to quotas.
+func isLocal(provider Provider) bool {
+	local, ok := provider.(interface{ Local() bool })
+	return ok && local.Local()
+}
+
+func providerModel(provider Provider) string {
+	if described, ok := provider.(interface{ Model() string }); ok {
+		return described.Model()
+	}
+	return ""
+}
@@ -0,0 +1,332 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// outage fails every call to the named providers, like a chaos experiment.
+type outage map[string]error
+
+func (o outage) Inject(ctx context.Context, provider string) error {
+	return o[provider]
+}
+
+func TestFallbackChainStreamsFromNextProviderDuringOutage(t *testing.T) {
+	primary := &modelEcho{name: "anthropic", model: "claude-3-5-haiku"}
+	secondary := &modelEcho{name: "gemini", model: "gemini-2.0-flash"}
+	chain := NewFallbackChain(primary, secondary)
+	chain.SetFaults(outage{"anthropic": ErrProviderStatus{Provider: "anthropic", Status: http.StatusServiceUnavailable}})
+
+	var deltas []string
+	resp, err := chain.Stream(context.Background(), CompletionRequest{Prompt: "hi"}, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil || resp.Provider != "gemini" || len(deltas) != 1 {
+		t.Fatalf("expected gemini to serve the stream, got %+v %v: %v", resp, deltas, err)
+	}
+	if len(primary.seen) != 0 {
+		t.Fatalf("expected the failed provider not to be called, got %v", primary.seen)
+	}
+	if usage := chain.Providers()[0].Usage; usage.Requests != 1 || usage.Failures != 1 {
+		t.Fatalf("expected the outage to count as a failure, got %+v", usage)
+	}
+
+	chain.SetFaults(outage{"anthropic": errors.New("down"), "gemini": errors.New("down")})
+	if _, err := chain.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err == nil {
+		t.Fatalf("expected an error when every provider is down")
+	}
+}
+
+func TestFallbackChainStopsWhenCallerGivesUp(t *testing.T) {
+	secondary := &modelEcho{name: "gemini", model: "gemini-2.0-flash"}
+	chain := NewFallbackChain(&modelEcho{name: "anthropic", model: "claude-3-5-haiku"}, secondary)
+	ctx, cancel := context.WithCancel(context.Background())
+	chain.SetFaults(outage{"anthropic": context.Canceled})
+	cancel()
+
+	if _, err := chain.Complete(ctx, CompletionRequest{Prompt: "hi"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancellation to be reported, got %v", err)
+	}
+	if len(secondary.seen) != 0 {
+		t.Fatalf("expected no fallback after the caller gave up, got %v", secondary.seen)
+	}
+}
+
+type recordedMetrics struct {
+	calls     []string
+	tokens    int
+	fallbacks []string
+}
+
+func (m *recordedMetrics) ObserveProviderCall(provider, model string, promptTokens, completionTokens int, d time.Duration, failed bool) {
+	outcome := "ok"
+	if failed {
+		outcome = "failed"
+	}
+	m.calls = append(m.calls, provider+"/"+model+" "+outcome)
+	m.tokens += promptTokens + completionTokens
+}
+
+func (m *recordedMetrics) ObserveFallback(from, to string) {
+	m.fallbacks = append(m.fallbacks, from+"->"+to)
+}
+
+func TestFallbackChainReportsCallsAndFallbacks(t *testing.T) {
+	chain := NewFallbackChain(&modelEcho{name: "anthropic", model: "claude-3-5-haiku"}, &modelEcho{name: "gemini", model: "gemini-2.0-flash"})
+	metrics := &recordedMetrics{}
+	chain.SetMetrics(metrics)
+
+	if _, err := chain.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	chain.SetFaults(outage{"anthropic": errors.New("down")})
+	if _, err := chain.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Fatalf("complete during outage: %v", err)
+	}
+
+	want := []string{"anthropic/claude-3-5-haiku ok", "anthropic/claude-3-5-haiku failed", "gemini/gemini-2.0-flash ok"}
+	if !slices.Equal(metrics.calls, want) {
+		t.Fatalf("expected calls %v, got %v", want, metrics.calls)
+	}
+	if metrics.tokens != 2200 || !slices.Equal(metrics.fallbacks, []string{"anthropic->gemini"}) {
+		t.Fatalf("expected the tokens of both answers and one fallback, got %d tokens and %v", metrics.tokens, metrics.fallbacks)
+	}
+}
+
+func TestFallbackChainTracesProviderCallsWithinTheRequest(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	chain := NewFallbackChain(&modelEcho{name: "anthropic", model: "claude-3-5-haiku"}, &modelEcho{name: "gemini", model: "gemini-2.0-flash"})
+	chain.SetFaults(outage{"anthropic": errors.New("down")})
+	ctx, request := otel.Tracer("test").Start(context.Background(), "POST /api/v1/prompts")
+	if _, err := chain.Complete(ctx, CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	request.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 || spans[0].Name() != "nlp anthropic" || spans[1].Name() != "nlp gemini" {
+		t.Fatalf("expected a span per provider call, got %d spans", len(spans))
+	}
+	for _, span := range spans[:2] {
+		if span.Parent().SpanID() != request.SpanContext().SpanID() {
+			t.Fatalf("expected %s to be a child of the request span", span.Name())
+		}
+	}
+	attributes := map[string]string{}
+	for _, attribute := range spans[1].Attributes() {
+		attributes[string(attribute.Key)] = attribute.Value.Emit()
+	}
+	if attributes["kubechat.nlp.fallback_from"] != "anthropic" || attributes["gen_ai.usage.input_tokens"] != "1000" {
+		t.Fatalf("expected the gemini call to be marked as a fallback with its usage, got %v", attributes)
+	}
+}
+
+// heldOpen keeps the breakers of the upstreams it lists open and records every reported call.
+type heldOpen struct {
+	open     map[string]bool
+	recorded []string
+}
+
+func (b *heldOpen) Allow(ctx context.Context, upstream string) error {
+	if b.open[upstream] {
+		return errors.New("breaker open")
+	}
+	return nil
+}
+
+func (b *heldOpen) Record(ctx context.Context, upstream string, err error) {
+	b.recorded = append(b.recorded, fmt.Sprintf("%s %t", upstream, err == nil))
+}
+
+func TestFallbackChainSkipsProvidersWithOpenBreakers(t *testing.T) {
+	primary := &modelEcho{name: "anthropic", model: "claude-3-5-haiku"}
+	secondary := &modelEcho{name: "gemini", model: "gemini-2.0-flash"}
+	chain := NewFallbackChain(primary, secondary)
+	breakers := &heldOpen{open: map[string]bool{BreakerUpstream("anthropic"): true}}
+	chain.SetBreakers(breakers)
+
+	resp, err := chain.Complete(context.Background(), CompletionRequest{Prompt: "hi"})
+	if err != nil || resp.Provider != "gemini" {
+		t.Fatalf("expected gemini to serve the request, got %+v: %v", resp, err)
+	}
+	if len(primary.seen) != 0 || chain.Providers()[0].Usage.Requests != 0 {
+		t.Fatalf("expected the provider with an open breaker not to be called")
+	}
+	if len(breakers.recorded) != 1 || breakers.recorded[0] != "nlp:gemini true" {
+		t.Fatalf("expected the successful call to be reported, got %v", breakers.recorded)
+	}
+}
+
+type recordedUsage []UsageRecord
+
+func (r *recordedUsage) RecordUsage(ctx context.Context, record UsageRecord) {
+	*r = append(*r, record)
+}
+
+func TestFallbackChainAttributesUsageToTheUserAndSession(t *testing.T) {
+	chain := NewFallbackChain(&modelEcho{name: "anthropic", model: "claude-3-5-haiku"}, &modelEcho{name: "gemini", model: "gemini-2.0-flash"})
+	recorded := &recordedUsage{}
+	chain.SetUsageRecorder(recorded)
+
+	chain.Complete(NewSessionContext(identity.NewContext(context.Background(), "alice"), "session-1"), CompletionRequest{Prompt: "hi"})
+	chain.SetFaults(outage{"anthropic": errors.New("down")})
+	chain.Complete(identity.NewContext(context.Background(), "bob"), CompletionRequest{Prompt: "hi"})
+
+	if len(*recorded) != 2 {
+		t.Fatalf("expected only the calls that produced tokens to be recorded, got %+v", *recorded)
+	}
+	alice, bob := (*recorded)[0], (*recorded)[1]
+	if alice.User != "alice" || alice.Session != "session-1" || alice.Model != "claude-3-5-haiku" || alice.PromptTokens != 1000 || alice.CompletionTokens != 100 {
+		t.Fatalf("unexpected usage for alice %+v", alice)
+	}
+	if want := DefaultPrices.Cost("claude-3-5-haiku", Usage{PromptTokens: 1000, CompletionTokens: 100}); alice.CostUSD != want {
+		t.Fatalf("expected a cost of %f, got %f", want, alice.CostUSD)
+	}
+	if bob.User != "bob" || bob.Session != "" || bob.Provider != "gemini" {
+		t.Fatalf("unexpected usage for bob %+v", bob)
+	}
+}
+
+// fixedBudgets reports the same exceeded budget for every user it lists.
+type fixedBudgets map[string]ErrBudgetExceeded
+
+func (b fixedBudgets) Enforce(ctx context.Context, user string) error {
+	if exceeded, ok := b[user]; ok {
+		return exceeded
+	}
+	return nil
+}
+
+func TestFallbackChainKeepsRequestsPastABudgetOnLocalProviders(t *testing.T) {
+	chain := NewFallbackChain(&modelEcho{name: "anthropic", model: "claude-3-5-haiku"}, &localEcho{modelEcho{name: "ollama", model: "llama3"}})
+	chain.SetBudgets(fixedBudgets{
+		"alice": {Budget: "team", Spent: 120, Limit: 100, Currency: "USD"},
+		"bob":   {Budget: "team", Spent: 250, Limit: 200, Currency: "USD", Hard: true},
+	})
+
+	if resp, err := chain.Complete(identity.NewContext(context.Background(), "alice"), CompletionRequest{Prompt: "hi"}); err != nil || resp.Provider != "ollama" {
+		t.Fatalf("expected a soft limit to downgrade to the local provider, got %s: %v", resp.Provider, err)
+	}
+	var exceeded ErrBudgetExceeded
+	if _, err := chain.Complete(identity.NewContext(context.Background(), "bob"), CompletionRequest{Prompt: "hi"}); !errors.As(err, &exceeded) || !exceeded.Hard {
+		t.Fatalf("expected a hard limit to refuse the request, got %v", err)
+	}
+	if resp, _ := chain.Complete(identity.NewContext(context.Background(), "carol"), CompletionRequest{Prompt: "hi"}); resp.Provider != "anthropic" {
+		t.Fatalf("expected users within budget to reach hosted providers, got %s", resp.Provider)
+	}
+
+	hostedOnly := NewFallbackChain(&modelEcho{name: "anthropic", model: "claude-3-5-haiku"})
+	hostedOnly.SetBudgets(fixedBudgets{"alice": {Budget: "team", Spent: 120, Limit: 100, Currency: "USD"}})
+	if _, err := hostedOnly.Complete(identity.NewContext(context.Background(), "alice"), CompletionRequest{Prompt: "hi"}); !errors.As(err, &exceeded) || exceeded.Hard {
+		t.Fatalf("expected the soft limit to be reported without a local provider, got %v", err)
+	}
+}
+
+// ownKeys hands out the same providers for every user it lists.
+type ownKeys map[string][]Provider
+
+func (k ownKeys) Providers(ctx context.Context, user string) ([]Provider, error) {
+	return k[user], nil
+}
+
+func TestFallbackChainPrefersTheUsersOwnKey(t *testing.T) {
+	own := &modelEcho{name: "openai", model: "gpt-4o-mini"}
+	chain := NewFallbackChain(&modelEcho{name: "anthropic", model: "claude-3-5-haiku"})
+	chain.SetUserKeys(ownKeys{"alice": {own}})
+	recorded := &recordedUsage{}
+	chain.SetUsageRecorder(recorded)
+	quota, err := NewQuota(QuotaPolicy{Capacity: 5000, RefillPerMinute: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain.SetQuota(quota)
+	chain.SetBudgets(fixedBudgets{"alice": {Budget: "team", Spent: 250, Limit: 200, Currency: "USD", Hard: true}})
+
+	resp, err := chain.Complete(identity.NewContext(context.Background(), "alice"), CompletionRequest{Prompt: "hi"})
+	if err != nil || resp.Provider != "openai" {
+		t.Fatalf("expected alice's own key to serve her past the shared budget, got %s: %v", resp.Provider, err)
+	}
+	if balance := quota.Balance("alice"); balance.Balance != 5000 {
+		t.Fatalf("expected calls on her own key not to be charged to her quota, got %+v", balance)
+	}
+	if len(*recorded) != 1 || (*recorded)[0].KeyOwner != "alice" || (*recorded)[0].CostUSD == 0 {
+		t.Fatalf("expected the usage to be attributed to alice's key at its price, got %+v", *recorded)
+	}
+
+	chain.SetFaults(outage{"openai": errors.New("invalid key")})
+	var exceeded ErrBudgetExceeded
+	if _, err := chain.Complete(identity.NewContext(context.Background(), "alice"), CompletionRequest{Prompt: "hi"}); !errors.As(err, &exceeded) {
+		t.Fatalf("expected the shared providers to stay behind the budget, got %v", err)
+	}
+	if resp, _ := chain.Complete(identity.NewContext(context.Background(), "bob"), CompletionRequest{Prompt: "hi"}); resp.Provider != "anthropic" {
+		t.Fatalf("expected users without keys to use the shared providers, got %s", resp.Provider)
+	}
+	if last := (*recorded)[len(*recorded)-1]; last.User != "bob" || last.KeyOwner != "" {
+		t.Fatalf("expected bob's usage to be paid by kubechat, got %+v", last)
+	}
+}
+
+// batchEcho serves code generation for batch requests only, like a large self-hosted model.
+type batchEcho struct {
+	listedEcho
+}
+
+func (b *batchEcho) Capabilities() []string { return []string{CapabilityCode} }
+func (b *batchEcho) LatencyClass() string   { return LatencyBatch }
+
+func TestFallbackChainRoutesByCapabilityModelAndLatency(t *testing.T) {
+	primary := &listedEcho{
+		modelEcho: modelEcho{name: "ollama", model: "llama3.2"},
+		models:    []ModelInfo{{ID: "llama3.2:latest", MaxOutputTokens: 2048}, {ID: "qwen3:8b", MaxOutputTokens: 8192}},
+	}
+	coder := &batchEcho{listedEcho{modelEcho: modelEcho{name: "openai", model: "gpt-4o-mini"}, models: []ModelInfo{{ID: "gpt-4o-mini", MaxOutputTokens: 16384}}}}
+	chain := NewFallbackChain(primary, coder)
+	catalog := NewModelCatalog(chain.Members()...)
+	catalog.Refresh(context.Background())
+	chain.SetModelCatalog(catalog)
+	ctx := context.Background()
+
+	if resp, err := chain.Complete(ctx, CompletionRequest{Prompt: "hi", MinOutputTokens: 4096}); err != nil || resp.Provider != "openai" {
+		t.Fatalf("expected the default model's output limit to pass the primary over, got %s: %v", resp.Provider, err)
+	}
+	if resp, err := chain.Complete(ctx, CompletionRequest{Model: "qwen3:8b", Prompt: "hi", MinOutputTokens: 4096}); err != nil || resp.Model != "qwen3:8b" {
+		t.Fatalf("expected the requested model to satisfy the output limit, got %+v: %v", resp, err)
+	}
+	if resp, err := chain.Complete(ctx, CompletionRequest{Model: "mistral", Prompt: "hi"}); err != nil || resp.Provider != "openai" {
+		t.Fatalf("expected a model the primary does not list to be served by a fallback, got %s: %v", resp.Provider, err)
+	}
+
+	var unfit ErrNoCapableProvider
+	_, err := chain.Complete(ctx, CompletionRequest{Prompt: "hi", Capability: CapabilityCode, Latency: LatencyInteractive, MinOutputTokens: 4096})
+	if !errors.As(err, &unfit) || len(unfit.Reasons) != 2 || unfit.Reasons["openai"] != "serves batch requests only" {
+		t.Fatalf("expected no provider to qualify, got %v", err)
+	}
+	if _, err := chain.Stream(ctx, CompletionRequest{Prompt: "hi", Capability: CapabilityEmbedding}, func(string) error { return nil }); !errors.As(err, &unfit) {
+		t.Fatalf("expected embeddings to be refused by completion providers, got %v", err)
+	}
+	if _, err := chain.Complete(ctx, CompletionRequest{Prompt: "hi", Capability: "vision"}); err == nil || errors.As(err, &unfit) {
+		t.Fatalf("expected an unknown capability to be refused, got %v", err)
+	}
+	if infos := chain.Providers(); !slices.Equal(infos[1].Serves, []string{CapabilityCode}) || infos[1].Latency != LatencyBatch || infos[0].Latency != LatencyInteractive {
+		t.Fatalf("unexpected routing information %+v", infos)
+	}
+	if len(primary.seen) != 1 || len(coder.seen) != 2 {
+		t.Fatalf("expected passed-over providers not to be called, got %v and %v", primary.seen, coder.seen)
+	}
+}
@@ -0,0 +1,264 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultGeminiURL   = "https://generativelanguage.googleapis.com"
+	DefaultGeminiModel = "gemini-2.5-flash"
+)
+
+type GeminiProvider struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+func NewGeminiProvider(baseURL, apiKey, model string, client *http.Client) *GeminiProvider {
+	if strings.TrimSpace(baseURL) == "" {
+		baseURL = DefaultGeminiURL
+	}
+	if strings.TrimSpace(model) == "" {
+		model = DefaultGeminiModel
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+	return &GeminiProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+		client:  client,
+	}
+}
+
+func (p *GeminiProvider) Name() string {
+	return "gemini"
+}
+
+func (p *GeminiProvider) Model() string {
+	return p.model
+}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiGenerationConfig struct {
+	Temperature     float64 `json:"temperature"`
+	MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
+}
+
+type geminiRequest struct {
+	Contents          []geminiContent        `json:"contents"`
+	SystemInstruction *geminiContent         `json:"systemInstruction,omitempty"`
+	GenerationConfig  geminiGenerationConfig `json:"generationConfig"`
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content geminiContent `json:"content"`
+	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
+	ModelVersion string `json:"modelVersion"`
+}
+
+func (r geminiResponse) text() string {
+	var text strings.Builder
+	for _, candidate := range r.Candidates {
+		for _, part := range candidate.Content.Parts {
+			text.WriteString(part.Text)
+		}
+		// Only the first candidate is requested.
+		break
+	}
+	return text.String()
+}
+
+func (p *GeminiProvider) Complete(ctx context.Context, req CompletionRequest) (CompletionResponse, error) {
+	model := p.modelFor(req)
+	resp, err := p.send(ctx, req, model+":generateContent", nil)
+	if err != nil {
+		return CompletionResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	var decoded geminiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return CompletionResponse{}, err
+	}
+	return p.response(model, decoded, decoded.text()), nil
+}
+
+func (p *GeminiProvider) Stream(ctx context.Context, req CompletionRequest, onDelta func(delta string) error) (CompletionResponse, error) {
+	model := p.modelFor(req)
+	resp, err := p.send(ctx, req, model+":streamGenerateContent", url.Values{"alt": {"sse"}})
+	if err != nil {
+		return CompletionResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	var (
+		text strings.Builder
+		last geminiResponse
+	)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var chunk geminiResponse
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &chunk); err != nil {
+			return CompletionResponse{}, fmt.Errorf("decode gemini stream chunk: %w", err)
+		}
+		if delta := chunk.text(); delta != "" {
+			text.WriteString(delta)
+			if err := onDelta(delta); err != nil {
+				return CompletionResponse{}, err
+			}
+		}
+		// Usage metadata is cumulative; the final chunk carries the totals.
+		last = chunk
+	}
+	if err := scanner.Err(); err != nil {
+		return CompletionResponse{}, err
+	}
+	return p.response(model, last, text.String()), nil
+}
+
+// HealthCheck confirms the API key can read the configured model.
+func (p *GeminiProvider) HealthCheck(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/v1beta/models/"+p.model, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-goog-api-key", p.apiKey)
+	return checkStatus(p.client, req, p.Name())
+}
+
+func (p *GeminiProvider) modelFor(req CompletionRequest) string {
+	if req.Model != "" {
+		return req.Model
+	}
+	return p.model
+}
+
+func (p *GeminiProvider) response(model string, decoded geminiResponse, text string) CompletionResponse {
+	if decoded.ModelVersion != "" {
+		model = decoded.ModelVersion
+	}
+	return CompletionResponse{
+		Provider: p.Name(),
+		Model:    model,
+		Text:     text,
+		Usage: Usage{
+			PromptTokens:     decoded.UsageMetadata.PromptTokenCount,
+			CompletionTokens: decoded.UsageMetadata.CandidatesTokenCount,
+		},
+	}
+}
+
+func (p *GeminiProvider) send(ctx context.Context, req CompletionRequest, method string, query url.Values) (*http.Response, error) {
+	payload := geminiRequest{
+		Contents:         []geminiContent{{Role: "user", Parts: []geminiPart{{Text: req.Prompt}}}},
+		GenerationConfig: geminiGenerationConfig{Temperature: req.Temperature, MaxOutputTokens: req.MaxTokens},
+	}
+	if req.System != "" {
+		payload.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: req.System}}}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := p.baseURL + "/v1beta/models/" + method
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-goog-api-key", p.apiKey)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		payload, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, ErrProviderStatus{Provider: p.Name(), Status: resp.StatusCode, Body: string(payload)}
+	}
+	return resp, nil
+}
+
+type geminiModelList struct {
+	Models []struct {
+		Name                       string   `json:"name"`
+		DisplayName                string   `json:"displayName"`
+		InputTokenLimit            int      `json:"inputTokenLimit"`
+		OutputTokenLimit           int      `json:"outputTokenLimit"`
+		SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+	} `json:"models"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// ListModels pages through the models that can generate content, skipping embedding and other
+// models the chain cannot call. The API does not report tool support; Gemini models have it and
+// the open Gemma models served alongside them do not.
+func (p *GeminiProvider) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	var models []ModelInfo
+	query := url.Values{"pageSize": {"1000"}}
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/v1beta/models?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("x-goog-api-key", p.apiKey)
+		var page geminiModelList
+		if err := decodeJSON(p.client, req, p.Name(), &page); err != nil {
+			return nil, err
+		}
+		for _, model := range page.Models {
+			if !slices.Contains(model.SupportedGenerationMethods, "generateContent") {
+				continue
+			}
+			id := strings.TrimPrefix(model.Name, "models/")
+			models = append(models, ModelInfo{
+				ID:              id,
+				DisplayName:     model.DisplayName,
+				ContextWindow:   model.InputTokenLimit,
+				MaxOutputTokens: model.OutputTokenLimit,
+				FunctionCalling: strings.HasPrefix(id, "gemini-"),
+			})
+		}
+		if page.NextPageToken == "" {
+			return models, nil
+		}
+		query.Set("pageToken", page.NextPageToken)
+	}
+}
@@ -0,0 +1,89 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGeminiProviderComplete(t *testing.T) {
+	var received geminiRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta/models/gemini-test:generateContent" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("x-goog-api-key") != "secret" {
+			t.Fatal("missing API key header")
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		fmt.Fprint(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"lo"},{"text":"w"}]}}],"usageMetadata":{"promptTokenCount":20,"candidatesTokenCount":1},"modelVersion":"gemini-test-001"}`)
+	}))
+	defer server.Close()
+
+	provider := NewGeminiProvider(server.URL, "secret", "gemini-test", server.Client())
+	resp, err := provider.Complete(context.Background(), CompletionRequest{System: "classify", Prompt: "kubectl get pods", MaxTokens: 8})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if received.SystemInstruction == nil || received.SystemInstruction.Parts[0].Text != "classify" || received.GenerationConfig.MaxOutputTokens != 8 {
+		t.Fatalf("unexpected request: %+v", received)
+	}
+	if resp.Text != "low" || resp.Model != "gemini-test-001" || resp.Usage.TotalTokens() != 21 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestGeminiProviderStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("alt") != "sse" {
+			t.Fatalf("expected SSE stream, got %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"Hel\"}]}}],\"usageMetadata\":{\"promptTokenCount\":5}}\r\n\r\n")
+		fmt.Fprint(w, "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"lo\"}]}}],\"usageMetadata\":{\"promptTokenCount\":5,\"candidatesTokenCount\":2}}\r\n\r\n")
+	}))
+	defer server.Close()
+
+	provider := NewGeminiProvider(server.URL, "secret", "", server.Client())
+	var streamed string
+	resp, err := provider.Stream(context.Background(), CompletionRequest{Prompt: "hi"}, func(delta string) error {
+		streamed += delta
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if streamed != "Hello" || resp.Text != "Hello" || resp.Model != DefaultGeminiModel || resp.Usage.CompletionTokens != 2 {
+		t.Fatalf("unexpected stream result %q / %+v", streamed, resp)
+	}
+}
+
+func TestGeminiProviderHealthCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"message":"API key not valid"}}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	err := NewGeminiProvider(server.URL, "bad", "", server.Client()).HealthCheck(context.Background())
+	if statusErr, ok := err.(ErrProviderStatus); !ok || statusErr.Status != http.StatusBadRequest {
+		t.Fatalf("expected provider status error, got %v", err)
+	}
+}
+
+func TestPriceTableCost(t *testing.T) {
+	cost := DefaultPrices.Cost("claude-sonnet-4-20250514", Usage{PromptTokens: 1_000_000, CompletionTokens: 100_000})
+	if math.Abs(cost-4.5) > 1e-9 {
+		t.Fatalf("expected 4.5 USD, got %v", cost)
+	}
+	if price, ok := DefaultPrices.Lookup("models/gemini-2.5-flash-lite"); !ok || price.InputPerMillion != 0.3 {
+		t.Fatalf("expected gemini-2.5-flash family price, got %+v (%v)", price, ok)
+	}
+	if cost := DefaultPrices.Cost("llama3.2", Usage{PromptTokens: 1000}); cost != 0 {
+		t.Fatalf("expected local models to be free, got %v", cost)
+	}
+}
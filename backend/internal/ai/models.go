@@ -0,0 +1,134 @@
+package ai
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ModelInfo describes a model a provider serves. Zero limits mean the provider does not report them.
+type ModelInfo struct {
+	ID              string `json:"id"`
+	DisplayName     string `json:"displayName,omitempty"`
+	ContextWindow   int    `json:"contextWindow,omitempty"`
+	MaxOutputTokens int    `json:"maxOutputTokens,omitempty"`
+	FunctionCalling bool   `json:"functionCalling"`
+}
+
+// ModelLister is implemented by providers that can list the models available to their credentials.
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]ModelInfo, error)
+}
+
+// ProviderModels is the last model list fetched from one provider.
+type ProviderModels struct {
+	Provider string `json:"provider"`
+	// Model is the provider's configured default; Capabilities describes it when it is listed.
+	Model        string      `json:"model,omitempty"`
+	Capabilities *ModelInfo  `json:"capabilities,omitempty"`
+	Models       []ModelInfo `json:"models"`
+	RefreshedAt  *time.Time  `json:"refreshedAt,omitempty"`
+	// Error is the last refresh failure; Models keeps the list from the last successful refresh.
+	Error string `json:"error,omitempty"`
+}
+
+// ModelCatalog caches the models each provider serves so that reads never wait on the providers.
+// Lists are refreshed periodically and a failed refresh keeps the previous list.
+type ModelCatalog struct {
+	providers []Provider
+
+	mu      sync.RWMutex
+	entries map[string]*ProviderModels
+}
+
+func NewModelCatalog(providers ...Provider) *ModelCatalog {
+	entries := make(map[string]*ProviderModels, len(providers))
+	for _, provider := range providers {
+		entries[provider.Name()] = &ProviderModels{Provider: provider.Name(), Models: []ModelInfo{}}
+	}
+	return &ModelCatalog{providers: providers, entries: entries}
+}
+
+// Refresh fetches the model list of every provider that can list its models.
+func (c *ModelCatalog) Refresh(ctx context.Context) {
+	for _, provider := range c.providers {
+		lister, ok := provider.(ModelLister)
+		if !ok {
+			continue
+		}
+		models, err := lister.ListModels(ctx)
+		now := time.Now().UTC()
+
+		c.mu.Lock()
+		entry := c.entries[provider.Name()]
+		if err != nil {
+			entry.Error = err.Error()
+		} else {
+			entry.Models, entry.RefreshedAt, entry.Error = models, &now, ""
+		}
+		c.mu.Unlock()
+	}
+}
+
+// Run refreshes the catalog immediately and then every interval until ctx is cancelled.
+func (c *ModelCatalog) Run(ctx context.Context, interval time.Duration) {
+	c.Refresh(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.Refresh(ctx)
+		}
+	}
+}
+
+// Models returns the cached lists in provider order.
+func (c *ModelCatalog) Models() []ProviderModels {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	lists := make([]ProviderModels, 0, len(c.providers))
+	for _, provider := range c.providers {
+		entry := *c.entries[provider.Name()]
+		entry.Model = providerModel(provider)
+		if info, ok := c.lookupLocked(provider.Name(), entry.Model); ok {
+			entry.Capabilities = &info
+		}
+		entry.Models = append([]ModelInfo(nil), entry.Models...)
+		lists = append(lists, entry)
+	}
+	return lists
+}
+
+// Listed reports whether the catalog holds a model list for provider. Models of providers that
+// were never listed are unknown rather than unavailable.
+func (c *ModelCatalog) Listed(provider string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.entries[provider]
+	return ok && entry.RefreshedAt != nil
+}
+
+// Lookup returns the capabilities of model as listed by provider.
+func (c *ModelCatalog) Lookup(provider, model string) (ModelInfo, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lookupLocked(provider, model)
+}
+
+func (c *ModelCatalog) lookupLocked(provider, model string) (ModelInfo, bool) {
+	entry, ok := c.entries[provider]
+	if !ok || model == "" {
+		return ModelInfo{}, false
+	}
+	for _, info := range entry.Models {
+		// Ollama lists models with their tag; an untagged name refers to :latest.
+		if info.ID == model || info.ID == model+":latest" {
+			return info, true
+		}
+	}
+	return ModelInfo{}, false
+}
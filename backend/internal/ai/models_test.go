@@ -0,0 +1,104 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestModelCatalogKeepsLastListWhenRefreshFails(t *testing.T) {
+	down := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path != "/v1beta/models" || r.Header.Get("x-goog-api-key") != "secret" {
+			t.Fatalf("unexpected request %s", r.URL)
+		}
+		if r.URL.Query().Get("pageToken") == "" {
+			fmt.Fprint(w, `{"models":[{"name":"models/gemini-test","displayName":"Gemini Test","inputTokenLimit":1048576,"outputTokenLimit":65536,"supportedGenerationMethods":["generateContent","countTokens"]},{"name":"models/text-embedding-004","supportedGenerationMethods":["embedContent"]}],"nextPageToken":"next"}`)
+			return
+		}
+		fmt.Fprint(w, `{"models":[{"name":"models/gemma-3-4b-it","inputTokenLimit":32768,"outputTokenLimit":8192,"supportedGenerationMethods":["generateContent"]}]}`)
+	}))
+	defer server.Close()
+
+	provider := NewGeminiProvider(server.URL, "secret", "gemini-test", server.Client())
+	catalog := NewModelCatalog(provider, &modelEcho{name: "custom", model: "echo"})
+	catalog.Refresh(context.Background())
+
+	lists := catalog.Models()
+	if len(lists) != 2 || len(lists[0].Models) != 2 || lists[0].RefreshedAt == nil {
+		t.Fatalf("expected both generating models listed, got %+v", lists)
+	}
+	if capabilities := lists[0].Capabilities; capabilities == nil || capabilities.ContextWindow != 1048576 || capabilities.MaxOutputTokens != 65536 || !capabilities.FunctionCalling {
+		t.Fatalf("unexpected capabilities for the default model: %+v", capabilities)
+	}
+	if info, _ := catalog.Lookup("gemini", "gemma-3-4b-it"); info.FunctionCalling {
+		t.Fatalf("expected Gemma not to support function calling: %+v", info)
+	}
+	if catalog.Listed("custom") || len(lists[1].Models) != 0 {
+		t.Fatalf("expected a provider without a model list to stay unlisted: %+v", lists[1])
+	}
+
+	down = true
+	catalog.Refresh(context.Background())
+	lists = catalog.Models()
+	if lists[0].Error == "" || len(lists[0].Models) != 2 {
+		t.Fatalf("expected the failure to be reported and the last list kept, got %+v", lists[0])
+	}
+}
+
+// listedEcho is a modelEcho that lists its models and remembers the token limits it was sent.
+type listedEcho struct {
+	modelEcho
+	models    []ModelInfo
+	maxTokens []int
+}
+
+func (l *listedEcho) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	return l.models, nil
+}
+
+func (l *listedEcho) Complete(ctx context.Context, req CompletionRequest) (CompletionResponse, error) {
+	l.maxTokens = append(l.maxTokens, req.MaxTokens)
+	return l.modelEcho.Complete(ctx, req)
+}
+
+func TestFallbackChainRoutesWithinListedModels(t *testing.T) {
+	provider := &listedEcho{
+		modelEcho: modelEcho{name: "ollama", model: "llama3.2"},
+		models:    []ModelInfo{{ID: "llama3.2:latest", ContextWindow: 131072}, {ID: "qwen3:8b", MaxOutputTokens: 4096, FunctionCalling: true}},
+	}
+	chain := NewFallbackChain(provider)
+	catalog := NewModelCatalog(chain.Members()...)
+	chain.SetModelCatalog(catalog)
+
+	if _, err := chain.StartCanary("ollama", CanaryConfig{Model: "mistral", Percent: 10}); err != nil {
+		t.Fatalf("expected candidates to be accepted before the models are listed: %v", err)
+	}
+	if _, err := chain.AbortCanary("ollama"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	catalog.Refresh(context.Background())
+	if _, err := chain.StartCanary("ollama", CanaryConfig{Model: "mistral", Percent: 10}); err == nil {
+		t.Fatalf("expected a model the provider does not list to be rejected")
+	}
+	if info := chain.Providers()[0].Capabilities; info == nil || info.ContextWindow != 131072 {
+		t.Fatalf("expected the untagged default to match the :latest tag, got %+v", info)
+	}
+
+	if _, err := chain.Complete(context.Background(), CompletionRequest{Model: "qwen3:8b", Prompt: "hi", MaxTokens: 8192}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := chain.Complete(context.Background(), CompletionRequest{Prompt: "hi", MaxTokens: 8192}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if provider.maxTokens[0] != 4096 || provider.maxTokens[1] != 8192 {
+		t.Fatalf("expected only the listed output limit to clamp requests, got %v", provider.maxTokens)
+	}
+}
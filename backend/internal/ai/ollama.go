@@ -0,0 +1,185 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultOllamaURL   = "http://localhost:11434"
+	DefaultOllamaModel = "llama3.2"
+)
+
+type OllamaProvider struct {
+	baseURL string
+	model   string
+	client  *http.Client
+}
+
+func NewOllamaProvider(baseURL, model string, client *http.Client) *OllamaProvider {
+	if strings.TrimSpace(baseURL) == "" {
+		baseURL = DefaultOllamaURL
+	}
+	if strings.TrimSpace(model) == "" {
+		model = DefaultOllamaModel
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &OllamaProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		model:   model,
+		client:  client,
+	}
+}
+
+func (p *OllamaProvider) Name() string {
+	return "ollama"
+}
+
+type ollamaGenerateRequest struct {
+	Model   string         `json:"model"`
+	System  string         `json:"system,omitempty"`
+	Prompt  string         `json:"prompt"`
+	Stream  bool           `json:"stream"`
+	Options map[string]any `json:"options,omitempty"`
+}
+
+type ollamaGenerateResponse struct {
+	Model           string `json:"model"`
+	Response        string `json:"response"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+}
+
+func (p *OllamaProvider) Complete(ctx context.Context, req CompletionRequest) (CompletionResponse, error) {
+	model := req.Model
+	if model == "" {
+		model = p.model
+	}
+
+	options := map[string]any{"temperature": req.Temperature}
+	if req.MaxTokens > 0 {
+		options["num_predict"] = req.MaxTokens
+	}
+
+	body, err := json.Marshal(ollamaGenerateRequest{
+		Model:   model,
+		System:  req.System,
+		Prompt:  req.Prompt,
+		Stream:  false,
+		Options: options,
+	})
+	if err != nil {
+		return CompletionResponse{}, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/api/generate", bytes.NewReader(body))
+	if err != nil {
+		return CompletionResponse{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return CompletionResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		payload, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return CompletionResponse{}, ErrProviderStatus{Provider: p.Name(), Status: resp.StatusCode, Body: string(payload)}
+	}
+
+	var decoded ollamaGenerateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return CompletionResponse{}, err
+	}
+
+	return CompletionResponse{
+		Provider: p.Name(),
+		Model:    decoded.Model,
+		Text:     decoded.Response,
+		Usage: Usage{
+			PromptTokens:     decoded.PromptEvalCount,
+			CompletionTokens: decoded.EvalCount,
+		},
+	}, nil
+}
+
+// Local reports that Ollama runs alongside kubechat, so its tokens are not charged to quotas.
+func (p *OllamaProvider) Local() bool {
+	return true
+}
+
+func (p *OllamaProvider) Model() string {
+	return p.model
+}
+
+// HealthCheck lists local models to confirm the Ollama server is reachable.
+func (p *OllamaProvider) HealthCheck(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/api/tags", nil)
+	if err != nil {
+		return err
+	}
+	return checkStatus(p.client, req, p.Name())
+}
+
+type ollamaTags struct {
+	Models []struct {
+		Name string `json:"name"`
+	} `json:"models"`
+}
+
+type ollamaShow struct {
+	Capabilities []string       `json:"capabilities"`
+	ModelInfo    map[string]any `json:"model_info"`
+}
+
+// ListModels lists the locally pulled models and reads each one's capabilities. Ollama has no
+// separate output limit; generation may run until the context window is full.
+func (p *OllamaProvider) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	var tags ollamaTags
+	if err := decodeJSON(p.client, req, p.Name(), &tags); err != nil {
+		return nil, err
+	}
+
+	models := make([]ModelInfo, 0, len(tags.Models))
+	for _, tag := range tags.Models {
+		body, err := json.Marshal(map[string]string{"model": tag.Name})
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/api/show", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		var show ollamaShow
+		if err := decodeJSON(p.client, req, p.Name(), &show); err != nil {
+			return nil, err
+		}
+		// Older servers report no capabilities; embedding-only models lack "completion".
+		if len(show.Capabilities) > 0 && !slices.Contains(show.Capabilities, "completion") {
+			continue
+		}
+		info := ModelInfo{ID: tag.Name, FunctionCalling: slices.Contains(show.Capabilities, "tools")}
+		for key, value := range show.ModelInfo {
+			if length, ok := value.(float64); ok && strings.HasSuffix(key, ".context_length") {
+				info.ContextWindow = int(length)
+			}
+		}
+		models = append(models, info)
+	}
+	return models, nil
+}
@@ -0,0 +1,90 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/plan"
+)
+
+func TestOllamaProviderComplete(t *testing.T) {
+	var received ollamaGenerateRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/generate" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		json.NewEncoder(w).Encode(ollamaGenerateResponse{Model: "llama3.2", Response: "high", PromptEvalCount: 42, EvalCount: 1})
+	}))
+	defer server.Close()
+
+	provider := NewOllamaProvider(server.URL, "llama3.2", server.Client())
+	resp, err := provider.Complete(context.Background(), CompletionRequest{Prompt: "classify"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if received.Stream {
+		t.Fatal("expected non-streaming request")
+	}
+	if resp.Text != "high" || resp.Usage.TotalTokens() != 43 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestOllamaProviderSurfacesStatusErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	provider := NewOllamaProvider(server.URL, "", server.Client())
+	_, err := provider.Complete(context.Background(), CompletionRequest{Prompt: "classify"})
+	statusErr, ok := err.(ErrProviderStatus)
+	if !ok || statusErr.Status != http.StatusNotFound {
+		t.Fatalf("expected provider status error, got %v", err)
+	}
+}
+
+type scriptedProvider struct {
+	text string
+	err  error
+	last CompletionRequest
+}
+
+func (s *scriptedProvider) Name() string { return "scripted" }
+
+func (s *scriptedProvider) Complete(ctx context.Context, req CompletionRequest) (CompletionResponse, error) {
+	s.last = req
+	return CompletionResponse{Provider: "scripted", Text: s.text}, s.err
+}
+
+func TestModelRiskReviewerParsesVerdict(t *testing.T) {
+	reviewer := NewModelRiskReviewer(&scriptedProvider{text: "Risk: HIGH, deletes data"}, "tiny")
+	severity, err := reviewer.ReviewStep(context.Background(), plan.PlanDraft{}, plan.PlanStep{Command: "kubectl delete ns prod"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if severity != "high" {
+		t.Fatalf("expected high, got %s", severity)
+	}
+
+	provider := &scriptedProvider{text: "medium"}
+	draft := plan.PlanDraft{Prompt: "now scale it to 3", Conversation: &plan.ConversationContext{Cluster: "prod", Namespace: "shop", Resources: []string{"deployments/api"}}}
+	if _, err := NewModelRiskReviewer(provider, "tiny").ReviewStep(context.Background(), draft, plan.PlanStep{Command: "kubectl scale deploy/api --replicas=3"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(provider.last.Prompt, "Conversation context: cluster prod; namespace shop; recent resources deployments/api") {
+		t.Fatalf("expected the conversation context in the prompt, got %q", provider.last.Prompt)
+	}
+
+	reviewer = NewModelRiskReviewer(&scriptedProvider{text: "not sure"}, "tiny")
+	if _, err := reviewer.ReviewStep(context.Background(), plan.PlanDraft{}, plan.PlanStep{}); err == nil {
+		t.Fatal("expected error for unrecognised verdict")
+	}
+}
@@ -0,0 +1,124 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultOpenAIURL   = "https://api.openai.com"
+	DefaultOpenAIModel = "gpt-4o-mini"
+)
+
+// OpenAIProvider calls the Chat Completions API. It is used with the API keys users bring for
+// themselves.
+type OpenAIProvider struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+func NewOpenAIProvider(baseURL, apiKey, model string, client *http.Client) *OpenAIProvider {
+	if strings.TrimSpace(baseURL) == "" {
+		baseURL = DefaultOpenAIURL
+	}
+	if strings.TrimSpace(model) == "" {
+		model = DefaultOpenAIModel
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+	return &OpenAIProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+		client:  client,
+	}
+}
+
+func (p *OpenAIProvider) Name() string {
+	return "openai"
+}
+
+func (p *OpenAIProvider) Model() string {
+	return p.model
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIRequest struct {
+	Model       string          `json:"model"`
+	Messages    []openAIMessage `json:"messages"`
+	MaxTokens   int             `json:"max_completion_tokens,omitempty"`
+	Temperature float64         `json:"temperature,omitempty"`
+}
+
+type openAIResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+func (p *OpenAIProvider) Complete(ctx context.Context, req CompletionRequest) (CompletionResponse, error) {
+	model := req.Model
+	if model == "" {
+		model = p.model
+	}
+	messages := make([]openAIMessage, 0, 2)
+	if req.System != "" {
+		messages = append(messages, openAIMessage{Role: "system", Content: req.System})
+	}
+	messages = append(messages, openAIMessage{Role: "user", Content: req.Prompt})
+	body, err := json.Marshal(openAIRequest{Model: model, Messages: messages, MaxTokens: req.MaxTokens, Temperature: req.Temperature})
+	if err != nil {
+		return CompletionResponse{}, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v1/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return CompletionResponse{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	var decoded openAIResponse
+	if err := decodeJSON(p.client, httpReq, p.Name(), &decoded); err != nil {
+		return CompletionResponse{}, err
+	}
+	var text strings.Builder
+	for _, choice := range decoded.Choices {
+		text.WriteString(choice.Message.Content)
+	}
+	return CompletionResponse{
+		Provider: p.Name(),
+		Model:    decoded.Model,
+		Text:     text.String(),
+		Usage: Usage{
+			PromptTokens:     decoded.Usage.PromptTokens,
+			CompletionTokens: decoded.Usage.CompletionTokens,
+		},
+	}, nil
+}
+
+// HealthCheck confirms the API key is accepted by listing models.
+func (p *OpenAIProvider) HealthCheck(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/v1/models", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	return checkStatus(p.client, req, p.Name())
+}
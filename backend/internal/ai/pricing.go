@@ -0,0 +1,75 @@
+package ai
+
+import (
+	"strings"
+	"time"
+)
+
+// Price is the list price per million tokens, and per request for providers that charge one.
+// Built-in prices are in USD.
+type Price struct {
+	InputPerMillion  float64 `json:"inputPerMillion"`
+	OutputPerMillion float64 `json:"outputPerMillion"`
+	PerRequest       float64 `json:"perRequest,omitempty"`
+}
+
+// Cost is what a call with usage costs at this price.
+func (p Price) Cost(usage Usage) float64 {
+	return (float64(usage.PromptTokens)*p.InputPerMillion+float64(usage.CompletionTokens)*p.OutputPerMillion)/1_000_000 + p.PerRequest
+}
+
+// Pricer prices a provider's models as they were priced at a point in time.
+type Pricer interface {
+	PriceAt(provider, model string, at time.Time) (Price, bool)
+}
+
+// PriceTable maps model name prefixes to prices; the longest matching prefix wins
+// so dated model snapshots resolve to their family price.
+type PriceTable map[string]Price
+
+// DefaultPrices covers the hosted models selectable through provider configuration.
+// Local models are free and therefore absent.
+var DefaultPrices = PriceTable{
+	"claude-opus-4":     {InputPerMillion: 15, OutputPerMillion: 75},
+	"claude-sonnet-4":   {InputPerMillion: 3, OutputPerMillion: 15},
+	"claude-3-7-sonnet": {InputPerMillion: 3, OutputPerMillion: 15},
+	"claude-3-5-sonnet": {InputPerMillion: 3, OutputPerMillion: 15},
+	"claude-3-5-haiku":  {InputPerMillion: 0.8, OutputPerMillion: 4},
+	"gemini-2.5-pro":    {InputPerMillion: 1.25, OutputPerMillion: 10},
+	"gemini-2.5-flash":  {InputPerMillion: 0.3, OutputPerMillion: 2.5},
+	"gemini-2.0-flash":  {InputPerMillion: 0.1, OutputPerMillion: 0.4},
+	"gemini-1.5-pro":    {InputPerMillion: 1.25, OutputPerMillion: 5},
+	"gemini-1.5-flash":  {InputPerMillion: 0.075, OutputPerMillion: 0.3},
+	"gpt-4.1":           {InputPerMillion: 2, OutputPerMillion: 8},
+	"gpt-4.1-mini":      {InputPerMillion: 0.4, OutputPerMillion: 1.6},
+	"gpt-4o":            {InputPerMillion: 2.5, OutputPerMillion: 10},
+	"gpt-4o-mini":       {InputPerMillion: 0.15, OutputPerMillion: 0.6},
+}
+
+func (t PriceTable) Lookup(model string) (Price, bool) {
+	model = strings.TrimPrefix(strings.ToLower(model), "models/")
+	var (
+		best    Price
+		bestLen int
+	)
+	for prefix, price := range t {
+		if strings.HasPrefix(model, prefix) && len(prefix) > bestLen {
+			best, bestLen = price, len(prefix)
+		}
+	}
+	return best, bestLen > 0
+}
+
+// Cost estimates the USD cost of usage on model; unknown models cost nothing.
+func (t PriceTable) Cost(model string, usage Usage) float64 {
+	price, ok := t.Lookup(model)
+	if !ok {
+		return 0
+	}
+	return price.Cost(usage)
+}
+
+// PriceAt prices model the same for every provider and at all times.
+func (t PriceTable) PriceAt(provider, model string, at time.Time) (Price, bool) {
+	return t.Lookup(model)
+}
@@ -0,0 +1,96 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Usage reports token consumption as returned by the upstream provider.
+type Usage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+}
+
+func (u Usage) TotalTokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+type CompletionRequest struct {
+	Model       string
+	System      string
+	Prompt      string
+	MaxTokens   int
+	Temperature float64
+	// Capability, when set, is what the serving provider must support, such as CapabilityCode.
+	Capability string
+	// MinOutputTokens is the least output the caller can work with; providers whose model is
+	// listed with a lower limit are passed over rather than cut short.
+	MinOutputTokens int
+	// Latency is the latency class of the request; interactive requests skip batch providers.
+	Latency string
+}
+
+type CompletionResponse struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Text     string `json:"text"`
+	Usage    Usage  `json:"usage"`
+}
+
+// Provider is the adapter contract every model backend implements.
+type Provider interface {
+	Name() string
+	Complete(ctx context.Context, req CompletionRequest) (CompletionResponse, error)
+}
+
+type ErrProviderStatus struct {
+	Provider string
+	Status   int
+	Body     string
+}
+
+func (e ErrProviderStatus) Error() string {
+	return fmt.Sprintf("%s returned status %d: %s", e.Provider, e.Status, e.Body)
+}
+
+// StreamingProvider is implemented by providers that can emit partial output as it is generated.
+// onDelta receives text fragments in order; returning an error from it aborts the stream.
+type StreamingProvider interface {
+	Provider
+	Stream(ctx context.Context, req CompletionRequest, onDelta func(delta string) error) (CompletionResponse, error)
+}
+
+// HealthChecker is implemented by providers that can verify connectivity and credentials cheaply.
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+func checkStatus(client *http.Client, req *http.Request, provider string) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		payload, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return ErrProviderStatus{Provider: provider, Status: resp.StatusCode, Body: string(payload)}
+	}
+	return nil
+}
+
+// decodeJSON sends req and decodes a successful JSON response into into.
+func decodeJSON(client *http.Client, req *http.Request, provider string, into any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		payload, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return ErrProviderStatus{Provider: provider, Status: resp.StatusCode, Body: string(payload)}
+	}
+	return json.NewDecoder(resp.Body).Decode(into)
+}
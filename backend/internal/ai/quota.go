@@ -0,0 +1,237 @@
+package ai
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// QuotaPolicy sizes a user's token bucket. The bucket holds up to Capacity tokens and refills at
+// RefillPerMinute, so a user can burst to Capacity and sustain RefillPerMinute. A zero capacity
+// leaves usage unlimited.
+type QuotaPolicy struct {
+	Capacity        int `json:"capacity"`
+	RefillPerMinute int `json:"refillPerMinute"`
+}
+
+func (p QuotaPolicy) validate() error {
+	if p.Capacity < 0 || p.RefillPerMinute < 0 {
+		return ErrInvalidQuota{Reason: "capacity and refillPerMinute must not be negative"}
+	}
+	if p.Capacity > 0 && p.RefillPerMinute == 0 {
+		return ErrInvalidQuota{Reason: "refillPerMinute must be positive when capacity is set"}
+	}
+	return nil
+}
+
+// QuotaBalance is a user's position in their bucket. The balance goes negative when a request
+// costs more than was left; the user is throttled to local providers until it refills.
+type QuotaBalance struct {
+	User            string  `json:"user"`
+	Unlimited       bool    `json:"unlimited"`
+	Capacity        int     `json:"capacity"`
+	RefillPerMinute int     `json:"refillPerMinute"`
+	Balance         float64 `json:"balance"`
+	Throttled       bool    `json:"throttled"`
+	// RecoversAt is when a throttled user's balance turns positive again.
+	RecoversAt *time.Time `json:"recoversAt,omitempty"`
+}
+
+// QuotaOverview lists the default policy, per-user overrides and every tracked balance.
+type QuotaOverview struct {
+	Default   QuotaPolicy            `json:"default"`
+	Overrides map[string]QuotaPolicy `json:"overrides"`
+	Balances  []QuotaBalance         `json:"balances"`
+}
+
+type ErrInvalidQuota struct {
+	Reason string
+}
+
+func (e ErrInvalidQuota) Error() string {
+	return e.Reason
+}
+
+// ErrQuotaExhausted is returned for throttled users when no local provider can serve them.
+type ErrQuotaExhausted struct {
+	User       string
+	RetryAfter time.Duration
+}
+
+func (e ErrQuotaExhausted) Error() string {
+	return fmt.Sprintf("model quota for %s is exhausted; retry in %s", e.User, e.RetryAfter.Round(time.Second))
+}
+
+type bucket struct {
+	balance float64
+	updated time.Time
+}
+
+// Quota keeps a soft token bucket per user. Requests are admitted while the balance is positive
+// and charged for the tokens they actually used, so a single request may overdraw the bucket.
+type Quota struct {
+	mu        sync.Mutex
+	defaults  QuotaPolicy
+	overrides map[string]QuotaPolicy
+	buckets   map[string]*bucket
+	now       func() time.Time
+}
+
+func NewQuota(defaults QuotaPolicy) (*Quota, error) {
+	if err := defaults.validate(); err != nil {
+		return nil, err
+	}
+	return &Quota{
+		defaults:  defaults,
+		overrides: make(map[string]QuotaPolicy),
+		buckets:   make(map[string]*bucket),
+		now:       time.Now,
+	}, nil
+}
+
+// Allow reports whether user may use hosted providers now.
+func (q *Quota) Allow(user string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	policy := q.policyLocked(user)
+	return policy.Capacity == 0 || q.bucketLocked(user, policy).balance > 0
+}
+
+// Charge takes tokens from user's bucket.
+func (q *Quota) Charge(user string, tokens int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	policy := q.policyLocked(user)
+	if policy.Capacity == 0 || tokens <= 0 {
+		return
+	}
+	q.bucketLocked(user, policy).balance -= float64(tokens)
+}
+
+func (q *Quota) Balance(user string) QuotaBalance {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.balanceLocked(user)
+}
+
+func (q *Quota) Overview() QuotaOverview {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	overview := QuotaOverview{Default: q.defaults, Overrides: make(map[string]QuotaPolicy, len(q.overrides))}
+	for user, policy := range q.overrides {
+		overview.Overrides[user] = policy
+	}
+	users := make([]string, 0, len(q.buckets))
+	for user := range q.buckets {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	for _, user := range users {
+		overview.Balances = append(overview.Balances, q.balanceLocked(user))
+	}
+	return overview
+}
+
+// SetDefault changes the policy of every user without an override.
+func (q *Quota) SetDefault(policy QuotaPolicy) error {
+	if err := policy.validate(); err != nil {
+		return err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.defaults = policy
+	q.clampLocked()
+	return nil
+}
+
+// SetUserPolicy overrides the default policy for user.
+func (q *Quota) SetUserPolicy(user string, policy QuotaPolicy) error {
+	if user == "" {
+		return ErrInvalidQuota{Reason: "user is required"}
+	}
+	if err := policy.validate(); err != nil {
+		return err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.overrides[user] = policy
+	q.clampLocked()
+	return nil
+}
+
+// RemoveUserPolicy returns user to the default policy, reporting whether there was an override.
+func (q *Quota) RemoveUserPolicy(user string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_, ok := q.overrides[user]
+	delete(q.overrides, user)
+	q.clampLocked()
+	return ok
+}
+
+func (q *Quota) policyLocked(user string) QuotaPolicy {
+	if policy, ok := q.overrides[user]; ok {
+		return policy
+	}
+	return q.defaults
+}
+
+// bucketLocked refills user's bucket up to now. New users start with a full bucket.
+func (q *Quota) bucketLocked(user string, policy QuotaPolicy) *bucket {
+	now := q.now()
+	b, ok := q.buckets[user]
+	if !ok {
+		b = &bucket{balance: float64(policy.Capacity), updated: now}
+		q.buckets[user] = b
+		return b
+	}
+	refill := now.Sub(b.updated).Minutes() * float64(policy.RefillPerMinute)
+	b.balance = math.Min(b.balance+refill, float64(policy.Capacity))
+	b.updated = now
+	return b
+}
+
+// clampLocked caps balances at capacities lowered by a policy change.
+func (q *Quota) clampLocked() {
+	for user, b := range q.buckets {
+		if policy := q.policyLocked(user); policy.Capacity > 0 {
+			b.balance = math.Min(b.balance, float64(policy.Capacity))
+		}
+	}
+}
+
+func (q *Quota) balanceLocked(user string) QuotaBalance {
+	policy := q.policyLocked(user)
+	balance := QuotaBalance{User: user, Capacity: policy.Capacity, RefillPerMinute: policy.RefillPerMinute}
+	if policy.Capacity == 0 {
+		balance.Unlimited = true
+		return balance
+	}
+	b := q.bucketLocked(user, policy)
+	balance.Balance = math.Round(b.balance)
+	if b.balance <= 0 {
+		balance.Throttled = true
+		recovers := b.updated.Add(q.recoveryLocked(b, policy))
+		balance.RecoversAt = &recovers
+	}
+	return balance
+}
+
+// recoveryLocked is how long b needs to refill to a positive balance.
+func (q *Quota) recoveryLocked(b *bucket, policy QuotaPolicy) time.Duration {
+	minutes := (1 - b.balance) / float64(policy.RefillPerMinute)
+	return time.Duration(math.Ceil(minutes * float64(time.Minute)))
+}
+
+// retryAfter is how long user must wait before hosted providers serve them again.
+func (q *Quota) retryAfter(user string) time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	policy := q.policyLocked(user)
+	if policy.Capacity == 0 {
+		return 0
+	}
+	return q.recoveryLocked(q.bucketLocked(user, policy), policy)
+}
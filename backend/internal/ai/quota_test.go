@@ -0,0 +1,111 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+)
+
+// localEcho is a modelEcho that runs locally, like Ollama.
+type localEcho struct {
+	modelEcho
+}
+
+func (l *localEcho) Local() bool { return true }
+
+func newTestQuota(t *testing.T, policy QuotaPolicy) (*Quota, *time.Time) {
+	t.Helper()
+	quota, err := NewQuota(policy)
+	if err != nil {
+		t.Fatalf("new quota: %v", err)
+	}
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	quota.now = func() time.Time { return now }
+	return quota, &now
+}
+
+func TestQuotaThrottlesSustainedUseToLocalProviders(t *testing.T) {
+	hosted := &modelEcho{name: "anthropic", model: "claude-3-5-haiku"}
+	local := &localEcho{modelEcho{name: "ollama", model: "llama3"}}
+	chain := NewFallbackChain(hosted, local)
+	// Each hosted request costs 1100 tokens, so a 2000 token bucket allows a burst of two.
+	quota, now := newTestQuota(t, QuotaPolicy{Capacity: 2000, RefillPerMinute: 600})
+	chain.SetQuota(quota)
+	ctx := identity.NewContext(context.Background(), "alice")
+
+	for i := 0; i < 3; i++ {
+		resp, err := chain.Complete(ctx, CompletionRequest{Prompt: "hi"})
+		if err != nil {
+			t.Fatalf("complete %d: %v", i, err)
+		}
+		want := "anthropic"
+		if i == 2 {
+			want = "ollama"
+		}
+		if resp.Provider != want {
+			t.Fatalf("request %d served by %s, want %s", i, resp.Provider, want)
+		}
+	}
+	balance := quota.Balance("alice")
+	if !balance.Throttled || balance.Balance != -200 || balance.RecoversAt == nil {
+		t.Fatalf("expected alice to be throttled at -200, got %+v", balance)
+	}
+	if other := quota.Balance("bob"); other.Throttled || other.Balance != 2000 {
+		t.Fatalf("expected bob to keep a full bucket, got %+v", other)
+	}
+
+	*now = now.Add(time.Minute)
+	if resp, _ := chain.Complete(ctx, CompletionRequest{Prompt: "hi"}); resp.Provider != "anthropic" {
+		t.Fatalf("expected the refilled bucket to reach hosted providers, got %s", resp.Provider)
+	}
+	if resp, _ := chain.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); resp.Provider != "anthropic" {
+		t.Fatalf("expected requests without a user to be unmetered, got %s", resp.Provider)
+	}
+}
+
+func TestQuotaWithoutLocalProvider(t *testing.T) {
+	chain := NewFallbackChain(&modelEcho{name: "anthropic", model: "claude-3-5-haiku"})
+	quota, _ := newTestQuota(t, QuotaPolicy{Capacity: 1000, RefillPerMinute: 100})
+	chain.SetQuota(quota)
+	ctx := identity.NewContext(context.Background(), "alice")
+
+	if _, err := chain.Complete(ctx, CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Fatalf("expected the burst to be served: %v", err)
+	}
+	var exhausted ErrQuotaExhausted
+	if _, err := chain.Complete(ctx, CompletionRequest{Prompt: "hi"}); !errors.As(err, &exhausted) {
+		t.Fatalf("expected an exhausted quota, got %v", err)
+	}
+	if exhausted.RetryAfter.Round(time.Second) != 61*time.Second {
+		t.Fatalf("expected to wait until the balance is positive, got %s", exhausted.RetryAfter)
+	}
+}
+
+func TestQuotaPolicies(t *testing.T) {
+	quota, _ := newTestQuota(t, QuotaPolicy{})
+	if balance := quota.Balance("alice"); !balance.Unlimited {
+		t.Fatalf("expected a zero capacity to be unlimited, got %+v", balance)
+	}
+	if err := quota.SetDefault(QuotaPolicy{Capacity: 100}); !errors.As(err, &ErrInvalidQuota{}) {
+		t.Fatalf("expected a capacity without refill to be rejected, got %v", err)
+	}
+	if err := quota.SetDefault(QuotaPolicy{Capacity: 5000, RefillPerMinute: 100}); err != nil {
+		t.Fatalf("set default: %v", err)
+	}
+	if err := quota.SetUserPolicy("alice", QuotaPolicy{Capacity: 500, RefillPerMinute: 50}); err != nil {
+		t.Fatalf("set user policy: %v", err)
+	}
+	if balance := quota.Balance("alice"); balance.Capacity != 500 || balance.Balance != 500 {
+		t.Fatalf("expected the override to cap alice's bucket, got %+v", balance)
+	}
+	if !quota.RemoveUserPolicy("alice") || quota.RemoveUserPolicy("alice") {
+		t.Fatalf("expected exactly one override to remove")
+	}
+	overview := quota.Overview()
+	if overview.Default.Capacity != 5000 || len(overview.Overrides) != 0 || len(overview.Balances) != 1 {
+		t.Fatalf("unexpected overview %+v", overview)
+	}
+}
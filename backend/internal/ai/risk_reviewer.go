@@ -0,0 +1,65 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/plan"
+)
+
+const riskReviewSystemPrompt = `You are a Kubernetes change-safety reviewer. Classify the risk of executing the given kubectl command against a live cluster.
+Answer with exactly one word: low, medium, or high.
+- low: read-only inspection with no side effects.
+- medium: reversible changes to a single workload (scale, restart, label).
+- high: destructive, irreversible, cluster-wide, or security-sensitive changes (delete, drain, RBAC, secrets).`
+
+var riskVerdictPattern = regexp.MustCompile(`(?i)\b(low|medium|high)\b`)
+
+// ModelRiskReviewer asks an independent model to classify plan steps so that
+// a single model mislabelling a destructive command cannot go unnoticed.
+type ModelRiskReviewer struct {
+	provider Provider
+	model    string
+}
+
+func NewModelRiskReviewer(provider Provider, model string) *ModelRiskReviewer {
+	return &ModelRiskReviewer{provider: provider, model: model}
+}
+
+func (r *ModelRiskReviewer) Name() string {
+	if r.model == "" {
+		return r.provider.Name()
+	}
+	return r.provider.Name() + "/" + r.model
+}
+
+func (r *ModelRiskReviewer) ReviewStep(ctx context.Context, draft plan.PlanDraft, step plan.PlanStep) (string, error) {
+	prompt := fmt.Sprintf("Cluster: %s\nNamespace: %s\nOperator request: %s\nCommand: %s",
+		step.Target.Cluster, step.Target.Namespace, draft.Prompt, step.Command)
+	// Follow-up requests such as "now scale it to 3" only make sense with what the
+	// conversation referred to before.
+	if carried := draft.Conversation.String(); carried != "" {
+		prompt += "\nConversation context: " + carried
+	}
+
+	resp, err := r.provider.Complete(ctx, CompletionRequest{
+		Model:       r.model,
+		System:      riskReviewSystemPrompt,
+		Prompt:      prompt,
+		MaxTokens:   8,
+		Temperature: 0,
+		// The plan waits on the review.
+		Latency: LatencyInteractive,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	match := riskVerdictPattern.FindStringSubmatch(resp.Text)
+	if len(match) < 2 {
+		return "", fmt.Errorf("unrecognised risk verdict %q", strings.TrimSpace(resp.Text))
+	}
+	return strings.ToLower(match[1]), nil
+}
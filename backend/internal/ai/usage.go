@@ -0,0 +1,67 @@
+package ai
+
+import (
+	"context"
+	"time"
+)
+
+// UsageRecord is the token usage of one provider call, as the provider reported it, attributed to
+// the user and chat session the call was made for.
+type UsageRecord struct {
+	At               time.Time `json:"at"`
+	User             string    `json:"user,omitempty"`
+	Session          string    `json:"session,omitempty"`
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"promptTokens"`
+	CompletionTokens int       `json:"completionTokens"`
+	CostUSD          float64   `json:"costUsd"`
+	// KeyOwner is the user whose own API key paid for the call; it is empty when KubeChat's did.
+	KeyOwner string `json:"keyOwner,omitempty"`
+}
+
+// UsageRecorder receives the usage of every provider call that consumed tokens.
+type UsageRecorder interface {
+	RecordUsage(ctx context.Context, record UsageRecord)
+}
+
+type sessionKey struct{}
+
+// NewSessionContext attributes model calls made with ctx to a chat session.
+func NewSessionContext(ctx context.Context, session string) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+// SessionFromContext returns the session stored by NewSessionContext, or "" when there is none.
+func SessionFromContext(ctx context.Context) string {
+	session, _ := ctx.Value(sessionKey{}).(string)
+	return session
+}
+
+// UsageTotals sums the usage of one user and session on one model.
+type UsageTotals struct {
+	User             string    `json:"user,omitempty"`
+	Session          string    `json:"session,omitempty"`
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	KeyOwner         string    `json:"keyOwner,omitempty"`
+	Requests         int       `json:"requests"`
+	PromptTokens     int       `json:"promptTokens"`
+	CompletionTokens int       `json:"completionTokens"`
+	CostUSD          float64   `json:"costUsd"`
+	LastUsedAt       time.Time `json:"lastUsedAt"`
+}
+
+// UsageFilter narrows a usage report; empty fields match everything.
+type UsageFilter struct {
+	User    string
+	Session string
+	Model   string
+}
+
+// Matches reports whether record falls within the filter.
+func (f UsageFilter) Matches(record UsageRecord) bool {
+	return (f.User == "" || f.User == record.User) &&
+		(f.Session == "" || f.Session == record.Session) &&
+		(f.Model == "" || f.Model == record.Model)
+}
@@ -0,0 +1,74 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// UserKeys builds providers from the API keys users bring for themselves.
+type UserKeys interface {
+	// Providers returns the providers calling with user's own keys, in the order to try them.
+	Providers(ctx context.Context, user string) ([]Provider, error)
+}
+
+// SetUserKeys tries a user's own keys before the shared providers. Calls made with them are
+// neither charged to quotas nor held back by budgets, and their usage is recorded with the key
+// owner, since KubeChat does not pay for them.
+func (c *FallbackChain) SetUserKeys(keys UserKeys) {
+	c.keys = keys
+}
+
+// member is a provider tried for a request: one of the chain's, at index, or one calling with the
+// own key of owner.
+type member struct {
+	provider Provider
+	index    int
+	owner    string
+}
+
+// members lists the providers to try for user in order. When the user's keys cannot be loaded
+// the shared providers are still tried, and the error is reported if they all fail.
+func (c *FallbackChain) members(ctx context.Context, user string) ([]member, error) {
+	members := make([]member, 0, len(c.providers))
+	var err error
+	if c.keys != nil && user != "" {
+		own, keysErr := c.keys.Providers(ctx, user)
+		if keysErr != nil {
+			err = fmt.Errorf("own API keys of %s: %w", user, keysErr)
+		}
+		for _, provider := range own {
+			members = append(members, member{provider: provider, index: -1, owner: user})
+		}
+	}
+	c.mu.Lock()
+	order := c.orderLocked()
+	c.mu.Unlock()
+	for _, i := range order {
+		members = append(members, member{provider: c.providers[i], index: i})
+	}
+	return members, err
+}
+
+func owned(members []member) bool {
+	return len(members) > 0 && members[0].owner != ""
+}
+
+// primary reports whether provider is the same kind of provider as the chain's first.
+func (c *FallbackChain) primary(provider Provider) bool {
+	return len(c.providers) > 0 && c.providers[0].Name() == provider.Name()
+}
+
+// price returns what a call to model cost at the chain's prices.
+func (c *FallbackChain) price(provider Provider, model string, resp CompletionResponse) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.priceLocked(provider, model, resp)
+}
+
+func (c *FallbackChain) priceLocked(provider Provider, model string, resp CompletionResponse) float64 {
+	if price, ok := c.prices.PriceAt(provider.Name(), model, time.Now()); ok {
+		return price.Cost(resp.Usage)
+	}
+	return 0
+}
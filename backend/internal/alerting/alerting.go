@@ -0,0 +1,334 @@
+// Package alerting notifies people of security events and of requests awaiting their decision.
+// Alerts are routed by severity, type and addressed group to channels such as Slack, PagerDuty,
+// email and webhooks; repeats are deduplicated, each channel is rate limited, and the outcome of
+// every delivery is recorded.
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+)
+
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityLow      Severity = "low"
+	SeverityMedium   Severity = "medium"
+	SeverityHigh     Severity = "high"
+	SeverityCritical Severity = "critical"
+)
+
+var severityRank = map[Severity]int{SeverityInfo: 0, SeverityLow: 1, SeverityMedium: 2, SeverityHigh: 3, SeverityCritical: 4}
+
+// AtLeast reports whether s is as severe as other.
+func (s Severity) AtLeast(other Severity) bool {
+	return severityRank[s] >= severityRank[other]
+}
+
+func (s Severity) valid() bool {
+	_, ok := severityRank[s]
+	return ok
+}
+
+// Alert is a security event people should know about.
+type Alert struct {
+	ID string `json:"id"`
+	// Type names the kind of event, such as anomaly.impossible_travel or audit.tamper.
+	Type     string   `json:"type"`
+	Severity Severity `json:"severity"`
+	Title    string   `json:"title"`
+	Summary  string   `json:"summary"`
+	// DedupKey identifies repeats of the same alert; the type when empty.
+	DedupKey string         `json:"dedupKey,omitempty"`
+	Details  map[string]any `json:"details,omitempty"`
+	// Groups addresses the alert to groups of people, such as the approvers of a command.
+	Groups []string `json:"groups,omitempty"`
+	// Links are actions the recipients can take, shown as buttons where the channel has them.
+	Links []Link    `json:"links,omitempty"`
+	At    time.Time `json:"at"`
+}
+
+// Link is an action offered with an alert.
+type Link struct {
+	Text string `json:"text"`
+	URL  string `json:"url"`
+}
+
+func (a Alert) dedupKey() string {
+	if a.DedupKey != "" {
+		return a.DedupKey
+	}
+	return a.Type
+}
+
+type DeliveryStatus string
+
+const (
+	DeliverySent        DeliveryStatus = "sent"
+	DeliveryFailed      DeliveryStatus = "failed"
+	DeliveryRateLimited DeliveryStatus = "rate_limited"
+)
+
+// Delivery is the outcome of sending an alert to one channel.
+type Delivery struct {
+	Channel  string         `json:"channel"`
+	Type     ChannelType    `json:"type"`
+	Status   DeliveryStatus `json:"status"`
+	Attempts int            `json:"attempts"`
+	Error    string         `json:"error,omitempty"`
+	At       time.Time      `json:"at"`
+}
+
+// Record is a dispatched alert with its deliveries. A deduplicated alert repeated one dispatched
+// within the dedup window and was not sent again.
+type Record struct {
+	Alert        Alert      `json:"alert"`
+	Deduplicated bool       `json:"deduplicated,omitempty"`
+	Deliveries   []Delivery `json:"deliveries"`
+}
+
+// Filter narrows the records listed; Limit defaults to DefaultLimit.
+type Filter struct {
+	Type        string
+	MinSeverity Severity
+	Limit       int
+}
+
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+type ErrInvalidRequest struct {
+	Reason string
+}
+
+func (e ErrInvalidRequest) Error() string {
+	return e.Reason
+}
+
+// Route sends the alerts of at least MinSeverity whose type matches one of Types to Channels.
+// Types are path patterns such as anomaly.* ; a route without types matches every alert. A route
+// naming Groups only carries the alerts addressed to one of them, and alerts addressed to groups
+// only take such routes, so that their links reach nobody else.
+type Route struct {
+	Channels    []string `json:"channels"`
+	MinSeverity Severity `json:"minSeverity,omitempty"`
+	Types       []string `json:"types,omitempty"`
+	Groups      []string `json:"groups,omitempty"`
+}
+
+func (r Route) matches(alert Alert) bool {
+	if r.MinSeverity != "" && !alert.Severity.AtLeast(r.MinSeverity) {
+		return false
+	}
+	if (len(r.Groups) > 0 || len(alert.Groups) > 0) &&
+		!slices.ContainsFunc(r.Groups, func(group string) bool { return slices.Contains(alert.Groups, group) }) {
+		return false
+	}
+	if len(r.Types) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(r.Types, func(pattern string) bool {
+		matched, _ := path.Match(pattern, alert.Type)
+		return matched
+	})
+}
+
+const (
+	// maxAttempts bounds the tries of a delivery that keeps failing.
+	maxAttempts = 3
+	// DefaultDedupWindow and DefaultRateLimit apply when the configuration leaves them out.
+	DefaultDedupWindow = 15 * time.Minute
+	DefaultRateLimit   = 30
+)
+
+// Dispatcher routes alerts to channels and records the deliveries.
+type Dispatcher struct {
+	channels []Channel
+	routes   []Route
+	repo     Repository
+	logger   *log.Logger
+	now      func() time.Time
+	// dedupWindow suppresses repeats of an alert; rateLimit bounds each channel's deliveries per minute.
+	dedupWindow time.Duration
+	rateLimit   int
+	retryDelay  time.Duration
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+	sent map[string][]time.Time
+}
+
+// NewDispatcher returns a dispatcher for the channels and routes of config, which must be valid.
+func NewDispatcher(config Config, channels []Channel, repo Repository, logger *log.Logger) *Dispatcher {
+	if logger == nil {
+		logger = log.Default()
+	}
+	d := &Dispatcher{
+		channels:    channels,
+		routes:      config.Routes,
+		repo:        repo,
+		logger:      logger,
+		now:         func() time.Time { return time.Now().UTC() },
+		dedupWindow: DefaultDedupWindow,
+		rateLimit:   DefaultRateLimit,
+		retryDelay:  time.Second,
+		seen:        map[string]time.Time{},
+		sent:        map[string][]time.Time{},
+	}
+	if config.DedupMinutes != nil {
+		d.dedupWindow = time.Duration(*config.DedupMinutes) * time.Minute
+	}
+	if config.RateLimitPerMinute > 0 {
+		d.rateLimit = config.RateLimitPerMinute
+	}
+	return d
+}
+
+// ChannelInfo describes a configured channel.
+type ChannelInfo struct {
+	Name string      `json:"name"`
+	Type ChannelType `json:"type"`
+}
+
+func (d *Dispatcher) Channels() []ChannelInfo {
+	infos := make([]ChannelInfo, 0, len(d.channels))
+	for _, channel := range d.channels {
+		infos = append(infos, ChannelInfo{Name: channel.Name(), Type: channel.Type()})
+	}
+	return infos
+}
+
+func (d *Dispatcher) Routes() []Route {
+	return d.routes
+}
+
+// Dispatch sends alert to the channels of every route it matches, unless it repeats an alert
+// dispatched within the dedup window, and records the outcome. Failed deliveries are retried a
+// few times before they are recorded as failed.
+func (d *Dispatcher) Dispatch(ctx context.Context, alert Alert) (Record, error) {
+	if alert.ID == "" {
+		alert.ID = uuid.NewString()
+	}
+	if alert.At.IsZero() {
+		alert.At = d.now()
+	}
+	record := Record{Alert: alert, Deliveries: []Delivery{}}
+
+	d.mu.Lock()
+	key := alert.dedupKey()
+	if last, ok := d.seen[key]; ok && alert.At.Sub(last) < d.dedupWindow {
+		record.Deduplicated = true
+	} else {
+		d.seen[key] = alert.At
+	}
+	d.mu.Unlock()
+
+	if !record.Deduplicated {
+		for _, channel := range d.route(alert) {
+			record.Deliveries = append(record.Deliveries, d.deliver(ctx, channel, alert))
+		}
+	}
+	if err := d.repo.Save(ctx, record); err != nil {
+		return record, err
+	}
+	return record, nil
+}
+
+// Test sends a test alert to one channel, bypassing routes, deduplication and rate limits.
+func (d *Dispatcher) Test(ctx context.Context, name, actor string) (Delivery, error) {
+	for _, channel := range d.channels {
+		if channel.Name() != name {
+			continue
+		}
+		alert := Alert{
+			ID:       uuid.NewString(),
+			Type:     "alerting.test",
+			Severity: SeverityInfo,
+			Title:    "KubeChat test alert",
+			Summary:  fmt.Sprintf("%s sent a test alert to the %s channel.", actor, name),
+			At:       d.now(),
+		}
+		return d.send(ctx, channel, alert), nil
+	}
+	return Delivery{}, ErrInvalidRequest{Reason: fmt.Sprintf("no channel is named %q", name)}
+}
+
+func (d *Dispatcher) List(ctx context.Context, filter Filter) ([]Record, error) {
+	if filter.MinSeverity != "" && !filter.MinSeverity.valid() {
+		return nil, ErrInvalidRequest{Reason: fmt.Sprintf("unknown severity %q", filter.MinSeverity)}
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultLimit
+	}
+	filter.Limit = min(filter.Limit, MaxLimit)
+	return d.repo.List(ctx, filter)
+}
+
+// route returns the channels of the routes alert matches, each once, in configuration order.
+func (d *Dispatcher) route(alert Alert) []Channel {
+	var names []string
+	for _, route := range d.routes {
+		if route.matches(alert) {
+			names = append(names, route.Channels...)
+		}
+	}
+	var channels []Channel
+	for _, channel := range d.channels {
+		if slices.Contains(names, channel.Name()) {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, channel Channel, alert Alert) Delivery {
+	d.mu.Lock()
+	cutoff := d.now().Add(-time.Minute)
+	sent := slices.DeleteFunc(d.sent[channel.Name()], func(at time.Time) bool { return !at.After(cutoff) })
+	limited := len(sent) >= d.rateLimit
+	if !limited {
+		sent = append(sent, d.now())
+	}
+	d.sent[channel.Name()] = sent
+	d.mu.Unlock()
+	if limited {
+		d.logger.Warn("alert rate limited", "channel", channel.Name(), "alert", alert.ID, "type", alert.Type)
+		return Delivery{Channel: channel.Name(), Type: channel.Type(), Status: DeliveryRateLimited, At: d.now()}
+	}
+	return d.send(ctx, channel, alert)
+}
+
+func (d *Dispatcher) send(ctx context.Context, channel Channel, alert Alert) Delivery {
+	delivery := Delivery{Channel: channel.Name(), Type: channel.Type(), Status: DeliverySent}
+	for delivery.Attempts < maxAttempts {
+		if delivery.Attempts > 0 {
+			select {
+			case <-ctx.Done():
+				delivery.Error = ctx.Err().Error()
+				delivery.Status, delivery.At = DeliveryFailed, d.now()
+				return delivery
+			case <-time.After(d.retryDelay * time.Duration(delivery.Attempts)):
+			}
+		}
+		delivery.Attempts++
+		err := channel.Send(ctx, alert)
+		if err == nil {
+			delivery.Error, delivery.At = "", d.now()
+			return delivery
+		}
+		delivery.Error = err.Error()
+	}
+	d.logger.Error("failed to deliver alert", "channel", channel.Name(), "alert", alert.ID, "type", alert.Type, "error", delivery.Error)
+	delivery.Status, delivery.At = DeliveryFailed, d.now()
+	return delivery
+}
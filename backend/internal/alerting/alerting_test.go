@@ -0,0 +1,169 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+type fakeChannel struct {
+	name   string
+	fail   bool
+	alerts []Alert
+}
+
+func (c *fakeChannel) Name() string      { return c.name }
+func (c *fakeChannel) Type() ChannelType { return ChannelWebhook }
+
+func (c *fakeChannel) Send(ctx context.Context, alert Alert) error {
+	c.alerts = append(c.alerts, alert)
+	if c.fail {
+		return errors.New("unreachable")
+	}
+	return nil
+}
+
+func TestDispatchRoutesDeduplicatesAndRateLimits(t *testing.T) {
+	oncall, security, broken := &fakeChannel{name: "oncall"}, &fakeChannel{name: "security"}, &fakeChannel{name: "broken", fail: true}
+	dedup := 10
+	config := Config{
+		Routes: []Route{
+			{Channels: []string{"oncall"}, MinSeverity: SeverityCritical},
+			{Channels: []string{"security", "broken"}, Types: []string{"anomaly.*"}},
+		},
+		DedupMinutes:       &dedup,
+		RateLimitPerMinute: 2,
+	}
+	dispatcher := NewDispatcher(config, []Channel{oncall, security, broken}, NewMemoryRepository(), log.NewWithOptions(io.Discard, log.Options{}))
+	dispatcher.retryDelay = 0
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	record, err := dispatcher.Dispatch(ctx, Alert{Type: "anomaly.new_namespace", Severity: SeverityLow, DedupKey: "alice", At: now})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(record.Deliveries) != 2 || record.Deliveries[0].Status != DeliverySent || record.Deliveries[1].Status != DeliveryFailed || record.Deliveries[1].Attempts != maxAttempts {
+		t.Fatalf("expected the anomaly to reach security and fail on broken after retries, got %+v", record.Deliveries)
+	}
+
+	record, _ = dispatcher.Dispatch(ctx, Alert{Type: "anomaly.new_namespace", Severity: SeverityLow, DedupKey: "alice", At: now.Add(time.Minute)})
+	if !record.Deduplicated || len(record.Deliveries) != 0 {
+		t.Fatalf("expected the repeat to be deduplicated, got %+v", record)
+	}
+
+	record, _ = dispatcher.Dispatch(ctx, Alert{Type: "audit.tamper", Severity: SeverityCritical, At: now})
+	if len(record.Deliveries) != 1 || record.Deliveries[0].Channel != "oncall" {
+		t.Fatalf("expected the tamper alert to page oncall only, got %+v", record.Deliveries)
+	}
+
+	dispatcher.Dispatch(ctx, Alert{Type: "anomaly.new_namespace", Severity: SeverityLow, DedupKey: "carol", At: now})
+	record, _ = dispatcher.Dispatch(ctx, Alert{Type: "anomaly.kube_system_tampering", Severity: SeverityCritical, DedupKey: "bob", At: now})
+	statuses := map[string]DeliveryStatus{}
+	for _, delivery := range record.Deliveries {
+		statuses[delivery.Channel] = delivery.Status
+	}
+	if statuses["oncall"] != DeliverySent || statuses["security"] != DeliveryRateLimited || statuses["broken"] != DeliveryRateLimited {
+		t.Fatalf("expected the anomaly channels to be rate limited after two deliveries a minute, got %v", statuses)
+	}
+
+	records, err := dispatcher.List(ctx, Filter{MinSeverity: SeverityCritical})
+	if err != nil || len(records) != 2 || records[0].Alert.Type != "anomaly.kube_system_tampering" {
+		t.Fatalf("expected the critical alerts newest first, got %+v (%v)", records, err)
+	}
+	if _, err := dispatcher.Test(ctx, "missing", "alice"); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected an unknown channel to be refused, got %v", err)
+	}
+}
+
+func TestAlertsAddressedToGroupsOnlyTakeTheirRoutes(t *testing.T) {
+	approvers, security := &fakeChannel{name: "approvers"}, &fakeChannel{name: "security"}
+	config := Config{Routes: []Route{
+		{Channels: []string{"approvers"}, Groups: []string{"sre"}},
+		{Channels: []string{"security"}},
+	}}
+	dispatcher := NewDispatcher(config, []Channel{approvers, security}, NewMemoryRepository(), log.NewWithOptions(io.Discard, log.Options{}))
+	ctx := context.Background()
+
+	dispatcher.Dispatch(ctx, Alert{Type: "approval.requested", Severity: SeverityHigh, Groups: []string{"leads", "sre"}, DedupKey: "e1"})
+	dispatcher.Dispatch(ctx, Alert{Type: "approval.requested", Severity: SeverityHigh, Groups: []string{"finance"}, DedupKey: "e2"})
+	dispatcher.Dispatch(ctx, Alert{Type: "audit.tamper", Severity: SeverityCritical})
+	if len(approvers.alerts) != 1 || approvers.alerts[0].DedupKey != "e1" {
+		t.Fatalf("expected only the sre approval to reach the approvers, got %+v", approvers.alerts)
+	}
+	if len(security.alerts) != 1 || security.alerts[0].Type != "audit.tamper" {
+		t.Fatalf("expected approval requests to stay off the catch-all route, got %+v", security.alerts)
+	}
+}
+
+func TestChannelsFormatAlertsForTheirDestination(t *testing.T) {
+	var bodies []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	alert := Alert{ID: "a1", Type: "audit.tamper", Severity: SeverityHigh, Title: "Tampered", Summary: "Record 4 was altered", DedupKey: "audit.tamper/4", At: time.Now().UTC()}
+	ctx := context.Background()
+
+	if err := NewSlackChannel("slack", server.URL, server.Client()).Send(ctx, alert); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := NewPagerDutyChannel("pd", server.URL, "routing-key", server.Client()).Send(ctx, alert); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if blocks, ok := bodies[0]["blocks"].([]any); !ok || len(blocks) != 3 {
+		t.Fatalf("expected a Slack message with three blocks, got %v", bodies[0])
+	}
+	alert.Links = []Link{{Text: "Approve", URL: "https://kubechat.example.com/approve"}}
+	if err := NewSlackChannel("slack", server.URL, server.Client()).Send(ctx, alert); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	blocks, _ := bodies[len(bodies)-1]["blocks"].([]any)
+	if actions, _ := blocks[len(blocks)-1].(map[string]any); actions["type"] != "actions" {
+		t.Fatalf("expected the links as Slack buttons, got %v", blocks)
+	}
+	payload, _ := bodies[1]["payload"].(map[string]any)
+	if bodies[1]["routing_key"] != "routing-key" || bodies[1]["dedup_key"] != "audit.tamper/4" || payload["severity"] != "error" {
+		t.Fatalf("expected a PagerDuty trigger with the dedup key and error severity, got %v", bodies[1])
+	}
+
+	email := NewEmailChannel("mail", SMTPConfig{Host: "smtp.example.com", Username: "kubechat", From: "kubechat@example.com", To: []string{"sec@example.com"}}, "secret")
+	var sent string
+	email.sendMail = func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+		sent = addr + "\n" + string(msg)
+		return nil
+	}
+	if err := email.Send(ctx, alert); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(sent, "smtp.example.com:587\n") || !strings.Contains(sent, "Subject: [KubeChat] [HIGH] Tampered") ||
+		!strings.Contains(sent, "Approve: https://kubechat.example.com/approve") {
+		t.Fatalf("unexpected email: %s", sent)
+	}
+}
+
+func TestLoadConfigRejectsRoutesToUnknownChannels(t *testing.T) {
+	config := Config{
+		Channels: []ChannelConfig{{Name: "slack", Type: ChannelSlack, SecretEnv: "SLACK_URL"}},
+		Routes:   []Route{{Channels: []string{"pagerduty"}}},
+	}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "unknown channel pagerduty") {
+		t.Fatalf("expected the unknown channel to be refused, got %v", err)
+	}
+	config.Routes[0].Channels = []string{"slack"}
+	if _, err := config.BuildChannels(func(string) (string, bool) { return "", false }, nil); err == nil {
+		t.Fatalf("expected a missing secret to be refused")
+	}
+}
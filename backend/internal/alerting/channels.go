@@ -0,0 +1,210 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+type ChannelType string
+
+const (
+	ChannelSlack     ChannelType = "slack"
+	ChannelPagerDuty ChannelType = "pagerduty"
+	ChannelEmail     ChannelType = "email"
+	ChannelWebhook   ChannelType = "webhook"
+)
+
+// Channel delivers alerts to one destination.
+type Channel interface {
+	Name() string
+	Type() ChannelType
+	Send(ctx context.Context, alert Alert) error
+}
+
+// sendTimeout bounds each attempt to deliver an alert.
+const sendTimeout = 10 * time.Second
+
+// SignatureHeader carries the hex HMAC-SHA256 of the body of webhook alerts, as sha256=<hex>.
+const SignatureHeader = "X-Kubechat-Signature"
+
+func post(ctx context.Context, client *http.Client, url string, body []byte, headers map[string]string) error {
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s answered %s: %s", req.URL.Host, resp.Status, bytes.TrimSpace(raw))
+	}
+	return nil
+}
+
+// WebhookChannel posts alerts as JSON, signed with the secret when there is one.
+type WebhookChannel struct {
+	name, url, secret string
+	client            *http.Client
+}
+
+func NewWebhookChannel(name, url, secret string, client *http.Client) *WebhookChannel {
+	return &WebhookChannel{name: name, url: url, secret: secret, client: client}
+}
+
+func (c *WebhookChannel) Name() string      { return c.name }
+func (c *WebhookChannel) Type() ChannelType { return ChannelWebhook }
+
+func (c *WebhookChannel) Send(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{"X-Kubechat-Alert": alert.ID}
+	if c.secret != "" {
+		mac := hmac.New(sha256.New, []byte(c.secret))
+		mac.Write(body)
+		headers[SignatureHeader] = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	return post(ctx, c.client, c.url, body, headers)
+}
+
+// SlackChannel posts alerts to a Slack incoming webhook as Block Kit messages.
+type SlackChannel struct {
+	name, url string
+	client    *http.Client
+}
+
+func NewSlackChannel(name, webhookURL string, client *http.Client) *SlackChannel {
+	return &SlackChannel{name: name, url: webhookURL, client: client}
+}
+
+func (c *SlackChannel) Name() string      { return c.name }
+func (c *SlackChannel) Type() ChannelType { return ChannelSlack }
+
+var slackEmoji = map[Severity]string{
+	SeverityInfo:     ":information_source:",
+	SeverityLow:      ":large_blue_circle:",
+	SeverityMedium:   ":large_yellow_circle:",
+	SeverityHigh:     ":large_orange_circle:",
+	SeverityCritical: ":red_circle:",
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackButton struct {
+	Type string    `json:"type"`
+	Text slackText `json:"text"`
+	URL  string    `json:"url"`
+}
+
+type slackBlock struct {
+	Type     string     `json:"type"`
+	Text     *slackText `json:"text,omitempty"`
+	Elements []any      `json:"elements,omitempty"`
+}
+
+func (c *SlackChannel) Send(ctx context.Context, alert Alert) error {
+	blocks := []slackBlock{
+		{Type: "header", Text: &slackText{Type: "plain_text", Text: alert.Title}},
+		{Type: "section", Text: &slackText{Type: "mrkdwn", Text: alert.Summary}},
+		{Type: "context", Elements: []any{slackText{
+			Type: "mrkdwn",
+			Text: fmt.Sprintf("%s *%s* · `%s` · %s", slackEmoji[alert.Severity], alert.Severity, alert.Type, alert.At.Format(time.RFC3339)),
+		}}},
+	}
+	if len(alert.Links) > 0 {
+		actions := slackBlock{Type: "actions"}
+		for _, link := range alert.Links {
+			actions.Elements = append(actions.Elements, slackButton{Type: "button", Text: slackText{Type: "plain_text", Text: link.Text}, URL: link.URL})
+		}
+		blocks = append(blocks, actions)
+	}
+	body, err := json.Marshal(map[string]any{
+		// text is shown in notifications, where blocks are not.
+		"text":   fmt.Sprintf("[%s] %s: %s", alert.Severity, alert.Title, alert.Summary),
+		"blocks": blocks,
+	})
+	if err != nil {
+		return err
+	}
+	return post(ctx, c.client, c.url, body, nil)
+}
+
+// DefaultPagerDutyURL is the PagerDuty Events API v2 endpoint.
+const DefaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDutyChannel triggers PagerDuty incidents through the Events API v2. Repeats of an alert
+// share its dedup key, so PagerDuty groups them into one incident.
+type PagerDutyChannel struct {
+	name, url, routingKey string
+	client                *http.Client
+}
+
+func NewPagerDutyChannel(name, url, routingKey string, client *http.Client) *PagerDutyChannel {
+	if url == "" {
+		url = DefaultPagerDutyURL
+	}
+	return &PagerDutyChannel{name: name, url: url, routingKey: routingKey, client: client}
+}
+
+func (c *PagerDutyChannel) Name() string      { return c.name }
+func (c *PagerDutyChannel) Type() ChannelType { return ChannelPagerDuty }
+
+// pagerDutySeverity maps severities to the four PagerDuty knows.
+var pagerDutySeverity = map[Severity]string{
+	SeverityInfo:     "info",
+	SeverityLow:      "info",
+	SeverityMedium:   "warning",
+	SeverityHigh:     "error",
+	SeverityCritical: "critical",
+}
+
+type pagerDutyLink struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+func (c *PagerDutyChannel) Send(ctx context.Context, alert Alert) error {
+	links := make([]pagerDutyLink, 0, len(alert.Links))
+	for _, link := range alert.Links {
+		links = append(links, pagerDutyLink{Href: link.URL, Text: link.Text})
+	}
+	body, err := json.Marshal(map[string]any{
+		"routing_key":  c.routingKey,
+		"event_action": "trigger",
+		"dedup_key":    alert.dedupKey(),
+		"links":        links,
+		"payload": map[string]any{
+			"summary":        alert.Title + ": " + alert.Summary,
+			"source":         "kubechat",
+			"severity":       pagerDutySeverity[alert.Severity],
+			"timestamp":      alert.At.Format(time.RFC3339),
+			"class":          alert.Type,
+			"custom_details": alert.Details,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return post(ctx, c.client, c.url, body, nil)
+}
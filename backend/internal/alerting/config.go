@@ -0,0 +1,136 @@
+package alerting
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+
+	"sigs.k8s.io/yaml"
+)
+
+// ChannelConfig configures one channel. Secrets are never written in the file: SecretEnv names the
+// environment variable holding the Slack webhook URL, the PagerDuty routing key, the SMTP
+// password or the webhook signing secret.
+type ChannelConfig struct {
+	Name      string      `json:"name"`
+	Type      ChannelType `json:"type"`
+	SecretEnv string      `json:"secretEnv,omitempty"`
+	// URL is the webhook's endpoint, or for PagerDuty the Events API when not the default.
+	URL string `json:"url,omitempty"`
+	// SMTP configures the email channel.
+	SMTP *SMTPConfig `json:"smtp,omitempty"`
+}
+
+type SMTPConfig struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`
+	Username string   `json:"username,omitempty"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// Config configures alerting.
+type Config struct {
+	Channels []ChannelConfig `json:"channels"`
+	Routes   []Route         `json:"routes"`
+	// DedupMinutes is the window in which repeats of an alert are not sent again, 15 when left
+	// out; 0 sends every repeat.
+	DedupMinutes *int `json:"dedupMinutes,omitempty"`
+	// RateLimitPerMinute bounds the alerts each channel is sent per minute, 30 when left out.
+	RateLimitPerMinute int `json:"rateLimitPerMinute,omitempty"`
+}
+
+// LoadConfig reads the alerting configuration from a YAML file.
+func LoadConfig(path string) (Config, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	var config Config
+	if err := yaml.UnmarshalStrict(raw, &config); err != nil {
+		return Config{}, fmt.Errorf("parse alerting config %s: %w", path, err)
+	}
+	if err := config.Validate(); err != nil {
+		return Config{}, fmt.Errorf("alerting config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+func (c Config) Validate() error {
+	var names []string
+	for _, channel := range c.Channels {
+		switch {
+		case channel.Name == "":
+			return fmt.Errorf("every channel needs a name")
+		case slices.Contains(names, channel.Name):
+			return fmt.Errorf("channel %s is configured twice", channel.Name)
+		}
+		names = append(names, channel.Name)
+		switch channel.Type {
+		case ChannelSlack, ChannelPagerDuty:
+			if channel.SecretEnv == "" {
+				return fmt.Errorf("channel %s: %s needs secretEnv", channel.Name, channel.Type)
+			}
+		case ChannelWebhook:
+			if channel.URL == "" {
+				return fmt.Errorf("channel %s: webhook needs a url", channel.Name)
+			}
+		case ChannelEmail:
+			if smtp := channel.SMTP; smtp == nil || smtp.Host == "" || smtp.From == "" || len(smtp.To) == 0 {
+				return fmt.Errorf("channel %s: email needs smtp host, from and to", channel.Name)
+			}
+		default:
+			return fmt.Errorf("channel %s: unknown type %q", channel.Name, channel.Type)
+		}
+	}
+	for i, route := range c.Routes {
+		if len(route.Channels) == 0 {
+			return fmt.Errorf("route %d names no channel", i+1)
+		}
+		for _, name := range route.Channels {
+			if !slices.Contains(names, name) {
+				return fmt.Errorf("route %d: unknown channel %s", i+1, name)
+			}
+		}
+		if route.MinSeverity != "" && !route.MinSeverity.valid() {
+			return fmt.Errorf("route %d: unknown severity %q", i+1, route.MinSeverity)
+		}
+	}
+	if c.DedupMinutes != nil && *c.DedupMinutes < 0 {
+		return fmt.Errorf("dedupMinutes must not be negative")
+	}
+	if c.RateLimitPerMinute < 0 {
+		return fmt.Errorf("rateLimitPerMinute must not be negative")
+	}
+	return nil
+}
+
+// BuildChannels creates the configured channels, reading their secrets with lookupEnv.
+func (c Config) BuildChannels(lookupEnv func(string) (string, bool), client *http.Client) ([]Channel, error) {
+	if client == nil {
+		client = &http.Client{Timeout: sendTimeout}
+	}
+	channels := make([]Channel, 0, len(c.Channels))
+	for _, config := range c.Channels {
+		var secret string
+		if config.SecretEnv != "" {
+			value, ok := lookupEnv(config.SecretEnv)
+			if !ok || value == "" {
+				return nil, fmt.Errorf("channel %s: %s is not set", config.Name, config.SecretEnv)
+			}
+			secret = value
+		}
+		switch config.Type {
+		case ChannelSlack:
+			channels = append(channels, NewSlackChannel(config.Name, secret, client))
+		case ChannelPagerDuty:
+			channels = append(channels, NewPagerDutyChannel(config.Name, config.URL, secret, client))
+		case ChannelEmail:
+			channels = append(channels, NewEmailChannel(config.Name, *config.SMTP, secret))
+		case ChannelWebhook:
+			channels = append(channels, NewWebhookChannel(config.Name, config.URL, secret, client))
+		}
+	}
+	return channels, nil
+}
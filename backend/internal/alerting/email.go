@@ -0,0 +1,76 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EmailChannel mails alerts through an SMTP server, which is asked to upgrade to TLS when it
+// offers STARTTLS. It authenticates with the password when the config names a username.
+type EmailChannel struct {
+	name     string
+	config   SMTPConfig
+	password string
+	// sendMail is smtp.SendMail, replaced in tests.
+	sendMail func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func NewEmailChannel(name string, config SMTPConfig, password string) *EmailChannel {
+	if config.Port == 0 {
+		config.Port = 587
+	}
+	return &EmailChannel{name: name, config: config, password: password, sendMail: smtp.SendMail}
+}
+
+func (c *EmailChannel) Name() string      { return c.name }
+func (c *EmailChannel) Type() ChannelType { return ChannelEmail }
+
+func (c *EmailChannel) Send(ctx context.Context, alert Alert) error {
+	var auth smtp.Auth
+	if c.config.Username != "" {
+		auth = smtp.PlainAuth("", c.config.Username, c.password, c.config.Host)
+	}
+	addr := net.JoinHostPort(c.config.Host, strconv.Itoa(c.config.Port))
+	done := make(chan error, 1)
+	go func() { done <- c.sendMail(addr, auth, c.config.From, c.config.To, c.message(alert)) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(sendTimeout):
+		return fmt.Errorf("smtp server %s did not answer within %s", addr, sendTimeout)
+	}
+}
+
+func (c *EmailChannel) message(alert Alert) []byte {
+	var msg bytes.Buffer
+	subject := fmt.Sprintf("[KubeChat] [%s] %s", strings.ToUpper(string(alert.Severity)), alert.Title)
+	fmt.Fprintf(&msg, "From: %s\r\n", c.config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(c.config.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", alert.At.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s\r\n\r\n", strings.ReplaceAll(alert.Summary, "\n", "\r\n"))
+	for _, link := range alert.Links {
+		fmt.Fprintf(&msg, "%s: %s\r\n", link.Text, link.URL)
+	}
+	if len(alert.Links) > 0 {
+		fmt.Fprintf(&msg, "\r\n")
+	}
+	fmt.Fprintf(&msg, "Type: %s\r\nSeverity: %s\r\nAt: %s\r\nAlert: %s\r\n", alert.Type, alert.Severity, alert.At.Format(time.RFC3339), alert.ID)
+	if len(alert.Details) > 0 {
+		if details, err := json.MarshalIndent(alert.Details, "", "  "); err == nil {
+			fmt.Fprintf(&msg, "\r\n%s\r\n", strings.ReplaceAll(string(details), "\n", "\r\n"))
+		}
+	}
+	return msg.Bytes()
+}
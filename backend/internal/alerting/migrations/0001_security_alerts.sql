@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS security_alerts (
+    id TEXT PRIMARY KEY,
+    type TEXT NOT NULL,
+    severity TEXT NOT NULL,
+    severity_rank INTEGER NOT NULL,
+    body JSONB NOT NULL,
+    at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS security_alerts_at_idx ON security_alerts (at DESC);
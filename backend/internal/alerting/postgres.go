@@ -0,0 +1,80 @@
+package alerting
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pramodksahoo/kubechat/backend/internal/database"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+type PostgresRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresRepository applies the alerting schema migrations and returns a repository backed by db.
+func NewPostgresRepository(ctx context.Context, db *sqlx.DB) (*PostgresRepository, error) {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Migrate(ctx, db, "alerting", sub); err != nil {
+		return nil, err
+	}
+	return &PostgresRepository{db: db}, nil
+}
+
+func (r *PostgresRepository) Save(ctx context.Context, record Record) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `INSERT INTO security_alerts (id, type, severity, severity_rank, body, at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		record.Alert.ID, record.Alert.Type, string(record.Alert.Severity), severityRank[record.Alert.Severity], body, record.Alert.At)
+	return err
+}
+
+func (r *PostgresRepository) List(ctx context.Context, filter Filter) ([]Record, error) {
+	var (
+		conditions []string
+		args       []any
+	)
+	if filter.Type != "" {
+		args = append(args, filter.Type)
+		conditions = append(conditions, "type = $"+strconv.Itoa(len(args)))
+	}
+	if filter.MinSeverity != "" {
+		args = append(args, severityRank[filter.MinSeverity])
+		conditions = append(conditions, "severity_rank >= $"+strconv.Itoa(len(args)))
+	}
+	query := `SELECT body FROM security_alerts`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY at DESC`
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += ` LIMIT $` + strconv.Itoa(len(args))
+	}
+	var bodies [][]byte
+	if err := r.db.SelectContext(ctx, &bodies, query, args...); err != nil {
+		return nil, err
+	}
+	records := make([]Record, 0, len(bodies))
+	for _, body := range bodies {
+		var record Record
+		if err := json.Unmarshal(body, &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
@@ -0,0 +1,59 @@
+package alerting
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// Repository persists dispatched alerts and their deliveries.
+type Repository interface {
+	Save(ctx context.Context, record Record) error
+	// List returns the records matching filter, most recent first.
+	List(ctx context.Context, filter Filter) ([]Record, error)
+}
+
+// memoryCapacity bounds the records MemoryRepository keeps; the oldest are dropped first.
+const memoryCapacity = 10000
+
+// MemoryRepository keeps alerts in process memory; it is used when no database is configured.
+type MemoryRepository struct {
+	mu      sync.RWMutex
+	records []Record
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{}
+}
+
+func (r *MemoryRepository) Save(ctx context.Context, record Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record.Deliveries = slices.Clone(record.Deliveries)
+	r.records = append(r.records, record)
+	if len(r.records) > memoryCapacity {
+		r.records = r.records[len(r.records)-memoryCapacity:]
+	}
+	return nil
+}
+
+func (r *MemoryRepository) List(ctx context.Context, filter Filter) ([]Record, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var records []Record
+	for i := len(r.records) - 1; i >= 0; i-- {
+		record := r.records[i]
+		if !filter.matches(record.Alert) {
+			continue
+		}
+		records = append(records, record)
+		if filter.Limit > 0 && len(records) == filter.Limit {
+			break
+		}
+	}
+	return records, nil
+}
+
+func (f Filter) matches(alert Alert) bool {
+	return (f.Type == "" || alert.Type == f.Type) && (f.MinSeverity == "" || alert.Severity.AtLeast(f.MinSeverity))
+}
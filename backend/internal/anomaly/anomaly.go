@@ -0,0 +1,417 @@
+// Package anomaly detects suspicious activity. Each rule compares what a user does with what they
+// usually do, or with what nobody should do, and raises a scored security event. Usual behaviour
+// is learned from the activity this server observed since it started.
+package anomaly
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/pramodksahoo/kubechat/backend/internal/alerting"
+	"github.com/pramodksahoo/kubechat/backend/internal/audit"
+)
+
+// EventStreamID is the SSE stream security events are published on as security_event events.
+const EventStreamID = "security-events"
+
+// Source is where an activity was observed.
+type Source string
+
+const (
+	SourceRequest Source = "request"
+	SourceCommand Source = "command"
+)
+
+// Activity is one thing a user did: an API request or a command submitted for execution.
+type Activity struct {
+	User   string    `json:"user"`
+	At     time.Time `json:"at"`
+	Source Source    `json:"source"`
+	IP     string    `json:"ip,omitempty"`
+	// Method and Path are the request's method and route template.
+	Method string `json:"method,omitempty"`
+	Path   string `json:"path,omitempty"`
+	// The remaining fields describe a command.
+	Config      string `json:"config,omitempty"`
+	Cluster     string `json:"cluster,omitempty"`
+	Operation   string `json:"operation,omitempty"`
+	Kind        string `json:"kind,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name,omitempty"`
+	ExecutionID string `json:"executionId,omitempty"`
+	// Dangerous marks deletes and commands classified dangerous; Write marks anything but reads.
+	Dangerous bool `json:"dangerous,omitempty"`
+	Write     bool `json:"write,omitempty"`
+}
+
+type Severity string
+
+const (
+	SeverityLow      Severity = "low"
+	SeverityMedium   Severity = "medium"
+	SeverityHigh     Severity = "high"
+	SeverityCritical Severity = "critical"
+)
+
+func severity(score int) Severity {
+	switch {
+	case score >= 90:
+		return SeverityCritical
+	case score >= 70:
+		return SeverityHigh
+	case score >= 40:
+		return SeverityMedium
+	}
+	return SeverityLow
+}
+
+// Event is a security event raised by a rule.
+type Event struct {
+	ID       string         `json:"id"`
+	Rule     RuleName       `json:"rule"`
+	User     string         `json:"user"`
+	Score    int            `json:"score"`
+	Severity Severity       `json:"severity"`
+	Summary  string         `json:"summary"`
+	Details  map[string]any `json:"details,omitempty"`
+	Activity Activity       `json:"activity"`
+	At       time.Time      `json:"at"`
+}
+
+// Filter narrows the events listed; Limit defaults to DefaultLimit.
+type Filter struct {
+	User  string
+	Rule  RuleName
+	Limit int
+}
+
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// AuditLedger chains security events into the tamper-evident audit ledger.
+type AuditLedger interface {
+	Append(ctx context.Context, source audit.Source, entry any) error
+}
+
+// Engine evaluates the rules against every activity it observes.
+type Engine struct {
+	rules   Rules
+	repo    Repository
+	locator Locator
+	ledger  AuditLedger
+	logger  *log.Logger
+	events  chan Event
+
+	mu        sync.Mutex
+	profiles  map[string]*profile
+	cooldowns map[cooldownKey]time.Time
+}
+
+func NewEngine(rules Rules, repo Repository, logger *log.Logger) *Engine {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Engine{
+		rules:     rules,
+		repo:      repo,
+		logger:    logger,
+		events:    make(chan Event, 64),
+		profiles:  map[string]*profile{},
+		cooldowns: map[cooldownKey]time.Time{},
+	}
+}
+
+// SetLocator geolocates request IPs for the impossible travel rule, which is off without one.
+func (e *Engine) SetLocator(locator Locator) {
+	e.locator = locator
+}
+
+func (e *Engine) SetLedger(ledger AuditLedger) {
+	e.ledger = ledger
+}
+
+func (e *Engine) Rules() Rules {
+	return e.rules
+}
+
+// Events delivers every security event raised. Events are dropped, though still stored and
+// logged, while nobody receives them.
+func (e *Engine) Events() <-chan Event {
+	return e.events
+}
+
+func (e *Engine) List(ctx context.Context, filter Filter) ([]Event, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultLimit
+	}
+	filter.Limit = min(filter.Limit, MaxLimit)
+	return e.repo.List(ctx, filter)
+}
+
+// Observe evaluates the rules against activity, then learns from it. It returns the events
+// raised, which are already stored and delivered.
+func (e *Engine) Observe(ctx context.Context, activity Activity) []Event {
+	if activity.At.IsZero() {
+		activity.At = time.Now().UTC()
+	}
+	var location *Location
+	if e.locator != nil && e.rules.ImpossibleTravel.Enabled {
+		if addr, err := netip.ParseAddr(activity.IP); err == nil {
+			if found, ok := e.locator.Locate(addr); ok {
+				location = &found
+			}
+		}
+	}
+
+	e.mu.Lock()
+	p := e.profiles[activity.User]
+	if p == nil {
+		p = newProfile()
+		e.profiles[activity.User] = p
+	}
+	var events []Event
+	for _, f := range []*finding{
+		e.timeOfDay(p, activity),
+		e.dangerousBurst(p, activity),
+		e.newNamespace(p, activity),
+		e.impossibleTravel(p, activity, location),
+		e.kubeSystem(activity),
+	} {
+		if f == nil || e.coolingDown(f.rule, activity) {
+			continue
+		}
+		events = append(events, Event{
+			ID:       uuid.NewString(),
+			Rule:     f.rule,
+			User:     activity.User,
+			Score:    f.score,
+			Severity: severity(f.score),
+			Summary:  f.summary,
+			Details:  f.details,
+			Activity: activity,
+			At:       activity.At,
+		})
+	}
+	p.learn(activity, location, time.Duration(e.rules.DangerousBurst.WindowMinutes)*time.Minute)
+	e.mu.Unlock()
+
+	for _, event := range events {
+		e.emit(ctx, event)
+	}
+	return events
+}
+
+func (e *Engine) emit(ctx context.Context, event Event) {
+	e.logger.Warn("suspicious activity", "event", "security", "rule", event.Rule, "user", event.User, "score", event.Score, "summary", event.Summary)
+	if err := e.repo.Save(ctx, event); err != nil {
+		e.logger.Error("failed to store security event", "id", event.ID, "error", err)
+	}
+	if e.ledger != nil {
+		if err := e.ledger.Append(ctx, audit.SourceAnomaly, event); err != nil {
+			e.logger.Error("failed to chain security event into the audit ledger", "id", event.ID, "error", err)
+		}
+	}
+	select {
+	case e.events <- event:
+	default:
+		e.logger.Warn("security event dropped, no receiver is keeping up", "id", event.ID)
+	}
+}
+
+type cooldownKey struct {
+	rule RuleName
+	user string
+}
+
+// coolingDown reports whether rule raised an event for the user within the cooldown, starting
+// the cooldown otherwise. It is called with e.mu held.
+func (e *Engine) coolingDown(rule RuleName, activity Activity) bool {
+	key := cooldownKey{rule: rule, user: activity.User}
+	if until, ok := e.cooldowns[key]; ok && activity.At.Before(until) {
+		return true
+	}
+	e.cooldowns[key] = activity.At.Add(time.Duration(e.rules.CooldownMinutes) * time.Minute)
+	return false
+}
+
+// finding is a rule's verdict on an activity.
+type finding struct {
+	rule    RuleName
+	score   int
+	summary string
+	details map[string]any
+}
+
+// profile is what the engine learned about a user's usual activity.
+type profile struct {
+	hours      [24]int
+	total      int
+	commands   int
+	namespaces map[string]bool
+	// dangerous are the times of the dangerous commands within the burst window.
+	dangerous []time.Time
+	// sighting is the last request that could be located.
+	sighting *sighting
+}
+
+type sighting struct {
+	at       time.Time
+	ip       string
+	location Location
+}
+
+func newProfile() *profile {
+	return &profile{namespaces: map[string]bool{}}
+}
+
+func namespaceKey(activity Activity) string {
+	return activity.Config + "/" + activity.Cluster + "/" + activity.Namespace
+}
+
+func (p *profile) learn(activity Activity, location *Location, window time.Duration) {
+	p.hours[activity.At.UTC().Hour()]++
+	p.total++
+	if location != nil {
+		p.sighting = &sighting{at: activity.At, ip: activity.IP, location: *location}
+	}
+	if activity.Source != SourceCommand {
+		return
+	}
+	p.commands++
+	if activity.Namespace != "" {
+		p.namespaces[namespaceKey(activity)] = true
+	}
+	if activity.Dangerous {
+		p.dangerous = append(recent(p.dangerous, activity.At, window), activity.At)
+	}
+}
+
+// recent drops the times older than window before at.
+func recent(times []time.Time, at time.Time, window time.Duration) []time.Time {
+	cutoff := at.Add(-window)
+	return slices.DeleteFunc(times, func(t time.Time) bool { return !t.After(cutoff) })
+}
+
+// timeOfDay compares the activity's hour and its two neighbours with the user's history, so
+// working a little later than usual is not unusual.
+func (e *Engine) timeOfDay(p *profile, activity Activity) *finding {
+	rule := e.rules.TimeOfDay
+	if !rule.Enabled || p.total < rule.MinActivity {
+		return nil
+	}
+	hour := activity.At.UTC().Hour()
+	usual := p.hours[(hour+23)%24] + p.hours[hour] + p.hours[(hour+1)%24]
+	share := float64(usual) / float64(p.total)
+	if share >= rule.MinShare {
+		return nil
+	}
+	return &finding{
+		rule:    RuleTimeOfDay,
+		score:   rule.Score,
+		summary: fmt.Sprintf("%s is active at %02d:00 UTC, around when %.1f%% of their activity happens", activity.User, hour, share*100),
+		details: map[string]any{"hour": hour, "share": share, "observed": p.total},
+	}
+}
+
+func (e *Engine) dangerousBurst(p *profile, activity Activity) *finding {
+	rule := e.rules.DangerousBurst
+	if !rule.Enabled || activity.Source != SourceCommand || !activity.Dangerous {
+		return nil
+	}
+	window := time.Duration(rule.WindowMinutes) * time.Minute
+	count := len(recent(slices.Clone(p.dangerous), activity.At, window)) + 1
+	if count < rule.Count {
+		return nil
+	}
+	return &finding{
+		rule:    RuleDangerousBurst,
+		score:   min(100, rule.Score+5*(count-rule.Count)),
+		summary: fmt.Sprintf("%s submitted %d dangerous commands within %d minutes", activity.User, count, rule.WindowMinutes),
+		details: map[string]any{"count": count, "windowMinutes": rule.WindowMinutes},
+	}
+}
+
+func (e *Engine) newNamespace(p *profile, activity Activity) *finding {
+	rule := e.rules.NewNamespace
+	if !rule.Enabled || activity.Source != SourceCommand || activity.Namespace == "" ||
+		p.commands < rule.MinActivity || p.namespaces[namespaceKey(activity)] {
+		return nil
+	}
+	return &finding{
+		rule:    RuleNewNamespace,
+		score:   rule.Score,
+		summary: fmt.Sprintf("%s ran a command in namespace %s of %s for the first time", activity.User, activity.Namespace, activity.Cluster),
+		details: map[string]any{"namespaces": len(p.namespaces), "commands": p.commands},
+	}
+}
+
+func (e *Engine) impossibleTravel(p *profile, activity Activity, location *Location) *finding {
+	rule := e.rules.ImpossibleTravel
+	if !rule.Enabled || location == nil || p.sighting == nil || p.sighting.ip == activity.IP {
+		return nil
+	}
+	distance := distanceKm(p.sighting.location, *location)
+	if distance < rule.MinDistanceKm {
+		return nil
+	}
+	// Requests in the same minute are compared as a minute apart rather than dividing by zero.
+	elapsed := max(activity.At.Sub(p.sighting.at), time.Minute)
+	speed := distance / elapsed.Hours()
+	if speed <= rule.MaxSpeedKmh {
+		return nil
+	}
+	return &finding{
+		rule:  RuleImpossibleTravel,
+		score: rule.Score,
+		summary: fmt.Sprintf("%s made requests from %s and %s, %.0f km apart, %s apart",
+			activity.User, p.sighting.location, *location, distance, elapsed.Round(time.Minute)),
+		details: map[string]any{
+			"previousIp": p.sighting.ip, "previousLocation": p.sighting.location, "location": *location,
+			"distanceKm": distance, "speedKmh": speed,
+		},
+	}
+}
+
+func (e *Engine) kubeSystem(activity Activity) *finding {
+	rule := e.rules.KubeSystem
+	if !rule.Enabled || activity.Source != SourceCommand || !activity.Write {
+		return nil
+	}
+	var target string
+	switch {
+	case activity.Namespace != "" && slices.Contains(rule.Namespaces, activity.Namespace):
+		target = "namespace " + activity.Namespace
+	case slices.ContainsFunc(rule.Kinds, func(kind string) bool { return strings.EqualFold(kind, activity.Kind) }):
+		target = activity.Kind + " " + activity.Name
+	default:
+		return nil
+	}
+	return &finding{
+		rule:    RuleKubeSystem,
+		score:   rule.Score,
+		summary: fmt.Sprintf("%s submitted %s on %s in %s", activity.User, activity.Operation, strings.TrimSpace(target), activity.Cluster),
+	}
+}
+
+// Alert describes the event for alerting. Repeats of a rule for the same user share a dedup key.
+func (e Event) Alert() alerting.Alert {
+	return alerting.Alert{
+		ID:       e.ID,
+		Type:     "anomaly." + string(e.Rule),
+		Severity: alerting.Severity(e.Severity),
+		Title:    "Suspicious activity by " + e.User,
+		Summary:  e.Summary,
+		DedupKey: "anomaly." + string(e.Rule) + "/" + e.User,
+		Details:  map[string]any{"rule": e.Rule, "score": e.Score, "user": e.User, "activity": e.Activity, "details": e.Details},
+		At:       e.At,
+	}
+}
@@ -0,0 +1,128 @@
+package anomaly
+
+import (
+	"context"
+	"io"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pramodksahoo/kubechat/backend/internal/commands"
+)
+
+func newTestEngine(rules Rules) *Engine {
+	return NewEngine(rules, NewMemoryRepository(), log.NewWithOptions(io.Discard, log.Options{}))
+}
+
+func rulesRaised(events []Event) []RuleName {
+	var rules []RuleName
+	for _, event := range events {
+		rules = append(rules, event.Rule)
+	}
+	return rules
+}
+
+func TestEngineFlagsCommandsThatBreakTheUsersHabits(t *testing.T) {
+	rules := DefaultRules()
+	rules.CooldownMinutes = 0
+	engine := newTestEngine(rules)
+	ctx := context.Background()
+	morning := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	// Twenty reads in the usual namespace during office hours teach the engine the user's habits.
+	for i := range 20 {
+		execution := commands.Execution{
+			ID: "read", UserID: "alice", Config: "prod", Cluster: "main", CreatedAt: morning.Add(time.Duration(i) * time.Minute),
+			Operation: commands.OperationList, Resource: commands.Resource{Kind: "Pod", Namespace: "shop"}, SafetyLevel: commands.SafetySafe,
+		}
+		for _, activity := range CommandActivities(execution) {
+			if events := engine.Observe(ctx, activity); len(events) > 0 {
+				t.Fatalf("expected usual activity to raise nothing, got %v", rulesRaised(events))
+			}
+		}
+	}
+
+	plan := commands.Execution{
+		ID: "plan", UserID: "alice", Config: "prod", Cluster: "main", CreatedAt: morning.Add(time.Hour),
+		Operation: commands.OperationPlan, SafetyLevel: commands.SafetyDangerous,
+	}
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		plan.Steps = append(plan.Steps, commands.PlanStep{Operation: commands.OperationDelete, Resource: commands.Resource{Kind: "Pod", Namespace: "shop", Name: name}})
+	}
+	plan.Steps = append(plan.Steps, commands.PlanStep{Operation: commands.OperationPatch, Resource: commands.Resource{Kind: "ConfigMap", Namespace: "kube-system", Name: "coredns"}})
+	var raised []Event
+	for _, activity := range CommandActivities(plan) {
+		raised = append(raised, engine.Observe(ctx, activity)...)
+	}
+	got := rulesRaised(raised)
+	want := []RuleName{RuleDangerousBurst, RuleNewNamespace, RuleKubeSystem}
+	if strings.Join(toStrings(got), ",") != strings.Join(toStrings(want), ",") {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if raised[0].Score != rules.DangerousBurst.Score || raised[0].Severity != SeverityHigh {
+		t.Fatalf("expected the fifth delete to score %d, got %+v", rules.DangerousBurst.Score, raised[0])
+	}
+
+	stored, err := engine.List(ctx, Filter{User: "alice", Rule: RuleKubeSystem})
+	if err != nil || len(stored) != 1 || stored[0].Activity.Name != "coredns" {
+		t.Fatalf("expected the tampering event to be stored, got %+v (%v)", stored, err)
+	}
+	if len(engine.Events()) != len(raised) {
+		t.Fatalf("expected %d events delivered, got %d", len(raised), len(engine.Events()))
+	}
+}
+
+func toStrings(rules []RuleName) []string {
+	var names []string
+	for _, rule := range rules {
+		names = append(names, string(rule))
+	}
+	return names
+}
+
+func TestEngineFlagsRequestsAtUnusualHoursAndImpossibleTravel(t *testing.T) {
+	locator, err := ReadGeoTable(strings.NewReader(`network,latitude,longitude,country,city
+203.0.113.0/24,52.52,13.40,DE,Berlin
+198.51.100.0/24,40.71,-74.00,US,New York
+198.51.100.128/25,,,US,
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location, ok := locator.Locate(netip.MustParseAddr("198.51.100.200")); !ok || location.City != "New York" {
+		t.Fatalf("expected networks without coordinates to be skipped, got %+v", location)
+	}
+
+	rules := DefaultRules()
+	rules.TimeOfDay.MinActivity = 10
+	engine := newTestEngine(rules)
+	engine.SetLocator(locator)
+	ctx := context.Background()
+	day := time.Date(2026, 3, 2, 14, 0, 0, 0, time.UTC)
+	for i := range 10 {
+		engine.Observe(ctx, Activity{User: "bob", At: day.Add(time.Duration(i) * time.Minute), Source: SourceRequest, IP: "203.0.113.7"})
+	}
+
+	events := engine.Observe(ctx, Activity{User: "bob", At: day.Add(20 * time.Minute), Source: SourceRequest, IP: "198.51.100.9"})
+	if len(events) != 1 || events[0].Rule != RuleImpossibleTravel || !strings.Contains(events[0].Summary, "Berlin, DE and New York, US") {
+		t.Fatalf("expected Berlin to New York in minutes to be impossible, got %+v", events)
+	}
+	events = engine.Observe(ctx, Activity{User: "bob", At: day.Add(12 * time.Hour), Source: SourceRequest, IP: "198.51.100.9"})
+	if len(events) != 1 || events[0].Rule != RuleTimeOfDay {
+		t.Fatalf("expected a request at 02:00 to be unusual, got %+v", events)
+	}
+	// The cooldown keeps a second request at the same hour from raising another event.
+	if events := engine.Observe(ctx, Activity{User: "bob", At: day.Add(12*time.Hour + time.Minute), Source: SourceRequest}); len(events) != 0 {
+		t.Fatalf("expected the cooldown to suppress the event, got %+v", events)
+	}
+}
+
+func TestLoadRulesRejectsInvalidSettings(t *testing.T) {
+	rules := DefaultRules()
+	rules.DangerousBurst.Score = 0
+	if err := rules.Validate(); err == nil || !strings.Contains(err.Error(), string(RuleDangerousBurst)) {
+		t.Fatalf("expected a zero score to be refused, got %v", err)
+	}
+}
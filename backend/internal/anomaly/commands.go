@@ -0,0 +1,60 @@
+package anomaly
+
+import (
+	"context"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/commands"
+)
+
+// CommandObserver feeds the commands admitted for execution to engine.
+func CommandObserver(engine *Engine) commands.Observer {
+	return commandObserver{engine: engine}
+}
+
+type commandObserver struct {
+	engine *Engine
+}
+
+func (o commandObserver) ObserveSubmission(ctx context.Context, execution commands.Execution) {
+	for _, activity := range CommandActivities(execution) {
+		o.engine.Observe(ctx, activity)
+	}
+}
+
+// CommandActivities describes an execution as activities, one per step for a plan.
+func CommandActivities(execution commands.Execution) []Activity {
+	activity := func(operation commands.Operation, resource commands.Resource, safety commands.SafetyLevel) Activity {
+		return Activity{
+			User:        execution.UserID,
+			At:          execution.CreatedAt,
+			Source:      SourceCommand,
+			Config:      execution.Config,
+			Cluster:     execution.Cluster,
+			Operation:   string(operation),
+			Kind:        resource.Kind,
+			Namespace:   resource.Namespace,
+			Name:        resource.Name,
+			ExecutionID: execution.ID,
+			Dangerous:   safety == commands.SafetyDangerous || operation == commands.OperationDelete,
+			Write:       !readOnly(operation),
+		}
+	}
+	if execution.Operation != commands.OperationPlan {
+		return []Activity{activity(execution.Operation, execution.Resource, execution.SafetyLevel)}
+	}
+	activities := make([]Activity, 0, len(execution.Steps))
+	for _, step := range execution.Steps {
+		activities = append(activities, activity(step.Operation, step.Resource, step.SafetyLevel))
+	}
+	return activities
+}
+
+// readOnly reports whether an operation changes nothing in the cluster; generate records a
+// refused command that never runs.
+func readOnly(operation commands.Operation) bool {
+	switch operation {
+	case commands.OperationGet, commands.OperationList, commands.OperationDescribe, commands.OperationGenerate:
+		return true
+	}
+	return false
+}
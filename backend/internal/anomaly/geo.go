@@ -0,0 +1,140 @@
+package anomaly
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Location is where an IP address is located.
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Country   string  `json:"country,omitempty"`
+	City      string  `json:"city,omitempty"`
+}
+
+func (l Location) String() string {
+	if place := strings.Trim(l.City+", "+l.Country, ", "); place != "" {
+		return place
+	}
+	return fmt.Sprintf("%.2f,%.2f", l.Latitude, l.Longitude)
+}
+
+// Locator geolocates IP addresses.
+type Locator interface {
+	Locate(addr netip.Addr) (Location, bool)
+}
+
+// GeoTable locates addresses by the longest matching network in a table.
+type GeoTable struct {
+	// networks maps each prefix length to the networks of that length.
+	networks map[int]map[netip.Prefix]Location
+	lengths  []int
+}
+
+// LoadGeoTable reads a CSV file whose header names the network, latitude and longitude columns
+// and optionally country and city, such as the GeoLite2 City blocks files.
+func LoadGeoTable(path string) (*GeoTable, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	table, err := ReadGeoTable(file)
+	if err != nil {
+		return nil, fmt.Errorf("read geodata %s: %w", path, err)
+	}
+	return table, nil
+}
+
+func ReadGeoTable(r io.Reader) (*GeoTable, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"network", "latitude", "longitude"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("the header has no %s column", required)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	table := &GeoTable{networks: map[int]map[netip.Prefix]Location{}}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		prefix, err := netip.ParsePrefix(field(record, "network"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		latitude, latErr := strconv.ParseFloat(field(record, "latitude"), 64)
+		longitude, lonErr := strconv.ParseFloat(field(record, "longitude"), 64)
+		if latErr != nil || lonErr != nil {
+			// Networks known only by country carry no coordinates and cannot be compared.
+			continue
+		}
+		prefix = prefix.Masked()
+		if table.networks[prefix.Bits()] == nil {
+			table.networks[prefix.Bits()] = map[netip.Prefix]Location{}
+			table.lengths = append(table.lengths, prefix.Bits())
+		}
+		table.networks[prefix.Bits()][prefix] = Location{
+			Latitude:  latitude,
+			Longitude: longitude,
+			Country:   field(record, "country"),
+			City:      field(record, "city"),
+		}
+	}
+	slices.Sort(table.lengths)
+	slices.Reverse(table.lengths)
+	return table, nil
+}
+
+func (t *GeoTable) Locate(addr netip.Addr) (Location, bool) {
+	addr = addr.Unmap()
+	for _, bits := range t.lengths {
+		prefix, err := addr.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if location, ok := t.networks[bits][prefix]; ok {
+			return location, true
+		}
+	}
+	return Location{}, false
+}
+
+// earthRadiusKm is the mean radius used for great-circle distances.
+const earthRadiusKm = 6371
+
+// distanceKm returns the great-circle distance between two locations.
+func distanceKm(a, b Location) float64 {
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat, dLon := lat2-lat1, (b.Longitude-a.Longitude)*math.Pi/180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS security_events (
+    id TEXT PRIMARY KEY,
+    rule TEXT NOT NULL,
+    "user" TEXT NOT NULL,
+    score INTEGER NOT NULL,
+    severity TEXT NOT NULL,
+    summary TEXT NOT NULL,
+    details JSONB,
+    activity JSONB NOT NULL,
+    at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS security_events_at_idx ON security_events (at DESC);
+CREATE INDEX IF NOT EXISTS security_events_user_idx ON security_events ("user", at DESC);
@@ -0,0 +1,122 @@
+package anomaly
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pramodksahoo/kubechat/backend/internal/database"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+type PostgresRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresRepository applies the security event schema migrations and returns a repository backed by db.
+func NewPostgresRepository(ctx context.Context, db *sqlx.DB) (*PostgresRepository, error) {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Migrate(ctx, db, "anomaly", sub); err != nil {
+		return nil, err
+	}
+	return &PostgresRepository{db: db}, nil
+}
+
+type eventRow struct {
+	ID       string    `db:"id"`
+	Rule     string    `db:"rule"`
+	User     string    `db:"user"`
+	Score    int       `db:"score"`
+	Severity string    `db:"severity"`
+	Summary  string    `db:"summary"`
+	Details  []byte    `db:"details"`
+	Activity []byte    `db:"activity"`
+	At       time.Time `db:"at"`
+}
+
+func (r *PostgresRepository) Save(ctx context.Context, event Event) error {
+	var details []byte
+	if len(event.Details) > 0 {
+		var err error
+		if details, err = json.Marshal(event.Details); err != nil {
+			return err
+		}
+	}
+	activity, err := json.Marshal(event.Activity)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.NamedExecContext(ctx, `INSERT INTO security_events (id, rule, "user", score, severity, summary, details, activity, at)
+		VALUES (:id, :rule, :user, :score, :severity, :summary, :details, :activity, :at)`, eventRow{
+		ID:       event.ID,
+		Rule:     string(event.Rule),
+		User:     event.User,
+		Score:    event.Score,
+		Severity: string(event.Severity),
+		Summary:  event.Summary,
+		Details:  details,
+		Activity: activity,
+		At:       event.At,
+	})
+	return err
+}
+
+func (r *PostgresRepository) List(ctx context.Context, filter Filter) ([]Event, error) {
+	var (
+		conditions []string
+		args       []any
+	)
+	if filter.User != "" {
+		args = append(args, filter.User)
+		conditions = append(conditions, `"user" = $`+strconv.Itoa(len(args)))
+	}
+	if filter.Rule != "" {
+		args = append(args, string(filter.Rule))
+		conditions = append(conditions, "rule = $"+strconv.Itoa(len(args)))
+	}
+	query := `SELECT id, rule, "user", score, severity, summary, details, activity, at FROM security_events`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY at DESC`
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += ` LIMIT $` + strconv.Itoa(len(args))
+	}
+	var rows []eventRow
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, err
+	}
+	events := make([]Event, 0, len(rows))
+	for _, row := range rows {
+		event := Event{
+			ID:       row.ID,
+			Rule:     RuleName(row.Rule),
+			User:     row.User,
+			Score:    row.Score,
+			Severity: Severity(row.Severity),
+			Summary:  row.Summary,
+			At:       row.At.UTC(),
+		}
+		if len(row.Details) > 0 {
+			if err := json.Unmarshal(row.Details, &event.Details); err != nil {
+				return nil, err
+			}
+		}
+		if err := json.Unmarshal(row.Activity, &event.Activity); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
@@ -0,0 +1,53 @@
+package anomaly
+
+import (
+	"context"
+	"sync"
+)
+
+// Repository persists security events.
+type Repository interface {
+	Save(ctx context.Context, event Event) error
+	// List returns the events matching filter, most recent first.
+	List(ctx context.Context, filter Filter) ([]Event, error)
+}
+
+// memoryCapacity bounds the events MemoryRepository keeps; the oldest are dropped first.
+const memoryCapacity = 10000
+
+// MemoryRepository keeps security events in process memory; it is used when no database is configured.
+type MemoryRepository struct {
+	mu     sync.RWMutex
+	events []Event
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{}
+}
+
+func (r *MemoryRepository) Save(ctx context.Context, event Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	if len(r.events) > memoryCapacity {
+		r.events = r.events[len(r.events)-memoryCapacity:]
+	}
+	return nil
+}
+
+func (r *MemoryRepository) List(ctx context.Context, filter Filter) ([]Event, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var events []Event
+	for i := len(r.events) - 1; i >= 0; i-- {
+		event := r.events[i]
+		if (filter.User != "" && event.User != filter.User) || (filter.Rule != "" && event.Rule != filter.Rule) {
+			continue
+		}
+		events = append(events, event)
+		if filter.Limit > 0 && len(events) == filter.Limit {
+			break
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,146 @@
+package anomaly
+
+import (
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+// RuleName identifies an anomaly rule on the events it raises.
+type RuleName string
+
+const (
+	RuleTimeOfDay        RuleName = "time_of_day"
+	RuleDangerousBurst   RuleName = "dangerous_burst"
+	RuleNewNamespace     RuleName = "new_namespace"
+	RuleImpossibleTravel RuleName = "impossible_travel"
+	RuleKubeSystem       RuleName = "kube_system_tampering"
+)
+
+// RuleSettings are what every rule has: whether it runs and the score of the events it raises,
+// from 1 to 100.
+type RuleSettings struct {
+	Enabled bool `json:"enabled"`
+	Score   int  `json:"score"`
+}
+
+// TimeOfDayRule flags activity at an hour in which the user is rarely active. Hours are compared
+// in UTC, together with their neighbours, against the user's own history.
+type TimeOfDayRule struct {
+	RuleSettings
+	// MinActivity is how much activity a user needs before their hours are judged.
+	MinActivity int `json:"minActivity"`
+	// MinShare is the share of a user's activity below which an hour is unusual for them.
+	MinShare float64 `json:"minShare"`
+}
+
+// DangerousBurstRule flags a user submitting Count dangerous commands or deletes within
+// WindowMinutes. Each command beyond Count adds 5 to the score.
+type DangerousBurstRule struct {
+	RuleSettings
+	Count         int `json:"count"`
+	WindowMinutes int `json:"windowMinutes"`
+}
+
+// NewNamespaceRule flags commands in a namespace the user never ran commands in before, once the
+// user ran MinActivity commands.
+type NewNamespaceRule struct {
+	RuleSettings
+	MinActivity int `json:"minActivity"`
+}
+
+// ImpossibleTravelRule flags requests from places further apart than the user could have
+// travelled in between. It needs IP geodata.
+type ImpossibleTravelRule struct {
+	RuleSettings
+	MaxSpeedKmh float64 `json:"maxSpeedKmh"`
+	// MinDistanceKm ignores jumps smaller than geolocation is accurate.
+	MinDistanceKm float64 `json:"minDistanceKm"`
+}
+
+// KubeSystemRule flags commands that change system namespaces or cluster-wide security
+// resources.
+type KubeSystemRule struct {
+	RuleSettings
+	Namespaces []string `json:"namespaces"`
+	Kinds      []string `json:"kinds"`
+}
+
+// Rules configure the anomaly engine.
+type Rules struct {
+	TimeOfDay        TimeOfDayRule        `json:"timeOfDay"`
+	DangerousBurst   DangerousBurstRule   `json:"dangerousBurst"`
+	NewNamespace     NewNamespaceRule     `json:"newNamespace"`
+	ImpossibleTravel ImpossibleTravelRule `json:"impossibleTravel"`
+	KubeSystem       KubeSystemRule       `json:"kubeSystem"`
+	// AlertScore is the lowest score of the events alerted on.
+	AlertScore int `json:"alertScore"`
+	// CooldownMinutes suppresses further events of a rule for a user after it raised one.
+	CooldownMinutes int `json:"cooldownMinutes"`
+}
+
+// DefaultRules apply when no rule file is configured, and to the settings a file leaves out.
+func DefaultRules() Rules {
+	return Rules{
+		TimeOfDay:        TimeOfDayRule{RuleSettings: RuleSettings{Enabled: true, Score: 40}, MinActivity: 50, MinShare: 0.02},
+		DangerousBurst:   DangerousBurstRule{RuleSettings: RuleSettings{Enabled: true, Score: 70}, Count: 5, WindowMinutes: 10},
+		NewNamespace:     NewNamespaceRule{RuleSettings: RuleSettings{Enabled: true, Score: 30}, MinActivity: 20},
+		ImpossibleTravel: ImpossibleTravelRule{RuleSettings: RuleSettings{Enabled: true, Score: 80}, MaxSpeedKmh: 900, MinDistanceKm: 500},
+		KubeSystem: KubeSystemRule{
+			RuleSettings: RuleSettings{Enabled: true, Score: 90},
+			Namespaces:   []string{"kube-system", "kube-public", "kube-node-lease"},
+			Kinds: []string{
+				"ClusterRole", "ClusterRoleBinding", "MutatingWebhookConfiguration",
+				"ValidatingWebhookConfiguration", "CustomResourceDefinition", "Node",
+			},
+		},
+		AlertScore:      70,
+		CooldownMinutes: 10,
+	}
+}
+
+// LoadRules reads rules from a YAML file over DefaultRules.
+func LoadRules(path string) (Rules, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Rules{}, err
+	}
+	rules := DefaultRules()
+	if err := yaml.UnmarshalStrict(raw, &rules); err != nil {
+		return Rules{}, fmt.Errorf("parse anomaly rules %s: %w", path, err)
+	}
+	if err := rules.Validate(); err != nil {
+		return Rules{}, err
+	}
+	return rules, nil
+}
+
+func (r Rules) Validate() error {
+	for name, settings := range map[RuleName]RuleSettings{
+		RuleTimeOfDay:        r.TimeOfDay.RuleSettings,
+		RuleDangerousBurst:   r.DangerousBurst.RuleSettings,
+		RuleNewNamespace:     r.NewNamespace.RuleSettings,
+		RuleImpossibleTravel: r.ImpossibleTravel.RuleSettings,
+		RuleKubeSystem:       r.KubeSystem.RuleSettings,
+	} {
+		if settings.Score < 1 || settings.Score > 100 {
+			return fmt.Errorf("anomaly rule %s: score must be between 1 and 100", name)
+		}
+	}
+	switch {
+	case r.TimeOfDay.MinActivity < 1 || r.TimeOfDay.MinShare <= 0 || r.TimeOfDay.MinShare >= 1:
+		return fmt.Errorf("anomaly rule %s: minActivity must be positive and minShare between 0 and 1", RuleTimeOfDay)
+	case r.DangerousBurst.Count < 2 || r.DangerousBurst.WindowMinutes < 1:
+		return fmt.Errorf("anomaly rule %s: count must be at least 2 and windowMinutes positive", RuleDangerousBurst)
+	case r.NewNamespace.MinActivity < 1:
+		return fmt.Errorf("anomaly rule %s: minActivity must be positive", RuleNewNamespace)
+	case r.ImpossibleTravel.MaxSpeedKmh <= 0 || r.ImpossibleTravel.MinDistanceKm < 0:
+		return fmt.Errorf("anomaly rule %s: maxSpeedKmh must be positive and minDistanceKm not negative", RuleImpossibleTravel)
+	case r.AlertScore < 0 || r.AlertScore > 100:
+		return fmt.Errorf("anomaly rules: alertScore must be between 0 and 100")
+	case r.CooldownMinutes < 0:
+		return fmt.Errorf("anomaly rules: cooldownMinutes must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package alerting
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/alerting"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+)
+
+type Dispatcher interface {
+	List(ctx context.Context, filter alerting.Filter) ([]alerting.Record, error)
+	Channels() []alerting.ChannelInfo
+	Routes() []alerting.Route
+	Test(ctx context.Context, channel, actor string) (alerting.Delivery, error)
+}
+
+// Channels lists the configured channels and the routes alerts take to them.
+type Channels struct {
+	Channels []alerting.ChannelInfo `json:"channels"`
+	Routes   []alerting.Route       `json:"routes"`
+}
+
+type TestRequest struct {
+	Channel string `json:"channel"`
+}
+
+type AlertsController struct {
+	dispatcher Dispatcher
+	logger     *log.Logger
+}
+
+func NewAlertsController(dispatcher Dispatcher, logger *log.Logger) *AlertsController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &AlertsController{dispatcher: dispatcher, logger: logger}
+}
+
+// Alerts lists dispatched alerts with the status of their deliveries, most recent first,
+// narrowed by the type and severity parameters; severity is the lowest severity listed.
+func (c *AlertsController) Alerts(ctx echo.Context) error {
+	filter := alerting.Filter{Type: ctx.QueryParam("type"), MinSeverity: alerting.Severity(ctx.QueryParam("severity"))}
+	if raw := ctx.QueryParam("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > alerting.MaxLimit {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "limit must be between 1 and " + strconv.Itoa(alerting.MaxLimit)})
+		}
+		filter.Limit = limit
+	}
+	records, err := c.dispatcher.List(ctx.Request().Context(), filter)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list alerts")
+	}
+	return ctx.JSON(http.StatusOK, map[string][]alerting.Record{"alerts": records})
+}
+
+func (c *AlertsController) Channels(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, Channels{Channels: c.dispatcher.Channels(), Routes: c.dispatcher.Routes()})
+}
+
+// Test sends a test alert to a channel and reports the delivery.
+func (c *AlertsController) Test(ctx echo.Context) error {
+	var req TestRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	delivery, err := c.dispatcher.Test(ctx.Request().Context(), req.Channel, identity.User(ctx))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to send test alert")
+	}
+	return ctx.JSON(http.StatusOK, delivery)
+}
+
+func (c *AlertsController) respondError(ctx echo.Context, err error, message string) error {
+	if errors.As(err, &alerting.ErrInvalidRequest{}) {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	c.logger.Error(message, "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
@@ -0,0 +1,51 @@
+package anomaly
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/anomaly"
+)
+
+type EventLister interface {
+	List(ctx context.Context, filter anomaly.Filter) ([]anomaly.Event, error)
+	Rules() anomaly.Rules
+}
+
+type EventsController struct {
+	engine EventLister
+	logger *log.Logger
+}
+
+func NewEventsController(engine EventLister, logger *log.Logger) *EventsController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &EventsController{engine: engine, logger: logger}
+}
+
+// Events lists security events, most recent first, narrowed by the user and rule parameters.
+func (c *EventsController) Events(ctx echo.Context) error {
+	filter := anomaly.Filter{User: ctx.QueryParam("user"), Rule: anomaly.RuleName(ctx.QueryParam("rule"))}
+	if raw := ctx.QueryParam("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > anomaly.MaxLimit {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "limit must be between 1 and " + strconv.Itoa(anomaly.MaxLimit)})
+		}
+		filter.Limit = limit
+	}
+	events, err := c.engine.List(ctx.Request().Context(), filter)
+	if err != nil {
+		c.logger.Error("failed to list security events", "error", err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to list security events"})
+	}
+	return ctx.JSON(http.StatusOK, map[string][]anomaly.Event{"events": events})
+}
+
+// Rules returns the anomaly rules in effect.
+func (c *EventsController) Rules(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, c.engine.Rules())
+}
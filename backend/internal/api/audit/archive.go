@@ -0,0 +1,100 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/audit"
+)
+
+type Archiver interface {
+	Archives(ctx context.Context) ([]audit.Archive, error)
+	Restore(ctx context.Context, id string) (audit.Archive, error)
+	Evict(ctx context.Context, id string) error
+	Restored(ctx context.Context, filter audit.RestoredFilter) ([]audit.RestoredRecord, error)
+}
+
+// ArchiveController lists the audit archives moved to object storage by retention and rehydrates
+// them for investigations.
+type ArchiveController struct {
+	archiver Archiver
+	logger   *log.Logger
+}
+
+func NewArchiveController(archiver Archiver, logger *log.Logger) *ArchiveController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &ArchiveController{archiver: archiver, logger: logger}
+}
+
+func (c *ArchiveController) List(ctx echo.Context) error {
+	archives, err := c.archiver.Archives(ctx.Request().Context())
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list audit archives")
+	}
+	return ctx.JSON(http.StatusOK, map[string][]audit.Archive{"archives": archives})
+}
+
+// Restore downloads and verifies an archive and makes its records queryable until evicted.
+func (c *ArchiveController) Restore(ctx echo.Context) error {
+	archive, err := c.archiver.Restore(ctx.Request().Context(), ctx.Param("id"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to restore audit archive")
+	}
+	return ctx.JSON(http.StatusOK, archive)
+}
+
+func (c *ArchiveController) Evict(ctx echo.Context) error {
+	if err := c.archiver.Evict(ctx.Request().Context(), ctx.Param("id")); err != nil {
+		return c.respondError(ctx, err, "failed to evict audit archive")
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// Records pages through restored records, optionally of one archive or one source.
+func (c *ArchiveController) Records(ctx echo.Context) error {
+	filter := audit.RestoredFilter{ArchiveID: ctx.QueryParam("archive"), Source: audit.Source(ctx.QueryParam("source")), Limit: 100}
+	if raw := ctx.QueryParam("after"); raw != "" {
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || value < 0 {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "after must be a record sequence number"})
+		}
+		filter.After = value
+	}
+	if raw := ctx.QueryParam("limit"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value <= 0 {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "limit must be a positive number"})
+		}
+		filter.Limit = min(value, 1000)
+	}
+	records, err := c.archiver.Restored(ctx.Request().Context(), filter)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to load restored audit records")
+	}
+	return ctx.JSON(http.StatusOK, map[string][]audit.RestoredRecord{"records": records})
+}
+
+func (c *ArchiveController) respondError(ctx echo.Context, err error, message string) error {
+	var (
+		notFound audit.ErrArchiveNotFound
+		corrupt  audit.ErrArchiveCorrupt
+		invalid  audit.ErrInvalidRequest
+	)
+	switch {
+	case errors.As(err, &notFound):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.As(err, &corrupt):
+		c.logger.Error("audit archive failed verification", "event", "security", "error", err)
+		return ctx.JSON(http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+	case errors.As(err, &invalid):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	c.logger.Error(message, "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
@@ -0,0 +1,100 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/audit"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+)
+
+type Ledger interface {
+	Status() (audit.Verification, bool)
+	Verify(ctx context.Context) (audit.Verification, error)
+	Records(ctx context.Context, after int64, limit int) ([]audit.Record, error)
+	Reanchor(ctx context.Context, actor, reason string) (audit.Anchor, error)
+}
+
+type AnchorRequest struct {
+	Reason string `json:"reason"`
+}
+
+// LedgerController lets admins check the integrity of the audit ledger and re-anchor it once a
+// tamper alert has been investigated.
+type LedgerController struct {
+	ledger Ledger
+	logger *log.Logger
+}
+
+func NewLedgerController(ledger Ledger, logger *log.Logger) *LedgerController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &LedgerController{ledger: ledger, logger: logger}
+}
+
+// Integrity returns the outcome of the last background verification, verifying now when none ran yet.
+func (c *LedgerController) Integrity(ctx echo.Context) error {
+	if status, ok := c.ledger.Status(); ok {
+		return ctx.JSON(http.StatusOK, status)
+	}
+	return c.Verify(ctx)
+}
+
+func (c *LedgerController) Verify(ctx echo.Context) error {
+	status, err := c.ledger.Verify(ctx.Request().Context())
+	if err != nil {
+		return c.respondError(ctx, err, "failed to verify audit ledger")
+	}
+	return ctx.JSON(http.StatusOK, status)
+}
+
+// Records pages through the chain so it can be verified independently.
+func (c *LedgerController) Records(ctx echo.Context) error {
+	var after int64
+	limit := 100
+	if raw := ctx.QueryParam("after"); raw != "" {
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || value < 0 {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "after must be a record sequence number"})
+		}
+		after = value
+	}
+	if raw := ctx.QueryParam("limit"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value <= 0 {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "limit must be a positive number"})
+		}
+		limit = min(value, 1000)
+	}
+	records, err := c.ledger.Records(ctx.Request().Context(), after, limit)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to load audit ledger")
+	}
+	return ctx.JSON(http.StatusOK, map[string][]audit.Record{"records": records})
+}
+
+func (c *LedgerController) Reanchor(ctx echo.Context) error {
+	var req AnchorRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	anchor, err := c.ledger.Reanchor(ctx.Request().Context(), identity.User(ctx), req.Reason)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to re-anchor audit ledger")
+	}
+	return ctx.JSON(http.StatusCreated, anchor)
+}
+
+func (c *LedgerController) respondError(ctx echo.Context, err error, message string) error {
+	var invalid audit.ErrInvalidRequest
+	if errors.As(err, &invalid) {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	c.logger.Error(message, "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
@@ -0,0 +1,80 @@
+package breakers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/breaker"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+)
+
+type BreakerService interface {
+	List(ctx context.Context) []breaker.Status
+	Get(ctx context.Context, upstream string) (breaker.Status, error)
+	ForceOpen(ctx context.Context, actor, upstream, reason string) (breaker.Status, error)
+	Reset(ctx context.Context, actor, upstream, reason string) (breaker.Status, error)
+}
+
+type BreakerController struct {
+	service BreakerService
+	logger  *log.Logger
+}
+
+func NewBreakerController(service BreakerService, logger *log.Logger) *BreakerController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &BreakerController{service: service, logger: logger}
+}
+
+// List returns the circuit breaker of every upstream.
+func (c *BreakerController) List(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, map[string][]breaker.Status{"breakers": c.service.List(ctx.Request().Context())})
+}
+
+// Get returns the circuit breaker of one upstream.
+func (c *BreakerController) Get(ctx echo.Context) error {
+	status, err := c.service.Get(ctx.Request().Context(), ctx.Param("upstream"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to read circuit breaker")
+	}
+	return ctx.JSON(http.StatusOK, status)
+}
+
+// Open holds an upstream's breaker open until it is reset.
+func (c *BreakerController) Open(ctx echo.Context) error {
+	return c.override(ctx, c.service.ForceOpen, "failed to open circuit breaker")
+}
+
+// Reset closes an upstream's breaker.
+func (c *BreakerController) Reset(ctx echo.Context) error {
+	return c.override(ctx, c.service.Reset, "failed to reset circuit breaker")
+}
+
+func (c *BreakerController) override(ctx echo.Context, apply func(ctx context.Context, actor, upstream, reason string) (breaker.Status, error), message string) error {
+	var req breaker.OverrideRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	status, err := apply(ctx.Request().Context(), identity.User(ctx), ctx.Param("upstream"), req.Reason)
+	if err != nil {
+		return c.respondError(ctx, err, message)
+	}
+	return ctx.JSON(http.StatusOK, status)
+}
+
+func (c *BreakerController) respondError(ctx echo.Context, err error, message string) error {
+	var unknown breaker.ErrUnknownUpstream
+	if errors.As(err, &unknown) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": unknown.Error()})
+	}
+	var invalid breaker.ErrInvalidOverride
+	if errors.As(err, &invalid) {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": invalid.Error()})
+	}
+	c.logger.Error(message, "upstream", ctx.Param("upstream"), "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
@@ -0,0 +1,25 @@
+package capabilities
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/capabilities"
+)
+
+type CapabilityLister interface {
+	List() []capabilities.Status
+}
+
+type CapabilitiesController struct {
+	capabilities CapabilityLister
+}
+
+func NewCapabilitiesController(capabilities CapabilityLister) *CapabilitiesController {
+	return &CapabilitiesController{capabilities: capabilities}
+}
+
+// Handle lists the optional subsystems with, for disabled ones, the reason and how to enable them.
+func (c *CapabilitiesController) Handle(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, map[string][]capabilities.Status{"capabilities": c.capabilities.List()})
+}
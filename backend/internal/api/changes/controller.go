@@ -0,0 +1,52 @@
+package changes
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/inventory"
+)
+
+const maxWindow = 7 * 24 * time.Hour
+
+type ChangeReader interface {
+	Changes(ctx context.Context, config, cluster string, since time.Duration) (inventory.Changelog, error)
+}
+
+type ChangesController struct {
+	tracker ChangeReader
+	logger  *log.Logger
+}
+
+func NewChangesController(tracker ChangeReader, logger *log.Logger) *ChangesController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &ChangesController{tracker: tracker, logger: logger}
+}
+
+func (c *ChangesController) Handle(ctx echo.Context) error {
+	since := 24 * time.Hour
+	if raw := ctx.QueryParam("since"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "since must be a positive duration such as 24h"})
+		}
+		since = parsed
+	}
+	if since > maxWindow {
+		since = maxWindow
+	}
+
+	config := ctx.QueryParam("config")
+	cluster := ctx.QueryParam("cluster")
+	changelog, err := c.tracker.Changes(ctx.Request().Context(), config, cluster, since)
+	if err != nil {
+		c.logger.Error("failed to build changelog", "config", config, "cluster", cluster, "error", err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to build changelog"})
+	}
+	return ctx.JSON(http.StatusOK, changelog)
+}
@@ -0,0 +1,73 @@
+package chaos
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/chaos"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+)
+
+type ExperimentService interface {
+	Start(actor string, in chaos.ExperimentInput) (chaos.Experiment, error)
+	Stop(actor, id string) (chaos.Experiment, error)
+	List() ([]chaos.Experiment, error)
+}
+
+type ChaosController struct {
+	service ExperimentService
+	logger  *log.Logger
+}
+
+func NewChaosController(service ExperimentService, logger *log.Logger) *ChaosController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &ChaosController{service: service, logger: logger}
+}
+
+// Start begins a chaos experiment that ends by itself once its window runs out.
+func (c *ChaosController) Start(ctx echo.Context) error {
+	var req chaos.ExperimentInput
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	experiment, err := c.service.Start(identity.User(ctx), req)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to start chaos experiment")
+	}
+	return ctx.JSON(http.StatusCreated, experiment)
+}
+
+// Stop ends a chaos experiment early.
+func (c *ChaosController) Stop(ctx echo.Context) error {
+	experiment, err := c.service.Stop(identity.User(ctx), ctx.Param("id"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to stop chaos experiment")
+	}
+	return ctx.JSON(http.StatusOK, experiment)
+}
+
+// List returns running and recently finished experiments.
+func (c *ChaosController) List(ctx echo.Context) error {
+	experiments, err := c.service.List()
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list chaos experiments")
+	}
+	return ctx.JSON(http.StatusOK, map[string][]chaos.Experiment{"experiments": experiments})
+}
+
+func (c *ChaosController) respondError(ctx echo.Context, err error, message string) error {
+	var notFound chaos.ErrExperimentNotFound
+	if errors.As(err, &notFound) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": notFound.Error()})
+	}
+	var invalid chaos.ErrInvalidExperiment
+	if errors.As(err, &invalid) {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": invalid.Error()})
+	}
+	c.logger.Error(message, "experiment", ctx.Param("id"), "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
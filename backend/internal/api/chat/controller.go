@@ -0,0 +1,134 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/chat"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+)
+
+type SessionService interface {
+	Start(ctx context.Context, owner, title string) (chat.Session, error)
+	Sessions(ctx context.Context, owner string) ([]chat.Session, error)
+	Conversation(ctx context.Context, owner, id string) (chat.Conversation, error)
+	Delete(ctx context.Context, owner, id string) error
+	ActiveContext(ctx context.Context, owner, id string) (*chat.ActiveContext, error)
+	ClearContext(ctx context.Context, owner, id string) error
+	Retention(ctx context.Context, owner string) (chat.Retention, error)
+	SetRetention(ctx context.Context, owner string, days int) (chat.Retention, error)
+}
+
+type CreateSessionRequest struct {
+	Title string `json:"title"`
+}
+
+type RetentionRequest struct {
+	// Days is how long idle sessions are kept; 0 restores the server default.
+	Days int `json:"days"`
+}
+
+type SessionController struct {
+	service SessionService
+	logger  *log.Logger
+}
+
+func NewSessionController(service SessionService, logger *log.Logger) *SessionController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &SessionController{service: service, logger: logger}
+}
+
+// List returns the caller's chat sessions, most recently active first.
+func (c *SessionController) List(ctx echo.Context) error {
+	sessions, err := c.service.Sessions(ctx.Request().Context(), identity.User(ctx))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list chat sessions")
+	}
+	return ctx.JSON(http.StatusOK, map[string][]chat.Session{"sessions": sessions})
+}
+
+// Create starts an empty session that prompts can continue with its ID.
+func (c *SessionController) Create(ctx echo.Context) error {
+	var req CreateSessionRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	session, err := c.service.Start(ctx.Request().Context(), identity.User(ctx), req.Title)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to start chat session")
+	}
+	return ctx.JSON(http.StatusCreated, session)
+}
+
+// Get returns a session with its messages so the conversation can be resumed.
+func (c *SessionController) Get(ctx echo.Context) error {
+	conversation, err := c.service.Conversation(ctx.Request().Context(), identity.User(ctx), ctx.Param("id"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to load chat session")
+	}
+	return ctx.JSON(http.StatusOK, conversation)
+}
+
+func (c *SessionController) Delete(ctx echo.Context) error {
+	if err := c.service.Delete(ctx.Request().Context(), identity.User(ctx), ctx.Param("id")); err != nil {
+		return c.respondError(ctx, err, "failed to delete chat session")
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// GetContext returns what the session currently refers to; the context is null once cleared.
+func (c *SessionController) GetContext(ctx echo.Context) error {
+	active, err := c.service.ActiveContext(ctx.Request().Context(), identity.User(ctx), ctx.Param("id"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to load chat context")
+	}
+	return ctx.JSON(http.StatusOK, map[string]*chat.ActiveContext{"context": active})
+}
+
+// ClearContext forgets what the session refers to, so its next prompt does not carry over.
+func (c *SessionController) ClearContext(ctx echo.Context) error {
+	if err := c.service.ClearContext(ctx.Request().Context(), identity.User(ctx), ctx.Param("id")); err != nil {
+		return c.respondError(ctx, err, "failed to clear chat context")
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// GetRetention returns how long the caller's idle sessions are kept.
+func (c *SessionController) GetRetention(ctx echo.Context) error {
+	retention, err := c.service.Retention(ctx.Request().Context(), identity.User(ctx))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to load chat retention")
+	}
+	return ctx.JSON(http.StatusOK, retention)
+}
+
+// SetRetention changes how long the caller's idle sessions are kept.
+func (c *SessionController) SetRetention(ctx echo.Context) error {
+	var req RetentionRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	retention, err := c.service.SetRetention(ctx.Request().Context(), identity.User(ctx), req.Days)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to update chat retention")
+	}
+	return ctx.JSON(http.StatusOK, retention)
+}
+
+func (c *SessionController) respondError(ctx echo.Context, err error, message string) error {
+	var notFound chat.ErrSessionNotFound
+	if errors.As(err, &notFound) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": notFound.Error()})
+	}
+	var invalid chat.ErrInvalidRequest
+	if errors.As(err, &invalid) {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": invalid.Error()})
+	}
+	c.logger.Error(message, "session", ctx.Param("id"), "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
@@ -0,0 +1,133 @@
+package chatfiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/chatfiles"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+)
+
+// multipartOverhead allows for the multipart framing around an upload at the size limit.
+const multipartOverhead = 64 << 10
+
+type FileService interface {
+	Upload(ctx context.Context, owner, name, contentType string, r io.Reader) (chatfiles.File, error)
+	Get(ctx context.Context, owner, id string) (chatfiles.File, error)
+	Open(ctx context.Context, owner, id string) (chatfiles.File, io.ReadCloser, error)
+	List(ctx context.Context, owner string) ([]chatfiles.File, error)
+	Delete(ctx context.Context, owner, id string) error
+}
+
+type AttachmentController struct {
+	service FileService
+	maxSize int64
+	logger  *log.Logger
+}
+
+func NewAttachmentController(service FileService, maxSize int64, logger *log.Logger) *AttachmentController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if maxSize <= 0 {
+		maxSize = chatfiles.DefaultMaxSize
+	}
+	return &AttachmentController{service: service, maxSize: maxSize, logger: logger}
+}
+
+// Upload stores the multipart "file" field as a chat attachment once it passed the content scans.
+func (c *AttachmentController) Upload(ctx echo.Context) error {
+	req := ctx.Request()
+	req.Body = http.MaxBytesReader(ctx.Response(), req.Body, c.maxSize+multipartOverhead)
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return c.respondError(ctx, chatfiles.ErrTooLarge{Limit: c.maxSize}, "")
+		}
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "multipart field file is required"})
+	}
+	if header.Size > c.maxSize {
+		return c.respondError(ctx, chatfiles.ErrTooLarge{Limit: c.maxSize}, "")
+	}
+	src, err := header.Open()
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "failed to read uploaded file"})
+	}
+	defer src.Close()
+
+	file, err := c.service.Upload(req.Context(), identity.User(ctx), header.Filename, header.Header.Get(echo.HeaderContentType), src)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to store attachment")
+	}
+	c.logger.Info("chat attachment uploaded", "id", file.ID, "owner", file.Owner, "kind", file.Kind, "size", file.Size)
+	return ctx.JSON(http.StatusCreated, file)
+}
+
+func (c *AttachmentController) List(ctx echo.Context) error {
+	files, err := c.service.List(ctx.Request().Context(), identity.User(ctx))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list attachments")
+	}
+	return ctx.JSON(http.StatusOK, map[string][]chatfiles.File{"attachments": files})
+}
+
+func (c *AttachmentController) Get(ctx echo.Context) error {
+	file, err := c.service.Get(ctx.Request().Context(), identity.User(ctx), ctx.Param("id"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to load attachment")
+	}
+	return ctx.JSON(http.StatusOK, file)
+}
+
+// Content streams the attachment back to its owner.
+func (c *AttachmentController) Content(ctx echo.Context) error {
+	file, reader, err := c.service.Open(ctx.Request().Context(), identity.User(ctx), ctx.Param("id"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to open attachment")
+	}
+	defer reader.Close()
+	header := ctx.Response().Header()
+	header.Set(echo.HeaderContentLength, strconv.FormatInt(file.Size, 10))
+	header.Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", file.Name))
+	header.Set("X-Content-SHA256", file.SHA256)
+	return ctx.Stream(http.StatusOK, file.ContentType, reader)
+}
+
+func (c *AttachmentController) Delete(ctx echo.Context) error {
+	if err := c.service.Delete(ctx.Request().Context(), identity.User(ctx), ctx.Param("id")); err != nil {
+		return c.respondError(ctx, err, "failed to delete attachment")
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (c *AttachmentController) respondError(ctx echo.Context, err error, message string) error {
+	var notFound chatfiles.ErrFileNotFound
+	if errors.As(err, &notFound) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": notFound.Error()})
+	}
+	var invalid chatfiles.ErrInvalidRequest
+	if errors.As(err, &invalid) {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": invalid.Error()})
+	}
+	var tooLarge chatfiles.ErrTooLarge
+	if errors.As(err, &tooLarge) {
+		return ctx.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": tooLarge.Error()})
+	}
+	var rejected chatfiles.ErrRejected
+	if errors.As(err, &rejected) {
+		return ctx.JSON(http.StatusUnprocessableEntity, map[string]any{"error": rejected.Error(), "findings": rejected.Findings})
+	}
+	var scanFailed chatfiles.ErrScanFailed
+	if errors.As(err, &scanFailed) {
+		return ctx.JSON(http.StatusServiceUnavailable, map[string]string{"error": "attachment could not be scanned, try again later"})
+	}
+	c.logger.Error(message, "attachment", ctx.Param("id"), "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
@@ -0,0 +1,109 @@
+package clusters
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/clusters"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+)
+
+type ClusterManager interface {
+	List(ctx context.Context) ([]clusters.Cluster, error)
+	Get(ctx context.Context, id string) (clusters.Cluster, error)
+	Register(ctx context.Context, actor string, spec clusters.Spec) (clusters.Cluster, error)
+	Update(ctx context.Context, id string, spec clusters.Spec) (clusters.Cluster, error)
+	Delete(ctx context.Context, id string) error
+	Health(ctx context.Context, id string) (clusters.Health, error)
+}
+
+type ClusterController struct {
+	manager ClusterManager
+	logger  *log.Logger
+}
+
+func NewClusterController(manager ClusterManager, logger *log.Logger) *ClusterController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &ClusterController{manager: manager, logger: logger}
+}
+
+func (c *ClusterController) List(ctx echo.Context) error {
+	list, err := c.manager.List(ctx.Request().Context())
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list clusters")
+	}
+	return ctx.JSON(http.StatusOK, list)
+}
+
+func (c *ClusterController) Get(ctx echo.Context) error {
+	cluster, err := c.manager.Get(ctx.Request().Context(), ctx.Param("id"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to load cluster")
+	}
+	return ctx.JSON(http.StatusOK, cluster)
+}
+
+func (c *ClusterController) Create(ctx echo.Context) error {
+	var spec clusters.Spec
+	if err := ctx.Bind(&spec); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	spec.Name = strings.TrimSpace(spec.Name)
+	cluster, err := c.manager.Register(ctx.Request().Context(), identity.User(ctx), spec)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to register cluster")
+	}
+	return ctx.JSON(http.StatusCreated, cluster)
+}
+
+// Update replaces labels, and credentials when the body carries them, e.g. to rotate a token.
+func (c *ClusterController) Update(ctx echo.Context) error {
+	var spec clusters.Spec
+	if err := ctx.Bind(&spec); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	spec.Name = strings.TrimSpace(spec.Name)
+	cluster, err := c.manager.Update(ctx.Request().Context(), ctx.Param("id"), spec)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to update cluster")
+	}
+	return ctx.JSON(http.StatusOK, cluster)
+}
+
+func (c *ClusterController) Delete(ctx echo.Context) error {
+	if err := c.manager.Delete(ctx.Request().Context(), ctx.Param("id")); err != nil {
+		return c.respondError(ctx, err, "failed to delete cluster")
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// Health probes the cluster's API server and reports the result; an unreachable cluster is
+// still a 200 with status "unreachable".
+func (c *ClusterController) Health(ctx echo.Context) error {
+	health, err := c.manager.Health(ctx.Request().Context(), ctx.Param("id"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to check cluster health")
+	}
+	return ctx.JSON(http.StatusOK, health)
+}
+
+func (c *ClusterController) respondError(ctx echo.Context, err error, message string) error {
+	var (
+		notFound clusters.ErrClusterNotFound
+		invalid  clusters.ErrInvalidRequest
+	)
+	switch {
+	case errors.As(err, &notFound):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.As(err, &invalid):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	c.logger.Error(message, "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
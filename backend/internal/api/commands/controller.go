@@ -0,0 +1,607 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/chatfiles"
+	"github.com/pramodksahoo/kubechat/backend/internal/commands"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+	"github.com/pramodksahoo/kubechat/backend/internal/roles"
+)
+
+type ExecutionService interface {
+	Submit(ctx context.Context, req commands.Request) (commands.Execution, error)
+	SubmitPlan(ctx context.Context, req commands.PlanRequest) (commands.Execution, error)
+	Preview(ctx context.Context, req commands.Request) (commands.Preview, error)
+	DryRun(ctx context.Context, req commands.Request) (commands.DryRunResult, error)
+	Explain(ctx context.Context, id string) (commands.Explanation, error)
+	Get(ctx context.Context, id string) (commands.Execution, error)
+	ListByUser(ctx context.Context, userID string, limit, offset int) ([]commands.Execution, error)
+	ResourceHistory(ctx context.Context, filter commands.AuditFilter) ([]commands.Execution, error)
+	SafetyPolicy() commands.SafetyPolicyStatus
+	NamespaceTemplate(workspace string) (commands.NamespaceTemplate, bool)
+	Delete(ctx context.Context, id, user string) error
+	Approve(ctx context.Context, id, approver string, groups []string, reason string) (commands.Execution, error)
+	Reject(ctx context.Context, id, approver string, groups []string, reason string) (commands.Execution, error)
+	Rollback(ctx context.Context, id, user string, groups []string) (commands.Execution, error)
+	PreviewRollback(ctx context.Context, id string) (commands.RollbackPreview, error)
+	PendingApprovals(ctx context.Context) ([]commands.Approval, error)
+	Approvals(ctx context.Context, status commands.ApprovalStatus) ([]commands.Approval, error)
+	Approval(ctx context.Context, id string) (commands.Approval, []commands.ApprovalEvent, error)
+	AssignedApprovals(ctx context.Context, user string, groups []string) ([]commands.AssignedApproval, error)
+	Delegate(ctx context.Context, delegator string, req commands.DelegationRequest) (commands.ApprovalDelegation, error)
+	RevokeDelegation(ctx context.Context, id, user string) (commands.ApprovalDelegation, error)
+	Delegations(ctx context.Context, user string) ([]commands.ApprovalDelegation, error)
+	Schedule(ctx context.Context, req commands.ScheduleRequest) (commands.CommandSchedule, error)
+	GetSchedule(ctx context.Context, id, user string) (commands.CommandSchedule, error)
+	Schedules(ctx context.Context, user string) ([]commands.CommandSchedule, error)
+	CancelSchedule(ctx context.Context, id, user string) (commands.CommandSchedule, error)
+	DefineChangeWindow(ctx context.Context, window commands.ChangeWindow) (commands.ChangeWindow, error)
+	ChangeWindows(ctx context.Context) ([]commands.ChangeWindow, error)
+	DeleteChangeWindow(ctx context.Context, id, user string) error
+	FreezeCalendar(ctx context.Context, from, to time.Time) ([]commands.FreezeOccurrence, error)
+	OverrideFreeze(ctx context.Context, id, approver string, groups []string, reason string) (commands.Execution, error)
+	Attachment(ctx context.Context, executionID, attachmentID string) (commands.Attachment, io.ReadCloser, error)
+}
+
+// Authorizer checks whether the caller may act on another user's executions.
+type Authorizer interface {
+	Authorize(ctx context.Context, user string, groups []string, permission roles.Permission, namespace string) error
+}
+
+type ExecutionController struct {
+	service    ExecutionService
+	authorizer Authorizer
+	logger     *log.Logger
+}
+
+type ApprovalResponse struct {
+	Approval commands.Approval        `json:"approval"`
+	Events   []commands.ApprovalEvent `json:"events"`
+}
+
+type decisionRequest struct {
+	Reason string `json:"reason"`
+}
+
+func NewExecutionController(service ExecutionService, logger *log.Logger) *ExecutionController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &ExecutionController{service: service, logger: logger}
+}
+
+// SetAuthorizer lets users holding roles:manage read and delete other users' executions; without
+// an authorizer users only reach their own.
+func (c *ExecutionController) SetAuthorizer(authorizer Authorizer) {
+	c.authorizer = authorizer
+}
+
+func (c *ExecutionController) Create(ctx echo.Context) error {
+	var req commands.Request
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	if req.Config == "" {
+		req.Config = ctx.QueryParam("config")
+	}
+	if req.Cluster == "" {
+		req.Cluster = ctx.QueryParam("cluster")
+	}
+	req.UserID = identity.User(ctx)
+	req.Groups = identity.Groups(ctx)
+	req.Workspace = identity.Workspace(ctx)
+
+	if req.DryRun {
+		result, err := c.service.DryRun(ctx.Request().Context(), req)
+		if err != nil {
+			return c.respondError(ctx, err, "failed to dry-run command")
+		}
+		return ctx.JSON(http.StatusOK, result)
+	}
+	execution, err := c.service.Submit(ctx.Request().Context(), req)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to submit command")
+	}
+	status := http.StatusCreated
+	if execution.Status == commands.StatusAwaitingApproval || execution.Status == commands.StatusQueued {
+		status = http.StatusAccepted
+	}
+	return ctx.JSON(status, execution)
+}
+
+// CreatePlan submits several commands to run in order as one plan, approved as a whole.
+func (c *ExecutionController) CreatePlan(ctx echo.Context) error {
+	var req commands.PlanRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	if req.Config == "" {
+		req.Config = ctx.QueryParam("config")
+	}
+	if req.Cluster == "" {
+		req.Cluster = ctx.QueryParam("cluster")
+	}
+	req.UserID = identity.User(ctx)
+	req.Groups = identity.Groups(ctx)
+	req.Workspace = identity.Workspace(ctx)
+
+	plan, err := c.service.SubmitPlan(ctx.Request().Context(), req)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to submit command plan")
+	}
+	status := http.StatusCreated
+	if plan.Status == commands.StatusAwaitingApproval || plan.Status == commands.StatusQueued {
+		status = http.StatusAccepted
+	}
+	return ctx.JSON(status, plan)
+}
+
+// Preview reports the safety level, kubectl equivalent and RBAC verdict for a command without running it.
+func (c *ExecutionController) Preview(ctx echo.Context) error {
+	var req commands.Request
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	if req.Config == "" {
+		req.Config = ctx.QueryParam("config")
+	}
+	if req.Cluster == "" {
+		req.Cluster = ctx.QueryParam("cluster")
+	}
+	req.UserID = identity.User(ctx)
+	req.Groups = identity.Groups(ctx)
+	req.Workspace = identity.Workspace(ctx)
+
+	preview, err := c.service.Preview(ctx.Request().Context(), req)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to preview command")
+	}
+	return ctx.JSON(http.StatusOK, preview)
+}
+
+// Explain describes what an execution's command does, which resources it touches, whether it can
+// be undone and which permissions it needs.
+func (c *ExecutionController) Explain(ctx echo.Context) error {
+	explanation, err := c.service.Explain(ctx.Request().Context(), ctx.Param("id"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to explain command execution")
+	}
+	return ctx.JSON(http.StatusOK, explanation)
+}
+
+func (c *ExecutionController) List(ctx echo.Context) error {
+	limit, _ := strconv.Atoi(ctx.QueryParam("limit"))
+	offset, _ := strconv.Atoi(ctx.QueryParam("offset"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	executions, err := c.service.ListByUser(ctx.Request().Context(), identity.User(ctx), limit, max(offset, 0))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list command executions")
+	}
+	if executions == nil {
+		executions = []commands.Execution{}
+	}
+	return ctx.JSON(http.StatusOK, executions)
+}
+
+// ResourceHistory lists every action taken against one object, optionally on one ?cluster=.
+// Cluster-scoped objects are queried with "_" as the namespace.
+func (c *ExecutionController) ResourceHistory(ctx echo.Context) error {
+	limit, _ := strconv.Atoi(ctx.QueryParam("limit"))
+	offset, _ := strconv.Atoi(ctx.QueryParam("offset"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	executions, err := c.service.ResourceHistory(ctx.Request().Context(), commands.AuditFilter{
+		Target: commands.Target{
+			Cluster:   ctx.QueryParam("cluster"),
+			Namespace: ctx.Param("namespace"),
+			Kind:      ctx.Param("kind"),
+			Name:      ctx.Param("name"),
+		},
+		Limit:  limit,
+		Offset: max(offset, 0),
+	})
+	if err != nil {
+		return c.respondError(ctx, err, "failed to load resource audit history")
+	}
+	if executions == nil {
+		executions = []commands.Execution{}
+	}
+	return ctx.JSON(http.StatusOK, map[string][]commands.Execution{"executions": executions})
+}
+
+// SafetyPolicy returns the safety rules in force and where they were loaded from.
+func (c *ExecutionController) SafetyPolicy(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, c.service.SafetyPolicy())
+}
+
+// NamespaceTemplate returns the template applied to namespaces created from the caller's workspace.
+func (c *ExecutionController) NamespaceTemplate(ctx echo.Context) error {
+	template, ok := c.service.NamespaceTemplate(identity.Workspace(ctx))
+	if !ok {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "no namespace template applies to this workspace"})
+	}
+	return ctx.JSON(http.StatusOK, template)
+}
+
+func (c *ExecutionController) Get(ctx echo.Context) error {
+	execution, err := c.service.Get(ctx.Request().Context(), ctx.Param("id"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to load command execution")
+	}
+	if !c.visible(ctx, execution) {
+		return c.respondError(ctx, commands.ErrExecutionNotFound{ID: execution.ID}, "failed to load command execution")
+	}
+	return ctx.JSON(http.StatusOK, execution)
+}
+
+// Delete hides the caller's execution; retention purges it later.
+func (c *ExecutionController) Delete(ctx echo.Context) error {
+	execution, err := c.service.Get(ctx.Request().Context(), ctx.Param("id"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to delete command execution")
+	}
+	if !c.visible(ctx, execution) {
+		return c.respondError(ctx, commands.ErrExecutionNotFound{ID: execution.ID}, "failed to delete command execution")
+	}
+	if err := c.service.Delete(ctx.Request().Context(), execution.ID, identity.User(ctx)); err != nil {
+		return c.respondError(ctx, err, "failed to delete command execution")
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// visible reports whether the caller requested execution or holds roles:manage. Others are told
+// it does not exist.
+func (c *ExecutionController) visible(ctx echo.Context, execution commands.Execution) bool {
+	caller := identity.User(ctx)
+	if execution.UserID == caller {
+		return true
+	}
+	if c.authorizer == nil {
+		return false
+	}
+	return c.authorizer.Authorize(ctx.Request().Context(), caller, identity.Groups(ctx), roles.PermissionRolesManage, "") == nil
+}
+
+func (c *ExecutionController) Approve(ctx echo.Context) error {
+	var req decisionRequest
+	_ = ctx.Bind(&req)
+	execution, err := c.service.Approve(ctx.Request().Context(), ctx.Param("id"), identity.User(ctx), identity.Groups(ctx), req.Reason)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to approve command execution")
+	}
+	return ctx.JSON(http.StatusOK, execution)
+}
+
+func (c *ExecutionController) Reject(ctx echo.Context) error {
+	var req decisionRequest
+	_ = ctx.Bind(&req)
+	execution, err := c.service.Reject(ctx.Request().Context(), ctx.Param("id"), identity.User(ctx), identity.Groups(ctx), req.Reason)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to reject command execution")
+	}
+	return ctx.JSON(http.StatusOK, execution)
+}
+
+func (c *ExecutionController) Rollback(ctx echo.Context) error {
+	if dryRun, _ := strconv.ParseBool(ctx.QueryParam("dryRun")); dryRun {
+		preview, err := c.service.PreviewRollback(ctx.Request().Context(), ctx.Param("id"))
+		if err != nil {
+			return c.respondError(ctx, err, "failed to preview rollback")
+		}
+		return ctx.JSON(http.StatusOK, preview)
+	}
+	execution, err := c.service.Rollback(ctx.Request().Context(), ctx.Param("id"), identity.User(ctx), identity.Groups(ctx))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to roll back command execution")
+	}
+	return ctx.JSON(http.StatusOK, execution)
+}
+
+func (c *ExecutionController) PendingApprovals(ctx echo.Context) error {
+	approvals, err := c.service.PendingApprovals(ctx.Request().Context())
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list pending approvals")
+	}
+	if approvals == nil {
+		approvals = []commands.Approval{}
+	}
+	return ctx.JSON(http.StatusOK, approvals)
+}
+
+// AssignedApprovals lists the pending approvals the caller may decide, including those routed to
+// them by a delegation.
+func (c *ExecutionController) AssignedApprovals(ctx echo.Context) error {
+	approvals, err := c.service.AssignedApprovals(ctx.Request().Context(), identity.User(ctx), identity.Groups(ctx))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list assigned approvals")
+	}
+	if approvals == nil {
+		approvals = []commands.AssignedApproval{}
+	}
+	return ctx.JSON(http.StatusOK, approvals)
+}
+
+func (c *ExecutionController) Delegations(ctx echo.Context) error {
+	delegations, err := c.service.Delegations(ctx.Request().Context(), identity.User(ctx))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list approval delegations")
+	}
+	if delegations == nil {
+		delegations = []commands.ApprovalDelegation{}
+	}
+	return ctx.JSON(http.StatusOK, delegations)
+}
+
+func (c *ExecutionController) Delegate(ctx echo.Context) error {
+	var req commands.DelegationRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	delegation, err := c.service.Delegate(ctx.Request().Context(), identity.User(ctx), req)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to delegate approvals")
+	}
+	return ctx.JSON(http.StatusCreated, delegation)
+}
+
+func (c *ExecutionController) RevokeDelegation(ctx echo.Context) error {
+	delegation, err := c.service.RevokeDelegation(ctx.Request().Context(), ctx.Param("id"), identity.User(ctx))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to revoke approval delegation")
+	}
+	return ctx.JSON(http.StatusOK, delegation)
+}
+
+// Schedule records a command to run once at runAt or repeatedly on a cron expression.
+func (c *ExecutionController) Schedule(ctx echo.Context) error {
+	var req commands.ScheduleRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	if req.Config == "" {
+		req.Config = ctx.QueryParam("config")
+	}
+	if req.Cluster == "" {
+		req.Cluster = ctx.QueryParam("cluster")
+	}
+	req.UserID = identity.User(ctx)
+	req.Groups = identity.Groups(ctx)
+	req.Workspace = identity.Workspace(ctx)
+
+	schedule, err := c.service.Schedule(ctx.Request().Context(), req)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to schedule command")
+	}
+	return ctx.JSON(http.StatusCreated, schedule)
+}
+
+func (c *ExecutionController) Schedules(ctx echo.Context) error {
+	schedules, err := c.service.Schedules(ctx.Request().Context(), identity.User(ctx))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list command schedules")
+	}
+	if schedules == nil {
+		schedules = []commands.CommandSchedule{}
+	}
+	return ctx.JSON(http.StatusOK, schedules)
+}
+
+func (c *ExecutionController) GetSchedule(ctx echo.Context) error {
+	schedule, err := c.service.GetSchedule(ctx.Request().Context(), ctx.Param("id"), identity.User(ctx))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to load command schedule")
+	}
+	return ctx.JSON(http.StatusOK, schedule)
+}
+
+func (c *ExecutionController) CancelSchedule(ctx echo.Context) error {
+	schedule, err := c.service.CancelSchedule(ctx.Request().Context(), ctx.Param("id"), identity.User(ctx))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to cancel command schedule")
+	}
+	return ctx.JSON(http.StatusOK, schedule)
+}
+
+// OverrideFreeze runs an execution queued by a change window without waiting for the window to end.
+func (c *ExecutionController) OverrideFreeze(ctx echo.Context) error {
+	var req decisionRequest
+	_ = ctx.Bind(&req)
+	execution, err := c.service.OverrideFreeze(ctx.Request().Context(), ctx.Param("id"), identity.User(ctx), identity.Groups(ctx), req.Reason)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to override change window")
+	}
+	return ctx.JSON(http.StatusOK, execution)
+}
+
+func (c *ExecutionController) ChangeWindows(ctx echo.Context) error {
+	windows, err := c.service.ChangeWindows(ctx.Request().Context())
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list change windows")
+	}
+	if windows == nil {
+		windows = []commands.ChangeWindow{}
+	}
+	return ctx.JSON(http.StatusOK, windows)
+}
+
+// ChangeWindowCalendar lists the freezes between the RFC 3339 from and to parameters, which
+// default to the next seven days.
+func (c *ExecutionController) ChangeWindowCalendar(ctx echo.Context) error {
+	from := time.Now().UTC()
+	if raw := ctx.QueryParam("from"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "from must be an RFC 3339 time"})
+		}
+		from = parsed
+	}
+	to := from.AddDate(0, 0, 7)
+	if raw := ctx.QueryParam("to"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "to must be an RFC 3339 time"})
+		}
+		to = parsed
+	}
+	calendar, err := c.service.FreezeCalendar(ctx.Request().Context(), from, to)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list change window calendar")
+	}
+	return ctx.JSON(http.StatusOK, calendar)
+}
+
+func (c *ExecutionController) DefineChangeWindow(ctx echo.Context) error {
+	var window commands.ChangeWindow
+	if err := ctx.Bind(&window); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	window.CreatedBy = identity.User(ctx)
+	window, err := c.service.DefineChangeWindow(ctx.Request().Context(), window)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to define change window")
+	}
+	return ctx.JSON(http.StatusCreated, window)
+}
+
+func (c *ExecutionController) DeleteChangeWindow(ctx echo.Context) error {
+	if err := c.service.DeleteChangeWindow(ctx.Request().Context(), ctx.Param("id"), identity.User(ctx)); err != nil {
+		return c.respondError(ctx, err, "failed to delete change window")
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (c *ExecutionController) Approvals(ctx echo.Context) error {
+	status := commands.ApprovalStatus(ctx.QueryParam("status"))
+	switch status {
+	case "", commands.ApprovalPending, commands.ApprovalApproved, commands.ApprovalRejected, commands.ApprovalExpired:
+	default:
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "unknown approval status"})
+	}
+	approvals, err := c.service.Approvals(ctx.Request().Context(), status)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list approvals")
+	}
+	if approvals == nil {
+		approvals = []commands.Approval{}
+	}
+	return ctx.JSON(http.StatusOK, approvals)
+}
+
+func (c *ExecutionController) Approval(ctx echo.Context) error {
+	approval, events, err := c.service.Approval(ctx.Request().Context(), ctx.Param("id"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to load approval")
+	}
+	if events == nil {
+		events = []commands.ApprovalEvent{}
+	}
+	return ctx.JSON(http.StatusOK, ApprovalResponse{Approval: approval, Events: events})
+}
+
+// Attachment streams an output that was too large to keep on the execution record.
+func (c *ExecutionController) Attachment(ctx echo.Context) error {
+	attachment, reader, err := c.service.Attachment(ctx.Request().Context(), ctx.Param("id"), ctx.Param("attachmentId"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to load attachment")
+	}
+	defer reader.Close()
+
+	header := ctx.Response().Header()
+	header.Set(echo.HeaderContentLength, strconv.FormatInt(attachment.Size, 10))
+	header.Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", attachment.Name))
+	header.Set("X-Content-SHA256", attachment.SHA256)
+	return ctx.Stream(http.StatusOK, attachment.ContentType, reader)
+}
+
+func (c *ExecutionController) respondError(ctx echo.Context, err error, message string) error {
+	var notFound commands.ErrExecutionNotFound
+	if errors.As(err, &notFound) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "command execution not found"})
+	}
+	var noAttachment commands.ErrAttachmentNotFound
+	if errors.As(err, &noAttachment) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "attachment not found"})
+	}
+	var noApproval commands.ErrApprovalNotFound
+	if errors.As(err, &noApproval) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": noApproval.Error()})
+	}
+	var noDelegation commands.ErrDelegationNotFound
+	if errors.As(err, &noDelegation) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": noDelegation.Error()})
+	}
+	var noSchedule commands.ErrScheduleNotFound
+	if errors.As(err, &noSchedule) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": noSchedule.Error()})
+	}
+	var noWindow commands.ErrChangeWindowNotFound
+	if errors.As(err, &noWindow) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": noWindow.Error()})
+	}
+	var frozen commands.ErrChangeFrozen
+	if errors.As(err, &frozen) {
+		if wait := time.Until(frozen.Until); wait > 0 {
+			ctx.Response().Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(wait.Seconds())))))
+		}
+		return ctx.JSON(http.StatusConflict, map[string]any{"error": frozen.Error(), "changeWindow": frozen.Window, "frozenUntil": frozen.Until})
+	}
+	var managed commands.ErrManagedResource
+	if errors.As(err, &managed) {
+		return ctx.JSON(http.StatusConflict, map[string]any{"error": managed.Error(), "driftWarning": managed.Warning})
+	}
+	var invalid commands.ErrInvalidRequest
+	if errors.As(err, &invalid) {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": invalid.Error()})
+	}
+	var denied commands.ErrAccessDenied
+	if errors.As(err, &denied) {
+		return ctx.JSON(http.StatusForbidden, map[string]any{"error": denied.Error(), "accessReview": denied.Review})
+	}
+	if errors.As(err, &roles.ErrForbidden{}) {
+		return ctx.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	}
+	var policyDenied commands.ErrPolicyDenied
+	if errors.As(err, &policyDenied) {
+		return ctx.JSON(http.StatusForbidden, map[string]any{"error": policyDenied.Error(), "policyDecision": policyDenied.Decision})
+	}
+	var quota commands.ErrQuotaExceeded
+	if errors.As(err, &quota) {
+		if quota.RetryAfter > 0 {
+			ctx.Response().Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(quota.RetryAfter.Seconds())))))
+		}
+		return ctx.JSON(http.StatusTooManyRequests, map[string]string{"error": quota.Error()})
+	}
+	if errors.As(err, &commands.ErrPolicyUnavailable{}) {
+		return ctx.JSON(http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+	}
+	var notAllowed commands.ErrResourceNotAllowed
+	if errors.As(err, &notAllowed) {
+		return ctx.JSON(http.StatusForbidden, map[string]string{"error": notAllowed.Error()})
+	}
+	if errors.As(err, &chatfiles.ErrFileNotFound{}) || errors.As(err, &chatfiles.ErrInvalidRequest{}) {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if errors.Is(err, commands.ErrSelfApproval) || errors.Is(err, commands.ErrNotEligibleApprover) {
+		return ctx.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	}
+	if errors.Is(err, commands.ErrNotAwaitingApproval) || errors.Is(err, commands.ErrRollbackUnavailable) ||
+		errors.Is(err, commands.ErrAlreadyDecided) || errors.Is(err, commands.ErrApprovalExpired) || errors.Is(err, commands.ErrNotQueued) ||
+		errors.Is(err, commands.ErrApprovalConflict) {
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	c.logger.Error(message, "execution_id", ctx.Param("id"), "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/commands"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+)
+
+type ExecutorController struct {
+	registry *commands.WebhookRegistry
+	logger   *log.Logger
+}
+
+type RegisterExecutorRequest struct {
+	Operation      string               `json:"operation"`
+	URL            string               `json:"url"`
+	Description    string               `json:"description"`
+	SafetyLevel    string               `json:"safetyLevel"`
+	TimeoutSeconds int                  `json:"timeoutSeconds"`
+	Auth           commands.WebhookAuth `json:"auth"`
+}
+
+func NewExecutorController(registry *commands.WebhookRegistry, logger *log.Logger) *ExecutorController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &ExecutorController{registry: registry, logger: logger}
+}
+
+func (c *ExecutorController) Register(ctx echo.Context) error {
+	var req RegisterExecutorRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+
+	executor, err := c.registry.Register(commands.WebhookExecutor{
+		Operation:   commands.Operation(req.Operation),
+		URL:         req.URL,
+		Description: req.Description,
+		SafetyLevel: commands.SafetyLevel(req.SafetyLevel),
+		Timeout:     time.Duration(req.TimeoutSeconds) * time.Second,
+		Auth:        req.Auth,
+		CreatedBy:   identity.User(ctx),
+	})
+	if err != nil {
+		var invalid commands.ErrInvalidRequest
+		if errors.As(err, &invalid) {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": invalid.Error()})
+		}
+		c.logger.Error("failed to register executor", "operation", req.Operation, "error", err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to register executor"})
+	}
+	c.logger.Info("registered webhook executor", "operation", executor.Operation, "url", executor.URL, "by", executor.CreatedBy)
+	return ctx.JSON(http.StatusCreated, executor)
+}
+
+func (c *ExecutorController) List(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, c.registry.List())
+}
+
+func (c *ExecutorController) Delete(ctx echo.Context) error {
+	if !c.registry.Remove(commands.Operation(ctx.Param("operation"))) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "executor not found"})
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,144 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/commands"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+)
+
+// ApprovalLinkService verifies approval links and records their decisions.
+type ApprovalLinkService interface {
+	ApprovalByLink(ctx context.Context, token string) (commands.ApprovalLink, commands.Execution, commands.Approval, error)
+	DecideByLink(ctx context.Context, token, user string, groups []string, reason string) (commands.ApprovalLink, commands.Execution, error)
+}
+
+// ApprovalLinkController serves the approve and reject links sent to approvers. Following a link
+// only shows the decision; it is recorded when the page is submitted, so that mail scanners and
+// link previews that fetch it decide nothing.
+type ApprovalLinkController struct {
+	service ApprovalLinkService
+	logger  *log.Logger
+}
+
+type ApprovalLinkResponse struct {
+	Link      commands.ApprovalLink `json:"link"`
+	Execution commands.Execution    `json:"execution"`
+	Approval  commands.Approval     `json:"approval"`
+}
+
+func NewApprovalLinkController(service ApprovalLinkService, logger *log.Logger) *ApprovalLinkController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &ApprovalLinkController{service: service, logger: logger}
+}
+
+var approvalLinkPage = template.Must(template.New("approval").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><meta name="robots" content="noindex"><title>KubeChat approval</title></head>
+<body>
+{{- if .Error}}
+<h1>{{.Error}}</h1>
+{{- else if .Decided}}
+<h1>Recorded: {{.Link.Action}}</h1>
+<p>Command execution {{.Execution.ID}} is now {{.Execution.Status}}.</p>
+{{- else}}
+<h1>{{if eq .Link.Action "approve"}}Approve{{else}}Reject{{end}} command execution</h1>
+<p>{{.Execution.UserID}} asked to run {{.Execution.Operation}} {{.Execution.Resource.Kind}}/{{.Execution.Resource.Name}}{{with .Execution.Resource.Namespace}} in {{.}}{{end}} on {{.Execution.Config}}/{{.Execution.Cluster}}.</p>
+<p>Safety: {{.Execution.SafetyLevel}}</p>
+{{- with .Approval.Explanation}}
+{{- with .Command}}<pre>{{.}}</pre>{{end}}
+<p>{{.Summary}}</p>
+{{- if .Effects}}<ul>{{range .Effects}}<li>{{.}}</li>{{end}}</ul>{{end}}
+<p>Reversibility: {{.Reversibility}}. {{.Reversal}}</p>
+{{- end}}
+<p>Policy {{.Approval.PolicyName}} needs {{.Approval.RequiredApprovals}} approval(s).{{with .Approval.ExpiresAt}} The request expires at {{.Format "2006-01-02 15:04 MST"}}.{{end}}</p>
+<form method="post">
+<label>Reason <input name="reason" type="text"></label>
+<button type="submit">{{if eq .Link.Action "approve"}}Approve{{else}}Reject{{end}}</button>
+</form>
+{{- end}}
+</body>
+</html>
+`))
+
+type approvalLinkView struct {
+	ApprovalLinkResponse
+	Decided bool
+	Error   string
+}
+
+// Get describes the decision the link records, as a confirmation page for browsers.
+func (c *ApprovalLinkController) Get(ctx echo.Context) error {
+	link, execution, approval, err := c.service.ApprovalByLink(ctx.Request().Context(), ctx.Param("token"))
+	if err != nil {
+		return c.respondError(ctx, err)
+	}
+	response := ApprovalLinkResponse{Link: link, Execution: execution, Approval: approval}
+	if wantsJSON(ctx) {
+		return ctx.JSON(http.StatusOK, response)
+	}
+	return c.render(ctx, http.StatusOK, approvalLinkView{ApprovalLinkResponse: response})
+}
+
+// Decide records the link's decision as the caller, who must be the approver the link was sent to
+// and still be eligible.
+func (c *ApprovalLinkController) Decide(ctx echo.Context) error {
+	var req decisionRequest
+	if wantsJSON(ctx) {
+		_ = ctx.Bind(&req)
+	} else {
+		req.Reason = ctx.FormValue("reason")
+	}
+	link, execution, err := c.service.DecideByLink(ctx.Request().Context(), ctx.Param("token"), identity.User(ctx), identity.Groups(ctx), req.Reason)
+	if err != nil {
+		return c.respondError(ctx, err)
+	}
+	if wantsJSON(ctx) {
+		return ctx.JSON(http.StatusOK, execution)
+	}
+	return c.render(ctx, http.StatusOK, approvalLinkView{ApprovalLinkResponse: ApprovalLinkResponse{Link: link, Execution: execution}, Decided: true})
+}
+
+func (c *ApprovalLinkController) respondError(ctx echo.Context, err error) error {
+	status, message := http.StatusInternalServerError, "failed to process approval link"
+	switch {
+	case errors.Is(err, commands.ErrInvalidApprovalLink), errors.As(err, &commands.ErrExecutionNotFound{}), errors.As(err, &commands.ErrApprovalNotFound{}):
+		status, message = http.StatusNotFound, commands.ErrInvalidApprovalLink.Error()
+	case errors.Is(err, commands.ErrApprovalLinkUsed):
+		status, message = http.StatusGone, err.Error()
+	case errors.Is(err, commands.ErrSelfApproval), errors.Is(err, commands.ErrNotEligibleApprover), errors.Is(err, commands.ErrNotLinkRecipient):
+		status, message = http.StatusForbidden, err.Error()
+	case errors.Is(err, commands.ErrNotAwaitingApproval), errors.Is(err, commands.ErrAlreadyDecided), errors.Is(err, commands.ErrApprovalExpired):
+		status, message = http.StatusConflict, err.Error()
+	case errors.As(err, &commands.ErrChangeFrozen{}), errors.As(err, &commands.ErrQuotaExceeded{}):
+		status, message = http.StatusConflict, err.Error()
+	default:
+		c.logger.Error(message, "error", err)
+	}
+	if wantsJSON(ctx) {
+		return ctx.JSON(status, map[string]string{"error": message})
+	}
+	return c.render(ctx, status, approvalLinkView{Error: message})
+}
+
+func (c *ApprovalLinkController) render(ctx echo.Context, status int, view approvalLinkView) error {
+	var page strings.Builder
+	if err := approvalLinkPage.Execute(&page, view); err != nil {
+		return err
+	}
+	return ctx.HTML(status, page.String())
+}
+
+// wantsJSON reports whether the caller is an API client rather than a browser following the link.
+func wantsJSON(ctx echo.Context) bool {
+	return strings.Contains(ctx.Request().Header.Get(echo.HeaderAccept), echo.MIMEApplicationJSON) ||
+		strings.HasPrefix(ctx.Request().Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON)
+}
@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/commands"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+)
+
+type CommandQuotaManager interface {
+	Usage(user string) commands.CommandUsage
+	Overview() commands.CommandQuotaOverview
+	SetDefault(limits commands.CommandLimits) error
+	SetUserLimits(user string, limits commands.CommandLimits) error
+	RemoveUserLimits(user string) bool
+	Freeze(freeze commands.NamespaceFreeze) (commands.NamespaceFreeze, error)
+	Unfreeze(cluster, namespace string) error
+	Freezes() []commands.NamespaceFreeze
+}
+
+type QuotaController struct {
+	quota  CommandQuotaManager
+	logger *log.Logger
+}
+
+func NewQuotaController(quota CommandQuotaManager, logger *log.Logger) *QuotaController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &QuotaController{quota: quota, logger: logger}
+}
+
+// Get returns the caller's command usage against their limits and the freezes in effect.
+func (c *QuotaController) Get(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, map[string]any{"usage": c.quota.Usage(identity.User(ctx)), "freezes": c.quota.Freezes()})
+}
+
+// Overview lists the limits, freezes and the usage of every user seen so far.
+func (c *QuotaController) Overview(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, c.quota.Overview())
+}
+
+// SetDefault changes the limits of users without an override.
+func (c *QuotaController) SetDefault(ctx echo.Context) error {
+	var req commands.CommandLimits
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	if err := c.quota.SetDefault(req); err != nil {
+		return c.respondError(ctx, err)
+	}
+	c.logger.Info("default command limits updated", "destructive_per_hour", req.DestructivePerHour, "max_concurrent", req.MaxConcurrent, "user", identity.User(ctx))
+	return ctx.JSON(http.StatusOK, c.quota.Overview())
+}
+
+// SetUser overrides the default limits for one user.
+func (c *QuotaController) SetUser(ctx echo.Context) error {
+	var req commands.CommandLimits
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	user := ctx.Param("user")
+	if err := c.quota.SetUserLimits(user, req); err != nil {
+		return c.respondError(ctx, err)
+	}
+	c.logger.Info("user command limits updated", "subject", user, "destructive_per_hour", req.DestructivePerHour, "max_concurrent", req.MaxConcurrent, "user", identity.User(ctx))
+	return ctx.JSON(http.StatusOK, c.quota.Usage(user))
+}
+
+// RemoveUser returns a user to the default limits.
+func (c *QuotaController) RemoveUser(ctx echo.Context) error {
+	if !c.quota.RemoveUserLimits(ctx.Param("user")) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "user has no command limit override"})
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// Freeze starts a change freeze on a namespace.
+func (c *QuotaController) Freeze(ctx echo.Context) error {
+	var req commands.NamespaceFreeze
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	req.CreatedBy = identity.User(ctx)
+	freeze, err := c.quota.Freeze(req)
+	if err != nil {
+		return c.respondError(ctx, err)
+	}
+	c.logger.Info("namespace frozen for changes", "cluster", freeze.Cluster, "namespace", freeze.Namespace, "until", freeze.Until, "user", freeze.CreatedBy)
+	return ctx.JSON(http.StatusCreated, freeze)
+}
+
+// Unfreeze lifts the change freeze of the namespace; ?cluster= selects a single-cluster freeze.
+func (c *QuotaController) Unfreeze(ctx echo.Context) error {
+	cluster, namespace := strings.TrimSpace(ctx.QueryParam("cluster")), ctx.Param("namespace")
+	if err := c.quota.Unfreeze(cluster, namespace); err != nil {
+		return c.respondError(ctx, err)
+	}
+	c.logger.Info("namespace freeze lifted", "cluster", cluster, "namespace", namespace, "user", identity.User(ctx))
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (c *QuotaController) respondError(ctx echo.Context, err error) error {
+	var invalid commands.ErrInvalidQuota
+	if errors.As(err, &invalid) {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": invalid.Error()})
+	}
+	var notFound commands.ErrFreezeNotFound
+	if errors.As(err, &notFound) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": notFound.Error()})
+	}
+	c.logger.Error("command quota request failed", "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "command quota request failed"})
+}
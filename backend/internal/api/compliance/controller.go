@@ -0,0 +1,101 @@
+package compliance
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/compliance"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+)
+
+type ReportAnalyzer interface {
+	Controls() []compliance.ControlInfo
+	Analyze(ctx context.Context, framework compliance.Framework, from, to *time.Time) (compliance.Report, error)
+}
+
+type AccessReviews interface {
+	Record(ctx context.Context, reviewer, notes string) (compliance.AccessReview, error)
+	Reviews(ctx context.Context) ([]compliance.AccessReview, error)
+}
+
+type ReviewRequest struct {
+	Notes string `json:"notes"`
+}
+
+// Catalog lists the frameworks reports can be generated for and the controls they evaluate.
+type Catalog struct {
+	Frameworks []compliance.FrameworkInfo `json:"frameworks"`
+	Controls   []compliance.ControlInfo   `json:"controls"`
+}
+
+type ComplianceController struct {
+	analyzer ReportAnalyzer
+	reviews  AccessReviews
+	logger   *log.Logger
+}
+
+func NewComplianceController(analyzer ReportAnalyzer, reviews AccessReviews, logger *log.Logger) *ComplianceController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &ComplianceController{analyzer: analyzer, reviews: reviews, logger: logger}
+}
+
+func (c *ComplianceController) Catalog(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, Catalog{Frameworks: compliance.Frameworks(), Controls: c.analyzer.Controls()})
+}
+
+// Report evaluates a framework's controls between the RFC 3339 from and to parameters, which
+// default to the last 30 days.
+func (c *ComplianceController) Report(ctx echo.Context) error {
+	var from, to *time.Time
+	for name, bound := range map[string]**time.Time{"from": &from, "to": &to} {
+		raw := ctx.QueryParam(name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": name + " must be an RFC 3339 time"})
+		}
+		*bound = &parsed
+	}
+	report, err := c.analyzer.Analyze(ctx.Request().Context(), compliance.Framework(ctx.Param("framework")), from, to)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to generate compliance report")
+	}
+	return ctx.JSON(http.StatusOK, report)
+}
+
+func (c *ComplianceController) Reviews(ctx echo.Context) error {
+	reviews, err := c.reviews.Reviews(ctx.Request().Context())
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list access reviews")
+	}
+	return ctx.JSON(http.StatusOK, reviews)
+}
+
+// RecordReview marks every current role binding reviewed by the caller.
+func (c *ComplianceController) RecordReview(ctx echo.Context) error {
+	var req ReviewRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	review, err := c.reviews.Record(ctx.Request().Context(), identity.User(ctx), req.Notes)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to record access review")
+	}
+	return ctx.JSON(http.StatusCreated, review)
+}
+
+func (c *ComplianceController) respondError(ctx echo.Context, err error, message string) error {
+	if errors.As(err, &compliance.ErrInvalidRequest{}) {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	c.logger.Error(message, "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
@@ -0,0 +1,43 @@
+package correlation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/correlation"
+)
+
+type Tracer interface {
+	Trace(ctx context.Context, id string) (correlation.Trail, error)
+}
+
+// CorrelationController serves the audit trail linking a chat message to the commands it led to
+// and the cluster changes they made.
+type CorrelationController struct {
+	tracer Tracer
+	logger *log.Logger
+}
+
+func NewCorrelationController(tracer Tracer, logger *log.Logger) *CorrelationController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &CorrelationController{tracer: tracer, logger: logger}
+}
+
+func (c *CorrelationController) Trace(ctx echo.Context) error {
+	id := ctx.Param("id")
+	trail, err := c.tracer.Trace(ctx.Request().Context(), id)
+	var notFound correlation.ErrNotFound
+	if errors.As(err, &notFound) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		c.logger.Error("failed to load correlated audit trail", "error", err, "id", id)
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to load correlated audit trail"})
+	}
+	return ctx.JSON(http.StatusOK, trail)
+}
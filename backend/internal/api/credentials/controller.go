@@ -0,0 +1,174 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/credentials"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+)
+
+type Keys interface {
+	List(ctx context.Context, user string) ([]credentials.Key, error)
+	Put(ctx context.Context, user, provider string, req credentials.KeyRequest) (credentials.Key, error)
+	Delete(ctx context.Context, user, provider string) error
+	Backends() []string
+	Migrate(ctx context.Context, req credentials.MigrationRequest) (credentials.Migration, error)
+	Rewrap(ctx context.Context) (credentials.Rewrap, error)
+	Validate(ctx context.Context, req credentials.ValidationRequest) (credentials.ValidationJob, error)
+	ValidationJob(id string) (credentials.ValidationJob, error)
+	Policies(ctx context.Context) ([]credentials.Policy, error)
+	PutPolicy(ctx context.Context, provider string, policy credentials.Policy) (credentials.Policy, error)
+	DeletePolicy(ctx context.Context, provider string) error
+}
+
+// KeysController lets users manage the model provider API keys they bring for themselves.
+type KeysController struct {
+	keys   Keys
+	logger *log.Logger
+}
+
+func NewKeysController(keys Keys, logger *log.Logger) *KeysController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &KeysController{keys: keys, logger: logger}
+}
+
+func (c *KeysController) List(ctx echo.Context) error {
+	keys, err := c.keys.List(ctx.Request().Context(), identity.User(ctx))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list API keys")
+	}
+	return ctx.JSON(http.StatusOK, map[string][]credentials.Key{"keys": keys})
+}
+
+// Put stores the caller's key for the provider in the path. Admins impersonating a user cannot
+// change their keys.
+func (c *KeysController) Put(ctx echo.Context) error {
+	if identity.Actor(ctx) != identity.User(ctx) {
+		return ctx.JSON(http.StatusForbidden, map[string]string{"error": "API keys cannot be changed while impersonating"})
+	}
+	var req credentials.KeyRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	key, err := c.keys.Put(ctx.Request().Context(), identity.User(ctx), ctx.Param("provider"), req)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to store API key")
+	}
+	return ctx.JSON(http.StatusOK, key)
+}
+
+func (c *KeysController) Delete(ctx echo.Context) error {
+	if identity.Actor(ctx) != identity.User(ctx) {
+		return ctx.JSON(http.StatusForbidden, map[string]string{"error": "API keys cannot be changed while impersonating"})
+	}
+	if err := c.keys.Delete(ctx.Request().Context(), identity.User(ctx), ctx.Param("provider")); err != nil {
+		return c.respondError(ctx, err, "failed to remove API key")
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// Backends lists where keys may be kept, for the backend field of a key.
+func (c *KeysController) Backends(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, map[string][]string{"backends": c.keys.Backends()})
+}
+
+// Migrate moves every user's keys from one backend to another. Keys that fail to move are
+// reported and stay usable where they were, so the migration can be run again.
+func (c *KeysController) Migrate(ctx echo.Context) error {
+	var req credentials.MigrationRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	migration, err := c.keys.Migrate(ctx.Request().Context(), req)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to migrate API keys")
+	}
+	return ctx.JSON(http.StatusOK, migration)
+}
+
+// Rewrap re-wraps data keys with the current KMS key version at once, rather than waiting for
+// the periodic job, such as right after rotating the key.
+func (c *KeysController) Rewrap(ctx echo.Context) error {
+	rewrap, err := c.keys.Rewrap(ctx.Request().Context())
+	if err != nil {
+		return c.respondError(ctx, err, "failed to re-wrap API key data keys")
+	}
+	return ctx.JSON(http.StatusOK, rewrap)
+}
+
+// Validate checks stored keys with their providers. Small batches answer 200 with the summary;
+// larger ones answer 202 with a job to read from Validation.
+func (c *KeysController) Validate(ctx echo.Context) error {
+	var req credentials.ValidationRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	job, err := c.keys.Validate(ctx.Request().Context(), req)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to validate API keys")
+	}
+	if job.Status != credentials.ValidationCompleted {
+		return ctx.JSON(http.StatusAccepted, job)
+	}
+	return ctx.JSON(http.StatusOK, job)
+}
+
+func (c *KeysController) Validation(ctx echo.Context) error {
+	job, err := c.keys.ValidationJob(ctx.Param("id"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to read API key validation")
+	}
+	return ctx.JSON(http.StatusOK, job)
+}
+
+func (c *KeysController) Policies(ctx echo.Context) error {
+	policies, err := c.keys.Policies(ctx.Request().Context())
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list API key policies")
+	}
+	return ctx.JSON(http.StatusOK, map[string][]credentials.Policy{"policies": policies})
+}
+
+// PutPolicy replaces the policy of the provider in the path; * sets the one for providers
+// without their own.
+func (c *KeysController) PutPolicy(ctx echo.Context) error {
+	var policy credentials.Policy
+	if err := ctx.Bind(&policy); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	policy, err := c.keys.PutPolicy(ctx.Request().Context(), ctx.Param("provider"), policy)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to store API key policy")
+	}
+	return ctx.JSON(http.StatusOK, policy)
+}
+
+func (c *KeysController) DeletePolicy(ctx echo.Context) error {
+	if err := c.keys.DeletePolicy(ctx.Request().Context(), ctx.Param("provider")); err != nil {
+		return c.respondError(ctx, err, "failed to remove API key policy")
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (c *KeysController) respondError(ctx echo.Context, err error, message string) error {
+	var (
+		notFound   credentials.ErrKeyNotFound
+		validation credentials.ErrValidationNotFound
+		policy     credentials.ErrPolicyNotFound
+		invalid    credentials.ErrInvalidRequest
+	)
+	switch {
+	case errors.As(err, &notFound), errors.As(err, &validation), errors.As(err, &policy):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.As(err, &invalid):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	c.logger.Error(message, "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
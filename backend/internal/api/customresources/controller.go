@@ -0,0 +1,57 @@
+package customresources
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/clusters"
+	"github.com/pramodksahoo/kubechat/backend/internal/customresources"
+)
+
+type Index interface {
+	Definitions(ctx context.Context, config, cluster string, refresh bool) ([]customresources.Definition, error)
+}
+
+type ClusterResolver interface {
+	Resolve(ctx context.Context, ref string) (string, string, error)
+}
+
+type DefinitionController struct {
+	index    Index
+	clusters ClusterResolver
+	logger   *log.Logger
+}
+
+func NewDefinitionController(index Index, clusters ClusterResolver, logger *log.Logger) *DefinitionController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &DefinitionController{index: index, clusters: clusters, logger: logger}
+}
+
+// List returns the custom resource definitions installed in the cluster given by ID or name, as
+// prompts see them. ?refresh=true reads them again instead of using the cached ones.
+func (c *DefinitionController) List(ctx echo.Context) error {
+	config, kubeContext, err := c.clusters.Resolve(ctx.Request().Context(), ctx.Param("cluster"))
+	if err != nil {
+		var notFound clusters.ErrClusterNotFound
+		var invalid clusters.ErrInvalidRequest
+		switch {
+		case errors.As(err, &notFound):
+			return ctx.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		case errors.As(err, &invalid):
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		c.logger.Error("failed to resolve cluster", "error", err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to resolve cluster"})
+	}
+	definitions, err := c.index.Definitions(ctx.Request().Context(), config, kubeContext, ctx.QueryParam("refresh") == "true")
+	if err != nil {
+		c.logger.Error("failed to list custom resource definitions", "error", err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to list custom resource definitions"})
+	}
+	return ctx.JSON(http.StatusOK, map[string]any{"definitions": definitions})
+}
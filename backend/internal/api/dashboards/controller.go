@@ -0,0 +1,231 @@
+package dashboards
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/dashboards"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+)
+
+// variablePrefix marks query parameters that override dashboard variables, e.g. ?var.cluster=prod.
+const variablePrefix = "var."
+
+type DashboardService interface {
+	Sources() []dashboards.SourceInfo
+
+	Widgets(ctx context.Context, viewer dashboards.Viewer) ([]dashboards.Widget, error)
+	Widget(ctx context.Context, viewer dashboards.Viewer, id string) (dashboards.Widget, error)
+	CreateWidget(ctx context.Context, viewer dashboards.Viewer, widget dashboards.Widget) (dashboards.Widget, error)
+	UpdateWidget(ctx context.Context, viewer dashboards.Viewer, id string, widget dashboards.Widget) (dashboards.Widget, error)
+	DeleteWidget(ctx context.Context, viewer dashboards.Viewer, id string) error
+	RunWidget(ctx context.Context, viewer dashboards.Viewer, id string, variables map[string]string) (dashboards.PanelResult, error)
+
+	Dashboards(ctx context.Context, viewer dashboards.Viewer) ([]dashboards.Dashboard, error)
+	Dashboard(ctx context.Context, viewer dashboards.Viewer, id string) (dashboards.Dashboard, error)
+	CreateDashboard(ctx context.Context, viewer dashboards.Viewer, dashboard dashboards.Dashboard) (dashboards.Dashboard, error)
+	UpdateDashboard(ctx context.Context, viewer dashboards.Viewer, id string, dashboard dashboards.Dashboard) (dashboards.Dashboard, error)
+	DeleteDashboard(ctx context.Context, viewer dashboards.Viewer, id string) error
+	Render(ctx context.Context, viewer dashboards.Viewer, id string, variables map[string]string) (dashboards.Rendered, error)
+}
+
+type WidgetRequest struct {
+	Name          string            `json:"name"`
+	Description   string            `json:"description"`
+	Visualization string            `json:"visualization"`
+	Source        string            `json:"source"`
+	Params        map[string]string `json:"params"`
+	Shared        bool              `json:"shared"`
+}
+
+func (r WidgetRequest) widget() dashboards.Widget {
+	return dashboards.Widget{
+		Name:          strings.TrimSpace(r.Name),
+		Description:   r.Description,
+		Visualization: r.Visualization,
+		Source:        r.Source,
+		Params:        r.Params,
+		Shared:        r.Shared,
+	}
+}
+
+type DashboardRequest struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Shared      bool               `json:"shared"`
+	Panels      []dashboards.Panel `json:"panels"`
+	Variables   map[string]string  `json:"variables"`
+}
+
+func (r DashboardRequest) dashboard() dashboards.Dashboard {
+	return dashboards.Dashboard{
+		Name:        strings.TrimSpace(r.Name),
+		Description: r.Description,
+		Shared:      r.Shared,
+		Panels:      r.Panels,
+		Variables:   r.Variables,
+	}
+}
+
+type DashboardController struct {
+	service DashboardService
+	logger  *log.Logger
+}
+
+func NewDashboardController(service DashboardService, logger *log.Logger) *DashboardController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &DashboardController{service: service, logger: logger}
+}
+
+func viewer(ctx echo.Context) dashboards.Viewer {
+	return dashboards.Viewer{User: identity.User(ctx), Workspace: identity.Workspace(ctx)}
+}
+
+func variables(ctx echo.Context) map[string]string {
+	vars := map[string]string{}
+	for name, values := range ctx.QueryParams() {
+		if key, ok := strings.CutPrefix(name, variablePrefix); ok && key != "" && len(values) > 0 {
+			vars[key] = values[0]
+		}
+	}
+	return vars
+}
+
+func (c *DashboardController) Sources(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, c.service.Sources())
+}
+
+func (c *DashboardController) ListWidgets(ctx echo.Context) error {
+	widgets, err := c.service.Widgets(ctx.Request().Context(), viewer(ctx))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list widgets")
+	}
+	return ctx.JSON(http.StatusOK, widgets)
+}
+
+func (c *DashboardController) GetWidget(ctx echo.Context) error {
+	widget, err := c.service.Widget(ctx.Request().Context(), viewer(ctx), ctx.Param("id"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to load widget")
+	}
+	return ctx.JSON(http.StatusOK, widget)
+}
+
+func (c *DashboardController) CreateWidget(ctx echo.Context) error {
+	var req WidgetRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	widget, err := c.service.CreateWidget(ctx.Request().Context(), viewer(ctx), req.widget())
+	if err != nil {
+		return c.respondError(ctx, err, "failed to create widget")
+	}
+	return ctx.JSON(http.StatusCreated, widget)
+}
+
+func (c *DashboardController) UpdateWidget(ctx echo.Context) error {
+	var req WidgetRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	widget, err := c.service.UpdateWidget(ctx.Request().Context(), viewer(ctx), ctx.Param("id"), req.widget())
+	if err != nil {
+		return c.respondError(ctx, err, "failed to update widget")
+	}
+	return ctx.JSON(http.StatusOK, widget)
+}
+
+func (c *DashboardController) DeleteWidget(ctx echo.Context) error {
+	if err := c.service.DeleteWidget(ctx.Request().Context(), viewer(ctx), ctx.Param("id")); err != nil {
+		return c.respondError(ctx, err, "failed to delete widget")
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (c *DashboardController) RunWidget(ctx echo.Context) error {
+	result, err := c.service.RunWidget(ctx.Request().Context(), viewer(ctx), ctx.Param("id"), variables(ctx))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to run widget")
+	}
+	return ctx.JSON(http.StatusOK, result)
+}
+
+func (c *DashboardController) ListDashboards(ctx echo.Context) error {
+	list, err := c.service.Dashboards(ctx.Request().Context(), viewer(ctx))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list dashboards")
+	}
+	return ctx.JSON(http.StatusOK, list)
+}
+
+func (c *DashboardController) GetDashboard(ctx echo.Context) error {
+	dashboard, err := c.service.Dashboard(ctx.Request().Context(), viewer(ctx), ctx.Param("id"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to load dashboard")
+	}
+	return ctx.JSON(http.StatusOK, dashboard)
+}
+
+func (c *DashboardController) CreateDashboard(ctx echo.Context) error {
+	var req DashboardRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	dashboard, err := c.service.CreateDashboard(ctx.Request().Context(), viewer(ctx), req.dashboard())
+	if err != nil {
+		return c.respondError(ctx, err, "failed to create dashboard")
+	}
+	return ctx.JSON(http.StatusCreated, dashboard)
+}
+
+func (c *DashboardController) UpdateDashboard(ctx echo.Context) error {
+	var req DashboardRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	dashboard, err := c.service.UpdateDashboard(ctx.Request().Context(), viewer(ctx), ctx.Param("id"), req.dashboard())
+	if err != nil {
+		return c.respondError(ctx, err, "failed to update dashboard")
+	}
+	return ctx.JSON(http.StatusOK, dashboard)
+}
+
+func (c *DashboardController) DeleteDashboard(ctx echo.Context) error {
+	if err := c.service.DeleteDashboard(ctx.Request().Context(), viewer(ctx), ctx.Param("id")); err != nil {
+		return c.respondError(ctx, err, "failed to delete dashboard")
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (c *DashboardController) Render(ctx echo.Context) error {
+	rendered, err := c.service.Render(ctx.Request().Context(), viewer(ctx), ctx.Param("id"), variables(ctx))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to render dashboard")
+	}
+	return ctx.JSON(http.StatusOK, rendered)
+}
+
+func (c *DashboardController) respondError(ctx echo.Context, err error, message string) error {
+	var (
+		widgetNotFound    dashboards.ErrWidgetNotFound
+		dashboardNotFound dashboards.ErrDashboardNotFound
+		forbidden         dashboards.ErrForbidden
+		invalid           dashboards.ErrInvalidRequest
+	)
+	switch {
+	case errors.As(err, &widgetNotFound), errors.As(err, &dashboardNotFound):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.As(err, &forbidden):
+		return ctx.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	case errors.As(err, &invalid):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	c.logger.Error(message, "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
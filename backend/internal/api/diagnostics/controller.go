@@ -0,0 +1,76 @@
+package diagnostics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/clusters"
+	"github.com/pramodksahoo/kubechat/backend/internal/diagnostics"
+)
+
+type Runner interface {
+	Run(ctx context.Context, cluster diagnostics.Cluster, req diagnostics.Request) (diagnostics.Report, error)
+}
+
+type ClusterResolver interface {
+	Resolve(ctx context.Context, ref string) (string, string, error)
+}
+
+type PlaybookController struct {
+	runner   Runner
+	clusters ClusterResolver
+	logger   *log.Logger
+}
+
+func NewPlaybookController(runner Runner, clusters ClusterResolver, logger *log.Logger) *PlaybookController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &PlaybookController{runner: runner, clusters: clusters, logger: logger}
+}
+
+// List returns the built-in playbooks.
+func (c *PlaybookController) List(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, map[string]any{"playbooks": diagnostics.Playbooks})
+}
+
+// Run runs the playbooks in ?playbooks=, a comma-separated list, or all of them, against the
+// cluster given by ID or name. ?namespace= and ?target= narrow the pods they look at.
+func (c *PlaybookController) Run(ctx echo.Context) error {
+	ref := ctx.Param("cluster")
+	config, kubeContext, err := c.clusters.Resolve(ctx.Request().Context(), ref)
+	if err != nil {
+		return c.respondError(ctx, err)
+	}
+	req := diagnostics.Request{Namespace: ctx.QueryParam("namespace"), Target: ctx.QueryParam("target")}
+	for _, id := range strings.Split(ctx.QueryParam("playbooks"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			req.Playbooks = append(req.Playbooks, id)
+		}
+	}
+	report, err := c.runner.Run(ctx.Request().Context(), diagnostics.Cluster{Name: ref, Config: config, Context: kubeContext}, req)
+	if err != nil {
+		return c.respondError(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, report)
+}
+
+func (c *PlaybookController) respondError(ctx echo.Context, err error) error {
+	var (
+		notFound clusters.ErrClusterNotFound
+		invalid  clusters.ErrInvalidRequest
+		unknown  diagnostics.ErrUnknownPlaybook
+	)
+	switch {
+	case errors.As(err, &notFound):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.As(err, &invalid), errors.As(err, &unknown):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	c.logger.Error("failed to run diagnostics", "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to run diagnostics"})
+}
@@ -0,0 +1,136 @@
+package exports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/exports"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+	"github.com/pramodksahoo/kubechat/backend/internal/privacy"
+	"github.com/pramodksahoo/kubechat/backend/internal/roles"
+)
+
+type ExportManager interface {
+	Sources() []exports.SourceInfo
+	Submit(ctx context.Context, owner string, req exports.Request) (exports.Job, error)
+	Get(ctx context.Context, owner, id string) (exports.Job, error)
+	List(ctx context.Context, owner string) []exports.Job
+	Delete(ctx context.Context, owner, id string) error
+	Open(ctx context.Context, id, expires, signature string) (exports.Job, io.ReadCloser, error)
+}
+
+// Authorizer checks the KubeChat permissions some sources require.
+type Authorizer interface {
+	Authorize(ctx context.Context, user string, groups []string, permission roles.Permission, namespace string) error
+}
+
+type ExportController struct {
+	manager    ExportManager
+	authorizer Authorizer
+	logger     *log.Logger
+}
+
+func NewExportController(manager ExportManager, logger *log.Logger) *ExportController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &ExportController{manager: manager, logger: logger}
+}
+
+// SetAuthorizer enforces the permission a source declares before it is exported; without an
+// authorizer every source is open to every user.
+func (c *ExportController) SetAuthorizer(authorizer Authorizer) {
+	c.authorizer = authorizer
+}
+
+func (c *ExportController) Sources(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, c.manager.Sources())
+}
+
+// Create queues an export and returns immediately; clients poll the job for its download link.
+func (c *ExportController) Create(ctx echo.Context) error {
+	var req exports.Request
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+	}
+	if err := c.authorize(ctx, req.Source); err != nil {
+		return c.respondError(ctx, err, "failed to check roles")
+	}
+	job, err := c.manager.Submit(ctx.Request().Context(), identity.User(ctx), req)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to queue export")
+	}
+	return ctx.JSON(http.StatusAccepted, job)
+}
+
+func (c *ExportController) authorize(ctx echo.Context, source string) error {
+	if c.authorizer == nil {
+		return nil
+	}
+	for _, info := range c.manager.Sources() {
+		if info.Name == source && info.Permission != "" {
+			return c.authorizer.Authorize(ctx.Request().Context(), identity.User(ctx), identity.Groups(ctx), roles.Permission(info.Permission), "")
+		}
+	}
+	return nil
+}
+
+func (c *ExportController) List(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, c.manager.List(ctx.Request().Context(), identity.User(ctx)))
+}
+
+func (c *ExportController) Get(ctx echo.Context) error {
+	job, err := c.manager.Get(ctx.Request().Context(), identity.User(ctx), ctx.Param("id"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to load export")
+	}
+	return ctx.JSON(http.StatusOK, job)
+}
+
+func (c *ExportController) Delete(ctx echo.Context) error {
+	if err := c.manager.Delete(ctx.Request().Context(), identity.User(ctx), ctx.Param("id")); err != nil {
+		return c.respondError(ctx, err, "failed to delete export")
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// Download streams a finished export. The signed link is the only credential, so it can be
+// handed to tools that cannot send identity headers.
+func (c *ExportController) Download(ctx echo.Context) error {
+	job, reader, err := c.manager.Open(ctx.Request().Context(), ctx.Param("id"), ctx.QueryParam("expires"), ctx.QueryParam("signature"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to open export")
+	}
+	defer reader.Close()
+
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", job.FileName))
+	return ctx.Stream(http.StatusOK, job.Request.Format.ContentType(), reader)
+}
+
+func (c *ExportController) respondError(ctx echo.Context, err error, message string) error {
+	var (
+		notFound  exports.ErrJobNotFound
+		invalid   exports.ErrInvalidRequest
+		queueFull exports.ErrQueueFull
+		signature exports.ErrInvalidSignature
+		userLevel privacy.ErrUserLevel
+		forbidden roles.ErrForbidden
+	)
+	switch {
+	case errors.As(err, &notFound):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.As(err, &invalid):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.As(err, &queueFull):
+		return ctx.JSON(http.StatusTooManyRequests, map[string]string{"error": err.Error()})
+	case errors.As(err, &signature), errors.As(err, &userLevel), errors.As(err, &forbidden):
+		return ctx.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	}
+	c.logger.Error(message, "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
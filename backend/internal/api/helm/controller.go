@@ -0,0 +1,279 @@
+package helm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/chatfiles"
+	"github.com/pramodksahoo/kubechat/backend/internal/commands"
+	"github.com/pramodksahoo/kubechat/backend/internal/helm"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+	"github.com/pramodksahoo/kubechat/backend/internal/roles"
+)
+
+// ReleaseService reads Helm releases and changes them through command executions, so upgrades and
+// rollbacks are classified and approved like any other command.
+type ReleaseService interface {
+	HelmReleases(ctx context.Context, req commands.Request) ([]helm.Release, error)
+	HelmHistory(ctx context.Context, req commands.Request) ([]helm.Release, error)
+	HelmRelease(ctx context.Context, req commands.Request, revision int) (helm.Release, error)
+	PreviewHelm(ctx context.Context, req commands.Request) (commands.HelmPreview, error)
+	Submit(ctx context.Context, req commands.Request) (commands.Execution, error)
+}
+
+// ChangeRequest is the body of an upgrade or rollback of a release. An upgrade takes the chart
+// rendered with helm template, inline or as a chat attachment; a rollback takes the revision to
+// reinstall, the previous one when it is 0.
+type ChangeRequest struct {
+	Config      string         `json:"config"`
+	Cluster     string         `json:"cluster"`
+	Revision    int            `json:"revision,omitempty"`
+	Manifest    string         `json:"manifest,omitempty"`
+	Attachment  string         `json:"attachment,omitempty"`
+	Values      map[string]any `json:"values,omitempty"`
+	Description string         `json:"description,omitempty"`
+	// AcknowledgeDrift, CorrelationID and OverrideReason are passed on like those of a command.
+	AcknowledgeDrift bool   `json:"acknowledgeDrift,omitempty"`
+	CorrelationID    string `json:"correlationId,omitempty"`
+	OverrideReason   string `json:"overrideReason,omitempty"`
+}
+
+// ValuesResponse is the values supplied for a revision of a release.
+type ValuesResponse struct {
+	Release helm.Release   `json:"release"`
+	Values  map[string]any `json:"values"`
+}
+
+// ManifestResponse is the manifest a revision of a release installed.
+type ManifestResponse struct {
+	Release  helm.Release `json:"release"`
+	Manifest string       `json:"manifest"`
+}
+
+type ReleaseController struct {
+	service ReleaseService
+	logger  *log.Logger
+}
+
+func NewReleaseController(service ReleaseService, logger *log.Logger) *ReleaseController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &ReleaseController{service: service, logger: logger}
+}
+
+// List returns the latest revision of each release in ?namespace=, or in every namespace.
+func (c *ReleaseController) List(ctx echo.Context) error {
+	releases, err := c.service.HelmReleases(ctx.Request().Context(), c.query(ctx, ""))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list helm releases")
+	}
+	return ctx.JSON(http.StatusOK, map[string]any{"releases": releases})
+}
+
+func (c *ReleaseController) History(ctx echo.Context) error {
+	req, err := c.releaseQuery(ctx)
+	if err != nil {
+		return c.respondError(ctx, err, "")
+	}
+	history, err := c.service.HelmHistory(ctx.Request().Context(), req)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to read helm release history")
+	}
+	return ctx.JSON(http.StatusOK, map[string]any{"revisions": history})
+}
+
+// Values returns the values supplied for ?revision=, or for the latest revision.
+func (c *ReleaseController) Values(ctx echo.Context) error {
+	release, err := c.revision(ctx)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to read helm release values")
+	}
+	values := release.Values
+	if values == nil {
+		values = map[string]any{}
+	}
+	return ctx.JSON(http.StatusOK, ValuesResponse{Release: release, Values: values})
+}
+
+// Manifest returns the manifest ?revision=, or the latest revision, installed.
+func (c *ReleaseController) Manifest(ctx echo.Context) error {
+	release, err := c.revision(ctx)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to read helm release manifest")
+	}
+	return ctx.JSON(http.StatusOK, ManifestResponse{Release: release, Manifest: release.Manifest})
+}
+
+func (c *ReleaseController) PreviewUpgrade(ctx echo.Context) error {
+	return c.preview(ctx, commands.OperationHelmUpgrade)
+}
+
+func (c *ReleaseController) PreviewRollback(ctx echo.Context) error {
+	return c.preview(ctx, commands.OperationHelmRollback)
+}
+
+// Upgrade submits an upgrade of the release, which waits for approval like other changes.
+func (c *ReleaseController) Upgrade(ctx echo.Context) error {
+	return c.submit(ctx, commands.OperationHelmUpgrade)
+}
+
+// Rollback submits a rollback of the release, which waits for approval like other changes.
+func (c *ReleaseController) Rollback(ctx echo.Context) error {
+	return c.submit(ctx, commands.OperationHelmRollback)
+}
+
+func (c *ReleaseController) preview(ctx echo.Context, op commands.Operation) error {
+	req, err := c.change(ctx, op)
+	if err != nil {
+		return c.respondError(ctx, err, "")
+	}
+	preview, err := c.service.PreviewHelm(ctx.Request().Context(), req)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to preview helm "+string(op))
+	}
+	return ctx.JSON(http.StatusOK, preview)
+}
+
+func (c *ReleaseController) submit(ctx echo.Context, op commands.Operation) error {
+	req, err := c.change(ctx, op)
+	if err != nil {
+		return c.respondError(ctx, err, "")
+	}
+	execution, err := c.service.Submit(ctx.Request().Context(), req)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to submit helm "+string(op))
+	}
+	status := http.StatusCreated
+	if execution.Status == commands.StatusAwaitingApproval || execution.Status == commands.StatusQueued {
+		status = http.StatusAccepted
+	}
+	return ctx.JSON(status, execution)
+}
+
+func (c *ReleaseController) query(ctx echo.Context, name string) commands.Request {
+	return commands.Request{
+		UserID:   identity.User(ctx),
+		Groups:   identity.Groups(ctx),
+		Config:   ctx.QueryParam("config"),
+		Cluster:  ctx.QueryParam("cluster"),
+		Resource: commands.Resource{Kind: commands.HelmReleaseKind, Namespace: ctx.QueryParam("namespace"), Name: name},
+	}
+}
+
+// releaseQuery reads the release named in the path. Its namespace is the ?namespace= query
+// parameter, which namespaced role bindings are checked against.
+func (c *ReleaseController) releaseQuery(ctx echo.Context) (commands.Request, error) {
+	req := c.query(ctx, ctx.Param("name"))
+	if req.Resource.Namespace == "" {
+		return commands.Request{}, commands.ErrInvalidRequest{Reason: "namespace is required"}
+	}
+	return req, nil
+}
+
+func (c *ReleaseController) revision(ctx echo.Context) (helm.Release, error) {
+	req, err := c.releaseQuery(ctx)
+	if err != nil {
+		return helm.Release{}, err
+	}
+	revision := 0
+	if raw := ctx.QueryParam("revision"); raw != "" {
+		if revision, err = strconv.Atoi(raw); err != nil || revision < 1 {
+			return helm.Release{}, commands.ErrInvalidRequest{Reason: "revision must be a positive integer"}
+		}
+	}
+	return c.service.HelmRelease(ctx.Request().Context(), req, revision)
+}
+
+func (c *ReleaseController) change(ctx echo.Context, op commands.Operation) (commands.Request, error) {
+	req, err := c.releaseQuery(ctx)
+	if err != nil {
+		return commands.Request{}, err
+	}
+	var body ChangeRequest
+	if err := ctx.Bind(&body); err != nil {
+		return commands.Request{}, commands.ErrInvalidRequest{Reason: "invalid request payload"}
+	}
+	if body.Config != "" {
+		req.Config = body.Config
+	}
+	if body.Cluster != "" {
+		req.Cluster = body.Cluster
+	}
+	req.Operation = op
+	req.Workspace = identity.Workspace(ctx)
+	req.AcknowledgeDrift = body.AcknowledgeDrift
+	req.CorrelationID = body.CorrelationID
+	req.OverrideReason = body.OverrideReason
+	req.Parameters = map[string]string{}
+	switch op {
+	case commands.OperationHelmRollback:
+		if body.Revision < 0 {
+			return commands.Request{}, commands.ErrInvalidRequest{Reason: "revision must be a positive integer"}
+		}
+		if body.Revision > 0 {
+			req.Parameters["revision"] = strconv.Itoa(body.Revision)
+		}
+	case commands.OperationHelmUpgrade:
+		for key, value := range map[string]string{"manifest": body.Manifest, "attachment": body.Attachment, "description": body.Description} {
+			if value != "" {
+				req.Parameters[key] = value
+			}
+		}
+		if body.Values != nil {
+			values, err := json.Marshal(body.Values)
+			if err != nil {
+				return commands.Request{}, commands.ErrInvalidRequest{Reason: "values must be a JSON object"}
+			}
+			req.Parameters["values"] = string(values)
+		}
+	}
+	return req, nil
+}
+
+func (c *ReleaseController) respondError(ctx echo.Context, err error, message string) error {
+	var (
+		notFound helm.ErrReleaseNotFound
+		invalid  commands.ErrInvalidRequest
+		denied   commands.ErrAccessDenied
+		managed  commands.ErrManagedResource
+		frozen   commands.ErrChangeFrozen
+		policy   commands.ErrPolicyDenied
+		quota    commands.ErrQuotaExceeded
+	)
+	switch {
+	case errors.As(err, &notFound):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": notFound.Error()})
+	case errors.As(err, &invalid), errors.As(err, &chatfiles.ErrFileNotFound{}), errors.As(err, &chatfiles.ErrInvalidRequest{}):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.As(err, &denied):
+		return ctx.JSON(http.StatusForbidden, map[string]any{"error": denied.Error(), "accessReview": denied.Review})
+	case errors.As(err, &policy):
+		return ctx.JSON(http.StatusForbidden, map[string]any{"error": policy.Error(), "policyDecision": policy.Decision})
+	case errors.As(err, &roles.ErrForbidden{}), errors.As(err, &commands.ErrResourceNotAllowed{}):
+		return ctx.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	case errors.As(err, &managed):
+		return ctx.JSON(http.StatusConflict, map[string]any{"error": managed.Error(), "driftWarning": managed.Warning})
+	case errors.As(err, &frozen):
+		if wait := time.Until(frozen.Until); wait > 0 {
+			ctx.Response().Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(wait.Seconds())))))
+		}
+		return ctx.JSON(http.StatusConflict, map[string]any{"error": frozen.Error(), "changeWindow": frozen.Window, "frozenUntil": frozen.Until})
+	case errors.As(err, &quota):
+		if quota.RetryAfter > 0 {
+			ctx.Response().Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(quota.RetryAfter.Seconds())))))
+		}
+		return ctx.JSON(http.StatusTooManyRequests, map[string]string{"error": quota.Error()})
+	case errors.As(err, &commands.ErrPolicyUnavailable{}):
+		return ctx.JSON(http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+	}
+	c.logger.Error(message, "release", ctx.Param("name"), "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
@@ -0,0 +1,81 @@
+package iac
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/iac"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+type OwnershipDetector interface {
+	Detect(ctx context.Context, config, cluster string, ref iac.ResourceRef) (iac.Ownership, error)
+}
+
+type OwnershipController struct {
+	detector OwnershipDetector
+	logger   *log.Logger
+}
+
+type SuggestionRequest struct {
+	Resource iac.ResourceRef `json:"resource"`
+	Change   iac.Change      `json:"change"`
+}
+
+type SuggestionResponse struct {
+	Ownership  iac.Ownership `json:"ownership"`
+	Suggestion string        `json:"suggestion,omitempty"`
+}
+
+func NewOwnershipController(detector OwnershipDetector, logger *log.Logger) *OwnershipController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &OwnershipController{detector: detector, logger: logger}
+}
+
+func (c *OwnershipController) Ownership(ctx echo.Context) error {
+	ref := iac.ResourceRef{
+		Kind:      strings.TrimSpace(ctx.QueryParam("kind")),
+		Namespace: strings.TrimSpace(ctx.QueryParam("namespace")),
+		Name:      strings.TrimSpace(ctx.QueryParam("name")),
+	}
+	if ref.Kind == "" || ref.Name == "" {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "kind and name are required"})
+	}
+	ownership, err := c.detect(ctx, ref)
+	if err != nil {
+		return c.respondError(ctx, ref, err)
+	}
+	return ctx.JSON(http.StatusOK, ownership)
+}
+
+func (c *OwnershipController) Suggest(ctx echo.Context) error {
+	var req SuggestionRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	if req.Resource.Kind == "" || req.Resource.Name == "" || req.Change.Operation == "" {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "resource kind, name and change operation are required"})
+	}
+	ownership, err := c.detect(ctx, req.Resource)
+	if err != nil {
+		return c.respondError(ctx, req.Resource, err)
+	}
+	return ctx.JSON(http.StatusOK, SuggestionResponse{Ownership: ownership, Suggestion: iac.Suggest(ownership, req.Change)})
+}
+
+func (c *OwnershipController) detect(ctx echo.Context, ref iac.ResourceRef) (iac.Ownership, error) {
+	return c.detector.Detect(ctx.Request().Context(), ctx.QueryParam("config"), ctx.QueryParam("cluster"), ref)
+}
+
+func (c *OwnershipController) respondError(ctx echo.Context, ref iac.ResourceRef, err error) error {
+	if apierrors.IsNotFound(err) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "resource not found"})
+	}
+	c.logger.Error("failed to detect infrastructure-as-code ownership", "kind", ref.Kind, "namespace", ref.Namespace, "name", ref.Name, "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to detect ownership"})
+}
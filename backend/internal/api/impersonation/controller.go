@@ -0,0 +1,148 @@
+package impersonation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+	"github.com/pramodksahoo/kubechat/backend/internal/impersonation"
+	"github.com/pramodksahoo/kubechat/backend/internal/roles"
+)
+
+type SessionService interface {
+	Request(ctx context.Context, actor string, in impersonation.RequestInput) (impersonation.Session, error)
+	Consent(ctx context.Context, id, user string) (impersonation.Session, error)
+	Deny(ctx context.Context, id, user string) (impersonation.Session, error)
+	End(ctx context.Context, id, user string) (impersonation.Session, error)
+	Sessions(ctx context.Context, user string) ([]impersonation.Session, error)
+	AllSessions(ctx context.Context) ([]impersonation.Session, error)
+	Audit(ctx context.Context, caller string, all bool, filter impersonation.AuditFilter) ([]impersonation.AuditEntry, error)
+}
+
+// Authorizer reports whether a user holds a KubeChat permission.
+type Authorizer interface {
+	Authorize(ctx context.Context, user string, groups []string, permission roles.Permission, namespace string) error
+}
+
+type ImpersonationController struct {
+	service    SessionService
+	authorizer Authorizer
+	logger     *log.Logger
+}
+
+func NewImpersonationController(service SessionService, logger *log.Logger) *ImpersonationController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &ImpersonationController{service: service, logger: logger}
+}
+
+// SetAuthorizer lets callers holding roles:manage read the whole audit log; without it everyone
+// only sees the entries naming them.
+func (c *ImpersonationController) SetAuthorizer(authorizer Authorizer) {
+	c.authorizer = authorizer
+}
+
+// Request asks to impersonate a user. The session waits for the user's consent unless the admin breaks glass.
+func (c *ImpersonationController) Request(ctx echo.Context) error {
+	var req impersonation.RequestInput
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	session, err := c.service.Request(ctx.Request().Context(), identity.User(ctx), req)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to request impersonation")
+	}
+	c.logger.Info("impersonation requested", "session", session.ID, "actor", session.Actor, "subject", session.Subject, "mode", session.Mode)
+	return ctx.JSON(http.StatusCreated, session)
+}
+
+// ListAll lists every session for an admin.
+func (c *ImpersonationController) ListAll(ctx echo.Context) error {
+	sessions, err := c.service.AllSessions(ctx.Request().Context())
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list impersonation sessions")
+	}
+	return ctx.JSON(http.StatusOK, map[string][]impersonation.Session{"sessions": sessions})
+}
+
+// List returns the sessions the caller requested or was asked to consent to.
+func (c *ImpersonationController) List(ctx echo.Context) error {
+	sessions, err := c.service.Sessions(ctx.Request().Context(), identity.User(ctx))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list impersonation sessions")
+	}
+	return ctx.JSON(http.StatusOK, map[string][]impersonation.Session{"sessions": sessions})
+}
+
+func (c *ImpersonationController) Consent(ctx echo.Context) error {
+	session, err := c.service.Consent(ctx.Request().Context(), ctx.Param("id"), identity.User(ctx))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to consent to impersonation")
+	}
+	c.logger.Info("impersonation consented", "session", session.ID, "actor", session.Actor, "subject", session.Subject)
+	return ctx.JSON(http.StatusOK, session)
+}
+
+func (c *ImpersonationController) Deny(ctx echo.Context) error {
+	session, err := c.service.Deny(ctx.Request().Context(), ctx.Param("id"), identity.User(ctx))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to deny impersonation")
+	}
+	return ctx.JSON(http.StatusOK, session)
+}
+
+// End closes a session; the admin and the impersonated user can both end it.
+func (c *ImpersonationController) End(ctx echo.Context) error {
+	session, err := c.service.End(ctx.Request().Context(), ctx.Param("id"), identity.User(ctx))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to end impersonation")
+	}
+	c.logger.Info("impersonation ended", "session", session.ID, "actor", session.Actor, "subject", session.Subject, "ended_by", session.EndedBy)
+	return ctx.JSON(http.StatusOK, session)
+}
+
+// Audit returns the audit log, filtered by ?session= and ?user=. Users without roles:manage only
+// see entries that name them.
+func (c *ImpersonationController) Audit(ctx echo.Context) error {
+	filter := impersonation.AuditFilter{SessionID: ctx.QueryParam("session"), User: ctx.QueryParam("user"), Limit: 200}
+	if raw := ctx.QueryParam("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > 1000 {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "limit must be between 1 and 1000"})
+		}
+		filter.Limit = limit
+	}
+	user := identity.User(ctx)
+	all := c.authorizer != nil && c.authorizer.Authorize(ctx.Request().Context(), user, identity.Groups(ctx), roles.PermissionRolesManage, "") == nil
+	entries, err := c.service.Audit(ctx.Request().Context(), user, all, filter)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to load impersonation audit log")
+	}
+	return ctx.JSON(http.StatusOK, map[string][]impersonation.AuditEntry{"entries": entries})
+}
+
+func (c *ImpersonationController) respondError(ctx echo.Context, err error, message string) error {
+	var notFound impersonation.ErrSessionNotFound
+	if errors.As(err, &notFound) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": notFound.Error()})
+	}
+	var invalid impersonation.ErrInvalidRequest
+	if errors.As(err, &invalid) {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": invalid.Error()})
+	}
+	var forbidden impersonation.ErrForbidden
+	if errors.As(err, &forbidden) {
+		return ctx.JSON(http.StatusForbidden, map[string]string{"error": forbidden.Error()})
+	}
+	var state impersonation.ErrSessionState
+	if errors.As(err, &state) {
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": state.Error()})
+	}
+	c.logger.Error(message, "session", ctx.Param("id"), "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
@@ -0,0 +1,50 @@
+package logging
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+	"github.com/pramodksahoo/kubechat/backend/internal/logging"
+)
+
+// LevelRequest sets a module's log level: debug, info, warn, error or fatal.
+type LevelRequest struct {
+	Level string `json:"level"`
+}
+
+type LevelsController struct {
+	logger *log.Logger
+}
+
+func NewLevelsController(logger *log.Logger) *LevelsController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &LevelsController{logger: logger}
+}
+
+// List returns the level of every module logger and of the default logger.
+func (c *LevelsController) List(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, map[string]map[string]string{"levels": logging.Levels()})
+}
+
+// Set changes one module's level until the server restarts.
+func (c *LevelsController) Set(ctx echo.Context) error {
+	var req LevelRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	module := ctx.Param("module")
+	if err := logging.SetLevel(module, req.Level); err != nil {
+		var unknown logging.ErrUnknownModule
+		if errors.As(err, &unknown) {
+			return ctx.JSON(http.StatusNotFound, map[string]string{"error": unknown.Error()})
+		}
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	logging.FromContext(ctx.Request().Context(), c.logger).Info("log level changed", "target", module, "level", req.Level, "by", identity.User(ctx))
+	return ctx.JSON(http.StatusOK, map[string]map[string]string{"levels": logging.Levels()})
+}
@@ -0,0 +1,62 @@
+package logs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/logs"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+type Searcher interface {
+	Search(ctx context.Context, config, cluster string, query logs.Query) (logs.Result, error)
+}
+
+// SearchController answers filtered log queries such as
+// "level:error since:10m selector:app=payments -healthz".
+type SearchController struct {
+	searcher Searcher
+	logger   *log.Logger
+}
+
+func NewSearchController(searcher Searcher, logger *log.Logger) *SearchController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &SearchController{searcher: searcher, logger: logger}
+}
+
+func (c *SearchController) Handle(ctx echo.Context) error {
+	query, err := logs.ParseQuery(ctx.QueryParam("q"))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	// Explicit parameters take precedence over the same keys inside q.
+	if namespace := strings.TrimSpace(ctx.QueryParam("namespace")); namespace != "" {
+		query.Namespace = namespace
+	}
+	if pod := strings.TrimSpace(ctx.QueryParam("pod")); pod != "" {
+		query.Pods = []string{pod}
+	}
+	if selector := strings.TrimSpace(ctx.QueryParam("selector")); selector != "" {
+		query.Selector = selector
+	}
+	if query.Namespace == "" || (len(query.Pods) == 0 && query.Selector == "") {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "namespace and a pod or selector are required"})
+	}
+
+	result, err := c.searcher.Search(ctx.Request().Context(), ctx.QueryParam("config"), ctx.QueryParam("cluster"), query)
+	switch {
+	case err == nil:
+		return ctx.JSON(http.StatusOK, result)
+	case errors.Is(err, logs.ErrNoPods), apierrors.IsNotFound(err):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	default:
+		c.logger.Error("failed to search logs", "namespace", query.Namespace, "error", err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to search logs"})
+	}
+}
@@ -0,0 +1,275 @@
+package logs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/logs"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	// heartbeatInterval is how often idle streams are kept alive and dropped lines are reported.
+	heartbeatInterval = 15 * time.Second
+	// writeTimeout disconnects WebSocket clients that stop reading.
+	writeTimeout = 10 * time.Second
+)
+
+type Follower interface {
+	Follow(ctx context.Context, config, cluster string, query logs.Query, opts logs.FollowOptions) (*logs.Follow, error)
+}
+
+// Dropped reports lines skipped because the client read slower than the pods wrote.
+type Dropped struct {
+	Dropped int64 `json:"dropped"`
+}
+
+// End is the last event of a stream, sent once every container stream has ended.
+type End struct {
+	Error string `json:"error,omitempty"`
+}
+
+// SocketMessage is one WebSocket frame: Type is "log", "dropped" or "end".
+type SocketMessage struct {
+	Type    string      `json:"type"`
+	Entry   *logs.Entry `json:"entry,omitempty"`
+	Dropped int64       `json:"dropped,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// FollowController streams pod logs as they are written, over server-sent events or a WebSocket.
+type FollowController struct {
+	follower Follower
+	upgrader *websocket.Upgrader
+	logger   *log.Logger
+	sockets  ConnectionGauge
+}
+
+func NewFollowController(follower Follower, upgrader *websocket.Upgrader, logger *log.Logger) *FollowController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if upgrader == nil {
+		upgrader = &websocket.Upgrader{}
+	}
+	return &FollowController{follower: follower, upgrader: upgrader, logger: logger}
+}
+
+// ConnectionGauge counts open connections, such as a Prometheus gauge.
+type ConnectionGauge interface {
+	Inc()
+	Dec()
+}
+
+// SetConnections counts open log sockets in gauge.
+func (c *FollowController) SetConnections(gauge ConnectionGauge) {
+	c.sockets = gauge
+}
+
+// Stream follows logs as server-sent events: "log" for each line, "dropped" when lines were
+// skipped for a slow client, and "end" when the pods stopped writing.
+func (c *FollowController) Stream(ctx echo.Context) error {
+	follow, err := c.open(ctx)
+	if err != nil {
+		return c.respondError(ctx, err)
+	}
+	defer follow.Close()
+
+	res := ctx.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	send := func(event string, payload any) error {
+		if payload == nil {
+			_, err := fmt.Fprint(res, ": keep-alive\n\n")
+			res.Flush()
+			return err
+		}
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(res, "event: %s\ndata: %s\n\n", event, data); err != nil {
+			return err
+		}
+		res.Flush()
+		return nil
+	}
+	c.pump(ctx.Request().Context(), follow, func(entry logs.Entry) error {
+		return send("log", entry)
+	}, func(dropped int64) error {
+		if dropped == 0 {
+			return send("", nil)
+		}
+		return send("dropped", Dropped{Dropped: dropped})
+	}, func(end End) error {
+		return send("end", end)
+	})
+	return nil
+}
+
+// Socket follows logs over a WebSocket with the same query parameters as Stream. Closing the
+// socket stops the follow.
+func (c *FollowController) Socket(ctx echo.Context) error {
+	follow, err := c.open(ctx)
+	if err != nil {
+		return c.respondError(ctx, err)
+	}
+	defer follow.Close()
+
+	conn, err := c.upgrader.Upgrade(ctx.Response(), ctx.Request(), nil)
+	if err != nil {
+		// The upgrader already wrote the error response.
+		c.logger.Warn("failed to upgrade log stream", "error", err)
+		return nil
+	}
+	defer conn.Close()
+	if c.sockets != nil {
+		c.sockets.Inc()
+		defer c.sockets.Dec()
+	}
+
+	streamCtx, cancel := context.WithCancel(ctx.Request().Context())
+	defer cancel()
+	go func() {
+		// Clients only send close frames; reading surfaces them.
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(message SocketMessage) error {
+		_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		return conn.WriteJSON(message)
+	}
+	c.pump(streamCtx, follow, func(entry logs.Entry) error {
+		return send(SocketMessage{Type: "log", Entry: &entry})
+	}, func(dropped int64) error {
+		if dropped == 0 {
+			_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			return conn.WriteMessage(websocket.PingMessage, nil)
+		}
+		return send(SocketMessage{Type: "dropped", Dropped: dropped})
+	}, func(end End) error {
+		if err := send(SocketMessage{Type: "end", Error: end.Error}); err != nil {
+			return err
+		}
+		return conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	})
+	return nil
+}
+
+// pump forwards lines until the follow ends, the client leaves or a write fails. Dropped lines
+// are reported before the next line and on every heartbeat.
+func (c *FollowController) pump(ctx context.Context, follow *logs.Follow, onEntry func(logs.Entry) error, onHeartbeat func(dropped int64) error, onEnd func(End) error) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := onHeartbeat(follow.Dropped()); err != nil {
+				return
+			}
+		case entry, ok := <-follow.Entries():
+			if !ok {
+				end := End{}
+				if err := follow.Err(); err != nil {
+					end.Error = err.Error()
+				}
+				_ = onEnd(end)
+				return
+			}
+			if dropped := follow.Dropped(); dropped > 0 {
+				if err := onHeartbeat(dropped); err != nil {
+					return
+				}
+			}
+			if err := onEntry(entry); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// open parses the follow parameters: q takes the log query DSL and namespace, pod, selector and
+// container override it; since is a duration such as 10m or an RFC 3339 time; tail is the number
+// of existing lines per container to start with when since is not set.
+func (c *FollowController) open(ctx echo.Context) (*logs.Follow, error) {
+	query, err := logs.ParseQuery(ctx.QueryParam("q"))
+	if err != nil {
+		return nil, invalidParam(err.Error())
+	}
+	if namespace := strings.TrimSpace(ctx.QueryParam("namespace")); namespace != "" {
+		query.Namespace = namespace
+	}
+	if pod := strings.TrimSpace(ctx.QueryParam("pod")); pod != "" {
+		query.Pods = []string{pod}
+	}
+	if selector := strings.TrimSpace(ctx.QueryParam("selector")); selector != "" {
+		query.Selector = selector
+	}
+	if containers := strings.TrimSpace(ctx.QueryParam("container")); containers != "" {
+		query.Containers = strings.Split(containers, ",")
+	}
+	if query.Namespace == "" || (len(query.Pods) == 0 && query.Selector == "") {
+		return nil, invalidParam("namespace and a pod or selector are required")
+	}
+	// A follow has no end, so until and limit from the DSL do not apply.
+	query.Until = nil
+
+	var opts logs.FollowOptions
+	if since := strings.TrimSpace(ctx.QueryParam("since")); since != "" {
+		if duration, err := time.ParseDuration(since); err == nil && duration > 0 {
+			start := time.Now().Add(-duration)
+			opts.SinceTime = &start
+		} else if start, err := time.Parse(time.RFC3339, since); err == nil {
+			opts.SinceTime = &start
+		} else {
+			return nil, invalidParam("since must be a positive duration such as 10m or an RFC 3339 time")
+		}
+	}
+	if tail := strings.TrimSpace(ctx.QueryParam("tail")); tail != "" {
+		lines, err := strconv.ParseInt(tail, 10, 64)
+		if err != nil || lines <= 0 {
+			return nil, invalidParam("tail must be a positive number")
+		}
+		opts.TailLines = lines
+	}
+	return c.follower.Follow(ctx.Request().Context(), ctx.QueryParam("config"), ctx.QueryParam("cluster"), query, opts)
+}
+
+type invalidParam string
+
+func (e invalidParam) Error() string { return string(e) }
+
+func (c *FollowController) respondError(ctx echo.Context, err error) error {
+	var invalid invalidParam
+	switch {
+	case errors.As(err, &invalid):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": invalid.Error()})
+	case errors.Is(err, logs.ErrNoPods), apierrors.IsNotFound(err):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, logs.ErrTooManyFollowers):
+		return ctx.JSON(http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+	default:
+		c.logger.Error("failed to follow logs", "error", err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to follow logs"})
+	}
+}
@@ -0,0 +1,145 @@
+package mfa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+	"github.com/pramodksahoo/kubechat/backend/internal/mfa"
+)
+
+type MFAService interface {
+	Enroll(ctx context.Context, user string) (mfa.Secret, error)
+	Confirm(ctx context.Context, user, code string) (mfa.Enrollment, error)
+	Verify(ctx context.Context, user, code string) (mfa.Assertion, error)
+	Remove(ctx context.Context, actor, user string) error
+	Status(ctx context.Context, user string) (mfa.Status, error)
+	Audit(ctx context.Context, filter mfa.AuditFilter) ([]mfa.AuditEntry, error)
+}
+
+// MFAController manages the caller's own second factor. It acts for the person making the
+// request, so an admin impersonating a user can neither see nor change the user's enrollment.
+type MFAController struct {
+	service MFAService
+	logger  *log.Logger
+}
+
+// CodeRequest carries a code from the caller's authenticator app.
+type CodeRequest struct {
+	Code string `json:"code"`
+}
+
+func NewMFAController(service MFAService, logger *log.Logger) *MFAController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &MFAController{service: service, logger: logger}
+}
+
+func (c *MFAController) Status(ctx echo.Context) error {
+	status, err := c.service.Status(ctx.Request().Context(), identity.Actor(ctx))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to load MFA status")
+	}
+	return ctx.JSON(http.StatusOK, status)
+}
+
+// Enroll returns a new TOTP secret; it is shown once and must be confirmed with a code.
+func (c *MFAController) Enroll(ctx echo.Context) error {
+	secret, err := c.service.Enroll(ctx.Request().Context(), identity.Actor(ctx))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to enroll authenticator")
+	}
+	return ctx.JSON(http.StatusCreated, secret)
+}
+
+func (c *MFAController) Confirm(ctx echo.Context) error {
+	var req CodeRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	enrollment, err := c.service.Confirm(ctx.Request().Context(), identity.Actor(ctx), req.Code)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to confirm authenticator")
+	}
+	return ctx.JSON(http.StatusOK, enrollment)
+}
+
+// Verify checks a code and returns the step-up assertion it grants.
+func (c *MFAController) Verify(ctx echo.Context) error {
+	var req CodeRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	assertion, err := c.service.Verify(ctx.Request().Context(), identity.Actor(ctx), req.Code)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to verify MFA code")
+	}
+	return ctx.JSON(http.StatusOK, assertion)
+}
+
+func (c *MFAController) Remove(ctx echo.Context) error {
+	actor := identity.Actor(ctx)
+	if err := c.service.Remove(ctx.Request().Context(), actor, actor); err != nil {
+		return c.respondError(ctx, err, "failed to remove authenticator")
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// Audit returns the caller's MFA audit entries.
+func (c *MFAController) Audit(ctx echo.Context) error {
+	return c.audit(ctx, identity.Actor(ctx))
+}
+
+// Reset removes a user's enrollment for an admin, such as after a lost device.
+func (c *MFAController) Reset(ctx echo.Context) error {
+	if err := c.service.Remove(ctx.Request().Context(), identity.Actor(ctx), ctx.Param("user")); err != nil {
+		return c.respondError(ctx, err, "failed to reset authenticator")
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// AdminAudit returns the MFA audit log, optionally narrowed with ?user=.
+func (c *MFAController) AdminAudit(ctx echo.Context) error {
+	return c.audit(ctx, ctx.QueryParam("user"))
+}
+
+func (c *MFAController) audit(ctx echo.Context, user string) error {
+	filter := mfa.AuditFilter{User: user, Limit: 100}
+	if raw := ctx.QueryParam("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "limit must be a positive number"})
+		}
+		filter.Limit = min(limit, 1000)
+	}
+	entries, err := c.service.Audit(ctx.Request().Context(), filter)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to load MFA audit log")
+	}
+	return ctx.JSON(http.StatusOK, map[string][]mfa.AuditEntry{"entries": entries})
+}
+
+func (c *MFAController) respondError(ctx echo.Context, err error, message string) error {
+	var (
+		notEnrolled mfa.ErrNotEnrolled
+		invalid     mfa.ErrInvalidRequest
+		stepUp      mfa.ErrStepUpRequired
+	)
+	switch {
+	case errors.As(err, &notEnrolled):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.As(err, &invalid):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.As(err, &mfa.ErrInvalidCode{}):
+		return ctx.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	case errors.As(err, &stepUp):
+		return ctx.JSON(http.StatusForbidden, map[string]any{"error": err.Error(), "stepUpRequired": true, "enrolled": stepUp.Enrolled})
+	}
+	c.logger.Error(message, "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
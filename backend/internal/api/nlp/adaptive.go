@@ -0,0 +1,26 @@
+package nlp
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/ai"
+)
+
+type AdaptiveRouting interface {
+	Adaptive() ai.AdaptiveStatus
+}
+
+type AdaptiveController struct {
+	routing AdaptiveRouting
+}
+
+func NewAdaptiveController(routing AdaptiveRouting) *AdaptiveController {
+	return &AdaptiveController{routing: routing}
+}
+
+// Handle returns the live weights of the shared providers, in the order they are tried, with the
+// latency, error rate and cost behind each.
+func (c *AdaptiveController) Handle(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, c.routing.Adaptive())
+}
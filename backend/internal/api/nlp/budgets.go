@@ -0,0 +1,73 @@
+package nlp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/costs"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+)
+
+type BudgetManager interface {
+	List(ctx context.Context) ([]costs.BudgetStatus, error)
+	Save(ctx context.Context, actor, name string, req costs.BudgetRequest) (costs.Budget, error)
+	Delete(ctx context.Context, name string) error
+}
+
+type BudgetController struct {
+	budgets BudgetManager
+	logger  *log.Logger
+}
+
+func NewBudgetController(budgets BudgetManager, logger *log.Logger) *BudgetController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &BudgetController{budgets: budgets, logger: logger}
+}
+
+// List returns every budget with what was spent against it this month.
+func (c *BudgetController) List(ctx echo.Context) error {
+	budgets, err := c.budgets.List(ctx.Request().Context())
+	if err != nil {
+		return c.respondError(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]any{"budgets": budgets})
+}
+
+// Put creates or replaces a budget.
+func (c *BudgetController) Put(ctx echo.Context) error {
+	var req costs.BudgetRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	budget, err := c.budgets.Save(ctx.Request().Context(), identity.User(ctx), ctx.Param("name"), req)
+	if err != nil {
+		return c.respondError(ctx, err)
+	}
+	c.logger.Info("model budget saved", "budget", budget.Name, "soft_limit", budget.SoftLimit, "hard_limit", budget.HardLimit, "user", identity.User(ctx))
+	return ctx.JSON(http.StatusOK, budget)
+}
+
+func (c *BudgetController) Delete(ctx echo.Context) error {
+	if err := c.budgets.Delete(ctx.Request().Context(), ctx.Param("name")); err != nil {
+		return c.respondError(ctx, err)
+	}
+	c.logger.Info("model budget deleted", "budget", ctx.Param("name"), "user", identity.User(ctx))
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (c *BudgetController) respondError(ctx echo.Context, err error) error {
+	var invalid costs.ErrInvalidRequest
+	if errors.As(err, &invalid) {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": invalid.Error()})
+	}
+	if errors.As(err, &costs.ErrBudgetNotFound{}) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	c.logger.Error("model budget request failed", "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "model budget request failed"})
+}
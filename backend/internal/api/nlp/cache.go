@@ -0,0 +1,52 @@
+package nlp
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+	"github.com/pramodksahoo/kubechat/backend/internal/promptcache"
+)
+
+type CachePreferences interface {
+	Preference(ctx context.Context, user string) (promptcache.Preference, error)
+	SetPreference(ctx context.Context, user string, preference promptcache.Preference) (promptcache.Preference, error)
+}
+
+type CacheController struct {
+	preferences CachePreferences
+	logger      *log.Logger
+}
+
+func NewCacheController(preferences CachePreferences, logger *log.Logger) *CacheController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &CacheController{preferences: preferences, logger: logger}
+}
+
+// Get returns whether the caller's prompts are served from the prompt cache.
+func (c *CacheController) Get(ctx echo.Context) error {
+	preference, err := c.preferences.Preference(ctx.Request().Context(), identity.User(ctx))
+	if err != nil {
+		c.logger.Error("failed to read prompt cache preference", "user", identity.User(ctx), "error", err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to read prompt cache preference"})
+	}
+	return ctx.JSON(http.StatusOK, preference)
+}
+
+// Put opts the caller in to or out of the prompt cache.
+func (c *CacheController) Put(ctx echo.Context) error {
+	var req promptcache.Preference
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	preference, err := c.preferences.SetPreference(ctx.Request().Context(), identity.User(ctx), req)
+	if err != nil {
+		c.logger.Error("failed to save prompt cache preference", "user", identity.User(ctx), "error", err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to save prompt cache preference"})
+	}
+	return ctx.JSON(http.StatusOK, preference)
+}
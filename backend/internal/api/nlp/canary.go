@@ -0,0 +1,86 @@
+package nlp
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/ai"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+)
+
+type CanaryRollouts interface {
+	StartCanary(provider string, cfg ai.CanaryConfig) (ai.CanaryStatus, error)
+	Canary(provider string) (ai.CanaryStatus, error)
+	PromoteCanary(provider string) (ai.CanaryStatus, error)
+	AbortCanary(provider string) (ai.CanaryStatus, error)
+}
+
+type CanaryController struct {
+	rollouts CanaryRollouts
+	logger   *log.Logger
+}
+
+func NewCanaryController(rollouts CanaryRollouts, logger *log.Logger) *CanaryController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &CanaryController{rollouts: rollouts, logger: logger}
+}
+
+// Get compares the baseline and candidate models of the provider's rollout.
+func (c *CanaryController) Get(ctx echo.Context) error {
+	status, err := c.rollouts.Canary(ctx.Param("name"))
+	if err != nil {
+		return c.respondError(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, status)
+}
+
+// Put starts a rollout or changes the share of traffic its candidate receives.
+func (c *CanaryController) Put(ctx echo.Context) error {
+	var req ai.CanaryConfig
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	status, err := c.rollouts.StartCanary(ctx.Param("name"), req)
+	if err != nil {
+		return c.respondError(ctx, err)
+	}
+	c.logger.Info("model canary updated", "provider", status.Provider, "model", status.Candidate.Model, "percent", status.Percent, "user", identity.User(ctx))
+	return ctx.JSON(http.StatusOK, status)
+}
+
+// Promote makes the candidate the provider's default model and returns the final comparison.
+func (c *CanaryController) Promote(ctx echo.Context) error {
+	status, err := c.rollouts.PromoteCanary(ctx.Param("name"))
+	if err != nil {
+		return c.respondError(ctx, err)
+	}
+	c.logger.Info("model canary promoted", "provider", status.Provider, "model", status.Candidate.Model, "user", identity.User(ctx))
+	return ctx.JSON(http.StatusOK, status)
+}
+
+// Abort returns all traffic to the baseline model and returns the final comparison.
+func (c *CanaryController) Abort(ctx echo.Context) error {
+	status, err := c.rollouts.AbortCanary(ctx.Param("name"))
+	if err != nil {
+		return c.respondError(ctx, err)
+	}
+	c.logger.Info("model canary aborted", "provider", status.Provider, "model", status.Candidate.Model, "user", identity.User(ctx))
+	return ctx.JSON(http.StatusOK, status)
+}
+
+func (c *CanaryController) respondError(ctx echo.Context, err error) error {
+	var notFound ai.ErrProviderNotFound
+	if errors.As(err, &notFound) || errors.Is(err, ai.ErrNoCanary) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	var invalid ai.ErrInvalidCanary
+	if errors.As(err, &invalid) {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": invalid.Error()})
+	}
+	c.logger.Error("model canary request failed", "provider", ctx.Param("name"), "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "model canary request failed"})
+}
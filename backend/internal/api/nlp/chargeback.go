@@ -0,0 +1,127 @@
+package nlp
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/costs"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+)
+
+type ChargebackManager interface {
+	Rules() []costs.AllocationRule
+	SaveRule(ctx context.Context, actor, name string, req costs.AllocationRuleRequest) (costs.AllocationRule, error)
+	DeleteRule(ctx context.Context, name string) error
+	Reports(ctx context.Context) ([]costs.ChargebackSummary, error)
+	Report(ctx context.Context, year int, month time.Month) (costs.ChargebackReport, error)
+}
+
+type ChargebackController struct {
+	chargeback ChargebackManager
+	logger     *log.Logger
+}
+
+func NewChargebackController(chargeback ChargebackManager, logger *log.Logger) *ChargebackController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &ChargebackController{chargeback: chargeback, logger: logger}
+}
+
+// Rules returns the allocation rules in the order they are tried.
+func (c *ChargebackController) Rules(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, map[string]any{"rules": c.chargeback.Rules()})
+}
+
+// PutRule creates or replaces an allocation rule. Reports already generated keep their allocation.
+func (c *ChargebackController) PutRule(ctx echo.Context) error {
+	var req costs.AllocationRuleRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	rule, err := c.chargeback.SaveRule(ctx.Request().Context(), identity.User(ctx), ctx.Param("name"), req)
+	if err != nil {
+		return c.respondError(ctx, err)
+	}
+	c.logger.Info("allocation rule saved", "rule", rule.Name, "department", rule.Department, "project", rule.Project, "user", identity.User(ctx))
+	return ctx.JSON(http.StatusOK, rule)
+}
+
+func (c *ChargebackController) DeleteRule(ctx echo.Context) error {
+	if err := c.chargeback.DeleteRule(ctx.Request().Context(), ctx.Param("name")); err != nil {
+		return c.respondError(ctx, err)
+	}
+	c.logger.Info("allocation rule deleted", "rule", ctx.Param("name"), "user", identity.User(ctx))
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// Reports lists the monthly reports generated so far, newest first.
+func (c *ChargebackController) Reports(ctx echo.Context) error {
+	reports, err := c.chargeback.Reports(ctx.Request().Context())
+	if err != nil {
+		return c.respondError(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]any{"reports": reports})
+}
+
+// Report returns a month's chargeback grouped by the group_by query parameter, as JSON or, with
+// format=csv, as a CSV download.
+func (c *ChargebackController) Report(ctx echo.Context) error {
+	month, err := strconv.Atoi(ctx.Param("month"))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "month must be a number"})
+	}
+	year, err := strconv.Atoi(ctx.Param("year"))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "year must be a number"})
+	}
+	group := costs.ChargebackGroup(ctx.QueryParam("group_by"))
+	switch group {
+	case "", costs.GroupByDepartment, costs.GroupByProject, costs.GroupByUser:
+	default:
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "group_by must be department, project or user"})
+	}
+	format := ctx.QueryParam("format")
+	if format != "" && format != "json" && format != "csv" {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "format must be json or csv"})
+	}
+	report, err := c.chargeback.Report(ctx.Request().Context(), year, time.Month(month))
+	if err != nil {
+		return c.respondError(ctx, err)
+	}
+	report.Lines = report.GroupBy(group)
+	if format != "csv" {
+		return ctx.JSON(http.StatusOK, report)
+	}
+
+	response := ctx.Response()
+	response.Header().Set(echo.HeaderContentType, "text/csv")
+	response.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="chargeback-%04d-%02d.csv"`, report.Year, report.Month))
+	response.WriteHeader(http.StatusOK)
+	writer := csv.NewWriter(response)
+	writer.Write(costs.ChargebackColumns)
+	for _, line := range report.Lines {
+		writer.Write(line.Row(report.Currency))
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func (c *ChargebackController) respondError(ctx echo.Context, err error) error {
+	var invalid costs.ErrInvalidRequest
+	if errors.As(err, &invalid) {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": invalid.Error()})
+	}
+	if errors.As(err, &costs.ErrAllocationRuleNotFound{}) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	c.logger.Error("chargeback request failed", "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "chargeback request failed"})
+}
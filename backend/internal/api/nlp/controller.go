@@ -0,0 +1,25 @@
+package nlp
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/ai"
+)
+
+type ProviderLister interface {
+	Providers() []ai.ProviderInfo
+}
+
+type ProvidersController struct {
+	providers ProviderLister
+}
+
+func NewProvidersController(providers ProviderLister) *ProvidersController {
+	return &ProvidersController{providers: providers}
+}
+
+// Handle lists the configured model providers in fallback order with their token usage.
+func (c *ProvidersController) Handle(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, map[string][]ai.ProviderInfo{"providers": c.providers.Providers()})
+}
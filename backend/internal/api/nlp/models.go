@@ -0,0 +1,25 @@
+package nlp
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/ai"
+)
+
+type ModelCatalog interface {
+	Models() []ai.ProviderModels
+}
+
+type ModelsController struct {
+	catalog ModelCatalog
+}
+
+func NewModelsController(catalog ModelCatalog) *ModelsController {
+	return &ModelsController{catalog: catalog}
+}
+
+// Handle lists the models each provider serves with their capabilities, as last refreshed.
+func (c *ModelsController) Handle(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, map[string][]ai.ProviderModels{"providers": c.catalog.Models()})
+}
@@ -0,0 +1,75 @@
+package nlp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/costs"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+)
+
+type PriceBookManager interface {
+	Overview() costs.PriceBookOverview
+	Version(number int) (costs.PriceVersion, error)
+	Publish(ctx context.Context, actor string, req costs.PriceVersionRequest) (costs.PriceVersion, error)
+}
+
+type PriceBookController struct {
+	prices PriceBookManager
+	logger *log.Logger
+}
+
+func NewPriceBookController(prices PriceBookManager, logger *log.Logger) *PriceBookController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &PriceBookController{prices: prices, logger: logger}
+}
+
+// Get returns the currency, the prices in effect and every published version, newest first.
+func (c *PriceBookController) Get(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, c.prices.Overview())
+}
+
+// GetVersion returns one published version of the prices.
+func (c *PriceBookController) GetVersion(ctx echo.Context) error {
+	number, err := strconv.Atoi(ctx.Param("version"))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "version must be a number"})
+	}
+	version, err := c.prices.Version(number)
+	if errors.As(err, &costs.ErrPriceVersionNotFound{}) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return c.respondError(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, version)
+}
+
+// Publish adds a version of the prices, taking effect now or at its effectiveFrom.
+func (c *PriceBookController) Publish(ctx echo.Context) error {
+	var req costs.PriceVersionRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	version, err := c.prices.Publish(ctx.Request().Context(), identity.User(ctx), req)
+	if err != nil {
+		return c.respondError(ctx, err)
+	}
+	c.logger.Info("model prices published", "version", version.Version, "effective_from", version.EffectiveFrom, "user", identity.User(ctx))
+	return ctx.JSON(http.StatusCreated, version)
+}
+
+func (c *PriceBookController) respondError(ctx echo.Context, err error) error {
+	var invalid costs.ErrInvalidRequest
+	if errors.As(err, &invalid) {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": invalid.Error()})
+	}
+	c.logger.Error("price book request failed", "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "price book request failed"})
+}
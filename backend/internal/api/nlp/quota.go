@@ -0,0 +1,85 @@
+package nlp
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/ai"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+)
+
+type QuotaManager interface {
+	Balance(user string) ai.QuotaBalance
+	Overview() ai.QuotaOverview
+	SetDefault(policy ai.QuotaPolicy) error
+	SetUserPolicy(user string, policy ai.QuotaPolicy) error
+	RemoveUserPolicy(user string) bool
+}
+
+type QuotaController struct {
+	quota  QuotaManager
+	logger *log.Logger
+}
+
+func NewQuotaController(quota QuotaManager, logger *log.Logger) *QuotaController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &QuotaController{quota: quota, logger: logger}
+}
+
+// Get returns the caller's token balance and whether they are throttled to local models.
+func (c *QuotaController) Get(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, c.quota.Balance(identity.User(ctx)))
+}
+
+// Overview lists the quota policies and the balance of every user seen so far.
+func (c *QuotaController) Overview(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, c.quota.Overview())
+}
+
+// SetDefault changes the bucket size and refill rate of users without an override.
+func (c *QuotaController) SetDefault(ctx echo.Context) error {
+	var req ai.QuotaPolicy
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	if err := c.quota.SetDefault(req); err != nil {
+		return c.respondError(ctx, err)
+	}
+	c.logger.Info("default model quota updated", "capacity", req.Capacity, "refill_per_minute", req.RefillPerMinute, "user", identity.User(ctx))
+	return ctx.JSON(http.StatusOK, c.quota.Overview())
+}
+
+// SetUser overrides the default policy for one user.
+func (c *QuotaController) SetUser(ctx echo.Context) error {
+	var req ai.QuotaPolicy
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	user := ctx.Param("user")
+	if err := c.quota.SetUserPolicy(user, req); err != nil {
+		return c.respondError(ctx, err)
+	}
+	c.logger.Info("user model quota updated", "subject", user, "capacity", req.Capacity, "refill_per_minute", req.RefillPerMinute, "user", identity.User(ctx))
+	return ctx.JSON(http.StatusOK, c.quota.Balance(user))
+}
+
+// RemoveUser returns a user to the default policy.
+func (c *QuotaController) RemoveUser(ctx echo.Context) error {
+	if !c.quota.RemoveUserPolicy(ctx.Param("user")) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "user has no quota override"})
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (c *QuotaController) respondError(ctx echo.Context, err error) error {
+	var invalid ai.ErrInvalidQuota
+	if errors.As(err, &invalid) {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": invalid.Error()})
+	}
+	c.logger.Error("model quota request failed", "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "model quota request failed"})
+}
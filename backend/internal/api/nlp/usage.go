@@ -0,0 +1,97 @@
+package nlp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/ai"
+	"github.com/pramodksahoo/kubechat/backend/internal/costs"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+)
+
+type CostLedger interface {
+	Usage(ctx context.Context, filter ai.UsageFilter) ([]ai.UsageTotals, error)
+	Bill(ctx context.Context, year int, month time.Month) (costs.Bill, error)
+	Backfill(ctx context.Context, from, to string) (costs.Backfill, error)
+}
+
+type UsageController struct {
+	costs  CostLedger
+	logger *log.Logger
+}
+
+func NewUsageController(costs CostLedger, logger *log.Logger) *UsageController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &UsageController{costs: costs, logger: logger}
+}
+
+// BackfillRequest names the days, as YYYY-MM-DD, whose rollups are rebuilt.
+type BackfillRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Get returns the caller's model token usage per session and model, optionally for one session.
+func (c *UsageController) Get(ctx echo.Context) error {
+	return c.usage(ctx, ai.UsageFilter{User: identity.User(ctx), Session: ctx.QueryParam("session"), Model: ctx.QueryParam("model")})
+}
+
+// Overview returns the model token usage of every user, filtered by user, session or model.
+func (c *UsageController) Overview(ctx echo.Context) error {
+	return c.usage(ctx, ai.UsageFilter{User: ctx.QueryParam("user"), Session: ctx.QueryParam("session"), Model: ctx.QueryParam("model")})
+}
+
+// Bill returns a calendar month of model usage and cost per user and model.
+func (c *UsageController) Bill(ctx echo.Context) error {
+	month, err := strconv.Atoi(ctx.Param("month"))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "month must be a number"})
+	}
+	year, err := strconv.Atoi(ctx.Param("year"))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "year must be a number"})
+	}
+	bill, err := c.costs.Bill(ctx.Request().Context(), year, time.Month(month))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to build the model usage bill")
+	}
+	return ctx.JSON(http.StatusOK, bill)
+}
+
+// Backfill rebuilds the daily rollups of a range of days from the recorded usage.
+func (c *UsageController) Backfill(ctx echo.Context) error {
+	var req BackfillRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	result, err := c.costs.Backfill(ctx.Request().Context(), req.From, req.To)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to backfill model usage rollups")
+	}
+	c.logger.Info("model usage rollups backfilled", "from", req.From, "to", req.To, "days", result.Days, "user", identity.User(ctx))
+	return ctx.JSON(http.StatusOK, result)
+}
+
+func (c *UsageController) usage(ctx echo.Context, filter ai.UsageFilter) error {
+	usage, err := c.costs.Usage(ctx.Request().Context(), filter)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to load model usage")
+	}
+	return ctx.JSON(http.StatusOK, map[string]any{"usage": usage})
+}
+
+func (c *UsageController) respondError(ctx echo.Context, err error, message string) error {
+	var invalid costs.ErrInvalidRequest
+	if errors.As(err, &invalid) {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": invalid.Error()})
+	}
+	c.logger.Error(message, "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
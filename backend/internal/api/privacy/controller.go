@@ -0,0 +1,190 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+	"github.com/pramodksahoo/kubechat/backend/internal/privacy"
+	"github.com/pramodksahoo/kubechat/backend/internal/roles"
+)
+
+type SubjectRequests interface {
+	Export(ctx context.Context, actor, user string, w io.Writer) error
+	RequestErasure(ctx context.Context, actor, user, reason string) (privacy.Erasure, error)
+	Erasure(ctx context.Context, id string) (privacy.Erasure, error)
+	Erasures(ctx context.Context) ([]privacy.Erasure, error)
+	Approve(ctx context.Context, actor, id string) (privacy.Erasure, error)
+	Reject(ctx context.Context, actor, id, reason string) (privacy.Erasure, error)
+}
+
+// Authorizer checks whether the caller may act on another user's data.
+type Authorizer interface {
+	Authorize(ctx context.Context, user string, groups []string, permission roles.Permission, namespace string) error
+}
+
+type ErasureRequest struct {
+	// User defaults to the caller.
+	User   string `json:"user,omitempty"`
+	Reason string `json:"reason"`
+}
+
+type RejectRequest struct {
+	Reason string `json:"reason"`
+}
+
+// PrivacyController serves data subject requests. Users export and request erasure of their own
+// data; acting on another user's data requires the roles:manage permission.
+type PrivacyController struct {
+	requests   SubjectRequests
+	authorizer Authorizer
+	logger     *log.Logger
+}
+
+func NewPrivacyController(requests SubjectRequests, logger *log.Logger) *PrivacyController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &PrivacyController{requests: requests, logger: logger}
+}
+
+// SetAuthorizer lets users holding roles:manage act on other users' data; without an authorizer
+// users only reach their own.
+func (c *PrivacyController) SetAuthorizer(authorizer Authorizer) {
+	c.authorizer = authorizer
+}
+
+// Export downloads a zip archive of everything kept about the user named by the user query
+// parameter, the caller by default.
+func (c *PrivacyController) Export(ctx echo.Context) error {
+	user := ctx.QueryParam("user")
+	if user == "" {
+		user = identity.User(ctx)
+	}
+	if err := c.authorize(ctx, user); err != nil {
+		return c.respondError(ctx, err, "failed to check roles")
+	}
+	header := ctx.Response().Header()
+	header.Set(echo.HeaderContentType, "application/zip")
+	header.Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", "kubechat-personal-data.zip"))
+	if err := c.requests.Export(ctx.Request().Context(), identity.User(ctx), user, ctx.Response()); err != nil {
+		if ctx.Response().Committed {
+			c.logger.Error("personal data export interrupted", "error", err)
+			return nil
+		}
+		header.Del(echo.HeaderContentDisposition)
+		return c.respondError(ctx, err, "failed to export personal data")
+	}
+	return nil
+}
+
+func (c *PrivacyController) RequestErasure(ctx echo.Context) error {
+	var req ErasureRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	if req.User == "" {
+		req.User = identity.User(ctx)
+	}
+	if err := c.authorize(ctx, req.User); err != nil {
+		return c.respondError(ctx, err, "failed to check roles")
+	}
+	erasure, err := c.requests.RequestErasure(ctx.Request().Context(), identity.User(ctx), req.User, req.Reason)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to request erasure")
+	}
+	return ctx.JSON(http.StatusCreated, erasure)
+}
+
+// Erasures lists every erasure request to admins and the caller's own to everyone else.
+func (c *PrivacyController) Erasures(ctx echo.Context) error {
+	erasures, err := c.requests.Erasures(ctx.Request().Context())
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list erasure requests")
+	}
+	visible := make([]privacy.Erasure, 0, len(erasures))
+	for _, erasure := range erasures {
+		if c.visible(ctx, erasure) {
+			visible = append(visible, erasure)
+		}
+	}
+	return ctx.JSON(http.StatusOK, visible)
+}
+
+func (c *PrivacyController) Erasure(ctx echo.Context) error {
+	erasure, err := c.requests.Erasure(ctx.Request().Context(), ctx.Param("id"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to load erasure request")
+	}
+	if !c.visible(ctx, erasure) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": privacy.ErrErasureNotFound{ID: erasure.ID}.Error()})
+	}
+	return ctx.JSON(http.StatusOK, erasure)
+}
+
+// Approve erases the user's data; the route is limited to admins by the role rules.
+func (c *PrivacyController) Approve(ctx echo.Context) error {
+	erasure, err := c.requests.Approve(ctx.Request().Context(), identity.User(ctx), ctx.Param("id"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to approve erasure")
+	}
+	return ctx.JSON(http.StatusOK, erasure)
+}
+
+func (c *PrivacyController) Reject(ctx echo.Context) error {
+	var req RejectRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	erasure, err := c.requests.Reject(ctx.Request().Context(), identity.User(ctx), ctx.Param("id"), req.Reason)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to reject erasure")
+	}
+	return ctx.JSON(http.StatusOK, erasure)
+}
+
+// authorize lets the caller act on their own data, and on anyone's with roles:manage.
+func (c *PrivacyController) authorize(ctx echo.Context, user string) error {
+	caller := identity.User(ctx)
+	if user == caller {
+		return nil
+	}
+	if c.authorizer == nil {
+		return roles.ErrForbidden{User: caller, Permission: roles.PermissionRolesManage}
+	}
+	return c.authorizer.Authorize(ctx.Request().Context(), caller, identity.Groups(ctx), roles.PermissionRolesManage, "")
+}
+
+func (c *PrivacyController) visible(ctx echo.Context, erasure privacy.Erasure) bool {
+	caller := identity.User(ctx)
+	if erasure.RequestedBy == caller {
+		return true
+	}
+	return c.authorize(ctx, erasure.User) == nil
+}
+
+func (c *PrivacyController) respondError(ctx echo.Context, err error, message string) error {
+	var (
+		notFound  privacy.ErrErasureNotFound
+		state     privacy.ErrErasureState
+		invalid   privacy.ErrInvalidRequest
+		forbidden roles.ErrForbidden
+	)
+	switch {
+	case errors.As(err, &notFound):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.As(err, &state):
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.As(err, &invalid):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.As(err, &forbidden):
+		return ctx.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	}
+	c.logger.Error(message, "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
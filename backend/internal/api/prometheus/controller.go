@@ -0,0 +1,101 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/prometheus"
+)
+
+type Querier interface {
+	Query(ctx context.Context, query string, at time.Time) (prometheus.Result, error)
+	QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (prometheus.Result, error)
+}
+
+type Answerer interface {
+	Run(ctx context.Context, q prometheus.Question) (prometheus.Answer, error)
+}
+
+type QueryController struct {
+	querier  Querier
+	answerer Answerer
+	logger   *log.Logger
+	clock    func() time.Time
+}
+
+func NewQueryController(querier Querier, answerer Answerer, logger *log.Logger) *QueryController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &QueryController{querier: querier, answerer: answerer, logger: logger, clock: time.Now}
+}
+
+// Usage answers ?question=, such as "how much CPU is checkout using", in ?namespace=. A question
+// naming another namespace is refused, since access was checked for ?namespace= only.
+func (c *QueryController) Usage(ctx echo.Context) error {
+	namespace := ctx.QueryParam("namespace")
+	q, ok := prometheus.Translate(ctx.QueryParam("question"), namespace)
+	if !ok {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "the question does not ask about CPU or memory usage"})
+	}
+	if namespace != "" && (q.Namespace != namespace || q.Scope == prometheus.ScopeNode) {
+		return ctx.JSON(http.StatusForbidden, map[string]string{"error": fmt.Sprintf("the question is not limited to namespace %s", namespace)})
+	}
+	answer, err := c.answerer.Run(ctx.Request().Context(), q)
+	if err != nil {
+		return c.respondError(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, answer)
+}
+
+// Query runs the PromQL in ?query=, at the current time or, with ?range= such as 6h, over that
+// range up to now at ?step=, which is raised to keep within the point limit.
+func (c *QueryController) Query(ctx echo.Context) error {
+	query := ctx.QueryParam("query")
+	if query == "" {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "query is required"})
+	}
+	now := c.clock()
+	if ctx.QueryParam("range") == "" {
+		result, err := c.querier.Query(ctx.Request().Context(), query, now)
+		if err != nil {
+			return c.respondError(ctx, err)
+		}
+		return ctx.JSON(http.StatusOK, result)
+	}
+	span, err := time.ParseDuration(ctx.QueryParam("range"))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "range must be a duration such as 6h"})
+	}
+	var step time.Duration
+	if raw := ctx.QueryParam("step"); raw != "" {
+		if step, err = time.ParseDuration(raw); err != nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "step must be a duration such as 1m"})
+		}
+	}
+	result, err := c.querier.QueryRange(ctx.Request().Context(), query, now.Add(-span), now, step)
+	if err != nil {
+		return c.respondError(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, result)
+}
+
+func (c *QueryController) respondError(ctx echo.Context, err error) error {
+	var (
+		invalid prometheus.ErrInvalidRange
+		failed  prometheus.ErrQuery
+	)
+	switch {
+	case errors.As(err, &invalid):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.As(err, &failed) && failed.Type == "bad_data":
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	c.logger.Error("failed to query prometheus", "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to query prometheus"})
+}
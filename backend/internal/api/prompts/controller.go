@@ -3,22 +3,41 @@ package prompts
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/ai"
 	"github.com/pramodksahoo/kubechat/backend/internal/api/repository"
+	"github.com/pramodksahoo/kubechat/backend/internal/chat"
+	"github.com/pramodksahoo/kubechat/backend/internal/chatfiles"
+	"github.com/pramodksahoo/kubechat/backend/internal/diagnostics"
+	"github.com/pramodksahoo/kubechat/backend/internal/guardrail"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
 	"github.com/pramodksahoo/kubechat/backend/internal/plan"
+	"github.com/pramodksahoo/kubechat/backend/internal/prometheus"
 	"github.com/pramodksahoo/kubechat/backend/internal/telemetry"
 )
 
+// diagnoseTimeout bounds the playbooks run for a diagnostic prompt, which read logs and events
+// after the plan is generated.
+const diagnoseTimeout = 10 * time.Second
+
+// usageTimeout bounds the Prometheus queries run for a resource usage prompt.
+const usageTimeout = 10 * time.Second
+
 type PromptRequest struct {
 	Prompt        string            `json:"prompt"`
 	ClusterHint   string            `json:"clusterHint,omitempty"`
 	NamespaceHint string            `json:"namespaceHint,omitempty"`
 	Metadata      map[string]string `json:"metadata,omitempty"`
+	// Attachments are IDs of chat attachments the prompt refers to, such as a manifest to apply.
+	Attachments []string `json:"attachments,omitempty"`
+	// SessionID continues an earlier chat session; a new session is started without one.
+	SessionID string `json:"sessionId,omitempty"`
 }
 
 type ResponseMetrics struct {
@@ -32,21 +51,64 @@ type PromptResponse struct {
 	StoredAt  time.Time                 `json:"storedAt,omitempty"`
 	ExpiresAt time.Time                 `json:"expiresAt,omitempty"`
 	Revisions []repository.PlanRevision `json:"revisions,omitempty"`
+	SessionID string                    `json:"sessionId,omitempty"`
+	// CorrelationID links the prompt to the commands that carry the plan out; submit it with them.
+	CorrelationID string `json:"correlationId"`
+	// Cached is set when the plan was served from the prompt cache instead of being generated.
+	Cached bool `json:"cached,omitempty"`
+	// Diagnostics are the findings of the troubleshooting playbooks run for diagnostic prompts.
+	Diagnostics *diagnostics.Report `json:"diagnostics,omitempty"`
+	// Usage answers prompts about CPU or memory usage from Prometheus.
+	Usage *prometheus.Answer `json:"usage,omitempty"`
 }
 
 type PromptController struct {
-	builder plan.Builder
-	metrics telemetry.PlanMetricsRecorder
-	store   PlanStore
-	logger  *log.Logger
-	timeout time.Duration
-	clock   func() time.Time
+	builder  plan.Builder
+	metrics  telemetry.PlanMetricsRecorder
+	store    PlanStore
+	files    AttachmentResolver
+	sessions SessionStore
+	budgets  BudgetGate
+	doctor   Diagnostician
+	usage    UsageAnswerer
+	logger   *log.Logger
+	timeout  time.Duration
+	clock    func() time.Time
 }
 
 type PlanStore interface {
 	Save(ctx context.Context, draft plan.PlanDraft) (repository.PlanRecord, error)
 }
 
+// SessionStore keeps the conversations prompts belong to.
+type SessionStore interface {
+	Start(ctx context.Context, owner, title string) (chat.Session, error)
+	History(ctx context.Context, owner, id string) ([]chat.Message, error)
+	ActiveContext(ctx context.Context, owner, id string) (*chat.ActiveContext, error)
+	Record(ctx context.Context, owner, id string, messages ...chat.Message) (chat.Session, error)
+}
+
+// BudgetGate refuses prompts of users past a hard spending limit on model providers.
+type BudgetGate interface {
+	Blocked(ctx context.Context, user string) error
+}
+
+// Diagnostician runs the troubleshooting playbooks a diagnostic prompt asks about against the
+// plan's target, returning nil for prompts that are not diagnostic.
+type Diagnostician interface {
+	Diagnose(ctx context.Context, draft plan.PlanDraft) (*diagnostics.Report, error)
+}
+
+// UsageAnswerer answers a prompt about CPU or memory usage, returning nil for other prompts.
+type UsageAnswerer interface {
+	Answer(ctx context.Context, draft plan.PlanDraft) (*prometheus.Answer, error)
+}
+
+// AttachmentResolver loads the chat attachments a prompt references.
+type AttachmentResolver interface {
+	Resolve(ctx context.Context, owner string, ids []string) ([]plan.Attachment, error)
+}
+
 func NewPromptController(builder plan.Builder, metrics telemetry.PlanMetricsRecorder, store PlanStore, logger *log.Logger) *PromptController {
 	if logger == nil {
 		logger = log.Default()
@@ -61,6 +123,35 @@ func NewPromptController(builder plan.Builder, metrics telemetry.PlanMetricsReco
 	}
 }
 
+// SetAttachments lets prompts reference chat attachments.
+func (c *PromptController) SetAttachments(files AttachmentResolver) {
+	c.files = files
+}
+
+// SetSessions records prompts and plans as chat sessions and passes earlier messages of a
+// session and its active context to the plan builder.
+func (c *PromptController) SetSessions(sessions SessionStore) {
+	c.sessions = sessions
+}
+
+// SetBudgets refuses prompts with 402 Payment Required once a hard budget limit is reached,
+// before any plan is generated.
+func (c *PromptController) SetBudgets(budgets BudgetGate) {
+	c.budgets = budgets
+}
+
+// SetDiagnostics answers diagnostic prompts with the findings of the troubleshooting playbooks
+// alongside the plan. The plan is returned without them when they fail.
+func (c *PromptController) SetDiagnostics(doctor Diagnostician) {
+	c.doctor = doctor
+}
+
+// SetUsage answers prompts about CPU or memory usage with a table of the usage alongside the plan.
+// The plan is returned without it when Prometheus cannot be queried.
+func (c *PromptController) SetUsage(usage UsageAnswerer) {
+	c.usage = usage
+}
+
 func (c *PromptController) Handle(ctx echo.Context) error {
 	var req PromptRequest
 	if err := ctx.Bind(&req); err != nil {
@@ -101,11 +192,60 @@ func (c *PromptController) Handle(ctx echo.Context) error {
 	}
 
 	parentCtx := ctx.Request().Context()
-	childCtx, cancel := context.WithTimeout(parentCtx, c.timeout)
+	if c.budgets != nil {
+		if err := c.budgets.Blocked(parentCtx, identity.User(ctx)); err != nil {
+			return c.budgetExceeded(ctx, err)
+		}
+	}
+	if len(req.Attachments) > 0 {
+		if c.files == nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "chat attachments are not enabled"})
+		}
+		attached, err := c.files.Resolve(parentCtx, identity.User(ctx), req.Attachments)
+		if errors.As(err, &chatfiles.ErrFileNotFound{}) || errors.As(err, &chatfiles.ErrInvalidRequest{}) {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		if err != nil {
+			c.logger.Error("failed to load prompt attachments", "error", err, "request_id", requestID)
+			return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to load attachments"})
+		}
+		planInput.Attachments = attached
+	}
+	if req.SessionID != "" {
+		if c.sessions == nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "chat sessions are not enabled"})
+		}
+		if err := c.loadSession(parentCtx, identity.User(ctx), req.SessionID, &planInput); err != nil {
+			if errors.As(err, &chat.ErrSessionNotFound{}) {
+				return ctx.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+			}
+			c.logger.Error("failed to load chat session", "error", err, "session_id", req.SessionID, "request_id", requestID)
+			return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to load chat session"})
+		}
+	}
+
+	// New sessions are started before generating so that model usage is attributed to them.
+	sessionID := req.SessionID
+	if c.sessions != nil && sessionID == "" {
+		session, err := c.sessions.Start(parentCtx, identity.User(ctx), "")
+		if err != nil {
+			c.logger.Error("failed to start chat session", "error", err, "request_id", requestID)
+		}
+		sessionID = session.ID
+	}
+
+	childCtx, cancel := context.WithTimeout(ai.NewSessionContext(identity.NewContext(parentCtx, identity.User(ctx)), sessionID), c.timeout)
 	defer cancel()
 
 	start := c.clock()
 	draft, err := c.builder.BuildPlan(childCtx, planInput)
+	var rejected guardrail.ErrRejected
+	if errors.As(err, &rejected) {
+		return ctx.JSON(http.StatusUnprocessableEntity, map[string]any{"error": "request rejected by the NLP guardrail", "reasons": rejected.Reasons})
+	}
+	if errors.As(err, &ai.ErrBudgetExceeded{}) {
+		return c.budgetExceeded(ctx, err)
+	}
 	if err != nil {
 		status := http.StatusInternalServerError
 		message := "failed to generate plan"
@@ -132,10 +272,12 @@ func (c *PromptController) Handle(ctx echo.Context) error {
 
 	c.metrics.ObservePlanGeneration(duration, draft.TargetCluster, draft.TargetNamespace)
 
-	c.logger.Info("plan generated", "request_id", requestID, "plan_id", draft.ID, "cluster", draft.TargetCluster, "namespace", draft.TargetNamespace, "duration_ms", duration.Milliseconds(), "risk_level", draft.RiskSummary.Level)
+	c.logger.Info("plan generated", "request_id", requestID, "plan_id", draft.ID, "cluster", draft.TargetCluster, "namespace", draft.TargetNamespace, "duration_ms", duration.Milliseconds(), "risk_level", draft.RiskSummary.Level, "cached", draft.CachedAt != nil)
 
 	resp := PromptResponse{
-		Plan: draft,
+		Plan:          draft,
+		CorrelationID: draft.ID,
+		Cached:        draft.CachedAt != nil,
 		Metrics: ResponseMetrics{
 			GenerationDurationMs: duration.Milliseconds(),
 			CapturedAt:           c.clock(),
@@ -151,6 +293,94 @@ func (c *PromptController) Handle(ctx echo.Context) error {
 	if len(record.Revisions) > 0 {
 		resp.Revisions = record.Revisions
 	}
+	if c.doctor != nil {
+		diagnoseCtx, cancel := context.WithTimeout(parentCtx, diagnoseTimeout)
+		report, err := c.doctor.Diagnose(diagnoseCtx, draft)
+		cancel()
+		if err != nil {
+			c.logger.Warn("failed to run diagnostics", "error", err, "plan_id", draft.ID, "request_id", requestID)
+		}
+		resp.Diagnostics = report
+	}
+	if c.usage != nil {
+		usageCtx, cancel := context.WithTimeout(parentCtx, usageTimeout)
+		answer, err := c.usage.Answer(usageCtx, draft)
+		cancel()
+		if err != nil {
+			c.logger.Warn("failed to query resource usage", "error", err, "plan_id", draft.ID, "request_id", requestID)
+		}
+		resp.Usage = answer
+	}
+	if c.sessions != nil && sessionID != "" {
+		resp.SessionID = c.recordTurn(parentCtx, identity.User(ctx), sessionID, req.Prompt, draft, requestID)
+	}
 
 	return ctx.JSON(http.StatusCreated, resp)
 }
+
+func (c *PromptController) budgetExceeded(ctx echo.Context, err error) error {
+	var exceeded ai.ErrBudgetExceeded
+	if !errors.As(err, &exceeded) {
+		return ctx.JSON(http.StatusPaymentRequired, map[string]string{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusPaymentRequired, map[string]any{
+		"error":    exceeded.Error(),
+		"budget":   exceeded.Budget,
+		"spent":    exceeded.Spent,
+		"limit":    exceeded.Limit,
+		"currency": exceeded.Currency,
+	})
+}
+
+// loadSession passes the session's recent messages and active context to the plan builder.
+func (c *PromptController) loadSession(ctx context.Context, user, sessionID string, input *plan.BuildInput) error {
+	history, err := c.sessions.History(ctx, user, sessionID)
+	if err != nil {
+		return err
+	}
+	for _, message := range history {
+		input.History = append(input.History, plan.Turn{Role: string(message.Role), Content: message.Content, Cluster: message.Cluster, Namespace: message.Namespace})
+	}
+	active, err := c.sessions.ActiveContext(ctx, user, sessionID)
+	if err != nil {
+		return err
+	}
+	if active != nil {
+		input.Conversation = &plan.ConversationContext{Cluster: active.Cluster, Namespace: active.Namespace, Resources: active.Resources}
+	}
+	return nil
+}
+
+// recordTurn stores the prompt and a summary of its plan in the chat session. The plan is returned
+// even when this fails.
+func (c *PromptController) recordTurn(ctx context.Context, user, sessionID, prompt string, draft plan.PlanDraft, requestID string) string {
+	_, err := c.sessions.Record(ctx, user, sessionID,
+		chat.Message{Role: chat.RoleUser, Content: prompt, CorrelationID: draft.ID},
+		chat.Message{
+			Role:          chat.RoleAssistant,
+			Content:       planSummary(draft),
+			PlanID:        draft.ID,
+			Cluster:       draft.TargetCluster,
+			Namespace:     draft.TargetNamespace,
+			Resources:     draft.ReferencedResources(),
+			CorrelationID: draft.ID,
+		},
+	)
+	if err != nil {
+		c.logger.Error("failed to record chat session", "error", err, "session_id", sessionID, "request_id", requestID)
+	}
+	return sessionID
+}
+
+// planSummary describes a plan in the words stored as the assistant's reply.
+func planSummary(draft plan.PlanDraft) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Plan for cluster %s, namespace %s (risk %s):", draft.TargetCluster, draft.TargetNamespace, draft.RiskSummary.Level)
+	for _, step := range draft.Steps {
+		fmt.Fprintf(&b, "\n%d. %s", step.Sequence, step.Title)
+		if step.Command != "" {
+			fmt.Fprintf(&b, ": %s", step.Command)
+		}
+	}
+	return b.String()
+}
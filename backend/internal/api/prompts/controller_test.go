@@ -13,6 +13,8 @@ import (
 
 	"github.com/charmbracelet/log"
 	"github.com/pramodksahoo/kubechat/backend/internal/api/repository"
+	"github.com/pramodksahoo/kubechat/backend/internal/chat"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
 	"github.com/pramodksahoo/kubechat/backend/internal/plan"
 	"github.com/pramodksahoo/kubechat/backend/internal/telemetry"
 	"github.com/labstack/echo/v4"
@@ -23,10 +25,12 @@ type fakeBuilder struct {
 	plan    plan.PlanDraft
 	err     error
 	invoked bool
+	input   plan.BuildInput
 }
 
 func (f *fakeBuilder) BuildPlan(ctx context.Context, input plan.BuildInput) (plan.PlanDraft, error) {
 	f.invoked = true
+	f.input = input
 	return f.plan, f.err
 }
 
@@ -162,3 +166,45 @@ func TestPromptControllerHandlesPersistenceError(t *testing.T) {
 		t.Fatalf("expected status 500, got %d", rec.Code)
 	}
 }
+
+func TestPromptControllerRecordsChatSessions(t *testing.T) {
+	builder := &fakeBuilder{plan: plan.PlanDraft{ID: "plan-1", TargetCluster: "prod", TargetNamespace: "payments", Steps: []plan.PlanStep{{Sequence: 1, Title: "Restart api", Command: "kubectl rollout restart deployment/api", Target: plan.TargetDescriptor{Resource: "deployments/api"}}}}}
+	logger := log.NewWithOptions(io.Discard, log.Options{})
+	sessions := chat.NewService(chat.NewMemoryRepository(), chat.Options{}, logger)
+	controller := NewPromptController(builder, telemetry.NewPlanMetrics(prometheus.NewRegistry()), nil, logger)
+	controller.SetSessions(sessions)
+
+	send := func(payload PromptRequest) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(payload)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/prompts", bytes.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(identity.Header, "alice")
+		rec := httptest.NewRecorder()
+		if err := controller.Handle(echo.New().NewContext(req, rec)); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		return rec
+	}
+
+	rec := send(PromptRequest{Prompt: "Restart the api in payments"})
+	var first PromptResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &first); err != nil || first.SessionID == "" {
+		t.Fatalf("expected a new session to be started, got %s", rec.Body.String())
+	}
+
+	send(PromptRequest{Prompt: "now the worker", SessionID: first.SessionID})
+	if history := builder.input.History; len(history) != 2 || history[1].Cluster != "prod" || history[1].Namespace != "payments" {
+		t.Fatalf("expected the follow-up to carry the earlier turns, got %+v", history)
+	}
+	if carried := builder.input.Conversation; carried == nil || carried.Cluster != "prod" || len(carried.Resources) != 1 || carried.Resources[0] != "deployments/api" {
+		t.Fatalf("expected the follow-up to carry the active context, got %+v", carried)
+	}
+	conversation, err := sessions.Conversation(context.Background(), "alice", first.SessionID)
+	if err != nil || len(conversation.Messages) != 4 || conversation.Title != "Restart the api in payments" {
+		t.Fatalf("unexpected conversation %+v: %v", conversation, err)
+	}
+
+	if rec := send(PromptRequest{Prompt: "again", SessionID: "missing"}); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected an unknown session to be refused, got %d", rec.Code)
+	}
+}
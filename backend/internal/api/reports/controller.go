@@ -0,0 +1,63 @@
+package reports
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/reports"
+)
+
+type HandoffGenerator interface {
+	Generate(ctx context.Context, scope reports.Scope) (reports.HandoffReport, error)
+}
+
+type LatestReports interface {
+	Latest(config, cluster string) (reports.HandoffReport, bool)
+}
+
+type HandoffController struct {
+	generator HandoffGenerator
+	latest    LatestReports
+	logger    *log.Logger
+}
+
+func NewHandoffController(generator HandoffGenerator, latest LatestReports, logger *log.Logger) *HandoffController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &HandoffController{generator: generator, latest: latest, logger: logger}
+}
+
+func (c *HandoffController) Handle(ctx echo.Context) error {
+	scope := reports.Scope{
+		Workspace: strings.TrimSpace(ctx.QueryParam("workspace")),
+		Config:    ctx.QueryParam("config"),
+		Cluster:   ctx.QueryParam("cluster"),
+	}
+	if raw := ctx.QueryParam("window"); raw != "" {
+		window, err := time.ParseDuration(raw)
+		if err != nil || window <= 0 {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "window must be a positive duration such as 12h"})
+		}
+		scope.Window = window
+	}
+
+	if strings.EqualFold(ctx.QueryParam("latest"), "true") && c.latest != nil {
+		report, ok := c.latest.Latest(scope.Config, scope.Cluster)
+		if !ok {
+			return ctx.JSON(http.StatusNotFound, map[string]string{"error": "no scheduled report available"})
+		}
+		return ctx.JSON(http.StatusOK, report)
+	}
+
+	report, err := c.generator.Generate(ctx.Request().Context(), scope)
+	if err != nil {
+		c.logger.Error("failed to generate handoff report", "cluster", scope.Cluster, "error", err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to generate handoff report"})
+	}
+	return ctx.JSON(http.StatusOK, report)
+}
@@ -323,6 +323,15 @@ func clonePlanStep(step plan.PlanStep) plan.PlanStep {
 	if step.DiffPreview != nil {
 		out.DiffPreview = cloneAny(step.DiffPreview).(map[string]any)
 	}
+	if step.SecondOpinion != nil {
+		review := *step.SecondOpinion
+		out.SecondOpinion = &review
+	}
+	if step.Operation != nil {
+		operation := *step.Operation
+		operation.Parameters = copyStringMap(step.Operation.Parameters)
+		out.Operation = &operation
+	}
 	return out
 }
 
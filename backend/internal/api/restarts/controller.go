@@ -0,0 +1,65 @@
+package restarts
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/inventory"
+)
+
+const (
+	defaultMinRestarts = 3
+	maxWindow          = 7 * 24 * time.Hour
+)
+
+type RestartReader interface {
+	Restarts(ctx context.Context, config, cluster string, filter inventory.RestartFilter) (inventory.RestartReport, error)
+}
+
+type RestartsController struct {
+	tracker RestartReader
+	logger  *log.Logger
+}
+
+func NewRestartsController(tracker RestartReader, logger *log.Logger) *RestartsController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &RestartsController{tracker: tracker, logger: logger}
+}
+
+func (c *RestartsController) Handle(ctx echo.Context) error {
+	filter := inventory.RestartFilter{
+		Namespace:   strings.TrimSpace(ctx.QueryParam("namespace")),
+		MinRestarts: defaultMinRestarts,
+		Window:      time.Hour,
+	}
+	if raw := ctx.QueryParam("min"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "min must be a positive number"})
+		}
+		filter.MinRestarts = int32(min(parsed, 1<<20))
+	}
+	if raw := ctx.QueryParam("window"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "window must be a positive duration such as 1h"})
+		}
+		filter.Window = min(parsed, maxWindow)
+	}
+
+	config := ctx.QueryParam("config")
+	cluster := ctx.QueryParam("cluster")
+	report, err := c.tracker.Restarts(ctx.Request().Context(), config, cluster, filter)
+	if err != nil {
+		c.logger.Error("failed to summarize restarts", "config", config, "cluster", cluster, "error", err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to summarize restarts"})
+	}
+	return ctx.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,121 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+	"github.com/pramodksahoo/kubechat/backend/internal/retention"
+)
+
+type RetentionManager interface {
+	Overview(ctx context.Context) (retention.Overview, error)
+	SetPolicy(ctx context.Context, actor string, category retention.Category, days int) (retention.Policy, error)
+	AddHold(ctx context.Context, actor string, hold retention.Hold) (retention.Hold, error)
+	ReleaseHold(ctx context.Context, id string) error
+	Enforce(ctx context.Context) []retention.Run
+	DryRun(ctx context.Context) ([]retention.Forecast, error)
+}
+
+type PolicyRequest struct {
+	Days int `json:"days"`
+}
+
+type HoldRequest struct {
+	Category retention.Category `json:"category"`
+	Subject  string             `json:"subject,omitempty"`
+	Records  []string           `json:"records,omitempty"`
+	Reason   string             `json:"reason"`
+}
+
+// RetentionController serves retention policies and holds. The role rules let auditors read them
+// and only roles:manage change them, since a short policy or an enforcement run purges audit and
+// execution records for good.
+type RetentionController struct {
+	manager RetentionManager
+	logger  *log.Logger
+}
+
+func NewRetentionController(manager RetentionManager, logger *log.Logger) *RetentionController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &RetentionController{manager: manager, logger: logger}
+}
+
+func (c *RetentionController) Get(ctx echo.Context) error {
+	overview, err := c.manager.Overview(ctx.Request().Context())
+	if err != nil {
+		return c.respondError(ctx, err, "failed to load retention policies")
+	}
+	return ctx.JSON(http.StatusOK, overview)
+}
+
+func (c *RetentionController) SetPolicy(ctx echo.Context) error {
+	var req PolicyRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	policy, err := c.manager.SetPolicy(ctx.Request().Context(), identity.User(ctx), retention.Category(ctx.Param("category")), req.Days)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to update retention policy")
+	}
+	return ctx.JSON(http.StatusOK, policy)
+}
+
+func (c *RetentionController) AddHold(ctx echo.Context) error {
+	var req HoldRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	hold, err := c.manager.AddHold(ctx.Request().Context(), identity.User(ctx), retention.Hold{
+		Category: req.Category,
+		Subject:  req.Subject,
+		Records:  req.Records,
+		Reason:   req.Reason,
+	})
+	if err != nil {
+		return c.respondError(ctx, err, "failed to place legal hold")
+	}
+	return ctx.JSON(http.StatusCreated, hold)
+}
+
+func (c *RetentionController) ReleaseHold(ctx echo.Context) error {
+	if err := c.manager.ReleaseHold(ctx.Request().Context(), ctx.Param("id")); err != nil {
+		return c.respondError(ctx, err, "failed to release legal hold")
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// Enforce runs retention for every category now instead of waiting for the scheduled job.
+func (c *RetentionController) Enforce(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, c.manager.Enforce(ctx.Request().Context()))
+}
+
+// DryRun reports what enforcing retention would remove now, with the holds keeping records back.
+func (c *RetentionController) DryRun(ctx echo.Context) error {
+	forecasts, err := c.manager.DryRun(ctx.Request().Context())
+	if err != nil {
+		return c.respondError(ctx, err, "failed to forecast retention")
+	}
+	return ctx.JSON(http.StatusOK, forecasts)
+}
+
+func (c *RetentionController) respondError(ctx echo.Context, err error, message string) error {
+	var (
+		noCategory retention.ErrCategoryNotFound
+		noHold     retention.ErrHoldNotFound
+		invalid    retention.ErrInvalidRequest
+	)
+	switch {
+	case errors.As(err, &noCategory), errors.As(err, &noHold):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.As(err, &invalid):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	c.logger.Error(message, "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
@@ -0,0 +1,60 @@
+package rightsizing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/capabilities"
+	"github.com/pramodksahoo/kubechat/backend/internal/rightsizing"
+)
+
+const maxWindow = 7 * 24 * time.Hour
+
+type Recommender interface {
+	Recommend(ctx context.Context, config, cluster string, filter rightsizing.Filter) (rightsizing.Report, error)
+}
+
+type RecommendationsController struct {
+	recommender Recommender
+	logger      *log.Logger
+}
+
+func NewRecommendationsController(recommender Recommender, logger *log.Logger) *RecommendationsController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &RecommendationsController{recommender: recommender, logger: logger}
+}
+
+func (c *RecommendationsController) Handle(ctx echo.Context) error {
+	filter := rightsizing.Filter{
+		Namespace:  strings.TrimSpace(ctx.QueryParam("namespace")),
+		Deployment: strings.TrimSpace(ctx.QueryParam("deployment")),
+		Window:     maxWindow,
+	}
+	if raw := ctx.QueryParam("window"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "window must be a positive duration such as 24h"})
+		}
+		filter.Window = min(parsed, maxWindow)
+	}
+
+	config := ctx.QueryParam("config")
+	cluster := ctx.QueryParam("cluster")
+	report, err := c.recommender.Recommend(ctx.Request().Context(), config, cluster, filter)
+	if errors.Is(err, rightsizing.ErrMetricsUnavailable) {
+		disabled := capabilities.ErrDisabled{Capability: capabilities.Metrics, Reason: err.Error(), Remediation: "install metrics-server in the cluster"}
+		return ctx.JSON(http.StatusServiceUnavailable, disabled.Response())
+	}
+	if err != nil {
+		c.logger.Error("failed to recommend requests", "config", config, "cluster", cluster, "error", err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to recommend requests"})
+	}
+	return ctx.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,127 @@
+package roles
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+	"github.com/pramodksahoo/kubechat/backend/internal/roles"
+)
+
+type RoleService interface {
+	Assign(ctx context.Context, actor string, req roles.BindingRequest) (roles.Binding, error)
+	Revoke(ctx context.Context, actor, id string) error
+	Bindings(ctx context.Context) ([]roles.Binding, error)
+	Access(ctx context.Context, user string, groups []string) (roles.Access, error)
+	AddNamespaceRule(ctx context.Context, actor string, req roles.NamespaceRuleRequest) (roles.NamespaceRule, error)
+	RemoveNamespaceRule(ctx context.Context, actor, id string) error
+	NamespacePolicy(ctx context.Context) (roles.NamespacePolicy, error)
+	Reload()
+}
+
+type RoleController struct {
+	service RoleService
+	logger  *log.Logger
+}
+
+func NewRoleController(service RoleService, logger *log.Logger) *RoleController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &RoleController{service: service, logger: logger}
+}
+
+// Roles lists the roles and the permissions each grants.
+func (c *RoleController) Roles(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, roles.Catalog())
+}
+
+// Me returns the caller's roles and cluster-wide permissions.
+func (c *RoleController) Me(ctx echo.Context) error {
+	access, err := c.service.Access(ctx.Request().Context(), identity.User(ctx), identity.Groups(ctx))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to load roles")
+	}
+	return ctx.JSON(http.StatusOK, access)
+}
+
+func (c *RoleController) Bindings(ctx echo.Context) error {
+	bindings, err := c.service.Bindings(ctx.Request().Context())
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list role bindings")
+	}
+	return ctx.JSON(http.StatusOK, bindings)
+}
+
+func (c *RoleController) Assign(ctx echo.Context) error {
+	var req roles.BindingRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	binding, err := c.service.Assign(ctx.Request().Context(), identity.User(ctx), req)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to bind role")
+	}
+	return ctx.JSON(http.StatusCreated, binding)
+}
+
+func (c *RoleController) Revoke(ctx echo.Context) error {
+	if err := c.service.Revoke(ctx.Request().Context(), identity.User(ctx), ctx.Param("id")); err != nil {
+		return c.respondError(ctx, err, "failed to remove role binding")
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// NamespacePolicy returns the configured default namespaces and the namespace rules.
+func (c *RoleController) NamespacePolicy(ctx echo.Context) error {
+	policy, err := c.service.NamespacePolicy(ctx.Request().Context())
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list namespace rules")
+	}
+	return ctx.JSON(http.StatusOK, policy)
+}
+
+func (c *RoleController) AddNamespaceRule(ctx echo.Context) error {
+	var req roles.NamespaceRuleRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	rule, err := c.service.AddNamespaceRule(ctx.Request().Context(), identity.User(ctx), req)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to add namespace rule")
+	}
+	return ctx.JSON(http.StatusCreated, rule)
+}
+
+func (c *RoleController) RemoveNamespaceRule(ctx echo.Context) error {
+	if err := c.service.RemoveNamespaceRule(ctx.Request().Context(), identity.User(ctx), ctx.Param("id")); err != nil {
+		return c.respondError(ctx, err, "failed to remove namespace rule")
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// Reload makes role bindings and namespace rules changed elsewhere, such as on another replica,
+// apply at once.
+func (c *RoleController) Reload(ctx echo.Context) error {
+	c.service.Reload()
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (c *RoleController) respondError(ctx echo.Context, err error, message string) error {
+	var (
+		notFound     roles.ErrBindingNotFound
+		ruleNotFound roles.ErrNamespaceRuleNotFound
+		invalid      roles.ErrInvalidRequest
+	)
+	switch {
+	case errors.As(err, &notFound), errors.As(err, &ruleNotFound):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.As(err, &invalid):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	c.logger.Error(message, "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
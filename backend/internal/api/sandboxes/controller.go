@@ -0,0 +1,114 @@
+package sandboxes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/sandbox"
+)
+
+type SandboxManager interface {
+	Create(ctx context.Context, req sandbox.Request) (sandbox.Sandbox, error)
+	Get(id string) (sandbox.Sandbox, error)
+	List() []sandbox.Sandbox
+	Verify(ctx context.Context, id string) (sandbox.Sandbox, error)
+	Promote(ctx context.Context, id string) (sandbox.Sandbox, error)
+	Delete(ctx context.Context, id string) error
+}
+
+type SandboxController struct {
+	manager SandboxManager
+	logger  *log.Logger
+}
+
+type CreateSandboxRequest struct {
+	Namespace  string                 `json:"namespace"`
+	Deployment string                 `json:"deployment"`
+	Change     sandbox.ProposedChange `json:"change"`
+}
+
+func NewSandboxController(manager SandboxManager, logger *log.Logger) *SandboxController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &SandboxController{manager: manager, logger: logger}
+}
+
+func (c *SandboxController) Create(ctx echo.Context) error {
+	var req CreateSandboxRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	req.Namespace = strings.TrimSpace(req.Namespace)
+	req.Deployment = strings.TrimSpace(req.Deployment)
+	if req.Namespace == "" || req.Deployment == "" {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "namespace and deployment are required"})
+	}
+	if req.Change.IsEmpty() {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "change is required"})
+	}
+
+	sb, err := c.manager.Create(ctx.Request().Context(), sandbox.Request{
+		Config:     ctx.QueryParam("config"),
+		Cluster:    ctx.QueryParam("cluster"),
+		Namespace:  req.Namespace,
+		Deployment: req.Deployment,
+		Change:     req.Change,
+	})
+	if err != nil {
+		c.logger.Error("failed to provision sandbox", "namespace", req.Namespace, "deployment", req.Deployment, "error", err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to provision sandbox"})
+	}
+	return ctx.JSON(http.StatusCreated, sb)
+}
+
+func (c *SandboxController) List(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, c.manager.List())
+}
+
+func (c *SandboxController) Get(ctx echo.Context) error {
+	sb, err := c.manager.Get(ctx.Param("id"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to load sandbox")
+	}
+	return ctx.JSON(http.StatusOK, sb)
+}
+
+func (c *SandboxController) Verify(ctx echo.Context) error {
+	sb, err := c.manager.Verify(ctx.Request().Context(), ctx.Param("id"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to verify sandbox")
+	}
+	return ctx.JSON(http.StatusOK, sb)
+}
+
+func (c *SandboxController) Promote(ctx echo.Context) error {
+	sb, err := c.manager.Promote(ctx.Request().Context(), ctx.Param("id"))
+	if err != nil {
+		if errors.Is(err, sandbox.ErrNotVerified) {
+			return ctx.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return c.respondError(ctx, err, "failed to promote sandbox")
+	}
+	return ctx.JSON(http.StatusOK, sb)
+}
+
+func (c *SandboxController) Delete(ctx echo.Context) error {
+	if err := c.manager.Delete(ctx.Request().Context(), ctx.Param("id")); err != nil {
+		return c.respondError(ctx, err, "failed to delete sandbox")
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (c *SandboxController) respondError(ctx echo.Context, err error, message string) error {
+	var notFound sandbox.ErrSandboxNotFound
+	if errors.As(err, &notFound) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "sandbox not found"})
+	}
+	c.logger.Error(message, "sandbox_id", ctx.Param("id"), "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
@@ -0,0 +1,63 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/security"
+)
+
+// defaultHistoryLimit is how many reports History returns without a limit.
+const defaultHistoryLimit = 20
+
+type ReportStore interface {
+	Latest(ctx context.Context) (security.Report, error)
+	List(ctx context.Context, limit int) ([]security.Report, error)
+}
+
+type PostureController struct {
+	reports ReportStore
+	logger  *log.Logger
+}
+
+func NewPostureController(reports ReportStore, logger *log.Logger) *PostureController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &PostureController{reports: reports, logger: logger}
+}
+
+// Get returns the posture report of the most recent server start.
+func (c *PostureController) Get(ctx echo.Context) error {
+	report, err := c.reports.Latest(ctx.Request().Context())
+	if errors.As(err, &security.ErrNoReport{}) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		c.logger.Error("failed to load security posture", "error", err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to load security posture"})
+	}
+	return ctx.JSON(http.StatusOK, report)
+}
+
+// History returns the reports of earlier starts, most recent first.
+func (c *PostureController) History(ctx echo.Context) error {
+	limit := defaultHistoryLimit
+	if raw := ctx.QueryParam("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > 1000 {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "limit must be between 1 and 1000"})
+		}
+		limit = parsed
+	}
+	reports, err := c.reports.List(ctx.Request().Context(), limit)
+	if err != nil {
+		c.logger.Error("failed to list security posture reports", "error", err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to list security posture reports"})
+	}
+	return ctx.JSON(http.StatusOK, map[string][]security.Report{"reports": reports})
+}
@@ -0,0 +1,53 @@
+package signing
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/signing"
+)
+
+type AuditSource interface {
+	Audit(ctx context.Context, filter signing.AuditFilter) ([]signing.AuditEntry, error)
+}
+
+// SignedRequestController lets admins review attempts at signed operations, kept apart from the
+// routine audit trails so refused signatures stand out.
+type SignedRequestController struct {
+	source AuditSource
+	logger *log.Logger
+}
+
+func NewSignedRequestController(source AuditSource, logger *log.Logger) *SignedRequestController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &SignedRequestController{source: source, logger: logger}
+}
+
+func (c *SignedRequestController) Audit(ctx echo.Context) error {
+	filter := signing.AuditFilter{
+		User:     ctx.QueryParam("user"),
+		Severity: signing.Severity(ctx.QueryParam("severity")),
+		Limit:    100,
+	}
+	if filter.Severity != "" && filter.Severity != signing.SeverityCritical && filter.Severity != signing.SeverityAlert {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "severity must be critical or alert"})
+	}
+	if raw := ctx.QueryParam("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "limit must be a positive number"})
+		}
+		filter.Limit = min(limit, 1000)
+	}
+	entries, err := c.source.Audit(ctx.Request().Context(), filter)
+	if err != nil {
+		c.logger.Error("failed to load signed request audit log", "error", err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to load signed request audit log"})
+	}
+	return ctx.JSON(http.StatusOK, map[string][]signing.AuditEntry{"entries": entries})
+}
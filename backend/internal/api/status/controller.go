@@ -0,0 +1,50 @@
+package status
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/health"
+)
+
+type SummaryProvider interface {
+	Summary() health.Summary
+}
+
+// StatusController serves the public status summary. Responses are cached for ttl
+// so the endpoint stays cheap when embedded in external status pages.
+type StatusController struct {
+	provider SummaryProvider
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	cached   health.Summary
+	cachedAt time.Time
+}
+
+func NewStatusController(provider SummaryProvider, ttl time.Duration) *StatusController {
+	if ttl <= 0 {
+		ttl = 15 * time.Second
+	}
+	return &StatusController{provider: provider, ttl: ttl, now: time.Now}
+}
+
+func (c *StatusController) Handle(ctx echo.Context) error {
+	ctx.Response().Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(c.ttl.Seconds())))
+	ctx.Response().Header().Set("Access-Control-Allow-Origin", "*")
+	return ctx.JSON(http.StatusOK, c.summary())
+}
+
+func (c *StatusController) summary() health.Summary {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cachedAt.IsZero() || c.now().Sub(c.cachedAt) >= c.ttl {
+		c.cached = c.provider.Summary()
+		c.cachedAt = c.now()
+	}
+	return c.cached
+}
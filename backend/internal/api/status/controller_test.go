@@ -0,0 +1,54 @@
+package status
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/health"
+)
+
+type countingProvider struct {
+	calls int
+}
+
+func (p *countingProvider) Summary() health.Summary {
+	p.calls++
+	return health.Summary{Status: health.Operational}
+}
+
+func TestStatusControllerCachesSummary(t *testing.T) {
+	provider := &countingProvider{}
+	controller := NewStatusController(provider, 15*time.Second)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	controller.now = func() time.Time { return now }
+
+	e := echo.New()
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		if err := controller.Handle(e.NewContext(httptest.NewRequest(http.MethodGet, "/status", nil), rec)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return rec
+	}
+
+	rec := serve()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "public, max-age=15" {
+		t.Fatalf("unexpected Cache-Control %q", got)
+	}
+	serve()
+	if provider.calls != 1 {
+		t.Fatalf("expected cached summary, provider called %d times", provider.calls)
+	}
+
+	now = now.Add(16 * time.Second)
+	serve()
+	if provider.calls != 2 {
+		t.Fatalf("expected refresh after ttl, provider called %d times", provider.calls)
+	}
+}
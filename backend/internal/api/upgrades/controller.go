@@ -0,0 +1,81 @@
+package upgrades
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/clusters"
+	"github.com/pramodksahoo/kubechat/backend/internal/upgrades"
+)
+
+type Advisor interface {
+	Check(ctx context.Context, cluster upgrades.Cluster, target string) (upgrades.Report, error)
+	CheckAll(ctx context.Context, clusters []upgrades.Cluster, target string) []upgrades.Report
+}
+
+type ClusterLister interface {
+	List(ctx context.Context) ([]clusters.Cluster, error)
+	Resolve(ctx context.Context, ref string) (string, string, error)
+}
+
+type AdviceController struct {
+	advisor  Advisor
+	clusters ClusterLister
+	logger   *log.Logger
+}
+
+func NewAdviceController(advisor Advisor, clusters ClusterLister, logger *log.Logger) *AdviceController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &AdviceController{advisor: advisor, clusters: clusters, logger: logger}
+}
+
+// List advises on upgrading every connected cluster to ?target=, or to each cluster's next
+// minor version.
+func (c *AdviceController) List(ctx echo.Context) error {
+	list, err := c.clusters.List(ctx.Request().Context())
+	if err != nil {
+		return c.respondError(ctx, err)
+	}
+	var targets []upgrades.Cluster
+	for _, cluster := range list {
+		if cluster.Connected {
+			targets = append(targets, upgrades.Cluster{Name: cluster.Name, Config: cluster.Config, Context: cluster.Context})
+		}
+	}
+	return ctx.JSON(http.StatusOK, c.advisor.CheckAll(ctx.Request().Context(), targets, ctx.QueryParam("target")))
+}
+
+// Get answers what breaks when the cluster, given by ID or name, is upgraded to ?target=.
+func (c *AdviceController) Get(ctx echo.Context) error {
+	ref := ctx.Param("cluster")
+	config, context, err := c.clusters.Resolve(ctx.Request().Context(), ref)
+	if err != nil {
+		return c.respondError(ctx, err)
+	}
+	report, err := c.advisor.Check(ctx.Request().Context(), upgrades.Cluster{Name: ref, Config: config, Context: context}, ctx.QueryParam("target"))
+	if err != nil {
+		return c.respondError(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, report)
+}
+
+func (c *AdviceController) respondError(ctx echo.Context, err error) error {
+	var (
+		notFound clusters.ErrClusterNotFound
+		invalid  clusters.ErrInvalidRequest
+		target   upgrades.ErrInvalidTarget
+	)
+	switch {
+	case errors.As(err, &notFound):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.As(err, &invalid), errors.As(err, &target):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	c.logger.Error("failed to check upgrade readiness", "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to check upgrade readiness"})
+}
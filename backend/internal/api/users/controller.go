@@ -0,0 +1,145 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+	"github.com/pramodksahoo/kubechat/backend/internal/roles"
+	"github.com/pramodksahoo/kubechat/backend/internal/users"
+)
+
+type Directory interface {
+	List(ctx context.Context, filter users.Filter) ([]users.User, error)
+	Get(ctx context.Context, name string) (users.Details, error)
+	Lock(ctx context.Context, actor, name, reason string) (users.User, error)
+	Unlock(ctx context.Context, actor, name string) (users.User, error)
+	ResetCredentials(ctx context.Context, actor, name string) (users.User, error)
+	AssignRole(ctx context.Context, actor, name string, role roles.Role, namespace string) (roles.Binding, error)
+	RevokeRole(ctx context.Context, actor, name, id string) error
+}
+
+type UserController struct {
+	directory Directory
+	logger    *log.Logger
+}
+
+func NewUserController(directory Directory, logger *log.Logger) *UserController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &UserController{directory: directory, logger: logger}
+}
+
+// LockRequest says why a user is locked; the reason is shown to admins, not to the user.
+type LockRequest struct {
+	Reason string `json:"reason"`
+}
+
+// RoleRequest binds a role to a user, in Namespace or in every namespace when it is empty.
+type RoleRequest struct {
+	Role      roles.Role `json:"role"`
+	Namespace string     `json:"namespace,omitempty"`
+}
+
+// List returns the users KubeChat knows, searched with ?q= and narrowed with ?locked=true|false.
+func (c *UserController) List(ctx echo.Context) error {
+	filter := users.Filter{Query: ctx.QueryParam("q"), Limit: 100}
+	if raw := ctx.QueryParam("locked"); raw != "" {
+		locked, err := strconv.ParseBool(raw)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "locked must be true or false"})
+		}
+		filter.Locked = &locked
+	}
+	if raw := ctx.QueryParam("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "limit must be a positive number"})
+		}
+		filter.Limit = min(limit, 1000)
+	}
+	list, err := c.directory.List(ctx.Request().Context(), filter)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to list users")
+	}
+	return ctx.JSON(http.StatusOK, map[string][]users.User{"users": list})
+}
+
+// Get returns a user with their last login, roles and active sessions.
+func (c *UserController) Get(ctx echo.Context) error {
+	details, err := c.directory.Get(ctx.Request().Context(), ctx.Param("user"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to load user")
+	}
+	return ctx.JSON(http.StatusOK, details)
+}
+
+func (c *UserController) Lock(ctx echo.Context) error {
+	var req LockRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	user, err := c.directory.Lock(ctx.Request().Context(), identity.Actor(ctx), ctx.Param("user"), req.Reason)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to lock user")
+	}
+	return ctx.JSON(http.StatusOK, user)
+}
+
+func (c *UserController) Unlock(ctx echo.Context) error {
+	user, err := c.directory.Unlock(ctx.Request().Context(), identity.Actor(ctx), ctx.Param("user"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to unlock user")
+	}
+	return ctx.JSON(http.StatusOK, user)
+}
+
+// Reset removes the user's second factor and ends their sessions.
+func (c *UserController) Reset(ctx echo.Context) error {
+	user, err := c.directory.ResetCredentials(ctx.Request().Context(), identity.Actor(ctx), ctx.Param("user"))
+	if err != nil {
+		return c.respondError(ctx, err, "failed to reset user credentials")
+	}
+	return ctx.JSON(http.StatusOK, user)
+}
+
+func (c *UserController) AssignRole(ctx echo.Context) error {
+	var req RoleRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+	}
+	binding, err := c.directory.AssignRole(ctx.Request().Context(), identity.Actor(ctx), ctx.Param("user"), req.Role, req.Namespace)
+	if err != nil {
+		return c.respondError(ctx, err, "failed to bind role")
+	}
+	return ctx.JSON(http.StatusCreated, binding)
+}
+
+func (c *UserController) RevokeRole(ctx echo.Context) error {
+	if err := c.directory.RevokeRole(ctx.Request().Context(), identity.Actor(ctx), ctx.Param("user"), ctx.Param("id")); err != nil {
+		return c.respondError(ctx, err, "failed to remove role binding")
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (c *UserController) respondError(ctx echo.Context, err error, message string) error {
+	var (
+		notFound        users.ErrUserNotFound
+		bindingNotFound roles.ErrBindingNotFound
+		invalid         users.ErrInvalidRequest
+		invalidRole     roles.ErrInvalidRequest
+	)
+	switch {
+	case errors.As(err, &notFound), errors.As(err, &bindingNotFound):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.As(err, &invalid), errors.As(err, &invalidRole):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	c.logger.Error(message, "error", err)
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
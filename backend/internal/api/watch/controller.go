@@ -0,0 +1,218 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+	"github.com/pramodksahoo/kubechat/backend/internal/watch"
+)
+
+// writeTimeout disconnects clients that stop reading.
+const writeTimeout = 10 * time.Second
+
+type Hub interface {
+	Subscribe(ctx context.Context, req watch.Request) (*watch.Subscription, error)
+	Unsubscribe(sub *watch.Subscription)
+	Subscriptions(user string) []*watch.Subscription
+}
+
+// ClientMessage is sent by clients. Action "subscribe" opens a subscription under the client's
+// ID with the request fields; "unsubscribe" closes the subscription with that ID.
+type ClientMessage struct {
+	Action string `json:"action"`
+	ID     string `json:"id"`
+	watch.Request
+}
+
+// ServerMessage is sent to clients. Type is "subscribed", "event", "error" or "closed"; ID is the
+// client's subscription ID.
+type ServerMessage struct {
+	Type         string              `json:"type"`
+	ID           string              `json:"id,omitempty"`
+	Subscription *watch.Subscription `json:"subscription,omitempty"`
+	Event        *watch.Event        `json:"event,omitempty"`
+	Error        string              `json:"error,omitempty"`
+}
+
+type WatchController struct {
+	hub      Hub
+	upgrader *websocket.Upgrader
+	logger   *log.Logger
+	sockets  ConnectionGauge
+}
+
+func NewWatchController(hub Hub, upgrader *websocket.Upgrader, logger *log.Logger) *WatchController {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if upgrader == nil {
+		upgrader = &websocket.Upgrader{}
+	}
+	return &WatchController{hub: hub, upgrader: upgrader, logger: logger}
+}
+
+// ConnectionGauge counts open connections, such as a Prometheus gauge.
+type ConnectionGauge interface {
+	Inc()
+	Dec()
+}
+
+// SetConnections counts open watch sockets in gauge.
+func (c *WatchController) SetConnections(gauge ConnectionGauge) {
+	c.sockets = gauge
+}
+
+// Subscriptions lists the caller's open subscriptions across all connections.
+func (c *WatchController) Subscriptions(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, map[string][]*watch.Subscription{"subscriptions": c.hub.Subscriptions(identity.User(ctx))})
+}
+
+// Socket multiplexes the caller's subscriptions over one WebSocket. Subscriptions end with the
+// connection.
+func (c *WatchController) Socket(ctx echo.Context) error {
+	conn, err := c.upgrader.Upgrade(ctx.Response(), ctx.Request(), nil)
+	if err != nil {
+		// The upgrader already wrote the error response.
+		c.logger.Warn("failed to upgrade watch connection", "error", err)
+		return nil
+	}
+	if c.sockets != nil {
+		c.sockets.Inc()
+		defer c.sockets.Dec()
+	}
+	session := &session{
+		hub:    c.hub,
+		conn:   conn,
+		user:   identity.User(ctx),
+		groups: identity.Groups(ctx),
+		subs:   make(map[string]*watch.Subscription),
+		logger: c.logger,
+	}
+	session.serve(ctx.Request().Context())
+	return nil
+}
+
+type session struct {
+	hub    Hub
+	conn   *websocket.Conn
+	user   string
+	groups []string
+	logger *log.Logger
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	subs    map[string]*watch.Subscription
+	wg      sync.WaitGroup
+}
+
+func (s *session) serve(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		s.mu.Lock()
+		for _, sub := range s.subs {
+			s.hub.Unsubscribe(sub)
+		}
+		s.mu.Unlock()
+		s.wg.Wait()
+		s.conn.Close()
+	}()
+
+	for {
+		var msg ClientMessage
+		if err := s.conn.ReadJSON(&msg); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) && ctx.Err() == nil {
+				s.logger.Debug("watch connection closed", "user", s.user, "error", err)
+			}
+			return
+		}
+		switch msg.Action {
+		case "subscribe":
+			s.subscribe(ctx, msg)
+		case "unsubscribe":
+			s.mu.Lock()
+			sub, ok := s.subs[msg.ID]
+			s.mu.Unlock()
+			if !ok {
+				s.send(ServerMessage{Type: "error", ID: msg.ID, Error: "no subscription with this id"})
+				continue
+			}
+			s.hub.Unsubscribe(sub)
+		default:
+			s.send(ServerMessage{Type: "error", ID: msg.ID, Error: `action must be "subscribe" or "unsubscribe"`})
+		}
+	}
+}
+
+func (s *session) subscribe(ctx context.Context, msg ClientMessage) {
+	if msg.ID == "" {
+		s.send(ServerMessage{Type: "error", Error: "subscriptions need a client-chosen id"})
+		return
+	}
+	s.mu.Lock()
+	_, taken := s.subs[msg.ID]
+	s.mu.Unlock()
+	if taken {
+		s.send(ServerMessage{Type: "error", ID: msg.ID, Error: "subscription id is already in use"})
+		return
+	}
+
+	req := msg.Request
+	req.User, req.Groups = s.user, s.groups
+	sub, err := s.hub.Subscribe(ctx, req)
+	if err != nil {
+		var invalid watch.ErrInvalidRequest
+		var forbidden watch.ErrForbidden
+		if !errors.As(err, &invalid) && !errors.As(err, &forbidden) && !errors.Is(err, watch.ErrTooManySubscriptions) {
+			s.logger.Error("failed to subscribe", "user", s.user, "resource", req.Resource, "error", err)
+		}
+		s.send(ServerMessage{Type: "error", ID: msg.ID, Error: err.Error()})
+		return
+	}
+	s.mu.Lock()
+	s.subs[msg.ID] = sub
+	s.mu.Unlock()
+	s.send(ServerMessage{Type: "subscribed", ID: msg.ID, Subscription: sub})
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.subs, msg.ID)
+			s.mu.Unlock()
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sub.Done():
+				closed := ServerMessage{Type: "closed", ID: msg.ID}
+				if err := sub.Err(); err != nil {
+					closed.Error = err.Error()
+				}
+				s.send(closed)
+				return
+			case event := <-sub.Events():
+				if err := s.send(ServerMessage{Type: "event", ID: msg.ID, Event: &event}); err != nil {
+					s.hub.Unsubscribe(sub)
+					return
+				}
+			}
+		}
+	}()
+}
+
+func (s *session) send(msg ServerMessage) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	_ = s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return s.conn.WriteJSON(msg)
+}
@@ -0,0 +1,186 @@
+// Package attachments stores large outputs outside the database so records only
+// carry a link and size metadata.
+package attachments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var ErrNotFound = errors.New("attachment not found")
+
+type Store interface {
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// StreamStore is implemented by stores that can upload without holding the object in memory.
+type StreamStore interface {
+	PutStream(ctx context.Context, key, contentType string, r io.Reader, size int64) error
+}
+
+// Presigner is implemented by stores that can issue time-limited download URLs themselves.
+type Presigner interface {
+	PresignGet(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+// MetadataStore is implemented by stores that keep metadata with an object, where bucket
+// lifecycle rules and operators can read it without downloading the object.
+type MetadataStore interface {
+	PutWithMetadata(ctx context.Context, key, contentType string, data []byte, metadata map[string]string) error
+}
+
+// PutWithMetadata uploads data with metadata, which stores that cannot keep it drop.
+func PutWithMetadata(ctx context.Context, store Store, key, contentType string, data []byte, metadata map[string]string) error {
+	if keeper, ok := store.(MetadataStore); ok {
+		return keeper.PutWithMetadata(ctx, key, contentType, data, metadata)
+	}
+	return store.Put(ctx, key, contentType, data)
+}
+
+// PutStream uploads size bytes from r, streaming when the store supports it.
+func PutStream(ctx context.Context, store Store, key, contentType string, r io.Reader, size int64) error {
+	if streamer, ok := store.(StreamStore); ok {
+		return streamer.PutStream(ctx, key, contentType, r, size)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, size))
+	if err != nil {
+		return err
+	}
+	return store.Put(ctx, key, contentType, data)
+}
+
+// Open builds a store from a URL: file:///var/lib/kubechat/attachments for a local directory,
+// s3://bucket/prefix?region=eu-west-1&endpoint=https://minio:9000 for S3-compatible storage, or
+// gs://bucket/prefix for Google Cloud Storage through its S3-compatible XML API. Credentials come
+// from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN, which hold an HMAC key of
+// the bucket's service account for Cloud Storage.
+func Open(rawURL string) (Store, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse attachment store URL: %w", err)
+	}
+	switch u.Scheme {
+	case "", "file":
+		dir := u.Path
+		if u.Scheme == "" {
+			dir = rawURL
+		}
+		return NewFileStore(dir)
+	case "s3", "gs":
+		query := u.Query()
+		cfg := S3Config{
+			Bucket:   u.Host,
+			Prefix:   strings.Trim(u.Path, "/"),
+			Region:   query.Get("region"),
+			Endpoint: query.Get("endpoint"),
+			Credentials: Credentials{
+				AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+				SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+				SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+			},
+		}
+		if u.Scheme == "gs" {
+			if cfg.Endpoint == "" {
+				cfg.Endpoint = "https://storage.googleapis.com"
+			}
+			if cfg.Region == "" {
+				cfg.Region = "auto"
+			}
+		}
+		return NewS3Store(cfg, nil)
+	default:
+		return nil, fmt.Errorf("unsupported attachment store scheme %q", u.Scheme)
+	}
+}
+
+// FileStore keeps attachments as files below a root directory.
+type FileStore struct {
+	root string
+}
+
+func NewFileStore(root string) (*FileStore, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("create attachment directory: %w", err)
+	}
+	return &FileStore{root: root}, nil
+}
+
+func (s *FileStore) Put(ctx context.Context, key, contentType string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *FileStore) PutStream(ctx context.Context, key, contentType string, r io.Reader, size int64) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, io.LimitReader(r, size)); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *FileStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (s *FileStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) path(key string) (string, error) {
+	cleaned := filepath.Clean("/" + key)
+	if key == "" || cleaned == "/" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid attachment key %q", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(cleaned)), nil
+}
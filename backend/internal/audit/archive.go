@@ -0,0 +1,412 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/pramodksahoo/kubechat/backend/internal/attachments"
+)
+
+const (
+	// archiveBatchSize is the most records sealed into one archive object.
+	archiveBatchSize = 5000
+	// archivePrefix is where archives are stored, so bucket lifecycle rules can match them.
+	archivePrefix      = "audit-archive/"
+	archiveContentType = "application/octet-stream"
+	// DefaultArchiveLifetime is how long archives are kept when no lifetime is set.
+	DefaultArchiveLifetime = 7 * 365 * 24 * time.Hour
+)
+
+// Archive is a batch of ledger records moved out of the database into object storage. The
+// object holds the records as gzip-compressed JSON Lines sealed with AES-256-GCM.
+type Archive struct {
+	ID  string `json:"id"`
+	Key string `json:"key"`
+	// FirstSeq to LastSeq is the range of records in the archive.
+	FirstSeq int64     `json:"firstSeq"`
+	LastSeq  int64     `json:"lastSeq"`
+	Records  int       `json:"records"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	// PrevHash is the hash the first record links to and LastHash the hash of the last record,
+	// which tie the archive into the chain when it is restored.
+	PrevHash string `json:"prevHash"`
+	LastHash string `json:"lastHash"`
+	Size     int64  `json:"size"`
+	// SHA256 is the digest of the stored object.
+	SHA256 string `json:"sha256"`
+	// KeyID identifies the encryption key the archive was sealed with.
+	KeyID       string    `json:"keyId"`
+	CreatedAt   time.Time `json:"createdAt"`
+	RetainUntil time.Time `json:"retainUntil"`
+	// RestoredAt is set while the archive's records are rehydrated for queries.
+	RestoredAt *time.Time `json:"restoredAt,omitempty"`
+}
+
+// RestoredRecord is a record rehydrated from an archive.
+type RestoredRecord struct {
+	Record
+	ArchiveID string `json:"archiveId"`
+}
+
+// RestoredFilter selects rehydrated records; an empty field matches every record.
+type RestoredFilter struct {
+	ArchiveID string
+	Source    Source
+	After     int64
+	Limit     int
+}
+
+// ArchiveRepository keeps the archive catalog and the rehydrated records next to the chain.
+type ArchiveRepository interface {
+	SaveArchive(ctx context.Context, archive Archive) error
+	ListArchives(ctx context.Context) ([]Archive, error)
+	GetArchive(ctx context.Context, id string) (Archive, error)
+	// DeleteThrough removes the records up to and including seq from the chain.
+	DeleteThrough(ctx context.Context, seq int64) (int, error)
+	// Restore replaces the rehydrated records of an archive and marks it restored at.
+	Restore(ctx context.Context, id string, records []Record, at time.Time) error
+	// Evict removes the rehydrated records of an archive.
+	Evict(ctx context.Context, id string) error
+	ListRestored(ctx context.Context, filter RestoredFilter) ([]RestoredRecord, error)
+}
+
+type ErrArchiveNotFound struct {
+	ID string
+}
+
+func (e ErrArchiveNotFound) Error() string {
+	return fmt.Sprintf("audit archive %s not found", e.ID)
+}
+
+// ErrArchiveCorrupt is returned when an archive no longer matches its catalog entry or its
+// records do not form the chain they were cut from.
+type ErrArchiveCorrupt struct {
+	ID     string
+	Reason string
+}
+
+func (e ErrArchiveCorrupt) Error() string {
+	return fmt.Sprintf("audit archive %s is corrupt: %s", e.ID, e.Reason)
+}
+
+// ErrChainBroken refuses archiving while the chain is broken, so the evidence stays in place.
+type ErrChainBroken struct {
+	Seq int64
+}
+
+func (e ErrChainBroken) Error() string {
+	return fmt.Sprintf("the audit ledger is broken at record %d; investigate and re-anchor it before archiving", e.Seq)
+}
+
+// archiveActor is recorded as the actor of anchors made by archiving.
+const archiveActor = "retention"
+
+// Archiver moves old ledger records to object storage and rehydrates them on request.
+type Archiver struct {
+	ledger   *Ledger
+	repo     ArchiveRepository
+	store    attachments.Store
+	aead     cipher.AEAD
+	keyID    string
+	lifetime time.Duration
+	logger   *log.Logger
+	now      func() time.Time
+
+	// mu keeps archiving from running twice at once in this process.
+	mu sync.Mutex
+}
+
+// NewArchiver seals archives with an AES-256 key derived from key. The ledger's repository must
+// be the one repo also keeps the archive catalog in.
+func NewArchiver(ledger *Ledger, repo ArchiveRepository, store attachments.Store, key string, logger *log.Logger) (*Archiver, error) {
+	if key == "" {
+		return nil, ErrInvalidRequest{Reason: "an archive encryption key is required"}
+	}
+	if logger == nil {
+		logger = log.Default()
+	}
+	derived := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(derived[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	keyID := sha256.Sum256(derived[:])
+	return &Archiver{
+		ledger:   ledger,
+		repo:     repo,
+		store:    store,
+		aead:     aead,
+		keyID:    hex.EncodeToString(keyID[:4]),
+		lifetime: DefaultArchiveLifetime,
+		logger:   logger,
+		now:      func() time.Time { return time.Now().UTC() },
+	}, nil
+}
+
+// SetLifetime sets how long archives are kept in the store. It is recorded on each archive for
+// bucket lifecycle rules to act on; KubeChat never deletes archives itself.
+func (a *Archiver) SetLifetime(lifetime time.Duration) {
+	if lifetime > 0 {
+		a.lifetime = lifetime
+	}
+}
+
+// Archive moves the records appended before the cutoff to object storage in batches and deletes
+// them from the chain. Verification continues from an anchor on the last archived record, which
+// is made before anything is deleted, and each batch is noted on the chain itself. Only records
+// verified intact are archived.
+func (a *Archiver) Archive(ctx context.Context, before time.Time) (int, error) {
+	return a.eligible(ctx, before, a.archive)
+}
+
+// Archivable counts the records Archive would move now.
+func (a *Archiver) Archivable(ctx context.Context, before time.Time) (int, error) {
+	return a.eligible(ctx, before, func(ctx context.Context, batch []Record) (int, error) {
+		return len(batch), nil
+	})
+}
+
+// eligible passes the verified records appended before the cutoff to visit in sequence batches
+// and sums what it returns.
+func (a *Archiver) eligible(ctx context.Context, before time.Time, visit func(ctx context.Context, batch []Record) (int, error)) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	verification, err := a.ledger.Verify(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !verification.Intact {
+		return 0, ErrChainBroken{Seq: verification.Alert.Seq}
+	}
+	var after int64
+	if verification.Anchor != nil {
+		after = verification.Anchor.Seq
+	}
+
+	total := 0
+	for {
+		records, err := a.ledger.repo.List(ctx, after, archiveBatchSize)
+		if err != nil {
+			return total, err
+		}
+		batch := records
+		for i, record := range records {
+			if record.Seq > verification.Through || !record.At.Before(before) {
+				batch = records[:i]
+				break
+			}
+		}
+		if len(batch) == 0 {
+			return total, nil
+		}
+		n, err := visit(ctx, batch)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if len(batch) < len(records) || len(records) < archiveBatchSize {
+			return total, nil
+		}
+		after = batch[len(batch)-1].Seq
+	}
+}
+
+func (a *Archiver) archive(ctx context.Context, batch []Record) (int, error) {
+	first, last := batch[0], batch[len(batch)-1]
+	now := a.now()
+	archive := Archive{
+		ID:          uuid.NewString(),
+		FirstSeq:    first.Seq,
+		LastSeq:     last.Seq,
+		Records:     len(batch),
+		From:        first.At,
+		To:          last.At,
+		PrevHash:    first.PrevHash,
+		LastHash:    last.Hash,
+		KeyID:       a.keyID,
+		CreatedAt:   now,
+		RetainUntil: now.Add(a.lifetime),
+	}
+	archive.Key = fmt.Sprintf("%s%s/%s.jsonl.gz.enc", archivePrefix, now.Format("2006/01"), archive.ID)
+
+	sealed, err := a.seal(archive.ID, batch)
+	if err != nil {
+		return 0, err
+	}
+	digest := sha256.Sum256(sealed)
+	archive.Size, archive.SHA256 = int64(len(sealed)), hex.EncodeToString(digest[:])
+	metadata := map[string]string{
+		"kubechat-archive":      archive.ID,
+		"kubechat-first-seq":    strconv.FormatInt(archive.FirstSeq, 10),
+		"kubechat-last-seq":     strconv.FormatInt(archive.LastSeq, 10),
+		"kubechat-records":      strconv.Itoa(archive.Records),
+		"kubechat-key-id":       archive.KeyID,
+		"kubechat-sha256":       archive.SHA256,
+		"kubechat-retain-until": archive.RetainUntil.Format(time.RFC3339),
+	}
+	if err := attachments.PutWithMetadata(ctx, a.store, archive.Key, archiveContentType, sealed, metadata); err != nil {
+		return 0, fmt.Errorf("upload audit archive: %w", err)
+	}
+	if err := a.repo.SaveArchive(ctx, archive); err != nil {
+		return 0, err
+	}
+	if err := a.ledger.Append(ctx, SourceLedger, map[string]any{
+		"action":   "archive",
+		"actor":    archiveActor,
+		"archive":  archive.ID,
+		"firstSeq": archive.FirstSeq,
+		"lastSeq":  archive.LastSeq,
+		"sha256":   archive.SHA256,
+	}); err != nil {
+		return 0, err
+	}
+	anchor := Anchor{
+		ID:     uuid.NewString(),
+		Seq:    last.Seq,
+		Hash:   last.Hash,
+		Actor:  archiveActor,
+		Reason: fmt.Sprintf("records %d to %d archived as %s", first.Seq, last.Seq, archive.ID),
+		At:     now,
+	}
+	if err := a.ledger.repo.SaveAnchor(ctx, anchor); err != nil {
+		return 0, err
+	}
+	removed, err := a.repo.DeleteThrough(ctx, last.Seq)
+	if err != nil {
+		return 0, err
+	}
+	a.logger.Info("archived audit ledger records", "archive", archive.ID, "first", first.Seq, "last", last.Seq, "key", archive.Key)
+	return removed, nil
+}
+
+// seal compresses the records as JSON Lines and encrypts them. The archive ID is authenticated
+// with them, so an object cannot be passed off as another archive.
+func (a *Archiver) seal(id string, records []Record) ([]byte, error) {
+	var plain bytes.Buffer
+	compressor := gzip.NewWriter(&plain)
+	encoder := json.NewEncoder(compressor)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return nil, err
+		}
+	}
+	if err := compressor.Close(); err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, a.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return a.aead.Seal(nonce, nonce, plain.Bytes(), []byte(id)), nil
+}
+
+func (a *Archiver) Archives(ctx context.Context) ([]Archive, error) {
+	return a.repo.ListArchives(ctx)
+}
+
+// Restore downloads an archive, checks that it is unchanged and that its records still form the
+// chain they were cut from, and rehydrates them for queries.
+func (a *Archiver) Restore(ctx context.Context, id string) (Archive, error) {
+	archive, err := a.repo.GetArchive(ctx, id)
+	if err != nil {
+		return Archive{}, err
+	}
+	if archive.KeyID != a.keyID {
+		return Archive{}, ErrInvalidRequest{Reason: fmt.Sprintf("archive %s was sealed with key %s, not the configured key %s", id, archive.KeyID, a.keyID)}
+	}
+	body, err := a.store.Open(ctx, archive.Key)
+	if err != nil {
+		return Archive{}, fmt.Errorf("download audit archive: %w", err)
+	}
+	sealed, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return Archive{}, err
+	}
+	records, err := a.open(archive, sealed)
+	if err != nil {
+		return Archive{}, err
+	}
+	restored := a.now()
+	if err := a.repo.Restore(ctx, id, records, restored); err != nil {
+		return Archive{}, err
+	}
+	archive.RestoredAt = &restored
+	a.logger.Info("restored audit archive", "archive", id, "records", len(records))
+	return archive, nil
+}
+
+func (a *Archiver) open(archive Archive, sealed []byte) ([]Record, error) {
+	corrupt := func(format string, args ...any) error {
+		return ErrArchiveCorrupt{ID: archive.ID, Reason: fmt.Sprintf(format, args...)}
+	}
+	if digest := sha256.Sum256(sealed); hex.EncodeToString(digest[:]) != archive.SHA256 {
+		return nil, corrupt("the stored object does not match its digest")
+	}
+	nonceSize := a.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, corrupt("the stored object is truncated")
+	}
+	plain, err := a.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(archive.ID))
+	if err != nil {
+		return nil, corrupt("the stored object cannot be decrypted")
+	}
+	decompressor, err := gzip.NewReader(bytes.NewReader(plain))
+	if err != nil {
+		return nil, corrupt("the records are not compressed")
+	}
+	records := make([]Record, 0, archive.Records)
+	scanner := bufio.NewScanner(decompressor)
+	scanner.Buffer(nil, 16<<20)
+	seq, prevHash := archive.FirstSeq, archive.PrevHash
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, corrupt("record %d cannot be decoded", seq)
+		}
+		if alert := check(record, seq, prevHash); alert != nil {
+			return nil, corrupt("%s at record %d", alert.Detail, alert.Seq)
+		}
+		records = append(records, record)
+		seq, prevHash = record.Seq+1, record.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, corrupt("the records cannot be read")
+	}
+	if len(records) != archive.Records || prevHash != archive.LastHash {
+		return nil, corrupt("expected %d records ending in hash %s", archive.Records, archive.LastHash)
+	}
+	return records, nil
+}
+
+// Evict drops the rehydrated records of an archive; the archive itself stays in the store.
+func (a *Archiver) Evict(ctx context.Context, id string) error {
+	if _, err := a.repo.GetArchive(ctx, id); err != nil {
+		return err
+	}
+	return a.repo.Evict(ctx, id)
+}
+
+func (a *Archiver) Restored(ctx context.Context, filter RestoredFilter) ([]RestoredRecord, error) {
+	return a.repo.ListRestored(ctx, filter)
+}
@@ -0,0 +1,333 @@
+// Package audit keeps a tamper-evident ledger of KubeChat's audit trails. Every record carries the
+// SHA-256 hash of the record before it, so altering, removing or reordering a stored record breaks
+// the chain from that point on. A background job verifies the chain and raises a TamperAlert where
+// it breaks; after investigating, an admin re-anchors the chain to accept its current state.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/pramodksahoo/kubechat/backend/internal/alerting"
+)
+
+// Source names the audit trail a record was copied from.
+type Source string
+
+const (
+	SourceImpersonation  Source = "impersonation"
+	SourceMFA            Source = "mfa"
+	SourceSignedRequests Source = "signed_requests"
+	SourceApprovals      Source = "approvals"
+	SourcePrivacy        Source = "privacy"
+	SourceCompliance     Source = "compliance"
+	SourceAnomaly        Source = "anomaly"
+	SourceBreakers       Source = "breakers"
+	SourceBudgets        Source = "budgets"
+	SourceUsers          Source = "users"
+	// SourceLedger records re-anchoring of the ledger itself.
+	SourceLedger Source = "ledger"
+)
+
+// AlertStreamID is the SSE stream tamper alerts are published on as tamper_alert events.
+const AlertStreamID = "audit-tamper-alerts"
+
+// Record is one link of the chain. Entry is the audit entry as it was written to its own trail.
+type Record struct {
+	Seq      int64           `json:"seq"`
+	Source   Source          `json:"source"`
+	Entry    json.RawMessage `json:"entry"`
+	At       time.Time       `json:"at"`
+	PrevHash string          `json:"prevHash"`
+	Hash     string          `json:"hash"`
+}
+
+// digest is the hex SHA-256 of the record's sequence number, source, time, entry and the hash of
+// the record before it, one per line.
+func (r Record) digest() string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		strconv.FormatInt(r.Seq, 10),
+		string(r.Source),
+		r.At.UTC().Format(time.RFC3339Nano),
+		r.PrevHash,
+		string(r.Entry),
+	}, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Anchor accepts the chain up to and including record Seq, whose hash was Hash, so verification
+// starts after it. Anchors are made after a tamper alert has been investigated.
+type Anchor struct {
+	ID     string    `json:"id"`
+	Seq    int64     `json:"seq"`
+	Hash   string    `json:"hash"`
+	Actor  string    `json:"actor"`
+	Reason string    `json:"reason"`
+	At     time.Time `json:"at"`
+}
+
+type TamperKind string
+
+const (
+	// TamperAltered is a record whose contents no longer match its hash.
+	TamperAltered TamperKind = "altered"
+	// TamperBrokenLink is a record that does not link to the record stored before it.
+	TamperBrokenLink TamperKind = "broken_link"
+	// TamperMissing is a gap in the sequence where records were removed.
+	TamperMissing TamperKind = "missing"
+	// TamperTruncated is a chain ending before the last record this server appended.
+	TamperTruncated TamperKind = "truncated"
+)
+
+// TamperAlert reports where verification found the chain broken.
+type TamperAlert struct {
+	Seq        int64      `json:"seq"`
+	Kind       TamperKind `json:"kind"`
+	Detail     string     `json:"detail"`
+	DetectedAt time.Time  `json:"detectedAt"`
+}
+
+// Alert describes the tamper alert for alerting; tampering is always critical.
+func (a TamperAlert) Alert() alerting.Alert {
+	return alerting.Alert{
+		Type:     "audit.tamper",
+		Severity: alerting.SeverityCritical,
+		Title:    "Audit ledger tampered with",
+		Summary:  fmt.Sprintf("Record %d of the audit ledger failed verification (%s): %s", a.Seq, a.Kind, a.Detail),
+		DedupKey: fmt.Sprintf("audit.tamper/%d", a.Seq),
+		Details:  map[string]any{"seq": a.Seq, "kind": a.Kind, "detail": a.Detail},
+		At:       a.DetectedAt,
+	}
+}
+
+// Verification is the outcome of one walk over the chain.
+type Verification struct {
+	VerifiedAt time.Time `json:"verifiedAt"`
+	// Through is the last record found intact.
+	Through int64        `json:"through"`
+	Records int          `json:"records"`
+	Intact  bool         `json:"intact"`
+	Alert   *TamperAlert `json:"alert,omitempty"`
+	Anchor  *Anchor      `json:"anchor,omitempty"`
+}
+
+type ErrInvalidRequest struct {
+	Reason string
+}
+
+func (e ErrInvalidRequest) Error() string {
+	return e.Reason
+}
+
+// verifyPageSize bounds the records loaded at once while walking the chain.
+const verifyPageSize = 500
+
+// VerifyInterval is how often the server walks the chain in the background.
+const VerifyInterval = 15 * time.Minute
+
+// Ledger appends audit entries to the chain and verifies it.
+type Ledger struct {
+	repo   Repository
+	logger *log.Logger
+	now    func() time.Time
+	alerts chan TamperAlert
+
+	mu sync.Mutex
+	// head is the last record this server appended, to detect the end of the chain being cut off.
+	head *Record
+	last *Verification
+}
+
+func NewLedger(repo Repository, logger *log.Logger) *Ledger {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Ledger{
+		repo:   repo,
+		logger: logger,
+		now:    func() time.Time { return time.Now().UTC() },
+		alerts: make(chan TamperAlert, 16),
+	}
+}
+
+// Alerts delivers a TamperAlert each time verification finds the chain broken at a new place.
+// Alerts are dropped, though still logged, while nobody receives them.
+func (l *Ledger) Alerts() <-chan TamperAlert {
+	return l.alerts
+}
+
+// Append links entry to the end of the chain as a record from source.
+func (l *Ledger) Append(ctx context.Context, source Source, entry any) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	record, err := l.repo.Append(ctx, func(last *Record) Record {
+		return l.link(last, source, data)
+	})
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.head == nil || record.Seq > l.head.Seq {
+		l.head = &record
+	}
+	return nil
+}
+
+// link builds the record following last. Times are kept to the microsecond, the precision the
+// database stores, so hashes can be recomputed from stored records.
+func (l *Ledger) link(last *Record, source Source, entry json.RawMessage) Record {
+	record := Record{Seq: 1, Source: source, Entry: entry, At: l.now().Truncate(time.Microsecond)}
+	if last != nil {
+		record.Seq, record.PrevHash = last.Seq+1, last.Hash
+	}
+	record.Hash = record.digest()
+	return record
+}
+
+// Records returns up to limit records following record after, in chain order.
+func (l *Ledger) Records(ctx context.Context, after int64, limit int) ([]Record, error) {
+	return l.repo.List(ctx, after, limit)
+}
+
+// Status returns the outcome of the last verification, or false before the first one.
+func (l *Ledger) Status() (Verification, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.last == nil {
+		return Verification{}, false
+	}
+	return *l.last, true
+}
+
+// Verify walks the chain from the latest anchor and stops at the first record that is altered,
+// missing or not linked to its predecessor. An alert is raised unless the last verification
+// already reported the chain broken at the same place.
+func (l *Ledger) Verify(ctx context.Context) (Verification, error) {
+	anchor, err := l.repo.LatestAnchor(ctx)
+	if err != nil {
+		return Verification{}, err
+	}
+	result := Verification{Intact: true, Anchor: anchor}
+	var prevHash string
+	if anchor != nil {
+		result.Through, prevHash = anchor.Seq, anchor.Hash
+	}
+	for result.Alert == nil {
+		records, err := l.repo.List(ctx, result.Through, verifyPageSize)
+		if err != nil {
+			return Verification{}, err
+		}
+		for _, record := range records {
+			if alert := check(record, result.Through+1, prevHash); alert != nil {
+				result.Alert = alert
+				break
+			}
+			result.Through, prevHash = record.Seq, record.Hash
+			result.Records++
+		}
+		if len(records) < verifyPageSize {
+			break
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if result.Alert == nil && l.head != nil && result.Through < l.head.Seq {
+		result.Alert = &TamperAlert{Seq: result.Through + 1, Kind: TamperTruncated,
+			Detail: fmt.Sprintf("the chain ends at record %d but record %d was appended", result.Through, l.head.Seq)}
+	}
+	result.VerifiedAt = l.now()
+	if result.Alert != nil {
+		result.Intact = false
+		result.Alert.DetectedAt = result.VerifiedAt
+		if l.last == nil || l.last.Alert == nil || l.last.Alert.Seq != result.Alert.Seq || l.last.Alert.Kind != result.Alert.Kind {
+			l.raise(*result.Alert)
+		}
+	}
+	l.last = &result
+	return result, nil
+}
+
+func check(record Record, seq int64, prevHash string) *TamperAlert {
+	switch {
+	case record.Seq != seq:
+		return &TamperAlert{Seq: seq, Kind: TamperMissing, Detail: fmt.Sprintf("records %d to %d are missing", seq, record.Seq-1)}
+	case record.PrevHash != prevHash:
+		return &TamperAlert{Seq: record.Seq, Kind: TamperBrokenLink, Detail: "the record does not link to the hash of the record before it"}
+	case record.digest() != record.Hash:
+		return &TamperAlert{Seq: record.Seq, Kind: TamperAltered, Detail: "the record's contents do not match its hash"}
+	}
+	return nil
+}
+
+// raise is called with l.mu held.
+func (l *Ledger) raise(alert TamperAlert) {
+	l.logger.Error("audit ledger tampered with", "event", "security", "seq", alert.Seq, "kind", alert.Kind, "detail", alert.Detail)
+	select {
+	case l.alerts <- alert:
+	default:
+		l.logger.Warn("tamper alert dropped, no receiver is keeping up", "seq", alert.Seq)
+	}
+}
+
+// Reanchor accepts the chain as it stands once an alert has been investigated: verification then
+// starts after the current last record. The anchor is itself appended to the chain, so re-anchoring
+// stays on the record.
+func (l *Ledger) Reanchor(ctx context.Context, actor, reason string) (Anchor, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return Anchor{}, ErrInvalidRequest{Reason: "a reason describing the investigation is required"}
+	}
+	var anchor Anchor
+	record, err := l.repo.Append(ctx, func(last *Record) Record {
+		anchor = Anchor{ID: uuid.NewString(), Actor: actor, Reason: reason, At: l.now()}
+		if last != nil {
+			anchor.Seq, anchor.Hash = last.Seq, last.Hash
+		}
+		entry, _ := json.Marshal(anchor)
+		return l.link(last, SourceLedger, entry)
+	})
+	if err != nil {
+		return Anchor{}, err
+	}
+	if err := l.repo.SaveAnchor(ctx, anchor); err != nil {
+		return Anchor{}, err
+	}
+	l.mu.Lock()
+	l.head = &record
+	l.last = nil
+	l.mu.Unlock()
+	l.logger.Warn("audit ledger re-anchored", "event", "security", "actor", actor, "seq", anchor.Seq, "reason", reason)
+	if _, err := l.Verify(ctx); err != nil {
+		l.logger.Error("failed to verify the re-anchored audit ledger", "error", err)
+	}
+	return anchor, nil
+}
+
+// Run verifies the chain every interval until ctx is done.
+func (l *Ledger) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := l.Verify(ctx); err != nil && ctx.Err() == nil {
+			l.logger.Error("failed to verify audit ledger", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/attachments"
+)
+
+type testEntry struct {
+	Actor  string `json:"actor"`
+	Action string `json:"action"`
+}
+
+func newTestLedger(t *testing.T, entries int) (*Ledger, *MemoryRepository) {
+	t.Helper()
+	repo := NewMemoryRepository()
+	ledger := NewLedger(repo, nil)
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	ledger.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	for i := 0; i < entries; i++ {
+		if err := ledger.Append(context.Background(), SourceApprovals, testEntry{Actor: "alice", Action: "approved"}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	return ledger, repo
+}
+
+func receive(t *testing.T, ledger *Ledger) TamperAlert {
+	t.Helper()
+	select {
+	case alert := <-ledger.Alerts():
+		return alert
+	default:
+		t.Fatal("expected a tamper alert")
+		return TamperAlert{}
+	}
+}
+
+func TestLedgerChainsRecordsAndVerifiesThem(t *testing.T) {
+	ledger, repo := newTestLedger(t, 3)
+	if repo.records[0].PrevHash != "" || repo.records[1].PrevHash != repo.records[0].Hash || repo.records[2].PrevHash != repo.records[1].Hash {
+		t.Fatalf("expected every record to link to the one before it, got %+v", repo.records)
+	}
+
+	status, err := ledger.Verify(context.Background())
+	if err != nil || !status.Intact || status.Through != 3 || status.Records != 3 {
+		t.Fatalf("expected an intact chain of 3 records, got %+v (%v)", status, err)
+	}
+	select {
+	case alert := <-ledger.Alerts():
+		t.Fatalf("expected no alert for an intact chain, got %+v", alert)
+	default:
+	}
+}
+
+func TestLedgerAlertsOnceWhereTheChainBreaks(t *testing.T) {
+	ctx := context.Background()
+	ledger, repo := newTestLedger(t, 4)
+	repo.records[1].Entry = json.RawMessage(`{"actor":"mallory","action":"approved"}`)
+
+	status, err := ledger.Verify(ctx)
+	if err != nil || status.Intact || status.Through != 1 {
+		t.Fatalf("expected verification to stop before the altered record, got %+v (%v)", status, err)
+	}
+	if alert := receive(t, ledger); alert.Seq != 2 || alert.Kind != TamperAltered {
+		t.Fatalf("expected an alert for record 2, got %+v", alert)
+	}
+	if _, err := ledger.Verify(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case alert := <-ledger.Alerts():
+		t.Fatalf("expected the known break not to alert again, got %+v", alert)
+	default:
+	}
+
+	repo.records = append(repo.records[:2], repo.records[3:]...)
+	repo.records[1].Entry = json.RawMessage(`{"actor":"alice","action":"approved"}`)
+	if _, err := ledger.Verify(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if alert := receive(t, ledger); alert.Seq != 3 || alert.Kind != TamperMissing {
+		t.Fatalf("expected the removed record to be reported, got %+v", alert)
+	}
+
+	repo.records = repo.records[:2]
+	status, _ = ledger.Verify(ctx)
+	if alert := receive(t, ledger); alert.Kind != TamperTruncated || status.Intact {
+		t.Fatalf("expected the cut-off end of the chain to be reported, got %+v", alert)
+	}
+}
+
+func TestReanchorAcceptsTheInvestigatedChain(t *testing.T) {
+	ctx := context.Background()
+	ledger, repo := newTestLedger(t, 3)
+	repo.records[0].Entry = json.RawMessage(`{}`)
+	if _, err := ledger.Verify(ctx); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, ledger)
+
+	if _, err := ledger.Reanchor(ctx, "admin", " "); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected a reason to be required, got %v", err)
+	}
+	anchor, err := ledger.Reanchor(ctx, "admin", "restored from backup, see INC-42")
+	if err != nil || anchor.Seq != 3 || anchor.Hash != repo.records[2].Hash {
+		t.Fatalf("expected the chain anchored at its last record, got %+v (%v)", anchor, err)
+	}
+	status, ok := ledger.Status()
+	if !ok || !status.Intact || status.Through != 4 || status.Anchor == nil || status.Anchor.ID != anchor.ID {
+		t.Fatalf("expected the chain intact from the anchor, got %+v", status)
+	}
+	if last := repo.records[len(repo.records)-1]; last.Source != SourceLedger {
+		t.Fatalf("expected the anchor to be chained, got %+v", last)
+	}
+
+	if err := ledger.Append(ctx, SourceMFA, testEntry{Actor: "bob", Action: "verified"}); err != nil {
+		t.Fatal(err)
+	}
+	repo.records[4].PrevHash = "forged"
+	if _, err := ledger.Verify(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if alert := receive(t, ledger); alert.Seq != 5 || alert.Kind != TamperBrokenLink {
+		t.Fatalf("expected tampering after the anchor to be reported, got %+v", alert)
+	}
+}
+
+func TestArchiveMovesOldRecordsOutAndRestoresThem(t *testing.T) {
+	ledger, repo := newTestLedger(t, 5)
+	store, err := attachments.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	archiver, err := NewArchiver(ledger, repo, store, "archive-secret", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+
+	// Records 1 to 3 were appended before the cutoff.
+	removed, err := archiver.Archive(ctx, time.Date(2026, 3, 10, 12, 0, 4, 0, time.UTC))
+	if err != nil || removed != 3 {
+		t.Fatalf("expected 3 records to be archived, got %d (%v)", removed, err)
+	}
+	if repo.records[0].Seq != 4 || repo.records[len(repo.records)-1].Source != SourceLedger {
+		t.Fatalf("expected the archived records to be removed and the archive noted on the chain, got %+v", repo.records)
+	}
+	if status, err := ledger.Verify(ctx); err != nil || !status.Intact || status.Anchor == nil || status.Anchor.Seq != 3 {
+		t.Fatalf("expected the chain to verify from the archive's anchor, got %+v (%v)", status, err)
+	}
+
+	archives, _ := archiver.Archives(ctx)
+	if len(archives) != 1 || archives[0].FirstSeq != 1 || archives[0].LastSeq != 3 || archives[0].Records != 3 {
+		t.Fatalf("unexpected archives %+v", archives)
+	}
+	archive := archives[0]
+	body, err := store.Open(ctx, archive.Key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sealed, _ := io.ReadAll(body)
+	body.Close()
+	if json.Valid(sealed) || len(sealed) == 0 {
+		t.Fatalf("expected the archive to be stored encrypted")
+	}
+
+	restored, err := archiver.Restore(ctx, archive.ID)
+	if err != nil || restored.RestoredAt == nil {
+		t.Fatalf("expected the archive to be restored, got %+v (%v)", restored, err)
+	}
+	records, err := archiver.Restored(ctx, RestoredFilter{ArchiveID: archive.ID, After: 1})
+	if err != nil || len(records) != 2 || records[0].Seq != 2 || records[0].ArchiveID != archive.ID {
+		t.Fatalf("expected records 2 and 3 to be queryable, got %+v (%v)", records, err)
+	}
+	if err := archiver.Evict(ctx, archive.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if records, _ := archiver.Restored(ctx, RestoredFilter{}); len(records) != 0 {
+		t.Fatalf("expected evicted records to be gone, got %+v", records)
+	}
+
+	other, _ := NewArchiver(ledger, repo, store, "another-secret", nil)
+	if _, err := other.Restore(ctx, archive.ID); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected an archive sealed with another key to be refused, got %v", err)
+	}
+	sealed[len(sealed)-1] ^= 1
+	if err := store.Put(ctx, archive.Key, archiveContentType, sealed); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := archiver.Restore(ctx, archive.ID); !errors.As(err, &ErrArchiveCorrupt{}) {
+		t.Fatalf("expected a changed archive to be reported corrupt, got %v", err)
+	}
+}
+
+func TestArchiveRefusesABrokenChain(t *testing.T) {
+	ledger, repo := newTestLedger(t, 3)
+	store, _ := attachments.NewFileStore(t.TempDir())
+	archiver, err := NewArchiver(ledger, repo, store, "archive-secret", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	repo.records[1].Entry = json.RawMessage(`{"actor":"mallory","action":"approved"}`)
+	if _, err := archiver.Archive(context.Background(), time.Now()); !errors.As(err, &ErrChainBroken{}) {
+		t.Fatalf("expected archiving to be refused, got %v", err)
+	}
+	if len(repo.records) != 3 {
+		t.Fatalf("expected no records to be removed, got %d", len(repo.records))
+	}
+}
+
+func TestPseudonymizeReplacesNamesWithinValues(t *testing.T) {
+	ledger, repo := newTestLedger(t, 0)
+	ctx := context.Background()
+	ledger.Append(ctx, SourceMFA, map[string]any{"detail": "deleted by alice", "path": "/home/alice/.kube/config"})
+	ledger.Append(ctx, SourceMFA, map[string]any{"detail": "flagged for malice by alice-bot"})
+
+	changed, err := ledger.Pseudonymize(ctx, "alice", "erased-1")
+	if err != nil || changed != 1 {
+		t.Fatalf("expected one record to be pseudonymized, got %d (%v)", changed, err)
+	}
+	if string(repo.records[0].Entry) != `{"detail":"deleted by erased-1","path":"/home/erased-1/.kube/config"}` {
+		t.Fatalf("expected alice replaced within the values, got %s", repo.records[0].Entry)
+	}
+	if string(repo.records[1].Entry) != `{"detail":"flagged for malice by alice-bot"}` {
+		t.Fatalf("expected longer names to be left alone, got %s", repo.records[1].Entry)
+	}
+	if mentions, _ := ledger.Mentions(ctx, "alice"); len(mentions) != 0 {
+		t.Fatalf("expected alice to be gone from the ledger, got %+v", mentions)
+	}
+}
+
+func TestPseudonymizeRehashesTheChain(t *testing.T) {
+	ledger, repo := newTestLedger(t, 2)
+	ctx := context.Background()
+	ledger.Append(ctx, SourceMFA, map[string]any{"user": "bob", "approvers": []string{"carol", "alice"}, "attempts": 3})
+	ledger.Append(ctx, SourceMFA, testEntry{Actor: "bob", Action: "enrolled"})
+	if _, err := ledger.Reanchor(ctx, "admin", "investigated"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mentions, err := ledger.Mentions(ctx, "alice")
+	if err != nil || len(mentions) != 3 {
+		t.Fatalf("expected three records naming alice, got %d (%v)", len(mentions), err)
+	}
+	changed, err := ledger.Pseudonymize(ctx, "alice", "erased-1")
+	if err != nil || changed != 3 {
+		t.Fatalf("expected three records to be pseudonymized, got %d (%v)", changed, err)
+	}
+	if mentions, _ := ledger.Mentions(ctx, "alice"); len(mentions) != 0 {
+		t.Fatalf("expected alice to be gone from the ledger, got %+v", mentions)
+	}
+	if string(repo.records[2].Entry) != `{"approvers":["carol","erased-1"],"attempts":3,"user":"bob"}` {
+		t.Fatalf("unexpected pseudonymized entry %s", repo.records[2].Entry)
+	}
+	if string(repo.records[3].Entry) != `{"actor":"bob","action":"enrolled"}` {
+		t.Fatalf("expected entries without alice to be kept byte for byte, got %s", repo.records[3].Entry)
+	}
+	last := repo.records[len(repo.records)-1]
+	if last.Source != SourceLedger || !strings.Contains(string(last.Entry), `"pseudonym":"erased-1"`) {
+		t.Fatalf("expected the rewrite to be noted on the chain, got %+v", last)
+	}
+	if status, err := ledger.Verify(ctx); err != nil || !status.Intact {
+		t.Fatalf("expected the rehashed chain to verify from the moved anchor, got %+v (%v)", status, err)
+	}
+
+	repo.records[len(repo.records)-1].Entry = json.RawMessage(`{"action":"pseudonymized"}`)
+	if _, err := ledger.Pseudonymize(ctx, "bob", "erased-2"); !errors.As(err, &ErrChainBroken{}) {
+		t.Fatalf("expected a tampered chain not to be rehashed, got %v", err)
+	}
+}
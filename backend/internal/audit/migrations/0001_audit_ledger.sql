@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS audit_ledger (
+    seq BIGINT PRIMARY KEY,
+    source TEXT NOT NULL,
+    -- Kept as text, not JSONB, so the hashed bytes come back unchanged.
+    entry TEXT NOT NULL,
+    at TIMESTAMPTZ NOT NULL,
+    prev_hash TEXT NOT NULL,
+    hash TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS audit_ledger_anchors (
+    id TEXT PRIMARY KEY,
+    seq BIGINT NOT NULL,
+    hash TEXT NOT NULL,
+    actor TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    at TIMESTAMPTZ NOT NULL
+);
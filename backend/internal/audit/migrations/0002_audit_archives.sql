@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS audit_archives (
+    id TEXT PRIMARY KEY,
+    object_key TEXT NOT NULL,
+    first_seq BIGINT NOT NULL,
+    last_seq BIGINT NOT NULL,
+    records INTEGER NOT NULL,
+    first_at TIMESTAMPTZ NOT NULL,
+    last_at TIMESTAMPTZ NOT NULL,
+    prev_hash TEXT NOT NULL,
+    last_hash TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    sha256 TEXT NOT NULL,
+    key_id TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    retain_until TIMESTAMPTZ NOT NULL,
+    restored_at TIMESTAMPTZ
+);
+
+-- Records rehydrated from archives, queryable until they are evicted again.
+CREATE TABLE IF NOT EXISTS audit_restored (
+    archive_id TEXT NOT NULL REFERENCES audit_archives (id),
+    seq BIGINT NOT NULL,
+    source TEXT NOT NULL,
+    entry TEXT NOT NULL,
+    at TIMESTAMPTZ NOT NULL,
+    prev_hash TEXT NOT NULL,
+    hash TEXT NOT NULL,
+    PRIMARY KEY (archive_id, seq)
+);
+
+CREATE INDEX IF NOT EXISTS audit_restored_seq ON audit_restored (seq);
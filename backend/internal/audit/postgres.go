@@ -0,0 +1,328 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pramodksahoo/kubechat/backend/internal/database"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// appendLockKey is the transaction-scoped advisory lock that serializes appends between replicas.
+const appendLockKey = 0x6b63_6175_6469_74
+
+type PostgresRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresRepository applies the audit ledger schema migrations and returns a repository backed by db.
+func NewPostgresRepository(ctx context.Context, db *sqlx.DB) (*PostgresRepository, error) {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Migrate(ctx, db, "audit", sub); err != nil {
+		return nil, err
+	}
+	return &PostgresRepository{db: db}, nil
+}
+
+type recordRow struct {
+	Seq      int64     `db:"seq"`
+	Source   string    `db:"source"`
+	Entry    string    `db:"entry"`
+	At       time.Time `db:"at"`
+	PrevHash string    `db:"prev_hash"`
+	Hash     string    `db:"hash"`
+}
+
+func (row recordRow) record() Record {
+	return Record{
+		Seq:      row.Seq,
+		Source:   Source(row.Source),
+		Entry:    json.RawMessage(row.Entry),
+		At:       row.At.UTC(),
+		PrevHash: row.PrevHash,
+		Hash:     row.Hash,
+	}
+}
+
+type anchorRow struct {
+	ID     string    `db:"id"`
+	Seq    int64     `db:"seq"`
+	Hash   string    `db:"hash"`
+	Actor  string    `db:"actor"`
+	Reason string    `db:"reason"`
+	At     time.Time `db:"at"`
+}
+
+const recordColumns = `seq, source, entry, at, prev_hash, hash`
+
+func (r *PostgresRepository) Append(ctx context.Context, next func(last *Record) Record) (Record, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Record{}, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, appendLockKey); err != nil {
+		return Record{}, err
+	}
+	var last *Record
+	var row recordRow
+	switch err := tx.GetContext(ctx, &row, `SELECT `+recordColumns+` FROM audit_ledger ORDER BY seq DESC LIMIT 1`); {
+	case err == nil:
+		record := row.record()
+		last = &record
+	case !errors.Is(err, sql.ErrNoRows):
+		return Record{}, err
+	}
+	record := next(last)
+	if _, err := tx.NamedExecContext(ctx, `INSERT INTO audit_ledger (`+recordColumns+`)
+		VALUES (:seq, :source, :entry, :at, :prev_hash, :hash)`, recordRow{
+		Seq:      record.Seq,
+		Source:   string(record.Source),
+		Entry:    string(record.Entry),
+		At:       record.At,
+		PrevHash: record.PrevHash,
+		Hash:     record.Hash,
+	}); err != nil {
+		return Record{}, err
+	}
+	return record, tx.Commit()
+}
+
+func (r *PostgresRepository) List(ctx context.Context, after int64, limit int) ([]Record, error) {
+	query := `SELECT ` + recordColumns + ` FROM audit_ledger WHERE seq > $1 ORDER BY seq`
+	args := []any{after}
+	if limit > 0 {
+		query += ` LIMIT $2`
+		args = append(args, limit)
+	}
+	var rows []recordRow
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, err
+	}
+	records := make([]Record, 0, len(rows))
+	for _, row := range rows {
+		records = append(records, row.record())
+	}
+	return records, nil
+}
+
+func (r *PostgresRepository) SaveAnchor(ctx context.Context, anchor Anchor) error {
+	_, err := r.db.NamedExecContext(ctx, `INSERT INTO audit_ledger_anchors (id, seq, hash, actor, reason, at)
+		VALUES (:id, :seq, :hash, :actor, :reason, :at)`, anchorRow{
+		ID:     anchor.ID,
+		Seq:    anchor.Seq,
+		Hash:   anchor.Hash,
+		Actor:  anchor.Actor,
+		Reason: anchor.Reason,
+		At:     anchor.At,
+	})
+	return err
+}
+
+func (r *PostgresRepository) LatestAnchor(ctx context.Context) (*Anchor, error) {
+	var row anchorRow
+	err := r.db.GetContext(ctx, &row, `SELECT id, seq, hash, actor, reason, at FROM audit_ledger_anchors ORDER BY seq DESC, at DESC LIMIT 1`)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Anchor{ID: row.ID, Seq: row.Seq, Hash: row.Hash, Actor: row.Actor, Reason: row.Reason, At: row.At.UTC()}, nil
+}
+
+func (r *PostgresRepository) Rewrite(ctx context.Context, from int64, rewrite func(records []Record) []Record) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, appendLockKey); err != nil {
+		return err
+	}
+	var rows []recordRow
+	if err := tx.SelectContext(ctx, &rows, `SELECT `+recordColumns+` FROM audit_ledger WHERE seq >= $1 ORDER BY seq`, from); err != nil {
+		return err
+	}
+	records := make([]Record, 0, len(rows))
+	for _, row := range rows {
+		records = append(records, row.record())
+	}
+	for _, record := range rewrite(records) {
+		if _, err := tx.ExecContext(ctx, `UPDATE audit_ledger SET entry = $2, prev_hash = $3, hash = $4 WHERE seq = $1`,
+			record.Seq, string(record.Entry), record.PrevHash, record.Hash); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE audit_ledger_anchors a SET hash = l.hash
+		FROM audit_ledger l WHERE a.seq = l.seq AND a.seq >= $1`, from); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PostgresRepository) DeleteThrough(ctx context.Context, seq int64) (int, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM audit_ledger WHERE seq <= $1`, seq)
+	if err != nil {
+		return 0, err
+	}
+	removed, err := result.RowsAffected()
+	return int(removed), err
+}
+
+type archiveRow struct {
+	ID          string     `db:"id"`
+	Key         string     `db:"object_key"`
+	FirstSeq    int64      `db:"first_seq"`
+	LastSeq     int64      `db:"last_seq"`
+	Records     int        `db:"records"`
+	From        time.Time  `db:"first_at"`
+	To          time.Time  `db:"last_at"`
+	PrevHash    string     `db:"prev_hash"`
+	LastHash    string     `db:"last_hash"`
+	Size        int64      `db:"size"`
+	SHA256      string     `db:"sha256"`
+	KeyID       string     `db:"key_id"`
+	CreatedAt   time.Time  `db:"created_at"`
+	RetainUntil time.Time  `db:"retain_until"`
+	RestoredAt  *time.Time `db:"restored_at"`
+}
+
+func (row archiveRow) archive() Archive {
+	archive := Archive(row)
+	archive.From, archive.To = row.From.UTC(), row.To.UTC()
+	archive.CreatedAt, archive.RetainUntil = row.CreatedAt.UTC(), row.RetainUntil.UTC()
+	if row.RestoredAt != nil {
+		restored := row.RestoredAt.UTC()
+		archive.RestoredAt = &restored
+	}
+	return archive
+}
+
+const archiveColumns = `id, object_key, first_seq, last_seq, records, first_at, last_at, prev_hash, last_hash, size, sha256, key_id, created_at, retain_until, restored_at`
+
+func (r *PostgresRepository) SaveArchive(ctx context.Context, archive Archive) error {
+	_, err := r.db.NamedExecContext(ctx, `INSERT INTO audit_archives (`+archiveColumns+`)
+		VALUES (:id, :object_key, :first_seq, :last_seq, :records, :first_at, :last_at, :prev_hash, :last_hash, :size, :sha256, :key_id, :created_at, :retain_until, :restored_at)`,
+		archiveRow(archive))
+	return err
+}
+
+func (r *PostgresRepository) ListArchives(ctx context.Context) ([]Archive, error) {
+	var rows []archiveRow
+	if err := r.db.SelectContext(ctx, &rows, `SELECT `+archiveColumns+` FROM audit_archives ORDER BY first_seq DESC`); err != nil {
+		return nil, err
+	}
+	archives := make([]Archive, 0, len(rows))
+	for _, row := range rows {
+		archives = append(archives, row.archive())
+	}
+	return archives, nil
+}
+
+func (r *PostgresRepository) GetArchive(ctx context.Context, id string) (Archive, error) {
+	var row archiveRow
+	err := r.db.GetContext(ctx, &row, `SELECT `+archiveColumns+` FROM audit_archives WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Archive{}, ErrArchiveNotFound{ID: id}
+	}
+	if err != nil {
+		return Archive{}, err
+	}
+	return row.archive(), nil
+}
+
+// restoreChunk bounds the rows inserted by one statement.
+const restoreChunk = 1000
+
+type restoredRow struct {
+	recordRow
+	ArchiveID string `db:"archive_id"`
+}
+
+func (r *PostgresRepository) Restore(ctx context.Context, id string, records []Record, at time.Time) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM audit_restored WHERE archive_id = $1`, id); err != nil {
+		return err
+	}
+	for start := 0; start < len(records); start += restoreChunk {
+		chunk := records[start:min(start+restoreChunk, len(records))]
+		rows := make([]restoredRow, 0, len(chunk))
+		for _, record := range chunk {
+			rows = append(rows, restoredRow{ArchiveID: id, recordRow: recordRow{
+				Seq:      record.Seq,
+				Source:   string(record.Source),
+				Entry:    string(record.Entry),
+				At:       record.At,
+				PrevHash: record.PrevHash,
+				Hash:     record.Hash,
+			}})
+		}
+		if _, err := tx.NamedExecContext(ctx, `INSERT INTO audit_restored (archive_id, `+recordColumns+`)
+			VALUES (:archive_id, :seq, :source, :entry, :at, :prev_hash, :hash)`, rows); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE audit_archives SET restored_at = $2 WHERE id = $1`, id, at); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PostgresRepository) Evict(ctx context.Context, id string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM audit_restored WHERE archive_id = $1`, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE audit_archives SET restored_at = NULL WHERE id = $1`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PostgresRepository) ListRestored(ctx context.Context, filter RestoredFilter) ([]RestoredRecord, error) {
+	query := `SELECT archive_id, ` + recordColumns + ` FROM audit_restored WHERE seq > $1`
+	args := []any{filter.After}
+	if filter.ArchiveID != "" {
+		args = append(args, filter.ArchiveID)
+		query += fmt.Sprintf(` AND archive_id = $%d`, len(args))
+	}
+	if filter.Source != "" {
+		args = append(args, string(filter.Source))
+		query += fmt.Sprintf(` AND source = $%d`, len(args))
+	}
+	query += ` ORDER BY seq`
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(` LIMIT $%d`, len(args))
+	}
+	var rows []restoredRow
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, err
+	}
+	restored := make([]RestoredRecord, 0, len(rows))
+	for _, row := range rows {
+		restored = append(restored, RestoredRecord{Record: row.record(), ArchiveID: row.ArchiveID})
+	}
+	return restored, nil
+}
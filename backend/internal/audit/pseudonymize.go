@@ -0,0 +1,198 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// RewriteRepository is implemented by repositories that can rewrite the chain in place, which
+// pseudonymizing a user's records requires.
+type RewriteRepository interface {
+	// Rewrite passes the records from sequence number from on to rewrite while appends are held,
+	// stores the records it returns over those with the same sequence numbers and moves anchors on
+	// them to their new hashes.
+	Rewrite(ctx context.Context, from int64, rewrite func(records []Record) []Record) error
+}
+
+// Pseudonymization is appended to the chain when a user's records were rewritten. It names the
+// pseudonym only, never the user.
+type Pseudonymization struct {
+	Action    string `json:"action"`
+	Pseudonym string `json:"pseudonym"`
+	Records   int    `json:"records"`
+	From      int64  `json:"from"`
+	// PreviousHead is the hash the last record had before the chain was rehashed.
+	PreviousHead string `json:"previousHead"`
+}
+
+// Mentions returns the records whose entries name user in a value, in chain order.
+func (l *Ledger) Mentions(ctx context.Context, user string) ([]Record, error) {
+	var (
+		mentions []Record
+		after    int64
+	)
+	for {
+		records, err := l.repo.List(ctx, after, verifyPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			if _, ok, err := replaceValue(record.Entry, user, ""); err != nil {
+				return nil, err
+			} else if ok {
+				mentions = append(mentions, record)
+			}
+		}
+		if len(records) < verifyPageSize {
+			return mentions, nil
+		}
+		after = records[len(records)-1].Seq
+	}
+}
+
+// Pseudonymize replaces user with pseudonym wherever a value of an entry names it and rehashes the
+// chain from the first record changed, so the chain still verifies. It refuses a chain that does
+// not verify, since rehashing would hide the tampering. The rewrite is itself appended to the
+// chain and returns how many records named the user.
+func (l *Ledger) Pseudonymize(ctx context.Context, user, pseudonym string) (int, error) {
+	rewriter, ok := l.repo.(RewriteRepository)
+	if !ok {
+		return 0, errors.New("the audit ledger store cannot rewrite records")
+	}
+	if user == "" || pseudonym == "" || user == pseudonym {
+		return 0, ErrInvalidRequest{Reason: "a user and a different pseudonym are required"}
+	}
+	verification, err := l.Verify(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !verification.Intact {
+		return 0, ErrChainBroken{Seq: verification.Alert.Seq}
+	}
+	mentions, err := l.Mentions(ctx, user)
+	if err != nil || len(mentions) == 0 {
+		return 0, err
+	}
+
+	note := Pseudonymization{Action: "pseudonymized", Pseudonym: pseudonym, From: mentions[0].Seq}
+	var rewriteErr error
+	err = rewriter.Rewrite(ctx, note.From, func(records []Record) []Record {
+		if len(records) == 0 {
+			return nil
+		}
+		note.PreviousHead = records[len(records)-1].Hash
+		prevHash := records[0].PrevHash
+		for i := range records {
+			entry, changed, err := replaceValue(records[i].Entry, user, pseudonym)
+			if err != nil {
+				rewriteErr = err
+				return nil
+			}
+			if changed {
+				records[i].Entry = entry
+				note.Records++
+			}
+			records[i].PrevHash = prevHash
+			records[i].Hash = records[i].digest()
+			prevHash = records[i].Hash
+		}
+		return records
+	})
+	if err = errors.Join(err, rewriteErr); err != nil {
+		return 0, err
+	}
+	l.mu.Lock()
+	l.last = nil
+	l.mu.Unlock()
+	l.logger.Warn("audit ledger records pseudonymized", "event", "compliance", "pseudonym", pseudonym, "records", note.Records, "from", note.From)
+	if err := l.Append(ctx, SourceLedger, note); err != nil {
+		return note.Records, err
+	}
+	_, err = l.Verify(ctx)
+	return note.Records, err
+}
+
+// replaceValue replaces old with value wherever a string in entry names it, as the whole string or
+// as a token within it such as "deleted by alice" or "/home/alice", and reports whether one did.
+// Entries without one are returned unchanged, byte for byte.
+func replaceValue(entry json.RawMessage, old, value string) (json.RawMessage, bool, error) {
+	quoted, err := json.Marshal(old)
+	if err != nil || !bytes.Contains(entry, quoted[1:len(quoted)-1]) {
+		return entry, false, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(entry))
+	decoder.UseNumber()
+	var document any
+	if err := decoder.Decode(&document); err != nil {
+		return nil, false, err
+	}
+	document, changed := replaceIn(document, old, value)
+	if !changed {
+		return entry, false, nil
+	}
+	replaced, err := json.Marshal(document)
+	return replaced, true, err
+}
+
+func replaceIn(value any, old, replacement string) (any, bool) {
+	changed := false
+	switch v := value.(type) {
+	case string:
+		return replaceToken(v, old, replacement)
+	case map[string]any:
+		for key, item := range v {
+			var ok bool
+			if v[key], ok = replaceIn(item, old, replacement); ok {
+				changed = true
+			}
+		}
+	case []any:
+		for i, item := range v {
+			var ok bool
+			if v[i], ok = replaceIn(item, old, replacement); ok {
+				changed = true
+			}
+		}
+	}
+	return value, changed
+}
+
+// replaceToken replaces the occurrences of old in s that are not part of a longer name, so "alice"
+// is replaced in "by alice," and "alice@example.com" but not in "malice" or "alice-bot".
+func replaceToken(s, old, replacement string) (string, bool) {
+	if old == "" {
+		return s, false
+	}
+	var out strings.Builder
+	last := 0
+	for from := 0; ; {
+		i := strings.Index(s[from:], old)
+		if i < 0 {
+			break
+		}
+		start, end := from+i, from+i+len(old)
+		before, _ := utf8.DecodeLastRuneInString(s[:start])
+		after, _ := utf8.DecodeRuneInString(s[end:])
+		if !nameRune(before) && !nameRune(after) {
+			out.WriteString(s[last:start])
+			out.WriteString(replacement)
+			last = end
+		}
+		from = end
+	}
+	if last == 0 {
+		return s, false
+	}
+	out.WriteString(s[last:])
+	return out.String(), true
+}
+
+// nameRune reports whether r can continue a user name; utf8.RuneError marks either end of the string.
+func nameRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-')
+}
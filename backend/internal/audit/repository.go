@@ -0,0 +1,190 @@
+package audit
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Repository stores the chain and its anchors.
+type Repository interface {
+	// Append stores the record next builds from the last record of the chain, or from nil while the
+	// chain is empty. Appends are serialized, also between replicas, so every record links to the
+	// one stored before it.
+	Append(ctx context.Context, next func(last *Record) Record) (Record, error)
+	// List returns up to limit records with a sequence number above after, in order.
+	List(ctx context.Context, after int64, limit int) ([]Record, error)
+	SaveAnchor(ctx context.Context, anchor Anchor) error
+	// LatestAnchor returns the most recent anchor, or nil when the chain was never re-anchored.
+	LatestAnchor(ctx context.Context) (*Anchor, error)
+}
+
+// MemoryRepository keeps the chain and its archive catalog in process memory; it is used when no
+// database is configured.
+type MemoryRepository struct {
+	mu       sync.Mutex
+	records  []Record
+	anchors  []Anchor
+	archives []Archive
+	restored map[string][]Record
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{}
+}
+
+func (r *MemoryRepository) Append(ctx context.Context, next func(last *Record) Record) (Record, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var last *Record
+	if len(r.records) > 0 {
+		last = &r.records[len(r.records)-1]
+	}
+	record := next(last)
+	r.records = append(r.records, record)
+	return record, nil
+}
+
+func (r *MemoryRepository) List(ctx context.Context, after int64, limit int) ([]Record, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	records := make([]Record, 0)
+	for _, record := range r.records {
+		if record.Seq <= after {
+			continue
+		}
+		records = append(records, record)
+		if limit > 0 && len(records) == limit {
+			break
+		}
+	}
+	return records, nil
+}
+
+func (r *MemoryRepository) SaveAnchor(ctx context.Context, anchor Anchor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.anchors = append(r.anchors, anchor)
+	return nil
+}
+
+func (r *MemoryRepository) LatestAnchor(ctx context.Context) (*Anchor, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.anchors) == 0 {
+		return nil, nil
+	}
+	anchor := r.anchors[len(r.anchors)-1]
+	return &anchor, nil
+}
+
+func (r *MemoryRepository) Rewrite(ctx context.Context, from int64, rewrite func(records []Record) []Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	start := sort.Search(len(r.records), func(i int) bool { return r.records[i].Seq >= from })
+	hashes := make(map[int64]string)
+	for _, record := range rewrite(slices.Clone(r.records[start:])) {
+		for i := start; i < len(r.records); i++ {
+			if r.records[i].Seq == record.Seq {
+				r.records[i] = record
+				hashes[record.Seq] = record.Hash
+			}
+		}
+	}
+	for i, anchor := range r.anchors {
+		if hash, ok := hashes[anchor.Seq]; ok {
+			r.anchors[i].Hash = hash
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRepository) DeleteThrough(ctx context.Context, seq int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := r.records[:0]
+	for _, record := range r.records {
+		if record.Seq > seq {
+			kept = append(kept, record)
+		}
+	}
+	removed := len(r.records) - len(kept)
+	r.records = kept
+	return removed, nil
+}
+
+func (r *MemoryRepository) SaveArchive(ctx context.Context, archive Archive) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.archives = append(r.archives, archive)
+	return nil
+}
+
+func (r *MemoryRepository) ListArchives(ctx context.Context) ([]Archive, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	archives := slices.Clone(r.archives)
+	slices.Reverse(archives)
+	return archives, nil
+}
+
+func (r *MemoryRepository) GetArchive(ctx context.Context, id string) (Archive, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, archive := range r.archives {
+		if archive.ID == id {
+			return archive, nil
+		}
+	}
+	return Archive{}, ErrArchiveNotFound{ID: id}
+}
+
+func (r *MemoryRepository) Restore(ctx context.Context, id string, records []Record, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.restored == nil {
+		r.restored = make(map[string][]Record)
+	}
+	r.restored[id] = slices.Clone(records)
+	for i := range r.archives {
+		if r.archives[i].ID == id {
+			r.archives[i].RestoredAt = &at
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRepository) Evict(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.restored, id)
+	for i := range r.archives {
+		if r.archives[i].ID == id {
+			r.archives[i].RestoredAt = nil
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRepository) ListRestored(ctx context.Context, filter RestoredFilter) ([]RestoredRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	restored := make([]RestoredRecord, 0)
+	for id, records := range r.restored {
+		if filter.ArchiveID != "" && id != filter.ArchiveID {
+			continue
+		}
+		for _, record := range records {
+			if record.Seq > filter.After && (filter.Source == "" || record.Source == filter.Source) {
+				restored = append(restored, RestoredRecord{Record: record, ArchiveID: id})
+			}
+		}
+	}
+	sort.Slice(restored, func(i, j int) bool { return restored[i].Seq < restored[j].Seq })
+	if filter.Limit > 0 && len(restored) > filter.Limit {
+		restored = restored[:filter.Limit]
+	}
+	return restored, nil
+}
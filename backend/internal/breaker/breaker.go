@@ -0,0 +1,301 @@
+// Package breaker stops calls to upstreams that keep failing. Breaker state is kept in Redis so
+// that every replica opens and closes a breaker together, or in memory for a single replica, and
+// operators may hold a breaker open or reset it.
+package breaker
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pramodksahoo/kubechat/backend/internal/audit"
+	"github.com/pramodksahoo/kubechat/backend/internal/redis"
+)
+
+// State is where a breaker stands.
+type State string
+
+const (
+	// StateClosed lets calls through while counting failures.
+	StateClosed State = "closed"
+	// StateOpen refuses calls until the breaker has been open long enough or is reset.
+	StateOpen State = "open"
+	// StateHalfOpen lets calls through again after an open breaker cooled down; the next failure
+	// opens it again and the next success closes it.
+	StateHalfOpen State = "half_open"
+)
+
+// Settings decide when breakers open.
+type Settings struct {
+	// FailureThreshold failures of an upstream within Window open its breaker.
+	FailureThreshold int
+	Window           time.Duration
+	// OpenFor is how long a breaker stays open before trying the upstream again.
+	OpenFor time.Duration
+}
+
+var DefaultSettings = Settings{FailureThreshold: 5, Window: time.Minute, OpenFor: 30 * time.Second}
+
+// Status is the state of one upstream's breaker.
+type Status struct {
+	Upstream string `json:"upstream"`
+	State    State  `json:"state"`
+	// Failures counts the failures of the current window while the breaker is closed.
+	Failures  int        `json:"failures"`
+	OpenedAt  *time.Time `json:"openedAt,omitempty"`
+	OpenUntil *time.Time `json:"openUntil,omitempty"`
+	// Forced is set while an operator holds the breaker open; it then stays open until reset.
+	Forced    bool      `json:"forced,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	UpdatedBy string    `json:"updatedBy,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Override is an operator forcing a breaker open or resetting it.
+type Override struct {
+	Upstream string    `json:"upstream"`
+	Action   string    `json:"action"`
+	Previous State     `json:"previous"`
+	Actor    string    `json:"actor"`
+	Reason   string    `json:"reason"`
+	At       time.Time `json:"at"`
+}
+
+// OverrideRequest gives the reason for an override.
+type OverrideRequest struct {
+	Reason string `json:"reason"`
+}
+
+const (
+	ActionForceOpen = "force_open"
+	ActionReset     = "reset"
+)
+
+// ErrOpen refuses a call to an upstream whose breaker is open.
+type ErrOpen struct {
+	Upstream string
+	Until    *time.Time
+}
+
+func (e ErrOpen) Error() string {
+	if e.Until == nil {
+		return fmt.Sprintf("circuit breaker for %s is held open", e.Upstream)
+	}
+	return fmt.Sprintf("circuit breaker for %s is open until %s", e.Upstream, e.Until.Format(time.RFC3339))
+}
+
+type ErrUnknownUpstream struct {
+	Upstream string
+}
+
+func (e ErrUnknownUpstream) Error() string {
+	return fmt.Sprintf("no circuit breaker for upstream %q", e.Upstream)
+}
+
+type ErrInvalidOverride struct {
+	Reason string
+}
+
+func (e ErrInvalidOverride) Error() string {
+	return "invalid breaker override: " + e.Reason
+}
+
+// Store keeps breaker state. Load returns a closed status for upstreams without saved state;
+// Fail counts a failure within window and returns the failures counted so far.
+type Store interface {
+	Load(ctx context.Context, upstream string) (Status, error)
+	Save(ctx context.Context, status Status) error
+	Fail(ctx context.Context, upstream string, window time.Duration) (int, error)
+	ClearFailures(ctx context.Context, upstream string) error
+}
+
+// AuditLedger chains manual overrides into the tamper-evident audit ledger.
+type AuditLedger interface {
+	Append(ctx context.Context, source audit.Source, entry any) error
+}
+
+// Registry holds the breakers of the registered upstreams.
+type Registry struct {
+	settings Settings
+	store    Store
+	// fallback keeps breakers working, per replica, while the store is unavailable.
+	fallback  Store
+	ledger    AuditLedger
+	logger    *log.Logger
+	now       func() time.Time
+	upstreams []string
+	// unavailable reports when the store cannot be reached.
+	unavailable *redis.Warner
+
+	mu sync.Mutex
+}
+
+// NewRegistry keeps breakers in store, or in memory when store is nil. With a shared store, this
+// replica's own breakers apply while the store cannot be reached.
+func NewRegistry(settings Settings, store Store, logger *log.Logger) *Registry {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	return &Registry{
+		settings:    settings,
+		store:       store,
+		fallback:    NewMemoryStore(),
+		logger:      logger,
+		now:         func() time.Time { return time.Now().UTC() },
+		unavailable: redis.NewWarner(logger, "circuit breaker store unavailable, keeping breakers per replica"),
+	}
+}
+
+func (r *Registry) SetLedger(ledger AuditLedger) {
+	r.ledger = ledger
+}
+
+// Register adds breakers for upstreams, which are then listed and may be overridden.
+func (r *Registry) Register(upstreams ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, upstream := range upstreams {
+		if !slices.Contains(r.upstreams, upstream) {
+			r.upstreams = append(r.upstreams, upstream)
+		}
+	}
+}
+
+// Allow refuses a call to upstream with ErrOpen while its breaker is open.
+func (r *Registry) Allow(ctx context.Context, upstream string) error {
+	status := r.load(ctx, upstream)
+	if status.State == StateOpen {
+		return ErrOpen{Upstream: upstream, Until: status.OpenUntil}
+	}
+	return nil
+}
+
+// Record reports how a call to upstream went, opening its breaker once it failed often enough and
+// closing a half-open breaker after a success.
+func (r *Registry) Record(ctx context.Context, upstream string, err error) {
+	status := r.load(ctx, upstream)
+	switch {
+	case status.State == StateOpen:
+		// The call started before the breaker opened.
+	case err == nil && status.State == StateHalfOpen:
+		r.save(ctx, Status{Upstream: upstream, State: StateClosed, UpdatedAt: r.now()})
+		r.logger.Info("circuit breaker closed", "upstream", upstream)
+	case err == nil:
+	case status.State == StateHalfOpen:
+		r.open(ctx, upstream, "still failing after cooling down")
+	default:
+		failures, storeErr := r.store.Fail(ctx, upstream, r.settings.Window)
+		if storeErr != nil {
+			r.unavailable.Warn(storeErr)
+			failures, _ = r.fallback.Fail(ctx, upstream, r.settings.Window)
+		}
+		if failures >= r.settings.FailureThreshold {
+			r.open(ctx, upstream, fmt.Sprintf("%d failures within %s", failures, r.settings.Window))
+		}
+	}
+}
+
+func (r *Registry) open(ctx context.Context, upstream, reason string) {
+	now := r.now()
+	until := now.Add(r.settings.OpenFor)
+	r.save(ctx, Status{Upstream: upstream, State: StateOpen, OpenedAt: &now, OpenUntil: &until, Reason: reason, UpdatedAt: now})
+	r.clearFailures(ctx, upstream)
+	r.logger.Warn("circuit breaker opened", "upstream", upstream, "reason", reason, "until", until)
+}
+
+// List returns the breakers of every registered upstream.
+func (r *Registry) List(ctx context.Context) []Status {
+	r.mu.Lock()
+	upstreams := slices.Clone(r.upstreams)
+	r.mu.Unlock()
+	out := make([]Status, 0, len(upstreams))
+	for _, upstream := range upstreams {
+		out = append(out, r.load(ctx, upstream))
+	}
+	return out
+}
+
+func (r *Registry) Get(ctx context.Context, upstream string) (Status, error) {
+	if !r.registered(upstream) {
+		return Status{}, ErrUnknownUpstream{Upstream: upstream}
+	}
+	return r.load(ctx, upstream), nil
+}
+
+// ForceOpen holds upstream's breaker open on behalf of actor until it is reset.
+func (r *Registry) ForceOpen(ctx context.Context, actor, upstream, reason string) (Status, error) {
+	return r.override(ctx, actor, upstream, reason, ActionForceOpen, func(now time.Time) Status {
+		return Status{Upstream: upstream, State: StateOpen, OpenedAt: &now, Forced: true, UpdatedBy: actor, UpdatedAt: now}
+	})
+}
+
+// Reset closes upstream's breaker on behalf of actor, forgetting its failures.
+func (r *Registry) Reset(ctx context.Context, actor, upstream, reason string) (Status, error) {
+	return r.override(ctx, actor, upstream, reason, ActionReset, func(now time.Time) Status {
+		return Status{Upstream: upstream, State: StateClosed, UpdatedBy: actor, UpdatedAt: now}
+	})
+}
+
+func (r *Registry) override(ctx context.Context, actor, upstream, reason, action string, next func(now time.Time) Status) (Status, error) {
+	if !r.registered(upstream) {
+		return Status{}, ErrUnknownUpstream{Upstream: upstream}
+	}
+	if reason = strings.TrimSpace(reason); reason == "" {
+		return Status{}, ErrInvalidOverride{Reason: "reason is required"}
+	}
+	previous := r.load(ctx, upstream)
+	now := r.now()
+	status := next(now)
+	status.Reason = reason
+	r.save(ctx, status)
+	r.clearFailures(ctx, upstream)
+
+	override := Override{Upstream: upstream, Action: action, Previous: previous.State, Actor: actor, Reason: reason, At: now}
+	r.logger.Warn("circuit breaker overridden", "upstream", upstream, "action", action, "previous", previous.State, "user", actor, "reason", reason)
+	if r.ledger != nil {
+		if err := r.ledger.Append(ctx, audit.SourceBreakers, override); err != nil {
+			r.logger.Error("failed to record breaker override in audit ledger", "upstream", upstream, "error", err)
+		}
+	}
+	return status, nil
+}
+
+func (r *Registry) registered(upstream string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Contains(r.upstreams, upstream)
+}
+
+// load reads upstream's breaker, reporting an open breaker that cooled down as half-open.
+func (r *Registry) load(ctx context.Context, upstream string) Status {
+	status, err := r.store.Load(ctx, upstream)
+	if err != nil {
+		r.unavailable.Warn(err)
+		status, _ = r.fallback.Load(ctx, upstream)
+	}
+	if status.State == StateOpen && !status.Forced && status.OpenUntil != nil && !r.now().Before(*status.OpenUntil) {
+		status.State = StateHalfOpen
+	}
+	return status
+}
+
+func (r *Registry) save(ctx context.Context, status Status) {
+	if err := r.store.Save(ctx, status); err != nil {
+		r.unavailable.Warn(err)
+		_ = r.fallback.Save(ctx, status)
+	}
+}
+
+func (r *Registry) clearFailures(ctx context.Context, upstream string) {
+	if err := r.store.ClearFailures(ctx, upstream); err != nil {
+		r.unavailable.Warn(err)
+		_ = r.fallback.ClearFailures(ctx, upstream)
+	}
+}
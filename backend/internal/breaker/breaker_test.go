@@ -0,0 +1,105 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pramodksahoo/kubechat/backend/internal/audit"
+)
+
+type recordingLedger struct {
+	entries []any
+}
+
+func (l *recordingLedger) Append(ctx context.Context, source audit.Source, entry any) error {
+	if source != audit.SourceBreakers {
+		return errors.New("unexpected source " + string(source))
+	}
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+func newTestRegistry(now *time.Time) (*Registry, *MemoryStore) {
+	store := NewMemoryStore()
+	store.now = func() time.Time { return *now }
+	registry := NewRegistry(Settings{FailureThreshold: 3, Window: time.Minute, OpenFor: 30 * time.Second}, store, log.NewWithOptions(io.Discard, log.Options{}))
+	registry.now = func() time.Time { return *now }
+	registry.Register("nlp:anthropic")
+	return registry, store
+}
+
+func TestBreakerOpensAfterFailuresAndClosesAfterATrialSucceeds(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	registry, _ := newTestRegistry(&now)
+	ctx := context.Background()
+	failure := errors.New("503 overloaded")
+
+	for range 2 {
+		registry.Record(ctx, "nlp:anthropic", failure)
+	}
+	if err := registry.Allow(ctx, "nlp:anthropic"); err != nil {
+		t.Fatalf("expected the breaker to stay closed below the threshold, got %v", err)
+	}
+	if status, _ := registry.Get(ctx, "nlp:anthropic"); status.Failures != 2 {
+		t.Fatalf("expected two failures to be counted, got %+v", status)
+	}
+	registry.Record(ctx, "nlp:anthropic", failure)
+	var open ErrOpen
+	if err := registry.Allow(ctx, "nlp:anthropic"); !errors.As(err, &open) || !open.Until.Equal(now.Add(30*time.Second)) {
+		t.Fatalf("expected the third failure to open the breaker for 30s, got %v", err)
+	}
+
+	now = now.Add(30 * time.Second)
+	if status, _ := registry.Get(ctx, "nlp:anthropic"); status.State != StateHalfOpen {
+		t.Fatalf("expected the breaker to be half-open after cooling down, got %s", status.State)
+	}
+	registry.Record(ctx, "nlp:anthropic", failure)
+	if err := registry.Allow(ctx, "nlp:anthropic"); err == nil {
+		t.Fatalf("expected a failed trial to open the breaker again")
+	}
+	now = now.Add(30 * time.Second)
+	registry.Record(ctx, "nlp:anthropic", nil)
+	if status, _ := registry.Get(ctx, "nlp:anthropic"); status.State != StateClosed || status.Failures != 0 {
+		t.Fatalf("expected a successful trial to close the breaker, got %+v", status)
+	}
+}
+
+func TestOverridesHoldTheBreakerOpenUntilResetAndAreAudited(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	registry, _ := newTestRegistry(&now)
+	ledger := &recordingLedger{}
+	registry.SetLedger(ledger)
+	ctx := context.Background()
+
+	if _, err := registry.ForceOpen(ctx, "alice", "nlp:anthropic", " "); !errors.As(err, new(ErrInvalidOverride)) {
+		t.Fatalf("expected a reason to be required, got %v", err)
+	}
+	if _, err := registry.ForceOpen(ctx, "alice", "nlp:openai", "maintenance"); !errors.As(err, new(ErrUnknownUpstream)) {
+		t.Fatalf("expected unknown upstreams to be refused, got %v", err)
+	}
+	status, err := registry.ForceOpen(ctx, "alice", "nlp:anthropic", "provider incident")
+	if err != nil || !status.Forced || status.UpdatedBy != "alice" {
+		t.Fatalf("unexpected status %+v, error %v", status, err)
+	}
+	now = now.Add(time.Hour)
+	registry.Record(ctx, "nlp:anthropic", nil)
+	if err := registry.Allow(ctx, "nlp:anthropic"); err == nil {
+		t.Fatalf("expected a forced breaker to stay open")
+	}
+	if _, err := registry.Reset(ctx, "bob", "nlp:anthropic", "incident resolved"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := registry.Allow(ctx, "nlp:anthropic"); err != nil {
+		t.Fatalf("expected the reset breaker to let calls through, got %v", err)
+	}
+	if len(ledger.entries) != 2 {
+		t.Fatalf("expected both overrides to be audited, got %d", len(ledger.entries))
+	}
+	if reset := ledger.entries[1].(Override); reset.Action != ActionReset || reset.Previous != StateOpen || reset.Actor != "bob" {
+		t.Fatalf("unexpected audit entry %+v", reset)
+	}
+}
@@ -0,0 +1,66 @@
+package breaker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps breakers in this replica.
+type MemoryStore struct {
+	mu       sync.Mutex
+	statuses map[string]Status
+	failures map[string]window
+	now      func() time.Time
+}
+
+// window counts failures until it ends.
+type window struct {
+	count int
+	ends  time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{statuses: map[string]Status{}, failures: map[string]window{}, now: time.Now}
+}
+
+func (s *MemoryStore) Load(ctx context.Context, upstream string) (Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status, ok := s.statuses[upstream]
+	if !ok {
+		status = Status{Upstream: upstream, State: StateClosed}
+	}
+	if failures := s.failures[upstream]; s.now().Before(failures.ends) {
+		status.Failures = failures.count
+	}
+	return status, nil
+}
+
+func (s *MemoryStore) Save(ctx context.Context, status Status) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status.Failures = 0
+	s.statuses[status.Upstream] = status
+	return nil
+}
+
+func (s *MemoryStore) Fail(ctx context.Context, upstream string, d time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	failures := s.failures[upstream]
+	if !now.Before(failures.ends) {
+		failures = window{ends: now.Add(d)}
+	}
+	failures.count++
+	s.failures[upstream] = failures
+	return failures.count, nil
+}
+
+func (s *MemoryStore) ClearFailures(ctx context.Context, upstream string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.failures, upstream)
+	return nil
+}
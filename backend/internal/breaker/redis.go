@@ -0,0 +1,83 @@
+package breaker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/redis"
+)
+
+// KeyPrefix starts the name of every Redis key the store writes. Each upstream has its status
+// as JSON under KeyPrefix+upstream and its failure count under KeyPrefix+upstream+":failures".
+const KeyPrefix = "kubechat:breaker:"
+
+// failScript counts a failure, starting a window of ARGV[1] milliseconds with the first one.
+var failScript = redis.NewScript(`
+local failures = redis.call('INCR', KEYS[1])
+if failures == 1 then redis.call('PEXPIRE', KEYS[1], ARGV[1]) end
+return failures
+`)
+
+// RedisStore keeps breakers in Redis, shared by every replica.
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Load(ctx context.Context, upstream string) (Status, error) {
+	reply, err := s.client.Do(ctx, "MGET", KeyPrefix+upstream, failuresKey(upstream))
+	if err != nil {
+		return Status{}, err
+	}
+	values, ok := reply.([]any)
+	if !ok || len(values) != 2 {
+		return Status{}, fmt.Errorf("unexpected breaker reply %v", reply)
+	}
+	status := Status{Upstream: upstream, State: StateClosed}
+	if raw, ok := values[0].(string); ok {
+		if err := json.Unmarshal([]byte(raw), &status); err != nil {
+			return Status{}, fmt.Errorf("decode breaker %s: %w", upstream, err)
+		}
+	}
+	if raw, ok := values[1].(string); ok {
+		status.Failures, _ = strconv.Atoi(raw)
+	}
+	return status, nil
+}
+
+func (s *RedisStore) Save(ctx context.Context, status Status) error {
+	status.Failures = 0
+	raw, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Do(ctx, "SET", KeyPrefix+status.Upstream, string(raw))
+	return err
+}
+
+func (s *RedisStore) Fail(ctx context.Context, upstream string, window time.Duration) (int, error) {
+	reply, err := s.client.Eval(ctx, failScript, []string{failuresKey(upstream)}, strconv.FormatInt(max(window.Milliseconds(), 1), 10))
+	if err != nil {
+		return 0, err
+	}
+	failures, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected breaker reply %v", reply)
+	}
+	return int(failures), nil
+}
+
+func (s *RedisStore) ClearFailures(ctx context.Context, upstream string) error {
+	_, err := s.client.Do(ctx, "DEL", failuresKey(upstream))
+	return err
+}
+
+func failuresKey(upstream string) string {
+	return KeyPrefix + upstream + ":failures"
+}
@@ -0,0 +1,159 @@
+// Package capabilities records which optional subsystems are usable so that requests needing a
+// disabled one get a consistent answer telling the operator how to enable it.
+package capabilities
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Name identifies an optional subsystem.
+type Name string
+
+const (
+	// Kubernetes is available once at least one kubeconfig with clusters is loaded.
+	Kubernetes Name = "kubernetes"
+	// Models is available when at least one model provider is configured.
+	Models Name = "models"
+	// Persistence is available when a database is configured; without one, stores keep their
+	// data in memory until restart.
+	Persistence Name = "persistence"
+	// Metrics is available per cluster when the cluster runs metrics-server; it is reported by
+	// the handlers that need it rather than registered.
+	Metrics Name = "metrics"
+)
+
+// Code is the machine-readable code of responses refused because a capability is disabled.
+const Code = "capability_disabled"
+
+// Check reports whether a capability is usable. It returns ErrDisabled, or any error taken as the
+// reason, when it is not.
+type Check func() error
+
+// Status describes one capability for clients deciding which features to offer.
+type Status struct {
+	Name        Name   `json:"name"`
+	Enabled     bool   `json:"enabled"`
+	Reason      string `json:"reason,omitempty"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// Response is the body of a request refused because a capability is disabled.
+type Response struct {
+	Error       string `json:"error"`
+	Code        string `json:"code"`
+	Capability  Name   `json:"capability"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+type ErrDisabled struct {
+	Capability  Name
+	Reason      string
+	Remediation string
+}
+
+func (e ErrDisabled) Error() string {
+	return fmt.Sprintf("%s is disabled: %s", e.Capability, e.Reason)
+}
+
+// Response returns the body to answer a refused request with.
+func (e ErrDisabled) Response() Response {
+	return Response{Error: e.Error(), Code: Code, Capability: e.Capability, Remediation: e.Remediation}
+}
+
+// Rule names the capability a route group needs; Prefix matches the registered route path.
+type Rule struct {
+	Prefix     string
+	Capability Name
+}
+
+// DefaultRules maps route groups to the capabilities they need beyond Kubernetes, which every
+// cluster-scoped route needs.
+var DefaultRules = []Rule{
+	{Prefix: "/api/v1/nlp/providers/:name/canary", Capability: Models},
+}
+
+// RequiredCapability returns the capability the first rule matching path names.
+func RequiredCapability(rules []Rule, path string) (Name, bool) {
+	for _, rule := range rules {
+		if strings.HasPrefix(path, rule.Prefix) {
+			return rule.Capability, true
+		}
+	}
+	return "", false
+}
+
+// Registry holds the checks of the registered capabilities. Capabilities that were never
+// registered are taken as enabled.
+type Registry struct {
+	mu     sync.RWMutex
+	names  []Name
+	checks map[Name]Check
+}
+
+func NewRegistry() *Registry {
+	return &Registry{checks: make(map[Name]Check)}
+}
+
+// Register sets the check of name, replacing any earlier one.
+func (r *Registry) Register(name Name, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.checks[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.checks[name] = check
+}
+
+// Enable marks name as usable.
+func (r *Registry) Enable(name Name) {
+	r.Register(name, func() error { return nil })
+}
+
+// Disable records that name failed to initialize, with a hint for the operator to fix it.
+func (r *Registry) Disable(name Name, reason, remediation string) {
+	err := ErrDisabled{Capability: name, Reason: reason, Remediation: remediation}
+	r.Register(name, func() error { return err })
+}
+
+// Require returns ErrDisabled when name is not usable.
+func (r *Registry) Require(name Name) error {
+	r.mu.RLock()
+	check, ok := r.checks[name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	return disabled(name, check())
+}
+
+// List reports every registered capability in registration order.
+func (r *Registry) List() []Status {
+	r.mu.RLock()
+	names := append([]Name(nil), r.names...)
+	r.mu.RUnlock()
+
+	statuses := make([]Status, 0, len(names))
+	for _, name := range names {
+		status := Status{Name: name, Enabled: true}
+		var err ErrDisabled
+		if errors.As(r.Require(name), &err) {
+			status.Enabled, status.Reason, status.Remediation = false, err.Reason, err.Remediation
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+func disabled(name Name, err error) error {
+	if err == nil {
+		return nil
+	}
+	var known ErrDisabled
+	if errors.As(err, &known) {
+		return known
+	}
+	return ErrDisabled{Capability: name, Reason: err.Error()}
+}
@@ -0,0 +1,48 @@
+package capabilities
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRegistryReportsDisabledCapabilities(t *testing.T) {
+	registry := NewRegistry()
+	loaded := false
+	registry.Register(Kubernetes, func() error {
+		if !loaded {
+			return errors.New("no kubeconfig with clusters is loaded")
+		}
+		return nil
+	})
+	registry.Disable(Models, "no model provider is configured", "set ANTHROPIC_API_KEY")
+
+	var disabled ErrDisabled
+	if err := registry.Require(Kubernetes); !errors.As(err, &disabled) || disabled.Capability != Kubernetes {
+		t.Fatalf("expected kubernetes to be disabled, got %v", err)
+	}
+	if response := disabled.Response(); response.Code != Code || response.Capability != Kubernetes {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+	if err := registry.Require(Metrics); err != nil {
+		t.Fatalf("expected unregistered capabilities to be enabled, got %v", err)
+	}
+
+	loaded = true
+	statuses := registry.List()
+	if len(statuses) != 2 || !statuses[0].Enabled || statuses[1].Enabled || statuses[1].Remediation != "set ANTHROPIC_API_KEY" {
+		t.Fatalf("unexpected statuses: %+v", statuses)
+	}
+	registry.Enable(Models)
+	if err := registry.Require(Models); err != nil {
+		t.Fatalf("expected models to be enabled, got %v", err)
+	}
+}
+
+func TestRequiredCapabilityMatchesRoutePrefix(t *testing.T) {
+	if name, ok := RequiredCapability(DefaultRules, "/api/v1/nlp/providers/:name/canary/promote"); !ok || name != Models {
+		t.Fatalf("expected canary routes to need models, got %q %v", name, ok)
+	}
+	if _, ok := RequiredCapability(DefaultRules, "/api/v1/nlp/providers"); ok {
+		t.Fatalf("expected listing providers to need no capability")
+	}
+}
@@ -0,0 +1,104 @@
+// Package chaos lets admins rehearse outages on a live server: for a bounded window it fails or
+// slows model provider calls and throttles cluster API requests, so fallback, circuit-breaker and
+// recovery behavior can be exercised safely. Experiments are held in memory and only affect the
+// replica that started them; a restart ends them all.
+package chaos
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultDuration applies when an experiment does not ask for a duration.
+	DefaultDuration = 5 * time.Minute
+	// MaxDuration bounds how long one experiment runs.
+	MaxDuration = time.Hour
+	// MaxLatency bounds the delay added to one provider call.
+	MaxLatency = 30 * time.Second
+	// historySize bounds the finished experiments kept for review.
+	historySize = 100
+)
+
+// Kind names the fault an experiment injects.
+type Kind string
+
+const (
+	// KindProviderError fails model provider calls as if the provider were down.
+	KindProviderError Kind = "provider_error"
+	// KindProviderLatency delays model provider calls.
+	KindProviderLatency Kind = "provider_latency"
+	// KindClusterThrottle answers cluster API requests with 429 Too Many Requests.
+	KindClusterThrottle Kind = "cluster_throttle"
+)
+
+func (k Kind) valid() bool {
+	switch k {
+	case KindProviderError, KindProviderLatency, KindClusterThrottle:
+		return true
+	}
+	return false
+}
+
+// ExperimentInput starts an experiment against Target, a provider or cluster name, or against
+// every provider or cluster when Target is empty. Rate is the percentage of calls affected.
+type ExperimentInput struct {
+	Kind      Kind   `json:"kind"`
+	Target    string `json:"target,omitempty"`
+	Rate      int    `json:"rate,omitempty"`
+	LatencyMs int    `json:"latencyMs,omitempty"`
+	Minutes   int    `json:"minutes,omitempty"`
+	Reason    string `json:"reason"`
+}
+
+// Experiment is one fault injected for a bounded window.
+type Experiment struct {
+	ID        string     `json:"id"`
+	Kind      Kind       `json:"kind"`
+	Target    string     `json:"target,omitempty"`
+	Rate      int        `json:"rate"`
+	LatencyMs int        `json:"latencyMs,omitempty"`
+	Reason    string     `json:"reason"`
+	StartedBy string     `json:"startedBy"`
+	StartedAt time.Time  `json:"startedAt"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	StoppedAt *time.Time `json:"stoppedAt,omitempty"`
+	StoppedBy string     `json:"stoppedBy,omitempty"`
+	// Injected counts the calls the experiment failed, delayed or throttled.
+	Injected int  `json:"injected"`
+	Active   bool `json:"active"`
+}
+
+// active reports whether the experiment still injects faults at now.
+func (e Experiment) active(now time.Time) bool {
+	return e.StoppedAt == nil && now.Before(e.ExpiresAt)
+}
+
+// affects reports whether the experiment applies to target for one of kinds.
+func (e Experiment) affects(target string, kinds ...Kind) bool {
+	if e.Target != "" && e.Target != target {
+		return false
+	}
+	for _, kind := range kinds {
+		if e.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+type ErrExperimentNotFound struct {
+	ID string
+}
+
+func (e ErrExperimentNotFound) Error() string {
+	return fmt.Sprintf("chaos experiment %s not found", e.ID)
+}
+
+type ErrInvalidExperiment struct {
+	Reason string
+}
+
+func (e ErrInvalidExperiment) Error() string {
+	return e.Reason
+}
@@ -0,0 +1,209 @@
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/pramodksahoo/kubechat/backend/internal/ai"
+)
+
+// Service runs chaos experiments. It implements ai.FaultInjector for the model fallback chain and
+// reports cluster throttling to the HTTP middleware. The role rules decide who may run them.
+type Service struct {
+	logger *log.Logger
+	now    func() time.Time
+	roll   func() int
+	sleep  func(ctx context.Context, d time.Duration) error
+
+	mu          sync.Mutex
+	experiments []Experiment
+}
+
+func NewService(logger *log.Logger) *Service {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Service{
+		logger: logger,
+		now:    func() time.Time { return time.Now().UTC() },
+		roll:   func() int { return rand.IntN(100) },
+		sleep:  sleepContext,
+	}
+}
+
+// Start begins an experiment on behalf of actor.
+func (s *Service) Start(actor string, in ExperimentInput) (Experiment, error) {
+	in.Target, in.Reason = strings.TrimSpace(in.Target), strings.TrimSpace(in.Reason)
+	if !in.Kind.valid() {
+		return Experiment{}, ErrInvalidExperiment{Reason: fmt.Sprintf("unknown experiment kind %q", in.Kind)}
+	}
+	if in.Reason == "" {
+		return Experiment{}, ErrInvalidExperiment{Reason: "reason is required"}
+	}
+	if in.Rate == 0 {
+		in.Rate = 100
+	}
+	if in.Rate < 1 || in.Rate > 100 {
+		return Experiment{}, ErrInvalidExperiment{Reason: "rate must be between 1 and 100"}
+	}
+	if in.Kind == KindProviderLatency && (in.LatencyMs < 1 || time.Duration(in.LatencyMs)*time.Millisecond > MaxLatency) {
+		return Experiment{}, ErrInvalidExperiment{Reason: "latencyMs must be between 1 and " + fmt.Sprint(MaxLatency.Milliseconds())}
+	}
+	if in.Kind != KindProviderLatency {
+		in.LatencyMs = 0
+	}
+	if in.Minutes == 0 {
+		in.Minutes = int(DefaultDuration / time.Minute)
+	}
+	if in.Minutes < 0 || time.Duration(in.Minutes)*time.Minute > MaxDuration {
+		return Experiment{}, ErrInvalidExperiment{Reason: "minutes must be between 1 and " + fmt.Sprint(int(MaxDuration/time.Minute))}
+	}
+
+	now := s.now()
+	experiment := Experiment{
+		ID:        uuid.NewString(),
+		Kind:      in.Kind,
+		Target:    in.Target,
+		Rate:      in.Rate,
+		LatencyMs: in.LatencyMs,
+		Reason:    in.Reason,
+		StartedBy: actor,
+		StartedAt: now,
+		ExpiresAt: now.Add(time.Duration(in.Minutes) * time.Minute),
+	}
+
+	s.mu.Lock()
+	s.experiments = append(s.experiments, experiment)
+	s.pruneLocked(now)
+	s.mu.Unlock()
+
+	s.logger.Warn("chaos experiment started", "id", experiment.ID, "kind", experiment.Kind, "target", experiment.Target, "rate", experiment.Rate, "expires_at", experiment.ExpiresAt, "user", actor)
+	experiment.Active = true
+	return experiment, nil
+}
+
+// Stop ends an experiment before its window runs out.
+func (s *Service) Stop(actor, id string) (Experiment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for i := range s.experiments {
+		experiment := &s.experiments[i]
+		if experiment.ID != id {
+			continue
+		}
+		if experiment.active(now) {
+			experiment.StoppedAt = &now
+			experiment.StoppedBy = actor
+			s.logger.Warn("chaos experiment stopped", "id", experiment.ID, "injected", experiment.Injected, "user", actor)
+		}
+		return describe(*experiment, now), nil
+	}
+	return Experiment{}, ErrExperimentNotFound{ID: id}
+}
+
+// List returns running and recently finished experiments, most recent first.
+func (s *Service) List() ([]Experiment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	out := make([]Experiment, 0, len(s.experiments))
+	for i := len(s.experiments) - 1; i >= 0; i-- {
+		out = append(out, describe(s.experiments[i], now))
+	}
+	return out, nil
+}
+
+// Inject delays or fails a call to provider when a running experiment targets it. Failures look
+// like the provider answering 503, so callers exercise the same path as a real outage.
+func (s *Service) Inject(ctx context.Context, provider string) error {
+	var delay time.Duration
+	var failure error
+
+	s.mu.Lock()
+	now := s.now()
+	for i := range s.experiments {
+		experiment := &s.experiments[i]
+		if !experiment.active(now) || !experiment.affects(provider, KindProviderError, KindProviderLatency) || s.roll() >= experiment.Rate {
+			continue
+		}
+		experiment.Injected++
+		switch experiment.Kind {
+		case KindProviderLatency:
+			delay += time.Duration(experiment.LatencyMs) * time.Millisecond
+		case KindProviderError:
+			if failure == nil {
+				failure = ai.ErrProviderStatus{
+					Provider: provider,
+					Status:   http.StatusServiceUnavailable,
+					Body:     "simulated outage by chaos experiment " + experiment.ID,
+				}
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	if delay > 0 {
+		if err := s.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+	return failure
+}
+
+// Throttle reports whether a request to cluster should be refused as throttled, the experiment
+// responsible and how long the client should wait before retrying.
+func (s *Service) Throttle(cluster string) (string, time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for i := range s.experiments {
+		experiment := &s.experiments[i]
+		if !experiment.active(now) || !experiment.affects(cluster, KindClusterThrottle) || s.roll() >= experiment.Rate {
+			continue
+		}
+		experiment.Injected++
+		retryAfter := min(experiment.ExpiresAt.Sub(now), 5*time.Second)
+		return experiment.ID, retryAfter, true
+	}
+	return "", 0, false
+}
+
+// describe fills in whether an experiment is still running at now.
+func describe(experiment Experiment, now time.Time) Experiment {
+	experiment.Active = experiment.active(now)
+	return experiment
+}
+
+// pruneLocked drops the oldest finished experiments beyond the history size.
+func (s *Service) pruneLocked(now time.Time) {
+	for excess := len(s.experiments) - historySize; excess > 0; excess-- {
+		index := slices.IndexFunc(s.experiments, func(e Experiment) bool { return !e.active(now) })
+		if index < 0 {
+			return
+		}
+		s.experiments = slices.Delete(s.experiments, index, index+1)
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
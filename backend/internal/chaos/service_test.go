@@ -0,0 +1,94 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pramodksahoo/kubechat/backend/internal/ai"
+)
+
+func newTestService(t *testing.T) (*Service, *time.Time, *[]time.Duration) {
+	t.Helper()
+	service := NewService(log.NewWithOptions(io.Discard, log.Options{}))
+	now := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+	service.roll = func() int { return 0 }
+	var slept []time.Duration
+	service.sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+	return service, &now, &slept
+}
+
+func TestProviderFaultsAreBounded(t *testing.T) {
+	service, now, slept := newTestService(t)
+
+	var invalid ErrInvalidExperiment
+	if _, err := service.Start("alice", ExperimentInput{Kind: KindProviderError, Reason: "game day", Minutes: 120}); !errors.As(err, &invalid) {
+		t.Fatalf("expected experiments longer than an hour to be refused, got %v", err)
+	}
+
+	outage, err := service.Start("alice", ExperimentInput{Kind: KindProviderError, Target: "anthropic", Reason: "game day", Minutes: 10})
+	if err != nil || !outage.Active || outage.Rate != 100 {
+		t.Fatalf("unexpected experiment %+v: %v", outage, err)
+	}
+	if _, err := service.Start("alice", ExperimentInput{Kind: KindProviderLatency, LatencyMs: 1500, Reason: "slow models"}); err != nil {
+		t.Fatalf("start latency experiment: %v", err)
+	}
+
+	var status ai.ErrProviderStatus
+	if err := service.Inject(context.Background(), "anthropic"); !errors.As(err, &status) || status.Status != 503 {
+		t.Fatalf("expected a simulated 503, got %v", err)
+	}
+	if err := service.Inject(context.Background(), "ollama"); err != nil {
+		t.Fatalf("expected other providers only to be delayed, got %v", err)
+	}
+	if len(*slept) != 2 || (*slept)[1] != 1500*time.Millisecond {
+		t.Fatalf("expected both calls to be delayed, got %v", *slept)
+	}
+
+	*now = now.Add(11 * time.Minute)
+	if err := service.Inject(context.Background(), "anthropic"); err != nil {
+		t.Fatalf("expected the outage to end with its window, got %v", err)
+	}
+	experiments, _ := service.List()
+	if len(experiments) != 2 || experiments[1].ID != outage.ID || experiments[1].Active || experiments[1].Injected != 1 {
+		t.Fatalf("unexpected experiments %+v", experiments)
+	}
+}
+
+func TestClusterThrottleStopsEarly(t *testing.T) {
+	service, _, _ := newTestService(t)
+	experiment, err := service.Start("alice", ExperimentInput{Kind: KindClusterThrottle, Target: "prod", Rate: 50, Reason: "apiserver overload"})
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	if id, retryAfter, throttled := service.Throttle("prod"); !throttled || id != experiment.ID || retryAfter != 5*time.Second {
+		t.Fatalf("expected prod to be throttled, got %q %v %v", id, retryAfter, throttled)
+	}
+	if _, _, throttled := service.Throttle("staging"); throttled {
+		t.Fatalf("expected other clusters not to be throttled")
+	}
+	service.roll = func() int { return 50 }
+	if _, _, throttled := service.Throttle("prod"); throttled {
+		t.Fatalf("expected requests outside the rate to pass")
+	}
+
+	stopped, err := service.Stop("alice", experiment.ID)
+	if err != nil || stopped.Active || stopped.StoppedBy != "alice" {
+		t.Fatalf("unexpected stopped experiment %+v: %v", stopped, err)
+	}
+	service.roll = func() int { return 0 }
+	if _, _, throttled := service.Throttle("prod"); throttled {
+		t.Fatalf("expected a stopped experiment not to throttle")
+	}
+	if _, err := service.Stop("alice", "missing"); !errors.As(err, &ErrExperimentNotFound{}) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
@@ -0,0 +1,6 @@
+ALTER TABLE chat_sessions
+    ADD COLUMN IF NOT EXISTS context JSONB,
+    ADD COLUMN IF NOT EXISTS context_start INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE chat_messages
+    ADD COLUMN IF NOT EXISTS resources JSONB NOT NULL DEFAULT '[]'::jsonb;
@@ -0,0 +1,4 @@
+ALTER TABLE chat_messages
+    ADD COLUMN IF NOT EXISTS correlation_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS chat_messages_correlation_idx ON chat_messages (correlation_id) WHERE correlation_id <> '';
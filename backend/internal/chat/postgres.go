@@ -0,0 +1,271 @@
+package chat
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pramodksahoo/kubechat/backend/internal/database"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// PostgresRepository stores sessions and messages as plain rows; deleting a session cascades to
+// its messages.
+type PostgresRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresRepository applies the chat session schema migrations and returns a repository backed by db.
+func NewPostgresRepository(ctx context.Context, db *sqlx.DB) (*PostgresRepository, error) {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Migrate(ctx, db, "chat", sub); err != nil {
+		return nil, err
+	}
+	return &PostgresRepository{db: db}, nil
+}
+
+type sessionRow struct {
+	ID           string    `db:"id"`
+	Owner        string    `db:"owner"`
+	Title        string    `db:"title"`
+	MessageCount int       `db:"message_count"`
+	CreatedAt    time.Time `db:"created_at"`
+	UpdatedAt    time.Time `db:"updated_at"`
+	Context      []byte    `db:"context"`
+	ContextStart int       `db:"context_start"`
+}
+
+func toSessionRow(session Session) (sessionRow, error) {
+	var active []byte
+	if session.Context != nil {
+		var err error
+		if active, err = json.Marshal(session.Context); err != nil {
+			return sessionRow{}, err
+		}
+	}
+	return sessionRow{
+		ID:           session.ID,
+		Owner:        session.Owner,
+		Title:        session.Title,
+		MessageCount: session.MessageCount,
+		CreatedAt:    session.CreatedAt,
+		UpdatedAt:    session.UpdatedAt,
+		Context:      active,
+		ContextStart: session.ContextStart,
+	}, nil
+}
+
+func (r sessionRow) session() (Session, error) {
+	var active *ActiveContext
+	if len(r.Context) > 0 {
+		active = &ActiveContext{}
+		if err := json.Unmarshal(r.Context, active); err != nil {
+			return Session{}, fmt.Errorf("decode context of chat session %s: %w", r.ID, err)
+		}
+	}
+	return Session{
+		ID:           r.ID,
+		Owner:        r.Owner,
+		Title:        r.Title,
+		MessageCount: r.MessageCount,
+		CreatedAt:    r.CreatedAt,
+		UpdatedAt:    r.UpdatedAt,
+		Context:      active,
+		ContextStart: r.ContextStart,
+	}, nil
+}
+
+type messageRow struct {
+	ID            string    `db:"id"`
+	SessionID     string    `db:"session_id"`
+	Role          string    `db:"role"`
+	Content       string    `db:"content"`
+	PlanID        string    `db:"plan_id"`
+	Cluster       string    `db:"cluster"`
+	Namespace     string    `db:"namespace"`
+	Resources     []byte    `db:"resources"`
+	CorrelationID string    `db:"correlation_id"`
+	Tokens        int       `db:"tokens"`
+	CreatedAt     time.Time `db:"created_at"`
+}
+
+const messageColumns = `id, session_id, role, content, plan_id, cluster, namespace, resources, correlation_id, tokens, created_at`
+
+func (r messageRow) message() (Message, error) {
+	var resources []string
+	if err := json.Unmarshal(r.Resources, &resources); err != nil {
+		return Message{}, fmt.Errorf("decode resources of chat message %s: %w", r.ID, err)
+	}
+	return Message{
+		ID:            r.ID,
+		SessionID:     r.SessionID,
+		Role:          Role(r.Role),
+		Content:       r.Content,
+		PlanID:        r.PlanID,
+		Cluster:       r.Cluster,
+		Namespace:     r.Namespace,
+		Resources:     resources,
+		CorrelationID: r.CorrelationID,
+		Tokens:        r.Tokens,
+		CreatedAt:     r.CreatedAt,
+	}, nil
+}
+
+const sessionColumns = `id, owner, title, message_count, created_at, updated_at, context, context_start`
+
+func (r *PostgresRepository) SaveSession(ctx context.Context, session Session) error {
+	row, err := toSessionRow(session)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.NamedExecContext(ctx, `INSERT INTO chat_sessions (`+sessionColumns+`)
+		VALUES (:id, :owner, :title, :message_count, :created_at, :updated_at, :context, :context_start)
+		ON CONFLICT (id) DO UPDATE SET title = EXCLUDED.title, message_count = EXCLUDED.message_count, updated_at = EXCLUDED.updated_at,
+			context = EXCLUDED.context, context_start = EXCLUDED.context_start`, row)
+	return err
+}
+
+func (r *PostgresRepository) GetSession(ctx context.Context, id string) (Session, error) {
+	var row sessionRow
+	err := r.db.GetContext(ctx, &row, `SELECT `+sessionColumns+` FROM chat_sessions WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, ErrSessionNotFound{ID: id}
+	}
+	if err != nil {
+		return Session{}, err
+	}
+	return row.session()
+}
+
+func (r *PostgresRepository) ListSessions(ctx context.Context, owner string) ([]Session, error) {
+	return r.selectSessions(ctx, `SELECT `+sessionColumns+` FROM chat_sessions WHERE owner = $1 ORDER BY updated_at DESC`, owner)
+}
+
+func (r *PostgresRepository) IdleSessions(ctx context.Context, cutoff time.Time) ([]Session, error) {
+	return r.selectSessions(ctx, `SELECT `+sessionColumns+` FROM chat_sessions WHERE updated_at < $1`, cutoff)
+}
+
+func (r *PostgresRepository) DeleteSession(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM chat_sessions WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrSessionNotFound{ID: id}
+	}
+	return nil
+}
+
+func (r *PostgresRepository) AppendMessages(ctx context.Context, messages ...Message) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, message := range messages {
+		resources, err := json.Marshal(nonNil(message.Resources))
+		if err != nil {
+			return err
+		}
+		if _, err := tx.NamedExecContext(ctx, `INSERT INTO chat_messages (`+messageColumns+`)
+			VALUES (:id, :session_id, :role, :content, :plan_id, :cluster, :namespace, :resources, :correlation_id, :tokens, :created_at)`, messageRow{
+			ID:            message.ID,
+			SessionID:     message.SessionID,
+			Role:          string(message.Role),
+			Content:       message.Content,
+			PlanID:        message.PlanID,
+			Cluster:       message.Cluster,
+			Namespace:     message.Namespace,
+			Resources:     resources,
+			CorrelationID: message.CorrelationID,
+			Tokens:        message.Tokens,
+			CreatedAt:     message.CreatedAt,
+		}); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *PostgresRepository) ListMessages(ctx context.Context, sessionID string) ([]Message, error) {
+	return r.selectMessages(ctx, `SELECT `+messageColumns+` FROM chat_messages WHERE session_id = $1 ORDER BY seq`, sessionID)
+}
+
+func (r *PostgresRepository) ListCorrelated(ctx context.Context, correlationID string) ([]Message, error) {
+	return r.selectMessages(ctx, `SELECT `+messageColumns+` FROM chat_messages WHERE correlation_id = $1 ORDER BY seq`, correlationID)
+}
+
+func (r *PostgresRepository) Retention(ctx context.Context) (map[string]int, error) {
+	var rows []struct {
+		Owner string `db:"owner"`
+		Days  int    `db:"days"`
+	}
+	if err := r.db.SelectContext(ctx, &rows, `SELECT owner, days FROM chat_retention`); err != nil {
+		return nil, err
+	}
+	retention := make(map[string]int, len(rows))
+	for _, row := range rows {
+		retention[row.Owner] = row.Days
+	}
+	return retention, nil
+}
+
+func (r *PostgresRepository) SaveRetention(ctx context.Context, owner string, days int) error {
+	if days == 0 {
+		_, err := r.db.ExecContext(ctx, `DELETE FROM chat_retention WHERE owner = $1`, owner)
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, `INSERT INTO chat_retention (owner, days, updated_at) VALUES ($1, $2, now())
+		ON CONFLICT (owner) DO UPDATE SET days = EXCLUDED.days, updated_at = EXCLUDED.updated_at`, owner, days)
+	return err
+}
+
+func (r *PostgresRepository) selectSessions(ctx context.Context, query string, args ...any) ([]Session, error) {
+	var rows []sessionRow
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, err
+	}
+	sessions := make([]Session, 0, len(rows))
+	for _, row := range rows {
+		session, err := row.session()
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+func (r *PostgresRepository) selectMessages(ctx context.Context, query string, args ...any) ([]Message, error) {
+	var rows []messageRow
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, err
+	}
+	messages := make([]Message, 0, len(rows))
+	for _, row := range rows {
+		message, err := row.message()
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
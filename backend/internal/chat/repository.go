@@ -0,0 +1,149 @@
+package chat
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Repository persists sessions, their messages and the retention users chose.
+type Repository interface {
+	SaveSession(ctx context.Context, session Session) error
+	GetSession(ctx context.Context, id string) (Session, error)
+	// ListSessions returns owner's sessions, most recently active first.
+	ListSessions(ctx context.Context, owner string) ([]Session, error)
+	// IdleSessions returns every session last active before cutoff.
+	IdleSessions(ctx context.Context, cutoff time.Time) ([]Session, error)
+	// DeleteSession removes a session with its messages.
+	DeleteSession(ctx context.Context, id string) error
+	AppendMessages(ctx context.Context, messages ...Message) error
+	// ListMessages returns a session's messages, oldest first.
+	ListMessages(ctx context.Context, sessionID string) ([]Message, error)
+	// ListCorrelated returns the messages of every session sharing correlationID, oldest first.
+	ListCorrelated(ctx context.Context, correlationID string) ([]Message, error)
+	// Retention returns the days each user chose to keep their sessions, keyed by user.
+	Retention(ctx context.Context) (map[string]int, error)
+	// SaveRetention records owner's choice; zero days returns them to the default.
+	SaveRetention(ctx context.Context, owner string, days int) error
+}
+
+// MemoryRepository keeps conversations in process memory; it is used when no database is configured.
+type MemoryRepository struct {
+	mu        sync.RWMutex
+	sessions  map[string]Session
+	messages  map[string][]Message
+	retention map[string]int
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		sessions:  make(map[string]Session),
+		messages:  make(map[string][]Message),
+		retention: make(map[string]int),
+	}
+}
+
+func (r *MemoryRepository) SaveSession(ctx context.Context, session Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[session.ID] = session
+	return nil
+}
+
+func (r *MemoryRepository) GetSession(ctx context.Context, id string) (Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	session, ok := r.sessions[id]
+	if !ok {
+		return Session{}, ErrSessionNotFound{ID: id}
+	}
+	return session, nil
+}
+
+func (r *MemoryRepository) ListSessions(ctx context.Context, owner string) ([]Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	sessions := make([]Session, 0)
+	for _, session := range r.sessions {
+		if session.Owner == owner {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt) })
+	return sessions, nil
+}
+
+func (r *MemoryRepository) IdleSessions(ctx context.Context, cutoff time.Time) ([]Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	sessions := make([]Session, 0)
+	for _, session := range r.sessions {
+		if session.UpdatedAt.Before(cutoff) {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *MemoryRepository) DeleteSession(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.sessions[id]; !ok {
+		return ErrSessionNotFound{ID: id}
+	}
+	delete(r.sessions, id)
+	delete(r.messages, id)
+	return nil
+}
+
+func (r *MemoryRepository) AppendMessages(ctx context.Context, messages ...Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, message := range messages {
+		r.messages[message.SessionID] = append(r.messages[message.SessionID], message)
+	}
+	return nil
+}
+
+func (r *MemoryRepository) ListMessages(ctx context.Context, sessionID string) ([]Message, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Message{}, r.messages[sessionID]...), nil
+}
+
+func (r *MemoryRepository) ListCorrelated(ctx context.Context, correlationID string) ([]Message, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	messages := make([]Message, 0)
+	for _, session := range r.messages {
+		for _, message := range session {
+			if message.CorrelationID == correlationID {
+				messages = append(messages, message)
+			}
+		}
+	}
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].CreatedAt.Before(messages[j].CreatedAt) })
+	return messages, nil
+}
+
+func (r *MemoryRepository) Retention(ctx context.Context) (map[string]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	retention := make(map[string]int, len(r.retention))
+	for owner, days := range r.retention {
+		retention[owner] = days
+	}
+	return retention, nil
+}
+
+func (r *MemoryRepository) SaveRetention(ctx context.Context, owner string, days int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if days == 0 {
+		delete(r.retention, owner)
+		return nil
+	}
+	r.retention[owner] = days
+	return nil
+}
@@ -0,0 +1,302 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+)
+
+// Options configure retention and the context window; zero values take the defaults.
+type Options struct {
+	Retention     time.Duration
+	ContextTokens int
+}
+
+type Service struct {
+	repo          Repository
+	retention     time.Duration
+	contextTokens int
+	logger        *log.Logger
+	now           func() time.Time
+}
+
+func NewService(repo Repository, opts Options, logger *log.Logger) *Service {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = DefaultRetention
+	}
+	if opts.ContextTokens <= 0 {
+		opts.ContextTokens = DefaultContextTokens
+	}
+	return &Service{
+		repo:          repo,
+		retention:     opts.Retention,
+		contextTokens: opts.ContextTokens,
+		logger:        logger,
+		now:           func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// Start opens an empty session for owner. Without a title, the first prompt becomes the title.
+func (s *Service) Start(ctx context.Context, owner, title string) (Session, error) {
+	now := s.now()
+	session := Session{
+		ID:        uuid.NewString(),
+		Owner:     owner,
+		Title:     truncateTitle(title),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.repo.SaveSession(ctx, session); err != nil {
+		return Session{}, err
+	}
+	return s.withExpiry(ctx, session)
+}
+
+// Sessions lists owner's sessions, most recently active first.
+func (s *Service) Sessions(ctx context.Context, owner string) ([]Session, error) {
+	sessions, err := s.repo.ListSessions(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	window, err := s.window(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].ExpiresAt = sessions[i].UpdatedAt.Add(window)
+	}
+	return sessions, nil
+}
+
+// Conversation returns one of owner's sessions with all of its messages, to resume it.
+func (s *Service) Conversation(ctx context.Context, owner, id string) (Conversation, error) {
+	session, err := s.session(ctx, owner, id)
+	if err != nil {
+		return Conversation{}, err
+	}
+	messages, err := s.repo.ListMessages(ctx, id)
+	if err != nil {
+		return Conversation{}, err
+	}
+	if session, err = s.withExpiry(ctx, session); err != nil {
+		return Conversation{}, err
+	}
+	return Conversation{Session: session, Messages: messages}, nil
+}
+
+// Delete removes one of owner's sessions with its messages.
+func (s *Service) Delete(ctx context.Context, owner, id string) error {
+	if _, err := s.session(ctx, owner, id); err != nil {
+		return err
+	}
+	return s.repo.DeleteSession(ctx, id)
+}
+
+// Record appends messages to one of owner's sessions and marks it active.
+func (s *Service) Record(ctx context.Context, owner, id string, messages ...Message) (Session, error) {
+	session, err := s.session(ctx, owner, id)
+	if err != nil {
+		return Session{}, err
+	}
+	now := s.now()
+	for i := range messages {
+		messages[i].ID = uuid.NewString()
+		messages[i].SessionID = id
+		messages[i].Tokens = EstimateTokens(messages[i].Content)
+		messages[i].CreatedAt = now
+		if session.Title == "" && messages[i].Role == RoleUser {
+			session.Title = truncateTitle(messages[i].Content)
+		}
+		if messages[i].Role == RoleAssistant && messages[i].Cluster != "" {
+			session.Context = session.Context.advance(messages[i], now)
+		}
+	}
+	if err := s.repo.AppendMessages(ctx, messages...); err != nil {
+		return Session{}, err
+	}
+	session.MessageCount += len(messages)
+	session.UpdatedAt = now
+	if err := s.repo.SaveSession(ctx, session); err != nil {
+		return Session{}, err
+	}
+	return s.withExpiry(ctx, session)
+}
+
+// Correlated returns every message sharing correlationID, across all owners, oldest first. It
+// serves the audit trail, so callers must check the reader may see other users' conversations.
+func (s *Service) Correlated(ctx context.Context, correlationID string) ([]Message, error) {
+	return s.repo.ListCorrelated(ctx, correlationID)
+}
+
+// History returns the most recent messages of one of owner's sessions that fit the context
+// window, oldest first. Older messages, and those before the context was last cleared, are left
+// out so the next prompt fits the model.
+func (s *Service) History(ctx context.Context, owner, id string) ([]Message, error) {
+	session, err := s.session(ctx, owner, id)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := s.repo.ListMessages(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	messages = messages[min(session.ContextStart, len(messages)):]
+	budget := s.contextTokens
+	first := len(messages)
+	for first > 0 && messages[first-1].Tokens <= budget {
+		budget -= messages[first-1].Tokens
+		first--
+	}
+	return messages[first:], nil
+}
+
+// ActiveContext returns what one of owner's sessions currently refers to, or nil when nothing is.
+func (s *Service) ActiveContext(ctx context.Context, owner, id string) (*ActiveContext, error) {
+	session, err := s.session(ctx, owner, id)
+	if err != nil {
+		return nil, err
+	}
+	return session.Context, nil
+}
+
+// ClearContext forgets what one of owner's sessions refers to, so the next prompt starts afresh
+// while the messages stay in the session.
+func (s *Service) ClearContext(ctx context.Context, owner, id string) error {
+	session, err := s.session(ctx, owner, id)
+	if err != nil {
+		return err
+	}
+	session.Context = nil
+	session.ContextStart = session.MessageCount
+	return s.repo.SaveSession(ctx, session)
+}
+
+// Retention returns how long owner's idle sessions are kept.
+func (s *Service) Retention(ctx context.Context, owner string) (Retention, error) {
+	chosen, err := s.repo.Retention(ctx)
+	if err != nil {
+		return Retention{}, err
+	}
+	if days, ok := chosen[owner]; ok {
+		return Retention{Days: days}, nil
+	}
+	return Retention{Days: s.defaultDays(), Default: true}, nil
+}
+
+// SetRetention changes how many days owner's idle sessions are kept; zero restores the default.
+// A shorter retention applies to existing sessions at the next sweep.
+func (s *Service) SetRetention(ctx context.Context, owner string, days int) (Retention, error) {
+	if days < 0 || days > MaxRetentionDays {
+		return Retention{}, ErrInvalidRequest{Reason: "days must be between 1 and 365, or 0 for the default"}
+	}
+	if err := s.repo.SaveRetention(ctx, owner, days); err != nil {
+		return Retention{}, err
+	}
+	return s.Retention(ctx, owner)
+}
+
+// Sweep deletes sessions idle for longer than their owner's retention and returns how many it removed.
+func (s *Service) Sweep(ctx context.Context) (int, error) {
+	chosen, err := s.repo.Retention(ctx)
+	if err != nil {
+		return 0, err
+	}
+	shortest := s.retention
+	for _, days := range chosen {
+		shortest = min(shortest, time.Duration(days)*24*time.Hour)
+	}
+	now := s.now()
+	idle, err := s.repo.IdleSessions(ctx, now.Add(-shortest))
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, session := range idle {
+		window := s.retention
+		if days, ok := chosen[session.Owner]; ok {
+			window = time.Duration(days) * 24 * time.Hour
+		}
+		if session.UpdatedAt.After(now.Add(-window)) {
+			continue
+		}
+		err := s.repo.DeleteSession(ctx, session.ID)
+		if errors.As(err, &ErrSessionNotFound{}) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Run sweeps expired sessions every interval until ctx is cancelled.
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			removed, err := s.Sweep(ctx)
+			if err != nil {
+				s.logger.Error("failed to sweep expired chat sessions", "error", err)
+			} else if removed > 0 {
+				s.logger.Info("swept expired chat sessions", "count", removed)
+			}
+		}
+	}
+}
+
+// session loads a session, hiding other users' sessions as missing.
+func (s *Service) session(ctx context.Context, owner, id string) (Session, error) {
+	session, err := s.repo.GetSession(ctx, id)
+	if err != nil {
+		return Session{}, err
+	}
+	if session.Owner != owner {
+		return Session{}, ErrSessionNotFound{ID: id}
+	}
+	return session, nil
+}
+
+func (s *Service) withExpiry(ctx context.Context, session Session) (Session, error) {
+	window, err := s.window(ctx, session.Owner)
+	if err != nil {
+		return Session{}, err
+	}
+	session.ExpiresAt = session.UpdatedAt.Add(window)
+	return session, nil
+}
+
+func (s *Service) window(ctx context.Context, owner string) (time.Duration, error) {
+	retention, err := s.Retention(ctx, owner)
+	if err != nil {
+		return 0, err
+	}
+	if retention.Default {
+		return s.retention, nil
+	}
+	return time.Duration(retention.Days) * 24 * time.Hour, nil
+}
+
+func (s *Service) defaultDays() int {
+	return int((s.retention + 24*time.Hour - 1) / (24 * time.Hour))
+}
+
+func truncateTitle(title string) string {
+	title = strings.Join(strings.Fields(title), " ")
+	if runes := []rune(title); len(runes) > maxTitleLength {
+		return string(runes[:maxTitleLength-1]) + "…"
+	}
+	return title
+}
@@ -0,0 +1,165 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+func newTestService(opts Options) (*Service, *time.Time) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc := NewService(NewMemoryRepository(), opts, log.NewWithOptions(io.Discard, log.Options{}))
+	svc.now = func() time.Time { return now }
+	return svc, &now
+}
+
+func TestConversationIsResumedAndContextTruncated(t *testing.T) {
+	svc, _ := newTestService(Options{ContextTokens: 10})
+	ctx := context.Background()
+
+	session, err := svc.Start(ctx, "alice", "")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if _, err := svc.Record(ctx, "alice", session.ID,
+		Message{Role: RoleUser, Content: "restart the api deployment in shop please"},
+		Message{Role: RoleAssistant, Content: "Plan for prod", Cluster: "prod", Namespace: "shop"},
+	); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	updated, err := svc.Record(ctx, "alice", session.ID, Message{Role: RoleUser, Content: "and the worker"})
+	if err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if updated.Title != "restart the api deployment in shop please" || updated.MessageCount != 3 {
+		t.Fatalf("expected the first prompt to title the session, got %+v", updated)
+	}
+
+	conversation, err := svc.Conversation(ctx, "alice", session.ID)
+	if err != nil {
+		t.Fatalf("conversation: %v", err)
+	}
+	if len(conversation.Messages) != 3 || conversation.Messages[0].Role != RoleUser {
+		t.Fatalf("expected every message oldest first, got %+v", conversation.Messages)
+	}
+
+	// The first prompt alone is over the budget, so only the two newest messages fit.
+	window, err := svc.History(ctx, "alice", session.ID)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if len(window) != 2 || window[0].Cluster != "prod" || window[1].Content != "and the worker" {
+		t.Fatalf("expected the context window to keep the newest messages, got %+v", window)
+	}
+
+	var notFound ErrSessionNotFound
+	if _, err := svc.Conversation(ctx, "bob", session.ID); !errors.As(err, &notFound) {
+		t.Fatalf("expected other users not to see the session, got %v", err)
+	}
+	if err := svc.Delete(ctx, "bob", session.ID); !errors.As(err, &notFound) {
+		t.Fatalf("expected other users not to delete the session, got %v", err)
+	}
+	if err := svc.Delete(ctx, "alice", session.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if sessions, _ := svc.Sessions(ctx, "alice"); len(sessions) != 0 {
+		t.Fatalf("expected the session to be gone, got %+v", sessions)
+	}
+}
+
+func TestSweepHonoursPerUserRetention(t *testing.T) {
+	svc, now := newTestService(Options{Retention: 30 * 24 * time.Hour})
+	ctx := context.Background()
+
+	alice, _ := svc.Start(ctx, "alice", "deploys")
+	bob, _ := svc.Start(ctx, "bob", "incident")
+	retention, err := svc.SetRetention(ctx, "alice", 7)
+	if err != nil || retention.Days != 7 || retention.Default {
+		t.Fatalf("unexpected retention %+v, %v", retention, err)
+	}
+	if _, err := svc.SetRetention(ctx, "alice", MaxRetentionDays+1); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected an out of range retention to be refused, got %v", err)
+	}
+	if sessions, _ := svc.Sessions(ctx, "alice"); !sessions[0].ExpiresAt.Equal(alice.UpdatedAt.Add(7 * 24 * time.Hour)) {
+		t.Fatalf("expected the expiry to follow the user's retention, got %s", sessions[0].ExpiresAt)
+	}
+
+	*now = now.Add(10 * 24 * time.Hour)
+	removed, err := svc.Sweep(ctx)
+	if err != nil || removed != 1 {
+		t.Fatalf("expected only alice's session to expire, removed %d: %v", removed, err)
+	}
+	if _, err := svc.Conversation(ctx, "alice", alice.ID); !errors.As(err, &ErrSessionNotFound{}) {
+		t.Fatalf("expected alice's session to be swept, got %v", err)
+	}
+	if _, err := svc.Conversation(ctx, "bob", bob.ID); err != nil {
+		t.Fatalf("expected bob's session to be kept by the default retention, got %v", err)
+	}
+
+	retention, _ = svc.SetRetention(ctx, "alice", 0)
+	if !retention.Default || retention.Days != 30 {
+		t.Fatalf("expected the default retention to be restored, got %+v", retention)
+	}
+}
+
+func TestTitlesAreTruncated(t *testing.T) {
+	svc, _ := newTestService(Options{})
+	session, err := svc.Start(context.Background(), "alice", strings.Repeat("scale ", 40))
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if len([]rune(session.Title)) != maxTitleLength || !strings.HasSuffix(session.Title, "…") {
+		t.Fatalf("expected a truncated title, got %q", session.Title)
+	}
+}
+
+func TestActiveContextFollowsPlansAndCanBeCleared(t *testing.T) {
+	svc, _ := newTestService(Options{})
+	ctx := context.Background()
+
+	session, _ := svc.Start(ctx, "alice", "")
+	if active, err := svc.ActiveContext(ctx, "alice", session.ID); err != nil || active != nil {
+		t.Fatalf("expected no context before a plan, got %+v, %v", active, err)
+	}
+	if _, err := svc.Record(ctx, "alice", session.ID,
+		Message{Role: RoleUser, Content: "restart the api deployment in shop"},
+		Message{Role: RoleAssistant, Content: "Plan", Cluster: "prod", Namespace: "shop", Resources: []string{"deployments/api"}},
+		Message{Role: RoleUser, Content: "and the worker deployment"},
+		Message{Role: RoleAssistant, Content: "Plan", Cluster: "prod", Namespace: "shop", Resources: []string{"deployments/worker"}},
+	); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	active, err := svc.ActiveContext(ctx, "alice", session.ID)
+	if err != nil {
+		t.Fatalf("active context: %v", err)
+	}
+	if active.Cluster != "prod" || active.Namespace != "shop" || strings.Join(active.Resources, ",") != "deployments/worker,deployments/api" {
+		t.Fatalf("expected the most recent resources first, got %+v", active)
+	}
+
+	svc.Record(ctx, "alice", session.ID, Message{Role: RoleAssistant, Content: "Plan", Cluster: "staging", Namespace: "default"})
+	if active, _ := svc.ActiveContext(ctx, "alice", session.ID); active.Cluster != "staging" || len(active.Resources) != 0 {
+		t.Fatalf("expected moving to another cluster to drop earlier resources, got %+v", active)
+	}
+
+	if err := svc.ClearContext(ctx, "bob", session.ID); !errors.As(err, &ErrSessionNotFound{}) {
+		t.Fatalf("expected other users not to clear the context, got %v", err)
+	}
+	if err := svc.ClearContext(ctx, "alice", session.ID); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	if active, _ := svc.ActiveContext(ctx, "alice", session.ID); active != nil {
+		t.Fatalf("expected the context to be cleared, got %+v", active)
+	}
+	if history, _ := svc.History(ctx, "alice", session.ID); len(history) != 0 {
+		t.Fatalf("expected cleared messages to stay out of the history, got %+v", history)
+	}
+	if conversation, _ := svc.Conversation(ctx, "alice", session.ID); len(conversation.Messages) != 5 {
+		t.Fatalf("expected clearing to keep the messages, got %d", len(conversation.Messages))
+	}
+}
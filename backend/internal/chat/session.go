@@ -0,0 +1,128 @@
+// Package chat keeps users' conversations with the assistant so they can reopen them later.
+// Every prompt and the plan it produced are stored as messages of a session. The most recent
+// messages that fit the context window accompany the next prompt of the session, together with
+// the session's active context: the cluster, namespace and resources the conversation last
+// referred to. Sessions are deleted once they have been idle for longer than their owner's retention.
+package chat
+
+import (
+	"fmt"
+	"slices"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultRetention is how long idle sessions are kept when no retention is configured.
+	DefaultRetention = 30 * 24 * time.Hour
+	// MaxRetentionDays bounds the retention users may choose for their own sessions.
+	MaxRetentionDays = 365
+	// DefaultContextTokens is the share of the model context window given to earlier messages.
+	DefaultContextTokens = 4000
+	// maxTitleLength bounds titles, which default to the first prompt of a session.
+	maxTitleLength = 80
+	// maxContextResources bounds the resources an active context remembers.
+	maxContextResources = 5
+)
+
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+)
+
+type Session struct {
+	ID           string    `json:"id"`
+	Owner        string    `json:"owner"`
+	Title        string    `json:"title"`
+	MessageCount int       `json:"messageCount"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+	// ExpiresAt is when the session is deleted unless it is used again.
+	ExpiresAt time.Time `json:"expiresAt"`
+	// Context is what the conversation currently refers to; nil until a plan targeted a cluster
+	// or after the context was cleared.
+	Context *ActiveContext `json:"context,omitempty"`
+	// ContextStart is the number of messages before the context was last cleared; earlier
+	// messages no longer accompany prompts.
+	ContextStart int `json:"-"`
+}
+
+// ActiveContext is the cluster, namespace and resources a conversation last referred to, which
+// follow-up prompts such as "now scale it to 3" resolve against.
+type ActiveContext struct {
+	Cluster   string `json:"cluster"`
+	Namespace string `json:"namespace,omitempty"`
+	// Resources are recently referenced resources as kind/name, most recent first.
+	Resources []string  `json:"resources,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// advance returns the context after an assistant message that answered with a plan.
+func (c *ActiveContext) advance(message Message, at time.Time) *ActiveContext {
+	next := &ActiveContext{Cluster: message.Cluster, Namespace: message.Namespace, UpdatedAt: at}
+	next.Resources = append(next.Resources, message.Resources...)
+	if c != nil && c.Cluster == message.Cluster && c.Namespace == message.Namespace {
+		for _, resource := range c.Resources {
+			if !slices.Contains(next.Resources, resource) {
+				next.Resources = append(next.Resources, resource)
+			}
+		}
+	}
+	if len(next.Resources) > maxContextResources {
+		next.Resources = next.Resources[:maxContextResources]
+	}
+	return next
+}
+
+type Message struct {
+	ID        string `json:"id"`
+	SessionID string `json:"sessionId"`
+	Role      Role   `json:"role"`
+	Content   string `json:"content"`
+	// PlanID, Cluster, Namespace and Resources describe the plan an assistant message answered with.
+	PlanID    string   `json:"planId,omitempty"`
+	Cluster   string   `json:"cluster,omitempty"`
+	Namespace string   `json:"namespace,omitempty"`
+	Resources []string `json:"resources,omitempty"`
+	// CorrelationID links a prompt and the reply with its plan to the commands that carried the
+	// plan out; it is the plan's ID.
+	CorrelationID string    `json:"correlationId,omitempty"`
+	Tokens        int       `json:"tokens"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// Conversation is a session with all of its messages, oldest first.
+type Conversation struct {
+	Session
+	Messages []Message `json:"messages"`
+}
+
+// Retention is how long a user's idle sessions are kept.
+type Retention struct {
+	Days int `json:"days"`
+	// Default reports that the user has not chosen a retention of their own.
+	Default bool `json:"default"`
+}
+
+type ErrSessionNotFound struct {
+	ID string
+}
+
+func (e ErrSessionNotFound) Error() string {
+	return fmt.Sprintf("chat session %s not found", e.ID)
+}
+
+type ErrInvalidRequest struct {
+	Reason string
+}
+
+func (e ErrInvalidRequest) Error() string {
+	return e.Reason
+}
+
+// EstimateTokens approximates how many model tokens text takes, at about four characters a token.
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
@@ -0,0 +1,108 @@
+// Package chatfiles keeps the manifests and small text files users attach to chat prompts so
+// the plan pipeline can refer to them. Uploads are size limited, scanned for secrets and
+// malware before they are stored, and removed from the attachment store once they expire.
+package chatfiles
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultMaxSize bounds an upload when no limit is configured.
+	DefaultMaxSize = 1 << 20
+	// DefaultTTL is how long an attachment stays available after it was uploaded.
+	DefaultTTL = 24 * time.Hour
+	// MaxPerPrompt bounds how many attachments one prompt may reference.
+	MaxPerPrompt = 5
+)
+
+// Kind tells manifests, which commands can apply, from other text files.
+type Kind string
+
+const (
+	KindManifest Kind = "manifest"
+	KindFile     Kind = "file"
+)
+
+// File describes a stored attachment. The content itself lives in the attachment store under Key.
+type File struct {
+	ID          string `json:"id"`
+	Owner       string `json:"owner"`
+	Name        string `json:"name"`
+	ContentType string `json:"contentType"`
+	Kind        Kind   `json:"kind"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+	Key         string `json:"-"`
+	// Objects lists the Kubernetes objects in a manifest as Kind/name or Kind/namespace/name.
+	Objects   []string  `json:"objects,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func (f File) expired(now time.Time) bool {
+	return !now.Before(f.ExpiresAt)
+}
+
+// Finding is one reason a scanner rejected an upload. It never carries the matched content.
+type Finding struct {
+	Scanner     string `json:"scanner"`
+	Rule        string `json:"rule"`
+	Line        int    `json:"line,omitempty"`
+	Description string `json:"description"`
+}
+
+type ErrFileNotFound struct {
+	ID string
+}
+
+func (e ErrFileNotFound) Error() string {
+	return fmt.Sprintf("attachment %s not found", e.ID)
+}
+
+type ErrInvalidRequest struct {
+	Reason string
+}
+
+func (e ErrInvalidRequest) Error() string {
+	return e.Reason
+}
+
+// ErrTooLarge is returned for uploads over the configured size limit.
+type ErrTooLarge struct {
+	Limit int64
+}
+
+func (e ErrTooLarge) Error() string {
+	return fmt.Sprintf("attachment exceeds the %d byte limit", e.Limit)
+}
+
+// ErrRejected is returned when a scanner found secrets or malware in an upload.
+type ErrRejected struct {
+	Findings []Finding
+}
+
+func (e ErrRejected) Error() string {
+	rules := make([]string, 0, len(e.Findings))
+	for _, finding := range e.Findings {
+		rules = append(rules, finding.Rule)
+	}
+	return "attachment rejected by content scan: " + strings.Join(rules, ", ")
+}
+
+// ErrScanFailed is returned when a scanner could not check an upload; the upload is refused
+// rather than stored unchecked.
+type ErrScanFailed struct {
+	Scanner string
+	Err     error
+}
+
+func (e ErrScanFailed) Error() string {
+	return fmt.Sprintf("%s scan failed: %v", e.Scanner, e.Err)
+}
+
+func (e ErrScanFailed) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,61 @@
+package chatfiles
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+var manifestExtensions = map[string]bool{".yaml": true, ".yml": true, ".json": true}
+
+// decodeObjects parses every YAML document or JSON object in data, skipping empty documents.
+func decodeObjects(data []byte) ([]*unstructured.Unstructured, error) {
+	decoder := yamlutil.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	var objects []*unstructured.Unstructured
+	for {
+		var doc map[string]any
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objects, nil
+			}
+			return nil, err
+		}
+		if len(doc) == 0 {
+			continue
+		}
+		objects = append(objects, &unstructured.Unstructured{Object: doc})
+	}
+}
+
+// classify decides whether an upload is a Kubernetes manifest and lists its objects. Files named
+// like YAML or JSON must parse; they are manifests only if every document is a named object, so
+// Helm values and similar files stay plain files.
+func classify(name string, data []byte) (Kind, []string, error) {
+	objects, err := decodeObjects(data)
+	if err != nil {
+		if manifestExtensions[strings.ToLower(path.Ext(name))] {
+			return "", nil, ErrInvalidRequest{Reason: name + " is not valid YAML or JSON: " + err.Error()}
+		}
+		return KindFile, nil, nil
+	}
+	if len(objects) == 0 {
+		return KindFile, nil, nil
+	}
+	refs := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		if obj.GetAPIVersion() == "" || obj.GetKind() == "" || obj.GetName() == "" {
+			return KindFile, nil, nil
+		}
+		ref := obj.GetKind() + "/" + obj.GetName()
+		if namespace := obj.GetNamespace(); namespace != "" {
+			ref = obj.GetKind() + "/" + namespace + "/" + obj.GetName()
+		}
+		refs = append(refs, ref)
+	}
+	return KindManifest, refs, nil
+}
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS chat_attachments (
+    id TEXT PRIMARY KEY,
+    owner TEXT NOT NULL,
+    body JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS chat_attachments_owner_idx ON chat_attachments (owner, created_at);
+CREATE INDEX IF NOT EXISTS chat_attachments_expires_idx ON chat_attachments (expires_at);
@@ -0,0 +1,122 @@
+package chatfiles
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pramodksahoo/kubechat/backend/internal/database"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// PostgresRepository stores each attachment's metadata as a JSON document next to the columns
+// used for lookups and expiry.
+type PostgresRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresRepository applies the chat attachment schema migrations and returns a repository backed by db.
+func NewPostgresRepository(ctx context.Context, db *sqlx.DB) (*PostgresRepository, error) {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Migrate(ctx, db, "chatfiles", sub); err != nil {
+		return nil, err
+	}
+	return &PostgresRepository{db: db}, nil
+}
+
+type fileRow struct {
+	ID        string    `db:"id"`
+	Owner     string    `db:"owner"`
+	Body      []byte    `db:"body"`
+	CreatedAt time.Time `db:"created_at"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+func (r *PostgresRepository) Save(ctx context.Context, file File) error {
+	// Key is not part of the JSON form, so it travels in a wrapper.
+	body, err := json.Marshal(storedFile{File: file, Key: file.Key})
+	if err != nil {
+		return err
+	}
+	_, err = r.db.NamedExecContext(ctx, `INSERT INTO chat_attachments (id, owner, body, created_at, expires_at)
+		VALUES (:id, :owner, :body, :created_at, :expires_at)
+		ON CONFLICT (id) DO UPDATE SET body = EXCLUDED.body, expires_at = EXCLUDED.expires_at`, fileRow{
+		ID:        file.ID,
+		Owner:     file.Owner,
+		Body:      body,
+		CreatedAt: file.CreatedAt,
+		ExpiresAt: file.ExpiresAt,
+	})
+	return err
+}
+
+func (r *PostgresRepository) Get(ctx context.Context, id string) (File, error) {
+	var body []byte
+	err := r.db.GetContext(ctx, &body, `SELECT body FROM chat_attachments WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return File{}, ErrFileNotFound{ID: id}
+	}
+	if err != nil {
+		return File{}, err
+	}
+	return decodeFile(body)
+}
+
+func (r *PostgresRepository) List(ctx context.Context, owner string) ([]File, error) {
+	return r.selectFiles(ctx, `SELECT body FROM chat_attachments WHERE owner = $1 ORDER BY created_at DESC`, owner)
+}
+
+func (r *PostgresRepository) Expired(ctx context.Context, now time.Time) ([]File, error) {
+	return r.selectFiles(ctx, `SELECT body FROM chat_attachments WHERE expires_at <= $1`, now)
+}
+
+func (r *PostgresRepository) Delete(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM chat_attachments WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrFileNotFound{ID: id}
+	}
+	return nil
+}
+
+func (r *PostgresRepository) selectFiles(ctx context.Context, query string, args ...any) ([]File, error) {
+	var bodies [][]byte
+	if err := r.db.SelectContext(ctx, &bodies, query, args...); err != nil {
+		return nil, err
+	}
+	files := make([]File, 0, len(bodies))
+	for _, body := range bodies {
+		file, err := decodeFile(body)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+type storedFile struct {
+	File
+	Key string `json:"key"`
+}
+
+func decodeFile(body []byte) (File, error) {
+	var stored storedFile
+	if err := json.Unmarshal(body, &stored); err != nil {
+		return File{}, err
+	}
+	stored.File.Key = stored.Key
+	return stored.File, nil
+}
@@ -0,0 +1,81 @@
+package chatfiles
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Repository persists attachment metadata; the content is kept in the attachment store.
+type Repository interface {
+	Save(ctx context.Context, file File) error
+	Get(ctx context.Context, id string) (File, error)
+	// List returns owner's attachments, newest first.
+	List(ctx context.Context, owner string) ([]File, error)
+	// Expired returns every attachment that expired before now.
+	Expired(ctx context.Context, now time.Time) ([]File, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// MemoryRepository keeps attachment metadata in process memory; it is used when no database is configured.
+type MemoryRepository struct {
+	mu    sync.RWMutex
+	files map[string]File
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{files: make(map[string]File)}
+}
+
+func (r *MemoryRepository) Save(ctx context.Context, file File) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.files[file.ID] = file
+	return nil
+}
+
+func (r *MemoryRepository) Get(ctx context.Context, id string) (File, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	file, ok := r.files[id]
+	if !ok {
+		return File{}, ErrFileNotFound{ID: id}
+	}
+	return file, nil
+}
+
+func (r *MemoryRepository) List(ctx context.Context, owner string) ([]File, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	files := make([]File, 0)
+	for _, file := range r.files {
+		if file.Owner == owner {
+			files = append(files, file)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].CreatedAt.After(files[j].CreatedAt) })
+	return files, nil
+}
+
+func (r *MemoryRepository) Expired(ctx context.Context, now time.Time) ([]File, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	files := make([]File, 0)
+	for _, file := range r.files {
+		if file.expired(now) {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+func (r *MemoryRepository) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.files[id]; !ok {
+		return ErrFileNotFound{ID: id}
+	}
+	delete(r.files, id)
+	return nil
+}
@@ -0,0 +1,178 @@
+package chatfiles
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Scanner checks an upload before it is stored. Findings reject the upload; an error means the
+// content could not be checked.
+type Scanner interface {
+	Name() string
+	Scan(ctx context.Context, name string, data []byte) ([]Finding, error)
+}
+
+type secretRule struct {
+	rule        string
+	description string
+	pattern     *regexp.Regexp
+}
+
+var secretRules = []secretRule{
+	{"private-key", "PEM private key", regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY-----`)},
+	{"aws-access-key", "AWS access key ID", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"github-token", "GitHub token", regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},
+	{"slack-token", "Slack token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+	{"jwt", "JSON web token, such as a service account token", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{"password-assignment", "literal password or API key", regexp.MustCompile(`(?i)\b(?:password|passwd|api[_-]?key|client[_-]?secret)\s*[:=]\s*["']?[^\s"'$\{<][^\s"']{7,}`)},
+}
+
+// SecretScanner rejects credentials pasted into attachments, and manifests carrying Secret data.
+type SecretScanner struct{}
+
+func (SecretScanner) Name() string { return "secrets" }
+
+func (SecretScanner) Scan(ctx context.Context, name string, data []byte) ([]Finding, error) {
+	var findings []Finding
+	for _, rule := range secretRules {
+		for _, match := range rule.pattern.FindAllIndex(data, -1) {
+			findings = append(findings, Finding{
+				Scanner:     "secrets",
+				Rule:        rule.rule,
+				Line:        bytes.Count(data[:match[0]], []byte("\n")) + 1,
+				Description: rule.description,
+			})
+		}
+	}
+	// Unparseable content is left to the other rules; manifests are validated after scanning.
+	objects, _ := decodeObjects(data)
+	for _, obj := range objects {
+		if obj.GetKind() != "Secret" {
+			continue
+		}
+		secretData, _, _ := unstructured.NestedMap(obj.Object, "data")
+		stringData, _, _ := unstructured.NestedMap(obj.Object, "stringData")
+		if len(secretData) == 0 && len(stringData) == 0 {
+			continue
+		}
+		findings = append(findings, Finding{
+			Scanner:     "secrets",
+			Rule:        "kubernetes-secret",
+			Description: fmt.Sprintf("Secret %s carries data; reference an existing Secret instead", obj.GetName()),
+		})
+	}
+	return findings, nil
+}
+
+// eicar is the standard antivirus test file.
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+var executableMagic = []struct {
+	rule  string
+	magic []byte
+}{
+	{"elf-executable", []byte("\x7fELF")},
+	{"pe-executable", []byte("MZ")},
+	{"macho-executable", []byte("\xcf\xfa\xed\xfe")},
+	{"macho-executable", []byte("\xce\xfa\xed\xfe")},
+	{"macho-executable", []byte("\xca\xfe\xba\xbe")},
+}
+
+// SignatureScanner is the built-in malware check: it rejects the EICAR test file and executables,
+// which have no place in a chat attachment. Deployments that need real signatures add a ClamdScanner.
+type SignatureScanner struct{}
+
+func (SignatureScanner) Name() string { return "signatures" }
+
+func (SignatureScanner) Scan(ctx context.Context, name string, data []byte) ([]Finding, error) {
+	var findings []Finding
+	if bytes.Contains(data, []byte(eicar)) {
+		findings = append(findings, Finding{Scanner: "signatures", Rule: "eicar-test-file", Description: "EICAR antivirus test file"})
+	}
+	for _, exe := range executableMagic {
+		if bytes.HasPrefix(data, exe.magic) {
+			findings = append(findings, Finding{Scanner: "signatures", Rule: exe.rule, Description: "executable binary"})
+			break
+		}
+	}
+	return findings, nil
+}
+
+// ClamdScanner streams uploads to a clamd daemon with the INSTREAM command.
+type ClamdScanner struct {
+	// Address is host:port for TCP or a path for a unix socket.
+	Address string
+	Timeout time.Duration
+}
+
+func (c ClamdScanner) Name() string { return "clamav" }
+
+func (c ClamdScanner) Scan(ctx context.Context, name string, data []byte) ([]Finding, error) {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	network := "tcp"
+	if strings.HasPrefix(c.Address, "/") {
+		network = "unix"
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, c.Address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, err
+	}
+	const chunkSize = 64 << 10
+	for offset := 0; offset < len(data); offset += chunkSize {
+		chunk := data[offset:min(offset+chunkSize, len(data))]
+		if err := binary.Write(conn, binary.BigEndian, uint32(len(chunk))); err != nil {
+			return nil, err
+		}
+		if _, err := conn.Write(chunk); err != nil {
+			return nil, err
+		}
+	}
+	if err := binary.Write(conn, binary.BigEndian, uint32(0)); err != nil {
+		return nil, err
+	}
+
+	reply, err := io.ReadAll(conn)
+	if err != nil {
+		return nil, err
+	}
+	return parseClamdReply(string(reply))
+}
+
+// parseClamdReply reads "stream: OK" or "stream: <signature> FOUND".
+func parseClamdReply(reply string) ([]Finding, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	result := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case result == "OK":
+		return nil, nil
+	case strings.HasSuffix(result, " FOUND"):
+		signature := strings.TrimSuffix(result, " FOUND")
+		return []Finding{{Scanner: "clamav", Rule: signature, Description: "malware signature " + signature}}, nil
+	default:
+		return nil, fmt.Errorf("clamd: %s", reply)
+	}
+}
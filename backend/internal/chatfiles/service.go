@@ -0,0 +1,278 @@
+package chatfiles
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/pramodksahoo/kubechat/backend/internal/attachments"
+	"github.com/pramodksahoo/kubechat/backend/internal/plan"
+)
+
+// Options bound uploads; zero values take the defaults.
+type Options struct {
+	MaxSize int64
+	TTL     time.Duration
+}
+
+type Service struct {
+	repo     Repository
+	store    attachments.Store
+	scanners []Scanner
+	maxSize  int64
+	ttl      time.Duration
+	logger   *log.Logger
+	now      func() time.Time
+}
+
+// NewService stores uploads in store after every scanner accepted them.
+func NewService(repo Repository, store attachments.Store, opts Options, logger *log.Logger, scanners ...Scanner) *Service {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultTTL
+	}
+	return &Service{
+		repo:     repo,
+		store:    store,
+		scanners: scanners,
+		maxSize:  opts.MaxSize,
+		ttl:      opts.TTL,
+		logger:   logger,
+		now:      func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// Upload scans and stores a file for owner. Content is read up to the size limit.
+func (s *Service) Upload(ctx context.Context, owner, name, contentType string, r io.Reader) (File, error) {
+	name = path.Base(strings.ReplaceAll(strings.TrimSpace(name), `\`, "/"))
+	if name == "" || name == "." || name == "/" {
+		return File{}, ErrInvalidRequest{Reason: "file name is required"}
+	}
+	data, err := io.ReadAll(io.LimitReader(r, s.maxSize+1))
+	if err != nil {
+		return File{}, err
+	}
+	if int64(len(data)) > s.maxSize {
+		return File{}, ErrTooLarge{Limit: s.maxSize}
+	}
+	if len(data) == 0 {
+		return File{}, ErrInvalidRequest{Reason: "file is empty"}
+	}
+
+	if err := s.scan(ctx, owner, name, data); err != nil {
+		return File{}, err
+	}
+	if !utf8.Valid(data) || strings.ContainsRune(string(data), 0) {
+		return File{}, ErrInvalidRequest{Reason: "only text files can be attached"}
+	}
+	kind, objects, err := classify(name, data)
+	if err != nil {
+		return File{}, err
+	}
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = "text/plain; charset=utf-8"
+		if kind == KindManifest {
+			contentType = "application/yaml"
+		}
+	}
+
+	sum := sha256.Sum256(data)
+	created := s.now()
+	file := File{
+		ID:          uuid.NewString(),
+		Owner:       owner,
+		Name:        name,
+		ContentType: contentType,
+		Kind:        kind,
+		Size:        int64(len(data)),
+		SHA256:      hex.EncodeToString(sum[:]),
+		Objects:     objects,
+		CreatedAt:   created,
+		ExpiresAt:   created.Add(s.ttl),
+	}
+	file.Key = "chat/" + file.ID
+	if err := s.store.Put(ctx, file.Key, file.ContentType, data); err != nil {
+		return File{}, err
+	}
+	if err := s.repo.Save(ctx, file); err != nil {
+		s.deleteContent(ctx, file)
+		return File{}, err
+	}
+	return file, nil
+}
+
+// scan runs every scanner and rejects the upload when any of them reports a finding.
+func (s *Service) scan(ctx context.Context, owner, name string, data []byte) error {
+	var findings []Finding
+	for _, scanner := range s.scanners {
+		found, err := scanner.Scan(ctx, name, data)
+		if err != nil {
+			s.logger.Error("attachment scan failed", "scanner", scanner.Name(), "owner", owner, "name", name, "error", err)
+			return ErrScanFailed{Scanner: scanner.Name(), Err: err}
+		}
+		findings = append(findings, found...)
+	}
+	if len(findings) == 0 {
+		return nil
+	}
+	rules := make([]string, 0, len(findings))
+	for _, finding := range findings {
+		rules = append(rules, finding.Scanner+"/"+finding.Rule)
+	}
+	s.logger.Warn("attachment rejected", "owner", owner, "name", name, "findings", strings.Join(rules, ","))
+	return ErrRejected{Findings: findings}
+}
+
+// Get returns one of owner's attachments. Attachments of other users and expired ones are not found.
+func (s *Service) Get(ctx context.Context, owner, id string) (File, error) {
+	file, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return File{}, err
+	}
+	if file.Owner != owner || file.expired(s.now()) {
+		return File{}, ErrFileNotFound{ID: id}
+	}
+	return file, nil
+}
+
+// Open returns the content of one of owner's attachments.
+func (s *Service) Open(ctx context.Context, owner, id string) (File, io.ReadCloser, error) {
+	file, err := s.Get(ctx, owner, id)
+	if err != nil {
+		return File{}, nil, err
+	}
+	reader, err := s.store.Open(ctx, file.Key)
+	if errors.Is(err, attachments.ErrNotFound) {
+		return File{}, nil, ErrFileNotFound{ID: id}
+	}
+	return file, reader, err
+}
+
+// List returns owner's attachments that have not expired, newest first.
+func (s *Service) List(ctx context.Context, owner string) ([]File, error) {
+	files, err := s.repo.List(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	live := make([]File, 0, len(files))
+	for _, file := range files {
+		if !file.expired(now) {
+			live = append(live, file)
+		}
+	}
+	return live, nil
+}
+
+// Delete removes one of owner's attachments before it expires.
+func (s *Service) Delete(ctx context.Context, owner, id string) error {
+	file, err := s.Get(ctx, owner, id)
+	if err != nil {
+		return err
+	}
+	s.deleteContent(ctx, file)
+	return s.repo.Delete(ctx, id)
+}
+
+// Manifest returns the text of one of user's manifest attachments, letting commands apply it.
+func (s *Service) Manifest(ctx context.Context, user, id string) (string, error) {
+	file, content, err := s.read(ctx, user, id)
+	if err != nil {
+		return "", err
+	}
+	if file.Kind != KindManifest {
+		return "", ErrInvalidRequest{Reason: "attachment " + file.Name + " is not a Kubernetes manifest"}
+	}
+	return content, nil
+}
+
+// Resolve loads the attachments a prompt references so the plan builder can use them.
+func (s *Service) Resolve(ctx context.Context, owner string, ids []string) ([]plan.Attachment, error) {
+	if len(ids) > MaxPerPrompt {
+		return nil, ErrInvalidRequest{Reason: fmt.Sprintf("a prompt may reference at most %d attachments", MaxPerPrompt)}
+	}
+	resolved := make([]plan.Attachment, 0, len(ids))
+	for _, id := range ids {
+		file, content, err := s.read(ctx, owner, id)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, plan.Attachment{
+			ID:       file.ID,
+			Name:     file.Name,
+			Manifest: file.Kind == KindManifest,
+			Objects:  file.Objects,
+			Content:  content,
+		})
+	}
+	return resolved, nil
+}
+
+func (s *Service) read(ctx context.Context, owner, id string) (File, string, error) {
+	file, reader, err := s.Open(ctx, owner, id)
+	if err != nil {
+		return File{}, "", err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return File{}, "", err
+	}
+	return file, string(data), nil
+}
+
+// Sweep removes expired attachments from the store and the repository and reports how many it removed.
+func (s *Service) Sweep(ctx context.Context) (int, error) {
+	expired, err := s.repo.Expired(ctx, s.now())
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, file := range expired {
+		s.deleteContent(ctx, file)
+		if err := s.repo.Delete(ctx, file.ID); err != nil && !errors.As(err, &ErrFileNotFound{}) {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Run sweeps expired attachments every interval until ctx is done.
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			removed, err := s.Sweep(ctx)
+			if err != nil {
+				s.logger.Error("failed to sweep expired chat attachments", "error", err)
+			} else if removed > 0 {
+				s.logger.Info("swept expired chat attachments", "count", removed)
+			}
+		}
+	}
+}
+
+func (s *Service) deleteContent(ctx context.Context, file File) {
+	if err := s.store.Delete(ctx, file.Key); err != nil && !errors.Is(err, attachments.ErrNotFound) {
+		s.logger.Warn("failed to delete chat attachment content", "id", file.ID, "error", err)
+	}
+}
@@ -0,0 +1,205 @@
+// Package clusters registers Kubernetes clusters at runtime, persists their credentials and
+// tracks the health of every cluster the application can reach.
+package clusters
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// AuthType selects how a registered cluster authenticates.
+type AuthType string
+
+const (
+	// AuthKubeconfig uses one context of an uploaded kubeconfig, e.g. from a secret.
+	AuthKubeconfig AuthType = "kubeconfig"
+	AuthToken      AuthType = "token"
+	AuthOIDC       AuthType = "oidc"
+)
+
+// Source tells where a cluster listed by the manager comes from.
+type Source string
+
+const (
+	SourceRegistered Source = "registered"
+	SourceKubeconfig Source = "kubeconfig"
+)
+
+// OIDC holds the settings of the client-go oidc auth provider.
+type OIDC struct {
+	IssuerURL    string `json:"issuerUrl"`
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret,omitempty"`
+	IDToken      string `json:"idToken"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+}
+
+// Spec is the registration request. Credentials are written to a kubeconfig and never returned.
+type Spec struct {
+	Name     string            `json:"name"`
+	AuthType AuthType          `json:"authType"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	// Kubeconfig and Context are used with AuthKubeconfig; Context defaults to the current context.
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+	Context    string `json:"context,omitempty"`
+	// Server, CAData and Insecure describe the API server for token and OIDC authentication.
+	Server   string `json:"server,omitempty"`
+	CAData   string `json:"caData,omitempty"`
+	Insecure bool   `json:"insecure,omitempty"`
+	Token    string `json:"token,omitempty"`
+	OIDC     *OIDC  `json:"oidc,omitempty"`
+}
+
+// HasCredentials reports whether the spec carries connection details, as opposed to a
+// metadata-only update.
+func (s Spec) HasCredentials() bool {
+	return s.Kubeconfig != "" || s.Server != "" || s.Token != "" || s.OIDC != nil
+}
+
+// Registration is a cluster added through the API.
+type Registration struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	AuthType  AuthType          `json:"authType"`
+	Server    string            `json:"server"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	CreatedBy string            `json:"createdBy"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+	// Kubeconfig is the generated single-context kubeconfig; it is stored but never serialized.
+	Kubeconfig []byte `json:"-"`
+}
+
+type Health struct {
+	Status    string    `json:"status"`
+	Version   string    `json:"version,omitempty"`
+	LatencyMs int64     `json:"latencyMs"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+const (
+	HealthHealthy     = "healthy"
+	HealthUnreachable = "unreachable"
+)
+
+// Cluster is one config/context pair the application can talk to. Config and Context are the
+// values passed as ?config= and ?cluster= to the rest of the API.
+type Cluster struct {
+	ID        string            `json:"id,omitempty"`
+	Name      string            `json:"name"`
+	Config    string            `json:"config"`
+	Context   string            `json:"context"`
+	Source    Source            `json:"source"`
+	AuthType  AuthType          `json:"authType,omitempty"`
+	Server    string            `json:"server,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Connected bool              `json:"connected"`
+	Health    *Health           `json:"health,omitempty"`
+	CreatedBy string            `json:"createdBy,omitempty"`
+	CreatedAt *time.Time        `json:"createdAt,omitempty"`
+}
+
+type ErrClusterNotFound struct {
+	ID string
+}
+
+func (e ErrClusterNotFound) Error() string {
+	return fmt.Sprintf("cluster %s not found", e.ID)
+}
+
+type ErrInvalidRequest struct {
+	Reason string
+}
+
+func (e ErrInvalidRequest) Error() string {
+	return e.Reason
+}
+
+var namePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+
+// kubeconfig validates the spec and renders a kubeconfig with a single context named after the
+// cluster. It returns the API server URL alongside.
+func (s Spec) kubeconfig() ([]byte, string, error) {
+	if !namePattern.MatchString(s.Name) || len(s.Name) > 63 {
+		return nil, "", ErrInvalidRequest{Reason: "name must be a lowercase DNS label"}
+	}
+	cluster := clientcmdapi.NewCluster()
+	user := clientcmdapi.NewAuthInfo()
+
+	switch s.AuthType {
+	case AuthKubeconfig:
+		source, err := clientcmd.Load([]byte(s.Kubeconfig))
+		if err != nil {
+			return nil, "", ErrInvalidRequest{Reason: fmt.Sprintf("invalid kubeconfig: %v", err)}
+		}
+		contextName := s.Context
+		if contextName == "" {
+			contextName = source.CurrentContext
+		}
+		context, ok := source.Contexts[contextName]
+		if !ok {
+			return nil, "", ErrInvalidRequest{Reason: fmt.Sprintf("context %q not found in kubeconfig", contextName)}
+		}
+		if cluster, ok = source.Clusters[context.Cluster]; !ok {
+			return nil, "", ErrInvalidRequest{Reason: fmt.Sprintf("cluster %q not found in kubeconfig", context.Cluster)}
+		}
+		if user, ok = source.AuthInfos[context.AuthInfo]; !ok {
+			return nil, "", ErrInvalidRequest{Reason: fmt.Sprintf("user %q not found in kubeconfig", context.AuthInfo)}
+		}
+		if cluster.CertificateAuthority != "" || user.ClientCertificate != "" || user.ClientKey != "" || user.TokenFile != "" {
+			return nil, "", ErrInvalidRequest{Reason: "kubeconfig must embed certificates and tokens instead of referencing files"}
+		}
+		// Exec plugins would run arbitrary commands on the server.
+		if user.Exec != nil {
+			return nil, "", ErrInvalidRequest{Reason: "kubeconfig exec credential plugins are not supported"}
+		}
+	case AuthToken, AuthOIDC:
+		if !strings.HasPrefix(s.Server, "https://") && !strings.HasPrefix(s.Server, "http://") {
+			return nil, "", ErrInvalidRequest{Reason: "server must be an http(s) URL"}
+		}
+		cluster.Server = s.Server
+		cluster.CertificateAuthorityData = []byte(s.CAData)
+		cluster.InsecureSkipTLSVerify = s.Insecure
+		if s.AuthType == AuthToken {
+			if s.Token == "" {
+				return nil, "", ErrInvalidRequest{Reason: "token is required"}
+			}
+			user.Token = s.Token
+			break
+		}
+		if s.OIDC == nil || s.OIDC.IssuerURL == "" || s.OIDC.ClientID == "" || s.OIDC.IDToken == "" {
+			return nil, "", ErrInvalidRequest{Reason: "oidc issuerUrl, clientId and idToken are required"}
+		}
+		settings := map[string]string{
+			"idp-issuer-url": s.OIDC.IssuerURL,
+			"client-id":      s.OIDC.ClientID,
+			"id-token":       s.OIDC.IDToken,
+		}
+		if s.OIDC.ClientSecret != "" {
+			settings["client-secret"] = s.OIDC.ClientSecret
+		}
+		if s.OIDC.RefreshToken != "" {
+			settings["refresh-token"] = s.OIDC.RefreshToken
+		}
+		user.AuthProvider = &clientcmdapi.AuthProviderConfig{Name: "oidc", Config: settings}
+	default:
+		return nil, "", ErrInvalidRequest{Reason: "authType must be kubeconfig, token or oidc"}
+	}
+
+	config := clientcmdapi.NewConfig()
+	config.Clusters[s.Name] = cluster
+	config.AuthInfos[s.Name] = user
+	config.Contexts[s.Name] = &clientcmdapi.Context{Cluster: s.Name, AuthInfo: s.Name}
+	config.CurrentContext = s.Name
+	data, err := clientcmd.Write(*config)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, cluster.Server, nil
+}
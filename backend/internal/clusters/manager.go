@@ -0,0 +1,365 @@
+package clusters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"k8s.io/client-go/kubernetes"
+)
+
+// probeTimeout bounds a single health check against an API server.
+const probeTimeout = 10 * time.Second
+
+// Context is one config/context pair loaded by the application.
+type Context struct {
+	Config    string
+	Context   string
+	Server    string
+	Connected bool
+}
+
+// Catalog is the application's set of loaded kubeconfigs. Put and Remove manage the kubeconfig
+// files of registered clusters, which are keyed by registration ID.
+type Catalog interface {
+	Contexts() []Context
+	Client(config, context string) (kubernetes.Interface, bool)
+	Put(id string, kubeconfig []byte) error
+	Remove(id string) error
+}
+
+// Manager registers clusters, keeps their kubeconfigs in the catalog and records the last
+// health check of every known cluster.
+type Manager struct {
+	repo    Repository
+	catalog Catalog
+	logger  *log.Logger
+	clock   func() time.Time
+
+	mu     sync.Mutex
+	health map[string]Health
+}
+
+func NewManager(repo Repository, catalog Catalog, logger *log.Logger) *Manager {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Manager{
+		repo:    repo,
+		catalog: catalog,
+		logger:  logger,
+		clock:   func() time.Time { return time.Now().UTC() },
+		health:  map[string]Health{},
+	}
+}
+
+// Restore writes the kubeconfig of every stored registration to the catalog, so clusters
+// registered on another replica or before a restart are available.
+func (m *Manager) Restore(ctx context.Context) error {
+	registrations, err := m.repo.List(ctx)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, registration := range registrations {
+		if err := m.catalog.Put(registration.ID, registration.Kubeconfig); err != nil {
+			errs = append(errs, fmt.Errorf("restore cluster %s: %w", registration.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Manager) Register(ctx context.Context, actor string, spec Spec) (Cluster, error) {
+	kubeconfig, server, err := spec.kubeconfig()
+	if err != nil {
+		return Cluster{}, err
+	}
+	if err := m.checkNameFree(ctx, spec.Name); err != nil {
+		return Cluster{}, err
+	}
+	now := m.clock()
+	registration := Registration{
+		ID:         uuid.NewString(),
+		Name:       spec.Name,
+		AuthType:   spec.AuthType,
+		Server:     server,
+		Labels:     spec.Labels,
+		CreatedBy:  actor,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		Kubeconfig: kubeconfig,
+	}
+	if err := m.catalog.Put(registration.ID, kubeconfig); err != nil {
+		return Cluster{}, err
+	}
+	if err := m.repo.Save(ctx, registration); err != nil {
+		m.catalog.Remove(registration.ID)
+		return Cluster{}, err
+	}
+	return m.registered(registration), nil
+}
+
+// Update replaces a registration's labels and, when the spec carries them, its credentials.
+// The name is the cluster's context and cannot change.
+func (m *Manager) Update(ctx context.Context, id string, spec Spec) (Cluster, error) {
+	registration, err := m.repo.Get(ctx, id)
+	if err != nil {
+		return Cluster{}, err
+	}
+	if spec.Name != "" && spec.Name != registration.Name {
+		return Cluster{}, ErrInvalidRequest{Reason: "cluster name cannot be changed"}
+	}
+	registration.Labels = spec.Labels
+	registration.UpdatedAt = m.clock()
+	if spec.HasCredentials() {
+		spec.Name = registration.Name
+		kubeconfig, server, err := spec.kubeconfig()
+		if err != nil {
+			return Cluster{}, err
+		}
+		registration.AuthType, registration.Server, registration.Kubeconfig = spec.AuthType, server, kubeconfig
+		if err := m.catalog.Put(registration.ID, kubeconfig); err != nil {
+			return Cluster{}, err
+		}
+		m.forget(registration.ID, registration.Name)
+	}
+	if err := m.repo.Save(ctx, registration); err != nil {
+		return Cluster{}, err
+	}
+	return m.registered(registration), nil
+}
+
+func (m *Manager) Delete(ctx context.Context, id string) error {
+	registration, err := m.repo.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := m.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	m.forget(registration.ID, registration.Name)
+	return m.catalog.Remove(id)
+}
+
+func (m *Manager) Get(ctx context.Context, id string) (Cluster, error) {
+	registration, err := m.repo.Get(ctx, id)
+	if err != nil {
+		return Cluster{}, err
+	}
+	return m.registered(registration), nil
+}
+
+// List returns registered clusters and every other context loaded from kubeconfig files.
+func (m *Manager) List(ctx context.Context) ([]Cluster, error) {
+	registrations, err := m.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]Registration, len(registrations))
+	for _, registration := range registrations {
+		byID[registration.ID] = registration
+	}
+
+	clusters := []Cluster{}
+	for _, kc := range m.catalog.Contexts() {
+		if _, ok := byID[kc.Config]; ok {
+			continue
+		}
+		clusters = append(clusters, Cluster{
+			Name:      kc.Context,
+			Config:    kc.Config,
+			Context:   kc.Context,
+			Source:    SourceKubeconfig,
+			Server:    kc.Server,
+			Connected: kc.Connected,
+			Health:    m.lastHealth(kc.Config, kc.Context),
+		})
+	}
+	for _, registration := range registrations {
+		clusters = append(clusters, m.registered(registration))
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if clusters[i].Name != clusters[j].Name {
+			return clusters[i].Name < clusters[j].Name
+		}
+		return clusters[i].Config < clusters[j].Config
+	})
+	return clusters, nil
+}
+
+// Health probes a registered cluster's API server now.
+func (m *Manager) Health(ctx context.Context, id string) (Health, error) {
+	registration, err := m.repo.Get(ctx, id)
+	if err != nil {
+		return Health{}, err
+	}
+	return m.probe(ctx, registration.ID, registration.Name), nil
+}
+
+// Resolve maps a cluster reference to the config and context used by the rest of the API. The
+// reference is a registration ID, a registered name or a context name that is unique across
+// the loaded kubeconfigs.
+func (m *Manager) Resolve(ctx context.Context, ref string) (string, string, error) {
+	registrations, err := m.repo.List(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	for _, registration := range registrations {
+		if registration.ID == ref || registration.Name == ref {
+			return registration.ID, registration.Name, nil
+		}
+	}
+	var matches []Context
+	for _, kc := range m.catalog.Contexts() {
+		if kc.Context == ref {
+			matches = append(matches, kc)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", "", ErrClusterNotFound{ID: ref}
+	case 1:
+		return matches[0].Config, matches[0].Context, nil
+	}
+	configs := make([]string, len(matches))
+	for i, match := range matches {
+		configs[i] = match.Config
+	}
+	return "", "", ErrInvalidRequest{Reason: fmt.Sprintf("cluster %q exists in several kubeconfigs (%s); pass config as well", ref, strings.Join(configs, ", "))}
+}
+
+// Run probes every known cluster on each tick until the context is cancelled.
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.probeAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Manager) probeAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, kc := range m.catalog.Contexts() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.probe(ctx, kc.Config, kc.Context)
+		}()
+	}
+	wg.Wait()
+}
+
+func (m *Manager) probe(ctx context.Context, config, context string) Health {
+	health := Health{Status: HealthUnreachable, CheckedAt: m.clock()}
+	client, ok := m.catalog.Client(config, context)
+	if !ok {
+		health.Error = "kubeconfig could not be loaded"
+		m.record(config, context, health)
+		return health
+	}
+
+	type result struct {
+		version string
+		err     error
+	}
+	done := make(chan result, 1)
+	started := time.Now()
+	go func() {
+		info, err := client.Discovery().ServerVersion()
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		done <- result{version: info.GitVersion}
+	}()
+	select {
+	case r := <-done:
+		health.LatencyMs = time.Since(started).Milliseconds()
+		if r.err != nil {
+			health.Error = r.err.Error()
+			break
+		}
+		health.Status = HealthHealthy
+		health.Version = r.version
+	case <-time.After(probeTimeout):
+		health.Error = "timed out waiting for the API server"
+		health.LatencyMs = probeTimeout.Milliseconds()
+	case <-ctx.Done():
+		health.Error = ctx.Err().Error()
+	}
+	m.record(config, context, health)
+	return health
+}
+
+func healthKey(config, context string) string {
+	return config + "/" + context
+}
+
+func (m *Manager) record(config, context string, health Health) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.health[healthKey(config, context)] = health
+}
+
+func (m *Manager) forget(config, context string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.health, healthKey(config, context))
+}
+
+func (m *Manager) lastHealth(config, context string) *Health {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	health, ok := m.health[healthKey(config, context)]
+	if !ok {
+		return nil
+	}
+	return &health
+}
+
+func (m *Manager) registered(registration Registration) Cluster {
+	created := registration.CreatedAt
+	cluster := Cluster{
+		ID:        registration.ID,
+		Name:      registration.Name,
+		Config:    registration.ID,
+		Context:   registration.Name,
+		Source:    SourceRegistered,
+		AuthType:  registration.AuthType,
+		Server:    registration.Server,
+		Labels:    registration.Labels,
+		Health:    m.lastHealth(registration.ID, registration.Name),
+		CreatedBy: registration.CreatedBy,
+		CreatedAt: &created,
+	}
+	for _, kc := range m.catalog.Contexts() {
+		if kc.Config == registration.ID && kc.Context == registration.Name {
+			cluster.Connected = kc.Connected
+		}
+	}
+	return cluster
+}
+
+func (m *Manager) checkNameFree(ctx context.Context, name string) error {
+	registrations, err := m.repo.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, registration := range registrations {
+		if registration.Name == name {
+			return ErrInvalidRequest{Reason: fmt.Sprintf("a cluster named %q is already registered", name)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package clusters
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+type fakeCatalog struct {
+	static map[string][]string
+	files  map[string][]byte
+}
+
+func (c *fakeCatalog) Contexts() []Context {
+	var contexts []Context
+	for config, names := range c.static {
+		for _, name := range names {
+			contexts = append(contexts, Context{Config: config, Context: name})
+		}
+	}
+	for id, data := range c.files {
+		cfg, _ := clientcmd.Load(data)
+		for name := range cfg.Contexts {
+			contexts = append(contexts, Context{Config: id, Context: name, Server: cfg.Clusters[name].Server})
+		}
+	}
+	sort.Slice(contexts, func(i, j int) bool { return contexts[i].Config < contexts[j].Config })
+	return contexts
+}
+
+func (c *fakeCatalog) Client(config, context string) (kubernetes.Interface, bool) {
+	return fake.NewClientset(), true
+}
+
+func (c *fakeCatalog) Put(id string, kubeconfig []byte) error {
+	c.files[id] = kubeconfig
+	return nil
+}
+
+func (c *fakeCatalog) Remove(id string) error {
+	delete(c.files, id)
+	return nil
+}
+
+func newTestManager() (*Manager, *fakeCatalog) {
+	catalog := &fakeCatalog{
+		static: map[string][]string{"config": {"staging", "prod"}, "other": {"prod"}},
+		files:  map[string][]byte{},
+	}
+	return NewManager(NewMemoryRepository(), catalog, log.NewWithOptions(io.Discard, log.Options{})), catalog
+}
+
+func TestRegisterTokenClusterWritesSingleContextKubeconfig(t *testing.T) {
+	m, catalog := newTestManager()
+	ctx := context.Background()
+
+	cluster, err := m.Register(ctx, "alice", Spec{Name: "edge-1", AuthType: AuthToken, Server: "https://10.0.0.1:6443", Token: "s3cret"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cluster.Config != cluster.ID || cluster.Context != "edge-1" || cluster.Source != SourceRegistered {
+		t.Fatalf("unexpected cluster %+v", cluster)
+	}
+	written, err := clientcmd.Load(catalog.files[cluster.ID])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if written.CurrentContext != "edge-1" || written.AuthInfos["edge-1"].Token != "s3cret" || written.Clusters["edge-1"].Server != "https://10.0.0.1:6443" {
+		t.Fatalf("unexpected kubeconfig %+v", written)
+	}
+
+	if _, err := m.Register(ctx, "bob", Spec{Name: "edge-1", AuthType: AuthToken, Server: "https://10.0.0.2", Token: "x"}); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected duplicate name to be rejected, got %v", err)
+	}
+
+	clusters, err := m.List(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(clusters) != 4 {
+		t.Fatalf("expected 3 kubeconfig contexts and 1 registered cluster, got %+v", clusters)
+	}
+
+	health, err := m.Health(ctx, cluster.ID)
+	if err != nil || health.Status != HealthHealthy {
+		t.Fatalf("unexpected health %+v, %v", health, err)
+	}
+	if got, _ := m.Get(ctx, cluster.ID); got.Health == nil || got.Health.Status != HealthHealthy {
+		t.Fatalf("expected the probe to be recorded, got %+v", got.Health)
+	}
+
+	if err := m.Delete(ctx, cluster.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := catalog.files[cluster.ID]; ok {
+		t.Fatalf("expected kubeconfig to be removed")
+	}
+}
+
+func TestRegisterValidatesSpec(t *testing.T) {
+	m, _ := newTestManager()
+	execConfig := `apiVersion: v1
+kind: Config
+clusters:
+- name: c
+  cluster: {server: "https://example"}
+users:
+- name: u
+  user:
+    exec: {command: "sh", apiVersion: "client.authentication.k8s.io/v1"}
+contexts:
+- name: ctx
+  context: {cluster: c, user: u}
+current-context: ctx
+`
+	cases := map[string]Spec{
+		"bad name":     {Name: "Edge_1", AuthType: AuthToken, Server: "https://x", Token: "t"},
+		"missing oidc": {Name: "edge", AuthType: AuthOIDC, Server: "https://x"},
+		"exec plugin":  {Name: "edge", AuthType: AuthKubeconfig, Kubeconfig: execConfig},
+		"no context":   {Name: "edge", AuthType: AuthKubeconfig, Kubeconfig: execConfig, Context: "missing"},
+		"unknown auth": {Name: "edge", AuthType: "password"},
+	}
+	for name, spec := range cases {
+		if _, err := m.Register(context.Background(), "alice", spec); !errors.As(err, &ErrInvalidRequest{}) {
+			t.Errorf("%s: expected invalid request, got %v", name, err)
+		}
+	}
+}
+
+func TestResolveClusterReference(t *testing.T) {
+	m, _ := newTestManager()
+	ctx := context.Background()
+	registered, err := m.Register(ctx, "alice", Spec{Name: "edge", AuthType: AuthOIDC, Server: "https://x",
+		OIDC: &OIDC{IssuerURL: "https://issuer", ClientID: "kubechat", IDToken: "jwt"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if config, cluster, err := m.Resolve(ctx, "edge"); err != nil || config != registered.ID || cluster != "edge" {
+		t.Fatalf("unexpected resolution %s/%s, %v", config, cluster, err)
+	}
+	if config, cluster, err := m.Resolve(ctx, "staging"); err != nil || config != "config" || cluster != "staging" {
+		t.Fatalf("unexpected resolution %s/%s, %v", config, cluster, err)
+	}
+	if _, _, err := m.Resolve(ctx, "prod"); !errors.As(err, &ErrInvalidRequest{}) || !strings.Contains(err.Error(), "several") {
+		t.Fatalf("expected ambiguous context to be rejected, got %v", err)
+	}
+	if _, _, err := m.Resolve(ctx, "missing"); !errors.As(err, &ErrClusterNotFound{}) {
+		t.Fatalf("expected unknown cluster, got %v", err)
+	}
+}
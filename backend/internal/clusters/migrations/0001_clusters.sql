@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS clusters (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    body JSONB NOT NULL,
+    kubeconfig BYTEA NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,109 @@
+package clusters
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pramodksahoo/kubechat/backend/internal/database"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// PostgresRepository stores registration metadata as JSON and the kubeconfig in its own column,
+// so every replica can restore the credentials on start.
+type PostgresRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresRepository applies the cluster schema migrations and returns a repository backed by db.
+func NewPostgresRepository(ctx context.Context, db *sqlx.DB) (*PostgresRepository, error) {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Migrate(ctx, db, "clusters", sub); err != nil {
+		return nil, err
+	}
+	return &PostgresRepository{db: db}, nil
+}
+
+type registrationRow struct {
+	ID         string    `db:"id"`
+	Name       string    `db:"name"`
+	Body       []byte    `db:"body"`
+	Kubeconfig []byte    `db:"kubeconfig"`
+	UpdatedAt  time.Time `db:"updated_at"`
+}
+
+func (row registrationRow) registration() (Registration, error) {
+	var registration Registration
+	if err := json.Unmarshal(row.Body, &registration); err != nil {
+		return Registration{}, err
+	}
+	registration.Kubeconfig = row.Kubeconfig
+	return registration, nil
+}
+
+func (r *PostgresRepository) Save(ctx context.Context, registration Registration) error {
+	body, err := json.Marshal(registration)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.NamedExecContext(ctx, `INSERT INTO clusters (id, name, body, kubeconfig, updated_at)
+		VALUES (:id, :name, :body, :kubeconfig, :updated_at)
+		ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, body = EXCLUDED.body,
+			kubeconfig = EXCLUDED.kubeconfig, updated_at = EXCLUDED.updated_at`, registrationRow{
+		ID:         registration.ID,
+		Name:       registration.Name,
+		Body:       body,
+		Kubeconfig: registration.Kubeconfig,
+		UpdatedAt:  registration.UpdatedAt,
+	})
+	return err
+}
+
+func (r *PostgresRepository) Get(ctx context.Context, id string) (Registration, error) {
+	var row registrationRow
+	err := r.db.GetContext(ctx, &row, `SELECT id, name, body, kubeconfig, updated_at FROM clusters WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Registration{}, ErrClusterNotFound{ID: id}
+	}
+	if err != nil {
+		return Registration{}, err
+	}
+	return row.registration()
+}
+
+func (r *PostgresRepository) List(ctx context.Context) ([]Registration, error) {
+	var rows []registrationRow
+	if err := r.db.SelectContext(ctx, &rows, `SELECT id, name, body, kubeconfig, updated_at FROM clusters ORDER BY name`); err != nil {
+		return nil, err
+	}
+	registrations := make([]Registration, 0, len(rows))
+	for _, row := range rows {
+		registration, err := row.registration()
+		if err != nil {
+			return nil, err
+		}
+		registrations = append(registrations, registration)
+	}
+	return registrations, nil
+}
+
+func (r *PostgresRepository) Delete(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM clusters WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrClusterNotFound{ID: id}
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+package clusters
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// Repository persists cluster registrations together with their kubeconfigs.
+type Repository interface {
+	Save(ctx context.Context, registration Registration) error
+	Get(ctx context.Context, id string) (Registration, error)
+	List(ctx context.Context) ([]Registration, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// MemoryRepository keeps registrations in process memory; it is used when no database is
+// configured. The kubeconfig files written by the manager still survive restarts.
+type MemoryRepository struct {
+	mu            sync.RWMutex
+	registrations map[string]Registration
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{registrations: make(map[string]Registration)}
+}
+
+func (r *MemoryRepository) Save(ctx context.Context, registration Registration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.registrations[registration.ID] = clone(registration)
+	return nil
+}
+
+func (r *MemoryRepository) Get(ctx context.Context, id string) (Registration, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	registration, ok := r.registrations[id]
+	if !ok {
+		return Registration{}, ErrClusterNotFound{ID: id}
+	}
+	return clone(registration), nil
+}
+
+func (r *MemoryRepository) List(ctx context.Context) ([]Registration, error) {
+	r.mu.RLock()
+	registrations := make([]Registration, 0, len(r.registrations))
+	for _, registration := range r.registrations {
+		registrations = append(registrations, clone(registration))
+	}
+	r.mu.RUnlock()
+	sort.Slice(registrations, func(i, j int) bool { return registrations[i].Name < registrations[j].Name })
+	return registrations, nil
+}
+
+func (r *MemoryRepository) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.registrations[id]; !ok {
+		return ErrClusterNotFound{ID: id}
+	}
+	delete(r.registrations, id)
+	return nil
+}
+
+func clone(registration Registration) Registration {
+	registration.Labels = maps.Clone(registration.Labels)
+	registration.Kubeconfig = slices.Clone(registration.Kubeconfig)
+	return registration
+}
@@ -0,0 +1,182 @@
+package commands
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ApprovalLinkAction is the decision an approval link records.
+type ApprovalLinkAction string
+
+const (
+	LinkApprove ApprovalLinkAction = "approve"
+	LinkReject  ApprovalLinkAction = "reject"
+)
+
+var (
+	ErrInvalidApprovalLink = errors.New("approval link is invalid or has expired")
+	ErrApprovalLinkUsed    = errors.New("approval link has already been used")
+	ErrNotLinkRecipient    = errors.New("approval link was sent to another approver")
+	ErrNoApprovalLinkKey   = errors.New("approval links need a signing key")
+)
+
+// ApprovalLinkPath is where approval links are served; the signed token follows it.
+const ApprovalLinkPath = "/api/v1/commands/approvals/links/"
+
+// DefaultApprovalLinkTTL bounds the links of approvals that do not expire.
+const DefaultApprovalLinkTTL = 24 * time.Hour
+
+// ApprovalLink is the decision a verified link lets its recipient record on an execution.
+type ApprovalLink struct {
+	ExecutionID string             `json:"executionId"`
+	Recipient   string             `json:"recipient"`
+	Action      ApprovalLinkAction `json:"action"`
+	ExpiresAt   time.Time          `json:"expiresAt"`
+}
+
+// ApprovalLinks signs the approve and reject links sent to approvers. Each approver gets their own
+// links, which only they can use, and they must still be eligible to approve the execution. The
+// service records the decisions made through links in its repository, by execution and
+// recipient, so each approver decides once, across replicas and restarts, while the others can
+// still reach the approvals the policy needs.
+type ApprovalLinks struct {
+	key     []byte
+	baseURL string
+}
+
+// NewApprovalLinks signs links with key, prefixing them with baseURL, the address approvers reach
+// the server at. It returns ErrNoApprovalLinkKey when key is empty.
+func NewApprovalLinks(key []byte, baseURL string) (*ApprovalLinks, error) {
+	if len(key) == 0 {
+		return nil, ErrNoApprovalLinkKey
+	}
+	return &ApprovalLinks{key: key, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+func (l *ApprovalLinks) signature(payload string) string {
+	mac := hmac.New(sha256.New, l.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// URL returns a link letting recipient record action on the execution until expires.
+func (l *ApprovalLinks) URL(executionID, recipient string, action ApprovalLinkAction, expires time.Time) string {
+	payload := strings.Join([]string{executionID, recipient, string(action), strconv.FormatInt(expires.Unix(), 10)}, "|")
+	return l.baseURL + ApprovalLinkPath + base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + l.signature(payload)
+}
+
+// Verify returns the decision token records, unless it is forged or expired.
+func (l *ApprovalLinks) Verify(token string, now time.Time) (ApprovalLink, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return ApprovalLink{}, ErrInvalidApprovalLink
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || !hmac.Equal([]byte(l.signature(string(raw))), []byte(signature)) {
+		return ApprovalLink{}, ErrInvalidApprovalLink
+	}
+	// The recipient comes second and may itself contain the separator.
+	fields := strings.Split(string(raw), "|")
+	if len(fields) < 4 {
+		return ApprovalLink{}, ErrInvalidApprovalLink
+	}
+	last := len(fields) - 1
+	unix, err := strconv.ParseInt(fields[last], 10, 64)
+	if err != nil || now.Unix() > unix {
+		return ApprovalLink{}, ErrInvalidApprovalLink
+	}
+	link := ApprovalLink{
+		ExecutionID: fields[0],
+		Recipient:   strings.Join(fields[1:last-1], "|"),
+		Action:      ApprovalLinkAction(fields[last-1]),
+		ExpiresAt:   time.Unix(unix, 0).UTC(),
+	}
+	if link.Recipient == "" || (link.Action != LinkApprove && link.Action != LinkReject) {
+		return ApprovalLink{}, ErrInvalidApprovalLink
+	}
+	return link, nil
+}
+
+// verifyLink returns the decision token records, unless it is forged, expired or already used.
+func (s *Service) verifyLink(ctx context.Context, token string) (ApprovalLink, error) {
+	link, err := s.links.Verify(token, s.now())
+	if err != nil {
+		return ApprovalLink{}, err
+	}
+	used, err := s.repo.ApprovalLinkRedeemed(ctx, link.ExecutionID, link.Recipient)
+	if err != nil {
+		return ApprovalLink{}, err
+	}
+	if used {
+		return ApprovalLink{}, ErrApprovalLinkUsed
+	}
+	return link, nil
+}
+
+// SetApprovalLinks adds approve and reject links to approval notifications and accepts them.
+func (s *Service) SetApprovalLinks(links *ApprovalLinks) {
+	s.links = links
+}
+
+// ApprovalByLink returns the decision token records with the execution and approval it is for.
+func (s *Service) ApprovalByLink(ctx context.Context, token string) (ApprovalLink, Execution, Approval, error) {
+	if s.links == nil {
+		return ApprovalLink{}, Execution{}, Approval{}, ErrInvalidApprovalLink
+	}
+	link, err := s.verifyLink(ctx, token)
+	if err != nil {
+		return ApprovalLink{}, Execution{}, Approval{}, err
+	}
+	execution, err := s.repo.GetByID(ctx, link.ExecutionID)
+	if err != nil {
+		return ApprovalLink{}, Execution{}, Approval{}, err
+	}
+	approval, err := s.repo.GetApproval(ctx, link.ExecutionID)
+	if err != nil {
+		return ApprovalLink{}, Execution{}, Approval{}, err
+	}
+	return link, execution, approval, nil
+}
+
+// DecideByLink records the decision token carries as user, a member of groups, as Approve or
+// Reject would. Only the link's recipient may use it, and neither of their links for the
+// execution can be used again once the decision is recorded.
+func (s *Service) DecideByLink(ctx context.Context, token, user string, groups []string, reason string) (ApprovalLink, Execution, error) {
+	if s.links == nil {
+		return ApprovalLink{}, Execution{}, ErrInvalidApprovalLink
+	}
+	link, err := s.verifyLink(ctx, token)
+	if err != nil {
+		return ApprovalLink{}, Execution{}, err
+	}
+	if user != link.Recipient {
+		return ApprovalLink{}, Execution{}, ErrNotLinkRecipient
+	}
+	// The link is redeemed while the decision is recorded, and released if that fails.
+	redeemed, err := s.repo.RedeemApprovalLink(ctx, link.ExecutionID, link.Recipient, link.ExpiresAt, s.now())
+	if err != nil {
+		return ApprovalLink{}, Execution{}, err
+	}
+	if !redeemed {
+		return ApprovalLink{}, Execution{}, ErrApprovalLinkUsed
+	}
+	var execution Execution
+	if link.Action == LinkApprove {
+		execution, err = s.Approve(ctx, link.ExecutionID, user, groups, reason)
+	} else {
+		execution, err = s.Reject(ctx, link.ExecutionID, user, groups, reason)
+	}
+	if err != nil {
+		if releaseErr := s.repo.ReleaseApprovalLink(ctx, link.ExecutionID, link.Recipient); releaseErr != nil {
+			s.log(ctx).Warn("failed to release approval link", "execution", link.ExecutionID, "error", releaseErr)
+		}
+		return ApprovalLink{}, Execution{}, err
+	}
+	return link, execution, nil
+}
@@ -0,0 +1,357 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pramodksahoo/kubechat/backend/internal/audit"
+)
+
+var (
+	ErrNotAwaitingApproval = errors.New("command execution is not awaiting approval")
+	ErrSelfApproval        = errors.New("command executions cannot be approved by the requester")
+	ErrNotEligibleApprover = errors.New("user is not in an approver group for this command execution")
+	ErrAlreadyDecided      = errors.New("user has already recorded a decision for this command execution")
+	ErrApprovalExpired     = errors.New("approval request has expired")
+	ErrApprovalConflict    = errors.New("approval was changed by another decision; reload it and try again")
+)
+
+// systemActor is recorded in the audit trail for expiry and escalation.
+const systemActor = "system"
+
+func (s *Service) requestApproval(ctx context.Context, execution Execution) error {
+	policy := s.policies.Match(execution)
+	explanation := s.explain(execution)
+	approval := Approval{
+		ID:                uuid.NewString(),
+		ExecutionID:       execution.ID,
+		RequestedBy:       execution.UserID,
+		PolicyName:        policy.Name,
+		RequiredApprovals: max(policy.RequiredApprovals, 1),
+		ApproverGroups:    slices.Clone(policy.ApproverGroups),
+		Status:            ApprovalPending,
+		CreatedAt:         execution.CreatedAt,
+		Explanation:       &explanation,
+	}
+	if policy.Expiry.Duration > 0 {
+		expires := execution.CreatedAt.Add(policy.Expiry.Duration)
+		approval.ExpiresAt = &expires
+	}
+	if policy.EscalateAfter.Duration > 0 && len(policy.EscalationGroups) > 0 {
+		escalate := execution.CreatedAt.Add(policy.EscalateAfter.Duration)
+		approval.EscalateAt = &escalate
+	}
+	if err := s.repo.SaveApproval(ctx, approval); err != nil {
+		return err
+	}
+	if err := s.record(ctx, execution.ID, execution.UserID, ApprovalActionRequested, "policy "+policy.Name); err != nil {
+		return err
+	}
+	if err := s.routeApproval(ctx, approval); err != nil {
+		return err
+	}
+	s.notifyApproval(ctx, execution, approval, approval.ApproverGroups, false)
+	return nil
+}
+
+// Approve records approver's decision and runs the execution once the policy quorum is reached.
+// Delegates approve on behalf of their delegator, whose approval then counts toward the quorum.
+// A decision recorded concurrently on the same approval makes it return ErrApprovalConflict
+// without running the execution.
+func (s *Service) Approve(ctx context.Context, id, approver string, groups []string, reason string) (Execution, error) {
+	execution, approval, err := s.pendingApproval(ctx, id)
+	if err != nil {
+		return Execution{}, err
+	}
+	onBehalfOf, err := s.checkApprover(ctx, execution, approval, approver, groups)
+	if err != nil {
+		return Execution{}, err
+	}
+
+	now := s.now()
+	reason = strings.TrimSpace(reason)
+	approval.Decisions = append(approval.Decisions, ApprovalDecision{User: approver, OnBehalfOf: onBehalfOf, Decision: ApprovalApproved, Reason: reason, At: now})
+	quorum := approval.Approvals() >= approval.RequiredApprovals
+	var (
+		window ChangeWindow
+		until  time.Time
+		frozen bool
+	)
+	if quorum {
+		// A rejecting change window refuses the decision like a quota does; a queueing one
+		// records it and holds the execution until the window ends.
+		if window, until, frozen, err = s.frozen(ctx, execution); err != nil {
+			return Execution{}, err
+		}
+		if frozen && window.Mode == FreezeReject {
+			return Execution{}, ErrChangeFrozen{Window: window, Until: until}
+		}
+	}
+	release := func() {}
+	if quorum && !frozen {
+		// The decision is not recorded while the requester is over their quota, so it can be
+		// made again once the quota allows the execution to run.
+		if release, err = s.startQuota(execution); err != nil {
+			return Execution{}, err
+		}
+	}
+	defer release()
+	if quorum {
+		approval.Status = ApprovalApproved
+		approval.DecidedBy = approver
+		approval.Reason = reason
+		approval.DecidedAt = &now
+	}
+	if err := s.repo.SaveApproval(ctx, approval); err != nil {
+		return Execution{}, err
+	}
+	if err := s.recordOnBehalf(ctx, id, approver, onBehalfOf, ApprovalActionApproved, reason); err != nil {
+		return Execution{}, err
+	}
+	if !quorum {
+		return execution, nil
+	}
+	if frozen {
+		execution.QueuedUntil = &until
+		return s.queue(ctx, execution, false)
+	}
+
+	execution.Status = StatusRunning
+	if err := s.repo.Update(ctx, execution); err != nil {
+		return Execution{}, err
+	}
+	return s.run(ctx, execution)
+}
+
+// Reject closes the approval; a single eligible rejection is enough to stop the execution.
+func (s *Service) Reject(ctx context.Context, id, approver string, groups []string, reason string) (Execution, error) {
+	execution, approval, err := s.pendingApproval(ctx, id)
+	if err != nil {
+		return Execution{}, err
+	}
+	onBehalfOf, err := s.checkApprover(ctx, execution, approval, approver, groups)
+	if err != nil && !errors.Is(err, ErrSelfApproval) {
+		return Execution{}, err
+	}
+
+	now := s.now()
+	reason = strings.TrimSpace(reason)
+	approval.Decisions = append(approval.Decisions, ApprovalDecision{User: approver, OnBehalfOf: onBehalfOf, Decision: ApprovalRejected, Reason: reason, At: now})
+	approval.Status = ApprovalRejected
+	approval.DecidedBy = approver
+	approval.Reason = reason
+	approval.DecidedAt = &now
+	if err := s.repo.SaveApproval(ctx, approval); err != nil {
+		return Execution{}, err
+	}
+	if err := s.recordOnBehalf(ctx, id, approver, onBehalfOf, ApprovalActionRejected, reason); err != nil {
+		return Execution{}, err
+	}
+
+	execution.Status = StatusRejected
+	execution.CompletedAt = &now
+	if err := s.repo.Update(ctx, execution); err != nil {
+		return Execution{}, err
+	}
+	return execution, nil
+}
+
+func (s *Service) Approval(ctx context.Context, id string) (Approval, []ApprovalEvent, error) {
+	approval, err := s.repo.GetApproval(ctx, id)
+	if err != nil {
+		return Approval{}, nil, err
+	}
+	events, err := s.repo.ListApprovalEvents(ctx, id)
+	if err != nil {
+		return Approval{}, nil, err
+	}
+	return approval, events, nil
+}
+
+func (s *Service) Approvals(ctx context.Context, status ApprovalStatus) ([]Approval, error) {
+	return s.repo.ListApprovals(ctx, status)
+}
+
+func (s *Service) PendingApprovals(ctx context.Context) ([]Approval, error) {
+	return s.repo.ListApprovals(ctx, ApprovalPending)
+}
+
+// ProcessApprovals expires overdue approvals and widens the approver groups of those due for escalation.
+func (s *Service) ProcessApprovals(ctx context.Context) error {
+	pending, err := s.repo.ListApprovals(ctx, ApprovalPending)
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	for _, approval := range pending {
+		switch {
+		case approval.ExpiresAt != nil && !now.Before(*approval.ExpiresAt):
+			execution, err := s.repo.GetByID(ctx, approval.ExecutionID)
+			if err != nil {
+				return err
+			}
+			// An approval decided since it was listed is left to that decision.
+			if err := s.expire(ctx, execution, approval); err != nil && !errors.Is(err, ErrApprovalConflict) {
+				return err
+			}
+		case approval.EscalateAt != nil && approval.EscalatedAt == nil && !now.Before(*approval.EscalateAt):
+			if err := s.escalate(ctx, approval); err != nil && !errors.Is(err, ErrApprovalConflict) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.ProcessApprovals(ctx); err != nil {
+				s.log(ctx).Error("failed to process pending approvals", "error", err)
+			}
+		}
+	}
+}
+
+func (s *Service) pendingApproval(ctx context.Context, id string) (Execution, Approval, error) {
+	execution, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return Execution{}, Approval{}, err
+	}
+	if execution.Status != StatusAwaitingApproval {
+		return Execution{}, Approval{}, ErrNotAwaitingApproval
+	}
+	approval, err := s.repo.GetApproval(ctx, id)
+	if err != nil {
+		return Execution{}, Approval{}, err
+	}
+	if approval.Status != ApprovalPending {
+		return Execution{}, Approval{}, ErrNotAwaitingApproval
+	}
+	if approval.ExpiresAt != nil && !s.now().Before(*approval.ExpiresAt) {
+		if err := s.expire(ctx, execution, approval); err != nil {
+			return Execution{}, Approval{}, err
+		}
+		return Execution{}, Approval{}, ErrApprovalExpired
+	}
+	return execution, approval, nil
+}
+
+// checkApprover reports whether approver may decide approval, returning the delegator they act
+// for when they are only eligible through a delegation. The approver must also hold a role that
+// lets them approve in every namespace the execution touches.
+func (s *Service) checkApprover(ctx context.Context, execution Execution, approval Approval, approver string, groups []string) (string, error) {
+	if approver == approval.RequestedBy {
+		return "", ErrSelfApproval
+	}
+	if approval.decidedBy(approver) {
+		return "", ErrAlreadyDecided
+	}
+	if s.roles != nil {
+		for _, namespace := range execution.namespaces() {
+			if err := s.roles.CheckApprove(ctx, approver, groups, namespace); err != nil {
+				return "", err
+			}
+		}
+	}
+	if len(approval.ApproverGroups) == 0 || s.policies.InAnyGroup(approver, approval.ApproverGroups) {
+		return "", nil
+	}
+	delegator, ok, err := s.delegatorFor(ctx, approval, approver)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", ErrNotEligibleApprover
+	}
+	return delegator, nil
+}
+
+func (s *Service) expire(ctx context.Context, execution Execution, approval Approval) error {
+	now := s.now()
+	approval.Status = ApprovalExpired
+	approval.DecidedAt = &now
+	if err := s.repo.SaveApproval(ctx, approval); err != nil {
+		return err
+	}
+	if err := s.record(ctx, approval.ExecutionID, systemActor, ApprovalActionExpired, ""); err != nil {
+		return err
+	}
+	execution.Status = StatusExpired
+	execution.CompletedAt = &now
+	s.log(ctx).Info("approval expired", "execution", execution.ID, "policy", approval.PolicyName)
+	return s.repo.Update(ctx, execution)
+}
+
+func (s *Service) escalate(ctx context.Context, approval Approval) error {
+	policy, ok := s.policies.Lookup(approval.PolicyName)
+	if !ok {
+		return nil
+	}
+	now := s.now()
+	var added []string
+	for _, group := range policy.EscalationGroups {
+		if !slices.Contains(approval.ApproverGroups, group) {
+			approval.ApproverGroups = append(approval.ApproverGroups, group)
+			added = append(added, group)
+		}
+	}
+	approval.EscalatedAt = &now
+	if err := s.repo.SaveApproval(ctx, approval); err != nil {
+		return err
+	}
+	s.log(ctx).Warn("approval escalated", "execution", approval.ExecutionID, "policy", approval.PolicyName, "groups", approval.ApproverGroups)
+	if err := s.record(ctx, approval.ExecutionID, systemActor, ApprovalActionEscalated, "escalated to "+strings.Join(policy.EscalationGroups, ", ")); err != nil {
+		return err
+	}
+	if len(added) > 0 && s.notifier != nil {
+		execution, err := s.repo.GetByID(ctx, approval.ExecutionID)
+		if err != nil {
+			return err
+		}
+		s.notifyApproval(ctx, execution, approval, added, true)
+	}
+	return nil
+}
+
+// Ledger chains audit entries so that changes to the stored trail can be detected.
+type Ledger interface {
+	Append(ctx context.Context, source audit.Source, entry any) error
+}
+
+// SetLedger copies every approval event into ledger after it is stored.
+func (s *Service) SetLedger(ledger Ledger) {
+	s.ledger = ledger
+}
+
+func (s *Service) record(ctx context.Context, executionID, actor string, action ApprovalAction, reason string) error {
+	return s.recordOnBehalf(ctx, executionID, actor, "", action, reason)
+}
+
+func (s *Service) recordOnBehalf(ctx context.Context, executionID, actor, onBehalfOf string, action ApprovalAction, reason string) error {
+	event := ApprovalEvent{
+		ID:          uuid.NewString(),
+		ExecutionID: executionID,
+		Actor:       actor,
+		OnBehalfOf:  onBehalfOf,
+		Action:      action,
+		Reason:      reason,
+		At:          s.now(),
+	}
+	if err := s.repo.AppendApprovalEvent(ctx, event); err != nil {
+		return err
+	}
+	if s.ledger == nil {
+		return nil
+	}
+	return s.ledger.Append(ctx, audit.SourceApprovals, event)
+}
@@ -0,0 +1,299 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testPolicies() *ApprovalPolicies {
+	return &ApprovalPolicies{
+		Groups: map[string][]string{
+			"sre":     {"bob", "carol", "dave"},
+			"leads":   {"erin"},
+			"finance": {"frank"},
+		},
+		Policies: []ApprovalPolicy{{
+			Name:              "prod-two-sre",
+			Clusters:          []string{"prod"},
+			RequiredApprovals: 2,
+			ApproverGroups:    []string{"sre"},
+			Expiry:            metav1.Duration{Duration: 2 * time.Hour},
+			EscalateAfter:     metav1.Duration{Duration: 30 * time.Minute},
+			EscalationGroups:  []string{"leads"},
+		}},
+	}
+}
+
+func TestApprovalPolicyRequiresQuorumFromGroup(t *testing.T) {
+	svc, client, _ := newTestService(deployment(3))
+	svc.SetApprovalPolicies(testPolicies())
+	ctx := context.Background()
+
+	execution, err := svc.Submit(ctx, scaleRequest("alice", "0"))
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+
+	if _, err := svc.Approve(ctx, execution.ID, "frank", nil, ""); !errors.Is(err, ErrNotEligibleApprover) {
+		t.Fatalf("expected non-sre approver to be refused, got %v", err)
+	}
+	first, err := svc.Approve(ctx, execution.ID, "bob", nil, "looks fine")
+	if err != nil {
+		t.Fatalf("first approval: %v", err)
+	}
+	if first.Status != StatusAwaitingApproval {
+		t.Fatalf("expected execution to wait for a second approver, got %s", first.Status)
+	}
+	if _, err := svc.Approve(ctx, execution.ID, "bob", nil, ""); !errors.Is(err, ErrAlreadyDecided) {
+		t.Fatalf("expected duplicate decision to be refused, got %v", err)
+	}
+
+	second, err := svc.Approve(ctx, execution.ID, "carol", nil, "")
+	if err != nil {
+		t.Fatalf("second approval: %v", err)
+	}
+	if second.Status != StatusSucceeded {
+		t.Fatalf("expected execution to run after quorum, got %s", second.Status)
+	}
+	got, _ := client.AppsV1().Deployments("shop").Get(ctx, "api", metav1.GetOptions{})
+	if *got.Spec.Replicas != 0 {
+		t.Fatalf("expected deployment scaled to 0, got %d", *got.Spec.Replicas)
+	}
+
+	approval, events, err := svc.Approval(ctx, execution.ID)
+	if err != nil {
+		t.Fatalf("approval: %v", err)
+	}
+	if approval.Status != ApprovalApproved || approval.PolicyName != "prod-two-sre" || approval.Approvals() != 2 {
+		t.Fatalf("unexpected approval %+v", approval)
+	}
+	var actions []ApprovalAction
+	for _, event := range events {
+		actions = append(actions, event.Action)
+	}
+	if len(actions) != 3 || actions[0] != ApprovalActionRequested || actions[2] != ApprovalActionApproved {
+		t.Fatalf("unexpected audit trail %v", actions)
+	}
+}
+
+func TestApproverNeedsARoleInTheExecutionsNamespace(t *testing.T) {
+	svc, client, _ := newTestService(deployment(3))
+	svc.SetApprovalPolicies(testPolicies())
+	svc.SetRoleChecker(namespaceRoles{"alice": {"shop"}, "bob": {"dev"}, "carol": {"shop"}, "dave": {"shop"}})
+	ctx := context.Background()
+
+	execution, err := svc.Submit(ctx, scaleRequest("alice", "0"))
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if _, err := svc.Approve(ctx, execution.ID, "bob", []string{"sre"}, ""); err == nil {
+		t.Fatal("expected an sre approver bound only in dev to be refused on shop")
+	}
+	if _, err := svc.Reject(ctx, execution.ID, "bob", []string{"sre"}, ""); err == nil {
+		t.Fatal("expected an sre approver bound only in dev to be unable to reject on shop")
+	}
+	if assigned, err := svc.AssignedApprovals(ctx, "bob", []string{"sre"}); err != nil || len(assigned) != 0 {
+		t.Fatalf("expected nothing assigned to bob, got %+v (%v)", assigned, err)
+	}
+
+	if _, err := svc.Approve(ctx, execution.ID, "carol", nil, ""); err != nil {
+		t.Fatalf("first approval: %v", err)
+	}
+	approved, err := svc.Approve(ctx, execution.ID, "dave", nil, "")
+	if err != nil || approved.Status != StatusSucceeded {
+		t.Fatalf("expected approvers bound in shop to run the execution, got %+v (%v)", approved, err)
+	}
+	if got, _ := client.AppsV1().Deployments("shop").Get(ctx, "api", metav1.GetOptions{}); *got.Spec.Replicas != 0 {
+		t.Fatalf("expected 0 replicas, got %d", *got.Spec.Replicas)
+	}
+}
+
+func TestApprovalEscalationAndExpiry(t *testing.T) {
+	svc, _, _ := newTestService(deployment(3))
+	svc.SetApprovalPolicies(testPolicies())
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	execution, err := svc.Submit(ctx, scaleRequest("alice", "0"))
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if _, err := svc.Approve(ctx, execution.ID, "erin", nil, ""); !errors.Is(err, ErrNotEligibleApprover) {
+		t.Fatalf("expected lead to be ineligible before escalation, got %v", err)
+	}
+
+	now = now.Add(45 * time.Minute)
+	if err := svc.ProcessApprovals(ctx); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	approval, _, _ := svc.Approval(ctx, execution.ID)
+	if approval.EscalatedAt == nil {
+		t.Fatalf("expected approval to be escalated")
+	}
+	if _, err := svc.Approve(ctx, execution.ID, "erin", nil, ""); err != nil {
+		t.Fatalf("expected lead to approve after escalation, got %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, err := svc.Approve(ctx, execution.ID, "bob", nil, ""); !errors.Is(err, ErrApprovalExpired) {
+		t.Fatalf("expected expired approval, got %v", err)
+	}
+	stored, _ := svc.Get(ctx, execution.ID)
+	if stored.Status != StatusExpired {
+		t.Fatalf("expected execution to expire, got %s", stored.Status)
+	}
+}
+
+func TestDefaultPolicyAppliesOutsideConfiguredClusters(t *testing.T) {
+	svc, _, _ := newTestService(deployment(3))
+	svc.SetApprovalPolicies(testPolicies())
+	ctx := context.Background()
+
+	req := scaleRequest("alice", "0")
+	req.Cluster = "staging"
+	execution, _ := svc.Submit(ctx, req)
+	approval, _, err := svc.Approval(ctx, execution.ID)
+	if err != nil {
+		t.Fatalf("approval: %v", err)
+	}
+	if approval.PolicyName != DefaultApprovalPolicy.Name || approval.RequiredApprovals != 1 {
+		t.Fatalf("expected default policy, got %+v", approval)
+	}
+}
+
+func TestLoadApprovalPoliciesRejectsUnknownGroups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.yaml")
+	os.WriteFile(path, []byte(`
+groups:
+  sre: [bob, carol]
+policies:
+  - name: prod
+    clusters: [prod]
+    requiredApprovals: 2
+    approverGroups: [sre]
+    expiry: 2h
+`), 0o600)
+	policies, err := LoadApprovalPolicies(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if policies.Policies[0].Expiry.Duration != 2*time.Hour {
+		t.Fatalf("unexpected expiry %s", policies.Policies[0].Expiry.Duration)
+	}
+
+	os.WriteFile(path, []byte(`
+policies:
+  - name: prod
+    requiredApprovals: 1
+    approverGroups: [dba]
+`), 0o600)
+	if _, err := LoadApprovalPolicies(path); err == nil {
+		t.Fatalf("expected unknown group to be rejected")
+	}
+}
+
+func TestSaveApprovalRefusesAStaleVersion(t *testing.T) {
+	repo := NewMemoryRepository()
+	ctx := context.Background()
+	if err := repo.SaveApproval(ctx, Approval{ID: "a1", ExecutionID: "e1", RequestedBy: "alice", RequiredApprovals: 1, Status: ApprovalPending}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	read, err := repo.GetApproval(ctx, "e1")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		saved     []string
+		conflicts int
+	)
+	for _, approver := range []string{"bob", "carol", "dave", "erin"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			decided := cloneApproval(read)
+			decided.Decisions = append(decided.Decisions, ApprovalDecision{User: approver, Decision: ApprovalApproved})
+			decided.Status = ApprovalApproved
+			decided.DecidedBy = approver
+			err := repo.SaveApproval(ctx, decided)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				saved = append(saved, approver)
+			case errors.Is(err, ErrApprovalConflict):
+				conflicts++
+			default:
+				t.Errorf("save by %s: %v", approver, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(saved) != 1 || conflicts != 3 {
+		t.Fatalf("expected one decision saved and three conflicts, got %v and %d", saved, conflicts)
+	}
+	stored, _ := repo.GetApproval(ctx, "e1")
+	if stored.DecidedBy != saved[0] || len(stored.Decisions) != 1 || stored.Version != read.Version+1 {
+		t.Fatalf("expected only %s's decision stored, got %+v", saved[0], stored)
+	}
+}
+
+func TestConcurrentApprovalsRunTheExecutionOnce(t *testing.T) {
+	svc, client, _ := newTestService(deployment(3))
+	policies := testPolicies()
+	policies.Policies[0].RequiredApprovals = 1
+	svc.SetApprovalPolicies(policies)
+	ctx := context.Background()
+
+	execution, err := svc.Submit(ctx, scaleRequest("alice", "0"))
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		ran int
+	)
+	for _, approver := range []string{"bob", "carol", "dave"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			approved, err := svc.Approve(ctx, execution.ID, approver, nil, "")
+			if err != nil && !errors.Is(err, ErrApprovalConflict) && !errors.Is(err, ErrNotAwaitingApproval) {
+				t.Errorf("approval by %s: %v", approver, err)
+			}
+			if err == nil && approved.Status == StatusSucceeded {
+				mu.Lock()
+				ran++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if ran != 1 {
+		t.Fatalf("expected exactly one approval to run the execution, got %d", ran)
+	}
+	writes := 0
+	for _, action := range client.Actions() {
+		if action.Matches("patch", "deployments") || action.Matches("update", "deployments") {
+			writes++
+		}
+	}
+	if writes != 1 {
+		t.Fatalf("expected the deployment to be scaled once, got %d writes", writes)
+	}
+	approval, _ := svc.repo.GetApproval(ctx, execution.ID)
+	if approval.Status != ApprovalApproved || len(approval.Decisions) != 1 {
+		t.Fatalf("expected a single recorded decision, got %+v", approval)
+	}
+}
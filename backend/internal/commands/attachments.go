@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/pramodksahoo/kubechat/backend/internal/attachments"
+)
+
+const (
+	// DefaultAttachmentThreshold is the result size above which output moves to the attachment store.
+	DefaultAttachmentThreshold = 64 << 10
+	// attachmentPreviewSize is how much of an externalized result stays inline.
+	attachmentPreviewSize = 4 << 10
+)
+
+// SetAttachmentStore moves results larger than threshold bytes into store.
+func (s *Service) SetAttachmentStore(store attachments.Store, threshold int) {
+	if threshold <= 0 {
+		threshold = DefaultAttachmentThreshold
+	}
+	s.attachments = store
+	s.attachmentThreshold = threshold
+}
+
+// storeResult sets the execution result, externalizing it when it exceeds the threshold.
+// If the store fails the full result is kept inline so no output is lost.
+func (s *Service) storeResult(ctx context.Context, execution *Execution, result string) {
+	if s.attachments == nil || len(result) <= s.attachmentThreshold {
+		execution.Result = result
+		return
+	}
+
+	sum := sha256.Sum256([]byte(result))
+	attachment := Attachment{
+		ID:          uuid.NewString(),
+		Name:        "result.txt",
+		ContentType: "text/plain; charset=utf-8",
+		Size:        int64(len(result)),
+		SHA256:      hex.EncodeToString(sum[:]),
+		CreatedAt:   s.now(),
+	}
+	attachment.Key = execution.ID + "/" + attachment.ID
+	attachment.URL = fmt.Sprintf("/api/v1/commands/executions/%s/attachments/%s", execution.ID, attachment.ID)
+
+	if err := s.attachments.Put(ctx, attachment.Key, attachment.ContentType, []byte(result)); err != nil {
+		s.log(ctx).Error("failed to store execution output as attachment", "id", execution.ID, "size", len(result), "error", err)
+		execution.Result = result
+		return
+	}
+	execution.Attachments = append(execution.Attachments, attachment)
+	execution.Result = fmt.Sprintf("%s\n... output truncated, %d bytes stored in attachment %s", preview(result, attachmentPreviewSize), len(result), attachment.ID)
+}
+
+// Attachment opens the content of an execution attachment.
+func (s *Service) Attachment(ctx context.Context, executionID, attachmentID string) (Attachment, io.ReadCloser, error) {
+	execution, err := s.repo.GetByID(ctx, executionID)
+	if err != nil {
+		return Attachment{}, nil, err
+	}
+	for _, attachment := range execution.Attachments {
+		if attachment.ID != attachmentID {
+			continue
+		}
+		if s.attachments == nil {
+			return Attachment{}, nil, ErrAttachmentNotFound{ExecutionID: executionID, AttachmentID: attachmentID}
+		}
+		reader, err := s.attachments.Open(ctx, attachment.Key)
+		if errors.Is(err, attachments.ErrNotFound) {
+			return Attachment{}, nil, ErrAttachmentNotFound{ExecutionID: executionID, AttachmentID: attachmentID}
+		}
+		return attachment, reader, err
+	}
+	return Attachment{}, nil, ErrAttachmentNotFound{ExecutionID: executionID, AttachmentID: attachmentID}
+}
+
+func (s *Service) deleteAttachments(ctx context.Context, execution Execution) {
+	if s.attachments == nil {
+		return
+	}
+	for _, attachment := range execution.Attachments {
+		if err := s.attachments.Delete(ctx, attachment.Key); err != nil {
+			s.log(ctx).Warn("failed to delete execution attachment", "id", execution.ID, "attachment", attachment.ID, "error", err)
+		}
+	}
+}
+
+// preview cuts text to at most size bytes without splitting a UTF-8 sequence.
+func preview(text string, size int) string {
+	if len(text) <= size {
+		return text
+	}
+	for size > 0 && !utf8.RuneStart(text[size]) {
+		size--
+	}
+	return text[:size]
+}
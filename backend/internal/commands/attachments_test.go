@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/attachments"
+)
+
+func TestLargeResultsAreStoredAsAttachments(t *testing.T) {
+	svc, _, repo := newTestService()
+	store, err := attachments.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	svc.SetAttachmentStore(store, 1024)
+	ctx := context.Background()
+
+	execution := Execution{ID: "exec-1", UserID: "alice", Status: StatusRunning}
+	if err := repo.Create(ctx, execution); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	output := strings.Repeat("line of pod logs\n", 1000)
+	svc.storeResult(ctx, &execution, output)
+	execution.Status = StatusSucceeded
+	if err := repo.Update(ctx, execution); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(execution.Attachments) != 1 {
+		t.Fatalf("expected one attachment, got %+v", execution.Attachments)
+	}
+	attachment := execution.Attachments[0]
+	if attachment.Size != int64(len(output)) || attachment.URL != "/api/v1/commands/executions/exec-1/attachments/"+attachment.ID {
+		t.Fatalf("unexpected attachment metadata: %+v", attachment)
+	}
+	if len(execution.Result) > attachmentPreviewSize+200 || !strings.Contains(execution.Result, "output truncated") {
+		t.Fatalf("expected truncated preview, got %d bytes", len(execution.Result))
+	}
+
+	_, reader, err := svc.Attachment(ctx, "exec-1", attachment.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(content) != output {
+		t.Fatal("attachment content does not match the original output")
+	}
+
+	if err := svc.Delete(ctx, "exec-1", "alice"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := store.Open(ctx, attachment.Key); err != nil {
+		t.Fatalf("expected the attachment to be kept until retention purges the execution, got %v", err)
+	}
+	if purged, err := svc.Purge(ctx, time.Now(), nil, nil); err != nil || purged != 1 {
+		t.Fatalf("expected the deleted execution to be purged, got %d (%v)", purged, err)
+	}
+	if _, err := store.Open(ctx, attachment.Key); !errors.Is(err, attachments.ErrNotFound) {
+		t.Fatalf("expected attachment to be deleted with the purged execution, got %v", err)
+	}
+}
+
+func TestSmallResultsStayInline(t *testing.T) {
+	svc, _, _ := newTestService()
+	store, _ := attachments.NewFileStore(t.TempDir())
+	svc.SetAttachmentStore(store, 1024)
+
+	execution := Execution{ID: "exec-2"}
+	svc.storeResult(context.Background(), &execution, "scaled")
+	if execution.Result != "scaled" || len(execution.Attachments) != 0 {
+		t.Fatalf("expected inline result, got %+v", execution)
+	}
+	if _, _, err := svc.Attachment(context.Background(), "exec-2", "missing"); err == nil {
+		t.Fatal("expected error for unknown execution")
+	}
+}
+
+func TestPreviewKeepsUTF8Boundaries(t *testing.T) {
+	if got := preview("héllo", 2); got != "h" {
+		t.Fatalf("expected split before multi-byte rune, got %q", got)
+	}
+}
@@ -0,0 +1,179 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ClusterScoped is the namespace reviewers give to query resources that are not namespaced.
+const ClusterScoped = "_"
+
+// Target names the object an execution acted on in the form the audit trail is indexed by: the
+// kind is lower case and singular without its API group, so "Deployment", "deployments.apps" and
+// "deploy" are the same target.
+type Target struct {
+	Cluster   string `json:"cluster"`
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+}
+
+// AuditFilter selects the executions that acted on one target. An empty Cluster matches every
+// cluster.
+type AuditFilter struct {
+	Target Target
+	Limit  int
+	Offset int
+}
+
+// ResourceHistory lists every execution, including refused generations, that targeted the
+// filter's object, most recent first.
+func (s *Service) ResourceHistory(ctx context.Context, filter AuditFilter) ([]Execution, error) {
+	if filter.Target.Kind == "" || filter.Target.Name == "" {
+		return nil, ErrInvalidRequest{Reason: "resource kind and name are required"}
+	}
+	filter.Target.Kind = NormalizeKind(filter.Target.Kind)
+	if filter.Target.Namespace == ClusterScoped {
+		filter.Target.Namespace = ""
+	}
+	return s.repo.ListByTarget(ctx, filter)
+}
+
+// CorrelatedExecution is an execution of a correlated chain with the rollback recorded for it.
+type CorrelatedExecution struct {
+	Execution
+	Rollback *CorrelatedRollback `json:"rollback,omitempty"`
+}
+
+// CorrelatedRollback summarizes a rollback plan without the object snapshots it restores from,
+// which can hold secret data.
+type CorrelatedRollback struct {
+	Resources  []Resource     `json:"resources"`
+	Executed   bool           `json:"executed"`
+	ExecutedAt *time.Time     `json:"executedAt,omitempty"`
+	Changes    []ObjectChange `json:"changes,omitempty"`
+}
+
+// Correlated returns the executions sharing correlationID, oldest first, each with its rollback.
+func (s *Service) Correlated(ctx context.Context, correlationID string) ([]CorrelatedExecution, error) {
+	executions, err := s.repo.ListByCorrelation(ctx, correlationID)
+	if err != nil {
+		return nil, err
+	}
+	correlated := make([]CorrelatedExecution, 0, len(executions))
+	for _, execution := range executions {
+		entry := CorrelatedExecution{Execution: execution}
+		plan, err := s.repo.GetRollbackPlan(ctx, execution.ID)
+		var notFound ErrRollbackNotFound
+		switch {
+		case errors.As(err, &notFound):
+		case err != nil:
+			return nil, err
+		default:
+			entry.Rollback = &CorrelatedRollback{Executed: plan.Executed, ExecutedAt: plan.ExecutedAt, Changes: plan.Changes}
+			for _, step := range plan.Steps {
+				entry.Rollback.Resources = append(entry.Rollback.Resources, step.Resource)
+			}
+		}
+		correlated = append(correlated, entry)
+	}
+	return correlated, nil
+}
+
+// TargetOf returns the target an execution is indexed under. Generations refused by the NLP
+// guardrail are indexed by the object their command named, which can differ from the plan's.
+func TargetOf(execution Execution) Target {
+	target := Target{
+		Cluster:   execution.Cluster,
+		Namespace: execution.Resource.Namespace,
+		Kind:      NormalizeKind(execution.Resource.Kind),
+		Name:      execution.Resource.Name,
+	}
+	if parsed, ok := parseCommandTarget(execution.Parameters["command"]); ok {
+		target.Kind, target.Name = parsed.Kind, parsed.Name
+		if parsed.Namespace != "" {
+			target.Namespace = parsed.Namespace
+		}
+		if parsed.Cluster != "" {
+			target.Cluster = parsed.Cluster
+		}
+	}
+	return target
+}
+
+// kindShortNames maps kubectl's short names to the kinds they stand for.
+var kindShortNames = map[string]string{
+	"po": "pod", "deploy": "deployment", "sts": "statefulset", "ds": "daemonset", "rs": "replicaset",
+	"cj": "cronjob", "svc": "service", "ep": "endpoint", "ing": "ingress", "cm": "configmap", "ev": "event",
+	"no": "node", "ns": "namespace", "hpa": "horizontalpodautoscaler", "pdb": "poddisruptionbudget",
+	"pvc": "persistentvolumeclaim", "pv": "persistentvolume", "sa": "serviceaccount", "netpol": "networkpolicy",
+}
+
+// NormalizeKind lowers kind, drops its API group and turns a short name or plural resource into
+// the singular kind. The migration backfilling existing executions applies the same plural rules.
+func NormalizeKind(kind string) string {
+	kind, _, _ = strings.Cut(strings.ToLower(strings.TrimSpace(kind)), ".")
+	if full, ok := kindShortNames[kind]; ok {
+		return full
+	}
+	switch {
+	case strings.HasSuffix(kind, "ies"):
+		return strings.TrimSuffix(kind, "ies") + "y"
+	case strings.HasSuffix(kind, "sses"), strings.HasSuffix(kind, "xes"), strings.HasSuffix(kind, "ches"), strings.HasSuffix(kind, "shes"):
+		return strings.TrimSuffix(kind, "es")
+	case strings.HasSuffix(kind, "s") && !strings.HasSuffix(kind, "ss"):
+		return strings.TrimSuffix(kind, "s")
+	}
+	return kind
+}
+
+// parseCommandTarget reads the object a kubectl command names, as in "kubectl scale deploy/api
+// -n shop" or "kubectl describe deployment api --context prod".
+func parseCommandTarget(command string) (Target, bool) {
+	fields := strings.Fields(command)
+	if len(fields) < 3 || fields[0] != "kubectl" {
+		return Target{}, false
+	}
+	var target Target
+	var args []string
+	for i := 1; i < len(fields); i++ {
+		field := fields[i]
+		if !strings.HasPrefix(field, "-") {
+			args = append(args, field)
+			continue
+		}
+		flag, value, inline := strings.Cut(field, "=")
+		if !inline && i+1 < len(fields) {
+			switch flag {
+			case "-n", "--namespace", "--context", "-l", "--selector", "-o", "--output", "-f", "--filename", "-c", "--container", "--replicas":
+				i++
+				value = fields[i]
+			}
+		}
+		switch flag {
+		case "-n", "--namespace":
+			target.Namespace = value
+		case "--context":
+			target.Cluster = value
+		}
+	}
+	// Subcommands such as "rollout restart" and "set image" come before the resource.
+	if len(args) > 1 && (args[0] == "rollout" || args[0] == "set") {
+		args = args[1:]
+	}
+	if len(args) < 2 {
+		return Target{}, false
+	}
+	if kind, name, found := strings.Cut(args[1], "/"); found {
+		target.Kind, target.Name = kind, name
+	} else if len(args) > 2 {
+		target.Kind, target.Name = args[1], args[2]
+	}
+	if target.Kind == "" || target.Name == "" || strings.HasPrefix(target.Name, "<") {
+		return Target{}, false
+	}
+	target.Kind = NormalizeKind(target.Kind)
+	return target, true
+}
@@ -0,0 +1,121 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNormalizeKind(t *testing.T) {
+	for kind, want := range map[string]string{
+		"Deployment":       "deployment",
+		"deployments.apps": "deployment",
+		"deploy":           "deployment",
+		"ingresses":        "ingress",
+		"networkpolicies":  "networkpolicy",
+		"services":         "service",
+		"widgets.example":  "widget",
+		"ingress":          "ingress",
+	} {
+		if got := NormalizeKind(kind); got != want {
+			t.Fatalf("NormalizeKind(%q) = %q, want %q", kind, got, want)
+		}
+	}
+}
+
+func TestResourceHistoryFindsEveryActionAgainstAnObject(t *testing.T) {
+	svc, _, repo := newTestService(deployment(2))
+	ctx := context.Background()
+
+	scaled, err := svc.Submit(ctx, scaleRequest("alice", "4"))
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	base := scaled.CreatedAt.Add(-time.Hour)
+	for i, execution := range []Execution{
+		{ID: "read", UserID: "bob", Cluster: "prod", Operation: OperationGet, Resource: Resource{Kind: "deployments.apps", Namespace: "shop", Name: "api"}, CreatedAt: base.Add(time.Minute)},
+		{ID: "staging", UserID: "bob", Cluster: "staging", Operation: OperationGet, Resource: Resource{Kind: "deployments.apps", Namespace: "shop", Name: "api"}, CreatedAt: base.Add(2 * time.Minute)},
+		{ID: "other", UserID: "bob", Cluster: "prod", Operation: OperationGet, Resource: Resource{Kind: "deployments.apps", Namespace: "shop", Name: "web"}, CreatedAt: base.Add(3 * time.Minute)},
+		{ID: "node", UserID: "bob", Cluster: "prod", Operation: OperationDescribe, Resource: Resource{Kind: "nodes", Name: "node-1"}, CreatedAt: base.Add(4 * time.Minute)},
+	} {
+		if err := repo.Create(ctx, execution); err != nil {
+			t.Fatalf("create %d: %v", i, err)
+		}
+	}
+	svc.now = func() time.Time { return scaled.CreatedAt.Add(time.Second) }
+	blocked, err := svc.RecordBlocked(ctx, BlockedGeneration{
+		UserID:   "mallory",
+		Cluster:  "prod",
+		Resource: Resource{Kind: "deployments", Namespace: "shop", Name: "web"},
+		Command:  "kubectl exec -it deploy/api --namespace=shop -- sh",
+		Reasons:  []string{`kubectl verb "exec" is not allowed`},
+	})
+	if err != nil {
+		t.Fatalf("record blocked: %v", err)
+	}
+
+	history, err := svc.ResourceHistory(ctx, AuditFilter{Target: Target{Cluster: "prod", Namespace: "shop", Kind: "deploy", Name: "api"}})
+	if err != nil {
+		t.Fatalf("resource history: %v", err)
+	}
+	var ids []string
+	for _, execution := range history {
+		ids = append(ids, execution.ID)
+	}
+	if len(ids) != 3 || ids[0] != blocked.ID || ids[1] != scaled.ID || ids[2] != "read" {
+		t.Fatalf("expected the blocked exec, the scale and the read, got %v", ids)
+	}
+
+	all, _ := svc.ResourceHistory(ctx, AuditFilter{Target: Target{Namespace: "shop", Kind: "Deployment", Name: "api"}})
+	if len(all) != 4 {
+		t.Fatalf("expected every cluster without a cluster filter, got %d", len(all))
+	}
+	nodes, _ := svc.ResourceHistory(ctx, AuditFilter{Target: Target{Namespace: ClusterScoped, Kind: "node", Name: "node-1"}})
+	if len(nodes) != 1 || nodes[0].ID != "node" {
+		t.Fatalf("expected the cluster-scoped node, got %+v", nodes)
+	}
+	var invalid ErrInvalidRequest
+	if _, err := svc.ResourceHistory(ctx, AuditFilter{Target: Target{Kind: "deployment"}}); !errors.As(err, &invalid) {
+		t.Fatalf("expected a name to be required, got %v", err)
+	}
+}
+
+func TestCorrelatedLinksExecutionsChangesAndRollbacks(t *testing.T) {
+	api := deployment(2)
+	api.ResourceVersion = "7"
+	svc, _, _ := newTestService(api)
+	ctx := context.Background()
+
+	req := scaleRequest("alice", "4")
+	req.CorrelationID = "plan-1"
+	scaled, err := svc.Submit(ctx, req)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if scaled.CorrelationID != "plan-1" || len(scaled.Changes) != 1 || scaled.Changes[0].ResourceVersionBefore != "7" {
+		t.Fatalf("expected the scale to be correlated with its change recorded, got %+v", scaled)
+	}
+	direct, err := svc.Submit(ctx, scaleRequest("alice", "3"))
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if direct.CorrelationID != direct.ID {
+		t.Fatalf("expected a command without a plan to be correlated by its own ID, got %q", direct.CorrelationID)
+	}
+	if _, err := svc.Rollback(ctx, scaled.ID, "alice", nil); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+
+	correlated, err := svc.Correlated(ctx, "plan-1")
+	if err != nil {
+		t.Fatalf("correlated: %v", err)
+	}
+	if len(correlated) != 1 || correlated[0].ID != scaled.ID || correlated[0].Status != StatusRolledBack {
+		t.Fatalf("expected only the rolled back scale, got %+v", correlated)
+	}
+	rollback := correlated[0].Rollback
+	if rollback == nil || !rollback.Executed || len(rollback.Changes) != 1 || rollback.Changes[0].ResourceVersionBefore == "" {
+		t.Fatalf("expected the executed rollback with its change, got %+v", rollback)
+	}
+}
@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// BlockedGeneration is a prompt or generated command the NLP guardrail refused.
+type BlockedGeneration struct {
+	UserID  string
+	Cluster string
+	// Resource is what the command targeted; it is empty when the prompt itself was refused.
+	Resource Resource
+	Prompt   string
+	// Command is the generated command as it was before sanitizing.
+	Command string
+	Reasons []string
+}
+
+// RecordBlocked adds a refused generation to the execution audit trail. It is recorded as a
+// rejected execution with the blocked safety level and is never run.
+func (s *Service) RecordBlocked(ctx context.Context, blocked BlockedGeneration) (Execution, error) {
+	parameters := map[string]string{"prompt": blocked.Prompt}
+	if blocked.Command != "" {
+		parameters["command"] = blocked.Command
+	}
+	now := s.now()
+	execution := Execution{
+		ID:          uuid.NewString(),
+		UserID:      blocked.UserID,
+		Cluster:     blocked.Cluster,
+		Operation:   OperationGenerate,
+		Resource:    blocked.Resource,
+		Parameters:  parameters,
+		SafetyLevel: SafetyBlocked,
+		Status:      StatusRejected,
+		Error:       strings.Join(blocked.Reasons, "; "),
+		CreatedAt:   now,
+		CompletedAt: &now,
+	}
+	execution.CorrelationID = execution.ID
+	if err := s.repo.Create(ctx, execution); err != nil {
+		return Execution{}, err
+	}
+	return execution, nil
+}
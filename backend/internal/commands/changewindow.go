@@ -0,0 +1,439 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// maxFreezeOccurrences bounds the occurrences a calendar lists for one window.
+const maxFreezeOccurrences = 500
+
+type FreezeMode string
+
+const (
+	// FreezeReject refuses changes made during the window.
+	FreezeReject FreezeMode = "reject"
+	// FreezeQueue holds changes made during the window and runs them once it ends.
+	FreezeQueue FreezeMode = "queue"
+)
+
+// ChangeWindow freezes changes to a cluster or namespace, either once between StartsAt and EndsAt
+// or for Duration each time Cron fires: "0 17 * * 5" for 63h freezes Friday 5pm to Monday 8am.
+// Reads still run. Users holding the approver role may override a window by giving a reason.
+type ChangeWindow struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Cluster and Namespace limit the window to one cluster or namespace; empty matches every one.
+	Cluster   string     `json:"cluster,omitempty"`
+	Namespace string     `json:"namespace,omitempty"`
+	StartsAt  *time.Time `json:"startsAt,omitempty"`
+	EndsAt    *time.Time `json:"endsAt,omitempty"`
+	// Cron is a five-field expression or macro evaluated in Timezone, which defaults to UTC.
+	Cron      string          `json:"cron,omitempty"`
+	Duration  metav1.Duration `json:"duration,omitempty"`
+	Timezone  string          `json:"timezone,omitempty"`
+	Mode      FreezeMode      `json:"mode"`
+	Reason    string          `json:"reason,omitempty"`
+	CreatedBy string          `json:"createdBy"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// FreezeOccurrence is one period in which a change window freezes changes.
+type FreezeOccurrence struct {
+	WindowID  string     `json:"windowId"`
+	Name      string     `json:"name"`
+	Cluster   string     `json:"cluster,omitempty"`
+	Namespace string     `json:"namespace,omitempty"`
+	Mode      FreezeMode `json:"mode"`
+	Start     time.Time  `json:"start"`
+	End       time.Time  `json:"end"`
+}
+
+// FreezeOverride records who let an execution run during a change window, and why.
+type FreezeOverride struct {
+	WindowID string    `json:"windowId"`
+	Window   string    `json:"window"`
+	By       string    `json:"by"`
+	Reason   string    `json:"reason"`
+	At       time.Time `json:"at"`
+}
+
+// ErrChangeFrozen is returned for changes refused by a change window.
+type ErrChangeFrozen struct {
+	Window ChangeWindow
+	Until  time.Time
+}
+
+func (e ErrChangeFrozen) Error() string {
+	message := fmt.Sprintf("changes are frozen by change window %q until %s", e.Window.Name, e.Until.Format(time.RFC3339))
+	if e.Window.Reason != "" {
+		message += ": " + e.Window.Reason
+	}
+	return message
+}
+
+type ErrChangeWindowNotFound struct {
+	ID string
+}
+
+func (e ErrChangeWindowNotFound) Error() string {
+	return fmt.Sprintf("change window %s not found", e.ID)
+}
+
+// ErrNotQueued is returned when overriding the change window of an execution that is not queued.
+var ErrNotQueued = errors.New("command execution is not queued by a change window")
+
+func (w ChangeWindow) covers(cluster, namespace string) bool {
+	return (w.Cluster == "" || w.Cluster == cluster) && (w.Namespace == "" || w.Namespace == namespace)
+}
+
+// normalize validates w and fills in its defaults.
+func (w ChangeWindow) normalize(now time.Time) (ChangeWindow, error) {
+	w.Name = strings.TrimSpace(w.Name)
+	w.Cron = strings.TrimSpace(w.Cron)
+	w.Timezone = strings.TrimSpace(w.Timezone)
+	if w.Mode == "" {
+		w.Mode = FreezeReject
+	}
+	switch {
+	case w.Name == "":
+		return ChangeWindow{}, ErrInvalidRequest{Reason: "name is required"}
+	case w.Mode != FreezeReject && w.Mode != FreezeQueue:
+		return ChangeWindow{}, ErrInvalidRequest{Reason: "mode must be reject or queue"}
+	case (w.Cron == "") == (w.StartsAt == nil && w.EndsAt == nil):
+		return ChangeWindow{}, ErrInvalidRequest{Reason: "exactly one of cron and startsAt/endsAt is required"}
+	}
+	if w.Cron == "" {
+		switch {
+		case w.StartsAt == nil || w.EndsAt == nil:
+			return ChangeWindow{}, ErrInvalidRequest{Reason: "startsAt and endsAt are both required"}
+		case !w.EndsAt.After(*w.StartsAt):
+			return ChangeWindow{}, ErrInvalidRequest{Reason: "endsAt must be after startsAt"}
+		case !w.EndsAt.After(now):
+			return ChangeWindow{}, ErrInvalidRequest{Reason: "endsAt must be in the future"}
+		case w.Timezone != "" || w.Duration.Duration != 0:
+			return ChangeWindow{}, ErrInvalidRequest{Reason: "timezone and duration apply only to cron windows"}
+		}
+		starts, ends := w.StartsAt.UTC(), w.EndsAt.UTC()
+		w.StartsAt, w.EndsAt = &starts, &ends
+		return w, nil
+	}
+	if w.Duration.Duration <= 0 {
+		return ChangeWindow{}, ErrInvalidRequest{Reason: "duration must be positive"}
+	}
+	if w.Timezone == "" {
+		w.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(w.Timezone); err != nil {
+		return ChangeWindow{}, ErrInvalidRequest{Reason: err.Error()}
+	}
+	cron, err := parseCron(w.Cron)
+	if err != nil {
+		return ChangeWindow{}, ErrInvalidRequest{Reason: err.Error()}
+	}
+	if cron.next(now).IsZero() {
+		return ChangeWindow{}, ErrInvalidRequest{Reason: fmt.Sprintf("cron expression %q never fires", w.Cron)}
+	}
+	return w, nil
+}
+
+// activeAt returns when the freeze in effect at t ends, if there is one. Overlapping occurrences
+// of a cron window are merged.
+func (w ChangeWindow) activeAt(t time.Time) (time.Time, bool) {
+	if w.Cron == "" {
+		if w.StartsAt != nil && w.EndsAt != nil && !t.Before(*w.StartsAt) && t.Before(*w.EndsAt) {
+			return *w.EndsAt, true
+		}
+		return time.Time{}, false
+	}
+	occurrences := w.occurrences(t, t.Add(time.Nanosecond))
+	if len(occurrences) == 0 {
+		return time.Time{}, false
+	}
+	end := occurrences[0].End
+	for _, next := range w.occurrences(end, end.Add(w.Duration.Duration)) {
+		if next.Start.After(end) {
+			break
+		}
+		end = next.End
+	}
+	return end, true
+}
+
+// occurrences lists the freezes of w that overlap [from, to), oldest first.
+func (w ChangeWindow) occurrences(from, to time.Time) []FreezeOccurrence {
+	occurrence := func(start, end time.Time) FreezeOccurrence {
+		return FreezeOccurrence{WindowID: w.ID, Name: w.Name, Cluster: w.Cluster, Namespace: w.Namespace, Mode: w.Mode, Start: start, End: end}
+	}
+	if w.Cron == "" {
+		if w.StartsAt == nil || w.EndsAt == nil || !w.StartsAt.Before(to) || !w.EndsAt.After(from) {
+			return nil
+		}
+		return []FreezeOccurrence{occurrence(*w.StartsAt, *w.EndsAt)}
+	}
+	cron, err := parseCron(w.Cron)
+	if err != nil {
+		return nil
+	}
+	location, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return nil
+	}
+	var occurrences []FreezeOccurrence
+	for start := cron.next(from.Add(-w.Duration.Duration).In(location)); !start.IsZero() && start.Before(to); start = cron.next(start) {
+		occurrences = append(occurrences, occurrence(start.UTC(), start.Add(w.Duration.Duration).UTC()))
+		if len(occurrences) == maxFreezeOccurrences {
+			break
+		}
+	}
+	return occurrences
+}
+
+// DefineChangeWindow adds a change window.
+func (s *Service) DefineChangeWindow(ctx context.Context, window ChangeWindow) (ChangeWindow, error) {
+	now := s.now()
+	window, err := window.normalize(now)
+	if err != nil {
+		return ChangeWindow{}, err
+	}
+	window.ID = uuid.NewString()
+	window.CreatedAt = now
+	if err := s.repo.SaveChangeWindow(ctx, window); err != nil {
+		return ChangeWindow{}, err
+	}
+	s.log(ctx).Info("change window defined", "id", window.ID, "name", window.Name, "cluster", window.Cluster, "namespace", window.Namespace, "mode", window.Mode, "user", window.CreatedBy)
+	return window, nil
+}
+
+// ChangeWindows lists the change windows, most recently defined first.
+func (s *Service) ChangeWindows(ctx context.Context) ([]ChangeWindow, error) {
+	return s.repo.ListChangeWindows(ctx)
+}
+
+// DeleteChangeWindow removes a change window. Executions it queued run at the next release.
+func (s *Service) DeleteChangeWindow(ctx context.Context, id, user string) error {
+	if err := s.repo.DeleteChangeWindow(ctx, id); err != nil {
+		return err
+	}
+	s.log(ctx).Info("change window deleted", "id", id, "user", user)
+	return nil
+}
+
+// FreezeCalendar lists the freezes overlapping [from, to), ordered by start.
+func (s *Service) FreezeCalendar(ctx context.Context, from, to time.Time) ([]FreezeOccurrence, error) {
+	if !to.After(from) {
+		return nil, ErrInvalidRequest{Reason: "to must be after from"}
+	}
+	windows, err := s.repo.ListChangeWindows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	calendar := make([]FreezeOccurrence, 0)
+	for _, window := range windows {
+		calendar = append(calendar, window.occurrences(from, to)...)
+	}
+	slices.SortStableFunc(calendar, func(a, b FreezeOccurrence) int { return a.Start.Compare(b.Start) })
+	return calendar, nil
+}
+
+// frozen returns the change window holding back execution and when it ends. Reads and
+// executions whose window was overridden are never frozen. A rejecting window wins over a
+// queueing one; otherwise the window ending last is returned.
+func (s *Service) frozen(ctx context.Context, execution Execution) (ChangeWindow, time.Time, bool, error) {
+	if readOperation(execution.Operation) || execution.FreezeOverride != nil {
+		return ChangeWindow{}, time.Time{}, false, nil
+	}
+	windows, err := s.repo.ListChangeWindows(ctx)
+	if err != nil {
+		return ChangeWindow{}, time.Time{}, false, err
+	}
+	now := s.now()
+	var (
+		match ChangeWindow
+		until time.Time
+		found bool
+	)
+	for _, window := range windows {
+		if !slices.ContainsFunc(execution.namespaces(), func(namespace string) bool { return window.covers(execution.Cluster, namespace) }) {
+			continue
+		}
+		end, active := window.activeAt(now)
+		if !active {
+			continue
+		}
+		if !found || (window.Mode == FreezeReject && match.Mode != FreezeReject) || (window.Mode == match.Mode && end.After(until)) {
+			match, until, found = window, end, true
+		}
+	}
+	return match, until, found, nil
+}
+
+// checkChangeWindow decides what happens to a new execution during a change window. With an
+// override reason from a user holding the approver role it runs, recording the override; a
+// queueing window holds it and a rejecting one refuses it.
+func (s *Service) checkChangeWindow(ctx context.Context, execution *Execution, req Request) (bool, error) {
+	window, until, frozen, err := s.frozen(ctx, *execution)
+	if err != nil || !frozen {
+		return false, err
+	}
+	if reason := strings.TrimSpace(req.OverrideReason); reason != "" {
+		if s.roles != nil {
+			for _, namespace := range execution.namespaces() {
+				if err := s.roles.CheckApprove(ctx, req.UserID, req.Groups, namespace); err != nil {
+					return false, err
+				}
+			}
+		}
+		execution.FreezeOverride = &FreezeOverride{WindowID: window.ID, Window: window.Name, By: req.UserID, Reason: reason, At: s.now()}
+		s.log(ctx).Warn("change window overridden", "event", "security", "window", window.Name, "user", req.UserID, "cluster", execution.Cluster, "resource", execution.Resource.String(), "reason", reason)
+		return false, nil
+	}
+	if window.Mode == FreezeQueue {
+		execution.QueuedUntil = &until
+		return true, nil
+	}
+	err = ErrChangeFrozen{Window: window, Until: until}
+	s.log(ctx).Warn("change refused by change window", "event", "security", "window", window.Name, "user", execution.UserID, "cluster", execution.Cluster, "resource", execution.Resource.String())
+	refused := *execution
+	now := s.now()
+	refused.ID = uuid.NewString()
+	refused.Status = StatusRejected
+	refused.Error = err.Error()
+	refused.CompletedAt = &now
+	if createErr := s.repo.Create(ctx, refused); createErr != nil {
+		s.log(ctx).Error("failed to record refused command", "user", execution.UserID, "error", createErr)
+	}
+	return false, err
+}
+
+// queue holds an execution until its change window ends.
+func (s *Service) queue(ctx context.Context, execution Execution, create bool) (Execution, error) {
+	execution.Status = StatusQueued
+	save := s.repo.Update
+	if create {
+		save = s.repo.Create
+	}
+	if err := save(ctx, execution); err != nil {
+		return Execution{}, err
+	}
+	s.log(ctx).Info("command queued by change window", "id", execution.ID, "user", execution.UserID, "resource", execution.Resource.String(), "until", execution.QueuedUntil)
+	return execution, nil
+}
+
+// recordFreezeOverride adds a change window override made at submission to the approval trail.
+func (s *Service) recordFreezeOverride(ctx context.Context, execution Execution) error {
+	if execution.FreezeOverride == nil {
+		return nil
+	}
+	return s.record(ctx, execution.ID, execution.FreezeOverride.By, ApprovalActionFreezeOverridden, execution.FreezeOverride.Reason)
+}
+
+// OverrideFreeze runs a queued execution before its change window ends. Like approvals, the
+// requester cannot override their own execution and approver must hold the approver role.
+func (s *Service) OverrideFreeze(ctx context.Context, id, approver string, groups []string, reason string) (Execution, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return Execution{}, ErrInvalidRequest{Reason: "a reason is required to override a change window"}
+	}
+	execution, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return Execution{}, err
+	}
+	if execution.Status != StatusQueued {
+		return Execution{}, ErrNotQueued
+	}
+	if approver == execution.UserID {
+		return Execution{}, ErrSelfApproval
+	}
+	if s.roles != nil {
+		for _, namespace := range execution.namespaces() {
+			if err := s.roles.CheckApprove(ctx, approver, groups, namespace); err != nil {
+				return Execution{}, err
+			}
+		}
+	}
+	window, _, _, err := s.frozen(ctx, execution)
+	if err != nil {
+		return Execution{}, err
+	}
+	release, err := s.startQuota(execution)
+	if err != nil {
+		return Execution{}, err
+	}
+	defer release()
+	execution.FreezeOverride = &FreezeOverride{WindowID: window.ID, Window: window.Name, By: approver, Reason: reason, At: s.now()}
+	if err := s.record(ctx, id, approver, ApprovalActionFreezeOverridden, reason); err != nil {
+		return Execution{}, err
+	}
+	s.log(ctx).Warn("change window overridden", "event", "security", "window", window.Name, "user", approver, "execution", id, "reason", reason)
+	execution.Status = StatusRunning
+	if err := s.repo.Update(ctx, execution); err != nil {
+		return Execution{}, err
+	}
+	return s.run(ctx, execution)
+}
+
+// ReleaseQueued runs the queued executions whose change windows have ended.
+func (s *Service) ReleaseQueued(ctx context.Context) error {
+	queued, err := s.repo.ListByStatus(ctx, StatusQueued)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, execution := range queued {
+		_, until, frozen, err := s.frozen(ctx, execution)
+		if err != nil {
+			return err
+		}
+		if frozen {
+			if execution.QueuedUntil == nil || !execution.QueuedUntil.Equal(until) {
+				execution.QueuedUntil = &until
+				if err := s.repo.Update(ctx, execution); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			continue
+		}
+		// Executions over their requester's quota stay queued until it allows them to run.
+		release, err := s.startQuota(execution)
+		if err != nil {
+			continue
+		}
+		execution.Status = StatusRunning
+		if err := s.repo.Update(ctx, execution); err != nil {
+			release()
+			errs = append(errs, err)
+			continue
+		}
+		s.log(ctx).Info("queued command released", "id", execution.ID, "user", execution.UserID, "resource", execution.Resource.String())
+		if _, err := s.run(ctx, execution); err != nil {
+			errs = append(errs, fmt.Errorf("execution %s: %w", execution.ID, err))
+		}
+		release()
+	}
+	return errors.Join(errs...)
+}
+
+// RunQueued releases queued executions every interval until ctx is cancelled. It must run on one
+// replica at a time.
+func (s *Service) RunQueued(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.ReleaseQueued(ctx); err != nil {
+				s.log(ctx).Error("failed to release queued commands", "error", err)
+			}
+		}
+	}
+}
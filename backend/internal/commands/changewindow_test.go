@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCronChangeWindowOccurrences(t *testing.T) {
+	// Friday 5pm to Monday 8am.
+	window := ChangeWindow{ID: "weekend", Name: "weekend", Cron: "0 17 * * 5", Duration: metav1.Duration{Duration: 63 * time.Hour}, Timezone: "UTC", Mode: FreezeReject}
+	saturday := time.Date(2026, 3, 7, 12, 0, 0, 0, time.UTC)
+	end, active := window.activeAt(saturday)
+	if !active || !end.Equal(time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected the freeze to last until Monday 8am, got %s (%v)", end, active)
+	}
+	if _, active := window.activeAt(time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC)); active {
+		t.Fatal("expected the freeze to have ended on Monday 8am")
+	}
+
+	occurrences := window.occurrences(saturday, saturday.AddDate(0, 0, 14))
+	if len(occurrences) != 3 || !occurrences[0].Start.Equal(time.Date(2026, 3, 6, 17, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected the current and two following weekends, got %+v", occurrences)
+	}
+
+	if _, err := (ChangeWindow{Name: "bad", Cron: "0 17 * * 5"}).normalize(saturday); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected a cron window without a duration to be rejected, got %v", err)
+	}
+}
+
+func TestChangeWindowRejectsOrQueuesChanges(t *testing.T) {
+	svc, client, _ := newTestService(deployment(2))
+	ctx := context.Background()
+	now := time.Date(2026, 3, 6, 19, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	ends := now.Add(2 * time.Hour)
+	start := now.Add(-time.Hour)
+	window, err := svc.DefineChangeWindow(ctx, ChangeWindow{Name: "release", Namespace: "shop", StartsAt: &start, EndsAt: &ends, CreatedBy: "admin"})
+	if err != nil {
+		t.Fatalf("define: %v", err)
+	}
+	var frozen ErrChangeFrozen
+	if _, err := svc.Submit(ctx, scaleRequest("alice", "3")); !errors.As(err, &frozen) || !frozen.Until.Equal(ends) {
+		t.Fatalf("expected the change to be refused until the window ends, got %v", err)
+	}
+	read := Execution{Cluster: "prod", Operation: OperationGet, Resource: Resource{Kind: "Deployment", Namespace: "shop", Name: "api"}}
+	if _, _, isFrozen, err := svc.frozen(ctx, read); err != nil || isFrozen {
+		t.Fatalf("expected reads not to be frozen, got %v (%v)", isFrozen, err)
+	}
+
+	overridden := scaleRequest("alice", "3")
+	overridden.OverrideReason = "hotfix for incident 42"
+	execution, err := svc.Submit(ctx, overridden)
+	if err != nil || execution.Status != StatusSucceeded || execution.FreezeOverride == nil || execution.FreezeOverride.WindowID != window.ID {
+		t.Fatalf("expected the override to run and be recorded, got %+v (%v)", execution, err)
+	}
+
+	if err := svc.DeleteChangeWindow(ctx, window.ID, "admin"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := svc.DefineChangeWindow(ctx, ChangeWindow{Name: "queue", StartsAt: &start, EndsAt: &ends, Mode: FreezeQueue}); err != nil {
+		t.Fatalf("define: %v", err)
+	}
+	queued, err := svc.Submit(ctx, scaleRequest("alice", "5"))
+	if err != nil || queued.Status != StatusQueued || !queued.QueuedUntil.Equal(ends) {
+		t.Fatalf("expected the change to be queued, got %+v (%v)", queued, err)
+	}
+	if _, err := svc.OverrideFreeze(ctx, queued.ID, "bob", nil, " "); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected an override without a reason to be rejected, got %v", err)
+	}
+	if _, err := svc.OverrideFreeze(ctx, queued.ID, "alice", nil, "mine"); !errors.Is(err, ErrSelfApproval) {
+		t.Fatalf("expected requesters not to override their own execution, got %v", err)
+	}
+
+	if err := svc.ReleaseQueued(ctx); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if held, _ := svc.Get(ctx, queued.ID); held.Status != StatusQueued {
+		t.Fatalf("expected the execution held while the window is open, got %s", held.Status)
+	}
+	now = ends
+	if err := svc.ReleaseQueued(ctx); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if released, _ := svc.Get(ctx, queued.ID); released.Status != StatusSucceeded {
+		t.Fatalf("expected the execution to run once the window ended, got %+v", released)
+	}
+	scaled, _ := client.AppsV1().Deployments("shop").Get(ctx, "api", metav1.GetOptions{})
+	if *scaled.Spec.Replicas != 5 {
+		t.Fatalf("expected the deployment scaled to 5, got %d", *scaled.Spec.Replicas)
+	}
+}
@@ -0,0 +1,221 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxDelegationWindow bounds how long approval authority can be delegated at once.
+const MaxDelegationWindow = 90 * 24 * time.Hour
+
+// ApprovalDelegation lets Delegate decide approvals on behalf of Delegator between StartsAt and
+// EndsAt, such as while Delegator is on vacation. Only the delegator's own approver group
+// membership is delegated: authority a user holds as a delegate cannot be passed on.
+type ApprovalDelegation struct {
+	ID        string     `json:"id"`
+	Delegator string     `json:"delegator"`
+	Delegate  string     `json:"delegate"`
+	StartsAt  time.Time  `json:"startsAt"`
+	EndsAt    time.Time  `json:"endsAt"`
+	Reason    string     `json:"reason,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+func (d ApprovalDelegation) activeAt(at time.Time) bool {
+	return d.RevokedAt == nil && !at.Before(d.StartsAt) && at.Before(d.EndsAt)
+}
+
+func (d ApprovalDelegation) overlaps(start, end time.Time) bool {
+	return d.RevokedAt == nil && d.StartsAt.Before(end) && start.Before(d.EndsAt)
+}
+
+// DelegationRequest asks to delegate the caller's approval authority. StartsAt defaults to now.
+type DelegationRequest struct {
+	Delegate string     `json:"delegate"`
+	StartsAt *time.Time `json:"startsAt,omitempty"`
+	EndsAt   time.Time  `json:"endsAt"`
+	Reason   string     `json:"reason,omitempty"`
+}
+
+type ErrDelegationNotFound struct {
+	ID string
+}
+
+func (e ErrDelegationNotFound) Error() string {
+	return fmt.Sprintf("approval delegation %s not found", e.ID)
+}
+
+// AssignedApproval is a pending approval a user may decide, directly or on behalf of a delegator.
+type AssignedApproval struct {
+	Approval
+	OnBehalfOf string `json:"onBehalfOf,omitempty"`
+}
+
+// Delegate hands delegator's approval authority to req.Delegate for a time window. Pending
+// approvals the delegator could decide are routed to the delegate once the window starts.
+func (s *Service) Delegate(ctx context.Context, delegator string, req DelegationRequest) (ApprovalDelegation, error) {
+	now := s.now()
+	delegation := ApprovalDelegation{
+		ID:        uuid.NewString(),
+		Delegator: delegator,
+		Delegate:  strings.TrimSpace(req.Delegate),
+		StartsAt:  now,
+		EndsAt:    req.EndsAt.UTC(),
+		Reason:    strings.TrimSpace(req.Reason),
+		CreatedAt: now,
+	}
+	if req.StartsAt != nil {
+		delegation.StartsAt = req.StartsAt.UTC()
+	}
+	switch {
+	case delegation.Delegate == "":
+		return ApprovalDelegation{}, ErrInvalidRequest{Reason: "delegate is required"}
+	case delegation.Delegate == delegator:
+		return ApprovalDelegation{}, ErrInvalidRequest{Reason: "approval authority cannot be delegated to yourself"}
+	case !delegation.EndsAt.After(delegation.StartsAt) || !delegation.EndsAt.After(now):
+		return ApprovalDelegation{}, ErrInvalidRequest{Reason: "endsAt must be in the future and after startsAt"}
+	case delegation.EndsAt.Sub(delegation.StartsAt) > MaxDelegationWindow:
+		return ApprovalDelegation{}, ErrInvalidRequest{Reason: fmt.Sprintf("delegations cannot last longer than %s", MaxDelegationWindow)}
+	case s.policies == nil || !s.policies.InAnyGroup(delegator, s.policies.groupNames()):
+		return ApprovalDelegation{}, ErrInvalidRequest{Reason: "only members of an approver group can delegate approval authority"}
+	}
+
+	existing, err := s.repo.ListDelegations(ctx)
+	if err != nil {
+		return ApprovalDelegation{}, err
+	}
+	for _, other := range existing {
+		if !other.overlaps(delegation.StartsAt, delegation.EndsAt) {
+			continue
+		}
+		if other.Delegator == delegator {
+			return ApprovalDelegation{}, ErrInvalidRequest{Reason: fmt.Sprintf("you already delegate to %s until %s", other.Delegate, other.EndsAt.Format(time.RFC3339))}
+		}
+		if other.Delegator == delegation.Delegate {
+			return ApprovalDelegation{}, ErrInvalidRequest{Reason: fmt.Sprintf("%s has delegated their own approvals during this window", delegation.Delegate)}
+		}
+	}
+	if err := s.repo.SaveDelegation(ctx, delegation); err != nil {
+		return ApprovalDelegation{}, err
+	}
+	s.log(ctx).Info("approval authority delegated", "delegator", delegator, "delegate", delegation.Delegate, "until", delegation.EndsAt)
+
+	if delegation.activeAt(now) {
+		pending, err := s.repo.ListApprovals(ctx, ApprovalPending)
+		if err != nil {
+			return ApprovalDelegation{}, err
+		}
+		for _, approval := range pending {
+			if err := s.route(ctx, approval, delegation); err != nil {
+				return ApprovalDelegation{}, err
+			}
+		}
+	}
+	return delegation, nil
+}
+
+// RevokeDelegation ends a delegation early; only its delegator may revoke it.
+func (s *Service) RevokeDelegation(ctx context.Context, id, user string) (ApprovalDelegation, error) {
+	delegation, err := s.repo.GetDelegation(ctx, id)
+	if err != nil {
+		return ApprovalDelegation{}, err
+	}
+	if delegation.Delegator != user {
+		return ApprovalDelegation{}, ErrDelegationNotFound{ID: id}
+	}
+	if delegation.RevokedAt == nil {
+		now := s.now()
+		delegation.RevokedAt = &now
+		if err := s.repo.SaveDelegation(ctx, delegation); err != nil {
+			return ApprovalDelegation{}, err
+		}
+		s.log(ctx).Info("approval delegation revoked", "delegator", user, "delegate", delegation.Delegate)
+	}
+	return delegation, nil
+}
+
+// Delegations lists the delegations user made or received.
+func (s *Service) Delegations(ctx context.Context, user string) ([]ApprovalDelegation, error) {
+	delegations, err := s.repo.ListDelegations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(delegations, func(d ApprovalDelegation) bool {
+		return d.Delegator != user && d.Delegate != user
+	}), nil
+}
+
+// AssignedApprovals lists the pending approvals user, a member of groups, may decide now.
+func (s *Service) AssignedApprovals(ctx context.Context, user string, groups []string) ([]AssignedApproval, error) {
+	pending, err := s.repo.ListApprovals(ctx, ApprovalPending)
+	if err != nil {
+		return nil, err
+	}
+	var assigned []AssignedApproval
+	for _, approval := range pending {
+		execution, err := s.repo.GetByID(ctx, approval.ExecutionID)
+		if err != nil {
+			return nil, err
+		}
+		onBehalfOf, err := s.checkApprover(ctx, execution, approval, user, groups)
+		if err == nil {
+			assigned = append(assigned, AssignedApproval{Approval: approval, OnBehalfOf: onBehalfOf})
+		}
+	}
+	return assigned, nil
+}
+
+// routeApproval records that a new approval is routed to the delegates of eligible approvers.
+func (s *Service) routeApproval(ctx context.Context, approval Approval) error {
+	if len(approval.ApproverGroups) == 0 {
+		return nil
+	}
+	delegations, err := s.repo.ListDelegations(ctx)
+	if err != nil {
+		return err
+	}
+	for _, delegation := range delegations {
+		if delegation.activeAt(s.now()) {
+			if err := s.route(ctx, approval, delegation); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Service) route(ctx context.Context, approval Approval, delegation ApprovalDelegation) error {
+	if len(approval.ApproverGroups) == 0 || delegation.Delegator == approval.RequestedBy ||
+		!s.policies.InAnyGroup(delegation.Delegator, approval.ApproverGroups) {
+		return nil
+	}
+	reason := fmt.Sprintf("routed to %s until %s", delegation.Delegate, delegation.EndsAt.Format(time.RFC3339))
+	return s.recordOnBehalf(ctx, approval.ExecutionID, systemActor, delegation.Delegator, ApprovalActionRouted, reason)
+}
+
+// delegatorFor returns the delegator on whose behalf approver may decide approval.
+func (s *Service) delegatorFor(ctx context.Context, approval Approval, approver string) (string, bool, error) {
+	delegations, err := s.repo.ListDelegations(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	now := s.now()
+	for _, delegation := range delegations {
+		if delegation.Delegate != approver || !delegation.activeAt(now) || delegation.Delegator == approval.RequestedBy {
+			continue
+		}
+		if approval.decidedBy(delegation.Delegator) {
+			continue
+		}
+		if s.policies.InAnyGroup(delegation.Delegator, approval.ApproverGroups) {
+			return delegation.Delegator, true, nil
+		}
+	}
+	return "", false, nil
+}
@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDelegateApprovesOnBehalfOfApprover(t *testing.T) {
+	svc, _, _ := newTestService(deployment(3))
+	svc.SetApprovalPolicies(testPolicies())
+	ctx := context.Background()
+	week := DelegationRequest{Delegate: "frank", EndsAt: svc.now().Add(7 * 24 * time.Hour), Reason: "vacation"}
+
+	delegation, err := svc.Delegate(ctx, "bob", week)
+	if err != nil {
+		t.Fatalf("delegate: %v", err)
+	}
+	execution, err := svc.Submit(ctx, scaleRequest("alice", "0"))
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	_, events, _ := svc.Approval(ctx, execution.ID)
+	if last := events[len(events)-1]; last.Action != ApprovalActionRouted || last.OnBehalfOf != "bob" {
+		t.Fatalf("expected the approval to be routed to bob's delegate, got %+v", last)
+	}
+	assigned, err := svc.AssignedApprovals(ctx, "frank", nil)
+	if err != nil || len(assigned) != 1 || assigned[0].OnBehalfOf != "bob" {
+		t.Fatalf("expected frank to be assigned the approval for bob, got %+v (%v)", assigned, err)
+	}
+
+	if _, err := svc.Approve(ctx, execution.ID, "frank", nil, "covering for bob"); err != nil {
+		t.Fatalf("delegate approval: %v", err)
+	}
+	if _, err := svc.Approve(ctx, execution.ID, "bob", nil, ""); !errors.Is(err, ErrAlreadyDecided) {
+		t.Fatalf("expected bob's approval to count once, got %v", err)
+	}
+	approved, err := svc.Approve(ctx, execution.ID, "carol", nil, "")
+	if err != nil {
+		t.Fatalf("second approval: %v", err)
+	}
+	if approved.Status != StatusSucceeded {
+		t.Fatalf("expected the delegated approval to count toward the quorum, got %s", approved.Status)
+	}
+	approval, events, _ := svc.Approval(ctx, execution.ID)
+	if decision := approval.Decisions[0]; decision.User != "frank" || decision.OnBehalfOf != "bob" {
+		t.Fatalf("expected the decision to record who approved on whose behalf, got %+v", decision)
+	}
+	found := false
+	for _, event := range events {
+		found = found || (event.Action == ApprovalActionApproved && event.Actor == "frank" && event.OnBehalfOf == "bob")
+	}
+	if !found {
+		t.Fatalf("expected the audit trail to record frank approving for bob, got %+v", events)
+	}
+
+	if _, err := svc.RevokeDelegation(ctx, delegation.ID, "frank"); !errors.As(err, &ErrDelegationNotFound{}) {
+		t.Fatalf("expected only the delegator to revoke, got %v", err)
+	}
+	if _, err := svc.RevokeDelegation(ctx, delegation.ID, "bob"); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	next, err := svc.Submit(ctx, scaleRequest("alice", "0"))
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if _, err := svc.Approve(ctx, next.ID, "frank", nil, ""); !errors.Is(err, ErrNotEligibleApprover) {
+		t.Fatalf("expected a revoked delegation to grant nothing, got %v", err)
+	}
+}
+
+func TestDelegationLimits(t *testing.T) {
+	svc, _, _ := newTestService()
+	svc.SetApprovalPolicies(testPolicies())
+	ctx := context.Background()
+	until := svc.now().Add(48 * time.Hour)
+
+	if _, err := svc.Delegate(ctx, "bob", DelegationRequest{Delegate: "grace", EndsAt: until}); err != nil {
+		t.Fatalf("delegate: %v", err)
+	}
+	for name, tc := range map[string]struct {
+		delegator string
+		req       DelegationRequest
+	}{
+		"to self":                  {"dave", DelegationRequest{Delegate: "dave", EndsAt: until}},
+		"in the past":              {"dave", DelegationRequest{Delegate: "erin", EndsAt: svc.now().Add(-time.Hour)}},
+		"too long":                 {"dave", DelegationRequest{Delegate: "erin", EndsAt: svc.now().Add(MaxDelegationWindow + time.Hour)}},
+		"without authority":        {"heidi", DelegationRequest{Delegate: "alice", EndsAt: until}},
+		"received authority":       {"grace", DelegationRequest{Delegate: "alice", EndsAt: until}},
+		"to an absent approver":    {"dave", DelegationRequest{Delegate: "bob", EndsAt: until}},
+		"twice in the same window": {"bob", DelegationRequest{Delegate: "dave", EndsAt: until}},
+	} {
+		if _, err := svc.Delegate(ctx, tc.delegator, tc.req); !errors.As(err, &ErrInvalidRequest{}) {
+			t.Errorf("%s: expected the delegation to be refused, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package commands
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+var ErrNoReadSnapshot = errors.New("no earlier read with the same signature")
+
+// ObjectFingerprint is what a read remembers about each object it returned.
+type ObjectFingerprint struct {
+	Namespace       string `json:"namespace,omitempty"`
+	Name            string `json:"name"`
+	UID             string `json:"uid"`
+	ResourceVersion string `json:"resourceVersion"`
+	// Restarts sums the restart counts of a pod's containers.
+	Restarts int64 `json:"restarts,omitempty"`
+}
+
+func (f ObjectFingerprint) key() string {
+	if f.Namespace == "" {
+		return f.Name
+	}
+	return f.Namespace + "/" + f.Name
+}
+
+// ReadSnapshot is the latest result of a read with a given signature: the same requester,
+// cluster, operation, resource and selector.
+type ReadSnapshot struct {
+	Signature   string              `json:"signature"`
+	ExecutionID string              `json:"executionId"`
+	Objects     []ObjectFingerprint `json:"objects"`
+	TakenAt     time.Time           `json:"takenAt"`
+}
+
+// ResultDiff compares a read with the previous run of the same read. Objects are named
+// namespace/name, or name when they are not namespaced.
+type ResultDiff struct {
+	PreviousExecutionID string    `json:"previousExecutionId"`
+	Since               time.Time `json:"since"`
+	Added               []string  `json:"added,omitempty"`
+	Removed             []string  `json:"removed,omitempty"`
+	Restarted           []string  `json:"restarted,omitempty"`
+	// Changed lists objects whose resource version moved for any other reason.
+	Changed []string `json:"changed,omitempty"`
+	// Summary reads like "Since you last checked 3h ago, 3 pods were added, 1 restarted."
+	Summary string `json:"summary"`
+}
+
+// readSignature identifies repeated runs of the same read by the same requester.
+func readSignature(execution Execution) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		execution.UserID, execution.Config, execution.Cluster, string(execution.Operation),
+		execution.Resource.Kind, execution.Resource.Namespace, execution.Resource.Name, execution.Parameters["selector"],
+	}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+func fingerprints(objects []unstructured.Unstructured) []ObjectFingerprint {
+	out := make([]ObjectFingerprint, 0, len(objects))
+	for _, obj := range objects {
+		fingerprint := ObjectFingerprint{
+			Namespace:       obj.GetNamespace(),
+			Name:            obj.GetName(),
+			UID:             string(obj.GetUID()),
+			ResourceVersion: obj.GetResourceVersion(),
+		}
+		statuses, _, _ := unstructured.NestedSlice(obj.Object, "status", "containerStatuses")
+		for _, status := range statuses {
+			if status, ok := status.(map[string]any); ok {
+				restarts, _, _ := unstructured.NestedInt64(status, "restartCount")
+				fingerprint.Restarts += restarts
+			}
+		}
+		out = append(out, fingerprint)
+	}
+	return out
+}
+
+// diffRead compares the objects a read returned with the previous run of the same read and
+// remembers them for the next one. Failing to load or store fingerprints never fails the read.
+func (s *Service) diffRead(ctx context.Context, execution Execution, objects []unstructured.Unstructured) *ResultDiff {
+	current := ReadSnapshot{
+		Signature:   readSignature(execution),
+		ExecutionID: execution.ID,
+		Objects:     fingerprints(objects),
+		TakenAt:     s.now(),
+	}
+	previous, err := s.repo.GetReadSnapshot(ctx, current.Signature)
+	if err != nil && !errors.Is(err, ErrNoReadSnapshot) {
+		s.log(ctx).Warn("failed to load previous read", "id", execution.ID, "error", err)
+	}
+	if err := s.repo.SaveReadSnapshot(ctx, current); err != nil {
+		s.log(ctx).Warn("failed to remember read", "id", execution.ID, "error", err)
+	}
+	if previous.ExecutionID == "" {
+		return nil
+	}
+	diff := compareSnapshots(previous, current)
+	diff.Summary = s.summarizeDiff(diff, execution.Resource.Kind)
+	return &diff
+}
+
+func compareSnapshots(previous, current ReadSnapshot) ResultDiff {
+	diff := ResultDiff{PreviousExecutionID: previous.ExecutionID, Since: previous.TakenAt}
+	before := make(map[string]ObjectFingerprint, len(previous.Objects))
+	for _, object := range previous.Objects {
+		before[object.key()] = object
+	}
+	for _, object := range current.Objects {
+		key := object.key()
+		old, ok := before[key]
+		delete(before, key)
+		switch {
+		case !ok || old.UID != object.UID:
+			diff.Added = append(diff.Added, key)
+		case object.Restarts > old.Restarts:
+			diff.Restarted = append(diff.Restarted, key)
+		case object.ResourceVersion != old.ResourceVersion:
+			diff.Changed = append(diff.Changed, key)
+		}
+	}
+	for _, object := range previous.Objects {
+		if _, ok := before[object.key()]; ok {
+			diff.Removed = append(diff.Removed, object.key())
+		}
+	}
+	return diff
+}
+
+// summarizeDiff describes diff in a sentence, naming objects by their resource.
+func (s *Service) summarizeDiff(diff ResultDiff, resource string) string {
+	since := "Since you last checked " + duration.HumanDuration(s.now().Sub(diff.Since)) + " ago"
+	var parts []string
+	for _, change := range []struct {
+		count int
+		verb  string
+	}{
+		{len(diff.Added), "added"},
+		{len(diff.Removed), "removed"},
+		{len(diff.Restarted), "restarted"},
+		{len(diff.Changed), "changed"},
+	} {
+		switch {
+		case change.count == 0:
+		case len(parts) > 0:
+			parts = append(parts, fmt.Sprintf("%d %s", change.count, change.verb))
+		case change.count == 1:
+			parts = append(parts, fmt.Sprintf("1 %s was %s", NormalizeKind(resource), change.verb))
+		default:
+			parts = append(parts, fmt.Sprintf("%d %s were %s", change.count, pluralKind(NormalizeKind(resource)), change.verb))
+		}
+	}
+	if len(parts) == 0 {
+		return since + ", nothing changed."
+	}
+	return since + ", " + strings.Join(parts, ", ") + "."
+}
+
+// pluralKind is the plural of a normalized kind, such as "pods" or "ingresses".
+func pluralKind(kind string) string {
+	switch {
+	case strings.HasSuffix(kind, "y") && !strings.HasSuffix(kind, "ay") && !strings.HasSuffix(kind, "ey"):
+		return strings.TrimSuffix(kind, "y") + "ies"
+	case strings.HasSuffix(kind, "s"), strings.HasSuffix(kind, "x"), strings.HasSuffix(kind, "ch"), strings.HasSuffix(kind, "sh"):
+		return kind + "es"
+	}
+	return kind + "s"
+}
@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func widget(name string, restarts int64) *unstructured.Unstructured {
+	obj := object("example.com/v1", "Widget", "shop", name, map[string]any{
+		"status": map[string]any{"containerStatuses": []any{map[string]any{"name": "main", "restartCount": restarts}}},
+	})
+	obj.SetUID(types.UID("uid-" + name))
+	return obj
+}
+
+func TestRepeatedReadsAreDiffed(t *testing.T) {
+	ctx := context.Background()
+	svc := newReadService(widget("blue", 0), widget("green", 2), widget("red", 0))
+	req := readRequest(OperationList, Resource{Kind: "widgets", Namespace: "shop"})
+
+	first, err := svc.Submit(ctx, req)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if first.Diff != nil {
+		t.Fatalf("expected no diff on the first read, got %+v", first.Diff)
+	}
+
+	client, _ := svc.dynamic(req.Config, req.Cluster, nil)
+	widgets := client.Resource(widgetsGVR).Namespace("shop")
+	if err := widgets.Delete(ctx, "red", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, obj := range []*unstructured.Unstructured{widget("pink", 0), widget("teal", 0)} {
+		if _, err := widgets.Create(ctx, obj, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := widgets.Update(ctx, widget("green", 3), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	later := svc.now().Add(3 * time.Hour)
+	svc.now = func() time.Time { return later }
+
+	second, err := svc.Submit(ctx, req)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	diff := second.Diff
+	if diff == nil || diff.PreviousExecutionID != first.ID {
+		t.Fatalf("expected a diff against %s, got %+v", first.ID, diff)
+	}
+	if !slices.Equal(diff.Added, []string{"shop/pink", "shop/teal"}) || !slices.Equal(diff.Removed, []string{"shop/red"}) ||
+		!slices.Equal(diff.Restarted, []string{"shop/green"}) || len(diff.Changed) != 0 {
+		t.Fatalf("unexpected diff %+v", diff)
+	}
+	if want := "Since you last checked 3h ago, 2 widgets were added, 1 removed, 1 restarted."; diff.Summary != want {
+		t.Fatalf("expected %q, got %q", want, diff.Summary)
+	}
+	stored, err := svc.Get(ctx, second.ID)
+	if err != nil || stored.Diff == nil || stored.Diff.Summary != diff.Summary {
+		t.Fatalf("expected the diff to be stored, got %+v (%v)", stored.Diff, err)
+	}
+
+	third, err := svc.Submit(ctx, req)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if want := "Since you last checked 0s ago, nothing changed."; third.Diff == nil || third.Diff.Summary != want {
+		t.Fatalf("expected %q, got %+v", want, third.Diff)
+	}
+
+	// Other requesters compare against their own reads.
+	req.UserID = "bob"
+	other, err := svc.Submit(ctx, req)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if other.Diff != nil {
+		t.Fatalf("expected no diff for another requester, got %+v", other.Diff)
+	}
+}
@@ -0,0 +1,300 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type DryRunAction string
+
+const (
+	DryRunCreate DryRunAction = "create"
+	DryRunUpdate DryRunAction = "update"
+	DryRunDelete DryRunAction = "delete"
+)
+
+// DryRunChange is one field a command would change, named like the fields of a rollback preview.
+type DryRunChange struct {
+	Path   string `json:"path"`
+	Before any    `json:"before,omitempty"`
+	After  any    `json:"after,omitempty"`
+	// Managers are the other field managers owning the field, such as a GitOps controller that
+	// would put the old value back.
+	Managers []string `json:"managers,omitempty"`
+}
+
+// DryRunDiff is what a command would change, as computed by the API server with a server-side
+// dry run. Like a rollback preview it compares labels, annotations and the object's content, not
+// its status or the metadata the API server maintains.
+type DryRunDiff struct {
+	Resource Resource       `json:"resource"`
+	Action   DryRunAction   `json:"action"`
+	Changes  []DryRunChange `json:"changes,omitempty"`
+	// Summary reads like "Would update Deployment shop/api: 1 field changed."
+	Summary string `json:"summary"`
+}
+
+// DryRunResult is the preview of a request together with the changes it would make.
+type DryRunResult struct {
+	Preview
+	Diff DryRunDiff `json:"diff"`
+}
+
+// DryRun previews req and has the API server validate and compute its changes without persisting
+// them. Nothing is recorded and no approval is needed, so the requester can check a command
+// before confirming it.
+func (s *Service) DryRun(ctx context.Context, req Request) (DryRunResult, error) {
+	req, webhook, delegated, err := s.prepare(ctx, req)
+	if err != nil {
+		return DryRunResult{}, err
+	}
+	switch {
+	case delegated:
+		return DryRunResult{}, ErrInvalidRequest{Reason: fmt.Sprintf("%s is run by an external executor and cannot be dry-run", req.Operation)}
+	case readOperation(req.Operation):
+		return DryRunResult{}, ErrInvalidRequest{Reason: fmt.Sprintf("%s changes nothing; only changes can be dry-run", req.Operation)}
+	case helmOperation(req.Operation):
+		return DryRunResult{}, ErrInvalidRequest{Reason: fmt.Sprintf("%s changes many objects; preview it with the diff of its release instead", req.Operation)}
+	}
+	if s.roles != nil {
+		if err := s.roles.CheckExecute(ctx, req.UserID, req.Groups, req.Resource.Namespace); err != nil {
+			return DryRunResult{}, err
+		}
+	}
+	preview, err := s.preview(ctx, req, webhook, delegated)
+	if err != nil {
+		return DryRunResult{}, err
+	}
+	if preview.Access != nil && !preview.Access.Allowed {
+		return DryRunResult{}, ErrAccessDenied{Review: *preview.Access}
+	}
+
+	execution := Execution{
+		UserID:       req.UserID,
+		Config:       req.Config,
+		Cluster:      req.Cluster,
+		Operation:    req.Operation,
+		Resource:     req.Resource,
+		Parameters:   req.Parameters,
+		AccessReview: preview.Access,
+	}
+	diff, err := s.dryRun(ctx, execution)
+	if err != nil {
+		return DryRunResult{}, err
+	}
+	s.log(ctx).Info("command dry run", "user", req.UserID, "cluster", req.Cluster, "operation", req.Operation, "resource", req.Resource.String(), "changes", len(diff.Changes))
+	return DryRunResult{Preview: preview, Diff: diff}, nil
+}
+
+// dryRun performs execution's operation as a server-side dry run and diffs the result with the
+// live object.
+func (s *Service) dryRun(ctx context.Context, execution Execution) (DryRunDiff, error) {
+	diff := DryRunDiff{Resource: execution.Resource, Action: DryRunUpdate}
+	var before, after *unstructured.Unstructured
+	if manifestOperation(execution.Operation) {
+		target, err := s.manifestTarget(ctx, execution)
+		if err != nil {
+			return DryRunDiff{}, err
+		}
+		result, err := target.write(ctx, execution.Operation, execution.Parameters["force"] == "true", true)
+		if err != nil {
+			return DryRunDiff{}, err
+		}
+		if target.current == nil {
+			diff.Action = DryRunCreate
+		} else {
+			before = target.current
+		}
+		after = result
+	} else {
+		client, err := s.client(execution)
+		if err != nil {
+			return DryRunDiff{}, err
+		}
+		live, err := getObject(ctx, client, execution.Resource)
+		if err != nil {
+			return DryRunDiff{}, err
+		}
+		if before, err = toUnstructured(live); err != nil {
+			return DryRunDiff{}, err
+		}
+		if execution.Operation == OperationDelete {
+			if err := deleteObject(ctx, client, execution.Resource, true); err != nil {
+				return DryRunDiff{}, err
+			}
+			diff.Action = DryRunDelete
+			diff.Summary = fmt.Sprintf("Would delete %s.", describeTarget(execution.Resource))
+			return diff, nil
+		}
+		patchType, patch, err := operationPatch(execution.Operation, execution.Parameters)
+		if err != nil {
+			return DryRunDiff{}, err
+		}
+		result, err := patchObject(ctx, client, execution.Resource, patchType, patch, true)
+		if err != nil {
+			return DryRunDiff{}, err
+		}
+		if after, err = toUnstructured(result); err != nil {
+			return DryRunDiff{}, err
+		}
+	}
+
+	diff.Changes = dryRunChanges(before, after)
+	switch {
+	case diff.Action == DryRunCreate:
+		diff.Summary = fmt.Sprintf("Would create %s.", describeTarget(execution.Resource))
+	case len(diff.Changes) == 0:
+		diff.Summary = fmt.Sprintf("Would leave %s unchanged.", describeTarget(execution.Resource))
+	case len(diff.Changes) == 1:
+		diff.Summary = fmt.Sprintf("Would update %s: 1 field changed.", describeTarget(execution.Resource))
+	default:
+		diff.Summary = fmt.Sprintf("Would update %s: %d fields changed.", describeTarget(execution.Resource), len(diff.Changes))
+	}
+	return diff, nil
+}
+
+func describeTarget(resource Resource) string {
+	if resource.Namespace == "" {
+		return resource.Kind + " " + resource.Name
+	}
+	return resource.Kind + " " + resource.Namespace + "/" + resource.Name
+}
+
+func toUnstructured(obj any) (*unstructured.Unstructured, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: content}, nil
+}
+
+// dryRunChanges lists the fields that differ between before, which is nil for an object being
+// created, and after. Each names the managers other than KubeChat that owned it before.
+func dryRunChanges(before, after *unstructured.Unstructured) []DryRunChange {
+	beforeFields, afterFields := writableFields(before), writableFields(after)
+	paths := make(map[string]bool)
+	for path := range beforeFields {
+		paths[path] = true
+	}
+	for path := range afterFields {
+		paths[path] = true
+	}
+	owners := fieldOwners(before)
+	var changes []DryRunChange
+	for path := range paths {
+		if reflect.DeepEqual(beforeFields[path], afterFields[path]) {
+			continue
+		}
+		change := DryRunChange{Path: path, Before: beforeFields[path], After: afterFields[path]}
+		for _, owner := range owners {
+			if owner.owns(path) {
+				change.Managers = append(change.Managers, owner.manager)
+			}
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// writableFields flattens obj's labels, annotations and every top-level field but its type,
+// metadata and status. Manifests may carry content outside spec, such as a Role's rules.
+func writableFields(obj *unstructured.Unstructured) map[string]any {
+	fields := make(map[string]any)
+	if obj == nil {
+		return fields
+	}
+	for key, value := range obj.Object {
+		switch key {
+		case "apiVersion", "kind", "metadata", "status":
+		default:
+			flatten(key, value, fields)
+		}
+	}
+	for _, key := range []string{"labels", "annotations"} {
+		if value, ok, _ := unstructured.NestedFieldNoCopy(obj.Object, "metadata", key); ok {
+			flatten("metadata."+key, value, fields)
+		}
+	}
+	return fields
+}
+
+// fieldOwner is a field manager and the paths of the fields it owns, in the form flatten uses.
+// A path ending in [] stands for every item of a list, since FieldsV1 keys items by value or
+// merge key rather than by index.
+type fieldOwner struct {
+	manager string
+	paths   []string
+}
+
+func fieldOwners(obj *unstructured.Unstructured) []fieldOwner {
+	if obj == nil {
+		return nil
+	}
+	var owners []fieldOwner
+	index := make(map[string]int)
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Manager == FieldManager || entry.Subresource != "" || entry.FieldsV1 == nil {
+			continue
+		}
+		var fields map[string]any
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		// A manager has an entry per operation, such as one for its applies and one for its updates.
+		i, ok := index[entry.Manager]
+		if !ok {
+			i = len(owners)
+			index[entry.Manager] = i
+			owners = append(owners, fieldOwner{manager: entry.Manager})
+		}
+		ownedPaths("", fields, &owners[i].paths)
+	}
+	return owners
+}
+
+func ownedPaths(prefix string, fields map[string]any, out *[]string) {
+	for key, child := range fields {
+		name, isField := strings.CutPrefix(key, "f:")
+		switch {
+		case key == ".":
+		case !isField:
+			*out = append(*out, prefix+"[]")
+		default:
+			path := name
+			if prefix != "" {
+				path = prefix + "." + name
+			}
+			if children, _ := child.(map[string]any); len(children) > 0 {
+				ownedPaths(path, children, out)
+			} else {
+				*out = append(*out, path)
+			}
+		}
+	}
+}
+
+// owns reports whether the manager owns the field at path, a field containing it or a field
+// within it.
+func (o fieldOwner) owns(path string) bool {
+	for _, owned := range o.paths {
+		if list, ok := strings.CutSuffix(owned, "[]"); ok {
+			if path == list || strings.HasPrefix(path, list+"[") {
+				return true
+			}
+			continue
+		}
+		if path == owned || strings.HasPrefix(path, owned+".") || strings.HasPrefix(path, owned+"[") ||
+			strings.HasPrefix(owned, path+".") || strings.HasPrefix(owned, path+"[") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestDryRunReportsChangesAndTheirManagers(t *testing.T) {
+	target := deployment(2)
+	target.ManagedFields = []metav1.ManagedFieldsEntry{{
+		Manager:    "argocd-controller",
+		Operation:  metav1.ManagedFieldsOperationApply,
+		FieldsType: "FieldsV1",
+		FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:replicas":{}}}`)},
+	}}
+	svc, client, repo := newTestService(target)
+	allowVerbs(client, "get", "patch", "delete")
+	ctx := context.Background()
+
+	req := scaleRequest("alice", "5")
+	req.DryRun = true
+	if _, err := svc.Submit(ctx, req); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected Submit to refuse a dry run, got %v", err)
+	}
+
+	result, err := svc.DryRun(ctx, req)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	diff := result.Diff
+	if diff.Action != DryRunUpdate || len(diff.Changes) != 1 || result.Kubectl == "" {
+		t.Fatalf("expected one field to change, got %+v", result)
+	}
+	change := diff.Changes[0]
+	if change.Path != "spec.replicas" || change.Before != int64(2) || change.After != int64(5) || !slices.Equal(change.Managers, []string{"argocd-controller"}) {
+		t.Fatalf("expected replicas owned by argocd to change from 2 to 5, got %+v", change)
+	}
+	if diff.Summary != "Would update Deployment shop/api: 1 field changed." {
+		t.Fatalf("unexpected summary %q", diff.Summary)
+	}
+	for _, action := range client.Actions() {
+		if patch, ok := action.(k8stesting.PatchActionImpl); ok && !slices.Equal(patch.PatchOptions.DryRun, []string{metav1.DryRunAll}) {
+			t.Fatalf("expected the patch to be a server-side dry run, got %+v", patch.PatchOptions)
+		}
+	}
+	if executions, _ := repo.ListByUser(ctx, "alice", 10, 0); len(executions) != 0 {
+		t.Fatalf("expected a dry run to record nothing, got %+v", executions)
+	}
+
+	remove := Request{UserID: "alice", Config: "kubeconfig", Cluster: "prod", Operation: OperationDelete, Resource: Resource{Kind: "Deployment", Namespace: "shop", Name: "api"}}
+	deleted, err := svc.DryRun(ctx, remove)
+	if err != nil || deleted.Diff.Action != DryRunDelete || !deleted.RequiresApproval {
+		t.Fatalf("expected a delete dry run, got %+v (%v)", deleted, err)
+	}
+	for _, action := range client.Actions() {
+		if del, ok := action.(k8stesting.DeleteActionImpl); ok && !slices.Equal(del.DeleteOptions.DryRun, []string{metav1.DryRunAll}) {
+			t.Fatalf("expected the delete to be a server-side dry run, got %+v", del.DeleteOptions)
+		}
+	}
+}
+
+func TestFieldOwnershipCoversListItems(t *testing.T) {
+	owner := fieldOwner{manager: "helm"}
+	ownedPaths("", map[string]any{
+		"f:metadata": map[string]any{"f:labels": map[string]any{".": map[string]any{}, "f:app": map[string]any{}}},
+		"f:spec": map[string]any{"f:template": map[string]any{"f:spec": map[string]any{"f:containers": map[string]any{
+			`k:{"name":"app"}`: map[string]any{"f:image": map[string]any{}},
+		}}}},
+	}, &owner.paths)
+
+	for path, want := range map[string]bool{
+		"spec.template.spec.containers[0].image": true,
+		"metadata.labels.app":                    true,
+		"metadata.labels.tier":                   false,
+		"spec.replicas":                          false,
+	} {
+		if got := owner.owns(path); got != want {
+			t.Fatalf("owns(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package commands
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// maxCorrelatedEvents caps the events attached to one execution, keeping warnings first.
+const maxCorrelatedEvents = 20
+
+// CorrelatedEvent is a Kubernetes event recorded for one of an execution's subjects.
+type CorrelatedEvent struct {
+	Type    string `json:"type"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+	Count   int64  `json:"count,omitempty"`
+	// Object is the object the event was recorded for: the subject itself, or a ReplicaSet or
+	// Pod a workload subject created.
+	Object    Resource  `json:"object"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// EventReport holds the events correlated with an execution's subjects, most relevant first.
+type EventReport struct {
+	Subjects []Resource        `json:"subjects"`
+	Events   []CorrelatedEvent `json:"events"`
+	Warnings int               `json:"warnings"`
+	// Summary explains the events in a sentence or two; it is empty when no summarizer is
+	// configured or the summarizer failed.
+	Summary string `json:"summary,omitempty"`
+}
+
+// EventSummarizer explains the events recorded for an execution's subjects, such as why a pod
+// keeps restarting.
+type EventSummarizer interface {
+	SummarizeEvents(ctx context.Context, subjects []Resource, events []CorrelatedEvent) (string, error)
+}
+
+// eventCorrelation is set when executions attach the events of their subjects.
+type eventCorrelation struct {
+	summarizer EventSummarizer
+}
+
+// SetEventCorrelation attaches the events recorded for the objects an execution read or changed
+// to its result. Events are summarized by summarizer when it is not nil.
+func (s *Service) SetEventCorrelation(summarizer EventSummarizer) {
+	s.events = &eventCorrelation{summarizer: summarizer}
+}
+
+// workloadKinds create the ReplicaSets, Pods and Jobs their events are recorded for, which are
+// named after them.
+var workloadKinds = map[string]bool{
+	"deployment": true, "statefulset": true, "daemonset": true, "replicaset": true, "job": true, "cronjob": true,
+}
+
+// correlateEvents collects the events of the objects execution read or changed. It never fails
+// the execution: events that cannot be read are logged and left out.
+func (s *Service) correlateEvents(ctx context.Context, execution Execution, read []unstructured.Unstructured) *EventReport {
+	if s.events == nil || s.dynamic == nil {
+		return nil
+	}
+	subjects := eventSubjects(execution, read)
+	if len(subjects) == 0 {
+		return nil
+	}
+	client, err := s.dynamicClient(execution)
+	if err != nil {
+		s.log(ctx).Warn("failed to read events", "id", execution.ID, "error", err)
+		return nil
+	}
+
+	report := &EventReport{Subjects: subjects}
+	listed := map[string]bool{}
+	for _, subject := range subjects {
+		if listed[subject.Namespace] {
+			continue
+		}
+		listed[subject.Namespace] = true
+		list, err := client.Resource(eventsGVR).Namespace(subject.Namespace).List(ctx, metav1.ListOptions{Limit: listLimit})
+		if err != nil {
+			s.log(ctx).Warn("failed to read events", "id", execution.ID, "namespace", subject.Namespace, "error", err)
+			continue
+		}
+		for _, item := range list.Items {
+			if event, ok := correlate(item, subjects); ok {
+				report.Events = append(report.Events, event)
+			}
+		}
+	}
+	if len(report.Events) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(report.Events, func(i, j int) bool {
+		a, b := report.Events[i], report.Events[j]
+		if (a.Type == corev1.EventTypeWarning) != (b.Type == corev1.EventTypeWarning) {
+			return a.Type == corev1.EventTypeWarning
+		}
+		return a.LastSeen.After(b.LastSeen)
+	})
+	if len(report.Events) > maxCorrelatedEvents {
+		report.Events = report.Events[:maxCorrelatedEvents]
+	}
+	for _, event := range report.Events {
+		if event.Type == corev1.EventTypeWarning {
+			report.Warnings++
+		}
+	}
+	if s.events.summarizer != nil {
+		summary, err := s.events.summarizer.SummarizeEvents(ctx, subjects, report.Events)
+		if err != nil {
+			s.log(ctx).Warn("failed to summarize events", "id", execution.ID, "error", err)
+		}
+		report.Summary = strings.TrimSpace(summary)
+	}
+	return report
+}
+
+// eventSubjects are the named, namespaced objects an execution read or changed. Objects a read
+// returned carry their kind; changes already name theirs.
+func eventSubjects(execution Execution, read []unstructured.Unstructured) []Resource {
+	var subjects []Resource
+	seen := map[Resource]bool{}
+	add := func(resource Resource) {
+		if resource.Name == "" || resource.Namespace == "" || resource.Kind == "" || seen[resource] {
+			return
+		}
+		seen[resource] = true
+		subjects = append(subjects, resource)
+	}
+	// A list returns many objects; only correlate reads of a single object.
+	if execution.Operation != OperationList {
+		for _, obj := range read {
+			add(Resource{Kind: obj.GetKind(), Namespace: obj.GetNamespace(), Name: obj.GetName()})
+		}
+	}
+	for _, change := range execution.Changes {
+		add(change.Resource)
+	}
+	return subjects
+}
+
+// correlate reports whether event was recorded for one of subjects, or for an object a workload
+// subject created, which its controller names after it.
+func correlate(event unstructured.Unstructured, subjects []Resource) (CorrelatedEvent, bool) {
+	kind, _, _ := unstructured.NestedString(event.Object, "involvedObject", "kind")
+	name, _, _ := unstructured.NestedString(event.Object, "involvedObject", "name")
+	created := kind == "ReplicaSet" || kind == "Pod" || kind == "Job"
+	matched := false
+	for _, subject := range subjects {
+		if subject.Namespace != event.GetNamespace() {
+			continue
+		}
+		if (strings.EqualFold(subject.Kind, kind) && subject.Name == name) ||
+			(created && workloadKinds[strings.ToLower(subject.Kind)] && strings.HasPrefix(name, subject.Name+"-")) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return CorrelatedEvent{}, false
+	}
+	correlated := CorrelatedEvent{
+		Object:   Resource{Kind: kind, Namespace: event.GetNamespace(), Name: name},
+		LastSeen: eventTime(event),
+	}
+	correlated.Type, _, _ = unstructured.NestedString(event.Object, "type")
+	correlated.Reason, _, _ = unstructured.NestedString(event.Object, "reason")
+	message, _, _ := unstructured.NestedString(event.Object, "message")
+	correlated.Message = strings.TrimSpace(message)
+	correlated.Count, _, _ = unstructured.NestedInt64(event.Object, "count")
+	if first, _, _ := unstructured.NestedString(event.Object, "firstTimestamp"); first != "" {
+		if at, err := time.Parse(time.RFC3339, first); err == nil {
+			correlated.FirstSeen = at
+		}
+	}
+	return correlated, true
+}
@@ -0,0 +1,286 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+type Operation string
+
+const (
+	OperationScale   Operation = "scale"
+	OperationRestart Operation = "restart"
+	OperationDelete  Operation = "delete"
+	OperationPatch   Operation = "patch"
+	// OperationApply server-side applies the manifest parameter.
+	OperationApply Operation = "apply"
+	// OperationCreate creates the object in the manifest parameter.
+	OperationCreate Operation = "create"
+	// OperationEdit replaces the live object with the manifest parameter.
+	OperationEdit Operation = "edit"
+	// OperationGet returns one object of any resource the cluster serves as YAML.
+	OperationGet Operation = "get"
+	// OperationList lists the objects of a resource, across namespaces when none is given.
+	OperationList Operation = "list"
+	// OperationDescribe returns an object with the events recorded for it.
+	OperationDescribe Operation = "describe"
+	// OperationTop lists the CPU and memory pods or nodes use, as metrics-server reports it.
+	OperationTop Operation = "top"
+	// OperationGenerate records a command the NLP guardrail refused to generate; it never runs.
+	OperationGenerate Operation = "generate"
+	// OperationPlan runs the execution's steps in order, each as an execution of its own.
+	OperationPlan Operation = "plan"
+	// OperationHelmUpgrade installs a new revision of a Helm release from the rendered manifest
+	// parameter, such as the output of helm template.
+	OperationHelmUpgrade Operation = "helm_upgrade"
+	// OperationHelmRollback reinstalls an earlier revision of a Helm release, the previous one
+	// unless the revision parameter names another.
+	OperationHelmRollback Operation = "helm_rollback"
+	// OperationRollback restores what the execution named by the execution parameter changed. It
+	// is only submitted through Rollback.
+	OperationRollback Operation = "rollback"
+)
+
+type SafetyLevel string
+
+const (
+	SafetySafe      SafetyLevel = "safe"
+	SafetyWarning   SafetyLevel = "warning"
+	SafetyDangerous SafetyLevel = "dangerous"
+	// SafetyBlocked marks generations the NLP guardrail rejected, kept only for the audit trail.
+	SafetyBlocked SafetyLevel = "blocked"
+)
+
+type Status string
+
+const (
+	StatusAwaitingApproval Status = "awaiting_approval"
+	StatusRunning          Status = "running"
+	StatusSucceeded        Status = "succeeded"
+	StatusFailed           Status = "failed"
+	StatusRejected         Status = "rejected"
+	StatusExpired          Status = "expired"
+	StatusRolledBack       Status = "rolled_back"
+	// StatusQueued holds an execution until the change window freezing its target ends.
+	StatusQueued Status = "queued"
+)
+
+type Resource struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+func (r Resource) String() string {
+	return fmt.Sprintf("%s/%s/%s", r.Kind, r.Namespace, r.Name)
+}
+
+type Execution struct {
+	ID          string            `json:"id"`
+	UserID      string            `json:"userId"`
+	Config      string            `json:"config"`
+	Cluster     string            `json:"cluster"`
+	Operation   Operation         `json:"operation"`
+	Resource    Resource          `json:"resource"`
+	Parameters  map[string]string `json:"parameters,omitempty"`
+	SafetyLevel SafetyLevel       `json:"safetyLevel"`
+	Status      Status            `json:"status"`
+	Result      string            `json:"result,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	StartedAt   *time.Time        `json:"startedAt,omitempty"`
+	CompletedAt *time.Time        `json:"completedAt,omitempty"`
+	// DriftWarning is set when the requester acknowledged that the target is managed by infrastructure-as-code.
+	DriftWarning *DriftWarning `json:"driftWarning,omitempty"`
+	// AccessReview is the requester's permission check, recorded when executions run as the requester.
+	AccessReview *AccessReview `json:"accessReview,omitempty"`
+	// PolicyDecision is the authorization policy's decision, recorded when one is configured.
+	PolicyDecision *PolicyDecision `json:"policyDecision,omitempty"`
+	// Diff compares a read with the previous run of the same read by the same requester.
+	Diff *ResultDiff `json:"diff,omitempty"`
+	// Attachments hold outputs too large to keep inline; Result then carries a truncated preview.
+	Attachments []Attachment `json:"attachments,omitempty"`
+	// CorrelationID links the execution to the chat turn and plan it carries out; it is the
+	// execution's own ID when the command was not submitted from a plan.
+	CorrelationID string `json:"correlationId"`
+	// Changes record the objects the execution changed in the cluster.
+	Changes []ObjectChange `json:"changes,omitempty"`
+	// QueuedUntil is when the change window that queued the execution ends.
+	QueuedUntil *time.Time `json:"queuedUntil,omitempty"`
+	// FreezeOverride is set when the execution ran during a change window.
+	FreezeOverride *FreezeOverride `json:"freezeOverride,omitempty"`
+	// Steps are the commands a plan runs, approved together with the plan.
+	Steps []PlanStep `json:"steps,omitempty"`
+	// PlanID is the plan whose step the execution carried out.
+	PlanID string `json:"planId,omitempty"`
+	// Events are the Kubernetes events recorded for the objects the execution read or changed.
+	Events *EventReport `json:"events,omitempty"`
+	// DeletedAt is when DeletedBy deleted the execution. Deleted executions are hidden but kept
+	// until retention purges them.
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	DeletedBy string     `json:"deletedBy,omitempty"`
+}
+
+// ObjectChange records an object's resourceVersion before and after a change. Before is empty
+// for an object the change created and After for one it deleted.
+type ObjectChange struct {
+	Resource              Resource `json:"resource"`
+	ResourceVersionBefore string   `json:"resourceVersionBefore,omitempty"`
+	ResourceVersionAfter  string   `json:"resourceVersionAfter,omitempty"`
+}
+
+// finished reports whether the execution can no longer change state.
+func (e Execution) finished() bool {
+	return e.Status != StatusAwaitingApproval && e.Status != StatusRunning && e.Status != StatusQueued
+}
+
+// Attachment describes an output stored in the attachment store.
+type Attachment struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	Key         string    `json:"-"`
+	URL         string    `json:"url"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type ApprovalStatus string
+
+const (
+	ApprovalPending  ApprovalStatus = "pending"
+	ApprovalApproved ApprovalStatus = "approved"
+	ApprovalRejected ApprovalStatus = "rejected"
+	ApprovalExpired  ApprovalStatus = "expired"
+)
+
+type ApprovalDecision struct {
+	User string `json:"user"`
+	// OnBehalfOf names the approver a delegate decided for.
+	OnBehalfOf string         `json:"onBehalfOf,omitempty"`
+	Decision   ApprovalStatus `json:"decision"`
+	Reason     string         `json:"reason,omitempty"`
+	At         time.Time      `json:"at"`
+}
+
+type Approval struct {
+	ID                string             `json:"id"`
+	ExecutionID       string             `json:"executionId"`
+	RequestedBy       string             `json:"requestedBy"`
+	PolicyName        string             `json:"policy"`
+	RequiredApprovals int                `json:"requiredApprovals"`
+	ApproverGroups    []string           `json:"approverGroups,omitempty"`
+	Decisions         []ApprovalDecision `json:"decisions,omitempty"`
+	DecidedBy         string             `json:"decidedBy,omitempty"`
+	Status            ApprovalStatus     `json:"status"`
+	Reason            string             `json:"reason,omitempty"`
+	CreatedAt         time.Time          `json:"createdAt"`
+	DecidedAt         *time.Time         `json:"decidedAt,omitempty"`
+	ExpiresAt         *time.Time         `json:"expiresAt,omitempty"`
+	EscalateAt        *time.Time         `json:"escalateAt,omitempty"`
+	EscalatedAt       *time.Time         `json:"escalatedAt,omitempty"`
+	// Explanation tells approvers what the command does, so they do not approve an opaque kubectl string.
+	Explanation *Explanation `json:"explanation,omitempty"`
+	// Version counts the saves of the approval, so a decision made on a stale copy is refused.
+	Version int `json:"version"`
+}
+
+// decidedBy reports whether user decided, directly or through a delegate.
+func (a Approval) decidedBy(user string) bool {
+	return slices.ContainsFunc(a.Decisions, func(d ApprovalDecision) bool {
+		return d.User == user || d.OnBehalfOf == user
+	})
+}
+
+// Approvals counts the approve decisions recorded so far.
+func (a Approval) Approvals() int {
+	count := 0
+	for _, decision := range a.Decisions {
+		if decision.Decision == ApprovalApproved {
+			count++
+		}
+	}
+	return count
+}
+
+type ApprovalAction string
+
+const (
+	ApprovalActionRequested ApprovalAction = "requested"
+	ApprovalActionApproved  ApprovalAction = "approved"
+	ApprovalActionRejected  ApprovalAction = "rejected"
+	ApprovalActionExpired   ApprovalAction = "expired"
+	ApprovalActionEscalated ApprovalAction = "escalated"
+	// ApprovalActionFreezeOverridden records an approver running the execution during a change window.
+	ApprovalActionFreezeOverridden ApprovalAction = "freeze_overridden"
+	// ApprovalActionRouted records that a delegate may decide on behalf of an approver.
+	ApprovalActionRouted ApprovalAction = "routed"
+	// ApprovalActionDeleted records who deleted the execution.
+	ApprovalActionDeleted ApprovalAction = "deleted"
+)
+
+// ApprovalEvent is one entry of the append-only audit trail kept for every approval.
+type ApprovalEvent struct {
+	ID          string `json:"id"`
+	ExecutionID string `json:"executionId"`
+	Actor       string `json:"actor"`
+	// OnBehalfOf names the approver the actor decided for, or the approver whose approvals were routed.
+	OnBehalfOf string         `json:"onBehalfOf,omitempty"`
+	Action     ApprovalAction `json:"action"`
+	Reason     string         `json:"reason,omitempty"`
+	At         time.Time      `json:"at"`
+}
+
+// RollbackStep restores one resource from the snapshot taken before the execution changed it.
+type RollbackStep struct {
+	Action   RollbackAction  `json:"action"`
+	Resource Resource        `json:"resource"`
+	Snapshot json.RawMessage `json:"snapshot"`
+}
+
+type RollbackPlan struct {
+	ID          string         `json:"id"`
+	ExecutionID string         `json:"executionId"`
+	Steps       []RollbackStep `json:"steps"`
+	Executed    bool           `json:"executed"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	ExecutedAt  *time.Time     `json:"executedAt,omitempty"`
+	// Changes record the objects the rollback restored, once it was executed.
+	Changes []ObjectChange `json:"changes,omitempty"`
+}
+
+type ErrExecutionNotFound struct {
+	ID string
+}
+
+func (e ErrExecutionNotFound) Error() string {
+	return fmt.Sprintf("command execution %s not found", e.ID)
+}
+
+type ErrApprovalNotFound struct {
+	ExecutionID string
+}
+
+func (e ErrApprovalNotFound) Error() string {
+	return fmt.Sprintf("no approval recorded for command execution %s", e.ExecutionID)
+}
+
+type ErrAttachmentNotFound struct {
+	ExecutionID  string
+	AttachmentID string
+}
+
+func (e ErrAttachmentNotFound) Error() string {
+	return fmt.Sprintf("attachment %s not found on command execution %s", e.AttachmentID, e.ExecutionID)
+}
+
+type ErrRollbackNotFound struct {
+	ExecutionID string
+}
+
+func (e ErrRollbackNotFound) Error() string {
+	return fmt.Sprintf("no rollback plan recorded for command execution %s", e.ExecutionID)
+}
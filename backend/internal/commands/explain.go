@@ -0,0 +1,286 @@
+package commands
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/maypok86/otter/v2"
+)
+
+// explanationCacheSize bounds the explanations kept per service.
+const explanationCacheSize = 1024
+
+type Reversibility string
+
+const (
+	// ReversibilityReadOnly commands change nothing.
+	ReversibilityReadOnly Reversibility = "read_only"
+	// ReversibilityReversible commands record a rollback plan that restores the previous state.
+	ReversibilityReversible Reversibility = "reversible"
+	// ReversibilityPartial commands can be rolled back, but not everything they disturb comes back.
+	ReversibilityPartial Reversibility = "partially_reversible"
+	// ReversibilityIrreversible commands cannot be undone by KubeChat.
+	ReversibilityIrreversible Reversibility = "irreversible"
+	// ReversibilityUnknown is used for operations delegated to an external executor.
+	ReversibilityUnknown Reversibility = "unknown"
+)
+
+// Permission is one Kubernetes authorization a command needs.
+type Permission struct {
+	Verb      string `json:"verb"`
+	Group     string `json:"group,omitempty"`
+	Resource  string `json:"resource"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
+
+// Explanation describes a command in terms an approver can check without reading kubectl.
+type Explanation struct {
+	// Hash identifies the command: its cluster, operation, target and parameters.
+	Hash    string `json:"hash"`
+	Command string `json:"command,omitempty"`
+	Summary string `json:"summary"`
+	// Effects lists what changes in the cluster, including side effects on other resources.
+	Effects []string `json:"effects"`
+	// Resources are the objects the command reads or writes directly.
+	Resources     []Resource    `json:"resources"`
+	Reversibility Reversibility `json:"reversibility"`
+	// Reversal says how the change can be undone.
+	Reversal    string       `json:"reversal"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// Explain describes a recorded execution.
+func (s *Service) Explain(ctx context.Context, id string) (Explanation, error) {
+	execution, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return Explanation{}, err
+	}
+	return s.explain(execution), nil
+}
+
+// explain returns the cached explanation of the execution's command, building it on first use.
+func (s *Service) explain(execution Execution) Explanation {
+	hash := commandHash(execution)
+	if explanation, ok := s.explanations.GetIfPresent(hash); ok {
+		return explanation
+	}
+	explanation := s.buildExplanation(execution)
+	explanation.Hash = hash
+	s.explanations.Set(hash, explanation)
+	return explanation
+}
+
+// commandHash identifies what an execution does, independent of who asked for it and when.
+func commandHash(execution Execution) string {
+	keys := make([]string, 0, len(execution.Parameters))
+	for key := range execution.Parameters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%s\x00%s", execution.Config, execution.Cluster, execution.Operation,
+		execution.Resource.Kind, execution.Resource.Namespace, execution.Resource.Name)
+	for _, key := range keys {
+		fmt.Fprintf(h, "\x00%s=%s", key, execution.Parameters[key])
+	}
+	for _, step := range execution.Steps {
+		fmt.Fprintf(h, "\x00step\x00%s", commandHash(execution.stepExecution(step)))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (s *Service) buildExplanation(execution Execution) Explanation {
+	if execution.Operation == OperationPlan {
+		return s.explainPlan(execution)
+	}
+	resource := execution.Resource
+	target := fmt.Sprintf("%s %s", resource.Kind, resource.Name)
+	if resource.Namespace != "" {
+		target += " in namespace " + resource.Namespace
+	}
+	explanation := Explanation{Resources: []Resource{resource}}
+	if execution.Operation == OperationRollback {
+		explanation.Summary = fmt.Sprintf("Roll back execution %s on %s", execution.Parameters["execution"], target)
+		explanation.Effects = []string{"The objects the execution changed are restored to the snapshots taken before it ran"}
+		explanation.Reversibility = ReversibilityIrreversible
+		explanation.Reversal = "A rollback records no rollback plan of its own; run the original command again to redo it"
+		explanation.Permissions = []Permission{}
+		return explanation
+	}
+
+	if webhook, ok := s.webhooks.Lookup(execution.Operation); ok {
+		explanation.Summary = fmt.Sprintf("Send %s of %s to the external executor at %s", execution.Operation, target, webhook.URL)
+		explanation.Effects = []string{"The executor decides what changes; KubeChat does not run this command against the cluster itself"}
+		explanation.Reversibility = ReversibilityUnknown
+		explanation.Reversal = "Depends on the executor; KubeChat records no rollback plan"
+		explanation.Permissions = []Permission{}
+		return explanation
+	}
+	explanation.Command = Kubectl(execution.Cluster, execution.Operation, resource, execution.Parameters)
+
+	params := execution.Parameters
+	switch execution.Operation {
+	case OperationScale:
+		explanation.Summary = fmt.Sprintf("Set the replica count of %s to %s", target, params["replicas"])
+		explanation.Effects = []string{"Pods are created or terminated until " + params["replicas"] + " replicas run"}
+		if params["replicas"] == "0" {
+			explanation.Effects = append(explanation.Effects, "Every pod is terminated and the workload stops serving")
+		}
+		explanation.Reversibility = ReversibilityReversible
+		explanation.Reversal = "Rollback restores the previous replica count; terminated pods are replaced with new ones"
+	case OperationRestart:
+		explanation.Summary = "Restart every pod of " + target + " with a rolling update"
+		explanation.Effects = []string{"Pods are replaced one batch at a time following the rollout strategy", "In-memory state of the running pods is lost"}
+		explanation.Reversibility = ReversibilityIrreversible
+		explanation.Reversal = "A restart cannot be undone, but it leaves the specification unchanged"
+	case OperationDelete:
+		explanation.Summary = "Delete " + target
+		if strings.EqualFold(resource.Kind, "pod") {
+			explanation.Effects = []string{"The pod's containers are stopped", "A controller that owns the pod starts a replacement"}
+			explanation.Reversibility = ReversibilityIrreversible
+			explanation.Reversal = "The pod itself cannot be restored; its owner, if any, creates a new one"
+		} else {
+			explanation.Effects = []string{"The object is removed from the cluster", "Objects it owns, such as pods, are garbage collected"}
+			explanation.Reversibility = ReversibilityPartial
+			explanation.Reversal = "Rollback recreates the object from the snapshot taken before deletion; garbage-collected pods and their data are not restored"
+		}
+	case OperationPatch:
+		patchType := params["patchType"]
+		if patchType == "" {
+			patchType = "strategic"
+		}
+		explanation.Summary = fmt.Sprintf("Change fields of %s with a %s patch", target, patchType)
+		explanation.Effects = []string{"Fields named in the patch are changed: " + params["patch"]}
+		if workloadKind(resource.Kind) {
+			explanation.Effects = append(explanation.Effects, "Changes to the pod template roll out new pods")
+		}
+		explanation.Reversibility = ReversibilityReversible
+		explanation.Reversal = "Rollback restores the object from the snapshot taken before the patch"
+	case OperationApply:
+		explanation.Summary = "Server-side apply the manifest for " + target
+		explanation.Effects = []string{"The object is created, or the fields set in the manifest are changed"}
+		if params["force"] == "true" {
+			explanation.Effects = append(explanation.Effects, "Fields owned by other managers are taken over")
+		}
+		explanation.Reversibility = ReversibilityReversible
+		explanation.Reversal = "Rollback restores the previous object, or removes it when the apply created it"
+	case OperationCreate:
+		explanation.Summary = "Create " + target + " from the manifest"
+		explanation.Effects = []string{"A new object is added; the command fails if it already exists"}
+		explanation.Reversibility = ReversibilityReversible
+		explanation.Reversal = "Rollback deletes the created object"
+	case OperationEdit:
+		explanation.Summary = "Replace " + target + " with the manifest"
+		explanation.Effects = []string{"Every field of the live object is replaced by the manifest; fields it omits are cleared"}
+		explanation.Reversibility = ReversibilityReversible
+		explanation.Reversal = "Rollback restores the object from the snapshot taken before the replace"
+	case OperationGet:
+		explanation.Summary = "Read " + target + " as YAML"
+		explanation.Effects = []string{"Nothing in the cluster changes"}
+		explanation.Reversibility = ReversibilityReadOnly
+		explanation.Reversal = "Nothing to undo"
+	case OperationDescribe:
+		explanation.Summary = "Read " + target + " with the events recorded for it"
+		explanation.Effects = []string{"Nothing in the cluster changes"}
+		explanation.Reversibility = ReversibilityReadOnly
+		explanation.Reversal = "Nothing to undo"
+		explanation.Resources = append(explanation.Resources, Resource{Kind: "Event", Namespace: resource.Namespace})
+	case OperationList:
+		scope := "all namespaces"
+		if resource.Namespace != "" {
+			scope = "namespace " + resource.Namespace
+		}
+		explanation.Summary = fmt.Sprintf("List %s objects in %s", resource.Kind, scope)
+		if selector := params["selector"]; selector != "" {
+			explanation.Summary += " matching " + selector
+		}
+		explanation.Effects = []string{"Nothing in the cluster changes"}
+		explanation.Reversibility = ReversibilityReadOnly
+		explanation.Reversal = "Nothing to undo"
+	case OperationTop:
+		scope := "all namespaces"
+		if topResource(resource.Kind) == "nodes" {
+			scope = "the cluster"
+		} else if resource.Namespace != "" {
+			scope = "namespace " + resource.Namespace
+		}
+		explanation.Summary = fmt.Sprintf("Show the CPU and memory %s use in %s, as metrics-server reports it", topResource(resource.Kind), scope)
+		explanation.Effects = []string{"Nothing in the cluster changes"}
+		explanation.Reversibility = ReversibilityReadOnly
+		explanation.Reversal = "Nothing to undo"
+	case OperationHelmUpgrade:
+		explanation.Summary = "Upgrade Helm release " + target + " with the rendered manifest"
+		explanation.Effects = []string{
+			"Every object in the manifest is server-side applied and objects of the release it omits are deleted",
+			"A new revision is recorded in the release history; chart hooks are not run",
+		}
+		explanation.Reversibility = ReversibilityPartial
+		explanation.Reversal = "A helm_rollback reinstalls the revision this upgrade replaces; data of deleted objects, such as volumes, is not restored"
+	case OperationHelmRollback:
+		revision := params["revision"]
+		if revision == "" {
+			revision = "the previous revision"
+		} else {
+			revision = "revision " + revision
+		}
+		explanation.Summary = fmt.Sprintf("Roll back Helm release %s to %s", target, revision)
+		explanation.Effects = []string{
+			"Every object of the earlier revision is server-side applied and objects it did not have are deleted",
+			"A new revision recording the rollback is added to the release history; chart hooks are not run",
+		}
+		explanation.Reversibility = ReversibilityPartial
+		explanation.Reversal = "Another helm_rollback reinstalls the revision this one replaces; data of deleted objects is not restored"
+	}
+	explanation.Permissions = s.requiredPermissions(execution)
+	return explanation
+}
+
+// requiredPermissions lists the verbs the execution uses on its target. When the resource cannot
+// be mapped, for example because the cluster is unreachable, the kind is named in lower case.
+func (s *Service) requiredPermissions(execution Execution) []Permission {
+	gr := [2]string{"", strings.ToLower(execution.Resource.Kind)}
+	if s.mappers != nil || !(manifestOperation(execution.Operation) || readOperation(execution.Operation)) {
+		mapped, err := s.accessResource(Request{
+			Config:     execution.Config,
+			Cluster:    execution.Cluster,
+			Operation:  execution.Operation,
+			Resource:   execution.Resource,
+			Parameters: execution.Parameters,
+		})
+		if err == nil {
+			gr = mapped
+		}
+	}
+	var permissions []Permission
+	target := accessTarget(execution.Operation, execution.Resource)
+	for _, verb := range requiredVerbs(execution.Operation) {
+		permissions = append(permissions, Permission{
+			Verb:      verb,
+			Group:     gr[0],
+			Resource:  gr[1],
+			Namespace: target.Namespace,
+			Name:      target.Name,
+		})
+	}
+	if execution.Operation == OperationDescribe {
+		permissions = append(permissions, Permission{Verb: "list", Resource: "events", Namespace: execution.Resource.Namespace})
+	}
+	return permissions
+}
+
+func workloadKind(kind string) bool {
+	switch strings.ToLower(kind) {
+	case "deployment", "statefulset", "daemonset":
+		return true
+	}
+	return false
+}
+
+func newExplanationCache() *otter.Cache[string, Explanation] {
+	return otter.Must(&otter.Options[string, Explanation]{MaximumSize: explanationCacheSize})
+}
@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestApprovalCarriesExplanation(t *testing.T) {
+	svc, _, _ := newTestService(deployment(3))
+	ctx := context.Background()
+
+	execution, err := svc.Submit(ctx, scaleRequest("alice", "0"))
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	approval, _, err := svc.Approval(ctx, execution.ID)
+	if err != nil {
+		t.Fatalf("approval: %v", err)
+	}
+	explanation := approval.Explanation
+	if explanation == nil {
+		t.Fatalf("expected the approval request to carry an explanation")
+	}
+	if explanation.Reversibility != ReversibilityReversible || !strings.Contains(explanation.Summary, "to 0") {
+		t.Fatalf("unexpected explanation %+v", explanation)
+	}
+	if len(explanation.Effects) != 2 || !strings.Contains(explanation.Command, "--replicas=0") {
+		t.Fatalf("expected the scale-to-zero side effect and kubectl command, got %+v", explanation)
+	}
+	if len(explanation.Permissions) != 2 || explanation.Permissions[1].Verb != "patch" || explanation.Permissions[1].Resource != "deployments" {
+		t.Fatalf("unexpected permissions %+v", explanation.Permissions)
+	}
+
+	// The same command asked for by someone else shares the cached explanation.
+	again, err := svc.Submit(ctx, scaleRequest("bob", "0"))
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	explained, err := svc.Explain(ctx, again.ID)
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	if explained.Hash != explanation.Hash {
+		t.Fatalf("expected identical commands to share a hash")
+	}
+	if other := svc.explain(Execution{Config: "kubeconfig", Cluster: "prod", Operation: OperationScale, Resource: execution.Resource, Parameters: map[string]string{"replicas": "1"}}); other.Hash == explanation.Hash {
+		t.Fatalf("expected different parameters to change the hash")
+	}
+}
+
+func TestExplainReversibility(t *testing.T) {
+	svc, _, _ := newTestService()
+	cases := []struct {
+		op       Operation
+		kind     string
+		expected Reversibility
+	}{
+		{OperationDelete, "Pod", ReversibilityIrreversible},
+		{OperationDelete, "ConfigMap", ReversibilityPartial},
+		{OperationRestart, "Deployment", ReversibilityIrreversible},
+		{OperationPatch, "Deployment", ReversibilityReversible},
+		{OperationGet, "Deployment", ReversibilityReadOnly},
+	}
+	for _, tc := range cases {
+		explanation := svc.explain(Execution{
+			Config:    "kubeconfig",
+			Cluster:   "prod",
+			Operation: tc.op,
+			Resource:  Resource{Kind: tc.kind, Namespace: "shop", Name: "api"},
+		})
+		if explanation.Reversibility != tc.expected {
+			t.Fatalf("%s %s: expected %s, got %s", tc.op, tc.kind, tc.expected, explanation.Reversibility)
+		}
+	}
+}
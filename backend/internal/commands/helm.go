@@ -0,0 +1,396 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/helm"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+)
+
+// HelmReleaseKind is the resource kind of Helm operations; the resource's name is the release's.
+const HelmReleaseKind = "HelmRelease"
+
+// HelmPreview is what a Helm upgrade or rollback would change in its release, together with the
+// preview of submitting it.
+type HelmPreview struct {
+	Preview
+	// From is the release's current revision and To the revision a rollback would reinstall; To
+	// is 0 for an upgrade, which installs a new manifest.
+	From int               `json:"from"`
+	To   int               `json:"to,omitempty"`
+	Diff []helm.ObjectDiff `json:"diff"`
+}
+
+// helmOperation reports whether op changes a Helm release.
+func helmOperation(op Operation) bool {
+	return op == OperationHelmUpgrade || op == OperationHelmRollback
+}
+
+func validateHelm(req Request) error {
+	if req.Resource.Name == "" || req.Resource.Namespace == "" {
+		return ErrInvalidRequest{Reason: "release namespace and name are required"}
+	}
+	if req.Resource.Kind != "" && !strings.EqualFold(req.Resource.Kind, HelmReleaseKind) {
+		return ErrInvalidRequest{Reason: fmt.Sprintf("%s targets a %s, not a %s", req.Operation, HelmReleaseKind, req.Resource.Kind)}
+	}
+	switch req.Operation {
+	case OperationHelmRollback:
+		if _, err := revisionParam(req.Parameters); err != nil {
+			return err
+		}
+	case OperationHelmUpgrade:
+		if strings.TrimSpace(req.Parameters["manifest"]) == "" {
+			return ErrInvalidRequest{Reason: "manifest is required; render the chart with helm template"}
+		}
+		objects, err := helm.Objects(req.Parameters["manifest"], req.Resource.Namespace)
+		if err != nil {
+			return ErrInvalidRequest{Reason: err.Error()}
+		}
+		if len(objects) == 0 {
+			return ErrInvalidRequest{Reason: "manifest has no objects"}
+		}
+		if _, err := valuesParam(req.Parameters); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revisionParam returns the revision a rollback reinstalls, or 0 for the previous one.
+func revisionParam(params map[string]string) (int, error) {
+	raw := params["revision"]
+	if raw == "" {
+		return 0, nil
+	}
+	revision, err := strconv.Atoi(raw)
+	if err != nil || revision < 1 {
+		return 0, ErrInvalidRequest{Reason: "revision must be a positive integer"}
+	}
+	return revision, nil
+}
+
+// valuesParam parses the values an upgrade records for the new revision; nil keeps the values of
+// the current revision.
+func valuesParam(params map[string]string) (map[string]any, error) {
+	raw := params["values"]
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var values map[string]any
+	if err := yaml.Unmarshal([]byte(raw), &values); err != nil || values == nil {
+		return nil, ErrInvalidRequest{Reason: "values must be a YAML or JSON object"}
+	}
+	return values, nil
+}
+
+// sensitiveManifest reports whether a rendered manifest writes a kind that changes who can do
+// what in the cluster.
+func sensitiveManifest(manifest string) bool {
+	objects, err := helm.Objects(manifest, "")
+	if err != nil {
+		return false
+	}
+	for _, obj := range objects {
+		if sensitiveKinds[strings.ToLower(obj.GetKind())] {
+			return true
+		}
+	}
+	return false
+}
+
+// checkReleasePolicy refuses an upgrade whose manifest writes a resource the resource policy does
+// not allow. The secrets Helm records revisions in are not checked; they are Helm's bookkeeping
+// rather than the release's content. A rollback's objects are checked as they are written.
+func (s *Service) checkReleasePolicy(req Request) error {
+	if req.Operation != OperationHelmUpgrade {
+		return nil
+	}
+	objects, err := helm.Objects(req.Parameters["manifest"], req.Resource.Namespace)
+	if err != nil {
+		return ErrInvalidRequest{Reason: err.Error()}
+	}
+	for _, obj := range objects {
+		mapping, err := s.mapping(req.Config, req.Cluster, obj.GroupVersionKind())
+		if err != nil {
+			return err
+		}
+		if resource := mapping.Resource.GroupResource(); !s.resources.Allows(resource) {
+			return ErrResourceNotAllowed{Resource: resource}
+		}
+	}
+	return nil
+}
+
+// helmStore returns the release store of the cluster req names, read as the requester when
+// impersonation is enabled.
+func (s *Service) helmStore(ctx context.Context, req Request) (*helm.Store, error) {
+	req, err := s.resolveCluster(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Config == "" || req.Cluster == "" {
+		return nil, ErrInvalidRequest{Reason: "config and cluster are required"}
+	}
+	client, err := s.requesterClient(req)
+	if err != nil {
+		return nil, err
+	}
+	return helm.NewStore(client), nil
+}
+
+// HelmReleases lists the latest revision of every Helm release in the namespace of req's
+// resource, or in all namespaces when it has none.
+func (s *Service) HelmReleases(ctx context.Context, req Request) ([]helm.Release, error) {
+	store, err := s.helmStore(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return store.List(ctx, req.Resource.Namespace)
+}
+
+// HelmHistory lists the revisions of the release req names, oldest first.
+func (s *Service) HelmHistory(ctx context.Context, req Request) ([]helm.Release, error) {
+	store, err := s.helmStore(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return store.History(ctx, req.Resource.Namespace, req.Resource.Name)
+}
+
+// HelmRelease returns a revision of the release req names, including its values and manifest,
+// or its latest revision when revision is 0.
+func (s *Service) HelmRelease(ctx context.Context, req Request, revision int) (helm.Release, error) {
+	store, err := s.helmStore(ctx, req)
+	if err != nil {
+		return helm.Release{}, err
+	}
+	return store.Get(ctx, req.Resource.Namespace, req.Resource.Name, revision)
+}
+
+// PreviewHelm previews a Helm upgrade or rollback like Preview and diffs the manifest it would
+// install with the release's current one.
+func (s *Service) PreviewHelm(ctx context.Context, req Request) (HelmPreview, error) {
+	if !helmOperation(req.Operation) {
+		return HelmPreview{}, ErrInvalidRequest{Reason: fmt.Sprintf("%s is not a Helm operation", req.Operation)}
+	}
+	req, webhook, delegated, err := s.prepare(ctx, req)
+	if err != nil {
+		return HelmPreview{}, err
+	}
+	preview, err := s.preview(ctx, req, webhook, delegated)
+	if err != nil {
+		return HelmPreview{}, err
+	}
+	client, err := s.requesterClient(req)
+	if err != nil {
+		return HelmPreview{}, err
+	}
+	current, next, err := helmTarget(ctx, helm.NewStore(client), req.Operation, req.Resource, req.Parameters)
+	if err != nil {
+		return HelmPreview{}, err
+	}
+	diff, err := helm.Diff(current.Manifest, next.Manifest, req.Resource.Namespace)
+	if err != nil {
+		return HelmPreview{}, err
+	}
+	result := HelmPreview{Preview: preview, From: current.Revision, Diff: diff}
+	if req.Operation == OperationHelmRollback {
+		result.To = next.Revision
+	}
+	return result, nil
+}
+
+// helmTarget returns the release's latest revision and the release an operation would record
+// next: an earlier revision for a rollback, the latest with a new manifest for an upgrade.
+func helmTarget(ctx context.Context, store *helm.Store, op Operation, resource Resource, params map[string]string) (helm.Release, helm.Release, error) {
+	current, err := store.Get(ctx, resource.Namespace, resource.Name, 0)
+	if err != nil {
+		return helm.Release{}, helm.Release{}, err
+	}
+	switch op {
+	case OperationHelmRollback:
+		revision, err := revisionParam(params)
+		if err != nil {
+			return helm.Release{}, helm.Release{}, err
+		}
+		if revision == 0 {
+			revision = current.Revision - 1
+		}
+		if revision < 1 || revision == current.Revision {
+			return helm.Release{}, helm.Release{}, ErrInvalidRequest{Reason: fmt.Sprintf("release %s has no earlier revision to roll back to", resource.Name)}
+		}
+		next, err := store.Get(ctx, resource.Namespace, resource.Name, revision)
+		if err != nil {
+			return helm.Release{}, helm.Release{}, err
+		}
+		next.Description = fmt.Sprintf("Rollback to %d", revision)
+		return current, next, nil
+	case OperationHelmUpgrade:
+		values, err := valuesParam(params)
+		if err != nil {
+			return helm.Release{}, helm.Release{}, err
+		}
+		next := current
+		next.Manifest = params["manifest"]
+		if values != nil {
+			next.Values = values
+		}
+		next.Description = params["description"]
+		if next.Description == "" {
+			next.Description = "Upgrade complete"
+		}
+		return current, next, nil
+	}
+	return helm.Release{}, helm.Release{}, ErrInvalidRequest{Reason: fmt.Sprintf("%s is not a Helm operation", op)}
+}
+
+// runHelm installs the manifest of the release an upgrade or rollback records: it server-side
+// applies every object of the manifest, deletes the objects of the current revision the manifest
+// no longer has, then records the new revision. Chart hooks are not run.
+func (s *Service) runHelm(ctx context.Context, execution Execution) ([]ObjectChange, string, error) {
+	client, err := s.client(execution)
+	if err != nil {
+		return nil, "", err
+	}
+	store := helm.NewStore(client)
+	current, next, err := helmTarget(ctx, store, execution.Operation, execution.Resource, execution.Parameters)
+	if err != nil {
+		return nil, "", err
+	}
+	dynamicClient, err := s.dynamicClient(execution)
+	if err != nil {
+		return nil, "", err
+	}
+	desired, err := helm.Objects(next.Manifest, next.Namespace)
+	if err != nil {
+		return nil, "", err
+	}
+	installed, err := helm.Objects(current.Manifest, current.Namespace)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var changes []ObjectChange
+	kept := make(map[string]bool, len(desired))
+	for _, obj := range desired {
+		kept[helm.Key(obj)] = true
+		change, err := s.applyReleaseObject(ctx, execution, dynamicClient, next, obj)
+		if err != nil {
+			return changes, "", err
+		}
+		changes = append(changes, change)
+	}
+	deleted := 0
+	for _, obj := range installed {
+		if kept[helm.Key(obj)] {
+			continue
+		}
+		change, found, err := s.deleteReleaseObject(ctx, execution, dynamicClient, obj)
+		if err != nil {
+			return changes, "", err
+		}
+		if found {
+			changes = append(changes, change)
+			deleted++
+		}
+	}
+
+	recorded, err := store.Record(ctx, next)
+	if err != nil {
+		return changes, "", err
+	}
+	verb := "upgraded"
+	if execution.Operation == OperationHelmRollback {
+		verb = "rolled back"
+	}
+	return changes, fmt.Sprintf("%s release %s/%s to revision %d: %d objects applied, %d deleted", verb, recorded.Namespace, recorded.Name, recorded.Revision, len(desired), deleted), nil
+}
+
+// releaseResource returns the client for obj's resource, clearing the namespace of
+// cluster-scoped kinds, and refuses resources the resource policy does not allow.
+func (s *Service) releaseResource(execution Execution, client dynamic.Interface, obj *unstructured.Unstructured) (dynamic.ResourceInterface, error) {
+	mapping, err := s.mapping(execution.Config, execution.Cluster, obj.GroupVersionKind())
+	if err != nil {
+		return nil, err
+	}
+	if resource := mapping.Resource.GroupResource(); !s.resources.Allows(resource) {
+		return nil, ErrResourceNotAllowed{Resource: resource}
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		obj.SetNamespace("")
+		return client.Resource(mapping.Resource), nil
+	}
+	return client.Resource(mapping.Resource).Namespace(obj.GetNamespace()), nil
+}
+
+// applyReleaseObject server-side applies one object of a release, marked as belonging to the
+// release so the helm CLI keeps managing it. Conflicts are forced: the fields were last written by
+// helm's own field manager.
+func (s *Service) applyReleaseObject(ctx context.Context, execution Execution, client dynamic.Interface, release helm.Release, obj *unstructured.Unstructured) (ObjectChange, error) {
+	resource, err := s.releaseResource(execution, client, obj)
+	if err != nil {
+		return ObjectChange{}, err
+	}
+	change := ObjectChange{Resource: Resource{Kind: obj.GetKind(), Namespace: obj.GetNamespace(), Name: obj.GetName()}}
+	live, err := resource.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if err == nil {
+		change.ResourceVersionBefore = live.GetResourceVersion()
+	} else if !apierrors.IsNotFound(err) {
+		return ObjectChange{}, err
+	}
+
+	obj = obj.DeepCopy()
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels["app.kubernetes.io/managed-by"] = "Helm"
+	obj.SetLabels(labels)
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations["meta.helm.sh/release-name"] = release.Name
+	annotations["meta.helm.sh/release-namespace"] = release.Namespace
+	obj.SetAnnotations(annotations)
+	obj.SetManagedFields(nil)
+	obj.SetResourceVersion("")
+
+	applied, err := resource.Apply(ctx, obj.GetName(), obj, metav1.ApplyOptions{FieldManager: FieldManager, Force: true})
+	if err != nil {
+		return ObjectChange{}, fmt.Errorf("apply %s: %w", change.Resource, err)
+	}
+	change.ResourceVersionAfter = applied.GetResourceVersion()
+	return change, nil
+}
+
+// deleteReleaseObject deletes an object the new revision of a release no longer has, reporting
+// whether it still existed.
+func (s *Service) deleteReleaseObject(ctx context.Context, execution Execution, client dynamic.Interface, obj *unstructured.Unstructured) (ObjectChange, bool, error) {
+	resource, err := s.releaseResource(execution, client, obj)
+	if err != nil {
+		return ObjectChange{}, false, err
+	}
+	change := ObjectChange{Resource: Resource{Kind: obj.GetKind(), Namespace: obj.GetNamespace(), Name: obj.GetName()}}
+	live, err := resource.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return ObjectChange{}, false, nil
+	}
+	if err != nil {
+		return ObjectChange{}, false, err
+	}
+	change.ResourceVersionBefore = live.GetResourceVersion()
+	propagation := metav1.DeletePropagationBackground
+	if err := resource.Delete(ctx, obj.GetName(), metav1.DeleteOptions{PropagationPolicy: &propagation}); err != nil && !apierrors.IsNotFound(err) {
+		return ObjectChange{}, false, fmt.Errorf("delete %s: %w", change.Resource, err)
+	}
+	return change, true, nil
+}
@@ -0,0 +1,173 @@
+package commands
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/helm"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const (
+	settingsV1 = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\ndata:\n  mode: slow\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: legacy\ndata:\n  on: \"yes\"\n"
+	settingsV2 = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\ndata:\n  mode: fast\n"
+)
+
+// releaseSecret stores a revision of the release shop/web the way helm does.
+func releaseSecret(t *testing.T, revision int, status, manifest string) *corev1.Secret {
+	t.Helper()
+	data, err := helm.Encode(helm.Release{Name: "web", Namespace: "shop", Revision: revision, Status: status, Chart: "web", ChartVersion: "1.0.0", Manifest: manifest})
+	if err != nil {
+		t.Fatalf("encode release: %v", err)
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "sh.helm.release.v1.web.v" + strconv.Itoa(revision),
+			Namespace: "shop",
+			Labels:    map[string]string{"name": "web", "owner": "helm", "status": status, "version": strconv.Itoa(revision)},
+		},
+		Type: "helm.sh/release.v1",
+		Data: map[string][]byte{"release": data},
+	}
+}
+
+func newHelmService(t *testing.T, objects ...runtime.Object) (*Service, *fake.Clientset, *dynamicfake.FakeDynamicClient) {
+	svc, typed, _ := newTestService(
+		releaseSecret(t, 1, helm.StatusSuperseded, settingsV1),
+		releaseSecret(t, 2, helm.StatusDeployed, settingsV2),
+	)
+	allowVerbs(typed, "list", "create", "update")
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		configMapsGVR: "ConfigMapList",
+	}, objects...)
+	// The fake tracker cannot merge apply patches into unstructured objects; answer with the patch.
+	client.PrependReactor("patch", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(action.(k8stesting.PatchAction).GetPatch()); err != nil {
+			return true, nil, err
+		}
+		obj.SetResourceVersion("2")
+		return true, obj, nil
+	})
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	svc.SetDynamicClients(func(config, cluster string, subject *Subject) (dynamic.Interface, error) {
+		return client, nil
+	}, func(config, cluster string) (meta.RESTMapper, error) {
+		return mapper, nil
+	})
+	return svc, typed, client
+}
+
+func helmRequest(op Operation, params map[string]string) Request {
+	return Request{
+		UserID:     "alice",
+		Config:     "kubeconfig",
+		Cluster:    "prod",
+		Operation:  op,
+		Resource:   Resource{Namespace: "shop", Name: "web"},
+		Parameters: params,
+	}
+}
+
+func TestHelmRollbackPreviewsTheDiffAndRecordsARevisionAfterApproval(t *testing.T) {
+	svc, typed, client := newHelmService(t)
+	ctx := context.Background()
+
+	preview, err := svc.PreviewHelm(ctx, helmRequest(OperationHelmRollback, nil))
+	if err != nil {
+		t.Fatalf("preview: %v", err)
+	}
+	if preview.From != 2 || preview.To != 1 || !preview.RequiresApproval || len(preview.Diff) != 2 {
+		t.Fatalf("expected a rollback from 2 to 1 needing approval with two changed objects, got %+v", preview)
+	}
+	if preview.Diff[0].Name != "legacy" || preview.Diff[0].Action != helm.DiffAdded || preview.Diff[1].Action != helm.DiffChanged {
+		t.Fatalf("unexpected diff %+v", preview.Diff)
+	}
+	if !strings.Contains(strings.Join(preview.Diff[1].Lines, "\n"), "-  mode: fast\n+  mode: slow") {
+		t.Fatalf("expected the changed value in the line diff, got %q", preview.Diff[1].Lines)
+	}
+
+	execution, err := svc.Submit(ctx, helmRequest(OperationHelmRollback, nil))
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if execution.Status != StatusAwaitingApproval || execution.Resource.Kind != HelmReleaseKind {
+		t.Fatalf("expected the rollback to wait for approval, got %+v", execution)
+	}
+	approved, err := svc.Approve(ctx, execution.ID, "bob", nil, "")
+	if err != nil || approved.Status != StatusSucceeded || len(approved.Changes) != 2 {
+		t.Fatalf("expected the rollback to apply both objects, got %+v (%v)", approved, err)
+	}
+
+	history, err := helm.NewStore(typed).History(ctx, "shop", "web")
+	if err != nil || len(history) != 3 {
+		t.Fatalf("expected a third revision, got %+v (%v)", history, err)
+	}
+	if latest := history[2]; latest.Status != helm.StatusDeployed || latest.Description != "Rollback to 1" || latest.Manifest != settingsV1 {
+		t.Fatalf("unexpected new revision %+v", latest)
+	}
+	if history[1].Status != helm.StatusSuperseded {
+		t.Fatalf("expected the replaced revision to be superseded, got %s", history[1].Status)
+	}
+	var annotated bool
+	for _, action := range client.Actions() {
+		if patch, ok := action.(k8stesting.PatchAction); ok {
+			annotated = strings.Contains(string(patch.GetPatch()), `"meta.helm.sh/release-name":"web"`)
+		}
+	}
+	if !annotated {
+		t.Fatalf("expected applied objects to stay owned by the release")
+	}
+}
+
+func TestHelmUpgradeDeletesDroppedObjectsAndEscalatesSensitiveKinds(t *testing.T) {
+	legacy := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]any{"name": "legacy", "namespace": "shop", "resourceVersion": "7"},
+	}}
+	svc, _, client := newHelmService(t, legacy)
+	ctx := context.Background()
+
+	if level := Classify(OperationHelmUpgrade, Resource{}, map[string]string{"manifest": "apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: web\n"}); level != SafetyDangerous {
+		t.Fatalf("expected a manifest writing RBAC kinds to be dangerous, got %s", level)
+	}
+	if _, err := svc.Submit(ctx, helmRequest(OperationHelmUpgrade, map[string]string{"manifest": settingsV2, "values": "- not an object"})); err == nil {
+		t.Fatalf("expected values that are not an object to be refused")
+	}
+
+	// Revision 2 dropped legacy; rolling forward from revision 1's manifest must delete it again.
+	execution, err := svc.Submit(ctx, helmRequest(OperationHelmRollback, map[string]string{"revision": "1"}))
+	if err != nil {
+		t.Fatalf("submit rollback: %v", err)
+	}
+	if _, err := svc.Approve(ctx, execution.ID, "bob", nil, ""); err != nil {
+		t.Fatalf("approve rollback: %v", err)
+	}
+	execution, err = svc.Submit(ctx, helmRequest(OperationHelmUpgrade, map[string]string{"manifest": settingsV2, "values": "mode: fast"}))
+	if err != nil {
+		t.Fatalf("submit upgrade: %v", err)
+	}
+	approved, err := svc.Approve(ctx, execution.ID, "bob", nil, "")
+	if err != nil || approved.Status != StatusSucceeded {
+		t.Fatalf("expected the upgrade to succeed, got %+v (%v)", approved, err)
+	}
+	if !strings.Contains(approved.Result, "revision 4: 1 objects applied, 1 deleted") {
+		t.Fatalf("unexpected result %q", approved.Result)
+	}
+	if _, err := client.Resource(configMapsGVR).Namespace("shop").Get(ctx, "legacy", metav1.GetOptions{}); err == nil {
+		t.Fatalf("expected the object the upgrade dropped to be deleted")
+	}
+}
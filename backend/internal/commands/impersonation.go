@@ -0,0 +1,212 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Subject is the Kubernetes identity an execution runs as.
+type Subject struct {
+	User   string   `json:"user"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// ImpersonatingClientFunc returns a client whose requests are made on behalf of subject.
+type ImpersonatingClientFunc func(config, cluster string, subject Subject) (kubernetes.Interface, error)
+
+// PermissionCheck is the outcome of one SelfSubjectAccessReview.
+type PermissionCheck struct {
+	Verb      string `json:"verb"`
+	Group     string `json:"group,omitempty"`
+	Resource  string `json:"resource"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Allowed   bool   `json:"allowed"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// AccessReview records whether the subject may perform every request an execution makes.
+// Without impersonation the checks describe the service account's permissions instead.
+type AccessReview struct {
+	Subject      Subject           `json:"subject"`
+	Impersonated bool              `json:"impersonated"`
+	Allowed      bool              `json:"allowed"`
+	Checks       []PermissionCheck `json:"checks"`
+}
+
+type ErrAccessDenied struct {
+	Review AccessReview
+}
+
+func (e ErrAccessDenied) Error() string {
+	for _, check := range e.Review.Checks {
+		if !check.Allowed {
+			resource := check.Resource
+			if check.Group != "" {
+				resource += "." + check.Group
+			}
+			return fmt.Sprintf("%s cannot %s %s %s/%s", e.Review.Subject.User, check.Verb, resource, check.Namespace, check.Name)
+		}
+	}
+	return fmt.Sprintf("%s is not allowed to run this command", e.Review.Subject.User)
+}
+
+// kindResources maps supported kinds to their API group and resource.
+var kindResources = map[string][2]string{
+	"deployment":  {"apps", "deployments"},
+	"statefulset": {"apps", "statefulsets"},
+	"daemonset":   {"apps", "daemonsets"},
+	"pod":         {"", "pods"},
+	"configmap":   {"", "configmaps"},
+	"service":     {"", "services"},
+}
+
+// requiredVerbs lists the verbs an execution of op uses; the target is read first to snapshot it.
+func requiredVerbs(op Operation) []string {
+	switch op {
+	case OperationDelete:
+		return []string{"get", "delete"}
+	case OperationCreate:
+		return []string{"create"}
+	case OperationEdit:
+		return []string{"get", "update"}
+	case OperationGet, OperationDescribe:
+		return []string{"get"}
+	case OperationList, OperationTop:
+		return []string{"list"}
+	case OperationHelmUpgrade, OperationHelmRollback:
+		// Helm keeps each revision of a release in a secret of its own.
+		return []string{"list", "create", "update"}
+	}
+	return []string{"get", "patch"}
+}
+
+// SetImpersonation makes executions run with the requester's Kubernetes permissions instead of
+// the service account's. Submit then refuses requests the requester is not authorized for.
+func (s *Service) SetImpersonation(clients ImpersonatingClientFunc) {
+	s.impersonate = clients
+}
+
+// Impersonating reports whether executions run as the requesting user.
+func (s *Service) Impersonating() bool {
+	return s.impersonate != nil
+}
+
+// ReviewAccess asks the cluster whether each request a validated req makes is allowed, as the
+// requester when impersonation is enabled and as the service account otherwise.
+func (s *Service) ReviewAccess(ctx context.Context, req Request) (AccessReview, error) {
+	gr, err := s.accessResource(req)
+	if err != nil {
+		return AccessReview{}, err
+	}
+	client, err := s.requesterClient(req)
+	if err != nil {
+		return AccessReview{}, err
+	}
+	review, err := reviewAccess(ctx, client, Subject{User: req.UserID, Groups: req.Groups}, gr, req.Operation, accessTarget(req.Operation, req.Resource))
+	review.Impersonated = s.impersonate != nil
+	return review, err
+}
+
+// accessResource returns the API group and resource req operates on.
+func (s *Service) accessResource(req Request) ([2]string, error) {
+	if manifestOperation(req.Operation) {
+		obj, err := decodeManifest(req.Parameters)
+		if err != nil {
+			return [2]string{}, err
+		}
+		mapping, err := s.mapping(req.Config, req.Cluster, obj.GroupVersionKind())
+		if err != nil {
+			return [2]string{}, err
+		}
+		return [2]string{mapping.Resource.Group, mapping.Resource.Resource}, nil
+	}
+	if helmOperation(req.Operation) {
+		return [2]string{"", "secrets"}, nil
+	}
+	if req.Operation == OperationTop {
+		return [2]string{"metrics.k8s.io", topResource(req.Resource.Kind)}, nil
+	}
+	if readOperation(req.Operation) {
+		mapping, err := s.resourceMapping(req.Config, req.Cluster, req.Resource.Kind)
+		if err != nil {
+			return [2]string{}, err
+		}
+		return [2]string{mapping.Resource.Group, mapping.Resource.Resource}, nil
+	}
+	gr, ok := kindResources[strings.ToLower(req.Resource.Kind)]
+	if !ok {
+		return [2]string{}, ErrInvalidRequest{Reason: fmt.Sprintf("unsupported kind %q", req.Resource.Kind)}
+	}
+	return gr, nil
+}
+
+// accessTarget returns the object whose access is reviewed for op on resource. The secrets of a
+// Helm release are named after each revision, so access to them is reviewed for the namespace.
+func accessTarget(op Operation, resource Resource) Resource {
+	if helmOperation(op) {
+		return Resource{Namespace: resource.Namespace}
+	}
+	return resource
+}
+
+func reviewAccess(ctx context.Context, client kubernetes.Interface, subject Subject, gr [2]string, op Operation, resource Resource) (AccessReview, error) {
+	review := AccessReview{Subject: subject, Allowed: true}
+	for _, verb := range requiredVerbs(op) {
+		attributes := &authorizationv1.ResourceAttributes{
+			Verb:      verb,
+			Group:     gr[0],
+			Resource:  gr[1],
+			Namespace: resource.Namespace,
+			Name:      resource.Name,
+		}
+		result, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: attributes},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return AccessReview{}, fmt.Errorf("review %s access to %s: %w", verb, resource, err)
+		}
+		check := PermissionCheck{
+			Verb:      verb,
+			Group:     gr[0],
+			Resource:  gr[1],
+			Namespace: resource.Namespace,
+			Name:      resource.Name,
+			Allowed:   result.Status.Allowed,
+			Reason:    result.Status.Reason,
+		}
+		if result.Status.EvaluationError != "" && check.Reason == "" {
+			check.Reason = result.Status.EvaluationError
+		}
+		review.Allowed = review.Allowed && check.Allowed
+		review.Checks = append(review.Checks, check)
+	}
+	return review, nil
+}
+
+// requesterClient returns a client acting as req's requester when impersonation is enabled and as
+// the service account otherwise.
+func (s *Service) requesterClient(req Request) (kubernetes.Interface, error) {
+	if s.impersonate == nil {
+		return s.clients(req.Config, req.Cluster), nil
+	}
+	return s.impersonate(req.Config, req.Cluster, Subject{User: req.UserID, Groups: req.Groups})
+}
+
+// client returns the client an execution's requests are made with: the requester's identity when
+// impersonation is enabled, otherwise the service account.
+func (s *Service) client(execution Execution) (kubernetes.Interface, error) {
+	if s.impersonate == nil {
+		return s.clients(execution.Config, execution.Cluster), nil
+	}
+	subject := Subject{User: execution.UserID}
+	if execution.AccessReview != nil {
+		subject = execution.AccessReview.Subject
+	}
+	return s.impersonate(execution.Config, execution.Cluster, subject)
+}
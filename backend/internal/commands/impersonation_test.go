@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// allowVerbs makes the fake cluster answer access reviews, allowing only verbs.
+func allowVerbs(client *fake.Clientset, verbs ...string) {
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		review.Status.Allowed = slices.Contains(verbs, review.Spec.ResourceAttributes.Verb)
+		if !review.Status.Allowed {
+			review.Status.Reason = "no RBAC policy matched"
+		}
+		return true, review, nil
+	})
+}
+
+func newImpersonatingService(client *fake.Clientset) (*Service, *[]Subject) {
+	svc := NewService(NewMemoryRepository(), func(config, cluster string) kubernetes.Interface {
+		panic("service account client used while impersonating")
+	}, log.NewWithOptions(io.Discard, log.Options{}))
+	var subjects []Subject
+	svc.SetImpersonation(func(config, cluster string, subject Subject) (kubernetes.Interface, error) {
+		subjects = append(subjects, subject)
+		return client, nil
+	})
+	return svc, &subjects
+}
+
+func TestImpersonatedExecutionRunsAsRequester(t *testing.T) {
+	client := fake.NewSimpleClientset(deployment(2))
+	allowVerbs(client, "get", "patch")
+	svc, subjects := newImpersonatingService(client)
+	ctx := context.Background()
+
+	req := scaleRequest("alice", "4")
+	req.Groups = []string{"sre"}
+	execution, err := svc.Submit(ctx, req)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if execution.Status != StatusSucceeded {
+		t.Fatalf("expected success, got %+v", execution)
+	}
+	if execution.AccessReview == nil || !execution.AccessReview.Allowed || len(execution.AccessReview.Checks) != 2 {
+		t.Fatalf("expected a recorded access review, got %+v", execution.AccessReview)
+	}
+	check := execution.AccessReview.Checks[1]
+	if check.Verb != "patch" || check.Group != "apps" || check.Resource != "deployments" || check.Name != "api" {
+		t.Fatalf("unexpected permission check %+v", check)
+	}
+	for _, subject := range *subjects {
+		if subject.User != "alice" || !slices.Equal(subject.Groups, []string{"sre"}) {
+			t.Fatalf("expected requests as alice in sre, got %+v", subject)
+		}
+	}
+	got, _ := client.AppsV1().Deployments("shop").Get(ctx, "api", metav1.GetOptions{})
+	if *got.Spec.Replicas != 4 {
+		t.Fatalf("expected 4 replicas, got %d", *got.Spec.Replicas)
+	}
+
+	if _, err := svc.Rollback(ctx, execution.ID, "alice", []string{"sre"}); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	if last := (*subjects)[len(*subjects)-1]; last.User != "alice" {
+		t.Fatalf("expected rollback as alice, got %+v", last)
+	}
+}
+
+func TestRollbackRunsAsTheCallerNotTheRequester(t *testing.T) {
+	client := fake.NewSimpleClientset(deployment(2))
+	allowVerbs(client, "get", "patch")
+	svc, subjects := newImpersonatingService(client)
+	ctx := context.Background()
+
+	req := scaleRequest("alice", "4")
+	req.Groups = []string{"sre"}
+	execution, err := svc.Submit(ctx, req)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	rollback, err := svc.Rollback(ctx, execution.ID, "bob", []string{"ops"})
+	if err != nil || rollback.Status != StatusSucceeded {
+		t.Fatalf("expected bob's rollback to run, got %+v (%v)", rollback, err)
+	}
+	if rollback.UserID != "bob" || rollback.AccessReview == nil || rollback.AccessReview.Subject.User != "bob" {
+		t.Fatalf("expected the rollback to be recorded as bob's, got %+v", rollback)
+	}
+	if last := (*subjects)[len(*subjects)-1]; last.User != "bob" || !slices.Equal(last.Groups, []string{"ops"}) {
+		t.Fatalf("expected the rollback to run as bob in ops, got %+v", last)
+	}
+}
+
+func TestImpersonatedSubmitRefusesUnauthorizedRequester(t *testing.T) {
+	client := fake.NewSimpleClientset(deployment(2))
+	allowVerbs(client, "get")
+	svc, _ := newImpersonatingService(client)
+	ctx := context.Background()
+
+	_, err := svc.Submit(ctx, scaleRequest("bob", "4"))
+	var denied ErrAccessDenied
+	if !errors.As(err, &denied) {
+		t.Fatalf("expected access denied, got %v", err)
+	}
+	if denied.Review.Allowed || denied.Review.Checks[1].Allowed || denied.Review.Checks[1].Reason == "" {
+		t.Fatalf("unexpected review %+v", denied.Review)
+	}
+	if denied.Error() != "bob cannot patch deployments.apps shop/api" {
+		t.Fatalf("unexpected message %q", denied.Error())
+	}
+	got, _ := client.AppsV1().Deployments("shop").Get(ctx, "api", metav1.GetOptions{})
+	if *got.Spec.Replicas != 2 {
+		t.Fatalf("expected the deployment to be untouched, got %d replicas", *got.Spec.Replicas)
+	}
+}
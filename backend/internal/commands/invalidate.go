@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"context"
+	"slices"
+)
+
+// Invalidator is told of the namespaces a command wrote to, such as to drop cached responses
+// about them. An empty namespace stands for a cluster-scoped write.
+type Invalidator interface {
+	Invalidate(ctx context.Context, config, cluster, namespace string)
+}
+
+// SetInvalidator reports every write an execution or rollback made, whether or not it succeeded,
+// since a failed write may still have changed some objects.
+func (s *Service) SetInvalidator(invalidator Invalidator) {
+	s.invalidator = invalidator
+}
+
+// invalidate reports the namespaces of resources, and of the objects changed, to the invalidator.
+func (s *Service) invalidate(ctx context.Context, execution Execution, changes []ObjectChange) {
+	if s.invalidator == nil {
+		return
+	}
+	namespaces := []string{execution.Resource.Namespace}
+	for _, change := range changes {
+		if !slices.Contains(namespaces, change.Resource.Namespace) {
+			namespaces = append(namespaces, change.Resource.Namespace)
+		}
+	}
+	for _, namespace := range namespaces {
+		s.invalidator.Invalidate(ctx, execution.Config, execution.Cluster, namespace)
+	}
+}
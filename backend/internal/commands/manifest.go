@@ -0,0 +1,307 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+)
+
+// FieldManager identifies kubechat in the managed fields of the objects it writes.
+const FieldManager = "kubechat"
+
+// DynamicClientFunc returns a client for resources of any kind, acting as subject when it is set.
+type DynamicClientFunc func(config, cluster string, subject *Subject) (dynamic.Interface, error)
+
+// MapperFunc returns the mapper that resolves manifest kinds to API resources on a cluster.
+type MapperFunc func(config, cluster string) (meta.RESTMapper, error)
+
+// sensitiveKinds change who can do what in a cluster, so writing them is always dangerous.
+var sensitiveKinds = map[string]bool{
+	"namespace":                      true,
+	"role":                           true,
+	"rolebinding":                    true,
+	"clusterrole":                    true,
+	"clusterrolebinding":             true,
+	"serviceaccount":                 true,
+	"customresourcedefinition":       true,
+	"mutatingwebhookconfiguration":   true,
+	"validatingwebhookconfiguration": true,
+}
+
+// SetDynamicClients enables the manifest operations apply, create and edit.
+func (s *Service) SetDynamicClients(clients DynamicClientFunc, mappers MapperFunc) {
+	s.dynamic = clients
+	s.mappers = mappers
+}
+
+// ManifestSource loads the manifests users attach to chat prompts.
+type ManifestSource interface {
+	Manifest(ctx context.Context, user, id string) (string, error)
+}
+
+// SetManifestSource lets manifest operations name a chat attachment in the attachment parameter
+// instead of passing the manifest itself.
+func (s *Service) SetManifestSource(source ManifestSource) {
+	s.manifests = source
+}
+
+// inlineAttachment copies the manifest of the attachment parameter into the manifest parameter,
+// so an execution waiting for approval keeps its manifest after the attachment expires.
+func (s *Service) inlineAttachment(ctx context.Context, req Request) (Request, error) {
+	id := req.Parameters["attachment"]
+	if id == "" || !(manifestOperation(req.Operation) || req.Operation == OperationHelmUpgrade) {
+		return req, nil
+	}
+	if req.Parameters["manifest"] != "" {
+		return Request{}, ErrInvalidRequest{Reason: "pass either manifest or attachment, not both"}
+	}
+	if s.manifests == nil {
+		return Request{}, ErrInvalidRequest{Reason: "chat attachments are not enabled"}
+	}
+	manifest, err := s.manifests.Manifest(ctx, req.UserID, id)
+	if err != nil {
+		return Request{}, fmt.Errorf("load attachment %s: %w", id, err)
+	}
+	params := make(map[string]string, len(req.Parameters)+1)
+	for k, v := range req.Parameters {
+		params[k] = v
+	}
+	params["manifest"] = manifest
+	req.Parameters = params
+	return req, nil
+}
+
+// manifestOperation reports whether op takes its target from the manifest parameter.
+func manifestOperation(op Operation) bool {
+	return op == OperationApply || op == OperationCreate || op == OperationEdit
+}
+
+// decodeManifest parses the single YAML or JSON object in the manifest parameter.
+func decodeManifest(params map[string]string) (*unstructured.Unstructured, error) {
+	manifest := params["manifest"]
+	if strings.TrimSpace(manifest) == "" {
+		return nil, ErrInvalidRequest{Reason: "manifest is required"}
+	}
+	decoder := yamlutil.NewYAMLOrJSONDecoder(strings.NewReader(manifest), 4096)
+	var objects []json.RawMessage
+	for {
+		var raw json.RawMessage
+		err := decoder.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, ErrInvalidRequest{Reason: fmt.Sprintf("manifest is not valid YAML or JSON: %v", err)}
+		}
+		if len(bytes.TrimSpace(raw)) > 0 && string(raw) != "null" {
+			objects = append(objects, raw)
+		}
+	}
+	if len(objects) != 1 {
+		return nil, ErrInvalidRequest{Reason: "manifest must contain exactly one object"}
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(objects[0]); err != nil {
+		return nil, ErrInvalidRequest{Reason: fmt.Sprintf("manifest is not a Kubernetes object: %v", err)}
+	}
+	if obj.GetName() == "" {
+		return nil, ErrInvalidRequest{Reason: "manifest metadata.name is required"}
+	}
+	return obj, nil
+}
+
+// mapping resolves gvk on the cluster, refreshing cached discovery once for kinds it does not know.
+func (s *Service) mapping(config, cluster string, gvk schema.GroupVersionKind) (*meta.RESTMapping, error) {
+	return s.resolveMapping(config, cluster, gvk.String(), func(mapper meta.RESTMapper) (*meta.RESTMapping, error) {
+		return mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	})
+}
+
+// resolveMapping runs resolve against the cluster's mapper, resetting the mapper and retrying
+// once when discovery does not know what resolve asks for.
+func (s *Service) resolveMapping(config, cluster, what string, resolve func(meta.RESTMapper) (*meta.RESTMapping, error)) (*meta.RESTMapping, error) {
+	mapper, err := s.mappers(config, cluster)
+	if err != nil {
+		return nil, err
+	}
+	mapping, err := resolve(mapper)
+	if meta.IsNoMatchError(err) {
+		if resettable, ok := mapper.(meta.ResettableRESTMapper); ok {
+			resettable.Reset()
+			mapping, err = resolve(mapper)
+		}
+	}
+	if meta.IsNoMatchError(err) {
+		return nil, ErrInvalidRequest{Reason: fmt.Sprintf("cluster does not serve %s", what)}
+	}
+	if meta.IsAmbiguousError(err) {
+		return nil, ErrInvalidRequest{Reason: fmt.Sprintf("%s is ambiguous; qualify it with its API group", what)}
+	}
+	return mapping, err
+}
+
+// resolveManifest sets the request's resource from its manifest, defaulting the namespace of
+// namespaced kinds, and rejects manifests that contradict a resource given explicitly.
+func (s *Service) resolveManifest(req Request) (Request, error) {
+	if s.mappers == nil || s.dynamic == nil {
+		return Request{}, ErrInvalidRequest{Reason: fmt.Sprintf("%s is not enabled", req.Operation)}
+	}
+	obj, err := decodeManifest(req.Parameters)
+	if err != nil {
+		return Request{}, err
+	}
+	if req.Resource.Kind != "" && !strings.EqualFold(req.Resource.Kind, obj.GetKind()) {
+		return Request{}, ErrInvalidRequest{Reason: fmt.Sprintf("manifest kind %s does not match %s", obj.GetKind(), req.Resource.Kind)}
+	}
+	if req.Resource.Name != "" && req.Resource.Name != obj.GetName() {
+		return Request{}, ErrInvalidRequest{Reason: fmt.Sprintf("manifest name %s does not match %s", obj.GetName(), req.Resource.Name)}
+	}
+	mapping, err := s.mapping(req.Config, req.Cluster, obj.GroupVersionKind())
+	if err != nil {
+		return Request{}, err
+	}
+
+	namespace := obj.GetNamespace()
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		if namespace == "" {
+			namespace = req.Resource.Namespace
+		}
+		if namespace == "" {
+			namespace = metav1.NamespaceDefault
+		}
+		if req.Resource.Namespace != "" && req.Resource.Namespace != namespace {
+			return Request{}, ErrInvalidRequest{Reason: fmt.Sprintf("manifest namespace %s does not match %s", namespace, req.Resource.Namespace)}
+		}
+	} else if namespace != "" || req.Resource.Namespace != "" {
+		return Request{}, ErrInvalidRequest{Reason: fmt.Sprintf("%s is not namespaced", obj.GetKind())}
+	}
+	req.Resource = Resource{Kind: obj.GetKind(), Namespace: namespace, Name: obj.GetName()}
+	return req, nil
+}
+
+// dynamicClient returns the dynamic client for an execution, acting as the requester when
+// impersonation is enabled.
+func (s *Service) dynamicClient(execution Execution) (dynamic.Interface, error) {
+	if s.impersonate == nil {
+		return s.dynamic(execution.Config, execution.Cluster, nil)
+	}
+	subject := Subject{User: execution.UserID}
+	if execution.AccessReview != nil {
+		subject = execution.AccessReview.Subject
+	}
+	return s.dynamic(execution.Config, execution.Cluster, &subject)
+}
+
+// manifestTarget is the object an execution's manifest writes, the client for its resource and
+// the live object, which is nil when it does not exist yet.
+type manifestTarget struct {
+	mapping  *meta.RESTMapping
+	resource dynamic.ResourceInterface
+	object   *unstructured.Unstructured
+	current  *unstructured.Unstructured
+}
+
+func (s *Service) manifestTarget(ctx context.Context, execution Execution) (manifestTarget, error) {
+	obj, err := decodeManifest(execution.Parameters)
+	if err != nil {
+		return manifestTarget{}, err
+	}
+	mapping, err := s.mapping(execution.Config, execution.Cluster, obj.GroupVersionKind())
+	if err != nil {
+		return manifestTarget{}, err
+	}
+	client, err := s.dynamicClient(execution)
+	if err != nil {
+		return manifestTarget{}, err
+	}
+	var resource dynamic.ResourceInterface = client.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		obj.SetNamespace(execution.Resource.Namespace)
+		resource = client.Resource(mapping.Resource).Namespace(execution.Resource.Namespace)
+	}
+
+	current, err := resource.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		current = nil
+	} else if err != nil {
+		return manifestTarget{}, err
+	}
+	return manifestTarget{mapping: mapping, resource: resource, object: obj, current: current}, nil
+}
+
+// write creates, applies or updates the manifest and returns the object as written. With dryRun
+// the API server validates and returns the result without persisting it.
+func (t manifestTarget) write(ctx context.Context, op Operation, force, dryRun bool) (*unstructured.Unstructured, error) {
+	obj := t.object.DeepCopy()
+	obj.SetManagedFields(nil)
+	switch op {
+	case OperationCreate:
+		return t.resource.Create(ctx, obj, metav1.CreateOptions{FieldManager: FieldManager, DryRun: dryRunOption(dryRun)})
+	case OperationApply:
+		obj.SetResourceVersion("")
+		return t.resource.Apply(ctx, obj.GetName(), obj, metav1.ApplyOptions{FieldManager: FieldManager, Force: force, DryRun: dryRunOption(dryRun)})
+	case OperationEdit:
+		if t.current == nil {
+			return nil, apierrors.NewNotFound(t.mapping.Resource.GroupResource(), obj.GetName())
+		}
+		// Without a resourceVersion in the manifest the edit replaces whatever is live.
+		if obj.GetResourceVersion() == "" {
+			obj.SetResourceVersion(t.current.GetResourceVersion())
+		}
+		return t.resource.Update(ctx, obj, metav1.UpdateOptions{FieldManager: FieldManager, DryRun: dryRunOption(dryRun)})
+	}
+	return nil, ErrInvalidRequest{Reason: fmt.Sprintf("unsupported operation %q", op)}
+}
+
+// applyManifest writes the execution's manifest with server-side apply, create or a full update
+// and returns the steps that undo it when the kind supports rollback.
+func (s *Service) applyManifest(ctx context.Context, execution Execution) ([]RollbackStep, []ObjectChange, error) {
+	target, err := s.manifestTarget(ctx, execution)
+	if err != nil {
+		return nil, nil, err
+	}
+	rollback, err := manifestRollback(execution.Resource, target.mapping.Resource.GroupResource(), target.current)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	change := ObjectChange{Resource: execution.Resource}
+	if target.current != nil {
+		change.ResourceVersionBefore = target.current.GetResourceVersion()
+	}
+	result, err := target.write(ctx, execution.Operation, execution.Parameters["force"] == "true", false)
+	if err != nil {
+		return nil, nil, err
+	}
+	change.ResourceVersionAfter = result.GetResourceVersion()
+	return rollback, []ObjectChange{change}, nil
+}
+
+// manifestRollback snapshots current, or plans removal when the object does not exist yet.
+// Rollback restores through the typed clients, so other kinds get no plan.
+func manifestRollback(target Resource, gr schema.GroupResource, current *unstructured.Unstructured) ([]RollbackStep, error) {
+	known, ok := kindResources[strings.ToLower(target.Kind)]
+	if !ok || known != [2]string{gr.Group, gr.Resource} {
+		return nil, nil
+	}
+	if current == nil {
+		return []RollbackStep{{Action: RollbackRemove, Resource: target}}, nil
+	}
+	raw, err := current.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	return []RollbackStep{{Action: RollbackRestore, Resource: target, Snapshot: raw}}, nil
+}
@@ -0,0 +1,205 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var (
+	configMapsGVR  = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	deploymentsGVR = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+)
+
+func newManifestService(objects ...runtime.Object) (*Service, *dynamicfake.FakeDynamicClient) {
+	svc, typed, _ := newTestService()
+	allowVerbs(typed, "get", "create", "update", "patch")
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		configMapsGVR:  "ConfigMapList",
+		deploymentsGVR: "DeploymentList",
+	}, objects...)
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}, meta.RESTScopeRoot)
+	svc.SetDynamicClients(func(config, cluster string, subject *Subject) (dynamic.Interface, error) {
+		return client, nil
+	}, func(config, cluster string) (meta.RESTMapper, error) {
+		return mapper, nil
+	})
+	return svc, client
+}
+
+func manifestRequest(op Operation, manifest string) Request {
+	return Request{
+		UserID:     "alice",
+		Config:     "kubeconfig",
+		Cluster:    "prod",
+		Operation:  op,
+		Parameters: map[string]string{"manifest": manifest},
+	}
+}
+
+func TestCreateFromManifestAfterApproval(t *testing.T) {
+	svc, client := newManifestService()
+	ctx := context.Background()
+
+	execution, err := svc.Submit(ctx, manifestRequest(OperationCreate, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\ndata:\n  mode: fast\n"))
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if execution.Status != StatusAwaitingApproval || execution.SafetyLevel != SafetyWarning {
+		t.Fatalf("expected create to wait for approval, got %+v", execution)
+	}
+	if execution.Resource != (Resource{Kind: "ConfigMap", Namespace: "default", Name: "settings"}) {
+		t.Fatalf("expected the resource to come from the manifest, got %+v", execution.Resource)
+	}
+
+	approved, err := svc.Approve(ctx, execution.ID, "bob", nil, "")
+	if err != nil || approved.Status != StatusSucceeded {
+		t.Fatalf("expected success after approval, got %+v (%v)", approved, err)
+	}
+	created, err := client.Resource(configMapsGVR).Namespace("default").Get(ctx, "settings", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the config map to exist: %v", err)
+	}
+	if mode, _, _ := unstructured.NestedString(created.Object, "data", "mode"); mode != "fast" {
+		t.Fatalf("unexpected data %v", created.Object["data"])
+	}
+	plan, err := svc.repo.GetRollbackPlan(ctx, execution.ID)
+	if err != nil || len(plan.Steps) != 1 || plan.Steps[0].Action != RollbackRemove {
+		t.Fatalf("expected a remove step, got %+v (%v)", plan, err)
+	}
+}
+
+func TestForcedApplyIsDangerousAndServerSide(t *testing.T) {
+	live := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]any{"name": "api", "namespace": "shop"},
+		"spec":       map[string]any{"replicas": int64(2)},
+	}}
+	svc, client := newManifestService(live)
+	// The fake tracker cannot merge apply patches into unstructured objects; answer with the live object.
+	client.PrependReactor("patch", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, live, nil
+	})
+	ctx := context.Background()
+
+	req := manifestRequest(OperationApply, `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"api","namespace":"shop"},"spec":{"replicas":4}}`)
+	req.Parameters["force"] = "true"
+	execution, err := svc.Submit(ctx, req)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if execution.SafetyLevel != SafetyDangerous {
+		t.Fatalf("expected forced apply to be dangerous, got %s", execution.SafetyLevel)
+	}
+	if approved, err := svc.Approve(ctx, execution.ID, "bob", nil, ""); err != nil || approved.Status != StatusSucceeded {
+		t.Fatalf("expected success after approval, got %+v (%v)", approved, err)
+	}
+
+	var applied bool
+	for _, action := range client.Actions() {
+		if patch, ok := action.(k8stesting.PatchActionImpl); ok && patch.GetPatchType() == types.ApplyPatchType {
+			applied = strings.Contains(string(patch.GetPatch()), `"replicas":4`)
+		}
+	}
+	if !applied {
+		t.Fatalf("expected a server-side apply of the manifest, got %v", client.Actions())
+	}
+	plan, err := svc.repo.GetRollbackPlan(ctx, execution.ID)
+	if err != nil || plan.Steps[0].Action != RollbackRestore {
+		t.Fatalf("expected a restore step, got %+v (%v)", plan, err)
+	}
+}
+
+func TestManifestRequestsAreValidated(t *testing.T) {
+	svc, _ := newManifestService()
+	ctx := context.Background()
+	configMap := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\n  namespace: shop\n"
+
+	twoObjects := manifestRequest(OperationApply, configMap+"---\n"+configMap)
+	mismatched := manifestRequest(OperationApply, configMap)
+	mismatched.Resource = Resource{Kind: "ConfigMap", Namespace: "other", Name: "settings"}
+	unknown := manifestRequest(OperationCreate, "apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: w\n")
+	for name, req := range map[string]Request{"two objects": twoObjects, "namespace mismatch": mismatched, "unknown kind": unknown} {
+		var invalid ErrInvalidRequest
+		if _, err := svc.Submit(ctx, req); !errors.As(err, &invalid) {
+			t.Fatalf("%s: expected invalid request, got %v", name, err)
+		}
+	}
+
+	preview, err := svc.Preview(ctx, manifestRequest(OperationEdit, "apiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRole\nmetadata:\n  name: viewer\n"))
+	if err != nil {
+		t.Fatalf("preview: %v", err)
+	}
+	if preview.SafetyLevel != SafetyDangerous || preview.Resource.Namespace != "" {
+		t.Fatalf("expected a dangerous cluster-scoped edit, got %+v", preview)
+	}
+	if want := "kubectl --context prod replace --field-manager=kubechat -f -"; preview.Kubectl != want {
+		t.Fatalf("expected %q, got %q", want, preview.Kubectl)
+	}
+	if check := preview.Access.Checks[1]; check.Verb != "update" || check.Resource != "clusterroles" || check.Group != "rbac.authorization.k8s.io" {
+		t.Fatalf("unexpected permission check %+v", check)
+	}
+}
+
+type attachedManifests map[string]string
+
+func (a attachedManifests) Manifest(ctx context.Context, user, id string) (string, error) {
+	manifest, ok := a[user+"/"+id]
+	if !ok {
+		return "", errors.New("attachment not found")
+	}
+	return manifest, nil
+}
+
+func TestManifestFromChatAttachment(t *testing.T) {
+	svc, _ := newManifestService()
+	ctx := context.Background()
+	req := Request{
+		UserID:     "alice",
+		Config:     "kubeconfig",
+		Cluster:    "prod",
+		Operation:  OperationCreate,
+		Parameters: map[string]string{"attachment": "a1"},
+	}
+	var invalid ErrInvalidRequest
+	if _, err := svc.Submit(ctx, req); !errors.As(err, &invalid) {
+		t.Fatalf("expected attachments to need a manifest source, got %v", err)
+	}
+
+	svc.SetManifestSource(attachedManifests{"alice/a1": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\n"})
+	execution, err := svc.Submit(ctx, req)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if execution.Resource.Name != "settings" || !strings.Contains(execution.Parameters["manifest"], "kind: ConfigMap") {
+		t.Fatalf("expected the attached manifest to be inlined, got %+v", execution)
+	}
+	if _, ok := req.Parameters["manifest"]; ok {
+		t.Fatalf("expected the caller's parameters to be left alone")
+	}
+
+	req.UserID = "mallory"
+	if _, err := svc.Submit(ctx, req); err == nil {
+		t.Fatalf("expected another user's attachment to be refused")
+	}
+	req.UserID = "alice"
+	req.Parameters = map[string]string{"attachment": "a1", "manifest": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: other\n"}
+	if _, err := svc.Submit(ctx, req); !errors.As(err, &invalid) {
+		t.Fatalf("expected manifest and attachment together to be rejected, got %v", err)
+	}
+}
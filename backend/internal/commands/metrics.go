@@ -0,0 +1,21 @@
+package commands
+
+import "time"
+
+// Metrics records finished executions for monitoring, such as Prometheus collectors.
+type Metrics interface {
+	ObserveExecution(operation, status string, d time.Duration)
+}
+
+// SetMetrics reports the duration and final status of every execution that runs to metrics. A
+// plan is reported once as a whole and once per step.
+func (s *Service) SetMetrics(metrics Metrics) {
+	s.metrics = metrics
+}
+
+func (s *Service) observeExecution(execution Execution) {
+	if s.metrics == nil || execution.StartedAt == nil || execution.CompletedAt == nil {
+		return
+	}
+	s.metrics.ObserveExecution(string(execution.Operation), string(execution.Status), execution.CompletedAt.Sub(*execution.StartedAt))
+}
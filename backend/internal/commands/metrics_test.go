@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+type recordedMetrics []string
+
+func (m *recordedMetrics) ObserveExecution(operation, status string, d time.Duration) {
+	*m = append(*m, operation+" "+status)
+}
+
+func TestServiceReportsFinishedExecutions(t *testing.T) {
+	svc, _, _ := newTestService(deployment(2))
+	metrics := &recordedMetrics{}
+	svc.SetMetrics(metrics)
+	ctx := context.Background()
+
+	if _, err := svc.Submit(ctx, scaleRequest("alice", "3")); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	missing := scaleRequest("alice", "3")
+	missing.Resource.Name = "gone"
+	if _, err := svc.Submit(ctx, missing); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+
+	if want := (recordedMetrics{"scale succeeded", "scale failed"}); !slices.Equal(*metrics, want) {
+		t.Fatalf("expected %v, got %v", want, *metrics)
+	}
+}
@@ -0,0 +1,44 @@
+CREATE TABLE IF NOT EXISTS kubernetes_command_executions (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    config TEXT NOT NULL,
+    cluster TEXT NOT NULL,
+    operation TEXT NOT NULL,
+    resource_kind TEXT NOT NULL,
+    resource_namespace TEXT NOT NULL DEFAULT '',
+    resource_name TEXT NOT NULL,
+    parameters JSONB NOT NULL DEFAULT '{}'::jsonb,
+    safety_level TEXT NOT NULL,
+    status TEXT NOT NULL,
+    result TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_command_executions_user_created
+    ON kubernetes_command_executions (user_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS command_approvals (
+    id TEXT PRIMARY KEY,
+    execution_id TEXT NOT NULL UNIQUE REFERENCES kubernetes_command_executions (id) ON DELETE CASCADE,
+    requested_by TEXT NOT NULL,
+    decided_by TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    decided_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_command_approvals_status
+    ON command_approvals (status, created_at);
+
+CREATE TABLE IF NOT EXISTS rollback_plans (
+    id TEXT PRIMARY KEY,
+    execution_id TEXT NOT NULL UNIQUE REFERENCES kubernetes_command_executions (id) ON DELETE CASCADE,
+    steps JSONB NOT NULL,
+    executed BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL,
+    executed_at TIMESTAMPTZ
+);
@@ -0,0 +1,20 @@
+ALTER TABLE command_approvals
+    ADD COLUMN IF NOT EXISTS policy_name TEXT NOT NULL DEFAULT 'default',
+    ADD COLUMN IF NOT EXISTS required_approvals INTEGER NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS approver_groups JSONB NOT NULL DEFAULT '[]'::jsonb,
+    ADD COLUMN IF NOT EXISTS decisions JSONB NOT NULL DEFAULT '[]'::jsonb,
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS escalate_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS escalated_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS command_approval_events (
+    id TEXT PRIMARY KEY,
+    execution_id TEXT NOT NULL REFERENCES kubernetes_command_executions (id) ON DELETE CASCADE,
+    actor TEXT NOT NULL,
+    action TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_command_approval_events_execution
+    ON command_approval_events (execution_id, at);
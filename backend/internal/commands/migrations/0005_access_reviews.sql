@@ -0,0 +1,2 @@
+ALTER TABLE kubernetes_command_executions
+    ADD COLUMN IF NOT EXISTS access_review JSONB;
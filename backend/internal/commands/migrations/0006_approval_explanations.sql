@@ -0,0 +1,2 @@
+ALTER TABLE command_approvals
+    ADD COLUMN IF NOT EXISTS explanation JSONB;
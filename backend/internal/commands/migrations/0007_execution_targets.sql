@@ -0,0 +1,23 @@
+ALTER TABLE kubernetes_command_executions
+    ADD COLUMN IF NOT EXISTS target_cluster TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS target_namespace TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS target_kind TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS target_name TEXT NOT NULL DEFAULT '';
+
+-- Index existing executions by their recorded resource, normalizing the kind the way
+-- NormalizeKind does: lower case, without the API group, singular.
+UPDATE kubernetes_command_executions AS e SET
+    target_cluster = e.cluster,
+    target_namespace = e.resource_namespace,
+    target_name = e.resource_name,
+    target_kind = CASE
+        WHEN k.kind ~ 'ies$' THEN regexp_replace(k.kind, 'ies$', 'y')
+        WHEN k.kind ~ '(ss|x|ch|sh)es$' THEN regexp_replace(k.kind, 'es$', '')
+        WHEN k.kind ~ '[^s]s$' THEN regexp_replace(k.kind, 's$', '')
+        ELSE k.kind
+    END
+FROM (SELECT id, lower(split_part(resource_kind, '.', 1)) AS kind FROM kubernetes_command_executions) AS k
+WHERE k.id = e.id AND e.target_kind = '';
+
+CREATE INDEX IF NOT EXISTS idx_command_executions_target
+    ON kubernetes_command_executions (target_kind, target_namespace, target_name, target_cluster, created_at DESC);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS command_read_snapshots (
+    signature TEXT PRIMARY KEY,
+    execution_id TEXT NOT NULL,
+    objects JSONB NOT NULL,
+    taken_at TIMESTAMPTZ NOT NULL
+);
+
+ALTER TABLE kubernetes_command_executions
+    ADD COLUMN IF NOT EXISTS result_diff JSONB;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS approval_delegations (
+    id TEXT PRIMARY KEY,
+    delegator TEXT NOT NULL,
+    delegate TEXT NOT NULL,
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_approval_delegations_delegate
+    ON approval_delegations (delegate, starts_at);
+
+ALTER TABLE command_approval_events
+    ADD COLUMN IF NOT EXISTS on_behalf_of TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS command_schedules (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    request JSONB NOT NULL,
+    groups JSONB NOT NULL DEFAULT '[]'::jsonb,
+    workspace TEXT NOT NULL DEFAULT '',
+    run_at TIMESTAMPTZ,
+    cron TEXT NOT NULL DEFAULT '',
+    timezone TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    next_run_at TIMESTAMPTZ,
+    last_run_at TIMESTAMPTZ,
+    last_execution_id TEXT NOT NULL DEFAULT '',
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    cancelled_at TIMESTAMPTZ,
+    cancelled_by TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_command_schedules_user_created
+    ON command_schedules (user_id, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_command_schedules_due
+    ON command_schedules (next_run_at) WHERE status = 'active';
@@ -0,0 +1,11 @@
+ALTER TABLE kubernetes_command_executions
+    ADD COLUMN IF NOT EXISTS correlation_id TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS changes JSONB;
+
+UPDATE kubernetes_command_executions SET correlation_id = id WHERE correlation_id = '';
+
+CREATE INDEX IF NOT EXISTS idx_command_executions_correlation
+    ON kubernetes_command_executions (correlation_id, created_at);
+
+ALTER TABLE rollback_plans
+    ADD COLUMN IF NOT EXISTS changes JSONB NOT NULL DEFAULT '[]'::jsonb;
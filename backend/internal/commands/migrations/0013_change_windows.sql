@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS change_windows (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    cluster TEXT NOT NULL DEFAULT '',
+    namespace TEXT NOT NULL DEFAULT '',
+    starts_at TIMESTAMPTZ,
+    ends_at TIMESTAMPTZ,
+    cron TEXT NOT NULL DEFAULT '',
+    duration_seconds BIGINT NOT NULL DEFAULT 0,
+    timezone TEXT NOT NULL DEFAULT '',
+    mode TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_by TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+ALTER TABLE kubernetes_command_executions
+    ADD COLUMN IF NOT EXISTS queued_until TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS freeze_override JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_kubernetes_command_executions_queued
+    ON kubernetes_command_executions (created_at) WHERE status = 'queued';
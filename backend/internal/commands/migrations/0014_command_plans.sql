@@ -0,0 +1,6 @@
+ALTER TABLE kubernetes_command_executions
+    ADD COLUMN IF NOT EXISTS steps JSONB,
+    ADD COLUMN IF NOT EXISTS plan_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_kubernetes_command_executions_plan
+    ON kubernetes_command_executions (plan_id) WHERE plan_id <> '';
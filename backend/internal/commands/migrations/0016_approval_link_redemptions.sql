@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS approval_link_redemptions (
+    nonce TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS approval_link_redemptions_expires_idx ON approval_link_redemptions (expires_at);
@@ -0,0 +1,2 @@
+ALTER TABLE command_approvals
+    ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 0;
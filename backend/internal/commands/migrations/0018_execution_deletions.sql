@@ -0,0 +1,3 @@
+ALTER TABLE kubernetes_command_executions
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS deleted_by TEXT NOT NULL DEFAULT '';
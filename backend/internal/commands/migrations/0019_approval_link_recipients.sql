@@ -0,0 +1,12 @@
+-- Approval links are now issued per recipient, and the links signed before carry no recipient
+-- and no longer verify, so their redemptions are dropped with the nonce key.
+DROP TABLE IF EXISTS approval_link_redemptions;
+
+CREATE TABLE approval_link_redemptions (
+    execution_id TEXT NOT NULL,
+    recipient TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (execution_id, recipient)
+);
+
+CREATE INDEX IF NOT EXISTS approval_link_redemptions_expires_idx ON approval_link_redemptions (expires_at);
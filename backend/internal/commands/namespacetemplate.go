@@ -0,0 +1,220 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// NamespaceTemplate is applied to namespaces created through kubechat, so they follow platform
+// standards. Labels and annotations are merged into the namespace; Resources, such as quotas,
+// limit ranges, network policies and role bindings, are created inside it.
+type NamespaceTemplate struct {
+	// Workspace selects the requests the template applies to; "*" applies to workspaces without
+	// a template of their own.
+	Workspace   string            `json:"workspace"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Resources   []map[string]any  `json:"resources,omitempty"`
+}
+
+// NamespaceTemplates holds one template per workspace.
+type NamespaceTemplates struct {
+	Templates []NamespaceTemplate `json:"templates"`
+}
+
+// templateKinds are the namespaced kinds a template may create.
+var templateKinds = map[string]bool{
+	"resourcequota":  true,
+	"limitrange":     true,
+	"networkpolicy":  true,
+	"role":           true,
+	"rolebinding":    true,
+	"serviceaccount": true,
+	"configmap":      true,
+}
+
+// Namespace requests record the workspace whose template they follow, and the template resources
+// as they were when the namespace was requested, so an approved execution creates what its
+// approvers saw.
+const (
+	anyWorkspace       = "*"
+	provisionParameter = "provision"
+	templateParameter  = "namespaceTemplate"
+)
+
+// LoadNamespaceTemplates reads and validates the namespace templates in a YAML file.
+func LoadNamespaceTemplates(path string) (*NamespaceTemplates, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var templates NamespaceTemplates
+	if err := yaml.UnmarshalStrict(raw, &templates); err != nil {
+		return nil, fmt.Errorf("parse namespace templates %s: %w", path, err)
+	}
+	if err := templates.Validate(); err != nil {
+		return nil, err
+	}
+	return &templates, nil
+}
+
+// Validate rejects templates without a workspace, duplicate workspaces and resources a template
+// may not create.
+func (t *NamespaceTemplates) Validate() error {
+	seen := make(map[string]bool)
+	for i, template := range t.Templates {
+		name := template.Workspace
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("namespace template %d has no workspace", i+1)
+		}
+		if seen[name] {
+			return fmt.Errorf("namespace template for %s is defined twice", name)
+		}
+		seen[name] = true
+		for i, resource := range template.Resources {
+			obj := unstructured.Unstructured{Object: resource}
+			switch {
+			case obj.GetAPIVersion() == "" || obj.GetKind() == "" || obj.GetName() == "":
+				return fmt.Errorf("namespace template for %s: resource %d needs apiVersion, kind and metadata.name", name, i+1)
+			case !templateKinds[strings.ToLower(obj.GetKind())]:
+				return fmt.Errorf("namespace template for %s: %s cannot be provisioned", name, obj.GetKind())
+			case obj.GetNamespace() != "":
+				return fmt.Errorf("namespace template for %s: %s/%s must not set a namespace", name, obj.GetKind(), obj.GetName())
+			}
+		}
+	}
+	return nil
+}
+
+// For returns the template of workspace, falling back to the "*" template.
+func (t *NamespaceTemplates) For(workspace string) (NamespaceTemplate, bool) {
+	if t == nil {
+		return NamespaceTemplate{}, false
+	}
+	var fallback *NamespaceTemplate
+	for i, template := range t.Templates {
+		if template.Workspace == workspace {
+			return template, true
+		}
+		if template.Workspace == anyWorkspace {
+			fallback = &t.Templates[i]
+		}
+	}
+	if fallback == nil {
+		return NamespaceTemplate{}, false
+	}
+	return *fallback, true
+}
+
+// SetNamespaceTemplates applies templates to namespaces created or applied through manifests.
+func (s *Service) SetNamespaceTemplates(templates *NamespaceTemplates) {
+	s.templates = templates
+}
+
+// NamespaceTemplate returns the template applied to namespaces created from workspace.
+func (s *Service) NamespaceTemplate(workspace string) (NamespaceTemplate, bool) {
+	return s.templates.For(workspace)
+}
+
+// applyNamespaceTemplate merges the workspace's template into a namespace manifest and records
+// the resources to provision in it. Other requests are returned unchanged.
+func (s *Service) applyNamespaceTemplate(req Request) (Request, error) {
+	if (req.Operation != OperationCreate && req.Operation != OperationApply) || NormalizeKind(req.Resource.Kind) != "namespace" {
+		return req, nil
+	}
+	template, ok := s.templates.For(req.Workspace)
+	if !ok {
+		return req, nil
+	}
+	obj, err := decodeManifest(req.Parameters)
+	if err != nil {
+		return Request{}, err
+	}
+	// The template wins over the manifest so chat-created namespaces cannot opt out of standards.
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	maps.Copy(labels, template.Labels)
+	obj.SetLabels(labels)
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	maps.Copy(annotations, template.Annotations)
+	obj.SetAnnotations(annotations)
+	manifest, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return Request{}, err
+	}
+
+	params := maps.Clone(req.Parameters)
+	params["manifest"] = string(manifest)
+	params[templateParameter] = template.Workspace
+	if len(template.Resources) > 0 {
+		resources, err := json.Marshal(template.Resources)
+		if err != nil {
+			return Request{}, err
+		}
+		params[provisionParameter] = string(resources)
+	}
+	req.Parameters = params
+	return req, nil
+}
+
+// provisioned lists the resources an execution provisions in the namespace it creates.
+func provisioned(execution Execution) ([]unstructured.Unstructured, error) {
+	raw := execution.Parameters[provisionParameter]
+	if raw == "" {
+		return nil, nil
+	}
+	var objects []map[string]any
+	if err := json.Unmarshal([]byte(raw), &objects); err != nil {
+		return nil, ErrInvalidRequest{Reason: fmt.Sprintf("provisioned resources are not valid: %v", err)}
+	}
+	out := make([]unstructured.Unstructured, 0, len(objects))
+	for _, object := range objects {
+		obj := unstructured.Unstructured{Object: object}
+		obj.SetNamespace(execution.Resource.Name)
+		out = append(out, obj)
+	}
+	return out, nil
+}
+
+// provision server-side applies the template resources into the namespace an execution created.
+func (s *Service) provision(ctx context.Context, execution Execution) ([]Resource, error) {
+	objects, err := provisioned(execution)
+	if err != nil || len(objects) == 0 {
+		return nil, err
+	}
+	client, err := s.dynamicClient(execution)
+	if err != nil {
+		return nil, err
+	}
+	var done []Resource
+	for _, obj := range objects {
+		mapping, err := s.mapping(execution.Config, execution.Cluster, obj.GroupVersionKind())
+		if err != nil {
+			return done, err
+		}
+		if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+			return done, ErrInvalidRequest{Reason: fmt.Sprintf("%s is not namespaced", obj.GetKind())}
+		}
+		_, err = client.Resource(mapping.Resource).Namespace(obj.GetNamespace()).
+			Apply(ctx, obj.GetName(), &obj, metav1.ApplyOptions{FieldManager: FieldManager, Force: true})
+		if err != nil {
+			return done, fmt.Errorf("provision %s/%s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		done = append(done, Resource{Kind: obj.GetKind(), Namespace: obj.GetNamespace(), Name: obj.GetName()})
+	}
+	return done, nil
+}
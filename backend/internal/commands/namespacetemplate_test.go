@@ -0,0 +1,143 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testNamespaceTemplates = `
+templates:
+- workspace: payments
+  labels:
+    team: payments
+    pod-security.kubernetes.io/enforce: restricted
+  resources:
+  - apiVersion: v1
+    kind: ResourceQuota
+    metadata:
+      name: compute
+    spec:
+      hard:
+        requests.cpu: "8"
+  - apiVersion: networking.k8s.io/v1
+    kind: NetworkPolicy
+    metadata:
+      name: default-deny
+    spec:
+      podSelector: {}
+- workspace: "*"
+  labels:
+    provisioned-by: kubechat
+`
+
+func TestNamespaceTemplateProvisionsNewNamespaces(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "templates.yaml")
+	if err := os.WriteFile(path, []byte(testNamespaceTemplates), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	templates, err := LoadNamespaceTemplates(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	svc, typed, _ := newTestService()
+	allowVerbs(typed, "get", "create", "patch")
+	svc.SetNamespaceTemplates(templates)
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	// The fake tracker cannot merge apply patches into unstructured objects.
+	client.PrependReactor("patch", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &unstructured.Unstructured{Object: map[string]any{}}, nil
+	})
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ResourceQuota"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "NetworkPolicy"}, meta.RESTScopeNamespace)
+	svc.SetDynamicClients(func(config, cluster string, subject *Subject) (dynamic.Interface, error) {
+		return client, nil
+	}, func(config, cluster string) (meta.RESTMapper, error) {
+		return mapper, nil
+	})
+	ctx := context.Background()
+
+	req := manifestRequest(OperationCreate, "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: checkout\n  labels:\n    team: someone-else\n")
+	req.Workspace = "payments"
+	preview, err := svc.Preview(ctx, req)
+	if err != nil {
+		t.Fatalf("preview: %v", err)
+	}
+	want := []Resource{{Kind: "ResourceQuota", Namespace: "checkout", Name: "compute"}, {Kind: "NetworkPolicy", Namespace: "checkout", Name: "default-deny"}}
+	if !slices.Equal(preview.Provisioned, want) {
+		t.Fatalf("expected the preview to list %v, got %v", want, preview.Provisioned)
+	}
+
+	execution, err := svc.Submit(ctx, req)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if execution.Parameters[templateParameter] != "payments" {
+		t.Fatalf("expected the execution to record its template, got %v", execution.Parameters)
+	}
+	approved, err := svc.Approve(ctx, execution.ID, "bob", nil, "")
+	if err != nil || approved.Status != StatusSucceeded {
+		t.Fatalf("expected success after approval, got %+v (%v)", approved, err)
+	}
+	namespace, err := client.Resource(schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}).Get(ctx, "checkout", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the namespace to exist: %v", err)
+	}
+	if labels := namespace.GetLabels(); labels["team"] != "payments" || labels["pod-security.kubernetes.io/enforce"] != "restricted" {
+		t.Fatalf("expected the template labels to win, got %v", labels)
+	}
+	var applied []string
+	for _, action := range client.Actions() {
+		if patch, ok := action.(k8stesting.PatchActionImpl); ok {
+			applied = append(applied, patch.GetResource().Resource+"/"+patch.GetNamespace()+"/"+patch.GetName())
+		}
+	}
+	if !slices.Equal(applied, []string{"resourcequotas/checkout/compute", "networkpolicies/checkout/default-deny"}) {
+		t.Fatalf("expected the template resources to be applied in the namespace, got %v", applied)
+	}
+	if !strings.Contains(approved.Result, "provisioned NetworkPolicy/default-deny") {
+		t.Fatalf("expected the result to list provisioned resources:\n%s", approved.Result)
+	}
+
+	// Workspaces without a template of their own get the "*" template.
+	req = manifestRequest(OperationCreate, "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: scratch\n")
+	req.Workspace = "research"
+	preview, err = svc.Preview(ctx, req)
+	if err != nil || len(preview.Provisioned) != 0 {
+		t.Fatalf("expected the fallback template to provision nothing, got %+v (%v)", preview.Provisioned, err)
+	}
+	if template, ok := svc.NamespaceTemplate("research"); !ok || template.Labels["provisioned-by"] != "kubechat" {
+		t.Fatalf("expected the fallback template, got %+v", template)
+	}
+}
+
+func TestNamespaceTemplatesAreValidated(t *testing.T) {
+	for name, templates := range map[string]NamespaceTemplates{
+		"no workspace": {Templates: []NamespaceTemplate{{}}},
+		"duplicate":    {Templates: []NamespaceTemplate{{Workspace: "a"}, {Workspace: "a"}}},
+		"cluster kind": {Templates: []NamespaceTemplate{{Workspace: "a", Resources: []map[string]any{
+			{"apiVersion": "rbac.authorization.k8s.io/v1", "kind": "ClusterRoleBinding", "metadata": map[string]any{"name": "admin"}},
+		}}}},
+		"namespace set": {Templates: []NamespaceTemplate{{Workspace: "a", Resources: []map[string]any{
+			{"apiVersion": "v1", "kind": "LimitRange", "metadata": map[string]any{"name": "limits", "namespace": "kube-system"}},
+		}}}},
+	} {
+		if err := templates.Validate(); err == nil {
+			t.Errorf("%s: expected the templates to be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pramodksahoo/kubechat/backend/internal/alerting"
+)
+
+// ApprovalNotice tells approver groups that an execution awaits their decision.
+type ApprovalNotice struct {
+	Execution Execution `json:"execution"`
+	Approval  Approval  `json:"approval"`
+	// Groups are the groups asked to decide: the approver groups, or the groups an escalation added.
+	Groups    []string `json:"groups"`
+	Members   []string `json:"members,omitempty"`
+	Escalated bool     `json:"escalated,omitempty"`
+	// Links are each member's signed approve and reject links, set when approval links are
+	// configured.
+	Links []RecipientLinks `json:"links,omitempty"`
+}
+
+// RecipientLinks are the links only Recipient can use; one decision through either uses both.
+type RecipientLinks struct {
+	Recipient  string `json:"recipient"`
+	ApproveURL string `json:"approveUrl"`
+	RejectURL  string `json:"rejectUrl"`
+}
+
+// ApprovalNotifier is told of every approval request, and of the groups an escalation adds.
+type ApprovalNotifier interface {
+	NotifyApproval(ctx context.Context, notice ApprovalNotice)
+}
+
+// SetApprovalNotifier notifies approvers when an execution starts awaiting approval and when the
+// approval is escalated to more groups. Approvals any user may decide notify nobody.
+func (s *Service) SetApprovalNotifier(notifier ApprovalNotifier) {
+	s.notifier = notifier
+}
+
+func (s *Service) notifyApproval(ctx context.Context, execution Execution, approval Approval, groups []string, escalated bool) {
+	if s.notifier == nil || len(groups) == 0 {
+		return
+	}
+	notice := ApprovalNotice{
+		Execution: execution,
+		Approval:  approval,
+		Groups:    groups,
+		Members:   s.policies.Members(groups, approval.RequestedBy),
+		Escalated: escalated,
+	}
+	if s.links != nil {
+		expires := s.now().Add(DefaultApprovalLinkTTL)
+		if approval.ExpiresAt != nil {
+			expires = *approval.ExpiresAt
+		}
+		for _, member := range notice.Members {
+			notice.Links = append(notice.Links, RecipientLinks{
+				Recipient:  member,
+				ApproveURL: s.links.URL(execution.ID, member, LinkApprove, expires),
+				RejectURL:  s.links.URL(execution.ID, member, LinkReject, expires),
+			})
+		}
+	}
+	s.notifier.NotifyApproval(ctx, notice)
+}
+
+// Alert describes the notice for the approver groups, with the links as actions.
+func (n ApprovalNotice) Alert() alerting.Alert {
+	execution, approval := n.Execution, n.Approval
+	severity := alerting.SeverityMedium
+	if execution.SafetyLevel == SafetyDangerous {
+		severity = alerting.SeverityHigh
+	}
+	kind, title := "approval.requested", "Approval needed"
+	if n.Escalated {
+		kind, title = "approval.escalated", "Escalated approval needed"
+	}
+	target := execution.Resource.Kind + "/" + execution.Resource.Name
+	if execution.Resource.Namespace != "" {
+		target = execution.Resource.Namespace + "/" + target
+	}
+	command := fmt.Sprintf("%s %s", execution.Operation, target)
+	if len(execution.Steps) > 0 {
+		command = fmt.Sprintf("a plan of %d steps", len(execution.Steps))
+	}
+
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "%s asked to run %s on %s/%s.\n", execution.UserID, command, execution.Config, execution.Cluster)
+	fmt.Fprintf(&summary, "Safety: %s", execution.SafetyLevel)
+	if explanation := approval.Explanation; explanation != nil {
+		if explanation.Command != "" {
+			fmt.Fprintf(&summary, "\nCommand: %s", explanation.Command)
+		}
+		fmt.Fprintf(&summary, "\n%s", explanation.Summary)
+		if len(explanation.Effects) > 0 {
+			fmt.Fprintf(&summary, "\nEffects: %s", strings.Join(explanation.Effects, "; "))
+		}
+		fmt.Fprintf(&summary, "\nReversibility: %s", explanation.Reversibility)
+		if explanation.Reversal != "" {
+			fmt.Fprintf(&summary, ". %s", explanation.Reversal)
+		}
+	}
+	fmt.Fprintf(&summary, "\nPolicy %s needs %d approval(s) from %s.", approval.PolicyName, approval.RequiredApprovals, strings.Join(n.Groups, ", "))
+	if approval.ExpiresAt != nil {
+		fmt.Fprintf(&summary, " The request expires at %s.", approval.ExpiresAt.Format(time.RFC3339))
+	}
+
+	details := map[string]any{
+		"execution":         execution.ID,
+		"requestedBy":       execution.UserID,
+		"config":            execution.Config,
+		"cluster":           execution.Cluster,
+		"operation":         execution.Operation,
+		"resource":          execution.Resource,
+		"safetyLevel":       execution.SafetyLevel,
+		"policy":            approval.PolicyName,
+		"requiredApprovals": approval.RequiredApprovals,
+		"approverGroups":    n.Groups,
+		"approvers":         n.Members,
+	}
+	if approval.ExpiresAt != nil {
+		details["expiresAt"] = approval.ExpiresAt
+	}
+	var links []alerting.Link
+	for _, recipient := range n.Links {
+		links = append(links,
+			alerting.Link{Text: "Approve as " + recipient.Recipient, URL: recipient.ApproveURL},
+			alerting.Link{Text: "Reject as " + recipient.Recipient, URL: recipient.RejectURL})
+	}
+	return alerting.Alert{
+		Type:     kind,
+		Severity: severity,
+		Title:    fmt.Sprintf("%s: %s", title, command),
+		Summary:  summary.String(),
+		DedupKey: kind + "/" + execution.ID,
+		Details:  details,
+		Groups:   n.Groups,
+		Links:    links,
+	}
+}
+
+// AlertDispatcher routes alerts to the channels configured for them.
+type AlertDispatcher interface {
+	Dispatch(ctx context.Context, alert alerting.Alert) (alerting.Record, error)
+}
+
+// AlertNotifier sends approval notices as alerts addressed to the approver groups, so that they
+// reach the Slack or email channels routed to those groups.
+type AlertNotifier struct {
+	dispatcher AlertDispatcher
+	logger     *log.Logger
+}
+
+func NewAlertNotifier(dispatcher AlertDispatcher, logger *log.Logger) *AlertNotifier {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &AlertNotifier{dispatcher: dispatcher, logger: logger}
+}
+
+// NotifyApproval dispatches the notice in the background, since channels retry failed deliveries.
+func (n *AlertNotifier) NotifyApproval(ctx context.Context, notice ApprovalNotice) {
+	alert := notice.Alert()
+	go func() {
+		if _, err := n.dispatcher.Dispatch(context.WithoutCancel(ctx), alert); err != nil {
+			n.logger.Error("failed to record approval notification", "execution", notice.Execution.ID, "error", err)
+		}
+	}()
+}
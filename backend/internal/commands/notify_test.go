@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordingNotifier struct {
+	notices []ApprovalNotice
+}
+
+func (n *recordingNotifier) NotifyApproval(ctx context.Context, notice ApprovalNotice) {
+	n.notices = append(n.notices, notice)
+}
+
+func linkToken(url string) string {
+	return url[strings.Index(url, ApprovalLinkPath)+len(ApprovalLinkPath):]
+}
+
+func linksFor(t *testing.T, notice ApprovalNotice, recipient string) RecipientLinks {
+	t.Helper()
+	for _, links := range notice.Links {
+		if links.Recipient == recipient {
+			return links
+		}
+	}
+	t.Fatalf("no links for %s in %+v", recipient, notice.Links)
+	return RecipientLinks{}
+}
+
+func TestApprovalNotificationsCarryOneTimeLinks(t *testing.T) {
+	svc, _, _ := newTestService(deployment(3))
+	svc.SetApprovalPolicies(testPolicies())
+	notifier := &recordingNotifier{}
+	svc.SetApprovalNotifier(notifier)
+	if _, err := NewApprovalLinks(nil, "https://kubechat.example.com/"); !errors.Is(err, ErrNoApprovalLinkKey) {
+		t.Fatalf("expected links without a key to be refused, got %v", err)
+	}
+	links, err := NewApprovalLinks([]byte("key"), "https://kubechat.example.com/")
+	if err != nil {
+		t.Fatalf("approval links: %v", err)
+	}
+	svc.SetApprovalLinks(links)
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	execution, err := svc.Submit(ctx, scaleRequest("alice", "0"))
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if len(notifier.notices) != 1 {
+		t.Fatalf("expected one notice, got %d", len(notifier.notices))
+	}
+	notice := notifier.notices[0]
+	if strings.Join(notice.Groups, ",") != "sre" || strings.Join(notice.Members, ",") != "bob,carol,dave" {
+		t.Fatalf("expected the sre group and its members to be notified, got %v %v", notice.Groups, notice.Members)
+	}
+	bob := linksFor(t, notice, "bob")
+	if len(notice.Links) != 3 || !strings.HasPrefix(bob.ApproveURL, "https://kubechat.example.com"+ApprovalLinkPath) {
+		t.Fatalf("unexpected links %+v", notice.Links)
+	}
+	alert := notice.Alert()
+	if alert.Type != "approval.requested" || len(alert.Links) != 6 || !strings.Contains(alert.Summary, "expires at 2025-03-01T11:00:00Z") {
+		t.Fatalf("unexpected alert %+v", alert)
+	}
+
+	approve := linkToken(bob.ApproveURL)
+	if _, _, err := svc.DecideByLink(ctx, approve[:len(approve)-2]+"xx", "bob", nil, ""); !errors.Is(err, ErrInvalidApprovalLink) {
+		t.Fatalf("expected a tampered link to be refused, got %v", err)
+	}
+	if _, _, err := svc.DecideByLink(ctx, approve, "carol", nil, ""); !errors.Is(err, ErrNotLinkRecipient) {
+		t.Fatalf("expected bob's link to refuse carol, got %v", err)
+	}
+	link, _, err := svc.DecideByLink(ctx, approve, "bob", nil, "via slack")
+	if err != nil || link.Action != LinkApprove || link.Recipient != "bob" {
+		t.Fatalf("expected bob to approve through the link, got %+v %v", link, err)
+	}
+	if _, _, err := svc.DecideByLink(ctx, linkToken(bob.RejectURL), "bob", nil, ""); !errors.Is(err, ErrApprovalLinkUsed) {
+		t.Fatalf("expected bob's links to work once, got %v", err)
+	}
+	restarted, _ := NewApprovalLinks([]byte("key"), "https://kubechat.example.com/")
+	svc.SetApprovalLinks(restarted)
+	if _, _, err := svc.DecideByLink(ctx, approve, "bob", nil, ""); !errors.Is(err, ErrApprovalLinkUsed) {
+		t.Fatalf("expected the redemption to outlive the links that signed it, got %v", err)
+	}
+
+	now = now.Add(45 * time.Minute)
+	if err := svc.ProcessApprovals(ctx); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if len(notifier.notices) != 2 || !notifier.notices[1].Escalated || strings.Join(notifier.notices[1].Groups, ",") != "leads" {
+		t.Fatalf("expected the escalation to notify the leads, got %+v", notifier.notices)
+	}
+	rejected, _, err := svc.DecideByLink(ctx, linkToken(linksFor(t, notifier.notices[1], "erin").RejectURL), "erin", nil, "")
+	if err != nil || rejected.Action != LinkReject {
+		t.Fatalf("expected erin to reject through the link, got %v", err)
+	}
+	stored, _ := svc.Get(ctx, execution.ID)
+	if stored.Status != StatusRejected {
+		t.Fatalf("expected the execution to be rejected, got %s", stored.Status)
+	}
+
+	now = now.Add(3 * time.Hour)
+	if _, _, _, err := svc.ApprovalByLink(ctx, linkToken(linksFor(t, notice, "carol").RejectURL)); !errors.Is(err, ErrInvalidApprovalLink) {
+		t.Fatalf("expected links to expire with the approval, got %v", err)
+	}
+}
+
+func TestEachApproverReachesQuorumThroughTheirOwnLink(t *testing.T) {
+	svc, _, _ := newTestService(deployment(3))
+	svc.SetApprovalPolicies(testPolicies())
+	notifier := &recordingNotifier{}
+	svc.SetApprovalNotifier(notifier)
+	links, _ := NewApprovalLinks([]byte("key"), "https://kubechat.example.com")
+	svc.SetApprovalLinks(links)
+	ctx := context.Background()
+
+	execution, err := svc.Submit(ctx, scaleRequest("alice", "0"))
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	notice := notifier.notices[0]
+	for _, approver := range []string{"bob", "carol"} {
+		if _, _, err := svc.DecideByLink(ctx, linkToken(linksFor(t, notice, approver).ApproveURL), approver, nil, ""); err != nil {
+			t.Fatalf("expected %s to approve through their link, got %v", approver, err)
+		}
+	}
+	stored, _ := svc.Get(ctx, execution.ID)
+	if stored.Status == StatusAwaitingApproval {
+		t.Fatalf("expected two approvals through links to reach the policy's quorum")
+	}
+}
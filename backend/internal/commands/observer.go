@@ -0,0 +1,20 @@
+package commands
+
+import "context"
+
+// Observer is told of every command admitted for execution, such as to detect suspicious activity.
+type Observer interface {
+	ObserveSubmission(ctx context.Context, execution Execution)
+}
+
+// SetObserver reports executions once they passed the quota and change window checks, before
+// they await approval, are queued or run. A plan is reported once, with its steps.
+func (s *Service) SetObserver(observer Observer) {
+	s.observer = observer
+}
+
+func (s *Service) observeSubmission(ctx context.Context, execution Execution) {
+	if s.observer != nil {
+		s.observer.ObserveSubmission(ctx, execution)
+	}
+}
@@ -0,0 +1,229 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/maypok86/otter/v2"
+)
+
+// PolicyEffect is what an authorization policy decides for an execution.
+type PolicyEffect string
+
+const (
+	PolicyAllow           PolicyEffect = "allow"
+	PolicyDeny            PolicyEffect = "deny"
+	PolicyRequireApproval PolicyEffect = "require_approval"
+)
+
+// PolicyInput is the document an authorization policy evaluates, sent to OPA as its input.
+type PolicyInput struct {
+	User        string            `json:"user"`
+	Groups      []string          `json:"groups,omitempty"`
+	Config      string            `json:"config"`
+	Cluster     string            `json:"cluster"`
+	Operation   Operation         `json:"operation"`
+	Resource    Resource          `json:"resource"`
+	Parameters  map[string]string `json:"parameters,omitempty"`
+	SafetyLevel SafetyLevel       `json:"safetyLevel"`
+	// SafetyRule names the safety rule that classified the execution, or "builtin".
+	SafetyRule string `json:"safetyRule,omitempty"`
+}
+
+// PolicyDecision is recorded on executions an authorization policy evaluated.
+type PolicyDecision struct {
+	Effect PolicyEffect `json:"effect"`
+	Reason string       `json:"reason,omitempty"`
+}
+
+// Authorizer decides whether an execution may run before it is recorded.
+type Authorizer interface {
+	Authorize(ctx context.Context, input PolicyInput) (PolicyDecision, error)
+}
+
+type ErrPolicyDenied struct {
+	Decision PolicyDecision
+}
+
+func (e ErrPolicyDenied) Error() string {
+	if e.Decision.Reason != "" {
+		return "denied by authorization policy: " + e.Decision.Reason
+	}
+	return "denied by authorization policy"
+}
+
+// ErrPolicyUnavailable is returned when the authorization policy cannot be evaluated. Executions
+// are refused rather than run unchecked.
+type ErrPolicyUnavailable struct {
+	Err error
+}
+
+func (e ErrPolicyUnavailable) Error() string {
+	return fmt.Sprintf("authorization policy is unavailable: %v", e.Err)
+}
+
+func (e ErrPolicyUnavailable) Unwrap() error {
+	return e.Err
+}
+
+// SetAuthorizer consults authorizer before every execution is recorded.
+func (s *Service) SetAuthorizer(authorizer Authorizer) {
+	s.authorizer = authorizer
+}
+
+// authorize asks the authorizer about req. Denials fail the submission and required approvals
+// raise a safe execution to a warning so it waits for a reviewer.
+func (s *Service) authorize(ctx context.Context, req Request, execution *Execution, rule string) error {
+	if s.authorizer == nil {
+		return nil
+	}
+	decision, err := s.authorizer.Authorize(ctx, PolicyInput{
+		User:        req.UserID,
+		Groups:      req.Groups,
+		Config:      req.Config,
+		Cluster:     req.Cluster,
+		Operation:   req.Operation,
+		Resource:    req.Resource,
+		Parameters:  req.Parameters,
+		SafetyLevel: execution.SafetyLevel,
+		SafetyRule:  rule,
+	})
+	if err != nil {
+		s.log(ctx).Error("failed to evaluate authorization policy", "user", req.UserID, "resource", req.Resource.String(), "error", err)
+		return ErrPolicyUnavailable{Err: err}
+	}
+	switch decision.Effect {
+	case PolicyDeny:
+		s.log(ctx).Info("command refused by authorization policy", "user", req.UserID, "resource", req.Resource.String(), "reason", decision.Reason)
+		return ErrPolicyDenied{Decision: decision}
+	case PolicyRequireApproval:
+		if execution.SafetyLevel == SafetySafe {
+			execution.SafetyLevel = SafetyWarning
+		}
+	}
+	execution.PolicyDecision = &decision
+	return nil
+}
+
+// opaCacheSize bounds the decisions an OPAAuthorizer remembers.
+const opaCacheSize = 10_000
+
+// OPAAuthorizer evaluates an Open Policy Agent decision through OPA's data API. Decisions are
+// cached by input for the configured TTL.
+type OPAAuthorizer struct {
+	url    string
+	client *http.Client
+	cache  *otter.Cache[string, PolicyDecision]
+}
+
+// NewOPAAuthorizer queries the decision document at decisionURL, such as
+// http://opa:8181/v1/data/kubechat/authz. The decision may be a boolean, one of "allow", "deny"
+// or "require_approval", or an object with a "decision" and optional "reason". A ttl of zero
+// disables caching.
+func NewOPAAuthorizer(decisionURL string, ttl time.Duration, client *http.Client) (*OPAAuthorizer, error) {
+	parsed, err := url.Parse(decisionURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("OPA decision URL %q must be an absolute http(s) URL", decisionURL)
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	authorizer := &OPAAuthorizer{url: decisionURL, client: client}
+	if ttl > 0 {
+		authorizer.cache = otter.Must(&otter.Options[string, PolicyDecision]{
+			MaximumSize:      opaCacheSize,
+			ExpiryCalculator: otter.ExpiryWriting[string, PolicyDecision](ttl),
+		})
+	}
+	return authorizer, nil
+}
+
+func (a *OPAAuthorizer) Authorize(ctx context.Context, input PolicyInput) (PolicyDecision, error) {
+	body, err := json.Marshal(map[string]PolicyInput{"input": input})
+	if err != nil {
+		return PolicyDecision{}, err
+	}
+	sum := sha256.Sum256(body)
+	key := hex.EncodeToString(sum[:])
+	if a.cache != nil {
+		if decision, ok := a.cache.GetIfPresent(key); ok {
+			return decision, nil
+		}
+	}
+	decision, err := a.query(ctx, body)
+	if err != nil {
+		return PolicyDecision{}, err
+	}
+	if a.cache != nil {
+		a.cache.Set(key, decision)
+	}
+	return decision, nil
+}
+
+func (a *OPAAuthorizer) query(ctx context.Context, body []byte) (PolicyDecision, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return PolicyDecision{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return PolicyDecision{}, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return PolicyDecision{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return PolicyDecision{}, fmt.Errorf("OPA returned %s", resp.Status)
+	}
+	var envelope struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return PolicyDecision{}, fmt.Errorf("decode OPA response: %w", err)
+	}
+	if len(envelope.Result) == 0 {
+		return PolicyDecision{}, fmt.Errorf("OPA decision at %s is undefined", a.url)
+	}
+	return parseDecision(envelope.Result)
+}
+
+// parseDecision reads the decision forms NewOPAAuthorizer documents.
+func parseDecision(result json.RawMessage) (PolicyDecision, error) {
+	var allowed bool
+	if err := json.Unmarshal(result, &allowed); err == nil {
+		if allowed {
+			return PolicyDecision{Effect: PolicyAllow}, nil
+		}
+		return PolicyDecision{Effect: PolicyDeny}, nil
+	}
+	var decision PolicyDecision
+	var effect string
+	if err := json.Unmarshal(result, &effect); err == nil {
+		decision.Effect = PolicyEffect(effect)
+	} else {
+		var object struct {
+			Decision PolicyEffect `json:"decision"`
+			Reason   string       `json:"reason"`
+		}
+		if err := json.Unmarshal(result, &object); err != nil {
+			return PolicyDecision{}, fmt.Errorf("unsupported OPA decision %s", result)
+		}
+		decision = PolicyDecision{Effect: object.Decision, Reason: object.Reason}
+	}
+	switch decision.Effect {
+	case PolicyAllow, PolicyDeny, PolicyRequireApproval:
+		return decision, nil
+	}
+	return PolicyDecision{}, fmt.Errorf("unsupported OPA decision %q", decision.Effect)
+}
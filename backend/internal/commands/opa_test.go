@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// opaServer decides like a policy that denies deletes, requires approval in prod-* namespaces
+// and allows everything else.
+func opaServer(t *testing.T, queries *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries.Add(1)
+		var body struct {
+			Input PolicyInput `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode input: %v", err)
+		}
+		switch {
+		case body.Input.Operation == OperationDelete:
+			w.Write([]byte(`{"result":{"decision":"deny","reason":"deletes go through change management"}}`))
+		case body.Input.Resource.Namespace == "prod-shop":
+			w.Write([]byte(`{"result":"require_approval"}`))
+		default:
+			w.Write([]byte(`{"result":true}`))
+		}
+	}))
+}
+
+func TestAuthorizationPolicyDecidesExecutions(t *testing.T) {
+	var queries atomic.Int32
+	server := opaServer(t, &queries)
+	defer server.Close()
+	authorizer, err := NewOPAAuthorizer(server.URL+"/v1/data/kubechat/authz", time.Minute, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, _, _ := newTestService(deployment(2))
+	svc.SetAuthorizer(authorizer)
+	ctx := context.Background()
+
+	execution, err := svc.Submit(ctx, scaleRequest("alice", "3"))
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if execution.Status != StatusSucceeded || execution.PolicyDecision == nil || execution.PolicyDecision.Effect != PolicyAllow {
+		t.Fatalf("expected an allowed scale to run, got %+v", execution)
+	}
+	if _, err := svc.Submit(ctx, scaleRequest("alice", "3")); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if queries.Load() != 1 {
+		t.Fatalf("expected the repeated decision to be cached, OPA was queried %d times", queries.Load())
+	}
+
+	req := scaleRequest("alice", "3")
+	req.Resource.Namespace = "prod-shop"
+	execution, err = svc.Submit(ctx, req)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if execution.Status != StatusAwaitingApproval || execution.SafetyLevel != SafetyWarning {
+		t.Fatalf("expected the policy to require approval, got %+v", execution)
+	}
+
+	req = scaleRequest("alice", "3")
+	req.Operation = OperationDelete
+	var denied ErrPolicyDenied
+	if _, err := svc.Submit(ctx, req); !errors.As(err, &denied) || denied.Decision.Reason != "deletes go through change management" {
+		t.Fatalf("expected the policy to deny the delete, got %v", err)
+	}
+}
+
+func TestUnavailablePolicyRefusesExecutions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	authorizer, err := NewOPAAuthorizer(server.URL, 0, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, _, repo := newTestService(deployment(2))
+	svc.SetAuthorizer(authorizer)
+
+	var unavailable ErrPolicyUnavailable
+	if _, err := svc.Submit(context.Background(), scaleRequest("alice", "3")); !errors.As(err, &unavailable) {
+		t.Fatalf("expected an undefined decision to refuse the execution, got %v", err)
+	}
+	if executions, _ := repo.ListByUser(context.Background(), "alice", 10, 0); len(executions) != 0 {
+		t.Fatalf("expected nothing to be recorded, got %+v", executions)
+	}
+}
+
+func TestParseDecision(t *testing.T) {
+	for raw, want := range map[string]PolicyEffect{
+		`false`:                           PolicyDeny,
+		`"allow"`:                         PolicyAllow,
+		`{"decision":"require_approval"}`: PolicyRequireApproval,
+	} {
+		decision, err := parseDecision(json.RawMessage(raw))
+		if err != nil || decision.Effect != want {
+			t.Fatalf("%s: expected %s, got %+v (%v)", raw, want, decision, err)
+		}
+	}
+	if _, err := parseDecision(json.RawMessage(`"maybe"`)); err == nil {
+		t.Fatal("expected an unknown decision to be rejected")
+	}
+}
@@ -0,0 +1,508 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"k8s.io/client-go/kubernetes"
+)
+
+// maxPlanSteps bounds the commands one plan runs, counted after steps naming no object are expanded.
+const maxPlanSteps = 50
+
+type StepStatus string
+
+const (
+	StepPending   StepStatus = "pending"
+	StepRunning   StepStatus = "running"
+	StepSucceeded StepStatus = "succeeded"
+	StepFailed    StepStatus = "failed"
+	// StepSkipped marks the steps left when an earlier one failed.
+	StepSkipped StepStatus = "skipped"
+)
+
+// PlanStep is one command of a plan. It runs as an execution of its own, linked to the plan by
+// PlanID, so its result, changes and rollback plan are recorded like any other command's.
+type PlanStep struct {
+	Sequence    int               `json:"sequence"`
+	Operation   Operation         `json:"operation"`
+	Resource    Resource          `json:"resource"`
+	Parameters  map[string]string `json:"parameters,omitempty"`
+	SafetyLevel SafetyLevel       `json:"safetyLevel"`
+	Status      StepStatus        `json:"status"`
+	// ExecutionID is the execution that ran the step.
+	ExecutionID string `json:"executionId,omitempty"`
+	Error       string `json:"error,omitempty"`
+	// DriftWarning, AccessReview and PolicyDecision are recorded when the plan is submitted, as
+	// on an execution, and carried over to the step's execution.
+	DriftWarning   *DriftWarning   `json:"driftWarning,omitempty"`
+	AccessReview   *AccessReview   `json:"accessReview,omitempty"`
+	PolicyDecision *PolicyDecision `json:"policyDecision,omitempty"`
+}
+
+// PlanStepRequest is one command of a plan. A step on a supported kind that names no object
+// applies to every object of that kind in its namespace, narrowed by the selector parameter, a
+// label selector, and the olderThan parameter, an age such as "7d" or "12h".
+type PlanStepRequest struct {
+	Operation  Operation         `json:"operation"`
+	Resource   Resource          `json:"resource"`
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+// PlanRequest asks to run several commands against one cluster in order, such as the commands
+// the NLP service planned for a single query.
+type PlanRequest struct {
+	UserID  string            `json:"-"`
+	Config  string            `json:"config"`
+	Cluster string            `json:"cluster"`
+	Steps   []PlanStepRequest `json:"steps"`
+	// Query is the natural-language request the plan carries out, shown to approvers.
+	Query            string   `json:"query,omitempty"`
+	AcknowledgeDrift bool     `json:"acknowledgeDrift,omitempty"`
+	Groups           []string `json:"-"`
+	Workspace        string   `json:"-"`
+	CorrelationID    string   `json:"correlationId,omitempty"`
+	OverrideReason   string   `json:"overrideReason,omitempty"`
+}
+
+func (req PlanRequest) request(step PlanStepRequest) Request {
+	return Request{
+		UserID:           req.UserID,
+		Config:           req.Config,
+		Cluster:          req.Cluster,
+		Operation:        step.Operation,
+		Resource:         step.Resource,
+		Parameters:       step.Parameters,
+		AcknowledgeDrift: req.AcknowledgeDrift,
+		Groups:           req.Groups,
+		Workspace:        req.Workspace,
+		CorrelationID:    req.CorrelationID,
+		OverrideReason:   req.OverrideReason,
+	}
+}
+
+// SubmitPlan records a plan and runs its steps in order, stopping at the first failure. Every
+// step is checked as if it were submitted on its own before anything is recorded; when any of
+// them needs approval, the plan as a whole waits for one approval.
+func (s *Service) SubmitPlan(ctx context.Context, req PlanRequest) (Execution, error) {
+	if len(req.Steps) == 0 {
+		return Execution{}, ErrInvalidRequest{Reason: "a plan needs at least one step"}
+	}
+	if req.Config == "" && req.Cluster != "" && s.clusters != nil {
+		config, cluster, err := s.clusters.Resolve(ctx, req.Cluster)
+		if err != nil {
+			return Execution{}, ErrInvalidRequest{Reason: err.Error()}
+		}
+		req.Config, req.Cluster = config, cluster
+	}
+	if req.Config == "" || req.Cluster == "" {
+		return Execution{}, ErrInvalidRequest{Reason: "config and cluster are required"}
+	}
+
+	var requests []Request
+	for i, step := range req.Steps {
+		expanded, err := s.expandStep(ctx, req.request(step))
+		if err != nil {
+			return Execution{}, stepError(i+1, err)
+		}
+		requests = append(requests, expanded...)
+	}
+	switch {
+	case len(requests) == 0:
+		return Execution{}, ErrInvalidRequest{Reason: "no objects match the plan's steps"}
+	case len(requests) > maxPlanSteps:
+		return Execution{}, ErrInvalidRequest{Reason: fmt.Sprintf("the plan has %d steps; at most %d are allowed", len(requests), maxPlanSteps)}
+	}
+
+	plan := Execution{
+		ID:          uuid.NewString(),
+		UserID:      req.UserID,
+		Config:      req.Config,
+		Cluster:     req.Cluster,
+		Operation:   OperationPlan,
+		SafetyLevel: SafetySafe,
+		CreatedAt:   s.now(),
+	}
+	plan.CorrelationID = req.CorrelationID
+	if plan.CorrelationID == "" {
+		plan.CorrelationID = plan.ID
+	}
+	for i, stepReq := range requests {
+		step, err := s.planStep(ctx, stepReq)
+		if err != nil {
+			return Execution{}, stepError(i+1, err)
+		}
+		step.Sequence = i + 1
+		plan.Steps = append(plan.Steps, step)
+		if safetyRank[step.SafetyLevel] > safetyRank[plan.SafetyLevel] {
+			plan.SafetyLevel = step.SafetyLevel
+		}
+	}
+	plan.Resource = planResource(plan.Steps)
+	if s.reviews != nil && req.CorrelationID != "" {
+		disputed, err := s.reviews.RequiresApproval(ctx, req.CorrelationID)
+		if err != nil {
+			return Execution{}, err
+		}
+		if disputed && plan.SafetyLevel == SafetySafe {
+			s.log(ctx).Info("command plan requires approval after risk review", "id", plan.ID, "draft", req.CorrelationID)
+			plan.SafetyLevel = SafetyWarning
+		}
+	}
+	if query := strings.TrimSpace(req.Query); query != "" {
+		plan.Parameters = map[string]string{"query": query}
+	}
+	s.log(ctx).Info("command plan submitted", "id", plan.ID, "user", plan.UserID, "cluster", plan.Cluster, "steps", len(plan.Steps), "safety_level", plan.SafetyLevel)
+	return s.submit(ctx, plan, Request{UserID: req.UserID, Groups: req.Groups, OverrideReason: req.OverrideReason})
+}
+
+// safetyRank orders the safety levels a plan step can have.
+// PlanReviews tells whether the plan draft a submission carries out, named by its correlation ID,
+// was disputed by the second-opinion risk review.
+type PlanReviews interface {
+	RequiresApproval(ctx context.Context, draftID string) (bool, error)
+}
+
+// SetPlanReviews holds plans whose draft the risk review disputed for approval even when every
+// step is safe.
+func (s *Service) SetPlanReviews(reviews PlanReviews) {
+	s.reviews = reviews
+}
+
+var safetyRank = map[SafetyLevel]int{SafetySafe: 0, SafetyWarning: 1, SafetyDangerous: 2}
+
+// stepError names the step an error is about; invalid requests keep their type so they are
+// still reported as such.
+func stepError(sequence int, err error) error {
+	var invalid ErrInvalidRequest
+	if errors.As(err, &invalid) {
+		return ErrInvalidRequest{Reason: fmt.Sprintf("step %d: %s", sequence, invalid.Reason)}
+	}
+	return fmt.Errorf("step %d: %w", sequence, err)
+}
+
+// planStep validates and checks one step the way Submit checks a command.
+func (s *Service) planStep(ctx context.Context, req Request) (PlanStep, error) {
+	switch {
+	case req.Operation == OperationPlan || req.Operation == OperationGenerate:
+		return PlanStep{}, ErrInvalidRequest{Reason: fmt.Sprintf("%s cannot be a step of a plan", req.Operation)}
+	case readOperation(req.Operation):
+		return PlanStep{}, ErrInvalidRequest{Reason: fmt.Sprintf("%s changes nothing; plans only carry out changes", req.Operation)}
+	}
+	req, webhook, delegated, err := s.prepare(ctx, req)
+	if err != nil {
+		return PlanStep{}, err
+	}
+	if delegated {
+		return PlanStep{}, ErrInvalidRequest{Reason: fmt.Sprintf("%s is run by an external executor and cannot be a step of a plan", req.Operation)}
+	}
+	execution, err := s.newExecution(ctx, req, webhook, delegated)
+	if err != nil {
+		return PlanStep{}, err
+	}
+	return PlanStep{
+		Operation:      execution.Operation,
+		Resource:       execution.Resource,
+		Parameters:     execution.Parameters,
+		SafetyLevel:    execution.SafetyLevel,
+		Status:         StepPending,
+		DriftWarning:   execution.DriftWarning,
+		AccessReview:   execution.AccessReview,
+		PolicyDecision: execution.PolicyDecision,
+	}, nil
+}
+
+// expandStep turns a step naming no object into one step per object of its kind in its
+// namespace that matches the selector and olderThan parameters, ordered by name.
+func (s *Service) expandStep(ctx context.Context, req Request) ([]Request, error) {
+	selector, olderThan := req.Parameters["selector"], req.Parameters["olderThan"]
+	if req.Resource.Name != "" || manifestOperation(req.Operation) || readOperation(req.Operation) {
+		if selector != "" || olderThan != "" {
+			return nil, ErrInvalidRequest{Reason: "selector and olderThan apply only to steps naming no object"}
+		}
+		return []Request{req}, nil
+	}
+	if !supportedKinds[strings.ToLower(req.Resource.Kind)] {
+		return nil, ErrInvalidRequest{Reason: fmt.Sprintf("a step naming no object does not support kind %q", req.Resource.Kind)}
+	}
+	if req.Resource.Namespace == "" {
+		return nil, ErrInvalidRequest{Reason: "a step naming no object needs a namespace"}
+	}
+	var age time.Duration
+	if olderThan != "" {
+		var err error
+		if age, err = parseAge(olderThan); err != nil {
+			return nil, err
+		}
+	}
+
+	client, err := s.requesterClient(req)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := listObjects(ctx, client, req.Resource, selector)
+	if err != nil {
+		return nil, fmt.Errorf("list %s in %s: %w", req.Resource.Kind, req.Resource.Namespace, err)
+	}
+	params := maps.Clone(req.Parameters)
+	delete(params, "selector")
+	delete(params, "olderThan")
+	cutoff := s.now().Add(-age)
+	var expanded []Request
+	for _, obj := range objects {
+		if age > 0 && !obj.GetCreationTimestamp().Time.Before(cutoff) {
+			continue
+		}
+		step := req
+		step.Resource.Name = obj.GetName()
+		step.Parameters = maps.Clone(params)
+		expanded = append(expanded, step)
+	}
+	slices.SortFunc(expanded, func(a, b Request) int { return strings.Compare(a.Resource.Name, b.Resource.Name) })
+	return expanded, nil
+}
+
+// parseAge reads an age such as "7d" or "36h".
+func parseAge(value string) (time.Duration, error) {
+	var (
+		age time.Duration
+		err error
+	)
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		age = time.Duration(n) * 24 * time.Hour
+	} else {
+		age, err = time.ParseDuration(value)
+	}
+	if err != nil || age <= 0 {
+		return 0, ErrInvalidRequest{Reason: fmt.Sprintf("olderThan must be a positive age such as 7d or 12h, got %q", value)}
+	}
+	return age, nil
+}
+
+// planResource is the target a plan is indexed under: the kind and namespace its steps share.
+func planResource(steps []PlanStep) Resource {
+	resource := Resource{Kind: steps[0].Resource.Kind, Namespace: steps[0].Resource.Namespace}
+	for _, step := range steps[1:] {
+		if !strings.EqualFold(step.Resource.Kind, resource.Kind) {
+			resource.Kind = ""
+		}
+		if step.Resource.Namespace != resource.Namespace {
+			resource.Namespace = ""
+		}
+	}
+	return resource
+}
+
+// namespaces lists the namespaces an execution changes: its target's, or its steps' for a plan.
+func (e Execution) namespaces() []string {
+	if e.Operation != OperationPlan {
+		return []string{e.Resource.Namespace}
+	}
+	var namespaces []string
+	for _, step := range e.Steps {
+		if !slices.Contains(namespaces, step.Resource.Namespace) {
+			namespaces = append(namespaces, step.Resource.Namespace)
+		}
+	}
+	return namespaces
+}
+
+// operations lists the operations an execution performs; a plan performs its steps' as well.
+func (e Execution) operations() []Operation {
+	operations := []Operation{e.Operation}
+	for _, step := range e.Steps {
+		if !slices.Contains(operations, step.Operation) {
+			operations = append(operations, step.Operation)
+		}
+	}
+	return operations
+}
+
+// stepExecution is the execution that runs step of the plan e.
+func (e Execution) stepExecution(step PlanStep) Execution {
+	return Execution{
+		ID:             uuid.NewString(),
+		UserID:         e.UserID,
+		Config:         e.Config,
+		Cluster:        e.Cluster,
+		Operation:      step.Operation,
+		Resource:       step.Resource,
+		Parameters:     maps.Clone(step.Parameters),
+		SafetyLevel:    step.SafetyLevel,
+		DriftWarning:   step.DriftWarning,
+		AccessReview:   step.AccessReview,
+		PolicyDecision: step.PolicyDecision,
+		CorrelationID:  e.CorrelationID,
+		FreezeOverride: e.FreezeOverride,
+		PlanID:         e.ID,
+	}
+}
+
+// runPlan runs the steps of an approved or safe plan in order. The plan is saved as each step
+// starts and ends, so its progress can be followed; once a step fails the rest are skipped.
+func (s *Service) runPlan(ctx context.Context, plan Execution) (Execution, error) {
+	started := s.now()
+	plan.StartedAt = &started
+
+	var failed *PlanStep
+	for i := range plan.Steps {
+		step := &plan.Steps[i]
+		if failed != nil {
+			step.Status = StepSkipped
+			continue
+		}
+		execution := plan.stepExecution(*step)
+		execution.Status = StatusRunning
+		execution.CreatedAt = s.now()
+		step.Status = StepRunning
+		step.ExecutionID = execution.ID
+		if err := s.repo.Update(ctx, plan); err != nil {
+			return Execution{}, err
+		}
+		if err := s.repo.Create(ctx, execution); err != nil {
+			return Execution{}, err
+		}
+		result, err := s.run(ctx, execution)
+		switch {
+		case err != nil:
+			step.Status, step.Error = StepFailed, err.Error()
+		case result.Status != StatusSucceeded:
+			step.Status, step.Error = StepFailed, result.Error
+		default:
+			step.Status = StepSucceeded
+			plan.Changes = append(plan.Changes, result.Changes...)
+		}
+		if step.Status == StepFailed {
+			failed = step
+			s.log(ctx).Warn("command plan step failed", "id", plan.ID, "step", step.Sequence, "resource", step.Resource.String(), "error", step.Error)
+		}
+	}
+
+	completed := s.now()
+	plan.CompletedAt = &completed
+	lines := make([]string, 0, len(plan.Steps))
+	for _, step := range plan.Steps {
+		lines = append(lines, fmt.Sprintf("%d. %s %s: %s", step.Sequence, step.Operation, step.Resource, step.Status))
+	}
+	plan.Result = strings.Join(lines, "\n")
+	if failed != nil {
+		plan.Status = StatusFailed
+		plan.Error = fmt.Sprintf("step %d (%s %s) failed: %s", failed.Sequence, failed.Operation, failed.Resource, failed.Error)
+	} else {
+		plan.Status = StatusSucceeded
+	}
+	if err := s.repo.Update(ctx, plan); err != nil {
+		return Execution{}, err
+	}
+	s.observeExecution(plan)
+	s.log(ctx).Info("command plan finished", "id", plan.ID, "user", plan.UserID, "status", plan.Status, "steps", len(plan.Steps))
+	return plan, nil
+}
+
+// rollbackSteps rolls back the steps a plan completed, last first. Steps with nothing to
+// restore, such as restarts, are passed over; the plan counts as rolled back once any step was.
+func (s *Service) rollbackSteps(ctx context.Context, client kubernetes.Interface, plan Execution) ([]ObjectChange, error) {
+	if plan.Status != StatusSucceeded && plan.Status != StatusFailed {
+		return nil, ErrRollbackUnavailable
+	}
+	var changes []ObjectChange
+	rolledBack := 0
+	for i := len(plan.Steps) - 1; i >= 0; i-- {
+		step := plan.Steps[i]
+		if step.Status != StepSucceeded {
+			continue
+		}
+		restored, err := s.restoreExecution(ctx, client, step.ExecutionID)
+		changes = append(changes, restored...)
+		if errors.Is(err, ErrRollbackUnavailable) {
+			continue
+		}
+		if err != nil {
+			return changes, fmt.Errorf("step %d: %w", step.Sequence, err)
+		}
+		rolledBack++
+	}
+	if rolledBack == 0 {
+		return nil, ErrRollbackUnavailable
+	}
+	plan.Status = StatusRolledBack
+	if err := s.repo.Update(ctx, plan); err != nil {
+		return changes, err
+	}
+	s.log(ctx).Info("command plan rolled back", "id", plan.ID, "steps", rolledBack)
+	return changes, nil
+}
+
+// previewStepRollbacks reports what rollbackSteps would change.
+func (s *Service) previewStepRollbacks(ctx context.Context, plan Execution) (RollbackPreview, error) {
+	if plan.Status != StatusSucceeded && plan.Status != StatusFailed {
+		return RollbackPreview{}, ErrRollbackUnavailable
+	}
+	preview := RollbackPreview{ExecutionID: plan.ID, Applicable: true}
+	for i := len(plan.Steps) - 1; i >= 0; i-- {
+		step := plan.Steps[i]
+		if step.Status != StepSucceeded {
+			continue
+		}
+		stepPreview, err := s.PreviewRollback(ctx, step.ExecutionID)
+		if err != nil {
+			if errors.Is(err, ErrRollbackUnavailable) {
+				continue
+			}
+			return RollbackPreview{}, fmt.Errorf("step %d: %w", step.Sequence, err)
+		}
+		preview.Applicable = preview.Applicable && stepPreview.Applicable
+		preview.Steps = append(preview.Steps, stepPreview.Steps...)
+	}
+	if len(preview.Steps) == 0 {
+		return RollbackPreview{}, ErrRollbackUnavailable
+	}
+	return preview, nil
+}
+
+// reversibilityRank orders how hard a plan step is to undo.
+var reversibilityRank = map[Reversibility]int{
+	ReversibilityReadOnly:     0,
+	ReversibilityReversible:   1,
+	ReversibilityPartial:      2,
+	ReversibilityUnknown:      3,
+	ReversibilityIrreversible: 4,
+}
+
+// explainPlan describes a plan step by step. It is as reversible as its least reversible step.
+func (s *Service) explainPlan(plan Execution) Explanation {
+	explanation := Explanation{
+		Summary:       fmt.Sprintf("Run %d commands in order, stopping at the first that fails", len(plan.Steps)),
+		Reversibility: ReversibilityReadOnly,
+		Reversal:      "Rollback undoes the completed steps that recorded a rollback plan, last first",
+		Permissions:   []Permission{},
+	}
+	if query := plan.Parameters["query"]; query != "" {
+		explanation.Summary += ": " + query
+	}
+	commands := make([]string, 0, len(plan.Steps))
+	for _, step := range plan.Steps {
+		described := s.buildExplanation(plan.stepExecution(step))
+		commands = append(commands, described.Command)
+		explanation.Effects = append(explanation.Effects, fmt.Sprintf("Step %d: %s", step.Sequence, described.Summary))
+		explanation.Resources = append(explanation.Resources, described.Resources...)
+		explanation.Permissions = append(explanation.Permissions, described.Permissions...)
+		if reversibilityRank[described.Reversibility] > reversibilityRank[explanation.Reversibility] {
+			explanation.Reversibility = described.Reversibility
+		}
+	}
+	explanation.Command = strings.Join(commands, "\n")
+	if explanation.Reversibility == ReversibilityIrreversible {
+		explanation.Reversal = "Some steps cannot be undone; rollback undoes the completed steps that recorded a rollback plan, last first"
+	}
+	return explanation
+}
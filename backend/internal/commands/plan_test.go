@@ -0,0 +1,165 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func agedDeployment(name string, replicas int32, created time.Time) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop", CreationTimestamp: metav1.NewTime(created)},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+	}
+}
+
+func TestPlanExpandsStepsAndRunsThemInOrder(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	svc, client, repo := newTestService(
+		agedDeployment("web", 2, now.AddDate(0, 0, -30)),
+		agedDeployment("api", 2, now.AddDate(0, 0, -10)),
+		agedDeployment("fresh", 2, now.AddDate(0, 0, -1)),
+	)
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	plan, err := svc.SubmitPlan(ctx, PlanRequest{
+		UserID:  "alice",
+		Config:  "kubeconfig",
+		Cluster: "prod",
+		Query:   "restart all deployments in namespace shop that are older than 7 days",
+		Steps: []PlanStepRequest{{
+			Operation:  OperationRestart,
+			Resource:   Resource{Kind: "Deployment", Namespace: "shop"},
+			Parameters: map[string]string{"olderThan": "7d"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("submit plan: %v", err)
+	}
+	if plan.Status != StatusSucceeded || len(plan.Steps) != 2 || plan.Resource != (Resource{Kind: "Deployment", Namespace: "shop"}) {
+		t.Fatalf("expected both old deployments restarted, got %+v", plan)
+	}
+	for i, name := range []string{"api", "web"} {
+		step := plan.Steps[i]
+		if step.Resource.Name != name || step.Status != StepSucceeded || step.Parameters["olderThan"] != "" {
+			t.Fatalf("unexpected step %d: %+v", i+1, step)
+		}
+		execution, err := repo.GetByID(ctx, step.ExecutionID)
+		if err != nil || execution.PlanID != plan.ID || execution.CorrelationID != plan.CorrelationID || execution.Status != StatusSucceeded {
+			t.Fatalf("expected step %d to run as an execution of the plan, got %+v (%v)", i+1, execution, err)
+		}
+	}
+	fresh, _ := client.AppsV1().Deployments("shop").Get(ctx, "fresh", metav1.GetOptions{})
+	if _, restarted := fresh.Spec.Template.Annotations["kubectl.kubernetes.io/restartedAt"]; restarted {
+		t.Fatal("expected the deployment created yesterday to be left alone")
+	}
+
+	if _, err := svc.SubmitPlan(ctx, PlanRequest{UserID: "alice", Config: "kubeconfig", Cluster: "prod", Steps: []PlanStepRequest{{
+		Operation: OperationRestart, Resource: Resource{Kind: "Deployment", Namespace: "shop"}, Parameters: map[string]string{"olderThan": "90d"},
+	}}}); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected a plan matching nothing to be refused, got %v", err)
+	}
+}
+
+func TestPlanStopsAtFailureAndRollsBackCompletedSteps(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	svc, client, _ := newTestService(agedDeployment("api", 2, now), agedDeployment("web", 2, now))
+	ctx := context.Background()
+	scale := func(name, replicas string) PlanStepRequest {
+		return PlanStepRequest{Operation: OperationScale, Resource: Resource{Kind: "Deployment", Namespace: "shop", Name: name}, Parameters: map[string]string{"replicas": replicas}}
+	}
+
+	plan, err := svc.SubmitPlan(ctx, PlanRequest{UserID: "alice", Config: "kubeconfig", Cluster: "prod", Steps: []PlanStepRequest{
+		scale("api", "4"), scale("gone", "4"), scale("web", "4"),
+	}})
+	if err != nil {
+		t.Fatalf("submit plan: %v", err)
+	}
+	if plan.Status != StatusFailed || plan.Steps[0].Status != StepSucceeded || plan.Steps[1].Status != StepFailed || plan.Steps[2].Status != StepSkipped {
+		t.Fatalf("expected the plan to stop at the missing deployment, got %+v", plan)
+	}
+	web, _ := client.AppsV1().Deployments("shop").Get(ctx, "web", metav1.GetOptions{})
+	if *web.Spec.Replicas != 2 {
+		t.Fatalf("expected the skipped step not to run, got %d replicas", *web.Spec.Replicas)
+	}
+
+	rollback, err := svc.Rollback(ctx, plan.ID, "alice", nil)
+	if err != nil || rollback.Status != StatusSucceeded {
+		t.Fatalf("expected the rollback to run, got %+v (%v)", rollback, err)
+	}
+	if rolledBack, _ := svc.Get(ctx, plan.ID); rolledBack.Status != StatusRolledBack {
+		t.Fatalf("expected the completed step to be rolled back, got %+v", rolledBack)
+	}
+	api, _ := client.AppsV1().Deployments("shop").Get(ctx, "api", metav1.GetOptions{})
+	if *api.Spec.Replicas != 2 {
+		t.Fatalf("expected api restored to 2 replicas, got %d", *api.Spec.Replicas)
+	}
+}
+
+type disputedDrafts map[string]bool
+
+func (d disputedDrafts) RequiresApproval(ctx context.Context, draftID string) (bool, error) {
+	return d[draftID], nil
+}
+
+func TestPlanDisputedByRiskReviewAwaitsApproval(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	svc, client, _ := newTestService(agedDeployment("api", 2, now))
+	svc.SetPlanReviews(disputedDrafts{"draft-1": true})
+	ctx := context.Background()
+	steps := []PlanStepRequest{{Operation: OperationRestart, Resource: Resource{Kind: "Deployment", Namespace: "shop", Name: "api"}}}
+
+	plan, err := svc.SubmitPlan(ctx, PlanRequest{UserID: "alice", Config: "kubeconfig", Cluster: "prod", CorrelationID: "draft-1", Steps: steps})
+	if err != nil {
+		t.Fatalf("submit plan: %v", err)
+	}
+	if plan.Status != StatusAwaitingApproval || plan.SafetyLevel != SafetyWarning {
+		t.Fatalf("expected the disputed plan to await approval, got %+v", plan)
+	}
+	api, _ := client.AppsV1().Deployments("shop").Get(ctx, "api", metav1.GetOptions{})
+	if _, restarted := api.Spec.Template.Annotations["kubectl.kubernetes.io/restartedAt"]; restarted {
+		t.Fatal("expected the disputed plan not to run before approval")
+	}
+
+	undisputed, err := svc.SubmitPlan(ctx, PlanRequest{UserID: "alice", Config: "kubeconfig", Cluster: "prod", CorrelationID: "draft-2", Steps: steps})
+	if err != nil || undisputed.Status != StatusSucceeded {
+		t.Fatalf("expected an undisputed safe plan to run, got %+v (%v)", undisputed, err)
+	}
+}
+
+func TestPlanIsApprovedAsAWhole(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	svc, client, repo := newTestService(agedDeployment("api", 2, now), agedDeployment("web", 2, now))
+	ctx := context.Background()
+
+	plan, err := svc.SubmitPlan(ctx, PlanRequest{UserID: "alice", Config: "kubeconfig", Cluster: "prod", Steps: []PlanStepRequest{
+		{Operation: OperationScale, Resource: Resource{Kind: "Deployment", Namespace: "shop", Name: "api"}, Parameters: map[string]string{"replicas": "3"}},
+		{Operation: OperationDelete, Resource: Resource{Kind: "Deployment", Namespace: "shop", Name: "web"}},
+	}})
+	if err != nil {
+		t.Fatalf("submit plan: %v", err)
+	}
+	if plan.Status != StatusAwaitingApproval || plan.SafetyLevel != SafetyDangerous {
+		t.Fatalf("expected the delete to hold the whole plan for approval, got %+v", plan)
+	}
+	approval, _, err := svc.Approval(ctx, plan.ID)
+	if err != nil || len(approval.Explanation.Effects) != 2 || len(approval.Explanation.Resources) != 2 {
+		t.Fatalf("expected one approval explaining both steps, got %+v (%v)", approval, err)
+	}
+	if executions, _ := repo.ListByUser(ctx, "alice", 10, 0); len(executions) != 1 {
+		t.Fatalf("expected no step to run before approval, got %d executions", len(executions))
+	}
+
+	approved, err := svc.Approve(ctx, plan.ID, "bob", nil, "")
+	if err != nil || approved.Status != StatusSucceeded {
+		t.Fatalf("expected the approved plan to run, got %+v (%v)", approved, err)
+	}
+	if _, err := client.AppsV1().Deployments("shop").Get(ctx, "web", metav1.GetOptions{}); err == nil {
+		t.Fatal("expected web to be deleted")
+	}
+}
@@ -0,0 +1,166 @@
+package commands
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// ApprovalPolicy decides how many approvers, from which groups, an execution needs and how long they have.
+type ApprovalPolicy struct {
+	Name              string          `json:"name"`
+	Operations        []Operation     `json:"operations,omitempty"`
+	SafetyLevels      []SafetyLevel   `json:"safetyLevels,omitempty"`
+	Clusters          []string        `json:"clusters,omitempty"`
+	RequiredApprovals int             `json:"requiredApprovals"`
+	ApproverGroups    []string        `json:"approverGroups,omitempty"`
+	Expiry            metav1.Duration `json:"expiry,omitempty"`
+	EscalateAfter     metav1.Duration `json:"escalateAfter,omitempty"`
+	EscalationGroups  []string        `json:"escalationGroups,omitempty"`
+}
+
+func (p ApprovalPolicy) matches(execution Execution) bool {
+	// A plan matches the policies of the operations its steps perform.
+	if len(p.Operations) > 0 && !slices.ContainsFunc(execution.operations(), func(op Operation) bool { return slices.Contains(p.Operations, op) }) {
+		return false
+	}
+	if len(p.SafetyLevels) > 0 && !slices.Contains(p.SafetyLevels, execution.SafetyLevel) {
+		return false
+	}
+	if len(p.Clusters) > 0 && !slices.Contains(p.Clusters, execution.Cluster) {
+		return false
+	}
+	return true
+}
+
+// ApprovalPolicies holds the ordered policy list and the approver group membership it refers to.
+type ApprovalPolicies struct {
+	Groups   map[string][]string `json:"groups,omitempty"`
+	Policies []ApprovalPolicy    `json:"policies"`
+}
+
+// DefaultApprovalPolicy applies when no configured policy matches: one approver from anyone, within a day.
+var DefaultApprovalPolicy = ApprovalPolicy{
+	Name:              "default",
+	RequiredApprovals: 1,
+	Expiry:            metav1.Duration{Duration: 24 * time.Hour},
+}
+
+func LoadApprovalPolicies(path string) (*ApprovalPolicies, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var policies ApprovalPolicies
+	if err := yaml.UnmarshalStrict(raw, &policies); err != nil {
+		return nil, fmt.Errorf("parse approval policies %s: %w", path, err)
+	}
+	if err := policies.Validate(); err != nil {
+		return nil, err
+	}
+	return &policies, nil
+}
+
+func (p *ApprovalPolicies) Validate() error {
+	seen := make(map[string]bool)
+	for i, policy := range p.Policies {
+		if strings.TrimSpace(policy.Name) == "" {
+			return fmt.Errorf("approval policy %d has no name", i)
+		}
+		if seen[policy.Name] {
+			return fmt.Errorf("approval policy %q is defined twice", policy.Name)
+		}
+		seen[policy.Name] = true
+		if policy.RequiredApprovals < 1 {
+			return fmt.Errorf("approval policy %q must require at least one approval", policy.Name)
+		}
+		for _, group := range append(slices.Clone(policy.ApproverGroups), policy.EscalationGroups...) {
+			if _, ok := p.Groups[group]; !ok {
+				return fmt.Errorf("approval policy %q refers to unknown group %q", policy.Name, group)
+			}
+		}
+		if len(policy.ApproverGroups) > 0 && policy.RequiredApprovals > p.memberCount(policy.ApproverGroups) {
+			return fmt.Errorf("approval policy %q requires %d approvals but its groups have fewer members", policy.Name, policy.RequiredApprovals)
+		}
+	}
+	return nil
+}
+
+// Match returns the first policy that applies to execution, or DefaultApprovalPolicy.
+func (p *ApprovalPolicies) Match(execution Execution) ApprovalPolicy {
+	if p != nil {
+		for _, policy := range p.Policies {
+			if policy.matches(execution) {
+				return policy
+			}
+		}
+	}
+	return DefaultApprovalPolicy
+}
+
+func (p *ApprovalPolicies) Lookup(name string) (ApprovalPolicy, bool) {
+	if p != nil {
+		for _, policy := range p.Policies {
+			if policy.Name == name {
+				return policy, true
+			}
+		}
+	}
+	if name == DefaultApprovalPolicy.Name {
+		return DefaultApprovalPolicy, true
+	}
+	return ApprovalPolicy{}, false
+}
+
+// InAnyGroup reports whether user belongs to one of groups.
+func (p *ApprovalPolicies) InAnyGroup(user string, groups []string) bool {
+	if p == nil {
+		return false
+	}
+	for _, group := range groups {
+		if slices.Contains(p.Groups[group], user) {
+			return true
+		}
+	}
+	return false
+}
+
+// Members lists the users of groups, each once and sorted, leaving out except.
+func (p *ApprovalPolicies) Members(groups []string, except string) []string {
+	if p == nil {
+		return nil
+	}
+	var members []string
+	for _, group := range groups {
+		for _, user := range p.Groups[group] {
+			if user != except && !slices.Contains(members, user) {
+				members = append(members, user)
+			}
+		}
+	}
+	slices.Sort(members)
+	return members
+}
+
+func (p *ApprovalPolicies) groupNames() []string {
+	if p == nil {
+		return nil
+	}
+	return slices.Sorted(maps.Keys(p.Groups))
+}
+
+func (p *ApprovalPolicies) memberCount(groups []string) int {
+	members := make(map[string]bool)
+	for _, group := range groups {
+		for _, user := range p.Groups[group] {
+			members[user] = true
+		}
+	}
+	return len(members)
+}
@@ -0,0 +1,1065 @@
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pramodksahoo/kubechat/backend/internal/database"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+type PostgresRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresRepository applies the command schema migrations and returns a repository backed by db.
+func NewPostgresRepository(ctx context.Context, db *sqlx.DB) (*PostgresRepository, error) {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Migrate(ctx, db, "commands", sub); err != nil {
+		return nil, err
+	}
+	return &PostgresRepository{db: db}, nil
+}
+
+type executionRow struct {
+	ID                string       `db:"id"`
+	UserID            string       `db:"user_id"`
+	Config            string       `db:"config"`
+	Cluster           string       `db:"cluster"`
+	Operation         string       `db:"operation"`
+	ResourceKind      string       `db:"resource_kind"`
+	ResourceNamespace string       `db:"resource_namespace"`
+	ResourceName      string       `db:"resource_name"`
+	Parameters        []byte       `db:"parameters"`
+	SafetyLevel       string       `db:"safety_level"`
+	Status            string       `db:"status"`
+	Result            string       `db:"result"`
+	Error             string       `db:"error"`
+	CreatedAt         time.Time    `db:"created_at"`
+	StartedAt         sql.NullTime `db:"started_at"`
+	CompletedAt       sql.NullTime `db:"completed_at"`
+	DriftWarning      []byte       `db:"drift_warning"`
+	Attachments       []byte       `db:"attachments"`
+	AccessReview      []byte       `db:"access_review"`
+	ResultDiff        []byte       `db:"result_diff"`
+	PolicyDecision    []byte       `db:"policy_decision"`
+	TargetCluster     string       `db:"target_cluster"`
+	TargetNamespace   string       `db:"target_namespace"`
+	TargetKind        string       `db:"target_kind"`
+	TargetName        string       `db:"target_name"`
+	CorrelationID     string       `db:"correlation_id"`
+	Changes           []byte       `db:"changes"`
+	QueuedUntil       sql.NullTime `db:"queued_until"`
+	FreezeOverride    []byte       `db:"freeze_override"`
+	Steps             []byte       `db:"steps"`
+	PlanID            string       `db:"plan_id"`
+	Events            []byte       `db:"events"`
+	DeletedAt         sql.NullTime `db:"deleted_at"`
+	DeletedBy         string       `db:"deleted_by"`
+}
+
+func newExecutionRow(execution Execution) (executionRow, error) {
+	params, err := json.Marshal(execution.Parameters)
+	if err != nil {
+		return executionRow{}, err
+	}
+	if execution.Parameters == nil {
+		params = []byte("{}")
+	}
+	var drift []byte
+	if execution.DriftWarning != nil {
+		if drift, err = json.Marshal(execution.DriftWarning); err != nil {
+			return executionRow{}, err
+		}
+	}
+	var review []byte
+	if execution.AccessReview != nil {
+		if review, err = json.Marshal(execution.AccessReview); err != nil {
+			return executionRow{}, err
+		}
+	}
+	var diff []byte
+	if execution.Diff != nil {
+		if diff, err = json.Marshal(execution.Diff); err != nil {
+			return executionRow{}, err
+		}
+	}
+	var decision []byte
+	if execution.PolicyDecision != nil {
+		if decision, err = json.Marshal(execution.PolicyDecision); err != nil {
+			return executionRow{}, err
+		}
+	}
+	var attachments []byte
+	if len(execution.Attachments) > 0 {
+		// Key is not part of the JSON representation, so persist the stored form explicitly.
+		stored := make([]storedAttachment, 0, len(execution.Attachments))
+		for _, attachment := range execution.Attachments {
+			stored = append(stored, storedAttachment{Attachment: attachment, Key: attachment.Key})
+		}
+		if attachments, err = json.Marshal(stored); err != nil {
+			return executionRow{}, err
+		}
+	}
+	var changes []byte
+	if len(execution.Changes) > 0 {
+		if changes, err = json.Marshal(execution.Changes); err != nil {
+			return executionRow{}, err
+		}
+	}
+	var override []byte
+	if execution.FreezeOverride != nil {
+		if override, err = json.Marshal(execution.FreezeOverride); err != nil {
+			return executionRow{}, err
+		}
+	}
+	var steps []byte
+	if len(execution.Steps) > 0 {
+		if steps, err = json.Marshal(execution.Steps); err != nil {
+			return executionRow{}, err
+		}
+	}
+	var events []byte
+	if execution.Events != nil {
+		if events, err = json.Marshal(execution.Events); err != nil {
+			return executionRow{}, err
+		}
+	}
+	target := TargetOf(execution)
+	return executionRow{
+		ID:                execution.ID,
+		UserID:            execution.UserID,
+		Config:            execution.Config,
+		Cluster:           execution.Cluster,
+		Operation:         string(execution.Operation),
+		ResourceKind:      execution.Resource.Kind,
+		ResourceNamespace: execution.Resource.Namespace,
+		ResourceName:      execution.Resource.Name,
+		Parameters:        params,
+		SafetyLevel:       string(execution.SafetyLevel),
+		Status:            string(execution.Status),
+		Result:            execution.Result,
+		Error:             execution.Error,
+		CreatedAt:         execution.CreatedAt,
+		StartedAt:         nullTime(execution.StartedAt),
+		CompletedAt:       nullTime(execution.CompletedAt),
+		DriftWarning:      drift,
+		Attachments:       attachments,
+		AccessReview:      review,
+		ResultDiff:        diff,
+		PolicyDecision:    decision,
+		TargetCluster:     target.Cluster,
+		TargetNamespace:   target.Namespace,
+		TargetKind:        target.Kind,
+		TargetName:        target.Name,
+		CorrelationID:     execution.CorrelationID,
+		Changes:           changes,
+		QueuedUntil:       nullTime(execution.QueuedUntil),
+		FreezeOverride:    override,
+		Steps:             steps,
+		PlanID:            execution.PlanID,
+		Events:            events,
+		DeletedAt:         nullTime(execution.DeletedAt),
+		DeletedBy:         execution.DeletedBy,
+	}, nil
+}
+
+type storedAttachment struct {
+	Attachment
+	Key string `json:"key"`
+}
+
+func (row executionRow) execution() (Execution, error) {
+	var params map[string]string
+	if len(row.Parameters) > 0 {
+		if err := json.Unmarshal(row.Parameters, &params); err != nil {
+			return Execution{}, fmt.Errorf("decode parameters of %s: %w", row.ID, err)
+		}
+	}
+	if len(params) == 0 {
+		params = nil
+	}
+	var drift *DriftWarning
+	if len(row.DriftWarning) > 0 {
+		drift = &DriftWarning{}
+		if err := json.Unmarshal(row.DriftWarning, drift); err != nil {
+			return Execution{}, fmt.Errorf("decode drift warning of %s: %w", row.ID, err)
+		}
+	}
+	var review *AccessReview
+	if len(row.AccessReview) > 0 {
+		review = &AccessReview{}
+		if err := json.Unmarshal(row.AccessReview, review); err != nil {
+			return Execution{}, fmt.Errorf("decode access review of %s: %w", row.ID, err)
+		}
+	}
+	var diff *ResultDiff
+	if len(row.ResultDiff) > 0 {
+		diff = &ResultDiff{}
+		if err := json.Unmarshal(row.ResultDiff, diff); err != nil {
+			return Execution{}, fmt.Errorf("decode result diff of %s: %w", row.ID, err)
+		}
+	}
+	var decision *PolicyDecision
+	if len(row.PolicyDecision) > 0 {
+		decision = &PolicyDecision{}
+		if err := json.Unmarshal(row.PolicyDecision, decision); err != nil {
+			return Execution{}, fmt.Errorf("decode policy decision of %s: %w", row.ID, err)
+		}
+	}
+	var attachments []Attachment
+	if len(row.Attachments) > 0 {
+		var stored []storedAttachment
+		if err := json.Unmarshal(row.Attachments, &stored); err != nil {
+			return Execution{}, fmt.Errorf("decode attachments of %s: %w", row.ID, err)
+		}
+		for _, attachment := range stored {
+			attachment.Attachment.Key = attachment.Key
+			attachments = append(attachments, attachment.Attachment)
+		}
+	}
+	var changes []ObjectChange
+	if len(row.Changes) > 0 {
+		if err := json.Unmarshal(row.Changes, &changes); err != nil {
+			return Execution{}, fmt.Errorf("decode changes of %s: %w", row.ID, err)
+		}
+	}
+	var override *FreezeOverride
+	if len(row.FreezeOverride) > 0 {
+		override = &FreezeOverride{}
+		if err := json.Unmarshal(row.FreezeOverride, override); err != nil {
+			return Execution{}, fmt.Errorf("decode freeze override of %s: %w", row.ID, err)
+		}
+	}
+	var steps []PlanStep
+	if len(row.Steps) > 0 {
+		if err := json.Unmarshal(row.Steps, &steps); err != nil {
+			return Execution{}, fmt.Errorf("decode plan steps of %s: %w", row.ID, err)
+		}
+	}
+	var events *EventReport
+	if len(row.Events) > 0 {
+		events = &EventReport{}
+		if err := json.Unmarshal(row.Events, events); err != nil {
+			return Execution{}, fmt.Errorf("decode events of %s: %w", row.ID, err)
+		}
+	}
+	return Execution{
+		ID:             row.ID,
+		UserID:         row.UserID,
+		Config:         row.Config,
+		Cluster:        row.Cluster,
+		Operation:      Operation(row.Operation),
+		Resource:       Resource{Kind: row.ResourceKind, Namespace: row.ResourceNamespace, Name: row.ResourceName},
+		Parameters:     params,
+		SafetyLevel:    SafetyLevel(row.SafetyLevel),
+		Status:         Status(row.Status),
+		Result:         row.Result,
+		Error:          row.Error,
+		CreatedAt:      row.CreatedAt.UTC(),
+		StartedAt:      timePtr(row.StartedAt),
+		CompletedAt:    timePtr(row.CompletedAt),
+		DriftWarning:   drift,
+		AccessReview:   review,
+		PolicyDecision: decision,
+		Diff:           diff,
+		Attachments:    attachments,
+		CorrelationID:  row.CorrelationID,
+		Changes:        changes,
+		QueuedUntil:    timePtr(row.QueuedUntil),
+		FreezeOverride: override,
+		Steps:          steps,
+		PlanID:         row.PlanID,
+		Events:         events,
+		DeletedAt:      timePtr(row.DeletedAt),
+		DeletedBy:      row.DeletedBy,
+	}, nil
+}
+
+const executionColumns = `id, user_id, config, cluster, operation, resource_kind, resource_namespace, resource_name,
+	parameters, safety_level, status, result, error, created_at, started_at, completed_at, drift_warning, attachments,
+	access_review, target_cluster, target_namespace, target_kind, target_name, result_diff, policy_decision,
+	correlation_id, changes, queued_until, freeze_override, steps, plan_id, events, deleted_at, deleted_by`
+
+func (r *PostgresRepository) Create(ctx context.Context, execution Execution) error {
+	row, err := newExecutionRow(execution)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.NamedExecContext(ctx, `INSERT INTO kubernetes_command_executions (`+executionColumns+`)
+		VALUES (:id, :user_id, :config, :cluster, :operation, :resource_kind, :resource_namespace, :resource_name,
+			:parameters, :safety_level, :status, :result, :error, :created_at, :started_at, :completed_at, :drift_warning, :attachments,
+			:access_review, :target_cluster, :target_namespace, :target_kind, :target_name, :result_diff, :policy_decision,
+			:correlation_id, :changes, :queued_until, :freeze_override, :steps, :plan_id, :events, :deleted_at, :deleted_by)`, row)
+	return err
+}
+
+func (r *PostgresRepository) Update(ctx context.Context, execution Execution) error {
+	row, err := newExecutionRow(execution)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.NamedExecContext(ctx, `UPDATE kubernetes_command_executions SET
+		parameters = :parameters, safety_level = :safety_level, status = :status, result = :result,
+		error = :error, started_at = :started_at, completed_at = :completed_at, attachments = :attachments,
+		result_diff = :result_diff, changes = :changes, queued_until = :queued_until, freeze_override = :freeze_override,
+		steps = :steps, events = :events
+		WHERE id = :id`, row)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrExecutionNotFound{ID: execution.ID}
+	}
+	return nil
+}
+
+func (r *PostgresRepository) GetByID(ctx context.Context, id string) (Execution, error) {
+	var row executionRow
+	err := r.db.GetContext(ctx, &row, `SELECT `+executionColumns+` FROM kubernetes_command_executions WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Execution{}, ErrExecutionNotFound{ID: id}
+	}
+	if err != nil {
+		return Execution{}, err
+	}
+	return row.execution()
+}
+
+func (r *PostgresRepository) ListByUser(ctx context.Context, userID string, limit, offset int) ([]Execution, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	var rows []executionRow
+	err := r.db.SelectContext(ctx, &rows, `SELECT `+executionColumns+` FROM kubernetes_command_executions
+		WHERE ($1 = '' OR user_id = $1) AND deleted_at IS NULL
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3`, userID, limit, max(offset, 0))
+	if err != nil {
+		return nil, err
+	}
+	executions := make([]Execution, 0, len(rows))
+	for _, row := range rows {
+		execution, err := row.execution()
+		if err != nil {
+			return nil, err
+		}
+		executions = append(executions, execution)
+	}
+	return executions, nil
+}
+
+func (r *PostgresRepository) ListByTarget(ctx context.Context, filter AuditFilter) ([]Execution, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	}
+	var rows []executionRow
+	err := r.db.SelectContext(ctx, &rows, `SELECT `+executionColumns+` FROM kubernetes_command_executions
+		WHERE target_kind = $1 AND target_namespace = $2 AND target_name = $3 AND ($4 = '' OR target_cluster = $4)
+		ORDER BY created_at DESC
+		LIMIT $5 OFFSET $6`, filter.Target.Kind, filter.Target.Namespace, filter.Target.Name, filter.Target.Cluster, filter.Limit, max(filter.Offset, 0))
+	if err != nil {
+		return nil, err
+	}
+	executions := make([]Execution, 0, len(rows))
+	for _, row := range rows {
+		execution, err := row.execution()
+		if err != nil {
+			return nil, err
+		}
+		executions = append(executions, execution)
+	}
+	return executions, nil
+}
+
+func (r *PostgresRepository) ListByCorrelation(ctx context.Context, correlationID string) ([]Execution, error) {
+	return r.selectExecutions(ctx, `SELECT `+executionColumns+` FROM kubernetes_command_executions
+		WHERE correlation_id = $1 ORDER BY created_at`, correlationID)
+}
+
+func (r *PostgresRepository) ListByStatus(ctx context.Context, status Status) ([]Execution, error) {
+	return r.selectExecutions(ctx, `SELECT `+executionColumns+` FROM kubernetes_command_executions
+		WHERE status = $1 ORDER BY created_at`, string(status))
+}
+
+func (r *PostgresRepository) selectExecutions(ctx context.Context, query string, args ...any) ([]Execution, error) {
+	var rows []executionRow
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, err
+	}
+	executions := make([]Execution, 0, len(rows))
+	for _, row := range rows {
+		execution, err := row.execution()
+		if err != nil {
+			return nil, err
+		}
+		executions = append(executions, execution)
+	}
+	return executions, nil
+}
+
+func (r *PostgresRepository) MarkDeleted(ctx context.Context, id, user string, at time.Time) error {
+	result, err := r.db.ExecContext(ctx, `UPDATE kubernetes_command_executions SET deleted_at = $2, deleted_by = $3 WHERE id = $1`, id, at, user)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrExecutionNotFound{ID: id}
+	}
+	return nil
+}
+
+// purgeableCondition selects the executions of the PurgeFilter given by purgeableArgs.
+const purgeableCondition = `created_at < $1
+	AND status NOT IN ($4, $5, $6)
+	AND user_id NOT IN (SELECT jsonb_array_elements_text($2::jsonb))
+	AND id NOT IN (SELECT jsonb_array_elements_text($3::jsonb))`
+
+func purgeableArgs(filter PurgeFilter) ([]any, error) {
+	keepUsers, err := json.Marshal(append([]string{}, filter.KeepUsers...))
+	if err != nil {
+		return nil, err
+	}
+	keepIDs, err := json.Marshal(append([]string{}, filter.KeepIDs...))
+	if err != nil {
+		return nil, err
+	}
+	return []any{filter.Cutoff, string(keepUsers), string(keepIDs),
+		string(StatusAwaitingApproval), string(StatusRunning), string(StatusQueued)}, nil
+}
+
+func (r *PostgresRepository) ListPurgeable(ctx context.Context, filter PurgeFilter) ([]Execution, error) {
+	args, err := purgeableArgs(filter)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + executionColumns + ` FROM kubernetes_command_executions WHERE ` + purgeableCondition + ` ORDER BY created_at, id`
+	if filter.Limit > 0 {
+		query += ` LIMIT $7`
+		args = append(args, filter.Limit)
+	}
+	return r.selectExecutions(ctx, query, args...)
+}
+
+func (r *PostgresRepository) CountPurgeable(ctx context.Context, filter PurgeFilter) (int, error) {
+	args, err := purgeableArgs(filter)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	err = r.db.GetContext(ctx, &count, `SELECT COUNT(*) FROM kubernetes_command_executions WHERE `+purgeableCondition, args...)
+	return count, err
+}
+
+func (r *PostgresRepository) PseudonymizeUser(ctx context.Context, user, pseudonym string) (int, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	var changed []string
+	for _, query := range []string{
+		`UPDATE kubernetes_command_executions SET user_id = $2 WHERE user_id = $1 RETURNING id`,
+		`UPDATE command_approvals SET
+			version = version + 1,
+			requested_by = CASE WHEN requested_by = $1 THEN $2 ELSE requested_by END,
+			decided_by = CASE WHEN decided_by = $1 THEN $2 ELSE decided_by END,
+			decisions = (SELECT COALESCE(jsonb_agg(
+				CASE WHEN d->>'user' = $1 THEN jsonb_set(d, '{user}', to_jsonb($2::text)) ELSE d END
+					|| CASE WHEN d->>'onBehalfOf' = $1 THEN jsonb_build_object('onBehalfOf', $2::text) ELSE '{}'::jsonb END
+				ORDER BY n), '[]'::jsonb) FROM jsonb_array_elements(decisions) WITH ORDINALITY AS e(d, n))
+		WHERE requested_by = $1 OR decided_by = $1
+			OR EXISTS (SELECT 1 FROM jsonb_array_elements(decisions) d WHERE d->>'user' = $1 OR d->>'onBehalfOf' = $1)
+		RETURNING execution_id`,
+		`UPDATE command_approval_events SET
+			actor = CASE WHEN actor = $1 THEN $2 ELSE actor END,
+			on_behalf_of = CASE WHEN on_behalf_of = $1 THEN $2 ELSE on_behalf_of END
+		WHERE actor = $1 OR on_behalf_of = $1
+		RETURNING execution_id`,
+	} {
+		var ids []string
+		if err := tx.SelectContext(ctx, &ids, query, user, pseudonym); err != nil {
+			return 0, err
+		}
+		changed = append(changed, ids...)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	slices.Sort(changed)
+	return len(slices.Compact(changed)), nil
+}
+
+func (r *PostgresRepository) DeleteFinished(ctx context.Context, ids []string) ([]Execution, error) {
+	encoded, err := json.Marshal(append([]string{}, ids...))
+	if err != nil {
+		return nil, err
+	}
+	return r.selectExecutions(ctx, `DELETE FROM kubernetes_command_executions
+		WHERE id IN (SELECT jsonb_array_elements_text($1::jsonb))
+			AND status NOT IN ($2, $3, $4)
+		RETURNING `+executionColumns, string(encoded), string(StatusAwaitingApproval), string(StatusRunning), string(StatusQueued))
+}
+
+type approvalRow struct {
+	ID                string       `db:"id"`
+	ExecutionID       string       `db:"execution_id"`
+	RequestedBy       string       `db:"requested_by"`
+	PolicyName        string       `db:"policy_name"`
+	RequiredApprovals int          `db:"required_approvals"`
+	ApproverGroups    []byte       `db:"approver_groups"`
+	Decisions         []byte       `db:"decisions"`
+	DecidedBy         string       `db:"decided_by"`
+	Status            string       `db:"status"`
+	Reason            string       `db:"reason"`
+	CreatedAt         time.Time    `db:"created_at"`
+	DecidedAt         sql.NullTime `db:"decided_at"`
+	ExpiresAt         sql.NullTime `db:"expires_at"`
+	EscalateAt        sql.NullTime `db:"escalate_at"`
+	EscalatedAt       sql.NullTime `db:"escalated_at"`
+	Explanation       []byte       `db:"explanation"`
+	Version           int          `db:"version"`
+}
+
+const approvalColumns = `id, execution_id, requested_by, policy_name, required_approvals, approver_groups, decisions,
+	decided_by, status, reason, created_at, decided_at, expires_at, escalate_at, escalated_at, explanation, version`
+
+func (row approvalRow) approval() (Approval, error) {
+	approval := Approval{
+		ID:                row.ID,
+		ExecutionID:       row.ExecutionID,
+		RequestedBy:       row.RequestedBy,
+		PolicyName:        row.PolicyName,
+		RequiredApprovals: row.RequiredApprovals,
+		DecidedBy:         row.DecidedBy,
+		Status:            ApprovalStatus(row.Status),
+		Reason:            row.Reason,
+		CreatedAt:         row.CreatedAt.UTC(),
+		DecidedAt:         timePtr(row.DecidedAt),
+		ExpiresAt:         timePtr(row.ExpiresAt),
+		EscalateAt:        timePtr(row.EscalateAt),
+		EscalatedAt:       timePtr(row.EscalatedAt),
+		Version:           row.Version,
+	}
+	if err := json.Unmarshal(row.ApproverGroups, &approval.ApproverGroups); err != nil {
+		return Approval{}, fmt.Errorf("decode approver groups of %s: %w", row.ExecutionID, err)
+	}
+	if err := json.Unmarshal(row.Decisions, &approval.Decisions); err != nil {
+		return Approval{}, fmt.Errorf("decode decisions of %s: %w", row.ExecutionID, err)
+	}
+	if len(row.Explanation) > 0 {
+		approval.Explanation = &Explanation{}
+		if err := json.Unmarshal(row.Explanation, approval.Explanation); err != nil {
+			return Approval{}, fmt.Errorf("decode explanation of %s: %w", row.ExecutionID, err)
+		}
+	}
+	return approval, nil
+}
+
+func (r *PostgresRepository) SaveApproval(ctx context.Context, approval Approval) error {
+	groups, err := json.Marshal(nonNil(approval.ApproverGroups))
+	if err != nil {
+		return err
+	}
+	decisions, err := json.Marshal(nonNil(approval.Decisions))
+	if err != nil {
+		return err
+	}
+	var explanation []byte
+	if approval.Explanation != nil {
+		if explanation, err = json.Marshal(approval.Explanation); err != nil {
+			return err
+		}
+	}
+	row := approvalRow{
+		ID:                approval.ID,
+		ExecutionID:       approval.ExecutionID,
+		RequestedBy:       approval.RequestedBy,
+		PolicyName:        approval.PolicyName,
+		RequiredApprovals: approval.RequiredApprovals,
+		ApproverGroups:    groups,
+		Decisions:         decisions,
+		DecidedBy:         approval.DecidedBy,
+		Status:            string(approval.Status),
+		Reason:            approval.Reason,
+		CreatedAt:         approval.CreatedAt,
+		DecidedAt:         nullTime(approval.DecidedAt),
+		ExpiresAt:         nullTime(approval.ExpiresAt),
+		EscalateAt:        nullTime(approval.EscalateAt),
+		EscalatedAt:       nullTime(approval.EscalatedAt),
+		Explanation:       explanation,
+		Version:           approval.Version + 1,
+	}
+	// The update only applies to the version approval was read at, so of two decisions made
+	// concurrently on the same approval only the first is stored.
+	result, err := r.db.NamedExecContext(ctx, `INSERT INTO command_approvals (`+approvalColumns+`)
+		VALUES (:id, :execution_id, :requested_by, :policy_name, :required_approvals, :approver_groups, :decisions,
+			:decided_by, :status, :reason, :created_at, :decided_at, :expires_at, :escalate_at, :escalated_at, :explanation, :version)
+		ON CONFLICT (execution_id) DO UPDATE SET
+			approver_groups = EXCLUDED.approver_groups, decisions = EXCLUDED.decisions,
+			decided_by = EXCLUDED.decided_by, status = EXCLUDED.status, reason = EXCLUDED.reason,
+			decided_at = EXCLUDED.decided_at, escalated_at = EXCLUDED.escalated_at, version = EXCLUDED.version
+		WHERE command_approvals.version = EXCLUDED.version - 1`, row)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrApprovalConflict
+	}
+	return nil
+}
+
+func (r *PostgresRepository) GetApproval(ctx context.Context, executionID string) (Approval, error) {
+	var row approvalRow
+	err := r.db.GetContext(ctx, &row, `SELECT `+approvalColumns+` FROM command_approvals WHERE execution_id = $1`, executionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Approval{}, ErrApprovalNotFound{ExecutionID: executionID}
+	}
+	if err != nil {
+		return Approval{}, err
+	}
+	return row.approval()
+}
+
+func (r *PostgresRepository) ListApprovals(ctx context.Context, status ApprovalStatus) ([]Approval, error) {
+	var rows []approvalRow
+	err := r.db.SelectContext(ctx, &rows, `SELECT `+approvalColumns+` FROM command_approvals
+		WHERE ($1 = '' OR status = $1) ORDER BY created_at`, string(status))
+	if err != nil {
+		return nil, err
+	}
+	approvals := make([]Approval, 0, len(rows))
+	for _, row := range rows {
+		approval, err := row.approval()
+		if err != nil {
+			return nil, err
+		}
+		approvals = append(approvals, approval)
+	}
+	return approvals, nil
+}
+
+func (r *PostgresRepository) AppendApprovalEvent(ctx context.Context, event ApprovalEvent) error {
+	_, err := r.db.ExecContext(ctx, `INSERT INTO command_approval_events (id, execution_id, actor, on_behalf_of, action, reason, at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		event.ID, event.ExecutionID, event.Actor, event.OnBehalfOf, string(event.Action), event.Reason, event.At)
+	return err
+}
+
+func (r *PostgresRepository) ListApprovalEvents(ctx context.Context, executionID string) ([]ApprovalEvent, error) {
+	var rows []struct {
+		ID          string    `db:"id"`
+		ExecutionID string    `db:"execution_id"`
+		Actor       string    `db:"actor"`
+		OnBehalfOf  string    `db:"on_behalf_of"`
+		Action      string    `db:"action"`
+		Reason      string    `db:"reason"`
+		At          time.Time `db:"at"`
+	}
+	err := r.db.SelectContext(ctx, &rows, `SELECT id, execution_id, actor, on_behalf_of, action, reason, at
+		FROM command_approval_events WHERE execution_id = $1 ORDER BY at, id`, executionID)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]ApprovalEvent, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, ApprovalEvent{
+			ID:          row.ID,
+			ExecutionID: row.ExecutionID,
+			Actor:       row.Actor,
+			OnBehalfOf:  row.OnBehalfOf,
+			Action:      ApprovalAction(row.Action),
+			Reason:      row.Reason,
+			At:          row.At.UTC(),
+		})
+	}
+	return events, nil
+}
+
+type delegationRow struct {
+	ID        string       `db:"id"`
+	Delegator string       `db:"delegator"`
+	Delegate  string       `db:"delegate"`
+	StartsAt  time.Time    `db:"starts_at"`
+	EndsAt    time.Time    `db:"ends_at"`
+	Reason    string       `db:"reason"`
+	CreatedAt time.Time    `db:"created_at"`
+	RevokedAt sql.NullTime `db:"revoked_at"`
+}
+
+func (row delegationRow) delegation() ApprovalDelegation {
+	return ApprovalDelegation{
+		ID:        row.ID,
+		Delegator: row.Delegator,
+		Delegate:  row.Delegate,
+		StartsAt:  row.StartsAt.UTC(),
+		EndsAt:    row.EndsAt.UTC(),
+		Reason:    row.Reason,
+		CreatedAt: row.CreatedAt.UTC(),
+		RevokedAt: timePtr(row.RevokedAt),
+	}
+}
+
+const delegationColumns = `id, delegator, delegate, starts_at, ends_at, reason, created_at, revoked_at`
+
+func (r *PostgresRepository) SaveDelegation(ctx context.Context, delegation ApprovalDelegation) error {
+	_, err := r.db.ExecContext(ctx, `INSERT INTO approval_delegations (`+delegationColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (id) DO UPDATE SET revoked_at = EXCLUDED.revoked_at`,
+		delegation.ID, delegation.Delegator, delegation.Delegate, delegation.StartsAt, delegation.EndsAt,
+		delegation.Reason, delegation.CreatedAt, nullTime(delegation.RevokedAt))
+	return err
+}
+
+func (r *PostgresRepository) GetDelegation(ctx context.Context, id string) (ApprovalDelegation, error) {
+	var row delegationRow
+	err := r.db.GetContext(ctx, &row, `SELECT `+delegationColumns+` FROM approval_delegations WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ApprovalDelegation{}, ErrDelegationNotFound{ID: id}
+	}
+	if err != nil {
+		return ApprovalDelegation{}, err
+	}
+	return row.delegation(), nil
+}
+
+func (r *PostgresRepository) ListDelegations(ctx context.Context) ([]ApprovalDelegation, error) {
+	var rows []delegationRow
+	if err := r.db.SelectContext(ctx, &rows, `SELECT `+delegationColumns+` FROM approval_delegations ORDER BY starts_at, id`); err != nil {
+		return nil, err
+	}
+	delegations := make([]ApprovalDelegation, 0, len(rows))
+	for _, row := range rows {
+		delegations = append(delegations, row.delegation())
+	}
+	return delegations, nil
+}
+
+type scheduleRow struct {
+	ID              string       `db:"id"`
+	UserID          string       `db:"user_id"`
+	Request         []byte       `db:"request"`
+	Groups          []byte       `db:"groups"`
+	Workspace       string       `db:"workspace"`
+	RunAt           sql.NullTime `db:"run_at"`
+	Cron            string       `db:"cron"`
+	Timezone        string       `db:"timezone"`
+	Status          string       `db:"status"`
+	NextRunAt       sql.NullTime `db:"next_run_at"`
+	LastRunAt       sql.NullTime `db:"last_run_at"`
+	LastExecutionID string       `db:"last_execution_id"`
+	LastError       string       `db:"last_error"`
+	CreatedAt       time.Time    `db:"created_at"`
+	CancelledAt     sql.NullTime `db:"cancelled_at"`
+	CancelledBy     string       `db:"cancelled_by"`
+}
+
+func (row scheduleRow) schedule() (CommandSchedule, error) {
+	schedule := CommandSchedule{
+		ID:              row.ID,
+		UserID:          row.UserID,
+		RunAt:           timePtr(row.RunAt),
+		Cron:            row.Cron,
+		Timezone:        row.Timezone,
+		Status:          ScheduleStatus(row.Status),
+		NextRunAt:       timePtr(row.NextRunAt),
+		LastRunAt:       timePtr(row.LastRunAt),
+		LastExecutionID: row.LastExecutionID,
+		LastError:       row.LastError,
+		CreatedAt:       row.CreatedAt.UTC(),
+		CancelledAt:     timePtr(row.CancelledAt),
+		CancelledBy:     row.CancelledBy,
+	}
+	if err := json.Unmarshal(row.Request, &schedule.Request); err != nil {
+		return CommandSchedule{}, fmt.Errorf("decode request of schedule %s: %w", row.ID, err)
+	}
+	if err := json.Unmarshal(row.Groups, &schedule.Request.Groups); err != nil {
+		return CommandSchedule{}, fmt.Errorf("decode groups of schedule %s: %w", row.ID, err)
+	}
+	// The requester's identity is not part of the request's JSON form.
+	schedule.Request.UserID, schedule.Request.Workspace = row.UserID, row.Workspace
+	return schedule, nil
+}
+
+const scheduleColumns = `id, user_id, request, groups, workspace, run_at, cron, timezone, status, next_run_at,
+	last_run_at, last_execution_id, last_error, created_at, cancelled_at, cancelled_by`
+
+func (r *PostgresRepository) SaveSchedule(ctx context.Context, schedule CommandSchedule) error {
+	request, err := json.Marshal(schedule.Request)
+	if err != nil {
+		return err
+	}
+	groups, err := json.Marshal(nonNil(schedule.Request.Groups))
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `INSERT INTO command_schedules (`+scheduleColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		ON CONFLICT (id) DO UPDATE SET
+			status = EXCLUDED.status, next_run_at = EXCLUDED.next_run_at, last_run_at = EXCLUDED.last_run_at,
+			last_execution_id = EXCLUDED.last_execution_id, last_error = EXCLUDED.last_error,
+			cancelled_at = EXCLUDED.cancelled_at, cancelled_by = EXCLUDED.cancelled_by
+		WHERE command_schedules.status <> 'cancelled'`,
+		schedule.ID, schedule.UserID, request, groups, schedule.Request.Workspace, nullTime(schedule.RunAt),
+		schedule.Cron, schedule.Timezone, string(schedule.Status), nullTime(schedule.NextRunAt), nullTime(schedule.LastRunAt),
+		schedule.LastExecutionID, schedule.LastError, schedule.CreatedAt, nullTime(schedule.CancelledAt), schedule.CancelledBy)
+	return err
+}
+
+func (r *PostgresRepository) GetSchedule(ctx context.Context, id string) (CommandSchedule, error) {
+	var row scheduleRow
+	err := r.db.GetContext(ctx, &row, `SELECT `+scheduleColumns+` FROM command_schedules WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return CommandSchedule{}, ErrScheduleNotFound{ID: id}
+	}
+	if err != nil {
+		return CommandSchedule{}, err
+	}
+	return row.schedule()
+}
+
+func (r *PostgresRepository) ListSchedules(ctx context.Context, userID string) ([]CommandSchedule, error) {
+	return r.selectSchedules(ctx, `SELECT `+scheduleColumns+` FROM command_schedules WHERE user_id = $1 ORDER BY created_at DESC`, userID)
+}
+
+func (r *PostgresRepository) ListDueSchedules(ctx context.Context, at time.Time) ([]CommandSchedule, error) {
+	return r.selectSchedules(ctx, `SELECT `+scheduleColumns+` FROM command_schedules
+		WHERE status = $1 AND next_run_at <= $2 ORDER BY next_run_at`, string(ScheduleActive), at)
+}
+
+func (r *PostgresRepository) selectSchedules(ctx context.Context, query string, args ...any) ([]CommandSchedule, error) {
+	var rows []scheduleRow
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, err
+	}
+	schedules := make([]CommandSchedule, 0, len(rows))
+	for _, row := range rows {
+		schedule, err := row.schedule()
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, nil
+}
+
+type changeWindowRow struct {
+	ID        string       `db:"id"`
+	Name      string       `db:"name"`
+	Cluster   string       `db:"cluster"`
+	Namespace string       `db:"namespace"`
+	StartsAt  sql.NullTime `db:"starts_at"`
+	EndsAt    sql.NullTime `db:"ends_at"`
+	Cron      string       `db:"cron"`
+	Duration  int64        `db:"duration_seconds"`
+	Timezone  string       `db:"timezone"`
+	Mode      string       `db:"mode"`
+	Reason    string       `db:"reason"`
+	CreatedBy string       `db:"created_by"`
+	CreatedAt time.Time    `db:"created_at"`
+}
+
+func (row changeWindowRow) window() ChangeWindow {
+	return ChangeWindow{
+		ID:        row.ID,
+		Name:      row.Name,
+		Cluster:   row.Cluster,
+		Namespace: row.Namespace,
+		StartsAt:  timePtr(row.StartsAt),
+		EndsAt:    timePtr(row.EndsAt),
+		Cron:      row.Cron,
+		Duration:  metav1.Duration{Duration: time.Duration(row.Duration) * time.Second},
+		Timezone:  row.Timezone,
+		Mode:      FreezeMode(row.Mode),
+		Reason:    row.Reason,
+		CreatedBy: row.CreatedBy,
+		CreatedAt: row.CreatedAt.UTC(),
+	}
+}
+
+const changeWindowColumns = `id, name, cluster, namespace, starts_at, ends_at, cron, duration_seconds, timezone, mode,
+	reason, created_by, created_at`
+
+func (r *PostgresRepository) SaveChangeWindow(ctx context.Context, window ChangeWindow) error {
+	_, err := r.db.ExecContext(ctx, `INSERT INTO change_windows (`+changeWindowColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		window.ID, window.Name, window.Cluster, window.Namespace, nullTime(window.StartsAt), nullTime(window.EndsAt),
+		window.Cron, int64(window.Duration.Seconds()), window.Timezone, string(window.Mode), window.Reason,
+		window.CreatedBy, window.CreatedAt)
+	return err
+}
+
+func (r *PostgresRepository) ListChangeWindows(ctx context.Context) ([]ChangeWindow, error) {
+	var rows []changeWindowRow
+	if err := r.db.SelectContext(ctx, &rows, `SELECT `+changeWindowColumns+` FROM change_windows ORDER BY created_at DESC`); err != nil {
+		return nil, err
+	}
+	windows := make([]ChangeWindow, 0, len(rows))
+	for _, row := range rows {
+		windows = append(windows, row.window())
+	}
+	return windows, nil
+}
+
+func (r *PostgresRepository) DeleteChangeWindow(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM change_windows WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrChangeWindowNotFound{ID: id}
+	}
+	return nil
+}
+
+func (r *PostgresRepository) SaveRollbackPlan(ctx context.Context, plan RollbackPlan) error {
+	steps, err := json.Marshal(plan.Steps)
+	if err != nil {
+		return err
+	}
+	changes, err := json.Marshal(nonNil(plan.Changes))
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `INSERT INTO rollback_plans (id, execution_id, steps, executed, created_at, executed_at, changes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (execution_id) DO UPDATE SET
+			steps = EXCLUDED.steps, executed = EXCLUDED.executed, executed_at = EXCLUDED.executed_at, changes = EXCLUDED.changes`,
+		plan.ID, plan.ExecutionID, steps, plan.Executed, plan.CreatedAt, nullTime(plan.ExecutedAt), changes)
+	return err
+}
+
+func (r *PostgresRepository) GetRollbackPlan(ctx context.Context, executionID string) (RollbackPlan, error) {
+	var row struct {
+		ID          string       `db:"id"`
+		ExecutionID string       `db:"execution_id"`
+		Steps       []byte       `db:"steps"`
+		Executed    bool         `db:"executed"`
+		CreatedAt   time.Time    `db:"created_at"`
+		ExecutedAt  sql.NullTime `db:"executed_at"`
+		Changes     []byte       `db:"changes"`
+	}
+	err := r.db.GetContext(ctx, &row, `SELECT id, execution_id, steps, executed, created_at, executed_at, changes
+		FROM rollback_plans WHERE execution_id = $1`, executionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return RollbackPlan{}, ErrRollbackNotFound{ExecutionID: executionID}
+	}
+	if err != nil {
+		return RollbackPlan{}, err
+	}
+	plan := RollbackPlan{
+		ID:          row.ID,
+		ExecutionID: row.ExecutionID,
+		Executed:    row.Executed,
+		CreatedAt:   row.CreatedAt.UTC(),
+		ExecutedAt:  timePtr(row.ExecutedAt),
+	}
+	if err := json.Unmarshal(row.Steps, &plan.Steps); err != nil {
+		return RollbackPlan{}, fmt.Errorf("decode rollback plan for %s: %w", executionID, err)
+	}
+	if err := json.Unmarshal(row.Changes, &plan.Changes); err != nil {
+		return RollbackPlan{}, fmt.Errorf("decode rollback changes for %s: %w", executionID, err)
+	}
+	if len(plan.Changes) == 0 {
+		plan.Changes = nil
+	}
+	return plan, nil
+}
+
+func (r *PostgresRepository) SaveReadSnapshot(ctx context.Context, snapshot ReadSnapshot) error {
+	objects, err := json.Marshal(nonNil(snapshot.Objects))
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `INSERT INTO command_read_snapshots (signature, execution_id, objects, taken_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (signature) DO UPDATE SET
+			execution_id = EXCLUDED.execution_id, objects = EXCLUDED.objects, taken_at = EXCLUDED.taken_at`,
+		snapshot.Signature, snapshot.ExecutionID, objects, snapshot.TakenAt)
+	return err
+}
+
+func (r *PostgresRepository) GetReadSnapshot(ctx context.Context, signature string) (ReadSnapshot, error) {
+	var row struct {
+		Signature   string    `db:"signature"`
+		ExecutionID string    `db:"execution_id"`
+		Objects     []byte    `db:"objects"`
+		TakenAt     time.Time `db:"taken_at"`
+	}
+	err := r.db.GetContext(ctx, &row, `SELECT signature, execution_id, objects, taken_at
+		FROM command_read_snapshots WHERE signature = $1`, signature)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ReadSnapshot{}, ErrNoReadSnapshot
+	}
+	if err != nil {
+		return ReadSnapshot{}, err
+	}
+	snapshot := ReadSnapshot{Signature: row.Signature, ExecutionID: row.ExecutionID, TakenAt: row.TakenAt.UTC()}
+	if err := json.Unmarshal(row.Objects, &snapshot.Objects); err != nil {
+		return ReadSnapshot{}, fmt.Errorf("decode read snapshot %s: %w", signature, err)
+	}
+	return snapshot, nil
+}
+
+func (r *PostgresRepository) RedeemApprovalLink(ctx context.Context, executionID, recipient string, expires, now time.Time) (bool, error) {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM approval_link_redemptions WHERE expires_at < $1`, now); err != nil {
+		return false, err
+	}
+	result, err := r.db.ExecContext(ctx, `INSERT INTO approval_link_redemptions (execution_id, recipient, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (execution_id, recipient) DO NOTHING`, executionID, recipient, expires)
+	if err != nil {
+		return false, err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return inserted == 1, nil
+}
+
+func (r *PostgresRepository) ApprovalLinkRedeemed(ctx context.Context, executionID, recipient string) (bool, error) {
+	var used bool
+	err := r.db.GetContext(ctx, &used, `SELECT EXISTS (SELECT 1 FROM approval_link_redemptions WHERE execution_id = $1 AND recipient = $2)`, executionID, recipient)
+	return used, err
+}
+
+func (r *PostgresRepository) ReleaseApprovalLink(ctx context.Context, executionID, recipient string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM approval_link_redemptions WHERE execution_id = $1 AND recipient = $2`, executionID, recipient)
+	return err
+}
+
+func nonNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	v := t.Time.UTC()
+	return &v
+}
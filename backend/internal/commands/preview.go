@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"context"
+	"strings"
+)
+
+// Preview describes what submitting a request would do, without running it.
+type Preview struct {
+	Config      string      `json:"config"`
+	Cluster     string      `json:"cluster"`
+	Operation   Operation   `json:"operation"`
+	Resource    Resource    `json:"resource"`
+	SafetyLevel SafetyLevel `json:"safetyLevel"`
+	// Classification traces how the safety rules reached SafetyLevel; it is nil for delegated operations.
+	Classification *Classification `json:"classification,omitempty"`
+	// RequiresApproval is set when the safety level holds the execution for approval.
+	RequiresApproval bool `json:"requiresApproval"`
+	// Kubectl is the equivalent kubectl command, or helm command for a Helm rollback; it is empty for
+	// operations run by an external executor.
+	Kubectl string `json:"kubectl,omitempty"`
+	// Executor names the webhook executor the operation is delegated to.
+	Executor string `json:"executor,omitempty"`
+	// Access is nil for delegated operations, whose permissions the executor enforces.
+	Access *AccessReview `json:"access,omitempty"`
+	// Provisioned lists the resources the workspace's namespace template creates in a new namespace.
+	Provisioned []Resource `json:"provisioned,omitempty"`
+	// Explanation is what an approver of the command would be shown.
+	Explanation Explanation `json:"explanation"`
+}
+
+// Preview validates req, classifies it and checks the requester's permissions for it.
+func (s *Service) Preview(ctx context.Context, req Request) (Preview, error) {
+	req, webhook, delegated, err := s.prepare(ctx, req)
+	if err != nil {
+		return Preview{}, err
+	}
+	return s.preview(ctx, req, webhook, delegated)
+}
+
+func (s *Service) preview(ctx context.Context, req Request, webhook WebhookExecutor, delegated bool) (Preview, error) {
+	preview := Preview{
+		Config:    req.Config,
+		Cluster:   req.Cluster,
+		Operation: req.Operation,
+		Resource:  req.Resource,
+	}
+	if delegated {
+		preview.SafetyLevel = webhook.SafetyLevel
+		preview.Executor = webhook.URL
+	} else {
+		classification := s.classify(ctx, req)
+		preview.SafetyLevel = classification.Level
+		preview.Classification = &classification
+		review, err := s.ReviewAccess(ctx, req)
+		if err != nil {
+			return Preview{}, err
+		}
+		preview.Access = &review
+		preview.Kubectl = Kubectl(req.Cluster, req.Operation, req.Resource, req.Parameters)
+	}
+	preview.RequiresApproval = preview.SafetyLevel != SafetySafe
+	objects, err := provisioned(Execution{Resource: req.Resource, Parameters: req.Parameters})
+	if err != nil {
+		return Preview{}, err
+	}
+	for _, obj := range objects {
+		preview.Provisioned = append(preview.Provisioned, Resource{Kind: obj.GetKind(), Namespace: obj.GetNamespace(), Name: obj.GetName()})
+	}
+	preview.Explanation = s.explain(Execution{
+		Config:     req.Config,
+		Cluster:    req.Cluster,
+		Operation:  req.Operation,
+		Resource:   req.Resource,
+		Parameters: req.Parameters,
+	})
+	return preview, nil
+}
+
+// Kubectl renders the kubectl command equivalent to running op against resource in cluster.
+// Manifest operations and Helm upgrades read the manifest parameter from standard input; Helm
+// rollbacks render as the helm command.
+func Kubectl(cluster string, op Operation, resource Resource, params map[string]string) string {
+	args := []string{"kubectl", "--context", cluster}
+	target := strings.ToLower(resource.Kind) + "/" + resource.Name
+	switch op {
+	case OperationScale:
+		args = append(args, "scale", target, "--replicas="+params["replicas"])
+	case OperationRestart:
+		args = append(args, "rollout", "restart", target)
+	case OperationDelete:
+		args = append(args, "delete", target)
+	case OperationPatch:
+		patchType := params["patchType"]
+		if patchType == "" {
+			patchType = "strategic"
+		}
+		args = append(args, "patch", target, "--type="+patchType, "-p", params["patch"])
+	case OperationApply:
+		args = append(args, "apply", "--server-side", "--field-manager="+FieldManager)
+		if params["force"] == "true" {
+			args = append(args, "--force-conflicts")
+		}
+		args = append(args, "-f", "-")
+	case OperationCreate:
+		args = append(args, "create", "--field-manager="+FieldManager, "-f", "-")
+	case OperationEdit:
+		args = append(args, "replace", "--field-manager="+FieldManager, "-f", "-")
+	case OperationGet:
+		args = append(args, "get", target, "-o", "yaml")
+	case OperationDescribe:
+		args = append(args, "describe", target)
+	case OperationList:
+		args = append(args, "get", strings.ToLower(resource.Kind))
+		if selector := params["selector"]; selector != "" {
+			args = append(args, "-l", selector)
+		}
+		if resource.Namespace == "" {
+			args = append(args, "--all-namespaces")
+		}
+	case OperationTop:
+		args = append(args, "top", strings.TrimSuffix(topResource(resource.Kind), "s"))
+		if resource.Name != "" {
+			args = append(args, resource.Name)
+		}
+		if sortBy := params["sort-by"]; sortBy != "" {
+			args = append(args, "--sort-by="+sortBy)
+		}
+		if selector := params["selector"]; selector != "" {
+			args = append(args, "-l", selector)
+		}
+		if resource.Namespace == "" && topResource(resource.Kind) == "pods" {
+			args = append(args, "--all-namespaces")
+		}
+	case OperationHelmRollback:
+		args = []string{"helm", "--kube-context", cluster, "rollback", resource.Name}
+		if revision := params["revision"]; revision != "" {
+			args = append(args, revision)
+		}
+	case OperationHelmUpgrade:
+		// The chart is rendered before submission, so the upgrade applies its manifest as helm
+		// would after rendering it.
+		args = append(args, "apply", "--server-side", "--force-conflicts", "--field-manager="+FieldManager, "-f", "-")
+	}
+	if resource.Namespace != "" {
+		args = append(args, "-n", resource.Namespace)
+	}
+	for i, arg := range args {
+		args[i] = shellQuote(arg)
+	}
+	return strings.Join(args, " ")
+}
+
+// shellQuote single-quotes arg when a POSIX shell would otherwise split or expand it.
+func shellQuote(arg string) string {
+	if arg != "" && !strings.ContainsFunc(arg, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./=:,@%+", r))
+	}) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
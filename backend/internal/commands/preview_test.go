@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPreviewReportsAccessWithoutExecuting(t *testing.T) {
+	svc, client, repo := newTestService(deployment(2))
+	allowVerbs(client, "get")
+	ctx := context.Background()
+
+	preview, err := svc.Preview(ctx, scaleRequest("alice", "0"))
+	if err != nil {
+		t.Fatalf("preview: %v", err)
+	}
+	if preview.SafetyLevel != SafetyWarning || !preview.RequiresApproval {
+		t.Fatalf("expected scale to zero to need approval, got %+v", preview)
+	}
+	if preview.Access == nil || preview.Access.Allowed || preview.Access.Impersonated {
+		t.Fatalf("expected a denied service account review, got %+v", preview.Access)
+	}
+	if want := "kubectl --context prod scale deployment/api --replicas=0 -n shop"; preview.Kubectl != want {
+		t.Fatalf("expected %q, got %q", want, preview.Kubectl)
+	}
+
+	got, _ := client.AppsV1().Deployments("shop").Get(ctx, "api", metav1.GetOptions{})
+	if *got.Spec.Replicas != 2 {
+		t.Fatalf("preview changed the deployment to %d replicas", *got.Spec.Replicas)
+	}
+	if executions, _ := repo.ListByUser(ctx, "alice", 10, 0); len(executions) != 0 {
+		t.Fatalf("preview recorded executions: %+v", executions)
+	}
+}
+
+func TestKubectlQuotesPatches(t *testing.T) {
+	got := Kubectl("prod", OperationPatch, Resource{Kind: "ConfigMap", Namespace: "shop", Name: "settings"},
+		map[string]string{"patchType": "merge", "patch": `{"data":{"mode":"it's on"}}`})
+	want := `kubectl --context prod patch configmap/settings --type=merge -p '{"data":{"mode":"it'\''s on"}}' -n shop`
+	if got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
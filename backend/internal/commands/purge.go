@@ -0,0 +1,121 @@
+package commands
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pramodksahoo/kubechat/backend/internal/attachments"
+)
+
+const (
+	// purgeBatchSize is how many executions are archived together and then deleted.
+	purgeBatchSize = 500
+	// purgeArchivePrefix is where archives of purged executions are kept in the archive store.
+	purgeArchivePrefix = "retention-archive/execution_records/"
+)
+
+// PurgeFilter selects the finished executions created before Cutoff, except those requested by
+// KeepUsers or listed in KeepIDs. A zero Limit selects all of them.
+type PurgeFilter struct {
+	Cutoff    time.Time
+	KeepUsers []string
+	KeepIDs   []string
+	Limit     int
+}
+
+func (f PurgeFilter) matches(execution Execution) bool {
+	return execution.CreatedAt.Before(f.Cutoff) && execution.finished() &&
+		!slices.Contains(f.KeepUsers, execution.UserID) && !slices.Contains(f.KeepIDs, execution.ID)
+}
+
+// archivedExecution is one line of a purge archive: an execution with its approval trail.
+type archivedExecution struct {
+	Execution Execution       `json:"execution"`
+	Approval  *Approval       `json:"approval,omitempty"`
+	Events    []ApprovalEvent `json:"approvalEvents,omitempty"`
+}
+
+// SetArchiveStore makes Purge write every batch of executions to store, as gzip-compressed JSON
+// lines, before deleting it.
+func (s *Service) SetArchiveStore(store attachments.Store) {
+	s.archive = store
+}
+
+// Purge archives and then deletes finished executions created before cutoff, with their
+// approvals, rollback plans and attachments. Executions requested by keepUsers or listed in
+// keepIDs are kept. A batch that cannot be archived is not deleted.
+func (s *Service) Purge(ctx context.Context, cutoff time.Time, keepUsers, keepIDs []string) (int, error) {
+	filter := PurgeFilter{Cutoff: cutoff, KeepUsers: keepUsers, KeepIDs: keepIDs, Limit: purgeBatchSize}
+	purged := 0
+	for {
+		batch, err := s.repo.ListPurgeable(ctx, filter)
+		if err != nil || len(batch) == 0 {
+			return purged, err
+		}
+		if err := s.archiveExecutions(ctx, batch); err != nil {
+			return purged, fmt.Errorf("archive executions: %w", err)
+		}
+		ids := make([]string, 0, len(batch))
+		for _, execution := range batch {
+			ids = append(ids, execution.ID)
+		}
+		deleted, err := s.repo.DeleteFinished(ctx, ids)
+		for _, execution := range deleted {
+			s.deleteAttachments(ctx, execution)
+		}
+		purged += len(deleted)
+		if err != nil || len(batch) < purgeBatchSize || len(deleted) == 0 {
+			return purged, err
+		}
+	}
+}
+
+// Purgeable counts the executions Purge would delete now.
+func (s *Service) Purgeable(ctx context.Context, cutoff time.Time, keepUsers, keepIDs []string) (int, error) {
+	return s.repo.CountPurgeable(ctx, PurgeFilter{Cutoff: cutoff, KeepUsers: keepUsers, KeepIDs: keepIDs})
+}
+
+func (s *Service) archiveExecutions(ctx context.Context, batch []Execution) error {
+	if s.archive == nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	compressed := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(compressed)
+	for _, execution := range batch {
+		line := archivedExecution{Execution: execution}
+		approval, err := s.repo.GetApproval(ctx, execution.ID)
+		if err == nil {
+			line.Approval = &approval
+			if line.Events, err = s.repo.ListApprovalEvents(ctx, execution.ID); err != nil {
+				return err
+			}
+		} else if !errors.As(err, &ErrApprovalNotFound{}) {
+			return err
+		}
+		if err := encoder.Encode(line); err != nil {
+			return err
+		}
+	}
+	if err := compressed.Close(); err != nil {
+		return err
+	}
+
+	now := s.now()
+	key := fmt.Sprintf("%s%s/%s.jsonl.gz", purgeArchivePrefix, now.Format("2006/01"), uuid.NewString())
+	return attachments.PutWithMetadata(ctx, s.archive, key, "application/gzip", buf.Bytes(), map[string]string{
+		"kubechat-category": "execution_records",
+		"kubechat-records":  strconv.Itoa(len(batch)),
+		"kubechat-from":     batch[0].CreatedAt.Format(time.RFC3339),
+		"kubechat-to":       batch[len(batch)-1].CreatedAt.Format(time.RFC3339),
+		"kubechat-archived": now.Format(time.RFC3339),
+	})
+}
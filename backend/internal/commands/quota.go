@@ -0,0 +1,421 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// destructiveWindow is the period DestructivePerHour counts over.
+	destructiveWindow = time.Hour
+	// concurrentRetryAfter is suggested to users at their concurrency limit; running commands
+	// usually finish within it.
+	concurrentRetryAfter = 30 * time.Second
+)
+
+// CommandLimits caps what one user may run. A zero limit is not enforced.
+type CommandLimits struct {
+	// DestructivePerHour counts deletes and dangerous commands submitted in the last hour.
+	DestructivePerHour int `json:"destructivePerHour"`
+	// MaxConcurrent counts the user's commands running at the same time.
+	MaxConcurrent int `json:"maxConcurrent"`
+}
+
+func (l CommandLimits) validate() error {
+	if l.DestructivePerHour < 0 || l.MaxConcurrent < 0 {
+		return ErrInvalidQuota{Reason: "destructivePerHour and maxConcurrent must not be negative"}
+	}
+	return nil
+}
+
+// NamespaceFreeze refuses changes in a namespace until it ends or is lifted. Reads still run.
+type NamespaceFreeze struct {
+	// Cluster limits the freeze to one cluster; empty freezes the namespace everywhere.
+	Cluster   string `json:"cluster,omitempty"`
+	Namespace string `json:"namespace"`
+	Reason    string `json:"reason,omitempty"`
+	// Until ends the freeze; without it the freeze lasts until lifted.
+	Until     *time.Time `json:"until,omitempty"`
+	CreatedBy string     `json:"createdBy"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+func (f NamespaceFreeze) key() string {
+	return f.Cluster + "/" + f.Namespace
+}
+
+func (f NamespaceFreeze) covers(cluster, namespace string) bool {
+	return f.Namespace == namespace && (f.Cluster == "" || f.Cluster == cluster)
+}
+
+// CommandUsage is where a user stands against their limits.
+type CommandUsage struct {
+	User        string        `json:"user"`
+	Limits      CommandLimits `json:"limits"`
+	Destructive int           `json:"destructiveLastHour"`
+	Running     int           `json:"running"`
+}
+
+// CommandQuotaOverview lists the default limits, per-user overrides, active freezes and the
+// usage of every user seen so far.
+type CommandQuotaOverview struct {
+	Default   CommandLimits            `json:"default"`
+	Overrides map[string]CommandLimits `json:"overrides"`
+	Freezes   []NamespaceFreeze        `json:"freezes"`
+	Usage     []CommandUsage           `json:"usage"`
+}
+
+type ErrInvalidQuota struct {
+	Reason string
+}
+
+func (e ErrInvalidQuota) Error() string {
+	return e.Reason
+}
+
+// ErrQuotaExceeded is returned for commands over the requester's limits or in a frozen namespace.
+// RetryAfter is zero when the wait is unknown, such as for a freeze without an end.
+type ErrQuotaExceeded struct {
+	User       string
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e ErrQuotaExceeded) Error() string {
+	return e.Reason
+}
+
+type ErrFreezeNotFound struct {
+	Cluster   string
+	Namespace string
+}
+
+func (e ErrFreezeNotFound) Error() string {
+	return fmt.Sprintf("namespace %s has no change freeze", e.Namespace)
+}
+
+// CommandQuota limits how many destructive commands users run and how many run at once, and
+// holds the namespace change freezes. Like the model quota it is kept in process memory, so
+// limits apply per replica.
+type CommandQuota struct {
+	mu          sync.Mutex
+	defaults    CommandLimits
+	overrides   map[string]CommandLimits
+	freezes     map[string]NamespaceFreeze
+	destructive map[string][]time.Time
+	running     map[string]int
+	now         func() time.Time
+}
+
+func NewCommandQuota(defaults CommandLimits) (*CommandQuota, error) {
+	if err := defaults.validate(); err != nil {
+		return nil, err
+	}
+	return &CommandQuota{
+		defaults:    defaults,
+		overrides:   make(map[string]CommandLimits),
+		freezes:     make(map[string]NamespaceFreeze),
+		destructive: make(map[string][]time.Time),
+		running:     make(map[string]int),
+		now:         func() time.Time { return time.Now().UTC() },
+	}, nil
+}
+
+// Admit checks an execution against namespace freezes and, for destructive commands, the hourly
+// limit of its requester, which it charges when the execution is admitted.
+func (q *CommandQuota) Admit(execution Execution) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.frozenLocked(execution); err != nil {
+		return err
+	}
+	count := destructiveCount(execution)
+	if count == 0 {
+		return nil
+	}
+	limit := q.limitsLocked(execution.UserID).DestructivePerHour
+	recent := q.recentLocked(execution.UserID)
+	if limit > 0 && count > limit {
+		return ErrQuotaExceeded{
+			User:   execution.UserID,
+			Reason: fmt.Sprintf("the plan has %d destructive steps, more than the limit of %d per hour", count, limit),
+		}
+	}
+	if limit > 0 && len(recent)+count > limit {
+		return ErrQuotaExceeded{
+			User:       execution.UserID,
+			Reason:     fmt.Sprintf("%s reached the limit of %d destructive commands per hour", execution.UserID, limit),
+			RetryAfter: recent[len(recent)+count-limit-1].Add(destructiveWindow).Sub(q.now()),
+		}
+	}
+	for range count {
+		recent = append(recent, q.now())
+	}
+	q.destructive[execution.UserID] = recent
+	return nil
+}
+
+// Start takes one of the requester's concurrent execution slots, checking freezes again since
+// one may have begun while the execution awaited approval. The returned func gives it back.
+func (q *CommandQuota) Start(execution Execution) (func(), error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.frozenLocked(execution); err != nil {
+		return nil, err
+	}
+	user := execution.UserID
+	if limit := q.limitsLocked(user).MaxConcurrent; limit > 0 && q.running[user] >= limit {
+		return nil, ErrQuotaExceeded{
+			User:       user,
+			Reason:     fmt.Sprintf("%s already has %d commands running", user, q.running[user]),
+			RetryAfter: concurrentRetryAfter,
+		}
+	}
+	q.running[user]++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			if q.running[user]--; q.running[user] <= 0 {
+				delete(q.running, user)
+			}
+		})
+	}, nil
+}
+
+func (q *CommandQuota) Usage(user string) CommandUsage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.usageLocked(user)
+}
+
+func (q *CommandQuota) Overview() CommandQuotaOverview {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	overview := CommandQuotaOverview{Default: q.defaults, Overrides: make(map[string]CommandLimits, len(q.overrides)), Freezes: q.freezesLocked()}
+	for user, limits := range q.overrides {
+		overview.Overrides[user] = limits
+	}
+	users := make(map[string]struct{})
+	for user := range q.destructive {
+		users[user] = struct{}{}
+	}
+	for user := range q.running {
+		users[user] = struct{}{}
+	}
+	names := make([]string, 0, len(users))
+	for user := range users {
+		names = append(names, user)
+	}
+	sort.Strings(names)
+	for _, user := range names {
+		overview.Usage = append(overview.Usage, q.usageLocked(user))
+	}
+	return overview
+}
+
+// SetDefault changes the limits of every user without an override.
+func (q *CommandQuota) SetDefault(limits CommandLimits) error {
+	if err := limits.validate(); err != nil {
+		return err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.defaults = limits
+	return nil
+}
+
+// SetUserLimits overrides the default limits for user.
+func (q *CommandQuota) SetUserLimits(user string, limits CommandLimits) error {
+	if user == "" {
+		return ErrInvalidQuota{Reason: "user is required"}
+	}
+	if err := limits.validate(); err != nil {
+		return err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.overrides[user] = limits
+	return nil
+}
+
+// RemoveUserLimits returns user to the default limits, reporting whether there was an override.
+func (q *CommandQuota) RemoveUserLimits(user string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_, ok := q.overrides[user]
+	delete(q.overrides, user)
+	return ok
+}
+
+// Freeze starts or replaces the change freeze of a namespace.
+func (q *CommandQuota) Freeze(freeze NamespaceFreeze) (NamespaceFreeze, error) {
+	freeze.Namespace = strings.TrimSpace(freeze.Namespace)
+	if freeze.Namespace == "" {
+		return NamespaceFreeze{}, ErrInvalidQuota{Reason: "namespace is required"}
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	freeze.CreatedAt = q.now()
+	if freeze.Until != nil && !freeze.Until.After(freeze.CreatedAt) {
+		return NamespaceFreeze{}, ErrInvalidQuota{Reason: "until must be in the future"}
+	}
+	q.freezes[freeze.key()] = freeze
+	return freeze, nil
+}
+
+// Unfreeze lifts the change freeze of a namespace.
+func (q *CommandQuota) Unfreeze(cluster, namespace string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	key := NamespaceFreeze{Cluster: cluster, Namespace: namespace}.key()
+	if _, ok := q.freezes[key]; !ok {
+		return ErrFreezeNotFound{Cluster: cluster, Namespace: namespace}
+	}
+	delete(q.freezes, key)
+	return nil
+}
+
+// Freezes lists the freezes in effect.
+func (q *CommandQuota) Freezes() []NamespaceFreeze {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.freezesLocked()
+}
+
+func (q *CommandQuota) frozenLocked(execution Execution) error {
+	if readOperation(execution.Operation) {
+		return nil
+	}
+	for _, freeze := range q.freezesLocked() {
+		if !slices.ContainsFunc(execution.namespaces(), func(namespace string) bool { return freeze.covers(execution.Cluster, namespace) }) {
+			continue
+		}
+		err := ErrQuotaExceeded{User: execution.UserID, Reason: fmt.Sprintf("namespace %s is frozen for changes", freeze.Namespace)}
+		if freeze.Reason != "" {
+			err.Reason += ": " + freeze.Reason
+		}
+		if freeze.Until != nil {
+			err.RetryAfter = freeze.Until.Sub(q.now())
+		}
+		return err
+	}
+	return nil
+}
+
+// freezesLocked drops expired freezes and returns the rest ordered by namespace.
+func (q *CommandQuota) freezesLocked() []NamespaceFreeze {
+	now := q.now()
+	freezes := make([]NamespaceFreeze, 0, len(q.freezes))
+	for key, freeze := range q.freezes {
+		if freeze.Until != nil && !freeze.Until.After(now) {
+			delete(q.freezes, key)
+			continue
+		}
+		freezes = append(freezes, freeze)
+	}
+	slices.SortFunc(freezes, func(a, b NamespaceFreeze) int { return strings.Compare(a.key(), b.key()) })
+	return freezes
+}
+
+func (q *CommandQuota) limitsLocked(user string) CommandLimits {
+	if limits, ok := q.overrides[user]; ok {
+		return limits
+	}
+	return q.defaults
+}
+
+// recentLocked drops destructive commands older than the window and returns the rest, oldest first.
+func (q *CommandQuota) recentLocked(user string) []time.Time {
+	cutoff := q.now().Add(-destructiveWindow)
+	recent := q.destructive[user]
+	i := 0
+	for i < len(recent) && !recent[i].After(cutoff) {
+		i++
+	}
+	recent = recent[i:]
+	if len(recent) == 0 {
+		delete(q.destructive, user)
+		return nil
+	}
+	q.destructive[user] = recent
+	return recent
+}
+
+func (q *CommandQuota) usageLocked(user string) CommandUsage {
+	return CommandUsage{
+		User:        user,
+		Limits:      q.limitsLocked(user),
+		Destructive: len(q.recentLocked(user)),
+		Running:     q.running[user],
+	}
+}
+
+// destructiveCount is how many commands an execution counts for toward the hourly destructive
+// limit: one for a destructive command and one per destructive step for a plan.
+func destructiveCount(execution Execution) int {
+	if execution.Operation == OperationPlan {
+		count := 0
+		for _, step := range execution.Steps {
+			if step.Operation == OperationDelete || step.SafetyLevel == SafetyDangerous {
+				count++
+			}
+		}
+		return count
+	}
+	if execution.Operation == OperationDelete || execution.SafetyLevel == SafetyDangerous {
+		return 1
+	}
+	return 0
+}
+
+// SetCommandQuota enforces per-user limits and namespace freezes on new and approved executions.
+func (s *Service) SetCommandQuota(quota *CommandQuota) {
+	s.quota = quota
+}
+
+// admitQuota checks a new execution against the quota. Refusals are security events: they are
+// logged and kept in the audit trail as rejected executions.
+func (s *Service) admitQuota(ctx context.Context, execution Execution) error {
+	if s.quota == nil {
+		return nil
+	}
+	err := s.quota.Admit(execution)
+	if err != nil {
+		s.refuseQuota(ctx, execution, err)
+	}
+	return err
+}
+
+// startQuota takes a concurrent execution slot for execution; release is never nil.
+func (s *Service) startQuota(execution Execution) (func(), error) {
+	if s.quota == nil {
+		return func() {}, nil
+	}
+	release, err := s.quota.Start(execution)
+	if err != nil {
+		s.logger.Warn("command quota exceeded", "event", "security", "id", execution.ID, "user", execution.UserID, "resource", execution.Resource.String(), "reason", err)
+		return func() {}, err
+	}
+	return release, nil
+}
+
+func (s *Service) refuseQuota(ctx context.Context, execution Execution, err error) {
+	s.log(ctx).Warn("command quota exceeded", "event", "security", "user", execution.UserID, "cluster", execution.Cluster, "resource", execution.Resource.String(), "reason", err)
+	now := s.now()
+	execution.ID = uuid.NewString()
+	execution.Status = StatusRejected
+	execution.Error = err.Error()
+	execution.CompletedAt = &now
+	if createErr := s.repo.Create(ctx, execution); createErr != nil {
+		s.log(ctx).Error("failed to record refused command", "user", execution.UserID, "error", createErr)
+	}
+}
@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCommandQuotaLimitsDestructiveCommandsAndFreezes(t *testing.T) {
+	svc, _, repo := newTestService(deployment(2))
+	quota, err := NewCommandQuota(CommandLimits{DestructivePerHour: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	quota.now = func() time.Time { return now }
+	svc.SetCommandQuota(quota)
+	ctx := context.Background()
+	remove := Request{UserID: "alice", Config: "kubeconfig", Cluster: "prod", Operation: OperationDelete, Resource: Resource{Kind: "Deployment", Namespace: "shop", Name: "api"}}
+
+	if execution, err := svc.Submit(ctx, remove); err != nil || execution.Status != StatusAwaitingApproval {
+		t.Fatalf("expected the first delete to await approval, got %+v %v", execution, err)
+	}
+	var exceeded ErrQuotaExceeded
+	if _, err := svc.Submit(ctx, remove); !errors.As(err, &exceeded) || exceeded.RetryAfter != time.Hour {
+		t.Fatalf("expected the second delete to be refused for an hour, got %v", err)
+	}
+	executions, _ := repo.ListByUser(ctx, "alice", 10, 0)
+	if len(executions) != 2 || !(executions[0].Status == StatusRejected || executions[1].Status == StatusRejected) {
+		t.Fatalf("expected the refusal in the audit trail, got %+v", executions)
+	}
+	if _, err := svc.Submit(ctx, scaleRequest("alice", "3")); err != nil {
+		t.Fatalf("expected safe commands to stay within the quota: %v", err)
+	}
+
+	until := now.Add(10 * time.Minute)
+	if _, err := quota.Freeze(NamespaceFreeze{Cluster: "prod", Namespace: "shop", Reason: "release", Until: &until}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.Submit(ctx, scaleRequest("bob", "4")); !errors.As(err, &exceeded) || exceeded.RetryAfter != 10*time.Minute {
+		t.Fatalf("expected the frozen namespace to refuse changes, got %v", err)
+	}
+	now = now.Add(time.Hour)
+	if len(quota.Freezes()) != 0 {
+		t.Fatalf("expected the freeze to end")
+	}
+	if _, err := svc.Submit(ctx, remove); err != nil {
+		t.Fatalf("expected the destructive quota to recover after an hour: %v", err)
+	}
+}
+
+func TestCommandQuotaLimitsConcurrentExecutions(t *testing.T) {
+	quota, err := NewCommandQuota(CommandLimits{MaxConcurrent: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := quota.SetUserLimits("ops", CommandLimits{MaxConcurrent: 2}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	release, err := quota.Start(Execution{UserID: "alice"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := quota.Start(Execution{UserID: "alice"}); !errors.As(err, &ErrQuotaExceeded{}) {
+		t.Fatalf("expected a second concurrent execution to be refused, got %v", err)
+	}
+	if _, err := quota.Start(Execution{UserID: "ops"}); err != nil {
+		t.Fatalf("expected the override to apply: %v", err)
+	}
+	release()
+	release()
+	if usage := quota.Usage("alice"); usage.Running != 0 {
+		t.Fatalf("expected releasing twice to free one slot, got %+v", usage)
+	}
+	if _, err := quota.Start(Execution{UserID: "alice"}); err != nil {
+		t.Fatalf("expected the slot to be free again: %v", err)
+	}
+}
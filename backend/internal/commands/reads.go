@@ -0,0 +1,203 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+)
+
+// listLimit caps the objects one list returns; narrower selectors page through the rest.
+const listLimit = 500
+
+var eventsGVR = schema.GroupVersionResource{Version: "v1", Resource: "events"}
+
+// readOperation reports whether op only reads from the cluster.
+func readOperation(op Operation) bool {
+	return op == OperationGet || op == OperationList || op == OperationDescribe || op == OperationTop
+}
+
+func validateRead(req Request) error {
+	if req.Operation == OperationTop {
+		_, err := validateTop(req)
+		return err
+	}
+	if req.Resource.Kind == "" {
+		return ErrInvalidRequest{Reason: "resource kind is required"}
+	}
+	if req.Operation != OperationList {
+		if req.Resource.Name == "" {
+			return ErrInvalidRequest{Reason: "resource name is required"}
+		}
+		return nil
+	}
+	if req.Resource.Name != "" {
+		return ErrInvalidRequest{Reason: "list does not take a resource name; use get"}
+	}
+	if _, err := labels.Parse(req.Parameters["selector"]); err != nil {
+		return ErrInvalidRequest{Reason: fmt.Sprintf("selector is not a valid label selector: %v", err)}
+	}
+	return nil
+}
+
+// resourceMapping resolves kind the way kubectl resolves a resource argument: a kind such as
+// "Deployment", a resource such as "deployments", or a qualified "widgets.example.com".
+func (s *Service) resourceMapping(config, cluster, kind string) (*meta.RESTMapping, error) {
+	gvr := schema.ParseGroupResource(strings.ToLower(kind)).WithVersion("")
+	return s.resolveMapping(config, cluster, kind, func(mapper meta.RESTMapper) (*meta.RESTMapping, error) {
+		gvk, err := mapper.KindFor(gvr)
+		if err != nil {
+			return nil, err
+		}
+		return mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	})
+}
+
+// resolveRead rewrites the request's kind to the resource.group it names so executions record
+// exactly what they read. Gets of namespaced resources default to the default namespace.
+func (s *Service) resolveRead(req Request) (Request, error) {
+	if req.Operation == OperationTop {
+		return s.resolveTop(req)
+	}
+	if s.mappers == nil || s.dynamic == nil {
+		return Request{}, ErrInvalidRequest{Reason: fmt.Sprintf("%s is not enabled", req.Operation)}
+	}
+	mapping, err := s.resourceMapping(req.Config, req.Cluster, req.Resource.Kind)
+	if err != nil {
+		return Request{}, err
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		if req.Resource.Namespace == "" && req.Operation != OperationList {
+			req.Resource.Namespace = metav1.NamespaceDefault
+		}
+	} else if req.Resource.Namespace != "" {
+		return Request{}, ErrInvalidRequest{Reason: fmt.Sprintf("%s is not namespaced", req.Resource.Kind)}
+	}
+	req.Resource.Kind = mapping.Resource.GroupResource().String()
+	return req, nil
+}
+
+// read runs a get, list or describe execution and renders its result the way kubectl would. It
+// also returns the objects read so repeated reads can be compared.
+func (s *Service) read(ctx context.Context, execution Execution) (string, []unstructured.Unstructured, error) {
+	mapping, err := s.resourceMapping(execution.Config, execution.Cluster, execution.Resource.Kind)
+	if err != nil {
+		return "", nil, err
+	}
+	client, err := s.dynamicClient(execution)
+	if err != nil {
+		return "", nil, err
+	}
+	namespaced := mapping.Scope.Name() == meta.RESTScopeNameNamespace
+	var resource dynamic.ResourceInterface = client.Resource(mapping.Resource)
+	if namespaced {
+		resource = client.Resource(mapping.Resource).Namespace(execution.Resource.Namespace)
+	}
+
+	if execution.Operation == OperationList {
+		list, err := resource.List(ctx, metav1.ListOptions{LabelSelector: execution.Parameters["selector"], Limit: listLimit})
+		if err != nil {
+			return "", nil, err
+		}
+		return s.objectTable(list, namespaced && execution.Resource.Namespace == ""), list.Items, nil
+	}
+	obj, err := resource.Get(ctx, execution.Resource.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", nil, err
+	}
+	obj.SetManagedFields(nil)
+	raw, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return "", nil, err
+	}
+	objects := []unstructured.Unstructured{*obj}
+	if execution.Operation == OperationGet {
+		return string(raw), objects, nil
+	}
+	return string(raw) + "\n" + s.eventTable(ctx, client, obj), objects, nil
+}
+
+// objectTable renders list as NAME and AGE columns, with NAMESPACE when it spans namespaces.
+func (s *Service) objectTable(list *unstructured.UnstructuredList, withNamespace bool) string {
+	if len(list.Items) == 0 {
+		return "No resources found.\n"
+	}
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 3, ' ', 0)
+	if withNamespace {
+		fmt.Fprint(w, "NAMESPACE\t")
+	}
+	fmt.Fprintln(w, "NAME\tAGE")
+	for _, item := range list.Items {
+		if withNamespace {
+			fmt.Fprintf(w, "%s\t", item.GetNamespace())
+		}
+		fmt.Fprintf(w, "%s\t%s\n", item.GetName(), s.age(item.GetCreationTimestamp().Time))
+	}
+	w.Flush()
+	if list.GetContinue() != "" {
+		fmt.Fprintf(&buf, "\nShowing the first %d objects; narrow the list with a selector or namespace.\n", listLimit)
+	}
+	return buf.String()
+}
+
+// eventTable renders the events recorded for obj. Describing still succeeds when they cannot be read.
+func (s *Service) eventTable(ctx context.Context, client dynamic.Interface, obj *unstructured.Unstructured) string {
+	events, err := client.Resource(eventsGVR).Namespace(obj.GetNamespace()).List(ctx, metav1.ListOptions{
+		FieldSelector: "involvedObject.uid=" + string(obj.GetUID()),
+	})
+	if err != nil {
+		return fmt.Sprintf("Events: unavailable (%v)\n", err)
+	}
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "Events:")
+	fmt.Fprintln(w, "TYPE\tREASON\tAGE\tMESSAGE")
+	found := false
+	for _, event := range events.Items {
+		// Field selectors are advisory for some servers, so match the object again here.
+		if uid, _, _ := unstructured.NestedString(event.Object, "involvedObject", "uid"); uid != string(obj.GetUID()) {
+			continue
+		}
+		found = true
+		eventType, _, _ := unstructured.NestedString(event.Object, "type")
+		reason, _, _ := unstructured.NestedString(event.Object, "reason")
+		message, _, _ := unstructured.NestedString(event.Object, "message")
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", eventType, reason, s.age(eventTime(event)), strings.TrimSpace(message))
+	}
+	if !found {
+		return "Events: <none>\n"
+	}
+	w.Flush()
+	return buf.String()
+}
+
+// eventTime is when an event was last seen, falling back to when it was first recorded.
+func eventTime(event unstructured.Unstructured) time.Time {
+	for _, field := range []string{"lastTimestamp", "eventTime"} {
+		if value, _, _ := unstructured.NestedString(event.Object, field); value != "" {
+			if at, err := time.Parse(time.RFC3339Nano, value); err == nil {
+				return at
+			}
+		}
+	}
+	return event.GetCreationTimestamp().Time
+}
+
+func (s *Service) age(at time.Time) string {
+	if at.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(s.now().Sub(at))
+}
@@ -0,0 +1,221 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+var widgetsGVR = schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+
+func object(apiVersion, kind, namespace, name string, fields map[string]any) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: fields}
+	if obj.Object == nil {
+		obj.Object = map[string]any{}
+	}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+func newReadService(objects ...runtime.Object) *Service {
+	svc, typed, _ := newTestService(deployment(2))
+	allowVerbs(typed, "get", "list", "patch")
+	svc.now = func() time.Time { return time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC) }
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		deploymentsGVR: "DeploymentList",
+		widgetsGVR:     "WidgetList",
+		eventsGVR:      "EventList",
+	}, objects...)
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Secret"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Node"}, meta.RESTScopeRoot)
+	svc.SetDynamicClients(func(config, cluster string, subject *Subject) (dynamic.Interface, error) {
+		return client, nil
+	}, func(config, cluster string) (meta.RESTMapper, error) {
+		return mapper, nil
+	})
+	return svc
+}
+
+func readRequest(op Operation, resource Resource) Request {
+	return Request{UserID: "alice", Config: "kubeconfig", Cluster: "prod", Operation: op, Resource: resource}
+}
+
+func TestDescribeCustomResourceWithEvents(t *testing.T) {
+	widget := object("example.com/v1", "Widget", "shop", "blue", map[string]any{"spec": map[string]any{"size": "large"}})
+	widget.SetUID("widget-uid")
+	event := object("v1", "Event", "shop", "blue.1", map[string]any{
+		"involvedObject": map[string]any{"uid": "widget-uid"},
+		"type":           "Normal",
+		"reason":         "Resized",
+		"message":        "grew to large",
+		"lastTimestamp":  "2026-03-01T11:55:00Z",
+	})
+	other := object("v1", "Event", "shop", "other.1", map[string]any{
+		"involvedObject": map[string]any{"uid": "other-uid"},
+		"reason":         "Unrelated",
+	})
+	svc := newReadService(widget, event, other)
+
+	execution, err := svc.Submit(context.Background(), readRequest(OperationDescribe, Resource{Kind: "Widget", Namespace: "shop", Name: "blue"}))
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if execution.Status != StatusSucceeded || execution.SafetyLevel != SafetySafe {
+		t.Fatalf("expected describe to run immediately, got %+v", execution)
+	}
+	if execution.Resource.Kind != "widgets.example.com" {
+		t.Fatalf("expected the kind to resolve to its resource, got %s", execution.Resource.Kind)
+	}
+	for _, want := range []string{"size: large", "Resized", "5m", "grew to large"} {
+		if !strings.Contains(execution.Result, want) {
+			t.Fatalf("expected %q in the description:\n%s", want, execution.Result)
+		}
+	}
+	if strings.Contains(execution.Result, "Unrelated") {
+		t.Fatalf("description includes another object's events:\n%s", execution.Result)
+	}
+}
+
+func TestListAcrossNamespaces(t *testing.T) {
+	svc := newReadService(
+		object("apps/v1", "Deployment", "shop", "api", nil),
+		object("apps/v1", "Deployment", "billing", "worker", nil),
+	)
+	req := readRequest(OperationList, Resource{Kind: "deployments"})
+
+	preview, err := svc.Preview(context.Background(), req)
+	if err != nil {
+		t.Fatalf("preview: %v", err)
+	}
+	if want := "kubectl --context prod get deployments.apps --all-namespaces"; preview.Kubectl != want {
+		t.Fatalf("expected %q, got %q", want, preview.Kubectl)
+	}
+	if check := preview.Access.Checks[0]; check.Verb != "list" || check.Group != "apps" || check.Namespace != "" {
+		t.Fatalf("unexpected permission check %+v", check)
+	}
+
+	execution, err := svc.Submit(context.Background(), req)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(execution.Result), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "NAMESPACE") || !strings.Contains(execution.Result, "billing") {
+		t.Fatalf("unexpected table:\n%s", execution.Result)
+	}
+}
+
+func TestReadRequestsAreValidated(t *testing.T) {
+	svc := newReadService()
+	for name, req := range map[string]Request{
+		"missing name":         readRequest(OperationGet, Resource{Kind: "deployments"}),
+		"named list":           readRequest(OperationList, Resource{Kind: "deployments", Name: "api"}),
+		"unknown resource":     readRequest(OperationGet, Resource{Kind: "gadgets.example.com", Name: "g"}),
+		"namespaced node":      readRequest(OperationGet, Resource{Kind: "nodes", Namespace: "shop", Name: "n1"}),
+		"bad selector":         {UserID: "alice", Config: "kubeconfig", Cluster: "prod", Operation: OperationList, Resource: Resource{Kind: "widgets"}, Parameters: map[string]string{"selector": "a in ("}},
+		"missing kind on list": readRequest(OperationList, Resource{}),
+	} {
+		var invalid ErrInvalidRequest
+		if _, err := svc.Submit(context.Background(), req); !errors.As(err, &invalid) {
+			t.Fatalf("%s: expected invalid request, got %v", name, err)
+		}
+	}
+}
+
+func TestResourcePolicy(t *testing.T) {
+	svc := newReadService()
+	ctx := context.Background()
+
+	var notAllowed ErrResourceNotAllowed
+	if _, err := svc.Submit(ctx, readRequest(OperationGet, Resource{Kind: "Secret", Namespace: "shop", Name: "db"})); !errors.As(err, &notAllowed) {
+		t.Fatalf("expected secrets to be denied by default, got %v", err)
+	}
+
+	svc.SetResourcePolicy(&ResourcePolicy{Allow: []string{"*.example.com"}, Deny: []string{"gadgets.example.com"}})
+	if _, err := svc.Preview(ctx, readRequest(OperationList, Resource{Kind: "widgets"})); err != nil {
+		t.Fatalf("expected widgets to be allowed, got %v", err)
+	}
+	if _, err := svc.Submit(ctx, scaleRequest("alice", "3")); !errors.As(err, &notAllowed) || notAllowed.Resource.String() != "deployments.apps" {
+		t.Fatalf("expected deployments outside the allowlist to be denied, got %v", err)
+	}
+
+	policy := ResourcePolicy{Deny: []string{"*.example.com", "secrets"}}
+	for gr, want := range map[schema.GroupResource]bool{
+		{Resource: "configmaps"}:                    true,
+		{Resource: "secrets"}:                       false,
+		{Group: "example.com", Resource: "widgets"}: false,
+		{Group: "apps", Resource: "deployments"}:    true,
+		{Group: "secrets.io", Resource: "vaults"}:   true,
+	} {
+		if got := policy.Allows(gr); got != want {
+			t.Fatalf("Allows(%s) = %t, want %t", gr, got, want)
+		}
+	}
+}
+
+type fakeEventSummarizer struct {
+	subjects []Resource
+	events   []CorrelatedEvent
+}
+
+func (f *fakeEventSummarizer) SummarizeEvents(ctx context.Context, subjects []Resource, events []CorrelatedEvent) (string, error) {
+	f.subjects, f.events = subjects, events
+	return " The api pods cannot pull their image. ", nil
+}
+
+func TestGetCorrelatesEventsOfWorkloadPods(t *testing.T) {
+	event := func(name, kind, involved, eventType, reason, at string) *unstructured.Unstructured {
+		return object("v1", "Event", "shop", name, map[string]any{
+			"involvedObject": map[string]any{"kind": kind, "name": involved},
+			"type":           eventType,
+			"reason":         reason,
+			"message":        reason + " " + involved,
+			"count":          int64(3),
+			"lastTimestamp":  at,
+		})
+	}
+	svc := newReadService(
+		object("apps/v1", "Deployment", "shop", "api", nil),
+		event("api.1", "Deployment", "api", "Normal", "ScalingReplicaSet", "2026-03-01T11:59:00Z"),
+		event("api-7d9f.1", "Pod", "api-7d9f-x2k", "Warning", "BackOff", "2026-03-01T11:50:00Z"),
+		event("api-7d9f.2", "Pod", "api-7d9f-x2k", "Warning", "Failed", "2026-03-01T11:58:00Z"),
+		event("apiary.1", "Pod", "apiary", "Warning", "BackOff", "2026-03-01T11:58:00Z"),
+	)
+	summarizer := &fakeEventSummarizer{}
+	svc.SetEventCorrelation(summarizer)
+
+	execution, err := svc.Submit(context.Background(), readRequest(OperationGet, Resource{Kind: "deployments", Namespace: "shop", Name: "api"}))
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	report := execution.Events
+	if report == nil || len(report.Events) != 3 || report.Warnings != 2 {
+		t.Fatalf("expected the deployment's and its pod's events, got %+v", report)
+	}
+	if first := report.Events[0]; first.Reason != "Failed" || first.Object.Kind != "Pod" || first.Count != 3 {
+		t.Fatalf("expected the latest warning first, got %+v", first)
+	}
+	if report.Events[2].Reason != "ScalingReplicaSet" {
+		t.Fatalf("expected normal events after warnings, got %+v", report.Events)
+	}
+	if report.Summary != "The api pods cannot pull their image." || len(summarizer.events) != 3 {
+		t.Fatalf("expected the summary of the correlated events, got %q", report.Summary)
+	}
+	if len(summarizer.subjects) != 1 || summarizer.subjects[0] != (Resource{Kind: "Deployment", Namespace: "shop", Name: "api"}) {
+		t.Fatalf("unexpected subjects %+v", summarizer.subjects)
+	}
+}
@@ -0,0 +1,574 @@
+package commands
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Repository persists command executions together with their approvals and rollback plans.
+type Repository interface {
+	Create(ctx context.Context, execution Execution) error
+	Update(ctx context.Context, execution Execution) error
+	GetByID(ctx context.Context, id string) (Execution, error)
+	ListByUser(ctx context.Context, userID string, limit, offset int) ([]Execution, error)
+	// ListByTarget returns the executions indexed under the filter's target, most recent first.
+	ListByTarget(ctx context.Context, filter AuditFilter) ([]Execution, error)
+	// ListByCorrelation returns the executions sharing correlationID, oldest first.
+	ListByCorrelation(ctx context.Context, correlationID string) ([]Execution, error)
+	// ListByStatus returns the executions in status, oldest first.
+	ListByStatus(ctx context.Context, status Status) ([]Execution, error)
+	// MarkDeleted records that user deleted the execution at the given time. The execution is kept
+	// until retention purges it, but no longer listed.
+	MarkDeleted(ctx context.Context, id, user string, at time.Time) error
+	// ListPurgeable returns the executions matching filter, oldest first.
+	ListPurgeable(ctx context.Context, filter PurgeFilter) ([]Execution, error)
+	CountPurgeable(ctx context.Context, filter PurgeFilter) (int, error)
+	// PseudonymizeUser replaces user with pseudonym as the requester of executions and as
+	// requester, approver or actor on approvals, and returns how many executions it changed.
+	PseudonymizeUser(ctx context.Context, user, pseudonym string) (int, error)
+	// DeleteFinished removes the listed executions that are no longer pending or running and
+	// returns what it removed.
+	DeleteFinished(ctx context.Context, ids []string) ([]Execution, error)
+
+	// SaveApproval stores approval and increments its version, provided the stored approval has
+	// not been saved since approval was read; otherwise it returns ErrApprovalConflict.
+	SaveApproval(ctx context.Context, approval Approval) error
+	GetApproval(ctx context.Context, executionID string) (Approval, error)
+	// ListApprovals returns approvals in the given status, or all approvals when status is empty.
+	ListApprovals(ctx context.Context, status ApprovalStatus) ([]Approval, error)
+	AppendApprovalEvent(ctx context.Context, event ApprovalEvent) error
+	ListApprovalEvents(ctx context.Context, executionID string) ([]ApprovalEvent, error)
+
+	SaveDelegation(ctx context.Context, delegation ApprovalDelegation) error
+	GetDelegation(ctx context.Context, id string) (ApprovalDelegation, error)
+	// ListDelegations returns every delegation ordered by start.
+	ListDelegations(ctx context.Context) ([]ApprovalDelegation, error)
+
+	// SaveSchedule leaves a cancelled schedule unchanged, so a firing cannot revive it.
+	SaveSchedule(ctx context.Context, schedule CommandSchedule) error
+	GetSchedule(ctx context.Context, id string) (CommandSchedule, error)
+	// ListSchedules returns userID's schedules, most recently created first.
+	ListSchedules(ctx context.Context, userID string) ([]CommandSchedule, error)
+	// ListDueSchedules returns the active schedules whose next run is at or before at.
+	ListDueSchedules(ctx context.Context, at time.Time) ([]CommandSchedule, error)
+
+	SaveChangeWindow(ctx context.Context, window ChangeWindow) error
+	// ListChangeWindows returns every change window, most recently defined first.
+	ListChangeWindows(ctx context.Context) ([]ChangeWindow, error)
+	DeleteChangeWindow(ctx context.Context, id string) error
+
+	SaveRollbackPlan(ctx context.Context, plan RollbackPlan) error
+	GetRollbackPlan(ctx context.Context, executionID string) (RollbackPlan, error)
+
+	// SaveReadSnapshot replaces the snapshot stored under the same signature.
+	SaveReadSnapshot(ctx context.Context, snapshot ReadSnapshot) error
+	// GetReadSnapshot returns ErrNoReadSnapshot when no read with signature has run.
+	GetReadSnapshot(ctx context.Context, signature string) (ReadSnapshot, error)
+
+	// RedeemApprovalLink records that recipient used their approval link for the execution until
+	// expires, and reports false when they already have. Redemptions that expired before now are
+	// forgotten.
+	RedeemApprovalLink(ctx context.Context, executionID, recipient string, expires, now time.Time) (bool, error)
+	ApprovalLinkRedeemed(ctx context.Context, executionID, recipient string) (bool, error)
+	// ReleaseApprovalLink forgets a redemption whose decision could not be recorded.
+	ReleaseApprovalLink(ctx context.Context, executionID, recipient string) error
+}
+
+// MemoryRepository keeps executions in process memory; it is used when no database is configured.
+type MemoryRepository struct {
+	mu          sync.RWMutex
+	executions  map[string]Execution
+	approvals   map[string]Approval
+	events      map[string][]ApprovalEvent
+	delegations map[string]ApprovalDelegation
+	schedules   map[string]CommandSchedule
+	windows     map[string]ChangeWindow
+	rollbacks   map[string]RollbackPlan
+	snapshots   map[string]ReadSnapshot
+	redeemed    map[linkRedemption]time.Time
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		executions:  make(map[string]Execution),
+		approvals:   make(map[string]Approval),
+		events:      make(map[string][]ApprovalEvent),
+		delegations: make(map[string]ApprovalDelegation),
+		schedules:   make(map[string]CommandSchedule),
+		windows:     make(map[string]ChangeWindow),
+		rollbacks:   make(map[string]RollbackPlan),
+		snapshots:   make(map[string]ReadSnapshot),
+		redeemed:    make(map[linkRedemption]time.Time),
+	}
+}
+
+func (r *MemoryRepository) Create(ctx context.Context, execution Execution) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.executions[execution.ID] = cloneExecution(execution)
+	return nil
+}
+
+func (r *MemoryRepository) Update(ctx context.Context, execution Execution) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.executions[execution.ID]
+	if !ok {
+		return ErrExecutionNotFound{ID: execution.ID}
+	}
+	execution = cloneExecution(execution)
+	execution.DeletedAt, execution.DeletedBy = stored.DeletedAt, stored.DeletedBy
+	r.executions[execution.ID] = execution
+	return nil
+}
+
+func (r *MemoryRepository) GetByID(ctx context.Context, id string) (Execution, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	execution, ok := r.executions[id]
+	if !ok {
+		return Execution{}, ErrExecutionNotFound{ID: id}
+	}
+	return cloneExecution(execution), nil
+}
+
+func (r *MemoryRepository) ListByUser(ctx context.Context, userID string, limit, offset int) ([]Execution, error) {
+	r.mu.RLock()
+	var executions []Execution
+	for _, execution := range r.executions {
+		if execution.DeletedAt == nil && (userID == "" || execution.UserID == userID) {
+			executions = append(executions, cloneExecution(execution))
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(executions, func(i, j int) bool {
+		return executions[i].CreatedAt.After(executions[j].CreatedAt)
+	})
+	return paginate(executions, limit, offset), nil
+}
+
+func (r *MemoryRepository) ListByTarget(ctx context.Context, filter AuditFilter) ([]Execution, error) {
+	r.mu.RLock()
+	var executions []Execution
+	for _, execution := range r.executions {
+		target := TargetOf(execution)
+		if filter.Target.Cluster != "" && target.Cluster != filter.Target.Cluster {
+			continue
+		}
+		target.Cluster = filter.Target.Cluster
+		if target == filter.Target {
+			executions = append(executions, cloneExecution(execution))
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(executions, func(i, j int) bool {
+		return executions[i].CreatedAt.After(executions[j].CreatedAt)
+	})
+	return paginate(executions, filter.Limit, filter.Offset), nil
+}
+
+func (r *MemoryRepository) ListByCorrelation(ctx context.Context, correlationID string) ([]Execution, error) {
+	r.mu.RLock()
+	executions := make([]Execution, 0)
+	for _, execution := range r.executions {
+		if execution.CorrelationID == correlationID {
+			executions = append(executions, cloneExecution(execution))
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(executions, func(i, j int) bool {
+		return executions[i].CreatedAt.Before(executions[j].CreatedAt)
+	})
+	return executions, nil
+}
+
+func (r *MemoryRepository) ListByStatus(ctx context.Context, status Status) ([]Execution, error) {
+	r.mu.RLock()
+	executions := make([]Execution, 0)
+	for _, execution := range r.executions {
+		if execution.Status == status {
+			executions = append(executions, cloneExecution(execution))
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(executions, func(i, j int) bool {
+		return executions[i].CreatedAt.Before(executions[j].CreatedAt)
+	})
+	return executions, nil
+}
+
+func (r *MemoryRepository) MarkDeleted(ctx context.Context, id, user string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	execution, ok := r.executions[id]
+	if !ok {
+		return ErrExecutionNotFound{ID: id}
+	}
+	execution.DeletedAt, execution.DeletedBy = &at, user
+	r.executions[id] = execution
+	return nil
+}
+
+func (r *MemoryRepository) ListPurgeable(ctx context.Context, filter PurgeFilter) ([]Execution, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var executions []Execution
+	for _, execution := range r.executions {
+		if filter.matches(execution) {
+			executions = append(executions, execution)
+		}
+	}
+	sort.Slice(executions, func(i, j int) bool { return executions[i].CreatedAt.Before(executions[j].CreatedAt) })
+	if filter.Limit > 0 && len(executions) > filter.Limit {
+		executions = executions[:filter.Limit]
+	}
+	return executions, nil
+}
+
+func (r *MemoryRepository) CountPurgeable(ctx context.Context, filter PurgeFilter) (int, error) {
+	filter.Limit = 0
+	executions, err := r.ListPurgeable(ctx, filter)
+	return len(executions), err
+}
+
+func (r *MemoryRepository) DeleteFinished(ctx context.Context, ids []string) ([]Execution, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var deleted []Execution
+	for _, id := range ids {
+		execution, ok := r.executions[id]
+		if !ok || !execution.finished() {
+			continue
+		}
+		deleted = append(deleted, execution)
+		delete(r.executions, id)
+		delete(r.approvals, id)
+		delete(r.events, id)
+		delete(r.rollbacks, id)
+	}
+	return deleted, nil
+}
+
+func (r *MemoryRepository) PseudonymizeUser(ctx context.Context, user, pseudonym string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	replace := func(name *string) bool {
+		if *name == user {
+			*name = pseudonym
+			return true
+		}
+		return false
+	}
+	changed := map[string]bool{}
+	for id, execution := range r.executions {
+		if replace(&execution.UserID) {
+			r.executions[id] = execution
+			changed[id] = true
+		}
+	}
+	for id, approval := range r.approvals {
+		approval = cloneApproval(approval)
+		hit := replace(&approval.RequestedBy)
+		hit = replace(&approval.DecidedBy) || hit
+		for i := range approval.Decisions {
+			hit = replace(&approval.Decisions[i].User) || hit
+			hit = replace(&approval.Decisions[i].OnBehalfOf) || hit
+		}
+		if hit {
+			approval.Version++
+			r.approvals[id] = approval
+			changed[id] = true
+		}
+	}
+	for id, events := range r.events {
+		events = slices.Clone(events)
+		hit := false
+		for i := range events {
+			hit = replace(&events[i].Actor) || hit
+			hit = replace(&events[i].OnBehalfOf) || hit
+		}
+		if hit {
+			r.events[id] = events
+			changed[id] = true
+		}
+	}
+	return len(changed), nil
+}
+
+func (r *MemoryRepository) SaveApproval(ctx context.Context, approval Approval) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.approvals[approval.ExecutionID].Version != approval.Version {
+		return ErrApprovalConflict
+	}
+	approval = cloneApproval(approval)
+	approval.Version++
+	r.approvals[approval.ExecutionID] = approval
+	return nil
+}
+
+func (r *MemoryRepository) GetApproval(ctx context.Context, executionID string) (Approval, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	approval, ok := r.approvals[executionID]
+	if !ok {
+		return Approval{}, ErrApprovalNotFound{ExecutionID: executionID}
+	}
+	return cloneApproval(approval), nil
+}
+
+func (r *MemoryRepository) ListApprovals(ctx context.Context, status ApprovalStatus) ([]Approval, error) {
+	r.mu.RLock()
+	var approvals []Approval
+	for _, approval := range r.approvals {
+		if status == "" || approval.Status == status {
+			approvals = append(approvals, cloneApproval(approval))
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(approvals, func(i, j int) bool {
+		return approvals[i].CreatedAt.Before(approvals[j].CreatedAt)
+	})
+	return approvals, nil
+}
+
+func (r *MemoryRepository) AppendApprovalEvent(ctx context.Context, event ApprovalEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events[event.ExecutionID] = append(r.events[event.ExecutionID], event)
+	return nil
+}
+
+func (r *MemoryRepository) ListApprovalEvents(ctx context.Context, executionID string) ([]ApprovalEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.events[executionID]), nil
+}
+
+func (r *MemoryRepository) SaveDelegation(ctx context.Context, delegation ApprovalDelegation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.delegations[delegation.ID] = delegation
+	return nil
+}
+
+func (r *MemoryRepository) GetDelegation(ctx context.Context, id string) (ApprovalDelegation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	delegation, ok := r.delegations[id]
+	if !ok {
+		return ApprovalDelegation{}, ErrDelegationNotFound{ID: id}
+	}
+	return delegation, nil
+}
+
+func (r *MemoryRepository) ListDelegations(ctx context.Context) ([]ApprovalDelegation, error) {
+	r.mu.RLock()
+	delegations := slices.Collect(maps.Values(r.delegations))
+	r.mu.RUnlock()
+
+	sort.Slice(delegations, func(i, j int) bool {
+		return delegations[i].StartsAt.Before(delegations[j].StartsAt)
+	})
+	return delegations, nil
+}
+
+func (r *MemoryRepository) SaveSchedule(ctx context.Context, schedule CommandSchedule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.schedules[schedule.ID]; ok && existing.Status == ScheduleCancelled {
+		return nil
+	}
+	r.schedules[schedule.ID] = cloneSchedule(schedule)
+	return nil
+}
+
+func (r *MemoryRepository) GetSchedule(ctx context.Context, id string) (CommandSchedule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	schedule, ok := r.schedules[id]
+	if !ok {
+		return CommandSchedule{}, ErrScheduleNotFound{ID: id}
+	}
+	return cloneSchedule(schedule), nil
+}
+
+func (r *MemoryRepository) ListSchedules(ctx context.Context, userID string) ([]CommandSchedule, error) {
+	r.mu.RLock()
+	var schedules []CommandSchedule
+	for _, schedule := range r.schedules {
+		if schedule.UserID == userID {
+			schedules = append(schedules, cloneSchedule(schedule))
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].CreatedAt.After(schedules[j].CreatedAt)
+	})
+	return schedules, nil
+}
+
+func (r *MemoryRepository) ListDueSchedules(ctx context.Context, at time.Time) ([]CommandSchedule, error) {
+	r.mu.RLock()
+	var schedules []CommandSchedule
+	for _, schedule := range r.schedules {
+		if schedule.Status == ScheduleActive && schedule.NextRunAt != nil && !schedule.NextRunAt.After(at) {
+			schedules = append(schedules, cloneSchedule(schedule))
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].NextRunAt.Before(*schedules[j].NextRunAt)
+	})
+	return schedules, nil
+}
+
+func (r *MemoryRepository) SaveChangeWindow(ctx context.Context, window ChangeWindow) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.windows[window.ID] = window
+	return nil
+}
+
+func (r *MemoryRepository) ListChangeWindows(ctx context.Context) ([]ChangeWindow, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	windows := make([]ChangeWindow, 0, len(r.windows))
+	for _, window := range r.windows {
+		windows = append(windows, window)
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i].CreatedAt.After(windows[j].CreatedAt) })
+	return windows, nil
+}
+
+func (r *MemoryRepository) DeleteChangeWindow(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.windows[id]; !ok {
+		return ErrChangeWindowNotFound{ID: id}
+	}
+	delete(r.windows, id)
+	return nil
+}
+
+func (r *MemoryRepository) SaveRollbackPlan(ctx context.Context, plan RollbackPlan) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	plan.Steps = slices.Clone(plan.Steps)
+	plan.Changes = slices.Clone(plan.Changes)
+	r.rollbacks[plan.ExecutionID] = plan
+	return nil
+}
+
+func (r *MemoryRepository) GetRollbackPlan(ctx context.Context, executionID string) (RollbackPlan, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	plan, ok := r.rollbacks[executionID]
+	if !ok {
+		return RollbackPlan{}, ErrRollbackNotFound{ExecutionID: executionID}
+	}
+	plan.Steps = slices.Clone(plan.Steps)
+	plan.Changes = slices.Clone(plan.Changes)
+	return plan, nil
+}
+
+func (r *MemoryRepository) SaveReadSnapshot(ctx context.Context, snapshot ReadSnapshot) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	snapshot.Objects = slices.Clone(snapshot.Objects)
+	r.snapshots[snapshot.Signature] = snapshot
+	return nil
+}
+
+func (r *MemoryRepository) GetReadSnapshot(ctx context.Context, signature string) (ReadSnapshot, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	snapshot, ok := r.snapshots[signature]
+	if !ok {
+		return ReadSnapshot{}, ErrNoReadSnapshot
+	}
+	snapshot.Objects = slices.Clone(snapshot.Objects)
+	return snapshot, nil
+}
+
+// linkRedemption identifies the approval links of one recipient for one execution.
+type linkRedemption struct {
+	executionID, recipient string
+}
+
+func (r *MemoryRepository) RedeemApprovalLink(ctx context.Context, executionID, recipient string, expires, now time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for used, until := range r.redeemed {
+		if now.After(until) {
+			delete(r.redeemed, used)
+		}
+	}
+	key := linkRedemption{executionID: executionID, recipient: recipient}
+	if _, used := r.redeemed[key]; used {
+		return false, nil
+	}
+	r.redeemed[key] = expires
+	return true, nil
+}
+
+func (r *MemoryRepository) ApprovalLinkRedeemed(ctx context.Context, executionID, recipient string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, used := r.redeemed[linkRedemption{executionID: executionID, recipient: recipient}]
+	return used, nil
+}
+
+func (r *MemoryRepository) ReleaseApprovalLink(ctx context.Context, executionID, recipient string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.redeemed, linkRedemption{executionID: executionID, recipient: recipient})
+	return nil
+}
+
+func cloneExecution(execution Execution) Execution {
+	execution.Parameters = maps.Clone(execution.Parameters)
+	execution.Attachments = slices.Clone(execution.Attachments)
+	execution.Changes = slices.Clone(execution.Changes)
+	execution.Steps = slices.Clone(execution.Steps)
+	for i := range execution.Steps {
+		execution.Steps[i].Parameters = maps.Clone(execution.Steps[i].Parameters)
+	}
+	return execution
+}
+
+func cloneApproval(approval Approval) Approval {
+	approval.ApproverGroups = slices.Clone(approval.ApproverGroups)
+	approval.Decisions = slices.Clone(approval.Decisions)
+	return approval
+}
+
+func cloneSchedule(schedule CommandSchedule) CommandSchedule {
+	schedule.Request.Parameters = maps.Clone(schedule.Request.Parameters)
+	schedule.Request.Groups = slices.Clone(schedule.Request.Groups)
+	return schedule
+}
+
+func paginate[T any](items []T, limit, offset int) []T {
+	if offset > 0 {
+		if offset >= len(items) {
+			return []T{}
+		}
+		items = items[offset:]
+	}
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
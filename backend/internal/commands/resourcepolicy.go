@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// ResourcePolicy limits the API resources commands may touch. Entries use kubectl's
+// resource.group form, such as "secrets", "deployments.apps" or "*.cert-manager.io", where "*"
+// matches any resource or group. Deny wins over Allow, and an empty Allow allows everything else.
+type ResourcePolicy struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// DefaultResourcePolicy applies when no policy is configured: everything except secrets.
+var DefaultResourcePolicy = ResourcePolicy{Deny: []string{"secrets"}}
+
+type ErrResourceNotAllowed struct {
+	Resource schema.GroupResource
+}
+
+func (e ErrResourceNotAllowed) Error() string {
+	return fmt.Sprintf("%s are not allowed by the resource policy", e.Resource)
+}
+
+func LoadResourcePolicy(path string) (*ResourcePolicy, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var policy ResourcePolicy
+	if err := yaml.UnmarshalStrict(raw, &policy); err != nil {
+		return nil, fmt.Errorf("parse resource policy %s: %w", path, err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (p *ResourcePolicy) Validate() error {
+	for _, entry := range append(append([]string{}, p.Allow...), p.Deny...) {
+		if strings.TrimSpace(entry) == "" || strings.ContainsAny(entry, " /") {
+			return fmt.Errorf("resource policy entry %q must look like resource.group", entry)
+		}
+	}
+	return nil
+}
+
+// Allows reports whether commands may operate on gr.
+func (p *ResourcePolicy) Allows(gr schema.GroupResource) bool {
+	if p == nil {
+		p = &DefaultResourcePolicy
+	}
+	for _, entry := range p.Deny {
+		if matchesResource(entry, gr) {
+			return false
+		}
+	}
+	if len(p.Allow) == 0 {
+		return true
+	}
+	for _, entry := range p.Allow {
+		if matchesResource(entry, gr) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetResourcePolicy replaces DefaultResourcePolicy for every operation that is not delegated.
+func (s *Service) SetResourcePolicy(policy *ResourcePolicy) {
+	s.resources = policy
+}
+
+// checkResourcePolicy refuses req when the resource it operates on is not allowed.
+func (s *Service) checkResourcePolicy(req Request) error {
+	if helmOperation(req.Operation) {
+		return s.checkReleasePolicy(req)
+	}
+	gr, err := s.accessResource(req)
+	if err != nil {
+		return err
+	}
+	if resource := (schema.GroupResource{Group: gr[0], Resource: gr[1]}); !s.resources.Allows(resource) {
+		return ErrResourceNotAllowed{Resource: resource}
+	}
+	return nil
+}
+
+func matchesResource(entry string, gr schema.GroupResource) bool {
+	if entry == "*" {
+		return true
+	}
+	resource, group, _ := strings.Cut(strings.ToLower(entry), ".")
+	return (resource == "*" || resource == gr.Resource) && (group == "*" || group == gr.Group)
+}
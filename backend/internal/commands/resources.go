@@ -0,0 +1,208 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// supportedKinds lists the kinds commands can read, change and restore.
+var supportedKinds = map[string]bool{
+	"deployment":  true,
+	"statefulset": true,
+	"daemonset":   true,
+	"pod":         true,
+	"configmap":   true,
+	"service":     true,
+}
+
+func newObject(kind string) (metav1.Object, error) {
+	switch strings.ToLower(kind) {
+	case "deployment":
+		return &appsv1.Deployment{TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"}}, nil
+	case "statefulset":
+		return &appsv1.StatefulSet{TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "StatefulSet"}}, nil
+	case "daemonset":
+		return &appsv1.DaemonSet{TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "DaemonSet"}}, nil
+	case "pod":
+		return &corev1.Pod{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"}}, nil
+	case "configmap":
+		return &corev1.ConfigMap{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"}}, nil
+	case "service":
+		return &corev1.Service{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Service"}}, nil
+	}
+	return nil, ErrInvalidRequest{Reason: fmt.Sprintf("unsupported kind %q", kind)}
+}
+
+func getObject(ctx context.Context, client kubernetes.Interface, resource Resource) (metav1.Object, error) {
+	opts := metav1.GetOptions{}
+	ns, name := resource.Namespace, resource.Name
+	switch strings.ToLower(resource.Kind) {
+	case "deployment":
+		return client.AppsV1().Deployments(ns).Get(ctx, name, opts)
+	case "statefulset":
+		return client.AppsV1().StatefulSets(ns).Get(ctx, name, opts)
+	case "daemonset":
+		return client.AppsV1().DaemonSets(ns).Get(ctx, name, opts)
+	case "pod":
+		return client.CoreV1().Pods(ns).Get(ctx, name, opts)
+	case "configmap":
+		return client.CoreV1().ConfigMaps(ns).Get(ctx, name, opts)
+	case "service":
+		return client.CoreV1().Services(ns).Get(ctx, name, opts)
+	}
+	return nil, ErrInvalidRequest{Reason: fmt.Sprintf("unsupported kind %q", resource.Kind)}
+}
+
+// listObjects lists the objects of resource's kind in its namespace that match selector.
+func listObjects(ctx context.Context, client kubernetes.Interface, resource Resource, selector string) ([]metav1.Object, error) {
+	opts := metav1.ListOptions{LabelSelector: selector}
+	ns := resource.Namespace
+	var (
+		list runtime.Object
+		err  error
+	)
+	switch strings.ToLower(resource.Kind) {
+	case "deployment":
+		list, err = client.AppsV1().Deployments(ns).List(ctx, opts)
+	case "statefulset":
+		list, err = client.AppsV1().StatefulSets(ns).List(ctx, opts)
+	case "daemonset":
+		list, err = client.AppsV1().DaemonSets(ns).List(ctx, opts)
+	case "pod":
+		list, err = client.CoreV1().Pods(ns).List(ctx, opts)
+	case "configmap":
+		list, err = client.CoreV1().ConfigMaps(ns).List(ctx, opts)
+	case "service":
+		list, err = client.CoreV1().Services(ns).List(ctx, opts)
+	default:
+		return nil, ErrInvalidRequest{Reason: fmt.Sprintf("unsupported kind %q", resource.Kind)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]metav1.Object, 0, len(items))
+	for _, item := range items {
+		obj, err := meta.Accessor(item)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// liveResourceVersion returns the object's current resourceVersion, or "" when it cannot be read,
+// such as after it was deleted.
+func liveResourceVersion(ctx context.Context, client kubernetes.Interface, resource Resource) string {
+	obj, err := getObject(ctx, client, resource)
+	if err != nil {
+		return ""
+	}
+	return obj.GetResourceVersion()
+}
+
+func createObject(ctx context.Context, client kubernetes.Interface, obj metav1.Object) error {
+	opts := metav1.CreateOptions{FieldManager: FieldManager}
+	var err error
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		_, err = client.AppsV1().Deployments(o.Namespace).Create(ctx, o, opts)
+	case *appsv1.StatefulSet:
+		_, err = client.AppsV1().StatefulSets(o.Namespace).Create(ctx, o, opts)
+	case *appsv1.DaemonSet:
+		_, err = client.AppsV1().DaemonSets(o.Namespace).Create(ctx, o, opts)
+	case *corev1.Pod:
+		_, err = client.CoreV1().Pods(o.Namespace).Create(ctx, o, opts)
+	case *corev1.ConfigMap:
+		_, err = client.CoreV1().ConfigMaps(o.Namespace).Create(ctx, o, opts)
+	case *corev1.Service:
+		_, err = client.CoreV1().Services(o.Namespace).Create(ctx, o, opts)
+	default:
+		err = fmt.Errorf("cannot create %T", obj)
+	}
+	return err
+}
+
+func updateObject(ctx context.Context, client kubernetes.Interface, obj metav1.Object) error {
+	opts := metav1.UpdateOptions{FieldManager: FieldManager}
+	var err error
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		_, err = client.AppsV1().Deployments(o.Namespace).Update(ctx, o, opts)
+	case *appsv1.StatefulSet:
+		_, err = client.AppsV1().StatefulSets(o.Namespace).Update(ctx, o, opts)
+	case *appsv1.DaemonSet:
+		_, err = client.AppsV1().DaemonSets(o.Namespace).Update(ctx, o, opts)
+	case *corev1.Pod:
+		_, err = client.CoreV1().Pods(o.Namespace).Update(ctx, o, opts)
+	case *corev1.ConfigMap:
+		_, err = client.CoreV1().ConfigMaps(o.Namespace).Update(ctx, o, opts)
+	case *corev1.Service:
+		_, err = client.CoreV1().Services(o.Namespace).Update(ctx, o, opts)
+	default:
+		err = fmt.Errorf("cannot update %T", obj)
+	}
+	return err
+}
+
+// patchObject patches the object and returns it as patched. With dryRun the API server validates
+// and returns the result without persisting it.
+func patchObject(ctx context.Context, client kubernetes.Interface, resource Resource, patchType types.PatchType, data []byte, dryRun bool) (metav1.Object, error) {
+	opts := metav1.PatchOptions{FieldManager: FieldManager, DryRun: dryRunOption(dryRun)}
+	ns, name := resource.Namespace, resource.Name
+	switch strings.ToLower(resource.Kind) {
+	case "deployment":
+		return client.AppsV1().Deployments(ns).Patch(ctx, name, patchType, data, opts)
+	case "statefulset":
+		return client.AppsV1().StatefulSets(ns).Patch(ctx, name, patchType, data, opts)
+	case "daemonset":
+		return client.AppsV1().DaemonSets(ns).Patch(ctx, name, patchType, data, opts)
+	case "pod":
+		return client.CoreV1().Pods(ns).Patch(ctx, name, patchType, data, opts)
+	case "configmap":
+		return client.CoreV1().ConfigMaps(ns).Patch(ctx, name, patchType, data, opts)
+	case "service":
+		return client.CoreV1().Services(ns).Patch(ctx, name, patchType, data, opts)
+	}
+	return nil, ErrInvalidRequest{Reason: fmt.Sprintf("unsupported kind %q", resource.Kind)}
+}
+
+func deleteObject(ctx context.Context, client kubernetes.Interface, resource Resource, dryRun bool) error {
+	opts := metav1.DeleteOptions{DryRun: dryRunOption(dryRun)}
+	ns, name := resource.Namespace, resource.Name
+	switch strings.ToLower(resource.Kind) {
+	case "deployment":
+		return client.AppsV1().Deployments(ns).Delete(ctx, name, opts)
+	case "statefulset":
+		return client.AppsV1().StatefulSets(ns).Delete(ctx, name, opts)
+	case "daemonset":
+		return client.AppsV1().DaemonSets(ns).Delete(ctx, name, opts)
+	case "pod":
+		return client.CoreV1().Pods(ns).Delete(ctx, name, opts)
+	case "configmap":
+		return client.CoreV1().ConfigMaps(ns).Delete(ctx, name, opts)
+	case "service":
+		return client.CoreV1().Services(ns).Delete(ctx, name, opts)
+	}
+	return ErrInvalidRequest{Reason: fmt.Sprintf("unsupported kind %q", resource.Kind)}
+}
+
+func dryRunOption(dryRun bool) []string {
+	if dryRun {
+		return []string{metav1.DryRunAll}
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+type RollbackAction string
+
+const (
+	// RollbackRestore writes the snapshot back over the live object.
+	RollbackRestore RollbackAction = "restore"
+	// RollbackRecreate creates the object again from the snapshot after a delete.
+	RollbackRecreate RollbackAction = "recreate"
+	// RollbackRemove deletes an object the execution created; it has no snapshot.
+	RollbackRemove RollbackAction = "remove"
+)
+
+// FieldChange is one difference between the live object and the state a rollback would restore.
+type FieldChange struct {
+	Path    string `json:"path"`
+	Current any    `json:"current,omitempty"`
+	Restore any    `json:"restore,omitempty"`
+}
+
+type RollbackStepPreview struct {
+	Action   RollbackAction `json:"action"`
+	Resource Resource       `json:"resource"`
+	Changes  []FieldChange  `json:"changes,omitempty"`
+	Conflict string         `json:"conflict,omitempty"`
+}
+
+type RollbackPreview struct {
+	ExecutionID string                `json:"executionId"`
+	Steps       []RollbackStepPreview `json:"steps"`
+	// Applicable is false when a step would conflict with the live state.
+	Applicable bool `json:"applicable"`
+}
+
+// snapshot captures the full state of resource so it can be restored later.
+func snapshot(ctx context.Context, client kubernetes.Interface, resource Resource) (metav1.Object, json.RawMessage, error) {
+	obj, err := getObject(ctx, client, resource)
+	if err != nil {
+		return nil, nil, err
+	}
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return nil, nil, err
+	}
+	return obj, raw, nil
+}
+
+func decodeSnapshot(step RollbackStep) (metav1.Object, error) {
+	obj, err := newObject(step.Resource.Kind)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(step.Snapshot, obj); err != nil {
+		return nil, fmt.Errorf("decode snapshot of %s: %w", step.Resource, err)
+	}
+	return obj, nil
+}
+
+func restore(ctx context.Context, client kubernetes.Interface, step RollbackStep) error {
+	if step.Action == RollbackRemove {
+		return deleteObject(ctx, client, step.Resource, false)
+	}
+	obj, err := decodeSnapshot(step)
+	if err != nil {
+		return err
+	}
+	obj.SetManagedFields(nil)
+
+	switch step.Action {
+	case RollbackRecreate:
+		obj.SetResourceVersion("")
+		obj.SetUID("")
+		obj.SetCreationTimestamp(metav1.Time{})
+		obj.SetDeletionTimestamp(nil)
+		obj.SetGeneration(0)
+		return createObject(ctx, client, obj)
+	case RollbackRestore:
+		current, err := getObject(ctx, client, step.Resource)
+		if err != nil {
+			return err
+		}
+		obj.SetResourceVersion(current.GetResourceVersion())
+		return updateObject(ctx, client, obj)
+	}
+	return fmt.Errorf("unknown rollback action %q", step.Action)
+}
+
+func previewStep(ctx context.Context, client kubernetes.Interface, step RollbackStep) (RollbackStepPreview, error) {
+	preview := RollbackStepPreview{Action: step.Action, Resource: step.Resource}
+	if step.Action == RollbackRemove {
+		if _, err := getObject(ctx, client, step.Resource); apierrors.IsNotFound(err) {
+			preview.Conflict = "resource no longer exists"
+		} else if err != nil {
+			return preview, err
+		}
+		return preview, nil
+	}
+	target, err := decodeSnapshot(step)
+	if err != nil {
+		return preview, err
+	}
+
+	current, err := getObject(ctx, client, step.Resource)
+	switch {
+	case apierrors.IsNotFound(err):
+		if step.Action == RollbackRestore {
+			preview.Conflict = "resource no longer exists"
+		}
+		return preview, nil
+	case err != nil:
+		return preview, err
+	case step.Action == RollbackRecreate:
+		preview.Conflict = "resource already exists again"
+		return preview, nil
+	}
+
+	preview.Changes, err = diffObjects(current, target)
+	return preview, err
+}
+
+// diffObjects lists the spec, data, label and annotation fields that differ between current and target.
+func diffObjects(current, target metav1.Object) ([]FieldChange, error) {
+	currentFields, err := comparableFields(current)
+	if err != nil {
+		return nil, err
+	}
+	targetFields, err := comparableFields(target)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]bool)
+	for path := range currentFields {
+		paths[path] = true
+	}
+	for path := range targetFields {
+		paths[path] = true
+	}
+	var changes []FieldChange
+	for path := range paths {
+		if !reflect.DeepEqual(currentFields[path], targetFields[path]) {
+			changes = append(changes, FieldChange{Path: path, Current: currentFields[path], Restore: targetFields[path]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+func comparableFields(obj metav1.Object) (map[string]any, error) {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil, err
+	}
+	fields := make(map[string]any)
+	for _, key := range []string{"spec", "data", "binaryData"} {
+		if value, ok := decoded[key]; ok {
+			flatten(key, value, fields)
+		}
+	}
+	if metadata, ok := decoded["metadata"].(map[string]any); ok {
+		for _, key := range []string{"labels", "annotations"} {
+			if value, ok := metadata[key]; ok {
+				flatten("metadata."+key, value, fields)
+			}
+		}
+	}
+	return fields, nil
+}
+
+func flatten(prefix string, value any, out map[string]any) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			flatten(prefix+"."+key, child, out)
+		}
+	case []any:
+		for i, child := range v {
+			flatten(fmt.Sprintf("%s[%d]", prefix, i), child, out)
+		}
+	default:
+		out[prefix] = v
+	}
+}
+
+// hasController reports whether obj is owned by a controller that recreates it after deletion.
+func hasController(obj metav1.Object) bool {
+	for _, owner := range obj.GetOwnerReferences() {
+		if owner.Controller != nil && *owner.Controller {
+			return true
+		}
+	}
+	return false
+}
+
+func describeRollback(steps []RollbackStep) string {
+	parts := make([]string, 0, len(steps))
+	for _, step := range steps {
+		parts = append(parts, fmt.Sprintf("%s %s", step.Action, step.Resource))
+	}
+	return strings.Join(parts, ", ")
+}
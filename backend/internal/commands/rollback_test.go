@@ -0,0 +1,158 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDeletedConfigMapIsRecreatedOnRollback(t *testing.T) {
+	svc, client, _ := newTestService(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "shop", Labels: map[string]string{"app": "api"}},
+		Data:       map[string]string{"mode": "live"},
+	})
+	ctx := context.Background()
+
+	execution, err := svc.Submit(ctx, Request{
+		UserID:    "alice",
+		Config:    "kubeconfig",
+		Cluster:   "prod",
+		Operation: OperationDelete,
+		Resource:  Resource{Kind: "ConfigMap", Namespace: "shop", Name: "settings"},
+	})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if execution.SafetyLevel != SafetyDangerous {
+		t.Fatalf("expected configmap delete to be dangerous, got %s", execution.SafetyLevel)
+	}
+	if execution, err = svc.Approve(ctx, execution.ID, "bob", nil, ""); err != nil || execution.Status != StatusSucceeded {
+		t.Fatalf("approve: %+v (%v)", execution, err)
+	}
+
+	preview, err := svc.PreviewRollback(ctx, execution.ID)
+	if err != nil || !preview.Applicable || preview.Steps[0].Action != RollbackRecreate {
+		t.Fatalf("unexpected preview %+v (%v)", preview, err)
+	}
+	rollback, err := svc.Rollback(ctx, execution.ID, "alice", nil)
+	if err != nil || rollback.Status != StatusAwaitingApproval {
+		t.Fatalf("expected the rollback of a %s command to await approval, got %+v (%v)", execution.SafetyLevel, rollback, err)
+	}
+	if _, err := svc.Approve(ctx, rollback.ID, "bob", nil, ""); err != nil {
+		t.Fatalf("approve rollback: %v", err)
+	}
+	restored, err := client.CoreV1().ConfigMaps("shop").Get(ctx, "settings", metav1.GetOptions{})
+	if err != nil || restored.Data["mode"] != "live" || restored.Labels["app"] != "api" {
+		t.Fatalf("expected configmap to be recreated, got %+v (%v)", restored, err)
+	}
+}
+
+func TestPatchRollbackRestoresPreviousState(t *testing.T) {
+	svc, client, _ := newTestService(deployment(2))
+	ctx := context.Background()
+
+	execution, err := svc.Submit(ctx, Request{
+		UserID:     "alice",
+		Config:     "kubeconfig",
+		Cluster:    "prod",
+		Operation:  OperationPatch,
+		Resource:   Resource{Kind: "Deployment", Namespace: "shop", Name: "api"},
+		Parameters: map[string]string{"patchType": "merge", "patch": `{"metadata":{"labels":{"tier":"gold"}}}`},
+	})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if execution, err = svc.Approve(ctx, execution.ID, "bob", nil, ""); err != nil || execution.Status != StatusSucceeded {
+		t.Fatalf("approve: %+v (%v)", execution, err)
+	}
+	patched, _ := client.AppsV1().Deployments("shop").Get(ctx, "api", metav1.GetOptions{})
+	if patched.Labels["tier"] != "gold" {
+		t.Fatalf("expected patch to be applied, got %v", patched.Labels)
+	}
+
+	preview, err := svc.PreviewRollback(ctx, execution.ID)
+	if err != nil || len(preview.Steps[0].Changes) != 1 || preview.Steps[0].Changes[0].Path != "metadata.labels.tier" {
+		t.Fatalf("unexpected preview %+v (%v)", preview, err)
+	}
+	rollback, err := svc.Rollback(ctx, execution.ID, "alice", nil)
+	if err != nil || rollback.Status != StatusAwaitingApproval {
+		t.Fatalf("expected the rollback of a %s command to await approval, got %+v (%v)", execution.SafetyLevel, rollback, err)
+	}
+	if _, err := svc.Approve(ctx, rollback.ID, "bob", nil, ""); err != nil {
+		t.Fatalf("approve rollback: %v", err)
+	}
+	restored, _ := client.AppsV1().Deployments("shop").Get(ctx, "api", metav1.GetOptions{})
+	if _, ok := restored.Labels["tier"]; ok {
+		t.Fatalf("expected label to be removed by rollback, got %v", restored.Labels)
+	}
+}
+
+func TestControllerOwnedPodDeleteHasNoRollback(t *testing.T) {
+	controller := true
+	svc, _, _ := newTestService(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:            "api-1",
+		Namespace:       "shop",
+		OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "api-7d", Controller: &controller}},
+	}})
+	ctx := context.Background()
+
+	execution, _ := svc.Submit(ctx, Request{
+		UserID: "alice", Config: "c", Cluster: "k", Operation: OperationDelete,
+		Resource: Resource{Kind: "Pod", Namespace: "shop", Name: "api-1"},
+	})
+	execution, err := svc.Approve(ctx, execution.ID, "bob", nil, "")
+	if err != nil || execution.Status != StatusSucceeded {
+		t.Fatalf("approve: %+v (%v)", execution, err)
+	}
+	if _, err := svc.PreviewRollback(ctx, execution.ID); !errors.Is(err, ErrRollbackUnavailable) {
+		t.Fatalf("expected no rollback for controller-owned pod, got %v", err)
+	}
+}
+
+// namespaceRoles lets each user run and approve commands in the namespaces listed for them.
+type namespaceRoles map[string][]string
+
+func (r namespaceRoles) check(user, namespace string) error {
+	if !slices.Contains(r[user], namespace) {
+		return fmt.Errorf("%s has no role in namespace %s", user, namespace)
+	}
+	return nil
+}
+
+func (r namespaceRoles) CheckExecute(ctx context.Context, user string, groups []string, namespace string) error {
+	return r.check(user, namespace)
+}
+
+func (r namespaceRoles) CheckApprove(ctx context.Context, user string, groups []string, namespace string) error {
+	return r.check(user, namespace)
+}
+
+func TestRollbackNeedsTheCallersRoleInTheNamespace(t *testing.T) {
+	svc, client, _ := newTestService(deployment(2))
+	svc.SetRoleChecker(namespaceRoles{"alice": {"shop"}, "carol": {"shop"}, "mallory": {"dev"}})
+	ctx := context.Background()
+
+	execution, err := svc.Submit(ctx, scaleRequest("alice", "5"))
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if _, err := svc.Rollback(ctx, execution.ID, "mallory", nil); err == nil {
+		t.Fatal("expected a caller without a role in shop to be refused")
+	}
+	if got, _ := client.AppsV1().Deployments("shop").Get(ctx, "api", metav1.GetOptions{}); *got.Spec.Replicas != 5 {
+		t.Fatalf("expected the refused rollback to leave 5 replicas, got %d", *got.Spec.Replicas)
+	}
+
+	rollback, err := svc.Rollback(ctx, execution.ID, "carol", nil)
+	if err != nil || rollback.UserID != "carol" || rollback.Status != StatusSucceeded {
+		t.Fatalf("expected carol, who may run commands in shop, to roll back alice's scale, got %+v (%v)", rollback, err)
+	}
+	if got, _ := client.AppsV1().Deployments("shop").Get(ctx, "api", metav1.GetOptions{}); *got.Spec.Replicas != 2 {
+		t.Fatalf("expected replicas restored to 2, got %d", *got.Spec.Replicas)
+	}
+}
@@ -0,0 +1,290 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// builtinRule names the built-in heuristics in classification traces.
+const builtinRule = "builtin"
+
+// SafetyRule sets the safety level of the executions it matches. Every condition of Match must
+// hold; conditions left empty match anything.
+type SafetyRule struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Match       RuleMatch   `json:"match"`
+	Level       SafetyLevel `json:"level"`
+}
+
+// RuleMatch selects executions by what they do and to what. Namespaces are globs such as
+// "prod-*". Labels are compared with the labels of the live object, and replica deltas with the
+// change a scale makes to its current replicas, so rules using them do not match objects that do
+// not exist yet.
+type RuleMatch struct {
+	Operations      []Operation       `json:"operations,omitempty"`
+	Kinds           []string          `json:"kinds,omitempty"`
+	Namespaces      []string          `json:"namespaces,omitempty"`
+	Clusters        []string          `json:"clusters,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	MinReplicaDelta *int              `json:"minReplicaDelta,omitempty"`
+	MaxReplicaDelta *int              `json:"maxReplicaDelta,omitempty"`
+}
+
+// observes reports whether matching needs the live object.
+func (m RuleMatch) observes() bool {
+	return len(m.Labels) > 0 || m.MinReplicaDelta != nil || m.MaxReplicaDelta != nil
+}
+
+// SafetyPolicy is an ordered list of safety rules. The first rule that matches an execution
+// decides its safety level; executions no rule matches keep the built-in classification.
+type SafetyPolicy struct {
+	Rules []SafetyRule `json:"rules"`
+}
+
+// ParseSafetyPolicy reads a policy written as YAML or JSON.
+func ParseSafetyPolicy(raw []byte) (SafetyPolicy, error) {
+	var policy SafetyPolicy
+	if err := yaml.UnmarshalStrict(raw, &policy); err != nil {
+		return SafetyPolicy{}, err
+	}
+	return policy, policy.Validate()
+}
+
+func (p SafetyPolicy) Validate() error {
+	seen := make(map[string]bool, len(p.Rules))
+	for i, rule := range p.Rules {
+		if strings.TrimSpace(rule.Name) == "" {
+			return fmt.Errorf("safety rule %d has no name", i+1)
+		}
+		if seen[rule.Name] {
+			return fmt.Errorf("safety rule %q is defined twice", rule.Name)
+		}
+		seen[rule.Name] = true
+		switch rule.Level {
+		case SafetySafe, SafetyWarning, SafetyDangerous:
+		default:
+			return fmt.Errorf("safety rule %q: level must be safe, warning or dangerous", rule.Name)
+		}
+		for _, pattern := range rule.Match.Namespaces {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("safety rule %q: invalid namespace pattern %q", rule.Name, pattern)
+			}
+		}
+		if lo, hi := rule.Match.MinReplicaDelta, rule.Match.MaxReplicaDelta; lo != nil && hi != nil && *lo > *hi {
+			return fmt.Errorf("safety rule %q: minReplicaDelta is above maxReplicaDelta", rule.Name)
+		}
+	}
+	return nil
+}
+
+// observes reports whether any rule needs the live object.
+func (p SafetyPolicy) observes() bool {
+	return slices.ContainsFunc(p.Rules, func(rule SafetyRule) bool { return rule.Match.observes() })
+}
+
+// Classification is the safety level of a request and how it was reached.
+type Classification struct {
+	Level SafetyLevel `json:"level"`
+	// Rule names the rule that decided the level, or "builtin" for the built-in heuristics.
+	Rule  string      `json:"rule"`
+	Trace []RuleTrace `json:"trace"`
+}
+
+// RuleTrace records whether one rule matched and, when it did not, the condition that failed.
+type RuleTrace struct {
+	Rule    string `json:"rule"`
+	Matched bool   `json:"matched"`
+	Detail  string `json:"detail"`
+}
+
+// safetySubject is what rules are evaluated against. labels and delta are nil when the live
+// object was not observed.
+type safetySubject struct {
+	cluster   string
+	operation Operation
+	resource  Resource
+	params    map[string]string
+	labels    map[string]string
+	delta     *int
+}
+
+// classify evaluates the rules in order and falls back to the built-in heuristics.
+func (p SafetyPolicy) classify(subject safetySubject) Classification {
+	var trace []RuleTrace
+	for _, rule := range p.Rules {
+		detail, matched := rule.Match.evaluate(subject)
+		trace = append(trace, RuleTrace{Rule: rule.Name, Matched: matched, Detail: detail})
+		if matched {
+			return Classification{Level: rule.Level, Rule: rule.Name, Trace: trace}
+		}
+	}
+	level := Classify(subject.operation, subject.resource, subject.params)
+	trace = append(trace, RuleTrace{
+		Rule:    builtinRule,
+		Matched: true,
+		Detail:  fmt.Sprintf("no safety rule matched; the built-in heuristics classify %s of %s as %s", subject.operation, strings.ToLower(subject.resource.Kind), level),
+	})
+	return Classification{Level: level, Rule: builtinRule, Trace: trace}
+}
+
+// evaluate reports whether subject matches and explains the outcome.
+func (m RuleMatch) evaluate(subject safetySubject) (string, bool) {
+	if len(m.Operations) > 0 && !slices.Contains(m.Operations, subject.operation) {
+		return fmt.Sprintf("operation %s is not one of %v", subject.operation, m.Operations), false
+	}
+	if len(m.Kinds) > 0 {
+		kind := NormalizeKind(subject.resource.Kind)
+		if !slices.ContainsFunc(m.Kinds, func(k string) bool { return NormalizeKind(k) == kind }) {
+			return fmt.Sprintf("kind %s is not one of %v", kind, m.Kinds), false
+		}
+	}
+	if len(m.Namespaces) > 0 && !slices.ContainsFunc(m.Namespaces, func(pattern string) bool {
+		matched, _ := path.Match(pattern, subject.resource.Namespace)
+		return matched
+	}) {
+		return fmt.Sprintf("namespace %q does not match %v", subject.resource.Namespace, m.Namespaces), false
+	}
+	if len(m.Clusters) > 0 && !slices.Contains(m.Clusters, subject.cluster) {
+		return fmt.Sprintf("cluster %s is not one of %v", subject.cluster, m.Clusters), false
+	}
+	if len(m.Labels) > 0 {
+		if subject.labels == nil {
+			return "labels could not be read from the live object", false
+		}
+		for _, key := range slices.Sorted(maps.Keys(m.Labels)) {
+			if value, ok := subject.labels[key]; !ok || value != m.Labels[key] {
+				return fmt.Sprintf("object is not labelled %s=%s", key, m.Labels[key]), false
+			}
+		}
+	}
+	if m.MinReplicaDelta != nil || m.MaxReplicaDelta != nil {
+		if subject.delta == nil {
+			return "replica delta applies to scaling an existing deployment or statefulset", false
+		}
+		if m.MinReplicaDelta != nil && *subject.delta < *m.MinReplicaDelta {
+			return fmt.Sprintf("replica delta %d is below %d", *subject.delta, *m.MinReplicaDelta), false
+		}
+		if m.MaxReplicaDelta != nil && *subject.delta > *m.MaxReplicaDelta {
+			return fmt.Sprintf("replica delta %d is above %d", *subject.delta, *m.MaxReplicaDelta), false
+		}
+	}
+	return "all conditions matched", true
+}
+
+// SafetyPolicyStatus reports the rules in force and where they came from.
+type SafetyPolicyStatus struct {
+	Rules []SafetyRule `json:"rules"`
+	// Sources lists where the rules were loaded from, such as configmap/kubechat/safety-policy.
+	Sources  []string   `json:"sources,omitempty"`
+	LoadedAt *time.Time `json:"loadedAt,omitempty"`
+	// Error is the last reload failure; the previously loaded rules stay in force.
+	Error string `json:"error,omitempty"`
+}
+
+// safetyState is swapped atomically when the policy is reloaded.
+type safetyState struct {
+	policy SafetyPolicy
+	status SafetyPolicyStatus
+}
+
+// safetyRules holds the current safety policy. Its zero value has no rules.
+type safetyRules struct {
+	state atomic.Pointer[safetyState]
+}
+
+func (r *safetyRules) load() safetyState {
+	if state := r.state.Load(); state != nil {
+		return *state
+	}
+	return safetyState{}
+}
+
+// SetSafetyPolicy replaces the safety rules; sources describes where they were loaded from.
+func (s *Service) SetSafetyPolicy(policy SafetyPolicy, sources ...string) {
+	loaded := s.now()
+	s.safety.state.Store(&safetyState{
+		policy: policy,
+		status: SafetyPolicyStatus{Rules: policy.Rules, Sources: sources, LoadedAt: &loaded},
+	})
+	s.logger.Info("safety policy loaded", "rules", len(policy.Rules), "sources", strings.Join(sources, ","))
+}
+
+// safetyPolicyFailed records a failed reload while keeping the rules in force.
+func (s *Service) safetyPolicyFailed(err error) {
+	state := s.safety.load()
+	state.status.Error = err.Error()
+	s.safety.state.Store(&state)
+}
+
+// SafetyPolicy returns the rules in force.
+func (s *Service) SafetyPolicy() SafetyPolicyStatus {
+	status := s.safety.load().status
+	if status.Rules == nil {
+		status.Rules = []SafetyRule{}
+	}
+	return status
+}
+
+// classify evaluates the safety policy for req, reading the live object when a rule needs it.
+func (s *Service) classify(ctx context.Context, req Request) Classification {
+	policy := s.safety.load().policy
+	subject := safetySubject{
+		cluster:   req.Cluster,
+		operation: req.Operation,
+		resource:  req.Resource,
+		params:    req.Parameters,
+	}
+	if policy.observes() {
+		subject.labels, subject.delta = s.observe(ctx, req)
+	}
+	return policy.classify(subject)
+}
+
+// observe reads the labels of the object req targets and, for scales, the change in replicas.
+func (s *Service) observe(ctx context.Context, req Request) (map[string]string, *int) {
+	if s.clients == nil || !supportedKinds[strings.ToLower(req.Resource.Kind)] || req.Resource.Name == "" {
+		return nil, nil
+	}
+	obj, err := getObject(ctx, s.clients(req.Config, req.Cluster), req.Resource)
+	if err != nil {
+		return nil, nil
+	}
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	if req.Operation != OperationScale {
+		return labels, nil
+	}
+	desired, err := strconv.Atoi(req.Parameters["replicas"])
+	if err != nil {
+		return labels, nil
+	}
+	var replicas *int32
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		replicas = o.Spec.Replicas
+	case *appsv1.StatefulSet:
+		replicas = o.Spec.Replicas
+	default:
+		return labels, nil
+	}
+	// The API server defaults unset replicas to one.
+	current := 1
+	if replicas != nil {
+		current = int(*replicas)
+	}
+	delta := desired - current
+	return labels, &delta
+}
@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes"
+)
+
+const testSafetyRules = `
+rules:
+  - name: critical-scale-down
+    match:
+      operations: [scale]
+      kinds: [deployments]
+      labels: {tier: critical}
+      maxReplicaDelta: -1
+    level: dangerous
+  - name: dev-is-safe
+    match:
+      namespaces: ["dev-*"]
+    level: safe
+`
+
+func TestSafetyRulesClassifyWithTrace(t *testing.T) {
+	critical := deployment(3)
+	critical.Labels = map[string]string{"tier": "critical"}
+	svc, client, _ := newTestService(critical)
+	allowVerbs(client, "get")
+	ctx := context.Background()
+
+	client.CoreV1().ConfigMaps("kubechat").Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kubechat", Name: "kubechat-safety-policy"},
+		Data:       map[string]string{SafetyPolicyKey: testSafetyRules},
+	}, metav1.CreateOptions{})
+	crd := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "kubechat.io/v1alpha1",
+		"kind":       "SafetyPolicy",
+		"metadata":   map[string]any{"namespace": "kubechat", "name": "prod"},
+		"spec": map[string]any{"rules": []any{map[string]any{
+			"name":  "prod-deletes",
+			"match": map[string]any{"operations": []any{"delete"}, "clusters": []any{"prod"}},
+			"level": "dangerous",
+		}}},
+	}}
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{SafetyPolicyGVR: "SafetyPolicyList"}, crd)
+	loader, err := NewSafetyPolicyLoader(svc, func(ctx context.Context) (kubernetes.Interface, dynamic.Interface, error) {
+		return client, dyn, nil
+	}, "kubechat/kubechat-safety-policy", nil)
+	if err != nil {
+		t.Fatalf("new loader: %v", err)
+	}
+	if err := loader.Reload(ctx); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if status := svc.SafetyPolicy(); len(status.Rules) != 3 || len(status.Sources) != 2 || status.Sources[1] != "safetypolicy/kubechat/prod" {
+		t.Fatalf("unexpected policy status %+v", status)
+	}
+
+	preview, err := svc.Preview(ctx, scaleRequest("alice", "1"))
+	if err != nil {
+		t.Fatalf("preview: %v", err)
+	}
+	if preview.SafetyLevel != SafetyDangerous || preview.Classification.Rule != "critical-scale-down" || len(preview.Classification.Trace) != 1 {
+		t.Fatalf("expected the critical scale down rule to decide, got %+v", preview.Classification)
+	}
+
+	preview, err = svc.Preview(ctx, scaleRequest("alice", "5"))
+	if err != nil {
+		t.Fatalf("preview: %v", err)
+	}
+	trace := preview.Classification.Trace
+	if preview.SafetyLevel != SafetySafe || len(trace) != 4 || trace[0].Detail != "replica delta 2 is above -1" || trace[3].Rule != builtinRule {
+		t.Fatalf("expected every rule to be traced before the built-in heuristics, got %+v", trace)
+	}
+
+	client.CoreV1().ConfigMaps("kubechat").Update(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kubechat", Name: "kubechat-safety-policy"},
+		Data:       map[string]string{SafetyPolicyKey: "rules:\n  - name: typo\n    level: catastrophic\n"},
+	}, metav1.UpdateOptions{})
+	loader.reload(ctx)
+	if status := svc.SafetyPolicy(); len(status.Rules) != 3 || status.Error == "" {
+		t.Fatalf("expected an invalid policy to keep the rules in force, got %+v", status)
+	}
+}
@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// SafetyPolicyKey is the ConfigMap key holding safety rules.
+const SafetyPolicyKey = "rules.yaml"
+
+// SafetyPolicyGVR is the SafetyPolicy custom resource; its spec holds rules in the ConfigMap format.
+var SafetyPolicyGVR = schema.GroupVersionResource{Group: "kubechat.io", Version: "v1alpha1", Resource: "safetypolicies"}
+
+// SafetyPolicyClients returns clients for the cluster that holds the safety policy.
+type SafetyPolicyClients func(ctx context.Context) (kubernetes.Interface, dynamic.Interface, error)
+
+// SafetyPolicyLoader keeps a service's safety rules in sync with a ConfigMap and the SafetyPolicy
+// resources of one cluster. ConfigMap rules come first, then each SafetyPolicy ordered by
+// namespace and name. A missing ConfigMap or an uninstalled CRD contributes no rules.
+type SafetyPolicyLoader struct {
+	service   *Service
+	clients   SafetyPolicyClients
+	namespace string
+	name      string
+	logger    *log.Logger
+	// applied fingerprints the last policy applied, so unchanged policies are not reapplied.
+	applied string
+}
+
+// NewSafetyPolicyLoader loads rules into service from the ConfigMap named "namespace/name".
+func NewSafetyPolicyLoader(service *Service, clients SafetyPolicyClients, configMap string, logger *log.Logger) (*SafetyPolicyLoader, error) {
+	namespace, name, ok := strings.Cut(configMap, "/")
+	if !ok || namespace == "" || name == "" {
+		return nil, fmt.Errorf("safety policy ConfigMap %q must look like namespace/name", configMap)
+	}
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &SafetyPolicyLoader{service: service, clients: clients, namespace: namespace, name: name, logger: logger}, nil
+}
+
+// Run reloads the policy now and then every interval until ctx is done.
+func (l *SafetyPolicyLoader) Run(ctx context.Context, interval time.Duration) {
+	l.reload(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.reload(ctx)
+		}
+	}
+}
+
+func (l *SafetyPolicyLoader) reload(ctx context.Context) {
+	if err := l.Reload(ctx); err != nil {
+		l.logger.Error("failed to reload safety policy; keeping the rules in force", "error", err)
+		l.service.safetyPolicyFailed(err)
+	}
+}
+
+// Reload reads the rules and applies them when they changed. An invalid policy is rejected as a
+// whole so a typo never silently drops a rule.
+func (l *SafetyPolicyLoader) Reload(ctx context.Context) error {
+	kube, dyn, err := l.clients(ctx)
+	if err != nil {
+		return err
+	}
+	var policy SafetyPolicy
+	var sources []string
+
+	configMap, err := kube.CoreV1().ConfigMaps(l.namespace).Get(ctx, l.name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+	case err != nil:
+		return fmt.Errorf("read ConfigMap %s/%s: %w", l.namespace, l.name, err)
+	default:
+		source := "configmap/" + l.namespace + "/" + l.name
+		parsed, err := ParseSafetyPolicy([]byte(configMap.Data[SafetyPolicyKey]))
+		if err != nil {
+			return fmt.Errorf("%s: %w", source, err)
+		}
+		policy.Rules = append(policy.Rules, parsed.Rules...)
+		sources = append(sources, source)
+	}
+
+	list, err := dyn.Resource(SafetyPolicyGVR).Namespace(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+	case err != nil:
+		return fmt.Errorf("list SafetyPolicies: %w", err)
+	default:
+		// Lists are returned ordered by namespace and name.
+		for _, item := range list.Items {
+			source := "safetypolicy/" + item.GetNamespace() + "/" + item.GetName()
+			spec, err := json.Marshal(item.Object["spec"])
+			if err != nil {
+				return fmt.Errorf("%s: %w", source, err)
+			}
+			parsed, err := ParseSafetyPolicy(spec)
+			if err != nil {
+				return fmt.Errorf("%s: %w", source, err)
+			}
+			policy.Rules = append(policy.Rules, parsed.Rules...)
+			sources = append(sources, source)
+		}
+	}
+
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	fingerprint, err := json.Marshal(struct {
+		Policy  SafetyPolicy
+		Sources []string
+	}{policy, sources})
+	if err != nil {
+		return err
+	}
+	if string(fingerprint) == l.applied && l.service.SafetyPolicy().Error == "" {
+		return nil
+	}
+	l.service.SetSafetyPolicy(policy, sources...)
+	l.applied = string(fingerprint)
+	return nil
+}
@@ -0,0 +1,271 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxScheduleLateness bounds how late a schedule may fire, such as after every replica was down
+// at its time. Later firings are skipped rather than run hours after the intended moment.
+const MaxScheduleLateness = 15 * time.Minute
+
+type ScheduleStatus string
+
+const (
+	ScheduleActive ScheduleStatus = "active"
+	// ScheduleCompleted marks a one-shot schedule that fired or was skipped.
+	ScheduleCompleted ScheduleStatus = "completed"
+	ScheduleCancelled ScheduleStatus = "cancelled"
+)
+
+// ScheduleRequest asks to run a command later, either once at RunAt or repeatedly on Cron.
+type ScheduleRequest struct {
+	Request
+	RunAt *time.Time `json:"runAt,omitempty"`
+	// Cron is a five-field expression (minute hour day-of-month month day-of-week) or a macro
+	// such as @daily, evaluated in Timezone.
+	Cron string `json:"cron,omitempty"`
+	// Timezone is an IANA zone name; it defaults to UTC.
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// CommandSchedule is a command submitted on its owner's behalf when it comes due. Each firing
+// goes through Submit, so the role bindings, approval policy, freezes and quotas in effect at
+// that moment decide whether the command runs or waits for approval.
+type CommandSchedule struct {
+	ID       string         `json:"id"`
+	UserID   string         `json:"userId"`
+	Request  Request        `json:"request"`
+	RunAt    *time.Time     `json:"runAt,omitempty"`
+	Cron     string         `json:"cron,omitempty"`
+	Timezone string         `json:"timezone,omitempty"`
+	Status   ScheduleStatus `json:"status"`
+	// NextRunAt is when the schedule fires next; it is cleared once the schedule is done.
+	NextRunAt *time.Time `json:"nextRunAt,omitempty"`
+	LastRunAt *time.Time `json:"lastRunAt,omitempty"`
+	// LastExecutionID is the execution most recently submitted by the schedule.
+	LastExecutionID string `json:"lastExecutionId,omitempty"`
+	// LastError explains why the last firing submitted nothing.
+	LastError   string     `json:"lastError,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	CancelledAt *time.Time `json:"cancelledAt,omitempty"`
+	CancelledBy string     `json:"cancelledBy,omitempty"`
+}
+
+type ErrScheduleNotFound struct {
+	ID string
+}
+
+func (e ErrScheduleNotFound) Error() string {
+	return fmt.Sprintf("command schedule %s not found", e.ID)
+}
+
+// nextRun returns when schedule fires after after, or nil when it will not fire again.
+func (schedule CommandSchedule) nextRun(after time.Time) (*time.Time, error) {
+	if schedule.Cron == "" {
+		if schedule.RunAt != nil && schedule.RunAt.After(after) {
+			at := schedule.RunAt.UTC()
+			return &at, nil
+		}
+		return nil, nil
+	}
+	cron, err := parseCron(schedule.Cron)
+	if err != nil {
+		return nil, err
+	}
+	location, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	next := cron.next(after.In(location))
+	if next.IsZero() {
+		return nil, nil
+	}
+	next = next.UTC()
+	return &next, nil
+}
+
+// Schedule records a command to submit later as req.UserID. The command is validated now so
+// mistakes surface before it is due; it is checked again, and approved if needed, when it fires.
+func (s *Service) Schedule(ctx context.Context, req ScheduleRequest) (CommandSchedule, error) {
+	now := s.now()
+	schedule := CommandSchedule{
+		ID:        uuid.NewString(),
+		UserID:    req.UserID,
+		Request:   req.Request,
+		Cron:      strings.TrimSpace(req.Cron),
+		Timezone:  strings.TrimSpace(req.Timezone),
+		Status:    ScheduleActive,
+		CreatedAt: now,
+	}
+	if req.RunAt != nil {
+		at := req.RunAt.UTC()
+		schedule.RunAt = &at
+	}
+	switch {
+	case (schedule.RunAt == nil) == (schedule.Cron == ""):
+		return CommandSchedule{}, ErrInvalidRequest{Reason: "exactly one of runAt and cron is required"}
+	case schedule.RunAt != nil && !schedule.RunAt.After(now):
+		return CommandSchedule{}, ErrInvalidRequest{Reason: "runAt must be in the future"}
+	case schedule.RunAt != nil && schedule.Timezone != "":
+		return CommandSchedule{}, ErrInvalidRequest{Reason: "timezone applies only to cron schedules"}
+	case schedule.Request.Operation == OperationGenerate:
+		return CommandSchedule{}, ErrInvalidRequest{Reason: "generate operations cannot be scheduled"}
+	case schedule.Request.DryRun:
+		return CommandSchedule{}, ErrInvalidRequest{Reason: "dry runs cannot be scheduled"}
+	}
+	if schedule.Cron != "" && schedule.Timezone == "" {
+		schedule.Timezone = "UTC"
+	}
+	next, err := schedule.nextRun(now)
+	if err != nil {
+		return CommandSchedule{}, ErrInvalidRequest{Reason: err.Error()}
+	}
+	if next == nil {
+		return CommandSchedule{}, ErrInvalidRequest{Reason: fmt.Sprintf("cron expression %q never fires", schedule.Cron)}
+	}
+	schedule.NextRunAt = next
+
+	prepared, _, _, err := s.prepare(ctx, req.Request)
+	if err != nil {
+		return CommandSchedule{}, err
+	}
+	if s.roles != nil {
+		if err := s.roles.CheckExecute(ctx, prepared.UserID, prepared.Groups, prepared.Resource.Namespace); err != nil {
+			return CommandSchedule{}, err
+		}
+	}
+	// Later firings address the cluster that was resolved now, even if the reference is renamed.
+	schedule.Request.Config, schedule.Request.Cluster = prepared.Config, prepared.Cluster
+	if err := s.repo.SaveSchedule(ctx, schedule); err != nil {
+		return CommandSchedule{}, err
+	}
+	s.log(ctx).Info("command scheduled", "id", schedule.ID, "user", schedule.UserID, "resource", schedule.Request.Resource.String(), "next_run_at", schedule.NextRunAt)
+	return schedule, nil
+}
+
+// GetSchedule returns one of user's schedules.
+func (s *Service) GetSchedule(ctx context.Context, id, user string) (CommandSchedule, error) {
+	schedule, err := s.repo.GetSchedule(ctx, id)
+	if err != nil {
+		return CommandSchedule{}, err
+	}
+	if schedule.UserID != user {
+		return CommandSchedule{}, ErrScheduleNotFound{ID: id}
+	}
+	return schedule, nil
+}
+
+// Schedules lists user's schedules, most recently created first.
+func (s *Service) Schedules(ctx context.Context, user string) ([]CommandSchedule, error) {
+	return s.repo.ListSchedules(ctx, user)
+}
+
+// CancelSchedule stops a schedule from firing again; only its owner may cancel it. Executions it
+// already submitted are left alone.
+func (s *Service) CancelSchedule(ctx context.Context, id, user string) (CommandSchedule, error) {
+	schedule, err := s.GetSchedule(ctx, id, user)
+	if err != nil {
+		return CommandSchedule{}, err
+	}
+	if schedule.Status != ScheduleActive {
+		return schedule, nil
+	}
+	now := s.now()
+	schedule.Status = ScheduleCancelled
+	schedule.CancelledAt = &now
+	schedule.CancelledBy = user
+	schedule.NextRunAt = nil
+	if err := s.repo.SaveSchedule(ctx, schedule); err != nil {
+		return CommandSchedule{}, err
+	}
+	s.log(ctx).Info("command schedule cancelled", "id", id, "user", user)
+	return schedule, nil
+}
+
+// FireSchedules submits the commands of every schedule that is due. Schedules are advanced before
+// their command is submitted, so a crash part way through never submits one twice.
+func (s *Service) FireSchedules(ctx context.Context) error {
+	now := s.now()
+	due, err := s.repo.ListDueSchedules(ctx, now)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, schedule := range due {
+		if err := s.fire(ctx, schedule, now); err != nil {
+			errs = append(errs, fmt.Errorf("schedule %s: %w", schedule.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Service) fire(ctx context.Context, schedule CommandSchedule, now time.Time) error {
+	dueAt := *schedule.NextRunAt
+	// The schedule may have been cancelled since it was listed.
+	if current, err := s.repo.GetSchedule(ctx, schedule.ID); err != nil || current.Status != ScheduleActive {
+		return err
+	}
+	next, err := schedule.nextRun(now)
+	if err != nil {
+		return err
+	}
+	previous := schedule.LastExecutionID
+	schedule.NextRunAt = next
+	if next == nil {
+		schedule.Status = ScheduleCompleted
+	}
+	schedule.LastRunAt = &now
+	schedule.LastError = ""
+
+	skip := ""
+	if late := now.Sub(dueAt); late > MaxScheduleLateness {
+		skip = fmt.Sprintf("skipped: due at %s, %s ago", dueAt.Format(time.RFC3339), late.Truncate(time.Second))
+	} else if previous != "" {
+		// A firing still waiting for approval is not stacked with another request for the same command.
+		if last, err := s.repo.GetByID(ctx, previous); err == nil && (last.Status == StatusAwaitingApproval || last.Status == StatusQueued) {
+			skip = fmt.Sprintf("skipped: execution %s is still %s", previous, strings.ReplaceAll(string(last.Status), "_", " "))
+		}
+	}
+	if skip != "" {
+		schedule.LastError = skip
+		s.log(ctx).Warn("scheduled command skipped", "id", schedule.ID, "user", schedule.UserID, "reason", skip)
+		return s.repo.SaveSchedule(ctx, schedule)
+	}
+	if err := s.repo.SaveSchedule(ctx, schedule); err != nil {
+		return err
+	}
+
+	execution, err := s.Submit(ctx, schedule.Request)
+	if err != nil {
+		schedule.LastError = err.Error()
+		s.log(ctx).Warn("scheduled command refused", "id", schedule.ID, "user", schedule.UserID, "resource", schedule.Request.Resource.String(), "error", err)
+	} else {
+		schedule.LastExecutionID = execution.ID
+		s.log(ctx).Info("scheduled command submitted", "id", schedule.ID, "user", schedule.UserID, "execution_id", execution.ID, "status", execution.Status)
+	}
+	// Failed runs are recorded on their execution; only refusals are reported on the schedule.
+	return s.repo.SaveSchedule(ctx, schedule)
+}
+
+// RunSchedules fires due schedules every interval until ctx is cancelled. It must run on one
+// replica at a time.
+func (s *Service) RunSchedules(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.FireSchedules(ctx); err != nil {
+				s.log(ctx).Error("failed to fire command schedules", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCronNextRun(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	cases := map[string]struct {
+		expr  string
+		after time.Time
+		want  time.Time
+	}{
+		"weekday evenings skip the weekend": {"30 20 * * 1-5", time.Date(2026, 3, 6, 21, 0, 0, 0, newYork), time.Date(2026, 3, 9, 20, 30, 0, 0, newYork)},
+		"steps within a range":              {"*/20 9-10 * * *", time.Date(2026, 3, 6, 10, 45, 0, 0, time.UTC), time.Date(2026, 3, 7, 9, 0, 0, 0, time.UTC)},
+		"either restricted day field fires": {"0 8 1 * 1", time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC), time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)},
+		"sunday as seven":                   {"0 0 * * 7", time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC)},
+		"macro":                             {"@monthly", time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC), time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for name, tc := range cases {
+		cron, err := parseCron(tc.expr)
+		if err != nil {
+			t.Fatalf("%s: parse: %v", name, err)
+		}
+		if got := cron.next(tc.after); !got.Equal(tc.want) {
+			t.Fatalf("%s: expected %s, got %s", name, tc.want, got)
+		}
+	}
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Fatalf("expected %q to be rejected", expr)
+		}
+	}
+	never, _ := parseCron("0 0 30 2 *")
+	if next := never.next(time.Now()); !next.IsZero() {
+		t.Fatalf("expected February 30th never to fire, got %s", next)
+	}
+}
+
+func TestScheduledCommandsFireThroughSubmit(t *testing.T) {
+	svc, client, _ := newTestService(deployment(2))
+	svc.SetApprovalPolicies(testPolicies())
+	ctx := context.Background()
+	now := time.Date(2026, 3, 6, 19, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	if _, err := svc.Schedule(ctx, ScheduleRequest{Request: scaleRequest("alice", "3")}); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected a schedule without runAt or cron to be rejected, got %v", err)
+	}
+	past := now.Add(-time.Minute)
+	if _, err := svc.Schedule(ctx, ScheduleRequest{Request: scaleRequest("alice", "3"), RunAt: &past}); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected a past runAt to be rejected, got %v", err)
+	}
+
+	at := now.Add(time.Hour)
+	once, err := svc.Schedule(ctx, ScheduleRequest{Request: scaleRequest("alice", "3"), RunAt: &at})
+	if err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	if err := svc.FireSchedules(ctx); err != nil {
+		t.Fatalf("fire: %v", err)
+	}
+	if scheduled, _ := svc.GetSchedule(ctx, once.ID, "alice"); scheduled.LastRunAt != nil {
+		t.Fatalf("expected nothing to fire before runAt, got %+v", scheduled)
+	}
+
+	now = at
+	if err := svc.FireSchedules(ctx); err != nil {
+		t.Fatalf("fire: %v", err)
+	}
+	fired, _ := svc.GetSchedule(ctx, once.ID, "alice")
+	if fired.Status != ScheduleCompleted || fired.NextRunAt != nil || fired.LastExecutionID == "" {
+		t.Fatalf("expected the one-shot schedule to complete, got %+v", fired)
+	}
+	if execution, _ := svc.Get(ctx, fired.LastExecutionID); execution.Status != StatusSucceeded || execution.UserID != "alice" {
+		t.Fatalf("expected the command to run as alice, got %+v", execution)
+	}
+	scaled, _ := client.AppsV1().Deployments("shop").Get(ctx, "api", metav1.GetOptions{})
+	if *scaled.Spec.Replicas != 3 {
+		t.Fatalf("expected the deployment scaled to 3, got %d", *scaled.Spec.Replicas)
+	}
+
+	hourly, err := svc.Schedule(ctx, ScheduleRequest{Request: scaleRequest("alice", "0"), Cron: "@hourly"})
+	if err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	now = *hourly.NextRunAt
+	if err := svc.FireSchedules(ctx); err != nil {
+		t.Fatalf("fire: %v", err)
+	}
+	fired, _ = svc.GetSchedule(ctx, hourly.ID, "alice")
+	pending, _ := svc.Get(ctx, fired.LastExecutionID)
+	if pending.Status != StatusAwaitingApproval || fired.Status != ScheduleActive || !fired.NextRunAt.Equal(now.Add(time.Hour)) {
+		t.Fatalf("expected the firing to await approval and the schedule to advance, got %+v / %+v", pending, fired)
+	}
+	now = now.Add(time.Hour)
+	if err := svc.FireSchedules(ctx); err != nil {
+		t.Fatalf("fire: %v", err)
+	}
+	fired, _ = svc.GetSchedule(ctx, hourly.ID, "alice")
+	if fired.LastExecutionID != pending.ID || !strings.Contains(fired.LastError, "awaiting approval") {
+		t.Fatalf("expected the firing to be skipped while the last one awaits approval, got %+v", fired)
+	}
+
+	if _, err := svc.CancelSchedule(ctx, hourly.ID, "bob"); !errors.As(err, &ErrScheduleNotFound{}) {
+		t.Fatalf("expected only the owner to cancel, got %v", err)
+	}
+	cancelled, err := svc.CancelSchedule(ctx, hourly.ID, "alice")
+	if err != nil || cancelled.Status != ScheduleCancelled || cancelled.NextRunAt != nil {
+		t.Fatalf("expected the schedule cancelled, got %+v (%v)", cancelled, err)
+	}
+	now = now.Add(time.Hour)
+	if err := svc.FireSchedules(ctx); err != nil {
+		t.Fatalf("fire: %v", err)
+	}
+	if after, _ := svc.GetSchedule(ctx, hourly.ID, "alice"); !after.LastRunAt.Equal(*fired.LastRunAt) {
+		t.Fatalf("expected a cancelled schedule not to fire, got %+v", after)
+	}
+}
+
+func TestLateScheduleIsSkipped(t *testing.T) {
+	svc, client, _ := newTestService(deployment(2))
+	ctx := context.Background()
+	now := time.Date(2026, 3, 6, 19, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	at := now.Add(time.Hour)
+	schedule, err := svc.Schedule(ctx, ScheduleRequest{Request: scaleRequest("alice", "3"), RunAt: &at})
+	if err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	now = at.Add(MaxScheduleLateness + time.Minute)
+	if err := svc.FireSchedules(ctx); err != nil {
+		t.Fatalf("fire: %v", err)
+	}
+	skipped, _ := svc.GetSchedule(ctx, schedule.ID, "alice")
+	if skipped.Status != ScheduleCompleted || skipped.LastExecutionID != "" || !strings.HasPrefix(skipped.LastError, "skipped") {
+		t.Fatalf("expected a late firing to be skipped, got %+v", skipped)
+	}
+	if unchanged, _ := client.AppsV1().Deployments("shop").Get(ctx, "api", metav1.GetOptions{}); *unchanged.Spec.Replicas != 2 {
+		t.Fatalf("expected the deployment untouched, got %d replicas", *unchanged.Spec.Replicas)
+	}
+}
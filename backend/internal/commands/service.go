@@ -0,0 +1,836 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/maypok86/otter/v2"
+	"github.com/pramodksahoo/kubechat/backend/internal/attachments"
+	"github.com/pramodksahoo/kubechat/backend/internal/logging"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+type ClientFunc func(config, cluster string) kubernetes.Interface
+
+type Request struct {
+	UserID string `json:"-"`
+	Config string `json:"config"`
+	// Cluster is the context to run against. Without Config it may name any cluster known to
+	// the ClusterResolver, such as a registered cluster.
+	Cluster    string            `json:"cluster"`
+	Operation  Operation         `json:"operation"`
+	Resource   Resource          `json:"resource"`
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// AcknowledgeDrift runs the command even though the resource is owned by infrastructure-as-code.
+	AcknowledgeDrift bool `json:"acknowledgeDrift,omitempty"`
+	// Groups are the requester's groups, used for role checks and passed on to the cluster when
+	// impersonation is enabled.
+	Groups []string `json:"-"`
+	// Workspace selects the namespace template applied to namespaces the request creates.
+	Workspace string `json:"-"`
+	// CorrelationID is the correlation ID of the chat turn whose plan the command carries out,
+	// returned with the plan. Executions submitted without one are correlated by their own ID.
+	CorrelationID string `json:"correlationId,omitempty"`
+	// OverrideReason runs the command during a change window; the requester must hold the
+	// approver role.
+	OverrideReason string `json:"overrideReason,omitempty"`
+	// DryRun asks for the changes the command would make, computed by a server-side dry run,
+	// instead of running it.
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+type ErrInvalidRequest struct {
+	Reason string
+}
+
+func (e ErrInvalidRequest) Error() string {
+	return e.Reason
+}
+
+var ErrRollbackUnavailable = errors.New("command execution has no rollback plan to run")
+
+// DriftWarning explains that a resource is owned by an infrastructure-as-code tool and how to change it there.
+type DriftWarning struct {
+	Tool       string   `json:"tool"`
+	Owner      string   `json:"owner,omitempty"`
+	Evidence   []string `json:"evidence"`
+	Suggestion string   `json:"suggestion,omitempty"`
+}
+
+// DriftGuard inspects the target of an execution for infrastructure-as-code ownership.
+type DriftGuard interface {
+	Check(ctx context.Context, execution Execution) (*DriftWarning, error)
+}
+
+// ClusterResolver maps a cluster reference to the config and context that address it.
+type ClusterResolver interface {
+	Resolve(ctx context.Context, ref string) (config, cluster string, err error)
+}
+
+// RoleChecker enforces KubeChat's own role bindings on the namespace a command targets.
+type RoleChecker interface {
+	CheckExecute(ctx context.Context, user string, groups []string, namespace string) error
+	CheckApprove(ctx context.Context, user string, groups []string, namespace string) error
+}
+
+type ErrManagedResource struct {
+	Warning DriftWarning
+}
+
+func (e ErrManagedResource) Error() string {
+	return fmt.Sprintf("resource is managed by %s; direct changes will drift from its source", e.Warning.Tool)
+}
+
+type Service struct {
+	repo     Repository
+	clients  ClientFunc
+	webhooks *WebhookRegistry
+	policies *ApprovalPolicies
+	guard    DriftGuard
+	clusters ClusterResolver
+	roles    RoleChecker
+	quota    *CommandQuota
+	logger   *log.Logger
+	now      func() time.Time
+
+	attachments         attachments.Store
+	attachmentThreshold int
+	archive             attachments.Store
+
+	// impersonate, when set, replaces clients so executions run as their requester.
+	impersonate ImpersonatingClientFunc
+	dynamic     DynamicClientFunc
+	mappers     MapperFunc
+	resources   *ResourcePolicy
+	manifests   ManifestSource
+	reviews     PlanReviews
+	safety      safetyRules
+	authorizer  Authorizer
+	templates   *NamespaceTemplates
+	metrics     Metrics
+	ledger      Ledger
+	observer    Observer
+	invalidator Invalidator
+	notifier    ApprovalNotifier
+	links       *ApprovalLinks
+	events      *eventCorrelation
+	usage       MetricsClientFunc
+
+	explanations *otter.Cache[string, Explanation]
+}
+
+func NewService(repo Repository, clients ClientFunc, logger *log.Logger) *Service {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Service{
+		repo:         repo,
+		clients:      clients,
+		logger:       logger,
+		now:          func() time.Time { return time.Now().UTC() },
+		explanations: newExplanationCache(),
+	}
+}
+
+// log returns the logger for work done in ctx, carrying the request and correlation IDs.
+func (s *Service) log(ctx context.Context) *log.Logger {
+	return logging.FromContext(ctx, s.logger)
+}
+
+// SetWebhookRegistry routes operations registered in webhooks to external executors.
+func (s *Service) SetWebhookRegistry(webhooks *WebhookRegistry) {
+	s.webhooks = webhooks
+}
+
+// SetDriftGuard makes Submit refuse unacknowledged changes to resources owned by infrastructure-as-code.
+func (s *Service) SetDriftGuard(guard DriftGuard) {
+	s.guard = guard
+}
+
+// SetClusterResolver lets requests name a cluster without the kubeconfig it was loaded from.
+func (s *Service) SetClusterResolver(clusters ClusterResolver) {
+	s.clusters = clusters
+}
+
+// SetRoleChecker makes Submit refuse commands in namespaces where the requester may not run them.
+func (s *Service) SetRoleChecker(roles RoleChecker) {
+	s.roles = roles
+}
+
+// SetApprovalPolicies replaces the policies used for new approval requests.
+func (s *Service) SetApprovalPolicies(policies *ApprovalPolicies) {
+	s.policies = policies
+}
+
+// Submit records a new execution and runs it immediately unless its safety level needs approval.
+func (s *Service) Submit(ctx context.Context, req Request) (Execution, error) {
+	if req.DryRun {
+		// Callers route dry runs to DryRun; one reaching Submit must not change anything.
+		return Execution{}, ErrInvalidRequest{Reason: "dry runs are not submitted"}
+	}
+	req, webhook, delegated, err := s.prepare(ctx, req)
+	if err != nil {
+		return Execution{}, err
+	}
+	execution, err := s.newExecution(ctx, req, webhook, delegated)
+	if err != nil {
+		return Execution{}, err
+	}
+	return s.submit(ctx, execution, req)
+}
+
+// newExecution classifies a prepared request and runs the checks every submission passes: drift
+// detection, the requester's access review and the authorization policy.
+func (s *Service) newExecution(ctx context.Context, req Request, webhook WebhookExecutor, delegated bool) (Execution, error) {
+	if s.roles != nil {
+		if err := s.roles.CheckExecute(ctx, req.UserID, req.Groups, req.Resource.Namespace); err != nil {
+			return Execution{}, err
+		}
+	}
+	classification := s.classify(ctx, req)
+	execution := Execution{
+		ID:          uuid.NewString(),
+		UserID:      req.UserID,
+		Config:      req.Config,
+		Cluster:     req.Cluster,
+		Operation:   req.Operation,
+		Resource:    req.Resource,
+		Parameters:  req.Parameters,
+		SafetyLevel: classification.Level,
+		CreatedAt:   s.now(),
+	}
+	execution.CorrelationID = req.CorrelationID
+	if execution.CorrelationID == "" {
+		execution.CorrelationID = execution.ID
+	}
+	if delegated {
+		execution.SafetyLevel = webhook.SafetyLevel
+		classification.Rule = ""
+	} else if s.guard != nil {
+		warning, err := s.guard.Check(ctx, execution)
+		if err != nil {
+			s.log(ctx).Warn("failed to check infrastructure-as-code ownership", "resource", execution.Resource.String(), "error", err)
+		}
+		if warning != nil {
+			if !req.AcknowledgeDrift {
+				return Execution{}, ErrManagedResource{Warning: *warning}
+			}
+			execution.DriftWarning = warning
+		}
+	}
+	if !delegated && s.impersonate != nil {
+		review, err := s.ReviewAccess(ctx, req)
+		if err != nil {
+			return Execution{}, err
+		}
+		if !review.Allowed {
+			return Execution{}, ErrAccessDenied{Review: review}
+		}
+		execution.AccessReview = &review
+	}
+	if err := s.authorize(ctx, req, &execution, classification.Rule); err != nil {
+		return Execution{}, err
+	}
+	return execution, nil
+}
+
+// submit records a checked execution, then queues it, requests its approval or runs it.
+func (s *Service) submit(ctx context.Context, execution Execution, req Request) (Execution, error) {
+	ctx = logging.NewContext(ctx, "correlation_id", execution.CorrelationID)
+	if err := s.admitQuota(ctx, execution); err != nil {
+		return Execution{}, err
+	}
+	// Executions needing approval are only queued once approved, so approvers can decide during
+	// the window.
+	queue, err := s.checkChangeWindow(ctx, &execution, req)
+	if err != nil {
+		return Execution{}, err
+	}
+	s.observeSubmission(ctx, execution)
+
+	if execution.SafetyLevel != SafetySafe {
+		execution.QueuedUntil = nil
+		execution.Status = StatusAwaitingApproval
+		if err := s.repo.Create(ctx, execution); err != nil {
+			return Execution{}, err
+		}
+		if err := s.recordFreezeOverride(ctx, execution); err != nil {
+			return Execution{}, err
+		}
+		if err := s.requestApproval(ctx, execution); err != nil {
+			return Execution{}, err
+		}
+		return execution, nil
+	}
+	if queue {
+		return s.queue(ctx, execution, true)
+	}
+
+	release, err := s.startQuota(execution)
+	if err != nil {
+		return Execution{}, err
+	}
+	defer release()
+	execution.Status = StatusRunning
+	if err := s.repo.Create(ctx, execution); err != nil {
+		return Execution{}, err
+	}
+	if err := s.recordFreezeOverride(ctx, execution); err != nil {
+		return Execution{}, err
+	}
+	return s.run(ctx, execution)
+}
+
+// prepare resolves the cluster a request names and validates it, reporting whether its
+// operation is delegated to a webhook executor.
+func (s *Service) prepare(ctx context.Context, req Request) (Request, WebhookExecutor, bool, error) {
+	req, err := s.inlineAttachment(ctx, req)
+	if err != nil {
+		return Request{}, WebhookExecutor{}, false, err
+	}
+	if req, err = s.resolveCluster(ctx, req); err != nil {
+		return Request{}, WebhookExecutor{}, false, err
+	}
+	webhook, delegated := s.webhooks.Lookup(req.Operation)
+	if delegated {
+		if req.Config == "" || req.Cluster == "" {
+			return Request{}, WebhookExecutor{}, false, ErrInvalidRequest{Reason: "config and cluster are required"}
+		}
+	} else if err := validate(req); err != nil {
+		return Request{}, WebhookExecutor{}, false, err
+	} else if manifestOperation(req.Operation) {
+		if req, err = s.resolveManifest(req); err != nil {
+			return Request{}, WebhookExecutor{}, false, err
+		}
+		if req, err = s.applyNamespaceTemplate(req); err != nil {
+			return Request{}, WebhookExecutor{}, false, err
+		}
+	} else if readOperation(req.Operation) {
+		if req, err = s.resolveRead(req); err != nil {
+			return Request{}, WebhookExecutor{}, false, err
+		}
+	} else if helmOperation(req.Operation) {
+		if s.mappers == nil || s.dynamic == nil {
+			return Request{}, WebhookExecutor{}, false, ErrInvalidRequest{Reason: fmt.Sprintf("%s is not enabled", req.Operation)}
+		}
+		req.Resource.Kind = HelmReleaseKind
+	}
+	if !delegated {
+		if err := s.checkResourcePolicy(req); err != nil {
+			return Request{}, WebhookExecutor{}, false, err
+		}
+	}
+	return req, webhook, delegated, nil
+}
+
+// resolveCluster maps the cluster a request names without a config to its config and context.
+func (s *Service) resolveCluster(ctx context.Context, req Request) (Request, error) {
+	if req.Config == "" && req.Cluster != "" && s.clusters != nil {
+		config, cluster, err := s.clusters.Resolve(ctx, req.Cluster)
+		if err != nil {
+			return Request{}, ErrInvalidRequest{Reason: err.Error()}
+		}
+		req.Config, req.Cluster = config, cluster
+	}
+	return req, nil
+}
+
+// Rollback asks, as user, to restore the resource snapshots captured before the execution changed
+// them. The rollback is an execution of its own requested by user, who must be allowed to run
+// commands in the namespaces it touches. It is held for approval, queued or refused like any
+// submission of the execution's safety level, and restores the resources with user's cluster
+// permissions. A plan is rolled back step by step.
+func (s *Service) Rollback(ctx context.Context, id, user string, groups []string) (Execution, error) {
+	target, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return Execution{}, err
+	}
+	targets, err := s.rollbackTargets(ctx, target)
+	if err != nil {
+		return Execution{}, err
+	}
+	req := Request{UserID: user, Groups: groups, Config: target.Config, Cluster: target.Cluster, Operation: OperationRollback, Resource: target.Resource}
+	rollback := Execution{
+		ID:          uuid.NewString(),
+		UserID:      user,
+		Config:      target.Config,
+		Cluster:     target.Cluster,
+		Operation:   OperationRollback,
+		Resource:    target.Resource,
+		Parameters:  map[string]string{"execution": target.ID},
+		SafetyLevel: target.SafetyLevel,
+		CreatedAt:   s.now(),
+	}
+	rollback.CorrelationID = rollback.ID
+	if s.impersonate != nil {
+		rollback.AccessReview = &AccessReview{Subject: Subject{User: user, Groups: groups}, Impersonated: true, Allowed: true}
+	}
+	for _, restored := range targets {
+		if s.roles != nil {
+			if err := s.roles.CheckExecute(ctx, user, groups, restored.Resource.Namespace); err != nil {
+				return Execution{}, err
+			}
+		}
+		if rollback.AccessReview == nil {
+			continue
+		}
+		review, err := s.ReviewAccess(ctx, Request{UserID: user, Groups: groups, Config: restored.Config, Cluster: restored.Cluster,
+			Operation: restored.Operation, Resource: restored.Resource, Parameters: restored.Parameters})
+		if err != nil {
+			return Execution{}, err
+		}
+		rollback.AccessReview.Allowed = rollback.AccessReview.Allowed && review.Allowed
+		rollback.AccessReview.Checks = append(rollback.AccessReview.Checks, review.Checks...)
+	}
+	if rollback.AccessReview != nil && !rollback.AccessReview.Allowed {
+		return Execution{}, ErrAccessDenied{Review: *rollback.AccessReview}
+	}
+	s.log(ctx).Info("command rollback submitted", "id", rollback.ID, "execution", target.ID, "user", user, "safety_level", rollback.SafetyLevel)
+	return s.submit(ctx, rollback, req)
+}
+
+// rollbackTargets returns the executions a rollback of target restores: target itself, or the
+// steps of a plan that completed and have something to restore.
+func (s *Service) rollbackTargets(ctx context.Context, target Execution) ([]Execution, error) {
+	if target.Operation != OperationPlan {
+		execution, _, err := s.rollbackPlan(ctx, target.ID)
+		if err != nil {
+			return nil, err
+		}
+		return []Execution{execution}, nil
+	}
+	if target.Status != StatusSucceeded && target.Status != StatusFailed {
+		return nil, ErrRollbackUnavailable
+	}
+	var targets []Execution
+	for _, step := range target.Steps {
+		if step.Status != StepSucceeded {
+			continue
+		}
+		execution, _, err := s.rollbackPlan(ctx, step.ExecutionID)
+		if errors.Is(err, ErrRollbackUnavailable) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, execution)
+	}
+	if len(targets) == 0 {
+		return nil, ErrRollbackUnavailable
+	}
+	return targets, nil
+}
+
+// runRollback carries out an approved or safe rollback execution with its requester's client.
+func (s *Service) runRollback(ctx context.Context, rollback Execution) (Execution, error) {
+	started := s.now()
+	rollback.StartedAt = &started
+	id := rollback.Parameters["execution"]
+	client, err := s.client(rollback)
+	var changes []ObjectChange
+	if err == nil {
+		changes, err = s.rollBack(ctx, client, id)
+	}
+	completed := s.now()
+	rollback.CompletedAt = &completed
+	rollback.Changes = changes
+	if err != nil {
+		rollback.Status = StatusFailed
+		rollback.Error = err.Error()
+		s.log(ctx).Warn("command rollback failed", "id", rollback.ID, "execution", id, "error", err)
+	} else {
+		rollback.Status = StatusSucceeded
+		s.storeResult(ctx, &rollback, "rolled back execution "+id)
+	}
+	if err := s.repo.Update(ctx, rollback); err != nil {
+		return Execution{}, err
+	}
+	s.observeExecution(rollback)
+	return rollback, nil
+}
+
+// rollBack restores what the execution with id changed, or what the completed steps of a plan
+// changed, last first, and returns the changes it made.
+func (s *Service) rollBack(ctx context.Context, client kubernetes.Interface, id string) ([]ObjectChange, error) {
+	target, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if target.Operation != OperationPlan {
+		return s.restoreExecution(ctx, client, id)
+	}
+	return s.rollbackSteps(ctx, client, target)
+}
+
+// restoreExecution restores the resource snapshots captured before the execution with id changed
+// them, and marks it rolled back.
+func (s *Service) restoreExecution(ctx context.Context, client kubernetes.Interface, id string) ([]ObjectChange, error) {
+	execution, plan, err := s.rollbackPlan(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var changes []ObjectChange
+	for _, step := range plan.Steps {
+		change := ObjectChange{Resource: step.Resource, ResourceVersionBefore: liveResourceVersion(ctx, client, step.Resource)}
+		if err := restore(ctx, client, step); err != nil {
+			s.invalidate(ctx, execution, append(changes, change))
+			return changes, fmt.Errorf("rollback %s: %w", step.Resource, err)
+		}
+		change.ResourceVersionAfter = liveResourceVersion(ctx, client, step.Resource)
+		changes = append(changes, change)
+	}
+	plan.Changes = append(plan.Changes, changes...)
+	s.invalidate(ctx, execution, plan.Changes)
+	s.log(ctx).Info("command execution rolled back", "id", execution.ID, "steps", describeRollback(plan.Steps))
+
+	executed := s.now()
+	plan.Executed = true
+	plan.ExecutedAt = &executed
+	if err := s.repo.SaveRollbackPlan(ctx, plan); err != nil {
+		return changes, err
+	}
+	execution.Status = StatusRolledBack
+	return changes, s.repo.Update(ctx, execution)
+}
+
+// PreviewRollback reports what Rollback would change without touching the cluster.
+func (s *Service) PreviewRollback(ctx context.Context, id string) (RollbackPreview, error) {
+	if plan, err := s.repo.GetByID(ctx, id); err == nil && plan.Operation == OperationPlan {
+		return s.previewStepRollbacks(ctx, plan)
+	}
+	execution, plan, err := s.rollbackPlan(ctx, id)
+	if err != nil {
+		return RollbackPreview{}, err
+	}
+	client, err := s.client(execution)
+	if err != nil {
+		return RollbackPreview{}, err
+	}
+	preview := RollbackPreview{ExecutionID: id, Applicable: true}
+	for _, step := range plan.Steps {
+		stepPreview, err := previewStep(ctx, client, step)
+		if err != nil {
+			return RollbackPreview{}, fmt.Errorf("preview rollback of %s: %w", step.Resource, err)
+		}
+		if stepPreview.Conflict != "" {
+			preview.Applicable = false
+		}
+		preview.Steps = append(preview.Steps, stepPreview)
+	}
+	return preview, nil
+}
+
+func (s *Service) rollbackPlan(ctx context.Context, id string) (Execution, RollbackPlan, error) {
+	execution, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return Execution{}, RollbackPlan{}, err
+	}
+	if execution.Status != StatusSucceeded {
+		return Execution{}, RollbackPlan{}, ErrRollbackUnavailable
+	}
+	plan, err := s.repo.GetRollbackPlan(ctx, id)
+	if err != nil {
+		var notFound ErrRollbackNotFound
+		if errors.As(err, &notFound) {
+			return Execution{}, RollbackPlan{}, ErrRollbackUnavailable
+		}
+		return Execution{}, RollbackPlan{}, err
+	}
+	if plan.Executed {
+		return Execution{}, RollbackPlan{}, ErrRollbackUnavailable
+	}
+	return execution, plan, nil
+}
+
+// Get returns the execution named by id, unless it was deleted.
+func (s *Service) Get(ctx context.Context, id string) (Execution, error) {
+	execution, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return Execution{}, err
+	}
+	if execution.DeletedAt != nil {
+		return Execution{}, ErrExecutionNotFound{ID: id}
+	}
+	return execution, nil
+}
+
+func (s *Service) ListByUser(ctx context.Context, userID string, limit, offset int) ([]Execution, error) {
+	return s.repo.ListByUser(ctx, userID, limit, offset)
+}
+
+// Delete hides the execution named by id as deleted by user, and records the deletion in its
+// audit trail. The execution and its attachments are kept until retention purges them.
+func (s *Service) Delete(ctx context.Context, id, user string) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	if err := s.repo.MarkDeleted(ctx, id, user, s.now()); err != nil {
+		return err
+	}
+	return s.record(ctx, id, user, ApprovalActionDeleted, "")
+}
+
+// Pseudonymize replaces user with pseudonym on the executions they requested and the approvals
+// they took part in, for erasure requests. Executions themselves are kept as the record of what
+// changed in clusters.
+func (s *Service) Pseudonymize(ctx context.Context, user, pseudonym string) (int, error) {
+	return s.repo.PseudonymizeUser(ctx, user, pseudonym)
+}
+
+// run executes execution within a span, so the Kubernetes API calls it makes are traced as its
+// children.
+func (s *Service) run(ctx context.Context, execution Execution) (Execution, error) {
+	ctx, span := startExecutionSpan(ctx, execution)
+	result, err := s.execute(ctx, execution)
+	endExecutionSpan(span, result, err)
+	return result, err
+}
+
+func (s *Service) execute(ctx context.Context, execution Execution) (Execution, error) {
+	if execution.Operation == OperationRollback {
+		return s.runRollback(ctx, execution)
+	}
+	if execution.Operation == OperationPlan {
+		return s.runPlan(ctx, execution)
+	}
+	started := s.now()
+	execution.StartedAt = &started
+
+	var (
+		rollback []RollbackStep
+		changes  []ObjectChange
+		read     []unstructured.Unstructured
+		result   string
+		err      error
+	)
+	if webhook, ok := s.webhooks.Lookup(execution.Operation); ok {
+		result, err = s.webhooks.Invoke(ctx, webhook, execution)
+	} else if manifestOperation(execution.Operation) {
+		rollback, changes, err = s.applyManifest(ctx, execution)
+		result = fmt.Sprintf("%s %s completed", execution.Operation, execution.Resource)
+		if err == nil {
+			var done []Resource
+			done, err = s.provision(ctx, execution)
+			for _, resource := range done {
+				result += fmt.Sprintf("\nprovisioned %s/%s", resource.Kind, resource.Name)
+			}
+		}
+	} else if helmOperation(execution.Operation) {
+		changes, result, err = s.runHelm(ctx, execution)
+	} else if execution.Operation == OperationTop {
+		result, err = s.top(ctx, execution)
+	} else if readOperation(execution.Operation) {
+		if result, read, err = s.read(ctx, execution); err == nil {
+			execution.Diff = s.diffRead(ctx, execution, read)
+		}
+	} else {
+		var client kubernetes.Interface
+		if client, err = s.client(execution); err == nil {
+			rollback, changes, err = apply(ctx, client, execution.Operation, execution.Resource, execution.Parameters)
+		}
+		result = fmt.Sprintf("%s %s completed", execution.Operation, execution.Resource)
+	}
+
+	completed := s.now()
+	execution.CompletedAt = &completed
+	execution.Changes = changes
+	execution.Events = s.correlateEvents(ctx, execution, read)
+	if err != nil {
+		execution.Status = StatusFailed
+		execution.Error = err.Error()
+		s.log(ctx).Warn("command execution failed", "id", execution.ID, "resource", execution.Resource.String(), "error", err)
+	} else {
+		execution.Status = StatusSucceeded
+		s.storeResult(ctx, &execution, result)
+	}
+	if err := s.repo.Update(ctx, execution); err != nil {
+		return Execution{}, err
+	}
+	s.observeExecution(execution)
+	if !readOperation(execution.Operation) {
+		s.invalidate(ctx, execution, changes)
+	}
+
+	if err == nil && len(rollback) > 0 {
+		plan := RollbackPlan{
+			ID:          uuid.NewString(),
+			ExecutionID: execution.ID,
+			Steps:       rollback,
+			CreatedAt:   completed,
+		}
+		if saveErr := s.repo.SaveRollbackPlan(ctx, plan); saveErr != nil {
+			s.log(ctx).Error("failed to store rollback plan", "id", execution.ID, "error", saveErr)
+		}
+	}
+	return execution, nil
+}
+
+// Classify assigns the safety level that decides whether an execution needs approval.
+func Classify(op Operation, resource Resource, params map[string]string) SafetyLevel {
+	switch op {
+	case OperationDelete:
+		if strings.EqualFold(resource.Kind, "pod") {
+			return SafetyWarning
+		}
+		return SafetyDangerous
+	case OperationPatch:
+		return SafetyWarning
+	case OperationScale:
+		if params["replicas"] == "0" {
+			return SafetyWarning
+		}
+	case OperationApply, OperationCreate, OperationEdit:
+		if sensitiveKinds[strings.ToLower(resource.Kind)] || params["force"] == "true" {
+			return SafetyDangerous
+		}
+		return SafetyWarning
+	case OperationHelmUpgrade:
+		if sensitiveManifest(params["manifest"]) {
+			return SafetyDangerous
+		}
+		return SafetyWarning
+	case OperationHelmRollback:
+		return SafetyWarning
+	}
+	return SafetySafe
+}
+
+func validate(req Request) error {
+	if req.Config == "" || req.Cluster == "" {
+		return ErrInvalidRequest{Reason: "config and cluster are required"}
+	}
+	if manifestOperation(req.Operation) {
+		if force := req.Parameters["force"]; force != "" && force != "true" && force != "false" {
+			return ErrInvalidRequest{Reason: "force must be true or false"}
+		}
+		_, err := decodeManifest(req.Parameters)
+		return err
+	}
+	if readOperation(req.Operation) {
+		return validateRead(req)
+	}
+	if helmOperation(req.Operation) {
+		return validateHelm(req)
+	}
+	if req.Resource.Name == "" || req.Resource.Namespace == "" {
+		return ErrInvalidRequest{Reason: "resource namespace and name are required"}
+	}
+	kind := strings.ToLower(req.Resource.Kind)
+	switch req.Operation {
+	case OperationScale:
+		if kind != "deployment" && kind != "statefulset" {
+			return ErrInvalidRequest{Reason: "scale supports deployments and statefulsets"}
+		}
+		if _, err := replicasParam(req.Parameters); err != nil {
+			return err
+		}
+	case OperationRestart:
+		if kind != "deployment" && kind != "statefulset" && kind != "daemonset" {
+			return ErrInvalidRequest{Reason: "restart supports deployments, statefulsets and daemonsets"}
+		}
+	case OperationDelete:
+		if !supportedKinds[kind] {
+			return ErrInvalidRequest{Reason: fmt.Sprintf("delete does not support kind %q", req.Resource.Kind)}
+		}
+	case OperationPatch:
+		if !supportedKinds[kind] {
+			return ErrInvalidRequest{Reason: fmt.Sprintf("patch does not support kind %q", req.Resource.Kind)}
+		}
+		if _, _, err := patchParams(req.Parameters); err != nil {
+			return err
+		}
+	default:
+		return ErrInvalidRequest{Reason: fmt.Sprintf("unsupported operation %q", req.Operation)}
+	}
+	return nil
+}
+
+func replicasParam(params map[string]string) (int32, error) {
+	value, err := strconv.ParseInt(params["replicas"], 10, 32)
+	if err != nil || value < 0 {
+		return 0, ErrInvalidRequest{Reason: "replicas must be a non-negative integer"}
+	}
+	return int32(value), nil
+}
+
+var patchTypes = map[string]types.PatchType{
+	"":          types.StrategicMergePatchType,
+	"strategic": types.StrategicMergePatchType,
+	"merge":     types.MergePatchType,
+	"json":      types.JSONPatchType,
+}
+
+func patchParams(params map[string]string) (types.PatchType, []byte, error) {
+	patchType, ok := patchTypes[params["patchType"]]
+	if !ok {
+		return "", nil, ErrInvalidRequest{Reason: "patchType must be strategic, merge or json"}
+	}
+	patch := []byte(params["patch"])
+	if !json.Valid(patch) {
+		return "", nil, ErrInvalidRequest{Reason: "patch must be a JSON document"}
+	}
+	return patchType, patch, nil
+}
+
+// apply snapshots the target, performs op against the cluster and returns the steps that undo it.
+func apply(ctx context.Context, client kubernetes.Interface, op Operation, resource Resource, params map[string]string) ([]RollbackStep, []ObjectChange, error) {
+	before, raw, err := snapshot(ctx, client, resource)
+	if err != nil {
+		return nil, nil, err
+	}
+	change := ObjectChange{Resource: resource, ResourceVersionBefore: before.GetResourceVersion()}
+
+	switch op {
+	case OperationScale, OperationRestart, OperationPatch:
+		var (
+			patchType types.PatchType
+			patch     []byte
+		)
+		if patchType, patch, err = operationPatch(op, params); err == nil {
+			_, err = patchObject(ctx, client, resource, patchType, patch, false)
+		}
+	case OperationDelete:
+		if err := deleteObject(ctx, client, resource, false); err != nil {
+			return nil, nil, err
+		}
+		if hasController(before) {
+			// The owning controller replaces the object, so there is nothing to recreate.
+			return nil, []ObjectChange{change}, nil
+		}
+		return []RollbackStep{{Action: RollbackRecreate, Resource: resource, Snapshot: raw}}, []ObjectChange{change}, nil
+	default:
+		return nil, nil, ErrInvalidRequest{Reason: fmt.Sprintf("unsupported operation %q", op)}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	change.ResourceVersionAfter = liveResourceVersion(ctx, client, resource)
+	return []RollbackStep{{Action: RollbackRestore, Resource: resource, Snapshot: raw}}, []ObjectChange{change}, nil
+}
+
+// operationPatch returns the patch that carries out a scale, restart or patch operation.
+func operationPatch(op Operation, params map[string]string) (types.PatchType, []byte, error) {
+	switch op {
+	case OperationScale:
+		replicas, err := replicasParam(params)
+		if err != nil {
+			return "", nil, err
+		}
+		return types.MergePatchType, []byte(fmt.Sprintf(`{"spec":{"replicas":%d}}`, replicas)), nil
+	case OperationRestart:
+		return types.StrategicMergePatchType, []byte(fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{"kubectl.kubernetes.io/restartedAt":%q}}}}}`, time.Now().UTC().Format(time.RFC3339))), nil
+	case OperationPatch:
+		return patchParams(params)
+	}
+	return "", nil, ErrInvalidRequest{Reason: fmt.Sprintf("operation %q is not a patch", op)}
+}
@@ -0,0 +1,353 @@
+package commands
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pramodksahoo/kubechat/backend/internal/attachments"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestService(objects ...runtime.Object) (*Service, *fake.Clientset, *MemoryRepository) {
+	client := fake.NewSimpleClientset(objects...)
+	repo := NewMemoryRepository()
+	svc := NewService(repo, func(config, cluster string) kubernetes.Interface { return client }, log.NewWithOptions(io.Discard, log.Options{}))
+	return svc, client, repo
+}
+
+func deployment(replicas int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "shop"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+	}
+}
+
+func scaleRequest(user, replicas string) Request {
+	return Request{
+		UserID:     user,
+		Config:     "kubeconfig",
+		Cluster:    "prod",
+		Operation:  OperationScale,
+		Resource:   Resource{Kind: "Deployment", Namespace: "shop", Name: "api"},
+		Parameters: map[string]string{"replicas": replicas},
+	}
+}
+
+func TestSubmitScaleRunsAndRollsBack(t *testing.T) {
+	svc, client, repo := newTestService(deployment(2))
+	ctx := context.Background()
+
+	execution, err := svc.Submit(ctx, scaleRequest("alice", "5"))
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if execution.Status != StatusSucceeded || execution.SafetyLevel != SafetySafe {
+		t.Fatalf("unexpected execution %+v", execution)
+	}
+	got, _ := client.AppsV1().Deployments("shop").Get(ctx, "api", metav1.GetOptions{})
+	if *got.Spec.Replicas != 5 {
+		t.Fatalf("expected 5 replicas, got %d", *got.Spec.Replicas)
+	}
+
+	plan, err := repo.GetRollbackPlan(ctx, execution.ID)
+	if err != nil || len(plan.Steps) != 1 || plan.Steps[0].Action != RollbackRestore {
+		t.Fatalf("expected a restore step, got %+v (%v)", plan, err)
+	}
+
+	preview, err := svc.PreviewRollback(ctx, execution.ID)
+	if err != nil {
+		t.Fatalf("preview: %v", err)
+	}
+	if !preview.Applicable || len(preview.Steps[0].Changes) != 1 || preview.Steps[0].Changes[0].Path != "spec.replicas" {
+		t.Fatalf("unexpected preview %+v", preview)
+	}
+
+	rollback, err := svc.Rollback(ctx, execution.ID, "alice", nil)
+	if err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	if rollback.Operation != OperationRollback || rollback.UserID != "alice" || rollback.Parameters["execution"] != execution.ID {
+		t.Fatalf("expected the rollback recorded as an execution of its own, got %+v", rollback)
+	}
+	if rolledBack, _ := svc.Get(ctx, execution.ID); rolledBack.Status != StatusRolledBack {
+		t.Fatalf("expected rolled back status, got %s", rolledBack.Status)
+	}
+	got, _ = client.AppsV1().Deployments("shop").Get(ctx, "api", metav1.GetOptions{})
+	if *got.Spec.Replicas != 2 {
+		t.Fatalf("expected replicas restored to 2, got %d", *got.Spec.Replicas)
+	}
+	if _, err := svc.Rollback(ctx, execution.ID, "alice", nil); !errors.Is(err, ErrRollbackUnavailable) {
+		t.Fatalf("expected second rollback to be refused, got %v", err)
+	}
+}
+
+func TestScaleToZeroRequiresApprovalFromAnotherUser(t *testing.T) {
+	svc, client, _ := newTestService(deployment(3))
+	ctx := context.Background()
+
+	execution, err := svc.Submit(ctx, scaleRequest("alice", "0"))
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if execution.Status != StatusAwaitingApproval {
+		t.Fatalf("expected approval gate, got %s", execution.Status)
+	}
+	pending, _ := svc.PendingApprovals(ctx)
+	if len(pending) != 1 || pending[0].ExecutionID != execution.ID {
+		t.Fatalf("expected one pending approval, got %+v", pending)
+	}
+
+	if _, err := svc.Approve(ctx, execution.ID, "alice", nil, ""); !errors.Is(err, ErrSelfApproval) {
+		t.Fatalf("expected self approval to fail, got %v", err)
+	}
+	approved, err := svc.Approve(ctx, execution.ID, "bob", nil, "maintenance window")
+	if err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	if approved.Status != StatusSucceeded {
+		t.Fatalf("expected success after approval, got %s (%s)", approved.Status, approved.Error)
+	}
+	got, _ := client.AppsV1().Deployments("shop").Get(ctx, "api", metav1.GetOptions{})
+	if *got.Spec.Replicas != 0 {
+		t.Fatalf("expected 0 replicas, got %d", *got.Spec.Replicas)
+	}
+	if _, err := svc.Reject(ctx, execution.ID, "bob", nil, ""); !errors.Is(err, ErrNotAwaitingApproval) {
+		t.Fatalf("expected reject after approval to fail, got %v", err)
+	}
+}
+
+func TestFailedExecutionIsRecorded(t *testing.T) {
+	svc, _, _ := newTestService()
+	ctx := context.Background()
+
+	execution, err := svc.Submit(ctx, scaleRequest("alice", "4"))
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if execution.Status != StatusFailed || execution.Error == "" {
+		t.Fatalf("expected failure to be recorded, got %+v", execution)
+	}
+	stored, err := svc.Get(ctx, execution.ID)
+	if err != nil || stored.Status != StatusFailed {
+		t.Fatalf("expected stored failure, got %+v (%v)", stored, err)
+	}
+}
+
+func TestSubmitValidatesRequests(t *testing.T) {
+	svc, _, _ := newTestService()
+	req := scaleRequest("alice", "-1")
+	_, err := svc.Submit(context.Background(), req)
+	var invalid ErrInvalidRequest
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected invalid request, got %v", err)
+	}
+}
+
+func TestMemoryRepositoryListByUser(t *testing.T) {
+	repo := NewMemoryRepository()
+	ctx := context.Background()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, user := range []string{"alice", "bob", "alice", "alice"} {
+		repo.Create(ctx, Execution{ID: string(rune('a' + i)), UserID: user, CreatedAt: base.Add(time.Duration(i) * time.Minute)})
+	}
+
+	executions, err := repo.ListByUser(ctx, "alice", 2, 0)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(executions) != 2 || executions[0].ID != "d" || executions[1].ID != "c" {
+		t.Fatalf("expected newest alice executions first, got %+v", executions)
+	}
+	if err := repo.MarkDeleted(ctx, "d", "alice", base.Add(time.Hour)); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if executions, _ := repo.ListByUser(ctx, "alice", 2, 0); len(executions) != 2 || executions[0].ID != "c" || executions[1].ID != "a" {
+		t.Fatalf("expected the deleted execution to be left out, got %+v", executions)
+	}
+	if deleted, err := repo.GetByID(ctx, "d"); err != nil || deleted.DeletedBy != "alice" || deleted.DeletedAt == nil {
+		t.Fatalf("expected the deleted execution to be kept, got %+v (%v)", deleted, err)
+	}
+}
+
+func TestDeleteHidesTheExecutionAndRecordsWhoDeletedIt(t *testing.T) {
+	svc, _, repo := newTestService(deployment(2))
+	ctx := context.Background()
+
+	execution, err := svc.Submit(ctx, scaleRequest("alice", "3"))
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if err := svc.Delete(ctx, execution.ID, "alice"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	var notFound ErrExecutionNotFound
+	if _, err := svc.Get(ctx, execution.ID); !errors.As(err, &notFound) {
+		t.Fatalf("expected not found after delete, got %v", err)
+	}
+	if err := svc.Delete(ctx, execution.ID, "alice"); !errors.As(err, &notFound) {
+		t.Fatalf("expected a second delete to find nothing, got %v", err)
+	}
+	events, err := repo.ListApprovalEvents(ctx, execution.ID)
+	if err != nil || len(events) != 1 || events[0].Action != ApprovalActionDeleted || events[0].Actor != "alice" {
+		t.Fatalf("expected the deletion by alice in the audit trail, got %+v (%v)", events, err)
+	}
+}
+
+type stubGuard struct {
+	warning *DriftWarning
+}
+
+func (g stubGuard) Check(ctx context.Context, execution Execution) (*DriftWarning, error) {
+	return g.warning, nil
+}
+
+func TestManagedResourcesNeedDriftAcknowledgement(t *testing.T) {
+	svc, _, _ := newTestService(deployment(2))
+	svc.SetDriftGuard(stubGuard{warning: &DriftWarning{Tool: "terraform", Evidence: []string{"field manager Terraform"}}})
+	ctx := context.Background()
+
+	_, err := svc.Submit(ctx, scaleRequest("alice", "3"))
+	var managed ErrManagedResource
+	if !errors.As(err, &managed) || managed.Warning.Tool != "terraform" {
+		t.Fatalf("expected managed resource error, got %v", err)
+	}
+
+	req := scaleRequest("alice", "3")
+	req.AcknowledgeDrift = true
+	execution, err := svc.Submit(ctx, req)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if execution.Status != StatusSucceeded || execution.DriftWarning == nil {
+		t.Fatalf("expected acknowledged execution with drift warning, got %+v", execution)
+	}
+}
+
+type staticResolver map[string][2]string
+
+func (r staticResolver) Resolve(ctx context.Context, ref string) (string, string, error) {
+	target, ok := r[ref]
+	if !ok {
+		return "", "", errors.New("cluster " + ref + " not found")
+	}
+	return target[0], target[1], nil
+}
+
+func TestSubmitResolvesClusterWithoutConfig(t *testing.T) {
+	svc, _, _ := newTestService(deployment(2))
+	svc.SetClusterResolver(staticResolver{"edge": {"3f2a", "edge"}})
+	ctx := context.Background()
+
+	req := scaleRequest("alice", "3")
+	req.Config, req.Cluster = "", "edge"
+	execution, err := svc.Submit(ctx, req)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if execution.Config != "3f2a" || execution.Cluster != "edge" {
+		t.Fatalf("expected the registered cluster to be targeted, got %s/%s", execution.Config, execution.Cluster)
+	}
+
+	req.Cluster = "unknown"
+	if _, err := svc.Submit(ctx, req); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected unknown cluster to be rejected, got %v", err)
+	}
+}
+
+func TestPurgeKeepsPendingAndHeldExecutions(t *testing.T) {
+	svc, _, repo := newTestService()
+	root := t.TempDir()
+	store, err := attachments.NewFileStore(root)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	svc.SetArchiveStore(store)
+	ctx := context.Background()
+	old := time.Now().Add(-48 * time.Hour)
+	for id, execution := range map[string]Execution{
+		"done":        {UserID: "alice", Status: StatusSucceeded},
+		"pending":     {UserID: "alice", Status: StatusAwaitingApproval},
+		"held":        {UserID: "mallory", Status: StatusFailed},
+		"held-record": {UserID: "alice", Status: StatusSucceeded},
+	} {
+		execution.ID, execution.CreatedAt = id, old
+		repo.Create(ctx, execution)
+	}
+	repo.Create(ctx, Execution{ID: "recent", UserID: "alice", Status: StatusSucceeded, CreatedAt: time.Now()})
+	repo.SaveApproval(ctx, Approval{ID: "a1", ExecutionID: "done", RequestedBy: "alice", Status: ApprovalApproved})
+
+	cutoff := time.Now().Add(-24 * time.Hour)
+	if count, err := svc.Purgeable(ctx, cutoff, []string{"mallory"}, []string{"held-record"}); err != nil || count != 1 {
+		t.Fatalf("expected one purgeable execution, got %d (%v)", count, err)
+	}
+	removed, err := svc.Purge(ctx, cutoff, []string{"mallory"}, []string{"held-record"})
+	if err != nil || removed != 1 {
+		t.Fatalf("expected one execution to be purged, got %d (%v)", removed, err)
+	}
+	if _, err := repo.GetByID(ctx, "done"); !errors.As(err, &ErrExecutionNotFound{}) {
+		t.Fatalf("expected finished execution to be purged, got %v", err)
+	}
+	for _, id := range []string{"pending", "held", "held-record", "recent"} {
+		if _, err := repo.GetByID(ctx, id); err != nil {
+			t.Fatalf("expected %s to be kept: %v", id, err)
+		}
+	}
+
+	archives, _ := filepath.Glob(filepath.Join(root, "retention-archive", "execution_records", "*", "*", "*.jsonl.gz"))
+	if len(archives) != 1 {
+		t.Fatalf("expected the purged execution to be archived first, got %v", archives)
+	}
+	file, err := os.Open(archives[0])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer file.Close()
+	decompressed, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var archived archivedExecution
+	if err := json.NewDecoder(decompressed).Decode(&archived); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if archived.Execution.ID != "done" || archived.Approval == nil || archived.Approval.ID != "a1" {
+		t.Fatalf("expected the execution to be archived with its approval, got %+v", archived)
+	}
+}
+
+type recordedInvalidations []string
+
+func (r *recordedInvalidations) Invalidate(ctx context.Context, config, cluster, namespace string) {
+	*r = append(*r, config+"/"+cluster+"/"+namespace)
+}
+
+func TestWritesAndRollbacksInvalidateTheirNamespace(t *testing.T) {
+	svc, _, _ := newTestService(deployment(2))
+	invalidated := &recordedInvalidations{}
+	svc.SetInvalidator(invalidated)
+	ctx := context.Background()
+
+	execution, err := svc.Submit(ctx, scaleRequest("alice", "5"))
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if _, err := svc.Rollback(ctx, execution.ID, "alice", nil); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	if strings.Join(*invalidated, " ") != "kubeconfig/prod/shop kubeconfig/prod/shop" {
+		t.Fatalf("expected the scale and its rollback to invalidate the shop namespace, got %v", *invalidated)
+	}
+}
@@ -0,0 +1,246 @@
+package commands
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// MetricsClientFunc returns a client for the metrics API metrics-server serves, acting as subject
+// when it is set.
+type MetricsClientFunc func(config, cluster string, subject *Subject) (metricsclient.Interface, error)
+
+// SetMetricsClients enables top, which reads pod and node usage from metrics-server the way
+// kubectl top does, without Prometheus.
+func (s *Service) SetMetricsClients(clients MetricsClientFunc) {
+	s.usage = clients
+}
+
+// Usage is the CPU and memory a pod, summed over its containers, or a node used when
+// metrics-server last sampled it.
+type Usage struct {
+	Namespace     string    `json:"namespace,omitempty"`
+	Name          string    `json:"name"`
+	CPUMillicores int64     `json:"cpuMillicores"`
+	MemoryBytes   int64     `json:"memoryBytes"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// PodMetrics returns the usage of the pods in namespace, or in every namespace when it is empty,
+// that match selector.
+func (s *Service) PodMetrics(ctx context.Context, config, cluster, namespace, selector string) ([]Usage, error) {
+	client, err := s.metricsClient(config, cluster, nil)
+	if err != nil {
+		return nil, err
+	}
+	return podUsage(ctx, client, namespace, selector)
+}
+
+// NodeMetrics returns the usage of the nodes that match selector.
+func (s *Service) NodeMetrics(ctx context.Context, config, cluster, selector string) ([]Usage, error) {
+	client, err := s.metricsClient(config, cluster, nil)
+	if err != nil {
+		return nil, err
+	}
+	return nodeUsage(ctx, client, selector)
+}
+
+func (s *Service) metricsClient(config, cluster string, subject *Subject) (metricsclient.Interface, error) {
+	if s.usage == nil {
+		return nil, ErrInvalidRequest{Reason: fmt.Sprintf("%s is not enabled", OperationTop)}
+	}
+	return s.usage(config, cluster, subject)
+}
+
+func podUsage(ctx context.Context, client metricsclient.Interface, namespace, selector string) ([]Usage, error) {
+	list, err := client.MetricsV1beta1().PodMetricses(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("read pod metrics from metrics-server: %w", err)
+	}
+	usage := make([]Usage, 0, len(list.Items))
+	for _, pod := range list.Items {
+		entry := Usage{Namespace: pod.Namespace, Name: pod.Name, Timestamp: pod.Timestamp.Time}
+		for _, container := range pod.Containers {
+			entry.CPUMillicores += container.Usage.Cpu().MilliValue()
+			entry.MemoryBytes += container.Usage.Memory().Value()
+		}
+		usage = append(usage, entry)
+	}
+	return usage, nil
+}
+
+func nodeUsage(ctx context.Context, client metricsclient.Interface, selector string) ([]Usage, error) {
+	list, err := client.MetricsV1beta1().NodeMetricses().List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("read node metrics from metrics-server: %w", err)
+	}
+	usage := make([]Usage, 0, len(list.Items))
+	for _, node := range list.Items {
+		usage = append(usage, Usage{
+			Name:          node.Name,
+			CPUMillicores: node.Usage.Cpu().MilliValue(),
+			MemoryBytes:   node.Usage.Memory().Value(),
+			Timestamp:     node.Timestamp.Time,
+		})
+	}
+	return usage, nil
+}
+
+// topKinds maps the kinds top accepts to the metrics API resource it reads.
+var topKinds = map[string]string{
+	"pod": "pods", "pods": "pods", "pods.metrics.k8s.io": "pods",
+	"node": "nodes", "nodes": "nodes", "nodes.metrics.k8s.io": "nodes",
+}
+
+// topResource is the metrics API resource, pods or nodes, top reads for kind.
+func topResource(kind string) string {
+	return topKinds[strings.ToLower(kind)]
+}
+
+// topQuery is what a top execution's parameters ask for: usage sorted by sort-by, cpu unless it
+// is memory, limited to the first limit entries and to those using at least min-cpu and
+// min-memory, such as 100m and 256Mi.
+type topQuery struct {
+	sortBy    string
+	limit     int
+	minCPU    int64
+	minMemory int64
+}
+
+func validateTop(req Request) (topQuery, error) {
+	resourceName := topResource(req.Resource.Kind)
+	if resourceName == "" {
+		return topQuery{}, ErrInvalidRequest{Reason: "top supports pods and nodes"}
+	}
+	if resourceName == "nodes" && req.Resource.Namespace != "" {
+		return topQuery{}, ErrInvalidRequest{Reason: "nodes are not namespaced"}
+	}
+	if _, err := labels.Parse(req.Parameters["selector"]); err != nil {
+		return topQuery{}, ErrInvalidRequest{Reason: fmt.Sprintf("selector is not a valid label selector: %v", err)}
+	}
+	query := topQuery{sortBy: "cpu"}
+	switch sortBy := req.Parameters["sort-by"]; sortBy {
+	case "", "cpu":
+	case "memory":
+		query.sortBy = sortBy
+	default:
+		return topQuery{}, ErrInvalidRequest{Reason: "sort-by must be cpu or memory"}
+	}
+	if raw := req.Parameters["limit"]; raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return topQuery{}, ErrInvalidRequest{Reason: "limit must be a positive number"}
+		}
+		query.limit = limit
+	}
+	for param, value := range map[string]*int64{"min-cpu": &query.minCPU, "min-memory": &query.minMemory} {
+		raw := req.Parameters[param]
+		if raw == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(raw)
+		if err != nil || quantity.Sign() < 0 {
+			return topQuery{}, ErrInvalidRequest{Reason: fmt.Sprintf("%s must be a quantity such as 100m or 256Mi", param)}
+		}
+		if param == "min-cpu" {
+			*value = quantity.MilliValue()
+		} else {
+			*value = quantity.Value()
+		}
+	}
+	return query, nil
+}
+
+// resolveTop rewrites the request's kind to the metrics API resource top reads.
+func (s *Service) resolveTop(req Request) (Request, error) {
+	if s.usage == nil {
+		return Request{}, ErrInvalidRequest{Reason: fmt.Sprintf("%s is not enabled", req.Operation)}
+	}
+	req.Resource.Kind = topResource(req.Resource.Kind) + ".metrics.k8s.io"
+	return req, nil
+}
+
+// filter applies the query's thresholds, order and limit to usage.
+func (q topQuery) filter(usage []Usage) []Usage {
+	usage = slices.DeleteFunc(usage, func(u Usage) bool {
+		return u.CPUMillicores < q.minCPU || u.MemoryBytes < q.minMemory
+	})
+	slices.SortStableFunc(usage, func(a, b Usage) int {
+		if q.sortBy == "memory" && a.MemoryBytes != b.MemoryBytes {
+			return cmp.Compare(b.MemoryBytes, a.MemoryBytes)
+		}
+		if a.CPUMillicores != b.CPUMillicores {
+			return cmp.Compare(b.CPUMillicores, a.CPUMillicores)
+		}
+		return strings.Compare(a.Namespace+"/"+a.Name, b.Namespace+"/"+b.Name)
+	})
+	if q.limit > 0 && len(usage) > q.limit {
+		usage = usage[:q.limit]
+	}
+	return usage
+}
+
+// top runs a top execution and renders its result the way kubectl top does.
+func (s *Service) top(ctx context.Context, execution Execution) (string, error) {
+	query, err := validateTop(Request{Resource: execution.Resource, Parameters: execution.Parameters})
+	if err != nil {
+		return "", err
+	}
+	var subject *Subject
+	if s.impersonate != nil {
+		subject = &Subject{User: execution.UserID}
+		if execution.AccessReview != nil {
+			subject = &execution.AccessReview.Subject
+		}
+	}
+	client, err := s.metricsClient(execution.Config, execution.Cluster, subject)
+	if err != nil {
+		return "", err
+	}
+	nodes := topResource(execution.Resource.Kind) == "nodes"
+	var usage []Usage
+	if nodes {
+		usage, err = nodeUsage(ctx, client, execution.Parameters["selector"])
+	} else {
+		usage, err = podUsage(ctx, client, execution.Resource.Namespace, execution.Parameters["selector"])
+	}
+	if err != nil {
+		return "", err
+	}
+	if name := execution.Resource.Name; name != "" {
+		usage = slices.DeleteFunc(usage, func(u Usage) bool { return u.Name != name })
+	}
+	return usageTable(query.filter(usage), !nodes && execution.Resource.Namespace == ""), nil
+}
+
+// usageTable renders usage as kubectl top does, with CPU in millicores and memory in Mi.
+func usageTable(usage []Usage, withNamespace bool) string {
+	if len(usage) == 0 {
+		return "No resources found.\n"
+	}
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 3, ' ', 0)
+	if withNamespace {
+		fmt.Fprint(w, "NAMESPACE\t")
+	}
+	fmt.Fprintln(w, "NAME\tCPU(cores)\tMEMORY(bytes)")
+	for _, u := range usage {
+		if withNamespace {
+			fmt.Fprintf(w, "%s\t", u.Namespace)
+		}
+		fmt.Fprintf(w, "%s\t%dm\t%dMi\n", u.Name, u.CPUMillicores, u.MemoryBytes/(1<<20))
+	}
+	w.Flush()
+	return buf.String()
+}
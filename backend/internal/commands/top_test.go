@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
+)
+
+func podMetrics(namespace, name string, usage ...string) *metricsv1beta1.PodMetrics {
+	metrics := &metricsv1beta1.PodMetrics{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	for i := 0; i < len(usage); i += 2 {
+		metrics.Containers = append(metrics.Containers, metricsv1beta1.ContainerMetrics{Name: "c" + usage[i], Usage: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(usage[i]),
+			corev1.ResourceMemory: resource.MustParse(usage[i+1]),
+		}})
+	}
+	return metrics
+}
+
+func TestTopSortsAndFiltersUsage(t *testing.T) {
+	svc := newReadService()
+	metrics := metricsfake.NewSimpleClientset()
+	for _, pod := range []*metricsv1beta1.PodMetrics{
+		podMetrics("default", "api-1", "250m", "300Mi", "50m", "212Mi"),
+		podMetrics("default", "worker-1", "900m", "128Mi"),
+		podMetrics("default", "cache-1", "5m", "1Gi"),
+		podMetrics("default", "idle-1", "1m", "16Mi"),
+		podMetrics("shop", "web-1", "2", "2Gi"),
+	} {
+		_ = metrics.Tracker().Create(metricsv1beta1.SchemeGroupVersion.WithResource("pods"), pod, pod.Namespace)
+	}
+	svc.SetMetricsClients(func(config, cluster string, subject *Subject) (metricsclient.Interface, error) {
+		return metrics, nil
+	})
+	req := readRequest(OperationTop, Resource{Kind: "pods", Namespace: "default"})
+	req.Parameters = map[string]string{"sort-by": "memory", "limit": "2", "min-memory": "100Mi"}
+
+	preview, err := svc.Preview(context.Background(), req)
+	if err != nil {
+		t.Fatalf("preview: %v", err)
+	}
+	if want := "kubectl --context prod top pod --sort-by=memory -n default"; preview.Kubectl != want {
+		t.Fatalf("expected %q, got %q", want, preview.Kubectl)
+	}
+	if check := preview.Access.Checks[0]; check.Verb != "list" || check.Group != "metrics.k8s.io" || check.Resource != "pods" {
+		t.Fatalf("unexpected permission check %+v", check)
+	}
+
+	execution, err := svc.Submit(context.Background(), req)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(execution.Result), "\n")
+	if len(lines) != 3 || strings.Join(strings.Fields(lines[1]), " ") != "cache-1 5m 1024Mi" || strings.Join(strings.Fields(lines[2]), " ") != "api-1 300m 512Mi" {
+		t.Fatalf("expected the two heaviest memory users summed over containers, got:\n%s", execution.Result)
+	}
+	if execution.Resource.Kind != "pods.metrics.k8s.io" || execution.SafetyLevel != SafetySafe {
+		t.Fatalf("unexpected execution %+v", execution)
+	}
+
+	usage, err := svc.PodMetrics(context.Background(), "kubeconfig", "prod", "", "")
+	if err != nil || len(usage) != 5 {
+		t.Fatalf("expected usage across namespaces, got %+v (%v)", usage, err)
+	}
+
+	for name, params := range map[string]map[string]string{
+		"sort":      {"sort-by": "disk"},
+		"limit":     {"limit": "0"},
+		"threshold": {"min-cpu": "lots"},
+	} {
+		req := readRequest(OperationTop, Resource{Kind: "pods"})
+		req.Parameters = params
+		var invalid ErrInvalidRequest
+		if _, err := svc.Submit(context.Background(), req); !errors.As(err, &invalid) {
+			t.Fatalf("%s: expected invalid request, got %v", name, err)
+		}
+	}
+}
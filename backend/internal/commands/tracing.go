@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/pramodksahoo/kubechat/backend/internal/commands"
+
+func startExecutionSpan(ctx context.Context, execution Execution) (context.Context, trace.Span) {
+	attributes := []attribute.KeyValue{
+		attribute.String("kubechat.command.id", execution.ID),
+		attribute.String("kubechat.command.operation", string(execution.Operation)),
+		attribute.String("kubechat.command.resource", execution.Resource.String()),
+		attribute.String("kubechat.cluster", execution.Cluster),
+		attribute.String("kubechat.user", execution.UserID),
+	}
+	if execution.CorrelationID != "" {
+		attributes = append(attributes, attribute.String("kubechat.correlation_id", execution.CorrelationID))
+	}
+	if execution.PlanID != "" {
+		attributes = append(attributes, attribute.String("kubechat.command.plan_id", execution.PlanID))
+	}
+	return otel.Tracer(tracerName).Start(ctx, "command "+string(execution.Operation), trace.WithAttributes(attributes...))
+}
+
+func endExecutionSpan(span trace.Span, execution Execution, err error) {
+	defer span.End()
+	switch {
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	case execution.Status == StatusFailed:
+		span.SetStatus(codes.Error, execution.Error)
+	}
+	span.SetAttributes(attribute.String("kubechat.command.status", string(execution.Status)))
+}
@@ -0,0 +1,222 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type WebhookAuthType string
+
+const (
+	WebhookAuthNone   WebhookAuthType = "none"
+	WebhookAuthBearer WebhookAuthType = "bearer"
+	WebhookAuthBasic  WebhookAuthType = "basic"
+	WebhookAuthHMAC   WebhookAuthType = "hmac"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the request body for hmac-authenticated executors.
+const SignatureHeader = "X-Kubechat-Signature"
+
+type WebhookAuth struct {
+	Type     WebhookAuthType `json:"type"`
+	Token    string          `json:"token,omitempty"`
+	Username string          `json:"username,omitempty"`
+	Password string          `json:"password,omitempty"`
+	Secret   string          `json:"secret,omitempty"`
+}
+
+// WebhookExecutor routes one operation type to an external endpoint instead of the cluster.
+type WebhookExecutor struct {
+	Operation   Operation     `json:"operation"`
+	URL         string        `json:"url"`
+	Description string        `json:"description,omitempty"`
+	SafetyLevel SafetyLevel   `json:"safetyLevel"`
+	Timeout     time.Duration `json:"timeout"`
+	Auth        WebhookAuth   `json:"-"`
+	AuthType    string        `json:"authType"`
+	CreatedBy   string        `json:"createdBy,omitempty"`
+	CreatedAt   time.Time     `json:"createdAt"`
+}
+
+type webhookPayload struct {
+	ExecutionID string            `json:"executionId"`
+	Operation   Operation         `json:"operation"`
+	RequestedBy string            `json:"requestedBy"`
+	Config      string            `json:"config,omitempty"`
+	Cluster     string            `json:"cluster,omitempty"`
+	Resource    Resource          `json:"resource"`
+	Parameters  map[string]string `json:"parameters,omitempty"`
+	SafetyLevel SafetyLevel       `json:"safetyLevel"`
+}
+
+type webhookResponse struct {
+	Result string `json:"result"`
+	Error  string `json:"error"`
+}
+
+type WebhookRegistry struct {
+	mu        sync.RWMutex
+	executors map[Operation]WebhookExecutor
+	client    *http.Client
+	now       func() time.Time
+}
+
+func NewWebhookRegistry(client *http.Client) *WebhookRegistry {
+	if client == nil {
+		client = &http.Client{}
+	}
+	return &WebhookRegistry{
+		executors: make(map[Operation]WebhookExecutor),
+		client:    client,
+		now:       func() time.Time { return time.Now().UTC() },
+	}
+}
+
+func (r *WebhookRegistry) Register(executor WebhookExecutor) (WebhookExecutor, error) {
+	executor.Operation = Operation(strings.TrimSpace(string(executor.Operation)))
+	if executor.Operation == "" {
+		return WebhookExecutor{}, ErrInvalidRequest{Reason: "operation is required"}
+	}
+	parsed, err := url.Parse(executor.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return WebhookExecutor{}, ErrInvalidRequest{Reason: "url must be an absolute http(s) URL"}
+	}
+	if executor.Auth.Type == "" {
+		executor.Auth.Type = WebhookAuthNone
+	}
+	switch executor.Auth.Type {
+	case WebhookAuthNone:
+	case WebhookAuthBearer:
+		if executor.Auth.Token == "" {
+			return WebhookExecutor{}, ErrInvalidRequest{Reason: "bearer auth requires a token"}
+		}
+	case WebhookAuthBasic:
+		if executor.Auth.Username == "" {
+			return WebhookExecutor{}, ErrInvalidRequest{Reason: "basic auth requires a username"}
+		}
+	case WebhookAuthHMAC:
+		if executor.Auth.Secret == "" {
+			return WebhookExecutor{}, ErrInvalidRequest{Reason: "hmac auth requires a secret"}
+		}
+	default:
+		return WebhookExecutor{}, ErrInvalidRequest{Reason: fmt.Sprintf("unsupported auth type %q", executor.Auth.Type)}
+	}
+	switch executor.SafetyLevel {
+	case SafetySafe, SafetyWarning, SafetyDangerous:
+	case "":
+		// External tooling is opaque to us, so it is gated by approval unless declared safe.
+		executor.SafetyLevel = SafetyWarning
+	default:
+		return WebhookExecutor{}, ErrInvalidRequest{Reason: fmt.Sprintf("unsupported safety level %q", executor.SafetyLevel)}
+	}
+	if executor.Timeout <= 0 {
+		executor.Timeout = 30 * time.Second
+	}
+	executor.AuthType = string(executor.Auth.Type)
+	executor.CreatedAt = r.now()
+
+	r.mu.Lock()
+	r.executors[executor.Operation] = executor
+	r.mu.Unlock()
+	return executor, nil
+}
+
+func (r *WebhookRegistry) Remove(op Operation) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.executors[op]; !ok {
+		return false
+	}
+	delete(r.executors, op)
+	return true
+}
+
+func (r *WebhookRegistry) Lookup(op Operation) (WebhookExecutor, bool) {
+	if r == nil {
+		return WebhookExecutor{}, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	executor, ok := r.executors[op]
+	return executor, ok
+}
+
+func (r *WebhookRegistry) List() []WebhookExecutor {
+	r.mu.RLock()
+	executors := make([]WebhookExecutor, 0, len(r.executors))
+	for _, executor := range r.executors {
+		executors = append(executors, executor)
+	}
+	r.mu.RUnlock()
+	sort.Slice(executors, func(i, j int) bool { return executors[i].Operation < executors[j].Operation })
+	return executors
+}
+
+// Invoke posts the execution to the executor and returns the result text reported by the endpoint.
+func (r *WebhookRegistry) Invoke(ctx context.Context, executor WebhookExecutor, execution Execution) (string, error) {
+	body, err := json.Marshal(webhookPayload{
+		ExecutionID: execution.ID,
+		Operation:   execution.Operation,
+		RequestedBy: execution.UserID,
+		Config:      execution.Config,
+		Cluster:     execution.Cluster,
+		Resource:    execution.Resource,
+		Parameters:  execution.Parameters,
+		SafetyLevel: execution.SafetyLevel,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, executor.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, executor.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Kubechat-Execution", execution.ID)
+	switch executor.Auth.Type {
+	case WebhookAuthBearer:
+		req.Header.Set("Authorization", "Bearer "+executor.Auth.Token)
+	case WebhookAuthBasic:
+		req.SetBasicAuth(executor.Auth.Username, executor.Auth.Password)
+	case WebhookAuthHMAC:
+		mac := hmac.New(sha256.New, []byte(executor.Auth.Secret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("executor %s: %w", executor.Operation, err)
+	}
+	defer resp.Body.Close()
+
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var decoded webhookResponse
+	_ = json.Unmarshal(raw, &decoded)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message := decoded.Error
+		if message == "" {
+			message = strings.TrimSpace(string(raw))
+		}
+		return "", fmt.Errorf("executor %s returned %d: %s", executor.Operation, resp.StatusCode, message)
+	}
+	if decoded.Result == "" {
+		decoded.Result = fmt.Sprintf("%s delegated to %s", execution.Operation, executor.URL)
+	}
+	return decoded.Result, nil
+}
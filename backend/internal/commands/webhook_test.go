@@ -0,0 +1,117 @@
+package commands
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhookExecutorHandlesDelegatedOperation(t *testing.T) {
+	var received webhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write(body)
+		if r.Header.Get(SignatureHeader) != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.Unmarshal(body, &received)
+		w.Write([]byte(`{"result":"failover to replica-b complete"}`))
+	}))
+	defer server.Close()
+
+	svc, _, _ := newTestService()
+	registry := NewWebhookRegistry(server.Client())
+	svc.SetWebhookRegistry(registry)
+	if _, err := registry.Register(WebhookExecutor{
+		Operation: "db-failover",
+		URL:       server.URL,
+		Auth:      WebhookAuth{Type: WebhookAuthHMAC, Secret: "s3cret"},
+	}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	ctx := context.Background()
+	execution, err := svc.Submit(ctx, Request{
+		UserID:     "alice",
+		Config:     "kubeconfig",
+		Cluster:    "prod",
+		Operation:  "db-failover",
+		Resource:   Resource{Kind: "Database", Name: "orders"},
+		Parameters: map[string]string{"target": "replica-b"},
+	})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if execution.Status != StatusAwaitingApproval || execution.SafetyLevel != SafetyWarning {
+		t.Fatalf("expected delegated operation to default to approval, got %+v", execution)
+	}
+
+	approved, err := svc.Approve(ctx, execution.ID, "bob", nil, "")
+	if err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	if approved.Status != StatusSucceeded || approved.Result != "failover to replica-b complete" {
+		t.Fatalf("unexpected execution %+v", approved)
+	}
+	if received.ExecutionID != execution.ID || received.Parameters["target"] != "replica-b" {
+		t.Fatalf("unexpected webhook payload %+v", received)
+	}
+	if _, err := svc.Rollback(ctx, execution.ID, "alice", nil); !errors.Is(err, ErrRollbackUnavailable) {
+		t.Fatalf("expected no rollback for delegated execution, got %v", err)
+	}
+}
+
+func TestWebhookExecutorFailureIsRecorded(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(`{"error":"cdn api unavailable"}`))
+	}))
+	defer server.Close()
+
+	svc, _, _ := newTestService()
+	registry := NewWebhookRegistry(server.Client())
+	svc.SetWebhookRegistry(registry)
+	registry.Register(WebhookExecutor{
+		Operation:   "cdn-purge",
+		URL:         server.URL,
+		SafetyLevel: SafetySafe,
+		Auth:        WebhookAuth{Type: WebhookAuthBearer, Token: "token"},
+	})
+
+	execution, err := svc.Submit(context.Background(), Request{UserID: "alice", Config: "c", Cluster: "k", Operation: "cdn-purge"})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if execution.Status != StatusFailed || execution.Error != "executor cdn-purge returned 502: cdn api unavailable" {
+		t.Fatalf("unexpected execution %+v", execution)
+	}
+}
+
+func TestWebhookRegistryValidatesExecutors(t *testing.T) {
+	registry := NewWebhookRegistry(nil)
+	cases := []WebhookExecutor{
+		{Operation: "", URL: "https://example.com"},
+		{Operation: "purge", URL: "ftp://example.com"},
+		{Operation: "purge", URL: "https://example.com", Auth: WebhookAuth{Type: WebhookAuthBearer}},
+		{Operation: "purge", URL: "https://example.com", SafetyLevel: "unknown"},
+	}
+	for _, executor := range cases {
+		var invalid ErrInvalidRequest
+		if _, err := registry.Register(executor); !errors.As(err, &invalid) {
+			t.Fatalf("expected %+v to be rejected, got %v", executor, err)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+// Package compliance evaluates KubeChat's own controls, such as MFA for privileged users and
+// approval of dangerous operations, against the SOX, HIPAA and SOC 2 requirements they serve. Every
+// finding is scored from the evidence it lists, so a report can be handed to an auditor as is.
+package compliance
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+type Framework string
+
+const (
+	FrameworkSOX   Framework = "sox"
+	FrameworkHIPAA Framework = "hipaa"
+	FrameworkSOC2  Framework = "soc2"
+)
+
+// FrameworkInfo describes a framework for clients.
+type FrameworkInfo struct {
+	Name  Framework `json:"name"`
+	Title string    `json:"title"`
+}
+
+var frameworks = []FrameworkInfo{
+	{Name: FrameworkSOX, Title: "Sarbanes-Oxley IT general controls"},
+	{Name: FrameworkHIPAA, Title: "HIPAA Security Rule"},
+	{Name: FrameworkSOC2, Title: "SOC 2 Trust Services Criteria"},
+}
+
+// Frameworks lists the frameworks reports are generated for.
+func Frameworks() []FrameworkInfo {
+	return slices.Clone(frameworks)
+}
+
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+	// StatusNotApplicable is a finding without evidence to score, such as approval coverage in a
+	// period in which no dangerous operation ran.
+	StatusNotApplicable Status = "not_applicable"
+	// StatusError is a control that could not be evaluated; it scores as a failure.
+	StatusError Status = "error"
+	// StatusInfo is evidence that describes the control without counting towards its score.
+	StatusInfo Status = "info"
+)
+
+// Evidence is one observation a finding is scored from.
+type Evidence struct {
+	Subject string     `json:"subject"`
+	Detail  string     `json:"detail"`
+	Status  Status     `json:"status"`
+	At      *time.Time `json:"at,omitempty"`
+}
+
+// Assessment is what a control found over a period.
+type Assessment struct {
+	Status   Status     `json:"status"`
+	Score    int        `json:"score"`
+	Summary  string     `json:"summary"`
+	Evidence []Evidence `json:"evidence"`
+}
+
+// Assess scores evidence: passing evidence counts fully, warnings half and failures not at all.
+// The assessment passes when every scored item passes, warns from a score of 80 and fails below.
+func Assess(summary string, evidence []Evidence) Assessment {
+	var scored, earned float64
+	for _, item := range evidence {
+		switch item.Status {
+		case StatusPass:
+			scored, earned = scored+1, earned+1
+		case StatusWarn:
+			scored, earned = scored+1, earned+0.5
+		case StatusFail, StatusError:
+			scored++
+		}
+	}
+	assessment := Assessment{Status: StatusNotApplicable, Summary: summary, Evidence: evidence}
+	if scored == 0 {
+		return assessment
+	}
+	assessment.Score = int(math.Floor(earned / scored * 100))
+	switch {
+	case assessment.Score == 100:
+		assessment.Status = StatusPass
+	case assessment.Score >= 80:
+		assessment.Status = StatusWarn
+	default:
+		assessment.Status = StatusFail
+	}
+	return assessment
+}
+
+// Period is the time range a report covers.
+type Period struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// Contains reports whether at falls inside the period.
+func (p Period) Contains(at time.Time) bool {
+	return !at.Before(p.From) && !at.After(p.To)
+}
+
+// ControlInfo describes a control and the requirement it serves in each framework.
+type ControlInfo struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// Requirements maps each framework the control applies to onto the clause it provides
+	// evidence for.
+	Requirements map[Framework]string `json:"requirements"`
+}
+
+// Control evaluates one control from the records KubeChat keeps.
+type Control interface {
+	Info() ControlInfo
+	Evaluate(ctx context.Context, period Period) (Assessment, error)
+}
+
+// ControlFunc adapts a function to the Control interface.
+type ControlFunc struct {
+	ControlInfo
+	Fn func(ctx context.Context, period Period) (Assessment, error)
+}
+
+func (c ControlFunc) Info() ControlInfo { return c.ControlInfo }
+
+func (c ControlFunc) Evaluate(ctx context.Context, period Period) (Assessment, error) {
+	return c.Fn(ctx, period)
+}
+
+// Finding is a control's assessment within a framework.
+type Finding struct {
+	Control     string `json:"control"`
+	Title       string `json:"title"`
+	Requirement string `json:"requirement"`
+	Assessment
+}
+
+// Report assesses every control a framework requires over a period.
+type Report struct {
+	Framework   Framework `json:"framework"`
+	Title       string    `json:"title"`
+	Period      Period    `json:"period"`
+	GeneratedAt time.Time `json:"generatedAt"`
+	// Score averages the scores of the findings that had evidence to score.
+	Score    int       `json:"score"`
+	Status   Status    `json:"status"`
+	Findings []Finding `json:"findings"`
+}
+
+type ErrInvalidRequest struct {
+	Reason string
+}
+
+func (e ErrInvalidRequest) Error() string {
+	return e.Reason
+}
+
+// DefaultPeriod is the range a report covers when the request names none.
+const DefaultPeriod = 30 * 24 * time.Hour
+
+// Analyzer generates framework reports from a set of controls.
+type Analyzer struct {
+	controls []Control
+	now      func() time.Time
+}
+
+func NewAnalyzer(controls ...Control) *Analyzer {
+	return &Analyzer{controls: controls, now: func() time.Time { return time.Now().UTC() }}
+}
+
+// Controls describes the controls the analyzer evaluates.
+func (a *Analyzer) Controls() []ControlInfo {
+	infos := make([]ControlInfo, 0, len(a.controls))
+	for _, control := range a.controls {
+		infos = append(infos, control.Info())
+	}
+	return infos
+}
+
+// Analyze evaluates the controls framework requires between from and to, the last DefaultPeriod
+// by default. A control that fails to evaluate is reported as an error rather than failing the
+// whole report.
+func (a *Analyzer) Analyze(ctx context.Context, framework Framework, from, to *time.Time) (Report, error) {
+	index := slices.IndexFunc(frameworks, func(info FrameworkInfo) bool { return info.Name == framework })
+	if index < 0 {
+		return Report{}, ErrInvalidRequest{Reason: fmt.Sprintf("unknown framework %q", framework)}
+	}
+	now := a.now()
+	period := Period{From: now.Add(-DefaultPeriod), To: now}
+	if to != nil {
+		period.To = to.UTC()
+	}
+	if from != nil {
+		period.From = from.UTC()
+	} else if to != nil {
+		period.From = period.To.Add(-DefaultPeriod)
+	}
+	if period.From.After(period.To) {
+		return Report{}, ErrInvalidRequest{Reason: "from must not be after to"}
+	}
+
+	report := Report{Framework: framework, Title: frameworks[index].Title, Period: period, GeneratedAt: now, Status: StatusNotApplicable}
+	var total, scored int
+	for _, control := range a.controls {
+		info := control.Info()
+		requirement, ok := info.Requirements[framework]
+		if !ok {
+			continue
+		}
+		assessment, err := control.Evaluate(ctx, period)
+		if err != nil {
+			if ctx.Err() != nil {
+				return Report{}, ctx.Err()
+			}
+			assessment = Assessment{Status: StatusError, Summary: fmt.Sprintf("The control could not be evaluated: %v", err), Evidence: []Evidence{}}
+		}
+		report.Findings = append(report.Findings, Finding{Control: info.ID, Title: info.Title, Requirement: requirement, Assessment: assessment})
+		if assessment.Status != StatusNotApplicable {
+			total, scored = total+assessment.Score, scored+1
+		}
+		report.Status = worse(report.Status, assessment.Status)
+	}
+	if scored > 0 {
+		report.Score = total / scored
+	}
+	return report, nil
+}
+
+var severity = map[Status]int{StatusNotApplicable: 0, StatusPass: 1, StatusWarn: 2, StatusFail: 3, StatusError: 4}
+
+// worse returns the more severe of two statuses; an error fails the report.
+func worse(a, b Status) Status {
+	if severity[b] > severity[a] {
+		a = b
+	}
+	if a == StatusError {
+		return StatusFail
+	}
+	return a
+}
@@ -0,0 +1,167 @@
+package compliance
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pramodksahoo/kubechat/backend/internal/audit"
+	"github.com/pramodksahoo/kubechat/backend/internal/commands"
+	"github.com/pramodksahoo/kubechat/backend/internal/impersonation"
+	"github.com/pramodksahoo/kubechat/backend/internal/mfa"
+	"github.com/pramodksahoo/kubechat/backend/internal/roles"
+)
+
+type fakeBindings []roles.Binding
+
+func (b *fakeBindings) Bindings(ctx context.Context) ([]roles.Binding, error) {
+	return *b, nil
+}
+
+type fakeFactors map[string]mfa.Status
+
+func (f fakeFactors) Status(ctx context.Context, user string) (mfa.Status, error) {
+	return f[user], nil
+}
+
+func (f fakeFactors) Audit(ctx context.Context, filter mfa.AuditFilter) ([]mfa.AuditEntry, error) {
+	return []mfa.AuditEntry{{User: "alice", Action: mfa.ActionStepUpSatisfied, At: time.Now()}}, nil
+}
+
+type fakeExecutions struct {
+	executions []commands.Execution
+	approvals  []commands.Approval
+}
+
+func (f fakeExecutions) ListByUser(ctx context.Context, userID string, limit, offset int) ([]commands.Execution, error) {
+	if offset >= len(f.executions) {
+		return nil, nil
+	}
+	return f.executions[offset:], nil
+}
+
+func (f fakeExecutions) Approvals(ctx context.Context, status commands.ApprovalStatus) ([]commands.Approval, error) {
+	return f.approvals, nil
+}
+
+type fakeSessions []impersonation.Session
+
+func (f fakeSessions) History(ctx context.Context) ([]impersonation.Session, error) {
+	return f, nil
+}
+
+type fakeLedgerStatus struct {
+	verification *audit.Verification
+}
+
+func (f fakeLedgerStatus) Status() (audit.Verification, bool) {
+	if f.verification == nil {
+		return audit.Verification{}, false
+	}
+	return *f.verification, true
+}
+
+func ago(d time.Duration) *time.Time {
+	at := time.Now().UTC().Add(-d)
+	return &at
+}
+
+func TestAnalyzeScoresEveryControlFromItsEvidence(t *testing.T) {
+	bindings := &fakeBindings{
+		{ID: "b1", Role: roles.RoleApprover, Subject: "bob", SubjectKind: roles.SubjectUser},
+		{ID: "b2", Role: roles.RoleViewer, Subject: "carol", SubjectKind: roles.SubjectUser},
+	}
+	factors := fakeFactors{"alice": {Enrolled: true, Confirmed: true}, "bob": {Enrolled: true}}
+	dangerous := func(id, user string) commands.Execution {
+		return commands.Execution{ID: id, UserID: user, SafetyLevel: commands.SafetyDangerous, CreatedAt: *ago(time.Hour), StartedAt: ago(time.Hour)}
+	}
+	executions := fakeExecutions{
+		executions: []commands.Execution{
+			dangerous("approved", "dave"),
+			dangerous("self-approved", "erin"),
+			dangerous("unapproved", "dave"),
+			{ID: "safe", UserID: "dave", SafetyLevel: commands.SafetySafe, CreatedAt: *ago(time.Hour), StartedAt: ago(time.Hour)},
+			dangerous("too-old", "dave"),
+		},
+		approvals: []commands.Approval{
+			{ExecutionID: "approved", Status: commands.ApprovalApproved, PolicyName: "default", Decisions: []commands.ApprovalDecision{{User: "bob", Decision: commands.ApprovalApproved}}},
+			{ExecutionID: "self-approved", Status: commands.ApprovalApproved, Decisions: []commands.ApprovalDecision{{User: "frank", OnBehalfOf: "erin", Decision: commands.ApprovalApproved}}},
+		},
+	}
+	executions.executions[4].CreatedAt = *ago(60 * 24 * time.Hour)
+	sessions := fakeSessions{
+		{ID: "short", Mode: impersonation.ModeConsent, Status: impersonation.StatusEnded, Minutes: 30, StartedAt: ago(2 * time.Hour), EndedAt: ago(100 * time.Minute), EndedBy: "alice"},
+		{ID: "long", Mode: impersonation.ModeBreakGlass, Status: impersonation.StatusExpired, Minutes: 240, StartedAt: ago(5 * time.Hour), EndedAt: ago(time.Hour)},
+	}
+	ledger := fakeLedgerStatus{verification: &audit.Verification{VerifiedAt: *ago(time.Minute), Records: 10, Through: 10, Intact: true}}
+	analyzer := NewAnalyzer(
+		MFAControl(bindings, []string{"alice"}, factors),
+		ApprovalControl(executions),
+		SessionControl(sessions, time.Hour, 30*time.Minute),
+		IntegrityControl(ledger, 15*time.Minute),
+		AccessReviewControl(NewAccessReviews(NewMemoryRepository(), bindings, nil), bindings, DefaultReviewInterval),
+	)
+
+	report, err := analyzer.Analyze(context.Background(), FrameworkHIPAA, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]struct {
+		status Status
+		score  int
+	}{
+		// alice confirmed, bob never confirmed; carol is a viewer and not privileged.
+		"mfa_privileged_users": {StatusFail, 50},
+		// The self-approval went through a delegate of the requester; too-old is outside the period.
+		"dangerous_operation_approval": {StatusFail, 33},
+		// The long break-glass session and the 30 minute step-up window.
+		"session_duration": {StatusFail, 50},
+		"audit_integrity":  {StatusPass, 100},
+		"access_review":    {StatusFail, 0},
+	}
+	if len(report.Findings) != len(want) {
+		t.Fatalf("expected %d findings, got %+v", len(want), report.Findings)
+	}
+	for _, finding := range report.Findings {
+		if expected := want[finding.Control]; finding.Status != expected.status || finding.Score != expected.score {
+			t.Fatalf("expected %s to be %s with %d, got %s with %d: %+v", finding.Control, expected.status, expected.score, finding.Status, finding.Score, finding.Evidence)
+		}
+		if finding.Requirement == "" {
+			t.Fatalf("expected %s to name its HIPAA requirement", finding.Control)
+		}
+	}
+	if report.Status != StatusFail || report.Score != (50+33+50+100+0)/5 {
+		t.Fatalf("unexpected report score %d (%s)", report.Score, report.Status)
+	}
+
+	if _, err := analyzer.Analyze(context.Background(), "pci", nil, nil); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected an unknown framework to be refused, got %v", err)
+	}
+}
+
+func TestAccessReviewsCoverTheBindingsTheyReviewed(t *testing.T) {
+	bindings := &fakeBindings{{ID: "b1", Role: roles.RoleAdmin, Subject: "alice", SubjectKind: roles.SubjectUser}}
+	reviews := NewAccessReviews(NewMemoryRepository(), bindings, log.NewWithOptions(io.Discard, log.Options{}))
+	control := AccessReviewControl(reviews, bindings, DefaultReviewInterval)
+	period := Period{From: time.Now().Add(-time.Hour), To: time.Now().Add(time.Minute)}
+
+	if _, err := reviews.Record(context.Background(), "alice", "quarterly review"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assessment, err := control.Evaluate(context.Background(), period)
+	if err != nil || assessment.Status != StatusPass {
+		t.Fatalf("expected a fresh review to pass, got %+v (%v)", assessment, err)
+	}
+
+	*bindings = append(*bindings,
+		roles.Binding{ID: "b2", Role: roles.RoleOperator, Subject: "bob", SubjectKind: roles.SubjectUser, CreatedBy: "alice"},
+		roles.Binding{ID: "b3", Role: roles.RoleViewer, Subject: "carol", SubjectKind: roles.SubjectUser, CreatedBy: "alice"},
+	)
+	assessment, err = control.Evaluate(context.Background(), period)
+	if err != nil || assessment.Status != StatusFail || len(assessment.Evidence) != 2 || assessment.Evidence[1].Subject != "binding b2" {
+		t.Fatalf("expected the unreviewed operator binding to be reported, got %+v (%v)", assessment, err)
+	}
+}
@@ -0,0 +1,422 @@
+package compliance
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/audit"
+	"github.com/pramodksahoo/kubechat/backend/internal/commands"
+	"github.com/pramodksahoo/kubechat/backend/internal/impersonation"
+	"github.com/pramodksahoo/kubechat/backend/internal/mfa"
+	"github.com/pramodksahoo/kubechat/backend/internal/roles"
+)
+
+type BindingLister interface {
+	Bindings(ctx context.Context) ([]roles.Binding, error)
+}
+
+type MFAReader interface {
+	Status(ctx context.Context, user string) (mfa.Status, error)
+	Audit(ctx context.Context, filter mfa.AuditFilter) ([]mfa.AuditEntry, error)
+}
+
+// privileged reports whether a role changes clusters, approves changes or manages KubeChat.
+func privileged(role roles.Role) bool {
+	return role.Grants(roles.PermissionCommandsExecute) || role.Grants(roles.PermissionCommandsApprove) || role.Grants(roles.PermissionRolesManage)
+}
+
+// MFAControl checks that every privileged user, the configured admins and every user bound to a
+// role that runs or approves commands, confirmed an MFA enrollment. Members of privileged groups
+// are resolved by the identity provider and are reported for review instead.
+func MFAControl(bindings BindingLister, admins []string, factors MFAReader) Control {
+	return ControlFunc{
+		ControlInfo: ControlInfo{
+			ID:          "mfa_privileged_users",
+			Title:       "Multi-factor authentication for privileged users",
+			Description: "Users who run, approve or administer changes hold a confirmed second factor, which high-risk actions require.",
+			Requirements: map[Framework]string{
+				FrameworkSOX:   "ITGC logical access: authentication of privileged users",
+				FrameworkHIPAA: "164.312(d) person or entity authentication",
+				FrameworkSOC2:  "CC6.1 logical access security",
+			},
+		},
+		Fn: func(ctx context.Context, period Period) (Assessment, error) {
+			list, err := bindings.Bindings(ctx)
+			if err != nil {
+				return Assessment{}, err
+			}
+			users := map[string][]string{}
+			for _, admin := range admins {
+				users[admin] = append(users[admin], "configured admin")
+			}
+			var evidence []Evidence
+			for _, binding := range list {
+				if !privileged(binding.Role) {
+					continue
+				}
+				if binding.SubjectKind == roles.SubjectGroup {
+					evidence = append(evidence, Evidence{
+						Subject: "group " + binding.Subject,
+						Detail:  fmt.Sprintf("holds %s; members are resolved by the identity provider, check that it enforces MFA for them", binding.Role),
+						Status:  StatusWarn,
+					})
+					continue
+				}
+				users[binding.Subject] = append(users[binding.Subject], string(binding.Role))
+			}
+
+			names := make([]string, 0, len(users))
+			for user := range users {
+				names = append(names, user)
+			}
+			sort.Strings(names)
+			enrolled := 0
+			for _, user := range names {
+				status, err := factors.Status(ctx, user)
+				if err != nil {
+					return Assessment{}, err
+				}
+				item := Evidence{Subject: user, Detail: "privileged as " + strings.Join(slices.Compact(slices.Sorted(slices.Values(users[user]))), ", ")}
+				switch {
+				case status.Confirmed:
+					enrolled++
+					item.Status, item.Detail = StatusPass, item.Detail+"; MFA confirmed"
+				case status.Enrolled:
+					item.Status, item.Detail = StatusFail, item.Detail+"; MFA enrollment never confirmed"
+				default:
+					item.Status, item.Detail = StatusFail, item.Detail+"; not enrolled in MFA"
+				}
+				evidence = append(evidence, item)
+			}
+
+			entries, err := factors.Audit(ctx, mfa.AuditFilter{})
+			if err != nil {
+				return Assessment{}, err
+			}
+			var satisfied, refused int
+			for _, entry := range entries {
+				if !period.Contains(entry.At) {
+					continue
+				}
+				switch entry.Action {
+				case mfa.ActionStepUpSatisfied:
+					satisfied++
+				case mfa.ActionStepUpRequired:
+					refused++
+				}
+			}
+			evidence = append(evidence, Evidence{
+				Subject: "step-up verification",
+				Detail:  fmt.Sprintf("%d high-risk requests carried a fresh MFA verification and %d were refused without one", satisfied, refused),
+				Status:  StatusInfo,
+			})
+			return Assess(fmt.Sprintf("%d of %d privileged users confirmed an MFA enrollment.", enrolled, len(names)), evidence), nil
+		},
+	}
+}
+
+type ExecutionReader interface {
+	ListByUser(ctx context.Context, userID string, limit, offset int) ([]commands.Execution, error)
+	Approvals(ctx context.Context, status commands.ApprovalStatus) ([]commands.Approval, error)
+}
+
+// executionPageSize is how many executions are read from the store at a time.
+const executionPageSize = 500
+
+// ApprovalControl checks that every dangerous operation that ran in the period was approved, and
+// not by the user who requested it.
+func ApprovalControl(executions ExecutionReader) Control {
+	return ControlFunc{
+		ControlInfo: ControlInfo{
+			ID:          "dangerous_operation_approval",
+			Title:       "Approval of dangerous operations",
+			Description: "Commands classified as dangerous only run once approved by someone other than their requester.",
+			Requirements: map[Framework]string{
+				FrameworkSOX:   "ITGC change management: authorization and segregation of duties",
+				FrameworkHIPAA: "164.308(a)(3)(ii)(A) authorization and supervision",
+				FrameworkSOC2:  "CC8.1 change management",
+			},
+		},
+		Fn: func(ctx context.Context, period Period) (Assessment, error) {
+			approvals, err := executions.Approvals(ctx, "")
+			if err != nil {
+				return Assessment{}, err
+			}
+			byExecution := make(map[string]commands.Approval, len(approvals))
+			for _, approval := range approvals {
+				byExecution[approval.ExecutionID] = approval
+			}
+
+			var evidence []Evidence
+			covered := 0
+			for offset := 0; ; offset += executionPageSize {
+				page, err := executions.ListByUser(ctx, "", executionPageSize, offset)
+				if err != nil {
+					return Assessment{}, err
+				}
+				done := len(page) < executionPageSize
+				for _, execution := range page {
+					// Pages are ordered newest first, so nothing in the period can follow.
+					if execution.CreatedAt.Before(period.From) {
+						done = true
+						break
+					}
+					if !period.Contains(execution.CreatedAt) || execution.SafetyLevel != commands.SafetyDangerous || execution.StartedAt == nil {
+						continue
+					}
+					item := approvalEvidence(execution, byExecution)
+					if item.Status == StatusPass {
+						covered++
+					}
+					evidence = append(evidence, item)
+				}
+				if done {
+					break
+				}
+			}
+			if len(evidence) == 0 {
+				return Assess("No dangerous operation ran in the period.", []Evidence{}), nil
+			}
+			return Assess(fmt.Sprintf("%d of %d dangerous operations that ran were approved by someone other than their requester.", covered, len(evidence)), evidence), nil
+		},
+	}
+}
+
+func approvalEvidence(execution commands.Execution, approvals map[string]commands.Approval) Evidence {
+	item := Evidence{
+		Subject: "execution " + execution.ID,
+		At:      execution.StartedAt,
+		Status:  StatusFail,
+	}
+	target := fmt.Sprintf("%s %s/%s by %s", execution.Operation, execution.Resource.Kind, execution.Resource.Name, execution.UserID)
+	approval, ok := approvals[execution.ID]
+	switch {
+	case !ok:
+		item.Detail = target + " ran without an approval"
+	case approval.Status != commands.ApprovalApproved:
+		item.Detail = fmt.Sprintf("%s ran while its approval was %s", target, approval.Status)
+	default:
+		var approvers []string
+		for _, decision := range approval.Decisions {
+			if decision.Decision != commands.ApprovalApproved {
+				continue
+			}
+			if decision.User == execution.UserID || decision.OnBehalfOf == execution.UserID {
+				item.Detail = target + " was approved by its requester"
+				return item
+			}
+			approvers = append(approvers, decision.User)
+		}
+		if len(approvers) == 0 {
+			item.Detail = target + " has an approval without approve decisions"
+			return item
+		}
+		item.Status = StatusPass
+		item.Detail = fmt.Sprintf("%s approved by %s under policy %s", target, strings.Join(approvers, ", "), approval.PolicyName)
+	}
+	return item
+}
+
+type SessionLister interface {
+	History(ctx context.Context) ([]impersonation.Session, error)
+}
+
+// MaxStepUpWindow is the longest step-up window the session control accepts without a warning.
+const MaxStepUpWindow = 15 * time.Minute
+
+// SessionControl checks that impersonation sessions started in the period ended within limit and
+// that an MFA verification does not satisfy step-up checks for longer than MaxStepUpWindow.
+func SessionControl(sessions SessionLister, limit, stepUpWindow time.Duration) Control {
+	if stepUpWindow <= 0 {
+		stepUpWindow = mfa.DefaultStepUpWindow
+	}
+	return ControlFunc{
+		ControlInfo: ControlInfo{
+			ID:          "session_duration",
+			Title:       "Duration of elevated sessions",
+			Description: fmt.Sprintf("Impersonation sessions end within %s and MFA step-up verifications expire within %s.", limit, MaxStepUpWindow),
+			Requirements: map[Framework]string{
+				FrameworkSOX:   "ITGC logical access: privileged session management",
+				FrameworkHIPAA: "164.312(a)(2)(iii) automatic logoff",
+				FrameworkSOC2:  "CC6.2 access provisioning and removal",
+			},
+		},
+		Fn: func(ctx context.Context, period Period) (Assessment, error) {
+			list, err := sessions.History(ctx)
+			if err != nil {
+				return Assessment{}, err
+			}
+			now := time.Now().UTC()
+			var evidence []Evidence
+			within := 0
+			for _, session := range list {
+				if session.StartedAt == nil || !period.Contains(*session.StartedAt) {
+					continue
+				}
+				end, ended := now, "still active"
+				if session.EndedAt != nil {
+					end, ended = *session.EndedAt, "ended by "+session.EndedBy
+					if session.Status == impersonation.StatusExpired {
+						ended = "expired"
+					}
+				}
+				lasted := end.Sub(*session.StartedAt).Round(time.Second)
+				item := Evidence{
+					Subject: "session " + session.ID,
+					Detail:  fmt.Sprintf("%s session of %s as %s lasted %s of %d minutes granted, %s", session.Mode, session.Actor, session.Subject, lasted, session.Minutes, ended),
+					Status:  StatusPass,
+					At:      session.StartedAt,
+				}
+				if lasted > limit {
+					item.Status = StatusFail
+				} else {
+					within++
+				}
+				evidence = append(evidence, item)
+			}
+			sessionCount := len(evidence)
+
+			window := Evidence{Subject: "MFA step-up window", Detail: fmt.Sprintf("a verified code satisfies step-up checks for %s", stepUpWindow), Status: StatusPass}
+			if stepUpWindow > MaxStepUpWindow {
+				window.Status = StatusWarn
+			}
+			evidence = append(evidence, window)
+			return Assess(fmt.Sprintf("%d of %d impersonation sessions ended within %s.", within, sessionCount, limit), evidence), nil
+		},
+	}
+}
+
+type LedgerStatus interface {
+	Status() (audit.Verification, bool)
+}
+
+// IntegrityControl checks that the audit ledger is verified every interval and was intact when
+// last verified. A nil ledger fails the control: the audit trails are then not tamper-evident.
+func IntegrityControl(ledger LedgerStatus, interval time.Duration) Control {
+	return ControlFunc{
+		ControlInfo: ControlInfo{
+			ID:          "audit_integrity",
+			Title:       "Integrity checks of the audit trail",
+			Description: fmt.Sprintf("The hash chain over the audit trails is verified every %s and found intact.", interval),
+			Requirements: map[Framework]string{
+				FrameworkSOX:   "ITGC computer operations: audit log integrity",
+				FrameworkHIPAA: "164.312(b) audit controls and 164.312(c)(1) integrity",
+				FrameworkSOC2:  "CC7.2 system monitoring",
+			},
+		},
+		Fn: func(ctx context.Context, period Period) (Assessment, error) {
+			if ledger == nil {
+				return Assess("The audit ledger is not configured.", []Evidence{{
+					Subject: "audit ledger",
+					Detail:  "audit trails are not chained into a tamper-evident ledger",
+					Status:  StatusFail,
+				}}), nil
+			}
+			evidence := []Evidence{{Subject: "verification cadence", Detail: fmt.Sprintf("the chain is verified every %s", interval), Status: StatusInfo}}
+			verification, ok := ledger.Status()
+			if !ok {
+				evidence = append(evidence, Evidence{Subject: "last verification", Detail: "the chain has not been verified since the server started", Status: StatusFail})
+				return Assess("The audit ledger has not been verified yet.", evidence), nil
+			}
+			at := verification.VerifiedAt
+			fresh := Evidence{Subject: "last verification", Detail: fmt.Sprintf("%d records verified through record %d", verification.Records, verification.Through), Status: StatusPass, At: &at}
+			// One missed run is tolerated; the verifier may be restarting with its server.
+			if age := time.Since(at); age > 2*interval {
+				fresh.Status, fresh.Detail = StatusFail, fmt.Sprintf("%s; the verification is %s overdue", fresh.Detail, (age-interval).Round(time.Minute))
+			}
+			intact := Evidence{Subject: "chain integrity", Detail: "the chain was intact", Status: StatusPass, At: &at}
+			if !verification.Intact {
+				intact.Status, intact.Detail = StatusFail, "the chain is broken"
+				if alert := verification.Alert; alert != nil {
+					intact.Detail = fmt.Sprintf("the chain is broken at record %d: %s", alert.Seq, alert.Detail)
+				}
+			}
+			evidence = append(evidence, fresh, intact)
+			summary := "The audit ledger was last verified intact at " + at.Format(time.RFC3339) + "."
+			if !verification.Intact {
+				summary = "The audit ledger failed verification at " + at.Format(time.RFC3339) + "."
+			}
+			return Assess(summary, evidence), nil
+		},
+	}
+}
+
+type ReviewLister interface {
+	Reviews(ctx context.Context) ([]AccessReview, error)
+}
+
+// AccessReviewControl checks that role bindings were reviewed within interval of the end of the
+// period, and lists the privileged bindings granted since the last review.
+func AccessReviewControl(reviews ReviewLister, bindings BindingLister, interval time.Duration) Control {
+	return ControlFunc{
+		ControlInfo: ControlInfo{
+			ID:          "access_review",
+			Title:       "Periodic access reviews",
+			Description: fmt.Sprintf("An admin reviews every role binding at least every %d days.", int(interval.Hours()/24)),
+			Requirements: map[Framework]string{
+				FrameworkSOX:   "ITGC logical access: periodic user access review",
+				FrameworkHIPAA: "164.308(a)(4)(ii)(C) access establishment and modification",
+				FrameworkSOC2:  "CC6.3 role-based access and its review",
+			},
+		},
+		Fn: func(ctx context.Context, period Period) (Assessment, error) {
+			list, err := reviews.Reviews(ctx)
+			if err != nil {
+				return Assessment{}, err
+			}
+			current, err := bindings.Bindings(ctx)
+			if err != nil {
+				return Assessment{}, err
+			}
+			var last *AccessReview
+			for i := range list {
+				if !list[i].ReviewedAt.After(period.To) {
+					last = &list[i]
+					break
+				}
+			}
+			if last == nil {
+				return Assess("Role bindings were never reviewed.", []Evidence{{
+					Subject: "access review",
+					Detail:  fmt.Sprintf("no access review was recorded; %d role bindings are unreviewed", len(current)),
+					Status:  StatusFail,
+				}}), nil
+			}
+
+			at := last.ReviewedAt
+			review := Evidence{
+				Subject: "access review " + last.ID,
+				Detail:  fmt.Sprintf("%s reviewed %d role bindings", last.Reviewer, len(last.Bindings)),
+				Status:  StatusPass,
+				At:      &at,
+			}
+			if age := period.To.Sub(at); age > interval {
+				review.Status = StatusFail
+				review.Detail = fmt.Sprintf("%s; the next review was due %s", review.Detail, at.Add(interval).Format(time.DateOnly))
+			}
+			evidence := []Evidence{review}
+			unreviewed := 0
+			for _, binding := range current {
+				if slices.Contains(last.Bindings, binding.ID) {
+					continue
+				}
+				unreviewed++
+				if !privileged(binding.Role) {
+					continue
+				}
+				created := binding.CreatedAt
+				evidence = append(evidence, Evidence{
+					Subject: "binding " + binding.ID,
+					Detail:  fmt.Sprintf("%s %s was granted %s by %s after the last review", binding.SubjectKind, binding.Subject, binding.Role, binding.CreatedBy),
+					Status:  StatusWarn,
+					At:      &created,
+				})
+			}
+			return Assess(fmt.Sprintf("Role bindings were last reviewed by %s on %s; %d bindings were granted since.", last.Reviewer, at.Format(time.DateOnly), unreviewed), evidence), nil
+		},
+	}
+}
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS compliance_access_reviews (
+    id TEXT PRIMARY KEY,
+    reviewer TEXT NOT NULL,
+    notes TEXT NOT NULL DEFAULT '',
+    bindings JSONB NOT NULL,
+    reviewed_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS compliance_access_reviews_reviewed_idx ON compliance_access_reviews (reviewed_at DESC);
@@ -0,0 +1,72 @@
+package compliance
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pramodksahoo/kubechat/backend/internal/database"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+type PostgresRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresRepository applies the compliance schema migrations and returns a repository backed by db.
+func NewPostgresRepository(ctx context.Context, db *sqlx.DB) (*PostgresRepository, error) {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Migrate(ctx, db, "compliance", sub); err != nil {
+		return nil, err
+	}
+	return &PostgresRepository{db: db}, nil
+}
+
+type reviewRow struct {
+	ID         string    `db:"id"`
+	Reviewer   string    `db:"reviewer"`
+	Notes      string    `db:"notes"`
+	Bindings   []byte    `db:"bindings"`
+	ReviewedAt time.Time `db:"reviewed_at"`
+}
+
+func (r *PostgresRepository) SaveReview(ctx context.Context, review AccessReview) error {
+	bindings, err := json.Marshal(review.Bindings)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.NamedExecContext(ctx, `INSERT INTO compliance_access_reviews (id, reviewer, notes, bindings, reviewed_at)
+		VALUES (:id, :reviewer, :notes, :bindings, :reviewed_at)`, reviewRow{
+		ID:         review.ID,
+		Reviewer:   review.Reviewer,
+		Notes:      review.Notes,
+		Bindings:   bindings,
+		ReviewedAt: review.ReviewedAt,
+	})
+	return err
+}
+
+func (r *PostgresRepository) ListReviews(ctx context.Context) ([]AccessReview, error) {
+	var rows []reviewRow
+	if err := r.db.SelectContext(ctx, &rows, `SELECT id, reviewer, notes, bindings, reviewed_at
+		FROM compliance_access_reviews ORDER BY reviewed_at DESC`); err != nil {
+		return nil, err
+	}
+	reviews := make([]AccessReview, 0, len(rows))
+	for _, row := range rows {
+		review := AccessReview{ID: row.ID, Reviewer: row.Reviewer, Notes: row.Notes, ReviewedAt: row.ReviewedAt.UTC()}
+		if err := json.Unmarshal(row.Bindings, &review.Bindings); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, nil
+}
@@ -0,0 +1,41 @@
+package compliance
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// Repository persists access reviews.
+type Repository interface {
+	SaveReview(ctx context.Context, review AccessReview) error
+	// ListReviews returns every access review, most recent first.
+	ListReviews(ctx context.Context) ([]AccessReview, error)
+}
+
+// MemoryRepository keeps access reviews in process memory; it is used when no database is configured.
+type MemoryRepository struct {
+	mu      sync.RWMutex
+	reviews []AccessReview
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{}
+}
+
+func (r *MemoryRepository) SaveReview(ctx context.Context, review AccessReview) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	review.Bindings = slices.Clone(review.Bindings)
+	r.reviews = append(r.reviews, review)
+	return nil
+}
+
+func (r *MemoryRepository) ListReviews(ctx context.Context) ([]AccessReview, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	reviews := slices.Clone(r.reviews)
+	sort.SliceStable(reviews, func(i, j int) bool { return reviews[i].ReviewedAt.After(reviews[j].ReviewedAt) })
+	return reviews, nil
+}
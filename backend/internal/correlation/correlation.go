@@ -0,0 +1,101 @@
+// Package correlation assembles the audit trail of one chat turn: the prompt, the reply with the
+// plan the model translated it into, the commands submitted to carry the plan out, the object
+// changes each made and any rollback of them. They share a correlation ID, the plan's ID; commands
+// submitted without a plan are correlated by their own execution ID.
+package correlation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/api/repository"
+	"github.com/pramodksahoo/kubechat/backend/internal/chat"
+	"github.com/pramodksahoo/kubechat/backend/internal/commands"
+)
+
+type MessageSource interface {
+	Correlated(ctx context.Context, correlationID string) ([]chat.Message, error)
+}
+
+type PlanSource interface {
+	Get(ctx context.Context, id string) (repository.PlanRecord, error)
+}
+
+type ExecutionSource interface {
+	Get(ctx context.Context, id string) (commands.Execution, error)
+	Correlated(ctx context.Context, correlationID string) ([]commands.CorrelatedExecution, error)
+}
+
+// Trail is everything recorded under one correlation ID, each part oldest first.
+type Trail struct {
+	CorrelationID string         `json:"correlationId"`
+	Messages      []chat.Message `json:"messages"`
+	// Plan is nil once the plan expired from the plan store, and for commands submitted without one.
+	Plan       *repository.PlanRecord         `json:"plan,omitempty"`
+	Executions []commands.CorrelatedExecution `json:"executions"`
+}
+
+func (t Trail) empty() bool {
+	return len(t.Messages) == 0 && t.Plan == nil && len(t.Executions) == 0
+}
+
+type ErrNotFound struct {
+	ID string
+}
+
+func (e ErrNotFound) Error() string {
+	return fmt.Sprintf("nothing is correlated with %s", e.ID)
+}
+
+type Tracer struct {
+	messages   MessageSource
+	plans      PlanSource
+	executions ExecutionSource
+}
+
+func NewTracer(messages MessageSource, plans PlanSource, executions ExecutionSource) *Tracer {
+	return &Tracer{messages: messages, plans: plans, executions: executions}
+}
+
+// Trace returns the trail of a correlation ID. id may also be the ID of any execution on the
+// trail, so reviewers can start from a command they found in the execution history.
+func (t *Tracer) Trace(ctx context.Context, id string) (Trail, error) {
+	trail, err := t.trail(ctx, id)
+	if err != nil || !trail.empty() {
+		return trail, err
+	}
+	execution, err := t.executions.Get(ctx, id)
+	var notFound commands.ErrExecutionNotFound
+	if errors.As(err, &notFound) {
+		return Trail{}, ErrNotFound{ID: id}
+	}
+	if err != nil {
+		return Trail{}, err
+	}
+	if execution.CorrelationID == "" || execution.CorrelationID == id {
+		return Trail{}, ErrNotFound{ID: id}
+	}
+	return t.trail(ctx, execution.CorrelationID)
+}
+
+func (t *Tracer) trail(ctx context.Context, correlationID string) (Trail, error) {
+	trail := Trail{CorrelationID: correlationID}
+	var err error
+	if trail.Messages, err = t.messages.Correlated(ctx, correlationID); err != nil {
+		return Trail{}, fmt.Errorf("load correlated chat messages: %w", err)
+	}
+	record, err := t.plans.Get(ctx, correlationID)
+	var expired repository.ErrPlanNotFound
+	switch {
+	case errors.As(err, &expired):
+	case err != nil:
+		return Trail{}, fmt.Errorf("load correlated plan: %w", err)
+	default:
+		trail.Plan = &record
+	}
+	if trail.Executions, err = t.executions.Correlated(ctx, correlationID); err != nil {
+		return Trail{}, fmt.Errorf("load correlated executions: %w", err)
+	}
+	return trail, nil
+}
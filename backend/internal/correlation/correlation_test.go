@@ -0,0 +1,79 @@
+package correlation
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/maypok86/otter/v2"
+	"github.com/pramodksahoo/kubechat/backend/internal/api/repository"
+	"github.com/pramodksahoo/kubechat/backend/internal/chat"
+	"github.com/pramodksahoo/kubechat/backend/internal/commands"
+	"github.com/pramodksahoo/kubechat/backend/internal/plan"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestTraceFollowsAChatTurnToItsCommands(t *testing.T) {
+	ctx := context.Background()
+	logger := log.NewWithOptions(io.Discard, log.Options{})
+
+	sessions := chat.NewService(chat.NewMemoryRepository(), chat.Options{}, logger)
+	session, err := sessions.Start(ctx, "alice", "")
+	if err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+	if _, err := sessions.Record(ctx, "alice", session.ID,
+		chat.Message{Role: chat.RoleUser, Content: "scale api to 4", CorrelationID: "plan-1"},
+		chat.Message{Role: chat.RoleAssistant, Content: "Plan", PlanID: "plan-1", CorrelationID: "plan-1"},
+	); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	plans := repository.NewPlanRepository(otter.Must(&otter.Options[string, any]{MaximumSize: 16}), time.Hour)
+	if _, err := plans.Save(ctx, plan.PlanDraft{ID: "plan-1", Prompt: "scale api to 4"}); err != nil {
+		t.Fatalf("save plan: %v", err)
+	}
+
+	replicas := int32(2)
+	client := fake.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "shop"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+	})
+	executions := commands.NewService(commands.NewMemoryRepository(), func(config, cluster string) kubernetes.Interface { return client }, logger)
+	execution, err := executions.Submit(ctx, commands.Request{
+		UserID:        "alice",
+		Config:        "kubeconfig",
+		Cluster:       "prod",
+		Operation:     commands.OperationScale,
+		Resource:      commands.Resource{Kind: "Deployment", Namespace: "shop", Name: "api"},
+		Parameters:    map[string]string{"replicas": "4"},
+		CorrelationID: "plan-1",
+	})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+
+	tracer := NewTracer(sessions, plans, executions)
+	for _, id := range []string{"plan-1", execution.ID} {
+		trail, err := tracer.Trace(ctx, id)
+		if err != nil {
+			t.Fatalf("trace %s: %v", id, err)
+		}
+		if trail.CorrelationID != "plan-1" || len(trail.Messages) != 2 || trail.Plan == nil || len(trail.Executions) != 1 {
+			t.Fatalf("unexpected trail from %s: %+v", id, trail)
+		}
+		if changes := trail.Executions[0].Changes; len(changes) != 1 || changes[0].Resource.Name != "api" {
+			t.Fatalf("expected the scaled deployment among the changes, got %+v", changes)
+		}
+	}
+
+	var notFound ErrNotFound
+	if _, err := tracer.Trace(ctx, "unknown"); !errors.As(err, &notFound) {
+		t.Fatalf("expected an unknown ID to be not found, got %v", err)
+	}
+}
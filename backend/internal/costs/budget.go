@@ -0,0 +1,319 @@
+package costs
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pramodksahoo/kubechat/backend/internal/ai"
+	"github.com/pramodksahoo/kubechat/backend/internal/audit"
+)
+
+// EachUser makes a budget cap every user's own spend rather than everyone's combined spend.
+const EachUser = "*"
+
+// Budget caps a calendar month of spend on hosted providers. User is empty for everyone's
+// combined spend, EachUser for each user's own spend, or the one user whose spend is capped.
+// Past the soft limit requests are kept on local providers; past the hard limit they are refused.
+// A zero limit is not enforced.
+type Budget struct {
+	Name      string    `json:"name"`
+	User      string    `json:"user,omitempty"`
+	SoftLimit float64   `json:"softLimit,omitempty"`
+	HardLimit float64   `json:"hardLimit,omitempty"`
+	UpdatedBy string    `json:"updatedBy,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type BudgetRequest struct {
+	User      string  `json:"user,omitempty"`
+	SoftLimit float64 `json:"softLimit,omitempty"`
+	HardLimit float64 `json:"hardLimit,omitempty"`
+}
+
+// BudgetStatus is a budget with what was spent against it this month. Spent is left out for
+// budgets capping each user, which have a spend per user.
+type BudgetStatus struct {
+	Budget
+	Currency string   `json:"currency"`
+	Spent    *float64 `json:"spent,omitempty"`
+}
+
+const (
+	// BudgetDowngraded is recorded when a request past a soft limit is kept on local providers.
+	BudgetDowngraded = "downgraded"
+	// BudgetBlocked is recorded when a request past a hard limit is refused.
+	BudgetBlocked = "blocked"
+)
+
+// BudgetAction is an enforcement of a budget, as recorded in the audit ledger.
+type BudgetAction struct {
+	At       time.Time `json:"at"`
+	Action   string    `json:"action"`
+	Budget   string    `json:"budget"`
+	User     string    `json:"user,omitempty"`
+	Spent    float64   `json:"spent"`
+	Limit    float64   `json:"limit"`
+	Currency string    `json:"currency"`
+}
+
+type ErrBudgetNotFound struct {
+	Name string
+}
+
+func (e ErrBudgetNotFound) Error() string {
+	return fmt.Sprintf("model budget %s not found", e.Name)
+}
+
+// BudgetRepository persists budgets.
+type BudgetRepository interface {
+	Budgets(ctx context.Context) ([]Budget, error)
+	SaveBudget(ctx context.Context, budget Budget) error
+	DeleteBudget(ctx context.Context, name string) error
+}
+
+// Spending totals what was spent since a point in time.
+type Spending interface {
+	// Spent totals the cost of user's usage since since, or of everyone's when user is empty.
+	Spent(ctx context.Context, user string, since time.Time) (float64, error)
+}
+
+// AuditLedger records budget enforcement in the tamper-evident audit trail.
+type AuditLedger interface {
+	Append(ctx context.Context, source audit.Source, entry any) error
+}
+
+// nameFormat is the format of budget and allocation rule names.
+var nameFormat = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,62}[a-z0-9])?$`)
+
+// spendTTL is how long a spend total is reused before it is read again, so that checking budgets
+// does not add a query to every model call.
+const spendTTL = 30 * time.Second
+
+type spend struct {
+	amount float64
+	at     time.Time
+}
+
+// BudgetManager enforces budgets against the spend the usage store recorded this month.
+type BudgetManager struct {
+	repo     BudgetRepository
+	spending Spending
+	currency string
+	ledger   AuditLedger
+	logger   *log.Logger
+	now      func() time.Time
+
+	mu      sync.Mutex
+	budgets []Budget
+	spent   map[string]spend
+}
+
+func NewBudgetManager(repo BudgetRepository, spending Spending, currency string, logger *log.Logger) *BudgetManager {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &BudgetManager{repo: repo, spending: spending, currency: currency, logger: logger, now: time.Now, spent: make(map[string]spend)}
+}
+
+// SetLedger records every request a budget downgraded or refused in ledger.
+func (m *BudgetManager) SetLedger(ledger AuditLedger) {
+	m.ledger = ledger
+}
+
+// Load reads the budgets, including those changed on other replicas.
+func (m *BudgetManager) Load(ctx context.Context) error {
+	budgets, err := m.repo.Budgets(ctx)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.budgets = budgets
+	m.mu.Unlock()
+	return nil
+}
+
+// Run reloads the budgets every interval.
+func (m *BudgetManager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.Load(ctx); err != nil {
+				m.logger.Error("failed to reload model budgets", "error", err)
+			}
+		}
+	}
+}
+
+// List returns every budget with this month's spend, by name.
+func (m *BudgetManager) List(ctx context.Context) ([]BudgetStatus, error) {
+	m.mu.Lock()
+	budgets := append([]Budget(nil), m.budgets...)
+	m.mu.Unlock()
+	statuses := make([]BudgetStatus, 0, len(budgets))
+	for _, budget := range budgets {
+		status := BudgetStatus{Budget: budget, Currency: m.currency}
+		if budget.User != EachUser {
+			spent, err := m.spend(ctx, budget.User)
+			if err != nil {
+				return nil, err
+			}
+			status.Spent = &spent
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Save creates or replaces the budget called name.
+func (m *BudgetManager) Save(ctx context.Context, actor, name string, req BudgetRequest) (Budget, error) {
+	if !nameFormat.MatchString(name) {
+		return Budget{}, ErrInvalidRequest{Reason: "budget names are lower-case letters, digits and dashes"}
+	}
+	if req.SoftLimit < 0 || req.HardLimit < 0 {
+		return Budget{}, ErrInvalidRequest{Reason: "budget limits must not be negative"}
+	}
+	if req.SoftLimit == 0 && req.HardLimit == 0 {
+		return Budget{}, ErrInvalidRequest{Reason: "a budget needs a soft or a hard limit"}
+	}
+	if req.SoftLimit > 0 && req.HardLimit > 0 && req.SoftLimit > req.HardLimit {
+		return Budget{}, ErrInvalidRequest{Reason: "the soft limit must not exceed the hard limit"}
+	}
+	budget := Budget{Name: name, User: req.User, SoftLimit: req.SoftLimit, HardLimit: req.HardLimit, UpdatedBy: actor, UpdatedAt: m.now().UTC()}
+	if err := m.repo.SaveBudget(ctx, budget); err != nil {
+		return Budget{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	budgets := make([]Budget, 0, len(m.budgets)+1)
+	for _, existing := range m.budgets {
+		if existing.Name != name {
+			budgets = append(budgets, existing)
+		}
+	}
+	budgets = append(budgets, budget)
+	sort.Slice(budgets, func(i, j int) bool { return budgets[i].Name < budgets[j].Name })
+	m.budgets = budgets
+	return budget, nil
+}
+
+func (m *BudgetManager) Delete(ctx context.Context, name string) error {
+	if err := m.repo.DeleteBudget(ctx, name); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, budget := range m.budgets {
+		if budget.Name == name {
+			m.budgets = append(m.budgets[:i:i], m.budgets[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Enforce returns the budget a model call for user is past and records the call as downgraded
+// to local providers or blocked.
+func (m *BudgetManager) Enforce(ctx context.Context, user string) error {
+	exceeded := m.check(ctx, user)
+	if exceeded == nil {
+		return nil
+	}
+	action := BudgetDowngraded
+	if exceeded.Hard {
+		action = BudgetBlocked
+	}
+	m.record(ctx, action, *exceeded)
+	return *exceeded
+}
+
+// Blocked returns the budget user is past the hard limit of, recording the refused request. Soft
+// limits are left to the model calls.
+func (m *BudgetManager) Blocked(ctx context.Context, user string) error {
+	exceeded := m.check(ctx, user)
+	if exceeded == nil || !exceeded.Hard {
+		return nil
+	}
+	m.record(ctx, BudgetBlocked, *exceeded)
+	return *exceeded
+}
+
+// check returns the budget user is furthest past: any hard limit before a soft one. Budgets whose
+// spend cannot be read are not enforced.
+func (m *BudgetManager) check(ctx context.Context, user string) *ai.ErrBudgetExceeded {
+	m.mu.Lock()
+	budgets := append([]Budget(nil), m.budgets...)
+	m.mu.Unlock()
+	var soft *ai.ErrBudgetExceeded
+	for _, budget := range budgets {
+		subject := budget.User
+		switch {
+		case subject == EachUser && user != "":
+			subject = user
+		case subject != "" && subject != user:
+			continue
+		}
+		spent, err := m.spend(ctx, subject)
+		if err != nil {
+			m.logger.Warn("model budget not enforced; spend is unavailable", "budget", budget.Name, "error", err)
+			continue
+		}
+		exceeded := ai.ErrBudgetExceeded{Budget: budget.Name, User: subject, Spent: spent, Currency: m.currency}
+		if budget.HardLimit > 0 && spent >= budget.HardLimit {
+			exceeded.Limit, exceeded.Hard = budget.HardLimit, true
+			return &exceeded
+		}
+		if budget.SoftLimit > 0 && spent >= budget.SoftLimit && soft == nil {
+			exceeded.Limit = budget.SoftLimit
+			soft = &exceeded
+		}
+	}
+	return soft
+}
+
+// spend returns what user, or everyone when user is empty, spent this month.
+func (m *BudgetManager) spend(ctx context.Context, user string) (float64, error) {
+	now := m.now()
+	m.mu.Lock()
+	cached, ok := m.spent[user]
+	m.mu.Unlock()
+	if ok && now.Sub(cached.at) < spendTTL {
+		return cached.amount, nil
+	}
+	utc := now.UTC()
+	amount, err := m.spending.Spent(ctx, user, time.Date(utc.Year(), utc.Month(), 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	m.spent[user] = spend{amount: amount, at: now}
+	m.mu.Unlock()
+	return amount, nil
+}
+
+func (m *BudgetManager) record(ctx context.Context, action string, exceeded ai.ErrBudgetExceeded) {
+	m.logger.Info("model budget enforced", "action", action, "budget", exceeded.Budget, "user", exceeded.User, "spent", exceeded.Spent, "limit", exceeded.Limit)
+	if m.ledger == nil {
+		return
+	}
+	entry := BudgetAction{
+		At:       m.now().UTC(),
+		Action:   action,
+		Budget:   exceeded.Budget,
+		User:     exceeded.User,
+		Spent:    exceeded.Spent,
+		Limit:    exceeded.Limit,
+		Currency: exceeded.Currency,
+	}
+	if err := m.ledger.Append(context.WithoutCancel(ctx), audit.SourceBudgets, entry); err != nil {
+		m.logger.Error("failed to record model budget enforcement in audit ledger", "budget", exceeded.Budget, "error", err)
+	}
+}
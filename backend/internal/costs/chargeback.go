@@ -0,0 +1,417 @@
+package costs
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// AllocationRule charges the model spend of the users it matches to a department and project.
+// Users are path.Match patterns such as "alice" or "payments-*". Rules are tried by ascending
+// priority, then by name, and the first match wins.
+type AllocationRule struct {
+	Name       string    `json:"name"`
+	Users      []string  `json:"users"`
+	Department string    `json:"department"`
+	Project    string    `json:"project,omitempty"`
+	Priority   int       `json:"priority"`
+	UpdatedBy  string    `json:"updatedBy,omitempty"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+type AllocationRuleRequest struct {
+	Users      []string `json:"users"`
+	Department string   `json:"department"`
+	Project    string   `json:"project,omitempty"`
+	Priority   int      `json:"priority"`
+}
+
+// ChargebackGroup is the level chargeback lines are totalled at.
+type ChargebackGroup string
+
+const (
+	GroupByDepartment ChargebackGroup = "department"
+	GroupByProject    ChargebackGroup = "project"
+	GroupByUser       ChargebackGroup = "user"
+)
+
+// ChargebackLine is the spend charged to a department, project or user. Spend no rule allocates
+// is flagged Unallocated and has no department.
+type ChargebackLine struct {
+	Department       string  `json:"department,omitempty"`
+	Project          string  `json:"project,omitempty"`
+	User             string  `json:"user,omitempty"`
+	Rule             string  `json:"rule,omitempty"`
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"promptTokens"`
+	CompletionTokens int     `json:"completionTokens"`
+	Cost             float64 `json:"cost"`
+	Unallocated      bool    `json:"unallocated,omitempty"`
+}
+
+// ChargebackColumns name the cells of Row.
+var ChargebackColumns = []string{"department", "project", "user", "rule", "requests", "prompt_tokens", "completion_tokens", "cost", "currency", "status"}
+
+// Row returns the cells of the line in a CSV export. The status is allocated or unallocated.
+func (l ChargebackLine) Row(currency string) []string {
+	status := "allocated"
+	if l.Unallocated {
+		status = "unallocated"
+	}
+	return []string{
+		l.Department, l.Project, l.User, l.Rule,
+		strconv.Itoa(l.Requests), strconv.Itoa(l.PromptTokens), strconv.Itoa(l.CompletionTokens),
+		strconv.FormatFloat(l.Cost, 'f', 6, 64), currency, status,
+	}
+}
+
+// ChargebackReport charges a calendar month of model spend to departments and projects. Lines are
+// per user; GroupBy totals them at a coarser level.
+type ChargebackReport struct {
+	Year            int              `json:"year"`
+	Month           int              `json:"month"`
+	Currency        string           `json:"currency"`
+	GeneratedAt     time.Time        `json:"generatedAt"`
+	Total           float64          `json:"total"`
+	UnallocatedCost float64          `json:"unallocatedCost"`
+	Lines           []ChargebackLine `json:"lines"`
+	// Final is set on the report generated once the month ended, which later rule changes leave as it is.
+	Final bool `json:"final,omitempty"`
+}
+
+// ChargebackSummary describes a generated monthly report without its lines.
+type ChargebackSummary struct {
+	Year            int       `json:"year"`
+	Month           int       `json:"month"`
+	Currency        string    `json:"currency"`
+	GeneratedAt     time.Time `json:"generatedAt"`
+	Total           float64   `json:"total"`
+	UnallocatedCost float64   `json:"unallocatedCost"`
+}
+
+func (r ChargebackReport) Summary() ChargebackSummary {
+	return ChargebackSummary{Year: r.Year, Month: r.Month, Currency: r.Currency, GeneratedAt: r.GeneratedAt, Total: r.Total, UnallocatedCost: r.UnallocatedCost}
+}
+
+// GroupBy totals the lines per department, per department and project, or per user, highest
+// cost first. Unallocated spend stays on lines of its own.
+func (r ChargebackReport) GroupBy(group ChargebackGroup) []ChargebackLine {
+	if group == GroupByUser || group == "" {
+		return r.Lines
+	}
+	type key struct {
+		department, project string
+		unallocated         bool
+	}
+	totals := make(map[key]*ChargebackLine)
+	var order []key
+	for _, line := range r.Lines {
+		k := key{department: line.Department, unallocated: line.Unallocated}
+		if group == GroupByProject {
+			k.project = line.Project
+		}
+		total, ok := totals[k]
+		if !ok {
+			total = &ChargebackLine{Department: k.department, Project: k.project, Unallocated: k.unallocated}
+			totals[k] = total
+			order = append(order, k)
+		}
+		total.Requests += line.Requests
+		total.PromptTokens += line.PromptTokens
+		total.CompletionTokens += line.CompletionTokens
+		total.Cost += line.Cost
+	}
+	lines := make([]ChargebackLine, 0, len(order))
+	for _, k := range order {
+		lines = append(lines, *totals[k])
+	}
+	sortChargebackLines(lines)
+	return lines
+}
+
+type ErrAllocationRuleNotFound struct {
+	Name string
+}
+
+func (e ErrAllocationRuleNotFound) Error() string {
+	return fmt.Sprintf("allocation rule %s not found", e.Name)
+}
+
+// AllocationRepository persists allocation rules and the monthly chargeback reports generated
+// from them.
+type AllocationRepository interface {
+	AllocationRules(ctx context.Context) ([]AllocationRule, error)
+	SaveAllocationRule(ctx context.Context, rule AllocationRule) error
+	DeleteAllocationRule(ctx context.Context, name string) error
+	// ChargebackReports summarizes the generated reports, newest month first.
+	ChargebackReports(ctx context.Context) ([]ChargebackSummary, error)
+	// ChargebackReport returns the report generated for a month, if there is one.
+	ChargebackReport(ctx context.Context, year, month int) (ChargebackReport, bool, error)
+	SaveChargebackReport(ctx context.Context, report ChargebackReport) error
+}
+
+// BillSource builds the bill of a calendar month.
+type BillSource interface {
+	Bill(ctx context.Context, year int, month time.Month) (Bill, error)
+}
+
+// ChargebackNotifier is told of every monthly report generated.
+type ChargebackNotifier interface {
+	NotifyChargeback(ctx context.Context, report ChargebackReport)
+}
+
+// chargebackDelay is how long after a month ends its report is generated, so the rollup job has
+// rolled up its last day.
+const chargebackDelay = time.Hour
+
+// Allocator charges model spend to departments and projects with the allocation rules and
+// generates a chargeback report for every month once it has ended.
+type Allocator struct {
+	repo     AllocationRepository
+	bills    BillSource
+	notifier ChargebackNotifier
+	logger   *log.Logger
+	now      func() time.Time
+
+	mu    sync.RWMutex
+	rules []AllocationRule
+}
+
+func NewAllocator(repo AllocationRepository, bills BillSource, logger *log.Logger) *Allocator {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Allocator{repo: repo, bills: bills, logger: logger, now: time.Now}
+}
+
+// SetNotifier announces every monthly report once it is generated.
+func (a *Allocator) SetNotifier(notifier ChargebackNotifier) {
+	a.notifier = notifier
+}
+
+// Load reads the allocation rules, including those changed on other replicas.
+func (a *Allocator) Load(ctx context.Context) error {
+	rules, err := a.repo.AllocationRules(ctx)
+	if err != nil {
+		return err
+	}
+	sortRules(rules)
+	a.mu.Lock()
+	a.rules = rules
+	a.mu.Unlock()
+	return nil
+}
+
+// Run reloads the allocation rules every interval.
+func (a *Allocator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.Load(ctx); err != nil {
+				a.logger.Error("failed to reload allocation rules", "error", err)
+			}
+		}
+	}
+}
+
+// RunMonthly generates the report of the previous month every interval until it has been
+// generated once.
+func (a *Allocator) RunMonthly(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.generateDue(ctx); err != nil {
+				a.logger.Error("failed to generate the monthly chargeback report", "error", err)
+			}
+		}
+	}
+}
+
+func (a *Allocator) generateDue(ctx context.Context) error {
+	now := a.now().UTC()
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if now.Sub(current) < chargebackDelay {
+		return nil
+	}
+	previous := current.AddDate(0, -1, 0)
+	if _, generated, err := a.repo.ChargebackReport(ctx, previous.Year(), int(previous.Month())); err != nil || generated {
+		return err
+	}
+	report, err := a.build(ctx, previous.Year(), previous.Month())
+	if err != nil {
+		return err
+	}
+	report.Final = true
+	if err := a.repo.SaveChargebackReport(ctx, report); err != nil {
+		return err
+	}
+	a.logger.Info("monthly chargeback report generated", "month", previous.Format("2006-01"), "total", report.Total, "unallocated", report.UnallocatedCost)
+	if a.notifier != nil {
+		a.notifier.NotifyChargeback(ctx, report)
+	}
+	return nil
+}
+
+func (a *Allocator) Rules() []AllocationRule {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return append([]AllocationRule{}, a.rules...)
+}
+
+// SaveRule creates or replaces the rule called name.
+func (a *Allocator) SaveRule(ctx context.Context, actor, name string, req AllocationRuleRequest) (AllocationRule, error) {
+	if !nameFormat.MatchString(name) {
+		return AllocationRule{}, ErrInvalidRequest{Reason: "allocation rule names are lower-case letters, digits and dashes"}
+	}
+	department := strings.TrimSpace(req.Department)
+	if department == "" {
+		return AllocationRule{}, ErrInvalidRequest{Reason: "an allocation rule needs a department"}
+	}
+	if len(req.Users) == 0 {
+		return AllocationRule{}, ErrInvalidRequest{Reason: "an allocation rule needs at least one user pattern"}
+	}
+	for _, pattern := range req.Users {
+		if _, err := path.Match(pattern, ""); err != nil || strings.TrimSpace(pattern) == "" {
+			return AllocationRule{}, ErrInvalidRequest{Reason: fmt.Sprintf("%q is not a valid user pattern", pattern)}
+		}
+	}
+	rule := AllocationRule{
+		Name:       name,
+		Users:      req.Users,
+		Department: department,
+		Project:    strings.TrimSpace(req.Project),
+		Priority:   req.Priority,
+		UpdatedBy:  actor,
+		UpdatedAt:  a.now().UTC(),
+	}
+	if err := a.repo.SaveAllocationRule(ctx, rule); err != nil {
+		return AllocationRule{}, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	rules := make([]AllocationRule, 0, len(a.rules)+1)
+	for _, existing := range a.rules {
+		if existing.Name != name {
+			rules = append(rules, existing)
+		}
+	}
+	rules = append(rules, rule)
+	sortRules(rules)
+	a.rules = rules
+	return rule, nil
+}
+
+func (a *Allocator) DeleteRule(ctx context.Context, name string) error {
+	if err := a.repo.DeleteAllocationRule(ctx, name); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, rule := range a.rules {
+		if rule.Name == name {
+			a.rules = append(a.rules[:i:i], a.rules[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (a *Allocator) Reports(ctx context.Context) ([]ChargebackSummary, error) {
+	return a.repo.ChargebackReports(ctx)
+}
+
+// Report returns the chargeback report of a month: the one generated when the month ended, or
+// one built with the current rules for the current month and months never generated.
+func (a *Allocator) Report(ctx context.Context, year int, month time.Month) (ChargebackReport, error) {
+	report, generated, err := a.repo.ChargebackReport(ctx, year, int(month))
+	if err != nil || generated {
+		return report, err
+	}
+	return a.build(ctx, year, month)
+}
+
+func (a *Allocator) build(ctx context.Context, year int, month time.Month) (ChargebackReport, error) {
+	bill, err := a.bills.Bill(ctx, year, month)
+	if err != nil {
+		return ChargebackReport{}, err
+	}
+	rules := a.Rules()
+	report := ChargebackReport{Year: bill.Year, Month: bill.Month, Currency: bill.Currency, GeneratedAt: a.now().UTC(), Lines: make([]ChargebackLine, 0)}
+	byUser := make(map[string]*ChargebackLine)
+	for _, billed := range bill.Lines {
+		// Users pay for what they run on their own API keys.
+		if billed.KeyOwner != "" {
+			continue
+		}
+		line, ok := byUser[billed.User]
+		if !ok {
+			line = &ChargebackLine{User: billed.User, Unallocated: true}
+			if rule, ok := allocate(rules, billed.User); ok {
+				line.Department, line.Project, line.Rule, line.Unallocated = rule.Department, rule.Project, rule.Name, false
+			}
+			byUser[billed.User] = line
+		}
+		line.Requests += billed.Requests
+		line.PromptTokens += billed.PromptTokens
+		line.CompletionTokens += billed.CompletionTokens
+		line.Cost += billed.CostUSD
+		report.Total += billed.CostUSD
+		if line.Unallocated {
+			report.UnallocatedCost += billed.CostUSD
+		}
+	}
+	for _, line := range byUser {
+		report.Lines = append(report.Lines, *line)
+	}
+	sortChargebackLines(report.Lines)
+	return report, nil
+}
+
+// allocate returns the first rule matching user. Calls made without a user are never allocated.
+func allocate(rules []AllocationRule, user string) (AllocationRule, bool) {
+	if user == "" {
+		return AllocationRule{}, false
+	}
+	for _, rule := range rules {
+		for _, pattern := range rule.Users {
+			if ok, _ := path.Match(pattern, user); ok {
+				return rule, true
+			}
+		}
+	}
+	return AllocationRule{}, false
+}
+
+func sortRules(rules []AllocationRule) {
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Priority != rules[j].Priority {
+			return rules[i].Priority < rules[j].Priority
+		}
+		return rules[i].Name < rules[j].Name
+	})
+}
+
+func sortChargebackLines(lines []ChargebackLine) {
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].Cost != lines[j].Cost {
+			return lines[i].Cost > lines[j].Cost
+		}
+		a, b := lines[i], lines[j]
+		return a.Department+"/"+a.Project+"/"+a.User < b.Department+"/"+b.Project+"/"+b.User
+	})
+}
@@ -0,0 +1,250 @@
+package costs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pramodksahoo/kubechat/backend/internal/ai"
+)
+
+// DailyRollup sums one UTC day of a user's usage of a model, apart for the usage paid with the
+// user's own API key.
+type DailyRollup struct {
+	Day              time.Time `json:"day"`
+	User             string    `json:"user,omitempty"`
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	KeyOwner         string    `json:"keyOwner,omitempty"`
+	Requests         int       `json:"requests"`
+	PromptTokens     int       `json:"promptTokens"`
+	CompletionTokens int       `json:"completionTokens"`
+	CostUSD          float64   `json:"costUsd"`
+}
+
+// BillLine is a month of a user's usage of a model. Lines with a KeyOwner were paid with that
+// user's own API key.
+type BillLine struct {
+	User             string  `json:"user,omitempty"`
+	Provider         string  `json:"provider"`
+	Model            string  `json:"model"`
+	KeyOwner         string  `json:"keyOwner,omitempty"`
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"promptTokens"`
+	CompletionTokens int     `json:"completionTokens"`
+	CostUSD          float64 `json:"costUsd"`
+}
+
+// BillDay is one day of a month's usage across users and models.
+type BillDay struct {
+	Day      string  `json:"day"`
+	Requests int     `json:"requests"`
+	CostUSD  float64 `json:"costUsd"`
+}
+
+// Bill is a calendar month of model usage, built from the daily rollups. Costs are in Currency.
+type Bill struct {
+	Year             int     `json:"year"`
+	Month            int     `json:"month"`
+	Currency         string  `json:"currency"`
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"promptTokens"`
+	CompletionTokens int     `json:"completionTokens"`
+	TotalUSD         float64 `json:"totalUsd"`
+	// UserKeysUSD is the part of TotalUSD users paid with their own API keys.
+	UserKeysUSD float64    `json:"userKeysUsd"`
+	Lines       []BillLine `json:"lines"`
+	Days        []BillDay  `json:"days"`
+}
+
+// Backfill is the result of rolling up a range of days again.
+type Backfill struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Days    int    `json:"days"`
+	Rollups int    `json:"rollups"`
+}
+
+type ErrInvalidRequest struct {
+	Reason string
+}
+
+func (e ErrInvalidRequest) Error() string {
+	return e.Reason
+}
+
+const (
+	// recordTimeout bounds how long storing a call's usage may take once the call has been made.
+	recordTimeout = 5 * time.Second
+	// maxBackfillDays bounds the days a single backfill rolls up.
+	maxBackfillDays = 366
+	dayLayout       = "2006-01-02"
+)
+
+// Service records model usage durably and rolls it up into daily totals that bills are built from.
+type Service struct {
+	repo     Repository
+	currency string
+	logger   *log.Logger
+	now      func() time.Time
+}
+
+func NewService(repo Repository, logger *log.Logger) *Service {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Service{repo: repo, currency: "USD", logger: logger, now: time.Now}
+}
+
+// SetCurrency names the currency costs are recorded in, as priced by the price book.
+func (s *Service) SetCurrency(currency string) {
+	s.currency = currency
+}
+
+// RecordUsage stores the usage of one provider call. The call has already been answered, so
+// failures are logged rather than returned.
+func (s *Service) RecordUsage(ctx context.Context, record ai.UsageRecord) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+	defer cancel()
+	if err := s.repo.AppendEvent(ctx, record); err != nil {
+		s.logger.Error("failed to record model usage", "error", err, "user", record.User, "provider", record.Provider, "model", record.Model)
+	}
+}
+
+// Usage totals the recorded usage matching filter per user, session and model, most recently
+// used first.
+func (s *Service) Usage(ctx context.Context, filter ai.UsageFilter) ([]ai.UsageTotals, error) {
+	return s.repo.Usage(ctx, filter)
+}
+
+// Bill builds the bill of a calendar month. The current day is rolled up first so the bill of the
+// current month is up to date.
+func (s *Service) Bill(ctx context.Context, year int, month time.Month) (Bill, error) {
+	if month < time.January || month > time.December {
+		return Bill{}, ErrInvalidRequest{Reason: fmt.Sprintf("month %d is not between 1 and 12", month)}
+	}
+	if year < 2000 || year > 9999 {
+		return Bill{}, ErrInvalidRequest{Reason: fmt.Sprintf("year %d is out of range", year)}
+	}
+	from := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	if today := day(s.now()); !today.Before(from) && today.Before(to) {
+		if _, err := s.repo.RollUp(ctx, today); err != nil {
+			return Bill{}, err
+		}
+	}
+	rollups, err := s.repo.Rollups(ctx, from, to)
+	if err != nil {
+		return Bill{}, err
+	}
+
+	bill := Bill{Year: year, Month: int(month), Currency: s.currency, Lines: make([]BillLine, 0), Days: make([]BillDay, 0)}
+	lines := make(map[BillLine]*BillLine)
+	days := make(map[string]*BillDay)
+	for _, rollup := range rollups {
+		bill.Requests += rollup.Requests
+		bill.PromptTokens += rollup.PromptTokens
+		bill.CompletionTokens += rollup.CompletionTokens
+		bill.TotalUSD += rollup.CostUSD
+		if rollup.KeyOwner != "" {
+			bill.UserKeysUSD += rollup.CostUSD
+		}
+
+		key := BillLine{User: rollup.User, Provider: rollup.Provider, Model: rollup.Model, KeyOwner: rollup.KeyOwner}
+		line, ok := lines[key]
+		if !ok {
+			line = &BillLine{User: rollup.User, Provider: rollup.Provider, Model: rollup.Model, KeyOwner: rollup.KeyOwner}
+			lines[key] = line
+		}
+		line.Requests += rollup.Requests
+		line.PromptTokens += rollup.PromptTokens
+		line.CompletionTokens += rollup.CompletionTokens
+		line.CostUSD += rollup.CostUSD
+
+		name := rollup.Day.Format(dayLayout)
+		total, ok := days[name]
+		if !ok {
+			total = &BillDay{Day: name}
+			days[name] = total
+		}
+		total.Requests += rollup.Requests
+		total.CostUSD += rollup.CostUSD
+	}
+	for _, line := range lines {
+		bill.Lines = append(bill.Lines, *line)
+	}
+	sort.Slice(bill.Lines, func(i, j int) bool {
+		if bill.Lines[i].CostUSD != bill.Lines[j].CostUSD {
+			return bill.Lines[i].CostUSD > bill.Lines[j].CostUSD
+		}
+		a, b := bill.Lines[i], bill.Lines[j]
+		return a.User+"/"+a.Provider+"/"+a.Model+"/"+a.KeyOwner < b.User+"/"+b.Provider+"/"+b.Model+"/"+b.KeyOwner
+	})
+	for _, total := range days {
+		bill.Days = append(bill.Days, *total)
+	}
+	sort.Slice(bill.Days, func(i, j int) bool { return bill.Days[i].Day < bill.Days[j].Day })
+	return bill, nil
+}
+
+// Backfill rolls up again every day from from to to, inclusive, given as YYYY-MM-DD. It is used
+// after usage events were imported or corrected, and to build the rollups of history recorded
+// before they existed.
+func (s *Service) Backfill(ctx context.Context, from, to string) (Backfill, error) {
+	first, err := time.Parse(dayLayout, from)
+	if err != nil {
+		return Backfill{}, ErrInvalidRequest{Reason: fmt.Sprintf("from %q is not a YYYY-MM-DD date", from)}
+	}
+	last, err := time.Parse(dayLayout, to)
+	if err != nil {
+		return Backfill{}, ErrInvalidRequest{Reason: fmt.Sprintf("to %q is not a YYYY-MM-DD date", to)}
+	}
+	if last.Before(first) {
+		return Backfill{}, ErrInvalidRequest{Reason: "to must not be before from"}
+	}
+	if last.After(day(s.now())) {
+		return Backfill{}, ErrInvalidRequest{Reason: "to must not be in the future"}
+	}
+	if days := int(last.Sub(first).Hours()/24) + 1; days > maxBackfillDays {
+		return Backfill{}, ErrInvalidRequest{Reason: fmt.Sprintf("a backfill covers at most %d days, not %d", maxBackfillDays, days)}
+	}
+
+	result := Backfill{From: from, To: to}
+	for current := first; !current.After(last); current = current.AddDate(0, 0, 1) {
+		rollups, err := s.repo.RollUp(ctx, current)
+		if err != nil {
+			return result, fmt.Errorf("roll up %s: %w", current.Format(dayLayout), err)
+		}
+		result.Days++
+		result.Rollups += rollups
+	}
+	return result, nil
+}
+
+// Run rolls up the current and previous day every interval; the previous day picks up usage
+// recorded around midnight.
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			today := day(s.now())
+			for _, current := range []time.Time{today.AddDate(0, 0, -1), today} {
+				if _, err := s.repo.RollUp(ctx, current); err != nil {
+					s.logger.Error("failed to roll up model usage", "error", err, "day", current.Format(dayLayout))
+				}
+			}
+		}
+	}
+}
+
+// day returns the start of the UTC day t falls on.
+func day(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
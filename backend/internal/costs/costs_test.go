@@ -0,0 +1,285 @@
+package costs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pramodksahoo/kubechat/backend/internal/ai"
+	"github.com/pramodksahoo/kubechat/backend/internal/audit"
+)
+
+func TestBillsAreBuiltFromDailyRollups(t *testing.T) {
+	repo := NewMemoryRepository()
+	service := NewService(repo, log.NewWithOptions(io.Discard, log.Options{}))
+	now := time.Date(2025, 3, 2, 12, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	record := func(at time.Time, user, session string, cost float64) {
+		service.RecordUsage(ctx, ai.UsageRecord{At: at, User: user, Session: session, Provider: "anthropic", Model: "claude-3-5-haiku", PromptTokens: 1000, CompletionTokens: 100, CostUSD: cost})
+	}
+	record(time.Date(2025, 2, 28, 23, 0, 0, 0, time.UTC), "alice", "s1", 1)
+	record(time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC), "alice", "s1", 2)
+	record(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC), "alice", "s2", 3)
+	record(time.Date(2025, 3, 2, 8, 0, 0, 0, time.UTC), "bob", "s3", 4)
+
+	if usage, _ := service.Usage(ctx, ai.UsageFilter{User: "alice", Session: "s1"}); len(usage) != 1 || usage[0].Requests != 2 || usage[0].CostUSD != 3 {
+		t.Fatalf("expected alice's first session to total two calls, got %+v", usage)
+	}
+
+	// Only the current day is rolled up on demand; earlier days wait for the job or a backfill.
+	bill, err := service.Bill(ctx, 2025, time.March)
+	if err != nil || bill.TotalUSD != 4 || len(bill.Lines) != 1 || bill.Lines[0].User != "bob" {
+		t.Fatalf("expected only today's usage before a backfill, got %+v: %v", bill, err)
+	}
+	result, err := service.Backfill(ctx, "2025-02-01", "2025-03-02")
+	if err != nil || result.Days != 30 || result.Rollups != 3 {
+		t.Fatalf("unexpected backfill %+v: %v", result, err)
+	}
+	bill, _ = service.Bill(ctx, 2025, time.March)
+	if bill.TotalUSD != 9 || bill.Requests != 3 || bill.PromptTokens != 3000 || len(bill.Days) != 2 || bill.Days[0].Day != "2025-03-01" {
+		t.Fatalf("unexpected March bill %+v", bill)
+	}
+	if len(bill.Lines) != 2 || bill.Lines[0].User != "alice" || bill.Lines[0].CostUSD != 5 || bill.Lines[0].Requests != 2 {
+		t.Fatalf("expected alice's sessions to share a line, got %+v", bill.Lines)
+	}
+	if february, _ := service.Bill(ctx, 2025, time.February); february.TotalUSD != 1 {
+		t.Fatalf("expected February to keep its own usage, got %+v", february)
+	}
+
+	var invalid ErrInvalidRequest
+	if _, err := service.Backfill(ctx, "2025-03-01", "2025-03-03"); !errors.As(err, &invalid) {
+		t.Fatalf("expected a backfill into the future to be refused, got %v", err)
+	}
+	if _, err := service.Bill(ctx, 2025, 13); !errors.As(err, &invalid) {
+		t.Fatalf("expected month 13 to be refused, got %v", err)
+	}
+}
+
+func TestUsageOnUsersOwnKeysIsBilledApart(t *testing.T) {
+	repo := NewMemoryRepository()
+	service := NewService(repo, log.NewWithOptions(io.Discard, log.Options{}))
+	now := time.Date(2025, 3, 2, 12, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	service.RecordUsage(ctx, ai.UsageRecord{At: now.Add(-time.Hour), User: "alice", Provider: "anthropic", Model: "claude-3-5-haiku", CostUSD: 2})
+	service.RecordUsage(ctx, ai.UsageRecord{At: now.Add(-time.Hour), User: "alice", Provider: "anthropic", Model: "claude-3-5-haiku", CostUSD: 5, KeyOwner: "alice"})
+
+	if spent, _ := repo.Spent(ctx, "alice", now.Add(-24*time.Hour)); spent != 2 {
+		t.Fatalf("expected budgets to count only what kubechat paid, got %v", spent)
+	}
+	bill, err := service.Bill(ctx, 2025, time.March)
+	if err != nil || bill.TotalUSD != 7 || bill.UserKeysUSD != 5 || len(bill.Lines) != 2 {
+		t.Fatalf("expected the own-key usage on a line of its own, got %+v: %v", bill, err)
+	}
+	if bill.Lines[0].KeyOwner != "alice" || bill.Lines[0].CostUSD != 5 || bill.Lines[1].KeyOwner != "" {
+		t.Fatalf("unexpected bill lines %+v", bill.Lines)
+	}
+}
+
+func TestPriceBookCostsUsageWithThePricesInEffect(t *testing.T) {
+	book := NewPriceBook(NewMemoryRepository(), "eur", ai.PriceTable{"claude-3-5-haiku": {InputPerMillion: 1}}, log.NewWithOptions(io.Discard, log.Options{}))
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	book.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if price, ok := book.PriceAt("anthropic", "claude-3-5-haiku-20241022", now); !ok || price.InputPerMillion != 1 {
+		t.Fatalf("expected the built-in prices before any version, got %+v", price)
+	}
+	first, err := book.Publish(ctx, "alice", PriceVersionRequest{Prices: map[string]ai.PriceTable{
+		AnyProvider: {"Claude-3-5-Haiku": {InputPerMillion: 2, OutputPerMillion: 4}},
+		"bedrock":   {"claude-3-5-haiku": {InputPerMillion: 3, PerRequest: 0.01}},
+	}})
+	if err != nil || first.Version != 1 || first.Currency != "EUR" {
+		t.Fatalf("unexpected first version %+v: %v", first, err)
+	}
+	later := now.Add(24 * time.Hour)
+	if _, err := book.Publish(ctx, "alice", PriceVersionRequest{EffectiveFrom: &later, Prices: map[string]ai.PriceTable{AnyProvider: {"claude-3-5-haiku": {InputPerMillion: 5}}}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if price, _ := book.PriceAt("anthropic", "claude-3-5-haiku", now.Add(time.Hour)); price.InputPerMillion != 2 {
+		t.Fatalf("expected the first version until the second takes effect, got %+v", price)
+	}
+	if price, _ := book.PriceAt("bedrock", "claude-3-5-haiku", now.Add(time.Hour)); price.Cost(ai.Usage{PromptTokens: 1_000_000}) != 3.01 {
+		t.Fatalf("expected the provider's own price with its request charge, got %+v", price)
+	}
+	if price, _ := book.PriceAt("anthropic", "claude-3-5-haiku", later); price.InputPerMillion != 5 {
+		t.Fatalf("expected the second version once it took effect, got %+v", price)
+	}
+	if price, _ := book.PriceAt("anthropic", "claude-3-5-haiku", now.Add(-time.Hour)); price.InputPerMillion != 1 {
+		t.Fatalf("expected earlier usage to keep the built-in prices, got %+v", price)
+	}
+	if overview := book.Overview(); overview.Current == nil || overview.Current.Version != 1 || len(overview.Versions) != 2 || overview.Versions[0].Version != 2 {
+		t.Fatalf("unexpected overview %+v", overview)
+	}
+
+	var invalid ErrInvalidRequest
+	past := now.Add(-time.Hour)
+	for _, req := range []PriceVersionRequest{
+		{EffectiveFrom: &past, Prices: map[string]ai.PriceTable{AnyProvider: {"claude": {}}}},
+		{EffectiveFrom: &now, Prices: map[string]ai.PriceTable{AnyProvider: {"claude": {}}}},
+		{Currency: "USD", Prices: map[string]ai.PriceTable{AnyProvider: {"claude": {}}}},
+		{Prices: map[string]ai.PriceTable{AnyProvider: {"claude": {InputPerMillion: -1}}}},
+	} {
+		if _, err := book.Publish(ctx, "alice", req); !errors.As(err, &invalid) {
+			t.Fatalf("expected %+v to be refused, got %v", req, err)
+		}
+	}
+}
+
+type recordedLedger []any
+
+func (l *recordedLedger) Append(ctx context.Context, source audit.Source, entry any) error {
+	*l = append(*l, entry)
+	return nil
+}
+
+func TestBudgetsDowngradeAndBlockPastTheirLimits(t *testing.T) {
+	repo := NewMemoryRepository()
+	budgets := NewBudgetManager(repo, repo, "USD", log.NewWithOptions(io.Discard, log.Options{}))
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)
+	budgets.now = func() time.Time { return now }
+	ledger := &recordedLedger{}
+	budgets.SetLedger(ledger)
+	ctx := context.Background()
+
+	if _, err := budgets.Save(ctx, "admin", "per-user", BudgetRequest{User: EachUser, SoftLimit: 10, HardLimit: 5}); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected a soft limit above the hard limit to be rejected, got %v", err)
+	}
+	if _, err := budgets.Save(ctx, "admin", "per-user", BudgetRequest{User: EachUser, SoftLimit: 5, HardLimit: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := budgets.Save(ctx, "admin", "everyone", BudgetRequest{HardLimit: 100}); err != nil {
+		t.Fatal(err)
+	}
+	record := func(at time.Time, user string, cost float64) {
+		repo.AppendEvent(ctx, ai.UsageRecord{At: at, User: user, Provider: "anthropic", Model: "claude-3-5-haiku", CostUSD: cost})
+	}
+	record(time.Date(2025, 2, 28, 9, 0, 0, 0, time.UTC), "alice", 50)
+	record(time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC), "alice", 6)
+	record(time.Date(2025, 3, 2, 9, 0, 0, 0, time.UTC), "bob", 12)
+
+	var exceeded ai.ErrBudgetExceeded
+	if err := budgets.Enforce(ctx, "alice"); !errors.As(err, &exceeded) || exceeded.Hard || exceeded.Spent != 6 || exceeded.User != "alice" {
+		t.Fatalf("expected only this month's spend to pass alice's soft limit, got %v", err)
+	}
+	if err := budgets.Blocked(ctx, "alice"); err != nil {
+		t.Fatalf("expected a soft limit not to block prompts, got %v", err)
+	}
+	if err := budgets.Blocked(ctx, "bob"); !errors.As(err, &exceeded) || !exceeded.Hard || exceeded.Limit != 10 {
+		t.Fatalf("expected bob to be blocked at the hard limit, got %v", err)
+	}
+	if err := budgets.Enforce(ctx, "carol"); err != nil {
+		t.Fatalf("expected carol to be within budget, got %v", err)
+	}
+	actions := []string{BudgetDowngraded, BudgetBlocked}
+	if len(*ledger) != len(actions) {
+		t.Fatalf("expected every enforcement to be audited, got %+v", *ledger)
+	}
+	for i, entry := range *ledger {
+		if action := entry.(BudgetAction); action.Action != actions[i] || action.Currency != "USD" {
+			t.Fatalf("unexpected audit entry %+v", action)
+		}
+	}
+
+	// Spend is reused briefly, then read again.
+	record(time.Date(2025, 3, 15, 11, 0, 0, 0, time.UTC), "carol", 90)
+	if err := budgets.Enforce(ctx, "carol"); err != nil {
+		t.Fatalf("expected the cached spend to be reused, got %v", err)
+	}
+	now = now.Add(time.Minute)
+	if err := budgets.Blocked(ctx, "carol"); !errors.As(err, &exceeded) || exceeded.Budget != "everyone" || exceeded.Spent != 108 {
+		t.Fatalf("expected everyone's combined spend to reach the hard limit, got %v", err)
+	}
+
+	statuses, err := budgets.List(ctx)
+	if err != nil || len(statuses) != 2 || statuses[0].Name != "everyone" || statuses[0].Spent == nil || statuses[1].Spent != nil {
+		t.Fatalf("unexpected budgets %+v: %v", statuses, err)
+	}
+	if err := budgets.Delete(ctx, "missing"); !errors.As(err, &ErrBudgetNotFound{}) {
+		t.Fatalf("expected a missing budget to be reported, got %v", err)
+	}
+}
+
+type recordedChargebacks []ChargebackReport
+
+func (n *recordedChargebacks) NotifyChargeback(ctx context.Context, report ChargebackReport) {
+	*n = append(*n, report)
+}
+
+func TestChargebackAllocatesSpendByRuleAndFlagsTheRest(t *testing.T) {
+	repo := NewMemoryRepository()
+	logger := log.NewWithOptions(io.Discard, log.Options{})
+	service := NewService(repo, logger)
+	allocator := NewAllocator(repo, service, logger)
+	now := time.Date(2025, 3, 20, 12, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+	allocator.now = service.now
+	notified := &recordedChargebacks{}
+	allocator.SetNotifier(notified)
+	ctx := context.Background()
+
+	if _, err := allocator.SaveRule(ctx, "admin", "payments", AllocationRuleRequest{Users: []string{"pay-*"}, Department: "Finance", Project: "payments"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := allocator.SaveRule(ctx, "admin", "ledger-lead", AllocationRuleRequest{Users: []string{"pay-lead"}, Department: "Finance", Project: "ledger", Priority: -1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := allocator.SaveRule(ctx, "admin", "broken", AllocationRuleRequest{Users: []string{"[a-"}, Department: "Finance"}); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected a malformed user pattern to be rejected, got %v", err)
+	}
+	for _, event := range []struct {
+		user string
+		cost float64
+	}{{"pay-alice", 4}, {"pay-lead", 3}, {"pay-alice", 1}, {"mallory", 2}, {"", 0.5}} {
+		service.RecordUsage(ctx, ai.UsageRecord{At: time.Date(2025, 2, 10, 9, 0, 0, 0, time.UTC), User: event.user, Provider: "anthropic", Model: "claude-3-5-haiku", CostUSD: event.cost})
+	}
+	if _, err := service.Backfill(ctx, "2025-02-10", "2025-02-10"); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := allocator.Report(ctx, 2025, time.February)
+	if err != nil || report.Total != 10.5 || report.UnallocatedCost != 2.5 || report.Final {
+		t.Fatalf("unexpected live report %+v: %v", report, err)
+	}
+	if lead := report.Lines[1]; lead.User != "pay-lead" || lead.Project != "ledger" || lead.Rule != "ledger-lead" {
+		t.Fatalf("expected the lower priority rule to allocate the lead, got %+v", report.Lines)
+	}
+	departments := report.GroupBy(GroupByDepartment)
+	if len(departments) != 2 || departments[0].Department != "Finance" || departments[0].Cost != 8 || !departments[1].Unallocated || departments[1].Cost != 2.5 {
+		t.Fatalf("unexpected department totals %+v", departments)
+	}
+	if projects := report.GroupBy(GroupByProject); len(projects) != 3 || projects[0].Project != "payments" || projects[0].Cost != 5 {
+		t.Fatalf("unexpected project totals %+v", projects)
+	}
+
+	// February's report is generated once March is under way, and later rule changes leave it be.
+	now = time.Date(2025, 3, 1, 0, 30, 0, 0, time.UTC)
+	if err := allocator.generateDue(ctx); err != nil || len(*notified) != 0 {
+		t.Fatalf("expected the report to wait for the last day's rollup: %v", err)
+	}
+	now = time.Date(2025, 3, 1, 2, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		if err := allocator.generateDue(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(*notified) != 1 || !(*notified)[0].Final {
+		t.Fatalf("expected one final report to be announced, got %+v", *notified)
+	}
+	if err := allocator.DeleteRule(ctx, "payments"); err != nil {
+		t.Fatal(err)
+	}
+	if final, _ := allocator.Report(ctx, 2025, time.February); !final.Final || final.UnallocatedCost != 2.5 {
+		t.Fatalf("expected the generated report to keep its allocation, got %+v", final)
+	}
+	if reports, _ := allocator.Reports(ctx); len(reports) != 1 || reports[0].Month != 2 {
+		t.Fatalf("unexpected generated reports %+v", reports)
+	}
+}
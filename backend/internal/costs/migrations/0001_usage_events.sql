@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS usage_events (
+    id BIGSERIAL PRIMARY KEY,
+    at TIMESTAMPTZ NOT NULL,
+    "user" TEXT NOT NULL DEFAULT '',
+    session_id TEXT NOT NULL DEFAULT '',
+    provider TEXT NOT NULL,
+    model TEXT NOT NULL,
+    prompt_tokens INTEGER NOT NULL,
+    completion_tokens INTEGER NOT NULL,
+    cost_usd DOUBLE PRECISION NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS usage_events_at_idx ON usage_events (at);
+CREATE INDEX IF NOT EXISTS usage_events_user_idx ON usage_events ("user", at DESC);
+
+CREATE TABLE IF NOT EXISTS cost_daily_rollups (
+    day DATE NOT NULL,
+    "user" TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    model TEXT NOT NULL,
+    requests INTEGER NOT NULL,
+    prompt_tokens BIGINT NOT NULL,
+    completion_tokens BIGINT NOT NULL,
+    cost_usd DOUBLE PRECISION NOT NULL,
+    rolled_up_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (day, "user", provider, model)
+);
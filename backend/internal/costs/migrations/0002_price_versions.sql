@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS price_versions (
+    version INTEGER PRIMARY KEY,
+    effective_from TIMESTAMPTZ NOT NULL,
+    body JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
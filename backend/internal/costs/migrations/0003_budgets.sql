@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS model_budgets (
+    name TEXT PRIMARY KEY,
+    body JSONB NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
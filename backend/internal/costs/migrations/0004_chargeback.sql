@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS allocation_rules (
+    name TEXT PRIMARY KEY,
+    body JSONB NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS chargeback_reports (
+    year INTEGER NOT NULL,
+    month INTEGER NOT NULL,
+    currency TEXT NOT NULL,
+    generated_at TIMESTAMPTZ NOT NULL,
+    total DOUBLE PRECISION NOT NULL,
+    unallocated_cost DOUBLE PRECISION NOT NULL,
+    body JSONB NOT NULL,
+    PRIMARY KEY (year, month)
+);
@@ -0,0 +1,5 @@
+ALTER TABLE usage_events ADD COLUMN IF NOT EXISTS key_owner TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE cost_daily_rollups ADD COLUMN IF NOT EXISTS key_owner TEXT NOT NULL DEFAULT '';
+ALTER TABLE cost_daily_rollups DROP CONSTRAINT IF EXISTS cost_daily_rollups_pkey;
+ALTER TABLE cost_daily_rollups ADD PRIMARY KEY (day, "user", provider, model, key_owner);
@@ -0,0 +1,69 @@
+package costs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/pramodksahoo/kubechat/backend/internal/alerting"
+)
+
+type AlertDispatcher interface {
+	Dispatch(ctx context.Context, alert alerting.Alert) (alerting.Record, error)
+}
+
+// AlertNotifier announces monthly chargeback reports as costs.chargeback alerts, so they reach
+// the email or Slack channels routed to that type.
+type AlertNotifier struct {
+	dispatcher AlertDispatcher
+	publicURL  string
+	logger     *log.Logger
+}
+
+// NewAlertNotifier links alerts to the report under publicURL, the address users reach KubeChat
+// at; without it the alert carries no link.
+func NewAlertNotifier(dispatcher AlertDispatcher, publicURL string, logger *log.Logger) *AlertNotifier {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &AlertNotifier{dispatcher: dispatcher, publicURL: strings.TrimRight(publicURL, "/"), logger: logger}
+}
+
+func (n *AlertNotifier) NotifyChargeback(ctx context.Context, report ChargebackReport) {
+	if _, err := n.dispatcher.Dispatch(context.WithoutCancel(ctx), n.alert(report)); err != nil {
+		n.logger.Error("failed to announce the chargeback report", "year", report.Year, "month", report.Month, "error", err)
+	}
+}
+
+func (n *AlertNotifier) alert(report ChargebackReport) alerting.Alert {
+	period := fmt.Sprintf("%04d-%02d", report.Year, report.Month)
+	severity := alerting.SeverityInfo
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "Model spend for %s: %.2f %s.\n", period, report.Total, report.Currency)
+	for _, line := range report.GroupBy(GroupByDepartment) {
+		department := line.Department
+		if line.Unallocated {
+			department = "Unallocated"
+		}
+		fmt.Fprintf(&summary, "%s: %.2f %s\n", department, line.Cost, report.Currency)
+	}
+	if report.UnallocatedCost > 0 {
+		severity = alerting.SeverityLow
+		fmt.Fprintf(&summary, "%.2f %s matched no allocation rule; add rules for the users listed as unallocated.", report.UnallocatedCost, report.Currency)
+	}
+	alert := alerting.Alert{
+		Type:     "costs.chargeback",
+		Severity: severity,
+		Title:    "Model chargeback report for " + period,
+		Summary:  strings.TrimSpace(summary.String()),
+		DedupKey: "costs.chargeback." + period,
+		Details:  map[string]any{"year": report.Year, "month": report.Month, "total": report.Total, "unallocatedCost": report.UnallocatedCost, "currency": report.Currency},
+		At:       report.GeneratedAt,
+	}
+	if n.publicURL != "" {
+		base := fmt.Sprintf("%s/api/v1/admin/nlp/chargeback/%d/%d", n.publicURL, report.Month, report.Year)
+		alert.Links = []alerting.Link{{Text: "Download CSV", URL: base + "?format=csv"}, {Text: "View report", URL: base}}
+	}
+	return alert
+}
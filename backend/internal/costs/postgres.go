@@ -0,0 +1,308 @@
+package costs
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pramodksahoo/kubechat/backend/internal/ai"
+	"github.com/pramodksahoo/kubechat/backend/internal/database"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// PostgresRepository stores every usage event as a row and keeps one rollup row per day, user,
+// provider and model. Price book versions, budgets, allocation rules and chargeback reports are
+// stored as JSON documents.
+type PostgresRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresRepository applies the cost schema migrations and returns a repository backed by db.
+func NewPostgresRepository(ctx context.Context, db *sqlx.DB) (*PostgresRepository, error) {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Migrate(ctx, db, "costs", sub); err != nil {
+		return nil, err
+	}
+	return &PostgresRepository{db: db}, nil
+}
+
+// usageReportLimit bounds the totals a usage report returns.
+const usageReportLimit = 1000
+
+func (r *PostgresRepository) AppendEvent(ctx context.Context, record ai.UsageRecord) error {
+	_, err := r.db.ExecContext(ctx, `INSERT INTO usage_events (at, "user", session_id, provider, model, prompt_tokens, completion_tokens, cost_usd, key_owner)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		record.At, record.User, record.Session, record.Provider, record.Model, record.PromptTokens, record.CompletionTokens, record.CostUSD, record.KeyOwner)
+	return err
+}
+
+type usageRow struct {
+	User             string    `db:"user"`
+	Session          string    `db:"session_id"`
+	Provider         string    `db:"provider"`
+	Model            string    `db:"model"`
+	KeyOwner         string    `db:"key_owner"`
+	Requests         int       `db:"requests"`
+	PromptTokens     int       `db:"prompt_tokens"`
+	CompletionTokens int       `db:"completion_tokens"`
+	CostUSD          float64   `db:"cost_usd"`
+	LastUsedAt       time.Time `db:"last_used_at"`
+}
+
+func (r *PostgresRepository) Usage(ctx context.Context, filter ai.UsageFilter) ([]ai.UsageTotals, error) {
+	var rows []usageRow
+	err := r.db.SelectContext(ctx, &rows, `SELECT "user", session_id, provider, model, key_owner, count(*) AS requests,
+			sum(prompt_tokens) AS prompt_tokens, sum(completion_tokens) AS completion_tokens,
+			sum(cost_usd) AS cost_usd, max(at) AS last_used_at
+		FROM usage_events
+		WHERE ($1 = '' OR "user" = $1) AND ($2 = '' OR session_id = $2) AND ($3 = '' OR model = $3)
+		GROUP BY "user", session_id, provider, model, key_owner
+		ORDER BY last_used_at DESC
+		LIMIT $4`, filter.User, filter.Session, filter.Model, usageReportLimit)
+	if err != nil {
+		return nil, err
+	}
+	report := make([]ai.UsageTotals, 0, len(rows))
+	for _, row := range rows {
+		report = append(report, ai.UsageTotals{
+			User:             row.User,
+			Session:          row.Session,
+			Provider:         row.Provider,
+			Model:            row.Model,
+			KeyOwner:         row.KeyOwner,
+			Requests:         row.Requests,
+			PromptTokens:     row.PromptTokens,
+			CompletionTokens: row.CompletionTokens,
+			CostUSD:          row.CostUSD,
+			LastUsedAt:       row.LastUsedAt,
+		})
+	}
+	return report, nil
+}
+
+// RollUp passes days as dates so that the time zone of the database does not shift them.
+func (r *PostgresRepository) RollUp(ctx context.Context, day time.Time) (int, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM cost_daily_rollups WHERE day = $1::date`, day.Format(dayLayout)); err != nil {
+		return 0, err
+	}
+	result, err := tx.ExecContext(ctx, `INSERT INTO cost_daily_rollups
+			(day, "user", provider, model, key_owner, requests, prompt_tokens, completion_tokens, cost_usd, rolled_up_at)
+		SELECT $1::date, "user", provider, model, key_owner, count(*), sum(prompt_tokens), sum(completion_tokens), sum(cost_usd), now()
+		FROM usage_events
+		WHERE at >= $2 AND at < $3
+		GROUP BY "user", provider, model, key_owner`, day.Format(dayLayout), day, day.AddDate(0, 0, 1))
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), tx.Commit()
+}
+
+type rollupRow struct {
+	Day              time.Time `db:"day"`
+	User             string    `db:"user"`
+	Provider         string    `db:"provider"`
+	Model            string    `db:"model"`
+	KeyOwner         string    `db:"key_owner"`
+	Requests         int       `db:"requests"`
+	PromptTokens     int       `db:"prompt_tokens"`
+	CompletionTokens int       `db:"completion_tokens"`
+	CostUSD          float64   `db:"cost_usd"`
+}
+
+func (r *PostgresRepository) Rollups(ctx context.Context, from, to time.Time) ([]DailyRollup, error) {
+	var rows []rollupRow
+	if err := r.db.SelectContext(ctx, &rows, `SELECT day, "user", provider, model, key_owner, requests, prompt_tokens, completion_tokens, cost_usd
+		FROM cost_daily_rollups WHERE day >= $1::date AND day < $2::date ORDER BY day`, from.Format(dayLayout), to.Format(dayLayout)); err != nil {
+		return nil, err
+	}
+	rollups := make([]DailyRollup, 0, len(rows))
+	for _, row := range rows {
+		rollups = append(rollups, DailyRollup(row))
+	}
+	return rollups, nil
+}
+
+func (r *PostgresRepository) Spent(ctx context.Context, user string, since time.Time) (float64, error) {
+	var spent float64
+	err := r.db.GetContext(ctx, &spent, `SELECT COALESCE(sum(cost_usd), 0) FROM usage_events WHERE at >= $1 AND key_owner = '' AND ($2 = '' OR "user" = $2)`, since, user)
+	return spent, err
+}
+
+func (r *PostgresRepository) PriceVersions(ctx context.Context) ([]PriceVersion, error) {
+	var bodies [][]byte
+	if err := r.db.SelectContext(ctx, &bodies, `SELECT body FROM price_versions ORDER BY version`); err != nil {
+		return nil, err
+	}
+	versions := make([]PriceVersion, 0, len(bodies))
+	for _, body := range bodies {
+		var version PriceVersion
+		if err := json.Unmarshal(body, &version); err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+func (r *PostgresRepository) SavePriceVersion(ctx context.Context, version PriceVersion) error {
+	body, err := json.Marshal(version)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `INSERT INTO price_versions (version, effective_from, body, created_at) VALUES ($1, $2, $3, $4)`,
+		version.Version, version.EffectiveFrom, body, version.CreatedAt)
+	return err
+}
+
+func (r *PostgresRepository) Budgets(ctx context.Context) ([]Budget, error) {
+	var bodies [][]byte
+	if err := r.db.SelectContext(ctx, &bodies, `SELECT body FROM model_budgets ORDER BY name`); err != nil {
+		return nil, err
+	}
+	budgets := make([]Budget, 0, len(bodies))
+	for _, body := range bodies {
+		var budget Budget
+		if err := json.Unmarshal(body, &budget); err != nil {
+			return nil, err
+		}
+		budgets = append(budgets, budget)
+	}
+	return budgets, nil
+}
+
+func (r *PostgresRepository) SaveBudget(ctx context.Context, budget Budget) error {
+	body, err := json.Marshal(budget)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `INSERT INTO model_budgets (name, body, updated_at) VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE SET body = EXCLUDED.body, updated_at = EXCLUDED.updated_at`,
+		budget.Name, body, budget.UpdatedAt)
+	return err
+}
+
+func (r *PostgresRepository) DeleteBudget(ctx context.Context, name string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM model_budgets WHERE name = $1`, name)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrBudgetNotFound{Name: name}
+	}
+	return nil
+}
+
+func (r *PostgresRepository) AllocationRules(ctx context.Context) ([]AllocationRule, error) {
+	var bodies [][]byte
+	if err := r.db.SelectContext(ctx, &bodies, `SELECT body FROM allocation_rules`); err != nil {
+		return nil, err
+	}
+	rules := make([]AllocationRule, 0, len(bodies))
+	for _, body := range bodies {
+		var rule AllocationRule
+		if err := json.Unmarshal(body, &rule); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (r *PostgresRepository) SaveAllocationRule(ctx context.Context, rule AllocationRule) error {
+	body, err := json.Marshal(rule)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `INSERT INTO allocation_rules (name, body, updated_at) VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE SET body = EXCLUDED.body, updated_at = EXCLUDED.updated_at`,
+		rule.Name, body, rule.UpdatedAt)
+	return err
+}
+
+func (r *PostgresRepository) DeleteAllocationRule(ctx context.Context, name string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM allocation_rules WHERE name = $1`, name)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrAllocationRuleNotFound{Name: name}
+	}
+	return nil
+}
+
+type chargebackRow struct {
+	Year            int       `db:"year"`
+	Month           int       `db:"month"`
+	Currency        string    `db:"currency"`
+	GeneratedAt     time.Time `db:"generated_at"`
+	Total           float64   `db:"total"`
+	UnallocatedCost float64   `db:"unallocated_cost"`
+}
+
+func (r *PostgresRepository) ChargebackReports(ctx context.Context) ([]ChargebackSummary, error) {
+	var rows []chargebackRow
+	if err := r.db.SelectContext(ctx, &rows, `SELECT year, month, currency, generated_at, total, unallocated_cost
+		FROM chargeback_reports ORDER BY year DESC, month DESC`); err != nil {
+		return nil, err
+	}
+	summaries := make([]ChargebackSummary, 0, len(rows))
+	for _, row := range rows {
+		summaries = append(summaries, ChargebackSummary(row))
+	}
+	return summaries, nil
+}
+
+func (r *PostgresRepository) ChargebackReport(ctx context.Context, year, month int) (ChargebackReport, bool, error) {
+	var body []byte
+	err := r.db.GetContext(ctx, &body, `SELECT body FROM chargeback_reports WHERE year = $1 AND month = $2`, year, month)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ChargebackReport{}, false, nil
+	}
+	if err != nil {
+		return ChargebackReport{}, false, err
+	}
+	var report ChargebackReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		return ChargebackReport{}, false, err
+	}
+	return report, true, nil
+}
+
+// SaveChargebackReport keeps the report first saved for a month, should two replicas generate it.
+func (r *PostgresRepository) SaveChargebackReport(ctx context.Context, report ChargebackReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `INSERT INTO chargeback_reports (year, month, currency, generated_at, total, unallocated_cost, body)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (year, month) DO NOTHING`,
+		report.Year, report.Month, report.Currency, report.GeneratedAt, report.Total, report.UnallocatedCost, body)
+	return err
+}
@@ -0,0 +1,233 @@
+package costs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pramodksahoo/kubechat/backend/internal/ai"
+)
+
+// AnyProvider keys the prices of a version that apply to a model whichever provider serves it.
+const AnyProvider = "*"
+
+// PriceVersion is one published set of prices. A version applies from EffectiveFrom until the
+// next version takes effect, so usage is always costed with the prices in effect when it happened.
+// Prices are keyed by provider, or AnyProvider, and then by model name prefix.
+type PriceVersion struct {
+	Version       int                      `json:"version"`
+	Currency      string                   `json:"currency"`
+	EffectiveFrom time.Time                `json:"effectiveFrom"`
+	Prices        map[string]ai.PriceTable `json:"prices"`
+	Note          string                   `json:"note,omitempty"`
+	CreatedBy     string                   `json:"createdBy"`
+	CreatedAt     time.Time                `json:"createdAt"`
+}
+
+// PriceVersionRequest publishes new prices. Without EffectiveFrom they take effect at once.
+type PriceVersionRequest struct {
+	Currency      string                   `json:"currency"`
+	EffectiveFrom *time.Time               `json:"effectiveFrom,omitempty"`
+	Prices        map[string]ai.PriceTable `json:"prices"`
+	Note          string                   `json:"note,omitempty"`
+}
+
+// PriceBookOverview shows the currency, the version in effect and every published version.
+type PriceBookOverview struct {
+	Currency string         `json:"currency"`
+	Current  *PriceVersion  `json:"current,omitempty"`
+	Versions []PriceVersion `json:"versions"`
+}
+
+type ErrPriceVersionNotFound struct {
+	Version int
+}
+
+func (e ErrPriceVersionNotFound) Error() string {
+	return fmt.Sprintf("price book version %d not found", e.Version)
+}
+
+// PriceRepository persists price book versions; versions are never changed once saved.
+type PriceRepository interface {
+	// PriceVersions returns every version, oldest first.
+	PriceVersions(ctx context.Context) ([]PriceVersion, error)
+	SavePriceVersion(ctx context.Context, version PriceVersion) error
+}
+
+// PriceBook prices model calls with versioned prices. Until a version is published, and for
+// usage before the first version took effect, the built-in defaults apply.
+type PriceBook struct {
+	repo     PriceRepository
+	currency string
+	defaults ai.PriceTable
+	logger   *log.Logger
+	now      func() time.Time
+
+	mu       sync.RWMutex
+	versions []PriceVersion
+}
+
+func NewPriceBook(repo PriceRepository, currency string, defaults ai.PriceTable, logger *log.Logger) *PriceBook {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &PriceBook{repo: repo, currency: strings.ToUpper(currency), defaults: defaults, logger: logger, now: time.Now}
+}
+
+// Currency is the currency prices are published and costs reported in.
+func (b *PriceBook) Currency() string {
+	return b.currency
+}
+
+// Load reads the published versions, including those other replicas published.
+func (b *PriceBook) Load(ctx context.Context) error {
+	versions, err := b.repo.PriceVersions(ctx)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	b.versions = versions
+	b.mu.Unlock()
+	return nil
+}
+
+// Run reloads the published versions every interval.
+func (b *PriceBook) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := b.Load(ctx); err != nil {
+				b.logger.Error("failed to reload the price book", "error", err)
+			}
+		}
+	}
+}
+
+// PriceAt prices model on provider with the version in effect at at. A provider's own prices
+// win over those for any provider.
+func (b *PriceBook) PriceAt(provider, model string, at time.Time) (ai.Price, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	version := b.effectiveLocked(at)
+	if version == nil {
+		return b.defaults.Lookup(model)
+	}
+	if price, ok := version.Prices[provider].Lookup(model); ok {
+		return price, true
+	}
+	return version.Prices[AnyProvider].Lookup(model)
+}
+
+func (b *PriceBook) Overview() PriceBookOverview {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	overview := PriceBookOverview{Currency: b.currency, Versions: make([]PriceVersion, 0, len(b.versions))}
+	for i := len(b.versions) - 1; i >= 0; i-- {
+		overview.Versions = append(overview.Versions, b.versions[i])
+	}
+	if current := b.effectiveLocked(b.now()); current != nil {
+		version := *current
+		overview.Current = &version
+	}
+	return overview
+}
+
+func (b *PriceBook) Version(number int) (PriceVersion, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, version := range b.versions {
+		if version.Version == number {
+			return version, nil
+		}
+	}
+	return PriceVersion{}, ErrPriceVersionNotFound{Version: number}
+}
+
+// Publish saves prices as the next version. Versions take effect now or later, never in the past,
+// so usage already costed keeps its cost.
+func (b *PriceBook) Publish(ctx context.Context, actor string, req PriceVersionRequest) (PriceVersion, error) {
+	now := b.now().UTC()
+	version := PriceVersion{Currency: strings.ToUpper(req.Currency), EffectiveFrom: now, Note: req.Note, CreatedBy: actor, CreatedAt: now}
+	if version.Currency == "" {
+		version.Currency = b.currency
+	}
+	if version.Currency != b.currency {
+		return PriceVersion{}, ErrInvalidRequest{Reason: fmt.Sprintf("prices must be in %s, the configured currency", b.currency)}
+	}
+	if req.EffectiveFrom != nil {
+		if req.EffectiveFrom.Before(now.Add(-time.Minute)) {
+			return PriceVersion{}, ErrInvalidRequest{Reason: "effectiveFrom must not be in the past"}
+		}
+		version.EffectiveFrom = req.EffectiveFrom.UTC()
+	}
+	prices, err := normalizePrices(req.Prices)
+	if err != nil {
+		return PriceVersion{}, err
+	}
+	version.Prices = prices
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// Another replica may have published since the versions were last loaded.
+	versions, err := b.repo.PriceVersions(ctx)
+	if err != nil {
+		return PriceVersion{}, err
+	}
+	b.versions = versions
+	// Versions take effect in the order they are published.
+	if n := len(b.versions); n > 0 {
+		latest := b.versions[n-1]
+		if version.EffectiveFrom.Before(latest.EffectiveFrom) {
+			return PriceVersion{}, ErrInvalidRequest{Reason: fmt.Sprintf("effectiveFrom must not be before %s, when version %d takes effect", latest.EffectiveFrom.Format(time.RFC3339), latest.Version)}
+		}
+		version.Version = latest.Version + 1
+	} else {
+		version.Version = 1
+	}
+	if err := b.repo.SavePriceVersion(ctx, version); err != nil {
+		return PriceVersion{}, err
+	}
+	b.versions = append(b.versions, version)
+	return version, nil
+}
+
+// effectiveLocked returns the latest version in effect at at, or nil before the first.
+func (b *PriceBook) effectiveLocked(at time.Time) *PriceVersion {
+	i := sort.Search(len(b.versions), func(i int) bool { return b.versions[i].EffectiveFrom.After(at) })
+	if i == 0 {
+		return nil
+	}
+	return &b.versions[i-1]
+}
+
+// normalizePrices checks prices and lower-cases model names, as model lookups are.
+func normalizePrices(prices map[string]ai.PriceTable) (map[string]ai.PriceTable, error) {
+	if len(prices) == 0 {
+		return nil, ErrInvalidRequest{Reason: "prices are required"}
+	}
+	normalized := make(map[string]ai.PriceTable, len(prices))
+	for provider, table := range prices {
+		if strings.TrimSpace(provider) == "" {
+			return nil, ErrInvalidRequest{Reason: fmt.Sprintf("prices need a provider name, or %q for every provider", AnyProvider)}
+		}
+		normalized[provider] = make(ai.PriceTable, len(table))
+		for model, price := range table {
+			if strings.TrimSpace(model) == "" {
+				return nil, ErrInvalidRequest{Reason: fmt.Sprintf("prices of %s need a model name", provider)}
+			}
+			if price.InputPerMillion < 0 || price.OutputPerMillion < 0 || price.PerRequest < 0 {
+				return nil, ErrInvalidRequest{Reason: fmt.Sprintf("the price of %s on %s must not be negative", model, provider)}
+			}
+			normalized[provider][strings.ToLower(model)] = price
+		}
+	}
+	return normalized, nil
+}
@@ -0,0 +1,253 @@
+package costs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/ai"
+)
+
+// Repository persists usage events and their daily rollups.
+type Repository interface {
+	AppendEvent(ctx context.Context, record ai.UsageRecord) error
+	// Usage totals the events matching filter per user, session and model, most recently used first.
+	Usage(ctx context.Context, filter ai.UsageFilter) ([]ai.UsageTotals, error)
+	// RollUp replaces the rollups of the UTC day starting at day with totals of its events,
+	// returning how many rollups the day has.
+	RollUp(ctx context.Context, day time.Time) (int, error)
+	// Rollups returns the rollups of the days from from up to but excluding to.
+	Rollups(ctx context.Context, from, to time.Time) ([]DailyRollup, error)
+	// Spent totals the cost of user's events since since, or of everyone's when user is empty.
+	// Events paid with a user's own API key are left out.
+	Spent(ctx context.Context, user string, since time.Time) (float64, error)
+}
+
+// maxMemoryEvents bounds the events a MemoryRepository keeps; the oldest are dropped first and
+// only remain in the rollups already made of them.
+const maxMemoryEvents = 100000
+
+// MemoryRepository keeps usage in process memory; it is used when no database is configured.
+type MemoryRepository struct {
+	mu      sync.RWMutex
+	events  []ai.UsageRecord
+	rollups map[time.Time][]DailyRollup
+	// dropped is when the newest dropped event was recorded; days before it keep their rollups.
+	dropped time.Time
+	prices  []PriceVersion
+	budgets map[string]Budget
+	rules   map[string]AllocationRule
+	reports map[string]ChargebackReport
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{rollups: make(map[time.Time][]DailyRollup), budgets: make(map[string]Budget),
+		rules: make(map[string]AllocationRule), reports: make(map[string]ChargebackReport)}
+}
+
+func (r *MemoryRepository) AppendEvent(ctx context.Context, record ai.UsageRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.events) >= maxMemoryEvents {
+		drop := len(r.events) - maxMemoryEvents + 1
+		r.dropped = r.events[drop-1].At
+		r.events = append(r.events[:0], r.events[drop:]...)
+	}
+	r.events = append(r.events, record)
+	return nil
+}
+
+func (r *MemoryRepository) Usage(ctx context.Context, filter ai.UsageFilter) ([]ai.UsageTotals, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	type group struct{ user, session, provider, model, keyOwner string }
+	totals := make(map[group]*ai.UsageTotals)
+	for _, event := range r.events {
+		if !filter.Matches(event) {
+			continue
+		}
+		key := group{event.User, event.Session, event.Provider, event.Model, event.KeyOwner}
+		total, ok := totals[key]
+		if !ok {
+			total = &ai.UsageTotals{User: event.User, Session: event.Session, Provider: event.Provider, Model: event.Model, KeyOwner: event.KeyOwner}
+			totals[key] = total
+		}
+		total.Requests++
+		total.PromptTokens += event.PromptTokens
+		total.CompletionTokens += event.CompletionTokens
+		total.CostUSD += event.CostUSD
+		if event.At.After(total.LastUsedAt) {
+			total.LastUsedAt = event.At
+		}
+	}
+	report := make([]ai.UsageTotals, 0, len(totals))
+	for _, total := range totals {
+		report = append(report, *total)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].LastUsedAt.After(report[j].LastUsedAt) })
+	return report, nil
+}
+
+func (r *MemoryRepository) RollUp(ctx context.Context, day time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.dropped.IsZero() && day.Before(r.dropped) {
+		return len(r.rollups[day]), nil
+	}
+	end := day.AddDate(0, 0, 1)
+	type group struct{ user, provider, model, keyOwner string }
+	totals := make(map[group]*DailyRollup)
+	var order []group
+	for _, event := range r.events {
+		if event.At.Before(day) || !event.At.Before(end) {
+			continue
+		}
+		key := group{event.User, event.Provider, event.Model, event.KeyOwner}
+		rollup, ok := totals[key]
+		if !ok {
+			rollup = &DailyRollup{Day: day, User: event.User, Provider: event.Provider, Model: event.Model, KeyOwner: event.KeyOwner}
+			totals[key] = rollup
+			order = append(order, key)
+		}
+		rollup.Requests++
+		rollup.PromptTokens += event.PromptTokens
+		rollup.CompletionTokens += event.CompletionTokens
+		rollup.CostUSD += event.CostUSD
+	}
+	rollups := make([]DailyRollup, 0, len(order))
+	for _, key := range order {
+		rollups = append(rollups, *totals[key])
+	}
+	if len(rollups) == 0 {
+		delete(r.rollups, day)
+	} else {
+		r.rollups[day] = rollups
+	}
+	return len(rollups), nil
+}
+
+func (r *MemoryRepository) Rollups(ctx context.Context, from, to time.Time) ([]DailyRollup, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	rollups := make([]DailyRollup, 0)
+	for day, totals := range r.rollups {
+		if !day.Before(from) && day.Before(to) {
+			rollups = append(rollups, totals...)
+		}
+	}
+	sort.SliceStable(rollups, func(i, j int) bool { return rollups[i].Day.Before(rollups[j].Day) })
+	return rollups, nil
+}
+
+// Spent only sees the events still kept, so spend falls once events are dropped.
+func (r *MemoryRepository) Spent(ctx context.Context, user string, since time.Time) (float64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var spent float64
+	for _, event := range r.events {
+		if !event.At.Before(since) && event.KeyOwner == "" && (user == "" || event.User == user) {
+			spent += event.CostUSD
+		}
+	}
+	return spent, nil
+}
+
+func (r *MemoryRepository) PriceVersions(ctx context.Context) ([]PriceVersion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]PriceVersion{}, r.prices...), nil
+}
+
+func (r *MemoryRepository) SavePriceVersion(ctx context.Context, version PriceVersion) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prices = append(r.prices, version)
+	return nil
+}
+
+func (r *MemoryRepository) Budgets(ctx context.Context) ([]Budget, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	budgets := make([]Budget, 0, len(r.budgets))
+	for _, budget := range r.budgets {
+		budgets = append(budgets, budget)
+	}
+	sort.Slice(budgets, func(i, j int) bool { return budgets[i].Name < budgets[j].Name })
+	return budgets, nil
+}
+
+func (r *MemoryRepository) SaveBudget(ctx context.Context, budget Budget) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.budgets[budget.Name] = budget
+	return nil
+}
+
+func (r *MemoryRepository) DeleteBudget(ctx context.Context, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.budgets[name]; !ok {
+		return ErrBudgetNotFound{Name: name}
+	}
+	delete(r.budgets, name)
+	return nil
+}
+
+func (r *MemoryRepository) AllocationRules(ctx context.Context) ([]AllocationRule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	rules := make([]AllocationRule, 0, len(r.rules))
+	for _, rule := range r.rules {
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (r *MemoryRepository) SaveAllocationRule(ctx context.Context, rule AllocationRule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rules[rule.Name] = rule
+	return nil
+}
+
+func (r *MemoryRepository) DeleteAllocationRule(ctx context.Context, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.rules[name]; !ok {
+		return ErrAllocationRuleNotFound{Name: name}
+	}
+	delete(r.rules, name)
+	return nil
+}
+
+func (r *MemoryRepository) ChargebackReports(ctx context.Context) ([]ChargebackSummary, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	summaries := make([]ChargebackSummary, 0, len(r.reports))
+	for _, report := range r.reports {
+		summaries = append(summaries, report.Summary())
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Year != summaries[j].Year {
+			return summaries[i].Year > summaries[j].Year
+		}
+		return summaries[i].Month > summaries[j].Month
+	})
+	return summaries, nil
+}
+
+func (r *MemoryRepository) ChargebackReport(ctx context.Context, year, month int) (ChargebackReport, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	report, ok := r.reports[fmt.Sprintf("%04d-%02d", year, month)]
+	return report, ok, nil
+}
+
+func (r *MemoryRepository) SaveChargebackReport(ctx context.Context, report ChargebackReport) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reports[fmt.Sprintf("%04d-%02d", report.Year, report.Month)] = report
+	return nil
+}
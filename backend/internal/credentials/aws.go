@@ -0,0 +1,72 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/attachments"
+)
+
+type errAWS struct {
+	service string
+	status  int
+	kind    string
+	message string
+}
+
+func (e errAWS) Error() string {
+	return fmt.Sprintf("%s returned %d %s: %s", e.service, e.status, e.kind, e.message)
+}
+
+func awsErrorType(err error) string {
+	var failure errAWS
+	if errors.As(err, &failure) {
+		return failure.kind
+	}
+	return ""
+}
+
+// callAWS calls an action of an AWS service speaking the JSON protocol, such as
+// secretsmanager.GetSecretValue or TrentService.Encrypt of KMS.
+func callAWS(ctx context.Context, client *http.Client, endpoint, region string, creds attachments.Credentials, service, target string, now time.Time, input, out any) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+	attachments.SignRequest(req, body, creds, region, service, now)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		var failure struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&failure)
+		// Error types may be qualified, such as com.amazonaws.secretsmanager#ResourceNotFoundException.
+		_, kind, found := strings.Cut(failure.Type, "#")
+		if !found {
+			kind = failure.Type
+		}
+		return errAWS{service: service, status: resp.StatusCode, kind: kind, message: failure.Message}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,84 @@
+package credentials
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/attachments"
+)
+
+type AWSKMSConfig struct {
+	// KeyID is the ARN, ID or alias of the key, such as alias/kubechat-user-keys.
+	KeyID  string
+	Region string
+	// Endpoint selects a compatible service; AWS is used when empty.
+	Endpoint    string
+	Credentials attachments.Credentials
+}
+
+// AWSKMS wraps data keys with a key of AWS KMS. Rotation of the key's material is transparent to
+// KubeChat; pointing KeyID at another key makes the re-wrap job move data keys to it.
+type AWSKMS struct {
+	cfg    AWSKMSConfig
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewAWSKMS(cfg AWSKMSConfig, client *http.Client) (*AWSKMS, error) {
+	if cfg.KeyID == "" || cfg.Region == "" {
+		return nil, ErrInvalidRequest{Reason: "aws kms requires a key and a region"}
+	}
+	if cfg.Credentials.AccessKeyID == "" || cfg.Credentials.SecretAccessKey == "" {
+		return nil, ErrInvalidRequest{Reason: "aws kms requires AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY"}
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://kms." + cfg.Region + ".amazonaws.com"
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+	return &AWSKMS{cfg: cfg, client: client, now: time.Now}, nil
+}
+
+func (k *AWSKMS) Name() string {
+	return KMSAWS
+}
+
+// Wrap returns the ARN of the key as its version.
+func (k *AWSKMS) Wrap(ctx context.Context, dataKey []byte) ([]byte, string, error) {
+	var out struct {
+		CiphertextBlob []byte
+		KeyId          string
+	}
+	err := k.call(ctx, "Encrypt", map[string]any{"KeyId": k.cfg.KeyID, "Plaintext": dataKey}, &out)
+	return out.CiphertextBlob, out.KeyId, err
+}
+
+func (k *AWSKMS) Unwrap(ctx context.Context, wrapped []byte, version string) ([]byte, error) {
+	var out struct {
+		Plaintext []byte
+	}
+	input := map[string]any{"CiphertextBlob": wrapped}
+	if version != "" {
+		input["KeyId"] = version
+	}
+	err := k.call(ctx, "Decrypt", input, &out)
+	return out.Plaintext, err
+}
+
+func (k *AWSKMS) CurrentVersion(ctx context.Context) (string, error) {
+	var out struct {
+		KeyMetadata struct {
+			Arn string
+		}
+	}
+	err := k.call(ctx, "DescribeKey", map[string]any{"KeyId": k.cfg.KeyID}, &out)
+	return out.KeyMetadata.Arn, err
+}
+
+func (k *AWSKMS) call(ctx context.Context, action string, input, out any) error {
+	return callAWS(ctx, k.client, k.cfg.Endpoint, k.cfg.Region, k.cfg.Credentials, "kms", "TrentService."+action, k.now(), input, out)
+}
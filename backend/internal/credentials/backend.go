@@ -0,0 +1,87 @@
+package credentials
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+)
+
+// Backends user keys may be kept in. Keys in the keyring backend are stored with the rest of the
+// keyring; the others hold the sealed key and the keyring only refers to it.
+const (
+	BackendKeyring           = "keyring"
+	BackendVault             = "vault"
+	BackendAWSSecretsManager = "aws-secrets-manager"
+)
+
+// Backend keeps sealed keys outside the keyring. Keys are sealed before they reach a backend, so
+// none of them sees a key in the clear.
+type Backend interface {
+	Name() string
+	Put(ctx context.Context, name string, sealed []byte) error
+	// Get returns ErrSecretNotFound when nothing is stored under name.
+	Get(ctx context.Context, name string) ([]byte, error)
+	// Delete succeeds when nothing is stored under name.
+	Delete(ctx context.Context, name string) error
+}
+
+type ErrSecretNotFound struct {
+	Backend string
+	Name    string
+}
+
+func (e ErrSecretNotFound) Error() string {
+	return fmt.Sprintf("%s has no secret %s", e.Backend, e.Name)
+}
+
+// backendName is the name a user's key for provider is kept under in a backend. It carries a hash
+// of the user rather than their name.
+func backendName(user, provider string) string {
+	return userHash(user) + "/" + provider
+}
+
+func userHash(user string) string {
+	sum := sha256.Sum256([]byte(user))
+	return hex.EncodeToString(sum[:10])
+}
+
+// MemoryBackend keeps sealed keys in process memory, for tests.
+type MemoryBackend struct {
+	name    string
+	mu      sync.RWMutex
+	secrets map[string][]byte
+}
+
+func NewMemoryBackend(name string) *MemoryBackend {
+	return &MemoryBackend{name: name, secrets: make(map[string][]byte)}
+}
+
+func (b *MemoryBackend) Name() string {
+	return b.name
+}
+
+func (b *MemoryBackend) Put(ctx context.Context, name string, sealed []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.secrets[name] = append([]byte(nil), sealed...)
+	return nil
+}
+
+func (b *MemoryBackend) Get(ctx context.Context, name string) ([]byte, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	sealed, ok := b.secrets[name]
+	if !ok {
+		return nil, ErrSecretNotFound{Backend: b.name, Name: name}
+	}
+	return sealed, nil
+}
+
+func (b *MemoryBackend) Delete(ctx context.Context, name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.secrets, name)
+	return nil
+}
@@ -0,0 +1,180 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/attachments"
+)
+
+// fakeVault serves a KV version 2 engine at secret/ and Kubernetes logins issuing renewable
+// tokens with a one minute lease.
+type fakeVault struct {
+	mu      sync.Mutex
+	secrets map[string][]byte
+	logins  int
+	renews  int
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	auth := func(token string) {
+		json.NewEncoder(w).Encode(map[string]any{"auth": map[string]any{"client_token": token, "lease_duration": 60, "renewable": true}})
+	}
+	switch {
+	case r.URL.Path == "/v1/auth/kubernetes/login":
+		v.logins++
+		auth(fmt.Sprint("token-", v.logins))
+		return
+	case r.URL.Path == "/v1/auth/token/renew-self":
+		v.renews++
+		auth(r.Header.Get("X-Vault-Token"))
+		return
+	case !strings.HasPrefix(r.Header.Get("X-Vault-Token"), "token-"):
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/v1/secret/data/"), "/v1/secret/metadata/")
+	switch r.Method {
+	case http.MethodPost:
+		var body struct {
+			Data struct {
+				Sealed []byte `json:"sealed"`
+			} `json:"data"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		v.secrets[name] = body.Data.Sealed
+	case http.MethodGet:
+		sealed, ok := v.secrets[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"data": map[string][]byte{"sealed": sealed}}})
+	case http.MethodDelete:
+		delete(v.secrets, name)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestVaultBackendRenewsItsLoginLease(t *testing.T) {
+	vault := &fakeVault{secrets: map[string][]byte{}}
+	server := httptest.NewServer(vault)
+	defer server.Close()
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(tokenFile, []byte("service-account-jwt\n"), 0o600)
+	backend, err := NewVaultBackend(VaultConfig{Address: server.URL, Path: "secret/kubechat/user-keys", Role: "kubechat", TokenFile: tokenFile}, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	backend.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if err := backend.Put(ctx, "abc/openai", []byte("sealed")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := vault.secrets["kubechat/user-keys/abc/openai"]; !ok {
+		t.Fatalf("expected the key under the prefix, got %v", vault.secrets)
+	}
+	if sealed, err := backend.Get(ctx, "abc/openai"); err != nil || string(sealed) != "sealed" {
+		t.Fatalf("expected the sealed key back, got %q: %v", sealed, err)
+	}
+	var missing ErrSecretNotFound
+	if _, err := backend.Get(ctx, "abc/gemini"); !errors.As(err, &missing) {
+		t.Fatalf("expected a missing key to be reported, got %v", err)
+	}
+
+	now = now.Add(50 * time.Second)
+	backend.Delete(ctx, "abc/openai")
+	if vault.logins != 1 || vault.renews != 1 || len(vault.secrets) != 0 {
+		t.Fatalf("expected the lease to be renewed rather than logging in again, got %d logins and %d renewals", vault.logins, vault.renews)
+	}
+	now = now.Add(5 * time.Minute)
+	backend.Get(ctx, "abc/openai")
+	if vault.logins != 2 {
+		t.Fatalf("expected an expired lease to log in again, got %d logins", vault.logins)
+	}
+}
+
+func TestAWSSecretsBackendCreatesSecretsOnFirstPut(t *testing.T) {
+	secrets := map[string][]byte{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var input struct {
+			SecretId     string
+			Name         string
+			SecretBinary []byte
+		}
+		json.NewDecoder(r.Body).Decode(&input)
+		notFound := func() {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"com.amazonaws.secretsmanager#ResourceNotFoundException","message":"not found"}`))
+		}
+		switch r.Header.Get("X-Amz-Target") {
+		case "secretsmanager.PutSecretValue":
+			if _, ok := secrets[input.SecretId]; !ok {
+				notFound()
+				return
+			}
+			secrets[input.SecretId] = input.SecretBinary
+		case "secretsmanager.CreateSecret":
+			secrets[input.Name] = input.SecretBinary
+		case "secretsmanager.GetSecretValue":
+			sealed, ok := secrets[input.SecretId]
+			if !ok {
+				notFound()
+				return
+			}
+			json.NewEncoder(w).Encode(map[string][]byte{"SecretBinary": sealed})
+			return
+		case "secretsmanager.DeleteSecret":
+			delete(secrets, input.SecretId)
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	backend, err := NewAWSSecretsBackend(AWSSecretsConfig{
+		Region:      "eu-west-1",
+		Prefix:      "kubechat/user-keys/",
+		Endpoint:    server.URL,
+		Credentials: attachments.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+	}, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	backend.Put(ctx, "abc/openai", []byte("first"))
+	if err := backend.Put(ctx, "abc/openai", []byte("second")); err != nil {
+		t.Fatal(err)
+	}
+	if sealed, err := backend.Get(ctx, "abc/openai"); err != nil || string(sealed) != "second" {
+		t.Fatalf("expected the latest version, got %q: %v", sealed, err)
+	}
+	if err := backend.Delete(ctx, "abc/openai"); err != nil || len(secrets) != 0 {
+		t.Fatalf("expected the secret to be deleted, got %v: %v", secrets, err)
+	}
+	var missing ErrSecretNotFound
+	if _, err := backend.Get(ctx, "abc/openai"); !errors.As(err, &missing) {
+		t.Fatalf("expected a missing secret to be reported, got %v", err)
+	}
+	if err := backend.Delete(ctx, "abc/openai"); err != nil {
+		t.Fatalf("expected deleting a missing secret to succeed, got %v", err)
+	}
+}
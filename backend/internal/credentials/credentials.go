@@ -0,0 +1,115 @@
+// Package credentials keeps the model provider API keys users bring for themselves, sealed with
+// a key only KubeChat holds, and builds the providers that call with them.
+package credentials
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Providers users may bring their own keys for.
+const (
+	ProviderAnthropic = "anthropic"
+	ProviderOpenAI    = "openai"
+	ProviderGemini    = "gemini"
+)
+
+var providers = []string{ProviderAnthropic, ProviderOpenAI, ProviderGemini}
+
+// Key describes a user's own API key. The key itself never leaves the store unsealed except to
+// call its provider.
+type Key struct {
+	Provider string `json:"provider"`
+	// Model overrides the provider's default model for the user.
+	Model string `json:"model,omitempty"`
+	// Hint is the end of the key, so the user can tell which key is stored.
+	Hint string `json:"hint"`
+	// Backend is where the sealed key is kept.
+	Backend   string    `json:"backend"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// KeyRequest stores a user's own key for a provider, replacing any earlier one.
+type KeyRequest struct {
+	APIKey string `json:"apiKey"`
+	Model  string `json:"model,omitempty"`
+	// Backend is where to keep the key; the configured default when empty.
+	Backend string `json:"backend,omitempty"`
+}
+
+// Sealed is a key as stored: its description and the key encrypted for its user and provider.
+// Ciphertext is empty when the sealed key is kept in a backend other than the keyring. Keys
+// sealed before envelope encryption have no Envelope and open with KubeChat's own key.
+type Sealed struct {
+	Key
+	Ciphertext []byte    `json:"ciphertext,omitempty"`
+	Envelope   *Envelope `json:"envelope,omitempty"`
+}
+
+// backend is where the sealed key is kept; keyrings stored before backends existed hold every key.
+func (s Sealed) backend() string {
+	if s.Backend == "" {
+		return BackendKeyring
+	}
+	return s.Backend
+}
+
+// Keyring is everything stored for one user, in the order the keys are tried.
+type Keyring struct {
+	User string   `json:"user"`
+	Keys []Sealed `json:"keys"`
+}
+
+func (k Keyring) index(provider string) int {
+	return slices.IndexFunc(k.Keys, func(sealed Sealed) bool { return sealed.Provider == provider })
+}
+
+type ErrKeyNotFound struct {
+	User     string
+	Provider string
+}
+
+func (e ErrKeyNotFound) Error() string {
+	return fmt.Sprintf("%s has no %s key", e.User, e.Provider)
+}
+
+// MigrationRequest moves every key kept in one backend to another.
+type MigrationRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Migration is the outcome of a MigrationRequest. Keys that failed to move stay where they were.
+type Migration struct {
+	From     string       `json:"from"`
+	To       string       `json:"to"`
+	Keyrings int          `json:"keyrings"`
+	Moved    int          `json:"moved"`
+	Failed   []KeyFailure `json:"failed,omitempty"`
+}
+
+// Rewrap is the outcome of re-wrapping data keys with the current version of the KMS key.
+type Rewrap struct {
+	KMS        string       `json:"kms"`
+	KeyVersion string       `json:"keyVersion"`
+	Keyrings   int          `json:"keyrings"`
+	Rewrapped  int          `json:"rewrapped"`
+	Failed     []KeyFailure `json:"failed,omitempty"`
+}
+
+// KeyFailure is a key a migration or re-wrap could not handle.
+type KeyFailure struct {
+	User     string `json:"user"`
+	Provider string `json:"provider"`
+	Error    string `json:"error"`
+}
+
+type ErrInvalidRequest struct {
+	Reason string
+}
+
+func (e ErrInvalidRequest) Error() string {
+	return e.Reason
+}
@@ -0,0 +1,152 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultGCPTokenURL is where workloads on Google Cloud get access tokens of their service account.
+const DefaultGCPTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+type GCPKMSConfig struct {
+	// Key is the resource name of the key, such as
+	// projects/p/locations/global/keyRings/kubechat/cryptoKeys/user-keys.
+	Key string
+	// Endpoint is the Cloud KMS API, https://cloudkms.googleapis.com by default.
+	Endpoint string
+	// TokenURL serves access tokens, DefaultGCPTokenURL by default.
+	TokenURL string
+}
+
+// GCPKMS wraps data keys with a key of Cloud KMS. The version is the key version that wrapped a
+// data key, so data keys are re-wrapped after the primary version is rotated.
+type GCPKMS struct {
+	cfg    GCPKMSConfig
+	client *http.Client
+	now    func() time.Time
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+func NewGCPKMS(cfg GCPKMSConfig, client *http.Client) (*GCPKMS, error) {
+	if !strings.Contains(cfg.Key, "/cryptoKeys/") {
+		return nil, ErrInvalidRequest{Reason: "gcp kms requires the resource name of a key"}
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://cloudkms.googleapis.com"
+	}
+	if cfg.TokenURL == "" {
+		cfg.TokenURL = DefaultGCPTokenURL
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+	return &GCPKMS{cfg: cfg, client: client, now: time.Now}, nil
+}
+
+func (k *GCPKMS) Name() string {
+	return KMSGCP
+}
+
+func (k *GCPKMS) Wrap(ctx context.Context, dataKey []byte) ([]byte, string, error) {
+	var out struct {
+		Name       string `json:"name"`
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	err := k.call(ctx, http.MethodPost, k.cfg.Key+":encrypt", map[string][]byte{"plaintext": dataKey}, &out)
+	return out.Ciphertext, out.Name, err
+}
+
+// Unwrap decrypts with the key rather than the version; Cloud KMS finds the version in the
+// ciphertext.
+func (k *GCPKMS) Unwrap(ctx context.Context, wrapped []byte, version string) ([]byte, error) {
+	var out struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+	err := k.call(ctx, http.MethodPost, k.cfg.Key+":decrypt", map[string][]byte{"ciphertext": wrapped}, &out)
+	return out.Plaintext, err
+}
+
+func (k *GCPKMS) CurrentVersion(ctx context.Context) (string, error) {
+	var out struct {
+		Primary struct {
+			Name string `json:"name"`
+		} `json:"primary"`
+	}
+	err := k.call(ctx, http.MethodGet, k.cfg.Key, nil, &out)
+	return out.Primary.Name, err
+}
+
+func (k *GCPKMS) call(ctx context.Context, method, resource string, input, out any) error {
+	token, err := k.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	var body io.Reader
+	if input != nil {
+		data, err := json.Marshal(input)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, k.cfg.Endpoint+"/v1/"+resource, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		payload, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("cloud kms %s returned %d: %s", resource, resp.StatusCode, payload)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// accessToken returns a token of the workload's service account, fetched again a minute before
+// it expires.
+func (k *GCPKMS) accessToken(ctx context.Context) (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.token != "" && k.now().Before(k.expiresAt) {
+		return k.token, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.cfg.TokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("access token request returned %d", resp.StatusCode)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	k.token = token.AccessToken
+	k.expiresAt = k.now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return k.token, nil
+}
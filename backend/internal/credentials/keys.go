@@ -0,0 +1,555 @@
+package credentials
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pramodksahoo/kubechat/backend/internal/ai"
+)
+
+// providerTTL bounds how long the providers built from a user's keys are reused, so keys changed
+// on another replica apply within it.
+const providerTTL = time.Minute
+
+// ProviderFunc builds the provider that calls with apiKey and model, an empty model meaning the
+// provider's default.
+type ProviderFunc func(provider, apiKey, model string) ai.Provider
+
+// DefaultProviders builds the hosted providers KubeChat has adapters for.
+func DefaultProviders(provider, apiKey, model string) ai.Provider {
+	switch provider {
+	case ProviderAnthropic:
+		return ai.NewAnthropicProvider("", apiKey, model, nil)
+	case ProviderOpenAI:
+		return ai.NewOpenAIProvider("", apiKey, model, nil)
+	case ProviderGemini:
+		return ai.NewGeminiProvider("", apiKey, model, nil)
+	}
+	return nil
+}
+
+// Keys stores users' own API keys and builds the providers the model chain tries first for them.
+// Each key is kept in the keyring or in one of the configured backends, sealed with a data key of
+// its own that a KMS wraps.
+type Keys struct {
+	store          Store
+	aead           cipher.AEAD
+	kms            KMS
+	unwrappers     map[string]KMS
+	build          ProviderFunc
+	logger         *log.Logger
+	now            func() time.Time
+	backends       map[string]Backend
+	defaultBackend string
+	policies       PolicyStore
+	background     Background
+
+	mu          sync.Mutex
+	cache       map[string]cachedProviders
+	validations map[string]*ValidationJob
+}
+
+type cachedProviders struct {
+	providers []ai.Provider
+	loadedAt  time.Time
+}
+
+// NewKeys wraps data keys with an AES-256 key derived from secret until SetKMS configures an
+// external service.
+func NewKeys(store Store, secret string, build ProviderFunc, logger *log.Logger) (*Keys, error) {
+	if secret == "" {
+		return nil, ErrInvalidRequest{Reason: "an encryption key for user API keys is required"}
+	}
+	if build == nil {
+		build = DefaultProviders
+	}
+	if logger == nil {
+		logger = log.Default()
+	}
+	derived := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(derived[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	local := localKMS{aead: aead}
+	return &Keys{
+		store:          store,
+		aead:           aead,
+		kms:            local,
+		unwrappers:     map[string]KMS{KMSLocal: local},
+		build:          build,
+		logger:         logger,
+		now:            func() time.Time { return time.Now().UTC() },
+		backends:       make(map[string]Backend),
+		defaultBackend: BackendKeyring,
+		policies:       NewMemoryPolicyStore(),
+		cache:          make(map[string]cachedProviders),
+		validations:    make(map[string]*ValidationJob),
+	}, nil
+}
+
+// SetBackends makes backends available for keys and keeps keys stored without a choice in
+// defaultBackend, which is the keyring or one of backends.
+func (k *Keys) SetBackends(defaultBackend string, backends ...Backend) {
+	for _, backend := range backends {
+		k.backends[backend.Name()] = backend
+	}
+	if defaultBackend != "" {
+		k.defaultBackend = defaultBackend
+	}
+}
+
+// SetKMS wraps new data keys with kms, or the local key when it is nil. Data keys wrapped earlier
+// by any of previous, or locally, still unwrap until the re-wrap job has moved them to kms.
+func (k *Keys) SetKMS(kms KMS, previous ...KMS) {
+	if kms != nil {
+		k.kms = kms
+		previous = append(previous, kms)
+	}
+	for _, unwrapper := range previous {
+		k.unwrappers[unwrapper.Name()] = unwrapper
+	}
+}
+
+// Backends lists the backends keys may be kept in, the keyring first.
+func (k *Keys) Backends() []string {
+	names := []string{BackendKeyring}
+	for name := range k.backends {
+		names = append(names, name)
+	}
+	slices.Sort(names[1:])
+	return names
+}
+
+// List describes the keys user stored, in the order they are tried.
+func (k *Keys) List(ctx context.Context, user string) ([]Key, error) {
+	keyring, err := k.store.Get(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]Key, 0, len(keyring.Keys))
+	for _, sealed := range keyring.Keys {
+		key := sealed.Key
+		key.Backend = sealed.backend()
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Put stores user's key for provider after the provider accepted it. A replaced key keeps its
+// place in the order keys are tried.
+func (k *Keys) Put(ctx context.Context, user, provider string, req KeyRequest) (Key, error) {
+	apiKey := strings.TrimSpace(req.APIKey)
+	backend := strings.TrimSpace(req.Backend)
+	if backend == "" {
+		backend = k.defaultBackend
+	}
+	switch {
+	case !slices.Contains(providers, provider):
+		return Key{}, ErrInvalidRequest{Reason: "provider must be one of " + strings.Join(providers, ", ")}
+	case apiKey == "":
+		return Key{}, ErrInvalidRequest{Reason: "apiKey is required"}
+	case !slices.Contains(k.Backends(), backend):
+		return Key{}, ErrInvalidRequest{Reason: "backend must be one of " + strings.Join(k.Backends(), ", ")}
+	}
+	policies, err := k.policies.List(ctx)
+	if err != nil {
+		return Key{}, err
+	}
+	if policy, ok := policyFor(policies, provider); ok {
+		if violations := policy.contentViolations(apiKey); len(violations) > 0 {
+			reasons := make([]string, len(violations))
+			for i, violation := range violations {
+				reasons[i] = violation.Message
+			}
+			return Key{}, ErrInvalidRequest{Reason: "key violates the " + policy.Provider + " policy: " + strings.Join(reasons, "; ")}
+		}
+	}
+	model := strings.TrimSpace(req.Model)
+	if checker, ok := k.build(provider, apiKey, model).(ai.HealthChecker); ok {
+		if err := checker.HealthCheck(ctx); err != nil {
+			var status ai.ErrProviderStatus
+			if errors.As(err, &status) && status.Status < 500 {
+				return Key{}, ErrInvalidRequest{Reason: provider + " did not accept the key: " + err.Error()}
+			}
+			return Key{}, err
+		}
+	}
+
+	keyring, err := k.store.Get(ctx, user)
+	if err != nil {
+		return Key{}, err
+	}
+	now := k.now()
+	key := Key{Provider: provider, Model: model, Hint: hint(apiKey), Backend: backend, CreatedAt: now, UpdatedAt: now}
+	ciphertext, envelope, err := k.seal(ctx, user, provider, apiKey)
+	if err != nil {
+		return Key{}, err
+	}
+	sealed := Sealed{Key: key, Envelope: envelope}
+	if err := k.place(ctx, user, &sealed, ciphertext); err != nil {
+		return Key{}, err
+	}
+	var replaced *Sealed
+	if i := keyring.index(provider); i >= 0 {
+		previous := keyring.Keys[i]
+		replaced = &previous
+		sealed.CreatedAt, key.CreatedAt = previous.CreatedAt, previous.CreatedAt
+		keyring.Keys[i] = sealed
+	} else {
+		keyring.Keys = append(keyring.Keys, sealed)
+	}
+	if err := k.store.Save(ctx, keyring); err != nil {
+		return Key{}, err
+	}
+	k.forget(user)
+	if replaced != nil && replaced.backend() != backend {
+		k.discard(ctx, user, *replaced)
+	}
+	k.logger.Info("user API key stored", "user", user, "provider", provider)
+	return key, nil
+}
+
+// Delete removes user's key for provider.
+func (k *Keys) Delete(ctx context.Context, user, provider string) error {
+	keyring, err := k.store.Get(ctx, user)
+	if err != nil {
+		return err
+	}
+	i := keyring.index(provider)
+	if i < 0 {
+		return ErrKeyNotFound{User: user, Provider: provider}
+	}
+	if err := k.discard(ctx, user, keyring.Keys[i]); err != nil {
+		return err
+	}
+	keyring.Keys = slices.Delete(keyring.Keys, i, i+1)
+	if len(keyring.Keys) == 0 {
+		err = k.store.Delete(ctx, user)
+	} else {
+		err = k.store.Save(ctx, keyring)
+	}
+	if err != nil {
+		return err
+	}
+	k.forget(user)
+	k.logger.Info("user API key removed", "user", user, "provider", provider)
+	return nil
+}
+
+// Forget removes every key user stored and returns how many there were.
+func (k *Keys) Forget(ctx context.Context, user string) (int, error) {
+	keyring, err := k.store.Get(ctx, user)
+	if err != nil || len(keyring.Keys) == 0 {
+		return 0, err
+	}
+	for _, sealed := range keyring.Keys {
+		if err := k.discard(ctx, user, sealed); err != nil {
+			return 0, err
+		}
+	}
+	if err := k.store.Delete(ctx, user); err != nil {
+		return 0, err
+	}
+	k.forget(user)
+	return len(keyring.Keys), nil
+}
+
+// Providers builds the providers calling with user's own keys, for the model chain to try first.
+// Keys that no longer open, such as after the encryption key was changed, are skipped.
+func (k *Keys) Providers(ctx context.Context, user string) ([]ai.Provider, error) {
+	k.mu.Lock()
+	cached, ok := k.cache[user]
+	k.mu.Unlock()
+	if ok && k.now().Sub(cached.loadedAt) < providerTTL {
+		return cached.providers, nil
+	}
+	keyring, err := k.store.Get(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	built := make([]ai.Provider, 0, len(keyring.Keys))
+	for _, sealed := range keyring.Keys {
+		apiKey, err := k.open(ctx, user, sealed)
+		if err != nil {
+			k.logger.Warn("failed to open user API key", "user", user, "provider", sealed.Provider, "error", err)
+			continue
+		}
+		if provider := k.build(sealed.Provider, apiKey, sealed.Model); provider != nil {
+			built = append(built, provider)
+		}
+	}
+	k.mu.Lock()
+	k.cache[user] = cachedProviders{providers: built, loadedAt: k.now()}
+	k.mu.Unlock()
+	return built, nil
+}
+
+// Migrate moves every key kept in req.From to req.To. Each keyring is saved once its keys were
+// copied, and only then are the copies left in req.From deleted.
+func (k *Keys) Migrate(ctx context.Context, req MigrationRequest) (Migration, error) {
+	backends := k.Backends()
+	switch {
+	case !slices.Contains(backends, req.From) || !slices.Contains(backends, req.To):
+		return Migration{}, ErrInvalidRequest{Reason: "from and to must be among the backends " + strings.Join(backends, ", ")}
+	case req.From == req.To:
+		return Migration{}, ErrInvalidRequest{Reason: "from and to must be different backends"}
+	}
+	users, err := k.store.Users(ctx)
+	if err != nil {
+		return Migration{}, err
+	}
+	migration := Migration{From: req.From, To: req.To}
+	fail := func(user, provider string, err error) {
+		migration.Failed = append(migration.Failed, KeyFailure{User: user, Provider: provider, Error: err.Error()})
+	}
+	for _, user := range users {
+		keyring, err := k.store.Get(ctx, user)
+		if err != nil {
+			fail(user, "", err)
+			continue
+		}
+		migration.Keyrings++
+		var moved []Sealed
+		for i, sealed := range keyring.Keys {
+			if sealed.backend() != req.From {
+				continue
+			}
+			ciphertext, err := k.ciphertext(ctx, user, sealed)
+			if err != nil {
+				fail(user, sealed.Provider, err)
+				continue
+			}
+			copied := sealed
+			copied.Backend = req.To
+			if err := k.place(ctx, user, &copied, ciphertext); err != nil {
+				fail(user, sealed.Provider, err)
+				continue
+			}
+			keyring.Keys[i] = copied
+			moved = append(moved, sealed)
+		}
+		if len(moved) == 0 {
+			continue
+		}
+		if err := k.store.Save(ctx, keyring); err != nil {
+			for _, sealed := range moved {
+				fail(user, sealed.Provider, err)
+			}
+			continue
+		}
+		k.forget(user)
+		for _, sealed := range moved {
+			k.discard(ctx, user, sealed)
+		}
+		migration.Moved += len(moved)
+	}
+	k.logger.Info("user API keys migrated", "from", req.From, "to", req.To, "moved", migration.Moved, "failed", len(migration.Failed))
+	return migration, nil
+}
+
+// Rewrap wraps every data key the current KMS key version did not wrap with it, and seals keys
+// stored before envelope encryption with data keys of their own. The sealed keys themselves are
+// untouched unless they had no data key.
+func (k *Keys) Rewrap(ctx context.Context) (Rewrap, error) {
+	version, err := k.kms.CurrentVersion(ctx)
+	if err != nil {
+		return Rewrap{}, fmt.Errorf("current version of %s: %w", k.kms.Name(), err)
+	}
+	users, err := k.store.Users(ctx)
+	if err != nil {
+		return Rewrap{}, err
+	}
+	rewrap := Rewrap{KMS: k.kms.Name(), KeyVersion: version}
+	fail := func(user, provider string, err error) {
+		rewrap.Failed = append(rewrap.Failed, KeyFailure{User: user, Provider: provider, Error: err.Error()})
+	}
+	for _, user := range users {
+		keyring, err := k.store.Get(ctx, user)
+		if err != nil {
+			fail(user, "", err)
+			continue
+		}
+		rewrap.Keyrings++
+		changed := 0
+		for i, sealed := range keyring.Keys {
+			if sealed.Envelope != nil && sealed.Envelope.KMS == rewrap.KMS && sealed.Envelope.KeyVersion == version {
+				continue
+			}
+			if err := k.rewrap(ctx, user, &keyring.Keys[i]); err != nil {
+				fail(user, sealed.Provider, err)
+				continue
+			}
+			changed++
+		}
+		if changed == 0 {
+			continue
+		}
+		if err := k.store.Save(ctx, keyring); err != nil {
+			fail(user, "", err)
+			continue
+		}
+		k.forget(user)
+		rewrap.Rewrapped += changed
+	}
+	if rewrap.Rewrapped > 0 || len(rewrap.Failed) > 0 {
+		k.logger.Info("user API key data keys re-wrapped", "kms", rewrap.KMS, "version", version, "rewrapped", rewrap.Rewrapped, "failed", len(rewrap.Failed))
+	}
+	return rewrap, nil
+}
+
+func (k *Keys) rewrap(ctx context.Context, user string, sealed *Sealed) error {
+	if sealed.Envelope == nil {
+		apiKey, err := k.open(ctx, user, *sealed)
+		if err != nil {
+			return err
+		}
+		ciphertext, envelope, err := k.seal(ctx, user, sealed.Provider, apiKey)
+		if err != nil {
+			return err
+		}
+		sealed.Envelope = envelope
+		return k.place(ctx, user, sealed, ciphertext)
+	}
+	dataKey, err := k.unwrap(ctx, *sealed.Envelope)
+	if err != nil {
+		return err
+	}
+	wrapped, version, err := k.kms.Wrap(ctx, dataKey)
+	if err != nil {
+		return err
+	}
+	sealed.Envelope = &Envelope{KMS: k.kms.Name(), KeyVersion: version, WrappedKey: wrapped}
+	return nil
+}
+
+// Run re-wraps data keys every interval, so they follow rotations of the KMS key.
+func (k *Keys) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := k.Rewrap(ctx); err != nil {
+				k.logger.Error("failed to re-wrap user API key data keys", "error", err)
+			}
+		}
+	}
+}
+
+// place keeps ciphertext in the keyring or in the backend of sealed.
+func (k *Keys) place(ctx context.Context, user string, sealed *Sealed, ciphertext []byte) error {
+	if sealed.backend() == BackendKeyring {
+		sealed.Ciphertext = ciphertext
+		return nil
+	}
+	sealed.Ciphertext = nil
+	return k.backends[sealed.Backend].Put(ctx, backendName(user, sealed.Provider), ciphertext)
+}
+
+func (k *Keys) ciphertext(ctx context.Context, user string, sealed Sealed) ([]byte, error) {
+	if sealed.backend() == BackendKeyring {
+		return sealed.Ciphertext, nil
+	}
+	backend, ok := k.backends[sealed.Backend]
+	if !ok {
+		return nil, fmt.Errorf("backend %s is not configured", sealed.Backend)
+	}
+	return backend.Get(ctx, backendName(user, sealed.Provider))
+}
+
+// discard deletes the sealed key from its backend. Failures are logged and returned; a key left
+// behind is sealed and no longer referred to.
+func (k *Keys) discard(ctx context.Context, user string, sealed Sealed) error {
+	if sealed.backend() == BackendKeyring {
+		return nil
+	}
+	backend, ok := k.backends[sealed.Backend]
+	if !ok {
+		return fmt.Errorf("backend %s is not configured", sealed.Backend)
+	}
+	err := backend.Delete(ctx, backendName(user, sealed.Provider))
+	if err != nil {
+		k.logger.Warn("failed to delete user API key from its backend", "user", user, "provider", sealed.Provider, "backend", sealed.Backend, "error", err)
+	}
+	return err
+}
+
+func (k *Keys) forget(user string) {
+	k.mu.Lock()
+	delete(k.cache, user)
+	k.mu.Unlock()
+}
+
+// seal encrypts apiKey with a new data key, bound to user and provider so a sealed key copied to
+// another user or provider does not open, and wraps the data key with the KMS.
+func (k *Keys) seal(ctx context.Context, user, provider, apiKey string) ([]byte, *Envelope, error) {
+	dataKey, aead, err := newDataKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	wrapped, version, err := k.kms.Wrap(ctx, dataKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("wrap data key with %s: %w", k.kms.Name(), err)
+	}
+	ciphertext := aead.Seal(nonce, nonce, []byte(apiKey), []byte(user+"\x00"+provider))
+	return ciphertext, &Envelope{KMS: k.kms.Name(), KeyVersion: version, WrappedKey: wrapped}, nil
+}
+
+func (k *Keys) open(ctx context.Context, user string, sealed Sealed) (string, error) {
+	ciphertext, err := k.ciphertext(ctx, user, sealed)
+	if err != nil {
+		return "", err
+	}
+	aead := k.aead
+	if sealed.Envelope != nil {
+		dataKey, err := k.unwrap(ctx, *sealed.Envelope)
+		if err != nil {
+			return "", err
+		}
+		if aead, err = dataKeyAEAD(dataKey); err != nil {
+			return "", err
+		}
+	}
+	size := aead.NonceSize()
+	if len(ciphertext) < size {
+		return "", errors.New("sealed key is truncated")
+	}
+	plain, err := aead.Open(nil, ciphertext[:size], ciphertext[size:], []byte(user+"\x00"+sealed.Provider))
+	return string(plain), err
+}
+
+func (k *Keys) unwrap(ctx context.Context, envelope Envelope) ([]byte, error) {
+	kms, ok := k.unwrappers[envelope.KMS]
+	if !ok {
+		return nil, fmt.Errorf("kms %s is not configured", envelope.KMS)
+	}
+	return kms.Unwrap(ctx, envelope.WrappedKey, envelope.KeyVersion)
+}
+
+func hint(apiKey string) string {
+	if len(apiKey) <= 8 {
+		return "…"
+	}
+	return "…" + apiKey[len(apiKey)-4:]
+}
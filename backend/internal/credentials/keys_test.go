@@ -0,0 +1,188 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/ai"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// keyed is a provider that remembers the key it calls with and rejects the key "revoked".
+type keyed struct {
+	name, apiKey, model string
+}
+
+func (p keyed) Name() string { return p.name }
+
+func (p keyed) Complete(ctx context.Context, req ai.CompletionRequest) (ai.CompletionResponse, error) {
+	return ai.CompletionResponse{Provider: p.name, Model: p.model}, nil
+}
+
+func (p keyed) HealthCheck(ctx context.Context) error {
+	if p.apiKey == "revoked" {
+		return ai.ErrProviderStatus{Provider: p.name, Status: http.StatusUnauthorized}
+	}
+	return nil
+}
+
+func newTestKeys(t *testing.T, store Store) *Keys {
+	t.Helper()
+	keys, err := NewKeys(store, "secret", func(provider, apiKey, model string) ai.Provider {
+		return keyed{name: provider, apiKey: apiKey, model: model}
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestKeysSealsKeysAndBuildsProvidersInOrder(t *testing.T) {
+	store := NewMemoryStore()
+	keys := newTestKeys(t, store)
+	ctx := context.Background()
+
+	if _, err := keys.Put(ctx, "alice", ProviderOpenAI, KeyRequest{APIKey: "sk-alice-openai-1234", Model: "gpt-4.1"}); err != nil {
+		t.Fatal(err)
+	}
+	key, err := keys.Put(ctx, "alice", ProviderAnthropic, KeyRequest{APIKey: "sk-ant-alice-5678"})
+	if err != nil || key.Hint != "…5678" {
+		t.Fatalf("expected the key to be described by its end, got %+v: %v", key, err)
+	}
+	keyring, _ := store.Get(ctx, "alice")
+	for _, sealed := range keyring.Keys {
+		if bytes.Contains(sealed.Ciphertext, []byte("sk-")) {
+			t.Fatalf("expected %s's key to be stored sealed", sealed.Provider)
+		}
+	}
+
+	providers, err := keys.Providers(ctx, "alice")
+	if err != nil || len(providers) != 2 {
+		t.Fatalf("expected a provider per key, got %v: %v", providers, err)
+	}
+	if first := providers[0].(keyed); first.name != ProviderOpenAI || first.apiKey != "sk-alice-openai-1234" || first.model != "gpt-4.1" {
+		t.Fatalf("expected the first key stored to be tried first, got %+v", first)
+	}
+	if own, _ := keys.Providers(ctx, "bob"); len(own) != 0 {
+		t.Fatalf("expected no providers for a user without keys, got %v", own)
+	}
+
+	// A keyring copied to another user does not open for them.
+	store.Save(ctx, Keyring{User: "mallory", Keys: keyring.Keys})
+	if stolen, _ := keys.Providers(ctx, "mallory"); len(stolen) != 0 {
+		t.Fatalf("expected sealed keys to open only for their owner, got %v", stolen)
+	}
+}
+
+func TestKeysRejectsKeysTheProviderRefuses(t *testing.T) {
+	keys := newTestKeys(t, NewMemoryStore())
+	ctx := context.Background()
+
+	var invalid ErrInvalidRequest
+	if _, err := keys.Put(ctx, "alice", ProviderOpenAI, KeyRequest{APIKey: "revoked"}); !errors.As(err, &invalid) {
+		t.Fatalf("expected a refused key to be invalid, got %v", err)
+	}
+	if _, err := keys.Put(ctx, "alice", "mistral", KeyRequest{APIKey: "sk-1"}); !errors.As(err, &invalid) {
+		t.Fatalf("expected an unknown provider to be invalid, got %v", err)
+	}
+	if _, err := keys.Put(ctx, "alice", ProviderGemini, KeyRequest{APIKey: "  "}); !errors.As(err, &invalid) {
+		t.Fatalf("expected an empty key to be invalid, got %v", err)
+	}
+	if listed, _ := keys.List(ctx, "alice"); len(listed) != 0 {
+		t.Fatalf("expected nothing to be stored, got %+v", listed)
+	}
+}
+
+func TestKeysDeleteAndForget(t *testing.T) {
+	keys := newTestKeys(t, NewMemoryStore())
+	ctx := context.Background()
+	keys.Put(ctx, "alice", ProviderOpenAI, KeyRequest{APIKey: "sk-alice-openai-1234"})
+	keys.Put(ctx, "alice", ProviderGemini, KeyRequest{APIKey: "alice-gemini-5678"})
+	if providers, _ := keys.Providers(ctx, "alice"); len(providers) != 2 {
+		t.Fatalf("expected both keys to be used, got %v", providers)
+	}
+
+	if err := keys.Delete(ctx, "alice", ProviderOpenAI); err != nil {
+		t.Fatal(err)
+	}
+	if providers, _ := keys.Providers(ctx, "alice"); len(providers) != 1 || providers[0].Name() != ProviderGemini {
+		t.Fatalf("expected a removed key to stop being used at once, got %v", providers)
+	}
+	var notFound ErrKeyNotFound
+	if err := keys.Delete(ctx, "alice", ProviderOpenAI); !errors.As(err, &notFound) {
+		t.Fatalf("expected removing a missing key to fail, got %v", err)
+	}
+	if n, err := keys.Forget(ctx, "alice"); err != nil || n != 1 {
+		t.Fatalf("expected the remaining key to be forgotten, got %d: %v", n, err)
+	}
+	if listed, _ := keys.List(ctx, "alice"); len(listed) != 0 {
+		t.Fatalf("expected no keys after forgetting, got %+v", listed)
+	}
+}
+
+func TestSecretStoreKeepsAKeyringPerUser(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	keys := newTestKeys(t, NewSecretStore(client, "kubechat"))
+	ctx := context.Background()
+
+	keys.Put(ctx, "alice@example.com", ProviderOpenAI, KeyRequest{APIKey: "sk-alice-openai-1234"})
+	keys.Put(ctx, "alice@example.com", ProviderOpenAI, KeyRequest{APIKey: "sk-alice-openai-9999"})
+	secrets, err := client.CoreV1().Secrets("kubechat").List(ctx, metav1.ListOptions{})
+	if err != nil || len(secrets.Items) != 1 {
+		t.Fatalf("expected one Secret for the user, got %v: %v", secrets, err)
+	}
+	if owner := secrets.Items[0].Annotations[userAnnotation]; owner != "alice@example.com" {
+		t.Fatalf("expected the Secret to name its user, got %q", owner)
+	}
+	if listed, _ := keys.List(ctx, "alice@example.com"); len(listed) != 1 || listed[0].Hint != "…9999" {
+		t.Fatalf("expected the replaced key to be stored, got %+v", listed)
+	}
+
+	keys.Delete(ctx, "alice@example.com", ProviderOpenAI)
+	if secrets, _ := client.CoreV1().Secrets("kubechat").List(ctx, metav1.ListOptions{}); len(secrets.Items) != 0 {
+		t.Fatalf("expected the Secret to be deleted with the last key, got %v", secrets.Items)
+	}
+}
+
+func TestKeysMigrateBetweenBackends(t *testing.T) {
+	store := NewMemoryStore()
+	vault := NewMemoryBackend(BackendVault)
+	keys := newTestKeys(t, store)
+	keys.SetBackends(BackendVault, vault)
+	ctx := context.Background()
+
+	keys.Put(ctx, "alice", ProviderOpenAI, KeyRequest{APIKey: "sk-alice-openai-1234"})
+	keys.Put(ctx, "alice", ProviderGemini, KeyRequest{APIKey: "alice-gemini-5678", Backend: BackendKeyring})
+	keys.Put(ctx, "bob", ProviderAnthropic, KeyRequest{APIKey: "sk-ant-bob-9012"})
+	keyring, _ := store.Get(ctx, "alice")
+	if keyring.Keys[0].Ciphertext != nil || len(vault.secrets) != 2 {
+		t.Fatalf("expected keys without a choice to be kept in the default backend, got %+v", keyring.Keys)
+	}
+	if _, err := keys.Put(ctx, "alice", ProviderOpenAI, KeyRequest{APIKey: "sk-1", Backend: BackendAWSSecretsManager}); err == nil {
+		t.Fatalf("expected a backend that is not configured to be refused")
+	}
+
+	migration, err := keys.Migrate(ctx, MigrationRequest{From: BackendVault, To: BackendKeyring})
+	if err != nil || migration.Keyrings != 2 || migration.Moved != 2 || len(migration.Failed) != 0 {
+		t.Fatalf("unexpected migration %+v: %v", migration, err)
+	}
+	if len(vault.secrets) != 0 {
+		t.Fatalf("expected the migrated keys to be deleted from vault, got %v", vault.secrets)
+	}
+	listed, _ := keys.List(ctx, "alice")
+	if listed[0].Provider != ProviderOpenAI || listed[0].Backend != BackendKeyring {
+		t.Fatalf("expected the key to keep its place in the keyring, got %+v", listed)
+	}
+	if providers, _ := keys.Providers(ctx, "bob"); len(providers) != 1 || providers[0].(keyed).apiKey != "sk-ant-bob-9012" {
+		t.Fatalf("expected the migrated key to open, got %v", providers)
+	}
+
+	var invalid ErrInvalidRequest
+	if _, err := keys.Migrate(ctx, MigrationRequest{From: BackendKeyring, To: BackendKeyring}); !errors.As(err, &invalid) {
+		t.Fatalf("expected a migration onto the same backend to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package credentials
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+)
+
+// Key management services data keys may be wrapped with.
+const (
+	KMSLocal        = "local"
+	KMSAWS          = "aws-kms"
+	KMSGCP          = "gcp-kms"
+	KMSVaultTransit = "vault-transit"
+)
+
+// KMS wraps the data keys user keys are sealed with. The key wrapping them never leaves the
+// service, except for the local one derived from KubeChat's own secret.
+type KMS interface {
+	Name() string
+	// Wrap encrypts dataKey with the current version of the key and returns that version.
+	Wrap(ctx context.Context, dataKey []byte) (wrapped []byte, version string, err error)
+	Unwrap(ctx context.Context, wrapped []byte, version string) ([]byte, error)
+	// CurrentVersion is the version Wrap uses now. Data keys wrapped with another are re-wrapped.
+	CurrentVersion(ctx context.Context) (string, error)
+}
+
+// Envelope is how the data key of a sealed key was wrapped.
+type Envelope struct {
+	KMS        string `json:"kms"`
+	KeyVersion string `json:"keyVersion"`
+	WrappedKey []byte `json:"wrappedKey"`
+}
+
+// localKMS wraps data keys with the key derived from KubeChat's secret. It is used when no
+// external service is configured, and keeps data keys of each sealed key apart all the same.
+type localKMS struct {
+	aead cipher.AEAD
+}
+
+func (l localKMS) Name() string {
+	return KMSLocal
+}
+
+func (l localKMS) Wrap(ctx context.Context, dataKey []byte) ([]byte, string, error) {
+	nonce := make([]byte, l.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, "", err
+	}
+	return l.aead.Seal(nonce, nonce, dataKey, []byte(KMSLocal)), "1", nil
+}
+
+func (l localKMS) Unwrap(ctx context.Context, wrapped []byte, version string) ([]byte, error) {
+	size := l.aead.NonceSize()
+	if len(wrapped) < size {
+		return nil, errors.New("wrapped data key is truncated")
+	}
+	return l.aead.Open(nil, wrapped[:size], wrapped[size:], []byte(KMSLocal))
+}
+
+func (l localKMS) CurrentVersion(ctx context.Context) (string, error) {
+	return "1", nil
+}
+
+// newDataKey returns a fresh AES-256 key and the AEAD sealing with it.
+func newDataKey() ([]byte, cipher.AEAD, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, err
+	}
+	aead, err := dataKeyAEAD(dataKey)
+	return dataKey, aead, err
+}
+
+func dataKeyAEAD(dataKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,141 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// rotatingKMS wraps data keys by prefixing them with the current version, which tests rotate.
+type rotatingKMS struct {
+	version string
+	wraps   int
+}
+
+func (r *rotatingKMS) Name() string { return KMSAWS }
+
+func (r *rotatingKMS) Wrap(ctx context.Context, dataKey []byte) ([]byte, string, error) {
+	r.wraps++
+	return append([]byte(r.version+":"), dataKey...), r.version, nil
+}
+
+func (r *rotatingKMS) Unwrap(ctx context.Context, wrapped []byte, version string) ([]byte, error) {
+	if !bytes.HasPrefix(wrapped, []byte(version+":")) {
+		return nil, fmt.Errorf("not wrapped with %s", version)
+	}
+	return wrapped[len(version)+1:], nil
+}
+
+func (r *rotatingKMS) CurrentVersion(ctx context.Context) (string, error) {
+	return r.version, nil
+}
+
+func TestKeysWrapsDataKeysWithTheKMSAndRewrapsAfterRotation(t *testing.T) {
+	store := NewMemoryStore()
+	keys := newTestKeys(t, store)
+	ctx := context.Background()
+
+	// A key sealed before envelope encryption, directly with the local key.
+	nonce := make([]byte, keys.aead.NonceSize())
+	legacy := keys.aead.Seal(nonce, nonce, []byte("sk-ant-alice-5678"), []byte("alice\x00"+ProviderAnthropic))
+	store.Save(ctx, Keyring{User: "alice", Keys: []Sealed{{Key: Key{Provider: ProviderAnthropic, Hint: "…5678"}, Ciphertext: legacy}}})
+	keys.Put(ctx, "alice", ProviderOpenAI, KeyRequest{APIKey: "sk-alice-openai-1234"})
+
+	kms := &rotatingKMS{version: "v1"}
+	keys.SetKMS(kms)
+	keys.Put(ctx, "bob", ProviderOpenAI, KeyRequest{APIKey: "sk-bob-openai-9012"})
+	keyring, _ := store.Get(ctx, "bob")
+	if envelope := keyring.Keys[0].Envelope; envelope == nil || envelope.KMS != KMSAWS || envelope.KeyVersion != "v1" {
+		t.Fatalf("expected the data key to be wrapped by the KMS, got %+v", envelope)
+	}
+
+	kms.version = "v2"
+	rewrap, err := keys.Rewrap(ctx)
+	if err != nil || rewrap.Keyrings != 2 || rewrap.Rewrapped != 3 || len(rewrap.Failed) != 0 {
+		t.Fatalf("unexpected re-wrap %+v: %v", rewrap, err)
+	}
+	keyring, _ = store.Get(ctx, "alice")
+	for _, sealed := range keyring.Keys {
+		if sealed.Envelope == nil || sealed.Envelope.KMS != KMSAWS || sealed.Envelope.KeyVersion != "v2" {
+			t.Fatalf("expected %s's data key to be wrapped with the current version, got %+v", sealed.Provider, sealed.Envelope)
+		}
+	}
+	keys.forget("alice")
+	providers, err := keys.Providers(ctx, "alice")
+	if err != nil || len(providers) != 2 || providers[0].(keyed).apiKey != "sk-ant-alice-5678" || providers[1].(keyed).apiKey != "sk-alice-openai-1234" {
+		t.Fatalf("expected re-wrapped keys to open, got %v: %v", providers, err)
+	}
+
+	wraps := kms.wraps
+	if rewrap, _ := keys.Rewrap(ctx); rewrap.Rewrapped != 0 || kms.wraps != wraps {
+		t.Fatalf("expected data keys wrapped with the current version to be left alone, got %+v", rewrap)
+	}
+}
+
+// fakeTransit serves a transit engine at transit/ whose ciphertexts are the base64 plaintext
+// behind the key version.
+type fakeTransit struct {
+	mu      sync.Mutex
+	version int
+}
+
+func (f *fakeTransit) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("X-Vault-Token") != "root" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	var body map[string]string
+	json.NewDecoder(r.Body).Decode(&body)
+	var data map[string]any
+	switch r.URL.Path {
+	case "/v1/transit/keys/kubechat":
+		data = map[string]any{"latest_version": f.version}
+	case "/v1/transit/encrypt/kubechat":
+		data = map[string]any{"ciphertext": fmt.Sprintf("vault:v%d:%s", f.version, body["plaintext"])}
+	case "/v1/transit/decrypt/kubechat":
+		parts := strings.SplitN(body["ciphertext"], ":", 3)
+		data = map[string]any{"plaintext": parts[2]}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"data": data})
+}
+
+func TestVaultTransitReportsTheVersionOfItsKey(t *testing.T) {
+	transit := &fakeTransit{version: 3}
+	server := httptest.NewServer(transit)
+	defer server.Close()
+	kms, err := NewVaultTransit(VaultConfig{Address: server.URL, Path: "transit/kubechat", Token: "root"}, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	wrapped, version, err := kms.Wrap(ctx, []byte("data-key"))
+	if err != nil || version != "v3" || !strings.HasPrefix(string(wrapped), "vault:v3:") {
+		t.Fatalf("expected the data key to be wrapped with v3, got %q %q: %v", wrapped, version, err)
+	}
+	if current, _ := kms.CurrentVersion(ctx); current != version {
+		t.Fatalf("expected the current version to match the one wrapping, got %q", current)
+	}
+	dataKey, err := kms.Unwrap(ctx, wrapped, version)
+	if err != nil || string(dataKey) != "data-key" {
+		t.Fatalf("expected the data key back, got %q: %v", dataKey, err)
+	}
+	transit.version = 4
+	if current, _ := kms.CurrentVersion(ctx); current != "v4" {
+		t.Fatalf("expected the rotated version, got %q", current)
+	}
+	if _, err := NewVaultTransit(VaultConfig{Address: server.URL, Path: "transit", Token: "root"}, nil); err == nil {
+		t.Fatalf("expected a path without a key name to be refused")
+	}
+}
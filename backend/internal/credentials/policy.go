@@ -0,0 +1,344 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// AnyProvider is the provider of the policy applying to providers without one of their own.
+const AnyProvider = "*"
+
+// Rules a policy may set.
+const (
+	RuleMinLength        = "min_length"
+	RuleMinEntropy       = "min_entropy"
+	RuleForbiddenPrefix  = "forbidden_prefix"
+	RuleMaxAge           = "max_age"
+	RuleRotationInterval = "rotation_interval"
+)
+
+// Severities of violations. Keys with errors are refused when stored; warnings only ask for
+// rotation.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Policy is what a provider's keys must comply with. Zero values leave a rule out.
+type Policy struct {
+	Provider  string `json:"provider"`
+	MinLength int    `json:"minLength,omitempty"`
+	// MinEntropyBits is the least Shannon entropy of the whole key.
+	MinEntropyBits float64 `json:"minEntropyBits,omitempty"`
+	// ForbiddenPrefixes refuses keys such as test or restricted keys by how they start.
+	ForbiddenPrefixes []string `json:"forbiddenPrefixes,omitempty"`
+	// MaxAgeDays is when a key stops complying and has to be replaced.
+	MaxAgeDays int `json:"maxAgeDays,omitempty"`
+	// RotationDays is when a key should be rotated, before MaxAgeDays.
+	RotationDays int       `json:"rotationDays,omitempty"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// Violation is a rule a key breaks and what its owner has to do about it.
+type Violation struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Action   string `json:"action"`
+}
+
+func (p Policy) validate() error {
+	switch {
+	case p.Provider != AnyProvider && !slices.Contains(providers, p.Provider):
+		return ErrInvalidRequest{Reason: "provider must be * or one of " + strings.Join(providers, ", ")}
+	case p.MinLength < 0 || p.MinEntropyBits < 0 || p.MaxAgeDays < 0 || p.RotationDays < 0:
+		return ErrInvalidRequest{Reason: "policy limits must not be negative"}
+	case p.MaxAgeDays > 0 && p.RotationDays > p.MaxAgeDays:
+		return ErrInvalidRequest{Reason: "rotationDays must not exceed maxAgeDays"}
+	case slices.Contains(p.ForbiddenPrefixes, ""):
+		return ErrInvalidRequest{Reason: "forbidden prefixes must not be empty"}
+	}
+	return nil
+}
+
+// contentViolations are the rules apiKey itself breaks.
+func (p Policy) contentViolations(apiKey string) []Violation {
+	var violations []Violation
+	if p.MinLength > 0 && len(apiKey) < p.MinLength {
+		violations = append(violations, Violation{
+			Rule: RuleMinLength, Severity: SeverityError,
+			Message: fmt.Sprintf("key has %d characters, fewer than %d", len(apiKey), p.MinLength),
+			Action:  "replace the key with a full-length key from " + p.Provider,
+		})
+	}
+	if bits := entropyBits(apiKey); p.MinEntropyBits > 0 && bits < p.MinEntropyBits {
+		violations = append(violations, Violation{
+			Rule: RuleMinEntropy, Severity: SeverityError,
+			Message: fmt.Sprintf("key has %.0f bits of entropy, fewer than %.0f", bits, p.MinEntropyBits),
+			Action:  "replace the key with one generated by the provider",
+		})
+	}
+	for _, prefix := range p.ForbiddenPrefixes {
+		if strings.HasPrefix(apiKey, prefix) {
+			violations = append(violations, Violation{
+				Rule: RuleForbiddenPrefix, Severity: SeverityError,
+				Message: fmt.Sprintf("key starts with the forbidden prefix %q", prefix),
+				Action:  "replace the key with a key of another kind",
+			})
+			break
+		}
+	}
+	return violations
+}
+
+// ageViolations are the rules key breaks by not having been replaced since updatedAt.
+func (p Policy) ageViolations(updatedAt, now time.Time) []Violation {
+	age := now.Sub(updatedAt)
+	days := int(age / (24 * time.Hour))
+	switch {
+	case p.MaxAgeDays > 0 && age > time.Duration(p.MaxAgeDays)*24*time.Hour:
+		return []Violation{{
+			Rule: RuleMaxAge, Severity: SeverityError,
+			Message: fmt.Sprintf("key is %d days old, older than %d", days, p.MaxAgeDays),
+			Action:  "replace the key now",
+		}}
+	case p.RotationDays > 0 && age > time.Duration(p.RotationDays)*24*time.Hour:
+		return []Violation{{
+			Rule: RuleRotationInterval, Severity: SeverityWarning,
+			Message: fmt.Sprintf("key is %d days old and due for rotation every %d", days, p.RotationDays),
+			Action:  "rotate the key",
+		}}
+	}
+	return nil
+}
+
+// entropyBits is the Shannon entropy of s's characters times its length.
+func entropyBits(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := map[rune]int{}
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	var perChar float64
+	for _, count := range counts {
+		p := float64(count) / float64(n)
+		perChar -= p * math.Log2(p)
+	}
+	return perChar * float64(n)
+}
+
+// policyFor picks provider's policy, or the one for any provider.
+func policyFor(policies []Policy, provider string) (Policy, bool) {
+	fallback := -1
+	for i, policy := range policies {
+		switch policy.Provider {
+		case provider:
+			return policy, true
+		case AnyProvider:
+			fallback = i
+		}
+	}
+	if fallback < 0 {
+		return Policy{}, false
+	}
+	return policies[fallback], true
+}
+
+// SetPolicies keeps policies in store rather than in memory.
+func (k *Keys) SetPolicies(store PolicyStore) {
+	k.policies = store
+}
+
+func (k *Keys) Policies(ctx context.Context) ([]Policy, error) {
+	return k.policies.List(ctx)
+}
+
+// PutPolicy replaces provider's policy. Keys stored earlier are not refused, but validations
+// report what they violate.
+func (k *Keys) PutPolicy(ctx context.Context, provider string, policy Policy) (Policy, error) {
+	policy.Provider = provider
+	policy.UpdatedAt = k.now()
+	if err := policy.validate(); err != nil {
+		return Policy{}, err
+	}
+	if err := k.policies.Save(ctx, policy); err != nil {
+		return Policy{}, err
+	}
+	k.logger.Info("user API key policy stored", "provider", provider)
+	return policy, nil
+}
+
+func (k *Keys) DeletePolicy(ctx context.Context, provider string) error {
+	if err := k.policies.Delete(ctx, provider); err != nil {
+		return err
+	}
+	k.logger.Info("user API key policy removed", "provider", provider)
+	return nil
+}
+
+type ErrPolicyNotFound struct {
+	Provider string
+}
+
+func (e ErrPolicyNotFound) Error() string {
+	return fmt.Sprintf("no policy for %s", e.Provider)
+}
+
+// PolicyStore persists policies, one per provider.
+type PolicyStore interface {
+	List(ctx context.Context) ([]Policy, error)
+	Save(ctx context.Context, policy Policy) error
+	// Delete returns ErrPolicyNotFound when provider has no policy.
+	Delete(ctx context.Context, provider string) error
+}
+
+// MemoryPolicyStore keeps policies in process memory; it is used when no namespace is configured
+// for the ConfigMap.
+type MemoryPolicyStore struct {
+	mu       sync.RWMutex
+	policies map[string]Policy
+}
+
+func NewMemoryPolicyStore() *MemoryPolicyStore {
+	return &MemoryPolicyStore{policies: make(map[string]Policy)}
+}
+
+func (s *MemoryPolicyStore) List(ctx context.Context) ([]Policy, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedPolicies(s.policies), nil
+}
+
+func (s *MemoryPolicyStore) Save(ctx context.Context, policy Policy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policies[policy.Provider] = policy
+	return nil
+}
+
+func (s *MemoryPolicyStore) Delete(ctx context.Context, provider string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.policies[provider]; !ok {
+		return ErrPolicyNotFound{Provider: provider}
+	}
+	delete(s.policies, provider)
+	return nil
+}
+
+func sortedPolicies(policies map[string]Policy) []Policy {
+	sorted := make([]Policy, 0, len(policies))
+	for _, policy := range policies {
+		sorted = append(sorted, policy)
+	}
+	slices.SortFunc(sorted, func(a, b Policy) int { return strings.Compare(a.Provider, b.Provider) })
+	return sorted
+}
+
+// PolicyConfigMap holds the policies in the user keys namespace, as a JSON document per provider
+// so operators can also edit them with kubectl.
+const PolicyConfigMap = "kubechat-user-key-policies"
+
+// ConfigMapPolicyStore keeps policies in the PolicyConfigMap of namespace.
+type ConfigMapPolicyStore struct {
+	client    kubernetes.Interface
+	namespace string
+}
+
+func NewConfigMapPolicyStore(client kubernetes.Interface, namespace string) *ConfigMapPolicyStore {
+	return &ConfigMapPolicyStore{client: client, namespace: namespace}
+}
+
+// policyKey names a provider's entry; ConfigMap keys cannot hold *.
+func policyKey(provider string) string {
+	if provider == AnyProvider {
+		provider = "default"
+	}
+	return provider + ".json"
+}
+
+// List fails on an entry that does not parse, so a typo never silently drops a policy.
+func (s *ConfigMapPolicyStore) List(ctx context.Context) ([]Policy, error) {
+	configMap, err := s.client.CoreV1().ConfigMaps(s.namespace).Get(ctx, PolicyConfigMap, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return []Policy{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	policies := make(map[string]Policy, len(configMap.Data))
+	for key, data := range configMap.Data {
+		var policy Policy
+		if err := json.Unmarshal([]byte(data), &policy); err != nil {
+			return nil, fmt.Errorf("configmap/%s/%s %s: %w", s.namespace, PolicyConfigMap, key, err)
+		}
+		if err := policy.validate(); err != nil {
+			return nil, fmt.Errorf("configmap/%s/%s %s: %w", s.namespace, PolicyConfigMap, key, err)
+		}
+		policies[policy.Provider] = policy
+	}
+	return sortedPolicies(policies), nil
+}
+
+func (s *ConfigMapPolicyStore) Save(ctx context.Context, policy Policy) error {
+	payload, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	return s.update(ctx, func(data map[string]string) error {
+		data[policyKey(policy.Provider)] = string(payload)
+		return nil
+	})
+}
+
+func (s *ConfigMapPolicyStore) Delete(ctx context.Context, provider string) error {
+	return s.update(ctx, func(data map[string]string) error {
+		if _, ok := data[policyKey(provider)]; !ok {
+			return ErrPolicyNotFound{Provider: provider}
+		}
+		delete(data, policyKey(provider))
+		return nil
+	})
+}
+
+func (s *ConfigMapPolicyStore) update(ctx context.Context, change func(data map[string]string) error) error {
+	configMaps := s.client.CoreV1().ConfigMaps(s.namespace)
+	configMap, err := configMaps.Get(ctx, PolicyConfigMap, metav1.GetOptions{})
+	missing := apierrors.IsNotFound(err)
+	if missing {
+		configMap = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:      PolicyConfigMap,
+			Namespace: s.namespace,
+			Labels:    map[string]string{managedByLabel: "kubechat", componentLabel: "user-keys"},
+		}}
+	} else if err != nil {
+		return err
+	}
+	if configMap.Data == nil {
+		configMap.Data = map[string]string{}
+	}
+	if err := change(configMap.Data); err != nil {
+		return err
+	}
+	if missing {
+		_, err = configMaps.Create(ctx, configMap, metav1.CreateOptions{})
+		return err
+	}
+	_, err = configMaps.Update(ctx, configMap, metav1.UpdateOptions{})
+	return err
+}
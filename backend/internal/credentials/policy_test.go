@@ -0,0 +1,79 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestKeysEnforcesPoliciesWhenStoringAndValidating(t *testing.T) {
+	store := NewMemoryStore()
+	keys := newTestKeys(t, store)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	keys.now = func() time.Time { return now.Add(-40 * 24 * time.Hour) }
+	keys.Put(ctx, "alice", ProviderOpenAI, KeyRequest{APIKey: "sk-alice-openai-1234"})
+	keys.Put(ctx, "alice", ProviderGemini, KeyRequest{APIKey: "aaaaaaaaaaaaaaaaaaaa"})
+	keys.now = func() time.Time { return now }
+
+	if _, err := keys.PutPolicy(ctx, AnyProvider, Policy{MinEntropyBits: 40}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.PutPolicy(ctx, ProviderOpenAI, Policy{MinLength: 16, ForbiddenPrefixes: []string{"sk-test-"}, RotationDays: 30, MaxAgeDays: 90}); err != nil {
+		t.Fatal(err)
+	}
+	var invalid ErrInvalidRequest
+	if _, err := keys.PutPolicy(ctx, ProviderOpenAI, Policy{RotationDays: 100, MaxAgeDays: 90}); !errors.As(err, &invalid) {
+		t.Fatalf("expected rotation after the maximum age to be refused, got %v", err)
+	}
+	if _, err := keys.Put(ctx, "bob", ProviderOpenAI, KeyRequest{APIKey: "sk-test-bob-openai-9012"}); !errors.As(err, &invalid) {
+		t.Fatalf("expected a key with a forbidden prefix to be refused, got %v", err)
+	}
+	if _, err := keys.Put(ctx, "bob", ProviderAnthropic, KeyRequest{APIKey: "11111111111111111111"}); !errors.As(err, &invalid) {
+		t.Fatalf("expected the default policy to refuse a low entropy key, got %v", err)
+	}
+
+	job, err := keys.Validate(ctx, ValidationRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := job.Summary.Results
+	openai, gemini := results[0], results[1]
+	if !openai.Compliant || len(openai.Violations) != 1 || openai.Violations[0].Rule != RuleRotationInterval || openai.Strength != StrengthWeak {
+		t.Fatalf("expected the openai key to be due for rotation, got %+v", openai)
+	}
+	if gemini.Compliant || len(gemini.Violations) != 1 || gemini.Violations[0].Rule != RuleMinEntropy || gemini.Violations[0].Action == "" {
+		t.Fatalf("expected the gemini key to break the default policy, got %+v", gemini)
+	}
+	if job.Summary.ComplianceRate != 0.5 {
+		t.Fatalf("expected one key in two to comply, got %v", job.Summary.ComplianceRate)
+	}
+
+	var notFound ErrPolicyNotFound
+	if err := keys.DeletePolicy(ctx, ProviderGemini); !errors.As(err, &notFound) {
+		t.Fatalf("expected removing a missing policy to fail, got %v", err)
+	}
+}
+
+func TestConfigMapPolicyStoreKeepsAnEntryPerProvider(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	store := NewConfigMapPolicyStore(client, "kubechat")
+	ctx := context.Background()
+
+	store.Save(ctx, Policy{Provider: AnyProvider, MinLength: 20})
+	store.Save(ctx, Policy{Provider: ProviderOpenAI, MaxAgeDays: 90})
+	policies, err := store.List(ctx)
+	if err != nil || len(policies) != 2 || policies[0].Provider != AnyProvider || policies[1].MaxAgeDays != 90 {
+		t.Fatalf("expected both policies back, got %+v: %v", policies, err)
+	}
+	if err := store.Delete(ctx, AnyProvider); err != nil {
+		t.Fatal(err)
+	}
+	if policies, _ := store.List(ctx); len(policies) != 1 || policies[0].Provider != ProviderOpenAI {
+		t.Fatalf("expected the default policy to be removed, got %+v", policies)
+	}
+}
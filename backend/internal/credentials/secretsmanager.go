@@ -0,0 +1,92 @@
+package credentials
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/attachments"
+)
+
+type AWSSecretsConfig struct {
+	Region string
+	// Prefix is prepended to the names of the secrets keys are kept in, such as kubechat/user-keys/.
+	Prefix string
+	// Endpoint selects a compatible service; AWS is used when empty.
+	Endpoint    string
+	Credentials attachments.Credentials
+}
+
+// AWSSecretsBackend keeps sealed keys in AWS Secrets Manager, one secret per key.
+type AWSSecretsBackend struct {
+	cfg    AWSSecretsConfig
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewAWSSecretsBackend(cfg AWSSecretsConfig, client *http.Client) (*AWSSecretsBackend, error) {
+	if cfg.Region == "" {
+		return nil, ErrInvalidRequest{Reason: "aws secrets manager backend requires a region"}
+	}
+	if cfg.Credentials.AccessKeyID == "" || cfg.Credentials.SecretAccessKey == "" {
+		return nil, ErrInvalidRequest{Reason: "aws secrets manager backend requires AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY"}
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://secretsmanager." + cfg.Region + ".amazonaws.com"
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+	return &AWSSecretsBackend{cfg: cfg, client: client, now: time.Now}, nil
+}
+
+func (b *AWSSecretsBackend) Name() string {
+	return BackendAWSSecretsManager
+}
+
+type awsTag struct {
+	Key   string
+	Value string
+}
+
+// Put stores a new version of the secret, creating it the first time.
+func (b *AWSSecretsBackend) Put(ctx context.Context, name string, sealed []byte) error {
+	id := b.cfg.Prefix + name
+	err := b.call(ctx, "PutSecretValue", map[string]any{"SecretId": id, "SecretBinary": sealed}, nil)
+	if awsErrorType(err) != "ResourceNotFoundException" {
+		return err
+	}
+	return b.call(ctx, "CreateSecret", map[string]any{
+		"Name":         id,
+		"SecretBinary": sealed,
+		"Description":  "A model provider API key a KubeChat user brought for themselves, sealed by KubeChat",
+		"Tags":         []awsTag{{Key: managedByLabel, Value: "kubechat"}, {Key: componentLabel, Value: "user-keys"}},
+	}, nil)
+}
+
+func (b *AWSSecretsBackend) Get(ctx context.Context, name string) ([]byte, error) {
+	var value struct {
+		SecretBinary []byte `json:"SecretBinary"`
+	}
+	err := b.call(ctx, "GetSecretValue", map[string]any{"SecretId": b.cfg.Prefix + name}, &value)
+	if awsErrorType(err) == "ResourceNotFoundException" {
+		return nil, ErrSecretNotFound{Backend: b.Name(), Name: name}
+	}
+	return value.SecretBinary, err
+}
+
+// Delete removes the secret at once rather than after the recovery window, since the key is
+// removed from the keyring that refers to it.
+func (b *AWSSecretsBackend) Delete(ctx context.Context, name string) error {
+	err := b.call(ctx, "DeleteSecret", map[string]any{"SecretId": b.cfg.Prefix + name, "ForceDeleteWithoutRecovery": true}, nil)
+	if awsErrorType(err) == "ResourceNotFoundException" {
+		return nil
+	}
+	return err
+}
+
+func (b *AWSSecretsBackend) call(ctx context.Context, action string, input, out any) error {
+	return callAWS(ctx, b.client, b.cfg.Endpoint, b.cfg.Region, b.cfg.Credentials, "secretsmanager", "secretsmanager."+action, b.now(), input, out)
+}
@@ -0,0 +1,163 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"slices"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Store persists the keyrings of users.
+type Store interface {
+	// Get returns user's keyring, which is empty when nothing is stored for them.
+	Get(ctx context.Context, user string) (Keyring, error)
+	Save(ctx context.Context, keyring Keyring) error
+	Delete(ctx context.Context, user string) error
+	// Users lists the users who stored keys.
+	Users(ctx context.Context) ([]string, error)
+}
+
+// MemoryStore keeps keyrings in process memory; it is used when no namespace is configured for
+// the Secrets.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	keyrings map[string]Keyring
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keyrings: make(map[string]Keyring)}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, user string) (Keyring, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keyring, ok := s.keyrings[user]
+	if !ok {
+		return Keyring{User: user}, nil
+	}
+	keyring.Keys = append([]Sealed(nil), keyring.Keys...)
+	return keyring, nil
+}
+
+func (s *MemoryStore) Save(ctx context.Context, keyring Keyring) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keyrings[keyring.User] = keyring
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, user string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keyrings, user)
+	return nil
+}
+
+func (s *MemoryStore) Users(ctx context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	users := slices.Collect(maps.Keys(s.keyrings))
+	slices.Sort(users)
+	return users, nil
+}
+
+const (
+	secretPrefix = "kubechat-user-keys-"
+	secretKey    = "keyring.json"
+	// userAnnotation names the user a Secret belongs to; Secret names only carry a hash of it.
+	userAnnotation = "kubechat.io/user"
+	managedByLabel = "app.kubernetes.io/managed-by"
+	componentLabel = "app.kubernetes.io/component"
+)
+
+// SecretStore keeps each user's keyring in a Secret of its own in namespace. The keys in it are
+// already sealed, so reading the Secret does not reveal them.
+type SecretStore struct {
+	client    kubernetes.Interface
+	namespace string
+}
+
+func NewSecretStore(client kubernetes.Interface, namespace string) *SecretStore {
+	return &SecretStore{client: client, namespace: namespace}
+}
+
+func secretName(user string) string {
+	return secretPrefix + userHash(user)
+}
+
+func (s *SecretStore) Get(ctx context.Context, user string) (Keyring, error) {
+	secret, err := s.client.CoreV1().Secrets(s.namespace).Get(ctx, secretName(user), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return Keyring{User: user}, nil
+	}
+	if err != nil {
+		return Keyring{}, err
+	}
+	var keyring Keyring
+	if err := json.Unmarshal(secret.Data[secretKey], &keyring); err != nil {
+		return Keyring{}, err
+	}
+	keyring.User = user
+	return keyring, nil
+}
+
+func (s *SecretStore) Save(ctx context.Context, keyring Keyring) error {
+	payload, err := json.Marshal(keyring)
+	if err != nil {
+		return err
+	}
+	secrets := s.client.CoreV1().Secrets(s.namespace)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        secretName(keyring.User),
+			Namespace:   s.namespace,
+			Labels:      map[string]string{managedByLabel: "kubechat", componentLabel: "user-keys"},
+			Annotations: map[string]string{userAnnotation: keyring.User},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{secretKey: payload},
+	}
+	existing, err := secrets.Get(ctx, secret.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = secrets.Create(ctx, secret, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	secret.ResourceVersion = existing.ResourceVersion
+	_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	return err
+}
+
+// Users reads the users from the annotations of the Secrets, since their names only carry hashes.
+func (s *SecretStore) Users(ctx context.Context) ([]string, error) {
+	secrets, err := s.client.CoreV1().Secrets(s.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: managedByLabel + "=kubechat," + componentLabel + "=user-keys",
+	})
+	if err != nil {
+		return nil, err
+	}
+	users := make([]string, 0, len(secrets.Items))
+	for _, secret := range secrets.Items {
+		if user := secret.Annotations[userAnnotation]; user != "" {
+			users = append(users, user)
+		}
+	}
+	slices.Sort(users)
+	return users, nil
+}
+
+func (s *SecretStore) Delete(ctx context.Context, user string) error {
+	err := s.client.CoreV1().Secrets(s.namespace).Delete(ctx, secretName(user), metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
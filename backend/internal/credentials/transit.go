@@ -0,0 +1,80 @@
+package credentials
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// VaultTransit wraps data keys with a key of Vault's transit secrets engine. Its versions are
+// those Vault prefixes ciphertexts with, such as v2 for vault:v2:...
+type VaultTransit struct {
+	*vaultClient
+	mount string
+	key   string
+}
+
+// NewVaultTransit uses the transit key at cfg.Path, such as transit/kubechat.
+func NewVaultTransit(cfg VaultConfig, client *http.Client) (*VaultTransit, error) {
+	vault, err := newVaultClient(cfg, client)
+	if err != nil {
+		return nil, err
+	}
+	mount, key, _ := strings.Cut(strings.Trim(cfg.Path, "/"), "/")
+	if mount == "" || key == "" {
+		return nil, ErrInvalidRequest{Reason: "vault transit requires the mount and name of its key"}
+	}
+	return &VaultTransit{vaultClient: vault, mount: mount, key: key}, nil
+}
+
+func (t *VaultTransit) Name() string {
+	return KMSVaultTransit
+}
+
+func (t *VaultTransit) Wrap(ctx context.Context, dataKey []byte) ([]byte, string, error) {
+	var out struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+	body := map[string]string{"plaintext": base64.StdEncoding.EncodeToString(dataKey)}
+	if err := t.do(ctx, http.MethodPost, "/v1/"+t.mount+"/encrypt/"+t.key, body, &out); err != nil {
+		return nil, "", err
+	}
+	return []byte(out.Data.Ciphertext), transitVersion(out.Data.Ciphertext), nil
+}
+
+func (t *VaultTransit) Unwrap(ctx context.Context, wrapped []byte, version string) ([]byte, error) {
+	var out struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	body := map[string]string{"ciphertext": string(wrapped)}
+	if err := t.do(ctx, http.MethodPost, "/v1/"+t.mount+"/decrypt/"+t.key, body, &out); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(out.Data.Plaintext)
+}
+
+func (t *VaultTransit) CurrentVersion(ctx context.Context) (string, error) {
+	var out struct {
+		Data struct {
+			LatestVersion int `json:"latest_version"`
+		} `json:"data"`
+	}
+	if err := t.do(ctx, http.MethodGet, "/v1/"+t.mount+"/keys/"+t.key, nil, &out); err != nil {
+		return "", err
+	}
+	return "v" + strconv.Itoa(out.Data.LatestVersion), nil
+}
+
+func transitVersion(ciphertext string) string {
+	parts := strings.SplitN(ciphertext, ":", 3)
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[1]
+}
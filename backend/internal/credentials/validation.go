@@ -0,0 +1,386 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pramodksahoo/kubechat/backend/internal/ai"
+)
+
+const (
+	// validationWorkers bounds how many keys are checked with their providers at once, so a batch
+	// does not trip the providers' rate limits.
+	validationWorkers = 4
+	// asyncValidationKeys is the most keys validated while the request waits; larger batches run
+	// as a job.
+	asyncValidationKeys = 50
+	// validationRetention is how long finished validation jobs can be read.
+	validationRetention = time.Hour
+	// rotateAfter is the age past which a key is reported for rotation.
+	rotateAfter = 90 * 24 * time.Hour
+)
+
+// Outcomes of checking a key with its provider.
+const (
+	ValidationValid       = "valid"
+	ValidationRejected    = "rejected"
+	ValidationUnreachable = "unreachable"
+	ValidationUnreadable  = "unreadable"
+)
+
+// Strength of a key: strong keys are valid and have no issues, weak ones are valid but have
+// some, invalid ones were rejected or could not be opened and unknown ones could not be checked.
+const (
+	StrengthStrong  = "strong"
+	StrengthWeak    = "weak"
+	StrengthInvalid = "invalid"
+	StrengthUnknown = "unknown"
+)
+
+// Issues reported for keys, besides the policy rules they violate.
+const (
+	IssueRejected     = "rejected by the provider"
+	IssueUnreadable   = "cannot be opened"
+	IssueOld          = "older than 90 days"
+	IssueNoDataKey    = "sealed without a data key"
+	IssueStaleDataKey = "data key not wrapped with the current KMS key"
+)
+
+// Job statuses of a validation. A job fails when the keys to validate could not be selected.
+const (
+	ValidationRunning   = "running"
+	ValidationCompleted = "completed"
+	ValidationFailed    = "failed"
+)
+
+// ValidationRequest selects the stored keys to validate: those of Users for Providers, every
+// user and provider when empty. Async runs it as a job however few keys it selects.
+type ValidationRequest struct {
+	Users     []string `json:"users,omitempty"`
+	Providers []string `json:"providers,omitempty"`
+	Async     bool     `json:"async,omitempty"`
+}
+
+// KeyValidation is the outcome of validating one key. A key is compliant unless it violates a
+// rule of its policy with error severity.
+type KeyValidation struct {
+	User       string      `json:"user"`
+	Provider   string      `json:"provider"`
+	Hint       string      `json:"hint"`
+	Status     string      `json:"status"`
+	Strength   string      `json:"strength"`
+	Compliant  bool        `json:"compliant"`
+	Violations []Violation `json:"violations,omitempty"`
+	Issues     []string    `json:"issues,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// IssueCount is how many keys share an issue.
+type IssueCount struct {
+	Issue string `json:"issue"`
+	Keys  int    `json:"keys"`
+}
+
+// ValidationSummary aggregates a validation. ComplianceRate is the share of keys that are
+// compliant. Failed lists keyrings that could not be read; their keys are missing from Results.
+type ValidationSummary struct {
+	Keyrings       int             `json:"keyrings"`
+	Keys           int             `json:"keys"`
+	Statuses       map[string]int  `json:"statuses"`
+	Strength       map[string]int  `json:"strength"`
+	ComplianceRate float64         `json:"complianceRate"`
+	CommonIssues   []IssueCount    `json:"commonIssues"`
+	Results        []KeyValidation `json:"results"`
+	Failed         []KeyFailure    `json:"failed,omitempty"`
+}
+
+// ValidationJob is a validation, finished at once for small batches and read back by its ID
+// while larger ones run. Keys is known once the keyrings of the job were read.
+type ValidationJob struct {
+	ID          string             `json:"id"`
+	Status      string             `json:"status"`
+	Keys        int                `json:"keys"`
+	Checked     int                `json:"checked"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	CompletedAt *time.Time         `json:"completedAt,omitempty"`
+	Summary     *ValidationSummary `json:"summary,omitempty"`
+	Error       string             `json:"error,omitempty"`
+}
+
+type ErrValidationNotFound struct {
+	ID string
+}
+
+func (e ErrValidationNotFound) Error() string {
+	return fmt.Sprintf("validation %s not found", e.ID)
+}
+
+type validationTarget struct {
+	user   string
+	sealed Sealed
+}
+
+// validationBatch is what a validation checks: the selected keys and the policies they are held to.
+type validationBatch struct {
+	summary  *ValidationSummary
+	targets  []validationTarget
+	policies []Policy
+}
+
+// Background runs validation jobs that outlive the request starting them, until the server shuts
+// down.
+type Background interface {
+	Go(name string, fn func(ctx context.Context))
+}
+
+// SetBackground runs large or asynchronous validations with background. Without one, every
+// validation completes before Validate returns.
+func (k *Keys) SetBackground(background Background) {
+	k.background = background
+}
+
+// Validate checks the selected keys with their providers, a few at a time, and reports what
+// should be done about them. Batches larger than asyncValidationKeys, or asked to, keep running
+// after Validate returns; their job is read with ValidationJob. Asynchronous jobs read the
+// keyrings they validate in the background too.
+func (k *Keys) Validate(ctx context.Context, req ValidationRequest) (ValidationJob, error) {
+	for _, provider := range req.Providers {
+		if !slices.Contains(providers, provider) {
+			return ValidationJob{}, ErrInvalidRequest{Reason: "providers must be among " + strings.Join(providers, ", ")}
+		}
+	}
+	if req.Async && k.background != nil {
+		job := k.track()
+		k.background.Go("user key validation", func(ctx context.Context) {
+			batch, err := k.selectKeys(ctx, req)
+			if err != nil {
+				k.fail(job, err)
+				return
+			}
+			k.validate(ctx, job, batch)
+		})
+		return k.ValidationJob(job.ID)
+	}
+
+	batch, err := k.selectKeys(ctx, req)
+	if err != nil {
+		return ValidationJob{}, err
+	}
+	job := k.track()
+	if len(batch.targets) > asyncValidationKeys && k.background != nil {
+		k.background.Go("user key validation", func(ctx context.Context) { k.validate(ctx, job, batch) })
+		return k.ValidationJob(job.ID)
+	}
+	k.validate(ctx, job, batch)
+	return k.ValidationJob(job.ID)
+}
+
+// selectKeys reads the keyrings of the users req names, or of every user, and the policies
+// their keys are held to.
+func (k *Keys) selectKeys(ctx context.Context, req ValidationRequest) (validationBatch, error) {
+	users := req.Users
+	if len(users) == 0 {
+		var err error
+		if users, err = k.store.Users(ctx); err != nil {
+			return validationBatch{}, err
+		}
+	}
+	policies, err := k.policies.List(ctx)
+	if err != nil {
+		return validationBatch{}, err
+	}
+	batch := validationBatch{summary: &ValidationSummary{}, policies: policies}
+	for _, user := range users {
+		keyring, err := k.store.Get(ctx, user)
+		if err != nil {
+			batch.summary.Failed = append(batch.summary.Failed, KeyFailure{User: user, Error: err.Error()})
+			continue
+		}
+		batch.summary.Keyrings++
+		for _, sealed := range keyring.Keys {
+			if len(req.Providers) == 0 || slices.Contains(req.Providers, sealed.Provider) {
+				batch.targets = append(batch.targets, validationTarget{user: user, sealed: sealed})
+			}
+		}
+	}
+	return batch, nil
+}
+
+// track registers a running validation job, forgetting those that finished long ago.
+func (k *Keys) track() *ValidationJob {
+	job := &ValidationJob{ID: uuid.NewString(), Status: ValidationRunning, CreatedAt: k.now()}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for id, previous := range k.validations {
+		if previous.CompletedAt != nil && k.now().Sub(*previous.CompletedAt) > validationRetention {
+			delete(k.validations, id)
+		}
+	}
+	k.validations[job.ID] = job
+	return job
+}
+
+func (k *Keys) fail(job *ValidationJob, err error) {
+	completed := k.now()
+	k.mu.Lock()
+	job.Status = ValidationFailed
+	job.CompletedAt = &completed
+	job.Error = err.Error()
+	k.mu.Unlock()
+	k.logger.Error("failed to select user API keys for validation", "validation", job.ID, "error", err)
+}
+
+// ValidationJob returns the validation with id, with its summary once it completed.
+func (k *Keys) ValidationJob(id string) (ValidationJob, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	job, ok := k.validations[id]
+	if !ok {
+		return ValidationJob{}, ErrValidationNotFound{ID: id}
+	}
+	return *job, nil
+}
+
+func (k *Keys) validate(ctx context.Context, job *ValidationJob, batch validationBatch) {
+	summary, targets, policies := batch.summary, batch.targets, batch.policies
+	k.mu.Lock()
+	job.Keys = len(targets)
+	k.mu.Unlock()
+	// The current version only tells stale data keys apart; without it none are reported.
+	current, err := k.kms.CurrentVersion(ctx)
+	if err != nil {
+		k.logger.Warn("failed to read the current KMS key version for validation", "kms", k.kms.Name(), "error", err)
+	}
+
+	results := make([]KeyValidation, len(targets))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range min(validationWorkers, len(targets)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = k.validateKey(ctx, targets[i], current, policies)
+				k.mu.Lock()
+				job.Checked++
+				k.mu.Unlock()
+			}
+		}()
+	}
+	for i := range targets {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	summary.Keys = len(results)
+	summary.Results = results
+	summary.Statuses = map[string]int{}
+	summary.Strength = map[string]int{StrengthStrong: 0, StrengthWeak: 0, StrengthInvalid: 0, StrengthUnknown: 0}
+	issues := map[string]int{}
+	compliant := 0
+	for _, result := range results {
+		if result.Compliant {
+			compliant++
+		}
+		summary.Statuses[result.Status]++
+		summary.Strength[result.Strength]++
+		for _, issue := range result.Issues {
+			issues[issue]++
+		}
+	}
+	if summary.Keys > 0 {
+		summary.ComplianceRate = float64(compliant) / float64(summary.Keys)
+	}
+	summary.CommonIssues = []IssueCount{}
+	for issue, keys := range issues {
+		summary.CommonIssues = append(summary.CommonIssues, IssueCount{Issue: issue, Keys: keys})
+	}
+	sort.Slice(summary.CommonIssues, func(i, j int) bool {
+		a, b := summary.CommonIssues[i], summary.CommonIssues[j]
+		if a.Keys != b.Keys {
+			return a.Keys > b.Keys
+		}
+		return a.Issue < b.Issue
+	})
+
+	completed := k.now()
+	k.mu.Lock()
+	job.Status = ValidationCompleted
+	job.CompletedAt = &completed
+	job.Summary = summary
+	k.mu.Unlock()
+	k.logger.Info("user API keys validated", "validation", job.ID, "keys", summary.Keys, "complianceRate", summary.ComplianceRate, "failed", len(summary.Failed))
+}
+
+// validateKey opens the key, evaluates its policy and checks it with its provider. Keys the
+// provider could not be asked about are unknown rather than invalid.
+func (k *Keys) validateKey(ctx context.Context, target validationTarget, currentVersion string, policies []Policy) KeyValidation {
+	sealed := target.sealed
+	result := KeyValidation{User: target.user, Provider: sealed.Provider, Hint: sealed.Hint, Compliant: true}
+	policy, hasPolicy := policyFor(policies, sealed.Provider)
+	violate := func(violations []Violation) {
+		for _, violation := range violations {
+			result.Violations = append(result.Violations, violation)
+			result.Issues = append(result.Issues, "violates "+violation.Rule)
+			if violation.Severity == SeverityError {
+				result.Compliant = false
+			}
+		}
+	}
+	if hasPolicy {
+		violate(policy.ageViolations(sealed.UpdatedAt, k.now()))
+	}
+	if k.now().Sub(sealed.UpdatedAt) > rotateAfter {
+		result.Issues = append(result.Issues, IssueOld)
+	}
+	switch {
+	case sealed.Envelope == nil:
+		result.Issues = append(result.Issues, IssueNoDataKey)
+	case currentVersion != "" && (sealed.Envelope.KMS != k.kms.Name() || sealed.Envelope.KeyVersion != currentVersion):
+		result.Issues = append(result.Issues, IssueStaleDataKey)
+	}
+
+	apiKey, err := k.open(ctx, target.user, sealed)
+	if err != nil {
+		result.Status, result.Strength, result.Error = ValidationUnreadable, StrengthInvalid, err.Error()
+		result.Issues = append(result.Issues, IssueUnreadable)
+		result.Compliant = false
+		return result
+	}
+	if hasPolicy {
+		violate(policy.contentViolations(apiKey))
+	}
+	result.Status = ValidationValid
+	if checker, ok := k.build(sealed.Provider, apiKey, sealed.Model).(ai.HealthChecker); ok {
+		if err := checker.HealthCheck(ctx); err != nil {
+			var status ai.ErrProviderStatus
+			if errors.As(err, &status) && status.Status < 500 {
+				result.Status = ValidationRejected
+				result.Compliant = false
+				result.Issues = append(result.Issues, IssueRejected)
+			} else {
+				result.Status = ValidationUnreachable
+			}
+			result.Error = err.Error()
+		}
+	}
+	switch {
+	case result.Status == ValidationRejected:
+		result.Strength = StrengthInvalid
+	case result.Status == ValidationUnreachable:
+		result.Strength = StrengthUnknown
+	case len(result.Issues) > 0:
+		result.Strength = StrengthWeak
+	default:
+		result.Strength = StrengthStrong
+	}
+	return result
+}
@@ -0,0 +1,96 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestKeysValidateSummarizesStoredKeys(t *testing.T) {
+	store := NewMemoryStore()
+	keys := newTestKeys(t, store)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	keys.now = func() time.Time { return now.Add(-100 * 24 * time.Hour) }
+	keys.Put(ctx, "alice", ProviderOpenAI, KeyRequest{APIKey: "sk-alice-openai-1234"})
+	keys.now = func() time.Time { return now }
+	keys.Put(ctx, "alice", ProviderGemini, KeyRequest{APIKey: "alice-gemini-5678"})
+	keys.Put(ctx, "bob", ProviderOpenAI, KeyRequest{APIKey: "sk-bob-openai-9012"})
+	// Revoked after it was stored.
+	keyring, _ := store.Get(ctx, "bob")
+	ciphertext, envelope, _ := keys.seal(ctx, "bob", ProviderOpenAI, "revoked")
+	keyring.Keys[0].Ciphertext, keyring.Keys[0].Envelope = ciphertext, envelope
+	store.Save(ctx, keyring)
+
+	job, err := keys.Validate(ctx, ValidationRequest{})
+	if err != nil || job.Status != ValidationCompleted || job.Summary == nil {
+		t.Fatalf("expected a small batch to complete at once, got %+v: %v", job, err)
+	}
+	summary := job.Summary
+	if summary.Keyrings != 2 || summary.Keys != 3 || len(summary.Failed) != 0 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	if summary.Statuses[ValidationValid] != 2 || summary.Statuses[ValidationRejected] != 1 {
+		t.Fatalf("expected two valid keys and one rejected, got %v", summary.Statuses)
+	}
+	if summary.Strength[StrengthStrong] != 1 || summary.Strength[StrengthWeak] != 1 || summary.Strength[StrengthInvalid] != 1 {
+		t.Fatalf("unexpected strength distribution %v", summary.Strength)
+	}
+	if summary.ComplianceRate < 0.66 || summary.ComplianceRate > 0.67 {
+		t.Fatalf("expected all but the rejected key to comply, got %v", summary.ComplianceRate)
+	}
+	if len(summary.CommonIssues) != 2 || summary.CommonIssues[0].Keys != 1 {
+		t.Fatalf("expected the old and the rejected key to be reported, got %+v", summary.CommonIssues)
+	}
+
+	only, _ := keys.Validate(ctx, ValidationRequest{Users: []string{"alice"}, Providers: []string{ProviderGemini}})
+	if only.Keys != 1 || only.Summary.Results[0].Strength != StrengthStrong {
+		t.Fatalf("expected only alice's gemini key to be validated, got %+v", only.Summary)
+	}
+	var invalid ErrInvalidRequest
+	if _, err := keys.Validate(ctx, ValidationRequest{Providers: []string{"mistral"}}); !errors.As(err, &invalid) {
+		t.Fatalf("expected an unknown provider to be refused, got %v", err)
+	}
+}
+
+// heldJobs runs the background jobs handed to it when released.
+type heldJobs struct {
+	jobs []func(ctx context.Context)
+}
+
+func (h *heldJobs) Go(name string, fn func(ctx context.Context)) {
+	h.jobs = append(h.jobs, fn)
+}
+
+func (h *heldJobs) release(ctx context.Context) {
+	for _, job := range h.jobs {
+		job(ctx)
+	}
+	h.jobs = nil
+}
+
+func TestKeysValidateRunsLargeBatchesAsJobs(t *testing.T) {
+	keys := newTestKeys(t, NewMemoryStore())
+	background := &heldJobs{}
+	keys.SetBackground(background)
+	ctx := context.Background()
+	keys.Put(ctx, "alice", ProviderOpenAI, KeyRequest{APIKey: "sk-alice-openai-1234"})
+
+	job, err := keys.Validate(ctx, ValidationRequest{Async: true})
+	if err != nil || job.Status != ValidationRunning || len(background.jobs) != 1 {
+		t.Fatalf("expected the validation to be handed to the background, got %+v: %v", job, err)
+	}
+	// The keyrings are read by the job, not by the request starting it.
+	keys.Put(ctx, "bob", ProviderOpenAI, KeyRequest{APIKey: "sk-bob-openai-9012"})
+	background.release(ctx)
+	job, _ = keys.ValidationJob(job.ID)
+	if job.Status != ValidationCompleted || job.Keys != 2 || job.Checked != 2 || job.Summary.Strength[StrengthStrong] != 2 {
+		t.Fatalf("unexpected completed job %+v", job)
+	}
+	var notFound ErrValidationNotFound
+	if _, err := keys.ValidationJob("missing"); !errors.As(err, &notFound) {
+		t.Fatalf("expected a missing validation to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,269 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultServiceAccountTokenFile is where pods find the token of their service account.
+const DefaultServiceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+type VaultConfig struct {
+	Address string
+	// Path is the mount of a KV version 2 secrets engine followed by the prefix keys are kept
+	// under, such as secret/kubechat/user-keys, or of a transit engine followed by the name of
+	// its key, such as transit/kubechat.
+	Path string
+	// Token authenticates when no Role is set.
+	Token string
+	// Role logs in through the Kubernetes auth method with the pod's service account token. The
+	// token Vault issues is renewed while its lease allows and replaced by a new login after.
+	Role string
+	// AuthMount is where the Kubernetes auth method is mounted, kubernetes by default.
+	AuthMount string
+	// TokenFile holds the service account token, DefaultServiceAccountTokenFile by default.
+	TokenFile string
+}
+
+// vaultClient calls Vault with a token it keeps valid.
+type vaultClient struct {
+	cfg    VaultConfig
+	client *http.Client
+	now    func() time.Time
+
+	mu    sync.Mutex
+	lease vaultLease
+}
+
+// VaultBackend keeps sealed keys in a KV version 2 secrets engine of HashiCorp Vault.
+type VaultBackend struct {
+	*vaultClient
+	mount  string
+	prefix string
+}
+
+// vaultLease is the token a Kubernetes login was issued and when it has to be renewed.
+type vaultLease struct {
+	token     string
+	renewable bool
+	renewAt   time.Time
+	expiresAt time.Time
+}
+
+func NewVaultBackend(cfg VaultConfig, client *http.Client) (*VaultBackend, error) {
+	vault, err := newVaultClient(cfg, client)
+	if err != nil {
+		return nil, err
+	}
+	mount, prefix, _ := strings.Cut(strings.Trim(cfg.Path, "/"), "/")
+	if mount == "" {
+		return nil, ErrInvalidRequest{Reason: "vault backend requires the path of a KV secrets engine"}
+	}
+	return &VaultBackend{vaultClient: vault, mount: mount, prefix: prefix}, nil
+}
+
+func newVaultClient(cfg VaultConfig, client *http.Client) (*vaultClient, error) {
+	if cfg.Address == "" {
+		return nil, ErrInvalidRequest{Reason: "vault requires an address"}
+	}
+	if cfg.Token == "" && cfg.Role == "" {
+		return nil, ErrInvalidRequest{Reason: "vault requires VAULT_TOKEN or a Kubernetes auth role"}
+	}
+	if cfg.AuthMount == "" {
+		cfg.AuthMount = "kubernetes"
+	}
+	if cfg.TokenFile == "" {
+		cfg.TokenFile = DefaultServiceAccountTokenFile
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	cfg.Address = strings.TrimRight(cfg.Address, "/")
+	return &vaultClient{cfg: cfg, client: client, now: time.Now}, nil
+}
+
+func (b *VaultBackend) Name() string {
+	return BackendVault
+}
+
+type vaultKV struct {
+	Data struct {
+		Data struct {
+			Sealed []byte `json:"sealed"`
+		} `json:"data"`
+	} `json:"data"`
+}
+
+func (b *VaultBackend) Put(ctx context.Context, name string, sealed []byte) error {
+	body := map[string]any{"data": map[string][]byte{"sealed": sealed}}
+	return b.do(ctx, http.MethodPost, b.kvPath("data", name), body, nil)
+}
+
+func (b *VaultBackend) Get(ctx context.Context, name string) ([]byte, error) {
+	var kv vaultKV
+	err := b.do(ctx, http.MethodGet, b.kvPath("data", name), nil, &kv)
+	if err != nil {
+		if vaultStatus(err) == http.StatusNotFound {
+			return nil, ErrSecretNotFound{Backend: b.Name(), Name: name}
+		}
+		return nil, err
+	}
+	return kv.Data.Data.Sealed, nil
+}
+
+// Delete removes every version of the key, not only the latest.
+func (b *VaultBackend) Delete(ctx context.Context, name string) error {
+	err := b.do(ctx, http.MethodDelete, b.kvPath("metadata", name), nil, nil)
+	if vaultStatus(err) == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+func (b *VaultBackend) kvPath(kind, name string) string {
+	path := "/v1/" + b.mount + "/" + kind + "/"
+	if b.prefix != "" {
+		path += b.prefix + "/"
+	}
+	return path + name
+}
+
+type errVaultStatus struct {
+	status int
+	errors []string
+}
+
+func (e errVaultStatus) Error() string {
+	return fmt.Sprintf("vault returned %d: %s", e.status, strings.Join(e.errors, "; "))
+}
+
+func vaultStatus(err error) int {
+	var status errVaultStatus
+	if errors.As(err, &status) {
+		return status.status
+	}
+	return 0
+}
+
+// do calls Vault with a valid token. A token Vault refuses is dropped so the next call logs in
+// again.
+func (b *vaultClient) do(ctx context.Context, method, path string, body, out any) error {
+	token, err := b.token(ctx)
+	if err != nil {
+		return err
+	}
+	err = b.call(ctx, method, path, token, body, out)
+	if vaultStatus(err) == http.StatusForbidden && b.cfg.Role != "" {
+		b.mu.Lock()
+		if b.lease.token == token {
+			b.lease = vaultLease{}
+		}
+		b.mu.Unlock()
+	}
+	return err
+}
+
+func (b *vaultClient) call(ctx context.Context, method, path, token string, body, out any) error {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, b.cfg.Address+path, payload)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		var failure struct {
+			Errors []string `json:"errors"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&failure)
+		return errVaultStatus{status: resp.StatusCode, errors: failure.Errors}
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type vaultAuth struct {
+	Auth struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+	} `json:"auth"`
+}
+
+// token returns the token to call Vault with. A token from a Kubernetes login is renewed once
+// two thirds of its lease have passed, and replaced by a new login when it cannot be renewed.
+func (b *vaultClient) token(ctx context.Context) (string, error) {
+	if b.cfg.Role == "" {
+		return b.cfg.Token, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	lease := b.lease
+	if lease.token != "" && (lease.renewAt.IsZero() || now.Before(lease.renewAt)) {
+		return lease.token, nil
+	}
+	if lease.token != "" && lease.renewable && now.Before(lease.expiresAt) {
+		var renewed vaultAuth
+		if err := b.call(ctx, http.MethodPost, "/v1/auth/token/renew-self", lease.token, map[string]any{}, &renewed); err == nil {
+			b.lease = b.leaseFrom(renewed, now)
+			return b.lease.token, nil
+		}
+	}
+	jwt, err := os.ReadFile(b.cfg.TokenFile)
+	if err != nil {
+		return "", fmt.Errorf("read service account token: %w", err)
+	}
+	var login vaultAuth
+	body := map[string]string{"role": b.cfg.Role, "jwt": strings.TrimSpace(string(jwt))}
+	if err := b.call(ctx, http.MethodPost, "/v1/auth/"+b.cfg.AuthMount+"/login", "", body, &login); err != nil {
+		return "", fmt.Errorf("vault login as %s: %w", b.cfg.Role, err)
+	}
+	b.lease = b.leaseFrom(login, now)
+	return b.lease.token, nil
+}
+
+func (b *vaultClient) leaseFrom(auth vaultAuth, now time.Time) vaultLease {
+	token := auth.Auth.ClientToken
+	if token == "" {
+		token = b.lease.token
+	}
+	duration := time.Duration(auth.Auth.LeaseDuration) * time.Second
+	if duration == 0 {
+		// Tokens without a lease never expire.
+		return vaultLease{token: token}
+	}
+	return vaultLease{
+		token:     token,
+		renewable: auth.Auth.Renewable,
+		renewAt:   now.Add(duration * 2 / 3),
+		expiresAt: now.Add(duration),
+	}
+}
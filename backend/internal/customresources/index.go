@@ -0,0 +1,220 @@
+// Package customresources indexes the custom resource definitions installed in a cluster, so that
+// prompts about an operator's resources, such as "list my kafka clusters", plan commands against
+// the resources the cluster actually serves.
+package customresources
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/plan"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// maxFieldDepth is how deep below spec the schema is indexed; deeper fields are rarely named
+	// in prompts.
+	maxFieldDepth = 3
+	// maxFields caps the fields indexed for one definition.
+	maxFields = 200
+)
+
+// ClientFunc returns the apiextensions client of a config/context pair.
+type ClientFunc func(config, cluster string) (apiextensionsclientset.Interface, error)
+
+// ClusterResolver maps the cluster a plan names to its config and context.
+type ClusterResolver interface {
+	Resolve(ctx context.Context, ref string) (config, cluster string, err error)
+}
+
+// Column is a printer column kubectl shows when listing a custom resource.
+type Column struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	JSONPath string `json:"jsonPath"`
+}
+
+// Definition is an established custom resource definition, read at the version the cluster
+// stores.
+type Definition struct {
+	Group      string   `json:"group"`
+	Kind       string   `json:"kind"`
+	Version    string   `json:"version"`
+	Versions   []string `json:"versions"`
+	Plural     string   `json:"plural"`
+	Singular   string   `json:"singular"`
+	ShortNames []string `json:"shortNames,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+	Namespaced bool     `json:"namespaced"`
+	Columns    []Column `json:"columns,omitempty"`
+	// Fields are the paths of the schema's spec fields, such as spec.kafka.replicas. Fields of
+	// list items are not indexed.
+	Fields []string `json:"fields,omitempty"`
+}
+
+// Resource is the resource.group kubectl accepts for the definition, such as
+// kafkas.kafka.strimzi.io.
+func (d Definition) Resource() string {
+	return d.Plural + "." + d.Group
+}
+
+type entry struct {
+	definitions []Definition
+	readAt      time.Time
+}
+
+// Index caches the definitions of each cluster for ttl, re-reading them on first use after that.
+type Index struct {
+	clients  ClientFunc
+	clusters ClusterResolver
+	ttl      time.Duration
+	clock    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]entry
+}
+
+func NewIndex(clients ClientFunc, clusters ClusterResolver, ttl time.Duration) *Index {
+	return &Index{clients: clients, clusters: clusters, ttl: ttl, clock: time.Now, entries: map[string]entry{}}
+}
+
+// Definitions returns the definitions installed in the config/context pair, sorted by resource.
+// refresh reads them again even when the cached ones have not expired.
+func (i *Index) Definitions(ctx context.Context, config, cluster string, refresh bool) ([]Definition, error) {
+	key := config + "/" + cluster
+	i.mu.Lock()
+	cached, ok := i.entries[key]
+	i.mu.Unlock()
+	if ok && !refresh && i.clock().Sub(cached.readAt) < i.ttl {
+		return cached.definitions, nil
+	}
+
+	client, err := i.clients(config, cluster)
+	if err != nil {
+		return nil, err
+	}
+	list, err := client.ApiextensionsV1().CustomResourceDefinitions().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list custom resource definitions: %w", err)
+	}
+	definitions := make([]Definition, 0, len(list.Items))
+	for _, crd := range list.Items {
+		if definition, ok := index(crd); ok {
+			definitions = append(definitions, definition)
+		}
+	}
+	sort.Slice(definitions, func(a, b int) bool { return definitions[a].Resource() < definitions[b].Resource() })
+
+	i.mu.Lock()
+	i.entries[key] = entry{definitions: definitions, readAt: i.clock()}
+	i.mu.Unlock()
+	return definitions, nil
+}
+
+// CustomResources returns the custom resources the cluster a plan names serves, so plans can
+// read them.
+func (i *Index) CustomResources(ctx context.Context, ref string) ([]plan.CustomResource, error) {
+	config, cluster, err := i.clusters.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	definitions, err := i.Definitions(ctx, config, cluster, false)
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]plan.CustomResource, len(definitions))
+	for n, definition := range definitions {
+		columns := make([]string, len(definition.Columns))
+		for c, column := range definition.Columns {
+			columns[c] = column.Name
+		}
+		resources[n] = plan.CustomResource{
+			Group:      definition.Group,
+			Kind:       definition.Kind,
+			Plural:     definition.Plural,
+			Singular:   definition.Singular,
+			ShortNames: definition.ShortNames,
+			Namespaced: definition.Namespaced,
+			Columns:    columns,
+			Fields:     definition.Fields,
+		}
+	}
+	return resources, nil
+}
+
+// index describes crd at its storage version. Definitions that are not established yet, or serve
+// no version, are left out since nothing can be read from them.
+func index(crd apiextensionsv1.CustomResourceDefinition) (Definition, bool) {
+	established := false
+	for _, condition := range crd.Status.Conditions {
+		if condition.Type == apiextensionsv1.Established && condition.Status == apiextensionsv1.ConditionTrue {
+			established = true
+		}
+	}
+	if !established {
+		return Definition{}, false
+	}
+	names := crd.Spec.Names
+	definition := Definition{
+		Group:      crd.Spec.Group,
+		Kind:       names.Kind,
+		Plural:     names.Plural,
+		Singular:   names.Singular,
+		ShortNames: names.ShortNames,
+		Categories: names.Categories,
+		Namespaced: crd.Spec.Scope == apiextensionsv1.NamespaceScoped,
+	}
+	if definition.Singular == "" {
+		definition.Singular = strings.ToLower(names.Kind)
+	}
+	var version *apiextensionsv1.CustomResourceDefinitionVersion
+	for v := range crd.Spec.Versions {
+		candidate := &crd.Spec.Versions[v]
+		if !candidate.Served {
+			continue
+		}
+		definition.Versions = append(definition.Versions, candidate.Name)
+		if version == nil || candidate.Storage {
+			version = candidate
+		}
+	}
+	if version == nil {
+		return Definition{}, false
+	}
+	definition.Version = version.Name
+	for _, column := range version.AdditionalPrinterColumns {
+		definition.Columns = append(definition.Columns, Column{Name: column.Name, Type: column.Type, JSONPath: column.JSONPath})
+	}
+	if version.Schema != nil && version.Schema.OpenAPIV3Schema != nil {
+		if spec, ok := version.Schema.OpenAPIV3Schema.Properties["spec"]; ok {
+			definition.Fields = fields(spec, "spec", 1, nil)
+		}
+	}
+	return definition, true
+}
+
+// fields appends the paths of the properties below schema, depth levels below spec, in name order.
+func fields(schema apiextensionsv1.JSONSchemaProps, path string, depth int, paths []string) []string {
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if len(paths) >= maxFields {
+			break
+		}
+		child := path + "." + name
+		paths = append(paths, child)
+		if depth < maxFieldDepth {
+			paths = fields(schema.Properties[name], child, depth+1, paths)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,101 @@
+package customresources
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+type staticResolver struct{}
+
+func (staticResolver) Resolve(ctx context.Context, ref string) (string, string, error) {
+	return "kubeconfig", ref, nil
+}
+
+func crd(plural, group, kind string, established bool, versions ...apiextensionsv1.CustomResourceDefinitionVersion) *apiextensionsv1.CustomResourceDefinition {
+	def := &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: plural + "." + group},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group:    group,
+			Names:    apiextensionsv1.CustomResourceDefinitionNames{Plural: plural, Kind: kind, ShortNames: []string{"k"}},
+			Scope:    apiextensionsv1.NamespaceScoped,
+			Versions: versions,
+		},
+	}
+	if established {
+		def.Status.Conditions = []apiextensionsv1.CustomResourceDefinitionCondition{{Type: apiextensionsv1.Established, Status: apiextensionsv1.ConditionTrue}}
+	}
+	return def
+}
+
+func TestIndexDescribesEstablishedDefinitions(t *testing.T) {
+	schema := &apiextensionsv1.CustomResourceValidation{OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{
+		Properties: map[string]apiextensionsv1.JSONSchemaProps{
+			"spec": {Properties: map[string]apiextensionsv1.JSONSchemaProps{
+				"kafka": {Properties: map[string]apiextensionsv1.JSONSchemaProps{
+					"replicas": {Type: "integer"},
+					"config":   {Properties: map[string]apiextensionsv1.JSONSchemaProps{"deep": {Properties: map[string]apiextensionsv1.JSONSchemaProps{"deeper": {}}}}},
+				}},
+			}},
+			"status": {Properties: map[string]apiextensionsv1.JSONSchemaProps{"ready": {}}},
+		},
+	}}
+	client := fake.NewSimpleClientset(
+		crd("kafkas", "kafka.strimzi.io", "Kafka", true,
+			apiextensionsv1.CustomResourceDefinitionVersion{Name: "v1beta1", Served: true},
+			apiextensionsv1.CustomResourceDefinitionVersion{Name: "v1beta2", Served: true, Storage: true, Schema: schema,
+				AdditionalPrinterColumns: []apiextensionsv1.CustomResourceColumnDefinition{{Name: "Ready", Type: "string", JSONPath: ".status.conditions[0].status"}}},
+			apiextensionsv1.CustomResourceDefinitionVersion{Name: "v1alpha1"},
+		),
+		crd("pending", "example.com", "Pending", false, apiextensionsv1.CustomResourceDefinitionVersion{Name: "v1", Served: true, Storage: true}),
+	)
+	reads := 0
+	client.PrependReactor("list", "customresourcedefinitions", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		reads++
+		return false, nil, nil
+	})
+	index := NewIndex(func(config, cluster string) (apiextensionsclientset.Interface, error) { return client, nil }, staticResolver{}, time.Minute)
+
+	definitions, err := index.Definitions(context.Background(), "kubeconfig", "prod", false)
+	if err != nil {
+		t.Fatalf("definitions: %v", err)
+	}
+	if len(definitions) != 1 {
+		t.Fatalf("expected only the established definition, got %+v", definitions)
+	}
+	kafka := definitions[0]
+	if kafka.Resource() != "kafkas.kafka.strimzi.io" || kafka.Singular != "kafka" || kafka.Version != "v1beta2" || len(kafka.Versions) != 2 || !kafka.Namespaced {
+		t.Fatalf("unexpected definition %+v", kafka)
+	}
+	if len(kafka.Columns) != 1 || kafka.Columns[0].Name != "Ready" {
+		t.Fatalf("expected the storage version's printer columns, got %+v", kafka.Columns)
+	}
+	want := []string{"spec.kafka", "spec.kafka.config", "spec.kafka.config.deep", "spec.kafka.replicas"}
+	if len(kafka.Fields) != len(want) {
+		t.Fatalf("expected fields %v, got %v", want, kafka.Fields)
+	}
+	for i := range want {
+		if kafka.Fields[i] != want[i] {
+			t.Fatalf("expected fields %v, got %v", want, kafka.Fields)
+		}
+	}
+
+	resources, err := index.CustomResources(context.Background(), "prod")
+	if err != nil || len(resources) != 1 || resources[0].Resource() != "kafkas.kafka.strimzi.io" || resources[0].Columns[0] != "Ready" {
+		t.Fatalf("unexpected custom resources %+v (%v)", resources, err)
+	}
+	if reads != 1 {
+		t.Fatalf("expected cached definitions to be reused, read %d times", reads)
+	}
+	index.clock = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if _, err := index.Definitions(context.Background(), "kubeconfig", "prod", false); err != nil || reads != 2 {
+		t.Fatalf("expected expired definitions to be read again, read %d times (%v)", reads, err)
+	}
+}
@@ -0,0 +1,114 @@
+// Package dashboards stores saved, parameterized queries as widgets and composes them into
+// dashboards shared within a workspace.
+package dashboards
+
+import (
+	"fmt"
+	"time"
+)
+
+// Widget is a named, saved query against one of the registered data sources.
+type Widget struct {
+	ID          string `json:"id"`
+	Workspace   string `json:"workspace"`
+	Owner       string `json:"owner"`
+	Shared      bool   `json:"shared"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Visualization is a rendering hint for the frontend, such as "table", "timeseries" or "stat".
+	Visualization string `json:"visualization,omitempty"`
+	Source        string `json:"source"`
+	// Params are passed to the source. Values may reference dashboard variables as ${name}.
+	Params    map[string]string `json:"params,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+// Panel places a widget on a dashboard grid.
+type Panel struct {
+	WidgetID string `json:"widgetId"`
+	Title    string `json:"title,omitempty"`
+	X        int    `json:"x"`
+	Y        int    `json:"y"`
+	W        int    `json:"w"`
+	H        int    `json:"h"`
+	// Params override the widget's params for this panel only.
+	Params map[string]string `json:"params,omitempty"`
+}
+
+type Dashboard struct {
+	ID          string  `json:"id"`
+	Workspace   string  `json:"workspace"`
+	Owner       string  `json:"owner"`
+	Shared      bool    `json:"shared"`
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Panels      []Panel `json:"panels"`
+	// Variables are defaults for ${name} references; viewers may override them when rendering.
+	Variables map[string]string `json:"variables,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+// Viewer identifies who is reading or changing dashboards.
+type Viewer struct {
+	User      string
+	Workspace string
+}
+
+// canRead reports whether the viewer owns the item or it is shared in the viewer's workspace.
+func (v Viewer) canRead(workspace, owner string, shared bool) bool {
+	return workspace == v.Workspace && (owner == v.User || shared)
+}
+
+func (v Viewer) canWrite(workspace, owner string) bool {
+	return workspace == v.Workspace && owner == v.User
+}
+
+type PanelResult struct {
+	Panel
+	Widget Widget `json:"widget"`
+	Data   any    `json:"data,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Rendered is a dashboard with every panel's query evaluated.
+type Rendered struct {
+	Dashboard  Dashboard         `json:"dashboard"`
+	Variables  map[string]string `json:"variables"`
+	Panels     []PanelResult     `json:"panels"`
+	RenderedAt time.Time         `json:"renderedAt"`
+}
+
+type ErrWidgetNotFound struct {
+	ID string
+}
+
+func (e ErrWidgetNotFound) Error() string {
+	return fmt.Sprintf("widget %s not found", e.ID)
+}
+
+type ErrDashboardNotFound struct {
+	ID string
+}
+
+func (e ErrDashboardNotFound) Error() string {
+	return fmt.Sprintf("dashboard %s not found", e.ID)
+}
+
+// ErrForbidden is returned when a viewer changes an item they can see but do not own.
+type ErrForbidden struct {
+	ID string
+}
+
+func (e ErrForbidden) Error() string {
+	return fmt.Sprintf("only the owner can change %s", e.ID)
+}
+
+type ErrInvalidRequest struct {
+	Reason string
+}
+
+func (e ErrInvalidRequest) Error() string {
+	return e.Reason
+}
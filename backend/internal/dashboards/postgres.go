@@ -0,0 +1,176 @@
+package dashboards
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pramodksahoo/kubechat/backend/internal/database"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// PostgresRepository stores each widget and dashboard as a JSON document, with the columns
+// used for lookups kept alongside.
+type PostgresRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresRepository applies the dashboard schema migrations and returns a repository backed by db.
+func NewPostgresRepository(ctx context.Context, db *sqlx.DB) (*PostgresRepository, error) {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Migrate(ctx, db, "dashboards", sub); err != nil {
+		return nil, err
+	}
+	return &PostgresRepository{db: db}, nil
+}
+
+type documentRow struct {
+	ID        string    `db:"id"`
+	Workspace string    `db:"workspace"`
+	Owner     string    `db:"owner"`
+	Name      string    `db:"name"`
+	Body      []byte    `db:"body"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+func (r *PostgresRepository) save(ctx context.Context, table string, row documentRow) error {
+	_, err := r.db.NamedExecContext(ctx, `INSERT INTO `+table+` (id, workspace, owner, name, body, updated_at)
+		VALUES (:id, :workspace, :owner, :name, :body, :updated_at)
+		ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, body = EXCLUDED.body, updated_at = EXCLUDED.updated_at`, row)
+	return err
+}
+
+func (r *PostgresRepository) get(ctx context.Context, table, id string, target any) (bool, error) {
+	var body []byte
+	err := r.db.GetContext(ctx, &body, `SELECT body FROM `+table+` WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(body, target)
+}
+
+func (r *PostgresRepository) list(ctx context.Context, table, workspace string) ([][]byte, error) {
+	var bodies [][]byte
+	err := r.db.SelectContext(ctx, &bodies, `SELECT body FROM `+table+` WHERE workspace = $1 ORDER BY name`, workspace)
+	return bodies, err
+}
+
+func (r *PostgresRepository) delete(ctx context.Context, table, id string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM `+table+` WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (r *PostgresRepository) SaveWidget(ctx context.Context, widget Widget) error {
+	body, err := json.Marshal(widget)
+	if err != nil {
+		return err
+	}
+	return r.save(ctx, "dashboard_widgets", documentRow{
+		ID: widget.ID, Workspace: widget.Workspace, Owner: widget.Owner, Name: widget.Name, Body: body, UpdatedAt: widget.UpdatedAt,
+	})
+}
+
+func (r *PostgresRepository) GetWidget(ctx context.Context, id string) (Widget, error) {
+	var widget Widget
+	found, err := r.get(ctx, "dashboard_widgets", id, &widget)
+	if err != nil {
+		return Widget{}, err
+	}
+	if !found {
+		return Widget{}, ErrWidgetNotFound{ID: id}
+	}
+	return widget, nil
+}
+
+func (r *PostgresRepository) ListWidgets(ctx context.Context, workspace string) ([]Widget, error) {
+	bodies, err := r.list(ctx, "dashboard_widgets", workspace)
+	if err != nil {
+		return nil, err
+	}
+	widgets := make([]Widget, 0, len(bodies))
+	for _, body := range bodies {
+		var widget Widget
+		if err := json.Unmarshal(body, &widget); err != nil {
+			return nil, err
+		}
+		widgets = append(widgets, widget)
+	}
+	return widgets, nil
+}
+
+func (r *PostgresRepository) DeleteWidget(ctx context.Context, id string) error {
+	deleted, err := r.delete(ctx, "dashboard_widgets", id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrWidgetNotFound{ID: id}
+	}
+	return nil
+}
+
+func (r *PostgresRepository) SaveDashboard(ctx context.Context, dashboard Dashboard) error {
+	body, err := json.Marshal(dashboard)
+	if err != nil {
+		return err
+	}
+	return r.save(ctx, "dashboards", documentRow{
+		ID: dashboard.ID, Workspace: dashboard.Workspace, Owner: dashboard.Owner, Name: dashboard.Name, Body: body, UpdatedAt: dashboard.UpdatedAt,
+	})
+}
+
+func (r *PostgresRepository) GetDashboard(ctx context.Context, id string) (Dashboard, error) {
+	var dashboard Dashboard
+	found, err := r.get(ctx, "dashboards", id, &dashboard)
+	if err != nil {
+		return Dashboard{}, err
+	}
+	if !found {
+		return Dashboard{}, ErrDashboardNotFound{ID: id}
+	}
+	return dashboard, nil
+}
+
+func (r *PostgresRepository) ListDashboards(ctx context.Context, workspace string) ([]Dashboard, error) {
+	bodies, err := r.list(ctx, "dashboards", workspace)
+	if err != nil {
+		return nil, err
+	}
+	dashboards := make([]Dashboard, 0, len(bodies))
+	for _, body := range bodies {
+		var dashboard Dashboard
+		if err := json.Unmarshal(body, &dashboard); err != nil {
+			return nil, err
+		}
+		dashboards = append(dashboards, dashboard)
+	}
+	return dashboards, nil
+}
+
+func (r *PostgresRepository) DeleteDashboard(ctx context.Context, id string) error {
+	deleted, err := r.delete(ctx, "dashboards", id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrDashboardNotFound{ID: id}
+	}
+	return nil
+}
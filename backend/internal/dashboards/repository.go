@@ -0,0 +1,130 @@
+package dashboards
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// Repository persists widgets and dashboards.
+type Repository interface {
+	SaveWidget(ctx context.Context, widget Widget) error
+	GetWidget(ctx context.Context, id string) (Widget, error)
+	ListWidgets(ctx context.Context, workspace string) ([]Widget, error)
+	DeleteWidget(ctx context.Context, id string) error
+
+	SaveDashboard(ctx context.Context, dashboard Dashboard) error
+	GetDashboard(ctx context.Context, id string) (Dashboard, error)
+	ListDashboards(ctx context.Context, workspace string) ([]Dashboard, error)
+	DeleteDashboard(ctx context.Context, id string) error
+}
+
+// MemoryRepository keeps dashboards in process memory; it is used when no database is configured.
+type MemoryRepository struct {
+	mu         sync.RWMutex
+	widgets    map[string]Widget
+	dashboards map[string]Dashboard
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		widgets:    make(map[string]Widget),
+		dashboards: make(map[string]Dashboard),
+	}
+}
+
+func (r *MemoryRepository) SaveWidget(ctx context.Context, widget Widget) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.widgets[widget.ID] = cloneWidget(widget)
+	return nil
+}
+
+func (r *MemoryRepository) GetWidget(ctx context.Context, id string) (Widget, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	widget, ok := r.widgets[id]
+	if !ok {
+		return Widget{}, ErrWidgetNotFound{ID: id}
+	}
+	return cloneWidget(widget), nil
+}
+
+func (r *MemoryRepository) ListWidgets(ctx context.Context, workspace string) ([]Widget, error) {
+	r.mu.RLock()
+	var widgets []Widget
+	for _, widget := range r.widgets {
+		if widget.Workspace == workspace {
+			widgets = append(widgets, cloneWidget(widget))
+		}
+	}
+	r.mu.RUnlock()
+	sort.Slice(widgets, func(i, j int) bool { return widgets[i].Name < widgets[j].Name })
+	return widgets, nil
+}
+
+func (r *MemoryRepository) DeleteWidget(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.widgets[id]; !ok {
+		return ErrWidgetNotFound{ID: id}
+	}
+	delete(r.widgets, id)
+	return nil
+}
+
+func (r *MemoryRepository) SaveDashboard(ctx context.Context, dashboard Dashboard) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dashboards[dashboard.ID] = cloneDashboard(dashboard)
+	return nil
+}
+
+func (r *MemoryRepository) GetDashboard(ctx context.Context, id string) (Dashboard, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	dashboard, ok := r.dashboards[id]
+	if !ok {
+		return Dashboard{}, ErrDashboardNotFound{ID: id}
+	}
+	return cloneDashboard(dashboard), nil
+}
+
+func (r *MemoryRepository) ListDashboards(ctx context.Context, workspace string) ([]Dashboard, error) {
+	r.mu.RLock()
+	var dashboards []Dashboard
+	for _, dashboard := range r.dashboards {
+		if dashboard.Workspace == workspace {
+			dashboards = append(dashboards, cloneDashboard(dashboard))
+		}
+	}
+	r.mu.RUnlock()
+	sort.Slice(dashboards, func(i, j int) bool { return dashboards[i].Name < dashboards[j].Name })
+	return dashboards, nil
+}
+
+func (r *MemoryRepository) DeleteDashboard(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.dashboards[id]; !ok {
+		return ErrDashboardNotFound{ID: id}
+	}
+	delete(r.dashboards, id)
+	return nil
+}
+
+func cloneWidget(widget Widget) Widget {
+	widget.Params = maps.Clone(widget.Params)
+	return widget
+}
+
+func cloneDashboard(dashboard Dashboard) Dashboard {
+	dashboard.Variables = maps.Clone(dashboard.Variables)
+	dashboard.Panels = slices.Clone(dashboard.Panels)
+	for i := range dashboard.Panels {
+		dashboard.Panels[i].Params = maps.Clone(dashboard.Panels[i].Params)
+	}
+	return dashboard
+}
@@ -0,0 +1,355 @@
+package dashboards
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+)
+
+const (
+	maxPanels = 50
+	// panelTimeout bounds each panel's query so one slow source does not stall the dashboard.
+	panelTimeout = 15 * time.Second
+)
+
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z0-9_.-]+)\}`)
+
+// Service validates, stores and renders widgets and dashboards.
+type Service struct {
+	repo    Repository
+	sources map[string]Source
+	clock   func() time.Time
+	logger  *log.Logger
+}
+
+func NewService(repo Repository, logger *log.Logger, sources ...Source) *Service {
+	if logger == nil {
+		logger = log.Default()
+	}
+	s := &Service{repo: repo, sources: map[string]Source{}, clock: time.Now, logger: logger}
+	for _, source := range sources {
+		s.sources[source.Info().Name] = source
+	}
+	return s
+}
+
+// Sources describes the data sources widgets may query.
+func (s *Service) Sources() []SourceInfo {
+	infos := make([]SourceInfo, 0, len(s.sources))
+	for _, source := range s.sources {
+		info := source.Info()
+		if info.Params == nil {
+			info.Params = []SourceParam{}
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+func (s *Service) Widgets(ctx context.Context, viewer Viewer) ([]Widget, error) {
+	all, err := s.repo.ListWidgets(ctx, viewer.Workspace)
+	if err != nil {
+		return nil, err
+	}
+	widgets := []Widget{}
+	for _, widget := range all {
+		if viewer.canRead(widget.Workspace, widget.Owner, widget.Shared) {
+			widgets = append(widgets, widget)
+		}
+	}
+	return widgets, nil
+}
+
+// Widget returns a widget the viewer can read; widgets in other workspaces or private to
+// another user are reported as not found.
+func (s *Service) Widget(ctx context.Context, viewer Viewer, id string) (Widget, error) {
+	widget, err := s.repo.GetWidget(ctx, id)
+	if err != nil {
+		return Widget{}, err
+	}
+	if !viewer.canRead(widget.Workspace, widget.Owner, widget.Shared) {
+		return Widget{}, ErrWidgetNotFound{ID: id}
+	}
+	return widget, nil
+}
+
+func (s *Service) CreateWidget(ctx context.Context, viewer Viewer, widget Widget) (Widget, error) {
+	if err := s.validateWidget(widget); err != nil {
+		return Widget{}, err
+	}
+	now := s.clock().UTC()
+	widget.ID = uuid.NewString()
+	widget.Workspace, widget.Owner = viewer.Workspace, viewer.User
+	widget.CreatedAt, widget.UpdatedAt = now, now
+	if err := s.repo.SaveWidget(ctx, widget); err != nil {
+		return Widget{}, err
+	}
+	return widget, nil
+}
+
+func (s *Service) UpdateWidget(ctx context.Context, viewer Viewer, id string, update Widget) (Widget, error) {
+	current, err := s.Widget(ctx, viewer, id)
+	if err != nil {
+		return Widget{}, err
+	}
+	if !viewer.canWrite(current.Workspace, current.Owner) {
+		return Widget{}, ErrForbidden{ID: id}
+	}
+	if err := s.validateWidget(update); err != nil {
+		return Widget{}, err
+	}
+	update.ID, update.Workspace, update.Owner, update.CreatedAt = current.ID, current.Workspace, current.Owner, current.CreatedAt
+	update.UpdatedAt = s.clock().UTC()
+	if err := s.repo.SaveWidget(ctx, update); err != nil {
+		return Widget{}, err
+	}
+	return update, nil
+}
+
+func (s *Service) DeleteWidget(ctx context.Context, viewer Viewer, id string) error {
+	widget, err := s.Widget(ctx, viewer, id)
+	if err != nil {
+		return err
+	}
+	if !viewer.canWrite(widget.Workspace, widget.Owner) {
+		return ErrForbidden{ID: id}
+	}
+	return s.repo.DeleteWidget(ctx, id)
+}
+
+// RunWidget evaluates a widget's query with the given variables.
+func (s *Service) RunWidget(ctx context.Context, viewer Viewer, id string, variables map[string]string) (PanelResult, error) {
+	widget, err := s.Widget(ctx, viewer, id)
+	if err != nil {
+		return PanelResult{}, err
+	}
+	return s.runPanel(ctx, widget, Panel{WidgetID: widget.ID, Title: widget.Name}, variables), nil
+}
+
+func (s *Service) Dashboards(ctx context.Context, viewer Viewer) ([]Dashboard, error) {
+	all, err := s.repo.ListDashboards(ctx, viewer.Workspace)
+	if err != nil {
+		return nil, err
+	}
+	dashboards := []Dashboard{}
+	for _, dashboard := range all {
+		if viewer.canRead(dashboard.Workspace, dashboard.Owner, dashboard.Shared) {
+			dashboards = append(dashboards, dashboard)
+		}
+	}
+	return dashboards, nil
+}
+
+func (s *Service) Dashboard(ctx context.Context, viewer Viewer, id string) (Dashboard, error) {
+	dashboard, err := s.repo.GetDashboard(ctx, id)
+	if err != nil {
+		return Dashboard{}, err
+	}
+	if !viewer.canRead(dashboard.Workspace, dashboard.Owner, dashboard.Shared) {
+		return Dashboard{}, ErrDashboardNotFound{ID: id}
+	}
+	return dashboard, nil
+}
+
+func (s *Service) CreateDashboard(ctx context.Context, viewer Viewer, dashboard Dashboard) (Dashboard, error) {
+	if err := s.validateDashboard(ctx, viewer, dashboard); err != nil {
+		return Dashboard{}, err
+	}
+	now := s.clock().UTC()
+	dashboard.ID = uuid.NewString()
+	dashboard.Workspace, dashboard.Owner = viewer.Workspace, viewer.User
+	dashboard.CreatedAt, dashboard.UpdatedAt = now, now
+	if dashboard.Panels == nil {
+		dashboard.Panels = []Panel{}
+	}
+	if err := s.repo.SaveDashboard(ctx, dashboard); err != nil {
+		return Dashboard{}, err
+	}
+	return dashboard, nil
+}
+
+func (s *Service) UpdateDashboard(ctx context.Context, viewer Viewer, id string, update Dashboard) (Dashboard, error) {
+	current, err := s.Dashboard(ctx, viewer, id)
+	if err != nil {
+		return Dashboard{}, err
+	}
+	if !viewer.canWrite(current.Workspace, current.Owner) {
+		return Dashboard{}, ErrForbidden{ID: id}
+	}
+	if err := s.validateDashboard(ctx, viewer, update); err != nil {
+		return Dashboard{}, err
+	}
+	update.ID, update.Workspace, update.Owner, update.CreatedAt = current.ID, current.Workspace, current.Owner, current.CreatedAt
+	update.UpdatedAt = s.clock().UTC()
+	if update.Panels == nil {
+		update.Panels = []Panel{}
+	}
+	if err := s.repo.SaveDashboard(ctx, update); err != nil {
+		return Dashboard{}, err
+	}
+	return update, nil
+}
+
+func (s *Service) DeleteDashboard(ctx context.Context, viewer Viewer, id string) error {
+	dashboard, err := s.Dashboard(ctx, viewer, id)
+	if err != nil {
+		return err
+	}
+	if !viewer.canWrite(dashboard.Workspace, dashboard.Owner) {
+		return ErrForbidden{ID: id}
+	}
+	return s.repo.DeleteDashboard(ctx, id)
+}
+
+// Render evaluates every panel of a dashboard concurrently. Variables override the dashboard's
+// defaults; a failing panel reports its error without failing the others.
+func (s *Service) Render(ctx context.Context, viewer Viewer, id string, variables map[string]string) (Rendered, error) {
+	dashboard, err := s.Dashboard(ctx, viewer, id)
+	if err != nil {
+		return Rendered{}, err
+	}
+	merged := maps.Clone(dashboard.Variables)
+	if merged == nil {
+		merged = map[string]string{}
+	}
+	maps.Copy(merged, variables)
+
+	rendered := Rendered{
+		Dashboard:  dashboard,
+		Variables:  merged,
+		Panels:     make([]PanelResult, len(dashboard.Panels)),
+		RenderedAt: s.clock().UTC(),
+	}
+	var wg sync.WaitGroup
+	for i, panel := range dashboard.Panels {
+		wg.Add(1)
+		go func(i int, panel Panel) {
+			defer wg.Done()
+			widget, err := s.Widget(ctx, viewer, panel.WidgetID)
+			if err != nil {
+				rendered.Panels[i] = PanelResult{Panel: panel, Error: err.Error()}
+				return
+			}
+			rendered.Panels[i] = s.runPanel(ctx, widget, panel, merged)
+		}(i, panel)
+	}
+	wg.Wait()
+	return rendered, nil
+}
+
+func (s *Service) runPanel(ctx context.Context, widget Widget, panel Panel, variables map[string]string) PanelResult {
+	result := PanelResult{Panel: panel, Widget: widget}
+	source, ok := s.sources[widget.Source]
+	if !ok {
+		result.Error = fmt.Sprintf("unknown source %q", widget.Source)
+		return result
+	}
+	params := maps.Clone(widget.Params)
+	if params == nil {
+		params = map[string]string{}
+	}
+	maps.Copy(params, panel.Params)
+	params, err := substitute(params, variables)
+	if err == nil {
+		err = checkRequired(source.Info(), params)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, panelTimeout)
+	defer cancel()
+	data, err := source.Query(queryCtx, params)
+	if err != nil {
+		s.logger.Warn("dashboard widget query failed", "widget", widget.ID, "source", widget.Source, "error", err)
+		result.Error = err.Error()
+		return result
+	}
+	result.Data = data
+	return result
+}
+
+func (s *Service) validateWidget(widget Widget) error {
+	if strings.TrimSpace(widget.Name) == "" {
+		return ErrInvalidRequest{Reason: "widget name is required"}
+	}
+	source, ok := s.sources[widget.Source]
+	if !ok {
+		return ErrInvalidRequest{Reason: fmt.Sprintf("unknown source %q", widget.Source)}
+	}
+	known := map[string]bool{}
+	for _, param := range source.Info().Params {
+		known[param.Name] = true
+	}
+	for name := range widget.Params {
+		if !known[name] {
+			return ErrInvalidRequest{Reason: fmt.Sprintf("source %s has no parameter %q", widget.Source, name)}
+		}
+	}
+	return nil
+}
+
+func (s *Service) validateDashboard(ctx context.Context, viewer Viewer, dashboard Dashboard) error {
+	if strings.TrimSpace(dashboard.Name) == "" {
+		return ErrInvalidRequest{Reason: "dashboard name is required"}
+	}
+	if len(dashboard.Panels) > maxPanels {
+		return ErrInvalidRequest{Reason: fmt.Sprintf("a dashboard can hold at most %d panels", maxPanels)}
+	}
+	for _, panel := range dashboard.Panels {
+		widget, err := s.Widget(ctx, viewer, panel.WidgetID)
+		var notFound ErrWidgetNotFound
+		if errors.As(err, &notFound) {
+			return ErrInvalidRequest{Reason: fmt.Sprintf("panel references unknown widget %q", panel.WidgetID)}
+		}
+		if err != nil {
+			return err
+		}
+		// Everyone who can see a shared dashboard must be able to see its widgets.
+		if dashboard.Shared && !widget.Shared {
+			return ErrInvalidRequest{Reason: fmt.Sprintf("widget %q must be shared before it can appear on a shared dashboard", widget.Name)}
+		}
+	}
+	return nil
+}
+
+// substitute replaces ${name} references in params with variable values.
+func substitute(params, variables map[string]string) (map[string]string, error) {
+	var missing []string
+	for name, value := range params {
+		params[name] = variablePattern.ReplaceAllStringFunc(value, func(ref string) string {
+			key := variablePattern.FindStringSubmatch(ref)[1]
+			replacement, ok := variables[key]
+			if !ok {
+				missing = append(missing, key)
+			}
+			return replacement
+		})
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("missing variables: %s", strings.Join(slices.Compact(missing), ", "))
+	}
+	return params, nil
+}
+
+func checkRequired(info SourceInfo, params map[string]string) error {
+	for _, param := range info.Params {
+		if param.Required && strings.TrimSpace(params[param.Name]) == "" {
+			return fmt.Errorf("parameter %q is required by %s", param.Name, info.Name)
+		}
+	}
+	return nil
+}
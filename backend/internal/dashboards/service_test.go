@@ -0,0 +1,90 @@
+package dashboards
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/charmbracelet/log"
+)
+
+func echoSource() Source {
+	return SourceFunc{
+		SourceInfo: SourceInfo{Name: "echo", Params: []SourceParam{{Name: "cluster", Required: true}, {Name: "namespace"}}},
+		Fn: func(ctx context.Context, params map[string]string) (any, error) {
+			return params, nil
+		},
+	}
+}
+
+func newTestService() *Service {
+	return NewService(NewMemoryRepository(), log.NewWithOptions(io.Discard, log.Options{}), echoSource())
+}
+
+func TestDashboardRenderSubstitutesVariables(t *testing.T) {
+	svc := newTestService()
+	ctx := context.Background()
+	alice := Viewer{User: "alice", Workspace: "payments"}
+
+	widget, err := svc.CreateWidget(ctx, alice, Widget{Name: "Pods", Source: "echo", Shared: true, Params: map[string]string{"cluster": "${cluster}", "namespace": "shop"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dashboard, err := svc.CreateDashboard(ctx, alice, Dashboard{
+		Name:      "Overview",
+		Shared:    true,
+		Variables: map[string]string{"cluster": "staging"},
+		Panels:    []Panel{{WidgetID: widget.ID}, {WidgetID: widget.ID, Params: map[string]string{"namespace": "ledger"}}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bob := Viewer{User: "bob", Workspace: "payments"}
+	rendered, err := svc.Render(ctx, bob, dashboard.ID, map[string]string{"cluster": "prod"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	first := rendered.Panels[0].Data.(map[string]string)
+	second := rendered.Panels[1].Data.(map[string]string)
+	if first["cluster"] != "prod" || first["namespace"] != "shop" || second["namespace"] != "ledger" {
+		t.Fatalf("unexpected panel params %v %v", first, second)
+	}
+
+	if _, err := svc.UpdateDashboard(ctx, bob, dashboard.ID, dashboard); !errors.As(err, &ErrForbidden{}) {
+		t.Fatalf("expected non-owner update to be forbidden, got %v", err)
+	}
+	if _, err := svc.Dashboard(ctx, Viewer{User: "carol", Workspace: "other"}, dashboard.ID); !errors.As(err, &ErrDashboardNotFound{}) {
+		t.Fatalf("expected dashboard to be hidden from other workspaces, got %v", err)
+	}
+}
+
+func TestDashboardValidation(t *testing.T) {
+	svc := newTestService()
+	ctx := context.Background()
+	alice := Viewer{User: "alice", Workspace: "payments"}
+
+	if _, err := svc.CreateWidget(ctx, alice, Widget{Name: "Bad", Source: "missing"}); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected unknown source to be rejected, got %v", err)
+	}
+	if _, err := svc.CreateWidget(ctx, alice, Widget{Name: "Bad", Source: "echo", Params: map[string]string{"bogus": "1"}}); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected unknown parameter to be rejected, got %v", err)
+	}
+
+	private, err := svc.CreateWidget(ctx, alice, Widget{Name: "Private", Source: "echo"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.CreateDashboard(ctx, alice, Dashboard{Name: "Team", Shared: true, Panels: []Panel{{WidgetID: private.ID}}}); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected private widget on shared dashboard to be rejected, got %v", err)
+	}
+	if widgets, _ := svc.Widgets(ctx, Viewer{User: "bob", Workspace: "payments"}); len(widgets) != 0 {
+		t.Fatalf("expected private widget to be hidden, got %+v", widgets)
+	}
+
+	result, err := svc.RunWidget(ctx, alice, private.ID, nil)
+	if err != nil || result.Error == "" {
+		t.Fatalf("expected missing required parameter to be reported on the panel, got %+v (%v)", result, err)
+	}
+}
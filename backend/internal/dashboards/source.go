@@ -0,0 +1,256 @@
+package dashboards
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/ai"
+	"github.com/pramodksahoo/kubechat/backend/internal/commands"
+	"github.com/pramodksahoo/kubechat/backend/internal/health"
+	"github.com/pramodksahoo/kubechat/backend/internal/inventory"
+	"github.com/pramodksahoo/kubechat/backend/internal/privacy"
+)
+
+// Source answers widget queries. Params come from the widget, with variables already substituted.
+type Source interface {
+	Info() SourceInfo
+	Query(ctx context.Context, params map[string]string) (any, error)
+}
+
+type SourceParam struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+}
+
+type SourceInfo struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Params      []SourceParam `json:"params"`
+}
+
+// SourceFunc adapts a function to the Source interface.
+type SourceFunc struct {
+	SourceInfo
+	Fn func(ctx context.Context, params map[string]string) (any, error)
+}
+
+func (s SourceFunc) Info() SourceInfo { return s.SourceInfo }
+
+func (s SourceFunc) Query(ctx context.Context, params map[string]string) (any, error) {
+	return s.Fn(ctx, params)
+}
+
+var clusterParams = []SourceParam{
+	{Name: "config", Description: "Kubeconfig name", Required: true},
+	{Name: "cluster", Description: "Cluster context", Required: true},
+}
+
+// HealthSource reports the component status summary shown on the public status page.
+func HealthSource(monitor interface{ Summary() health.Summary }) Source {
+	return SourceFunc{
+		SourceInfo: SourceInfo{Name: "cluster_health", Description: "Component status and uptime"},
+		Fn: func(ctx context.Context, params map[string]string) (any, error) {
+			return monitor.Summary(), nil
+		},
+	}
+}
+
+type RestartReader interface {
+	Restarts(ctx context.Context, config, cluster string, filter inventory.RestartFilter) (inventory.RestartReport, error)
+}
+
+// RestartsSource reports containers with high restart counts.
+func RestartsSource(reader RestartReader) Source {
+	return SourceFunc{
+		SourceInfo: SourceInfo{
+			Name:        "container_restarts",
+			Description: "Containers with high restart counts, their last termination reason and trend",
+			Params: append(clusterParams[:len(clusterParams):len(clusterParams)],
+				SourceParam{Name: "namespace", Description: "Namespace filter"},
+				SourceParam{Name: "min", Description: "Minimum restart count, defaults to 3"},
+				SourceParam{Name: "window", Description: "Trend window, defaults to 1h"},
+			),
+		},
+		Fn: func(ctx context.Context, params map[string]string) (any, error) {
+			filter := inventory.RestartFilter{Namespace: params["namespace"], MinRestarts: 3, Window: time.Hour}
+			if raw := params["min"]; raw != "" {
+				parsed, err := strconv.Atoi(raw)
+				if err != nil || parsed <= 0 {
+					return nil, fmt.Errorf("min must be a positive number")
+				}
+				filter.MinRestarts = int32(min(parsed, 1<<20))
+			}
+			window, err := durationParam(params, "window", time.Hour)
+			if err != nil {
+				return nil, err
+			}
+			filter.Window = window
+			return reader.Restarts(ctx, params["config"], params["cluster"], filter)
+		},
+	}
+}
+
+type ChangeReader interface {
+	Changes(ctx context.Context, config, cluster string, since time.Duration) (inventory.Changelog, error)
+}
+
+// ChangesSource reports workload changes within a window.
+func ChangesSource(reader ChangeReader) Source {
+	return SourceFunc{
+		SourceInfo: SourceInfo{
+			Name:        "workload_changes",
+			Description: "Workload and config map changes within a window",
+			Params:      append(clusterParams[:len(clusterParams):len(clusterParams)], SourceParam{Name: "since", Description: "Window, defaults to 24h"}),
+		},
+		Fn: func(ctx context.Context, params map[string]string) (any, error) {
+			since, err := durationParam(params, "since", 24*time.Hour)
+			if err != nil {
+				return nil, err
+			}
+			return reader.Changes(ctx, params["config"], params["cluster"], since)
+		},
+	}
+}
+
+type ExecutionLister interface {
+	ListByUser(ctx context.Context, userID string, limit, offset int) ([]commands.Execution, error)
+}
+
+type Bucket struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+	Users int    `json:"users"`
+}
+
+type ExecutionStats struct {
+	Since   time.Time `json:"since"`
+	GroupBy string    `json:"groupBy"`
+	Total   int       `json:"total"`
+	Buckets []Bucket  `json:"buckets"`
+	// Suppressed counts the buckets withheld for coming from too few users in aggregation-only mode.
+	Suppressed int `json:"suppressed,omitempty"`
+}
+
+// maxAnalyzedExecutions bounds how many recent executions an analytics widget reads.
+const maxAnalyzedExecutions = 5000
+
+// ExecutionsSource counts command executions, the audit trail of cluster changes, grouped by a field.
+// Under an aggregation-only policy, buckets built from too few distinct users are left out of both
+// the buckets and the total.
+func ExecutionsSource(lister ExecutionLister, policy privacy.Policy) Source {
+	return SourceFunc{
+		SourceInfo: SourceInfo{
+			Name:        "command_executions",
+			Description: "Command execution counts grouped by status, operation, user, cluster or namespace",
+			Params: []SourceParam{
+				{Name: "group_by", Description: "status, operation, user, cluster or namespace; defaults to status"},
+				{Name: "since", Description: "Window, defaults to 24h"},
+				{Name: "user", Description: "Only count executions requested by this user"},
+				{Name: "cluster", Description: "Only count executions against this cluster"},
+			},
+		},
+		Fn: func(ctx context.Context, params map[string]string) (any, error) {
+			since, err := durationParam(params, "since", 24*time.Hour)
+			if err != nil {
+				return nil, err
+			}
+			groupBy := params["group_by"]
+			if groupBy == "" {
+				groupBy = "status"
+			}
+			key, ok := executionGroups[groupBy]
+			if !ok {
+				return nil, fmt.Errorf("group_by must be one of status, operation, user, cluster or namespace")
+			}
+			executions, err := lister.ListByUser(ctx, params["user"], maxAnalyzedExecutions, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			stats := ExecutionStats{Since: time.Now().UTC().Add(-since), GroupBy: groupBy, Buckets: []Bucket{}}
+			groups := privacy.NewGroups()
+			for _, execution := range executions {
+				if execution.CreatedAt.Before(stats.Since) || params["cluster"] != "" && execution.Cluster != params["cluster"] {
+					continue
+				}
+				groups.Add(key(execution), execution.UserID)
+			}
+			released, suppressed := policy.Release(groups)
+			for _, group := range released {
+				stats.Buckets = append(stats.Buckets, Bucket{Key: group.Key, Count: group.Count, Users: group.Users})
+				stats.Total += group.Count
+			}
+			stats.Suppressed = suppressed
+			return stats, nil
+		},
+	}
+}
+
+var executionGroups = map[string]func(commands.Execution) string{
+	"status":    func(e commands.Execution) string { return string(e.Status) },
+	"operation": func(e commands.Execution) string { return string(e.Operation) },
+	"user":      func(e commands.Execution) string { return e.UserID },
+	"cluster":   func(e commands.Execution) string { return e.Cluster },
+	"namespace": func(e commands.Execution) string { return e.Resource.Namespace },
+}
+
+type ProviderCost struct {
+	Provider         string  `json:"provider"`
+	Model            string  `json:"model"`
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"promptTokens"`
+	CompletionTokens int     `json:"completionTokens"`
+	CostUSD          float64 `json:"costUsd"`
+	Users            int     `json:"users"`
+}
+
+type CostRollup struct {
+	TotalUSD  float64        `json:"totalUsd"`
+	Providers []ProviderCost `json:"providers"`
+	// Suppressed counts the providers withheld for serving too few users in aggregation-only mode.
+	Suppressed int `json:"suppressed,omitempty"`
+}
+
+// ModelCostSource rolls up model token usage and cost per provider since the server started.
+// Under an aggregation-only policy, providers that served too few distinct users are left out.
+func ModelCostSource(lister interface{ Providers() []ai.ProviderInfo }, policy privacy.Policy) Source {
+	return SourceFunc{
+		SourceInfo: SourceInfo{Name: "model_costs", Description: "Model token usage and estimated cost per provider"},
+		Fn: func(ctx context.Context, params map[string]string) (any, error) {
+			rollup := CostRollup{Providers: []ProviderCost{}}
+			for _, provider := range lister.Providers() {
+				if !policy.Releasable(provider.Usage.Users) {
+					rollup.Suppressed++
+					continue
+				}
+				rollup.Providers = append(rollup.Providers, ProviderCost{
+					Provider:         provider.Name,
+					Model:            provider.Model,
+					Requests:         provider.Usage.Requests,
+					PromptTokens:     provider.Usage.PromptTokens,
+					CompletionTokens: provider.Usage.CompletionTokens,
+					CostUSD:          provider.Usage.CostUSD,
+					Users:            provider.Usage.Users,
+				})
+				rollup.TotalUSD += provider.Usage.CostUSD
+			}
+			return rollup, nil
+		},
+	}
+}
+
+func durationParam(params map[string]string, name string, fallback time.Duration) (time.Duration, error) {
+	raw := strings.TrimSpace(params[name])
+	if raw == "" {
+		return fallback, nil
+	}
+	parsed, err := time.ParseDuration(raw)
+	if err != nil || parsed <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration such as 24h", name)
+	}
+	return min(parsed, 7*24*time.Hour), nil
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/XSAM/otelsql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Open connects to PostgreSQL through the pgx stdlib driver and verifies the connection. Queries
+// are traced as children of the span in their context.
+func Open(ctx context.Context, url string) (*sqlx.DB, error) {
+	conn, err := otelsql.Open("pgx", url,
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{OmitConnResetSession: true, OmitConnectorConnect: true, OmitRows: true}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	db := sqlx.NewDb(conn, "pgx")
+	db.SetMaxOpenConns(20)
+	db.SetMaxIdleConns(5)
+	db.SetConnMaxLifetime(30 * time.Minute)
+
+	pingCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := db.PingContext(pingCtx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ping database: %w", err)
+	}
+	return db, nil
+}
+
+// Migrate applies the *.sql files in migrations that have not yet been recorded
+// for component, in lexical order, each inside its own transaction.
+func Migrate(ctx context.Context, db *sqlx.DB, component string, migrations fs.FS) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		component TEXT NOT NULL,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY (component, name)
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	names, err := fs.Glob(migrations, "*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	var applied []string
+	if err := db.SelectContext(ctx, &applied, `SELECT name FROM schema_migrations WHERE component = $1`, component); err != nil {
+		return fmt.Errorf("load applied migrations: %w", err)
+	}
+	done := make(map[string]bool, len(applied))
+	for _, name := range applied {
+		done[name] = true
+	}
+
+	for _, name := range names {
+		if done[name] {
+			continue
+		}
+		body, err := fs.ReadFile(migrations, name)
+		if err != nil {
+			return err
+		}
+		if err := applyMigration(ctx, db, component, name, string(body)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyMigration(ctx context.Context, db *sqlx.DB, component, name, body string) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if strings.TrimSpace(body) != "" {
+		if _, err := tx.ExecContext(ctx, body); err != nil {
+			return fmt.Errorf("apply migration %s/%s: %w", component, name, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (component, name) VALUES ($1, $2)`, component, name); err != nil {
+		return fmt.Errorf("record migration %s/%s: %w", component, name, err)
+	}
+	return tx.Commit()
+}
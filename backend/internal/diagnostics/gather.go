@@ -0,0 +1,312 @@
+package diagnostics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/kubernetes"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// Evidence sources.
+const (
+	SourceDescribe = "describe"
+	SourceEvents   = "events"
+	SourceLogs     = "logs"
+	SourceMetrics  = "metrics"
+)
+
+const (
+	// logTailLines is how much of a container's log a finding quotes.
+	logTailLines = 30
+	// maxEvidenceEvents caps the events quoted per object, newest first.
+	maxEvidenceEvents = 10
+)
+
+// gatherer reads what playbooks need, listing pods and events once per run. A container is
+// claimed by the first playbook that reports it, so an out-of-memory crash loop is reported as
+// out of memory rather than twice.
+type gatherer struct {
+	client    kubernetes.Interface
+	metrics   metricsclient.Interface
+	namespace string
+	target    string
+	now       time.Time
+
+	pods    []corev1.Pod
+	listed  bool
+	events  map[string][]corev1.Event
+	claimed map[string]bool
+}
+
+func newGatherer(client kubernetes.Interface, metrics metricsclient.Interface, namespace, target string, now time.Time) *gatherer {
+	return &gatherer{
+		client:    client,
+		metrics:   metrics,
+		namespace: namespace,
+		target:    target,
+		now:       now,
+		events:    map[string][]corev1.Event{},
+		claimed:   map[string]bool{},
+	}
+}
+
+// targetPods returns the pods in scope: those of the namespace, named after the target when set.
+func (g *gatherer) targetPods(ctx context.Context) ([]corev1.Pod, error) {
+	if g.listed {
+		return g.pods, nil
+	}
+	list, err := g.client.CoreV1().Pods(g.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range list.Items {
+		if g.target == "" || pod.Name == g.target || strings.HasPrefix(pod.Name, g.target+"-") {
+			g.pods = append(g.pods, pod)
+		}
+	}
+	sort.Slice(g.pods, func(i, j int) bool {
+		if g.pods[i].Namespace != g.pods[j].Namespace {
+			return g.pods[i].Namespace < g.pods[j].Namespace
+		}
+		return g.pods[i].Name < g.pods[j].Name
+	})
+	g.listed = true
+	return g.pods, nil
+}
+
+// claim reports whether no earlier playbook reported the container, and claims it.
+func (g *gatherer) claim(object Object, container string) bool {
+	key := object.Kind + "/" + object.Namespace + "/" + object.Name + "/" + container
+	if g.claimed[key] {
+		return false
+	}
+	g.claimed[key] = true
+	return true
+}
+
+// eventsFor returns the events recorded for the object, newest first. Node events are recorded
+// outside any namespace the run is scoped to, so they are listed across namespaces.
+func (g *gatherer) eventsFor(ctx context.Context, object Object) ([]corev1.Event, error) {
+	namespace := object.Namespace
+	if object.Kind == "Node" {
+		namespace = metav1.NamespaceAll
+	}
+	all, ok := g.events[namespace]
+	if !ok {
+		list, err := g.client.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		all = list.Items
+		g.events[namespace] = all
+	}
+	var matched []corev1.Event
+	for _, event := range all {
+		if event.InvolvedObject.Kind == object.Kind && event.InvolvedObject.Name == object.Name &&
+			(object.Kind == "Node" || event.Namespace == object.Namespace) {
+			matched = append(matched, event)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return eventTime(matched[i]).After(eventTime(matched[j])) })
+	return matched, nil
+}
+
+// eventEvidence quotes the object's events, and returns their messages for playbooks to read the
+// cause from.
+func (g *gatherer) eventEvidence(ctx context.Context, object Object) (Evidence, string) {
+	events, err := g.eventsFor(ctx, object)
+	if err != nil {
+		return Evidence{Source: SourceEvents, Content: fmt.Sprintf("unavailable: %v", err)}, ""
+	}
+	if len(events) == 0 {
+		return Evidence{Source: SourceEvents, Content: "<none>"}, ""
+	}
+	var (
+		buf      bytes.Buffer
+		messages []string
+	)
+	w := tabwriter.NewWriter(&buf, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tREASON\tAGE\tCOUNT\tMESSAGE")
+	for i, event := range events {
+		messages = append(messages, event.Reason+": "+event.Message)
+		if i < maxEvidenceEvents {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", event.Type, event.Reason, g.age(eventTime(event)), max(event.Count, 1), strings.TrimSpace(event.Message))
+		}
+	}
+	w.Flush()
+	return Evidence{Source: SourceEvents, Content: buf.String()}, strings.Join(messages, "\n")
+}
+
+// logEvidence quotes the end of the container's log; previous reads the log of the instance that
+// last terminated, which is the one that explains a crash.
+func (g *gatherer) logEvidence(ctx context.Context, pod corev1.Pod, container string, previous bool) Evidence {
+	tail := int64(logTailLines)
+	raw, err := g.client.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		Container: container,
+		Previous:  previous,
+		TailLines: &tail,
+	}).DoRaw(ctx)
+	if err != nil {
+		return Evidence{Source: SourceLogs, Content: fmt.Sprintf("unavailable: %v", err)}
+	}
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return Evidence{Source: SourceLogs, Content: "<empty>"}
+	}
+	return Evidence{Source: SourceLogs, Content: string(raw)}
+}
+
+// podMetricsEvidence reports the container's current usage against its limits.
+func (g *gatherer) podMetricsEvidence(ctx context.Context, pod corev1.Pod, container string) Evidence {
+	if g.metrics == nil {
+		return Evidence{Source: SourceMetrics, Content: "unavailable: the cluster does not serve the metrics API"}
+	}
+	usage, err := g.metrics.MetricsV1beta1().PodMetricses(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+	if err != nil {
+		return Evidence{Source: SourceMetrics, Content: fmt.Sprintf("unavailable: %v", err)}
+	}
+	limits := corev1.ResourceList{}
+	for _, spec := range pod.Spec.Containers {
+		if spec.Name == container {
+			limits = spec.Resources.Limits
+		}
+	}
+	for _, current := range usage.Containers {
+		if current.Name != container {
+			continue
+		}
+		return Evidence{Source: SourceMetrics, Content: fmt.Sprintf("cpu %s (limit %s), memory %s (limit %s)",
+			quantity(current.Usage, corev1.ResourceCPU), quantity(limits, corev1.ResourceCPU),
+			quantity(current.Usage, corev1.ResourceMemory), quantity(limits, corev1.ResourceMemory))}
+	}
+	return Evidence{Source: SourceMetrics, Content: "no usage reported for the container; it is not running"}
+}
+
+// nodeMetricsEvidence reports the node's current usage against what it can allocate.
+func (g *gatherer) nodeMetricsEvidence(ctx context.Context, node corev1.Node) Evidence {
+	if g.metrics == nil {
+		return Evidence{Source: SourceMetrics, Content: "unavailable: the cluster does not serve the metrics API"}
+	}
+	usage, err := g.metrics.MetricsV1beta1().NodeMetricses().Get(ctx, node.Name, metav1.GetOptions{})
+	if err != nil {
+		return Evidence{Source: SourceMetrics, Content: fmt.Sprintf("unavailable: %v", err)}
+	}
+	return Evidence{Source: SourceMetrics, Content: fmt.Sprintf("cpu %s of %s allocatable, memory %s of %s allocatable",
+		quantity(usage.Usage, corev1.ResourceCPU), quantity(node.Status.Allocatable, corev1.ResourceCPU),
+		quantity(usage.Usage, corev1.ResourceMemory), quantity(node.Status.Allocatable, corev1.ResourceMemory))}
+}
+
+// describePod summarises the pod the way kubectl describe does, for the container in question.
+func (g *gatherer) describePod(pod corev1.Pod, container string) Evidence {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Pod %s/%s\nNode: %s\nStatus: %s\n", pod.Namespace, pod.Name, orNone(pod.Spec.NodeName), pod.Status.Phase)
+	for _, condition := range pod.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			fmt.Fprintf(&b, "Condition %s: %s %s %s\n", condition.Type, condition.Status, condition.Reason, strings.TrimSpace(condition.Message))
+		}
+	}
+	for _, spec := range append(append([]corev1.Container(nil), pod.Spec.InitContainers...), pod.Spec.Containers...) {
+		if container != "" && spec.Name != container {
+			continue
+		}
+		fmt.Fprintf(&b, "Container %s:\n  Image: %s\n", spec.Name, spec.Image)
+		if requests := resources(spec.Resources.Requests); requests != "" {
+			fmt.Fprintf(&b, "  Requests: %s\n", requests)
+		}
+		if limits := resources(spec.Resources.Limits); limits != "" {
+			fmt.Fprintf(&b, "  Limits: %s\n", limits)
+		}
+		if status, ok := containerStatus(pod, spec.Name); ok {
+			fmt.Fprintf(&b, "  State: %s\n", state(status.State))
+			if status.LastTerminationState.Terminated != nil {
+				fmt.Fprintf(&b, "  Last State: %s\n", state(status.LastTerminationState))
+			}
+			fmt.Fprintf(&b, "  Ready: %t\n  Restart Count: %d\n", status.Ready, status.RestartCount)
+		}
+	}
+	return Evidence{Source: SourceDescribe, Content: b.String()}
+}
+
+// describeNode summarises the node's conditions and taints.
+func describeNode(node corev1.Node) Evidence {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Node %s\nUnschedulable: %t\n", node.Name, node.Spec.Unschedulable)
+	for _, condition := range node.Status.Conditions {
+		fmt.Fprintf(&b, "Condition %s: %s %s %s\n", condition.Type, condition.Status, condition.Reason, strings.TrimSpace(condition.Message))
+	}
+	for _, taint := range node.Spec.Taints {
+		fmt.Fprintf(&b, "Taint: %s=%s:%s\n", taint.Key, taint.Value, taint.Effect)
+	}
+	return Evidence{Source: SourceDescribe, Content: b.String()}
+}
+
+func containerStatus(pod corev1.Pod, name string) (corev1.ContainerStatus, bool) {
+	for _, status := range append(append([]corev1.ContainerStatus(nil), pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...) {
+		if status.Name == name {
+			return status, true
+		}
+	}
+	return corev1.ContainerStatus{}, false
+}
+
+func state(s corev1.ContainerState) string {
+	switch {
+	case s.Waiting != nil:
+		return strings.TrimSpace("Waiting (" + s.Waiting.Reason + ") " + s.Waiting.Message)
+	case s.Terminated != nil:
+		return fmt.Sprintf("Terminated (%s), exit code %d, finished %s", s.Terminated.Reason, s.Terminated.ExitCode, s.Terminated.FinishedAt.UTC().Format(time.RFC3339))
+	case s.Running != nil:
+		return "Running since " + s.Running.StartedAt.UTC().Format(time.RFC3339)
+	}
+	return "Unknown"
+}
+
+func resources(list corev1.ResourceList) string {
+	var parts []string
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		if value, ok := list[name]; ok {
+			parts = append(parts, fmt.Sprintf("%s %s", name, value.String()))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func quantity(list corev1.ResourceList, name corev1.ResourceName) string {
+	if value, ok := list[name]; ok {
+		return value.String()
+	}
+	return "none"
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
+
+// eventTime is when an event was last seen, falling back to when it was first recorded.
+func eventTime(event corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+func (g *gatherer) age(at time.Time) string {
+	if at.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(g.now.Sub(at))
+}
@@ -0,0 +1,464 @@
+package diagnostics
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type Severity string
+
+const (
+	SeverityCritical Severity = "critical"
+	SeverityWarning  Severity = "warning"
+)
+
+var severityRank = map[Severity]int{SeverityWarning: 1, SeverityCritical: 2}
+
+// Playbook recognises one failure and explains each occurrence of it.
+type Playbook struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// Gathers are the evidence sources the playbook quotes in its findings.
+	Gathers []string `json:"gathers"`
+
+	intent *regexp.Regexp
+	run    func(ctx context.Context, g *gatherer) ([]Finding, error)
+}
+
+// Playbooks are the built-in playbooks, in the order they run. The more specific ones run first
+// and claim the containers they report.
+var Playbooks = []Playbook{
+	{
+		ID:          "image-pull-back-off",
+		Title:       "Image cannot be pulled",
+		Description: "Containers waiting in ImagePullBackOff or ErrImagePull, with the registry's answer",
+		Gathers:     []string{SourceDescribe, SourceEvents},
+		intent:      regexp.MustCompile(`(?i)image\s*pull|errimagepull|pull(?:ing)?\s+(?:the\s+|its\s+)?image`),
+		run:         imagePullBackOff,
+	},
+	{
+		ID:          "oom-killed",
+		Title:       "Container killed for running out of memory",
+		Description: "Containers whose current or last instance was OOMKilled, with their usage against their limit",
+		Gathers:     []string{SourceDescribe, SourceEvents, SourceMetrics},
+		intent:      regexp.MustCompile(`(?i)\boom|out\s+of\s+memory|memory\s+limit`),
+		run:         oomKilled,
+	},
+	{
+		ID:          "crash-loop-back-off",
+		Title:       "Container keeps crashing",
+		Description: "Containers waiting in CrashLoopBackOff, with how their last instance exited and what it logged",
+		Gathers:     []string{SourceDescribe, SourceEvents, SourceLogs},
+		intent:      regexp.MustCompile(`(?i)crash\s*-?\s*loop|keeps?\s+(?:on\s+)?(?:crashing|restarting)|restart\s+loop`),
+		run:         crashLoopBackOff,
+	},
+	{
+		ID:          "pending-pods",
+		Title:       "Pod cannot be scheduled",
+		Description: "Pending pods the scheduler cannot place, with the scheduler's reasons",
+		Gathers:     []string{SourceDescribe, SourceEvents},
+		intent:      regexp.MustCompile(`(?i)\bpending\b|not\s+(?:being\s+)?scheduled|unschedulable|won'?t\s+schedule`),
+		run:         pendingPods,
+	},
+	{
+		ID:          "node-not-ready",
+		Title:       "Node not ready",
+		Description: "Nodes whose Ready condition is not True, with their pressure conditions and usage",
+		Gathers:     []string{SourceDescribe, SourceEvents, SourceMetrics},
+		intent:      regexp.MustCompile(`(?i)nodes?\b.*\bnot\s*-?\s*ready|notready`),
+		run:         nodeNotReady,
+	},
+}
+
+// diagnosticIntent matches prompts that ask what is wrong rather than for a change.
+var diagnosticIntent = regexp.MustCompile(`(?i)\b(?:why|troubleshoot\w*|diagnos\w*|debug\w*|investigate|what'?s\s+wrong|what\s+is\s+wrong|failing|broken|not\s+working|unhealthy)\b`)
+
+// Find returns the built-in playbook with the ID.
+func Find(id string) (Playbook, bool) {
+	for _, playbook := range Playbooks {
+		if playbook.ID == id {
+			return playbook, true
+		}
+	}
+	return Playbook{}, false
+}
+
+// Select returns the playbooks a prompt asks about: those whose failure it names, or every
+// playbook when it only asks what is wrong. It returns none for prompts that are not diagnostic.
+func Select(prompt string) []Playbook {
+	var named []Playbook
+	for _, playbook := range Playbooks {
+		if playbook.intent.MatchString(prompt) {
+			named = append(named, playbook)
+		}
+	}
+	if len(named) > 0 {
+		return named
+	}
+	if diagnosticIntent.MatchString(prompt) {
+		return Playbooks
+	}
+	return nil
+}
+
+func podObject(pod corev1.Pod) Object {
+	return Object{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name}
+}
+
+// containerStatuses are the pod's init and app container statuses.
+func containerStatuses(pod corev1.Pod) []corev1.ContainerStatus {
+	return append(append([]corev1.ContainerStatus(nil), pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+}
+
+func imagePullBackOff(ctx context.Context, g *gatherer) ([]Finding, error) {
+	pods, err := g.targetPods(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, pod := range pods {
+		for _, status := range containerStatuses(pod) {
+			waiting := status.State.Waiting
+			if waiting == nil || (waiting.Reason != "ImagePullBackOff" && waiting.Reason != "ErrImagePull" && waiting.Reason != "InvalidImageName") {
+				continue
+			}
+			object := podObject(pod)
+			if !g.claim(object, status.Name) {
+				continue
+			}
+			events, messages := g.eventEvidence(ctx, object)
+			cause, recommendations := imagePullCause(status.Image, waiting.Message+"\n"+messages)
+			findings = append(findings, Finding{
+				Playbook:        "image-pull-back-off",
+				Severity:        SeverityCritical,
+				Object:          object,
+				Container:       status.Name,
+				Problem:         fmt.Sprintf("Container %s cannot pull image %s (%s)", status.Name, status.Image, waiting.Reason),
+				Cause:           cause,
+				Evidence:        []Evidence{g.describePod(pod, status.Name), events},
+				Recommendations: recommendations,
+			})
+		}
+	}
+	return findings, nil
+}
+
+func imagePullCause(image, messages string) (string, []string) {
+	lower := strings.ToLower(messages)
+	switch {
+	case containsAny(lower, "unauthorized", "authentication required", "access denied", "denied:", "403 forbidden", "no basic auth credentials"):
+		return fmt.Sprintf("The registry refused to serve %s without valid credentials.", image), []string{
+			"Create a docker-registry Secret with credentials for the registry and list it under imagePullSecrets in the pod spec or its service account",
+			"Check that the credentials in the existing pull secret have not expired",
+		}
+	case containsAny(lower, "not found", "manifest unknown", "does not exist", "repository does not exist"):
+		return fmt.Sprintf("The registry has no image %s; the repository or tag does not exist.", image), []string{
+			"Check the image name and tag for typos and that the tag was pushed",
+			"Roll the workload back to the last image that pulled successfully",
+		}
+	case containsAny(lower, "invalid reference format", "invalidimagename"):
+		return fmt.Sprintf("%s is not a valid image reference.", image), []string{"Fix the image reference in the pod spec"}
+	case containsAny(lower, "no such host", "i/o timeout", "connection refused", "tls handshake timeout", "context deadline exceeded"):
+		return fmt.Sprintf("The node could not reach the registry serving %s.", image), []string{
+			"Check DNS resolution and egress from the nodes to the registry",
+			"Check for a proxy or firewall between the nodes and the registry",
+		}
+	case containsAny(lower, "toomanyrequests", "rate limit"):
+		return fmt.Sprintf("The registry rate-limited pulls of %s.", image), []string{
+			"Authenticate pulls with an imagePullSecret or mirror the image to a registry you control",
+		}
+	}
+	return fmt.Sprintf("Pulling %s keeps failing; the events carry the registry's answer.", image), []string{
+		"Read the Failed events for the registry's error message",
+		"Pull the image from a workstation with the same credentials to reproduce the failure",
+	}
+}
+
+func oomKilled(ctx context.Context, g *gatherer) ([]Finding, error) {
+	pods, err := g.targetPods(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, pod := range pods {
+		for _, status := range containerStatuses(pod) {
+			terminated := status.State.Terminated
+			if terminated == nil || terminated.Reason != "OOMKilled" {
+				terminated = status.LastTerminationState.Terminated
+			}
+			if terminated == nil || terminated.Reason != "OOMKilled" {
+				continue
+			}
+			object := podObject(pod)
+			if !g.claim(object, status.Name) {
+				continue
+			}
+			events, _ := g.eventEvidence(ctx, object)
+			limit := memoryLimit(pod, status.Name)
+			cause := fmt.Sprintf("Container %s used more than its memory limit of %s and was killed.", status.Name, limit)
+			recommendations := []string{
+				"Raise the container's memory limit above its peak usage, or lower the memory the application uses, for example a JVM heap or cache size",
+				"Compare the usage in the metrics with the limit to see whether usage grows steadily, which points to a leak",
+			}
+			if limit == "none" {
+				cause = fmt.Sprintf("Container %s has no memory limit and was killed when its node ran out of memory.", status.Name)
+				recommendations = []string{
+					"Set a memory request so the scheduler reserves the memory the container needs, and a limit to contain it",
+					"Check the node for other workloads using more memory than they request",
+				}
+			}
+			findings = append(findings, Finding{
+				Playbook:        "oom-killed",
+				Severity:        SeverityCritical,
+				Object:          object,
+				Container:       status.Name,
+				Problem:         fmt.Sprintf("Container %s was OOMKilled (exit code %d) and has restarted %d times", status.Name, terminated.ExitCode, status.RestartCount),
+				Cause:           cause,
+				Evidence:        []Evidence{g.describePod(pod, status.Name), events, g.podMetricsEvidence(ctx, pod, status.Name)},
+				Recommendations: recommendations,
+			})
+		}
+	}
+	return findings, nil
+}
+
+func memoryLimit(pod corev1.Pod, container string) string {
+	for _, spec := range append(append([]corev1.Container(nil), pod.Spec.InitContainers...), pod.Spec.Containers...) {
+		if spec.Name == container {
+			return quantity(spec.Resources.Limits, corev1.ResourceMemory)
+		}
+	}
+	return "none"
+}
+
+func crashLoopBackOff(ctx context.Context, g *gatherer) ([]Finding, error) {
+	pods, err := g.targetPods(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, pod := range pods {
+		for _, status := range containerStatuses(pod) {
+			if waiting := status.State.Waiting; waiting == nil || waiting.Reason != "CrashLoopBackOff" {
+				continue
+			}
+			object := podObject(pod)
+			if !g.claim(object, status.Name) {
+				continue
+			}
+			events, messages := g.eventEvidence(ctx, object)
+			cause, recommendations := crashCause(status, memoryLimit(pod, status.Name), messages)
+			findings = append(findings, Finding{
+				Playbook:        "crash-loop-back-off",
+				Severity:        SeverityCritical,
+				Object:          object,
+				Container:       status.Name,
+				Problem:         fmt.Sprintf("Container %s is in CrashLoopBackOff after %d restarts", status.Name, status.RestartCount),
+				Cause:           cause,
+				Evidence:        []Evidence{g.describePod(pod, status.Name), events, g.logEvidence(ctx, pod, status.Name, true)},
+				Recommendations: recommendations,
+			})
+		}
+	}
+	return findings, nil
+}
+
+// crashCause explains a crash loop from how the last instance exited and the pod's events.
+func crashCause(status corev1.ContainerStatus, limit, messages string) (string, []string) {
+	lower := strings.ToLower(messages)
+	switch {
+	case strings.Contains(lower, "liveness probe failed"):
+		return fmt.Sprintf("The kubelet restarts container %s because its liveness probe keeps failing.", status.Name), []string{
+			"Check that the probe's port and path match what the application serves",
+			"Give slow-starting applications a startupProbe or a longer initialDelaySeconds",
+		}
+	}
+	terminated := status.LastTerminationState.Terminated
+	if terminated == nil {
+		return fmt.Sprintf("Container %s keeps exiting; its last exit was not recorded.", status.Name), []string{
+			"Read the container's logs for the error it exits with",
+		}
+	}
+	if terminated.Reason == "OOMKilled" {
+		return fmt.Sprintf("Container %s runs out of memory: it was OOMKilled for exceeding its memory limit of %s.", status.Name, limit), []string{
+			"Raise the container's memory limit above its peak usage, or lower the memory the application uses",
+		}
+	}
+	switch terminated.ExitCode {
+	case 126, 127:
+		return fmt.Sprintf("Container %s exits with code %d: its command cannot be found or executed in the image.", status.Name, terminated.ExitCode), []string{
+			"Check the command and args in the pod spec against the image's entrypoint",
+			"Check that the binary exists in the image and is executable",
+		}
+	case 137:
+		return fmt.Sprintf("Container %s was killed with SIGKILL (exit code 137) without running out of memory, which usually means a probe or the kubelet stopped it.", status.Name), []string{
+			"Check the events for failing probes or evictions",
+		}
+	case 143:
+		return fmt.Sprintf("Container %s exited on SIGTERM (exit code 143); something keeps stopping it.", status.Name), []string{
+			"Check the events for failing probes, and the application for shutting itself down",
+		}
+	case 0:
+		return fmt.Sprintf("Container %s exits successfully, but containers of long-running pods are restarted whenever they exit.", status.Name), []string{
+			"Run the process in the foreground so the container keeps running",
+			"Use a Job instead if the work is meant to finish",
+		}
+	}
+	cause := fmt.Sprintf("Container %s exits with code %d", status.Name, terminated.ExitCode)
+	if terminated.Reason != "" && terminated.Reason != "Error" {
+		cause += " (" + terminated.Reason + ")"
+	}
+	return cause + "; the application fails at startup and its logs show why.", []string{
+		"Read the logs of the previous instance for the error it exits with",
+		"Check configuration the application reads at startup, such as environment variables, mounted files and the services it connects to",
+	}
+}
+
+func pendingPods(ctx context.Context, g *gatherer) ([]Finding, error) {
+	pods, err := g.targetPods(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodPending {
+			continue
+		}
+		var scheduled *corev1.PodCondition
+		for i := range pod.Status.Conditions {
+			if pod.Status.Conditions[i].Type == corev1.PodScheduled {
+				scheduled = &pod.Status.Conditions[i]
+			}
+		}
+		if scheduled == nil || scheduled.Status != corev1.ConditionFalse {
+			continue
+		}
+		object := podObject(pod)
+		if !g.claim(object, "") {
+			continue
+		}
+		events, messages := g.eventEvidence(ctx, object)
+		cause, recommendations := schedulingCause(scheduled.Message + "\n" + messages)
+		findings = append(findings, Finding{
+			Playbook:        "pending-pods",
+			Severity:        SeverityWarning,
+			Object:          object,
+			Problem:         fmt.Sprintf("Pod has been pending for %s: %s", g.age(pod.CreationTimestamp.Time), strings.TrimSpace(scheduled.Message)),
+			Cause:           cause,
+			Evidence:        []Evidence{g.describePod(pod, ""), events},
+			Recommendations: recommendations,
+		})
+	}
+	return findings, nil
+}
+
+func schedulingCause(messages string) (string, []string) {
+	lower := strings.ToLower(messages)
+	switch {
+	case containsAny(lower, "insufficient cpu", "insufficient memory", "insufficient nvidia", "too many pods"):
+		return "No node has enough unreserved capacity for the pod's resource requests.", []string{
+			"Lower the pod's CPU or memory requests if they exceed what it uses",
+			"Add nodes, or let the cluster autoscaler add them",
+		}
+	case containsAny(lower, "unbound immediate persistentvolumeclaims", "persistentvolumeclaim", "volume node affinity conflict"):
+		return "The pod waits for a PersistentVolumeClaim that is not bound, or bound to a volume its nodes cannot reach.", []string{
+			"Check the claim's status and that its StorageClass can provision volumes",
+			"Check that the volume's zone matches nodes the pod may run on",
+		}
+	case strings.Contains(lower, "taint"):
+		return "The nodes with room for the pod carry taints the pod does not tolerate.", []string{
+			"Add a toleration for the taint if the pod belongs on those nodes",
+			"Remove a taint left behind by maintenance, such as node.kubernetes.io/unschedulable",
+		}
+	case containsAny(lower, "node affinity", "node selector", "nodeselector"):
+		return "No node matches the pod's node selector or node affinity.", []string{
+			"Check the pod's nodeSelector and affinity against the labels of the nodes",
+		}
+	case containsAny(lower, "pod affinity", "anti-affinity"):
+		return "The pod's affinity or anti-affinity rules leave no node it may run on.", []string{
+			"Relax required anti-affinity to preferred, or add nodes in the topology the rules need",
+		}
+	}
+	return "The scheduler cannot place the pod; its FailedScheduling events say why.", []string{
+		"Read the FailedScheduling events for the scheduler's reasons per node",
+	}
+}
+
+func nodeNotReady(ctx context.Context, g *gatherer) ([]Finding, error) {
+	nodes, err := g.client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, node := range nodes.Items {
+		var ready *corev1.NodeCondition
+		var pressure []string
+		for i, condition := range node.Status.Conditions {
+			switch condition.Type {
+			case corev1.NodeReady:
+				ready = &node.Status.Conditions[i]
+			case corev1.NodeMemoryPressure, corev1.NodeDiskPressure, corev1.NodePIDPressure, corev1.NodeNetworkUnavailable:
+				if condition.Status == corev1.ConditionTrue {
+					pressure = append(pressure, string(condition.Type))
+				}
+			}
+		}
+		if ready != nil && ready.Status == corev1.ConditionTrue {
+			continue
+		}
+		object := Object{Kind: "Node", Name: node.Name}
+		if !g.claim(object, "") {
+			continue
+		}
+		events, _ := g.eventEvidence(ctx, object)
+		problem := fmt.Sprintf("Node %s is not ready", node.Name)
+		cause := fmt.Sprintf("Node %s reports no Ready condition.", node.Name)
+		if ready != nil {
+			problem = fmt.Sprintf("Node %s has been %s since %s", node.Name, readyState(ready.Status), ready.LastTransitionTime.UTC().Format("2006-01-02 15:04 MST"))
+			cause = fmt.Sprintf("Node %s: %s", node.Name, strings.TrimSpace(ready.Reason+" "+ready.Message))
+		}
+		recommendations := []string{
+			"Check that the kubelet and container runtime are running on the node and can reach the API server",
+			"Cordon and drain the node if it does not recover, so its pods are rescheduled",
+		}
+		if ready != nil && ready.Status == corev1.ConditionUnknown {
+			cause = fmt.Sprintf("The kubelet on node %s stopped reporting its status; the node may be down or cut off from the control plane.", node.Name)
+		}
+		if len(pressure) > 0 {
+			cause += fmt.Sprintf(" The node reports %s.", strings.Join(pressure, ", "))
+			recommendations = append([]string{"Free the resource under pressure, for example by removing unused images or evicting pods that use more than they request"}, recommendations...)
+		}
+		findings = append(findings, Finding{
+			Playbook:        "node-not-ready",
+			Severity:        SeverityCritical,
+			Object:          object,
+			Problem:         problem,
+			Cause:           cause,
+			Evidence:        []Evidence{describeNode(node), events, g.nodeMetricsEvidence(ctx, node)},
+			Recommendations: recommendations,
+		})
+	}
+	return findings, nil
+}
+
+func readyState(status corev1.ConditionStatus) string {
+	if status == corev1.ConditionUnknown {
+		return "unreachable"
+	}
+	return "NotReady"
+}
+
+func containsAny(s string, substrings ...string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+	return false
+}
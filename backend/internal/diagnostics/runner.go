@@ -0,0 +1,206 @@
+// Package diagnostics runs troubleshooting playbooks against a cluster. Each playbook recognises
+// one common failure, gathers the describe output, events, logs and metrics that explain it, and
+// reports the likely cause and what to do about it.
+package diagnostics
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/plan"
+	"k8s.io/client-go/kubernetes"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// ClientFunc returns the clients of a config/context pair. The metrics clientset is nil when the
+// cluster has no metrics API; playbooks then report without usage.
+type ClientFunc func(config, cluster string) (kubernetes.Interface, metricsclient.Interface, error)
+
+// ClusterResolver maps the cluster a plan names to its config and context.
+type ClusterResolver interface {
+	Resolve(ctx context.Context, ref string) (config, cluster string, err error)
+}
+
+type ErrUnknownPlaybook struct {
+	ID string
+}
+
+func (e ErrUnknownPlaybook) Error() string {
+	return fmt.Sprintf("unknown diagnostics playbook %q", e.ID)
+}
+
+// Cluster is a config/context pair to diagnose; Name is what users call it, such as "prod".
+type Cluster struct {
+	Name    string
+	Config  string
+	Context string
+}
+
+// Request selects what to diagnose. Pod playbooks look at the pods of Namespace, every namespace
+// when it is empty, and only at pods named after Target when it is set, such as the pods of a
+// deployment. Node playbooks look at every node. All playbooks run when Playbooks is empty.
+type Request struct {
+	Namespace string
+	Target    string
+	Playbooks []string
+}
+
+// Object is the object a finding is about.
+type Object struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// Evidence is one piece of what a playbook gathered, such as a pod's events.
+type Evidence struct {
+	Source  string `json:"source"`
+	Content string `json:"content"`
+}
+
+// Finding is one occurrence of the failure a playbook recognises.
+type Finding struct {
+	Playbook  string   `json:"playbook"`
+	Severity  Severity `json:"severity"`
+	Object    Object   `json:"object"`
+	Container string   `json:"container,omitempty"`
+	// Problem says what is wrong; Cause says why, as far as the evidence shows.
+	Problem         string     `json:"problem"`
+	Cause           string     `json:"cause"`
+	Evidence        []Evidence `json:"evidence"`
+	Recommendations []string   `json:"recommendations"`
+}
+
+// Report is what the playbooks found, most severe first.
+type Report struct {
+	Cluster   string    `json:"cluster"`
+	Namespace string    `json:"namespace,omitempty"`
+	Target    string    `json:"target,omitempty"`
+	Playbooks []string  `json:"playbooks"`
+	Findings  []Finding `json:"findings"`
+	Summary   string    `json:"summary"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+type Runner struct {
+	clients  ClientFunc
+	clusters ClusterResolver
+	clock    func() time.Time
+}
+
+func NewRunner(clients ClientFunc, clusters ClusterResolver) *Runner {
+	return &Runner{clients: clients, clusters: clusters, clock: func() time.Time { return time.Now().UTC() }}
+}
+
+// Run runs the requested playbooks against the cluster.
+func (r *Runner) Run(ctx context.Context, cluster Cluster, req Request) (Report, error) {
+	playbooks, err := selected(req.Playbooks)
+	if err != nil {
+		return Report{}, err
+	}
+	client, metrics, err := r.clients(cluster.Config, cluster.Context)
+	if err != nil {
+		return Report{}, err
+	}
+	g := newGatherer(client, metrics, req.Namespace, req.Target, r.clock())
+	report := Report{Cluster: cluster.Name, Namespace: req.Namespace, Target: req.Target, Findings: []Finding{}, CheckedAt: g.now}
+	for _, playbook := range playbooks {
+		findings, err := playbook.run(ctx, g)
+		if err != nil {
+			return Report{}, fmt.Errorf("run the %s playbook: %w", playbook.ID, err)
+		}
+		report.Playbooks = append(report.Playbooks, playbook.ID)
+		report.Findings = append(report.Findings, findings...)
+	}
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		return severityRank[report.Findings[i].Severity] > severityRank[report.Findings[j].Severity]
+	})
+	report.Summary = summarize(report)
+	return report, nil
+}
+
+// Diagnose runs the playbooks a diagnostic prompt asks about against the plan's cluster and
+// namespace, narrowed to the workload the plan targets. It returns nil for prompts that are not
+// diagnostic.
+func (r *Runner) Diagnose(ctx context.Context, draft plan.PlanDraft) (*Report, error) {
+	playbooks := Select(draft.Prompt)
+	if len(playbooks) == 0 {
+		return nil, nil
+	}
+	config, kubeContext, err := r.clusters.Resolve(ctx, draft.TargetCluster)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(playbooks))
+	for i, playbook := range playbooks {
+		ids[i] = playbook.ID
+	}
+	report, err := r.Run(ctx, Cluster{Name: draft.TargetCluster, Config: config, Context: kubeContext}, Request{
+		Namespace: draft.TargetNamespace,
+		Target:    planTarget(draft),
+		Playbooks: ids,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// planTarget is the workload or pod the plan's steps name, if any.
+func planTarget(draft plan.PlanDraft) string {
+	for _, step := range draft.Steps {
+		kind, name, ok := strings.Cut(step.Target.Resource, "/")
+		if !ok || strings.Contains(name, "<") {
+			continue
+		}
+		switch kind {
+		case "deployments", "statefulsets", "daemonsets", "jobs", "pods":
+			return name
+		}
+	}
+	return ""
+}
+
+// selected returns the playbooks ids names, in their built-in order, or all of them.
+func selected(ids []string) ([]Playbook, error) {
+	if len(ids) == 0 {
+		return Playbooks, nil
+	}
+	for _, id := range ids {
+		if _, ok := Find(id); !ok {
+			return nil, ErrUnknownPlaybook{ID: id}
+		}
+	}
+	var playbooks []Playbook
+	for _, playbook := range Playbooks {
+		if slices.Contains(ids, playbook.ID) {
+			playbooks = append(playbooks, playbook)
+		}
+	}
+	return playbooks, nil
+}
+
+func summarize(report Report) string {
+	if len(report.Findings) == 0 {
+		return fmt.Sprintf("No problems found by %s.", strings.Join(report.Playbooks, ", "))
+	}
+	counts := map[string]int{}
+	for _, finding := range report.Findings {
+		counts[finding.Playbook]++
+	}
+	var parts []string
+	for _, id := range report.Playbooks {
+		if n := counts[id]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, id))
+		}
+	}
+	noun := "findings"
+	if len(report.Findings) == 1 {
+		noun = "finding"
+	}
+	return fmt.Sprintf("%d %s: %s. %s", len(report.Findings), noun, strings.Join(parts, ", "), report.Findings[0].Cause)
+}
@@ -0,0 +1,154 @@
+package diagnostics
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/plan"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
+)
+
+type staticClusters struct{}
+
+func (staticClusters) Resolve(ctx context.Context, ref string) (string, string, error) {
+	return "kubeconfig", ref, nil
+}
+
+func pod(name string, statuses ...corev1.ContainerStatus) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop"},
+		Spec: corev1.PodSpec{NodeName: "node-1", Containers: []corev1.Container{{
+			Name:      "app",
+			Image:     "registry.example.com/shop/api:1.4",
+			Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")}},
+		}}},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: statuses},
+	}
+}
+
+func event(object, reason, message string) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: object + "." + strings.ToLower(reason), Namespace: "shop"},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: "shop", Name: object},
+		Type:           corev1.EventTypeWarning,
+		Reason:         reason,
+		Message:        message,
+		Count:          4,
+		LastTimestamp:  metav1.NewTime(time.Date(2026, 10, 1, 11, 58, 0, 0, time.UTC)),
+	}
+}
+
+func newTestRunner(objects ...*corev1.Pod) (*Runner, *fake.Clientset) {
+	client := fake.NewSimpleClientset()
+	for _, object := range objects {
+		_ = client.Tracker().Add(object)
+	}
+	metrics := metricsfake.NewSimpleClientset()
+	runner := NewRunner(func(config, cluster string) (kubernetes.Interface, metricsclient.Interface, error) {
+		return client, metrics, nil
+	}, staticClusters{})
+	runner.clock = func() time.Time { return time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC) }
+	_ = metrics.Tracker().Create(metricsv1beta1.SchemeGroupVersion.WithResource("pods"), &metricsv1beta1.PodMetrics{
+		ObjectMeta: metav1.ObjectMeta{Name: "api-7d9f-oom", Namespace: "shop"},
+		Containers: []metricsv1beta1.ContainerMetrics{{Name: "app", Usage: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("250Mi")}}},
+	}, "shop")
+	return runner, client
+}
+
+func TestRunReportsEachContainerOnceWithItsCause(t *testing.T) {
+	crashing := corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}
+	runner, client := newTestRunner(
+		pod("api-7d9f-oom", corev1.ContainerStatus{Name: "app", RestartCount: 6, State: crashing,
+			LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}}}),
+		pod("api-7d9f-cmd", corev1.ContainerStatus{Name: "app", RestartCount: 3, State: crashing,
+			LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 127}}}),
+		pod("api-7d9f-pull", corev1.ContainerStatus{Name: "app", Image: "registry.example.com/shop/api:1.5",
+			State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}}}),
+		pod("web-5c4b-ok", corev1.ContainerStatus{Name: "app", Ready: true, State: crashing}),
+	)
+	_ = client.Tracker().Add(event("api-7d9f-pull", "Failed", `Failed to pull image "registry.example.com/shop/api:1.5": manifest unknown`))
+	_ = client.Tracker().Add(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-2"},
+		Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+			{Type: corev1.NodeReady, Status: corev1.ConditionUnknown, Reason: "NodeStatusUnknown"},
+			{Type: corev1.NodeDiskPressure, Status: corev1.ConditionTrue},
+		}},
+	})
+
+	report, err := runner.Run(context.Background(), Cluster{Name: "prod"}, Request{Namespace: "shop", Target: "api"})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if len(report.Playbooks) != len(Playbooks) || len(report.Findings) != 4 {
+		t.Fatalf("expected four findings from every playbook, got %+v", report.Findings)
+	}
+	byObject := map[string]Finding{}
+	for _, finding := range report.Findings {
+		byObject[finding.Object.Name] = finding
+	}
+	if f := byObject["api-7d9f-oom"]; f.Playbook != "oom-killed" || !strings.Contains(f.Cause, "256Mi") || f.Evidence[2].Content != "cpu none (limit none), memory 250Mi (limit 256Mi)" {
+		t.Fatalf("expected the crash loop caused by OOM to be reported once as such, got %+v", f)
+	}
+	if f := byObject["api-7d9f-cmd"]; f.Playbook != "crash-loop-back-off" || !strings.Contains(f.Cause, "cannot be found") || f.Evidence[2].Source != SourceLogs {
+		t.Fatalf("unexpected crash loop finding %+v", f)
+	}
+	if f := byObject["api-7d9f-pull"]; !strings.Contains(f.Cause, "does not exist") || !strings.Contains(f.Evidence[1].Content, "manifest unknown") {
+		t.Fatalf("expected the registry's answer to explain the pull failure, got %+v", f)
+	}
+	if f := byObject["node-2"]; !strings.Contains(f.Cause, "stopped reporting") || !strings.Contains(f.Cause, "DiskPressure") {
+		t.Fatalf("unexpected node finding %+v", f)
+	}
+	if _, ok := byObject["web-5c4b-ok"]; ok {
+		t.Fatalf("expected pods outside the target to be left out")
+	}
+	if !strings.HasPrefix(report.Summary, "4 findings: 1 image-pull-back-off, 1 oom-killed, 1 crash-loop-back-off, 1 node-not-ready.") {
+		t.Fatalf("unexpected summary %q", report.Summary)
+	}
+
+	if _, err := runner.Run(context.Background(), Cluster{Name: "prod"}, Request{Playbooks: []string{"disk-full"}}); err == nil {
+		t.Fatalf("expected an unknown playbook to be refused")
+	}
+}
+
+func TestDiagnoseRunsThePlaybooksAPromptAsksAbout(t *testing.T) {
+	pending := pod("api-7d9f-new")
+	pending.Status = corev1.PodStatus{Phase: corev1.PodPending, Conditions: []corev1.PodCondition{{
+		Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: "Unschedulable",
+		Message: "0/3 nodes are available: 3 Insufficient memory.",
+	}}}
+	runner, _ := newTestRunner(pending)
+	draft := plan.PlanDraft{
+		Prompt:          "why is deployment api stuck pending?",
+		TargetCluster:   "prod",
+		TargetNamespace: "shop",
+		Steps:           []plan.PlanStep{{Target: plan.TargetDescriptor{Resource: "pods"}}, {Target: plan.TargetDescriptor{Resource: "deployments/api"}}},
+	}
+
+	report, err := runner.Diagnose(context.Background(), draft)
+	if err != nil {
+		t.Fatalf("diagnose: %v", err)
+	}
+	if report.Target != "api" || len(report.Playbooks) != 1 || report.Playbooks[0] != "pending-pods" {
+		t.Fatalf("expected only the pending playbook for the deployment, got %+v", report)
+	}
+	if len(report.Findings) != 1 || report.Findings[0].Severity != SeverityWarning || !strings.Contains(report.Findings[0].Cause, "unreserved capacity") {
+		t.Fatalf("unexpected findings %+v", report.Findings)
+	}
+
+	draft.Prompt = "scale deployment api to 3"
+	if report, err := runner.Diagnose(context.Background(), draft); report != nil || err != nil {
+		t.Fatalf("expected no diagnostics for a change, got %+v (%v)", report, err)
+	}
+	if playbooks := Select("what's wrong with the checkout service?"); len(playbooks) != len(Playbooks) {
+		t.Fatalf("expected a general question to run every playbook, got %d", len(playbooks))
+	}
+}
@@ -0,0 +1,66 @@
+package exports
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// encoder writes the records of an export in one format.
+type encoder interface {
+	Write(row []string, object any) error
+	// Close writes whatever the format holds back until every record is known.
+	Close() error
+}
+
+// report describes an export to the formats that print it alongside the records.
+type report struct {
+	Source    SourceInfo
+	Request   Request
+	Owner     string
+	Generated time.Time
+}
+
+func newEncoder(w io.Writer, r report) (encoder, error) {
+	switch r.Request.Format {
+	case FormatJSONL:
+		return jsonlEncoder{json.NewEncoder(w)}, nil
+	case FormatPDF:
+		return newPDFEncoder(w, r), nil
+	case FormatXLSX:
+		return newXLSXEncoder(w, r)
+	default:
+		return newCSVEncoder(w, r.Source.Columns)
+	}
+}
+
+type jsonlEncoder struct {
+	encoder *json.Encoder
+}
+
+func (e jsonlEncoder) Write(row []string, object any) error { return e.encoder.Encode(object) }
+
+func (e jsonlEncoder) Close() error { return nil }
+
+type csvEncoder struct {
+	writer *csv.Writer
+}
+
+func newCSVEncoder(w io.Writer, columns []string) (csvEncoder, error) {
+	e := csvEncoder{writer: csv.NewWriter(w)}
+	return e, e.writer.Write(columns)
+}
+
+func (e csvEncoder) Write(row []string, object any) error {
+	if err := e.writer.Write(row); err != nil {
+		return err
+	}
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (e csvEncoder) Close() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
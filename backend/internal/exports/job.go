@@ -0,0 +1,124 @@
+// Package exports produces large data exports as background jobs that write to object
+// storage and hand out time-limited download links.
+package exports
+
+import (
+	"fmt"
+	"time"
+)
+
+type Format string
+
+const (
+	FormatCSV   Format = "csv"
+	FormatJSONL Format = "jsonl"
+	// FormatPDF is a printable report with a cover page, per-user summaries and a signature
+	// block, meant for auditors rather than further processing.
+	FormatPDF Format = "pdf"
+	// FormatXLSX is an Excel workbook with the records on one sheet and the report filters on
+	// another.
+	FormatXLSX Format = "xlsx"
+)
+
+func (f Format) ContentType() string {
+	switch f {
+	case FormatJSONL:
+		return "application/x-ndjson"
+	case FormatPDF:
+		return "application/pdf"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv"
+}
+
+func (f Format) valid() bool {
+	switch f {
+	case FormatCSV, FormatJSONL, FormatPDF, FormatXLSX:
+		return true
+	}
+	return false
+}
+
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+// Request selects the source, format and range of an export.
+type Request struct {
+	Source string            `json:"source"`
+	Format Format            `json:"format"`
+	From   *time.Time        `json:"from,omitempty"`
+	To     *time.Time        `json:"to,omitempty"`
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// Contains reports whether at falls inside the requested range.
+func (r Request) Contains(at time.Time) bool {
+	if r.From != nil && at.Before(*r.From) {
+		return false
+	}
+	if r.To != nil && at.After(*r.To) {
+		return false
+	}
+	return true
+}
+
+type Progress struct {
+	Rows  int   `json:"rows"`
+	Bytes int64 `json:"bytes"`
+}
+
+type Job struct {
+	ID          string     `json:"id"`
+	Owner       string     `json:"owner"`
+	Request     Request    `json:"request"`
+	Status      Status     `json:"status"`
+	Progress    Progress   `json:"progress"`
+	Error       string     `json:"error,omitempty"`
+	FileName    string     `json:"fileName,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	// ExpiresAt is when the exported file is deleted.
+	ExpiresAt time.Time `json:"expiresAt"`
+	// DownloadURL is issued on every read of a finished job and stops working at DownloadExpiresAt.
+	DownloadURL       string     `json:"downloadUrl,omitempty"`
+	DownloadExpiresAt *time.Time `json:"downloadExpiresAt,omitempty"`
+	key               string
+}
+
+type ErrJobNotFound struct {
+	ID string
+}
+
+func (e ErrJobNotFound) Error() string {
+	return fmt.Sprintf("export %s not found", e.ID)
+}
+
+type ErrInvalidRequest struct {
+	Reason string
+}
+
+func (e ErrInvalidRequest) Error() string {
+	return e.Reason
+}
+
+// ErrQueueFull is returned when too many exports are waiting to run.
+type ErrQueueFull struct{}
+
+func (ErrQueueFull) Error() string {
+	return "too many exports are queued, try again later"
+}
+
+// ErrInvalidSignature is returned for download links that are forged or expired.
+type ErrInvalidSignature struct{}
+
+func (ErrInvalidSignature) Error() string {
+	return "download link is invalid or has expired"
+}
@@ -0,0 +1,366 @@
+package exports
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/pramodksahoo/kubechat/backend/internal/attachments"
+	"github.com/pramodksahoo/kubechat/backend/internal/privacy"
+)
+
+const (
+	// DownloadPath is where signed download links served by this server point.
+	DownloadPath = "/api/v1/exports"
+
+	defaultRetention = 24 * time.Hour
+	linkLifetime     = 15 * time.Minute
+	queueSize        = 32
+	workers          = 2
+	// progressEvery is how many rows are written between progress updates.
+	progressEvery = 1000
+)
+
+// Manager queues export jobs, runs them in the background and serves their results.
+// Job metadata is kept in memory; exported files live in the attachment store.
+type Manager struct {
+	store     attachments.Store
+	signer    *Signer
+	sources   map[string]Source
+	policy    privacy.Policy
+	retention time.Duration
+	queue     chan string
+	clock     func() time.Time
+	logger    *log.Logger
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+func NewManager(store attachments.Store, signer *Signer, logger *log.Logger, sources ...Source) *Manager {
+	if logger == nil {
+		logger = log.Default()
+	}
+	m := &Manager{
+		store:     store,
+		signer:    signer,
+		sources:   map[string]Source{},
+		retention: defaultRetention,
+		queue:     make(chan string, queueSize),
+		clock:     time.Now,
+		logger:    logger,
+		jobs:      map[string]*Job{},
+	}
+	for _, source := range sources {
+		m.sources[source.Info().Name] = source
+	}
+	return m
+}
+
+// SetPolicy applies the analytics export policy. Aggregation-only mode hides and refuses
+// user-level sources.
+func (m *Manager) SetPolicy(policy privacy.Policy) {
+	m.policy = policy
+}
+
+func (m *Manager) Sources() []SourceInfo {
+	infos := make([]SourceInfo, 0, len(m.sources))
+	for _, source := range m.sources {
+		info := source.Info()
+		if info.UserLevel && m.policy.AggregateOnly {
+			continue
+		}
+		if info.Params == nil {
+			info.Params = []string{}
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// QueueDepth is how many exports are waiting for a worker.
+func (m *Manager) QueueDepth() int {
+	return len(m.queue)
+}
+
+// Submit validates the request and queues the export.
+func (m *Manager) Submit(ctx context.Context, owner string, req Request) (Job, error) {
+	source, ok := m.sources[req.Source]
+	if !ok {
+		return Job{}, ErrInvalidRequest{Reason: fmt.Sprintf("unknown export source %q", req.Source)}
+	}
+	if source.Info().UserLevel && m.policy.AggregateOnly {
+		return Job{}, privacy.ErrUserLevel{Source: req.Source}
+	}
+	if req.Format == "" {
+		req.Format = FormatCSV
+	}
+	if !req.Format.valid() {
+		return Job{}, ErrInvalidRequest{Reason: "format must be csv, jsonl, pdf or xlsx"}
+	}
+	if req.From != nil && req.To != nil && req.To.Before(*req.From) {
+		return Job{}, ErrInvalidRequest{Reason: "to must not be before from"}
+	}
+
+	now := m.clock().UTC()
+	job := &Job{
+		ID:        uuid.NewString(),
+		Owner:     owner,
+		Request:   req,
+		Status:    StatusQueued,
+		CreatedAt: now,
+		ExpiresAt: now.Add(m.retention),
+	}
+	job.FileName = fmt.Sprintf("%s-%s.%s", req.Source, now.Format("20060102-150405"), req.Format)
+	job.key = "exports/" + job.ID + "/" + job.FileName
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	select {
+	case m.queue <- job.ID:
+	default:
+		return Job{}, ErrQueueFull{}
+	}
+	m.jobs[job.ID] = job
+	return *job, nil
+}
+
+// Get returns the caller's job. Finished jobs carry a fresh time-limited download URL.
+func (m *Manager) Get(ctx context.Context, owner, id string) (Job, error) {
+	m.mu.Lock()
+	job, ok := m.jobs[id]
+	if !ok || job.Owner != owner {
+		m.mu.Unlock()
+		return Job{}, ErrJobNotFound{ID: id}
+	}
+	snapshot := *job
+	m.mu.Unlock()
+
+	if snapshot.Status == StatusSucceeded {
+		expires := m.clock().Add(linkLifetime)
+		if expires.After(snapshot.ExpiresAt) {
+			expires = snapshot.ExpiresAt
+		}
+		link, err := m.downloadURL(ctx, snapshot, expires)
+		if err != nil {
+			return Job{}, err
+		}
+		snapshot.DownloadURL = link
+		snapshot.DownloadExpiresAt = &expires
+	}
+	return snapshot, nil
+}
+
+func (m *Manager) downloadURL(ctx context.Context, job Job, expires time.Time) (string, error) {
+	if presigner, ok := m.store.(attachments.Presigner); ok {
+		return presigner.PresignGet(ctx, job.key, expires.Sub(m.clock()))
+	}
+	return m.signer.URL(DownloadPath, job.ID, expires), nil
+}
+
+func (m *Manager) List(ctx context.Context, owner string) []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := []Job{}
+	for _, job := range m.jobs {
+		if job.Owner == owner {
+			jobs = append(jobs, *job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.After(jobs[j].CreatedAt) })
+	return jobs
+}
+
+// Delete removes a job and its file. Running jobs finish writing and are then discarded.
+func (m *Manager) Delete(ctx context.Context, owner, id string) error {
+	m.mu.Lock()
+	job, ok := m.jobs[id]
+	if !ok || job.Owner != owner {
+		m.mu.Unlock()
+		return ErrJobNotFound{ID: id}
+	}
+	delete(m.jobs, id)
+	key := job.key
+	m.mu.Unlock()
+	return m.store.Delete(ctx, key)
+}
+
+// Open verifies a signed download link and returns the job and its file.
+func (m *Manager) Open(ctx context.Context, id, expires, signature string) (Job, io.ReadCloser, error) {
+	if !m.signer.Verify(id, expires, signature, m.clock()) {
+		return Job{}, nil, ErrInvalidSignature{}
+	}
+	m.mu.Lock()
+	job, ok := m.jobs[id]
+	var snapshot Job
+	if ok {
+		snapshot = *job
+	}
+	m.mu.Unlock()
+	if !ok || snapshot.Status != StatusSucceeded {
+		return Job{}, nil, ErrJobNotFound{ID: id}
+	}
+	body, err := m.store.Open(ctx, snapshot.key)
+	if errors.Is(err, attachments.ErrNotFound) {
+		return Job{}, nil, ErrJobNotFound{ID: id}
+	}
+	return snapshot, body, err
+}
+
+// Run processes queued exports and deletes expired results until the context is cancelled.
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case id := <-m.queue:
+					m.process(ctx, id)
+				}
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+			m.reap(ctx)
+		}
+	}
+}
+
+func (m *Manager) reap(ctx context.Context) {
+	now := m.clock()
+	m.mu.Lock()
+	var expired []*Job
+	for id, job := range m.jobs {
+		if now.After(job.ExpiresAt) && job.Status != StatusRunning {
+			expired = append(expired, job)
+			delete(m.jobs, id)
+		}
+	}
+	m.mu.Unlock()
+	for _, job := range expired {
+		if err := m.store.Delete(ctx, job.key); err != nil {
+			m.logger.Warn("failed to delete expired export", "export", job.ID, "error", err)
+		}
+	}
+}
+
+func (m *Manager) update(id string, fn func(job *Job)) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	fn(job)
+	return *job, true
+}
+
+func (m *Manager) process(ctx context.Context, id string) {
+	job, ok := m.update(id, func(job *Job) {
+		started := m.clock().UTC()
+		job.Status = StatusRunning
+		job.StartedAt = &started
+	})
+	if !ok {
+		return
+	}
+
+	progress, err := m.write(ctx, job)
+	_, kept := m.update(id, func(job *Job) {
+		completed := m.clock().UTC()
+		job.CompletedAt = &completed
+		job.Progress = progress
+		if err != nil {
+			job.Status = StatusFailed
+			job.Error = err.Error()
+			return
+		}
+		job.Status = StatusSucceeded
+	})
+	if err != nil {
+		m.logger.Error("export failed", "export", id, "source", job.Request.Source, "error", err)
+		return
+	}
+	// The job was deleted while it ran, so nobody can download the file.
+	if !kept {
+		if err := m.store.Delete(ctx, job.key); err != nil {
+			m.logger.Warn("failed to delete discarded export", "export", id, "error", err)
+		}
+	}
+}
+
+// write streams the source into a temporary file and uploads it, so memory use does not
+// grow with the size of the export. PDF reports, whose size is capped, are the exception.
+func (m *Manager) write(ctx context.Context, job Job) (Progress, error) {
+	file, err := os.CreateTemp("", "kubechat-export-*")
+	if err != nil {
+		return Progress{}, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	source := m.sources[job.Request.Source]
+	buffered := bufio.NewWriter(file)
+	var progress Progress
+	encoder, err := newEncoder(buffered, report{Source: source.Info(), Request: job.Request, Owner: job.Owner, Generated: m.clock().UTC()})
+	if err != nil {
+		return Progress{}, err
+	}
+
+	err = source.Rows(ctx, job.Request, func(row []string, object any) error {
+		if err := encoder.Write(row, object); err != nil {
+			return err
+		}
+		progress.Rows++
+		if progress.Rows%progressEvery == 0 {
+			progress.Bytes = int64(buffered.Buffered())
+			if info, err := file.Stat(); err == nil {
+				progress.Bytes += info.Size()
+			}
+			m.update(job.ID, func(j *Job) { j.Progress = progress })
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		return progress, err
+	}
+	if err := encoder.Close(); err != nil {
+		return progress, err
+	}
+	if err := buffered.Flush(); err != nil {
+		return progress, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return progress, err
+	}
+	progress.Bytes = info.Size()
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return progress, err
+	}
+	if err := attachments.PutStream(ctx, m.store, job.key, job.Request.Format.ContentType(), file, info.Size()); err != nil {
+		return progress, fmt.Errorf("upload export: %w", err)
+	}
+	return progress, nil
+}
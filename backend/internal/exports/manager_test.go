@@ -0,0 +1,288 @@
+package exports
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pramodksahoo/kubechat/backend/internal/attachments"
+	"github.com/pramodksahoo/kubechat/backend/internal/audit"
+	"github.com/pramodksahoo/kubechat/backend/internal/commands"
+	"github.com/pramodksahoo/kubechat/backend/internal/privacy"
+)
+
+func numbersSource() Source {
+	return SourceFunc{
+		SourceInfo: SourceInfo{Name: "numbers", Columns: []string{"n", "label"}},
+		Fn: func(ctx context.Context, req Request, emit EmitFunc) error {
+			for i, label := range []string{"one", "two, three"} {
+				if err := emit([]string{string(rune('1' + i)), label}, map[string]any{"n": i + 1, "label": label}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	store, err := attachments.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return NewManager(store, NewSigner([]byte("secret")), log.NewWithOptions(io.Discard, log.Options{}), numbersSource())
+}
+
+func waitFor(t *testing.T, m *Manager, owner, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := m.Get(context.Background(), owner, id)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if job.Status == StatusSucceeded || job.Status == StatusFailed {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("export %s did not finish", id)
+	return Job{}
+}
+
+func TestExportWritesCSVAndServesSignedDownload(t *testing.T) {
+	m := newTestManager(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx, time.Minute)
+
+	job, err := m.Submit(ctx, "alice", Request{Source: "numbers"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	job = waitFor(t, m, "alice", job.ID)
+	if job.Status != StatusSucceeded || job.Progress.Rows != 2 {
+		t.Fatalf("unexpected job %+v", job)
+	}
+	if _, err := m.Get(ctx, "bob", job.ID); !errors.As(err, &ErrJobNotFound{}) {
+		t.Fatalf("expected other users not to see the export, got %v", err)
+	}
+
+	link, err := url.Parse(job.DownloadURL)
+	if err != nil || !strings.HasPrefix(link.Path, DownloadPath+"/"+job.ID) {
+		t.Fatalf("unexpected download url %q", job.DownloadURL)
+	}
+	query := link.Query()
+	_, body, err := m.Open(ctx, job.ID, query.Get("expires"), query.Get("signature"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer body.Close()
+	data, _ := io.ReadAll(body)
+	if want := "n,label\n1,one\n2,\"two, three\"\n"; string(data) != want {
+		t.Fatalf("expected %q, got %q", want, data)
+	}
+
+	if _, _, err := m.Open(ctx, job.ID, query.Get("expires"), "forged"); !errors.As(err, &ErrInvalidSignature{}) {
+		t.Fatalf("expected forged signature to be rejected, got %v", err)
+	}
+	m.clock = func() time.Time { return time.Now().Add(time.Hour) }
+	if _, _, err := m.Open(ctx, job.ID, query.Get("expires"), query.Get("signature")); !errors.As(err, &ErrInvalidSignature{}) {
+		t.Fatalf("expected expired link to be rejected, got %v", err)
+	}
+}
+
+func TestExportRejectsUnknownSourceAndFormat(t *testing.T) {
+	m := newTestManager(t)
+	if _, err := m.Submit(context.Background(), "alice", Request{Source: "chats"}); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected unknown source to be rejected, got %v", err)
+	}
+	if _, err := m.Submit(context.Background(), "alice", Request{Source: "numbers", Format: "parquet"}); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected unknown format to be rejected, got %v", err)
+	}
+}
+
+type executionList []commands.Execution
+
+func (l executionList) ListByUser(ctx context.Context, userID string, limit, offset int) ([]commands.Execution, error) {
+	if offset >= len(l) {
+		return nil, nil
+	}
+	return l[offset:min(offset+limit, len(l))], nil
+}
+
+func TestAggregateOnlyExportsSuppressSmallGroups(t *testing.T) {
+	store, err := attachments.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	policy := privacy.Policy{AggregateOnly: true, MinGroupSize: 2}
+	now := time.Now().UTC()
+	executions := executionList{
+		{ID: "1", UserID: "alice", Status: commands.StatusSucceeded, CreatedAt: now},
+		{ID: "2", UserID: "bob", Status: commands.StatusSucceeded, CreatedAt: now},
+		{ID: "3", UserID: "alice", Status: commands.StatusSucceeded, CreatedAt: now},
+		{ID: "4", UserID: "carol", Status: commands.StatusFailed, CreatedAt: now},
+	}
+	m := NewManager(store, NewSigner([]byte("secret")), log.NewWithOptions(io.Discard, log.Options{}),
+		ExecutionsSource(executions), ExecutionCountsSource(executions, policy))
+	m.SetPolicy(policy)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx, time.Minute)
+
+	if _, err := m.Submit(ctx, "alice", Request{Source: "command_executions"}); !errors.As(err, &privacy.ErrUserLevel{}) {
+		t.Fatalf("expected user-level exports to be refused, got %v", err)
+	}
+	if sources := m.Sources(); len(sources) != 1 || sources[0].Name != "command_execution_counts" {
+		t.Fatalf("expected only aggregate sources to be offered, got %+v", sources)
+	}
+
+	job, err := m.Submit(ctx, "alice", Request{Source: "command_execution_counts"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	job = waitFor(t, m, "alice", job.ID)
+	link, _ := url.Parse(job.DownloadURL)
+	_, body, err := m.Open(ctx, job.ID, link.Query().Get("expires"), link.Query().Get("signature"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer body.Close()
+	data, _ := io.ReadAll(body)
+	// The single failed execution came from one user and is suppressed.
+	if want := "day,group_by,key,executions,users\n" + now.Format(time.DateOnly) + ",status,succeeded,3,2\n"; string(data) != want {
+		t.Fatalf("expected %q, got %q", want, data)
+	}
+}
+
+func download(t *testing.T, m *Manager, job Job) []byte {
+	t.Helper()
+	link, _ := url.Parse(job.DownloadURL)
+	_, body, err := m.Open(context.Background(), job.ID, link.Query().Get("expires"), link.Query().Get("signature"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer body.Close()
+	data, _ := io.ReadAll(body)
+	return data
+}
+
+func TestExportWritesPDFReportAndXLSXWorkbook(t *testing.T) {
+	m := newTestManager(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx, time.Minute)
+
+	pdf, err := m.Submit(ctx, "alice", Request{Source: "numbers", Format: FormatPDF, Params: map[string]string{"label": "one"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pdf = waitFor(t, m, "alice", pdf.ID)
+	if pdf.Status != StatusSucceeded || !strings.HasSuffix(pdf.FileName, ".pdf") {
+		t.Fatalf("unexpected job %+v", pdf)
+	}
+	if data := download(t, m, pdf); !bytes.HasPrefix(data, []byte("%PDF-")) {
+		t.Fatalf("expected a PDF document, got %q", data[:min(len(data), 16)])
+	}
+
+	xlsx, err := m.Submit(ctx, "alice", Request{Source: "numbers", Format: FormatXLSX})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	xlsx = waitFor(t, m, "alice", xlsx.ID)
+	data := download(t, m, xlsx)
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected a zip archive: %v", err)
+	}
+	parts := map[string]string{}
+	for _, file := range archive.File {
+		reader, _ := file.Open()
+		content, _ := io.ReadAll(reader)
+		reader.Close()
+		parts[file.Name] = string(content)
+	}
+	for _, name := range []string{"[Content_Types].xml", "xl/workbook.xml", "xl/styles.xml", "xl/worksheets/sheet2.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Fatalf("expected workbook part %s, got %v", name, archive.File)
+		}
+	}
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<c r="A1" t="inlineStr" s="1"><is><t xml:space="preserve">n</t></is></c>`,
+		`<c r="A3"><v>2</v></c>`,
+		`<t xml:space="preserve">two, three</t>`,
+		`<autoFilter ref="A1:B3"/>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Fatalf("expected sheet to contain %s, got %s", want, sheet)
+		}
+	}
+}
+
+func TestPDFReportsAreCapped(t *testing.T) {
+	e := newPDFEncoder(io.Discard, report{Source: SourceInfo{Columns: []string{"n"}}})
+	for range pdfMaxRecords {
+		if err := e.Write([]string{"1"}, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := e.Write([]string{"1"}, nil); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected the record past the cap to be refused, got %v", err)
+	}
+}
+
+func TestXLSXColumnNames(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 701: "ZZ", 702: "AAA"} {
+		if got := xlsxColumn(i); got != want {
+			t.Fatalf("expected column %d to be %s, got %s", i, want, got)
+		}
+	}
+}
+
+func TestAuditLedgerSourceFiltersBySourceAndUser(t *testing.T) {
+	ledger := audit.NewLedger(audit.NewMemoryRepository(), log.NewWithOptions(io.Discard, log.Options{}))
+	ctx := context.Background()
+	for _, record := range []struct {
+		source audit.Source
+		entry  any
+	}{
+		{audit.SourceMFA, map[string]string{"actor": "admin", "user": "bob", "action": "reset"}},
+		{audit.SourceSignedRequests, map[string]string{"user": "alice", "outcome": "rejected", "method": "POST", "path": "/api/v1/admin/audit/anchors"}},
+		{audit.SourceApprovals, map[string]string{"actor": "carol", "onBehalfOf": "bob", "action": "approve", "reason": "covering"}},
+	} {
+		if err := ledger.Append(ctx, record.source, record.entry); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	var rows [][]string
+	collect := func(row []string, object any) error {
+		rows = append(rows, row)
+		return nil
+	}
+	source := AuditLedgerSource(ledger)
+	if err := source.Rows(ctx, Request{Params: map[string]string{"user": "bob"}}, collect); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 2 || rows[0][3] != "admin" || rows[0][4] != "bob" || rows[0][5] != "reset" || rows[1][6] != "covering" {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+
+	rows = nil
+	if err := source.Rows(ctx, Request{Params: map[string]string{"source": "signed_requests"}}, collect); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 1 || rows[0][3] != "alice" || rows[0][5] != "rejected" || rows[0][6] != "POST /api/v1/admin/audit/anchors" || rows[0][7] == "" {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+}
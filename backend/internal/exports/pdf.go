@@ -0,0 +1,312 @@
+package exports
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// pdfMaxRecords caps PDF reports, which are laid out in memory; larger exports belong in xlsx.
+const pdfMaxRecords = 5000
+
+const (
+	pdfMargin     = 15.0
+	pdfRowHeight  = 5.0
+	pdfTableFont  = 7.0
+	pdfCellMaxMM  = 70.0
+	pdfCellMinMM  = 12.0
+	pdfCellPadMM  = 2.0
+	pdfNoUserName = "(none)"
+)
+
+// pdfEncoder collects the records and lays out the report once they are all known: a cover page
+// describing the export, a summary per user, the records and a signature block.
+type pdfEncoder struct {
+	w      io.Writer
+	report report
+	rows   [][]string
+	// digest is the SHA-256 of the CSV export of the same records, printed on the cover so the
+	// report can be matched against the machine-readable export.
+	digest hash.Hash
+	csv    *csv.Writer
+}
+
+func newPDFEncoder(w io.Writer, r report) *pdfEncoder {
+	digest := sha256.New()
+	e := &pdfEncoder{w: w, report: r, digest: digest, csv: csv.NewWriter(digest)}
+	e.csv.Write(r.Source.Columns)
+	return e
+}
+
+func (e *pdfEncoder) Write(row []string, object any) error {
+	if len(e.rows) == pdfMaxRecords {
+		return ErrInvalidRequest{Reason: fmt.Sprintf("pdf reports are limited to %d records, narrow the range or export xlsx", pdfMaxRecords)}
+	}
+	e.rows = append(e.rows, slices.Clone(row))
+	return e.csv.Write(row)
+}
+
+func (e *pdfEncoder) Close() error {
+	e.csv.Flush()
+	if err := e.csv.Error(); err != nil {
+		return err
+	}
+	doc := fpdf.New("L", "mm", "A4", "")
+	doc.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	doc.SetAutoPageBreak(true, pdfMargin)
+	doc.SetCreationDate(e.report.Generated)
+	doc.SetCreator("KubeChat", false)
+	tr := doc.UnicodeTranslatorFromDescriptor("")
+	doc.SetTitle(tr(e.title()), false)
+	doc.AliasNbPages("")
+	doc.SetFooterFunc(func() {
+		doc.SetY(-pdfMargin + 4)
+		doc.SetFont("Helvetica", "", 7)
+		doc.SetTextColor(110, 110, 110)
+		doc.CellFormat(0, 4, tr(fmt.Sprintf("%s  -  generated %s", e.title(), e.report.Generated.Format(time.RFC3339))), "", 0, "L", false, 0, "")
+		doc.SetX(pdfMargin)
+		doc.CellFormat(0, 4, fmt.Sprintf("Page %d of {nb}", doc.PageNo()), "", 0, "R", false, 0, "")
+		doc.SetTextColor(0, 0, 0)
+	})
+
+	layout := pdfLayout{doc: doc, tr: tr}
+	e.cover(layout)
+	e.summary(layout)
+	e.records(layout)
+	e.signature(layout)
+	return doc.Output(e.w)
+}
+
+func (e *pdfEncoder) title() string {
+	return "KubeChat export: " + e.report.Source.Name
+}
+
+type pdfLayout struct {
+	doc *fpdf.Fpdf
+	tr  func(string) string
+}
+
+func (l pdfLayout) heading(text string) {
+	l.doc.SetFont("Helvetica", "B", 13)
+	l.doc.CellFormat(0, 9, l.tr(text), "", 1, "L", false, 0, "")
+	l.doc.Ln(1)
+}
+
+// field prints a label and its value on one line, wrapping long values.
+func (l pdfLayout) field(label, value string) {
+	l.doc.SetFont("Helvetica", "B", 9)
+	l.doc.CellFormat(45, 6, l.tr(label), "", 0, "L", false, 0, "")
+	l.doc.SetFont("Helvetica", "", 9)
+	l.doc.MultiCell(0, 6, l.tr(value), "", "L", false)
+}
+
+// fit shortens text until it fits width at the current font.
+func (l pdfLayout) fit(text string, width float64) string {
+	text = l.tr(text)
+	if l.doc.GetStringWidth(text) <= width {
+		return text
+	}
+	for len(text) > 0 && l.doc.GetStringWidth(text+"...") > width {
+		text = text[:len(text)-1]
+	}
+	return text + "..."
+}
+
+// table prints rows under a header that is repeated on every page the table runs onto.
+func (l pdfLayout) table(columns []string, widths []float64, rows [][]string) {
+	_, pageHeight := l.doc.GetPageSize()
+	header := func() {
+		l.doc.SetFont("Helvetica", "B", pdfTableFont)
+		l.doc.SetFillColor(225, 230, 238)
+		for i, column := range columns {
+			l.doc.CellFormat(widths[i], pdfRowHeight+1, l.fit(column, widths[i]-pdfCellPadMM), "1", 0, "L", true, 0, "")
+		}
+		l.doc.Ln(-1)
+		l.doc.SetFont("Helvetica", "", pdfTableFont)
+	}
+	header()
+	for n, row := range rows {
+		if l.doc.GetY()+pdfRowHeight > pageHeight-pdfMargin {
+			l.doc.AddPage()
+			header()
+		}
+		l.doc.SetFillColor(246, 247, 250)
+		for i := range columns {
+			l.doc.CellFormat(widths[i], pdfRowHeight, l.fit(cellAt(row, i), widths[i]-pdfCellPadMM), "1", 0, "L", n%2 == 1, 0, "")
+		}
+		l.doc.Ln(-1)
+	}
+}
+
+func (e *pdfEncoder) cover(l pdfLayout) {
+	l.doc.AddPage()
+	l.doc.SetFont("Helvetica", "B", 20)
+	l.doc.CellFormat(0, 12, l.tr(e.title()), "", 1, "L", false, 0, "")
+	if description := e.report.Source.Description; description != "" {
+		l.doc.SetFont("Helvetica", "", 11)
+		l.doc.MultiCell(0, 6, l.tr(description), "", "L", false)
+	}
+	l.doc.Ln(6)
+
+	req := e.report.Request
+	l.field("Generated", e.report.Generated.Format(time.RFC3339))
+	l.field("Requested by", e.report.Owner)
+	l.field("From", formatBound(req.From, "the first record"))
+	l.field("To", formatBound(req.To, "the last record"))
+	filters := make([]string, 0, len(req.Params))
+	for name, value := range req.Params {
+		filters = append(filters, name+" = "+value)
+	}
+	sort.Strings(filters)
+	if len(filters) == 0 {
+		filters = append(filters, "none")
+	}
+	l.field("Filters", strings.Join(filters, "\n"))
+	l.field("Records", fmt.Sprint(len(e.rows)))
+	l.field("SHA-256 of CSV", hex.EncodeToString(e.digest.Sum(nil)))
+}
+
+func formatBound(at *time.Time, open string) string {
+	if at == nil {
+		return open
+	}
+	return at.UTC().Format(time.RFC3339)
+}
+
+// summary counts the records of each user, broken down by status when the source has one.
+// Sources without a user column have no summary.
+func (e *pdfEncoder) summary(l pdfLayout) {
+	userColumn := slices.Index(e.report.Source.Columns, "user")
+	if userColumn < 0 {
+		return
+	}
+	statusColumn := slices.Index(e.report.Source.Columns, "status")
+	type userSummary struct {
+		user     string
+		records  int
+		statuses map[string]int
+	}
+	byUser := map[string]*userSummary{}
+	for _, row := range e.rows {
+		user := cellAt(row, userColumn)
+		if user == "" {
+			user = pdfNoUserName
+		}
+		summary, ok := byUser[user]
+		if !ok {
+			summary = &userSummary{user: user, statuses: map[string]int{}}
+			byUser[user] = summary
+		}
+		summary.records++
+		if statusColumn >= 0 {
+			summary.statuses[cellAt(row, statusColumn)]++
+		}
+	}
+	summaries := make([]*userSummary, 0, len(byUser))
+	for _, summary := range byUser {
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].records != summaries[j].records {
+			return summaries[i].records > summaries[j].records
+		}
+		return summaries[i].user < summaries[j].user
+	})
+
+	columns := []string{"User", "Records"}
+	widths := []float64{80, 25}
+	if statusColumn >= 0 {
+		pageWidth, _ := l.doc.GetPageSize()
+		columns = append(columns, "By status")
+		widths = append(widths, pageWidth-2*pdfMargin-105)
+	}
+	rows := make([][]string, 0, len(summaries))
+	for _, summary := range summaries {
+		row := []string{summary.user, fmt.Sprint(summary.records)}
+		if statusColumn >= 0 {
+			statuses := make([]string, 0, len(summary.statuses))
+			for status, count := range summary.statuses {
+				statuses = append(statuses, fmt.Sprintf("%s %d", status, count))
+			}
+			sort.Strings(statuses)
+			row = append(row, strings.Join(statuses, ", "))
+		}
+		rows = append(rows, row)
+	}
+
+	l.doc.AddPage()
+	l.heading("Summary by user")
+	l.table(columns, widths, rows)
+}
+
+func cellAt(row []string, i int) string {
+	if i < len(row) {
+		return row[i]
+	}
+	return ""
+}
+
+func (e *pdfEncoder) records(l pdfLayout) {
+	l.doc.AddPage()
+	l.heading("Records")
+	if len(e.rows) == 0 {
+		l.doc.SetFont("Helvetica", "I", 9)
+		l.doc.CellFormat(0, 6, "No records matched the filters.", "", 1, "L", false, 0, "")
+		return
+	}
+	l.table(e.report.Source.Columns, e.columnWidths(l), e.rows)
+}
+
+// columnWidths sizes each column by its longest cell, within bounds, and scales the columns to
+// the width of the page.
+func (e *pdfEncoder) columnWidths(l pdfLayout) []float64 {
+	l.doc.SetFont("Helvetica", "", pdfTableFont)
+	columns := e.report.Source.Columns
+	widths := make([]float64, len(columns))
+	for i, column := range columns {
+		widths[i] = l.doc.GetStringWidth(l.tr(column))
+		for _, row := range e.rows {
+			widths[i] = max(widths[i], l.doc.GetStringWidth(l.tr(cellAt(row, i))))
+		}
+		widths[i] = min(max(widths[i]+pdfCellPadMM, pdfCellMinMM), pdfCellMaxMM)
+	}
+	pageWidth, _ := l.doc.GetPageSize()
+	var total float64
+	for _, width := range widths {
+		total += width
+	}
+	scale := (pageWidth - 2*pdfMargin) / total
+	for i := range widths {
+		widths[i] *= scale
+	}
+	return widths
+}
+
+// signature leaves room for the reviewer to sign the printed report.
+func (e *pdfEncoder) signature(l pdfLayout) {
+	_, pageHeight := l.doc.GetPageSize()
+	if l.doc.GetY()+60 > pageHeight-pdfMargin {
+		l.doc.AddPage()
+	} else {
+		l.doc.Ln(10)
+	}
+	l.heading("Sign-off")
+	l.field("Prepared by", e.report.Owner+" on "+e.report.Generated.Format(time.DateOnly))
+	l.doc.Ln(4)
+	for _, label := range []string{"Reviewed by", "Signature", "Date"} {
+		l.doc.SetFont("Helvetica", "B", 9)
+		l.doc.CellFormat(45, 10, label, "", 0, "L", false, 0, "")
+		x, y := l.doc.GetX(), l.doc.GetY()
+		l.doc.Line(x, y+8, x+100, y+8)
+		l.doc.Ln(12)
+	}
+}
@@ -0,0 +1,443 @@
+package exports
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/ai"
+	"github.com/pramodksahoo/kubechat/backend/internal/audit"
+	"github.com/pramodksahoo/kubechat/backend/internal/commands"
+	"github.com/pramodksahoo/kubechat/backend/internal/compliance"
+	"github.com/pramodksahoo/kubechat/backend/internal/costs"
+	"github.com/pramodksahoo/kubechat/backend/internal/inventory"
+	"github.com/pramodksahoo/kubechat/backend/internal/privacy"
+)
+
+// EmitFunc receives one exported record: the CSV cells in column order and the object
+// written to JSON Lines exports.
+type EmitFunc func(row []string, object any) error
+
+// Source streams the records of one kind of export.
+type Source interface {
+	Info() SourceInfo
+	Rows(ctx context.Context, req Request, emit EmitFunc) error
+}
+
+type SourceInfo struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Columns     []string `json:"columns"`
+	Params      []string `json:"params"`
+	// UserLevel marks sources whose records describe individual users; aggregation-only mode
+	// refuses them.
+	UserLevel bool `json:"userLevel,omitempty"`
+	// Permission is the KubeChat permission required in every namespace to export the source,
+	// for records beyond those every user may read.
+	Permission string `json:"permission,omitempty"`
+}
+
+// SourceFunc adapts a function to the Source interface.
+type SourceFunc struct {
+	SourceInfo
+	Fn func(ctx context.Context, req Request, emit EmitFunc) error
+}
+
+func (s SourceFunc) Info() SourceInfo { return s.SourceInfo }
+
+func (s SourceFunc) Rows(ctx context.Context, req Request, emit EmitFunc) error {
+	return s.Fn(ctx, req, emit)
+}
+
+type ExecutionLister interface {
+	ListByUser(ctx context.Context, userID string, limit, offset int) ([]commands.Execution, error)
+}
+
+// executionPageSize is how many executions are read from the store at a time.
+const executionPageSize = 500
+
+// ExecutionsSource exports the command execution audit trail within the requested range.
+func ExecutionsSource(lister ExecutionLister) Source {
+	return SourceFunc{
+		SourceInfo: SourceInfo{
+			Name:        "command_executions",
+			Description: "Command executions with their requester, target, outcome and timing",
+			Columns:     []string{"id", "user", "config", "cluster", "operation", "kind", "namespace", "name", "safety", "status", "error", "created_at", "completed_at"},
+			Params:      []string{"user"},
+			UserLevel:   true,
+		},
+		Fn: func(ctx context.Context, req Request, emit EmitFunc) error {
+			for offset := 0; ; offset += executionPageSize {
+				page, err := lister.ListByUser(ctx, req.Params["user"], executionPageSize, offset)
+				if err != nil {
+					return err
+				}
+				for _, execution := range page {
+					// Pages are ordered newest first, so nothing older than From can follow.
+					if req.From != nil && execution.CreatedAt.Before(*req.From) {
+						return nil
+					}
+					if !req.Contains(execution.CreatedAt) {
+						continue
+					}
+					completed := ""
+					if execution.CompletedAt != nil {
+						completed = execution.CompletedAt.UTC().Format(time.RFC3339)
+					}
+					row := []string{
+						execution.ID, execution.UserID, execution.Config, execution.Cluster, string(execution.Operation),
+						execution.Resource.Kind, execution.Resource.Namespace, execution.Resource.Name,
+						string(execution.SafetyLevel), string(execution.Status), execution.Error,
+						execution.CreatedAt.UTC().Format(time.RFC3339), completed,
+					}
+					if err := emit(row, execution); err != nil {
+						return err
+					}
+				}
+				if len(page) < executionPageSize {
+					return nil
+				}
+			}
+		},
+	}
+}
+
+// ExecutionCountsSource exports daily command execution counts grouped by a field, the
+// aggregate alternative to the execution audit trail. Under an aggregation-only policy, groups
+// built from too few distinct users are left out.
+func ExecutionCountsSource(lister ExecutionLister, policy privacy.Policy) Source {
+	return SourceFunc{
+		SourceInfo: SourceInfo{
+			Name:        "command_execution_counts",
+			Description: "Daily command execution counts grouped by status, operation, cluster or namespace",
+			Columns:     []string{"day", "group_by", "key", "executions", "users"},
+			Params:      []string{"group_by"},
+		},
+		Fn: func(ctx context.Context, req Request, emit EmitFunc) error {
+			groupBy := req.Params["group_by"]
+			if groupBy == "" {
+				groupBy = "status"
+			}
+			key, ok := executionCountGroups[groupBy]
+			if !ok {
+				return ErrInvalidRequest{Reason: "group_by must be one of status, operation, cluster or namespace"}
+			}
+			groups := privacy.NewGroups()
+			for offset := 0; ; offset += executionPageSize {
+				page, err := lister.ListByUser(ctx, "", executionPageSize, offset)
+				if err != nil {
+					return err
+				}
+				done := len(page) < executionPageSize
+				for _, execution := range page {
+					if req.From != nil && execution.CreatedAt.Before(*req.From) {
+						done = true
+						break
+					}
+					if req.Contains(execution.CreatedAt) {
+						groups.Add(execution.CreatedAt.UTC().Format(time.DateOnly)+"\x00"+key(execution), execution.UserID)
+					}
+				}
+				if done {
+					break
+				}
+			}
+
+			released, _ := policy.Release(groups)
+			sort.Slice(released, func(i, j int) bool { return released[i].Key < released[j].Key })
+			for _, group := range released {
+				day, value, _ := strings.Cut(group.Key, "\x00")
+				row := []string{day, groupBy, value, strconv.Itoa(group.Count), strconv.Itoa(group.Users)}
+				object := map[string]any{"day": day, "groupBy": groupBy, "key": value, "executions": group.Count, "users": group.Users}
+				if err := emit(row, object); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+var executionCountGroups = map[string]func(commands.Execution) string{
+	"status":    func(e commands.Execution) string { return string(e.Status) },
+	"operation": func(e commands.Execution) string { return string(e.Operation) },
+	"cluster":   func(e commands.Execution) string { return e.Config + "/" + e.Cluster },
+	"namespace": func(e commands.Execution) string { return e.Resource.Namespace },
+}
+
+// ModelCostSource exports token usage and estimated cost per model provider. Under an
+// aggregation-only policy, providers that served too few distinct users are left out.
+func ModelCostSource(lister interface{ Providers() []ai.ProviderInfo }, policy privacy.Policy) Source {
+	return SourceFunc{
+		SourceInfo: SourceInfo{
+			Name:        "model_costs",
+			Description: "Model token usage and estimated cost per provider since the server started",
+			Columns:     []string{"provider", "model", "requests", "failures", "prompt_tokens", "completion_tokens", "cost_usd", "users"},
+		},
+		Fn: func(ctx context.Context, req Request, emit EmitFunc) error {
+			for _, provider := range lister.Providers() {
+				if !policy.Releasable(provider.Usage.Users) {
+					continue
+				}
+				row := []string{
+					provider.Name, provider.Model,
+					strconv.Itoa(provider.Usage.Requests), strconv.Itoa(provider.Usage.Failures),
+					strconv.Itoa(provider.Usage.PromptTokens), strconv.Itoa(provider.Usage.CompletionTokens),
+					strconv.FormatFloat(provider.Usage.CostUSD, 'f', 6, 64), strconv.Itoa(provider.Usage.Users),
+				}
+				if err := emit(row, provider); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+type ChargebackReporter interface {
+	Report(ctx context.Context, year int, month time.Month) (costs.ChargebackReport, error)
+}
+
+// ChargebackSource exports a month of model spend charged to departments, projects or users, with
+// spend no allocation rule matched flagged unallocated. The month param is YYYY-MM and defaults to
+// the previous month.
+func ChargebackSource(reporter ChargebackReporter) Source {
+	return SourceFunc{
+		SourceInfo: SourceInfo{
+			Name:        "model_chargeback",
+			Description: "A month of model spend charged to departments, projects or users by the allocation rules",
+			Columns:     costs.ChargebackColumns,
+			Params:      []string{"month", "group_by"},
+			UserLevel:   true,
+			Permission:  "cost:manage",
+		},
+		Fn: func(ctx context.Context, req Request, emit EmitFunc) error {
+			month := time.Now().UTC().AddDate(0, -1, 0)
+			if param := req.Params["month"]; param != "" {
+				parsed, err := time.Parse("2006-01", param)
+				if err != nil {
+					return ErrInvalidRequest{Reason: "the month param must be YYYY-MM"}
+				}
+				month = parsed
+			}
+			group := costs.ChargebackGroup(req.Params["group_by"])
+			switch group {
+			case "", costs.GroupByDepartment, costs.GroupByProject, costs.GroupByUser:
+			default:
+				return ErrInvalidRequest{Reason: "the group_by param must be department, project or user"}
+			}
+			report, err := reporter.Report(ctx, month.Year(), month.Month())
+			if errors.As(err, &costs.ErrInvalidRequest{}) {
+				return ErrInvalidRequest{Reason: err.Error()}
+			}
+			if err != nil {
+				return err
+			}
+			for _, line := range report.GroupBy(group) {
+				if err := emit(line.Row(report.Currency), line); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+type ChangeReader interface {
+	Changes(ctx context.Context, config, cluster string, since time.Duration) (inventory.Changelog, error)
+}
+
+// ChangesSource exports workload changes observed on a cluster since From.
+func ChangesSource(reader ChangeReader) Source {
+	return SourceFunc{
+		SourceInfo: SourceInfo{
+			Name:        "workload_changes",
+			Description: "Workload and config map changes on a cluster since the start of the range",
+			Columns:     []string{"kind", "namespace", "name", "type", "attribution", "details"},
+			Params:      []string{"config", "cluster"},
+		},
+		Fn: func(ctx context.Context, req Request, emit EmitFunc) error {
+			if req.Params["config"] == "" || req.Params["cluster"] == "" {
+				return ErrInvalidRequest{Reason: "workload_changes exports require the config and cluster params"}
+			}
+			since := 24 * time.Hour
+			if req.From != nil {
+				since = time.Since(*req.From)
+			}
+			changelog, err := reader.Changes(ctx, req.Params["config"], req.Params["cluster"], since)
+			if err != nil {
+				return err
+			}
+			for _, change := range changelog.Changes {
+				row := []string{change.Kind, change.Namespace, change.Name, string(change.Type), string(change.Attribution), fmt.Sprint(change.Details)}
+				if err := emit(row, change); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+type LedgerReader interface {
+	Records(ctx context.Context, after int64, limit int) ([]audit.Record, error)
+}
+
+// ledgerPageSize is how many ledger records are read at a time.
+const ledgerPageSize = 500
+
+// AuditLedgerSource exports the tamper-evident audit ledger, the records of every audit trail
+// with the hashes that chain them, for compliance reviews.
+func AuditLedgerSource(reader LedgerReader) Source {
+	return SourceFunc{
+		SourceInfo: SourceInfo{
+			Name:        "audit_ledger",
+			Description: "Impersonation, MFA, signed request and approval audit records with their ledger hashes",
+			Columns:     []string{"seq", "source", "at", "user", "subject", "action", "detail", "hash"},
+			Params:      []string{"source", "user"},
+			UserLevel:   true,
+			Permission:  "audit:read",
+		},
+		Fn: func(ctx context.Context, req Request, emit EmitFunc) error {
+			for after := int64(0); ; {
+				page, err := reader.Records(ctx, after, ledgerPageSize)
+				if err != nil {
+					return err
+				}
+				for _, record := range page {
+					after = record.Seq
+					if !req.Contains(record.At) || (req.Params["source"] != "" && string(record.Source) != req.Params["source"]) {
+						continue
+					}
+					entry := ledgerEntryOf(record)
+					if user := req.Params["user"]; user != "" && entry.user() != user && entry.subject() != user {
+						continue
+					}
+					row := []string{
+						strconv.FormatInt(record.Seq, 10), string(record.Source), record.At.UTC().Format(time.RFC3339),
+						entry.user(), entry.subject(), entry.action(record.Source), entry.detail(), record.Hash,
+					}
+					if err := emit(row, record); err != nil {
+						return err
+					}
+				}
+				if len(page) < ledgerPageSize {
+					return nil
+				}
+			}
+		},
+	}
+}
+
+// ledgerEntry holds the fields the audit trails copied into the ledger name alike.
+type ledgerEntry struct {
+	Actor      string `json:"actor"`
+	User       string `json:"user"`
+	Subject    string `json:"subject"`
+	OnBehalfOf string `json:"onBehalfOf"`
+	Action     string `json:"action"`
+	Outcome    string `json:"outcome"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	Reason     string `json:"reason"`
+}
+
+func ledgerEntryOf(record audit.Record) ledgerEntry {
+	var entry ledgerEntry
+	// Records that are not objects leave every field empty and are still exported.
+	_ = json.Unmarshal(record.Entry, &entry)
+	return entry
+}
+
+// user is who acted; MFA resets name both the admin and the user reset.
+func (e ledgerEntry) user() string {
+	if e.Actor != "" {
+		return e.Actor
+	}
+	return e.User
+}
+
+// subject is who was acted upon, when that is someone else.
+func (e ledgerEntry) subject() string {
+	for _, subject := range []string{e.Subject, e.OnBehalfOf, e.User} {
+		if subject != "" && subject != e.user() {
+			return subject
+		}
+	}
+	return ""
+}
+
+func (e ledgerEntry) action(source audit.Source) string {
+	switch {
+	case e.Action != "":
+		return e.Action
+	case e.Outcome != "":
+		return e.Outcome
+	case source == audit.SourceLedger:
+		return "reanchor"
+	}
+	return ""
+}
+
+func (e ledgerEntry) detail() string {
+	if e.Reason != "" {
+		return e.Reason
+	}
+	return strings.TrimSpace(e.Method + " " + e.Path)
+}
+
+type ComplianceAnalyzer interface {
+	Analyze(ctx context.Context, framework compliance.Framework, from, to *time.Time) (compliance.Report, error)
+}
+
+// ComplianceFindingsSource exports a framework's compliance report with one row per piece of
+// evidence, so auditors can filter the findings and follow each one to its records.
+func ComplianceFindingsSource(analyzer ComplianceAnalyzer) Source {
+	return SourceFunc{
+		SourceInfo: SourceInfo{
+			Name:        "compliance_findings",
+			Description: "SOX, HIPAA or SOC 2 control findings over the range with the evidence each was scored from",
+			Columns:     []string{"framework", "control", "requirement", "finding_status", "score", "subject", "detail", "evidence_status", "at"},
+			Params:      []string{"framework"},
+			UserLevel:   true,
+			Permission:  "audit:read",
+		},
+		Fn: func(ctx context.Context, req Request, emit EmitFunc) error {
+			if req.Params["framework"] == "" {
+				return ErrInvalidRequest{Reason: "compliance_findings exports require the framework param"}
+			}
+			report, err := analyzer.Analyze(ctx, compliance.Framework(req.Params["framework"]), req.From, req.To)
+			if errors.As(err, &compliance.ErrInvalidRequest{}) {
+				return ErrInvalidRequest{Reason: err.Error()}
+			}
+			if err != nil {
+				return err
+			}
+			for _, finding := range report.Findings {
+				for _, evidence := range finding.Evidence {
+					at := ""
+					if evidence.At != nil {
+						at = evidence.At.UTC().Format(time.RFC3339)
+					}
+					row := []string{
+						string(report.Framework), finding.Control, finding.Requirement, string(finding.Status), strconv.Itoa(finding.Score),
+						evidence.Subject, evidence.Detail, string(evidence.Status), at,
+					}
+					object := map[string]any{
+						"framework": report.Framework, "control": finding.Control, "requirement": finding.Requirement,
+						"findingStatus": finding.Status, "score": finding.Score, "evidence": evidence,
+					}
+					if err := emit(row, object); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,183 @@
+package exports
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// Limits Excel puts on a worksheet.
+const (
+	xlsxMaxRows      = 1 << 20
+	xlsxMaxCellChars = 32767
+)
+
+// xlsxNumber matches cells written as numbers. Values with leading zeros or exponents stay
+// text, so identifiers keep their exact form.
+var xlsxNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]{0,14})(\.[0-9]+)?$`)
+
+// xlsxEncoder writes a workbook with the records on a "Records" sheet, streamed as they arrive,
+// and the report filters on a "Report" sheet written at the end.
+type xlsxEncoder struct {
+	zip    *zip.Writer
+	sheet  *bufio.Writer
+	report report
+	rows   int
+}
+
+func newXLSXEncoder(w io.Writer, r report) (*xlsxEncoder, error) {
+	e := &xlsxEncoder{zip: zip.NewWriter(w), report: r}
+	part, err := e.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	e.sheet = bufio.NewWriter(part)
+	e.sheet.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>` +
+		`<sheetData>`)
+	return e, e.writeRow(e.sheet, r.Source.Columns, true)
+}
+
+func (e *xlsxEncoder) Write(row []string, object any) error {
+	if e.rows == xlsxMaxRows {
+		return ErrInvalidRequest{Reason: fmt.Sprintf("xlsx exports are limited to %d records, narrow the range or export csv", xlsxMaxRows-1)}
+	}
+	return e.writeRow(e.sheet, row, false)
+}
+
+// writeRow appends a row of inline string and number cells; header rows are bold.
+func (e *xlsxEncoder) writeRow(w *bufio.Writer, cells []string, header bool) error {
+	e.rows++
+	fmt.Fprintf(w, `<row r="%d">`, e.rows)
+	for i, cell := range cells {
+		ref := xlsxColumn(i) + strconv.Itoa(e.rows)
+		if !header && xlsxNumber.MatchString(cell) {
+			fmt.Fprintf(w, `<c r="%s"><v>%s</v></c>`, ref, cell)
+			continue
+		}
+		style := ""
+		if header {
+			style = ` s="1"`
+		}
+		fmt.Fprintf(w, `<c r="%s" t="inlineStr"%s><is><t xml:space="preserve">`, ref, style)
+		if utf8.RuneCountInString(cell) > xlsxMaxCellChars {
+			cell = string([]rune(cell)[:xlsxMaxCellChars])
+		}
+		if err := xml.EscapeText(w, []byte(cell)); err != nil {
+			return err
+		}
+		w.WriteString(`</t></is></c>`)
+	}
+	_, err := w.WriteString(`</row>`)
+	return err
+}
+
+// xlsxColumn returns the letters naming the zero-based column i, such as "A" or "AB".
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+func (e *xlsxEncoder) Close() error {
+	lastColumn := xlsxColumn(max(len(e.report.Source.Columns), 1) - 1)
+	filterRange := fmt.Sprintf("A1:%s%d", lastColumn, e.rows)
+	fmt.Fprintf(e.sheet, `</sheetData><autoFilter ref="%s"/></worksheet>`, filterRange)
+	if err := e.sheet.Flush(); err != nil {
+		return err
+	}
+
+	part, err := e.zip.Create("xl/worksheets/sheet2.xml")
+	if err != nil {
+		return err
+	}
+	details := bufio.NewWriter(part)
+	details.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	rows := e.rows
+	e.rows = 0
+	for _, field := range e.reportFields(rows - 1) {
+		if err := e.writeRow(details, field, false); err != nil {
+			return err
+		}
+	}
+	details.WriteString(`</sheetData></worksheet>`)
+	if err := details.Flush(); err != nil {
+		return err
+	}
+
+	parts := map[string]string{
+		"[Content_Types].xml": `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet2.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			`</Types>`,
+		"_rels/.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`,
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Records" sheetId="1" r:id="rId1"/><sheet name="Report" sheetId="2" r:id="rId2"/></sheets>` +
+			fmt.Sprintf(`<definedNames><definedName name="_xlnm._FilterDatabase" localSheetId="0" hidden="1">Records!$A$1:$%s$%d</definedName></definedNames>`, lastColumn, rows) +
+			`</workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet2.xml"/>` +
+			`<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+			`</Relationships>`,
+		"xl/styles.xml": `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+			`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+			`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+			`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+			`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+			`</styleSheet>`,
+	}
+	names := make([]string, 0, len(parts))
+	for name := range parts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		part, err := e.zip.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(part, xml.Header+parts[name]); err != nil {
+			return err
+		}
+	}
+	return e.zip.Close()
+}
+
+// reportFields describes the export on the Report sheet.
+func (e *xlsxEncoder) reportFields(records int) [][]string {
+	req := e.report.Request
+	fields := [][]string{
+		{"Source", e.report.Source.Name},
+		{"Description", e.report.Source.Description},
+		{"Generated", e.report.Generated.Format(time.RFC3339)},
+		{"Requested by", e.report.Owner},
+		{"From", formatBound(req.From, "the first record")},
+		{"To", formatBound(req.To, "the last record")},
+	}
+	names := make([]string, 0, len(req.Params))
+	for name := range req.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fields = append(fields, []string{"Filter " + name, req.Params[name]})
+	}
+	return append(fields, []string{"Records", strconv.Itoa(records)})
+}
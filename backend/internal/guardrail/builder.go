@@ -0,0 +1,120 @@
+package guardrail
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/pramodksahoo/kubechat/backend/internal/commands"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+	"github.com/pramodksahoo/kubechat/backend/internal/plan"
+)
+
+// ErrRejected reports a prompt or generated plan the guardrail refused.
+type ErrRejected struct {
+	Reasons []string
+}
+
+func (e ErrRejected) Error() string {
+	return fmt.Sprintf("request rejected by the NLP guardrail: %s", strings.Join(e.Reasons, "; "))
+}
+
+// Auditor records refused generations in the command audit trail.
+type Auditor interface {
+	RecordBlocked(ctx context.Context, blocked commands.BlockedGeneration) (commands.Execution, error)
+}
+
+// Builder wraps the plan builder: prompts are checked before any model sees them and generated
+// commands are sanitized and checked before the plan is returned.
+type Builder struct {
+	inner   plan.Builder
+	policy  Policy
+	auditor Auditor
+	logger  *log.Logger
+}
+
+func NewBuilder(inner plan.Builder, policy Policy, logger *log.Logger) *Builder {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Builder{inner: inner, policy: policy, logger: logger}
+}
+
+// SetAuditor records every refused prompt and command in the command audit trail.
+func (b *Builder) SetAuditor(auditor Auditor) {
+	b.auditor = auditor
+}
+
+func (b *Builder) BuildPlan(ctx context.Context, input plan.BuildInput) (plan.PlanDraft, error) {
+	if matched := DetectInjection(input.Prompt); len(matched) > 0 {
+		reasons := make([]string, 0, len(matched))
+		for _, name := range matched {
+			reasons = append(reasons, "prompt injection: "+name)
+		}
+		b.record(ctx, commands.BlockedGeneration{Cluster: input.ClusterHint, Prompt: input.Prompt, Reasons: reasons})
+		return plan.PlanDraft{}, ErrRejected{Reasons: reasons}
+	}
+
+	draft, err := b.inner.BuildPlan(ctx, input)
+	if err != nil {
+		return draft, err
+	}
+
+	var rejected []string
+	sanitized := false
+	for i := range draft.Steps {
+		step := &draft.Steps[i]
+		if step.Command == "" {
+			continue
+		}
+		command, stripped := Sanitize(step.Command)
+		reasons := b.policy.Check(command)
+		if len(reasons) > 0 {
+			b.record(ctx, commands.BlockedGeneration{
+				Cluster:  draft.TargetCluster,
+				Resource: stepResource(*step),
+				Prompt:   draft.Prompt,
+				Command:  step.Command,
+				Reasons:  reasons,
+			})
+			for _, reason := range reasons {
+				rejected = append(rejected, fmt.Sprintf("step %d: %s", step.Sequence, reason))
+			}
+			continue
+		}
+		if stripped {
+			b.logger.Warn("stripped shell metacharacters from generated command", "plan_id", draft.ID, "step", step.Sequence)
+			step.Command = command
+			sanitized = true
+		}
+	}
+	if len(rejected) > 0 {
+		return plan.PlanDraft{}, ErrRejected{Reasons: rejected}
+	}
+	if sanitized {
+		if draft.ScopeSignals == nil {
+			draft.ScopeSignals = map[string]string{}
+		}
+		draft.ScopeSignals["guardrail"] = "sanitized"
+	}
+	return draft, nil
+}
+
+// record adds a refusal to the audit trail; failing to record it does not let the request through.
+func (b *Builder) record(ctx context.Context, blocked commands.BlockedGeneration) {
+	blocked.UserID = identity.FromContext(ctx)
+	b.logger.Warn("NLP guardrail rejected a generation", "user", blocked.UserID, "reasons", strings.Join(blocked.Reasons, "; "))
+	if b.auditor == nil {
+		return
+	}
+	if _, err := b.auditor.RecordBlocked(context.WithoutCancel(ctx), blocked); err != nil {
+		b.logger.Error("failed to record rejected generation", "error", err)
+	}
+}
+
+// stepResource describes the target of a step, such as deployments/api, as a command resource.
+func stepResource(step plan.PlanStep) commands.Resource {
+	kind, name, _ := strings.Cut(step.Target.Resource, "/")
+	return commands.Resource{Kind: kind, Namespace: step.Target.Namespace, Name: name}
+}
@@ -0,0 +1,138 @@
+package guardrail
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Policy lists the kubectl verbs and resources generated commands may use.
+type Policy struct {
+	Verbs     []string
+	Resources []string
+}
+
+// DefaultPolicy allows inspection and the workload changes plans propose. Commands that open a
+// shell or tunnel into the cluster, such as exec, cp, port-forward and proxy, are refused, as are
+// secrets.
+var DefaultPolicy = Policy{
+	Verbs: []string{
+		"get", "describe", "logs", "top", "explain", "events",
+		"scale", "rollout", "label", "annotate", "apply", "diff", "patch", "set", "delete", "cordon", "uncordon",
+	},
+	Resources: []string{
+		"pods", "deployments", "statefulsets", "daemonsets", "replicasets", "jobs", "cronjobs",
+		"services", "endpoints", "ingresses", "configmaps", "events", "nodes", "namespaces",
+		"horizontalpodautoscalers", "poddisruptionbudgets", "persistentvolumeclaims", "persistentvolumes",
+	},
+}
+
+// shortNames maps kubectl's short resource names to the plural names policies use.
+var shortNames = map[string]string{
+	"po": "pods", "pod": "pods", "deploy": "deployments", "deployment": "deployments", "sts": "statefulsets",
+	"statefulset": "statefulsets", "ds": "daemonsets", "daemonset": "daemonsets", "rs": "replicasets",
+	"replicaset": "replicasets", "job": "jobs", "cj": "cronjobs", "cronjob": "cronjobs", "svc": "services",
+	"service": "services", "ep": "endpoints", "ing": "ingresses", "ingress": "ingresses", "cm": "configmaps",
+	"configmap": "configmaps", "ev": "events", "event": "events", "no": "nodes", "node": "nodes",
+	"ns": "namespaces", "namespace": "namespaces", "hpa": "horizontalpodautoscalers", "pdb": "poddisruptionbudgets",
+	"pvc": "persistentvolumeclaims", "pv": "persistentvolumes", "secret": "secrets",
+}
+
+// subcommandVerbs take a subcommand before the resource, as in "rollout restart deploy/api".
+var subcommandVerbs = []string{"rollout", "set"}
+
+// fileVerbs name their resources in a manifest file rather than on the command line.
+var fileVerbs = []string{"apply", "diff"}
+
+var (
+	// placeholder matches the values plans leave for the operator to fill in, such as <target>.
+	placeholder = regexp.MustCompile(`<[a-z][a-z-]*>`)
+	// shellMetacharacters could chain, substitute or redirect commands if a step reached a shell.
+	shellMetacharacters = regexp.MustCompile("[;&|`$(){}<>\\\\!\r\n]")
+)
+
+// Sanitize strips shell metacharacters from command, keeping placeholders, and reports whether
+// anything was removed.
+func Sanitize(command string) (string, bool) {
+	var b strings.Builder
+	last := 0
+	for _, loc := range placeholder.FindAllStringIndex(command, -1) {
+		b.WriteString(shellMetacharacters.ReplaceAllString(command[last:loc[0]], " "))
+		b.WriteString(command[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(shellMetacharacters.ReplaceAllString(command[last:], " "))
+	sanitized := strings.Join(strings.Fields(b.String()), " ")
+	return sanitized, sanitized != strings.Join(strings.Fields(command), " ")
+}
+
+// Check returns why a sanitized command is not allowed, or nothing when it is.
+func (p Policy) Check(command string) []string {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil
+	}
+	if fields[0] != "kubectl" {
+		return []string{fmt.Sprintf("%q is not a kubectl command", fields[0])}
+	}
+	args := positional(fields[1:])
+	if len(args) == 0 {
+		return []string{"kubectl command has no verb"}
+	}
+	verb := args[0]
+	if !slices.Contains(p.Verbs, verb) {
+		return []string{fmt.Sprintf("kubectl verb %q is not allowed", verb)}
+	}
+	args = args[1:]
+	if slices.Contains(subcommandVerbs, verb) && len(args) > 0 {
+		args = args[1:]
+	}
+	if len(args) == 0 || slices.Contains(fileVerbs, verb) {
+		return nil
+	}
+	resource := resourceName(args[0], verb)
+	if !slices.Contains(p.Resources, resource) {
+		return []string{fmt.Sprintf("resource %q is not allowed", resource)}
+	}
+	return nil
+}
+
+// positional drops flags and the values of flags given as separate arguments.
+func positional(args []string) []string {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if strings.HasPrefix(arg, "-") {
+			if !strings.Contains(arg, "=") && i+1 < len(args) && valueFlag(arg) {
+				i++
+			}
+			continue
+		}
+		out = append(out, arg)
+	}
+	return out
+}
+
+// valueFlag reports whether a flag takes the next argument as its value.
+func valueFlag(flag string) bool {
+	switch flag {
+	case "-n", "--namespace", "--context", "-l", "--selector", "-o", "--output", "-f", "--filename", "-c", "--container", "--tail", "--since", "--replicas":
+		return true
+	}
+	return false
+}
+
+// resourceName returns the plural resource a command argument such as "deploy/api" names. Logs
+// take a bare pod name.
+func resourceName(arg, verb string) string {
+	kind, _, found := strings.Cut(strings.ToLower(arg), "/")
+	if !found && verb == "logs" {
+		return "pods"
+	}
+	kind, _, _ = strings.Cut(kind, ".")
+	if plural, ok := shortNames[kind]; ok {
+		return plural
+	}
+	return kind
+}
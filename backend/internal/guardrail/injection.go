@@ -0,0 +1,34 @@
+// Package guardrail sits between the NLP prompt pipeline and the commands it generates. Prompts
+// that try to override the assistant's instructions are refused before they reach a model, and
+// generated commands are stripped of shell metacharacters and checked against the kubectl verbs
+// and resources the assistant may propose. Refusals are recorded in the command audit trail.
+package guardrail
+
+import (
+	"regexp"
+)
+
+type injectionPattern struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// injectionPatterns are phrasings commonly used to hijack a model or smuggle commands through it.
+var injectionPatterns = []injectionPattern{
+	{"instruction override", regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override)\b.{0,20}\b(previous|prior|above|earlier|all|your|system)\b.{0,20}\b(instructions?|prompts?|rules|guidelines|directives)\b`)},
+	{"prompt exfiltration", regexp.MustCompile(`(?i)\b(reveal|print|show|repeat|output|leak)\b.{0,20}\b(system|hidden|initial|original)\s+(prompt|instructions?)\b`)},
+	{"role hijack", regexp.MustCompile(`(?i)\b(you are now|from now on,? you are|pretend (to be|you are)|act as (an? )?(unrestricted|unfiltered|jailbroken))\b|\b(developer mode|jailbreak|do anything now)\b`)},
+	{"role marker", regexp.MustCompile(`(?im)<\|(im_start|im_end|system|assistant)\|>|\[/?INST\]|^\s*#{2,}\s*(system|instruction)s?\b|^\s*(system|assistant)\s*:`)},
+	{"shell payload", regexp.MustCompile("(?i)\\$\\(|[`;&|]\\s*(rm|curl|wget|bash|sh|nc|python|perl|chmod|base64)\\b")},
+}
+
+// DetectInjection returns the names of the injection patterns prompt matches.
+func DetectInjection(prompt string) []string {
+	var matched []string
+	for _, p := range injectionPatterns {
+		if p.pattern.MatchString(prompt) {
+			matched = append(matched, p.name)
+		}
+	}
+	return matched
+}
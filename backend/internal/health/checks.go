@@ -0,0 +1,40 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func DatabaseCheck(db *sqlx.DB) Checker {
+	return CheckFunc{Component: "database", Fn: db.PingContext}
+}
+
+// HTTPCheck reports healthy when url answers with a non-5xx status.
+func HTTPCheck(component, url string, client *http.Client) Checker {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return CheckFunc{Component: component, Fn: func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("%s returned %d", url, resp.StatusCode)
+		}
+		return nil
+	}}
+}
+
+// APICheck reports the API itself; it is up whenever the server can run checks.
+func APICheck() Checker {
+	return CheckFunc{Component: "api", Fn: func(context.Context) error { return nil }}
+}
@@ -0,0 +1,118 @@
+package health
+
+import (
+	"sync"
+	"time"
+)
+
+// bucketSize is the resolution at which check results are aggregated.
+const bucketSize = 5 * time.Minute
+
+type bucket struct {
+	Start time.Time
+	OK    int
+	Total int
+}
+
+// History keeps aggregated check results per component for uptime reporting.
+type History struct {
+	mu        sync.RWMutex
+	retention time.Duration
+	buckets   map[string][]bucket
+}
+
+func NewHistory(retention time.Duration) *History {
+	if retention <= 0 {
+		retention = 7 * 24 * time.Hour
+	}
+	return &History{retention: retention, buckets: make(map[string][]bucket)}
+}
+
+func (h *History) Record(component string, at time.Time, ok bool) {
+	start := at.Truncate(bucketSize)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	buckets := h.buckets[component]
+	if n := len(buckets); n == 0 || !buckets[n-1].Start.Equal(start) {
+		buckets = append(buckets, bucket{Start: start})
+	}
+	last := &buckets[len(buckets)-1]
+	last.Total++
+	if ok {
+		last.OK++
+	}
+
+	h.buckets[component] = h.trimLocked(buckets, at)
+}
+
+// SetRetention changes how long results are kept; zero keeps them until retention is set again.
+func (h *History) SetRetention(retention time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.retention = retention
+}
+
+// Prune drops results older than the retention for every component and returns how many
+// aggregated buckets were removed.
+func (h *History) Prune(now time.Time) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	removed := 0
+	for component, buckets := range h.buckets {
+		kept := h.trimLocked(buckets, now)
+		removed += len(buckets) - len(kept)
+		h.buckets[component] = kept
+	}
+	return removed
+}
+
+// Expired counts the aggregated buckets Prune would remove under retention.
+func (h *History) Expired(retention time.Duration, now time.Time) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	expired := 0
+	for _, buckets := range h.buckets {
+		expired += len(buckets) - len(trimBuckets(buckets, retention, now))
+	}
+	return expired
+}
+
+func (h *History) trimLocked(buckets []bucket, now time.Time) []bucket {
+	return trimBuckets(buckets, h.retention, now)
+}
+
+func trimBuckets(buckets []bucket, retention time.Duration, now time.Time) []bucket {
+	if retention <= 0 {
+		return buckets
+	}
+	cutoff := now.Add(-retention)
+	drop := 0
+	for drop < len(buckets) && buckets[drop].Start.Add(bucketSize).Before(cutoff) {
+		drop++
+	}
+	return buckets[drop:]
+}
+
+// Uptime returns the percentage of successful checks for component within window ending at now.
+// ok is false when no checks were recorded in the window.
+func (h *History) Uptime(component string, window time.Duration, now time.Time) (float64, bool) {
+	cutoff := now.Add(-window)
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	okCount, total := 0, 0
+	for _, b := range h.buckets[component] {
+		if b.Start.Add(bucketSize).Before(cutoff) {
+			continue
+		}
+		okCount += b.OK
+		total += b.Total
+	}
+	if total == 0 {
+		return 0, false
+	}
+	return float64(okCount) * 100 / float64(total), true
+}
@@ -0,0 +1,176 @@
+package health
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// CheckFunc adapts a function to Checker.
+type CheckFunc struct {
+	Component string
+	Fn        func(ctx context.Context) error
+}
+
+func (c CheckFunc) Name() string                    { return c.Component }
+func (c CheckFunc) Check(ctx context.Context) error { return c.Fn(ctx) }
+
+type ComponentState string
+
+const (
+	StateUp      ComponentState = "up"
+	StateDown    ComponentState = "down"
+	StateUnknown ComponentState = "unknown"
+)
+
+type OverallState string
+
+const (
+	Operational OverallState = "operational"
+	Degraded    OverallState = "degraded"
+	MajorOutage OverallState = "major_outage"
+)
+
+type ComponentStatus struct {
+	Name        string             `json:"name"`
+	Status      ComponentState     `json:"status"`
+	LatencyMS   int64              `json:"latencyMs"`
+	LastChecked time.Time          `json:"lastChecked"`
+	Uptime      map[string]float64 `json:"uptime"`
+}
+
+type Summary struct {
+	Status     OverallState      `json:"status"`
+	UpdatedAt  time.Time         `json:"updatedAt"`
+	Components []ComponentStatus `json:"components"`
+}
+
+// uptimeWindows are the periods reported on the status page.
+var uptimeWindows = []struct {
+	label  string
+	window time.Duration
+}{
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+}
+
+type result struct {
+	ok      bool
+	latency time.Duration
+	at      time.Time
+}
+
+type Monitor struct {
+	checkers []Checker
+	history  *History
+	timeout  time.Duration
+	logger   *log.Logger
+	now      func() time.Time
+
+	mu     sync.RWMutex
+	latest map[string]result
+}
+
+func NewMonitor(history *History, logger *log.Logger, checkers ...Checker) *Monitor {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Monitor{
+		checkers: checkers,
+		history:  history,
+		timeout:  5 * time.Second,
+		logger:   logger,
+		now:      func() time.Time { return time.Now().UTC() },
+		latest:   make(map[string]result),
+	}
+}
+
+// CheckAll runs every checker concurrently and records the outcomes.
+// History returns the store of aggregated check results.
+func (m *Monitor) History() *History {
+	return m.history
+}
+
+func (m *Monitor) CheckAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, checker := range m.checkers {
+		wg.Add(1)
+		go func(checker Checker) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, m.timeout)
+			defer cancel()
+
+			started := time.Now()
+			err := checker.Check(checkCtx)
+			res := result{ok: err == nil, latency: time.Since(started), at: m.now()}
+			if err != nil {
+				m.logger.Warn("health check failed", "component", checker.Name(), "error", err)
+			}
+
+			m.history.Record(checker.Name(), res.at, res.ok)
+			m.mu.Lock()
+			m.latest[checker.Name()] = res
+			m.mu.Unlock()
+		}(checker)
+	}
+	wg.Wait()
+}
+
+func (m *Monitor) Run(ctx context.Context, interval time.Duration) {
+	m.CheckAll(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.CheckAll(ctx)
+		}
+	}
+}
+
+func (m *Monitor) Summary() Summary {
+	now := m.now()
+	summary := Summary{Status: Operational, UpdatedAt: now}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	down := 0
+	for _, checker := range m.checkers {
+		status := ComponentStatus{Name: checker.Name(), Status: StateUnknown, Uptime: make(map[string]float64)}
+		if res, ok := m.latest[checker.Name()]; ok {
+			status.LastChecked = res.at
+			status.LatencyMS = res.latency.Milliseconds()
+			status.Status = StateUp
+			if !res.ok {
+				status.Status = StateDown
+				down++
+			}
+		}
+		for _, w := range uptimeWindows {
+			if uptime, ok := m.history.Uptime(checker.Name(), w.window, now); ok {
+				status.Uptime[w.label] = math.Round(uptime*100) / 100
+			}
+		}
+		summary.Components = append(summary.Components, status)
+	}
+
+	switch {
+	case down == 0:
+	case down == len(m.checkers):
+		summary.Status = MajorOutage
+	default:
+		summary.Status = Degraded
+	}
+	return summary
+}
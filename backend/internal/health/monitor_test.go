@@ -0,0 +1,69 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+func TestHistoryUptime(t *testing.T) {
+	history := NewHistory(7 * 24 * time.Hour)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		history.Record("api", now.Add(-time.Duration(i)*time.Minute), true)
+	}
+	history.Record("api", now.Add(-2*time.Hour), false)
+	history.Record("api", now.Add(-48*time.Hour), false)
+
+	uptime, ok := history.Uptime("api", 24*time.Hour, now)
+	if !ok || uptime != 75 {
+		t.Fatalf("expected 24h uptime 75, got %v (ok=%v)", uptime, ok)
+	}
+	uptime, ok = history.Uptime("api", 7*24*time.Hour, now)
+	if !ok || uptime != 60 {
+		t.Fatalf("expected 7d uptime 60, got %v (ok=%v)", uptime, ok)
+	}
+	if _, ok := history.Uptime("database", 24*time.Hour, now); ok {
+		t.Fatalf("expected no uptime for unchecked component")
+	}
+}
+
+func TestHistoryDropsExpiredBuckets(t *testing.T) {
+	history := NewHistory(time.Hour)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	history.Record("api", now.Add(-3*time.Hour), false)
+	history.Record("api", now, true)
+
+	if got := len(history.buckets["api"]); got != 1 {
+		t.Fatalf("expected expired bucket to be dropped, have %d buckets", got)
+	}
+}
+
+func TestMonitorSummary(t *testing.T) {
+	healthy := CheckFunc{Component: "api", Fn: func(context.Context) error { return nil }}
+	failing := CheckFunc{Component: "database", Fn: func(context.Context) error { return errors.New("connection refused") }}
+	monitor := NewMonitor(NewHistory(0), log.NewWithOptions(io.Discard, log.Options{}), healthy, failing)
+
+	summary := monitor.Summary()
+	if summary.Status != Operational || summary.Components[0].Status != StateUnknown {
+		t.Fatalf("expected unknown components before the first check, got %+v", summary)
+	}
+
+	monitor.CheckAll(context.Background())
+	summary = monitor.Summary()
+	if summary.Status != Degraded {
+		t.Fatalf("expected degraded status, got %s", summary.Status)
+	}
+	if summary.Components[0].Status != StateUp || summary.Components[1].Status != StateDown {
+		t.Fatalf("unexpected component states: %+v", summary.Components)
+	}
+	if summary.Components[0].Uptime["24h"] != 100 || summary.Components[1].Uptime["24h"] != 0 {
+		t.Fatalf("unexpected uptime: %+v", summary.Components)
+	}
+}
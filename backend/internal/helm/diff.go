@@ -0,0 +1,190 @@
+package helm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+type DiffAction string
+
+const (
+	DiffAdded   DiffAction = "added"
+	DiffRemoved DiffAction = "removed"
+	DiffChanged DiffAction = "changed"
+)
+
+// ObjectDiff is how one object of a release changes between two manifests. Lines is a line diff
+// of the object as YAML, each line prefixed with "+", "-" or " ".
+type ObjectDiff struct {
+	Kind      string     `json:"kind"`
+	Namespace string     `json:"namespace,omitempty"`
+	Name      string     `json:"name"`
+	Action    DiffAction `json:"action"`
+	Lines     []string   `json:"lines"`
+}
+
+// Objects parses the objects of a rendered manifest, such as the output of helm template. Objects
+// without a namespace are given namespace, which the caller clears for cluster-scoped kinds.
+func Objects(manifest, namespace string) ([]*unstructured.Unstructured, error) {
+	decoder := yamlutil.NewYAMLOrJSONDecoder(strings.NewReader(manifest), 4096)
+	var objects []*unstructured.Unstructured
+	for {
+		var raw json.RawMessage
+		err := decoder.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("manifest is not valid YAML: %w", err)
+		}
+		if len(bytes.TrimSpace(raw)) == 0 || string(raw) == "null" {
+			continue
+		}
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(raw); err != nil {
+			return nil, fmt.Errorf("manifest holds something other than a Kubernetes object: %w", err)
+		}
+		if obj.GetName() == "" {
+			return nil, fmt.Errorf("manifest holds a %s without a name", obj.GetKind())
+		}
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(namespace)
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// Key identifies an object within a release.
+func Key(obj *unstructured.Unstructured) string {
+	return obj.GroupVersionKind().GroupKind().String() + "/" + obj.GetNamespace() + "/" + obj.GetName()
+}
+
+// Diff compares the objects of two rendered manifests of a release in namespace, listing added,
+// removed and changed objects by kind, namespace and name.
+func Diff(from, to, namespace string) ([]ObjectDiff, error) {
+	before, err := Objects(from, namespace)
+	if err != nil {
+		return nil, err
+	}
+	after, err := Objects(to, namespace)
+	if err != nil {
+		return nil, err
+	}
+	old := make(map[string]*unstructured.Unstructured, len(before))
+	for _, obj := range before {
+		old[Key(obj)] = obj
+	}
+	var diffs []ObjectDiff
+	for _, obj := range after {
+		key := Key(obj)
+		previous, ok := old[key]
+		delete(old, key)
+		diff := ObjectDiff{Kind: obj.GetKind(), Namespace: obj.GetNamespace(), Name: obj.GetName(), Action: DiffChanged}
+		if !ok {
+			diff.Action = DiffAdded
+		}
+		if diff.Lines, err = lineDiff(previous, obj); err != nil {
+			return nil, err
+		}
+		if ok && !changed(diff.Lines) {
+			continue
+		}
+		diffs = append(diffs, diff)
+	}
+	for _, obj := range old {
+		lines, err := lineDiff(obj, nil)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, ObjectDiff{Kind: obj.GetKind(), Namespace: obj.GetNamespace(), Name: obj.GetName(), Action: DiffRemoved, Lines: lines})
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].Kind != diffs[j].Kind {
+			return diffs[i].Kind < diffs[j].Kind
+		}
+		if diffs[i].Namespace != diffs[j].Namespace {
+			return diffs[i].Namespace < diffs[j].Namespace
+		}
+		return diffs[i].Name < diffs[j].Name
+	})
+	return diffs, nil
+}
+
+// lineDiff diffs the YAML of two objects, either of which may be nil. Rendering both through the
+// same encoder keeps key order and formatting from showing up as changes.
+func lineDiff(before, after *unstructured.Unstructured) ([]string, error) {
+	a, err := yamlLines(before)
+	if err != nil {
+		return nil, err
+	}
+	b, err := yamlLines(after)
+	if err != nil {
+		return nil, err
+	}
+	// lengths[i][j] is the longest common subsequence of a[i:] and b[j:].
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+	lines := make([]string, 0, max(len(a), len(b)))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, " "+a[i])
+			i, j = i+1, j+1
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			lines = append(lines, "-"+a[i])
+			i++
+		default:
+			lines = append(lines, "+"+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, "-"+a[i])
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, "+"+b[j])
+	}
+	return lines, nil
+}
+
+func yamlLines(obj *unstructured.Unstructured) ([]string, error) {
+	if obj == nil {
+		return nil, nil
+	}
+	out, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(string(out), "\n"), "\n"), nil
+}
+
+func changed(lines []string) bool {
+	for _, line := range lines {
+		if !strings.HasPrefix(line, " ") {
+			return true
+		}
+	}
+	return false
+}
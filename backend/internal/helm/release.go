@@ -0,0 +1,173 @@
+// Package helm reads and records Helm 3 releases in the secrets Helm's default storage driver
+// keeps them in, so releases installed with the helm CLI can be listed, compared and upgraded or
+// rolled back without the CLI.
+package helm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Release statuses, as Helm records them.
+const (
+	StatusDeployed   = "deployed"
+	StatusSuperseded = "superseded"
+	StatusFailed     = "failed"
+)
+
+// Release is one revision of a Helm release. Values and Manifest are left out of listings: values
+// often hold credentials and manifests may render secrets.
+type Release struct {
+	Name         string    `json:"name"`
+	Namespace    string    `json:"namespace"`
+	Revision     int       `json:"revision"`
+	Status       string    `json:"status"`
+	Description  string    `json:"description,omitempty"`
+	Updated      time.Time `json:"updated"`
+	Chart        string    `json:"chart"`
+	ChartVersion string    `json:"chartVersion,omitempty"`
+	AppVersion   string    `json:"appVersion,omitempty"`
+
+	// Values are the values supplied for the revision, not the chart's defaults.
+	Values map[string]any `json:"-"`
+	// Manifest is the rendered manifest the revision installed, without hooks.
+	Manifest string `json:"-"`
+
+	// raw is the release as Helm encoded it, kept so fields this package does not know survive
+	// recording a new revision.
+	raw map[string]any
+}
+
+// record is the part of Helm's release encoding that Release reads. Helm writes unset times as
+// empty strings, so times are parsed by hand.
+type record struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   int    `json:"version"`
+	Info      struct {
+		LastDeployed string `json:"last_deployed"`
+		Description  string `json:"description"`
+		Status       string `json:"status"`
+	} `json:"info"`
+	Chart struct {
+		Metadata struct {
+			Name       string `json:"name"`
+			Version    string `json:"version"`
+			AppVersion string `json:"appVersion"`
+		} `json:"metadata"`
+	} `json:"chart"`
+	Config   map[string]any `json:"config"`
+	Manifest string         `json:"manifest"`
+}
+
+var gzipMagic = []byte{0x1f, 0x8b, 0x08}
+
+// Decode parses a release as Helm's secret and configmap drivers store it: base64 of gzipped
+// JSON, or of plain JSON as written by old Helm versions.
+func Decode(data []byte) (Release, error) {
+	decoded, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return Release{}, fmt.Errorf("release is not base64: %w", err)
+	}
+	if bytes.HasPrefix(decoded, gzipMagic) {
+		reader, err := gzip.NewReader(bytes.NewReader(decoded))
+		if err != nil {
+			return Release{}, err
+		}
+		defer reader.Close()
+		if decoded, err = io.ReadAll(reader); err != nil {
+			return Release{}, fmt.Errorf("release is not gzipped: %w", err)
+		}
+	}
+	var rec record
+	if err := json.Unmarshal(decoded, &rec); err != nil {
+		return Release{}, fmt.Errorf("release is not JSON: %w", err)
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(decoded, &raw); err != nil {
+		return Release{}, err
+	}
+	release := Release{
+		Name:         rec.Name,
+		Namespace:    rec.Namespace,
+		Revision:     rec.Version,
+		Status:       rec.Info.Status,
+		Description:  rec.Info.Description,
+		Chart:        rec.Chart.Metadata.Name,
+		ChartVersion: rec.Chart.Metadata.Version,
+		AppVersion:   rec.Chart.Metadata.AppVersion,
+		Values:       rec.Config,
+		Manifest:     rec.Manifest,
+		raw:          raw,
+	}
+	if rec.Info.LastDeployed != "" {
+		release.Updated, _ = time.Parse(time.RFC3339Nano, rec.Info.LastDeployed)
+	}
+	return release, nil
+}
+
+// Encode returns the release in the format Decode reads. Fields of the release Helm encoded that
+// Release does not model, such as the chart's templates and hooks, are kept.
+func Encode(release Release) ([]byte, error) {
+	raw := make(map[string]any, len(release.raw)+8)
+	for k, v := range release.raw {
+		raw[k] = v
+	}
+	raw["name"] = release.Name
+	raw["namespace"] = release.Namespace
+	raw["version"] = release.Revision
+	raw["config"] = release.Values
+	raw["manifest"] = release.Manifest
+
+	info, _ := raw["info"].(map[string]any)
+	info = cloneMap(info)
+	info["status"] = release.Status
+	info["description"] = release.Description
+	info["last_deployed"] = release.Updated.Format(time.RFC3339Nano)
+	if _, ok := info["first_deployed"]; !ok {
+		info["first_deployed"] = info["last_deployed"]
+	}
+	raw["info"] = info
+
+	chart, _ := raw["chart"].(map[string]any)
+	chart = cloneMap(chart)
+	metadata, _ := chart["metadata"].(map[string]any)
+	metadata = cloneMap(metadata)
+	metadata["name"] = release.Chart
+	metadata["version"] = release.ChartVersion
+	if release.AppVersion != "" {
+		metadata["appVersion"] = release.AppVersion
+	}
+	chart["metadata"] = metadata
+	raw["chart"] = chart
+
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(encoded); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
+
+func cloneMap(m map[string]any) map[string]any {
+	clone := make(map[string]any, len(m)+1)
+	for k, v := range m {
+		clone[k] = v
+	}
+	return clone
+}
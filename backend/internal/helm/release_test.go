@@ -0,0 +1,48 @@
+package helm
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestDecodeKeepsWhatItDoesNotModelWhenReencoded(t *testing.T) {
+	// Releases written by old helm versions are not gzipped, and unset times are empty strings.
+	plain := `{"name":"web","namespace":"shop","version":3,"info":{"status":"deployed","last_deployed":"2026-10-01T08:00:00Z","deleted":""},` +
+		`"chart":{"metadata":{"name":"web","version":"1.2.0","appVersion":"2.0"},"templates":[{"name":"templates/cm.yaml"}]},` +
+		`"config":{"replicas":2},"manifest":"kind: ConfigMap","hooks":[{"name":"migrate"}]}`
+	release, err := Decode([]byte(base64.StdEncoding.EncodeToString([]byte(plain))))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if release.Revision != 3 || release.Status != StatusDeployed || release.ChartVersion != "1.2.0" || release.Values["replicas"] != float64(2) || release.Updated.IsZero() {
+		t.Fatalf("unexpected release %+v", release)
+	}
+
+	release.Revision, release.Status, release.Manifest = 4, StatusSuperseded, "kind: Secret"
+	encoded, err := Encode(release)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	decoded, err := Decode(encoded)
+	if err != nil {
+		t.Fatalf("decode gzipped release: %v", err)
+	}
+	if decoded.Revision != 4 || decoded.Status != StatusSuperseded || decoded.Manifest != "kind: Secret" || decoded.AppVersion != "2.0" {
+		t.Fatalf("unexpected re-encoded release %+v", decoded)
+	}
+	if hooks, _ := decoded.raw["hooks"].([]any); len(hooks) != 1 {
+		t.Fatalf("expected the hooks to survive re-encoding, got %v", decoded.raw["hooks"])
+	}
+}
+
+func TestDiffIgnoresFormattingAndListsRemovedObjects(t *testing.T) {
+	from := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\ndata: {one: \"1\", two: \"2\"}\n---\napiVersion: v1\nkind: Service\nmetadata:\n  name: b\n"
+	to := "# Source: web/templates/cm.yaml\n{\"apiVersion\":\"v1\",\"kind\":\"ConfigMap\",\"metadata\":{\"name\":\"a\"},\"data\":{\"two\":\"2\",\"one\":\"1\"}}\n"
+	diffs, err := Diff(from, to, "shop")
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	if len(diffs) != 1 || diffs[0].Kind != "Service" || diffs[0].Action != DiffRemoved || diffs[0].Namespace != "shop" {
+		t.Fatalf("expected only the removed service, got %+v", diffs)
+	}
+}
@@ -0,0 +1,190 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// secretType is the type of the secrets Helm stores releases in.
+const secretType corev1.SecretType = "helm.sh/release.v1"
+
+type ErrReleaseNotFound struct {
+	Namespace string
+	Name      string
+	Revision  int
+}
+
+func (e ErrReleaseNotFound) Error() string {
+	if e.Revision > 0 {
+		return fmt.Sprintf("helm release %s/%s has no revision %d", e.Namespace, e.Name, e.Revision)
+	}
+	return fmt.Sprintf("helm release %s/%s not found", e.Namespace, e.Name)
+}
+
+// Store reads and records releases in a cluster the way Helm's secret driver does.
+type Store struct {
+	client kubernetes.Interface
+	now    func() time.Time
+}
+
+func NewStore(client kubernetes.Interface) *Store {
+	return &Store{client: client, now: time.Now}
+}
+
+// List returns the latest revision of every release in namespace, or in all namespaces when it
+// is empty, by namespace and name.
+func (s *Store) List(ctx context.Context, namespace string) ([]Release, error) {
+	revisions, err := s.revisions(ctx, namespace, "")
+	if err != nil {
+		return nil, err
+	}
+	latest := map[string]Release{}
+	for _, release := range revisions {
+		key := release.Namespace + "/" + release.Name
+		if current, ok := latest[key]; !ok || release.Revision > current.Revision {
+			latest[key] = release
+		}
+	}
+	releases := make([]Release, 0, len(latest))
+	for _, release := range latest {
+		releases = append(releases, release)
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		if releases[i].Namespace != releases[j].Namespace {
+			return releases[i].Namespace < releases[j].Namespace
+		}
+		return releases[i].Name < releases[j].Name
+	})
+	return releases, nil
+}
+
+// History returns every revision of a release, oldest first.
+func (s *Store) History(ctx context.Context, namespace, name string) ([]Release, error) {
+	revisions, err := s.revisions(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		return nil, ErrReleaseNotFound{Namespace: namespace, Name: name}
+	}
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Revision < revisions[j].Revision })
+	return revisions, nil
+}
+
+// Get returns a revision of a release, or its latest revision when revision is 0.
+func (s *Store) Get(ctx context.Context, namespace, name string, revision int) (Release, error) {
+	history, err := s.History(ctx, namespace, name)
+	if err != nil {
+		return Release{}, err
+	}
+	if revision == 0 {
+		return history[len(history)-1], nil
+	}
+	for _, release := range history {
+		if release.Revision == revision {
+			return release, nil
+		}
+	}
+	return Release{}, ErrReleaseNotFound{Namespace: namespace, Name: name, Revision: revision}
+}
+
+// Record stores next as the release's newest revision, deployed now, and marks the revision that
+// was deployed before it superseded, as helm upgrade and helm rollback do.
+func (s *Store) Record(ctx context.Context, next Release) (Release, error) {
+	history, err := s.History(ctx, next.Namespace, next.Name)
+	if err != nil {
+		return Release{}, err
+	}
+	next.Revision = history[len(history)-1].Revision + 1
+	next.Status = StatusDeployed
+	next.Updated = s.now().UTC()
+	data, err := Encode(next)
+	if err != nil {
+		return Release{}, err
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName(next.Name, next.Revision),
+			Namespace: next.Namespace,
+			Labels:    secretLabels(next),
+		},
+		Type: secretType,
+		Data: map[string][]byte{"release": data},
+	}
+	if _, err := s.client.CoreV1().Secrets(next.Namespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		return Release{}, fmt.Errorf("record revision %d of helm release %s/%s: %w", next.Revision, next.Namespace, next.Name, err)
+	}
+	for _, previous := range history {
+		if previous.Status != StatusDeployed {
+			continue
+		}
+		previous.Status = StatusSuperseded
+		if err := s.update(ctx, previous); err != nil {
+			return next, err
+		}
+	}
+	return next, nil
+}
+
+func (s *Store) update(ctx context.Context, release Release) error {
+	data, err := Encode(release)
+	if err != nil {
+		return err
+	}
+	secrets := s.client.CoreV1().Secrets(release.Namespace)
+	secret, err := secrets.Get(ctx, secretName(release.Name, release.Revision), metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	secret.Labels = secretLabels(release)
+	secret.Data = map[string][]byte{"release": data}
+	_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	return err
+}
+
+// revisions decodes the release secrets in namespace, of the release called name or of all.
+func (s *Store) revisions(ctx context.Context, namespace, name string) ([]Release, error) {
+	selector := "owner=helm"
+	if name != "" {
+		selector += ",name=" + name
+	}
+	secrets, err := s.client.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("list helm releases: %w", err)
+	}
+	releases := make([]Release, 0, len(secrets.Items))
+	for _, secret := range secrets.Items {
+		if secret.Type != secretType {
+			continue
+		}
+		release, err := Decode(secret.Data["release"])
+		if err != nil {
+			return nil, fmt.Errorf("decode helm release secret %s/%s: %w", secret.Namespace, secret.Name, err)
+		}
+		if release.Namespace == "" {
+			release.Namespace = secret.Namespace
+		}
+		releases = append(releases, release)
+	}
+	return releases, nil
+}
+
+func secretName(name string, revision int) string {
+	return "sh.helm.release.v1." + name + ".v" + strconv.Itoa(revision)
+}
+
+func secretLabels(release Release) map[string]string {
+	return map[string]string{
+		"name":    release.Name,
+		"owner":   "helm",
+		"status":  release.Status,
+		"version": strconv.Itoa(release.Revision),
+	}
+}
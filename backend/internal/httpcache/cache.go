@@ -0,0 +1,157 @@
+// Package httpcache keeps responses of expensive read-only endpoints for a short time, in Redis
+// so that every replica serves the same responses, or in memory for a single replica. Entries are
+// versioned by the generation of the namespace they describe, so a write to a namespace drops
+// every cached response about it.
+package httpcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pramodksahoo/kubechat/backend/internal/redis"
+)
+
+// DefaultTTL is how long responses are kept when no TTL is configured.
+const DefaultTTL = 15 * time.Second
+
+// Scope is what a response describes: one namespace of a cluster, or all of them when Namespace
+// is empty.
+type Scope struct {
+	Config    string
+	Cluster   string
+	Namespace string
+}
+
+// Entry is a cached response.
+type Entry struct {
+	Status      int       `json:"status"`
+	ContentType string    `json:"contentType"`
+	ETag        string    `json:"etag"`
+	Body        []byte    `json:"body"`
+	StoredAt    time.Time `json:"storedAt"`
+}
+
+// ETag returns a strong entity tag for body.
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// Store keeps entries and generation counters. Generations returns the counters of keys, zero for
+// counters never bumped, and Bump increments each of keys.
+type Store interface {
+	Get(ctx context.Context, key string) (Entry, bool, error)
+	Set(ctx context.Context, key string, entry Entry, ttl time.Duration) error
+	Generations(ctx context.Context, keys ...string) ([]int64, error)
+	Bump(ctx context.Context, keys ...string) error
+}
+
+// Metrics counts cache lookups by route and outcome: hit, miss or bypass when the store failed.
+type Metrics interface {
+	ObserveCacheLookup(route, outcome string)
+}
+
+// Cache keeps responses for TTL.
+type Cache struct {
+	ttl     time.Duration
+	store   Store
+	metrics Metrics
+	logger  *log.Logger
+	now     func() time.Time
+	// unavailable reports when the store cannot be reached.
+	unavailable *redis.Warner
+}
+
+// NewCache keeps responses in store, or in memory when store is nil.
+func NewCache(ttl time.Duration, store Store, logger *log.Logger) *Cache {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	return &Cache{
+		ttl:         ttl,
+		store:       store,
+		logger:      logger,
+		now:         time.Now,
+		unavailable: redis.NewWarner(logger, "response cache store unavailable, serving uncached responses"),
+	}
+}
+
+func (c *Cache) SetMetrics(metrics Metrics) {
+	c.metrics = metrics
+}
+
+// Key names the entry for request within scope as of the scope's current generation. Taking the
+// key before serving a request keeps a write made meanwhile from being hidden by its response.
+func (c *Cache) Key(ctx context.Context, scope Scope, request string) (string, error) {
+	counters := []string{generationKey(scope.Config, scope.Cluster, "")}
+	if scope.Namespace == "" {
+		counters = append(counters, generationKey(scope.Config, scope.Cluster, "*"))
+	} else {
+		counters = append(counters, generationKey(scope.Config, scope.Cluster, scope.Namespace))
+	}
+	generations, err := c.store.Generations(ctx, counters...)
+	if err != nil {
+		c.unavailable.Warn(err)
+		return "", err
+	}
+	parts := []string{scope.Config, scope.Cluster, scope.Namespace, request}
+	for _, generation := range generations {
+		parts = append(parts, strconv.FormatInt(generation, 10))
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Get returns the entry under key, counting the lookup for route.
+func (c *Cache) Get(ctx context.Context, route, key string) (Entry, bool) {
+	entry, ok, err := c.store.Get(ctx, key)
+	outcome := "miss"
+	switch {
+	case err != nil:
+		c.unavailable.Warn(err)
+		outcome = "bypass"
+	case ok:
+		outcome = "hit"
+	}
+	if c.metrics != nil {
+		c.metrics.ObserveCacheLookup(route, outcome)
+	}
+	return entry, ok && err == nil
+}
+
+// Put keeps entry under key for the cache's TTL.
+func (c *Cache) Put(ctx context.Context, key string, entry Entry) {
+	if entry.StoredAt.IsZero() {
+		entry.StoredAt = c.now()
+	}
+	if err := c.store.Set(ctx, key, entry, c.ttl); err != nil {
+		c.unavailable.Warn(err)
+	}
+}
+
+// Invalidate drops the responses about namespace, and those about all namespaces, of a cluster.
+// An empty namespace drops every response about the cluster, such as after a cluster-scoped write.
+func (c *Cache) Invalidate(ctx context.Context, config, cluster, namespace string) {
+	counters := []string{generationKey(config, cluster, "")}
+	if namespace != "" {
+		counters = []string{generationKey(config, cluster, namespace), generationKey(config, cluster, "*")}
+	}
+	if err := c.store.Bump(ctx, counters...); err != nil {
+		c.logger.Error("failed to invalidate cached responses", "config", config, "cluster", cluster, "namespace", namespace, "error", err)
+	}
+}
+
+// generationKey names a generation counter: namespace "" for the whole cluster, "*" for responses
+// about all namespaces, otherwise one namespace.
+func generationKey(config, cluster, namespace string) string {
+	return fmt.Sprintf("generation:%s/%s/%s", config, cluster, namespace)
+}
@@ -0,0 +1,66 @@
+package httpcache
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+type countedLookups map[string]int
+
+func (c countedLookups) ObserveCacheLookup(route, outcome string) {
+	c[outcome]++
+}
+
+func TestWritesDropTheResponsesAboutTheirNamespace(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	cache := NewCache(15*time.Second, store, log.NewWithOptions(io.Discard, log.Options{}))
+	lookups := countedLookups{}
+	cache.SetMetrics(lookups)
+	ctx := context.Background()
+
+	shop := Scope{Config: "kubeconfig", Cluster: "prod", Namespace: "shop"}
+	billing := Scope{Config: "kubeconfig", Cluster: "prod", Namespace: "billing"}
+	all := Scope{Config: "kubeconfig", Cluster: "prod"}
+	keys := map[string]string{}
+	for name, scope := range map[string]Scope{"shop": shop, "billing": billing, "all": all} {
+		key, err := cache.Key(ctx, scope, "/api/v1/kubernetes/restarts?min=3")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		keys[name] = key
+		cache.Put(ctx, key, Entry{Status: 200, Body: []byte(name), ETag: ETag([]byte(name))})
+	}
+	if entry, ok := cache.Get(ctx, "/api/v1/kubernetes/restarts", keys["shop"]); !ok || string(entry.Body) != "shop" {
+		t.Fatalf("expected the shop response to be cached, got %+v", entry)
+	}
+
+	cache.Invalidate(ctx, "kubeconfig", "prod", "shop")
+	current := func(scope Scope) string {
+		key, _ := cache.Key(ctx, scope, "/api/v1/kubernetes/restarts?min=3")
+		return key
+	}
+	if current(shop) == keys["shop"] || current(all) == keys["all"] {
+		t.Fatalf("expected a write to shop to drop the responses about shop and about all namespaces")
+	}
+	if current(billing) != keys["billing"] {
+		t.Fatalf("expected the responses about billing to be kept")
+	}
+	cache.Invalidate(ctx, "kubeconfig", "prod", "")
+	if current(billing) == keys["billing"] {
+		t.Fatalf("expected a cluster-scoped write to drop every response about the cluster")
+	}
+
+	now = now.Add(15 * time.Second)
+	if _, ok := cache.Get(ctx, "/api/v1/kubernetes/restarts", keys["shop"]); ok {
+		t.Fatalf("expected entries to expire after the TTL")
+	}
+	if lookups["hit"] != 1 || lookups["miss"] != 1 {
+		t.Fatalf("expected one hit and one miss, got %v", lookups)
+	}
+}
@@ -0,0 +1,70 @@
+package httpcache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps responses in this replica.
+type MemoryStore struct {
+	mu          sync.Mutex
+	entries     map[string]memoryEntry
+	generations map[string]int64
+	now         func() time.Time
+	swept       time.Time
+}
+
+type memoryEntry struct {
+	Entry
+	expires time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]memoryEntry{}, generations: map[string]int64{}, now: time.Now}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok || !s.now().Before(entry.expires) {
+		return Entry{}, false, nil
+	}
+	return entry.Entry, true, nil
+}
+
+func (s *MemoryStore) Set(ctx context.Context, key string, entry Entry, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.swept) > time.Minute {
+		for k, cached := range s.entries {
+			if !now.Before(cached.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.swept = now
+	}
+	s.entries[key] = memoryEntry{Entry: entry, expires: now.Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Generations(ctx context.Context, keys ...string) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	generations := make([]int64, len(keys))
+	for i, key := range keys {
+		generations[i] = s.generations[key]
+	}
+	return generations, nil
+}
+
+func (s *MemoryStore) Bump(ctx context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		s.generations[key]++
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package httpcache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/redis"
+)
+
+// KeyPrefix starts the name of every Redis key the store writes.
+const KeyPrefix = "kubechat:httpcache:"
+
+// RedisStore keeps responses in Redis, shared by every replica.
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) (Entry, bool, error) {
+	reply, err := s.client.Do(ctx, "GET", KeyPrefix+key)
+	if err != nil || reply == nil {
+		return Entry{}, false, err
+	}
+	raw, ok := reply.(string)
+	if !ok {
+		return Entry{}, false, fmt.Errorf("unexpected response cache reply %v", reply)
+	}
+	var entry Entry
+	if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+		return Entry{}, false, fmt.Errorf("decode cached response: %w", err)
+	}
+	return entry, true, nil
+}
+
+func (s *RedisStore) Set(ctx context.Context, key string, entry Entry, ttl time.Duration) error {
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Do(ctx, "SET", KeyPrefix+key, string(raw), "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	return err
+}
+
+func (s *RedisStore) Generations(ctx context.Context, keys ...string) ([]int64, error) {
+	args := []string{"MGET"}
+	for _, key := range keys {
+		args = append(args, KeyPrefix+key)
+	}
+	reply, err := s.client.Do(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	values, ok := reply.([]any)
+	if !ok || len(values) != len(keys) {
+		return nil, fmt.Errorf("unexpected response cache reply %v", reply)
+	}
+	generations := make([]int64, len(keys))
+	for i, value := range values {
+		if raw, ok := value.(string); ok {
+			generations[i], _ = strconv.ParseInt(raw, 10, 64)
+		}
+	}
+	return generations, nil
+}
+
+func (s *RedisStore) Bump(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		if _, err := s.client.Do(ctx, "INCR", KeyPrefix+key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
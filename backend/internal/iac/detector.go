@@ -0,0 +1,149 @@
+package iac
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+type Tool string
+
+const (
+	ToolTerraform  Tool = "terraform"
+	ToolCrossplane Tool = "crossplane"
+)
+
+type ClientFunc func(config, cluster string) kubernetes.Interface
+
+type ResourceRef struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// Ownership describes why a resource is considered managed by an infrastructure-as-code tool.
+type Ownership struct {
+	Resource ResourceRef `json:"resource"`
+	Managed  bool        `json:"managed"`
+	Tool     Tool        `json:"tool,omitempty"`
+	// Owner is the Terraform resource address or the Crossplane claim/composite that renders the object.
+	Owner    string   `json:"owner,omitempty"`
+	Evidence []string `json:"evidence,omitempty"`
+}
+
+type Detector struct {
+	clients ClientFunc
+	state   *StateIndex
+}
+
+func NewDetector(clients ClientFunc, state *StateIndex) *Detector {
+	return &Detector{clients: clients, state: state}
+}
+
+func (d *Detector) Detect(ctx context.Context, config, cluster string, ref ResourceRef) (Ownership, error) {
+	meta, err := objectMeta(ctx, d.clients(config, cluster), ref)
+	if err != nil {
+		return Ownership{}, err
+	}
+	ownership := Inspect(meta, ref)
+	if ownership.Managed {
+		return ownership, nil
+	}
+	if address, ok := d.state.Lookup(ref); ok {
+		ownership.Managed = true
+		ownership.Tool = ToolTerraform
+		ownership.Owner = address
+		ownership.Evidence = append(ownership.Evidence, "listed in Terraform state as "+address)
+	}
+	return ownership, nil
+}
+
+// Inspect looks for Terraform and Crossplane markers in the object's metadata.
+func Inspect(meta metav1.Object, ref ResourceRef) Ownership {
+	ownership := Ownership{Resource: ref}
+
+	labels := meta.GetLabels()
+	annotations := meta.GetAnnotations()
+
+	if claim := labels["crossplane.io/claim-name"]; claim != "" {
+		namespace := labels["crossplane.io/claim-namespace"]
+		ownership.mark(ToolCrossplane, fmt.Sprintf("claim %s/%s", namespace, claim), "label crossplane.io/claim-name="+claim)
+	}
+	if composite := labels["crossplane.io/composite"]; composite != "" {
+		ownership.mark(ToolCrossplane, "composite "+composite, "label crossplane.io/composite="+composite)
+	}
+	for _, owner := range meta.GetOwnerReferences() {
+		if group := apiGroup(owner.APIVersion); group == "crossplane.io" || strings.HasSuffix(group, ".crossplane.io") {
+			ownership.mark(ToolCrossplane, fmt.Sprintf("%s %s", strings.ToLower(owner.Kind), owner.Name), "owned by "+owner.Kind+" "+owner.Name)
+		}
+	}
+	if ownership.Managed {
+		return ownership
+	}
+
+	if strings.EqualFold(labels["app.kubernetes.io/managed-by"], "terraform") {
+		ownership.mark(ToolTerraform, "", "label app.kubernetes.io/managed-by="+labels["app.kubernetes.io/managed-by"])
+	}
+	for _, key := range sortedKeys(annotations) {
+		if strings.HasPrefix(key, "terraform.io/") || strings.HasPrefix(key, "app.terraform.io/") {
+			ownership.mark(ToolTerraform, "", "annotation "+key)
+		}
+	}
+	for _, field := range meta.GetManagedFields() {
+		if strings.EqualFold(field.Manager, "terraform") || strings.HasPrefix(strings.ToLower(field.Manager), "terraform-provider-") {
+			ownership.mark(ToolTerraform, "", "field manager "+field.Manager)
+			break
+		}
+	}
+	return ownership
+}
+
+func (o *Ownership) mark(tool Tool, owner, evidence string) {
+	o.Managed = true
+	o.Tool = tool
+	if o.Owner == "" {
+		o.Owner = owner
+	}
+	o.Evidence = append(o.Evidence, evidence)
+}
+
+func objectMeta(ctx context.Context, client kubernetes.Interface, ref ResourceRef) (metav1.Object, error) {
+	opts := metav1.GetOptions{}
+	switch strings.ToLower(ref.Kind) {
+	case "deployment":
+		return client.AppsV1().Deployments(ref.Namespace).Get(ctx, ref.Name, opts)
+	case "statefulset":
+		return client.AppsV1().StatefulSets(ref.Namespace).Get(ctx, ref.Name, opts)
+	case "daemonset":
+		return client.AppsV1().DaemonSets(ref.Namespace).Get(ctx, ref.Name, opts)
+	case "pod":
+		return client.CoreV1().Pods(ref.Namespace).Get(ctx, ref.Name, opts)
+	case "service":
+		return client.CoreV1().Services(ref.Namespace).Get(ctx, ref.Name, opts)
+	case "configmap":
+		return client.CoreV1().ConfigMaps(ref.Namespace).Get(ctx, ref.Name, opts)
+	case "namespace":
+		return client.CoreV1().Namespaces().Get(ctx, ref.Name, opts)
+	}
+	return nil, fmt.Errorf("ownership detection does not support kind %q", ref.Kind)
+}
+
+func apiGroup(apiVersion string) string {
+	if group, _, ok := strings.Cut(apiVersion, "/"); ok {
+		return group
+	}
+	return ""
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,83 @@
+package iac
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const sampleState = `{
+  "version": 4,
+  "resources": [
+    {
+      "module": "module.shop",
+      "mode": "managed",
+      "type": "kubernetes_deployment_v1",
+      "name": "api",
+      "instances": [{"attributes": {"metadata": [{"name": "api", "namespace": "shop"}]}}]
+    },
+    {
+      "mode": "data",
+      "type": "kubernetes_service_v1",
+      "name": "lookup",
+      "instances": [{"attributes": {"metadata": [{"name": "db", "namespace": "shop"}]}}]
+    }
+  ]
+}`
+
+func TestDetectorUsesTerraformState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "terraform.tfstate")
+	if err := os.WriteFile(path, []byte(sampleState), 0o600); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	state, err := LoadStateIndex(path)
+	if err != nil {
+		t.Fatalf("load state: %v", err)
+	}
+	client := fake.NewSimpleClientset(&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "shop"}})
+	detector := NewDetector(func(config, cluster string) kubernetes.Interface { return client }, state)
+
+	ownership, err := detector.Detect(context.Background(), "c", "k", ResourceRef{Kind: "Deployment", Namespace: "shop", Name: "api"})
+	if err != nil {
+		t.Fatalf("detect: %v", err)
+	}
+	if !ownership.Managed || ownership.Tool != ToolTerraform || ownership.Owner != "module.shop.kubernetes_deployment_v1.api" {
+		t.Fatalf("unexpected ownership %+v", ownership)
+	}
+
+	suggestion := Suggest(ownership, Change{Operation: "scale", Parameters: map[string]string{"replicas": "4"}})
+	if !strings.Contains(suggestion, `resource "kubernetes_deployment_v1" "api"`) || !strings.Contains(suggestion, "replicas = 4") {
+		t.Fatalf("unexpected suggestion:\n%s", suggestion)
+	}
+	if _, ok := state.Lookup(ResourceRef{Kind: "Service", Namespace: "shop", Name: "db"}); ok {
+		t.Fatalf("data sources must not count as managed")
+	}
+}
+
+func TestInspectRecognisesMarkers(t *testing.T) {
+	crossplane := &metav1.ObjectMeta{Labels: map[string]string{
+		"crossplane.io/claim-name":      "orders-db",
+		"crossplane.io/claim-namespace": "shop",
+	}}
+	ownership := Inspect(crossplane, ResourceRef{Kind: "Deployment", Namespace: "shop", Name: "orders"})
+	if ownership.Tool != ToolCrossplane || ownership.Owner != "claim shop/orders-db" {
+		t.Fatalf("unexpected crossplane ownership %+v", ownership)
+	}
+
+	terraform := &metav1.ObjectMeta{ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl"}, {Manager: "Terraform"}}}
+	ownership = Inspect(terraform, ResourceRef{Kind: "ConfigMap", Name: "settings"})
+	if ownership.Tool != ToolTerraform || len(ownership.Evidence) != 1 {
+		t.Fatalf("unexpected terraform ownership %+v", ownership)
+	}
+
+	if Inspect(&metav1.ObjectMeta{}, ResourceRef{}).Managed {
+		t.Fatalf("plain objects must not be managed")
+	}
+}
@@ -0,0 +1,30 @@
+package iac
+
+import (
+	"context"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/commands"
+)
+
+// CommandGuard adapts Detector to the command service's drift check.
+type CommandGuard struct {
+	detector *Detector
+}
+
+func NewCommandGuard(detector *Detector) *CommandGuard {
+	return &CommandGuard{detector: detector}
+}
+
+func (g *CommandGuard) Check(ctx context.Context, execution commands.Execution) (*commands.DriftWarning, error) {
+	ref := ResourceRef{Kind: execution.Resource.Kind, Namespace: execution.Resource.Namespace, Name: execution.Resource.Name}
+	ownership, err := g.detector.Detect(ctx, execution.Config, execution.Cluster, ref)
+	if err != nil || !ownership.Managed {
+		return nil, err
+	}
+	return &commands.DriftWarning{
+		Tool:       string(ownership.Tool),
+		Owner:      ownership.Owner,
+		Evidence:   ownership.Evidence,
+		Suggestion: Suggest(ownership, Change{Operation: string(execution.Operation), Parameters: execution.Parameters}),
+	}, nil
+}
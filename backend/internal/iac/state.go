@@ -0,0 +1,124 @@
+package iac
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// StateIndex maps Kubernetes objects to the Terraform resource addresses that manage them.
+type StateIndex struct {
+	addresses map[string]string
+}
+
+type tfState struct {
+	Resources []struct {
+		Module    string `json:"module"`
+		Mode      string `json:"mode"`
+		Type      string `json:"type"`
+		Name      string `json:"name"`
+		Instances []struct {
+			IndexKey   any             `json:"index_key"`
+			Attributes json.RawMessage `json:"attributes"`
+		} `json:"instances"`
+	} `json:"resources"`
+}
+
+type tfMetadata struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+type tfAttributes struct {
+	Metadata []tfMetadata `json:"metadata"`
+	Manifest *struct {
+		Kind     string     `json:"kind"`
+		Metadata tfMetadata `json:"metadata"`
+	} `json:"manifest"`
+}
+
+// LoadStateIndex reads local Terraform state files (format version 4).
+func LoadStateIndex(paths ...string) (*StateIndex, error) {
+	index := &StateIndex{addresses: make(map[string]string)}
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := index.add(raw); err != nil {
+			return nil, fmt.Errorf("parse terraform state %s: %w", path, err)
+		}
+	}
+	return index, nil
+}
+
+func (s *StateIndex) add(raw []byte) error {
+	var state tfState
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return err
+	}
+	for _, resource := range state.Resources {
+		if resource.Mode != "managed" {
+			continue
+		}
+		kind := kindForType(resource.Type)
+		if kind == "" && resource.Type != "kubernetes_manifest" {
+			continue
+		}
+		for _, instance := range resource.Instances {
+			var attrs tfAttributes
+			if err := json.Unmarshal(instance.Attributes, &attrs); err != nil {
+				return err
+			}
+			address := resourceAddress(resource.Module, resource.Type, resource.Name, instance.IndexKey)
+			switch {
+			case attrs.Manifest != nil:
+				s.addresses[stateKey(attrs.Manifest.Kind, attrs.Manifest.Metadata.Namespace, attrs.Manifest.Metadata.Name)] = address
+			case kind != "" && len(attrs.Metadata) > 0:
+				s.addresses[stateKey(kind, attrs.Metadata[0].Namespace, attrs.Metadata[0].Name)] = address
+			}
+		}
+	}
+	return nil
+}
+
+func (s *StateIndex) Lookup(ref ResourceRef) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	address, ok := s.addresses[stateKey(ref.Kind, ref.Namespace, ref.Name)]
+	return address, ok
+}
+
+// kindForType maps kubernetes provider resource types such as kubernetes_deployment_v1 to their kind.
+func kindForType(resourceType string) string {
+	name, ok := strings.CutPrefix(resourceType, "kubernetes_")
+	if !ok {
+		return ""
+	}
+	name = strings.TrimSuffix(name, "_v1")
+	switch name {
+	case "deployment", "stateful_set", "daemonset", "daemon_set", "service", "config_map", "namespace", "pod", "secret", "ingress", "cron_job", "job":
+		return strings.ReplaceAll(name, "_", "")
+	}
+	return ""
+}
+
+func resourceAddress(module, resourceType, name string, indexKey any) string {
+	address := resourceType + "." + name
+	switch key := indexKey.(type) {
+	case string:
+		address += fmt.Sprintf("[%q]", key)
+	case float64:
+		address += fmt.Sprintf("[%d]", int(key))
+	}
+	if module != "" {
+		address = module + "." + address
+	}
+	return address
+}
+
+func stateKey(kind, namespace, name string) string {
+	return strings.ToLower(kind) + "/" + namespace + "/" + name
+}
@@ -0,0 +1,100 @@
+package iac
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Change is the direct edit a user asked for, expressed independently of how it would be executed.
+type Change struct {
+	Operation  string            `json:"operation"`
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+var terraformTypes = map[string]string{
+	"deployment":  "kubernetes_deployment_v1",
+	"statefulset": "kubernetes_stateful_set_v1",
+	"daemonset":   "kubernetes_daemon_set_v1",
+	"service":     "kubernetes_service_v1",
+	"configmap":   "kubernetes_config_map_v1",
+	"namespace":   "kubernetes_namespace_v1",
+	"pod":         "kubernetes_pod_v1",
+}
+
+var invalidIdentifier = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// Suggest renders the infrastructure-as-code edit equivalent to change for a managed resource.
+func Suggest(ownership Ownership, change Change) string {
+	if !ownership.Managed {
+		return ""
+	}
+	switch ownership.Tool {
+	case ToolTerraform:
+		return suggestTerraform(ownership, change)
+	case ToolCrossplane:
+		return suggestCrossplane(ownership, change)
+	}
+	return ""
+}
+
+func suggestTerraform(ownership Ownership, change Change) string {
+	ref := ownership.Resource
+	resourceType, name := terraformTypes[strings.ToLower(ref.Kind)], invalidIdentifier.ReplaceAllString(ref.Name, "_")
+	if ownership.Owner != "" {
+		if t, n, ok := splitAddress(ownership.Owner); ok {
+			resourceType, name = t, n
+		}
+	}
+	if resourceType == "" {
+		resourceType = "kubernetes_manifest"
+	}
+
+	header := fmt.Sprintf("# %s/%s/%s is managed by Terraform", ref.Kind, ref.Namespace, ref.Name)
+	if ownership.Owner != "" {
+		header += " (" + ownership.Owner + ")"
+	}
+	header += "; apply this change in code and run terraform plan/apply.\n"
+
+	switch change.Operation {
+	case "scale":
+		return header + fmt.Sprintf("resource %q %q {\n  spec {\n    replicas = %s\n  }\n}\n", resourceType, name, change.Parameters["replicas"])
+	case "restart":
+		return header + fmt.Sprintf("resource %q %q {\n  spec {\n    template {\n      metadata {\n        annotations = {\n          \"kubectl.kubernetes.io/restartedAt\" = %q\n        }\n      }\n    }\n  }\n}\n",
+			resourceType, name, time.Now().UTC().Format(time.RFC3339))
+	case "delete":
+		return header + fmt.Sprintf("# Remove the resource block %s.%s (or its module instance) and run terraform apply.\n", resourceType, name)
+	}
+	return header + fmt.Sprintf("# Update resource %s.%s to reflect the %s operation.\n", resourceType, name, change.Operation)
+}
+
+func suggestCrossplane(ownership Ownership, change Change) string {
+	ref := ownership.Resource
+	owner := ownership.Owner
+	if owner == "" {
+		owner = "its claim"
+	}
+	header := fmt.Sprintf("# %s/%s/%s is composed by Crossplane from %s; direct edits are reverted on the next reconcile.\n", ref.Kind, ref.Namespace, ref.Name, owner)
+	switch change.Operation {
+	case "scale":
+		return header + fmt.Sprintf("# Set the replica count on %s (for example spec.parameters.replicas: %s) so the composition renders it.\n", owner, change.Parameters["replicas"])
+	case "restart":
+		return header + fmt.Sprintf("# Bump a rollout annotation or parameter on %s so the composition re-renders the pod template.\n", owner)
+	case "delete":
+		return header + fmt.Sprintf("# Delete or update %s instead; Crossplane recreates composed resources.\n", owner)
+	}
+	return header + fmt.Sprintf("# Apply the %s change to %s.\n", change.Operation, owner)
+}
+
+// splitAddress extracts the resource type and name from a Terraform address such as module.app.kubernetes_deployment_v1.api["a"].
+func splitAddress(address string) (string, string, bool) {
+	if i := strings.Index(address, "["); i >= 0 {
+		address = address[:i]
+	}
+	parts := strings.Split(address, ".")
+	if len(parts) < 2 {
+		return "", "", false
+	}
+	return parts[len(parts)-2], parts[len(parts)-1], true
+}
@@ -0,0 +1,82 @@
+package identity
+
+import (
+	"context"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Header carries the caller's user name until session authentication is wired in.
+const Header = "X-Kubechat-User"
+
+const Anonymous = "anonymous"
+
+// User returns the caller identity for the request.
+func User(ctx echo.Context) string {
+	if user := strings.TrimSpace(ctx.Request().Header.Get(Header)); user != "" {
+		return user
+	}
+	return Anonymous
+}
+
+// GroupsHeader carries the caller's comma-separated group names.
+const GroupsHeader = "X-Kubechat-Groups"
+
+// Groups returns the caller's groups for the request.
+func Groups(ctx echo.Context) []string {
+	var groups []string
+	for _, group := range strings.Split(ctx.Request().Header.Get(GroupsHeader), ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// WorkspaceHeader selects the workspace that shared resources such as dashboards belong to.
+const WorkspaceHeader = "X-Kubechat-Workspace"
+
+const DefaultWorkspace = "default"
+
+// Workspace returns the caller's workspace for the request.
+func Workspace(ctx echo.Context) string {
+	if workspace := strings.TrimSpace(ctx.Request().Header.Get(WorkspaceHeader)); workspace != "" {
+		return workspace
+	}
+	return DefaultWorkspace
+}
+
+type contextKey struct{}
+
+// NewContext carries user into work done on the caller's behalf below the HTTP handler.
+func NewContext(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, contextKey{}, user)
+}
+
+// FromContext returns the user stored by NewContext, or "" when there is none.
+func FromContext(ctx context.Context) string {
+	user, _ := ctx.Value(contextKey{}).(string)
+	return user
+}
+
+// ImpersonationHeader names the impersonation session an admin is acting through.
+const ImpersonationHeader = "X-Kubechat-Impersonate"
+
+const actorKey = "kubechat.actor"
+
+// ActAs makes the rest of the request run as subject, remembering the caller as its actor.
+// The caller's groups are dropped so they do not carry over to the subject.
+func ActAs(ctx echo.Context, subject string) {
+	ctx.Set(actorKey, User(ctx))
+	ctx.Request().Header.Set(Header, subject)
+	ctx.Request().Header.Del(GroupsHeader)
+}
+
+// Actor returns the admin impersonating the caller, or the caller when nobody is.
+func Actor(ctx echo.Context) string {
+	if actor, ok := ctx.Get(actorKey).(string); ok {
+		return actor
+	}
+	return User(ctx)
+}
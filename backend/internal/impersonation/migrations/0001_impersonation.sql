@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS impersonation_sessions (
+    id TEXT PRIMARY KEY,
+    actor TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    status TEXT NOT NULL,
+    body JSONB NOT NULL,
+    requested_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS impersonation_sessions_actor_idx ON impersonation_sessions (actor);
+CREATE INDEX IF NOT EXISTS impersonation_sessions_subject_idx ON impersonation_sessions (subject);
+
+CREATE TABLE IF NOT EXISTS impersonation_audit (
+    id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    actor TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    action TEXT NOT NULL,
+    method TEXT NOT NULL DEFAULT '',
+    path TEXT NOT NULL DEFAULT '',
+    status INTEGER NOT NULL DEFAULT 0,
+    request_id TEXT NOT NULL DEFAULT '',
+    at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS impersonation_audit_session_idx ON impersonation_audit (session_id, at);
@@ -0,0 +1,174 @@
+package impersonation
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pramodksahoo/kubechat/backend/internal/database"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// PostgresRepository stores each session as a JSON document next to the columns used for
+// lookups, and the audit log as plain rows.
+type PostgresRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresRepository applies the impersonation schema migrations and returns a repository backed by db.
+func NewPostgresRepository(ctx context.Context, db *sqlx.DB) (*PostgresRepository, error) {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Migrate(ctx, db, "impersonation", sub); err != nil {
+		return nil, err
+	}
+	return &PostgresRepository{db: db}, nil
+}
+
+type sessionRow struct {
+	ID          string    `db:"id"`
+	Actor       string    `db:"actor"`
+	Subject     string    `db:"subject"`
+	Status      string    `db:"status"`
+	Body        []byte    `db:"body"`
+	RequestedAt time.Time `db:"requested_at"`
+}
+
+type auditRow struct {
+	ID        string    `db:"id"`
+	SessionID string    `db:"session_id"`
+	Actor     string    `db:"actor"`
+	Subject   string    `db:"subject"`
+	Action    string    `db:"action"`
+	Method    string    `db:"method"`
+	Path      string    `db:"path"`
+	Status    int       `db:"status"`
+	RequestID string    `db:"request_id"`
+	At        time.Time `db:"at"`
+}
+
+func (r *PostgresRepository) SaveSession(ctx context.Context, session Session) error {
+	body, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.NamedExecContext(ctx, `INSERT INTO impersonation_sessions (id, actor, subject, status, body, requested_at)
+		VALUES (:id, :actor, :subject, :status, :body, :requested_at)
+		ON CONFLICT (id) DO UPDATE SET status = EXCLUDED.status, body = EXCLUDED.body`, sessionRow{
+		ID:          session.ID,
+		Actor:       session.Actor,
+		Subject:     session.Subject,
+		Status:      string(session.Status),
+		Body:        body,
+		RequestedAt: session.RequestedAt,
+	})
+	return err
+}
+
+func (r *PostgresRepository) GetSession(ctx context.Context, id string) (Session, error) {
+	var body []byte
+	err := r.db.GetContext(ctx, &body, `SELECT body FROM impersonation_sessions WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, ErrSessionNotFound{ID: id}
+	}
+	if err != nil {
+		return Session{}, err
+	}
+	var session Session
+	return session, json.Unmarshal(body, &session)
+}
+
+func (r *PostgresRepository) ListSessions(ctx context.Context, user string) ([]Session, error) {
+	query := `SELECT body FROM impersonation_sessions`
+	var args []any
+	if user != "" {
+		query += ` WHERE actor = $1 OR subject = $1`
+		args = append(args, user)
+	}
+	var bodies [][]byte
+	if err := r.db.SelectContext(ctx, &bodies, query+` ORDER BY requested_at DESC`, args...); err != nil {
+		return nil, err
+	}
+	sessions := make([]Session, 0, len(bodies))
+	for _, body := range bodies {
+		var session Session
+		if err := json.Unmarshal(body, &session); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+func (r *PostgresRepository) AppendAudit(ctx context.Context, entry AuditEntry) error {
+	_, err := r.db.NamedExecContext(ctx, `INSERT INTO impersonation_audit (id, session_id, actor, subject, action, method, path, status, request_id, at)
+		VALUES (:id, :session_id, :actor, :subject, :action, :method, :path, :status, :request_id, :at)`, auditRow{
+		ID:        entry.ID,
+		SessionID: entry.SessionID,
+		Actor:     entry.Actor,
+		Subject:   entry.Subject,
+		Action:    string(entry.Action),
+		Method:    entry.Method,
+		Path:      entry.Path,
+		Status:    entry.Status,
+		RequestID: entry.RequestID,
+		At:        entry.At,
+	})
+	return err
+}
+
+func (r *PostgresRepository) ListAudit(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	var (
+		conditions []string
+		args       []any
+	)
+	if filter.SessionID != "" {
+		args = append(args, filter.SessionID)
+		conditions = append(conditions, "session_id = $"+strconv.Itoa(len(args)))
+	}
+	if filter.User != "" {
+		args = append(args, filter.User)
+		n := strconv.Itoa(len(args))
+		conditions = append(conditions, "(actor = $"+n+" OR subject = $"+n+")")
+	}
+	query := `SELECT id, session_id, actor, subject, action, method, path, status, request_id, at FROM impersonation_audit`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY at DESC`
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += ` LIMIT $` + strconv.Itoa(len(args))
+	}
+	var rows []auditRow
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, err
+	}
+	entries := make([]AuditEntry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, AuditEntry{
+			ID:        row.ID,
+			SessionID: row.SessionID,
+			Actor:     row.Actor,
+			Subject:   row.Subject,
+			Action:    Action(row.Action),
+			Method:    row.Method,
+			Path:      row.Path,
+			Status:    row.Status,
+			RequestID: row.RequestID,
+			At:        row.At.UTC(),
+		})
+	}
+	return entries, nil
+}
@@ -0,0 +1,81 @@
+package impersonation
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Repository persists sessions and the audit log. Audit entries are only ever appended.
+type Repository interface {
+	SaveSession(ctx context.Context, session Session) error
+	GetSession(ctx context.Context, id string) (Session, error)
+	// ListSessions returns sessions naming user as actor or subject, newest first; all of them when user is empty.
+	ListSessions(ctx context.Context, user string) ([]Session, error)
+	AppendAudit(ctx context.Context, entry AuditEntry) error
+	// ListAudit returns matching entries, newest first.
+	ListAudit(ctx context.Context, filter AuditFilter) ([]AuditEntry, error)
+}
+
+// MemoryRepository keeps sessions and the audit log in process memory; it is used when no database is configured.
+type MemoryRepository struct {
+	mu       sync.RWMutex
+	sessions map[string]Session
+	audit    []AuditEntry
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{sessions: make(map[string]Session)}
+}
+
+func (r *MemoryRepository) SaveSession(ctx context.Context, session Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[session.ID] = session
+	return nil
+}
+
+func (r *MemoryRepository) GetSession(ctx context.Context, id string) (Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	session, ok := r.sessions[id]
+	if !ok {
+		return Session{}, ErrSessionNotFound{ID: id}
+	}
+	return session, nil
+}
+
+func (r *MemoryRepository) ListSessions(ctx context.Context, user string) ([]Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	sessions := make([]Session, 0)
+	for _, session := range r.sessions {
+		if user == "" || session.Actor == user || session.Subject == user {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].RequestedAt.After(sessions[j].RequestedAt) })
+	return sessions, nil
+}
+
+func (r *MemoryRepository) AppendAudit(ctx context.Context, entry AuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.audit = append(r.audit, entry)
+	return nil
+}
+
+func (r *MemoryRepository) ListAudit(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entries := make([]AuditEntry, 0)
+	for i := len(r.audit) - 1; i >= 0; i-- {
+		if filter.matches(r.audit[i]) {
+			entries = append(entries, r.audit[i])
+			if filter.Limit > 0 && len(entries) == filter.Limit {
+				break
+			}
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,267 @@
+package impersonation
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/pramodksahoo/kubechat/backend/internal/audit"
+)
+
+// RequestInput asks to act as Subject for Minutes. BreakGlass starts the session without
+// waiting for consent and is limited to the policy's break-glass admins.
+type RequestInput struct {
+	Subject    string `json:"subject"`
+	Reason     string `json:"reason"`
+	Minutes    int    `json:"minutes,omitempty"`
+	BreakGlass bool   `json:"breakGlass,omitempty"`
+}
+
+type Service struct {
+	repo   Repository
+	policy Policy
+	ledger Ledger
+	logger *log.Logger
+	now    func() time.Time
+}
+
+// Ledger chains audit entries so that tampering with the stored audit log is detected.
+type Ledger interface {
+	Append(ctx context.Context, source audit.Source, entry any) error
+}
+
+func NewService(repo Repository, policy Policy, logger *log.Logger) *Service {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Service{repo: repo, policy: policy, logger: logger, now: func() time.Time { return time.Now().UTC() }}
+}
+
+// SetLedger adds every audit entry, including requests made while impersonating, to ledger.
+func (s *Service) SetLedger(ledger Ledger) {
+	s.ledger = ledger
+}
+
+// Request opens a session for actor, whose role the caller has checked. It waits for the
+// subject's consent unless it breaks glass.
+func (s *Service) Request(ctx context.Context, actor string, in RequestInput) (Session, error) {
+	in.Subject, in.Reason = strings.TrimSpace(in.Subject), strings.TrimSpace(in.Reason)
+	if in.Subject == "" || in.Reason == "" {
+		return Session{}, ErrInvalidRequest{Reason: "subject and reason are required"}
+	}
+	if in.Subject == actor {
+		return Session{}, ErrInvalidRequest{Reason: "cannot impersonate yourself"}
+	}
+	if in.Minutes == 0 {
+		in.Minutes = int(DefaultDuration / time.Minute)
+	}
+	if in.Minutes < 0 || time.Duration(in.Minutes)*time.Minute > MaxDuration {
+		return Session{}, ErrInvalidRequest{Reason: "minutes must be between 1 and " + MaxDuration.String()}
+	}
+	if in.BreakGlass && !s.policy.canBreakGlass(actor) {
+		return Session{}, ErrForbidden{Reason: actor + " is not allowed to break glass"}
+	}
+
+	session := Session{
+		ID:          uuid.NewString(),
+		Actor:       actor,
+		Subject:     in.Subject,
+		Reason:      in.Reason,
+		Mode:        ModeConsent,
+		Status:      StatusPending,
+		Minutes:     in.Minutes,
+		RequestedAt: s.now(),
+	}
+	action := ActionRequested
+	if in.BreakGlass {
+		session.Mode = ModeBreakGlass
+		s.start(&session)
+		action = ActionBreakGlass
+		s.logger.Warn("break-glass impersonation started", "actor", actor, "subject", in.Subject, "reason", in.Reason)
+	}
+	if err := s.repo.SaveSession(ctx, session); err != nil {
+		return Session{}, err
+	}
+	return session, s.audit(ctx, session, action)
+}
+
+// Consent lets the subject start a pending session.
+func (s *Service) Consent(ctx context.Context, id, user string) (Session, error) {
+	return s.decide(ctx, id, user, func(session *Session) Action {
+		s.start(session)
+		return ActionConsented
+	})
+}
+
+// Deny lets the subject refuse a pending session.
+func (s *Service) Deny(ctx context.Context, id, user string) (Session, error) {
+	return s.decide(ctx, id, user, func(session *Session) Action {
+		s.finish(session, StatusDenied, user)
+		return ActionDenied
+	})
+}
+
+func (s *Service) decide(ctx context.Context, id, user string, apply func(*Session) Action) (Session, error) {
+	session, err := s.load(ctx, id)
+	if err != nil {
+		return Session{}, err
+	}
+	if session.Subject != user {
+		return Session{}, ErrForbidden{Reason: "only " + session.Subject + " can answer this request"}
+	}
+	if session.Status != StatusPending {
+		return Session{}, ErrSessionState{ID: id, Status: session.Status}
+	}
+	action := apply(&session)
+	if err := s.repo.SaveSession(ctx, session); err != nil {
+		return Session{}, err
+	}
+	return session, s.audit(ctx, session, action)
+}
+
+// End closes a pending or active session; either the actor or the subject may end it.
+func (s *Service) End(ctx context.Context, id, user string) (Session, error) {
+	session, err := s.load(ctx, id)
+	if err != nil {
+		return Session{}, err
+	}
+	if session.Actor != user && session.Subject != user {
+		return Session{}, ErrForbidden{Reason: "only " + session.Actor + " or " + session.Subject + " can end this session"}
+	}
+	if session.Status != StatusPending && session.Status != StatusActive {
+		return Session{}, ErrSessionState{ID: id, Status: session.Status}
+	}
+	s.finish(&session, StatusEnded, user)
+	if err := s.repo.SaveSession(ctx, session); err != nil {
+		return Session{}, err
+	}
+	return session, s.audit(ctx, session, ActionEnded)
+}
+
+// Authorize returns the session actor may act through, refusing sessions that are not active.
+func (s *Service) Authorize(ctx context.Context, id, actor string) (Session, error) {
+	session, err := s.load(ctx, id)
+	if err != nil {
+		return Session{}, err
+	}
+	if session.Actor != actor {
+		return Session{}, ErrForbidden{Reason: "impersonation session belongs to " + session.Actor}
+	}
+	if session.Status != StatusActive {
+		return Session{}, ErrSessionState{ID: id, Status: session.Status}
+	}
+	return session, nil
+}
+
+// RecordRequest adds a request made through session to the audit log.
+func (s *Service) RecordRequest(ctx context.Context, session Session, method, path string, status int, requestID string) error {
+	return s.appendAudit(ctx, AuditEntry{
+		ID:        uuid.NewString(),
+		SessionID: session.ID,
+		Actor:     session.Actor,
+		Subject:   session.Subject,
+		Action:    ActionRequest,
+		Method:    method,
+		Path:      path,
+		Status:    status,
+		RequestID: requestID,
+		At:        s.now(),
+	})
+}
+
+// Sessions lists the sessions user requested or was asked to consent to.
+func (s *Service) Sessions(ctx context.Context, user string) ([]Session, error) {
+	return s.expireAll(ctx, user)
+}
+
+// AllSessions lists every session, for admins whose role the caller has checked.
+func (s *Service) AllSessions(ctx context.Context) ([]Session, error) {
+	return s.expireAll(ctx, "")
+}
+
+// History lists every session regardless of the caller, for compliance reports whose readers
+// were authorized by their route.
+func (s *Service) History(ctx context.Context) ([]Session, error) {
+	return s.expireAll(ctx, "")
+}
+
+// Audit returns the audit log. Unless all is set, the caller only sees entries naming them.
+func (s *Service) Audit(ctx context.Context, caller string, all bool, filter AuditFilter) ([]AuditEntry, error) {
+	if !all {
+		filter.User = caller
+	}
+	return s.repo.ListAudit(ctx, filter)
+}
+
+func (s *Service) start(session *Session) {
+	started := s.now()
+	expires := started.Add(time.Duration(session.Minutes) * time.Minute)
+	session.Status = StatusActive
+	session.StartedAt = &started
+	session.ExpiresAt = &expires
+}
+
+func (s *Service) finish(session *Session, status Status, by string) {
+	ended := s.now()
+	session.Status = status
+	session.EndedAt = &ended
+	session.EndedBy = by
+}
+
+// load returns a session, first expiring it when it has run out.
+func (s *Service) load(ctx context.Context, id string) (Session, error) {
+	session, err := s.repo.GetSession(ctx, id)
+	if err != nil {
+		return Session{}, err
+	}
+	return s.expire(ctx, session)
+}
+
+func (s *Service) expireAll(ctx context.Context, user string) ([]Session, error) {
+	sessions, err := s.repo.ListSessions(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		if sessions[i], err = s.expire(ctx, sessions[i]); err != nil {
+			return nil, err
+		}
+	}
+	return sessions, nil
+}
+
+func (s *Service) expire(ctx context.Context, session Session) (Session, error) {
+	if !session.expired(s.now()) {
+		return session, nil
+	}
+	ended := *session.ExpiresAt
+	session.Status = StatusExpired
+	session.EndedAt = &ended
+	if err := s.repo.SaveSession(ctx, session); err != nil {
+		return Session{}, err
+	}
+	return session, s.audit(ctx, session, ActionExpired)
+}
+
+func (s *Service) audit(ctx context.Context, session Session, action Action) error {
+	return s.appendAudit(ctx, AuditEntry{
+		ID:        uuid.NewString(),
+		SessionID: session.ID,
+		Actor:     session.Actor,
+		Subject:   session.Subject,
+		Action:    action,
+		At:        s.now(),
+	})
+}
+
+func (s *Service) appendAudit(ctx context.Context, entry AuditEntry) error {
+	if err := s.repo.AppendAudit(ctx, entry); err != nil {
+		return err
+	}
+	if s.ledger == nil {
+		return nil
+	}
+	return s.ledger.Append(ctx, audit.SourceImpersonation, entry)
+}
@@ -0,0 +1,130 @@
+package impersonation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pramodksahoo/kubechat/backend/internal/audit"
+)
+
+func newTestService() (*Service, *time.Time) {
+	svc := NewService(NewMemoryRepository(), Policy{BreakGlass: []string{"oncall"}}, log.NewWithOptions(io.Discard, log.Options{}))
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	return svc, &now
+}
+
+func TestSessionNeedsConsentAndExpires(t *testing.T) {
+	svc, now := newTestService()
+	ctx := context.Background()
+
+	session, err := svc.Request(ctx, "admin", RequestInput{Subject: "alice", Reason: "chat history fails to load", Minutes: 15})
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	var state ErrSessionState
+	if _, err := svc.Authorize(ctx, session.ID, "admin"); !errors.As(err, &state) || state.Status != StatusPending {
+		t.Fatalf("expected a pending session to be unusable, got %v", err)
+	}
+	var forbidden ErrForbidden
+	if _, err := svc.Consent(ctx, session.ID, "admin"); !errors.As(err, &forbidden) {
+		t.Fatalf("expected only alice to consent, got %v", err)
+	}
+	if session, err = svc.Consent(ctx, session.ID, "alice"); err != nil || session.Status != StatusActive {
+		t.Fatalf("expected consent to start the session, got %+v (%v)", session, err)
+	}
+	if _, err := svc.Authorize(ctx, session.ID, "mallory"); !errors.As(err, &forbidden) {
+		t.Fatalf("expected another user to be refused, got %v", err)
+	}
+	if _, err := svc.Authorize(ctx, session.ID, "admin"); err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	if err := svc.RecordRequest(ctx, session, "GET", "/api/v1/commands", 200, "req-1"); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+
+	*now = now.Add(15 * time.Minute)
+	if _, err := svc.Authorize(ctx, session.ID, "admin"); !errors.As(err, &state) || state.Status != StatusExpired {
+		t.Fatalf("expected the session to expire, got %v", err)
+	}
+
+	entries, err := svc.Audit(ctx, "admin", true, AuditFilter{SessionID: session.ID})
+	if err != nil {
+		t.Fatalf("audit: %v", err)
+	}
+	var actions []Action
+	for _, entry := range entries {
+		if entry.Actor != "admin" || entry.Subject != "alice" {
+			t.Fatalf("expected every entry to name both users, got %+v", entry)
+		}
+		actions = append(actions, entry.Action)
+	}
+	want := []Action{ActionExpired, ActionRequest, ActionConsented, ActionRequested}
+	if len(actions) != len(want) {
+		t.Fatalf("expected %v, got %v", want, actions)
+	}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, actions)
+		}
+	}
+}
+
+func TestBreakGlassPolicy(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+
+	var forbidden ErrForbidden
+	if _, err := svc.Request(ctx, "admin", RequestInput{Subject: "bob", Reason: "outage", BreakGlass: true}); !errors.As(err, &forbidden) {
+		t.Fatalf("expected admins outside the break-glass list to be refused, got %v", err)
+	}
+	var invalid ErrInvalidRequest
+	if _, err := svc.Request(ctx, "oncall", RequestInput{Subject: "bob", Reason: "outage", Minutes: 300}); !errors.As(err, &invalid) {
+		t.Fatalf("expected sessions beyond the maximum to be refused, got %v", err)
+	}
+
+	session, err := svc.Request(ctx, "oncall", RequestInput{Subject: "bob", Reason: "outage", BreakGlass: true})
+	if err != nil || session.Status != StatusActive || session.Mode != ModeBreakGlass {
+		t.Fatalf("expected break glass to start immediately, got %+v (%v)", session, err)
+	}
+	if session, err = svc.End(ctx, session.ID, "bob"); err != nil || session.EndedBy != "bob" {
+		t.Fatalf("expected bob to end the session, got %+v (%v)", session, err)
+	}
+
+	// Callers without the whole log only see the entries about them.
+	entries, err := svc.Audit(ctx, "bob", false, AuditFilter{User: "someone-else"})
+	if err != nil || len(entries) != 2 || entries[0].Action != ActionEnded || entries[1].Action != ActionBreakGlass {
+		t.Fatalf("unexpected audit entries for bob: %+v (%v)", entries, err)
+	}
+	if entries, _ := svc.Audit(ctx, "carol", false, AuditFilter{}); len(entries) != 0 {
+		t.Fatalf("expected carol to see nothing, got %+v", entries)
+	}
+}
+
+func TestAuditEntriesAreChainedIntoTheLedger(t *testing.T) {
+	svc, _ := newTestService()
+	ledger := audit.NewLedger(audit.NewMemoryRepository(), log.NewWithOptions(io.Discard, log.Options{}))
+	svc.SetLedger(ledger)
+	ctx := context.Background()
+
+	session, err := svc.Request(ctx, "oncall", RequestInput{Subject: "alice", Reason: "outage", BreakGlass: true})
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if err := svc.RecordRequest(ctx, session, "DELETE", "/api/v1/pods", 200, "req-1"); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	records, err := ledger.Records(ctx, 0, 0)
+	if err != nil || len(records) != 2 || records[0].Source != audit.SourceImpersonation || records[1].PrevHash != records[0].Hash {
+		t.Fatalf("expected both entries chained in the ledger, got %+v (%v)", records, err)
+	}
+	var entry AuditEntry
+	if err := json.Unmarshal(records[1].Entry, &entry); err != nil || entry.Action != ActionRequest || entry.RequestID != "req-1" {
+		t.Fatalf("expected the request entry as audited, got %+v (%v)", entry, err)
+	}
+}
@@ -0,0 +1,143 @@
+// Package impersonation lets admins act as another user for troubleshooting, with the user's
+// consent or under a break-glass policy, and keeps an audit trail naming both of them.
+package impersonation
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	// DefaultDuration applies when a request does not ask for a duration.
+	DefaultDuration = 30 * time.Minute
+	// MaxDuration bounds how long one session stays active.
+	MaxDuration = 4 * time.Hour
+)
+
+// Mode records how a session was authorized.
+type Mode string
+
+const (
+	ModeConsent    Mode = "consent"
+	ModeBreakGlass Mode = "break_glass"
+)
+
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusActive  Status = "active"
+	StatusDenied  Status = "denied"
+	StatusEnded   Status = "ended"
+	StatusExpired Status = "expired"
+)
+
+// Session lets Actor make requests as Subject while it is active.
+type Session struct {
+	ID      string `json:"id"`
+	Actor   string `json:"actor"`
+	Subject string `json:"subject"`
+	Reason  string `json:"reason"`
+	Mode    Mode   `json:"mode"`
+	Status  Status `json:"status"`
+	// Minutes is how long the session stays active once it starts.
+	Minutes     int        `json:"minutes"`
+	RequestedAt time.Time  `json:"requestedAt"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	EndedAt     *time.Time `json:"endedAt,omitempty"`
+	EndedBy     string     `json:"endedBy,omitempty"`
+}
+
+// expired reports whether an active session has run past its expiry at now.
+func (s Session) expired(now time.Time) bool {
+	return s.Status == StatusActive && s.ExpiresAt != nil && !now.Before(*s.ExpiresAt)
+}
+
+// Action names an audit log entry.
+type Action string
+
+const (
+	ActionRequested  Action = "requested"
+	ActionConsented  Action = "consented"
+	ActionDenied     Action = "denied"
+	ActionBreakGlass Action = "break_glass"
+	ActionEnded      Action = "ended"
+	ActionExpired    Action = "expired"
+	// ActionRequest is an API request the actor made as the subject.
+	ActionRequest Action = "request"
+)
+
+// AuditEntry attributes one action to both the admin who took it and the user it affected.
+type AuditEntry struct {
+	ID        string    `json:"id"`
+	SessionID string    `json:"sessionId"`
+	Actor     string    `json:"actor"`
+	Subject   string    `json:"subject"`
+	Action    Action    `json:"action"`
+	Method    string    `json:"method,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	Status    int       `json:"status,omitempty"`
+	RequestID string    `json:"requestId,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+// AuditFilter narrows the audit log to one session or to entries naming one user.
+type AuditFilter struct {
+	SessionID string
+	User      string
+	Limit     int
+}
+
+func (f AuditFilter) matches(entry AuditEntry) bool {
+	if f.SessionID != "" && entry.SessionID != f.SessionID {
+		return false
+	}
+	return f.User == "" || entry.Actor == f.User || entry.Subject == f.User
+}
+
+// Policy names the users who may break glass, starting a session without waiting for consent.
+// Who may request impersonation at all is left to the role rules.
+type Policy struct {
+	BreakGlass []string `json:"breakGlass"`
+}
+
+func (p Policy) canBreakGlass(user string) bool {
+	return slices.Contains(p.BreakGlass, user)
+}
+
+type ErrSessionNotFound struct {
+	ID string
+}
+
+func (e ErrSessionNotFound) Error() string {
+	return fmt.Sprintf("impersonation session %s not found", e.ID)
+}
+
+type ErrInvalidRequest struct {
+	Reason string
+}
+
+func (e ErrInvalidRequest) Error() string {
+	return e.Reason
+}
+
+// ErrForbidden is returned when the caller may not take an action on a session.
+type ErrForbidden struct {
+	Reason string
+}
+
+func (e ErrForbidden) Error() string {
+	return e.Reason
+}
+
+// ErrSessionState is returned for actions that do not apply to the session's current status.
+type ErrSessionState struct {
+	ID     string
+	Status Status
+}
+
+func (e ErrSessionState) Error() string {
+	return fmt.Sprintf("impersonation session %s is %s", e.ID, e.Status)
+}
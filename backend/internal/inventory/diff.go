@@ -0,0 +1,138 @@
+package inventory
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+type ChangeType string
+
+const (
+	ChangeAdded    ChangeType = "added"
+	ChangeRemoved  ChangeType = "removed"
+	ChangeModified ChangeType = "modified"
+)
+
+type Attribution string
+
+const (
+	AttributionExecution Attribution = "execution"
+	AttributionOutOfBand Attribution = "out-of-band"
+	AttributionUnknown   Attribution = "unknown"
+)
+
+type WorkloadChange struct {
+	Kind        string      `json:"kind"`
+	Namespace   string      `json:"namespace"`
+	Name        string      `json:"name"`
+	Type        ChangeType  `json:"type"`
+	Details     []string    `json:"details"`
+	Attribution Attribution `json:"attribution"`
+	References  []string    `json:"references,omitempty"`
+}
+
+type Changelog struct {
+	Config    string           `json:"config"`
+	Cluster   string           `json:"cluster"`
+	From      time.Time        `json:"from"`
+	To        time.Time        `json:"to"`
+	Changes   []WorkloadChange `json:"changes"`
+	Summary   []string         `json:"summary"`
+	Baselined bool             `json:"baselined"`
+}
+
+// Diff compares two snapshots of the same cluster and returns the per-workload changes.
+func Diff(before, after Snapshot) []WorkloadChange {
+	var changes []WorkloadChange
+	for _, key := range sortedKeys(after.Workloads) {
+		current := after.Workloads[key]
+		previous, existed := before.Workloads[key]
+		if !existed {
+			changes = append(changes, newChange(current, ChangeAdded, []string{"created"}))
+			continue
+		}
+		if details := describeModification(previous, current); len(details) > 0 {
+			changes = append(changes, newChange(current, ChangeModified, details))
+		}
+	}
+	for _, key := range sortedKeys(before.Workloads) {
+		if _, ok := after.Workloads[key]; !ok {
+			changes = append(changes, newChange(before.Workloads[key], ChangeRemoved, []string{"deleted"}))
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Namespace != changes[j].Namespace {
+			return changes[i].Namespace < changes[j].Namespace
+		}
+		if changes[i].Name != changes[j].Name {
+			return changes[i].Name < changes[j].Name
+		}
+		return changes[i].Kind < changes[j].Kind
+	})
+	return changes
+}
+
+func newChange(state WorkloadState, kind ChangeType, details []string) WorkloadChange {
+	return WorkloadChange{
+		Kind:        state.Kind,
+		Namespace:   state.Namespace,
+		Name:        state.Name,
+		Type:        kind,
+		Details:     details,
+		Attribution: AttributionUnknown,
+	}
+}
+
+func describeModification(before, after WorkloadState) []string {
+	var details []string
+	if before.Replicas != nil && after.Replicas != nil && *before.Replicas != *after.Replicas {
+		details = append(details, fmt.Sprintf("replicas %d → %d", *before.Replicas, *after.Replicas))
+	}
+	for _, name := range sortedKeys(after.Images) {
+		if prev, ok := before.Images[name]; ok && prev != after.Images[name] {
+			details = append(details, fmt.Sprintf("image %s: %s → %s", name, prev, after.Images[name]))
+		} else if !ok {
+			details = append(details, fmt.Sprintf("container %s added (%s)", name, after.Images[name]))
+		}
+	}
+	for _, name := range sortedKeys(before.Images) {
+		if _, ok := after.Images[name]; !ok {
+			details = append(details, fmt.Sprintf("container %s removed", name))
+		}
+	}
+	for _, key := range sortedKeys(after.Labels) {
+		if prev, ok := before.Labels[key]; !ok || prev != after.Labels[key] {
+			details = append(details, fmt.Sprintf("label %s=%s", key, after.Labels[key]))
+		}
+	}
+	if len(details) == 0 && before.SpecHash != after.SpecHash {
+		if after.Kind == "ConfigMap" {
+			details = append(details, "data changed")
+		} else {
+			details = append(details, fmt.Sprintf("spec changed (generation %d → %d)", before.Generation, after.Generation))
+		}
+	}
+	return details
+}
+
+// Summarize renders one human-readable line per changed workload.
+func Summarize(changes []WorkloadChange) []string {
+	lines := make([]string, 0, len(changes))
+	for _, c := range changes {
+		line := fmt.Sprintf("%s %s/%s %s", c.Kind, c.Namespace, c.Name, c.Type)
+		for i, d := range c.Details {
+			if i == 0 {
+				line += ": " + d
+			} else {
+				line += "; " + d
+			}
+		}
+		if c.Attribution == AttributionOutOfBand {
+			line += " [out-of-band]"
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,103 @@
+package inventory
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func replicas(v int32) *int32 { return &v }
+
+func deployment(name, image string, count int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "payments"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: replicas(count),
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: image}}}},
+		},
+	}
+}
+
+func TestDiffDescribesWorkloadChanges(t *testing.T) {
+	before := Snapshot{Workloads: map[string]WorkloadState{}}
+	after := Snapshot{Workloads: map[string]WorkloadState{}}
+
+	old := workloadState("Deployment", metav1.ObjectMeta{Namespace: "payments", Name: "checkout"}, replicas(2), corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "checkout:1"}}}, "v1")
+	updated := workloadState("Deployment", metav1.ObjectMeta{Namespace: "payments", Name: "checkout"}, replicas(4), corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "checkout:2"}}}, "v2")
+	gone := workloadState("Deployment", metav1.ObjectMeta{Namespace: "payments", Name: "legacy"}, replicas(1), corev1.PodSpec{}, "v1")
+	fresh := workloadState("StatefulSet", metav1.ObjectMeta{Namespace: "payments", Name: "ledger"}, replicas(1), corev1.PodSpec{}, "v1")
+
+	before.Workloads[old.Key()] = old
+	before.Workloads[gone.Key()] = gone
+	after.Workloads[updated.Key()] = updated
+	after.Workloads[fresh.Key()] = fresh
+
+	changes := Diff(before, after)
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes, got %+v", changes)
+	}
+	if changes[0].Name != "checkout" || changes[0].Type != ChangeModified {
+		t.Fatalf("unexpected first change %+v", changes[0])
+	}
+	joined := strings.Join(changes[0].Details, ";")
+	if !strings.Contains(joined, "replicas 2 → 4") || !strings.Contains(joined, "image app: checkout:1 → checkout:2") {
+		t.Fatalf("expected replica and image details, got %v", changes[0].Details)
+	}
+	if changes[1].Name != "ledger" || changes[1].Type != ChangeAdded || changes[2].Type != ChangeRemoved {
+		t.Fatalf("unexpected change types %+v", changes)
+	}
+}
+
+type stubAttributor struct {
+	refs map[string][]string
+}
+
+func (s *stubAttributor) Attribute(ctx context.Context, config, cluster string, change WorkloadChange, from, to time.Time) ([]string, error) {
+	return s.refs[change.Name], nil
+}
+
+func TestTrackerChangesFlagsOutOfBandChanges(t *testing.T) {
+	client := fake.NewSimpleClientset(deployment("checkout", "checkout:1", 2), deployment("billing", "billing:1", 1))
+	attributor := &stubAttributor{refs: map[string][]string{"checkout": {"exec-1"}}}
+	tracker := NewTracker(func(config, cluster string) kubernetes.Interface { return client }, time.Hour, attributor, nil)
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker.clock = func() time.Time { return now }
+
+	first, err := tracker.Changes(context.Background(), "cfg", "prod", 24*time.Hour)
+	if err != nil || !first.Baselined {
+		t.Fatalf("expected baseline on first call, got %+v (%v)", first, err)
+	}
+
+	client.AppsV1().Deployments("payments").Update(context.Background(), deployment("checkout", "checkout:2", 2), metav1.UpdateOptions{})
+	client.AppsV1().Deployments("payments").Update(context.Background(), deployment("billing", "billing:1", 3), metav1.UpdateOptions{})
+	now = now.Add(10 * time.Minute)
+
+	log, err := tracker.Changes(context.Background(), "cfg", "prod", 24*time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(log.Changes) != 2 {
+		t.Fatalf("expected two changes, got %+v", log.Changes)
+	}
+	byName := map[string]WorkloadChange{}
+	for _, c := range log.Changes {
+		byName[c.Name] = c
+	}
+	if byName["checkout"].Attribution != AttributionExecution || byName["checkout"].References[0] != "exec-1" {
+		t.Fatalf("expected checkout attributed to execution, got %+v", byName["checkout"])
+	}
+	if byName["billing"].Attribution != AttributionOutOfBand {
+		t.Fatalf("expected billing flagged out-of-band, got %+v", byName["billing"])
+	}
+	if !strings.Contains(strings.Join(log.Summary, "\n"), "[out-of-band]") {
+		t.Fatalf("expected summary to flag out-of-band change, got %v", log.Summary)
+	}
+}
@@ -0,0 +1,222 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// RestartCategory classifies why a container last terminated.
+type RestartCategory string
+
+const (
+	RestartOOM       RestartCategory = "oom"
+	RestartError     RestartCategory = "error"
+	RestartCompleted RestartCategory = "completed"
+	RestartUnknown   RestartCategory = "unknown"
+)
+
+// RestartTrend compares a container's restart count with the baseline snapshot.
+type RestartTrend string
+
+const (
+	TrendRising RestartTrend = "rising"
+	TrendStable RestartTrend = "stable"
+	TrendNew    RestartTrend = "new"
+)
+
+// ContainerState is the restart state of a single container captured in a snapshot.
+type ContainerState struct {
+	Namespace   string     `json:"namespace"`
+	Pod         string     `json:"pod"`
+	Container   string     `json:"container"`
+	Owner       string     `json:"owner,omitempty"`
+	Node        string     `json:"node,omitempty"`
+	Restarts    int32      `json:"restarts"`
+	Reason      string     `json:"reason,omitempty"`
+	ExitCode    int32      `json:"exitCode"`
+	Waiting     string     `json:"waiting,omitempty"`
+	LastRestart *time.Time `json:"lastRestart,omitempty"`
+	MemoryLimit string     `json:"memoryLimit,omitempty"`
+}
+
+func (c ContainerState) Key() string {
+	return c.Namespace + "/" + c.Pod + "/" + c.Container
+}
+
+// Category maps the last termination reason and exit code onto a restart category.
+func (c ContainerState) Category() RestartCategory {
+	switch {
+	case c.Reason == "OOMKilled" || (c.ExitCode == 137 && c.Reason == ""):
+		return RestartOOM
+	case c.Reason == "Completed" && c.ExitCode == 0:
+		return RestartCompleted
+	case c.ExitCode != 0 || c.Reason == "Error" || c.Reason == "ContainerCannotRun":
+		return RestartError
+	}
+	return RestartUnknown
+}
+
+type ContainerRestarts struct {
+	ContainerState
+	Category RestartCategory `json:"category"`
+	// Increase is the number of restarts since the baseline snapshot.
+	Increase int32        `json:"increase"`
+	Trend    RestartTrend `json:"trend"`
+	Summary  string       `json:"summary"`
+}
+
+type RestartReport struct {
+	Config     string              `json:"config"`
+	Cluster    string              `json:"cluster"`
+	From       time.Time           `json:"from"`
+	To         time.Time           `json:"to"`
+	Containers []ContainerRestarts `json:"containers"`
+	// Counts tallies the reported containers by category.
+	Counts    map[RestartCategory]int `json:"counts"`
+	Baselined bool                    `json:"baselined"`
+}
+
+// RestartFilter narrows a restart report.
+type RestartFilter struct {
+	Namespace   string
+	MinRestarts int32
+	Window      time.Duration
+}
+
+// Restarts captures the cluster and reports containers at or above the restart threshold, with the
+// increase since the oldest snapshot inside the window.
+func (t *Tracker) Restarts(ctx context.Context, config, cluster string, filter RestartFilter) (RestartReport, error) {
+	key := clusterKey(config, cluster)
+	windowStart := t.clock().Add(-filter.Window)
+
+	t.mu.Lock()
+	baseline := t.baselineLocked(key, windowStart)
+	t.mu.Unlock()
+
+	current, err := t.Capture(ctx, config, cluster)
+	if err != nil {
+		return RestartReport{}, err
+	}
+	report := RestartReport{
+		Config:    config,
+		Cluster:   cluster,
+		From:      current.CapturedAt,
+		To:        current.CapturedAt,
+		Baselined: baseline == nil,
+	}
+	var previous map[string]ContainerState
+	if baseline != nil {
+		report.From = baseline.CapturedAt
+		previous = baseline.Containers
+	}
+	report.Containers = SummarizeRestarts(current.Containers, previous, filter)
+	report.Counts = map[RestartCategory]int{}
+	for _, container := range report.Containers {
+		report.Counts[container.Category]++
+	}
+	return report, nil
+}
+
+// SummarizeRestarts ranks containers by recent restarts, then by total restarts.
+func SummarizeRestarts(current, baseline map[string]ContainerState, filter RestartFilter) []ContainerRestarts {
+	minRestarts := max(filter.MinRestarts, 1)
+	out := []ContainerRestarts{}
+	for _, key := range sortedKeys(current) {
+		state := current[key]
+		if state.Restarts < minRestarts || filter.Namespace != "" && state.Namespace != filter.Namespace {
+			continue
+		}
+		entry := ContainerRestarts{ContainerState: state, Category: state.Category(), Trend: TrendNew, Increase: state.Restarts}
+		if baseline != nil {
+			if before, ok := baseline[key]; ok && before.Restarts <= state.Restarts {
+				entry.Increase = state.Restarts - before.Restarts
+				entry.Trend = TrendStable
+				if entry.Increase > 0 {
+					entry.Trend = TrendRising
+				}
+			} else {
+				entry.Trend = TrendRising
+			}
+		}
+		entry.Summary = describeRestarts(entry)
+		out = append(out, entry)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Increase != out[j].Increase {
+			return out[i].Increase > out[j].Increase
+		}
+		return out[i].Restarts > out[j].Restarts
+	})
+	return out
+}
+
+func describeRestarts(entry ContainerRestarts) string {
+	summary := fmt.Sprintf("%s/%s container %s restarted %d times", entry.Namespace, entry.Pod, entry.Container, entry.Restarts)
+	switch entry.Category {
+	case RestartOOM:
+		summary += ", last killed for exceeding its memory limit"
+		if entry.MemoryLimit != "" {
+			summary += " of " + entry.MemoryLimit
+		}
+	case RestartError:
+		summary += fmt.Sprintf(", last exited with code %d", entry.ExitCode)
+		if entry.Reason != "" && entry.Reason != "Error" {
+			summary += " (" + entry.Reason + ")"
+		}
+	case RestartCompleted:
+		summary += ", last exited cleanly"
+	}
+	if entry.Waiting != "" {
+		summary += "; now " + entry.Waiting
+	}
+	if entry.Trend == TrendRising && entry.Increase > 0 {
+		summary += fmt.Sprintf("; %d new restarts in the window", entry.Increase)
+	}
+	return summary
+}
+
+func containerStates(pod corev1.Pod) []ContainerState {
+	owner := ""
+	if len(pod.OwnerReferences) > 0 {
+		owner = pod.OwnerReferences[0].Kind + "/" + pod.OwnerReferences[0].Name
+	}
+	limits := map[string]string{}
+	for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+		if limit, ok := container.Resources.Limits[corev1.ResourceMemory]; ok {
+			limits[container.Name] = limit.String()
+		}
+	}
+
+	var states []ContainerState
+	for _, status := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+		if status.RestartCount == 0 {
+			continue
+		}
+		state := ContainerState{
+			Namespace:   pod.Namespace,
+			Pod:         pod.Name,
+			Container:   status.Name,
+			Owner:       owner,
+			Node:        pod.Spec.NodeName,
+			Restarts:    status.RestartCount,
+			MemoryLimit: limits[status.Name],
+		}
+		if terminated := status.LastTerminationState.Terminated; terminated != nil {
+			state.Reason = terminated.Reason
+			state.ExitCode = terminated.ExitCode
+			if !terminated.FinishedAt.IsZero() {
+				finished := terminated.FinishedAt.UTC()
+				state.LastRestart = &finished
+			}
+		}
+		if waiting := status.State.Waiting; waiting != nil {
+			state.Waiting = waiting.Reason
+		}
+		states = append(states, state)
+	}
+	return states
+}
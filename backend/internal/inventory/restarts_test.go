@@ -0,0 +1,70 @@
+package inventory
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func restartingPod(name string, restarts int32, reason string, exitCode int32) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "payments"},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name:      "app",
+			Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")}},
+		}}},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			Name:                 "app",
+			RestartCount:         restarts,
+			State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+			LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: reason, ExitCode: exitCode}},
+		}}},
+	}
+}
+
+func TestTrackerRestartsReportsReasonsAndTrends(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		restartingPod("checkout", 4, "OOMKilled", 137),
+		restartingPod("billing", 6, "Error", 1),
+		restartingPod("quiet", 1, "Error", 1),
+	)
+	tracker := NewTracker(func(config, cluster string) kubernetes.Interface { return client }, time.Hour, nil, nil)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker.clock = func() time.Time { return now }
+
+	first, err := tracker.Restarts(context.Background(), "cfg", "prod", RestartFilter{MinRestarts: 3, Window: time.Hour})
+	if err != nil || !first.Baselined || len(first.Containers) != 2 {
+		t.Fatalf("expected two new containers on first call, got %+v (%v)", first, err)
+	}
+	if first.Containers[0].Pod != "billing" || first.Containers[0].Trend != TrendNew {
+		t.Fatalf("expected billing ranked first, got %+v", first.Containers[0])
+	}
+
+	client.CoreV1().Pods("payments").Update(context.Background(), restartingPod("checkout", 9, "OOMKilled", 137), metav1.UpdateOptions{})
+	now = now.Add(10 * time.Minute)
+
+	report, err := tracker.Restarts(context.Background(), "cfg", "prod", RestartFilter{MinRestarts: 3, Window: time.Hour})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkout := report.Containers[0]
+	if checkout.Pod != "checkout" || checkout.Increase != 5 || checkout.Trend != TrendRising || checkout.Category != RestartOOM {
+		t.Fatalf("expected rising OOM restarts for checkout, got %+v", checkout)
+	}
+	if !strings.Contains(checkout.Summary, "256Mi") || !strings.Contains(checkout.Summary, "CrashLoopBackOff") {
+		t.Fatalf("expected memory limit and waiting reason in summary, got %q", checkout.Summary)
+	}
+	if billing := report.Containers[1]; billing.Trend != TrendStable || billing.Category != RestartError {
+		t.Fatalf("expected stable error restarts for billing, got %+v", billing)
+	}
+	if report.Counts[RestartOOM] != 1 || report.Counts[RestartError] != 1 {
+		t.Fatalf("unexpected counts %v", report.Counts)
+	}
+}
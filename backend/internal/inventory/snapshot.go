@@ -0,0 +1,144 @@
+package inventory
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// WorkloadState is the comparable projection of a workload captured in a snapshot.
+type WorkloadState struct {
+	Kind            string            `json:"kind"`
+	Namespace       string            `json:"namespace"`
+	Name            string            `json:"name"`
+	Generation      int64             `json:"generation"`
+	ResourceVersion string            `json:"resourceVersion"`
+	Replicas        *int32            `json:"replicas,omitempty"`
+	Images          map[string]string `json:"images,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	SpecHash        string            `json:"specHash"`
+}
+
+func (w WorkloadState) Key() string {
+	return w.Kind + "/" + w.Namespace + "/" + w.Name
+}
+
+type Snapshot struct {
+	Config     string                   `json:"config"`
+	Cluster    string                   `json:"cluster"`
+	CapturedAt time.Time                `json:"capturedAt"`
+	Workloads  map[string]WorkloadState `json:"workloads"`
+	// Containers holds restart state for every container that has restarted, keyed by namespace/pod/container.
+	Containers map[string]ContainerState `json:"containers,omitempty"`
+}
+
+// Capture lists workloads, config maps and pods across all namespaces and records their comparable state.
+func Capture(ctx context.Context, client kubernetes.Interface, config, cluster string, now time.Time) (Snapshot, error) {
+	snap := Snapshot{
+		Config:     config,
+		Cluster:    cluster,
+		CapturedAt: now.UTC(),
+		Workloads:  map[string]WorkloadState{},
+		Containers: map[string]ContainerState{},
+	}
+	add := func(state WorkloadState) {
+		snap.Workloads[state.Key()] = state
+	}
+
+	deployments, err := client.AppsV1().Deployments(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return Snapshot{}, err
+	}
+	for _, d := range deployments.Items {
+		add(workloadState("Deployment", d.ObjectMeta, d.Spec.Replicas, d.Spec.Template.Spec, d.Spec))
+	}
+
+	statefulSets, err := client.AppsV1().StatefulSets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return Snapshot{}, err
+	}
+	for _, s := range statefulSets.Items {
+		add(workloadState("StatefulSet", s.ObjectMeta, s.Spec.Replicas, s.Spec.Template.Spec, s.Spec))
+	}
+
+	daemonSets, err := client.AppsV1().DaemonSets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return Snapshot{}, err
+	}
+	for _, d := range daemonSets.Items {
+		add(workloadState("DaemonSet", d.ObjectMeta, nil, d.Spec.Template.Spec, d.Spec))
+	}
+
+	configMaps, err := client.CoreV1().ConfigMaps(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return Snapshot{}, err
+	}
+	for _, cm := range configMaps.Items {
+		add(WorkloadState{
+			Kind:            "ConfigMap",
+			Namespace:       cm.Namespace,
+			Name:            cm.Name,
+			ResourceVersion: cm.ResourceVersion,
+			Labels:          cm.Labels,
+			SpecHash:        hashOf(map[string]any{"data": cm.Data, "binaryData": cm.BinaryData}),
+		})
+	}
+
+	pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return Snapshot{}, err
+	}
+	for _, pod := range pods.Items {
+		for _, state := range containerStates(pod) {
+			snap.Containers[state.Key()] = state
+		}
+	}
+
+	return snap, nil
+}
+
+func workloadState(kind string, meta metav1.ObjectMeta, replicas *int32, pod corev1.PodSpec, spec any) WorkloadState {
+	state := WorkloadState{
+		Kind:            kind,
+		Namespace:       meta.Namespace,
+		Name:            meta.Name,
+		Generation:      meta.Generation,
+		ResourceVersion: meta.ResourceVersion,
+		Labels:          meta.Labels,
+		Images:          map[string]string{},
+		SpecHash:        hashOf(spec),
+	}
+	if replicas != nil {
+		r := *replicas
+		state.Replicas = &r
+	}
+	for _, c := range pod.Containers {
+		state.Images[c.Name] = c.Image
+	}
+	return state
+}
+
+func hashOf(v any) string {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:8])
+}
+
+func sortedKeys[V any](in map[string]V) []string {
+	keys := make([]string, 0, len(in))
+	for k := range in {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
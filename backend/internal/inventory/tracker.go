@@ -0,0 +1,215 @@
+package inventory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ClientFunc resolves a clientset for the given kubeconfig and cluster context.
+type ClientFunc func(config, cluster string) kubernetes.Interface
+
+// ChangeAttributor links an observed change to the executions that caused it.
+type ChangeAttributor interface {
+	Attribute(ctx context.Context, config, cluster string, change WorkloadChange, from, to time.Time) ([]string, error)
+}
+
+// Tracker keeps a rolling window of inventory snapshots per cluster.
+type Tracker struct {
+	clients    ClientFunc
+	retention  time.Duration
+	attributor ChangeAttributor
+	clock      func() time.Time
+	logger     *log.Logger
+
+	mu      sync.Mutex
+	history map[string][]Snapshot
+	tracked map[string][2]string
+}
+
+func NewTracker(clients ClientFunc, retention time.Duration, attributor ChangeAttributor, logger *log.Logger) *Tracker {
+	if retention <= 0 {
+		retention = 48 * time.Hour
+	}
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Tracker{
+		clients:    clients,
+		retention:  retention,
+		attributor: attributor,
+		clock:      time.Now,
+		logger:     logger,
+		history:    map[string][]Snapshot{},
+		tracked:    map[string][2]string{},
+	}
+}
+
+func clusterKey(config, cluster string) string {
+	return config + "/" + cluster
+}
+
+// SetAttributor installs the attributor used to link changes to executions.
+func (t *Tracker) SetAttributor(attributor ChangeAttributor) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.attributor = attributor
+}
+
+// Capture takes a snapshot of the cluster, records it and starts tracking the cluster.
+func (t *Tracker) Capture(ctx context.Context, config, cluster string) (Snapshot, error) {
+	snap, err := Capture(ctx, t.clients(config, cluster), config, cluster, t.clock())
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	key := clusterKey(config, cluster)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tracked[key] = [2]string{config, cluster}
+	t.history[key] = t.trimLocked(append(t.history[key], snap), snap.CapturedAt)
+	return snap, nil
+}
+
+// SetRetention changes how long snapshots are kept; zero keeps them until retention is set again.
+func (t *Tracker) SetRetention(retention time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.retention = retention
+}
+
+// Prune drops snapshots older than the retention and returns how many were removed. The latest
+// snapshot of each cluster is always kept as the baseline for change detection.
+func (t *Tracker) Prune() int {
+	now := t.clock()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	removed := 0
+	for key, history := range t.history {
+		kept := t.trimLocked(history, now)
+		removed += len(history) - len(kept)
+		t.history[key] = kept
+	}
+	return removed
+}
+
+// Expired counts the snapshots Prune would remove under retention.
+func (t *Tracker) Expired(retention time.Duration) int {
+	now := t.clock()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	expired := 0
+	for _, history := range t.history {
+		expired += len(history) - len(trimSnapshots(history, retention, now))
+	}
+	return expired
+}
+
+func (t *Tracker) trimLocked(history []Snapshot, now time.Time) []Snapshot {
+	return trimSnapshots(history, t.retention, now)
+}
+
+func trimSnapshots(history []Snapshot, retention time.Duration, now time.Time) []Snapshot {
+	if retention <= 0 {
+		return history
+	}
+	cutoff := now.Add(-retention)
+	for len(history) > 1 && history[0].CapturedAt.Before(cutoff) {
+		history = history[1:]
+	}
+	return history
+}
+
+// Run snapshots every tracked cluster on the given interval until the context is cancelled.
+func (t *Tracker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.mu.Lock()
+			targets := make([][2]string, 0, len(t.tracked))
+			for _, target := range t.tracked {
+				targets = append(targets, target)
+			}
+			t.mu.Unlock()
+			for _, target := range targets {
+				if _, err := t.Capture(ctx, target[0], target[1]); err != nil {
+					t.logger.Warn("failed to capture inventory snapshot", "config", target[0], "cluster", target[1], "error", err)
+				}
+			}
+		}
+	}
+}
+
+// Changes diffs the oldest snapshot inside the window against the live cluster state.
+func (t *Tracker) Changes(ctx context.Context, config, cluster string, since time.Duration) (Changelog, error) {
+	key := clusterKey(config, cluster)
+	windowStart := t.clock().Add(-since)
+
+	t.mu.Lock()
+	baseline := t.baselineLocked(key, windowStart)
+	attributor := t.attributor
+	t.mu.Unlock()
+
+	current, err := t.Capture(ctx, config, cluster)
+	if err != nil {
+		return Changelog{}, err
+	}
+
+	if baseline == nil {
+		return Changelog{
+			Config:    config,
+			Cluster:   cluster,
+			From:      current.CapturedAt,
+			To:        current.CapturedAt,
+			Changes:   []WorkloadChange{},
+			Summary:   []string{"No earlier snapshot available; baseline captured for future comparisons"},
+			Baselined: true,
+		}, nil
+	}
+
+	changes := Diff(*baseline, current)
+	if attributor != nil {
+		for i := range changes {
+			refs, err := attributor.Attribute(ctx, config, cluster, changes[i], baseline.CapturedAt, current.CapturedAt)
+			if err != nil {
+				t.logger.Warn("failed to attribute change", "workload", changes[i].Name, "error", err)
+				continue
+			}
+			if len(refs) > 0 {
+				changes[i].Attribution = AttributionExecution
+				changes[i].References = refs
+			} else {
+				changes[i].Attribution = AttributionOutOfBand
+			}
+		}
+	}
+
+	return Changelog{
+		Config:  config,
+		Cluster: cluster,
+		From:    baseline.CapturedAt,
+		To:      current.CapturedAt,
+		Changes: changes,
+		Summary: Summarize(changes),
+	}, nil
+}
+
+// baselineLocked returns the oldest snapshot captured inside the window, or the newest one when
+// every snapshot predates it. Callers must hold t.mu.
+func (t *Tracker) baselineLocked(key string, windowStart time.Time) *Snapshot {
+	for i := range t.history[key] {
+		snap := t.history[key][i]
+		if !snap.CapturedAt.Before(windowStart) || i == len(t.history[key])-1 {
+			return &snap
+		}
+	}
+	return nil
+}
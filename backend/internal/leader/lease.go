@@ -0,0 +1,129 @@
+// Package leader elects one replica to run singleton background jobs using Kubernetes Leases.
+package leader
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// LeasePrefix starts the name of every Lease the elector holds.
+const LeasePrefix = "kubechat-"
+
+// LeaseElector holds one coordination.k8s.io Lease per job name. A replica that stops renewing
+// loses the job after the lease duration and another replica takes over.
+type LeaseElector struct {
+	client    kubernetes.Interface
+	namespace string
+	identity  string
+	logger    *log.Logger
+
+	leaseDuration time.Duration
+	renewDeadline time.Duration
+	retryPeriod   time.Duration
+}
+
+// NewLeaseElector campaigns for Leases in namespace. The identity defaults to the host name with
+// a random suffix so restarted pods never reuse a previous holder's identity.
+func NewLeaseElector(client kubernetes.Interface, namespace, identity string, logger *log.Logger) *LeaseElector {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if identity == "" {
+		host, _ := os.Hostname()
+		identity = host + "_" + uuid.NewString()[:8]
+	}
+	return &LeaseElector{
+		client:        client,
+		namespace:     namespace,
+		identity:      identity,
+		logger:        logger,
+		leaseDuration: 15 * time.Second,
+		renewDeadline: 10 * time.Second,
+		retryPeriod:   2 * time.Second,
+	}
+}
+
+// Identity is the holder name this replica records in the Leases it acquires.
+func (e *LeaseElector) Identity() string {
+	return e.identity
+}
+
+// Run implements lifecycle.Elector. fn has returned by the time Run returns, and the Lease is
+// released when ctx is cancelled so another replica can take over without waiting for it to expire.
+func (e *LeaseElector) Run(ctx context.Context, name string, fn func(ctx context.Context)) {
+	lease := LeasePrefix + leaseName(name)
+	for ctx.Err() == nil {
+		var (
+			mu      sync.Mutex
+			running sync.WaitGroup
+			stopped bool
+		)
+		elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+			Lock: &resourcelock.LeaseLock{
+				LeaseMeta:  metav1.ObjectMeta{Namespace: e.namespace, Name: lease},
+				Client:     e.client.CoordinationV1(),
+				LockConfig: resourcelock.ResourceLockConfig{Identity: e.identity},
+			},
+			LeaseDuration:   e.leaseDuration,
+			RenewDeadline:   e.renewDeadline,
+			RetryPeriod:     e.retryPeriod,
+			ReleaseOnCancel: true,
+			Name:            lease,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(leading context.Context) {
+					// The elector starts this callback in its own goroutine; skip it if leadership
+					// already ended so fn never outlives Run.
+					mu.Lock()
+					if stopped {
+						mu.Unlock()
+						return
+					}
+					running.Add(1)
+					mu.Unlock()
+					defer running.Done()
+					e.logger.Info("acquired singleton job", "job", name, "lease", lease, "identity", e.identity)
+					fn(leading)
+				},
+				OnStoppedLeading: func() {
+					e.logger.Debug("not leading singleton job", "job", name, "lease", lease)
+				},
+			},
+		})
+		if err != nil {
+			e.logger.Error("cannot elect a leader for singleton job", "job", name, "error", err)
+			return
+		}
+		elector.Run(ctx)
+
+		mu.Lock()
+		stopped = true
+		mu.Unlock()
+		running.Wait()
+	}
+}
+
+// leaseName turns a job name such as "retention enforcer" into a valid object name.
+func leaseName(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
@@ -0,0 +1,78 @@
+package leader
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestElector(client *fake.Clientset, identity string) *LeaseElector {
+	elector := NewLeaseElector(client, "kubechat", identity, log.NewWithOptions(io.Discard, log.Options{}))
+	elector.leaseDuration = time.Second
+	elector.renewDeadline = 500 * time.Millisecond
+	elector.retryPeriod = 50 * time.Millisecond
+	return elector
+}
+
+func TestOnlyOneReplicaRunsAJobAndHandsOverOnShutdown(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	first, second := newTestElector(client, "replica-a"), newTestElector(client, "replica-b")
+	started := make(chan string, 2)
+	job := func(identity string) func(ctx context.Context) {
+		return func(ctx context.Context) {
+			started <- identity
+			<-ctx.Done()
+		}
+	}
+
+	firstCtx, stopFirst := context.WithCancel(context.Background())
+	firstDone := make(chan struct{})
+	go func() {
+		first.Run(firstCtx, "Retention enforcer", job("replica-a"))
+		close(firstDone)
+	}()
+	if got := <-started; got != "replica-a" {
+		t.Fatalf("expected replica-a to lead, got %s", got)
+	}
+
+	secondCtx, stopSecond := context.WithCancel(context.Background())
+	defer stopSecond()
+	go second.Run(secondCtx, "Retention enforcer", job("replica-b"))
+	select {
+	case got := <-started:
+		t.Fatalf("%s started the job while replica-a held the lease", got)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	lease, err := client.CoordinationV1().Leases("kubechat").Get(context.Background(), "kubechat-retention-enforcer", metav1.GetOptions{})
+	if err != nil || *lease.Spec.HolderIdentity != "replica-a" {
+		t.Fatalf("expected replica-a to hold the lease, got %+v (%v)", lease, err)
+	}
+
+	stopFirst()
+	<-firstDone
+	select {
+	case got := <-started:
+		if got != "replica-b" {
+			t.Fatalf("expected replica-b to take over, got %s", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("replica-b never took over the released lease")
+	}
+}
+
+func TestLeaseName(t *testing.T) {
+	for name, want := range map[string]string{
+		"approval processor":   "approval-processor",
+		"  Handoff/Scheduler ": "handoff-scheduler",
+	} {
+		if got := leaseName(name); got != want {
+			t.Fatalf("leaseName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
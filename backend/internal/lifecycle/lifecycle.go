@@ -0,0 +1,167 @@
+// Package lifecycle coordinates background work and an orderly shutdown of the server.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+type hook struct {
+	name    string
+	timeout time.Duration
+	fn      func(ctx context.Context) error
+}
+
+// Manager owns the root context of background loops and the hooks run at shutdown.
+//
+// Shutdown happens in three stages:
+//  1. shutdown hooks run in registration order, each bounded by its own timeout, so
+//     streams can be closed before in-flight requests are drained;
+//  2. the root context is cancelled and background loops started with Go are awaited;
+//  3. close hooks run in reverse registration order to release shared resources such
+//     as the database once nothing can use them.
+type Manager struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	loops  sync.WaitGroup
+	logger *log.Logger
+
+	mu       sync.Mutex
+	elector  Elector
+	shutdown []hook
+	closers  []hook
+	stopping bool
+	once     sync.Once
+	err      error
+}
+
+func NewManager(logger *log.Logger) *Manager {
+	if logger == nil {
+		logger = log.Default()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{ctx: ctx, cancel: cancel, logger: logger}
+}
+
+// Context is cancelled when shutdown reaches the background stage.
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// Go runs fn in a goroutine that shutdown waits for. fn must return once its context is cancelled.
+func (m *Manager) Go(name string, fn func(ctx context.Context)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopping {
+		m.logger.Warn("not starting background task during shutdown", "task", name)
+		return
+	}
+	m.loops.Add(1)
+	go func() {
+		defer m.loops.Done()
+		fn(m.ctx)
+	}()
+}
+
+// Elector decides which of several replicas runs a singleton task.
+type Elector interface {
+	// Run calls fn while this replica holds leadership of name, with a context that is cancelled
+	// when leadership is lost, and campaigns again until ctx is cancelled.
+	Run(ctx context.Context, name string, fn func(ctx context.Context))
+}
+
+// SetElector coordinates tasks started with GoSingleton across replicas. Without an elector every
+// replica runs them.
+func (m *Manager) SetElector(elector Elector) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.elector = elector
+}
+
+// GoSingleton is Go for work that must not run on more than one replica at a time, such as
+// enforcing retention or expiring approvals.
+func (m *Manager) GoSingleton(name string, fn func(ctx context.Context)) {
+	m.mu.Lock()
+	elector := m.elector
+	m.mu.Unlock()
+	if elector == nil {
+		m.Go(name, fn)
+		return
+	}
+	m.Go(name, func(ctx context.Context) { elector.Run(ctx, name, fn) })
+}
+
+// OnShutdown registers a hook that drains work, such as closing streams or finishing requests.
+// A zero timeout leaves the hook bounded only by the overall shutdown deadline.
+func (m *Manager) OnShutdown(name string, timeout time.Duration, fn func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.shutdown = append(m.shutdown, hook{name: name, timeout: timeout, fn: fn})
+}
+
+// OnClose registers a hook that releases a resource after every background loop has stopped.
+func (m *Manager) OnClose(name string, fn func() error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closers = append(m.closers, hook{name: name, fn: func(context.Context) error { return fn() }})
+}
+
+// Shutdown runs every stage once; later calls return the first result. Hook failures are
+// logged and joined into the returned error without stopping the remaining stages.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.once.Do(func() {
+		m.mu.Lock()
+		m.stopping = true
+		shutdown := append([]hook(nil), m.shutdown...)
+		closers := append([]hook(nil), m.closers...)
+		m.mu.Unlock()
+
+		var errs []error
+		for _, h := range shutdown {
+			if err := m.run(ctx, h); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		m.cancel()
+		done := make(chan struct{})
+		go func() {
+			m.loops.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			errs = append(errs, fmt.Errorf("background tasks: %w", ctx.Err()))
+		}
+
+		for i := len(closers) - 1; i >= 0; i-- {
+			if err := m.run(ctx, closers[i]); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		m.err = errors.Join(errs...)
+	})
+	return m.err
+}
+
+func (m *Manager) run(ctx context.Context, h hook) error {
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+	started := time.Now()
+	err := h.fn(ctx)
+	if err != nil {
+		m.logger.Error("shutdown step failed", "step", h.name, "error", err)
+		return fmt.Errorf("%s: %w", h.name, err)
+	}
+	m.logger.Info("shutdown step finished", "step", h.name, "took", time.Since(started).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,100 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+func TestShutdownRunsStagesInOrder(t *testing.T) {
+	m := NewManager(log.NewWithOptions(io.Discard, log.Options{}))
+	var order []string
+
+	m.Go("loop", func(ctx context.Context) {
+		<-ctx.Done()
+		order = append(order, "loop stopped")
+	})
+	m.OnClose("database", func() error {
+		order = append(order, "database")
+		return nil
+	})
+	m.OnClose("events", func() error {
+		order = append(order, "events")
+		return nil
+	})
+	m.OnShutdown("streams", 0, func(ctx context.Context) error {
+		order = append(order, "streams")
+		return nil
+	})
+	m.OnShutdown("http", time.Second, func(ctx context.Context) error {
+		order = append(order, "http")
+		return errors.New("drain interrupted")
+	})
+
+	err := m.Shutdown(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "http: drain interrupted") {
+		t.Fatalf("expected the failed step in the error, got %v", err)
+	}
+	want := "streams,http,loop stopped,events,database"
+	if got := strings.Join(order, ","); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+	if again := m.Shutdown(context.Background()); again != err {
+		t.Fatalf("expected repeated shutdown to return the first result, got %v", again)
+	}
+}
+
+func TestShutdownHookTimeout(t *testing.T) {
+	m := NewManager(log.NewWithOptions(io.Discard, log.Options{}))
+	m.OnShutdown("slow", 10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	release := make(chan struct{})
+	defer close(release)
+	m.Go("stuck", func(ctx context.Context) { <-release })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := m.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "background tasks") {
+		t.Fatalf("expected hook and background timeouts, got %v", err)
+	}
+}
+
+type recordingElector struct {
+	names chan string
+}
+
+func (e recordingElector) Run(ctx context.Context, name string, fn func(ctx context.Context)) {
+	e.names <- name
+	fn(ctx)
+}
+
+func TestGoSingletonRunsThroughElector(t *testing.T) {
+	m := NewManager(log.NewWithOptions(io.Discard, log.Options{}))
+	elector := recordingElector{names: make(chan string, 1)}
+	m.SetElector(elector)
+
+	stopped := make(chan struct{})
+	m.GoSingleton("retention enforcer", func(ctx context.Context) {
+		<-ctx.Done()
+		close(stopped)
+	})
+	if name := <-elector.names; name != "retention enforcer" {
+		t.Fatalf("expected the job to campaign under its name, got %q", name)
+	}
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Fatalf("expected shutdown to wait for the singleton job")
+	}
+}
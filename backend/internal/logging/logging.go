@@ -0,0 +1,186 @@
+// Package logging configures the structured logs every KubeChat module writes. Modules log
+// through their own logger, whose level can be changed while the server runs, and attach the
+// fields of the request they serve, such as its request ID and user, from the context.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatText Format = "text"
+)
+
+// DefaultModule names the level of loggers that belong to no module.
+const DefaultModule = "default"
+
+// Keys of the fields attached to entries about a request.
+const (
+	RequestIDKey = "request_id"
+	UserKey      = "user"
+	TraceIDKey   = "trace_id"
+)
+
+type ErrUnknownModule struct {
+	Module string
+}
+
+func (e ErrUnknownModule) Error() string {
+	return fmt.Sprintf("no logger for module %q", e.Module)
+}
+
+type ErrInvalidLevel struct {
+	Level string
+}
+
+func (e ErrInvalidLevel) Error() string {
+	return fmt.Sprintf("unknown log level %q, expected debug, info, warn, error or fatal", e.Level)
+}
+
+// ParseFormat reads a --log-format value.
+func ParseFormat(value string) (Format, error) {
+	switch format := Format(strings.ToLower(value)); format {
+	case FormatJSON, FormatText:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown log format %q, expected json or text", value)
+	}
+}
+
+// ParseLevel reads a level name such as "debug" or "warn".
+func ParseLevel(value string) (log.Level, error) {
+	level, err := log.ParseLevel(strings.ToLower(strings.TrimSpace(value)))
+	if err != nil {
+		return 0, ErrInvalidLevel{Level: value}
+	}
+	return level, nil
+}
+
+var registry = struct {
+	sync.Mutex
+	modules map[string]*log.Logger
+	// levels holds the levels chosen at startup for modules whose logger is not yet created.
+	levels map[string]log.Level
+}{modules: make(map[string]*log.Logger), levels: make(map[string]log.Level)}
+
+// Setup makes the default logger write entries in format at level, and routes the standard
+// library's slog through it. Modules named in moduleLevels log at their own level instead. It is
+// called once at startup, before any module logger is created.
+func Setup(w io.Writer, format Format, level log.Level, moduleLevels map[string]string) error {
+	levels := make(map[string]log.Level, len(moduleLevels))
+	for module, value := range moduleLevels {
+		moduleLevel, err := ParseLevel(value)
+		if err != nil {
+			return err
+		}
+		levels[module] = moduleLevel
+	}
+	if w == nil {
+		w = os.Stderr
+	}
+	logger := log.NewWithOptions(w, log.Options{ReportTimestamp: true, Level: level})
+	if format == FormatJSON {
+		logger.SetFormatter(log.JSONFormatter)
+	}
+	log.SetDefault(logger)
+	slog.SetDefault(slog.New(logger))
+
+	registry.Lock()
+	defer registry.Unlock()
+	registry.modules = make(map[string]*log.Logger)
+	registry.levels = levels
+	return nil
+}
+
+// Module returns the logger of the named module, creating it at the default level on first use.
+// Its entries carry a module field.
+func Module(name string) *log.Logger {
+	registry.Lock()
+	defer registry.Unlock()
+	if logger, ok := registry.modules[name]; ok {
+		return logger
+	}
+	logger := log.Default().With("module", name)
+	if level, ok := registry.levels[name]; ok {
+		logger.SetLevel(level)
+	}
+	registry.modules[name] = logger
+	return logger
+}
+
+// Levels lists the level of every module logger, and of the default logger under DefaultModule.
+func Levels() map[string]string {
+	registry.Lock()
+	defer registry.Unlock()
+	levels := map[string]string{DefaultModule: log.Default().GetLevel().String()}
+	for name, logger := range registry.modules {
+		levels[name] = logger.GetLevel().String()
+	}
+	return levels
+}
+
+// SetLevel changes the level of a module logger, or of the default logger for DefaultModule.
+// The change applies immediately and lasts until the server restarts.
+func SetLevel(module, value string) error {
+	level, err := ParseLevel(value)
+	if err != nil {
+		return err
+	}
+	if module == DefaultModule {
+		log.Default().SetLevel(level)
+		return nil
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	logger, ok := registry.modules[module]
+	if !ok {
+		return ErrUnknownModule{Module: module}
+	}
+	logger.SetLevel(level)
+	return nil
+}
+
+type contextKey struct{}
+
+// NewContext adds keyvals to the fields logged with every entry about the work done in ctx.
+func NewContext(ctx context.Context, keyvals ...any) context.Context {
+	fields, _ := ctx.Value(contextKey{}).([]any)
+	return context.WithValue(ctx, contextKey{}, append(slices.Clip(fields), keyvals...))
+}
+
+// FromContext returns logger with the fields added to ctx and the ID of the trace ctx is part of.
+func FromContext(ctx context.Context, logger *log.Logger) *log.Logger {
+	fields, _ := ctx.Value(contextKey{}).([]any)
+	if span := trace.SpanContextFromContext(ctx); span.HasTraceID() {
+		fields = append(slices.Clip(fields), TraceIDKey, span.TraceID().String())
+	}
+	if len(fields) == 0 {
+		return logger
+	}
+	return logger.With(fields...)
+}
+
+// RequestID returns the ID of the HTTP request ctx belongs to, or "" outside of one.
+func RequestID(ctx context.Context) string {
+	fields, _ := ctx.Value(contextKey{}).([]any)
+	for i := 0; i+1 < len(fields); i += 2 {
+		if fields[i] == RequestIDKey {
+			id, _ := fields[i+1].(string)
+			return id
+		}
+	}
+	return ""
+}
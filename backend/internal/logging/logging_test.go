@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/log"
+)
+
+func entries(t *testing.T, out *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var parsed []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("expected JSON entries, got %q: %v", line, err)
+		}
+		parsed = append(parsed, entry)
+	}
+	return parsed
+}
+
+func TestModuleLevelsCanBeChangedWhileRunning(t *testing.T) {
+	previous, previousSlog := log.Default(), slog.Default()
+	t.Cleanup(func() {
+		log.SetDefault(previous)
+		slog.SetDefault(previousSlog)
+	})
+	var out bytes.Buffer
+	if err := Setup(&out, FormatJSON, log.InfoLevel, map[string]string{"commands": "warn"}); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+
+	commands, chat := Module("commands"), Module("chat")
+	commands.Info("hidden")
+	chat.Debug("hidden")
+	chat.Info("shown")
+	if err := SetLevel("commands", "debug"); err != nil {
+		t.Fatalf("set level: %v", err)
+	}
+	commands.Debug("now shown")
+
+	logged := entries(t, &out)
+	if len(logged) != 2 || logged[0]["msg"] != "shown" || logged[0]["module"] != "chat" || logged[1]["msg"] != "now shown" {
+		t.Fatalf("expected only entries at or above each module's level, got %v", logged)
+	}
+	if levels := Levels(); levels["commands"] != "debug" || levels["chat"] != "info" || levels[DefaultModule] != "info" {
+		t.Fatalf("unexpected levels %v", levels)
+	}
+	if err := SetLevel("billing", "debug"); !errors.As(err, &ErrUnknownModule{}) {
+		t.Fatalf("expected an unknown module to be refused, got %v", err)
+	}
+	if err := SetLevel("chat", "loud"); !errors.As(err, &ErrInvalidLevel{}) {
+		t.Fatalf("expected an unknown level to be refused, got %v", err)
+	}
+}
+
+func TestEntriesCarryTheFieldsOfTheirContext(t *testing.T) {
+	var out bytes.Buffer
+	logger := log.NewWithOptions(&out, log.Options{Formatter: log.JSONFormatter})
+	ctx := NewContext(context.Background(), RequestIDKey, "req-1", UserKey, "alice")
+	ctx = NewContext(ctx, "correlation_id", "turn-7")
+
+	FromContext(ctx, logger).Info("command submitted")
+	FromContext(context.Background(), logger).Info("background")
+
+	logged := entries(t, &out)
+	if logged[0]["request_id"] != "req-1" || logged[0]["user"] != "alice" || logged[0]["correlation_id"] != "turn-7" {
+		t.Fatalf("expected the request fields on the entry, got %v", logged[0])
+	}
+	if _, ok := logged[1]["request_id"]; ok {
+		t.Fatalf("expected no request fields outside a request, got %v", logged[1])
+	}
+	if RequestID(ctx) != "req-1" || RequestID(context.Background()) != "" {
+		t.Fatalf("unexpected request IDs %q and %q", RequestID(ctx), RequestID(context.Background()))
+	}
+}
@@ -0,0 +1,175 @@
+package logs
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DefaultTail is how many existing lines per container a follow starts with.
+	DefaultTail = 100
+	// MaxTail bounds the existing lines a follow may request per container.
+	MaxTail = 5000
+	// DefaultBuffer is how many lines are queued for a slow client before lines are dropped.
+	DefaultBuffer = 1000
+	// MaxFollowers bounds concurrent follow streams across all clients.
+	MaxFollowers = 64
+)
+
+// ErrTooManyFollowers is returned when MaxFollowers streams are already open.
+var ErrTooManyFollowers = errors.New("too many log streams are open, try again later")
+
+// FollowOptions choose where a follow starts and how much it may queue.
+type FollowOptions struct {
+	// SinceTime starts each container's stream at this time instead of its last TailLines lines.
+	SinceTime *time.Time
+	TailLines int64
+	// Buffer bounds the lines queued for the client. When it is full, new lines are dropped and
+	// counted instead of slowing down the reads from the cluster.
+	Buffer int
+}
+
+// Follow streams the log lines of every selected container as they are written. Lines are
+// filtered by the query; its limit and time range do not apply.
+type Follow struct {
+	// Sources lists the pod/container pairs being followed.
+	Sources []string
+
+	entries chan Entry
+	dropped atomic.Int64
+	cancel  context.CancelFunc
+	release func()
+	once    sync.Once
+
+	mu   sync.Mutex
+	errs []error
+}
+
+// Entries yields matching lines until every container stream ended or the follow was closed.
+func (f *Follow) Entries() <-chan Entry {
+	return f.entries
+}
+
+// Dropped returns and resets the number of lines dropped because the client fell behind.
+func (f *Follow) Dropped() int64 {
+	return f.dropped.Swap(0)
+}
+
+// Err reports the container streams that failed; it is complete once Entries is closed.
+func (f *Follow) Err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return errors.Join(f.errs...)
+}
+
+// Close stops every container stream.
+func (f *Follow) Close() {
+	f.once.Do(func() {
+		f.cancel()
+		f.release()
+	})
+}
+
+func (f *Follow) fail(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errs = append(f.errs, err)
+}
+
+// Follow opens a streaming read of the pods and containers the query selects.
+func (s *Searcher) Follow(ctx context.Context, config, cluster string, query Query, opts FollowOptions) (*Follow, error) {
+	if query.Namespace == "" {
+		return nil, fmt.Errorf("namespace is required")
+	}
+	if len(query.Pods) == 0 && query.Selector == "" {
+		return nil, fmt.Errorf("a pod name or label selector is required")
+	}
+	if opts.TailLines <= 0 {
+		opts.TailLines = DefaultTail
+	}
+	opts.TailLines = min(opts.TailLines, MaxTail)
+	if opts.Buffer <= 0 {
+		opts.Buffer = DefaultBuffer
+	}
+
+	select {
+	case s.followers <- struct{}{}:
+	default:
+		return nil, ErrTooManyFollowers
+	}
+	release := func() { <-s.followers }
+
+	client := s.clients(config, cluster)
+	pods, err := s.resolvePods(ctx, client, query)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	follow := &Follow{
+		entries: make(chan Entry, opts.Buffer),
+		cancel:  cancel,
+		release: release,
+	}
+	logOptions := corev1.PodLogOptions{Follow: true, Timestamps: true}
+	if opts.SinceTime != nil {
+		since := metav1.NewTime(*opts.SinceTime)
+		logOptions.SinceTime = &since
+	} else {
+		logOptions.TailLines = &opts.TailLines
+	}
+
+	var wg sync.WaitGroup
+	for _, pod := range pods {
+		for _, container := range containerNames(pod, query.Containers) {
+			follow.Sources = append(follow.Sources, pod.Name+"/"+container)
+			options := logOptions
+			options.Container = container
+			wg.Add(1)
+			go func(podName, container string) {
+				defer wg.Done()
+				stream, err := client.CoreV1().Pods(query.Namespace).GetLogs(podName, &options).Stream(streamCtx)
+				if err != nil {
+					follow.fail(fmt.Errorf("%s/%s: %w", podName, container, err))
+					return
+				}
+				defer stream.Close()
+				scanner := bufio.NewScanner(stream)
+				scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+				for scanner.Scan() {
+					entry := ParseLine(scanner.Text())
+					entry.Pod, entry.Container = podName, container
+					if !query.Matches(entry) {
+						continue
+					}
+					select {
+					case follow.entries <- entry:
+					default:
+						follow.dropped.Add(1)
+					}
+				}
+				if err := scanner.Err(); err != nil && streamCtx.Err() == nil {
+					follow.fail(fmt.Errorf("%s/%s: %w", podName, container, err))
+				}
+			}(pod.Name, container)
+		}
+	}
+	if len(follow.Sources) == 0 {
+		follow.Close()
+		return nil, ErrNoPods
+	}
+	go func() {
+		wg.Wait()
+		close(follow.entries)
+	}()
+	return follow, nil
+}
@@ -0,0 +1,75 @@
+package logs
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type Level string
+
+const (
+	LevelUnknown Level = ""
+	LevelDebug   Level = "debug"
+	LevelInfo    Level = "info"
+	LevelWarn    Level = "warn"
+	LevelError   Level = "error"
+	LevelFatal   Level = "fatal"
+)
+
+func ParseLevel(name string) (Level, error) {
+	if level := normalizeLevel(name); level != LevelUnknown {
+		return level, nil
+	}
+	return LevelUnknown, fmt.Errorf("unknown log level %q (want debug, info, warn, error or fatal)", name)
+}
+
+func normalizeLevel(name string) Level {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "trace", "debug", "dbg", "d":
+		return LevelDebug
+	case "info", "inf", "notice", "i":
+		return LevelInfo
+	case "warn", "warning", "wrn", "w":
+		return LevelWarn
+	case "error", "err", "eror", "e":
+		return LevelError
+	case "fatal", "panic", "critical", "crit", "emerg", "alert", "f":
+		return LevelFatal
+	}
+	return LevelUnknown
+}
+
+var (
+	// structuredLevel matches JSON ("level":"error", "severity":"ERROR") and logfmt (level=error) fields.
+	structuredLevel = regexp.MustCompile(`(?i)"?\b(?:level|lvl|severity|log\.level)"?\s*[:=]\s*"?([a-z]+)`)
+	// klogLevel matches the klog/glog header, e.g. "E0102 15:04:05.000000".
+	klogLevel = regexp.MustCompile(`^([IWEF])\d{4} \d{2}:\d{2}:\d{2}`)
+	// bracketLevel matches prefixes such as "[error]" or "<warn>" in any case.
+	bracketLevel = regexp.MustCompile(`(?i)[\[<](trace|debug|info|notice|warn|warning|error|err|fatal|panic|critical)[\]>]`)
+	// keywordLevel matches upper-case words such as "ERROR" or "WARN:"; lower-case "error" is too common in messages.
+	keywordLevel = regexp.MustCompile(`(?:^|[\s(|])(TRACE|DEBUG|INFO|NOTICE|WARN|WARNING|ERROR|ERR|FATAL|PANIC|CRITICAL)(?:$|[\s):|])`)
+)
+
+// DetectLevel infers the severity of a log line from common structured and plain-text formats.
+func DetectLevel(line string) Level {
+	if match := structuredLevel.FindStringSubmatch(line); match != nil {
+		if level := normalizeLevel(match[1]); level != LevelUnknown {
+			return level
+		}
+	}
+	if match := klogLevel.FindStringSubmatch(line); match != nil {
+		return normalizeLevel(match[1])
+	}
+	// Only the start of the line is considered so messages that merely mention "error" later on are not misclassified.
+	head := line
+	if len(head) > 64 {
+		head = head[:64]
+	}
+	for _, pattern := range []*regexp.Regexp{bracketLevel, keywordLevel} {
+		if match := pattern.FindStringSubmatch(head); match != nil {
+			return normalizeLevel(match[1])
+		}
+	}
+	return LevelUnknown
+}
@@ -0,0 +1,201 @@
+// Package logs answers bounded, filtered queries over pod logs and follows them as they are written.
+package logs
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	DefaultLimit = 200
+	MaxLimit     = 5000
+	// DefaultSince bounds queries that do not name a time range.
+	DefaultSince = time.Hour
+)
+
+// Query selects pods and filters their log lines. All filters must match.
+type Query struct {
+	Namespace  string
+	Pods       []string
+	Selector   string
+	Containers []string
+	// Contains are case-insensitive substrings; Excludes drop lines containing any of them.
+	Contains []string
+	Excludes []string
+	Pattern  *regexp.Regexp
+	Levels   []Level
+	Since    time.Duration
+	Until    *time.Time
+	Limit    int
+}
+
+// ParseQuery parses the log query DSL. Terms are separated by spaces:
+//
+//	level:error          keep lines at a level (repeatable, or level:error,warn)
+//	since:10m            look back this far (default 1h)
+//	until:2025-01-02T15:04:05Z
+//	pod:name  container:name  selector:app=payments
+//	limit:100            number of lines returned, newest kept
+//	/timeout|refused/    regular expression
+//	-healthz             exclude lines containing the word
+//	"connection reset"   quoted substring; bare words are substrings too
+func ParseQuery(input string) (Query, error) {
+	query := Query{Since: DefaultSince, Limit: DefaultLimit}
+	terms, err := tokenize(input)
+	if err != nil {
+		return Query{}, err
+	}
+	for _, term := range terms {
+		if err := query.apply(term); err != nil {
+			return Query{}, err
+		}
+	}
+	return query, nil
+}
+
+type term struct {
+	text   string
+	quoted bool
+	regex  bool
+}
+
+func (q *Query) apply(t term) error {
+	switch {
+	case t.regex:
+		pattern, err := regexp.Compile("(?i)" + t.text)
+		if err != nil {
+			return fmt.Errorf("invalid regular expression /%s/: %w", t.text, err)
+		}
+		q.Pattern = pattern
+		return nil
+	case t.quoted:
+		q.Contains = append(q.Contains, strings.ToLower(t.text))
+		return nil
+	case strings.HasPrefix(t.text, "-") && len(t.text) > 1:
+		q.Excludes = append(q.Excludes, strings.ToLower(t.text[1:]))
+		return nil
+	}
+
+	key, value, ok := strings.Cut(t.text, ":")
+	if !ok || value == "" {
+		q.Contains = append(q.Contains, strings.ToLower(t.text))
+		return nil
+	}
+	switch strings.ToLower(key) {
+	case "level":
+		for _, name := range strings.Split(value, ",") {
+			level, err := ParseLevel(name)
+			if err != nil {
+				return err
+			}
+			q.Levels = append(q.Levels, level)
+		}
+	case "since":
+		since, err := time.ParseDuration(value)
+		if err != nil || since <= 0 {
+			return fmt.Errorf("since must be a positive duration such as 10m")
+		}
+		q.Since = since
+	case "until":
+		until, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return fmt.Errorf("until must be an RFC 3339 timestamp")
+		}
+		q.Until = &until
+	case "pod":
+		q.Pods = append(q.Pods, value)
+	case "container":
+		q.Containers = append(q.Containers, value)
+	case "selector":
+		q.Selector = value
+	case "namespace", "ns":
+		q.Namespace = value
+	case "limit":
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return fmt.Errorf("limit must be a positive number")
+		}
+		q.Limit = min(limit, MaxLimit)
+	default:
+		// Unknown keys such as "http://" are ordinary search text.
+		q.Contains = append(q.Contains, strings.ToLower(t.text))
+	}
+	return nil
+}
+
+func tokenize(input string) ([]term, error) {
+	var (
+		terms []term
+		runes = []rune(input)
+	)
+	for i := 0; i < len(runes); {
+		switch {
+		case unicode.IsSpace(runes[i]):
+			i++
+		case runes[i] == '"' || runes[i] == '/':
+			closing := runes[i]
+			end := i + 1
+			for end < len(runes) && runes[end] != closing {
+				if runes[end] == '\\' && closing == '/' && end+1 < len(runes) {
+					end++
+				}
+				end++
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("unterminated %c in log query", closing)
+			}
+			text := string(runes[i+1 : end])
+			if closing == '/' {
+				text = strings.ReplaceAll(text, `\/`, "/")
+			}
+			terms = append(terms, term{text: text, quoted: closing == '"', regex: closing == '/'})
+			i = end + 1
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) {
+				end++
+			}
+			terms = append(terms, term{text: string(runes[i:end])})
+			i = end
+		}
+	}
+	return terms, nil
+}
+
+// Matches reports whether a parsed line passes every text and level filter.
+func (q Query) Matches(entry Entry) bool {
+	if len(q.Levels) > 0 && !containsLevel(q.Levels, entry.Level) {
+		return false
+	}
+	lower := strings.ToLower(entry.Message)
+	for _, needle := range q.Contains {
+		if !strings.Contains(lower, needle) {
+			return false
+		}
+	}
+	for _, needle := range q.Excludes {
+		if strings.Contains(lower, needle) {
+			return false
+		}
+	}
+	if q.Pattern != nil && !q.Pattern.MatchString(entry.Message) {
+		return false
+	}
+	if q.Until != nil && entry.Timestamp.After(*q.Until) {
+		return false
+	}
+	return true
+}
+
+func containsLevel(levels []Level, level Level) bool {
+	for _, candidate := range levels {
+		if candidate == level {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package logs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseQuery(t *testing.T) {
+	query, err := ParseQuery(`level:error,warn since:10m selector:app=payments "connection reset" -healthz /time(d)?out/ limit:50`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(query.Levels) != 2 || query.Levels[0] != LevelError || query.Levels[1] != LevelWarn {
+		t.Fatalf("unexpected levels %v", query.Levels)
+	}
+	if query.Since != 10*time.Minute || query.Selector != "app=payments" || query.Limit != 50 {
+		t.Fatalf("unexpected query %+v", query)
+	}
+	if len(query.Contains) != 1 || query.Contains[0] != "connection reset" {
+		t.Fatalf("unexpected contains %v", query.Contains)
+	}
+	if len(query.Excludes) != 1 || query.Excludes[0] != "healthz" {
+		t.Fatalf("unexpected excludes %v", query.Excludes)
+	}
+	if query.Pattern == nil || !query.Pattern.MatchString("request TIMEOUT") {
+		t.Fatalf("expected case-insensitive pattern, got %v", query.Pattern)
+	}
+
+	for _, input := range []string{"level:loud", "since:soon", `"open`, "/[/", "limit:0"} {
+		if _, err := ParseQuery(input); err == nil {
+			t.Fatalf("expected %q to be rejected", input)
+		}
+	}
+}
+
+func TestDetectLevel(t *testing.T) {
+	cases := map[string]Level{
+		`{"level":"error","msg":"payment failed"}`:             LevelError,
+		`time=2025-01-01 level=warn msg="slow query"`:          LevelWarn,
+		`E0102 15:04:05.000000       1 controller.go:42] boom`: LevelError,
+		`[INFO] server started`:                                LevelInfo,
+		`2025-01-01 12:00:00 FATAL out of memory`:              LevelFatal,
+		`GET /healthz 200 no error here`:                       LevelUnknown,
+	}
+	for line, want := range cases {
+		if got := DetectLevel(line); got != want {
+			t.Fatalf("DetectLevel(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
+
+func TestQueryMatches(t *testing.T) {
+	query, err := ParseQuery(`level:error payment -retry`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := []string{
+		`2025-01-01T10:00:00Z {"level":"error","msg":"Payment declined"}`,
+		`2025-01-01T10:00:01Z {"level":"error","msg":"payment retry scheduled"}`,
+		`2025-01-01T10:00:02Z {"level":"info","msg":"payment accepted"}`,
+	}
+	var matched []Entry
+	for _, line := range lines {
+		if entry := ParseLine(line); query.Matches(entry) {
+			matched = append(matched, entry)
+		}
+	}
+	if len(matched) != 1 || matched[0].Timestamp.Second() != 0 {
+		t.Fatalf("expected only the first line to match, got %+v", matched)
+	}
+}
+
+func TestSearchMergesContainers(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "payments-1", Namespace: "shop", Labels: map[string]string{"app": "payments"}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}, {Name: "proxy"}}},
+	})
+	searcher := NewSearcher(func(config, cluster string) kubernetes.Interface { return client })
+
+	query, _ := ParseQuery("selector:app=payments fake")
+	query.Namespace = "shop"
+	result, err := searcher.Search(context.Background(), "", "", query)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The fake clientset returns "fake logs" for every container.
+	if len(result.Sources) != 2 || result.Matched != 2 || len(result.Entries) != 2 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	query.Selector = "app=missing"
+	if _, err := searcher.Search(context.Background(), "", "", query); err != ErrNoPods {
+		t.Fatalf("expected ErrNoPods, got %v", err)
+	}
+}
+
+func TestFollowStreamsContainers(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "payments-1", Namespace: "shop", Labels: map[string]string{"app": "payments"}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}, {Name: "proxy"}}},
+	})
+	searcher := NewSearcher(func(config, cluster string) kubernetes.Interface { return client })
+
+	query, _ := ParseQuery("selector:app=payments container:proxy fake")
+	query.Namespace = "shop"
+	follow, err := searcher.Follow(context.Background(), "", "", query, FollowOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer follow.Close()
+	var entries []Entry
+	for entry := range follow.Entries() {
+		entries = append(entries, entry)
+	}
+	if len(follow.Sources) != 1 || len(entries) != 1 || entries[0].Container != "proxy" || follow.Err() != nil {
+		t.Fatalf("unexpected follow of %v: %+v, %v", follow.Sources, entries, follow.Err())
+	}
+
+	for i := 0; i < MaxFollowers-1; i++ {
+		searcher.followers <- struct{}{}
+	}
+	if _, err := searcher.Follow(context.Background(), "", "", query, FollowOptions{}); err != ErrTooManyFollowers {
+		t.Fatalf("expected ErrTooManyFollowers, got %v", err)
+	}
+	follow.Close()
+	if _, err := searcher.Follow(context.Background(), "", "", query, FollowOptions{}); err != nil {
+		t.Fatalf("expected a closed follow to free its slot, got %v", err)
+	}
+}
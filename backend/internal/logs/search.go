@@ -0,0 +1,208 @@
+package logs
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// maxPods bounds how many pods a selector query may fan out to.
+	maxPods = 20
+	// maxBytesPerContainer caps how much log data is read from a single container.
+	maxBytesPerContainer = 8 << 20
+)
+
+// ClientFunc resolves a clientset for the given kubeconfig and cluster context.
+type ClientFunc func(config, cluster string) kubernetes.Interface
+
+// ErrNoPods is returned when the query's pods or selector match nothing.
+var ErrNoPods = errors.New("no pods matched the log query")
+
+type Entry struct {
+	Pod       string    `json:"pod"`
+	Container string    `json:"container"`
+	Timestamp time.Time `json:"timestamp"`
+	Level     Level     `json:"level,omitempty"`
+	Message   string    `json:"message"`
+}
+
+type Result struct {
+	Entries []Entry `json:"entries"`
+	// Scanned is the number of lines read inside the time range; Matched is how many passed the filters.
+	Scanned   int  `json:"scanned"`
+	Matched   int  `json:"matched"`
+	Truncated bool `json:"truncated"`
+	// Sources lists the pod/container pairs that were read.
+	Sources []string `json:"sources"`
+}
+
+// Searcher runs log queries across every container of the selected pods.
+type Searcher struct {
+	clients   ClientFunc
+	clock     func() time.Time
+	followers chan struct{}
+}
+
+func NewSearcher(clients ClientFunc) *Searcher {
+	return &Searcher{clients: clients, clock: time.Now, followers: make(chan struct{}, MaxFollowers)}
+}
+
+func (s *Searcher) Search(ctx context.Context, config, cluster string, query Query) (Result, error) {
+	if query.Namespace == "" {
+		return Result{}, fmt.Errorf("namespace is required")
+	}
+	if len(query.Pods) == 0 && query.Selector == "" {
+		return Result{}, fmt.Errorf("a pod name or label selector is required")
+	}
+	if query.Limit <= 0 {
+		query.Limit = DefaultLimit
+	}
+	client := s.clients(config, cluster)
+	pods, err := s.resolvePods(ctx, client, query)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		result  Result
+		entries []Entry
+		errs    []error
+	)
+	sinceTime := metav1.NewTime(s.clock().Add(-query.Since))
+	for _, pod := range pods {
+		for _, container := range containerNames(pod, query.Containers) {
+			result.Sources = append(result.Sources, pod.Name+"/"+container)
+			wg.Add(1)
+			go func(podName, container string) {
+				defer wg.Done()
+				matched, scanned, err := s.read(ctx, client, query, podName, container, sinceTime)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s/%s: %w", podName, container, err))
+					return
+				}
+				result.Scanned += scanned
+				entries = append(entries, matched...)
+			}(pod.Name, container)
+		}
+	}
+	wg.Wait()
+	if len(result.Sources) > 0 && len(errs) == len(result.Sources) {
+		return Result{}, errors.Join(errs...)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Timestamp.Before(entries[j].Timestamp) })
+	result.Matched = len(entries)
+	if len(entries) > query.Limit {
+		entries = entries[len(entries)-query.Limit:]
+		result.Truncated = true
+	}
+	result.Entries = entries
+	sort.Strings(result.Sources)
+	return result, nil
+}
+
+func (s *Searcher) resolvePods(ctx context.Context, client kubernetes.Interface, query Query) ([]corev1.Pod, error) {
+	var pods []corev1.Pod
+	for _, name := range query.Pods {
+		pod, err := client.CoreV1().Pods(query.Namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		pods = append(pods, *pod)
+	}
+	if query.Selector != "" {
+		list, err := client.CoreV1().Pods(query.Namespace).List(ctx, metav1.ListOptions{LabelSelector: query.Selector})
+		if err != nil {
+			return nil, err
+		}
+		pods = append(pods, list.Items...)
+	}
+	if len(pods) == 0 {
+		return nil, ErrNoPods
+	}
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+	if len(pods) > maxPods {
+		pods = pods[:maxPods]
+	}
+	return pods, nil
+}
+
+func containerNames(pod corev1.Pod, wanted []string) []string {
+	var names []string
+	for _, container := range pod.Spec.Containers {
+		if len(wanted) == 0 || containsString(wanted, container.Name) {
+			names = append(names, container.Name)
+		}
+	}
+	return names
+}
+
+func (s *Searcher) read(ctx context.Context, client kubernetes.Interface, query Query, pod, container string, since metav1.Time) ([]Entry, int, error) {
+	limitBytes := int64(maxBytesPerContainer)
+	stream, err := client.CoreV1().Pods(query.Namespace).GetLogs(pod, &corev1.PodLogOptions{
+		Container:  container,
+		Timestamps: true,
+		SinceTime:  &since,
+		LimitBytes: &limitBytes,
+	}).Stream(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer stream.Close()
+
+	var (
+		entries []Entry
+		scanned int
+	)
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		entry := ParseLine(scanner.Text())
+		entry.Pod, entry.Container = pod, container
+		scanned++
+		if !query.Matches(entry) {
+			continue
+		}
+		entries = append(entries, entry)
+		// Keep memory bounded: only the newest Limit lines per container can survive the merge.
+		if len(entries) > 2*query.Limit {
+			entries = append(entries[:0], entries[len(entries)-query.Limit:]...)
+		}
+	}
+	return entries, scanned, scanner.Err()
+}
+
+// ParseLine splits a line fetched with timestamps enabled and detects its level.
+func ParseLine(line string) Entry {
+	entry := Entry{Message: line}
+	if stamp, message, ok := strings.Cut(line, " "); ok {
+		if parsed, err := time.Parse(time.RFC3339Nano, stamp); err == nil {
+			entry.Timestamp, entry.Message = parsed, message
+		}
+	}
+	entry.Level = DetectLevel(entry.Message)
+	return entry
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS mfa_enrollments (
+    "user" TEXT PRIMARY KEY,
+    method TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    confirmed BOOLEAN NOT NULL DEFAULT FALSE,
+    last_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL,
+    confirmed_at TIMESTAMPTZ,
+    verified_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS mfa_audit (
+    id TEXT PRIMARY KEY,
+    actor TEXT NOT NULL,
+    "user" TEXT NOT NULL,
+    action TEXT NOT NULL,
+    method TEXT NOT NULL DEFAULT '',
+    path TEXT NOT NULL DEFAULT '',
+    at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS mfa_audit_user_idx ON mfa_audit ("user", at);
@@ -0,0 +1,162 @@
+package mfa
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"io/fs"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pramodksahoo/kubechat/backend/internal/database"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// PostgresRepository stores enrollments and the audit log as plain rows.
+type PostgresRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresRepository applies the MFA schema migrations and returns a repository backed by db.
+func NewPostgresRepository(ctx context.Context, db *sqlx.DB) (*PostgresRepository, error) {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Migrate(ctx, db, "mfa", sub); err != nil {
+		return nil, err
+	}
+	return &PostgresRepository{db: db}, nil
+}
+
+type enrollmentRow struct {
+	User        string       `db:"user"`
+	Method      string       `db:"method"`
+	Secret      string       `db:"secret"`
+	Confirmed   bool         `db:"confirmed"`
+	LastStep    int64        `db:"last_step"`
+	CreatedAt   time.Time    `db:"created_at"`
+	ConfirmedAt sql.NullTime `db:"confirmed_at"`
+	VerifiedAt  sql.NullTime `db:"verified_at"`
+}
+
+type auditRow struct {
+	ID     string    `db:"id"`
+	Actor  string    `db:"actor"`
+	User   string    `db:"user"`
+	Action string    `db:"action"`
+	Method string    `db:"method"`
+	Path   string    `db:"path"`
+	At     time.Time `db:"at"`
+}
+
+func (r *PostgresRepository) SaveEnrollment(ctx context.Context, enrollment Enrollment) error {
+	row := enrollmentRow{
+		User:      enrollment.User,
+		Method:    string(enrollment.Method),
+		Secret:    enrollment.Secret,
+		Confirmed: enrollment.Confirmed,
+		LastStep:  enrollment.LastStep,
+		CreatedAt: enrollment.CreatedAt,
+	}
+	if enrollment.ConfirmedAt != nil {
+		row.ConfirmedAt = sql.NullTime{Time: *enrollment.ConfirmedAt, Valid: true}
+	}
+	if enrollment.VerifiedAt != nil {
+		row.VerifiedAt = sql.NullTime{Time: *enrollment.VerifiedAt, Valid: true}
+	}
+	_, err := r.db.NamedExecContext(ctx, `INSERT INTO mfa_enrollments ("user", method, secret, confirmed, last_step, created_at, confirmed_at, verified_at)
+		VALUES (:user, :method, :secret, :confirmed, :last_step, :created_at, :confirmed_at, :verified_at)
+		ON CONFLICT ("user") DO UPDATE SET method = EXCLUDED.method, secret = EXCLUDED.secret, confirmed = EXCLUDED.confirmed,
+			last_step = EXCLUDED.last_step, created_at = EXCLUDED.created_at, confirmed_at = EXCLUDED.confirmed_at, verified_at = EXCLUDED.verified_at`, row)
+	return err
+}
+
+func (r *PostgresRepository) GetEnrollment(ctx context.Context, user string) (Enrollment, error) {
+	var row enrollmentRow
+	err := r.db.GetContext(ctx, &row, `SELECT "user", method, secret, confirmed, last_step, created_at, confirmed_at, verified_at
+		FROM mfa_enrollments WHERE "user" = $1`, user)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Enrollment{}, ErrNotEnrolled{User: user}
+	}
+	if err != nil {
+		return Enrollment{}, err
+	}
+	enrollment := Enrollment{
+		User:      row.User,
+		Method:    Method(row.Method),
+		Secret:    row.Secret,
+		Confirmed: row.Confirmed,
+		LastStep:  row.LastStep,
+		CreatedAt: row.CreatedAt.UTC(),
+	}
+	if row.ConfirmedAt.Valid {
+		confirmedAt := row.ConfirmedAt.Time.UTC()
+		enrollment.ConfirmedAt = &confirmedAt
+	}
+	if row.VerifiedAt.Valid {
+		verifiedAt := row.VerifiedAt.Time.UTC()
+		enrollment.VerifiedAt = &verifiedAt
+	}
+	return enrollment, nil
+}
+
+func (r *PostgresRepository) DeleteEnrollment(ctx context.Context, user string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM mfa_enrollments WHERE "user" = $1`, user)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotEnrolled{User: user}
+	}
+	return nil
+}
+
+func (r *PostgresRepository) AppendAudit(ctx context.Context, entry AuditEntry) error {
+	_, err := r.db.NamedExecContext(ctx, `INSERT INTO mfa_audit (id, actor, "user", action, method, path, at)
+		VALUES (:id, :actor, :user, :action, :method, :path, :at)`, auditRow{
+		ID:     entry.ID,
+		Actor:  entry.Actor,
+		User:   entry.User,
+		Action: string(entry.Action),
+		Method: entry.Method,
+		Path:   entry.Path,
+		At:     entry.At,
+	})
+	return err
+}
+
+func (r *PostgresRepository) ListAudit(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	query := `SELECT id, actor, "user", action, method, path, at FROM mfa_audit`
+	var args []any
+	if filter.User != "" {
+		args = append(args, filter.User)
+		query += ` WHERE actor = $1 OR "user" = $1`
+	}
+	query += ` ORDER BY at DESC`
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += ` LIMIT $` + strconv.Itoa(len(args))
+	}
+	var rows []auditRow
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, err
+	}
+	entries := make([]AuditEntry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, AuditEntry{
+			ID:     row.ID,
+			Actor:  row.Actor,
+			User:   row.User,
+			Action: Action(row.Action),
+			Method: row.Method,
+			Path:   row.Path,
+			At:     row.At.UTC(),
+		})
+	}
+	return entries, nil
+}
@@ -0,0 +1,76 @@
+package mfa
+
+import (
+	"context"
+	"sync"
+)
+
+// Repository persists enrollments and the audit log. Audit entries are only ever appended.
+type Repository interface {
+	SaveEnrollment(ctx context.Context, enrollment Enrollment) error
+	GetEnrollment(ctx context.Context, user string) (Enrollment, error)
+	DeleteEnrollment(ctx context.Context, user string) error
+	AppendAudit(ctx context.Context, entry AuditEntry) error
+	// ListAudit returns matching entries, newest first.
+	ListAudit(ctx context.Context, filter AuditFilter) ([]AuditEntry, error)
+}
+
+// MemoryRepository keeps enrollments and the audit log in process memory; it is used when no database is configured.
+type MemoryRepository struct {
+	mu          sync.RWMutex
+	enrollments map[string]Enrollment
+	audit       []AuditEntry
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{enrollments: make(map[string]Enrollment)}
+}
+
+func (r *MemoryRepository) SaveEnrollment(ctx context.Context, enrollment Enrollment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.enrollments[enrollment.User] = enrollment
+	return nil
+}
+
+func (r *MemoryRepository) GetEnrollment(ctx context.Context, user string) (Enrollment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	enrollment, ok := r.enrollments[user]
+	if !ok {
+		return Enrollment{}, ErrNotEnrolled{User: user}
+	}
+	return enrollment, nil
+}
+
+func (r *MemoryRepository) DeleteEnrollment(ctx context.Context, user string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.enrollments[user]; !ok {
+		return ErrNotEnrolled{User: user}
+	}
+	delete(r.enrollments, user)
+	return nil
+}
+
+func (r *MemoryRepository) AppendAudit(ctx context.Context, entry AuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.audit = append(r.audit, entry)
+	return nil
+}
+
+func (r *MemoryRepository) ListAudit(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entries := make([]AuditEntry, 0)
+	for i := len(r.audit) - 1; i >= 0; i-- {
+		if filter.matches(r.audit[i]) {
+			entries = append(entries, r.audit[i])
+			if filter.Limit > 0 && len(entries) == filter.Limit {
+				break
+			}
+		}
+	}
+	return entries, nil
+}
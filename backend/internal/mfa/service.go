@@ -0,0 +1,213 @@
+package mfa
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/pramodksahoo/kubechat/backend/internal/audit"
+)
+
+// Service enrolls users and checks step-up assertions. The last verification is stored with the
+// enrollment, so an assertion made through one replica satisfies checks on every replica.
+type Service struct {
+	repo   Repository
+	issuer string
+	window time.Duration
+	ledger Ledger
+	logger *log.Logger
+	now    func() time.Time
+}
+
+// Ledger chains audit entries into a record that cannot be edited unnoticed.
+type Ledger interface {
+	Append(ctx context.Context, source audit.Source, entry any) error
+}
+
+// NewService returns a service whose assertions stay fresh for window.
+func NewService(repo Repository, issuer string, window time.Duration, logger *log.Logger) *Service {
+	if window <= 0 {
+		window = DefaultStepUpWindow
+	}
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Service{repo: repo, issuer: issuer, window: window, logger: logger, now: func() time.Time { return time.Now().UTC() }}
+}
+
+// SetLedger adds enrollments, verifications and resets to ledger as they are audited.
+func (s *Service) SetLedger(ledger Ledger) {
+	s.ledger = ledger
+}
+
+// Enroll creates a new unconfirmed secret for user, replacing any earlier unconfirmed one. A
+// confirmed enrollment has to be removed first, which itself needs a fresh assertion.
+func (s *Service) Enroll(ctx context.Context, user string) (Secret, error) {
+	existing, err := s.repo.GetEnrollment(ctx, user)
+	if err != nil && !errors.As(err, &ErrNotEnrolled{}) {
+		return Secret{}, err
+	}
+	if err == nil && existing.Confirmed {
+		return Secret{}, ErrInvalidRequest{Reason: "an authenticator is already enrolled; remove it before enrolling another"}
+	}
+	secret, err := newSecret()
+	if err != nil {
+		return Secret{}, err
+	}
+	enrollment := Enrollment{User: user, Method: MethodTOTP, Secret: secret, CreatedAt: s.now()}
+	if err := s.repo.SaveEnrollment(ctx, enrollment); err != nil {
+		return Secret{}, err
+	}
+	if err := s.audit(ctx, user, user, ActionEnrolled, "", ""); err != nil {
+		return Secret{}, err
+	}
+	return Secret{Secret: secret, URI: provisioningURI(s.issuer, user, secret)}, nil
+}
+
+// Confirm activates user's enrollment with a code from their authenticator. The code also
+// counts as a verification.
+func (s *Service) Confirm(ctx context.Context, user, candidate string) (Enrollment, error) {
+	enrollment, err := s.repo.GetEnrollment(ctx, user)
+	if err != nil {
+		return Enrollment{}, err
+	}
+	if enrollment.Confirmed {
+		return Enrollment{}, ErrInvalidRequest{Reason: "the enrollment is already confirmed"}
+	}
+	if err := s.check(ctx, &enrollment, candidate); err != nil {
+		return Enrollment{}, err
+	}
+	enrollment.Confirmed = true
+	enrollment.ConfirmedAt = enrollment.VerifiedAt
+	if err := s.repo.SaveEnrollment(ctx, enrollment); err != nil {
+		return Enrollment{}, err
+	}
+	return enrollment, s.audit(ctx, user, user, ActionConfirmed, "", "")
+}
+
+// Verify checks a code against user's confirmed enrollment and returns the assertion it grants.
+func (s *Service) Verify(ctx context.Context, user, candidate string) (Assertion, error) {
+	enrollment, err := s.repo.GetEnrollment(ctx, user)
+	if err != nil {
+		return Assertion{}, err
+	}
+	if !enrollment.Confirmed {
+		return Assertion{}, ErrNotEnrolled{User: user}
+	}
+	if err := s.check(ctx, &enrollment, candidate); err != nil {
+		return Assertion{}, err
+	}
+	if err := s.repo.SaveEnrollment(ctx, enrollment); err != nil {
+		return Assertion{}, err
+	}
+	return s.assertion(enrollment), s.audit(ctx, user, user, ActionVerified, "", "")
+}
+
+// check matches candidate against the enrollment and, on success, records the time step it used.
+func (s *Service) check(ctx context.Context, enrollment *Enrollment, candidate string) error {
+	now := s.now()
+	step, ok := match(enrollment.Secret, strings.TrimSpace(candidate), now, enrollment.LastStep)
+	if !ok {
+		s.logger.Warn("MFA code rejected", "user", enrollment.User)
+		if err := s.audit(ctx, enrollment.User, enrollment.User, ActionFailed, "", ""); err != nil {
+			return err
+		}
+		return ErrInvalidCode{}
+	}
+	enrollment.LastStep = step
+	enrollment.VerifiedAt = &now
+	return nil
+}
+
+// Remove deletes user's enrollment on behalf of actor. Users need a fresh assertion to remove
+// their own; admins resetting a lost authenticator are checked by the route instead.
+func (s *Service) Remove(ctx context.Context, actor, user string) error {
+	if actor == user {
+		enrollment, err := s.repo.GetEnrollment(ctx, user)
+		if err != nil {
+			return err
+		}
+		if enrollment.Confirmed && !s.fresh(enrollment) {
+			return ErrStepUpRequired{User: user, Enrolled: true}
+		}
+	}
+	if err := s.repo.DeleteEnrollment(ctx, user); err != nil {
+		return err
+	}
+	s.logger.Info("MFA enrollment removed", "actor", actor, "user", user)
+	return s.audit(ctx, actor, user, ActionRemoved, "", "")
+}
+
+// Status reports user's enrollment and any fresh assertion.
+func (s *Service) Status(ctx context.Context, user string) (Status, error) {
+	status := Status{User: user}
+	enrollment, err := s.repo.GetEnrollment(ctx, user)
+	if errors.As(err, &ErrNotEnrolled{}) {
+		return status, nil
+	}
+	if err != nil {
+		return Status{}, err
+	}
+	status.Enrolled, status.Confirmed, status.Method = true, enrollment.Confirmed, enrollment.Method
+	if enrollment.Confirmed && s.fresh(enrollment) {
+		assertion := s.assertion(enrollment)
+		status.StepUp = &assertion
+	}
+	return status, nil
+}
+
+// RequireStepUp checks that user verified a code within the step-up window before making the
+// request, and records the outcome in the audit log.
+func (s *Service) RequireStepUp(ctx context.Context, user, method, path string) error {
+	enrollment, err := s.repo.GetEnrollment(ctx, user)
+	if err != nil && !errors.As(err, &ErrNotEnrolled{}) {
+		return err
+	}
+	if err == nil && enrollment.Confirmed && s.fresh(enrollment) {
+		return s.audit(ctx, user, user, ActionStepUpSatisfied, method, path)
+	}
+	if auditErr := s.audit(ctx, user, user, ActionStepUpRequired, method, path); auditErr != nil {
+		return auditErr
+	}
+	return ErrStepUpRequired{User: user, Enrolled: err == nil && enrollment.Confirmed}
+}
+
+// Audit returns the audit log, narrowed by filter.
+func (s *Service) Audit(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	return s.repo.ListAudit(ctx, filter)
+}
+
+func (s *Service) fresh(enrollment Enrollment) bool {
+	return enrollment.VerifiedAt != nil && s.now().Before(enrollment.VerifiedAt.Add(s.window))
+}
+
+func (s *Service) assertion(enrollment Enrollment) Assertion {
+	return Assertion{
+		User:       enrollment.User,
+		Method:     enrollment.Method,
+		VerifiedAt: *enrollment.VerifiedAt,
+		ExpiresAt:  enrollment.VerifiedAt.Add(s.window),
+	}
+}
+
+func (s *Service) audit(ctx context.Context, actor, user string, action Action, method, path string) error {
+	entry := AuditEntry{
+		ID:     uuid.NewString(),
+		Actor:  actor,
+		User:   user,
+		Action: action,
+		Method: method,
+		Path:   path,
+		At:     s.now(),
+	}
+	if err := s.repo.AppendAudit(ctx, entry); err != nil {
+		return err
+	}
+	if s.ledger == nil {
+		return nil
+	}
+	return s.ledger.Append(ctx, audit.SourceMFA, entry)
+}
@@ -0,0 +1,95 @@
+package mfa
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStepUpNeedsAFreshConfirmedVerification(t *testing.T) {
+	repo := NewMemoryRepository()
+	svc := NewService(repo, "KubeChat", 5*time.Minute, nil)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+	current := func(secret string) string {
+		value, err := code(secret, timeStep(now))
+		if err != nil {
+			t.Fatalf("code: %v", err)
+		}
+		return value
+	}
+
+	if err := svc.RequireStepUp(ctx, "alice", "POST", "/api/v1/commands/executions/1/approve"); !errors.As(err, &ErrStepUpRequired{}) || err.(ErrStepUpRequired).Enrolled {
+		t.Fatalf("expected users without MFA to be told to enroll, got %v", err)
+	}
+	secret, err := svc.Enroll(ctx, "alice")
+	if err != nil {
+		t.Fatalf("enroll: %v", err)
+	}
+	if !strings.HasPrefix(secret.URI, "otpauth://totp/KubeChat:alice?") {
+		t.Fatalf("unexpected provisioning URI %s", secret.URI)
+	}
+	if _, err := svc.Verify(ctx, "alice", current(secret.Secret)); !errors.As(err, &ErrNotEnrolled{}) {
+		t.Fatalf("expected an unconfirmed enrollment to refuse verification, got %v", err)
+	}
+	if _, err := svc.Confirm(ctx, "alice", "000000"); !errors.As(err, &ErrInvalidCode{}) {
+		t.Fatalf("expected a wrong code to be refused, got %v", err)
+	}
+	if _, err := svc.Confirm(ctx, "alice", current(secret.Secret)); err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	if err := svc.RequireStepUp(ctx, "alice", "POST", "/api/v1/commands/executions/1/approve"); err != nil {
+		t.Fatalf("expected confirming to count as a verification: %v", err)
+	}
+
+	now = now.Add(10 * time.Minute)
+	if err := svc.RequireStepUp(ctx, "alice", "POST", "/api/v1/commands/executions/2/approve"); !errors.As(err, &ErrStepUpRequired{}) || !err.(ErrStepUpRequired).Enrolled {
+		t.Fatalf("expected a stale verification to need step-up, got %v", err)
+	}
+	if err := svc.Remove(ctx, "alice", "alice"); !errors.As(err, &ErrStepUpRequired{}) {
+		t.Fatalf("expected removing the authenticator to need step-up, got %v", err)
+	}
+	code := current(secret.Secret)
+	assertion, err := svc.Verify(ctx, "alice", code)
+	if err != nil || !assertion.ExpiresAt.Equal(now.Add(5*time.Minute)) {
+		t.Fatalf("unexpected assertion %+v (%v)", assertion, err)
+	}
+	if _, err := svc.Verify(ctx, "alice", code); !errors.As(err, &ErrInvalidCode{}) {
+		t.Fatalf("expected a replayed code to be refused, got %v", err)
+	}
+	if err := svc.RequireStepUp(ctx, "alice", "POST", "/api/v1/commands/executions/2/approve"); err != nil {
+		t.Fatalf("expected a fresh verification to satisfy step-up: %v", err)
+	}
+
+	entries, _ := svc.Audit(ctx, AuditFilter{User: "alice"})
+	var actions []string
+	for i := len(entries) - 1; i >= 0; i-- {
+		actions = append(actions, string(entries[i].Action))
+	}
+	want := "step_up_required enrolled failed confirmed step_up_satisfied step_up_required verified failed step_up_satisfied"
+	if got := strings.Join(actions, " "); got != want {
+		t.Fatalf("unexpected audit trail\n got: %s\nwant: %s", got, want)
+	}
+	if entries[0].Path != "/api/v1/commands/executions/2/approve" {
+		t.Fatalf("expected step-up checks to record the request path, got %+v", entries[0])
+	}
+
+	if err := svc.Remove(ctx, "root", "alice"); err != nil {
+		t.Fatalf("admin reset: %v", err)
+	}
+	if status, _ := svc.Status(ctx, "alice"); status.Enrolled {
+		t.Fatalf("expected the reset to remove the enrollment, got %+v", status)
+	}
+}
+
+func TestCodeMatchesRFC6238Vector(t *testing.T) {
+	// RFC 6238 appendix B uses the ASCII secret "12345678901234567890" with SHA-1.
+	secret := encoding.EncodeToString([]byte("12345678901234567890"))
+	got, err := code(secret, timeStep(time.Unix(59, 0)))
+	if err != nil || got != "287082" {
+		t.Fatalf("expected 287082, got %s (%v)", got, err)
+	}
+}
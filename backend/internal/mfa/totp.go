@@ -0,0 +1,80 @@
+package mfa
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// TOTP parameters follow RFC 6238 defaults, which every authenticator app supports.
+const (
+	period = 30 * time.Second
+	digits = 6
+	// skew accepts codes from the neighbouring time steps to allow for clock drift.
+	skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func newSecret() (string, error) {
+	raw := make([]byte, 20)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(raw), nil
+}
+
+func provisioningURI(issuer, user, secret string) string {
+	label := url.PathEscape(issuer + ":" + user)
+	query := url.Values{
+		"secret": {secret},
+		"issuer": {issuer},
+		"digits": {fmt.Sprint(digits)},
+		"period": {fmt.Sprint(int(period / time.Second))},
+	}
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+func timeStep(t time.Time) int64 {
+	return t.Unix() / int64(period/time.Second)
+}
+
+// code returns the TOTP code of secret for a time step.
+func code(secret string, step int64) (string, error) {
+	key, err := encoding.DecodeString(secret)
+	if err != nil {
+		return "", err
+	}
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", digits, value%1_000_000), nil
+}
+
+// match returns the time step candidate matches at now, accepting only steps after lastStep.
+func match(secret, candidate string, now time.Time, lastStep int64) (int64, bool) {
+	current := timeStep(now)
+	for step := current - skew; step <= current+skew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		expected, err := code(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(candidate)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
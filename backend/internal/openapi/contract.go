@@ -0,0 +1,264 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+)
+
+// ContractMode controls how the server reacts to responses that do not match the document.
+type ContractMode string
+
+const (
+	ContractOff ContractMode = "off"
+	// ContractLog logs violations and serves the response unchanged; intended for staging.
+	ContractLog ContractMode = "log"
+	// ContractEnforce replaces violating responses with a 500 so CI runs fail loudly.
+	ContractEnforce ContractMode = "enforce"
+)
+
+func ParseContractMode(value string) (ContractMode, error) {
+	switch mode := ContractMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case "", ContractOff:
+		return ContractOff, nil
+	case ContractLog, ContractEnforce:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown OpenAPI contract mode %q (want off, log or enforce)", value)
+	}
+}
+
+type Violation struct {
+	Method string
+	Path   string
+	Status int
+	Reason string
+}
+
+func (v Violation) Error() string {
+	return fmt.Sprintf("%s %s -> %d: %s", v.Method, v.Path, v.Status, v.Reason)
+}
+
+// ContractMiddleware validates every JSON response against doc. Event streams are passed through untouched.
+func ContractMiddleware(doc *Document, mode ContractMode, logger *log.Logger) echo.MiddlewareFunc {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		if mode == ContractOff {
+			return next
+		}
+		return func(c echo.Context) error {
+			op := doc.Operation(c.Request().Method, c.Path())
+			if op == nil {
+				if c.Path() != "" && !strings.Contains(c.Path(), "*") {
+					logger.Error("OpenAPI contract violation", "violation", Violation{Method: c.Request().Method, Path: c.Path(), Reason: "route is not documented"})
+				}
+				return next(c)
+			}
+			if op.streams() {
+				return next(c)
+			}
+
+			original := c.Response().Writer
+			capture := &captureWriter{ResponseWriter: original, status: http.StatusOK}
+			c.Response().Writer = capture
+			err := next(c)
+			if err != nil {
+				// Let echo's error handler write the response through the capture.
+				c.Error(err)
+			}
+			c.Response().Writer = original
+
+			if violation := doc.validate(op, c.Request().Method, c.Path(), capture.status, capture.Header().Get(echo.HeaderContentType), capture.body.Bytes()); violation != nil {
+				logger.Error("OpenAPI contract violation", "violation", violation)
+				if mode == ContractEnforce {
+					capture.Header().Del(echo.HeaderContentLength)
+					capture.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+					original.WriteHeader(http.StatusInternalServerError)
+					json.NewEncoder(original).Encode(ErrorResponse{Error: "response violates API contract: " + violation.Reason})
+					return nil
+				}
+			}
+			original.WriteHeader(capture.status)
+			original.Write(capture.body.Bytes())
+			return nil
+		}
+	}
+}
+
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *captureWriter) WriteHeader(status int) { w.status = status }
+
+func (w *captureWriter) Write(p []byte) (int, error) { return w.body.Write(p) }
+
+// Operation finds the documented operation for an echo route path.
+func (d *Document) Operation(method, path string) *OperationObject {
+	item, ok := d.Paths[SpecPath(path)]
+	if !ok {
+		return nil
+	}
+	return item[strings.ToLower(method)]
+}
+
+// streams reports whether the operation streams events or upgrades the connection; neither can
+// be captured and validated.
+func (o *OperationObject) streams() bool {
+	if _, ok := o.Responses[strconv.Itoa(http.StatusSwitchingProtocols)]; ok {
+		return true
+	}
+	for _, response := range o.Responses {
+		if _, ok := response.Content["text/event-stream"]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Document) validate(op *OperationObject, method, path string, status int, contentType string, body []byte) *Violation {
+	violation := func(reason string) *Violation {
+		return &Violation{Method: method, Path: path, Status: status, Reason: reason}
+	}
+
+	response, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		if status < http.StatusBadRequest {
+			return violation("undocumented status code")
+		}
+		response = op.Responses["default"]
+	}
+	if len(response.Content) == 0 {
+		if len(bytes.TrimSpace(body)) > 0 {
+			return violation("unexpected response body")
+		}
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	media, ok := response.Content[mediaType]
+	if !ok {
+		media, ok = response.Content["*/*"]
+	}
+	if !ok {
+		return violation(fmt.Sprintf("undocumented content type %q", contentType))
+	}
+	if mediaType != echo.MIMEApplicationJSON || media.Schema == nil {
+		return nil
+	}
+
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return violation("invalid JSON body: " + err.Error())
+	}
+	if err := d.check(media.Schema, value, "$"); err != nil {
+		return violation(err.Error())
+	}
+	return nil
+}
+
+func (d *Document) check(schema *Schema, value any, at string) error {
+	if schema.Ref != "" {
+		resolved, ok := d.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+		if !ok {
+			return fmt.Errorf("%s: unresolved reference %s", at, schema.Ref)
+		}
+		return d.check(resolved, value, at)
+	}
+	if len(schema.AnyOf) > 0 {
+		var errs []string
+		for _, candidate := range schema.AnyOf {
+			err := d.check(candidate, value, at)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, err.Error())
+		}
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	types := schema.types()
+	if len(types) > 0 && !matchesAny(types, value) {
+		return fmt.Errorf("%s: expected %s, got %s", at, strings.Join(types, " or "), jsonType(value))
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for _, name := range schema.Required {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", at, name)
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			property, ok := schema.Properties[key]
+			if !ok {
+				property = schema.AdditionalProperties
+			}
+			if property == nil {
+				continue
+			}
+			if err := d.check(property, v[key], at+"."+key); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if schema.Items == nil {
+			return nil
+		}
+		for i, item := range v {
+			if err := d.check(schema.Items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func matchesAny(types []string, value any) bool {
+	actual := jsonType(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonType(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return "unknown"
+}
@@ -0,0 +1,132 @@
+package openapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+)
+
+type widget struct {
+	ID        string            `json:"id"`
+	Count     int               `json:"count"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Parent    *widget           `json:"parent,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	internal  string
+}
+
+func newTestServer(mode ContractMode, handler echo.HandlerFunc) (*echo.Echo, []string) {
+	e := echo.New()
+	e.GET("api/v1/widgets/:id", handler)
+	e.GET("api/v1/widgets/:id/stream", func(c echo.Context) error { return c.String(http.StatusOK, "data: x\n\n") })
+	e.GET("api/v1/undocumented", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	registry := NewRegistry(Info{Title: "test", Version: "1"})
+	registry.Annotate(http.MethodGet, "api/v1/widgets/:id", Operation{Summary: "Get a widget", Responses: map[int]any{http.StatusOK: widget{}}})
+	registry.Derive(func(route *echo.Route) (Operation, bool) {
+		if strings.HasSuffix(route.Path, "/stream") {
+			return Operation{Responses: map[int]any{http.StatusOK: EventStream{}}}, true
+		}
+		return Operation{}, false
+	})
+	doc, undocumented := registry.Build(e.Routes())
+	e.Use(ContractMiddleware(doc, mode, log.NewWithOptions(io.Discard, log.Options{})))
+	return e, undocumented
+}
+
+func TestRegistryBuildsDocument(t *testing.T) {
+	e, undocumented := newTestServer(ContractOff, func(c echo.Context) error { return nil })
+	if len(undocumented) != 1 || undocumented[0] != "GET /api/v1/undocumented" {
+		t.Fatalf("unexpected undocumented routes: %v", undocumented)
+	}
+
+	registry := NewRegistry(Info{Title: "test"})
+	registry.Annotate(http.MethodGet, "api/v1/widgets/:id", Operation{Responses: map[int]any{http.StatusOK: widget{}}})
+	doc, _ := registry.Build(e.Routes())
+
+	op := doc.Operation(http.MethodGet, "api/v1/widgets/:id")
+	if op == nil || len(op.Parameters) != 1 || op.Parameters[0].Name != "id" || op.Parameters[0].In != "path" {
+		t.Fatalf("expected path parameter, got %+v", op)
+	}
+	schema := doc.Components.Schemas["openapi.widget"]
+	if schema == nil {
+		t.Fatalf("expected widget component, have %v", doc.Components.Schemas)
+	}
+	if strings.Join(schema.Required, ",") != "id,count,createdAt" {
+		t.Fatalf("unexpected required properties %v", schema.Required)
+	}
+	if _, ok := schema.Properties["internal"]; ok {
+		t.Fatal("unexported fields must not be documented")
+	}
+	if schema.Properties["parent"].AnyOf[0].Ref != "#/components/schemas/openapi.widget" {
+		t.Fatalf("expected nullable self reference, got %+v", schema.Properties["parent"])
+	}
+
+	raw, err := json.Marshal(doc)
+	if err != nil || !strings.Contains(string(raw), `"openapi":"3.1.0"`) || !strings.Contains(string(raw), `"/api/v1/widgets/{id}"`) {
+		t.Fatalf("unexpected document %s (%v)", raw, err)
+	}
+}
+
+func TestContractMiddlewareEnforcesSchema(t *testing.T) {
+	valid := func(c echo.Context) error {
+		return c.JSON(http.StatusOK, widget{ID: "w1", Count: 2})
+	}
+	drifted := func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]any{"id": "w1", "count": "two", "createdAt": time.Now()})
+	}
+	undocumentedStatus := func(c echo.Context) error {
+		return c.JSON(http.StatusCreated, widget{ID: "w1"})
+	}
+	documentedError := func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusNotFound, "widget not found")
+	}
+
+	cases := []struct {
+		name    string
+		mode    ContractMode
+		handler echo.HandlerFunc
+		status  int
+	}{
+		{"valid", ContractEnforce, valid, http.StatusOK},
+		{"wrong property type", ContractEnforce, drifted, http.StatusInternalServerError},
+		{"undocumented status", ContractEnforce, undocumentedStatus, http.StatusInternalServerError},
+		{"error response", ContractEnforce, documentedError, http.StatusNotFound},
+		{"log mode passes through", ContractLog, drifted, http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e, _ := newTestServer(tc.mode, tc.handler)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/widgets/w1", nil))
+			if rec.Code != tc.status {
+				t.Fatalf("expected %d, got %d: %s", tc.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestContractMiddlewareSkipsStreams(t *testing.T) {
+	e, _ := newTestServer(ContractEnforce, nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/widgets/w1/stream", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "data: x\n\n" {
+		t.Fatalf("expected stream to pass through, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestParseContractMode(t *testing.T) {
+	if mode, err := ParseContractMode(""); err != nil || mode != ContractOff {
+		t.Fatalf("expected off by default, got %q (%v)", mode, err)
+	}
+	if _, err := ParseContractMode("strict"); err == nil {
+		t.Fatal("expected error for unknown mode")
+	}
+}
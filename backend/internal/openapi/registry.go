@@ -0,0 +1,212 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Operation annotates a route. Responses map status codes to a sample Go value whose type
+// describes the JSON body; nil means the response has no body.
+type Operation struct {
+	Summary   string
+	Tags      []string
+	Query     []string
+	Form      []string
+	Request   any
+	Responses map[int]any
+}
+
+// EventStream marks a response delivered as server-sent events.
+type EventStream struct{}
+
+// PlainText marks a text/plain response.
+type PlainText struct{}
+
+// Binary marks a download whose content type depends on the stored object.
+type Binary struct{}
+
+// Page marks an HTML page for browsers that API clients get as the JSON body instead.
+type Page struct {
+	JSON any
+}
+
+// AnyOf documents a body that may take any of the given shapes.
+type AnyOf []any
+
+// ErrorResponse covers the error bodies in use: controllers return "error" while
+// echo's HTTPError and the older handlers return "message".
+type ErrorResponse struct {
+	Error   string `json:"error,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Deriver produces an annotation for routes that are not annotated explicitly,
+// typically from a route naming convention.
+type Deriver func(route *echo.Route) (Operation, bool)
+
+type Registry struct {
+	info     Info
+	mu       sync.RWMutex
+	ops      map[string]Operation
+	derivers []Deriver
+}
+
+func NewRegistry(info Info) *Registry {
+	return &Registry{info: info, ops: make(map[string]Operation)}
+}
+
+func (r *Registry) Annotate(method, path string, op Operation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ops[routeKey(method, path)] = op
+}
+
+func (r *Registry) Derive(deriver Deriver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.derivers = append(r.derivers, deriver)
+}
+
+func (r *Registry) lookup(route *echo.Route) (Operation, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if op, ok := r.ops[routeKey(route.Method, route.Path)]; ok {
+		return op, true
+	}
+	for _, derive := range r.derivers {
+		if op, ok := derive(route); ok {
+			return op, true
+		}
+	}
+	return Operation{}, false
+}
+
+// Build generates the document for routes and returns the routes that carry no annotation.
+// Undocumented routes are still listed in the document with a generic response.
+func (r *Registry) Build(routes []*echo.Route) (*Document, []string) {
+	b := &builder{components: make(map[string]*Schema)}
+	doc := &Document{OpenAPI: Version, Info: r.info, Paths: make(map[string]PathItem)}
+
+	sorted := append([]*echo.Route(nil), routes...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return routeKey(sorted[i].Method, sorted[i].Path) < routeKey(sorted[j].Method, sorted[j].Path)
+	})
+
+	var undocumented []string
+	for _, route := range sorted {
+		if !documentable(route) {
+			continue
+		}
+		op, ok := r.lookup(route)
+		if !ok {
+			undocumented = append(undocumented, route.Method+" "+route.Path)
+			op = Operation{Responses: map[int]any{http.StatusOK: nil}}
+		}
+		specPath := SpecPath(route.Path)
+		if doc.Paths[specPath] == nil {
+			doc.Paths[specPath] = make(PathItem)
+		}
+		doc.Paths[specPath][strings.ToLower(route.Method)] = b.operation(route, op)
+	}
+	doc.Components.Schemas = b.components
+	return doc, undocumented
+}
+
+type builder struct {
+	components map[string]*Schema
+}
+
+func (b *builder) operation(route *echo.Route, op Operation) *OperationObject {
+	object := &OperationObject{
+		OperationID: operationID(route),
+		Summary:     op.Summary,
+		Tags:        op.Tags,
+		Responses:   make(map[string]Response),
+	}
+	for _, name := range pathParams(route.Path) {
+		object.Parameters = append(object.Parameters, Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+	}
+	for _, name := range op.Query {
+		object.Parameters = append(object.Parameters, Parameter{Name: name, In: "query", Schema: &Schema{Type: "string"}})
+	}
+	if op.Request != nil {
+		object.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{"application/json": {Schema: b.schemaFor(op.Request)}}}
+	}
+	if len(op.Form) > 0 {
+		form := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		for _, field := range op.Form {
+			form.Properties[field] = &Schema{Type: "string"}
+		}
+		object.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+			"multipart/form-data":               {Schema: form},
+			"application/x-www-form-urlencoded": {Schema: form},
+		}}
+	}
+	for status, body := range op.Responses {
+		object.Responses[strconv.Itoa(status)] = b.response(status, body)
+	}
+	object.Responses["default"] = Response{
+		Description: "Error",
+		Content:     map[string]MediaType{"application/json": {Schema: b.schemaFor(ErrorResponse{})}},
+	}
+	return object
+}
+
+func (b *builder) response(status int, body any) Response {
+	response := Response{Description: http.StatusText(status)}
+	switch body := body.(type) {
+	case nil:
+	case EventStream:
+		response.Content = map[string]MediaType{"text/event-stream": {Schema: &Schema{Type: "string"}}}
+	case PlainText:
+		response.Content = map[string]MediaType{"text/plain": {Schema: &Schema{Type: "string"}}}
+	case Binary:
+		response.Content = map[string]MediaType{"*/*": {Schema: &Schema{Type: "string", Format: "binary"}}}
+	case Page:
+		response.Content = map[string]MediaType{
+			"text/html":        {Schema: &Schema{Type: "string"}},
+			"application/json": {Schema: b.schemaFor(body.JSON)},
+		}
+	default:
+		response.Content = map[string]MediaType{"application/json": {Schema: b.schemaFor(body)}}
+	}
+	return response
+}
+
+var pathParamPattern = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+// SpecPath converts an echo route path such as "api/v1/plans/:id" to "/api/v1/plans/{id}".
+func SpecPath(path string) string {
+	return "/" + strings.TrimPrefix(pathParamPattern.ReplaceAllString(path, "{$1}"), "/")
+}
+
+func pathParams(path string) []string {
+	var names []string
+	for _, match := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+func operationID(route *echo.Route) string {
+	if route.Name != "" && !strings.Contains(route.Name, ".") && !strings.Contains(route.Name, "/") {
+		return route.Name
+	}
+	return strings.ToLower(route.Method) + "_" + strings.NewReplacer("/", "_", ":", "", "-", "_").Replace(strings.Trim(route.Path, "/"))
+}
+
+// documentable excludes the static file handler and echo's internal routes.
+func documentable(route *echo.Route) bool {
+	return route.Method != echo.RouteNotFound && !strings.Contains(route.Path, "*")
+}
+
+func routeKey(method, path string) string {
+	return fmt.Sprintf("%s %s", method, strings.TrimPrefix(path, "/"))
+}
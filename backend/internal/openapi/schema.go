@@ -0,0 +1,172 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	rawMessageType      = reflect.TypeOf(json.RawMessage{})
+	stringMarshaledKind = map[string]bool{"Duration": true, "Time": true, "MicroTime": true}
+)
+
+// schemaFor returns the schema for the Go type of v, registering named structs as components.
+func (b *builder) schemaFor(v any) *Schema {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case AnyOf:
+		schema := &Schema{}
+		for _, option := range v {
+			schema.AnyOf = append(schema.AnyOf, b.schemaFor(option))
+		}
+		return schema
+	}
+	return b.schemaForType(reflect.TypeOf(v))
+}
+
+func (b *builder) schemaForType(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &Schema{}
+	case t.Kind() != reflect.Pointer && t.Kind() != reflect.Interface &&
+		(t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType)):
+		// Custom marshalers; the apimachinery time and duration wrappers encode as strings.
+		if stringMarshaledKind[t.Name()] {
+			return &Schema{Type: []string{"string", "null"}}
+		}
+		return &Schema{}
+	case t.Kind() != reflect.Pointer && t.Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		inner := b.schemaForType(t.Elem())
+		return nullable(inner)
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if t.Kind() == reflect.Int64 && t.PkgPath() == "time" {
+			// time.Duration marshals as integer nanoseconds.
+			return &Schema{Type: "integer", Format: "int64"}
+		}
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		items := b.schemaForType(t.Elem())
+		if t.Kind() == reflect.Slice {
+			return &Schema{Type: []string{"array", "null"}, Items: items}
+		}
+		return &Schema{Type: "array", Items: items}
+	case reflect.Map:
+		return &Schema{Type: []string{"object", "null"}, AdditionalProperties: b.schemaForType(t.Elem())}
+	case reflect.Struct:
+		return b.structSchema(t)
+	}
+	return &Schema{}
+}
+
+func (b *builder) structSchema(t reflect.Type) *Schema {
+	name := componentName(t)
+	if name == "" {
+		return b.inlineStruct(t)
+	}
+	if _, ok := b.components[name]; !ok {
+		// Reserve the name first so recursive types resolve to the reference.
+		b.components[name] = &Schema{}
+		*b.components[name] = *b.inlineStruct(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (b *builder) inlineStruct(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		name, omitEmpty, skip := jsonField(field)
+		if skip {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				inner := b.inlineStruct(embedded)
+				for prop, s := range inner.Properties {
+					schema.Properties[prop] = s
+				}
+				schema.Required = append(schema.Required, inner.Required...)
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = b.schemaForType(field.Type)
+		if !omitEmpty {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+func jsonField(field reflect.StructField) (name string, omitEmpty, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" || opt == "omitzero" {
+			omitEmpty = true
+		}
+	}
+	return parts[0], omitEmpty, false
+}
+
+func componentName(t reflect.Type) string {
+	if t.Name() == "" || t.PkgPath() == "" {
+		return ""
+	}
+	return path.Base(t.PkgPath()) + "." + t.Name()
+}
+
+func nullable(s *Schema) *Schema {
+	if s.Ref != "" {
+		return &Schema{AnyOf: []*Schema{s, {Type: "null"}}}
+	}
+	types := s.types()
+	if len(types) == 0 {
+		return s
+	}
+	for _, t := range types {
+		if t == "null" {
+			return s
+		}
+	}
+	copied := *s
+	copied.Type = append(append([]string{}, types...), "null")
+	return &copied
+}
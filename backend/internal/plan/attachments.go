@@ -0,0 +1,73 @@
+package plan
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Attachment is a file attached to a prompt. Manifest attachments can be applied by ID through
+// the commands API, so apply steps carry the ID instead of the manifest text.
+type Attachment struct {
+	ID       string
+	Name     string
+	Manifest bool
+	// Objects lists the manifest's objects as Kind/name or Kind/namespace/name.
+	Objects []string
+	Content string
+}
+
+var applyIntent = regexp.MustCompile(`(?i)\b(?:apply|create|deploy|install|roll ?out)\b`)
+
+// attachmentSteps plans an apply for each attached manifest when the prompt asks for one, and a
+// read-only diff against live state otherwise. Other files only inform the prompt.
+func attachmentSteps(cluster, namespace, prompt string, attachments []Attachment, offset int) []PlanStep {
+	apply := applyIntent.MatchString(prompt)
+	var steps []PlanStep
+	for _, attachment := range attachments {
+		if !attachment.Manifest {
+			continue
+		}
+		affected := attachment.Objects
+		if len(affected) == 0 {
+			affected = []string{attachment.Name}
+		}
+		step := PlanStep{
+			Sequence:          offset + len(steps) + 1,
+			Target:            TargetDescriptor{Cluster: cluster, Namespace: namespace, Resource: affected[0]},
+			AffectedResources: affected,
+			Attachment:        attachment.ID,
+		}
+		if apply {
+			step.Title = "Apply attached manifest " + attachment.Name
+			step.Description = "Apply " + strings.Join(affected, ", ") + " from the attached manifest after a server-side dry run"
+			step.Command = "kubectl apply --server-side -f " + attachment.Name + " --namespace=" + namespace + " --context=" + cluster
+			step.OperationType = OperationTypeMutating
+			step.DryRunAvailable = true
+			step.Risk = RiskAnnotation{
+				Severity:    "medium",
+				Code:        "OPS-MUT-110",
+				Description: "Applying a manifest changes live resources",
+			}
+		} else {
+			step.Title = "Review attached manifest " + attachment.Name
+			step.Description = "Compare " + strings.Join(affected, ", ") + " with the live cluster state to explain what the manifest would change"
+			step.Command = "kubectl diff -f " + attachment.Name + " --namespace=" + namespace + " --context=" + cluster
+			step.OperationType = OperationTypeDiagnostic
+			step.Risk = RiskAnnotation{
+				Severity:    "low",
+				Code:        "OPS-DIAG-010",
+				Description: "Diffing a manifest against the cluster is read-only",
+			}
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+func attachmentIDs(attachments []Attachment) string {
+	ids := make([]string, 0, len(attachments))
+	for _, attachment := range attachments {
+		ids = append(ids, attachment.ID)
+	}
+	return strings.Join(ids, ",")
+}
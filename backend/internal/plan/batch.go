@@ -0,0 +1,103 @@
+package plan
+
+import (
+	"regexp"
+	"strings"
+)
+
+// CommandOperation is the structured command a step carries out, in the form the commands API
+// accepts: as a plan step for changes, or on its own for reads such as top. A command naming no
+// object applies to every object of its kind in the namespace, narrowed by its selector and
+// olderThan parameters. ApplyParameters keeps its namespace, and the selector of a command
+// naming no object, in line with the plan's.
+type CommandOperation struct {
+	Operation  string            `json:"operation"`
+	Resource   CommandResource   `json:"resource"`
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+type CommandResource struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name,omitempty"`
+}
+
+var (
+	batchIntent = regexp.MustCompile(`(?i)\b(restart|delete|remove)\s+(?:all|every)\s+(?:the\s+)?(?:of\s+the\s+)?(deployments?|statefulsets?|daemonsets?|pods?|configmaps?|services?)\b`)
+	batchAge    = regexp.MustCompile(`(?i)\bolder\s+than\s+(\d+)\s*(d|days?|h|hours?)\b`)
+)
+
+// batchKinds maps the plural a prompt names to the kind and the resource kubectl addresses.
+var batchKinds = map[string][2]string{
+	"deployment":  {"Deployment", "deployment"},
+	"statefulset": {"StatefulSet", "statefulset"},
+	"daemonset":   {"DaemonSet", "daemonset"},
+	"pod":         {"Pod", "pod"},
+	"configmap":   {"ConfigMap", "configmap"},
+	"service":     {"Service", "service"},
+}
+
+// batchSteps plans a command over every matching object when the prompt asks for one, such as
+// "restart all deployments older than 7 days". The objects are resolved when the plan is
+// submitted to the commands API, which runs one command per object and stops at the first failure.
+func batchSteps(cluster, namespace, prompt string, offset int) []PlanStep {
+	match := batchIntent.FindStringSubmatch(prompt)
+	if match == nil {
+		return nil
+	}
+	verb := strings.ToLower(match[1])
+	plural := strings.ToLower(match[2])
+	kind := batchKinds[strings.TrimSuffix(plural, "s")]
+	if !strings.HasSuffix(plural, "s") {
+		plural += "s"
+	}
+
+	operation := CommandOperation{Resource: CommandResource{Kind: kind[0], Namespace: namespace}}
+	scope := "every matching " + kind[1]
+	if age := batchAge.FindStringSubmatch(prompt); age != nil {
+		operation.Parameters = map[string]string{"olderThan": age[1] + strings.ToLower(age[2][:1])}
+		scope += " created more than " + age[1] + " " + ageUnit(age[2], age[1]) + " ago"
+	}
+
+	step := PlanStep{
+		Sequence:          offset + 1,
+		OperationType:     OperationTypeMutating,
+		Target:            TargetDescriptor{Cluster: cluster, Namespace: namespace, Resource: plural},
+		AffectedResources: []string{plural},
+		Operation:         &operation,
+	}
+	flags := " --namespace=" + namespace + " --context=" + cluster
+	if verb == "restart" {
+		operation.Operation = "restart"
+		step.Title = "Restart all matching " + plural
+		step.Description = "Restart " + scope + ", one at a time, stopping at the first failure"
+		step.Command = "kubectl rollout restart " + kind[1] + flags
+		step.Risk = RiskAnnotation{
+			Severity:    "medium",
+			Code:        "OPS-MUT-120",
+			Description: "Restarting every matching workload replaces all of their pods",
+		}
+	} else {
+		operation.Operation = "delete"
+		step.Title = "Delete all matching " + plural
+		step.Description = "Delete " + scope + ", one at a time, stopping at the first failure"
+		step.Command = "kubectl delete " + kind[1] + flags
+		step.Risk = RiskAnnotation{
+			Severity:    "high",
+			Code:        "OPS-MUT-130",
+			Description: "Deleting every matching object removes them from the cluster",
+		}
+	}
+	return []PlanStep{step}
+}
+
+func ageUnit(unit, count string) string {
+	word := "days"
+	if strings.HasPrefix(strings.ToLower(unit), "h") {
+		word = "hours"
+	}
+	if count == "1" {
+		word = strings.TrimSuffix(word, "s")
+	}
+	return word
+}
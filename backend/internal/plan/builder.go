@@ -16,6 +16,21 @@ type BuildInput struct {
 	ClusterHint   string
 	NamespaceHint string
 	ScopeSignals  map[string]string
+	// Attachments are files the user attached to the prompt, such as manifests to apply or explain.
+	Attachments []Attachment
+	// History holds the earlier messages of the conversation the prompt continues, oldest first.
+	History []Turn
+	// Conversation is the structured context of that conversation; follow-up prompts fall back
+	// to its cluster, namespace and most recent resources.
+	Conversation *ConversationContext
+}
+
+// Turn is an earlier message of a conversation. Replies carry the target of the plan they described.
+type Turn struct {
+	Role      string
+	Content   string
+	Cluster   string
+	Namespace string
 }
 
 type OperationType string
@@ -48,6 +63,12 @@ type PlanStep struct {
 	AffectedResources []string         `json:"affectedResources,omitempty"`
 	Risk              RiskAnnotation   `json:"risk"`
 	DiffPreview       map[string]any   `json:"diffPreview,omitempty"`
+	SecondOpinion     *RiskReview      `json:"secondOpinion,omitempty"`
+	// Attachment is the chat attachment holding the manifest the step applies or reviews.
+	Attachment string `json:"attachment,omitempty"`
+	// Operation is the command the step carries out, for steps that can be submitted to the
+	// commands API as part of a plan.
+	Operation *CommandOperation `json:"operation,omitempty"`
 }
 
 type PlanDraft struct {
@@ -62,6 +83,11 @@ type PlanDraft struct {
 	GenerationLatency time.Duration     `json:"generationLatency"`
 	RiskSummary       RiskSummary       `json:"riskSummary"`
 	Parameters        Parameters        `json:"parameters"`
+	RequiresApproval  bool              `json:"requiresApproval,omitempty"`
+	// Conversation is the context of the conversation the prompt was resolved against.
+	Conversation *ConversationContext `json:"conversation,omitempty"`
+	// CachedAt is when the plan was first generated, set when it was served from the prompt cache.
+	CachedAt *time.Time `json:"cachedAt,omitempty"`
 }
 
 type RiskSummary struct {
@@ -84,8 +110,9 @@ type Builder interface {
 }
 
 type DefaultBuilder struct {
-	catalog ClusterCatalog
-	clock   func() time.Time
+	catalog   ClusterCatalog
+	resources ResourceCatalog
+	clock     func() time.Time
 }
 
 func NewDefaultBuilder(catalog ClusterCatalog) *DefaultBuilder {
@@ -112,7 +139,18 @@ func (b *DefaultBuilder) BuildPlan(ctx context.Context, input BuildInput) (PlanD
 	cluster := selectCluster(input, clusters)
 	namespace := selectNamespace(input, clusters, cluster)
 	steps := synthesizePlanSteps(cluster, namespace, input.Prompt)
+	target := resolveTarget(input, cluster, namespace)
+	resolveSteps(steps, target)
+	releaseSteps, installed := helmSteps(cluster, namespace, input.Prompt, input.Attachments, len(steps))
+	steps = append(steps, releaseSteps...)
+	steps = append(steps, attachmentSteps(cluster, namespace, input.Prompt, withoutAttachment(input.Attachments, installed), len(steps))...)
+	steps = append(steps, batchSteps(cluster, namespace, input.Prompt, len(steps))...)
+	steps = append(steps, topSteps(cluster, namespace, input.Prompt, len(steps))...)
+	steps = append(steps, b.customResourceSteps(ctx, cluster, namespace, input.Prompt, len(steps))...)
 	scope := mergeScopeSignals(input.ScopeSignals, cluster, namespace, steps)
+	if ids := attachmentIDs(input.Attachments); ids != "" {
+		scope["attachments"] = ids
+	}
 
 	plan := PlanDraft{
 		ID:                uuid.NewString(),
@@ -126,11 +164,18 @@ func (b *DefaultBuilder) BuildPlan(ctx context.Context, input BuildInput) (PlanD
 		GenerationLatency: b.clock().Sub(start),
 		RiskSummary:       summarizeRisk(steps),
 	}
+	if !input.Conversation.Empty() {
+		plan.Conversation = input.Conversation
+	}
 
+	overrides := map[string]int{}
+	if replicas, ok := desiredReplicas(input.Prompt); ok && target != "" {
+		overrides["deployments/"+target] = replicas
+	}
 	ApplyParameters(&plan, Parameters{
 		Namespace:        namespace,
 		Labels:           map[string]string{},
-		ReplicaOverrides: map[string]int{},
+		ReplicaOverrides: overrides,
 	})
 
 	return plan, nil
@@ -177,8 +222,8 @@ func synthesizePlanSteps(cluster, namespace, prompt string) []PlanStep {
 }
 
 var (
-	clusterKeyword   = regexp.MustCompile(`(?i)cluster(?:\s|:|=)*([a-z0-9\-]+)`)
-	namespaceKeyword = regexp.MustCompile(`(?i)namespace(?:\s|:|=)*([a-z0-9\-]+)`)
+	clusterKeyword   = regexp.MustCompile(`(?i)(?:^|\s)cluster(?:\s|:|=)+([a-z0-9\-]+)`)
+	namespaceKeyword = regexp.MustCompile(`(?i)(?:^|\s)namespace(?:\s|:|=)+([a-z0-9\-]+)`)
 )
 
 func annotateSteps(cluster, namespace, prompt string) []PlanStep {
@@ -247,11 +292,6 @@ func annotateSteps(cluster, namespace, prompt string) []PlanStep {
 }
 
 func summarizeRisk(steps []PlanStep) RiskSummary {
-	severityRank := map[string]int{
-		"low":    1,
-		"medium": 2,
-		"high":   3,
-	}
 	highest := "low"
 	var reasons []string
 	for _, step := range steps {
@@ -296,6 +336,10 @@ func selectCluster(input BuildInput, clusters []ClusterMetadata) string {
 		}
 	}
 
+	// A follow-up prompt stays on the cluster the conversation was working on.
+	if conversation := input.Conversation; !conversation.Empty() && conversation.Cluster != "" {
+		return conversation.Cluster
+	}
 	if len(clusters) > 0 {
 		return clusters[0].Name
 	}
@@ -311,6 +355,7 @@ func selectNamespace(input BuildInput, clusters []ClusterMetadata, cluster strin
 		return match[1]
 	}
 
+	var fallback string
 	for _, c := range clusters {
 		if c.Name != cluster {
 			continue
@@ -320,10 +365,16 @@ func selectNamespace(input BuildInput, clusters []ClusterMetadata, cluster strin
 				return ns
 			}
 		}
-		if c.DefaultNamespace != "" {
-			return c.DefaultNamespace
+		if fallback == "" {
+			fallback = c.DefaultNamespace
 		}
 	}
+	if conversation := input.Conversation; !conversation.Empty() && conversation.Cluster == cluster && conversation.Namespace != "" {
+		return conversation.Namespace
+	}
+	if fallback != "" {
+		return fallback
+	}
 	return "default"
 }
 
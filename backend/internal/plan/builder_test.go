@@ -117,3 +117,227 @@ func TestDefaultBuilderAddsCrashloopStep(t *testing.T) {
 		t.Fatalf("expected log capture step in synthesized plan")
 	}
 }
+
+func TestDefaultBuilderPlansBatchCommands(t *testing.T) {
+	builder := NewDefaultBuilder(&staticCatalog{clusters: []ClusterMetadata{{Name: "prod"}}})
+
+	draft, err := builder.BuildPlan(context.Background(), BuildInput{
+		Prompt: "restart all deployments in namespace payments that are older than 7 days",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	step := draft.Steps[len(draft.Steps)-1]
+	if step.Operation == nil || step.Operation.Operation != "restart" || step.Operation.Resource != (CommandResource{Kind: "Deployment", Namespace: "payments"}) {
+		t.Fatalf("expected a restart over every deployment in payments, got %+v", step)
+	}
+	if step.Operation.Parameters["olderThan"] != "7d" || step.Operation.Parameters["selector"] != "" {
+		t.Fatalf("expected only the age to narrow the command, got %+v", step.Operation.Parameters)
+	}
+
+	ApplyParameters(&draft, Parameters{Namespace: "shop", Labels: map[string]string{"tier": "web"}})
+	step = draft.Steps[len(draft.Steps)-1]
+	if step.Operation.Resource.Namespace != "shop" || step.Operation.Parameters["selector"] != "tier=web" {
+		t.Fatalf("expected the command to follow the plan's parameters, got %+v", step.Operation)
+	}
+
+	draft, _ = builder.BuildPlan(context.Background(), BuildInput{Prompt: "restart the api deployment in namespace payments"})
+	for _, step := range draft.Steps {
+		if step.Operation != nil {
+			t.Fatalf("expected no batch command for a single deployment, got %+v", step)
+		}
+	}
+}
+
+func TestDefaultBuilderPlansTopCommands(t *testing.T) {
+	builder := NewDefaultBuilder(&staticCatalog{clusters: []ClusterMetadata{{Name: "prod"}}})
+
+	draft, err := builder.BuildPlan(context.Background(), BuildInput{Prompt: "top 5 memory consumers in namespace default"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	step := draft.Steps[len(draft.Steps)-1]
+	if step.Operation == nil || step.Operation.Operation != "top" || step.Operation.Resource != (CommandResource{Kind: "pods", Namespace: "default"}) {
+		t.Fatalf("expected a top of the pods in default, got %+v", step)
+	}
+	if step.Operation.Parameters["sort-by"] != "memory" || step.Operation.Parameters["limit"] != "5" || step.OperationType != OperationTypeDiagnostic {
+		t.Fatalf("expected the five heaviest memory users, got %+v", step.Operation.Parameters)
+	}
+
+	draft, _ = builder.BuildPlan(context.Background(), BuildInput{Prompt: "which nodes use the most cpu in namespace default?"})
+	step = draft.Steps[len(draft.Steps)-1]
+	if step.Operation == nil || step.Operation.Resource != (CommandResource{Kind: "nodes"}) || step.Command != "kubectl top node --sort-by=cpu --context=prod" {
+		t.Fatalf("expected a top of every node, got %+v", step)
+	}
+}
+
+type staticResources []CustomResource
+
+func (r staticResources) CustomResources(ctx context.Context, cluster string) ([]CustomResource, error) {
+	return r, nil
+}
+
+func TestDefaultBuilderPlansCustomResourceReads(t *testing.T) {
+	builder := NewDefaultBuilder(&staticCatalog{clusters: []ClusterMetadata{{Name: "prod", DefaultNamespace: "streaming"}}})
+	builder.SetResourceCatalog(staticResources{
+		{Group: "kafka.strimzi.io", Kind: "Kafka", Plural: "kafkas", Singular: "kafka", ShortNames: []string{"k"}, Namespaced: true,
+			Columns: []string{"Desired Kafka replicas", "Ready"}, Fields: []string{"spec.kafka", "spec.kafka.replicas", "spec.kafka.version", "spec.zookeeper.replicas"}},
+		{Group: "cluster.x-k8s.io", Kind: "Machine", Plural: "machines", Singular: "machine"},
+	})
+
+	draft, err := builder.BuildPlan(context.Background(), BuildInput{Prompt: "list my kafka clusters"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	step := draft.Steps[len(draft.Steps)-1]
+	if draft.TargetCluster != "prod" || step.Operation == nil || step.Operation.Operation != "list" ||
+		step.Operation.Resource != (CommandResource{Kind: "kafkas.kafka.strimzi.io", Namespace: "streaming"}) {
+		t.Fatalf("expected a list of the Kafka clusters in streaming, got %+v", step)
+	}
+	if step.Command != "kubectl get kafkas.kafka.strimzi.io --namespace=streaming --context=prod" || !strings.Contains(step.Description, "Desired Kafka replicas, Ready") {
+		t.Fatalf("unexpected step %q: %q", step.Command, step.Description)
+	}
+
+	draft, _ = builder.BuildPlan(context.Background(), BuildInput{Prompt: "show kafka my-cluster version in namespace streaming"})
+	step = draft.Steps[len(draft.Steps)-1]
+	if step.Operation.Operation != "get" || step.Operation.Resource.Name != "my-cluster" ||
+		step.Command != "kubectl get kafkas.kafka.strimzi.io my-cluster -o jsonpath='{.spec.kafka.version}' --namespace=streaming --context=prod" {
+		t.Fatalf("expected a read of the Kafka version, got %+v", step)
+	}
+
+	draft, _ = builder.BuildPlan(context.Background(), BuildInput{Prompt: "show all machines"})
+	step = draft.Steps[len(draft.Steps)-1]
+	if step.Operation.Resource != (CommandResource{Kind: "machines.cluster.x-k8s.io"}) || strings.Contains(step.Command, "--namespace") {
+		t.Fatalf("expected a cluster-scoped list of machines, got %+v", step)
+	}
+
+	draft, _ = builder.BuildPlan(context.Background(), BuildInput{Prompt: "list pods"})
+	if step := draft.Steps[len(draft.Steps)-1]; step.Operation != nil {
+		t.Fatalf("expected no custom resource step, got %+v", step)
+	}
+}
+
+func TestDefaultBuilderPlansAttachedManifests(t *testing.T) {
+	builder := NewDefaultBuilder(&staticCatalog{clusters: []ClusterMetadata{{Name: "prod", DefaultNamespace: "shop"}}})
+	attachments := []Attachment{
+		{ID: "a1", Name: "app.yaml", Manifest: true, Objects: []string{"Deployment/api"}},
+		{ID: "a2", Name: "notes.txt"},
+	}
+
+	draft, err := builder.BuildPlan(context.Background(), BuildInput{Prompt: "apply this", Attachments: attachments})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	last := draft.Steps[len(draft.Steps)-1]
+	if last.Attachment != "a1" || last.OperationType != OperationTypeMutating || last.Sequence != len(draft.Steps) {
+		t.Fatalf("expected an apply step for the manifest, got %+v", last)
+	}
+	if !strings.HasPrefix(last.Command, "kubectl apply --server-side -f app.yaml --namespace=shop") {
+		t.Fatalf("unexpected command %q", last.Command)
+	}
+	if draft.ScopeSignals["attachments"] != "a1,a2" {
+		t.Fatalf("expected attachment IDs in the scope signals, got %q", draft.ScopeSignals["attachments"])
+	}
+
+	draft, err = builder.BuildPlan(context.Background(), BuildInput{Prompt: "explain this manifest", Attachments: attachments})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if last := draft.Steps[len(draft.Steps)-1]; last.OperationType != OperationTypeDiagnostic || !strings.HasPrefix(last.Command, "kubectl diff") {
+		t.Fatalf("expected a read-only review step, got %+v", last)
+	}
+}
+
+func TestDefaultBuilderPlansHelmReleaseOperations(t *testing.T) {
+	builder := NewDefaultBuilder(&staticCatalog{clusters: []ClusterMetadata{{Name: "prod", DefaultNamespace: "shop"}}})
+
+	draft, err := builder.BuildPlan(context.Background(), BuildInput{Prompt: "roll back release web to revision 3"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	last := draft.Steps[len(draft.Steps)-1]
+	if last.Operation == nil || last.Operation.Operation != "helm_rollback" || last.Operation.Resource != (CommandResource{Kind: "HelmRelease", Namespace: "shop", Name: "web"}) {
+		t.Fatalf("expected a rollback of release web, got %+v", last)
+	}
+	if last.Operation.Parameters["revision"] != "3" || last.Command != "helm rollback web 3 --namespace=shop --kube-context=prod" {
+		t.Fatalf("unexpected rollback step %+v", last)
+	}
+	ApplyParameters(&draft, Parameters{Namespace: "shop", Labels: map[string]string{"tier": "web"}})
+	if selector := draft.Steps[len(draft.Steps)-1].Operation.Parameters["selector"]; selector != "" {
+		t.Fatalf("expected a release step not to be narrowed by a selector, got %q", selector)
+	}
+
+	attachments := []Attachment{{ID: "a1", Name: "rendered.yaml", Manifest: true, Objects: []string{"Deployment/web"}}}
+	draft, err = builder.BuildPlan(context.Background(), BuildInput{Prompt: "deploy release web with this chart", Attachments: attachments})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	last = draft.Steps[len(draft.Steps)-1]
+	if last.Operation == nil || last.Operation.Operation != "helm_upgrade" || last.Operation.Parameters["attachment"] != "a1" {
+		t.Fatalf("expected the attachment to be installed as an upgrade of release web, got %+v", last)
+	}
+	for _, step := range draft.Steps {
+		if strings.HasPrefix(step.Command, "kubectl apply --server-side -f") {
+			t.Fatalf("expected the attachment not to be applied outside the release, got %+v", step)
+		}
+	}
+}
+
+func TestDefaultBuilderFollowsConversationTarget(t *testing.T) {
+	catalog := &staticCatalog{clusters: []ClusterMetadata{{Name: "prod", DefaultNamespace: "default"}, {Name: "staging", DefaultNamespace: "default"}}}
+	builder := NewDefaultBuilder(catalog)
+	conversation := &ConversationContext{Cluster: "staging", Namespace: "payments"}
+
+	draft, err := builder.BuildPlan(context.Background(), BuildInput{Prompt: "now restart the worker", Conversation: conversation})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if draft.TargetCluster != "staging" || draft.TargetNamespace != "payments" {
+		t.Fatalf("expected the follow-up to stay on staging/payments, got %s/%s", draft.TargetCluster, draft.TargetNamespace)
+	}
+
+	draft, err = builder.BuildPlan(context.Background(), BuildInput{Prompt: "list pods in prod", Conversation: conversation})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if draft.TargetCluster != "prod" || draft.TargetNamespace != "default" {
+		t.Fatalf("expected a prompt naming another cluster to leave the conversation target, got %s/%s", draft.TargetCluster, draft.TargetNamespace)
+	}
+}
+
+func TestDefaultBuilderResolvesFollowUpAgainstConversation(t *testing.T) {
+	catalog := &staticCatalog{clusters: []ClusterMetadata{{Name: "prod", DefaultNamespace: "default"}}}
+	builder := NewDefaultBuilder(catalog)
+	conversation := &ConversationContext{Cluster: "prod", Namespace: "shop", Resources: []string{"deployments/api", "deployments/worker"}}
+
+	draft, err := builder.BuildPlan(context.Background(), BuildInput{Prompt: "now scale it to 3", Conversation: conversation})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	scale := draft.Steps[1]
+	if scale.Command != "kubectl scale deploy/api --replicas=3 --dry-run=client --namespace=shop --context=prod" {
+		t.Fatalf("expected the follow-up to scale the last deployment, got %q", scale.Command)
+	}
+	if scale.Target.Resource != "deployments/api" || draft.Conversation != conversation {
+		t.Fatalf("expected the plan to record the resolved target and context, got %+v", draft)
+	}
+	if got := draft.ReferencedResources(); len(got) != 1 || got[0] != "deployments/api" {
+		t.Fatalf("unexpected referenced resources %v", got)
+	}
+
+	draft, err = builder.BuildPlan(context.Background(), BuildInput{Prompt: "scale the checkout deployment to 2", Conversation: conversation})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if draft.Steps[1].Target.Resource != "deployments/checkout" {
+		t.Fatalf("expected a named deployment to win over the conversation, got %q", draft.Steps[1].Target.Resource)
+	}
+
+	draft, err = builder.BuildPlan(context.Background(), BuildInput{Prompt: "now scale it to 3 in namespace other", Conversation: conversation})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if draft.Steps[1].Target.Resource != "deployments/<target>" {
+		t.Fatalf("expected no carry-over into another namespace, got %q", draft.Steps[1].Target.Resource)
+	}
+}
@@ -0,0 +1,125 @@
+package plan
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ConversationContext is what the earlier turns of a conversation established, so follow-up
+// prompts such as "now scale it to 3" resolve against them.
+type ConversationContext struct {
+	Cluster   string `json:"cluster,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	// Resources are the resources recently referenced, as kind/name, most recent first.
+	Resources []string `json:"resources,omitempty"`
+}
+
+// Empty reports whether the context carries nothing to resolve a prompt against.
+func (c *ConversationContext) Empty() bool {
+	return c == nil || (c.Cluster == "" && c.Namespace == "" && len(c.Resources) == 0)
+}
+
+// String renders the context as a single line for model prompts.
+func (c *ConversationContext) String() string {
+	if c.Empty() {
+		return ""
+	}
+	parts := make([]string, 0, 3)
+	if c.Cluster != "" {
+		parts = append(parts, "cluster "+c.Cluster)
+	}
+	if c.Namespace != "" {
+		parts = append(parts, "namespace "+c.Namespace)
+	}
+	if len(c.Resources) > 0 {
+		parts = append(parts, "recent resources "+strings.Join(c.Resources, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// ReferencedResources returns the concrete resources the plan's steps target, as kind/name, in
+// step order. Unresolved placeholders and whole collections such as "pods" are left out.
+func (p PlanDraft) ReferencedResources() []string {
+	var resources []string
+	seen := make(map[string]struct{})
+	for _, step := range p.Steps {
+		for _, resource := range append([]string{step.Target.Resource}, step.AffectedResources...) {
+			if !strings.Contains(resource, "/") || strings.Contains(resource, "<") {
+				continue
+			}
+			if _, ok := seen[resource]; ok {
+				continue
+			}
+			seen[resource] = struct{}{}
+			resources = append(resources, resource)
+		}
+	}
+	return resources
+}
+
+var (
+	// deploymentReferences match "deploy/api" or "deployment api", then "the api deployment".
+	deploymentReferences = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\bdeploy(?:ment)?s?(?:/|\s+)([a-z0-9][a-z0-9.\-]*)`),
+		regexp.MustCompile(`(?i)\b(?:the|my|our)\s+([a-z0-9][a-z0-9.\-]*)\s+deploy(?:ment)?\b`),
+	}
+	replicaCount = regexp.MustCompile(`(?i)\b(?:to|at)\s+(\d+)(?:\s+replicas?)?\b|\b(\d+)\s+replicas?\b`)
+)
+
+// notNames are words that surround a workload reference without naming it.
+var notNames = map[string]struct{}{
+	"a": {}, "an": {}, "the": {}, "this": {}, "that": {}, "it": {}, "its": {}, "all": {}, "same": {},
+	"in": {}, "on": {}, "of": {}, "for": {}, "to": {}, "from": {}, "with": {}, "named": {}, "called": {}, "and": {},
+}
+
+// resolveTarget returns the deployment a prompt is about: the one it names, or otherwise the most
+// recent deployment of the conversation when it stays on the same cluster and namespace.
+func resolveTarget(input BuildInput, cluster, namespace string) string {
+	for _, reference := range deploymentReferences {
+		for _, match := range reference.FindAllStringSubmatch(input.Prompt, -1) {
+			name := strings.ToLower(match[1])
+			if _, skip := notNames[name]; !skip {
+				return name
+			}
+		}
+	}
+	conversation := input.Conversation
+	if conversation.Empty() || conversation.Cluster != cluster || (conversation.Namespace != "" && conversation.Namespace != namespace) {
+		return ""
+	}
+	for _, resource := range conversation.Resources {
+		if kind, name, ok := strings.Cut(resource, "/"); ok && kind == "deployments" {
+			return name
+		}
+	}
+	return ""
+}
+
+// desiredReplicas returns the replica count a prompt asks for, such as "to 3" or "5 replicas".
+func desiredReplicas(prompt string) (int, bool) {
+	match := replicaCount.FindStringSubmatch(prompt)
+	if match == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(match[1] + match[2])
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// resolveSteps substitutes the target deployment for the placeholder in the steps.
+func resolveSteps(steps []PlanStep, target string) {
+	if target == "" {
+		return
+	}
+	for i := range steps {
+		step := &steps[i]
+		step.Command = strings.ReplaceAll(step.Command, "<target>", target)
+		step.Target.Resource = strings.ReplaceAll(step.Target.Resource, "<target>", target)
+		for j, resource := range step.AffectedResources {
+			step.AffectedResources[j] = strings.ReplaceAll(resource, "<target>", target)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package plan
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// CustomResource is a custom resource a cluster serves. Columns are the names of its printer
+// columns and Fields the paths of its spec fields, such as spec.kafka.replicas.
+type CustomResource struct {
+	Group      string
+	Kind       string
+	Plural     string
+	Singular   string
+	ShortNames []string
+	Namespaced bool
+	Columns    []string
+	Fields     []string
+}
+
+// Resource is the resource.group kubectl accepts for r.
+func (r CustomResource) Resource() string {
+	return r.Plural + "." + r.Group
+}
+
+// ResourceCatalog lists the custom resources a cluster serves.
+type ResourceCatalog interface {
+	CustomResources(ctx context.Context, cluster string) ([]CustomResource, error)
+}
+
+// SetResourceCatalog lets plans read the custom resources installed in a cluster, such as an
+// operator's Kafka clusters, when the prompt names them.
+func (b *DefaultBuilder) SetResourceCatalog(resources ResourceCatalog) {
+	b.resources = resources
+}
+
+var (
+	customReadIntent = regexp.MustCompile(`(?i)\b(list|show|get|describe|display|find|what|which)\b`)
+	promptWord       = regexp.MustCompile(`[a-z0-9][a-z0-9.-]*[a-z0-9]|[a-z0-9]`)
+)
+
+// genericNouns follow a resource's name without naming an object, as in "kafka clusters".
+var genericNouns = map[string]bool{
+	"cluster": true, "clusters": true, "instance": true, "instances": true, "resource": true,
+	"resources": true, "object": true, "objects": true, "cr": true, "crs": true,
+}
+
+// notAName are words that follow a resource's name without naming an object of it.
+var notAName = map[string]bool{
+	"in": true, "on": true, "for": true, "with": true, "across": true, "from": true, "that": true,
+	"which": true, "and": true, "are": true, "is": true, "to": true, "of": true, "the": true,
+	"namespace": true, "please": true, "status": true, "running": true, "now": true,
+}
+
+// builtinNames are core resource names a custom resource's short name must not shadow.
+var builtinNames = map[string]bool{
+	"po": true, "pod": true, "pods": true, "svc": true, "service": true, "services": true,
+	"deploy": true, "deployment": true, "deployments": true, "node": true, "nodes": true,
+	"ns": true, "namespace": true, "namespaces": true, "cm": true, "configmap": true,
+	"configmaps": true, "secret": true, "secrets": true, "job": true, "jobs": true,
+	"event": true, "events": true, "ing": true, "ingress": true, "ingresses": true,
+}
+
+// customResourceSteps plans a read of the custom resource the prompt names by its plural,
+// singular, kind or short name, such as "list my kafka clusters" or "show kafka my-cluster". The
+// longest name wins, so "kafka cluster" prefers a KafkaCluster kind over a Kafka one. When the
+// prompt also names a field of the resource's schema, such as replicas, the command reads that
+// field. Prompts are planned without custom resources when the catalog cannot be read.
+func (b *DefaultBuilder) customResourceSteps(ctx context.Context, cluster, namespace, prompt string, offset int) []PlanStep {
+	if b.resources == nil || !customReadIntent.MatchString(prompt) {
+		return nil
+	}
+	resources, err := b.resources.CustomResources(ctx, cluster)
+	if err != nil || len(resources) == 0 {
+		return nil
+	}
+	words := promptWord.FindAllString(strings.ToLower(prompt), -1)
+
+	var (
+		matched    []CustomResource
+		longest    []string
+		start, end int
+	)
+	for _, resource := range resources {
+		for _, alias := range aliases(resource) {
+			at := findWords(words, alias)
+			if at < 0 {
+				continue
+			}
+			switch {
+			case len(strings.Join(alias, " ")) > len(strings.Join(longest, " ")):
+				matched, longest, start, end = []CustomResource{resource}, alias, at, at+len(alias)
+			case strings.Join(alias, " ") == strings.Join(longest, " ") && matched[len(matched)-1].Resource() != resource.Resource():
+				matched = append(matched, resource)
+			}
+		}
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+
+	verb := strings.ToLower(customReadIntent.FindString(prompt))
+	name := objectName(words[end:], verb)
+	// The resource's and object's names are not fields the prompt asks for.
+	var rest []string
+	for i, word := range words {
+		if (i < start || i >= end) && word != name && !genericNouns[word] {
+			rest = append(rest, word)
+		}
+	}
+	var steps []PlanStep
+	for _, resource := range matched {
+		steps = append(steps, customResourceStep(cluster, namespace, resource, verb, name, schemaField(resource, rest), offset+len(steps)))
+	}
+	return steps
+}
+
+func customResourceStep(cluster, namespace string, resource CustomResource, verb, name, field string, offset int) PlanStep {
+	operation := CommandOperation{Operation: "list", Resource: CommandResource{Kind: resource.Resource()}}
+	command := "kubectl get " + resource.Resource()
+	title := "List " + resource.Kind + " objects"
+	description := "List the " + resource.Kind + " objects"
+	switch {
+	case name != "" && verb == "describe":
+		operation.Operation = "describe"
+		command = "kubectl describe " + resource.Resource() + " " + name
+		title = "Describe " + resource.Kind + " " + name
+		description = "Describe " + resource.Kind + " " + name + " with its recent events"
+	case name != "":
+		operation.Operation = "get"
+		command += " " + name
+		title = "Show " + resource.Kind + " " + name
+		description = "Read " + resource.Kind + " " + name
+		if field != "" {
+			command += " -o jsonpath='{." + field + "}'"
+			description = "Read " + field + " of " + resource.Kind + " " + name
+		}
+	case field != "":
+		command += " -o custom-columns=NAME:.metadata.name," + strings.ToUpper(field[strings.LastIndex(field, ".")+1:]) + ":." + field
+		description += " with their " + field
+	case len(resource.Columns) > 0:
+		description += " with their " + strings.Join(resource.Columns, ", ") + " columns"
+	}
+	operation.Resource.Name = name
+	if resource.Namespaced {
+		operation.Resource.Namespace = namespace
+		command += " --namespace=" + namespace
+	}
+	return PlanStep{
+		Sequence:          offset + 1,
+		Title:             title,
+		Description:       description,
+		Command:           command + " --context=" + cluster,
+		OperationType:     OperationTypeDiagnostic,
+		Target:            TargetDescriptor{Cluster: cluster, Namespace: namespace, Resource: resource.Resource()},
+		AffectedResources: []string{resource.Resource()},
+		Operation:         &operation,
+		Risk: RiskAnnotation{
+			Severity:    "low",
+			Code:        "OPS-DIAG-040",
+			Description: "Reading custom resources is read-only",
+		},
+	}
+}
+
+// aliases are the names a prompt may use for resource, each split into words. A kind such as
+// KafkaCluster is also matched as "kafka cluster" and "kafka clusters".
+func aliases(resource CustomResource) [][]string {
+	names := []string{resource.Plural, resource.Singular, strings.ToLower(resource.Kind)}
+	names = append(names, resource.ShortNames...)
+	if spaced := splitCamel(resource.Kind); strings.Contains(spaced, " ") {
+		names = append(names, spaced, spaced+"s")
+	}
+	var result [][]string
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if name == "" || builtinNames[name] {
+			continue
+		}
+		result = append(result, strings.Fields(name))
+	}
+	return result
+}
+
+func splitCamel(kind string) string {
+	var b strings.Builder
+	for i, r := range kind {
+		if i > 0 && unicode.IsUpper(r) && !unicode.IsUpper(rune(kind[i-1])) {
+			b.WriteByte(' ')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// findWords returns where alias first occurs in words, or -1.
+func findWords(words, alias []string) int {
+	for i := 0; i+len(alias) <= len(words); i++ {
+		found := true
+		for j, word := range alias {
+			if words[i+j] != word {
+				found = false
+				break
+			}
+		}
+		if found {
+			return i
+		}
+	}
+	return -1
+}
+
+// objectName is the object named by the words following a resource's name, as in "show kafka
+// my-cluster" or "describe kafka named events". Lists name no object.
+func objectName(words []string, verb string) string {
+	if len(words) > 0 && genericNouns[words[0]] {
+		words = words[1:]
+	}
+	if len(words) > 1 && (words[0] == "named" || words[0] == "called") {
+		return words[1]
+	}
+	if verb == "list" || len(words) == 0 || notAName[words[0]] {
+		return ""
+	}
+	return words[0]
+}
+
+// schemaField is the spec field of resource the prompt names by its last segment, such as
+// replicas for spec.replicas. The shallowest field wins; none is returned when several fields are
+// equally shallow, since the prompt does not say which it means.
+func schemaField(resource CustomResource, words []string) string {
+	named := map[string]bool{}
+	for _, word := range words {
+		named[word] = true
+	}
+	var found []string
+	for _, field := range resource.Fields {
+		if !named[strings.ToLower(field[strings.LastIndex(field, ".")+1:])] {
+			continue
+		}
+		if len(found) > 0 && strings.Count(field, ".") > strings.Count(found[0], ".") {
+			continue
+		}
+		if len(found) > 0 && strings.Count(field, ".") < strings.Count(found[0], ".") {
+			found = nil
+		}
+		found = append(found, field)
+	}
+	if len(found) != 1 {
+		return ""
+	}
+	return found[0]
+}
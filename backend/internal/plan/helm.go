@@ -0,0 +1,131 @@
+package plan
+
+import "regexp"
+
+var (
+	helmRollbackIntent = regexp.MustCompile(`(?i)\broll\s*back\s+(?:the\s+)?(?:helm\s+)?release\s+([a-z0-9](?:[a-z0-9.-]*[a-z0-9])?)(?:\s+to\s+revision\s+(\d+))?`)
+	helmUpgradeIntent  = regexp.MustCompile(`(?i)\b(?:upgrade|deploy)\s+(?:the\s+)?(?:helm\s+)?release\s+([a-z0-9](?:[a-z0-9.-]*[a-z0-9])?)`)
+	helmListIntent     = regexp.MustCompile(`(?i)\b(?:list|show)\s+(?:all\s+|the\s+)?helm\s+releases\b`)
+)
+
+// helmSteps plans Helm operations the prompt asks for: listing releases, rolling a release back,
+// or upgrading it to a manifest rendered with helm template and attached to the prompt. Upgrades
+// and rollbacks run through the commands API like other steps, where their diff can be previewed.
+// It returns the ID of the attachment an upgrade installs, which is then not planned as an apply.
+func helmSteps(cluster, namespace, prompt string, attachments []Attachment, offset int) ([]PlanStep, string) {
+	flags := " --namespace=" + namespace + " --kube-context=" + cluster
+	var (
+		steps    []PlanStep
+		attached string
+	)
+	if helmListIntent.MatchString(prompt) {
+		steps = append(steps, PlanStep{
+			Sequence:          offset + 1,
+			Title:             "List Helm releases",
+			Description:       "List the releases installed in the namespace with their chart, revision and status",
+			Command:           "helm list" + flags,
+			OperationType:     OperationTypeDiagnostic,
+			Target:            TargetDescriptor{Cluster: cluster, Namespace: namespace, Resource: "helmreleases"},
+			AffectedResources: []string{"helmreleases"},
+			Risk: RiskAnnotation{
+				Severity:    "low",
+				Code:        "OPS-DIAG-020",
+				Description: "Listing releases is read-only",
+			},
+		})
+	}
+
+	if match := helmRollbackIntent.FindStringSubmatch(prompt); match != nil {
+		release, revision := match[1], match[2]
+		operation := CommandOperation{
+			Operation: "helm_rollback",
+			Resource:  CommandResource{Kind: "HelmRelease", Namespace: namespace, Name: release},
+		}
+		title, command := "Roll back Helm release "+release, "helm rollback "+release
+		if revision != "" {
+			operation.Parameters = map[string]string{"revision": revision}
+			title += " to revision " + revision
+			command += " " + revision
+		}
+		steps = append(steps, PlanStep{
+			Sequence:          offset + len(steps) + 1,
+			Title:             title,
+			Description:       "Reinstall the earlier revision's objects and record the rollback as a new revision of the release",
+			Command:           command + flags,
+			OperationType:     OperationTypeMutating,
+			Target:            TargetDescriptor{Cluster: cluster, Namespace: namespace, Resource: "helmreleases/" + release},
+			AffectedResources: []string{"helmreleases/" + release},
+			Operation:         &operation,
+			Risk: RiskAnnotation{
+				Severity:    "medium",
+				Code:        "OPS-MUT-140",
+				Description: "Rolling back a release replaces its objects with those of an earlier revision",
+			},
+		})
+	}
+
+	if match := helmUpgradeIntent.FindStringSubmatch(prompt); match != nil {
+		release := match[1]
+		target := TargetDescriptor{Cluster: cluster, Namespace: namespace, Resource: "helmreleases/" + release}
+		var manifest *Attachment
+		for i := range attachments {
+			if attachments[i].Manifest {
+				manifest = &attachments[i]
+				break
+			}
+		}
+		if manifest == nil {
+			steps = append(steps, PlanStep{
+				Sequence:          offset + len(steps) + 1,
+				Title:             "Review Helm release " + release,
+				Description:       "Show the release's revisions; attach the chart rendered with helm template to plan the upgrade",
+				Command:           "helm history " + release + flags,
+				OperationType:     OperationTypeDiagnostic,
+				Target:            target,
+				AffectedResources: []string{"helmreleases/" + release},
+				Risk: RiskAnnotation{
+					Severity:    "low",
+					Code:        "OPS-DIAG-021",
+					Description: "Reading release history is read-only",
+				},
+			})
+			return steps, ""
+		}
+		attached = manifest.ID
+		steps = append(steps, PlanStep{
+			Sequence:          offset + len(steps) + 1,
+			Title:             "Upgrade Helm release " + release,
+			Description:       "Install the rendered chart in " + manifest.Name + " as a new revision of the release, deleting objects it no longer has",
+			Command:           "kubectl apply --server-side --force-conflicts -f " + manifest.Name + " --namespace=" + namespace + " --context=" + cluster,
+			OperationType:     OperationTypeMutating,
+			Target:            target,
+			AffectedResources: append([]string{"helmreleases/" + release}, manifest.Objects...),
+			Attachment:        manifest.ID,
+			Operation: &CommandOperation{
+				Operation:  "helm_upgrade",
+				Resource:   CommandResource{Kind: "HelmRelease", Namespace: namespace, Name: release},
+				Parameters: map[string]string{"attachment": manifest.ID},
+			},
+			Risk: RiskAnnotation{
+				Severity:    "medium",
+				Code:        "OPS-MUT-150",
+				Description: "Upgrading a release changes its live objects and removes those the new chart drops",
+			},
+		})
+	}
+	return steps, attached
+}
+
+// withoutAttachment returns attachments less the one with ID id.
+func withoutAttachment(attachments []Attachment, id string) []Attachment {
+	if id == "" {
+		return attachments
+	}
+	rest := make([]Attachment, 0, len(attachments))
+	for _, attachment := range attachments {
+		if attachment.ID != id {
+			rest = append(rest, attachment)
+		}
+	}
+	return rest
+}
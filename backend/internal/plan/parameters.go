@@ -64,8 +64,26 @@ func ApplyParameters(draft *PlanDraft, params Parameters) {
 	for i := range draft.Steps {
 		step := &draft.Steps[i]
 		step.Target.Namespace = draft.TargetNamespace
-		step.Command = rewriteFlag(step.Command, namespaceFlagPatterns, draft.TargetNamespace)
+		// Operations on cluster-scoped resources, such as a top of nodes, keep naming no namespace.
+		clusterScoped := step.Operation != nil && step.Operation.Resource.Namespace == ""
+		if !clusterScoped {
+			step.Command = rewriteFlag(step.Command, namespaceFlagPatterns, draft.TargetNamespace)
+		}
 		step.Command = rewriteSelectorFlag(step.Command, labelSelector)
+		if operation := step.Operation; operation != nil {
+			if !clusterScoped {
+				operation.Resource.Namespace = draft.TargetNamespace
+			}
+			// Only commands naming no object are narrowed by a selector.
+			if labelSelector != "" && operation.Resource.Name == "" {
+				if operation.Parameters == nil {
+					operation.Parameters = map[string]string{}
+				}
+				operation.Parameters["selector"] = labelSelector
+			} else {
+				delete(operation.Parameters, "selector")
+			}
+		}
 
 		resourceKey := step.Target.Resource
 		if override, ok := normalized.ReplicaOverrides[resourceKey]; ok {
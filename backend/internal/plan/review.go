@@ -0,0 +1,109 @@
+package plan
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// RiskReview records an independent classification of a step by a second reviewer.
+type RiskReview struct {
+	Reviewer string `json:"reviewer"`
+	Severity string `json:"severity,omitempty"`
+	Agreed   bool   `json:"agreed"`
+	Error    string `json:"error,omitempty"`
+}
+
+// RiskReviewer independently classifies the risk of a single plan step.
+type RiskReviewer interface {
+	Name() string
+	ReviewStep(ctx context.Context, draft PlanDraft, step PlanStep) (string, error)
+}
+
+// ReviewingBuilder wraps a Builder and asks a second reviewer to classify every
+// generated step; disagreements and steps the reviewer fails on escalate the step
+// and require approval.
+type ReviewingBuilder struct {
+	inner    Builder
+	reviewer RiskReviewer
+}
+
+func NewReviewingBuilder(inner Builder, reviewer RiskReviewer) *ReviewingBuilder {
+	return &ReviewingBuilder{inner: inner, reviewer: reviewer}
+}
+
+func (b *ReviewingBuilder) BuildPlan(ctx context.Context, input BuildInput) (PlanDraft, error) {
+	draft, err := b.inner.BuildPlan(ctx, input)
+	if err != nil || b.reviewer == nil {
+		return draft, err
+	}
+
+	var escalations []string
+	for i := range draft.Steps {
+		step := &draft.Steps[i]
+		review := RiskReview{Reviewer: b.reviewer.Name()}
+
+		severity, err := b.reviewer.ReviewStep(ctx, draft, *step)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return PlanDraft{}, ctxErr
+			}
+			// A step nobody could double-check is treated like a disputed one rather than trusted.
+			primary := normalizeSeverity(step.Risk.Severity)
+			review.Error = err.Error()
+			step.SecondOpinion = &review
+			escalated := higherSeverity(primary, "medium")
+			step.Risk.Severity = escalated
+			escalations = append(escalations, fmt.Sprintf("Step %d could not be reviewed by %s (%v); escalated to %s and requires approval", step.Sequence, review.Reviewer, err, escalated))
+			continue
+		}
+
+		primary := normalizeSeverity(step.Risk.Severity)
+		review.Severity = normalizeSeverity(severity)
+		review.Agreed = review.Severity == primary
+		step.SecondOpinion = &review
+
+		if review.Agreed {
+			continue
+		}
+
+		escalated := higherSeverity(higherSeverity(primary, review.Severity), "medium")
+		step.Risk.Severity = escalated
+		escalations = append(escalations, fmt.Sprintf("Step %d classified %s by primary and %s by %s; escalated to %s and requires approval", step.Sequence, primary, review.Severity, review.Reviewer, escalated))
+	}
+
+	if len(escalations) == 0 {
+		return draft, nil
+	}
+
+	draft.RequiresApproval = true
+	draft.RiskSummary = summarizeRisk(draft.Steps)
+	draft.RiskSummary.Justifications = append(draft.RiskSummary.Justifications, escalations...)
+	if draft.ScopeSignals == nil {
+		draft.ScopeSignals = map[string]string{}
+	}
+	draft.ScopeSignals["risk_level"] = draft.RiskSummary.Level
+	draft.ScopeSignals["second_opinion"] = "disagreement"
+	return draft, nil
+}
+
+var severityRank = map[string]int{
+	"low":    1,
+	"medium": 2,
+	"high":   3,
+}
+
+func normalizeSeverity(severity string) string {
+	s := strings.ToLower(strings.TrimSpace(severity))
+	if _, ok := severityRank[s]; ok {
+		return s
+	}
+	return "high"
+}
+
+func higherSeverity(a, b string) string {
+	if severityRank[b] > severityRank[a] {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,67 @@
+package plan
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type stubReviewer struct {
+	verdicts map[int]string
+	err      error
+}
+
+func (s *stubReviewer) Name() string { return "stub" }
+
+func (s *stubReviewer) ReviewStep(ctx context.Context, draft PlanDraft, step PlanStep) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	return s.verdicts[step.Sequence], nil
+}
+
+func TestReviewingBuilderEscalatesDisagreement(t *testing.T) {
+	catalog := &staticCatalog{clusters: []ClusterMetadata{{Name: "prod", DefaultNamespace: "payments"}}}
+	reviewer := &stubReviewer{verdicts: map[int]string{1: "high", 2: "medium"}}
+	builder := NewReviewingBuilder(NewDefaultBuilder(catalog), reviewer)
+
+	draft, err := builder.BuildPlan(context.Background(), BuildInput{Prompt: "scale prod"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !draft.RequiresApproval {
+		t.Fatal("expected disagreement to require approval")
+	}
+	first := draft.Steps[0]
+	if first.SecondOpinion == nil || first.SecondOpinion.Agreed {
+		t.Fatalf("expected recorded disagreement, got %+v", first.SecondOpinion)
+	}
+	if first.Risk.Severity != "high" {
+		t.Fatalf("expected step escalated to high, got %s", first.Risk.Severity)
+	}
+	if !draft.Steps[1].SecondOpinion.Agreed {
+		t.Fatalf("expected agreement on second step, got %+v", draft.Steps[1].SecondOpinion)
+	}
+	if draft.RiskSummary.Level != "high" || draft.ScopeSignals["risk_level"] != "high" {
+		t.Fatalf("expected risk summary to follow escalation, got %+v", draft.RiskSummary)
+	}
+}
+
+func TestReviewingBuilderEscalatesWhenReviewerFails(t *testing.T) {
+	catalog := &staticCatalog{clusters: []ClusterMetadata{{Name: "prod"}}}
+	builder := NewReviewingBuilder(NewDefaultBuilder(catalog), &stubReviewer{err: errors.New("model offline")})
+
+	draft, err := builder.BuildPlan(context.Background(), BuildInput{Prompt: "scale prod"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !draft.RequiresApproval {
+		t.Fatal("expected a step the reviewer could not classify to require approval")
+	}
+	if severityRank[draft.Steps[0].Risk.Severity] < severityRank["medium"] {
+		t.Fatalf("expected unreviewed step escalated to at least medium, got %s", draft.Steps[0].Risk.Severity)
+	}
+	if draft.Steps[0].SecondOpinion == nil || draft.Steps[0].SecondOpinion.Error == "" {
+		t.Fatalf("expected reviewer error to be recorded, got %+v", draft.Steps[0].SecondOpinion)
+	}
+}
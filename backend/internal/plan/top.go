@@ -0,0 +1,66 @@
+package plan
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	topIntent   = regexp.MustCompile(`(?i)\btop\s+(?:(\d+)\s+)?(?:(cpu|memory)\s+)?(pods?|nodes?|consumers?|users?)\b`)
+	mostIntent  = regexp.MustCompile(`(?i)\b(?:which|what)\s+(pods?|nodes?)\s+(?:use|uses|are\s+using|consume)\s+(?:the\s+)?most\s+(cpu|memory)\b`)
+	topResource = regexp.MustCompile(`(?i)\b(cpu|memory)\b`)
+)
+
+// topSteps plans a read of metrics-server usage when the prompt asks which pods or nodes use the
+// most CPU or memory, such as "top 5 memory consumers in default". The step runs through the
+// commands API as a top command, sorted by the resource the prompt names and limited to the
+// count it gives, so it works without Prometheus.
+func topSteps(cluster, namespace, prompt string, offset int) []PlanStep {
+	var count, subject string
+	if match := topIntent.FindStringSubmatch(prompt); match != nil {
+		count, subject = match[1], strings.ToLower(match[3])
+	} else if match := mostIntent.FindStringSubmatch(prompt); match != nil {
+		subject = strings.ToLower(match[1])
+	} else {
+		return nil
+	}
+	kind := "pods"
+	if strings.HasPrefix(subject, "node") {
+		kind = "nodes"
+	}
+	sortBy := "cpu"
+	if resource := topResource.FindString(prompt); strings.EqualFold(resource, "memory") {
+		sortBy = "memory"
+	}
+
+	operation := CommandOperation{
+		Operation:  "top",
+		Resource:   CommandResource{Kind: kind},
+		Parameters: map[string]string{"sort-by": sortBy},
+	}
+	title := "Show the " + kind + " using the most " + sortBy
+	if count != "" {
+		operation.Parameters["limit"] = count
+		title = "Show the top " + count + " " + kind + " by " + sortBy
+	}
+	command := "kubectl top " + strings.TrimSuffix(kind, "s") + " --sort-by=" + sortBy
+	if kind == "pods" {
+		operation.Resource.Namespace = namespace
+		command += " --namespace=" + namespace
+	}
+	return []PlanStep{{
+		Sequence:          offset + 1,
+		Title:             title,
+		Description:       "Read the CPU and memory usage metrics-server last sampled, heaviest first",
+		Command:           command + " --context=" + cluster,
+		OperationType:     OperationTypeDiagnostic,
+		Target:            TargetDescriptor{Cluster: cluster, Namespace: namespace, Resource: kind},
+		AffectedResources: []string{kind},
+		Operation:         &operation,
+		Risk: RiskAnnotation{
+			Severity:    "low",
+			Code:        "OPS-DIAG-030",
+			Description: "Reading resource usage is read-only",
+		},
+	}}
+}
@@ -0,0 +1,325 @@
+package privacy
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/pramodksahoo/kubechat/backend/internal/audit"
+)
+
+type ErasureStatus string
+
+const (
+	ErasurePending   ErasureStatus = "pending"
+	ErasureRejected  ErasureStatus = "rejected"
+	ErasureCompleted ErasureStatus = "completed"
+	// ErasureFailed is an approved erasure that a holder could not finish; approving it again
+	// retries every holder.
+	ErasureFailed ErasureStatus = "failed"
+)
+
+// Erasure is a request to erase a user's personal data. Once completed, the user is replaced by
+// the pseudonym on the request itself.
+type Erasure struct {
+	ID          string          `json:"id"`
+	User        string          `json:"user"`
+	Reason      string          `json:"reason"`
+	RequestedBy string          `json:"requestedBy"`
+	RequestedAt time.Time       `json:"requestedAt"`
+	Status      ErasureStatus   `json:"status"`
+	DecidedBy   string          `json:"decidedBy,omitempty"`
+	DecidedAt   *time.Time      `json:"decidedAt,omitempty"`
+	Rejection   string          `json:"rejection,omitempty"`
+	Pseudonym   string          `json:"pseudonym,omitempty"`
+	Results     []ErasureResult `json:"results,omitempty"`
+	CompletedAt *time.Time      `json:"completedAt,omitempty"`
+}
+
+// ErasureResult is what erasure did in one holder.
+type ErasureResult struct {
+	Holder  string `json:"holder"`
+	Method  string `json:"method"`
+	Records int    `json:"records"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ErasureEvent is appended to the audit ledger for every step of an erasure and every export.
+type ErasureEvent struct {
+	Action    string        `json:"action"`
+	Erasure   string        `json:"erasure,omitempty"`
+	User      string        `json:"user"`
+	Actor     string        `json:"actor"`
+	Status    ErasureStatus `json:"status,omitempty"`
+	Reason    string        `json:"reason,omitempty"`
+	Holders   []string      `json:"holders,omitempty"`
+	Timestamp time.Time     `json:"timestamp"`
+}
+
+type ErrErasureNotFound struct {
+	ID string
+}
+
+func (e ErrErasureNotFound) Error() string {
+	return fmt.Sprintf("erasure request %s not found", e.ID)
+}
+
+// ErrErasureState is returned for decisions on erasures that are no longer open.
+type ErrErasureState struct {
+	ID     string
+	Status ErasureStatus
+}
+
+func (e ErrErasureState) Error() string {
+	return fmt.Sprintf("erasure request %s is %s", e.ID, e.Status)
+}
+
+type ErrInvalidRequest struct {
+	Reason string
+}
+
+func (e ErrInvalidRequest) Error() string {
+	return e.Reason
+}
+
+// AuditLedger chains audit entries so that tampering with the stored audit log is detected.
+type AuditLedger interface {
+	Append(ctx context.Context, source audit.Source, entry any) error
+}
+
+// Manager serves data subject requests over every holder of personal data.
+type Manager struct {
+	repo    Repository
+	holders []Holder
+	ledger  AuditLedger
+	logger  *log.Logger
+	now     func() time.Time
+}
+
+// NewManager serves requests over holders, which are erased in order. The audit ledger should come
+// last, so the approval of an erasure is pseudonymized with everything else.
+func NewManager(repo Repository, logger *log.Logger, holders ...Holder) *Manager {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Manager{repo: repo, holders: holders, logger: logger, now: func() time.Time { return time.Now().UTC() }}
+}
+
+// SetLedger records every export and every step of an erasure on ledger.
+func (m *Manager) SetLedger(ledger AuditLedger) {
+	m.ledger = ledger
+}
+
+// Export writes a zip archive of everything the holders keep about user to w: a manifest.json
+// describing the export and one JSON file per holder.
+func (m *Manager) Export(ctx context.Context, actor, user string, w io.Writer) error {
+	user = strings.TrimSpace(user)
+	if user == "" {
+		return ErrInvalidRequest{Reason: "user is required"}
+	}
+	type file struct {
+		HolderInfo
+		File string `json:"file"`
+	}
+	manifest := struct {
+		User        string    `json:"user"`
+		RequestedBy string    `json:"requestedBy"`
+		Generated   time.Time `json:"generated"`
+		Holders     []file    `json:"holders"`
+	}{User: user, RequestedBy: actor, Generated: m.now()}
+	data := make([]any, 0, len(m.holders))
+	for _, holder := range m.holders {
+		info := holder.Info()
+		exported, err := holder.Export(ctx, user)
+		if err != nil {
+			return fmt.Errorf("export %s: %w", info.Name, err)
+		}
+		manifest.Holders = append(manifest.Holders, file{HolderInfo: info, File: info.Name + ".json"})
+		data = append(data, exported)
+	}
+
+	archive := zip.NewWriter(w)
+	if err := writeJSON(archive, "manifest.json", manifest); err != nil {
+		return err
+	}
+	for i, holder := range manifest.Holders {
+		if err := writeJSON(archive, holder.File, data[i]); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	m.record(ctx, ErasureEvent{Action: "exported", User: user, Actor: actor})
+	return nil
+}
+
+func writeJSON(archive *zip.Writer, name string, value any) error {
+	part, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(part)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// RequestErasure opens an erasure of user's data, which waits for an admin's approval.
+func (m *Manager) RequestErasure(ctx context.Context, actor, user, reason string) (Erasure, error) {
+	user, reason = strings.TrimSpace(user), strings.TrimSpace(reason)
+	if user == "" {
+		return Erasure{}, ErrInvalidRequest{Reason: "user is required"}
+	}
+	open, err := m.repo.ListErasures(ctx)
+	if err != nil {
+		return Erasure{}, err
+	}
+	for _, erasure := range open {
+		if erasure.User == user && (erasure.Status == ErasurePending || erasure.Status == ErasureFailed) {
+			return Erasure{}, ErrInvalidRequest{Reason: fmt.Sprintf("erasure %s of %s is already %s", erasure.ID, user, erasure.Status)}
+		}
+	}
+	erasure := Erasure{
+		ID:          uuid.NewString(),
+		User:        user,
+		Reason:      reason,
+		RequestedBy: actor,
+		RequestedAt: m.now(),
+		Status:      ErasurePending,
+	}
+	if err := m.repo.SaveErasure(ctx, erasure); err != nil {
+		return Erasure{}, err
+	}
+	m.record(ctx, ErasureEvent{Action: "erasure_requested", Erasure: erasure.ID, User: user, Actor: actor, Status: erasure.Status, Reason: reason})
+	return erasure, nil
+}
+
+func (m *Manager) Erasure(ctx context.Context, id string) (Erasure, error) {
+	return m.repo.GetErasure(ctx, id)
+}
+
+// Erasures lists erasure requests, most recent first.
+func (m *Manager) Erasures(ctx context.Context) ([]Erasure, error) {
+	return m.repo.ListErasures(ctx)
+}
+
+// Reject closes a pending erasure without erasing anything.
+func (m *Manager) Reject(ctx context.Context, actor, id, reason string) (Erasure, error) {
+	erasure, err := m.repo.GetErasure(ctx, id)
+	if err != nil {
+		return Erasure{}, err
+	}
+	if erasure.Status != ErasurePending {
+		return Erasure{}, ErrErasureState{ID: id, Status: erasure.Status}
+	}
+	now := m.now()
+	erasure.Status, erasure.DecidedBy, erasure.DecidedAt = ErasureRejected, actor, &now
+	erasure.Rejection = strings.TrimSpace(reason)
+	if err := m.repo.SaveErasure(ctx, erasure); err != nil {
+		return Erasure{}, err
+	}
+	m.record(ctx, ErasureEvent{Action: "erasure_rejected", Erasure: id, User: erasure.User, Actor: actor, Status: erasure.Status, Reason: erasure.Rejection})
+	return erasure, nil
+}
+
+// Approve erases the user's data in every holder. The requester cannot approve their own
+// request. When a holder fails the erasure is marked failed and can be approved again; holders
+// that already finished find nothing left to change.
+func (m *Manager) Approve(ctx context.Context, actor, id string) (Erasure, error) {
+	erasure, err := m.repo.GetErasure(ctx, id)
+	if err != nil {
+		return Erasure{}, err
+	}
+	if erasure.Status != ErasurePending && erasure.Status != ErasureFailed {
+		return Erasure{}, ErrErasureState{ID: id, Status: erasure.Status}
+	}
+	if actor == erasure.RequestedBy || actor == erasure.User {
+		return Erasure{}, ErrInvalidRequest{Reason: "an erasure must be approved by another admin"}
+	}
+	now := m.now()
+	erasure.DecidedBy, erasure.DecidedAt = actor, &now
+	if erasure.Pseudonym == "" {
+		erasure.Pseudonym = "erased-" + strings.ReplaceAll(uuid.NewString(), "-", "")[:12]
+	}
+	// The approval goes on the ledger before the ledger is erased, so it is pseudonymized too.
+	m.record(ctx, ErasureEvent{Action: "erasure_approved", Erasure: id, User: erasure.User, Actor: actor, Status: erasure.Status})
+
+	erasure.Status, erasure.Results = ErasureCompleted, nil
+	for _, holder := range m.holders {
+		info := holder.Info()
+		result := ErasureResult{Holder: info.Name, Method: info.Method}
+		result.Records, err = holder.Erase(ctx, erasure.User, erasure.Pseudonym)
+		if err != nil {
+			result.Error = err.Error()
+			erasure.Status = ErasureFailed
+			m.logger.Error("failed to erase personal data", "event", "compliance", "erasure", id, "holder", info.Name, "error", err)
+		}
+		erasure.Results = append(erasure.Results, result)
+	}
+
+	holders := make([]string, 0, len(erasure.Results))
+	for _, result := range erasure.Results {
+		holders = append(holders, result.Holder)
+	}
+	if erasure.Status == ErasureCompleted {
+		completed := m.now()
+		erasure.CompletedAt = &completed
+		if err := m.pseudonymizeRequests(ctx, erasure.User, erasure.Pseudonym); err != nil {
+			return Erasure{}, err
+		}
+		if erasure.RequestedBy == erasure.User {
+			erasure.RequestedBy = erasure.Pseudonym
+		}
+		erasure.User = erasure.Pseudonym
+	}
+	if err := m.repo.SaveErasure(ctx, erasure); err != nil {
+		return Erasure{}, err
+	}
+	event := ErasureEvent{Action: "erasure_completed", Erasure: id, User: erasure.Pseudonym, Actor: actor, Status: erasure.Status, Holders: holders}
+	if erasure.Status == ErasureFailed {
+		event.Action, event.User = "erasure_failed", erasure.User
+	}
+	m.record(ctx, event)
+	return erasure, nil
+}
+
+// pseudonymizeRequests replaces user on earlier erasure requests, such as rejected ones.
+func (m *Manager) pseudonymizeRequests(ctx context.Context, user, pseudonym string) error {
+	erasures, err := m.repo.ListErasures(ctx)
+	if err != nil {
+		return err
+	}
+	for _, erasure := range erasures {
+		if erasure.User != user && erasure.RequestedBy != user && erasure.DecidedBy != user {
+			continue
+		}
+		for _, name := range []*string{&erasure.User, &erasure.RequestedBy, &erasure.DecidedBy} {
+			if *name == user {
+				*name = pseudonym
+			}
+		}
+		if err := m.repo.SaveErasure(ctx, erasure); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// record logs a compliance event and appends it to the ledger. Logs cannot be erased, so they
+// carry no user names; the ledger entry does and is pseudonymized with the rest of the ledger.
+func (m *Manager) record(ctx context.Context, event ErasureEvent) {
+	event.Timestamp = m.now()
+	m.logger.Info("data subject request", "event", "compliance", "action", event.Action, "erasure", event.Erasure)
+	if m.ledger == nil {
+		return
+	}
+	if err := m.ledger.Append(ctx, audit.SourcePrivacy, event); err != nil {
+		m.logger.Error("failed to chain privacy audit entry", "action", event.Action, "error", err)
+	}
+}
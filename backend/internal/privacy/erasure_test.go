@@ -0,0 +1,135 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/pramodksahoo/kubechat/backend/internal/audit"
+)
+
+type fakeHolder struct {
+	name  string
+	data  map[string][]string
+	fails bool
+}
+
+func (h *fakeHolder) Info() HolderInfo {
+	return HolderInfo{Name: h.name, Method: MethodDeleted}
+}
+
+func (h *fakeHolder) Export(ctx context.Context, user string) (any, error) {
+	return h.data[user], nil
+}
+
+func (h *fakeHolder) Erase(ctx context.Context, user, pseudonym string) (int, error) {
+	if h.fails {
+		return 0, errors.New("store unavailable")
+	}
+	erased := len(h.data[user])
+	delete(h.data, user)
+	return erased, nil
+}
+
+type fakeLedger struct {
+	entries []ErasureEvent
+}
+
+func (l *fakeLedger) Append(ctx context.Context, source audit.Source, entry any) error {
+	l.entries = append(l.entries, entry.(ErasureEvent))
+	return nil
+}
+
+func newTestManager(holders ...Holder) (*Manager, *fakeLedger) {
+	m := NewManager(NewMemoryRepository(), log.NewWithOptions(io.Discard, log.Options{}), holders...)
+	ledger := &fakeLedger{}
+	m.SetLedger(ledger)
+	return m, ledger
+}
+
+func TestExportArchivesEveryHolder(t *testing.T) {
+	sessions := &fakeHolder{name: "chat_sessions", data: map[string][]string{"alice": {"s1", "s2"}, "bob": {"s3"}}}
+	m, ledger := newTestManager(sessions)
+
+	var buf bytes.Buffer
+	if err := m.Export(context.Background(), "alice", "alice", &buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	files := map[string]string{}
+	for _, file := range archive.File {
+		reader, _ := file.Open()
+		data, _ := io.ReadAll(reader)
+		files[file.Name] = string(data)
+	}
+	if !strings.Contains(files["manifest.json"], `"file": "chat_sessions.json"`) {
+		t.Fatalf("expected the manifest to list the holder, got %s", files["manifest.json"])
+	}
+	var exported []string
+	if err := json.Unmarshal([]byte(files["chat_sessions.json"]), &exported); err != nil || len(exported) != 2 {
+		t.Fatalf("expected alice's two sessions only, got %s (%v)", files["chat_sessions.json"], err)
+	}
+	if len(ledger.entries) != 1 || ledger.entries[0].Action != "exported" {
+		t.Fatalf("expected the export to be recorded, got %+v", ledger.entries)
+	}
+}
+
+func TestErasureNeedsAnotherAdminAndPseudonymizesTheRequest(t *testing.T) {
+	sessions := &fakeHolder{name: "chat_sessions", data: map[string][]string{"alice": {"s1", "s2"}}}
+	executions := &fakeHolder{name: "command_executions", data: map[string][]string{"alice": {"e1"}}, fails: true}
+	m, ledger := newTestManager(sessions, executions)
+	ctx := context.Background()
+
+	erasure, err := m.RequestErasure(ctx, "alice", "alice", "leaving the company")
+	if err != nil || erasure.Status != ErasurePending {
+		t.Fatalf("expected a pending erasure, got %+v (%v)", erasure, err)
+	}
+	if _, err := m.RequestErasure(ctx, "admin", "alice", ""); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected a second open erasure to be refused, got %v", err)
+	}
+	if _, err := m.Approve(ctx, "alice", erasure.ID); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected the requester not to approve their own erasure, got %v", err)
+	}
+
+	failed, err := m.Approve(ctx, "admin", erasure.ID)
+	if err != nil || failed.Status != ErasureFailed || failed.User != "alice" || failed.Results[1].Error == "" {
+		t.Fatalf("expected a failing holder to fail the erasure, got %+v (%v)", failed, err)
+	}
+	executions.fails = false
+	completed, err := m.Approve(ctx, "admin", erasure.ID)
+	if err != nil || completed.Status != ErasureCompleted {
+		t.Fatalf("expected the retried erasure to complete, got %+v (%v)", completed, err)
+	}
+	if completed.User != completed.Pseudonym || completed.RequestedBy != completed.Pseudonym || !strings.HasPrefix(completed.Pseudonym, "erased-") {
+		t.Fatalf("expected alice to be replaced on the request, got %+v", completed)
+	}
+	if completed.Results[0].Records != 0 || completed.Results[1].Records != 1 {
+		t.Fatalf("expected the retry to find the sessions already deleted, got %+v", completed.Results)
+	}
+	if len(sessions.data) != 0 || len(executions.data) != 0 {
+		t.Fatalf("expected alice's data to be erased, got %v %v", sessions.data, executions.data)
+	}
+	if _, err := m.Reject(ctx, "admin", erasure.ID, "too late"); !errors.As(err, &ErrErasureState{}) {
+		t.Fatalf("expected a completed erasure not to be rejected, got %v", err)
+	}
+
+	actions := make([]string, 0, len(ledger.entries))
+	for _, entry := range ledger.entries {
+		actions = append(actions, entry.Action)
+	}
+	if strings.Join(actions, ",") != "erasure_requested,erasure_approved,erasure_failed,erasure_approved,erasure_completed" {
+		t.Fatalf("unexpected compliance trail %v", actions)
+	}
+	if last := ledger.entries[len(ledger.entries)-1]; last.User != completed.Pseudonym {
+		t.Fatalf("expected the completion to name the pseudonym only, got %+v", last)
+	}
+}
@@ -0,0 +1,209 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/audit"
+	"github.com/pramodksahoo/kubechat/backend/internal/chat"
+	"github.com/pramodksahoo/kubechat/backend/internal/commands"
+	"github.com/pramodksahoo/kubechat/backend/internal/credentials"
+	"github.com/pramodksahoo/kubechat/backend/internal/users"
+)
+
+// Erasure methods a holder applies.
+const (
+	MethodDeleted       = "deleted"
+	MethodPseudonymized = "pseudonymized"
+)
+
+// HolderInfo describes a store of personal data and how erasure treats it.
+type HolderInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Method      string `json:"method"`
+}
+
+// Holder is a store of personal data.
+type Holder interface {
+	Info() HolderInfo
+	// Export returns what the holder keeps about user, written as JSON to the archive.
+	Export(ctx context.Context, user string) (any, error)
+	// Erase deletes what the holder keeps about user, or replaces user with pseudonym where the
+	// records must be kept, and returns how many records it changed.
+	Erase(ctx context.Context, user, pseudonym string) (int, error)
+}
+
+// HolderFunc adapts functions to the Holder interface.
+type HolderFunc struct {
+	HolderInfo
+	ExportFn func(ctx context.Context, user string) (any, error)
+	EraseFn  func(ctx context.Context, user, pseudonym string) (int, error)
+}
+
+func (h HolderFunc) Info() HolderInfo { return h.HolderInfo }
+
+func (h HolderFunc) Export(ctx context.Context, user string) (any, error) {
+	return h.ExportFn(ctx, user)
+}
+
+func (h HolderFunc) Erase(ctx context.Context, user, pseudonym string) (int, error) {
+	return h.EraseFn(ctx, user, pseudonym)
+}
+
+type ChatSessions interface {
+	Sessions(ctx context.Context, owner string) ([]chat.Session, error)
+	Conversation(ctx context.Context, owner, id string) (chat.Conversation, error)
+	Delete(ctx context.Context, owner, id string) error
+}
+
+// ChatSessionsHolder exports a user's conversations and deletes them on erasure.
+func ChatSessionsHolder(sessions ChatSessions) Holder {
+	return HolderFunc{
+		HolderInfo: HolderInfo{
+			Name:        "chat_sessions",
+			Description: "Conversations with the assistant, with every message",
+			Method:      MethodDeleted,
+		},
+		ExportFn: func(ctx context.Context, user string) (any, error) {
+			list, err := sessions.Sessions(ctx, user)
+			if err != nil {
+				return nil, err
+			}
+			conversations := make([]chat.Conversation, 0, len(list))
+			for _, session := range list {
+				conversation, err := sessions.Conversation(ctx, user, session.ID)
+				if err != nil {
+					return nil, err
+				}
+				conversations = append(conversations, conversation)
+			}
+			return conversations, nil
+		},
+		EraseFn: func(ctx context.Context, user, pseudonym string) (int, error) {
+			list, err := sessions.Sessions(ctx, user)
+			if err != nil {
+				return 0, err
+			}
+			for i, session := range list {
+				if err := sessions.Delete(ctx, user, session.ID); err != nil {
+					return i, err
+				}
+			}
+			return len(list), nil
+		},
+	}
+}
+
+type Executions interface {
+	ListByUser(ctx context.Context, userID string, limit, offset int) ([]commands.Execution, error)
+	Pseudonymize(ctx context.Context, user, pseudonym string) (int, error)
+}
+
+// executionsPageSize bounds the executions loaded at once for an export.
+const executionsPageSize = 500
+
+// ExecutionsHolder exports the commands a user requested. Executions record changes made to
+// clusters, so erasure keeps them and pseudonymizes the user as requester and approver.
+func ExecutionsHolder(executions Executions) Holder {
+	return HolderFunc{
+		HolderInfo: HolderInfo{
+			Name:        "command_executions",
+			Description: "Commands the user requested, with their results",
+			Method:      MethodPseudonymized,
+		},
+		ExportFn: func(ctx context.Context, user string) (any, error) {
+			all := make([]commands.Execution, 0)
+			for {
+				page, err := executions.ListByUser(ctx, user, executionsPageSize, len(all))
+				if err != nil {
+					return nil, err
+				}
+				all = append(all, page...)
+				if len(page) < executionsPageSize {
+					return all, nil
+				}
+			}
+		},
+		EraseFn: executions.Pseudonymize,
+	}
+}
+
+type Ledger interface {
+	Mentions(ctx context.Context, user string) ([]audit.Record, error)
+	Pseudonymize(ctx context.Context, user, pseudonym string) (int, error)
+}
+
+// AuditLedgerHolder exports the audit ledger records naming a user. The ledger must stay
+// verifiable, so erasure pseudonymizes the user and rehashes the chain. Records already archived
+// to object storage are not rewritten; they expire with the archive.
+func AuditLedgerHolder(ledger Ledger) Holder {
+	return HolderFunc{
+		HolderInfo: HolderInfo{
+			Name:        "audit_ledger",
+			Description: "Audit ledger records naming the user as actor, subject or approver",
+			Method:      MethodPseudonymized,
+		},
+		ExportFn: func(ctx context.Context, user string) (any, error) {
+			records, err := ledger.Mentions(ctx, user)
+			if records == nil {
+				records = []audit.Record{}
+			}
+			return records, err
+		},
+		EraseFn: ledger.Pseudonymize,
+	}
+}
+
+type UserDirectory interface {
+	Export(ctx context.Context, user string) (users.User, []users.Session, error)
+	Forget(ctx context.Context, user string) (int, error)
+}
+
+// UserDirectoryHolder exports the user's directory record and activity sessions and deletes them
+// on erasure. A lock survives erasure, keeping only the user's name.
+func UserDirectoryHolder(directory UserDirectory) Holder {
+	return HolderFunc{
+		HolderInfo: HolderInfo{
+			Name:        "user_directory",
+			Description: "The user's groups, logins, activity sessions and account state",
+			Method:      MethodDeleted,
+		},
+		ExportFn: func(ctx context.Context, user string) (any, error) {
+			record, sessions, err := directory.Export(ctx, user)
+			if errors.As(err, &users.ErrUserNotFound{}) {
+				return map[string]any{"sessions": []users.Session{}}, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{"user": record, "sessions": sessions}, nil
+		},
+		EraseFn: func(ctx context.Context, user, pseudonym string) (int, error) {
+			return directory.Forget(ctx, user)
+		},
+	}
+}
+
+type UserKeys interface {
+	List(ctx context.Context, user string) ([]credentials.Key, error)
+	Forget(ctx context.Context, user string) (int, error)
+}
+
+// UserKeysHolder exports which model provider API keys the user stored, never the keys
+// themselves, and deletes them on erasure.
+func UserKeysHolder(keys UserKeys) Holder {
+	return HolderFunc{
+		HolderInfo: HolderInfo{
+			Name:        "user_api_keys",
+			Description: "The model provider API keys the user brought for their own requests",
+			Method:      MethodDeleted,
+		},
+		ExportFn: func(ctx context.Context, user string) (any, error) {
+			return keys.List(ctx, user)
+		},
+		EraseFn: func(ctx context.Context, user, pseudonym string) (int, error) {
+			return keys.Forget(ctx, user)
+		},
+	}
+}
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS privacy_erasures (
+    id TEXT PRIMARY KEY,
+    status TEXT NOT NULL,
+    body JSONB NOT NULL,
+    requested_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS privacy_erasures_requested_idx ON privacy_erasures (requested_at DESC);
@@ -0,0 +1,93 @@
+// Package privacy enforces the aggregation-only analytics mode, in which analytics and cost
+// reports carry counts over groups of users instead of records about individual users, and
+// serves data subject requests: exporting everything kept about a user and erasing it once an
+// admin approves.
+package privacy
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DefaultMinGroupSize is the k-anonymity threshold used when aggregation-only mode is enabled
+// without one.
+const DefaultMinGroupSize = 5
+
+// Policy is the deployment's analytics export policy. With AggregateOnly set, user-level records
+// may not leave the server and aggregates built from fewer than MinGroupSize distinct users are
+// suppressed.
+type Policy struct {
+	AggregateOnly bool `json:"aggregateOnly"`
+	MinGroupSize  int  `json:"minGroupSize,omitempty"`
+}
+
+// Threshold is the smallest number of distinct users a released group may be built from.
+func (p Policy) Threshold() int {
+	if !p.AggregateOnly {
+		return 1
+	}
+	if p.MinGroupSize < 1 {
+		return DefaultMinGroupSize
+	}
+	return p.MinGroupSize
+}
+
+// Releasable reports whether an aggregate over users distinct users may be released.
+func (p Policy) Releasable(users int) bool {
+	return !p.AggregateOnly || users >= p.Threshold()
+}
+
+// ErrUserLevel is returned for user-level data requested in aggregation-only mode.
+type ErrUserLevel struct {
+	Source string
+}
+
+func (e ErrUserLevel) Error() string {
+	return fmt.Sprintf("%s contains user-level records, which aggregation-only analytics mode does not export", e.Source)
+}
+
+// Group is one released aggregate.
+type Group struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+	Users int    `json:"users"`
+}
+
+// Groups counts records and the distinct users behind them per key.
+type Groups struct {
+	counts map[string]int
+	users  map[string]map[string]struct{}
+}
+
+func NewGroups() *Groups {
+	return &Groups{counts: make(map[string]int), users: make(map[string]map[string]struct{})}
+}
+
+// Add counts one record by user under key.
+func (g *Groups) Add(key, user string) {
+	g.counts[key]++
+	if g.users[key] == nil {
+		g.users[key] = make(map[string]struct{})
+	}
+	g.users[key][user] = struct{}{}
+}
+
+// Release returns the groups the policy allows, largest first, and how many were suppressed.
+func (p Policy) Release(g *Groups) (released []Group, suppressed int) {
+	released = []Group{}
+	for key, count := range g.counts {
+		users := len(g.users[key])
+		if !p.Releasable(users) {
+			suppressed++
+			continue
+		}
+		released = append(released, Group{Key: key, Count: count, Users: users})
+	}
+	sort.Slice(released, func(i, j int) bool {
+		if released[i].Count != released[j].Count {
+			return released[i].Count > released[j].Count
+		}
+		return released[i].Key < released[j].Key
+	})
+	return released, suppressed
+}
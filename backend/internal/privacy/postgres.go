@@ -0,0 +1,86 @@
+package privacy
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pramodksahoo/kubechat/backend/internal/database"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// PostgresRepository stores each erasure request as a JSON document.
+type PostgresRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresRepository applies the privacy schema migrations and returns a repository backed by db.
+func NewPostgresRepository(ctx context.Context, db *sqlx.DB) (*PostgresRepository, error) {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Migrate(ctx, db, "privacy", sub); err != nil {
+		return nil, err
+	}
+	return &PostgresRepository{db: db}, nil
+}
+
+type erasureRow struct {
+	ID          string    `db:"id"`
+	Status      string    `db:"status"`
+	Body        []byte    `db:"body"`
+	RequestedAt time.Time `db:"requested_at"`
+}
+
+func (r *PostgresRepository) SaveErasure(ctx context.Context, erasure Erasure) error {
+	body, err := json.Marshal(erasure)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.NamedExecContext(ctx, `INSERT INTO privacy_erasures (id, status, body, requested_at)
+		VALUES (:id, :status, :body, :requested_at)
+		ON CONFLICT (id) DO UPDATE SET status = EXCLUDED.status, body = EXCLUDED.body`, erasureRow{
+		ID:          erasure.ID,
+		Status:      string(erasure.Status),
+		Body:        body,
+		RequestedAt: erasure.RequestedAt,
+	})
+	return err
+}
+
+func (r *PostgresRepository) GetErasure(ctx context.Context, id string) (Erasure, error) {
+	var body []byte
+	err := r.db.GetContext(ctx, &body, `SELECT body FROM privacy_erasures WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Erasure{}, ErrErasureNotFound{ID: id}
+	}
+	if err != nil {
+		return Erasure{}, err
+	}
+	var erasure Erasure
+	return erasure, json.Unmarshal(body, &erasure)
+}
+
+func (r *PostgresRepository) ListErasures(ctx context.Context) ([]Erasure, error) {
+	var bodies [][]byte
+	if err := r.db.SelectContext(ctx, &bodies, `SELECT body FROM privacy_erasures ORDER BY requested_at DESC`); err != nil {
+		return nil, err
+	}
+	erasures := make([]Erasure, 0, len(bodies))
+	for _, body := range bodies {
+		var erasure Erasure
+		if err := json.Unmarshal(body, &erasure); err != nil {
+			return nil, err
+		}
+		erasures = append(erasures, erasure)
+	}
+	return erasures, nil
+}
@@ -0,0 +1,53 @@
+package privacy
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Repository persists erasure requests.
+type Repository interface {
+	SaveErasure(ctx context.Context, erasure Erasure) error
+	GetErasure(ctx context.Context, id string) (Erasure, error)
+	// ListErasures returns every erasure request, most recent first.
+	ListErasures(ctx context.Context) ([]Erasure, error)
+}
+
+// MemoryRepository keeps erasure requests in process memory; it is used when no database is configured.
+type MemoryRepository struct {
+	mu       sync.RWMutex
+	erasures map[string]Erasure
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{erasures: make(map[string]Erasure)}
+}
+
+func (r *MemoryRepository) SaveErasure(ctx context.Context, erasure Erasure) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.erasures[erasure.ID] = erasure
+	return nil
+}
+
+func (r *MemoryRepository) GetErasure(ctx context.Context, id string) (Erasure, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	erasure, ok := r.erasures[id]
+	if !ok {
+		return Erasure{}, ErrErasureNotFound{ID: id}
+	}
+	return erasure, nil
+}
+
+func (r *MemoryRepository) ListErasures(ctx context.Context) ([]Erasure, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	erasures := make([]Erasure, 0, len(r.erasures))
+	for _, erasure := range r.erasures {
+		erasures = append(erasures, erasure)
+	}
+	sort.Slice(erasures, func(i, j int) bool { return erasures[i].RequestedAt.After(erasures[j].RequestedAt) })
+	return erasures, nil
+}
@@ -0,0 +1,167 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/plan"
+)
+
+// Table is an answer rendered as rows of formatted values, the first column naming the pod,
+// namespace or node the row is about.
+type Table struct {
+	Columns []string   `json:"columns"`
+	Rows    [][]string `json:"rows"`
+}
+
+// Answer is the usage a question asked about, as a table and, for questions over a time range,
+// the series behind it.
+type Answer struct {
+	Question Question `json:"question"`
+	PromQL   string   `json:"promql"`
+	// Unit is the unit of the raw values in Series; Table holds them formatted.
+	Unit      string    `json:"unit"`
+	Range     string    `json:"range,omitempty"`
+	Step      string    `json:"step,omitempty"`
+	Time      time.Time `json:"time"`
+	Table     Table     `json:"table"`
+	Series    []Series  `json:"series,omitempty"`
+	Truncated bool      `json:"truncated,omitempty"`
+	Summary   string    `json:"summary"`
+}
+
+// Answerer answers resource usage questions from Prometheus.
+type Answerer struct {
+	client *Client
+	clock  func() time.Time
+}
+
+func NewAnswerer(client *Client) *Answerer {
+	return &Answerer{client: client, clock: func() time.Time { return time.Now().UTC() }}
+}
+
+// Answer answers the plan's prompt when it asks about CPU or memory usage, looking in the plan's
+// namespace unless the prompt names one. It returns nil for other prompts.
+func (a *Answerer) Answer(ctx context.Context, draft plan.PlanDraft) (*Answer, error) {
+	return a.Ask(ctx, draft.Prompt, draft.TargetNamespace)
+}
+
+// Ask answers question, or returns nil when it is not a usage question Translate recognises.
+func (a *Answerer) Ask(ctx context.Context, question, namespace string) (*Answer, error) {
+	q, ok := Translate(question, namespace)
+	if !ok {
+		return nil, nil
+	}
+	answer, err := a.Run(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return &answer, nil
+}
+
+// Run queries the usage q asks about: the current usage, or the usage over q.Range.
+func (a *Answerer) Run(ctx context.Context, q Question) (Answer, error) {
+	answer := Answer{Question: q, PromQL: q.PromQL(), Unit: q.Unit(), Time: a.clock()}
+	label := q.groupBy()
+	if q.Range == 0 {
+		result, err := a.client.Query(ctx, answer.PromQL, answer.Time)
+		if err != nil {
+			return Answer{}, err
+		}
+		answer.Table.Columns = []string{label, string(q.Resource)}
+		answer.Table.Rows = [][]string{}
+		var total float64
+		for _, sample := range result.Samples {
+			answer.Table.Rows = append(answer.Table.Rows, []string{sample.Labels[label], q.Format(sample.Value)})
+			total += sample.Value
+		}
+		answer.Truncated = result.Truncated
+		answer.Summary = instantSummary(q, result.Samples, total)
+		return answer, nil
+	}
+
+	result, err := a.client.QueryRange(ctx, answer.PromQL, answer.Time.Add(-q.Range), answer.Time, 0)
+	if err != nil {
+		return Answer{}, err
+	}
+	answer.Range, answer.Step = span(q.Range), result.Step
+	answer.Series, answer.Truncated = result.Series, result.Truncated
+	answer.Table.Columns = []string{label, "average", "peak", "latest"}
+	answer.Table.Rows = [][]string{}
+	for _, series := range result.Series {
+		if len(series.Points) == 0 {
+			continue
+		}
+		var sum float64
+		for _, point := range series.Points {
+			sum += point.Value
+		}
+		answer.Table.Rows = append(answer.Table.Rows, []string{
+			series.Labels[label],
+			q.Format(sum / float64(len(series.Points))),
+			q.Format(peak(series)),
+			q.Format(series.Points[len(series.Points)-1].Value),
+		})
+	}
+	answer.Summary = rangeSummary(q, result.Series)
+	return answer, nil
+}
+
+func instantSummary(q Question, samples []Sample, total float64) string {
+	label, resource := q.groupBy(), q.displayResource()
+	switch len(samples) {
+	case 0:
+		return fmt.Sprintf("Prometheus has no %s usage for %s.", resource, q.describe())
+	case 1:
+		return fmt.Sprintf("%s %s uses %s of %s.", label, samples[0].Labels[label], q.Format(samples[0].Value), resource)
+	}
+	return fmt.Sprintf("Across %s, %d %ss use %s of %s in total; %s uses the most at %s.",
+		q.describe(), len(samples), label, q.Format(total), resource, samples[0].Labels[label], q.Format(samples[0].Value))
+}
+
+func rangeSummary(q Question, series []Series) string {
+	if len(series) == 0 {
+		return fmt.Sprintf("Prometheus has no %s usage for %s over the last %s.", q.displayResource(), q.describe(), span(q.Range))
+	}
+	label := q.groupBy()
+	return fmt.Sprintf("Over the last %s, %s %s used the most %s, peaking at %s.",
+		span(q.Range), label, series[0].Labels[label], q.displayResource(), q.Format(peak(series[0])))
+}
+
+func (q Question) displayResource() string {
+	if q.Resource == ResourceCPU {
+		return "CPU"
+	}
+	return "memory"
+}
+
+// describe names what q asks about, such as "checkout in namespace shop".
+func (q Question) describe() string {
+	var what string
+	switch {
+	case q.Scope == ScopeNamespace:
+		return "namespace " + q.Namespace
+	case q.Scope == ScopeNode:
+		return "node " + q.Name
+	case q.Name != "":
+		what = q.Name
+	default:
+		what = "all pods"
+	}
+	if q.Namespace != "" {
+		what += " in namespace " + q.Namespace
+	}
+	return what
+}
+
+// span writes a lookback the way questions do, such as 6h or 2d.
+func span(d time.Duration) string {
+	switch day := 24 * time.Hour; {
+	case d%day == 0:
+		return fmt.Sprintf("%dd", d/day)
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	}
+	return fmt.Sprintf("%dm", d/time.Minute)
+}
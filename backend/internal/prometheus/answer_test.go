@@ -0,0 +1,136 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/plan"
+)
+
+func TestTranslateRecognisesUsageQuestions(t *testing.T) {
+	cases := []struct {
+		prompt string
+		want   Question
+		promql string
+	}{
+		{
+			prompt: "How much CPU is checkout using?",
+			want:   Question{Resource: ResourceCPU, Scope: ScopeWorkload, Name: "checkout", Namespace: "shop"},
+			promql: `sum by (pod) (rate(container_cpu_usage_seconds_total{container!="",namespace="shop",pod=~"checkout-.*"}[5m]))`,
+		},
+		{
+			prompt: "which pods use the most memory in namespace payments over the last 6 hours",
+			want:   Question{Resource: ResourceMemory, Scope: ScopePod, Namespace: "payments", Range: 6 * time.Hour},
+			promql: `sum by (pod) (container_memory_working_set_bytes{container!="",namespace="payments"})`,
+		},
+		{
+			prompt: "memory usage of namespace monitoring",
+			want:   Question{Resource: ResourceMemory, Scope: ScopeNamespace, Name: "monitoring", Namespace: "monitoring"},
+			promql: `sum by (namespace) (container_memory_working_set_bytes{container!="",namespace="monitoring"})`,
+		},
+		{
+			prompt: "cpu used by node worker-1.eu in the past day",
+			want:   Question{Resource: ResourceCPU, Scope: ScopeNode, Name: "worker-1.eu", Range: 24 * time.Hour},
+			promql: `sum by (node) (rate(container_cpu_usage_seconds_total{container!="",node="worker-1.eu"}[5m]))`,
+		},
+	}
+	for _, tc := range cases {
+		q, ok := Translate(tc.prompt, "shop")
+		if !ok || q != tc.want {
+			t.Fatalf("%q: expected %+v, got %+v (%v)", tc.prompt, tc.want, q, ok)
+		}
+		if got := q.PromQL(); got != tc.promql {
+			t.Fatalf("%q: expected %s, got %s", tc.prompt, tc.promql, got)
+		}
+	}
+	for _, prompt := range []string{"scale deployment checkout to 3", "how many pods use the image nginx"} {
+		if q, ok := Translate(prompt, "shop"); ok {
+			t.Fatalf("expected %q not to be a usage question, got %+v", prompt, q)
+		}
+	}
+}
+
+func TestAnswerRendersInstantAndRangeQueries(t *testing.T) {
+	var queries []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		queries = append(queries, r.Form)
+		switch r.URL.Path {
+		case "/api/v1/query":
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{"pod":"checkout-7d9f-b"},"value":[1790856000,"0.125"]},
+				{"metric":{"pod":"checkout-7d9f-a"},"value":[1790856000,"0.5"]}]}}`)
+		case "/api/v1/query_range":
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"matrix","result":[
+				{"metric":{"pod":"checkout-7d9f-a"},"values":[[1790834400,"268435456"],[1790845200,"805306368"],[1790856000,"536870912"]]}]}}`)
+		}
+	}))
+	defer server.Close()
+	client, err := NewClient(server.URL, DefaultLimits, nil)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	answerer := NewAnswerer(client)
+	answerer.clock = func() time.Time { return time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC) }
+
+	answer, err := answerer.Answer(context.Background(), plan.PlanDraft{Prompt: "how much cpu is checkout using", TargetNamespace: "shop"})
+	if err != nil {
+		t.Fatalf("answer: %v", err)
+	}
+	if answer.Table.Rows[0][0] != "checkout-7d9f-a" || answer.Table.Rows[0][1] != "500m" || len(answer.Series) != 0 {
+		t.Fatalf("expected pods ordered by usage, got %+v", answer.Table)
+	}
+	if answer.Summary != "Across checkout in namespace shop, 2 pods use 625m of CPU in total; checkout-7d9f-a uses the most at 500m." {
+		t.Fatalf("unexpected summary %q", answer.Summary)
+	}
+	if queries[0].Get("query") != answer.PromQL || queries[0].Get("timeout") != "10s" {
+		t.Fatalf("unexpected query parameters %v", queries[0])
+	}
+
+	answer, err = answerer.Ask(context.Background(), "memory usage of deployment checkout over the last 6h", "shop")
+	if err != nil {
+		t.Fatalf("ask: %v", err)
+	}
+	if got := strings.Join(answer.Table.Rows[0], " "); got != "checkout-7d9f-a 512.0Mi 768.0Mi 512.0Mi" {
+		t.Fatalf("unexpected range table row %q", got)
+	}
+	if answer.Range != "6h" || queries[1].Get("step") != "87" || len(answer.Series[0].Points) != 3 {
+		t.Fatalf("expected 6h at the step keeping within the point limit, got %+v and %v", answer, queries[1])
+	}
+
+	if answer, err := answerer.Ask(context.Background(), "restart checkout", "shop"); answer != nil || err != nil {
+		t.Fatalf("expected no answer for other prompts, got %+v (%v)", answer, err)
+	}
+}
+
+func TestQueryRangeEnforcesLimits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"status":"error","errorType":"bad_data","error":"parse error at char 4"}`)
+	}))
+	defer server.Close()
+	client, _ := NewClient(server.URL, DefaultLimits, nil)
+	end := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	var invalid ErrInvalidRange
+	if _, err := client.QueryRange(context.Background(), "up", end.Add(-8*24*time.Hour), end, time.Minute); !errors.As(err, &invalid) {
+		t.Fatalf("expected a range beyond the limit to be refused, got %v", err)
+	}
+	if step := client.Step(7*24*time.Hour, time.Second); step != 2420*time.Second {
+		t.Fatalf("expected the step to be raised to stay within the point limit, got %s", step)
+	}
+	if step := client.Step(time.Hour, 0); step != DefaultLimits.MinStep {
+		t.Fatalf("expected the minimum step for short ranges, got %s", step)
+	}
+	var failed ErrQuery
+	if _, err := client.QueryRange(context.Background(), "sum(", end.Add(-time.Hour), end, 0); !errors.As(err, &failed) || failed.Type != "bad_data" {
+		t.Fatalf("expected the Prometheus error, got %v", err)
+	}
+}
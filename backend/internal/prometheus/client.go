@@ -0,0 +1,280 @@
+// Package prometheus answers resource usage questions, such as "how much CPU is checkout using",
+// from an in-cluster Prometheus. Questions are translated into PromQL and run through the HTTP API
+// within limits on the time range, resolution and number of series a query may return.
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits are the guardrails every query runs within.
+type Limits struct {
+	// MaxRange is the longest time range a range query may cover.
+	MaxRange time.Duration
+	// MaxPoints is the most points a range query returns per series; the step is raised to stay
+	// within it.
+	MaxPoints int
+	// MinStep is the finest resolution of a range query.
+	MinStep time.Duration
+	// MaxSeries is the most series or samples kept from a result; the rest are dropped.
+	MaxSeries int
+	// Timeout bounds how long Prometheus may evaluate a query.
+	Timeout time.Duration
+}
+
+// DefaultLimits keep queries cheap enough to run for every usage question.
+var DefaultLimits = Limits{
+	MaxRange:  7 * 24 * time.Hour,
+	MaxPoints: 250,
+	MinStep:   15 * time.Second,
+	MaxSeries: 20,
+	Timeout:   10 * time.Second,
+}
+
+// ErrInvalidRange is returned for range queries outside the limits.
+type ErrInvalidRange struct {
+	Reason string
+}
+
+func (e ErrInvalidRange) Error() string {
+	return "invalid query range: " + e.Reason
+}
+
+// ErrQuery is an error Prometheus reported for a query, such as a PromQL syntax error.
+type ErrQuery struct {
+	Type    string
+	Message string
+}
+
+func (e ErrQuery) Error() string {
+	return fmt.Sprintf("prometheus %s: %s", e.Type, e.Message)
+}
+
+// Sample is one series of an instant query.
+type Sample struct {
+	Labels map[string]string `json:"labels"`
+	Value  float64           `json:"value"`
+}
+
+// Point is one value of a series at a time.
+type Point struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// Series is one series of a range query.
+type Series struct {
+	Labels map[string]string `json:"labels"`
+	Points []Point           `json:"points"`
+}
+
+// Result is what a query returned. Instant queries fill Samples, range queries fill Series.
+type Result struct {
+	Query   string    `json:"query"`
+	Time    time.Time `json:"time,omitempty"`
+	Start   time.Time `json:"start,omitempty"`
+	End     time.Time `json:"end,omitempty"`
+	Step    string    `json:"step,omitempty"`
+	Samples []Sample  `json:"samples,omitempty"`
+	Series  []Series  `json:"series,omitempty"`
+	// Truncated is set when series beyond Limits.MaxSeries were dropped.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Client queries the Prometheus HTTP API.
+type Client struct {
+	baseURL string
+	client  *http.Client
+	limits  Limits
+}
+
+// NewClient queries the Prometheus at baseURL, such as http://prometheus-operated.monitoring:9090.
+func NewClient(baseURL string, limits Limits, client *http.Client) (*Client, error) {
+	parsed, err := url.Parse(baseURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("Prometheus URL %q must be an absolute http(s) URL", baseURL)
+	}
+	if client == nil {
+		client = &http.Client{Timeout: limits.Timeout + 5*time.Second}
+	}
+	return &Client{baseURL: strings.TrimRight(baseURL, "/"), client: client, limits: limits}, nil
+}
+
+// Query evaluates query at a single time.
+func (c *Client) Query(ctx context.Context, query string, at time.Time) (Result, error) {
+	params := url.Values{"query": {query}, "time": {formatTime(at)}}
+	data, err := c.get(ctx, "/api/v1/query", params)
+	if err != nil {
+		return Result{}, err
+	}
+	if data.ResultType != "vector" && data.ResultType != "scalar" {
+		return Result{}, fmt.Errorf("unsupported instant query result %q", data.ResultType)
+	}
+	result := Result{Query: query, Time: at}
+	if data.ResultType == "scalar" {
+		var value [2]any
+		if err := json.Unmarshal(data.Result, &value); err != nil {
+			return Result{}, fmt.Errorf("decode Prometheus scalar: %w", err)
+		}
+		v, err := parseValue(value[1])
+		if err != nil {
+			return Result{}, err
+		}
+		result.Samples = []Sample{{Labels: map[string]string{}, Value: v}}
+		return result, nil
+	}
+	var vector []struct {
+		Metric map[string]string `json:"metric"`
+		Value  [2]any            `json:"value"`
+	}
+	if err := json.Unmarshal(data.Result, &vector); err != nil {
+		return Result{}, fmt.Errorf("decode Prometheus vector: %w", err)
+	}
+	for _, entry := range vector {
+		v, err := parseValue(entry.Value[1])
+		if err != nil {
+			return Result{}, err
+		}
+		result.Samples = append(result.Samples, Sample{Labels: entry.Metric, Value: v})
+	}
+	sort.SliceStable(result.Samples, func(i, j int) bool { return result.Samples[i].Value > result.Samples[j].Value })
+	if len(result.Samples) > c.limits.MaxSeries {
+		result.Samples, result.Truncated = result.Samples[:c.limits.MaxSeries], true
+	}
+	return result, nil
+}
+
+// QueryRange evaluates query from start to end. The range must not exceed Limits.MaxRange; the
+// step, picked from the range when zero, is raised to stay within Limits.MaxPoints.
+func (c *Client) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (Result, error) {
+	span := end.Sub(start)
+	switch {
+	case span <= 0:
+		return Result{}, ErrInvalidRange{Reason: "the end must be after the start"}
+	case span > c.limits.MaxRange:
+		return Result{}, ErrInvalidRange{Reason: fmt.Sprintf("%s exceeds the limit of %s", span, c.limits.MaxRange)}
+	case step < 0:
+		return Result{}, ErrInvalidRange{Reason: "the step must not be negative"}
+	}
+	step = c.Step(span, step)
+	params := url.Values{
+		"query": {query},
+		"start": {formatTime(start)},
+		"end":   {formatTime(end)},
+		"step":  {strconv.FormatFloat(step.Seconds(), 'f', -1, 64)},
+	}
+	data, err := c.get(ctx, "/api/v1/query_range", params)
+	if err != nil {
+		return Result{}, err
+	}
+	if data.ResultType != "matrix" {
+		return Result{}, fmt.Errorf("unsupported range query result %q", data.ResultType)
+	}
+	var matrix []struct {
+		Metric map[string]string `json:"metric"`
+		Values [][2]any          `json:"values"`
+	}
+	if err := json.Unmarshal(data.Result, &matrix); err != nil {
+		return Result{}, fmt.Errorf("decode Prometheus matrix: %w", err)
+	}
+	result := Result{Query: query, Start: start, End: end, Step: step.String()}
+	for _, entry := range matrix {
+		series := Series{Labels: entry.Metric}
+		for _, value := range entry.Values {
+			seconds, ok := value[0].(float64)
+			if !ok {
+				return Result{}, fmt.Errorf("unexpected Prometheus timestamp %v", value[0])
+			}
+			v, err := parseValue(value[1])
+			if err != nil {
+				return Result{}, err
+			}
+			series.Points = append(series.Points, Point{Time: time.UnixMilli(int64(seconds * 1000)).UTC(), Value: v})
+		}
+		result.Series = append(result.Series, series)
+	}
+	sort.SliceStable(result.Series, func(i, j int) bool { return peak(result.Series[i]) > peak(result.Series[j]) })
+	if len(result.Series) > c.limits.MaxSeries {
+		result.Series, result.Truncated = result.Series[:c.limits.MaxSeries], true
+	}
+	return result, nil
+}
+
+// Step is the step a range query over span runs with when step is asked for: at least
+// Limits.MinStep and coarse enough for at most Limits.MaxPoints points.
+func (c *Client) Step(span, step time.Duration) time.Duration {
+	if least := span / time.Duration(c.limits.MaxPoints); step < least {
+		step = least.Round(time.Second)
+		if step < least {
+			step += time.Second
+		}
+	}
+	return max(step, c.limits.MinStep)
+}
+
+type queryData struct {
+	ResultType string          `json:"resultType"`
+	Result     json.RawMessage `json:"result"`
+}
+
+func (c *Client) get(ctx context.Context, path string, params url.Values) (queryData, error) {
+	if c.limits.Timeout > 0 {
+		params.Set("timeout", c.limits.Timeout.String())
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return queryData{}, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return queryData{}, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		return queryData{}, err
+	}
+	var envelope struct {
+		Status    string    `json:"status"`
+		Data      queryData `json:"data"`
+		ErrorType string    `json:"errorType"`
+		Error     string    `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return queryData{}, fmt.Errorf("Prometheus returned %s", resp.Status)
+	}
+	if envelope.Status != "success" {
+		return queryData{}, ErrQuery{Type: envelope.ErrorType, Message: envelope.Error}
+	}
+	return envelope.Data, nil
+}
+
+// parseValue reads a sample value, which the API encodes as a string to carry NaN and Inf.
+func parseValue(raw any) (float64, error) {
+	s, ok := raw.(string)
+	if !ok {
+		return 0, fmt.Errorf("unexpected Prometheus sample value %v", raw)
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixMilli())/1000, 'f', -1, 64)
+}
+
+func peak(series Series) float64 {
+	var highest float64
+	for _, point := range series.Points {
+		highest = max(highest, point.Value)
+	}
+	return highest
+}
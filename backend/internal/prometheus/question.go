@@ -0,0 +1,181 @@
+package prometheus
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Resource is what a usage question asks about.
+type Resource string
+
+const (
+	ResourceCPU    Resource = "cpu"
+	ResourceMemory Resource = "memory"
+)
+
+// Scope is what a usage question asks about the usage of.
+type Scope string
+
+const (
+	ScopePod       Scope = "pod"
+	ScopeWorkload  Scope = "workload"
+	ScopeNamespace Scope = "namespace"
+	ScopeNode      Scope = "node"
+)
+
+// rateWindow is the window CPU usage is averaged over.
+const rateWindow = "5m"
+
+// Question is a resource usage question translated from natural language.
+type Question struct {
+	Resource  Resource `json:"resource"`
+	Scope     Scope    `json:"scope"`
+	Name      string   `json:"name,omitempty"`
+	Namespace string   `json:"namespace,omitempty"`
+	// Range is how far back the question looks; zero asks about current usage.
+	Range time.Duration `json:"-"`
+}
+
+var (
+	usageWords  = regexp.MustCompile(`\b(how much|using|uses|used|use|usage|consum\w*|utili[sz]\w*|top|most|heaviest)\b`)
+	cpuWords    = regexp.MustCompile(`\b(cpu|cores?|millicores?)\b`)
+	memoryWords = regexp.MustCompile(`\b(memory|mem|ram)\b`)
+	kindSubject = regexp.MustCompile(`\b(pod|deployment|statefulset|daemonset|namespace|node|ns|deploy)s?\s+([a-z0-9]([a-z0-9.-]*[a-z0-9])?)\b`)
+	verbSubject = regexp.MustCompile(`\b(?:is|are|does|do)\s+(?:the\s+)?([a-z0-9]([a-z0-9.-]*[a-z0-9])?)\s+(?:pods?\s+|service\s+|app\s+)?(?:using|use|consum\w*)\b`)
+	inNamespace = regexp.MustCompile(`\bin\s+(?:the\s+)?(?:namespace\s+([a-z0-9][a-z0-9-]*)|([a-z0-9][a-z0-9-]*)\s+namespace)\b`)
+	lookback    = regexp.MustCompile(`\b(?:over|in|during|for)\s+the\s+(?:last|past)\s+(\d+\s*)?(minutes?|mins?|m|hours?|h|days?|d|weeks?|w)\b`)
+	notASubject = map[string]bool{
+		"it": true, "that": true, "this": true, "there": true, "they": true, "the": true, "most": true,
+		"cpu": true, "memory": true, "use": true, "uses": true, "using": true, "consume": true, "consuming": true,
+		"in": true, "on": true, "is": true, "are": true, "with": true, "and": true, "by": true, "have": true,
+	}
+	lookbackUnit = map[byte]time.Duration{'m': time.Minute, 'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+)
+
+// Translate recognises a question about CPU or memory usage, such as "how much CPU is checkout
+// using" or "which pods use the most memory in namespace shop over the last 6 hours". namespace
+// applies when the question names none.
+func Translate(prompt, namespace string) (Question, bool) {
+	text := strings.ToLower(prompt)
+	if !usageWords.MatchString(text) {
+		return Question{}, false
+	}
+	q := Question{Namespace: namespace}
+	switch cpu, memory := cpuWords.MatchString(text), memoryWords.MatchString(text); {
+	case cpu && (!memory || cpuWords.FindStringIndex(text)[0] < memoryWords.FindStringIndex(text)[0]):
+		q.Resource = ResourceCPU
+	case memory:
+		q.Resource = ResourceMemory
+	default:
+		return Question{}, false
+	}
+	if loc := inNamespace.FindStringSubmatchIndex(text); loc != nil {
+		m := inNamespace.FindStringSubmatch(text)
+		q.Namespace = m[1] + m[2]
+		// "in namespace shop" narrows the question rather than naming what it is about.
+		text = text[:loc[0]] + text[loc[1]:]
+	}
+	q.Scope = ScopePod
+	if m := subject(text); m != nil {
+		q.Name = m[2]
+		switch m[1] {
+		case "pod":
+		case "namespace", "ns":
+			q.Scope, q.Namespace = ScopeNamespace, m[2]
+		case "node":
+			q.Scope, q.Namespace = ScopeNode, ""
+		default:
+			q.Scope = ScopeWorkload
+		}
+	} else if m := verbSubject.FindStringSubmatch(text); m != nil && !notASubject[m[1]] {
+		q.Scope, q.Name = ScopeWorkload, m[1]
+	}
+	if m := lookback.FindStringSubmatch(text); m != nil {
+		n := 1
+		if count := strings.TrimSpace(m[1]); count != "" {
+			n, _ = strconv.Atoi(count)
+		}
+		q.Range = time.Duration(n) * lookbackUnit[m[2][0]]
+	}
+	return q, true
+}
+
+// subject is the first "<kind> <name>" in text whose name is not a common word, as in "pods use".
+func subject(text string) []string {
+	for _, m := range kindSubject.FindAllStringSubmatch(text, -1) {
+		if !notASubject[m[2]] {
+			return m
+		}
+	}
+	return nil
+}
+
+// PromQL is the query answering q, grouped by pod unless it asks about a namespace or node.
+func (q Question) PromQL() string {
+	matchers := []string{`container!=""`}
+	if q.Namespace != "" {
+		matchers = append(matchers, "namespace="+strconv.Quote(q.Namespace))
+	}
+	switch q.Scope {
+	case ScopePod:
+		if q.Name != "" {
+			matchers = append(matchers, "pod="+strconv.Quote(q.Name))
+		}
+	case ScopeWorkload:
+		matchers = append(matchers, "pod=~"+strconv.Quote(regexp.QuoteMeta(q.Name)+"-.*"))
+	case ScopeNode:
+		matchers = append(matchers, "node="+strconv.Quote(q.Name))
+	}
+	selector := "{" + strings.Join(matchers, ",") + "}"
+	var expr string
+	switch q.Resource {
+	case ResourceCPU:
+		expr = fmt.Sprintf("rate(container_cpu_usage_seconds_total%s[%s])", selector, rateWindow)
+	default:
+		expr = "container_memory_working_set_bytes" + selector
+	}
+	return fmt.Sprintf("sum by (%s) (%s)", q.groupBy(), expr)
+}
+
+// groupBy is the label the answer has a row for.
+func (q Question) groupBy() string {
+	switch q.Scope {
+	case ScopeNamespace:
+		return "namespace"
+	case ScopeNode:
+		return "node"
+	}
+	return "pod"
+}
+
+// Unit is the unit of the query's values.
+func (q Question) Unit() string {
+	if q.Resource == ResourceCPU {
+		return "cores"
+	}
+	return "bytes"
+}
+
+// Format renders a value of q's resource the way Kubernetes quantities are written, such as 250m
+// or 1.5Gi.
+func (q Question) Format(value float64) string {
+	if q.Resource == ResourceCPU {
+		if value < 1 {
+			return fmt.Sprintf("%.0fm", value*1000)
+		}
+		return strconv.FormatFloat(value, 'f', 2, 64)
+	}
+	units := []string{"", "Ki", "Mi", "Gi", "Ti"}
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f", value)
+	}
+	return strconv.FormatFloat(value, 'f', 1, 64) + units[i]
+}
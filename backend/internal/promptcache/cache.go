@@ -0,0 +1,198 @@
+// Package promptcache serves plans for prompts asked before. Prompts are normalized so that
+// near-identical wording shares a plan, and plans are keyed by the cluster context they were
+// resolved against, so they stop being served once the clusters or namespaces change. Plans are
+// kept in Redis so that every replica shares them, or in memory for a single replica.
+package promptcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+	"github.com/pramodksahoo/kubechat/backend/internal/plan"
+	"github.com/pramodksahoo/kubechat/backend/internal/redis"
+)
+
+// DefaultTTL is how long plans are served from the cache when no TTL is configured.
+const DefaultTTL = 5 * time.Minute
+
+// Preference is whether a user's prompts are served from, and stored in, the cache.
+type Preference struct {
+	Enabled bool `json:"enabled"`
+}
+
+// Store keeps cached plans and the users who opted out of the cache.
+type Store interface {
+	Get(ctx context.Context, key string) (plan.PlanDraft, bool, error)
+	Set(ctx context.Context, key string, draft plan.PlanDraft, ttl time.Duration) error
+	OptedOut(ctx context.Context, user string) (bool, error)
+	SetOptedOut(ctx context.Context, user string, out bool) error
+}
+
+// Builder serves plans from the cache and caches the plans inner builds. Prompts continuing a
+// conversation with earlier messages or referencing attachments are always built afresh.
+type Builder struct {
+	inner   plan.Builder
+	catalog plan.ClusterCatalog
+	store   Store
+	ttl     time.Duration
+	logger  *log.Logger
+	now     func() time.Time
+	// unavailable reports when the store cannot be reached.
+	unavailable *redis.Warner
+}
+
+// NewBuilder caches plans for ttl in store, or in memory when store is nil. catalog is the
+// catalog inner resolves prompts against.
+func NewBuilder(inner plan.Builder, catalog plan.ClusterCatalog, store Store, ttl time.Duration, logger *log.Logger) *Builder {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	return &Builder{
+		inner:       inner,
+		catalog:     catalog,
+		store:       store,
+		ttl:         ttl,
+		logger:      logger,
+		now:         time.Now,
+		unavailable: redis.NewWarner(logger, "prompt cache store unavailable, building plans afresh"),
+	}
+}
+
+func (b *Builder) BuildPlan(ctx context.Context, input plan.BuildInput) (plan.PlanDraft, error) {
+	user := identity.FromContext(ctx)
+	key, ok := b.key(ctx, user, input)
+	if !ok {
+		return b.inner.BuildPlan(ctx, input)
+	}
+	if cached, found, err := b.store.Get(ctx, key); err != nil {
+		b.unavailable.Warn(err)
+	} else if found {
+		b.logger.Debug("plan served from prompt cache", "plan_id", cached.ID, "user", user)
+		return b.reuse(cached, input), nil
+	}
+
+	draft, err := b.inner.BuildPlan(ctx, input)
+	if err != nil {
+		return draft, err
+	}
+	if err := b.store.Set(ctx, key, draft, b.ttl); err != nil {
+		b.unavailable.Warn(err)
+	}
+	return draft, nil
+}
+
+// reuse copies a cached plan for a new prompt: the plan gets its own ID, carries the prompt as
+// asked and the request's scope signals, and is marked as served from the cache.
+func (b *Builder) reuse(cached plan.PlanDraft, input plan.BuildInput) plan.PlanDraft {
+	draft := cached
+	generatedAt := cached.GeneratedAt
+	draft.ID = uuid.NewString()
+	draft.Prompt = input.Prompt
+	draft.CachedAt = &generatedAt
+	draft.GeneratedAt = b.now()
+	draft.ScopeSignals = maps.Clone(cached.ScopeSignals)
+	if draft.ScopeSignals == nil {
+		draft.ScopeSignals = map[string]string{}
+	}
+	maps.Copy(draft.ScopeSignals, input.ScopeSignals)
+	draft.ScopeSignals["cache"] = "hit"
+	return draft
+}
+
+// key names the cached plan for input, reporting false when input may not be cached.
+func (b *Builder) key(ctx context.Context, user string, input plan.BuildInput) (string, bool) {
+	if b.ttl <= 0 || len(input.History) > 0 || len(input.Attachments) > 0 {
+		return "", false
+	}
+	if user != "" {
+		out, err := b.store.OptedOut(ctx, user)
+		if err != nil {
+			b.unavailable.Warn(err)
+			return "", false
+		}
+		if out {
+			return "", false
+		}
+	}
+	clusters, err := b.catalog.List(ctx)
+	if err != nil {
+		return "", false
+	}
+	parts := []string{
+		Normalize(input.Prompt),
+		strings.ToLower(strings.TrimSpace(input.ClusterHint)),
+		strings.ToLower(strings.TrimSpace(input.NamespaceHint)),
+	}
+	if conversation := input.Conversation; !conversation.Empty() {
+		parts = append(parts, conversation.Cluster, conversation.Namespace)
+		parts = append(parts, conversation.Resources...)
+	}
+	parts = append(parts, fingerprint(clusters))
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:]), true
+}
+
+// Preference returns whether user's prompts use the cache.
+func (b *Builder) Preference(ctx context.Context, user string) (Preference, error) {
+	out, err := b.store.OptedOut(ctx, user)
+	if err != nil {
+		return Preference{}, err
+	}
+	return Preference{Enabled: !out}, nil
+}
+
+// SetPreference opts user in to or out of the cache.
+func (b *Builder) SetPreference(ctx context.Context, user string, preference Preference) (Preference, error) {
+	if err := b.store.SetOptedOut(ctx, user, !preference.Enabled); err != nil {
+		return Preference{}, err
+	}
+	b.logger.Info("prompt cache preference changed", "user", user, "enabled", preference.Enabled)
+	return preference, nil
+}
+
+// fillers are words dropped from prompts because they do not change what is asked.
+var fillers = map[string]bool{
+	"please": true, "pls": true, "kindly": true, "can": true, "could": true, "would": true,
+	"you": true, "me": true, "the": true, "a": true, "an": true,
+}
+
+// Normalize reduces a prompt to its lower-case words without punctuation and filler words, so
+// that "Please show me the pods in shop!" and "show pods in shop" are the same prompt.
+func Normalize(prompt string) string {
+	words := strings.FieldsFunc(strings.ToLower(prompt), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-./_:=", r)
+	})
+	kept := make([]string, 0, len(words))
+	for _, word := range words {
+		word = strings.Trim(word, ".:")
+		if word != "" && !fillers[word] {
+			kept = append(kept, word)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+// fingerprint identifies the cluster context prompts are resolved against.
+func fingerprint(clusters []plan.ClusterMetadata) string {
+	entries := make([]string, 0, len(clusters))
+	for _, cluster := range clusters {
+		namespaces := slices.Sorted(slices.Values(cluster.Namespaces))
+		entries = append(entries, fmt.Sprintf("%s/%s/%s", cluster.Name, cluster.DefaultNamespace, strings.Join(namespaces, ",")))
+	}
+	slices.Sort(entries)
+	sum := sha256.Sum256([]byte(strings.Join(entries, "\n")))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,79 @@
+package promptcache
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+	"github.com/pramodksahoo/kubechat/backend/internal/plan"
+)
+
+type countingBuilder struct {
+	builds int
+}
+
+func (b *countingBuilder) BuildPlan(ctx context.Context, input plan.BuildInput) (plan.PlanDraft, error) {
+	b.builds++
+	return plan.PlanDraft{
+		ID:           "plan-" + input.Prompt,
+		Prompt:       input.Prompt,
+		ScopeSignals: map[string]string{"request_id": input.ScopeSignals["request_id"]},
+		Steps:        []plan.PlanStep{{Sequence: 1, Command: "kubectl get pods --namespace=shop"}},
+		GeneratedAt:  time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC),
+	}, nil
+}
+
+type fixedCatalog []plan.ClusterMetadata
+
+func (c *fixedCatalog) List(ctx context.Context) ([]plan.ClusterMetadata, error) {
+	return *c, nil
+}
+
+func TestNearIdenticalPromptsShareAPlanUntilTheClustersChange(t *testing.T) {
+	inner := &countingBuilder{}
+	catalog := &fixedCatalog{{Name: "prod", DefaultNamespace: "shop", Namespaces: []string{"shop"}}}
+	builder := NewBuilder(inner, catalog, nil, time.Minute, log.NewWithOptions(io.Discard, log.Options{}))
+	ctx := identity.NewContext(context.Background(), "alice")
+
+	first, _ := builder.BuildPlan(ctx, plan.BuildInput{Prompt: "Show me the pods in shop", ScopeSignals: map[string]string{"request_id": "1"}})
+	second, err := builder.BuildPlan(identity.NewContext(context.Background(), "bob"), plan.BuildInput{Prompt: "please show pods in shop!", ScopeSignals: map[string]string{"request_id": "2"}})
+	if err != nil || inner.builds != 1 {
+		t.Fatalf("expected the second prompt to be served from the cache, got %d builds: %v", inner.builds, err)
+	}
+	if second.CachedAt == nil || second.ID == first.ID || second.Prompt != "please show pods in shop!" || second.ScopeSignals["request_id"] != "2" {
+		t.Fatalf("expected a copy of the cached plan for the new request, got %+v", second)
+	}
+	if second.Steps[0].Command != first.Steps[0].Command {
+		t.Fatalf("expected the cached command, got %q", second.Steps[0].Command)
+	}
+
+	builder.BuildPlan(ctx, plan.BuildInput{Prompt: "show pods in shop", Attachments: []plan.Attachment{{ID: "manifest"}}})
+	if inner.builds != 2 {
+		t.Fatalf("expected prompts with attachments to be built afresh")
+	}
+
+	*catalog = append(*catalog, plan.ClusterMetadata{Name: "staging"})
+	builder.BuildPlan(ctx, plan.BuildInput{Prompt: "show pods in shop"})
+	if inner.builds != 3 {
+		t.Fatalf("expected a changed cluster catalog to miss the cache")
+	}
+
+	if _, err := builder.SetPreference(ctx, "alice", Preference{Enabled: false}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if draft, _ := builder.BuildPlan(ctx, plan.BuildInput{Prompt: "show pods in shop"}); draft.CachedAt != nil || inner.builds != 4 {
+		t.Fatalf("expected users who opted out to get fresh plans")
+	}
+	if preference, _ := builder.Preference(ctx, "alice"); preference.Enabled {
+		t.Fatalf("expected the opt-out to be kept")
+	}
+}
+
+func TestNormalizeDropsPunctuationCaseAndFillerWords(t *testing.T) {
+	if got := Normalize("Could you PLEASE scale deployment/api to 3 replicas in ns shop?"); got != "scale deployment/api to 3 replicas in ns shop" {
+		t.Fatalf("unexpected normalized prompt %q", got)
+	}
+}
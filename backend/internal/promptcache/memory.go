@@ -0,0 +1,80 @@
+package promptcache
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/plan"
+)
+
+// MemoryStore keeps plans in this replica. Plans are kept encoded so that callers never share
+// the steps of a cached plan.
+type MemoryStore struct {
+	mu       sync.Mutex
+	plans    map[string]memoryPlan
+	optedOut map[string]bool
+	now      func() time.Time
+	swept    time.Time
+}
+
+type memoryPlan struct {
+	raw     []byte
+	expires time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{plans: map[string]memoryPlan{}, optedOut: map[string]bool{}, now: time.Now}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (plan.PlanDraft, bool, error) {
+	s.mu.Lock()
+	cached, ok := s.plans[key]
+	s.mu.Unlock()
+	if !ok || !s.now().Before(cached.expires) {
+		return plan.PlanDraft{}, false, nil
+	}
+	var draft plan.PlanDraft
+	if err := json.Unmarshal(cached.raw, &draft); err != nil {
+		return plan.PlanDraft{}, false, err
+	}
+	return draft, true, nil
+}
+
+func (s *MemoryStore) Set(ctx context.Context, key string, draft plan.PlanDraft, ttl time.Duration) error {
+	raw, err := json.Marshal(draft)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.swept) > time.Minute {
+		for k, cached := range s.plans {
+			if !now.Before(cached.expires) {
+				delete(s.plans, k)
+			}
+		}
+		s.swept = now
+	}
+	s.plans[key] = memoryPlan{raw: raw, expires: now.Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) OptedOut(ctx context.Context, user string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.optedOut[user], nil
+}
+
+func (s *MemoryStore) SetOptedOut(ctx context.Context, user string, out bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if out {
+		s.optedOut[user] = true
+	} else {
+		delete(s.optedOut, user)
+	}
+	return nil
+}
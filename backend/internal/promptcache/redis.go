@@ -0,0 +1,67 @@
+package promptcache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/plan"
+	"github.com/pramodksahoo/kubechat/backend/internal/redis"
+)
+
+// KeyPrefix starts the name of every Redis key the store writes. Users who opted out are members
+// of the set KeyPrefix+"opted-out".
+const KeyPrefix = "kubechat:promptcache:"
+
+// RedisStore keeps plans in Redis, shared by every replica.
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) (plan.PlanDraft, bool, error) {
+	reply, err := s.client.Do(ctx, "GET", KeyPrefix+"plan:"+key)
+	if err != nil || reply == nil {
+		return plan.PlanDraft{}, false, err
+	}
+	raw, ok := reply.(string)
+	if !ok {
+		return plan.PlanDraft{}, false, fmt.Errorf("unexpected prompt cache reply %v", reply)
+	}
+	var draft plan.PlanDraft
+	if err := json.Unmarshal([]byte(raw), &draft); err != nil {
+		return plan.PlanDraft{}, false, fmt.Errorf("decode cached plan: %w", err)
+	}
+	return draft, true, nil
+}
+
+func (s *RedisStore) Set(ctx context.Context, key string, draft plan.PlanDraft, ttl time.Duration) error {
+	raw, err := json.Marshal(draft)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Do(ctx, "SET", KeyPrefix+"plan:"+key, string(raw), "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	return err
+}
+
+func (s *RedisStore) OptedOut(ctx context.Context, user string) (bool, error) {
+	reply, err := s.client.Do(ctx, "SISMEMBER", KeyPrefix+"opted-out", user)
+	if err != nil {
+		return false, err
+	}
+	return reply == int64(1), nil
+}
+
+func (s *RedisStore) SetOptedOut(ctx context.Context, user string, out bool) error {
+	command := "SREM"
+	if out {
+		command = "SADD"
+	}
+	_, err := s.client.Do(ctx, command, KeyPrefix+"opted-out", user)
+	return err
+}
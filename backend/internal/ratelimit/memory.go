@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps allowances in this replica. Allowances are token buckets tracked with the
+// generic cell rate algorithm: each key stores only when its bucket will be full again.
+type MemoryStore struct {
+	mu    sync.Mutex
+	full  map[string]time.Time
+	now   func() time.Time
+	swept time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{full: map[string]time.Time{}, now: time.Now}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Decision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.swept) > time.Minute {
+		for k, full := range s.full {
+			if full.Before(now) {
+				delete(s.full, k)
+			}
+		}
+		s.swept = now
+	}
+	decision, full := take(s.full[key], now, limit)
+	if decision.Allowed {
+		s.full[key] = full
+	}
+	return decision, nil
+}
+
+// take spends one request from a bucket that is full again at full, returning when it is full
+// after the request. A zero full is a full bucket.
+func take(full, now time.Time, limit Limit) (Decision, time.Time) {
+	emission := limit.emission()
+	if full.Before(now) {
+		full = now
+	}
+	next := full.Add(emission)
+	// The bucket holds the whole period's worth of requests.
+	if allowAt := next.Add(-limit.Period.Duration); now.Before(allowAt) {
+		return Decision{Remaining: 0, Reset: full.Sub(now), RetryAfter: allowAt.Sub(now)}, full
+	}
+	remaining := int((limit.Period.Duration - next.Sub(now)) / emission)
+	return Decision{Allowed: true, Remaining: remaining, Reset: next.Sub(now)}, next
+}
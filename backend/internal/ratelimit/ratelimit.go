@@ -0,0 +1,193 @@
+// Package ratelimit limits API requests per user, API key or client IP. Limits are kept in Redis
+// so that every replica draws from the same allowance, or in memory for a single replica.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pramodksahoo/kubechat/backend/internal/redis"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Limit allows Requests per Period, all of which may be spent at once; 0 requests is unlimited.
+type Limit struct {
+	Requests int             `json:"requests"`
+	Period   metav1.Duration `json:"period"`
+}
+
+func (l Limit) unlimited() bool {
+	return l.Requests <= 0
+}
+
+// emission is the time it takes to earn back one request.
+func (l Limit) emission() time.Duration {
+	return l.Period.Duration / time.Duration(l.Requests)
+}
+
+// KeyBy chooses whom a group's limit applies to.
+type KeyBy string
+
+const (
+	// KeyByUser limits each user a trusted proxy signed in, then each client IP.
+	KeyByUser KeyBy = "user"
+	// KeyByIP limits each client IP, such as for unauthenticated endpoints.
+	KeyByIP KeyBy = "ip"
+)
+
+// Group overrides the default limit for the requests whose path starts with one of Prefixes.
+type Group struct {
+	Name     string   `json:"name"`
+	Prefixes []string `json:"prefixes"`
+	Limit
+	By KeyBy `json:"by,omitempty"`
+}
+
+// Policy is the default limit and the group overrides; the first group matching a path applies.
+type Policy struct {
+	Default Limit   `json:"default"`
+	Groups  []Group `json:"groups,omitempty"`
+}
+
+// DefaultPolicy applies when no policy file is configured.
+var DefaultPolicy = Policy{
+	Default: Limit{Requests: 600, Period: metav1.Duration{Duration: time.Minute}},
+	Groups: []Group{
+		{Name: "probes", Prefixes: []string{"/healthz", "/metrics"}},
+		{Name: "status", Prefixes: []string{"/status"}, Limit: Limit{Requests: 5, Period: metav1.Duration{Duration: 5 * time.Second}}, By: KeyByIP},
+		{Name: "nlp", Prefixes: []string{"/api/v1/prompts", "/api/v1/nlp"}, Limit: Limit{Requests: 60, Period: metav1.Duration{Duration: time.Minute}}},
+	},
+}
+
+// LoadPolicy reads a policy from a YAML file.
+func LoadPolicy(path string) (Policy, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Policy{}, err
+	}
+	var policy Policy
+	if err := yaml.UnmarshalStrict(raw, &policy); err != nil {
+		return Policy{}, fmt.Errorf("parse rate limit policy %s: %w", path, err)
+	}
+	if err := policy.Validate(); err != nil {
+		return Policy{}, fmt.Errorf("rate limit policy %s: %w", path, err)
+	}
+	return policy, nil
+}
+
+func (p Policy) Validate() error {
+	check := func(name string, limit Limit) error {
+		if limit.Requests < 0 {
+			return fmt.Errorf("%s: requests must not be negative", name)
+		}
+		if !limit.unlimited() && limit.Period.Duration <= 0 {
+			return fmt.Errorf("%s: a limit needs a period", name)
+		}
+		return nil
+	}
+	if err := check("default", p.Default); err != nil {
+		return err
+	}
+	var names []string
+	for _, group := range p.Groups {
+		switch {
+		case group.Name == "":
+			return fmt.Errorf("every group needs a name")
+		case slices.Contains(names, group.Name):
+			return fmt.Errorf("group %s is configured twice", group.Name)
+		case len(group.Prefixes) == 0:
+			return fmt.Errorf("group %s names no path prefix", group.Name)
+		case group.By != "" && group.By != KeyByUser && group.By != KeyByIP:
+			return fmt.Errorf("group %s: unknown key %q", group.Name, group.By)
+		}
+		names = append(names, group.Name)
+		if err := check("group "+group.Name, group.Limit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Match returns the group applying to path, or a group named default with the default limit.
+func (p Policy) Match(path string) Group {
+	for _, group := range p.Groups {
+		if slices.ContainsFunc(group.Prefixes, func(prefix string) bool { return strings.HasPrefix(path, prefix) }) {
+			return group
+		}
+	}
+	return Group{Name: "default", Limit: p.Default}
+}
+
+// Decision is the outcome of taking one request from an allowance.
+type Decision struct {
+	Allowed   bool
+	Remaining int
+	// Reset is how long until the whole allowance is available again.
+	Reset time.Duration
+	// RetryAfter is how long a refused caller must wait for its next request.
+	RetryAfter time.Duration
+}
+
+// Store keeps allowances. Take spends one request of key's allowance under limit.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Decision, error)
+}
+
+// Limiter applies a policy to requests using a store.
+type Limiter struct {
+	policy Policy
+	store  Store
+	// fallback keeps limiting, per replica, while the store is unavailable.
+	fallback Store
+	logger   *log.Logger
+	// unavailable reports when the store cannot be reached.
+	unavailable *redis.Warner
+}
+
+// NewLimiter limits requests under policy, which must be valid. With a shared store, this
+// replica's own limits apply while the store cannot be reached.
+func NewLimiter(policy Policy, store Store, logger *log.Logger) *Limiter {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	return &Limiter{
+		policy:      policy,
+		store:       store,
+		fallback:    NewMemoryStore(),
+		logger:      logger,
+		unavailable: redis.NewWarner(logger, "rate limit store unavailable, limiting per replica"),
+	}
+}
+
+func (l *Limiter) Policy() Policy {
+	return l.policy
+}
+
+// Allow spends one request of the caller's allowance for path. caller identifies whoever the
+// matched group limits: the caller for KeyByUser and the client IP for KeyByIP.
+func (l *Limiter) Allow(ctx context.Context, path string, caller func(KeyBy) string) (Group, Decision) {
+	group := l.policy.Match(path)
+	if group.unlimited() {
+		return group, Decision{Allowed: true}
+	}
+	by := group.By
+	if by == "" {
+		by = KeyByUser
+	}
+	key := group.Name + ":" + caller(by)
+	decision, err := l.store.Take(ctx, key, group.Limit)
+	if err != nil {
+		l.unavailable.Warn(err)
+		decision, _ = l.fallback.Take(ctx, key, group.Limit)
+	}
+	return group, decision
+}
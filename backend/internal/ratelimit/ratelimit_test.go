@@ -0,0 +1,151 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pramodksahoo/kubechat/backend/internal/redis"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMemoryStoreSpendsAndRefillsTheAllowance(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	limit := Limit{Requests: 3, Period: metav1.Duration{Duration: 3 * time.Second}}
+	ctx := context.Background()
+
+	for want := 2; want >= 0; want-- {
+		decision, _ := store.Take(ctx, "user:alice", limit)
+		if !decision.Allowed || decision.Remaining != want {
+			t.Fatalf("expected an allowed request with %d remaining, got %+v", want, decision)
+		}
+	}
+	decision, _ := store.Take(ctx, "user:alice", limit)
+	if decision.Allowed || decision.RetryAfter != time.Second || decision.Reset != 3*time.Second {
+		t.Fatalf("expected the fourth request to wait a second, got %+v", decision)
+	}
+	if decision, _ := store.Take(ctx, "user:bob", limit); !decision.Allowed {
+		t.Fatalf("expected each caller to have their own allowance")
+	}
+
+	now = now.Add(time.Second)
+	if decision, _ := store.Take(ctx, "user:alice", limit); !decision.Allowed || decision.Remaining != 0 {
+		t.Fatalf("expected one request to be earned back after a second, got %+v", decision)
+	}
+}
+
+func TestPolicyMatchesGroupsAndRejectsInvalidLimits(t *testing.T) {
+	if group := DefaultPolicy.Match("/api/v1/prompts"); group.Name != "nlp" || group.Requests != 60 {
+		t.Fatalf("expected prompts to be limited by the nlp group, got %+v", group)
+	}
+	if group := DefaultPolicy.Match("/api/v1/commands/executions"); group.Name != "default" || group.Requests != 600 {
+		t.Fatalf("expected the default limit elsewhere, got %+v", group)
+	}
+	if err := DefaultPolicy.Validate(); err != nil {
+		t.Fatalf("expected the default policy to be valid, got %v", err)
+	}
+	invalid := Policy{Groups: []Group{{Name: "nlp", Prefixes: []string{"/api/v1/nlp"}, Limit: Limit{Requests: 10}}}}
+	if err := invalid.Validate(); err == nil || !strings.Contains(err.Error(), "needs a period") {
+		t.Fatalf("expected a limit without a period to be refused, got %v", err)
+	}
+
+	limiter := NewLimiter(Policy{Default: Limit{Requests: 1, Period: metav1.Duration{Duration: time.Minute}}}, failingStore{}, log.NewWithOptions(io.Discard, log.Options{}))
+	caller := func(KeyBy) string { return "ip:10.0.0.1" }
+	if _, decision := limiter.Allow(context.Background(), "/api/v1/plans", caller); !decision.Allowed {
+		t.Fatalf("expected the first request to be allowed")
+	}
+	if _, decision := limiter.Allow(context.Background(), "/api/v1/plans", caller); decision.Allowed {
+		t.Fatalf("expected the replica's own limit to apply while the store is down")
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, limit Limit) (Decision, error) {
+	return Decision{}, io.ErrUnexpectedEOF
+}
+
+func TestRedisStoreLoadsTheScriptAndReadsTheDecision(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	commands := make(chan []string, 8)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for {
+			args, err := readCommand(reader)
+			if err != nil {
+				return
+			}
+			commands <- args
+			switch args[0] {
+			case "AUTH", "SELECT":
+				io.WriteString(conn, "+OK\r\n")
+			case "EVALSHA":
+				io.WriteString(conn, "-NOSCRIPT No matching script. Please use EVAL.\r\n")
+			case "EVAL":
+				io.WriteString(conn, "*4\r\n:0\r\n:0\r\n:2500\r\n:500\r\n")
+			}
+		}
+	}()
+
+	client, err := redis.NewClient("redis://:secret@" + listener.Addr().String() + "/2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	decision, err := NewRedisStore(client).Take(context.Background(), "nlp:user:alice", Limit{Requests: 60, Period: metav1.Duration{Duration: time.Minute}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decision.Allowed || decision.Reset != 2500*time.Millisecond || decision.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("unexpected decision %+v", decision)
+	}
+	var sent []string
+	for len(commands) > 0 {
+		args := <-commands
+		sent = append(sent, args[0])
+		if args[0] == "EVAL" && (args[3] != KeyPrefix+"nlp:user:alice" || args[4] != "60000" || args[5] != "1000") {
+			t.Fatalf("unexpected script arguments %v", args[3:])
+		}
+	}
+	if strings.Join(sent, " ") != "AUTH SELECT EVALSHA EVAL" {
+		t.Fatalf("expected authentication, the database and a script load, got %v", sent)
+	}
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, count)
+	for i := range args {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(header[1:]))
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
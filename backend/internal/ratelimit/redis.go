@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/redis"
+)
+
+// takeScript runs take atomically in Redis, against the Redis clock so that replicas agree. The
+// key holds when the bucket is full again, in milliseconds, and expires then. It returns whether
+// the request is allowed, the remaining requests, and the reset and retry-after milliseconds.
+var takeScript = redis.NewScript(`
+local now = redis.call('TIME')
+now = tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000)
+local period = tonumber(ARGV[1])
+local emission = tonumber(ARGV[2])
+local full = tonumber(redis.call('GET', KEYS[1]) or now)
+if full < now then full = now end
+local nextFull = full + emission
+local allowAt = nextFull - period
+if now < allowAt then
+  return {0, 0, full - now, allowAt - now}
+end
+redis.call('SET', KEYS[1], nextFull, 'PX', nextFull - now)
+return {1, math.floor((period - (nextFull - now)) / emission), nextFull - now, 0}
+`)
+
+// KeyPrefix starts the name of every Redis key the store writes.
+const KeyPrefix = "kubechat:ratelimit:"
+
+// RedisStore keeps allowances in Redis, shared by every replica.
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, limit Limit) (Decision, error) {
+	reply, err := s.client.Eval(ctx, takeScript, []string{KeyPrefix + key},
+		strconv.FormatInt(limit.Period.Milliseconds(), 10), strconv.FormatInt(max(limit.emission().Milliseconds(), 1), 10))
+	if err != nil {
+		return Decision{}, err
+	}
+	values, ok := reply.([]any)
+	if !ok || len(values) != 4 {
+		return Decision{}, fmt.Errorf("unexpected rate limit reply %v", reply)
+	}
+	ints := make([]int64, 4)
+	for i, value := range values {
+		if ints[i], ok = value.(int64); !ok {
+			return Decision{}, fmt.Errorf("unexpected rate limit reply %v", reply)
+		}
+	}
+	return Decision{
+		Allowed:    ints[0] == 1,
+		Remaining:  int(ints[1]),
+		Reset:      time.Duration(ints[2]) * time.Millisecond,
+		RetryAfter: time.Duration(ints[3]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,211 @@
+// Package redis is a small Redis client for the state replicas share, such as rate limits and
+// circuit breakers. It runs commands over a pool of connections and reads their replies.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error is an error reply from the server, after which the connection is still usable.
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+// Client runs commands against one Redis server.
+type Client struct {
+	address  string
+	username string
+	password string
+	database int
+	tls      bool
+	// Timeout bounds each command, including connecting.
+	Timeout time.Duration
+	// idle holds connections for reuse.
+	idle chan *conn
+}
+
+// NewClient connects to the server of a redis:// or rediss:// URL, such as
+// redis://:password@redis:6379/0. Connections are made when commands need them.
+func NewClient(rawURL string) (*Client, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	if parsed.Scheme != "redis" && parsed.Scheme != "rediss" {
+		return nil, fmt.Errorf("invalid redis URL: unknown scheme %q", parsed.Scheme)
+	}
+	client := &Client{address: parsed.Host, tls: parsed.Scheme == "rediss", Timeout: time.Second, idle: make(chan *conn, 16)}
+	if parsed.Port() == "" {
+		client.address = net.JoinHostPort(parsed.Hostname(), "6379")
+	}
+	if parsed.User != nil {
+		client.username = parsed.User.Username()
+		client.password, _ = parsed.User.Password()
+	}
+	if db := strings.TrimPrefix(parsed.Path, "/"); db != "" {
+		if client.database, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid redis URL: database %q is not a number", db)
+		}
+	}
+	return client, nil
+}
+
+// Do runs a command. Replies are strings, int64s, nil or []any; error replies are Errors.
+func (c *Client) Do(ctx context.Context, args ...string) (any, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.do(ctx, args...)
+	if err != nil && !errors.As(err, new(Error)) {
+		// The connection may be left mid-reply.
+		conn.Close()
+		return nil, err
+	}
+	c.release(conn)
+	return reply, err
+}
+
+// Eval runs a Lua script by its digest, loading it when the server does not know it yet.
+func (c *Client) Eval(ctx context.Context, script *Script, keys []string, args ...string) (any, error) {
+	params := append([]string{strconv.Itoa(len(keys))}, keys...)
+	params = append(params, args...)
+	reply, err := c.Do(ctx, append([]string{"EVALSHA", script.sha}, params...)...)
+	if err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT") {
+		return c.Do(ctx, append([]string{"EVAL", script.source}, params...)...)
+	}
+	return reply, err
+}
+
+// Ping checks that the server can be reached.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Do(ctx, "PING")
+	return err
+}
+
+func (c *Client) conn(ctx context.Context) (*conn, error) {
+	select {
+	case conn := <-c.idle:
+		return conn, nil
+	default:
+	}
+	var dialer net.Dialer
+	raw, err := dialer.DialContext(ctx, "tcp", c.address)
+	if err != nil {
+		return nil, err
+	}
+	if c.tls {
+		host, _, _ := net.SplitHostPort(c.address)
+		raw = tls.Client(raw, &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12})
+	}
+	conn := &conn{Conn: raw, reader: bufio.NewReader(raw)}
+	if c.password != "" {
+		auth := []string{"AUTH", c.password}
+		if c.username != "" {
+			auth = []string{"AUTH", c.username, c.password}
+		}
+		if _, err := conn.do(ctx, auth...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("redis authentication failed: %w", err)
+		}
+	}
+	if c.database != 0 {
+		if _, err := conn.do(ctx, "SELECT", strconv.Itoa(c.database)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (c *Client) release(conn *conn) {
+	select {
+	case c.idle <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+// conn speaks enough of the Redis protocol to run commands and read their replies.
+type conn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *conn) do(ctx context.Context, args ...string) (any, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = c.SetDeadline(deadline)
+	}
+	var command strings.Builder
+	fmt.Fprintf(&command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&command, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.Write([]byte(command.String())); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+func (c *conn) read() (any, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("empty redis reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		values := make([]any, count)
+		for i := range values {
+			if values[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("unexpected redis reply %q", line)
+	}
+}
@@ -0,0 +1,34 @@
+package redis
+
+import (
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// Warner reports that the store a feature keeps in Redis is unavailable at most once a minute, so
+// an outage is logged without a warning for every request it fails.
+type Warner struct {
+	logger  *log.Logger
+	message string
+
+	mu     sync.Mutex
+	warned time.Time
+}
+
+// NewWarner logs message with the error that made the store unavailable.
+func NewWarner(logger *log.Logger, message string) *Warner {
+	return &Warner{logger: logger, message: message}
+}
+
+// Warn reports err unless the store was already reported unavailable within the last minute.
+func (w *Warner) Warn(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if time.Since(w.warned) < time.Minute {
+		return
+	}
+	w.warned = time.Now()
+	w.logger.Warn(w.message, "error", err)
+}
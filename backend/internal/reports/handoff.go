@@ -0,0 +1,118 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SectionStatus string
+
+const (
+	SectionOK          SectionStatus = "ok"
+	SectionAttention   SectionStatus = "attention"
+	SectionUnavailable SectionStatus = "unavailable"
+)
+
+// Scope narrows a handoff report to a cluster context and a look-back window.
+type Scope struct {
+	Workspace string        `json:"workspace,omitempty"`
+	Config    string        `json:"config"`
+	Cluster   string        `json:"cluster"`
+	Window    time.Duration `json:"window"`
+}
+
+type Item struct {
+	Title     string `json:"title"`
+	Detail    string `json:"detail,omitempty"`
+	Severity  string `json:"severity"`
+	Reference string `json:"reference,omitempty"`
+}
+
+type Section struct {
+	Name   string        `json:"name"`
+	Title  string        `json:"title"`
+	Status SectionStatus `json:"status"`
+	Items  []Item        `json:"items"`
+	Error  string        `json:"error,omitempty"`
+}
+
+type HandoffReport struct {
+	ID          string    `json:"id"`
+	Scope       Scope     `json:"scope"`
+	GeneratedAt time.Time `json:"generatedAt"`
+	Headline    string    `json:"headline"`
+	Sections    []Section `json:"sections"`
+}
+
+// SectionSource contributes one section of the handoff report.
+type SectionSource interface {
+	Name() string
+	Title() string
+	Collect(ctx context.Context, scope Scope) ([]Item, error)
+}
+
+type HandoffGenerator struct {
+	sources []SectionSource
+	clock   func() time.Time
+}
+
+func NewHandoffGenerator(sources ...SectionSource) *HandoffGenerator {
+	return &HandoffGenerator{sources: sources, clock: time.Now}
+}
+
+// AddSource registers an additional section source.
+func (g *HandoffGenerator) AddSource(source SectionSource) {
+	g.sources = append(g.sources, source)
+}
+
+func (g *HandoffGenerator) Generate(ctx context.Context, scope Scope) (HandoffReport, error) {
+	if scope.Window <= 0 {
+		scope.Window = 12 * time.Hour
+	}
+	report := HandoffReport{
+		ID:          uuid.NewString(),
+		Scope:       scope,
+		GeneratedAt: g.clock().UTC(),
+		Sections:    make([]Section, 0, len(g.sources)),
+	}
+
+	attention := 0
+	for _, source := range g.sources {
+		if err := ctx.Err(); err != nil {
+			return HandoffReport{}, err
+		}
+		section := Section{Name: source.Name(), Title: source.Title(), Status: SectionOK, Items: []Item{}}
+		items, err := source.Collect(ctx, scope)
+		switch {
+		case err != nil:
+			section.Status = SectionUnavailable
+			section.Error = err.Error()
+		case len(items) > 0:
+			section.Items = items
+			if needsAttention(items) {
+				section.Status = SectionAttention
+				attention++
+			}
+		}
+		report.Sections = append(report.Sections, section)
+	}
+
+	if attention == 0 {
+		report.Headline = "All clear: nothing requires attention from the incoming on-call"
+	} else {
+		report.Headline = fmt.Sprintf("%d of %d sections need attention", attention, len(report.Sections))
+	}
+	return report, nil
+}
+
+func needsAttention(items []Item) bool {
+	for _, item := range items {
+		if item.Severity != "info" {
+			return true
+		}
+	}
+	return false
+}
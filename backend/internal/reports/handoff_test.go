@@ -0,0 +1,77 @@
+package reports
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type staticSource struct {
+	name  string
+	items []Item
+	err   error
+}
+
+func (s *staticSource) Name() string  { return s.name }
+func (s *staticSource) Title() string { return s.name }
+func (s *staticSource) Collect(ctx context.Context, scope Scope) ([]Item, error) {
+	return s.items, s.err
+}
+
+func TestHandoffGeneratorClassifiesSections(t *testing.T) {
+	generator := NewHandoffGenerator(
+		&staticSource{name: "quiet", items: []Item{{Title: "note", Severity: "info"}}},
+		&staticSource{name: "noisy", items: []Item{{Title: "pod down", Severity: "high"}}},
+		&staticSource{name: "broken", err: errors.New("no access")},
+	)
+
+	report, err := generator.Generate(context.Background(), Scope{Cluster: "prod"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Scope.Window != 12*time.Hour {
+		t.Fatalf("expected default window, got %s", report.Scope.Window)
+	}
+	statuses := map[string]SectionStatus{}
+	for _, s := range report.Sections {
+		statuses[s.Name] = s.Status
+	}
+	if statuses["quiet"] != SectionOK || statuses["noisy"] != SectionAttention || statuses["broken"] != SectionUnavailable {
+		t.Fatalf("unexpected section statuses %+v", statuses)
+	}
+	if report.Headline != "1 of 3 sections need attention" {
+		t.Fatalf("unexpected headline %q", report.Headline)
+	}
+}
+
+func TestFailingWorkloadsSourceDetectsCrashLoops(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "api-1", Namespace: "payments"},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:         "api",
+				RestartCount: 7,
+				State:        corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+			}},
+		},
+	}, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "healthy", Namespace: "payments"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+
+	source := NewFailingWorkloadsSource(func(config, cluster string) kubernetes.Interface { return client })
+	items, err := source.Collect(context.Background(), Scope{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != 1 || items[0].Reference != "pods/payments/api-1" {
+		t.Fatalf("expected crash looping pod only, got %+v", items)
+	}
+}
@@ -0,0 +1,104 @@
+package reports
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/r3labs/sse/v2"
+)
+
+// HandoffStreamID is the SSE stream scheduled handoff reports are published to.
+const HandoffStreamID = "reports:handoff"
+
+type Deliverer interface {
+	Deliver(ctx context.Context, report HandoffReport) error
+}
+
+type Publisher interface {
+	CreateStream(id string) *sse.Stream
+	Publish(id string, event *sse.Event)
+}
+
+// SSEDeliverer publishes reports as handoff_report events on the shared SSE server.
+type SSEDeliverer struct {
+	publisher Publisher
+}
+
+func NewSSEDeliverer(publisher Publisher) *SSEDeliverer {
+	return &SSEDeliverer{publisher: publisher}
+}
+
+func (d *SSEDeliverer) Deliver(ctx context.Context, report HandoffReport) error {
+	payload, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	d.publisher.CreateStream(HandoffStreamID)
+	d.publisher.Publish(HandoffStreamID, &sse.Event{Event: []byte("handoff_report"), Data: payload})
+	return nil
+}
+
+// Scheduler periodically generates handoff reports for every scope and keeps the latest one per scope.
+type Scheduler struct {
+	generator *HandoffGenerator
+	scopes    func() []Scope
+	deliverer Deliverer
+	logger    *log.Logger
+
+	mu     sync.Mutex
+	latest map[string]HandoffReport
+}
+
+func NewScheduler(generator *HandoffGenerator, scopes func() []Scope, deliverer Deliverer, logger *log.Logger) *Scheduler {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Scheduler{
+		generator: generator,
+		scopes:    scopes,
+		deliverer: deliverer,
+		logger:    logger,
+		latest:    map[string]HandoffReport{},
+	}
+}
+
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.RunOnce(ctx)
+		}
+	}
+}
+
+func (s *Scheduler) RunOnce(ctx context.Context) {
+	for _, scope := range s.scopes() {
+		report, err := s.generator.Generate(ctx, scope)
+		if err != nil {
+			s.logger.Warn("failed to generate handoff report", "config", scope.Config, "cluster", scope.Cluster, "error", err)
+			continue
+		}
+		s.mu.Lock()
+		s.latest[scope.Config+"/"+scope.Cluster] = report
+		s.mu.Unlock()
+		if s.deliverer != nil {
+			if err := s.deliverer.Deliver(ctx, report); err != nil {
+				s.logger.Warn("failed to deliver handoff report", "report_id", report.ID, "error", err)
+			}
+		}
+	}
+}
+
+func (s *Scheduler) Latest(config, cluster string) (HandoffReport, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	report, ok := s.latest[config+"/"+cluster]
+	return report, ok
+}
@@ -0,0 +1,170 @@
+package reports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/inventory"
+	"github.com/pramodksahoo/kubechat/backend/internal/rightsizing"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ClientFunc resolves a clientset for the given kubeconfig and cluster context.
+type ClientFunc func(config, cluster string) kubernetes.Interface
+
+// FailingWorkloadsSource lists pods that are crash looping, stuck pending, or failed,
+// and deployments running below their desired availability.
+type FailingWorkloadsSource struct {
+	clients      ClientFunc
+	pendingAfter time.Duration
+	clock        func() time.Time
+}
+
+func NewFailingWorkloadsSource(clients ClientFunc) *FailingWorkloadsSource {
+	return &FailingWorkloadsSource{clients: clients, pendingAfter: 10 * time.Minute, clock: time.Now}
+}
+
+func (s *FailingWorkloadsSource) Name() string  { return "failing_workloads" }
+func (s *FailingWorkloadsSource) Title() string { return "Failing workloads" }
+
+func (s *FailingWorkloadsSource) Collect(ctx context.Context, scope Scope) ([]Item, error) {
+	client := s.clients(scope.Config, scope.Cluster)
+	var items []Item
+
+	pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods.Items {
+		if reason := podProblem(pod, s.clock(), s.pendingAfter); reason != "" {
+			items = append(items, Item{
+				Title:     fmt.Sprintf("Pod %s/%s", pod.Namespace, pod.Name),
+				Detail:    reason,
+				Severity:  "high",
+				Reference: "pods/" + pod.Namespace + "/" + pod.Name,
+			})
+		}
+	}
+
+	deployments, err := client.AppsV1().Deployments(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deployments.Items {
+		desired := int32(1)
+		if d.Spec.Replicas != nil {
+			desired = *d.Spec.Replicas
+		}
+		if desired > 0 && d.Status.AvailableReplicas < desired {
+			items = append(items, Item{
+				Title:     fmt.Sprintf("Deployment %s/%s", d.Namespace, d.Name),
+				Detail:    fmt.Sprintf("%d/%d replicas available", d.Status.AvailableReplicas, desired),
+				Severity:  "medium",
+				Reference: "deployments/" + d.Namespace + "/" + d.Name,
+			})
+		}
+	}
+	return items, nil
+}
+
+func podProblem(pod corev1.Pod, now time.Time, pendingAfter time.Duration) string {
+	switch pod.Status.Phase {
+	case corev1.PodFailed:
+		if pod.Status.Reason != "" {
+			return "failed: " + pod.Status.Reason
+		}
+		return "failed"
+	case corev1.PodPending:
+		if now.Sub(pod.CreationTimestamp.Time) > pendingAfter {
+			return "pending for " + now.Sub(pod.CreationTimestamp.Time).Round(time.Minute).String()
+		}
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if w := status.State.Waiting; w != nil && (w.Reason == "CrashLoopBackOff" || w.Reason == "ImagePullBackOff" || w.Reason == "ErrImagePull") {
+			return fmt.Sprintf("container %s %s (%d restarts)", status.Name, w.Reason, status.RestartCount)
+		}
+	}
+	return ""
+}
+
+type ChangeReader interface {
+	Changes(ctx context.Context, config, cluster string, since time.Duration) (inventory.Changelog, error)
+}
+
+// RecentChangesSource surfaces workload changes in the report window, highlighting out-of-band edits.
+type RecentChangesSource struct {
+	changes ChangeReader
+}
+
+func NewRecentChangesSource(changes ChangeReader) *RecentChangesSource {
+	return &RecentChangesSource{changes: changes}
+}
+
+func (s *RecentChangesSource) Name() string  { return "recent_changes" }
+func (s *RecentChangesSource) Title() string { return "Recent changes" }
+
+func (s *RecentChangesSource) Collect(ctx context.Context, scope Scope) ([]Item, error) {
+	changelog, err := s.changes.Changes(ctx, scope.Config, scope.Cluster, scope.Window)
+	if err != nil {
+		return nil, err
+	}
+	lines := inventory.Summarize(changelog.Changes)
+	items := make([]Item, 0, len(changelog.Changes))
+	for i, change := range changelog.Changes {
+		severity := "info"
+		if change.Attribution == inventory.AttributionOutOfBand || change.Type == inventory.ChangeRemoved {
+			severity = "medium"
+		}
+		items = append(items, Item{
+			Title:     lines[i],
+			Severity:  severity,
+			Reference: fmt.Sprintf("%s/%s/%s", change.Kind, change.Namespace, change.Name),
+		})
+	}
+	return items, nil
+}
+
+type RightSizingReader interface {
+	Recommend(ctx context.Context, config, cluster string, filter rightsizing.Filter) (rightsizing.Report, error)
+}
+
+// RightSizingSource lists deployments whose requests are far from their recorded usage, raised
+// requests first.
+type RightSizingSource struct {
+	recommender RightSizingReader
+}
+
+func NewRightSizingSource(recommender RightSizingReader) *RightSizingSource {
+	return &RightSizingSource{recommender: recommender}
+}
+
+func (s *RightSizingSource) Name() string  { return "right_sizing" }
+func (s *RightSizingSource) Title() string { return "Right-sizing" }
+
+func (s *RightSizingSource) Collect(ctx context.Context, scope Scope) ([]Item, error) {
+	report, err := s.recommender.Recommend(ctx, scope.Config, scope.Cluster, rightsizing.Filter{})
+	if errors.Is(err, rightsizing.ErrMetricsUnavailable) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	items := make([]Item, 0, len(report.Recommendations))
+	for _, rec := range report.Recommendations {
+		severity := "info"
+		if rec.UnderProvisioned() {
+			severity = "medium"
+		}
+		items = append(items, Item{
+			Title:     fmt.Sprintf("Deployment %s/%s", rec.Namespace, rec.Deployment),
+			Detail:    rec.Summary,
+			Severity:  severity,
+			Reference: "deployments/" + rec.Namespace + "/" + rec.Deployment,
+		})
+	}
+	return items, nil
+}
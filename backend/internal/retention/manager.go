@@ -0,0 +1,260 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+)
+
+// Manager stores retention policies and legal holds and enforces them on every target.
+type Manager struct {
+	repo    Repository
+	targets map[Category]Target
+	order   []Category
+	logger  *log.Logger
+	clock   func() time.Time
+
+	// enforcing serializes enforcement between the scheduled job and API changes.
+	enforcing sync.Mutex
+	mu        sync.Mutex
+	lastRuns  map[Category]Run
+}
+
+func NewManager(repo Repository, logger *log.Logger, targets ...Target) *Manager {
+	if logger == nil {
+		logger = log.Default()
+	}
+	m := &Manager{
+		repo:     repo,
+		targets:  map[Category]Target{},
+		logger:   logger,
+		clock:    func() time.Time { return time.Now().UTC() },
+		lastRuns: map[Category]Run{},
+	}
+	for _, target := range targets {
+		category := target.Info().Category
+		m.targets[category] = target
+		m.order = append(m.order, category)
+	}
+	return m
+}
+
+func (m *Manager) Overview(ctx context.Context) (Overview, error) {
+	policies, err := m.policies(ctx)
+	if err != nil {
+		return Overview{}, err
+	}
+	holds, err := m.repo.ListHolds(ctx)
+	if err != nil {
+		return Overview{}, err
+	}
+
+	overview := Overview{Categories: make([]CategoryStatus, 0, len(m.order)), Holds: holds}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, category := range m.order {
+		policy := policies[category]
+		status := CategoryStatus{TargetInfo: m.targets[category].Info(), Days: policy.Days, UpdatedBy: policy.UpdatedBy}
+		if !policy.UpdatedAt.IsZero() {
+			updated := policy.UpdatedAt
+			status.UpdatedAt = &updated
+		}
+		for _, hold := range holds {
+			if hold.Category == category {
+				status.Holds++
+			}
+		}
+		if run, ok := m.lastRuns[category]; ok {
+			status.LastRun = &run
+		}
+		overview.Categories = append(overview.Categories, status)
+	}
+	return overview, nil
+}
+
+// SetPolicy changes the retention window of a category and applies it immediately.
+func (m *Manager) SetPolicy(ctx context.Context, actor string, category Category, days int) (Policy, error) {
+	if _, ok := m.targets[category]; !ok {
+		return Policy{}, ErrCategoryNotFound{Category: category}
+	}
+	if days < 1 || days > MaxDays {
+		return Policy{}, ErrInvalidRequest{Reason: fmt.Sprintf("days must be between 1 and %d", MaxDays)}
+	}
+	policy := Policy{Category: category, Days: days, UpdatedBy: actor, UpdatedAt: m.clock()}
+	if err := m.repo.SavePolicy(ctx, policy); err != nil {
+		return Policy{}, err
+	}
+	m.enforce(ctx, category)
+	return policy, nil
+}
+
+// AddHold places a legal hold. Deletion in the held scope stops immediately.
+func (m *Manager) AddHold(ctx context.Context, actor string, hold Hold) (Hold, error) {
+	target, ok := m.targets[hold.Category]
+	if !ok {
+		return Hold{}, ErrCategoryNotFound{Category: hold.Category}
+	}
+	hold.Subject = strings.TrimSpace(hold.Subject)
+	hold.Reason = strings.TrimSpace(hold.Reason)
+	records := hold.Records
+	hold.Records = nil
+	for _, record := range records {
+		if record = strings.TrimSpace(record); record != "" && !slices.Contains(hold.Records, record) {
+			hold.Records = append(hold.Records, record)
+		}
+	}
+	info := target.Info()
+	switch {
+	case hold.Reason == "":
+		return Hold{}, ErrInvalidRequest{Reason: "reason is required"}
+	case hold.Subject != "" && len(hold.Records) > 0:
+		return Hold{}, ErrInvalidRequest{Reason: "a hold covers either a subject or records, not both"}
+	case hold.Subject != "" && info.Subject == "", len(hold.Records) > 0 && info.Record == "":
+		return Hold{}, ErrInvalidRequest{Reason: fmt.Sprintf("%s can only be held as a whole", hold.Category)}
+	}
+	hold.ID = uuid.NewString()
+	hold.CreatedBy = actor
+	hold.CreatedAt = m.clock()
+	if err := m.repo.SaveHold(ctx, hold); err != nil {
+		return Hold{}, err
+	}
+	m.enforce(ctx, hold.Category)
+	return hold, nil
+}
+
+// ReleaseHold removes a legal hold; the category's policy applies again on the next run.
+func (m *Manager) ReleaseHold(ctx context.Context, id string) error {
+	return m.repo.DeleteHold(ctx, id)
+}
+
+// Enforce applies every category's policy now.
+func (m *Manager) Enforce(ctx context.Context) []Run {
+	return m.enforce(ctx, m.order...)
+}
+
+// DryRun reports what enforcing every category would remove now, without deleting anything.
+func (m *Manager) DryRun(ctx context.Context) ([]Forecast, error) {
+	policies, enforcements, err := m.enforcements(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := m.clock()
+	forecasts := make([]Forecast, 0, len(m.order))
+	for _, category := range m.order {
+		enforcement := enforcements[category]
+		forecast := Forecast{
+			Category:     category,
+			Days:         policies[category].Days,
+			Cutoff:       now.Add(-enforcement.Window),
+			Held:         enforcement.HoldAll,
+			HeldSubjects: enforcement.HeldSubjects,
+			HeldRecords:  enforcement.HeldRecords,
+		}
+		eligible, err := m.targets[category].Forecast(ctx, enforcement)
+		forecast.Eligible = eligible
+		if err != nil {
+			forecast.Error = err.Error()
+		}
+		forecasts = append(forecasts, forecast)
+	}
+	return forecasts, nil
+}
+
+// Run enforces retention at start and then on every interval until the context is cancelled.
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.Enforce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Manager) enforce(ctx context.Context, categories ...Category) []Run {
+	m.enforcing.Lock()
+	defer m.enforcing.Unlock()
+
+	policies, enforcements, err := m.enforcements(ctx)
+	if err != nil {
+		// Without the holds nothing can be deleted safely.
+		m.logger.Error("failed to load retention settings", "error", err)
+		return nil
+	}
+
+	runs := make([]Run, 0, len(categories))
+	for _, category := range categories {
+		policy, enforcement := policies[category], enforcements[category]
+		run := Run{Category: category, Days: policy.Days, Held: enforcement.HoldAll, At: m.clock()}
+		removed, err := m.targets[category].Enforce(ctx, enforcement)
+		run.Removed = removed
+		if err != nil {
+			run.Error = err.Error()
+			m.logger.Error("failed to enforce retention", "category", category, "error", err)
+		} else if removed > 0 {
+			m.logger.Info("retention removed expired records", "category", category, "removed", removed, "days", policy.Days)
+		}
+		m.mu.Lock()
+		m.lastRuns[category] = run
+		m.mu.Unlock()
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// enforcements returns every category's policy with the enforcement its policy and holds call for.
+func (m *Manager) enforcements(ctx context.Context) (map[Category]Policy, map[Category]Enforcement, error) {
+	policies, policyErr := m.policies(ctx)
+	holds, holdErr := m.repo.ListHolds(ctx)
+	if err := errors.Join(policyErr, holdErr); err != nil {
+		return nil, nil, err
+	}
+	enforcements := make(map[Category]Enforcement, len(policies))
+	for category, policy := range policies {
+		enforcements[category] = Enforcement{Window: time.Duration(policy.Days) * 24 * time.Hour}
+	}
+	for _, hold := range holds {
+		enforcement, ok := enforcements[hold.Category]
+		if !ok {
+			continue
+		}
+		switch {
+		case hold.Subject != "":
+			enforcement.HeldSubjects = append(enforcement.HeldSubjects, hold.Subject)
+		case len(hold.Records) > 0:
+			enforcement.HeldRecords = append(enforcement.HeldRecords, hold.Records...)
+		default:
+			enforcement.HoldAll = true
+		}
+		enforcements[hold.Category] = enforcement
+	}
+	return policies, enforcements, nil
+}
+
+// policies returns the stored policy of every category, falling back to the target's default.
+func (m *Manager) policies(ctx context.Context) (map[Category]Policy, error) {
+	stored, err := m.repo.ListPolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	policies := make(map[Category]Policy, len(m.targets))
+	for category, target := range m.targets {
+		policies[category] = Policy{Category: category, Days: target.Info().DefaultDays}
+	}
+	for _, policy := range stored {
+		if _, ok := m.targets[policy.Category]; ok {
+			policies[policy.Category] = policy
+		}
+	}
+	return policies, nil
+}
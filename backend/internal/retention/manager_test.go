@@ -0,0 +1,158 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pramodksahoo/kubechat/backend/internal/health"
+)
+
+type fakePurger struct {
+	cutoff  time.Time
+	kept    []string
+	keptIDs []string
+	calls   int
+}
+
+func (p *fakePurger) Purge(ctx context.Context, cutoff time.Time, keepUsers, keepIDs []string) (int, error) {
+	p.cutoff, p.kept, p.keptIDs = cutoff, keepUsers, keepIDs
+	p.calls++
+	return 3, nil
+}
+
+func (p *fakePurger) Purgeable(ctx context.Context, cutoff time.Time, keepUsers, keepIDs []string) (int, error) {
+	p.cutoff, p.kept, p.keptIDs = cutoff, keepUsers, keepIDs
+	return 5, nil
+}
+
+func newTestManager(targets ...Target) *Manager {
+	return NewManager(NewMemoryRepository(), log.NewWithOptions(io.Discard, log.Options{}), targets...)
+}
+
+func TestPolicyAndSubjectHoldsReachExecutions(t *testing.T) {
+	purger := &fakePurger{}
+	m := newTestManager(ExecutionsTarget(purger))
+	ctx := context.Background()
+
+	if _, err := m.SetPolicy(ctx, "admin", CategoryExecutions, 0); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected zero days to be rejected, got %v", err)
+	}
+	if _, err := m.SetPolicy(ctx, "admin", "chat_transcripts", 30); !errors.As(err, &ErrCategoryNotFound{}) {
+		t.Fatalf("expected unknown category, got %v", err)
+	}
+	if _, err := m.AddHold(ctx, "legal", Hold{Category: CategoryExecutions, Subject: "mallory", Reason: "case 42"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := m.SetPolicy(ctx, "admin", CategoryExecutions, 30); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if age := time.Since(purger.cutoff); age < 30*24*time.Hour-time.Minute || age > 30*24*time.Hour+time.Minute {
+		t.Fatalf("expected a 30 day cutoff, got %s", age)
+	}
+	if !slices.Equal(purger.kept, []string{"mallory"}) {
+		t.Fatalf("expected held user to be kept, got %v", purger.kept)
+	}
+
+	overview, err := m.Overview(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	status := overview.Categories[0]
+	if status.Days != 30 || status.Holds != 1 || status.LastRun == nil || status.LastRun.Removed != 3 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	hold, err := m.AddHold(ctx, "legal", Hold{Category: CategoryExecutions, Reason: "audit"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	calls := purger.calls
+	if runs := m.Enforce(ctx); !runs[0].Held || purger.calls != calls {
+		t.Fatalf("expected a category hold to suspend deletion, got %+v", runs)
+	}
+	if err := m.ReleaseHold(ctx, hold.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if runs := m.Enforce(ctx); runs[0].Held || purger.calls != calls+1 {
+		t.Fatalf("expected deletion to resume after release, got %+v", runs)
+	}
+}
+
+func TestHealthHistoryHoldStopsPruning(t *testing.T) {
+	history := health.NewHistory(time.Hour)
+	m := newTestManager(HealthHistoryTarget(history))
+	ctx := context.Background()
+	now := time.Now()
+
+	if _, err := m.AddHold(ctx, "legal", Hold{Category: CategoryHealthHistory, Subject: "api", Reason: "x"}); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected subject holds to be rejected for health history, got %v", err)
+	}
+	if _, err := m.AddHold(ctx, "legal", Hold{Category: CategoryHealthHistory, Reason: "incident review"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	history.Record("api", now.Add(-10*24*time.Hour), false)
+	history.Record("api", now, true)
+	if uptime, _ := history.Uptime("api", 30*24*time.Hour, now); uptime != 50 {
+		t.Fatalf("expected held results to survive recording, got uptime %v", uptime)
+	}
+
+	overview, _ := m.Overview(ctx)
+	if err := m.ReleaseHold(ctx, overview.Holds[0].ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if runs := m.Enforce(ctx); runs[0].Removed != 1 {
+		t.Fatalf("expected the 10 day old result to be removed under the 7 day default, got %+v", runs)
+	}
+}
+
+func TestRecordHoldsAndDryRun(t *testing.T) {
+	purger := &fakePurger{}
+	history := health.NewHistory(time.Hour)
+	m := newTestManager(ExecutionsTarget(purger), HealthHistoryTarget(history))
+	ctx := context.Background()
+
+	if _, err := m.AddHold(ctx, "legal", Hold{Category: CategoryHealthHistory, Records: []string{"api"}, Reason: "x"}); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected record holds to be rejected for health history, got %v", err)
+	}
+	if _, err := m.AddHold(ctx, "legal", Hold{Category: CategoryExecutions, Subject: "mallory", Records: []string{"e1"}, Reason: "x"}); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected a hold on both a subject and records to be rejected, got %v", err)
+	}
+	hold, err := m.AddHold(ctx, "legal", Hold{Category: CategoryExecutions, Records: []string{" e1 ", "e2", "e1", ""}, Reason: "case 42"})
+	if err != nil || !slices.Equal(hold.Records, []string{"e1", "e2"}) {
+		t.Fatalf("expected the held records to be cleaned up, got %+v (%v)", hold, err)
+	}
+	if !slices.Equal(purger.keptIDs, []string{"e1", "e2"}) {
+		t.Fatalf("expected held records to be kept, got %v", purger.keptIDs)
+	}
+
+	// A hold stops the history pruning itself while results are recorded.
+	healthHold, _ := m.AddHold(ctx, "legal", Hold{Category: CategoryHealthHistory, Reason: "incident review"})
+	now := time.Now()
+	history.Record("api", now.Add(-10*24*time.Hour), false)
+	history.Record("api", now, true)
+	if err := m.ReleaseHold(ctx, healthHold.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	calls := purger.calls
+	forecasts, err := m.DryRun(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if purger.calls != calls {
+		t.Fatalf("expected a dry run not to purge anything")
+	}
+	if forecasts[0].Eligible != 5 || !slices.Equal(forecasts[0].HeldRecords, []string{"e1", "e2"}) {
+		t.Fatalf("unexpected executions forecast %+v", forecasts[0])
+	}
+	if forecasts[1].Eligible != 1 {
+		t.Fatalf("expected the 10 day old health result to be eligible, got %+v", forecasts[1])
+	}
+	if uptime, _ := history.Uptime("api", 30*24*time.Hour, now); uptime != 50 {
+		t.Fatalf("expected a dry run to keep health results, got uptime %v", uptime)
+	}
+}
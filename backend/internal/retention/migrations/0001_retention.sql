@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS retention_policies (
+    category TEXT PRIMARY KEY,
+    days INTEGER NOT NULL,
+    updated_by TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS legal_holds (
+    id TEXT PRIMARY KEY,
+    category TEXT NOT NULL,
+    subject TEXT NOT NULL DEFAULT '',
+    reason TEXT NOT NULL,
+    created_by TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1 @@
+ALTER TABLE legal_holds ADD COLUMN IF NOT EXISTS records JSONB NOT NULL DEFAULT '[]';
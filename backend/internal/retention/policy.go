@@ -0,0 +1,102 @@
+// Package retention applies per-category retention windows to stored data and suspends deletion
+// for records under legal hold.
+package retention
+
+import (
+	"fmt"
+	"time"
+)
+
+// Category names one kind of stored data with its own retention window.
+type Category string
+
+const (
+	CategoryExecutions    Category = "execution_records"
+	CategoryHealthHistory Category = "health_history"
+	CategoryChangeHistory Category = "change_history"
+	CategoryAuditLedger   Category = "audit_ledger"
+)
+
+// MaxDays bounds a retention window to ten years.
+const MaxDays = 3650
+
+type Policy struct {
+	Category  Category  `json:"category"`
+	Days      int       `json:"days"`
+	UpdatedBy string    `json:"updatedBy,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Hold suspends deletion in a category. A hold with neither Subject nor Records covers the whole
+// category; otherwise only records of that subject, such as one user's executions, or the listed
+// records are kept.
+type Hold struct {
+	ID        string    `json:"id"`
+	Category  Category  `json:"category"`
+	Subject   string    `json:"subject,omitempty"`
+	Records   []string  `json:"records,omitempty"`
+	Reason    string    `json:"reason"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Run is the outcome of enforcing one category.
+type Run struct {
+	Category Category  `json:"category"`
+	Days     int       `json:"days"`
+	Removed  int       `json:"removed"`
+	Held     bool      `json:"held"`
+	Error    string    `json:"error,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// Forecast is what enforcing a category would remove now, worked out without deleting anything.
+type Forecast struct {
+	Category     Category  `json:"category"`
+	Days         int       `json:"days"`
+	Cutoff       time.Time `json:"cutoff"`
+	Eligible     int       `json:"eligible"`
+	Held         bool      `json:"held"`
+	HeldSubjects []string  `json:"heldSubjects,omitempty"`
+	HeldRecords  []string  `json:"heldRecords,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// CategoryStatus describes a category with its effective policy, holds and last enforcement.
+type CategoryStatus struct {
+	TargetInfo
+	Days      int        `json:"days"`
+	UpdatedBy string     `json:"updatedBy,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+	Holds     int        `json:"holds"`
+	LastRun   *Run       `json:"lastRun,omitempty"`
+}
+
+type Overview struct {
+	Categories []CategoryStatus `json:"categories"`
+	Holds      []Hold           `json:"holds"`
+}
+
+type ErrCategoryNotFound struct {
+	Category Category
+}
+
+func (e ErrCategoryNotFound) Error() string {
+	return fmt.Sprintf("retention category %s not found", e.Category)
+}
+
+type ErrHoldNotFound struct {
+	ID string
+}
+
+func (e ErrHoldNotFound) Error() string {
+	return fmt.Sprintf("legal hold %s not found", e.ID)
+}
+
+type ErrInvalidRequest struct {
+	Reason string
+}
+
+func (e ErrInvalidRequest) Error() string {
+	return e.Reason
+}
@@ -0,0 +1,124 @@
+package retention
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pramodksahoo/kubechat/backend/internal/database"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+type PostgresRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresRepository applies the retention schema migrations and returns a repository backed by db.
+func NewPostgresRepository(ctx context.Context, db *sqlx.DB) (*PostgresRepository, error) {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Migrate(ctx, db, "retention", sub); err != nil {
+		return nil, err
+	}
+	return &PostgresRepository{db: db}, nil
+}
+
+type policyRow struct {
+	Category  string    `db:"category"`
+	Days      int       `db:"days"`
+	UpdatedBy string    `db:"updated_by"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+type holdRow struct {
+	ID        string    `db:"id"`
+	Category  string    `db:"category"`
+	Subject   string    `db:"subject"`
+	Records   []byte    `db:"records"`
+	Reason    string    `db:"reason"`
+	CreatedBy string    `db:"created_by"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func (r *PostgresRepository) SavePolicy(ctx context.Context, policy Policy) error {
+	_, err := r.db.NamedExecContext(ctx, `INSERT INTO retention_policies (category, days, updated_by, updated_at)
+		VALUES (:category, :days, :updated_by, :updated_at)
+		ON CONFLICT (category) DO UPDATE SET days = EXCLUDED.days, updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at`,
+		policyRow{Category: string(policy.Category), Days: policy.Days, UpdatedBy: policy.UpdatedBy, UpdatedAt: policy.UpdatedAt})
+	return err
+}
+
+func (r *PostgresRepository) ListPolicies(ctx context.Context) ([]Policy, error) {
+	var rows []policyRow
+	if err := r.db.SelectContext(ctx, &rows, `SELECT category, days, updated_by, updated_at FROM retention_policies ORDER BY category`); err != nil {
+		return nil, err
+	}
+	policies := make([]Policy, 0, len(rows))
+	for _, row := range rows {
+		policies = append(policies, Policy{Category: Category(row.Category), Days: row.Days, UpdatedBy: row.UpdatedBy, UpdatedAt: row.UpdatedAt.UTC()})
+	}
+	return policies, nil
+}
+
+func (r *PostgresRepository) SaveHold(ctx context.Context, hold Hold) error {
+	records, err := json.Marshal(append([]string{}, hold.Records...))
+	if err != nil {
+		return err
+	}
+	_, err = r.db.NamedExecContext(ctx, `INSERT INTO legal_holds (id, category, subject, records, reason, created_by, created_at)
+		VALUES (:id, :category, :subject, :records, :reason, :created_by, :created_at)`, holdRow{
+		ID:        hold.ID,
+		Category:  string(hold.Category),
+		Subject:   hold.Subject,
+		Records:   records,
+		Reason:    hold.Reason,
+		CreatedBy: hold.CreatedBy,
+		CreatedAt: hold.CreatedAt,
+	})
+	return err
+}
+
+func (r *PostgresRepository) ListHolds(ctx context.Context) ([]Hold, error) {
+	var rows []holdRow
+	if err := r.db.SelectContext(ctx, &rows, `SELECT id, category, subject, records, reason, created_by, created_at FROM legal_holds ORDER BY created_at`); err != nil {
+		return nil, err
+	}
+	holds := make([]Hold, 0, len(rows))
+	for _, row := range rows {
+		hold := Hold{
+			ID:        row.ID,
+			Category:  Category(row.Category),
+			Subject:   row.Subject,
+			Reason:    row.Reason,
+			CreatedBy: row.CreatedBy,
+			CreatedAt: row.CreatedAt.UTC(),
+		}
+		if err := json.Unmarshal(row.Records, &hold.Records); err != nil {
+			return nil, fmt.Errorf("decode records of legal hold %s: %w", row.ID, err)
+		}
+		if len(hold.Records) == 0 {
+			hold.Records = nil
+		}
+		holds = append(holds, hold)
+	}
+	return holds, nil
+}
+
+func (r *PostgresRepository) DeleteHold(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM legal_holds WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrHoldNotFound{ID: id}
+	}
+	return nil
+}
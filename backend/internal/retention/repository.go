@@ -0,0 +1,76 @@
+package retention
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Repository persists retention policies and legal holds.
+type Repository interface {
+	SavePolicy(ctx context.Context, policy Policy) error
+	ListPolicies(ctx context.Context) ([]Policy, error)
+	SaveHold(ctx context.Context, hold Hold) error
+	ListHolds(ctx context.Context) ([]Hold, error)
+	DeleteHold(ctx context.Context, id string) error
+}
+
+// MemoryRepository keeps policies and holds in process memory; it is used when no database is configured.
+type MemoryRepository struct {
+	mu       sync.RWMutex
+	policies map[Category]Policy
+	holds    map[string]Hold
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		policies: make(map[Category]Policy),
+		holds:    make(map[string]Hold),
+	}
+}
+
+func (r *MemoryRepository) SavePolicy(ctx context.Context, policy Policy) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.policies[policy.Category] = policy
+	return nil
+}
+
+func (r *MemoryRepository) ListPolicies(ctx context.Context) ([]Policy, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	policies := make([]Policy, 0, len(r.policies))
+	for _, policy := range r.policies {
+		policies = append(policies, policy)
+	}
+	sort.Slice(policies, func(i, j int) bool { return policies[i].Category < policies[j].Category })
+	return policies, nil
+}
+
+func (r *MemoryRepository) SaveHold(ctx context.Context, hold Hold) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.holds[hold.ID] = hold
+	return nil
+}
+
+func (r *MemoryRepository) ListHolds(ctx context.Context) ([]Hold, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	holds := make([]Hold, 0, len(r.holds))
+	for _, hold := range r.holds {
+		holds = append(holds, hold)
+	}
+	sort.Slice(holds, func(i, j int) bool { return holds[i].CreatedAt.Before(holds[j].CreatedAt) })
+	return holds, nil
+}
+
+func (r *MemoryRepository) DeleteHold(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.holds[id]; !ok {
+		return ErrHoldNotFound{ID: id}
+	}
+	delete(r.holds, id)
+	return nil
+}
@@ -0,0 +1,173 @@
+package retention
+
+import (
+	"context"
+	"time"
+)
+
+// TargetInfo describes a category of data. Subject names what a subject hold matches and Record
+// what identifies the records a record hold lists; holds in categories without either always
+// cover the whole category.
+type TargetInfo struct {
+	Category    Category `json:"category"`
+	Description string   `json:"description"`
+	Subject     string   `json:"subject,omitempty"`
+	Record      string   `json:"record,omitempty"`
+	DefaultDays int      `json:"defaultDays"`
+}
+
+// Enforcement is the retention a target must apply.
+type Enforcement struct {
+	Window time.Duration
+	// HeldSubjects and HeldRecords must not be deleted; HoldAll suspends deletion in the
+	// category entirely.
+	HeldSubjects []string
+	HeldRecords  []string
+	HoldAll      bool
+}
+
+// Target is a store of one category of data.
+type Target interface {
+	Info() TargetInfo
+	// Enforce deletes records older than the window, honouring holds, and returns how many it
+	// removed. Stores that prune on their own must also adopt the window, or stop pruning while
+	// the category is held.
+	Enforce(ctx context.Context, enforcement Enforcement) (int, error)
+	// Forecast counts the records Enforce would remove, without changing anything.
+	Forecast(ctx context.Context, enforcement Enforcement) (int, error)
+}
+
+// TargetFunc adapts functions to the Target interface.
+type TargetFunc struct {
+	TargetInfo
+	Fn         func(ctx context.Context, enforcement Enforcement) (int, error)
+	ForecastFn func(ctx context.Context, enforcement Enforcement) (int, error)
+}
+
+func (t TargetFunc) Info() TargetInfo { return t.TargetInfo }
+
+func (t TargetFunc) Enforce(ctx context.Context, enforcement Enforcement) (int, error) {
+	return t.Fn(ctx, enforcement)
+}
+
+func (t TargetFunc) Forecast(ctx context.Context, enforcement Enforcement) (int, error) {
+	if enforcement.HoldAll {
+		return 0, nil
+	}
+	return t.ForecastFn(ctx, enforcement)
+}
+
+type ExecutionPurger interface {
+	// Purge archives and then deletes finished executions created before cutoff, except those
+	// requested by keepUsers or listed in keepIDs.
+	Purge(ctx context.Context, cutoff time.Time, keepUsers, keepIDs []string) (int, error)
+	Purgeable(ctx context.Context, cutoff time.Time, keepUsers, keepIDs []string) (int, error)
+}
+
+// ExecutionsTarget deletes finished command executions with their approvals, rollback plans and
+// attachments. Subject holds are matched against the requesting user and record holds against
+// the execution ID.
+func ExecutionsTarget(purger ExecutionPurger) Target {
+	return TargetFunc{
+		TargetInfo: TargetInfo{
+			Category:    CategoryExecutions,
+			Description: "Command executions with their approval trail, rollback plans and output attachments",
+			Subject:     "user",
+			Record:      "execution ID",
+			DefaultDays: 90,
+		},
+		Fn: func(ctx context.Context, enforcement Enforcement) (int, error) {
+			if enforcement.HoldAll {
+				return 0, nil
+			}
+			return purger.Purge(ctx, time.Now().Add(-enforcement.Window), enforcement.HeldSubjects, enforcement.HeldRecords)
+		},
+		ForecastFn: func(ctx context.Context, enforcement Enforcement) (int, error) {
+			return purger.Purgeable(ctx, time.Now().Add(-enforcement.Window), enforcement.HeldSubjects, enforcement.HeldRecords)
+		},
+	}
+}
+
+// RollingStore prunes on its own as data is recorded.
+type RollingStore interface {
+	SetRetention(retention time.Duration)
+}
+
+// HealthHistoryTarget applies retention to aggregated health check results.
+func HealthHistoryTarget(history interface {
+	RollingStore
+	Prune(now time.Time) int
+	Expired(retention time.Duration, now time.Time) int
+}) Target {
+	return TargetFunc{
+		TargetInfo: TargetInfo{
+			Category:    CategoryHealthHistory,
+			Description: "Aggregated health check results used for uptime on the status page",
+			DefaultDays: 7,
+		},
+		Fn: func(ctx context.Context, enforcement Enforcement) (int, error) {
+			if enforcement.HoldAll {
+				history.SetRetention(0)
+				return 0, nil
+			}
+			history.SetRetention(enforcement.Window)
+			return history.Prune(time.Now()), nil
+		},
+		ForecastFn: func(ctx context.Context, enforcement Enforcement) (int, error) {
+			return history.Expired(enforcement.Window, time.Now()), nil
+		},
+	}
+}
+
+// ChangeHistoryTarget applies retention to the inventory snapshots behind change history.
+func ChangeHistoryTarget(tracker interface {
+	RollingStore
+	Prune() int
+	Expired(retention time.Duration) int
+}) Target {
+	return TargetFunc{
+		TargetInfo: TargetInfo{
+			Category:    CategoryChangeHistory,
+			Description: "Inventory snapshots used to report workload changes and container restarts",
+			DefaultDays: 2,
+		},
+		Fn: func(ctx context.Context, enforcement Enforcement) (int, error) {
+			if enforcement.HoldAll {
+				tracker.SetRetention(0)
+				return 0, nil
+			}
+			tracker.SetRetention(enforcement.Window)
+			return tracker.Prune(), nil
+		},
+		ForecastFn: func(ctx context.Context, enforcement Enforcement) (int, error) {
+			return tracker.Expired(enforcement.Window), nil
+		},
+	}
+}
+
+type LedgerArchiver interface {
+	Archive(ctx context.Context, before time.Time) (int, error)
+	Archivable(ctx context.Context, before time.Time) (int, error)
+}
+
+// AuditLedgerTarget moves audit ledger records past the window to the archive store before they
+// are deleted from the database. The ledger is only held as a whole, since archives are cut from
+// the chain in sequence.
+func AuditLedgerTarget(archiver LedgerArchiver) Target {
+	return TargetFunc{
+		TargetInfo: TargetInfo{
+			Category:    CategoryAuditLedger,
+			Description: "Audit ledger records, archived encrypted to object storage and restorable for queries",
+			DefaultDays: 365,
+		},
+		Fn: func(ctx context.Context, enforcement Enforcement) (int, error) {
+			if enforcement.HoldAll {
+				return 0, nil
+			}
+			return archiver.Archive(ctx, time.Now().Add(-enforcement.Window))
+		},
+		ForecastFn: func(ctx context.Context, enforcement Enforcement) (int, error) {
+			return archiver.Archivable(ctx, time.Now().Add(-enforcement.Window))
+		},
+	}
+}
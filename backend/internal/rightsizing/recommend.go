@@ -0,0 +1,294 @@
+package rightsizing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/commands"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// minSamples is the usage history a container needs before it gets a recommendation.
+	minSamples = 12
+	// cpuHeadroom and memoryHeadroom are added on top of p95 CPU and peak memory usage.
+	cpuHeadroom    = 1.15
+	memoryHeadroom = 1.2
+	// tolerance is the relative difference from the current request worth changing.
+	tolerance = 0.2
+
+	cpuStep    = 5
+	minCPU     = 10
+	mebibyte   = 1 << 20
+	minMemory  = 32 * mebibyte
+	minCPUDiff = 10
+	minMemDiff = 16 * mebibyte
+)
+
+// Change says which way a recommendation moves a request.
+type Change string
+
+const (
+	ChangeIncrease Change = "increase"
+	ChangeDecrease Change = "decrease"
+	// ChangeSet gives a request to a container that has none.
+	ChangeSet Change = "set"
+)
+
+// Filter narrows recommendations to a namespace or deployment and sets the usage window.
+type Filter struct {
+	Namespace  string
+	Deployment string
+	Window     time.Duration
+}
+
+// ResourceAdvice compares a container's usage of one resource with its request and limit.
+type ResourceAdvice struct {
+	// Usage is p95 usage for CPU and peak usage for memory.
+	Usage       string `json:"usage"`
+	Request     string `json:"request,omitempty"`
+	Limit       string `json:"limit,omitempty"`
+	Recommended string `json:"recommended"`
+	// RecommendedLimit raises a limit that would otherwise sit below the recommended request.
+	RecommendedLimit string `json:"recommendedLimit,omitempty"`
+	Change           Change `json:"change"`
+}
+
+type ContainerAdvice struct {
+	Name    string          `json:"name"`
+	Samples int             `json:"samples"`
+	CPU     *ResourceAdvice `json:"cpu,omitempty"`
+	Memory  *ResourceAdvice `json:"memory,omitempty"`
+}
+
+// Recommendation is the request changes for one deployment, with the patch command applying them.
+type Recommendation struct {
+	Namespace  string            `json:"namespace"`
+	Deployment string            `json:"deployment"`
+	Containers []ContainerAdvice `json:"containers"`
+	Summary    string            `json:"summary"`
+	// Command is a request body for the command executions API; submitting it runs the patch
+	// through the usual safety checks and approvals.
+	Command commands.Request `json:"command"`
+}
+
+// UnderProvisioned reports whether any request is raised, which usually means throttling or OOM kills.
+func (r Recommendation) UnderProvisioned() bool {
+	for _, container := range r.Containers {
+		for _, advice := range []*ResourceAdvice{container.CPU, container.Memory} {
+			if advice != nil && advice.Change != ChangeDecrease {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type Report struct {
+	Config          string           `json:"config"`
+	Cluster         string           `json:"cluster"`
+	From            time.Time        `json:"from"`
+	To              time.Time        `json:"to"`
+	Recommendations []Recommendation `json:"recommendations"`
+	// Collecting counts deployments with too little usage history to judge yet.
+	Collecting int  `json:"collecting"`
+	Baselined  bool `json:"baselined"`
+}
+
+// Recommend samples the cluster, then compares the usage recorded in the window with the
+// requests of each deployment's containers.
+func (s *Sampler) Recommend(ctx context.Context, config, cluster string, filter Filter) (Report, error) {
+	if filter.Window <= 0 {
+		filter.Window = s.retention
+	}
+	s.mu.Lock()
+	_, baselined := s.tracked[clusterKey(config, cluster)]
+	s.mu.Unlock()
+
+	if err := s.Capture(ctx, config, cluster); err != nil {
+		return Report{}, err
+	}
+	now := s.clock()
+	from := now.Add(-filter.Window)
+	history := s.samples(config, cluster, from)
+
+	client, _ := s.clients(config, cluster)
+	deployments, err := client.AppsV1().Deployments(filter.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return Report{}, err
+	}
+	report := Report{Config: config, Cluster: cluster, From: from, To: now, Recommendations: []Recommendation{}, Baselined: !baselined}
+	for _, deployment := range deployments.Items {
+		if filter.Deployment != "" && deployment.Name != filter.Deployment {
+			continue
+		}
+		rec, collecting := recommend(deployment, history)
+		if collecting {
+			report.Collecting++
+		}
+		if len(rec.Containers) == 0 {
+			continue
+		}
+		rec.Command = command(config, cluster, deployment, rec.Containers)
+		report.Recommendations = append(report.Recommendations, rec)
+	}
+	sort.Slice(report.Recommendations, func(i, j int) bool {
+		a, b := report.Recommendations[i], report.Recommendations[j]
+		if a.UnderProvisioned() != b.UnderProvisioned() {
+			return a.UnderProvisioned()
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Deployment < b.Deployment
+	})
+	return report, nil
+}
+
+// recommend returns the advice for the deployment's containers, and whether any container still
+// lacks the history to be judged.
+func recommend(deployment appsv1.Deployment, history map[containerKey][]Sample) (Recommendation, bool) {
+	rec := Recommendation{Namespace: deployment.Namespace, Deployment: deployment.Name}
+	collecting := false
+	var changes []string
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		samples := history[containerKey{Namespace: deployment.Namespace, Deployment: deployment.Name, Container: container.Name}]
+		if len(samples) < minSamples {
+			collecting = true
+			continue
+		}
+		cpu := make([]int64, len(samples))
+		var peak int64
+		for i, sample := range samples {
+			cpu[i] = sample.CPU
+			peak = max(peak, sample.Memory)
+		}
+		advice := ContainerAdvice{
+			Name:    container.Name,
+			Samples: len(samples),
+			CPU:     cpuAdvice(container.Resources, percentile(cpu, 0.95)),
+			Memory:  memoryAdvice(container.Resources, peak),
+		}
+		if advice.CPU == nil && advice.Memory == nil {
+			continue
+		}
+		rec.Containers = append(rec.Containers, advice)
+		if advice.CPU != nil {
+			changes = append(changes, describe(container.Name, "CPU", advice.CPU))
+		}
+		if advice.Memory != nil {
+			changes = append(changes, describe(container.Name, "memory", advice.Memory))
+		}
+	}
+	if len(changes) > 0 {
+		rec.Summary = fmt.Sprintf("Deployment %s/%s: %s", deployment.Namespace, deployment.Name, strings.Join(changes, "; "))
+	}
+	return rec, collecting
+}
+
+func describe(container, name string, advice *ResourceAdvice) string {
+	if advice.Change == ChangeSet {
+		return fmt.Sprintf("set %s %s request to %s (usage %s)", container, name, advice.Recommended, advice.Usage)
+	}
+	return fmt.Sprintf("%s %s %s request from %s to %s (usage %s)", advice.Change, container, name, advice.Request, advice.Recommended, advice.Usage)
+}
+
+func cpuAdvice(resources corev1.ResourceRequirements, p95 int64) *ResourceAdvice {
+	recommended := max(roundUp(int64(math.Ceil(float64(p95)*cpuHeadroom)), cpuStep), minCPU)
+	return advise(resources, corev1.ResourceCPU, recommended, minCPUDiff,
+		func(q *resource.Quantity) int64 { return q.MilliValue() },
+		func(v int64) *resource.Quantity { return resource.NewMilliQuantity(v, resource.DecimalSI) },
+		resource.NewMilliQuantity(p95, resource.DecimalSI))
+}
+
+func memoryAdvice(resources corev1.ResourceRequirements, peak int64) *ResourceAdvice {
+	recommended := max(roundUp(int64(math.Ceil(float64(peak)*memoryHeadroom)), mebibyte), minMemory)
+	return advise(resources, corev1.ResourceMemory, recommended, minMemDiff,
+		func(q *resource.Quantity) int64 { return q.Value() },
+		func(v int64) *resource.Quantity { return resource.NewQuantity(v, resource.BinarySI) },
+		resource.NewQuantity(roundUp(peak, mebibyte), resource.BinarySI))
+}
+
+// advise returns nil when the current request is within tolerance of the recommended one.
+func advise(resources corev1.ResourceRequirements, name corev1.ResourceName, recommended, minDiff int64,
+	value func(*resource.Quantity) int64, quantity func(int64) *resource.Quantity, usage *resource.Quantity) *ResourceAdvice {
+	advice := &ResourceAdvice{Usage: usage.String(), Recommended: quantity(recommended).String(), Change: ChangeSet}
+	if request, ok := resources.Requests[name]; ok && !request.IsZero() {
+		current := value(&request)
+		diff := recommended - current
+		if abs(diff) < minDiff || float64(abs(diff)) <= tolerance*float64(current) {
+			return nil
+		}
+		advice.Request = request.String()
+		advice.Change = ChangeIncrease
+		if diff < 0 {
+			advice.Change = ChangeDecrease
+		}
+	}
+	if limit, ok := resources.Limits[name]; ok && !limit.IsZero() {
+		advice.Limit = limit.String()
+		if value(&limit) < recommended {
+			advice.RecommendedLimit = advice.Recommended
+		}
+	}
+	return advice
+}
+
+// command builds the strategic merge patch setting the recommended requests, and any limits that
+// would otherwise fall below them.
+func command(config, cluster string, deployment appsv1.Deployment, containers []ContainerAdvice) commands.Request {
+	patched := make([]map[string]any, 0, len(containers))
+	for _, container := range containers {
+		requests, limits := map[string]string{}, map[string]string{}
+		for name, advice := range map[string]*ResourceAdvice{"cpu": container.CPU, "memory": container.Memory} {
+			if advice == nil {
+				continue
+			}
+			requests[name] = advice.Recommended
+			if advice.RecommendedLimit != "" {
+				limits[name] = advice.RecommendedLimit
+			}
+		}
+		resources := map[string]any{"requests": requests}
+		if len(limits) > 0 {
+			resources["limits"] = limits
+		}
+		patched = append(patched, map[string]any{"name": container.Name, "resources": resources})
+	}
+	patch, _ := json.Marshal(map[string]any{"spec": map[string]any{"template": map[string]any{"spec": map[string]any{"containers": patched}}}})
+	return commands.Request{
+		Config:     config,
+		Cluster:    cluster,
+		Operation:  commands.OperationPatch,
+		Resource:   commands.Resource{Kind: "deployment", Namespace: deployment.Namespace, Name: deployment.Name},
+		Parameters: map[string]string{"patchType": "strategic", "patch": string(patch)},
+	}
+}
+
+// percentile returns the nearest-rank percentile of values.
+func percentile(values []int64, p float64) int64 {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(rank, 0)]
+}
+
+func roundUp(value, step int64) int64 {
+	return (value + step - 1) / step * step
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,137 @@
+package rightsizing
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/commands"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
+)
+
+var podMetricsResource = metricsv1beta1.SchemeGroupVersion.WithResource("pods")
+
+func deployment(name string, requests, limits corev1.ResourceList) (*appsv1.Deployment, *appsv1.ReplicaSet) {
+	controller := true
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name:      "app",
+			Resources: corev1.ResourceRequirements{Requests: requests, Limits: limits},
+		}}}}},
+	}, &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name:            name + "-5d8f9",
+		Namespace:       "shop",
+		OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: name, Controller: &controller}},
+	}}
+}
+
+func usage(name, cpu, memory string) *metricsv1beta1.PodMetrics {
+	return &metricsv1beta1.PodMetrics{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop"},
+		Containers: []metricsv1beta1.ContainerMetrics{{
+			Name:  "app",
+			Usage: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu), corev1.ResourceMemory: resource.MustParse(memory)},
+		}},
+	}
+}
+
+func TestRecommendAdjustsRequestsToUsage(t *testing.T) {
+	api, apiSet := deployment("api", corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("1"),
+		corev1.ResourceMemory: resource.MustParse("256Mi"),
+	}, corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")})
+	worker, workerSet := deployment("worker", corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("100m"),
+		corev1.ResourceMemory: resource.MustParse("128Mi"),
+	}, nil)
+	fresh, freshSet := deployment("fresh", nil, nil)
+	client := fake.NewSimpleClientset(api, apiSet, worker, workerSet, fresh, freshSet)
+	metrics := metricsfake.NewSimpleClientset()
+	for _, pod := range []*metricsv1beta1.PodMetrics{
+		usage("api-5d8f9-abcde", "200m", "300Mi"),
+		usage("worker-5d8f9-fghij", "90m", "110Mi"),
+	} {
+		if err := metrics.Tracker().Create(podMetricsResource, pod, "shop"); err != nil {
+			t.Fatalf("seed metrics: %v", err)
+		}
+	}
+
+	sampler := NewSampler(func(config, cluster string) (kubernetes.Interface, metricsclient.Interface) {
+		return client, metrics
+	}, 24*time.Hour, nil)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	sampler.clock = func() time.Time { return now }
+
+	first, err := sampler.Recommend(context.Background(), "cfg", "prod", Filter{})
+	if err != nil {
+		t.Fatalf("recommend: %v", err)
+	}
+	if !first.Baselined || len(first.Recommendations) != 0 || first.Collecting != 3 {
+		t.Fatalf("expected every deployment to still be collecting, got %+v", first)
+	}
+	for range minSamples {
+		now = now.Add(5 * time.Minute)
+		if err := sampler.Capture(context.Background(), "cfg", "prod"); err != nil {
+			t.Fatalf("capture: %v", err)
+		}
+	}
+
+	report, err := sampler.Recommend(context.Background(), "cfg", "prod", Filter{Namespace: "shop"})
+	if err != nil {
+		t.Fatalf("recommend: %v", err)
+	}
+	if report.Baselined || report.Collecting != 1 || len(report.Recommendations) != 1 {
+		t.Fatalf("expected only api to need new requests, got %+v", report)
+	}
+	rec := report.Recommendations[0]
+	app := rec.Containers[0]
+	if rec.Deployment != "api" || app.CPU == nil || app.CPU.Change != ChangeDecrease || app.CPU.Recommended != "230m" {
+		t.Fatalf("expected the idle CPU request to shrink to 230m, got %+v", app.CPU)
+	}
+	if app.Memory == nil || app.Memory.Change != ChangeIncrease || app.Memory.Recommended != "360Mi" || app.Memory.RecommendedLimit != "360Mi" {
+		t.Fatalf("expected memory request and limit raised to 360Mi, got %+v", app.Memory)
+	}
+	if !rec.UnderProvisioned() || rec.Summary == "" {
+		t.Fatalf("expected an under-provisioned recommendation with a summary, got %+v", rec)
+	}
+
+	if rec.Command.Operation != commands.OperationPatch || rec.Command.Resource != (commands.Resource{Kind: "deployment", Namespace: "shop", Name: "api"}) {
+		t.Fatalf("unexpected command %+v", rec.Command)
+	}
+	var patch struct {
+		Spec struct {
+			Template struct {
+				Spec corev1.PodSpec `json:"spec"`
+			} `json:"template"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal([]byte(rec.Command.Parameters["patch"]), &patch); err != nil {
+		t.Fatalf("decode patch: %v", err)
+	}
+	resources := patch.Spec.Template.Spec.Containers[0].Resources
+	if resources.Requests.Cpu().String() != "230m" || resources.Requests.Memory().String() != "360Mi" || resources.Limits.Memory().String() != "360Mi" {
+		t.Fatalf("unexpected patched resources %+v", resources)
+	}
+	if level := commands.Classify(rec.Command.Operation, rec.Command.Resource, rec.Command.Parameters); level != commands.SafetyWarning {
+		t.Fatalf("expected the patch to be classified like any other patch, got %s", level)
+	}
+}
+
+func TestRecommendWithoutMetricsAPI(t *testing.T) {
+	sampler := NewSampler(func(config, cluster string) (kubernetes.Interface, metricsclient.Interface) {
+		return fake.NewSimpleClientset(), nil
+	}, 0, nil)
+	if _, err := sampler.Recommend(context.Background(), "cfg", "prod", Filter{}); err != ErrMetricsUnavailable {
+		t.Fatalf("expected ErrMetricsUnavailable, got %v", err)
+	}
+}
@@ -0,0 +1,204 @@
+// Package rightsizing samples container usage from the metrics API and compares it with the
+// requests and limits of deployments to recommend better ones.
+package rightsizing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// ClientFunc resolves the clientset and metrics clientset for the given kubeconfig and cluster
+// context. The metrics clientset is nil when the cluster has no metrics API.
+type ClientFunc func(config, cluster string) (kubernetes.Interface, metricsclient.Interface)
+
+// ErrMetricsUnavailable is returned for clusters without a metrics API to sample.
+var ErrMetricsUnavailable = errors.New("the cluster does not serve the metrics API")
+
+// Sample is the usage of one replica's container at a point in time.
+type Sample struct {
+	At time.Time
+	// CPU is in millicores and Memory in bytes.
+	CPU    int64
+	Memory int64
+}
+
+// Sampler keeps a rolling window of container usage per deployment. Clusters are sampled once
+// recommendations are first requested for them, then on every Run tick.
+type Sampler struct {
+	clients   ClientFunc
+	retention time.Duration
+	clock     func() time.Time
+	logger    *log.Logger
+
+	mu      sync.Mutex
+	history map[string]map[containerKey][]Sample
+	tracked map[string][2]string
+}
+
+type containerKey struct {
+	Namespace  string
+	Deployment string
+	Container  string
+}
+
+func NewSampler(clients ClientFunc, retention time.Duration, logger *log.Logger) *Sampler {
+	if retention <= 0 {
+		retention = 7 * 24 * time.Hour
+	}
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Sampler{
+		clients:   clients,
+		retention: retention,
+		clock:     time.Now,
+		logger:    logger,
+		history:   map[string]map[containerKey][]Sample{},
+		tracked:   map[string][2]string{},
+	}
+}
+
+func clusterKey(config, cluster string) string {
+	return config + "/" + cluster
+}
+
+// Capture records the current usage of every deployment's containers and starts tracking the
+// cluster. Pods not owned by a deployment are skipped.
+func (s *Sampler) Capture(ctx context.Context, config, cluster string) error {
+	client, metrics := s.clients(config, cluster)
+	if metrics == nil {
+		return ErrMetricsUnavailable
+	}
+	usage, err := metrics.MetricsV1beta1().PodMetricses(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	owners, err := deploymentOwners(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	now := s.clock()
+	key := clusterKey(config, cluster)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tracked[key] = [2]string{config, cluster}
+	history := s.history[key]
+	if history == nil {
+		history = map[containerKey][]Sample{}
+		s.history[key] = history
+	}
+	for _, pod := range usage.Items {
+		deployment := ""
+		for _, ref := range pod.OwnerReferences {
+			if ref.Kind == "ReplicaSet" {
+				deployment = owners[pod.Namespace+"/"+ref.Name]
+			}
+		}
+		if deployment == "" {
+			// Metrics objects rarely carry owner references, so fall back to the pod-template-hash naming.
+			deployment = owners[pod.Namespace+"/"+replicaSetName(pod.Name)]
+		}
+		if deployment == "" {
+			continue
+		}
+		for _, container := range pod.Containers {
+			ck := containerKey{Namespace: pod.Namespace, Deployment: deployment, Container: container.Name}
+			history[ck] = append(history[ck], Sample{
+				At:     now,
+				CPU:    container.Usage.Cpu().MilliValue(),
+				Memory: container.Usage.Memory().Value(),
+			})
+		}
+	}
+	s.trimLocked(history, now)
+	return nil
+}
+
+// deploymentOwners maps namespace/replicaset to the name of the deployment owning it.
+func deploymentOwners(ctx context.Context, client kubernetes.Interface) (map[string]string, error) {
+	sets, err := client.AppsV1().ReplicaSets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	owners := make(map[string]string, len(sets.Items))
+	for _, set := range sets.Items {
+		for _, ref := range set.OwnerReferences {
+			if ref.Kind == "Deployment" && ref.Controller != nil && *ref.Controller {
+				owners[set.Namespace+"/"+set.Name] = ref.Name
+			}
+		}
+	}
+	return owners, nil
+}
+
+// replicaSetName strips the random suffix a replica set appends to the pods it creates.
+func replicaSetName(pod string) string {
+	for i := len(pod) - 1; i >= 0; i-- {
+		if pod[i] == '-' {
+			return pod[:i]
+		}
+	}
+	return ""
+}
+
+func (s *Sampler) trimLocked(history map[containerKey][]Sample, now time.Time) {
+	cutoff := now.Add(-s.retention)
+	for key, samples := range history {
+		for len(samples) > 0 && samples[0].At.Before(cutoff) {
+			samples = samples[1:]
+		}
+		if len(samples) == 0 {
+			delete(history, key)
+			continue
+		}
+		history[key] = samples
+	}
+}
+
+// samples returns a copy of the samples of a cluster taken since the given time.
+func (s *Sampler) samples(config, cluster string, since time.Time) map[containerKey][]Sample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := map[containerKey][]Sample{}
+	for key, samples := range s.history[clusterKey(config, cluster)] {
+		for _, sample := range samples {
+			if !sample.At.Before(since) {
+				out[key] = append(out[key], sample)
+			}
+		}
+	}
+	return out
+}
+
+// Run samples every tracked cluster on the given interval until the context is cancelled.
+func (s *Sampler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			targets := make([][2]string, 0, len(s.tracked))
+			for _, target := range s.tracked {
+				targets = append(targets, target)
+			}
+			s.mu.Unlock()
+			for _, target := range targets {
+				if err := s.Capture(ctx, target[0], target[1]); err != nil {
+					s.logger.Warn("failed to sample container usage", "config", target[0], "cluster", target[1], "error", err)
+				}
+			}
+		}
+	}
+}
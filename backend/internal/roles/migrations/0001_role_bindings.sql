@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS role_bindings (
+    id TEXT PRIMARY KEY,
+    role TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    subject_kind TEXT NOT NULL,
+    namespace TEXT NOT NULL DEFAULT '',
+    created_by TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS role_bindings_subject_idx ON role_bindings (subject_kind, subject, role, namespace);
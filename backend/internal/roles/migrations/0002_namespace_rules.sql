@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS namespace_rules (
+    id TEXT PRIMARY KEY,
+    effect TEXT NOT NULL,
+    pattern TEXT NOT NULL,
+    subject_kind TEXT NOT NULL,
+    subject TEXT NOT NULL DEFAULT '',
+    created_by TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
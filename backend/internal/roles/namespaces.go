@@ -0,0 +1,231 @@
+package roles
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type NamespaceEffect string
+
+const (
+	NamespaceAllow NamespaceEffect = "allow"
+	NamespaceDeny  NamespaceEffect = "deny"
+)
+
+// Namespace rules apply to a user, to the holders of a role, or to everyone.
+const (
+	SubjectRole     SubjectKind = "role"
+	SubjectEveryone SubjectKind = "everyone"
+)
+
+// NamespaceRule allows or denies the namespaces matching Pattern, such as kubechat-* or
+// team-?-dev, to its subject. Denies win; once any allow rule applies to a user, they may only
+// use the namespaces an allow rule matches. Requests spanning every namespace, such as lists
+// across namespaces or reads of cluster-scoped resources, are refused by any deny rule and only
+// allowed by the pattern *.
+type NamespaceRule struct {
+	ID          string          `json:"id"`
+	Effect      NamespaceEffect `json:"effect"`
+	Pattern     string          `json:"pattern"`
+	SubjectKind SubjectKind     `json:"subjectKind"`
+	Subject     string          `json:"subject,omitempty"`
+	CreatedBy   string          `json:"createdBy"`
+	CreatedAt   time.Time       `json:"createdAt"`
+}
+
+// NamespaceRuleRequest asks to add a namespace rule. SubjectKind defaults to everyone.
+type NamespaceRuleRequest struct {
+	Effect      NamespaceEffect `json:"effect"`
+	Pattern     string          `json:"pattern"`
+	SubjectKind SubjectKind     `json:"subjectKind,omitempty"`
+	Subject     string          `json:"subject,omitempty"`
+}
+
+// NamespacePolicy is the namespace rules in force: the configured defaults, which allow their
+// patterns to everyone, and the rules added through the API.
+type NamespacePolicy struct {
+	Defaults []string        `json:"defaults"`
+	Rules    []NamespaceRule `json:"rules"`
+}
+
+type ErrNamespaceRuleNotFound struct {
+	ID string
+}
+
+func (e ErrNamespaceRuleNotFound) Error() string {
+	return fmt.Sprintf("namespace rule %s not found", e.ID)
+}
+
+func (r NamespaceRule) matches(namespace string) bool {
+	if namespace == "" {
+		return r.Pattern == "*"
+	}
+	matched, _ := path.Match(r.Pattern, namespace)
+	return matched
+}
+
+func (r NamespaceRule) appliesTo(user string, held []Role) bool {
+	switch r.SubjectKind {
+	case SubjectEveryone:
+		return true
+	case SubjectRole:
+		return slices.Contains(held, Role(r.Subject))
+	}
+	return r.Subject == user
+}
+
+// SetNamespaceDefaults allows patterns, such as kubechat-*, to everyone alongside the rules added
+// through the API. With none, users may use every namespace no rule denies them.
+func (s *Service) SetNamespaceDefaults(patterns []string) error {
+	for _, pattern := range patterns {
+		if err := validPattern(pattern); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.defaults = patterns
+	return nil
+}
+
+func validPattern(pattern string) error {
+	if pattern == "" || strings.Contains(pattern, "/") {
+		return ErrInvalidRequest{Reason: fmt.Sprintf("namespace pattern %q must name namespaces, such as kubechat-*", pattern)}
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return ErrInvalidRequest{Reason: fmt.Sprintf("namespace pattern %q is malformed", pattern)}
+	}
+	return nil
+}
+
+// AddNamespaceRule adds a namespace rule on behalf of actor. It applies here at once and on other
+// replicas when they next reload.
+func (s *Service) AddNamespaceRule(ctx context.Context, actor string, req NamespaceRuleRequest) (NamespaceRule, error) {
+	rule := NamespaceRule{
+		ID:          uuid.NewString(),
+		Effect:      req.Effect,
+		Pattern:     strings.TrimSpace(req.Pattern),
+		SubjectKind: req.SubjectKind,
+		Subject:     strings.TrimSpace(req.Subject),
+		CreatedBy:   actor,
+		CreatedAt:   s.now(),
+	}
+	if rule.SubjectKind == "" {
+		rule.SubjectKind = SubjectEveryone
+	}
+	if err := validPattern(rule.Pattern); err != nil {
+		return NamespaceRule{}, err
+	}
+	switch {
+	case rule.Effect != NamespaceAllow && rule.Effect != NamespaceDeny:
+		return NamespaceRule{}, ErrInvalidRequest{Reason: "effect must be allow or deny"}
+	case rule.SubjectKind == SubjectEveryone && rule.Subject != "":
+		return NamespaceRule{}, ErrInvalidRequest{Reason: "rules for everyone take no subject"}
+	case rule.SubjectKind == SubjectUser && rule.Subject == "":
+		return NamespaceRule{}, ErrInvalidRequest{Reason: "subject is required"}
+	case rule.SubjectKind == SubjectRole && !Role(rule.Subject).Valid():
+		return NamespaceRule{}, ErrInvalidRequest{Reason: "subject must be viewer, operator, approver or admin"}
+	case rule.SubjectKind != SubjectEveryone && rule.SubjectKind != SubjectUser && rule.SubjectKind != SubjectRole:
+		return NamespaceRule{}, ErrInvalidRequest{Reason: "subjectKind must be everyone, user or role"}
+	}
+	if err := s.repo.SaveNamespaceRule(ctx, rule); err != nil {
+		return NamespaceRule{}, err
+	}
+	s.invalidate()
+	s.logger.Info("namespace rule added", "actor", actor, "effect", rule.Effect, "pattern", rule.Pattern, "kind", rule.SubjectKind, "subject", rule.Subject)
+	return rule, nil
+}
+
+// RemoveNamespaceRule removes a namespace rule on behalf of actor.
+func (s *Service) RemoveNamespaceRule(ctx context.Context, actor, id string) error {
+	if err := s.repo.DeleteNamespaceRule(ctx, id); err != nil {
+		return err
+	}
+	s.invalidate()
+	s.logger.Info("namespace rule removed", "actor", actor, "id", id)
+	return nil
+}
+
+func (s *Service) NamespacePolicy(ctx context.Context) (NamespacePolicy, error) {
+	rules, err := s.repo.ListNamespaceRules(ctx)
+	if err != nil {
+		return NamespacePolicy{}, err
+	}
+	s.mu.Lock()
+	defaults := append([]string{}, s.defaults...)
+	s.mu.Unlock()
+	return NamespacePolicy{Defaults: defaults, Rules: rules}, nil
+}
+
+// Reload drops the cached bindings and namespace rules, so changes made on other replicas or
+// directly in the database apply without waiting for them to expire.
+func (s *Service) Reload() {
+	s.invalidate()
+	s.logger.Info("role bindings and namespace rules reloaded")
+}
+
+// CheckNamespace checks that the namespace rules let user use namespace; an empty namespace
+// stands for every namespace. Configured admins are not restricted.
+func (s *Service) CheckNamespace(ctx context.Context, user string, groups []string, namespace string) error {
+	if slices.Contains(s.admins, user) {
+		return nil
+	}
+	bindings, rules, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defaults := s.defaults
+	s.mu.Unlock()
+	if reason := s.refusal(bindings, rules, defaults, user, groups, namespace); reason != "" {
+		return ErrForbidden{User: user, Namespace: namespace, Policy: reason}
+	}
+	return nil
+}
+
+// refusal says why the rules refuse namespace to user, or is empty when they allow it.
+func (s *Service) refusal(bindings []Binding, rules []NamespaceRule, defaults []string, user string, groups []string, namespace string) string {
+	held := s.held(bindings, user, groups)
+	restricted, allowed := false, false
+	for _, pattern := range defaults {
+		restricted = true
+		allowed = allowed || (NamespaceRule{Pattern: pattern}).matches(namespace)
+	}
+	for _, rule := range rules {
+		if !rule.appliesTo(user, held) {
+			continue
+		}
+		if rule.Effect == NamespaceDeny {
+			if namespace == "" || rule.matches(namespace) {
+				return "denied by namespace rule " + rule.Pattern
+			}
+			continue
+		}
+		restricted = true
+		allowed = allowed || rule.matches(namespace)
+	}
+	if restricted && !allowed {
+		return "not among the allowed namespaces"
+	}
+	return ""
+}
+
+// held lists the roles user holds through the default role or a binding in any namespace.
+func (s *Service) held(bindings []Binding, user string, groups []string) []Role {
+	var held []Role
+	if s.defaultRole != "" {
+		held = append(held, s.defaultRole)
+	}
+	for _, binding := range bindings {
+		if binding.appliesTo(user, groups) && !slices.Contains(held, binding.Role) {
+			held = append(held, binding.Role)
+		}
+	}
+	return held
+}
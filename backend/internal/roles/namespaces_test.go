@@ -0,0 +1,82 @@
+package roles
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestNamespaceRulesAllowAndDenyPatterns(t *testing.T) {
+	svc := NewService(NewMemoryRepository(), []string{"root"}, RoleOperator, nil)
+	ctx := context.Background()
+	if err := svc.SetNamespaceDefaults([]string{"kubechat", "kubechat-*", "default"}); err != nil {
+		t.Fatalf("defaults: %v", err)
+	}
+	if _, err := svc.Assign(ctx, "root", BindingRequest{Role: RoleApprover, Subject: "sre", SubjectKind: SubjectGroup}); err != nil {
+		t.Fatalf("assign: %v", err)
+	}
+	for _, req := range []NamespaceRuleRequest{
+		{Effect: NamespaceAllow, Pattern: "team-a-*", SubjectKind: SubjectUser, Subject: "alice"},
+		{Effect: NamespaceDeny, Pattern: "kubechat-secrets", SubjectKind: SubjectUser, Subject: "alice"},
+		{Effect: NamespaceAllow, Pattern: "*", SubjectKind: SubjectRole, Subject: string(RoleApprover)},
+	} {
+		if _, err := svc.AddNamespaceRule(ctx, "root", req); err != nil {
+			t.Fatalf("add %+v: %v", req, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		user      string
+		groups    []string
+		namespace string
+		allowed   bool
+	}{
+		{"alice", nil, "kubechat-web", true},
+		{"alice", nil, "team-a-dev", true},
+		{"alice", nil, "kubechat-secrets", false},
+		{"alice", nil, "team-b-dev", false},
+		{"alice", nil, "", false},
+		{"bob", nil, "default", true},
+		{"bob", nil, "team-a-dev", false},
+		{"carol", []string{"sre"}, "payments", true},
+		{"carol", []string{"sre"}, "", true},
+		{"root", nil, "anything", true},
+	} {
+		err := svc.CheckExecute(ctx, tc.user, tc.groups, tc.namespace)
+		if tc.allowed && err != nil {
+			t.Errorf("%s in %q: expected to be allowed, got %v", tc.user, tc.namespace, err)
+		}
+		var forbidden ErrForbidden
+		if !tc.allowed && (!errors.As(err, &forbidden) || forbidden.Policy == "") {
+			t.Errorf("%s in %q: expected the namespace rules to refuse, got %v", tc.user, tc.namespace, err)
+		}
+	}
+
+	policy, err := svc.NamespacePolicy(ctx)
+	if err != nil || len(policy.Defaults) != 3 || len(policy.Rules) != 3 {
+		t.Fatalf("unexpected policy %+v (%v)", policy, err)
+	}
+	if err := svc.RemoveNamespaceRule(ctx, "root", policy.Rules[0].ID); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if err := svc.CheckNamespace(ctx, "alice", nil, "team-a-dev"); !errors.As(err, &ErrForbidden{}) {
+		t.Fatalf("expected a removed rule to apply at once, got %v", err)
+	}
+	if err := svc.RemoveNamespaceRule(ctx, "root", policy.Rules[0].ID); !errors.As(err, &ErrNamespaceRuleNotFound{}) {
+		t.Fatalf("expected a second remove to find nothing, got %v", err)
+	}
+
+	for name, req := range map[string]NamespaceRuleRequest{
+		"effect":       {Effect: "maybe", Pattern: "shop"},
+		"pattern":      {Effect: NamespaceAllow, Pattern: "shop-["},
+		"path":         {Effect: NamespaceAllow, Pattern: "shop/prod"},
+		"role":         {Effect: NamespaceAllow, Pattern: "shop", SubjectKind: SubjectRole, Subject: "owner"},
+		"user":         {Effect: NamespaceAllow, Pattern: "shop", SubjectKind: SubjectUser},
+		"everyone":     {Effect: NamespaceAllow, Pattern: "shop", Subject: "alice"},
+		"subject kind": {Effect: NamespaceAllow, Pattern: "shop", SubjectKind: SubjectGroup, Subject: "sre"},
+	} {
+		if _, err := svc.AddNamespaceRule(ctx, "root", req); !errors.As(err, &ErrInvalidRequest{}) {
+			t.Errorf("%s: expected the rule to be rejected, got %v", name, err)
+		}
+	}
+}
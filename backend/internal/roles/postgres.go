@@ -0,0 +1,145 @@
+package roles
+
+import (
+	"context"
+	"embed"
+	"io/fs"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pramodksahoo/kubechat/backend/internal/database"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// PostgresRepository stores role bindings and namespace rules as plain rows.
+type PostgresRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresRepository applies the role schema migrations and returns a repository backed by db.
+func NewPostgresRepository(ctx context.Context, db *sqlx.DB) (*PostgresRepository, error) {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Migrate(ctx, db, "roles", sub); err != nil {
+		return nil, err
+	}
+	return &PostgresRepository{db: db}, nil
+}
+
+type bindingRow struct {
+	ID          string    `db:"id"`
+	Role        string    `db:"role"`
+	Subject     string    `db:"subject"`
+	SubjectKind string    `db:"subject_kind"`
+	Namespace   string    `db:"namespace"`
+	CreatedBy   string    `db:"created_by"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+func (r *PostgresRepository) SaveBinding(ctx context.Context, binding Binding) error {
+	_, err := r.db.NamedExecContext(ctx, `INSERT INTO role_bindings (id, role, subject, subject_kind, namespace, created_by, created_at)
+		VALUES (:id, :role, :subject, :subject_kind, :namespace, :created_by, :created_at)
+		ON CONFLICT (id) DO UPDATE SET role = EXCLUDED.role, namespace = EXCLUDED.namespace`, bindingRow{
+		ID:          binding.ID,
+		Role:        string(binding.Role),
+		Subject:     binding.Subject,
+		SubjectKind: string(binding.SubjectKind),
+		Namespace:   binding.Namespace,
+		CreatedBy:   binding.CreatedBy,
+		CreatedAt:   binding.CreatedAt,
+	})
+	return err
+}
+
+func (r *PostgresRepository) DeleteBinding(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM role_bindings WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrBindingNotFound{ID: id}
+	}
+	return nil
+}
+
+func (r *PostgresRepository) ListBindings(ctx context.Context) ([]Binding, error) {
+	var rows []bindingRow
+	if err := r.db.SelectContext(ctx, &rows, `SELECT id, role, subject, subject_kind, namespace, created_by, created_at
+		FROM role_bindings ORDER BY created_at`); err != nil {
+		return nil, err
+	}
+	bindings := make([]Binding, 0, len(rows))
+	for _, row := range rows {
+		bindings = append(bindings, Binding{
+			ID:          row.ID,
+			Role:        Role(row.Role),
+			Subject:     row.Subject,
+			SubjectKind: SubjectKind(row.SubjectKind),
+			Namespace:   row.Namespace,
+			CreatedBy:   row.CreatedBy,
+			CreatedAt:   row.CreatedAt.UTC(),
+		})
+	}
+	return bindings, nil
+}
+
+type namespaceRuleRow struct {
+	ID          string    `db:"id"`
+	Effect      string    `db:"effect"`
+	Pattern     string    `db:"pattern"`
+	SubjectKind string    `db:"subject_kind"`
+	Subject     string    `db:"subject"`
+	CreatedBy   string    `db:"created_by"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+func (r *PostgresRepository) SaveNamespaceRule(ctx context.Context, rule NamespaceRule) error {
+	_, err := r.db.NamedExecContext(ctx, `INSERT INTO namespace_rules (id, effect, pattern, subject_kind, subject, created_by, created_at)
+		VALUES (:id, :effect, :pattern, :subject_kind, :subject, :created_by, :created_at)
+		ON CONFLICT (id) DO UPDATE SET effect = EXCLUDED.effect, pattern = EXCLUDED.pattern`, namespaceRuleRow{
+		ID:          rule.ID,
+		Effect:      string(rule.Effect),
+		Pattern:     rule.Pattern,
+		SubjectKind: string(rule.SubjectKind),
+		Subject:     rule.Subject,
+		CreatedBy:   rule.CreatedBy,
+		CreatedAt:   rule.CreatedAt,
+	})
+	return err
+}
+
+func (r *PostgresRepository) DeleteNamespaceRule(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM namespace_rules WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNamespaceRuleNotFound{ID: id}
+	}
+	return nil
+}
+
+func (r *PostgresRepository) ListNamespaceRules(ctx context.Context) ([]NamespaceRule, error) {
+	var rows []namespaceRuleRow
+	if err := r.db.SelectContext(ctx, &rows, `SELECT id, effect, pattern, subject_kind, subject, created_by, created_at
+		FROM namespace_rules ORDER BY created_at`); err != nil {
+		return nil, err
+	}
+	rules := make([]NamespaceRule, 0, len(rows))
+	for _, row := range rows {
+		rules = append(rules, NamespaceRule{
+			ID:          row.ID,
+			Effect:      NamespaceEffect(row.Effect),
+			Pattern:     row.Pattern,
+			SubjectKind: SubjectKind(row.SubjectKind),
+			Subject:     row.Subject,
+			CreatedBy:   row.CreatedBy,
+			CreatedAt:   row.CreatedAt.UTC(),
+		})
+	}
+	return rules, nil
+}
@@ -0,0 +1,87 @@
+package roles
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Repository persists role bindings.
+type Repository interface {
+	SaveBinding(ctx context.Context, binding Binding) error
+	DeleteBinding(ctx context.Context, id string) error
+	// ListBindings returns every binding, oldest first.
+	ListBindings(ctx context.Context) ([]Binding, error)
+	SaveNamespaceRule(ctx context.Context, rule NamespaceRule) error
+	DeleteNamespaceRule(ctx context.Context, id string) error
+	// ListNamespaceRules returns every namespace rule, oldest first.
+	ListNamespaceRules(ctx context.Context) ([]NamespaceRule, error)
+}
+
+// MemoryRepository keeps role bindings and namespace rules in process memory; it is used when no
+// database is configured.
+type MemoryRepository struct {
+	mu       sync.RWMutex
+	bindings map[string]Binding
+	rules    map[string]NamespaceRule
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{bindings: make(map[string]Binding), rules: make(map[string]NamespaceRule)}
+}
+
+func (r *MemoryRepository) SaveBinding(ctx context.Context, binding Binding) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bindings[binding.ID] = binding
+	return nil
+}
+
+func (r *MemoryRepository) DeleteBinding(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.bindings[id]; !ok {
+		return ErrBindingNotFound{ID: id}
+	}
+	delete(r.bindings, id)
+	return nil
+}
+
+func (r *MemoryRepository) ListBindings(ctx context.Context) ([]Binding, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	bindings := make([]Binding, 0, len(r.bindings))
+	for _, binding := range r.bindings {
+		bindings = append(bindings, binding)
+	}
+	sort.Slice(bindings, func(i, j int) bool { return bindings[i].CreatedAt.Before(bindings[j].CreatedAt) })
+	return bindings, nil
+}
+
+func (r *MemoryRepository) SaveNamespaceRule(ctx context.Context, rule NamespaceRule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rules[rule.ID] = rule
+	return nil
+}
+
+func (r *MemoryRepository) DeleteNamespaceRule(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.rules[id]; !ok {
+		return ErrNamespaceRuleNotFound{ID: id}
+	}
+	delete(r.rules, id)
+	return nil
+}
+
+func (r *MemoryRepository) ListNamespaceRules(ctx context.Context) ([]NamespaceRule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	rules := make([]NamespaceRule, 0, len(r.rules))
+	for _, rule := range r.rules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].CreatedAt.Before(rules[j].CreatedAt) })
+	return rules, nil
+}
@@ -0,0 +1,307 @@
+// Package roles controls what users may do in KubeChat itself. Users and groups hold roles
+// through bindings, cluster-wide or in one namespace, and each role grants permissions on a
+// group of routes.
+package roles
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"
+	RoleOperator Role = "operator"
+	RoleApprover Role = "approver"
+	RoleAdmin    Role = "admin"
+)
+
+type Permission string
+
+const (
+	PermissionCommandsRead    Permission = "commands:read"
+	PermissionCommandsExecute Permission = "commands:execute"
+	PermissionCommandsApprove Permission = "commands:approve"
+	PermissionAuditRead       Permission = "audit:read"
+	PermissionCostRead        Permission = "cost:read"
+	PermissionCostManage      Permission = "cost:manage"
+	PermissionProvidersRead   Permission = "providers:read"
+	PermissionProvidersManage Permission = "providers:manage"
+	PermissionRolesManage     Permission = "roles:manage"
+	// PermissionNone marks routes every signed-in user may use whatever their roles, such as
+	// enrolling an authenticator or asking for their own data.
+	PermissionNone Permission = ""
+)
+
+var viewerPermissions = []Permission{PermissionCommandsRead, PermissionCostRead, PermissionProvidersRead}
+
+// grants lists the permissions of each role. Approvers do not run commands themselves; users who
+// do both hold both roles.
+var grants = map[Role][]Permission{
+	RoleViewer:   viewerPermissions,
+	RoleOperator: append(slices.Clone(viewerPermissions), PermissionCommandsExecute),
+	RoleApprover: append(slices.Clone(viewerPermissions), PermissionCommandsApprove, PermissionAuditRead),
+	RoleAdmin: {
+		PermissionCommandsRead, PermissionCommandsExecute, PermissionCommandsApprove, PermissionAuditRead,
+		PermissionCostRead, PermissionCostManage, PermissionProvidersRead, PermissionProvidersManage,
+		PermissionRolesManage,
+	},
+}
+
+// Valid reports whether r is a known role.
+func (r Role) Valid() bool {
+	_, ok := grants[r]
+	return ok
+}
+
+// Grants reports whether the role includes permission.
+func (r Role) Grants(permission Permission) bool {
+	return slices.Contains(grants[r], permission)
+}
+
+// RoleInfo describes a role for clients, such as a role picker.
+type RoleInfo struct {
+	Name        Role         `json:"name"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// Catalog lists the roles from least to most privileged.
+func Catalog() []RoleInfo {
+	catalog := make([]RoleInfo, 0, len(grants))
+	for _, role := range []Role{RoleViewer, RoleOperator, RoleApprover, RoleAdmin} {
+		catalog = append(catalog, RoleInfo{Name: role, Permissions: grants[role]})
+	}
+	return catalog
+}
+
+type SubjectKind string
+
+const (
+	SubjectUser  SubjectKind = "user"
+	SubjectGroup SubjectKind = "group"
+)
+
+// Binding gives a user or group a role. An empty Namespace binds the role in every namespace.
+type Binding struct {
+	ID          string      `json:"id"`
+	Role        Role        `json:"role"`
+	Subject     string      `json:"subject"`
+	SubjectKind SubjectKind `json:"subjectKind"`
+	Namespace   string      `json:"namespace,omitempty"`
+	CreatedBy   string      `json:"createdBy"`
+	CreatedAt   time.Time   `json:"createdAt"`
+}
+
+// BindingRequest asks to bind a role. SubjectKind defaults to user.
+type BindingRequest struct {
+	Role        Role        `json:"role"`
+	Subject     string      `json:"subject"`
+	SubjectKind SubjectKind `json:"subjectKind,omitempty"`
+	Namespace   string      `json:"namespace,omitempty"`
+}
+
+func (b Binding) appliesTo(user string, groups []string) bool {
+	if b.SubjectKind == SubjectGroup {
+		return slices.Contains(groups, b.Subject)
+	}
+	return b.Subject == user
+}
+
+// Rule requires Permission for requests to routes starting with Prefix. Methods limits the rule
+// to some HTTP methods; an empty list matches every method.
+type Rule struct {
+	Prefix     string
+	Methods    []string
+	Permission Permission
+}
+
+var writeMethods = []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+var readMethods = []string{http.MethodGet}
+
+// DefaultRules maps KubeChat's route groups to permissions; the first matching rule applies and
+// every route under /api/v1 has one.
+var DefaultRules = slices.Concat([]Rule{
+	{Prefix: "/api/v1/admin/roles", Permission: PermissionRolesManage},
+	// Resetting an authenticator lets the next enrollment for that user pass step-up checks.
+	{Prefix: "/api/v1/admin/mfa", Permission: PermissionRolesManage},
+	// Locking or resetting a user and binding their roles decide who may use KubeChat at all.
+	{Prefix: "/api/v1/admin/users", Permission: PermissionRolesManage},
+	// Moving the keys users brought between secret backends is managing providers, not costs.
+	{Prefix: "/api/v1/admin/nlp/keys", Permission: PermissionProvidersManage},
+	{Prefix: "/api/v1/admin/nlp", Permission: PermissionCostManage},
+	{Prefix: "/api/v1/admin/commands", Permission: PermissionRolesManage},
+	{Prefix: "/api/v1/admin/log-levels", Permission: PermissionRolesManage},
+	// Chaos experiments fail providers and throttle clusters for every user.
+	{Prefix: "/api/v1/admin/chaos", Permission: PermissionRolesManage},
+	// Impersonating a user acts with their roles, so it takes the permission to grant them.
+	{Prefix: "/api/v1/admin/impersonations", Permission: PermissionRolesManage},
+	// Retention policies and enforcement decide when audit and execution records are purged.
+	{Prefix: "/api/v1/admin/retention", Methods: writeMethods, Permission: PermissionRolesManage},
+	{Prefix: "/api/v1/admin/retention", Permission: PermissionAuditRead},
+	// A breaker held open cuts every user off from a provider, so overriding one is managing it.
+	{Prefix: "/api/v1/admin/breakers", Methods: writeMethods, Permission: PermissionProvidersManage},
+	{Prefix: "/api/v1/admin/breakers", Permission: PermissionProvidersRead},
+	{Prefix: "/api/v1/admin/signed-requests", Permission: PermissionAuditRead},
+	// Re-anchoring the audit ledger accepts tampered history, so it is limited to admins.
+	{Prefix: "/api/v1/admin/audit", Methods: writeMethods, Permission: PermissionRolesManage},
+	{Prefix: "/api/v1/admin/audit", Permission: PermissionAuditRead},
+	// Recording an access review vouches for every role binding, which only admins may grant.
+	{Prefix: "/api/v1/admin/compliance", Methods: writeMethods, Permission: PermissionRolesManage},
+	{Prefix: "/api/v1/admin/compliance", Permission: PermissionAuditRead},
+	{Prefix: "/api/v1/admin/security", Permission: PermissionAuditRead},
+	// Test alerts page whoever is on call, so only admins send them.
+	{Prefix: "/api/v1/security/alerts", Methods: writeMethods, Permission: PermissionRolesManage},
+	{Prefix: "/api/v1/security/alerts", Permission: PermissionAuditRead},
+	{Prefix: "/api/v1/security", Permission: PermissionAuditRead},
+	// Deciding an erasure request irreversibly pseudonymizes or deletes a user's data.
+	{Prefix: "/api/v1/privacy/erasures/:id/approve", Permission: PermissionRolesManage},
+	{Prefix: "/api/v1/privacy/erasures/:id/reject", Permission: PermissionRolesManage},
+	// Users ask for their own data and erasure whatever their roles; the service narrows what
+	// each caller sees.
+	{Prefix: "/api/v1/privacy", Permission: PermissionNone},
+	// Users consent to, deny and end sessions naming them, and read the audit entries about them.
+	{Prefix: "/api/v1/impersonations", Permission: PermissionNone},
+	// Step-up authentication must work before any role is bound, and every role's UI reads its
+	// roles and capabilities.
+	{Prefix: "/api/v1/mfa", Permission: PermissionNone},
+	{Prefix: "/api/v1/kubechat/roles", Permission: PermissionNone},
+	{Prefix: "/api/v1/capabilities", Permission: PermissionNone},
+	{Prefix: "/api/v1/openapi.json", Permission: PermissionNone},
+	{Prefix: "/api/v1/commands/executions/:id/approve", Permission: PermissionCommandsApprove},
+	{Prefix: "/api/v1/commands/approvals/links", Permission: PermissionCommandsApprove},
+	{Prefix: "/api/v1/commands/executions/:id/reject", Permission: PermissionCommandsApprove},
+	{Prefix: "/api/v1/commands/executions/:id/override-freeze", Permission: PermissionCommandsApprove},
+	{Prefix: "/api/v1/commands/delegations", Methods: writeMethods, Permission: PermissionCommandsApprove},
+	// Webhook executors run commands elsewhere, so registering one is managing a provider.
+	{Prefix: "/api/v1/commands/executors", Methods: writeMethods, Permission: PermissionProvidersManage},
+	// Release values often hold credentials and manifests may render secrets, so reading them takes
+	// the permission to change the release.
+	{Prefix: "/api/v1/helm/releases/:name/values", Permission: PermissionCommandsExecute},
+	{Prefix: "/api/v1/helm/releases/:name/manifest", Permission: PermissionCommandsExecute},
+	{Prefix: "/api/v1/diagnostics/clusters", Permission: PermissionCommandsRead},
+	{Prefix: "/api/v1/prometheus", Permission: PermissionCommandsRead},
+	{Prefix: "/api/v1/crdindex/clusters", Permission: PermissionCommandsRead},
+	{Prefix: "/api/v1/helm", Methods: readMethods, Permission: PermissionCommandsRead},
+	{Prefix: "/api/v1/helm", Permission: PermissionCommandsExecute},
+	{Prefix: "/api/v1/commands", Methods: readMethods, Permission: PermissionCommandsRead},
+	{Prefix: "/api/v1/commands", Permission: PermissionCommandsExecute},
+	{Prefix: "/api/v1/audit", Permission: PermissionAuditRead},
+	{Prefix: "/api/v1/nlp/quota", Permission: PermissionCostRead},
+	{Prefix: "/api/v1/nlp/usage", Permission: PermissionCostRead},
+	{Prefix: "/api/v1/nlp/providers", Methods: readMethods, Permission: PermissionProvidersRead},
+	{Prefix: "/api/v1/nlp/models", Permission: PermissionProvidersRead},
+	{Prefix: "/api/v1/nlp/providers", Permission: PermissionProvidersManage},
+	// Sessions, attachments and cache preferences belong to the caller.
+	{Prefix: "/api/v1/nlp", Permission: PermissionCommandsRead},
+	// Kubeconfigs and cluster registrations decide which clusters KubeChat reaches and with whose
+	// credentials.
+	{Prefix: "/api/v1/app/config/reload", Permission: PermissionRolesManage},
+	{Prefix: "/api/v1/app/config", Methods: readMethods, Permission: PermissionCommandsRead},
+	{Prefix: "/api/v1/app/config", Permission: PermissionRolesManage},
+	{Prefix: "/api/v1/app/apply", Permission: PermissionCommandsExecute},
+	{Prefix: "/api/v1/clusters", Methods: readMethods, Permission: PermissionCommandsRead},
+	{Prefix: "/api/v1/clusters", Permission: PermissionRolesManage},
+	// MCP tools and plans run commands against clusters.
+	{Prefix: "/api/v1/mcp", Permission: PermissionCommandsExecute},
+	{Prefix: "/api/v1/prompts", Permission: PermissionCommandsExecute},
+	{Prefix: "/api/v1/plans", Methods: readMethods, Permission: PermissionCommandsRead},
+	{Prefix: "/api/v1/plans", Permission: PermissionCommandsExecute},
+	{Prefix: "/api/v1/sandboxes", Methods: readMethods, Permission: PermissionCommandsRead},
+	{Prefix: "/api/v1/sandboxes", Permission: PermissionCommandsExecute},
+	{Prefix: "/api/v1/iac", Methods: readMethods, Permission: PermissionCommandsRead},
+	{Prefix: "/api/v1/iac", Permission: PermissionCommandsExecute},
+	{Prefix: "/api/v1/dashboards", Methods: readMethods, Permission: PermissionCommandsRead},
+	{Prefix: "/api/v1/dashboards", Permission: PermissionCommandsExecute},
+	{Prefix: "/api/v1/portforwards", Methods: readMethods, Permission: PermissionCommandsRead},
+	{Prefix: "/api/v1/portforwards", Permission: PermissionCommandsExecute},
+	// Exports check each source's own permission when they are created.
+	{Prefix: "/api/v1/exports", Permission: PermissionCommandsRead},
+	{Prefix: "/api/v1/logs", Permission: PermissionCommandsRead},
+	{Prefix: "/api/v1/watch", Permission: PermissionCommandsRead},
+	{Prefix: "/api/v1/changes", Permission: PermissionCommandsRead},
+	{Prefix: "/api/v1/diagnostics", Permission: PermissionCommandsRead},
+	{Prefix: "/api/v1/kubernetes", Permission: PermissionCommandsRead},
+	{Prefix: "/api/v1/reports", Permission: PermissionCommandsRead},
+	{Prefix: "/api/v1/upgrades", Permission: PermissionCommandsRead},
+	// Secrets hold credentials, so reading them takes the permission to change them.
+	{Prefix: "/api/v1/secrets", Permission: PermissionCommandsExecute},
+}, resourceRules(kubernetesResources))
+
+// kubernetesResources are the route groups of the resource browser.
+var kubernetesResources = []string{
+	"clusterrolebindings", "clusterroles", "configmaps", "cronjobs", "customresourcedefinitions",
+	"customresources", "daemonsets", "deployments", "endpoints", "events", "horizontalpodautoscalers",
+	"ingresses", "jobs", "leases", "limitranges", "namespaces", "nodes", "persistentvolumeclaims",
+	"persistentvolumes", "poddisruptionbudgets", "pods", "priorityclasses", "replicasets",
+	"resourcequotas", "rolebindings", "roles", "runtimeclasses", "serviceaccounts", "services",
+	"statefulsets", "storageclasses",
+}
+
+// resourceRules lets viewers browse Kubernetes resources and requires commands:execute to delete
+// or scale them, as running the matching kubectl command would.
+func resourceRules(resources []string) []Rule {
+	rules := make([]Rule, 0, 2*len(resources))
+	for _, resource := range resources {
+		prefix := "/api/v1/" + resource
+		rules = append(rules,
+			Rule{Prefix: prefix, Methods: readMethods, Permission: PermissionCommandsRead},
+			Rule{Prefix: prefix, Permission: PermissionCommandsExecute})
+	}
+	return rules
+}
+
+// RequiredPermission returns the permission the first matching rule requires for a route.
+func RequiredPermission(rules []Rule, method, route string) (Permission, bool) {
+	for _, rule := range rules {
+		if !strings.HasPrefix(route, rule.Prefix) {
+			continue
+		}
+		if len(rule.Methods) == 0 || slices.Contains(rule.Methods, method) {
+			return rule.Permission, true
+		}
+	}
+	return "", false
+}
+
+type ErrForbidden struct {
+	User       string
+	Permission Permission
+	Namespace  string
+	// Policy, when set, says why the namespace rules refuse Namespace; the user may hold the
+	// permission there.
+	Policy string
+}
+
+func (e ErrForbidden) Error() string {
+	if e.Policy != "" && e.Namespace == "" {
+		return fmt.Sprintf("%s may not use every namespace: %s", e.User, e.Policy)
+	}
+	if e.Policy != "" {
+		return fmt.Sprintf("%s may not use namespace %s: %s", e.User, e.Namespace, e.Policy)
+	}
+	if e.Namespace != "" {
+		return fmt.Sprintf("%s lacks the %s permission in namespace %s", e.User, e.Permission, e.Namespace)
+	}
+	return fmt.Sprintf("%s lacks the %s permission", e.User, e.Permission)
+}
+
+type ErrBindingNotFound struct {
+	ID string
+}
+
+func (e ErrBindingNotFound) Error() string {
+	return fmt.Sprintf("role binding %s not found", e.ID)
+}
+
+type ErrInvalidRequest struct {
+	Reason string
+}
+
+func (e ErrInvalidRequest) Error() string {
+	return e.Reason
+}
@@ -0,0 +1,218 @@
+package roles
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+)
+
+// bindingsTTL bounds how long bindings and namespace rules changed on another replica take to
+// apply here.
+const bindingsTTL = 30 * time.Second
+
+// Service assigns roles and authorizes requests against them. Configured admins always hold the
+// admin role, so bindings can be managed before any exist, and every user holds the default role
+// in every namespace.
+type Service struct {
+	repo        Repository
+	admins      []string
+	defaultRole Role
+	logger      *log.Logger
+	now         func() time.Time
+
+	mu       sync.Mutex
+	bindings []Binding
+	rules    []NamespaceRule
+	defaults []string
+	loadedAt time.Time
+}
+
+func NewService(repo Repository, admins []string, defaultRole Role, logger *log.Logger) *Service {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Service{
+		repo:        repo,
+		admins:      admins,
+		defaultRole: defaultRole,
+		logger:      logger,
+		now:         func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// Access is what a user may do, and why.
+type Access struct {
+	User        string    `json:"user"`
+	Admin       bool      `json:"admin"`
+	DefaultRole Role      `json:"defaultRole,omitempty"`
+	Bindings    []Binding `json:"bindings"`
+	// Permissions are granted in every namespace; namespace bindings add to them.
+	Permissions []Permission `json:"permissions"`
+}
+
+// Assign binds a role on behalf of actor.
+func (s *Service) Assign(ctx context.Context, actor string, req BindingRequest) (Binding, error) {
+	binding := Binding{
+		ID:          uuid.NewString(),
+		Role:        req.Role,
+		Subject:     strings.TrimSpace(req.Subject),
+		SubjectKind: req.SubjectKind,
+		Namespace:   strings.TrimSpace(req.Namespace),
+		CreatedBy:   actor,
+		CreatedAt:   s.now(),
+	}
+	if binding.SubjectKind == "" {
+		binding.SubjectKind = SubjectUser
+	}
+	switch {
+	case !binding.Role.Valid():
+		return Binding{}, ErrInvalidRequest{Reason: "role must be viewer, operator, approver or admin"}
+	case binding.Subject == "":
+		return Binding{}, ErrInvalidRequest{Reason: "subject is required"}
+	case binding.SubjectKind != SubjectUser && binding.SubjectKind != SubjectGroup:
+		return Binding{}, ErrInvalidRequest{Reason: "subjectKind must be user or group"}
+	case binding.Role == RoleAdmin && binding.Namespace != "":
+		return Binding{}, ErrInvalidRequest{Reason: "the admin role cannot be bound to a namespace"}
+	}
+	existing, err := s.repo.ListBindings(ctx)
+	if err != nil {
+		return Binding{}, err
+	}
+	for _, other := range existing {
+		if other.Role == binding.Role && other.Subject == binding.Subject && other.SubjectKind == binding.SubjectKind && other.Namespace == binding.Namespace {
+			return Binding{}, ErrInvalidRequest{Reason: "the role is already bound"}
+		}
+	}
+	if err := s.repo.SaveBinding(ctx, binding); err != nil {
+		return Binding{}, err
+	}
+	s.invalidate()
+	s.logger.Info("role bound", "actor", actor, "role", binding.Role, "subject", binding.Subject, "kind", binding.SubjectKind, "namespace", binding.Namespace)
+	return binding, nil
+}
+
+// Revoke removes a binding on behalf of actor.
+func (s *Service) Revoke(ctx context.Context, actor, id string) error {
+	if err := s.repo.DeleteBinding(ctx, id); err != nil {
+		return err
+	}
+	s.invalidate()
+	s.logger.Info("role binding removed", "actor", actor, "id", id)
+	return nil
+}
+
+func (s *Service) Bindings(ctx context.Context) ([]Binding, error) {
+	return s.repo.ListBindings(ctx)
+}
+
+// Access returns the roles and cluster-wide permissions of user.
+func (s *Service) Access(ctx context.Context, user string, groups []string) (Access, error) {
+	bindings, _, err := s.load(ctx)
+	if err != nil {
+		return Access{}, err
+	}
+	access := Access{User: user, Admin: slices.Contains(s.admins, user), DefaultRole: s.defaultRole, Bindings: []Binding{}, Permissions: []Permission{}}
+	for _, binding := range bindings {
+		if binding.appliesTo(user, groups) {
+			access.Bindings = append(access.Bindings, binding)
+		}
+	}
+	for _, role := range Catalog() {
+		for _, permission := range role.Permissions {
+			if !slices.Contains(access.Permissions, permission) && s.allowed(bindings, user, groups, permission, "", false) {
+				access.Permissions = append(access.Permissions, permission)
+			}
+		}
+	}
+	return access, nil
+}
+
+// Authorize checks that user holds permission in namespace, and that the namespace rules let
+// them use it; an empty namespace requires the permission in every namespace.
+func (s *Service) Authorize(ctx context.Context, user string, groups []string, permission Permission, namespace string) error {
+	bindings, _, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+	if !s.allowed(bindings, user, groups, permission, namespace, false) {
+		return ErrForbidden{User: user, Permission: permission, Namespace: namespace}
+	}
+	if namespace == "" {
+		return nil
+	}
+	return s.CheckNamespace(ctx, user, groups, namespace)
+}
+
+// AuthorizeAny checks that user holds permission in at least one namespace. It guards routes
+// that only learn the namespace from the request body, where the service checks it again.
+func (s *Service) AuthorizeAny(ctx context.Context, user string, groups []string, permission Permission) error {
+	bindings, _, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+	if !s.allowed(bindings, user, groups, permission, "", true) {
+		return ErrForbidden{User: user, Permission: permission}
+	}
+	return nil
+}
+
+// CheckExecute checks that user may run commands in namespace. Commands naming no namespace span
+// every namespace, so the namespace rules must allow all of them.
+func (s *Service) CheckExecute(ctx context.Context, user string, groups []string, namespace string) error {
+	if err := s.Authorize(ctx, user, groups, PermissionCommandsExecute, namespace); err != nil || namespace != "" {
+		return err
+	}
+	return s.CheckNamespace(ctx, user, groups, "")
+}
+
+// CheckApprove reports whether user may approve commands in namespace, such as to override a
+// change window.
+func (s *Service) CheckApprove(ctx context.Context, user string, groups []string, namespace string) error {
+	return s.Authorize(ctx, user, groups, PermissionCommandsApprove, namespace)
+}
+
+func (s *Service) allowed(bindings []Binding, user string, groups []string, permission Permission, namespace string, anyNamespace bool) bool {
+	if slices.Contains(s.admins, user) || s.defaultRole.Grants(permission) {
+		return true
+	}
+	for _, binding := range bindings {
+		if !binding.appliesTo(user, groups) || !binding.Role.Grants(permission) {
+			continue
+		}
+		if binding.Namespace == "" || anyNamespace || binding.Namespace == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// load returns the bindings and namespace rules, reading them again once they are older than
+// bindingsTTL.
+func (s *Service) load(ctx context.Context) ([]Binding, []NamespaceRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.loadedAt.IsZero() && s.now().Sub(s.loadedAt) < bindingsTTL {
+		return s.bindings, s.rules, nil
+	}
+	bindings, err := s.repo.ListBindings(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	rules, err := s.repo.ListNamespaceRules(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.bindings, s.rules, s.loadedAt = bindings, rules, s.now()
+	return bindings, rules, nil
+}
+
+func (s *Service) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadedAt = time.Time{}
+}
@@ -0,0 +1,151 @@
+package roles
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestNamespaceBindingsGrantOnlyTheirNamespace(t *testing.T) {
+	svc := NewService(NewMemoryRepository(), []string{"root"}, RoleViewer, nil)
+	ctx := context.Background()
+	if _, err := svc.Assign(ctx, "root", BindingRequest{Role: RoleOperator, Subject: "alice", Namespace: "shop"}); err != nil {
+		t.Fatalf("assign: %v", err)
+	}
+	if _, err := svc.Assign(ctx, "root", BindingRequest{Role: RoleApprover, Subject: "sre", SubjectKind: SubjectGroup}); err != nil {
+		t.Fatalf("assign group: %v", err)
+	}
+
+	if err := svc.CheckExecute(ctx, "alice", nil, "shop"); err != nil {
+		t.Fatalf("expected alice to run commands in shop: %v", err)
+	}
+	if err := svc.CheckExecute(ctx, "alice", nil, "payments"); !errors.As(err, &ErrForbidden{}) {
+		t.Fatalf("expected alice to be refused outside shop, got %v", err)
+	}
+	if err := svc.AuthorizeAny(ctx, "alice", nil, PermissionCommandsExecute); err != nil {
+		t.Fatalf("expected alice to pass the route check: %v", err)
+	}
+	if err := svc.Authorize(ctx, "bob", []string{"sre"}, PermissionCommandsApprove, ""); err != nil {
+		t.Fatalf("expected the sre group to approve everywhere: %v", err)
+	}
+	if err := svc.AuthorizeAny(ctx, "bob", nil, PermissionCommandsExecute); !errors.As(err, &ErrForbidden{}) {
+		t.Fatalf("expected the viewer default to refuse execution, got %v", err)
+	}
+	if err := svc.Authorize(ctx, "root", nil, PermissionRolesManage, ""); err != nil {
+		t.Fatalf("expected configured admins to manage roles: %v", err)
+	}
+
+	access, err := svc.Access(ctx, "bob", []string{"sre"})
+	if err != nil {
+		t.Fatalf("access: %v", err)
+	}
+	if len(access.Bindings) != 1 || !slices.Contains(access.Permissions, PermissionCommandsApprove) || slices.Contains(access.Permissions, PermissionCommandsExecute) {
+		t.Fatalf("unexpected access %+v", access)
+	}
+
+	bindings, _ := svc.Bindings(ctx)
+	if err := svc.Revoke(ctx, "root", bindings[0].ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if err := svc.CheckExecute(ctx, "alice", nil, "shop"); !errors.As(err, &ErrForbidden{}) {
+		t.Fatalf("expected a revoked binding to grant nothing, got %v", err)
+	}
+	if err := svc.Revoke(ctx, "root", bindings[0].ID); !errors.As(err, &ErrBindingNotFound{}) {
+		t.Fatalf("expected a second revoke to find nothing, got %v", err)
+	}
+}
+
+func TestInvalidBindingsAreRejected(t *testing.T) {
+	svc := NewService(NewMemoryRepository(), nil, "", nil)
+	ctx := context.Background()
+	if _, err := svc.Assign(ctx, "root", BindingRequest{Role: RoleViewer, Subject: "alice"}); err != nil {
+		t.Fatalf("assign: %v", err)
+	}
+	for name, req := range map[string]BindingRequest{
+		"unknown role":     {Role: "owner", Subject: "alice"},
+		"no subject":       {Role: RoleViewer},
+		"unknown kind":     {Role: RoleViewer, Subject: "alice", SubjectKind: "team"},
+		"namespaced admin": {Role: RoleAdmin, Subject: "alice", Namespace: "shop"},
+		"duplicate":        {Role: RoleViewer, Subject: "alice", SubjectKind: SubjectUser},
+	} {
+		if _, err := svc.Assign(ctx, "root", req); !errors.As(err, &ErrInvalidRequest{}) {
+			t.Errorf("%s: expected the binding to be rejected, got %v", name, err)
+		}
+	}
+}
+
+func TestRulesMapRouteGroupsToPermissions(t *testing.T) {
+	for _, tc := range []struct {
+		method, route string
+		want          Permission
+	}{
+		{http.MethodGet, "/api/v1/commands/executions", PermissionCommandsRead},
+		{http.MethodPost, "/api/v1/commands/executions", PermissionCommandsExecute},
+		{http.MethodPost, "/api/v1/commands/executions/:id/approve", PermissionCommandsApprove},
+		{http.MethodPost, "/api/v1/commands/executors", PermissionProvidersManage},
+		{http.MethodGet, "/api/v1/audit/resources/:kind/:namespace/:name", PermissionAuditRead},
+		{http.MethodGet, "/api/v1/nlp/quota", PermissionCostRead},
+		{http.MethodPut, "/api/v1/admin/nlp/quota", PermissionCostManage},
+		{http.MethodPut, "/api/v1/nlp/providers/:name/canary", PermissionProvidersManage},
+		{http.MethodDelete, "/api/v1/admin/roles/bindings/:id", PermissionRolesManage},
+		{http.MethodPut, "/api/v1/admin/retention/:category", PermissionRolesManage},
+		{http.MethodGet, "/api/v1/admin/retention/dry-run", PermissionAuditRead},
+		{http.MethodPost, "/api/v1/admin/chaos", PermissionRolesManage},
+		{http.MethodGet, "/api/v1/admin/impersonations", PermissionRolesManage},
+		{http.MethodGet, "/api/v1/impersonations/audit", PermissionNone},
+		{http.MethodGet, "/api/v1/clusters/:id/health", PermissionCommandsRead},
+		{http.MethodPut, "/api/v1/clusters/:id", PermissionRolesManage},
+		{http.MethodGet, "/api/v1/app/config/reload", PermissionRolesManage},
+		{http.MethodPost, "/api/v1/sandboxes/:id/promote", PermissionCommandsExecute},
+		{http.MethodGet, "/api/v1/dashboards/:id/data", PermissionCommandsRead},
+		{http.MethodPost, "/api/v1/iac/suggestions", PermissionCommandsExecute},
+		{http.MethodGet, "/api/v1/logs/stream", PermissionCommandsRead},
+		{http.MethodGet, "/api/v1/pods/:name/logs", PermissionCommandsRead},
+		{http.MethodPost, "/api/v1/deployments/:name/scale", PermissionCommandsExecute},
+		{http.MethodGet, "/api/v1/secrets/:name/yaml", PermissionCommandsExecute},
+		{http.MethodGet, "/api/v1/security/posture", PermissionAuditRead},
+	} {
+		if got, ok := RequiredPermission(DefaultRules, tc.method, tc.route); !ok || got != tc.want {
+			t.Errorf("%s %s: expected %s, got %q", tc.method, tc.route, tc.want, got)
+		}
+	}
+	if _, ok := RequiredPermission(DefaultRules, http.MethodGet, "/healthz"); ok {
+		t.Errorf("expected routes outside the API to be left alone")
+	}
+}
+
+func TestAuditorsReadRetentionButOnlyAdminsChangeIt(t *testing.T) {
+	svc := NewService(NewMemoryRepository(), []string{"root"}, "", nil)
+	ctx := context.Background()
+	if _, err := svc.Assign(ctx, "root", BindingRequest{Role: RoleApprover, Subject: "auditors", SubjectKind: SubjectGroup}); err != nil {
+		t.Fatalf("assign: %v", err)
+	}
+	for _, tc := range []struct {
+		method, route string
+		allowed       bool
+	}{
+		{http.MethodGet, "/api/v1/admin/retention", true},
+		{http.MethodGet, "/api/v1/admin/retention/dry-run", true},
+		{http.MethodPut, "/api/v1/admin/retention/:category", false},
+		{http.MethodPost, "/api/v1/admin/retention/holds", false},
+		{http.MethodDelete, "/api/v1/admin/retention/holds/:id", false},
+		{http.MethodPost, "/api/v1/admin/retention/enforce", false},
+	} {
+		permission, ok := RequiredPermission(DefaultRules, tc.method, tc.route)
+		if !ok {
+			t.Fatalf("%s %s: expected a rule", tc.method, tc.route)
+		}
+		err := svc.AuthorizeAny(ctx, "audrey", []string{"auditors"}, permission)
+		if tc.allowed && err != nil {
+			t.Errorf("%s %s: expected an auditor to be allowed, got %v", tc.method, tc.route, err)
+		}
+		if !tc.allowed && !errors.As(err, &ErrForbidden{}) {
+			t.Errorf("%s %s: expected an auditor to be refused, got %v", tc.method, tc.route, err)
+		}
+		if err := svc.AuthorizeAny(ctx, "root", nil, permission); err != nil {
+			t.Errorf("%s %s: expected an admin to be allowed, got %v", tc.method, tc.route, err)
+		}
+	}
+}
@@ -0,0 +1,505 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	LabelSandbox          = "kubechat.io/sandbox"
+	AnnotationSource      = "kubechat.io/sandbox-source"
+	AnnotationExpiresAt   = "kubechat.io/sandbox-expires-at"
+	namespacePrefix       = "kubechat-sandbox-"
+	defaultTTL            = 30 * time.Minute
+	defaultVerifyTimeout  = 2 * time.Minute
+	defaultVerifyInterval = 2 * time.Second
+)
+
+type Status string
+
+const (
+	StatusProvisioned Status = "provisioned"
+	StatusVerified    Status = "verified"
+	StatusFailed      Status = "failed"
+	StatusPromoted    Status = "promoted"
+	StatusDeleted     Status = "deleted"
+)
+
+// ProposedChange is the mutation rehearsed inside the sandbox before it is offered for the real namespace.
+type ProposedChange struct {
+	Replicas *int32            `json:"replicas,omitempty"`
+	Images   map[string]string `json:"images,omitempty"`
+	Env      map[string]string `json:"env,omitempty"`
+}
+
+func (c ProposedChange) IsEmpty() bool {
+	return c.Replicas == nil && len(c.Images) == 0 && len(c.Env) == 0
+}
+
+type Request struct {
+	Config     string         `json:"config"`
+	Cluster    string         `json:"cluster"`
+	Namespace  string         `json:"namespace"`
+	Deployment string         `json:"deployment"`
+	Change     ProposedChange `json:"change"`
+}
+
+type CheckResult struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+type Sandbox struct {
+	ID               string         `json:"id"`
+	Config           string         `json:"config"`
+	Cluster          string         `json:"cluster"`
+	Namespace        string         `json:"namespace"`
+	SourceNamespace  string         `json:"sourceNamespace"`
+	Deployment       string         `json:"deployment"`
+	Change           ProposedChange `json:"change"`
+	ClonedConfigMaps []string       `json:"clonedConfigMaps,omitempty"`
+	Status           Status         `json:"status"`
+	Checks           []CheckResult  `json:"checks,omitempty"`
+	CreatedAt        time.Time      `json:"createdAt"`
+	ExpiresAt        time.Time      `json:"expiresAt"`
+}
+
+type ErrSandboxNotFound struct {
+	ID string
+}
+
+func (e ErrSandboxNotFound) Error() string {
+	return fmt.Sprintf("sandbox %s not found", e.ID)
+}
+
+var ErrNotVerified = errors.New("sandbox has not passed verification")
+
+// ClientFunc resolves a clientset for the given kubeconfig and cluster context.
+type ClientFunc func(config, cluster string) kubernetes.Interface
+
+type Manager struct {
+	clients        ClientFunc
+	ttl            time.Duration
+	verifyTimeout  time.Duration
+	verifyInterval time.Duration
+	clock          func() time.Time
+	logger         *log.Logger
+
+	mu        sync.Mutex
+	sandboxes map[string]*Sandbox
+}
+
+func NewManager(clients ClientFunc, ttl time.Duration, logger *log.Logger) *Manager {
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Manager{
+		clients:        clients,
+		ttl:            ttl,
+		verifyTimeout:  defaultVerifyTimeout,
+		verifyInterval: defaultVerifyInterval,
+		clock:          time.Now,
+		logger:         logger,
+		sandboxes:      make(map[string]*Sandbox),
+	}
+}
+
+// Create clones the target deployment and the config maps it references into an
+// ephemeral namespace and applies the proposed change there.
+func (m *Manager) Create(ctx context.Context, req Request) (Sandbox, error) {
+	if req.Namespace == "" || req.Deployment == "" {
+		return Sandbox{}, errors.New("namespace and deployment are required")
+	}
+	if req.Change.IsEmpty() {
+		return Sandbox{}, errors.New("a proposed change is required")
+	}
+
+	client := m.clients(req.Config, req.Cluster)
+	source, err := client.AppsV1().Deployments(req.Namespace).Get(ctx, req.Deployment, metav1.GetOptions{})
+	if err != nil {
+		return Sandbox{}, err
+	}
+
+	id := uuid.NewString()
+	now := m.clock().UTC()
+	sb := &Sandbox{
+		ID:              id,
+		Config:          req.Config,
+		Cluster:         req.Cluster,
+		Namespace:       namespacePrefix + id[:8],
+		SourceNamespace: req.Namespace,
+		Deployment:      req.Deployment,
+		Change:          req.Change,
+		Status:          StatusProvisioned,
+		CreatedAt:       now,
+		ExpiresAt:       now.Add(m.ttl),
+	}
+
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   sb.Namespace,
+			Labels: map[string]string{LabelSandbox: "true"},
+			Annotations: map[string]string{
+				AnnotationSource:    req.Namespace + "/" + req.Deployment,
+				AnnotationExpiresAt: sb.ExpiresAt.Format(time.RFC3339),
+			},
+		},
+	}
+	if _, err := client.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{}); err != nil {
+		return Sandbox{}, fmt.Errorf("create sandbox namespace: %w", err)
+	}
+
+	cleanup := func(cause error) (Sandbox, error) {
+		if delErr := client.CoreV1().Namespaces().Delete(context.WithoutCancel(ctx), sb.Namespace, metav1.DeleteOptions{}); delErr != nil && !apierrors.IsNotFound(delErr) {
+			m.logger.Warn("failed to clean up sandbox namespace", "namespace", sb.Namespace, "error", delErr)
+		}
+		return Sandbox{}, cause
+	}
+
+	for _, name := range referencedConfigMaps(source.Spec.Template.Spec) {
+		cm, err := client.CoreV1().ConfigMaps(req.Namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return cleanup(fmt.Errorf("read config map %s: %w", name, err))
+		}
+		clone := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: cm.Name, Namespace: sb.Namespace, Labels: cm.Labels},
+			Data:       cm.Data,
+			BinaryData: cm.BinaryData,
+		}
+		if _, err := client.CoreV1().ConfigMaps(sb.Namespace).Create(ctx, clone, metav1.CreateOptions{}); err != nil {
+			return cleanup(fmt.Errorf("clone config map %s: %w", name, err))
+		}
+		sb.ClonedConfigMaps = append(sb.ClonedConfigMaps, name)
+	}
+
+	clone := cloneDeployment(source, sb.Namespace)
+	ApplyChange(clone, req.Change)
+	if _, err := client.AppsV1().Deployments(sb.Namespace).Create(ctx, clone, metav1.CreateOptions{}); err != nil {
+		return cleanup(fmt.Errorf("clone deployment: %w", err))
+	}
+
+	m.mu.Lock()
+	m.sandboxes[id] = sb
+	m.mu.Unlock()
+
+	m.logger.Info("sandbox provisioned", "sandbox_id", id, "namespace", sb.Namespace, "source", req.Namespace+"/"+req.Deployment)
+	return *sb, nil
+}
+
+func (m *Manager) Get(id string) (Sandbox, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sb, ok := m.sandboxes[id]
+	if !ok {
+		return Sandbox{}, ErrSandboxNotFound{ID: id}
+	}
+	return *sb, nil
+}
+
+func (m *Manager) List() []Sandbox {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]Sandbox, 0, len(m.sandboxes))
+	for _, sb := range m.sandboxes {
+		out = append(out, *sb)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+// Verify waits for the sandboxed rollout to settle and records the check results.
+func (m *Manager) Verify(ctx context.Context, id string) (Sandbox, error) {
+	sb, err := m.Get(id)
+	if err != nil {
+		return Sandbox{}, err
+	}
+	client := m.clients(sb.Config, sb.Cluster)
+
+	ctx, cancel := context.WithTimeout(ctx, m.verifyTimeout)
+	defer cancel()
+
+	var checks []CheckResult
+poll:
+	for {
+		checks = m.runChecks(ctx, client, sb)
+		if allPassed(checks) || hasTerminalFailure(checks) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			checks = append(checks, CheckResult{Name: "timeout", Passed: false, Message: "sandbox did not become ready before the verification deadline"})
+			break poll
+		case <-time.After(m.verifyInterval):
+		}
+	}
+
+	status := StatusVerified
+	if !allPassed(checks) {
+		status = StatusFailed
+	}
+	return m.update(id, func(s *Sandbox) {
+		s.Checks = checks
+		s.Status = status
+	})
+}
+
+func (m *Manager) runChecks(ctx context.Context, client kubernetes.Interface, sb Sandbox) []CheckResult {
+	dep, err := client.AppsV1().Deployments(sb.Namespace).Get(ctx, sb.Deployment, metav1.GetOptions{})
+	if err != nil {
+		return []CheckResult{{Name: "rollout", Passed: false, Message: err.Error()}}
+	}
+
+	desired := int32(1)
+	if dep.Spec.Replicas != nil {
+		desired = *dep.Spec.Replicas
+	}
+	rollout := CheckResult{Name: "rollout", Passed: dep.Status.ObservedGeneration >= dep.Generation &&
+		dep.Status.UpdatedReplicas == desired && dep.Status.AvailableReplicas == desired}
+	if !rollout.Passed {
+		rollout.Message = fmt.Sprintf("%d/%d replicas available", dep.Status.AvailableReplicas, desired)
+	}
+
+	pods := CheckResult{Name: "pods", Passed: true}
+	selector, err := metav1.LabelSelectorAsSelector(dep.Spec.Selector)
+	if err != nil {
+		selector = labels.Everything()
+	}
+	podList, err := client.CoreV1().Pods(sb.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		pods = CheckResult{Name: "pods", Passed: false, Message: err.Error()}
+	} else {
+		for _, pod := range podList.Items {
+			if reason := failingContainerReason(pod); reason != "" {
+				pods = CheckResult{Name: "pods", Passed: false, Message: fmt.Sprintf("pod %s: %s", pod.Name, reason)}
+				break
+			}
+		}
+	}
+
+	return []CheckResult{rollout, pods}
+}
+
+// Promote applies the rehearsed change to the real deployment once the sandbox has been verified.
+func (m *Manager) Promote(ctx context.Context, id string) (Sandbox, error) {
+	sb, err := m.Get(id)
+	if err != nil {
+		return Sandbox{}, err
+	}
+	if sb.Status != StatusVerified {
+		return Sandbox{}, ErrNotVerified
+	}
+
+	client := m.clients(sb.Config, sb.Cluster)
+	target, err := client.AppsV1().Deployments(sb.SourceNamespace).Get(ctx, sb.Deployment, metav1.GetOptions{})
+	if err != nil {
+		return Sandbox{}, err
+	}
+	ApplyChange(target, sb.Change)
+	if _, err := client.AppsV1().Deployments(sb.SourceNamespace).Update(ctx, target, metav1.UpdateOptions{}); err != nil {
+		return Sandbox{}, err
+	}
+
+	updated, err := m.update(id, func(s *Sandbox) { s.Status = StatusPromoted })
+	if err != nil {
+		return Sandbox{}, err
+	}
+	if err := m.Delete(ctx, id); err != nil {
+		m.logger.Warn("failed to clean up promoted sandbox", "sandbox_id", id, "error", err)
+	}
+	return updated, nil
+}
+
+func (m *Manager) Delete(ctx context.Context, id string) error {
+	sb, err := m.Get(id)
+	if err != nil {
+		return err
+	}
+	err = m.clients(sb.Config, sb.Cluster).CoreV1().Namespaces().Delete(ctx, sb.Namespace, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	m.mu.Lock()
+	delete(m.sandboxes, id)
+	m.mu.Unlock()
+	return nil
+}
+
+// Reap removes every sandbox whose TTL has elapsed.
+func (m *Manager) Reap(ctx context.Context) {
+	now := m.clock()
+	for _, sb := range m.List() {
+		if now.Before(sb.ExpiresAt) {
+			continue
+		}
+		if err := m.Delete(ctx, sb.ID); err != nil {
+			m.logger.Warn("failed to reap sandbox", "sandbox_id", sb.ID, "error", err)
+		}
+	}
+}
+
+// Run periodically reaps expired sandboxes until the context is cancelled.
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Reap(ctx)
+		}
+	}
+}
+
+func (m *Manager) update(id string, fn func(*Sandbox)) (Sandbox, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sb, ok := m.sandboxes[id]
+	if !ok {
+		return Sandbox{}, ErrSandboxNotFound{ID: id}
+	}
+	fn(sb)
+	return *sb, nil
+}
+
+// ApplyChange mutates the deployment spec with the proposed change.
+func ApplyChange(dep *appsv1.Deployment, change ProposedChange) {
+	if change.Replicas != nil {
+		replicas := *change.Replicas
+		dep.Spec.Replicas = &replicas
+	}
+	for i := range dep.Spec.Template.Spec.Containers {
+		container := &dep.Spec.Template.Spec.Containers[i]
+		if image, ok := change.Images[container.Name]; ok {
+			container.Image = image
+		}
+		for _, key := range sortedKeys(change.Env) {
+			setEnv(container, key, change.Env[key])
+		}
+	}
+}
+
+func setEnv(container *corev1.Container, key, value string) {
+	for i := range container.Env {
+		if container.Env[i].Name == key {
+			container.Env[i].Value = value
+			container.Env[i].ValueFrom = nil
+			return
+		}
+	}
+	container.Env = append(container.Env, corev1.EnvVar{Name: key, Value: value})
+}
+
+func cloneDeployment(source *appsv1.Deployment, namespace string) *appsv1.Deployment {
+	clone := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        source.Name,
+			Namespace:   namespace,
+			Labels:      source.Labels,
+			Annotations: map[string]string{AnnotationSource: source.Namespace + "/" + source.Name},
+		},
+		Spec: *source.Spec.DeepCopy(),
+	}
+	// Secrets and service accounts are intentionally not cloned; fall back to the default account.
+	clone.Spec.Template.Spec.ServiceAccountName = ""
+	return clone
+}
+
+func referencedConfigMaps(spec corev1.PodSpec) []string {
+	set := map[string]struct{}{}
+	for _, vol := range spec.Volumes {
+		if vol.ConfigMap != nil {
+			set[vol.ConfigMap.Name] = struct{}{}
+		}
+		if vol.Projected != nil {
+			for _, src := range vol.Projected.Sources {
+				if src.ConfigMap != nil {
+					set[src.ConfigMap.Name] = struct{}{}
+				}
+			}
+		}
+	}
+	containers := append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...)
+	for _, c := range containers {
+		for _, from := range c.EnvFrom {
+			if from.ConfigMapRef != nil {
+				set[from.ConfigMapRef.Name] = struct{}{}
+			}
+		}
+		for _, env := range c.Env {
+			if env.ValueFrom != nil && env.ValueFrom.ConfigMapKeyRef != nil {
+				set[env.ValueFrom.ConfigMapKeyRef.Name] = struct{}{}
+			}
+		}
+	}
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var terminalWaitingReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"CreateContainerConfigError": true,
+	"InvalidImageName":           true,
+}
+
+func failingContainerReason(pod corev1.Pod) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Waiting != nil && terminalWaitingReasons[status.State.Waiting.Reason] {
+			return status.State.Waiting.Reason
+		}
+	}
+	return ""
+}
+
+func allPassed(checks []CheckResult) bool {
+	for _, c := range checks {
+		if !c.Passed {
+			return false
+		}
+	}
+	return len(checks) > 0
+}
+
+func hasTerminalFailure(checks []CheckResult) bool {
+	for _, c := range checks {
+		if c.Name == "pods" && !c.Passed {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(in map[string]string) []string {
+	keys := make([]string, 0, len(in))
+	for k := range in {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
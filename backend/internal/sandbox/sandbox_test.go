@@ -0,0 +1,141 @@
+package sandbox
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func int32Ptr(v int32) *int32 { return &v }
+
+func newFixture() *fake.Clientset {
+	return fake.NewSimpleClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "checkout", Namespace: "payments"},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: int32Ptr(2),
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "checkout"}},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "checkout"}},
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{
+							Name:    "app",
+							Image:   "checkout:1.0",
+							EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "checkout-config"}}}},
+						}},
+					},
+				},
+			},
+		},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "checkout-config", Namespace: "payments"}, Data: map[string]string{"MODE": "live"}},
+	)
+}
+
+func newTestManager(client *fake.Clientset) *Manager {
+	m := NewManager(func(config, cluster string) kubernetes.Interface { return client }, time.Minute, log.NewWithOptions(io.Discard, log.Options{}))
+	m.verifyTimeout = 50 * time.Millisecond
+	m.verifyInterval = 5 * time.Millisecond
+	return m
+}
+
+func TestManagerCreateClonesWorkload(t *testing.T) {
+	client := newFixture()
+	manager := newTestManager(client)
+
+	sb, err := manager.Create(context.Background(), Request{
+		Namespace:  "payments",
+		Deployment: "checkout",
+		Change:     ProposedChange{Images: map[string]string{"app": "checkout:2.0"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(sb.Namespace, namespacePrefix) {
+		t.Fatalf("expected sandbox namespace prefix, got %s", sb.Namespace)
+	}
+	if len(sb.ClonedConfigMaps) != 1 || sb.ClonedConfigMaps[0] != "checkout-config" {
+		t.Fatalf("expected referenced config map to be cloned, got %v", sb.ClonedConfigMaps)
+	}
+
+	clone, err := client.AppsV1().Deployments(sb.Namespace).Get(context.Background(), "checkout", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected cloned deployment: %v", err)
+	}
+	if clone.Spec.Template.Spec.Containers[0].Image != "checkout:2.0" {
+		t.Fatalf("expected change applied to clone, got %s", clone.Spec.Template.Spec.Containers[0].Image)
+	}
+	original, _ := client.AppsV1().Deployments("payments").Get(context.Background(), "checkout", metav1.GetOptions{})
+	if original.Spec.Template.Spec.Containers[0].Image != "checkout:1.0" {
+		t.Fatal("expected original deployment to stay untouched")
+	}
+}
+
+func TestManagerPromoteRequiresVerification(t *testing.T) {
+	client := newFixture()
+	manager := newTestManager(client)
+
+	sb, err := manager.Create(context.Background(), Request{Namespace: "payments", Deployment: "checkout", Change: ProposedChange{Replicas: int32Ptr(4)}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := manager.Promote(context.Background(), sb.ID); err != ErrNotVerified {
+		t.Fatalf("expected ErrNotVerified, got %v", err)
+	}
+
+	verified, err := manager.Verify(context.Background(), sb.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if verified.Status != StatusFailed {
+		t.Fatalf("expected verification to fail without a running rollout, got %s", verified.Status)
+	}
+
+	dep, _ := client.AppsV1().Deployments(sb.Namespace).Get(context.Background(), "checkout", metav1.GetOptions{})
+	dep.Status = appsv1.DeploymentStatus{UpdatedReplicas: 4, AvailableReplicas: 4}
+	client.AppsV1().Deployments(sb.Namespace).UpdateStatus(context.Background(), dep, metav1.UpdateOptions{})
+
+	verified, err = manager.Verify(context.Background(), sb.ID)
+	if err != nil || verified.Status != StatusVerified {
+		t.Fatalf("expected verified sandbox, got %+v (%v)", verified, err)
+	}
+
+	promoted, err := manager.Promote(context.Background(), sb.ID)
+	if err != nil || promoted.Status != StatusPromoted {
+		t.Fatalf("expected promotion, got %+v (%v)", promoted, err)
+	}
+	original, _ := client.AppsV1().Deployments("payments").Get(context.Background(), "checkout", metav1.GetOptions{})
+	if *original.Spec.Replicas != 4 {
+		t.Fatalf("expected change promoted to real namespace, got %d", *original.Spec.Replicas)
+	}
+	if _, err := manager.Get(sb.ID); err == nil {
+		t.Fatal("expected promoted sandbox to be cleaned up")
+	}
+}
+
+func TestManagerReapsExpiredSandboxes(t *testing.T) {
+	client := newFixture()
+	manager := newTestManager(client)
+	sb, err := manager.Create(context.Background(), Request{Namespace: "payments", Deployment: "checkout", Change: ProposedChange{Replicas: int32Ptr(1)}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	manager.clock = func() time.Time { return sb.ExpiresAt.Add(time.Second) }
+	manager.Reap(context.Background())
+
+	if len(manager.List()) != 0 {
+		t.Fatal("expected expired sandbox to be reaped")
+	}
+	if _, err := client.CoreV1().Namespaces().Get(context.Background(), sb.Namespace, metav1.GetOptions{}); err == nil {
+		t.Fatal("expected sandbox namespace to be deleted")
+	}
+}
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS security_posture_reports (
+    id TEXT PRIMARY KEY,
+    instance TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    report JSONB NOT NULL,
+    generated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS security_posture_reports_generated_idx ON security_posture_reports (generated_at);
@@ -0,0 +1,69 @@
+package security
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pramodksahoo/kubechat/backend/internal/database"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// PostgresRepository stores each report as a JSON document, so every replica's starts are kept.
+type PostgresRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresRepository applies the security posture schema migrations and returns a repository backed by db.
+func NewPostgresRepository(ctx context.Context, db *sqlx.DB) (*PostgresRepository, error) {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Migrate(ctx, db, "security", sub); err != nil {
+		return nil, err
+	}
+	return &PostgresRepository{db: db}, nil
+}
+
+func (r *PostgresRepository) Save(ctx context.Context, report Report) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `INSERT INTO security_posture_reports (id, instance, status, report, generated_at)
+		VALUES ($1, $2, $3, $4, $5)`, report.ID, report.Instance, string(report.Status), body, report.GeneratedAt)
+	return err
+}
+
+func (r *PostgresRepository) Latest(ctx context.Context) (Report, error) {
+	reports, err := r.List(ctx, 1)
+	if err != nil {
+		return Report{}, err
+	}
+	if len(reports) == 0 {
+		return Report{}, ErrNoReport{}
+	}
+	return reports[0], nil
+}
+
+func (r *PostgresRepository) List(ctx context.Context, limit int) ([]Report, error) {
+	var bodies [][]byte
+	if err := r.db.SelectContext(ctx, &bodies, `SELECT report FROM security_posture_reports ORDER BY generated_at DESC LIMIT $1`, limit); err != nil {
+		return nil, err
+	}
+	reports := make([]Report, 0, len(bodies))
+	for _, body := range bodies {
+		var report Report
+		if err := json.Unmarshal(body, &report); err != nil {
+			return nil, fmt.Errorf("decode security posture report: %w", err)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
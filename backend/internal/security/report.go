@@ -0,0 +1,87 @@
+// Package security reports the security posture the server started with: whether TLS is on,
+// which browser origins may call the API, how callers are identified, which external providers
+// receive data, whether stored data is encrypted, whether FIPS mode is on and whether default
+// credentials are in use. Controls marked mandatory must pass for a production start.
+package security
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+)
+
+var statusRank = map[Status]int{StatusPass: 0, StatusWarn: 1, StatusFail: 2}
+
+// Check is one control of the posture. A missing control fails when it is mandatory and only
+// warns otherwise.
+type Check struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Status    Status `json:"status"`
+	Mandatory bool   `json:"mandatory"`
+	Detail    string `json:"detail"`
+}
+
+// Report is the posture of one server start.
+type Report struct {
+	ID       string `json:"id"`
+	Version  string `json:"version"`
+	Instance string `json:"instance"`
+	// Production reports that the server refuses to start while mandatory controls fail.
+	Production        bool      `json:"production"`
+	Status            Status    `json:"status"`
+	TLS               bool      `json:"tls"`
+	CORSOrigins       []string  `json:"corsOrigins"`
+	AuthModes         []string  `json:"authModes"`
+	ExternalProviders []string  `json:"externalProviders"`
+	EncryptionAtRest  bool      `json:"encryptionAtRest"`
+	FIPS              bool      `json:"fips"`
+	Checks            []Check   `json:"checks"`
+	GeneratedAt       time.Time `json:"generatedAt"`
+}
+
+// Missing returns the mandatory controls that failed.
+func (r Report) Missing() []Check {
+	var missing []Check
+	for _, check := range r.Checks {
+		if check.Mandatory && check.Status == StatusFail {
+			missing = append(missing, check)
+		}
+	}
+	return missing
+}
+
+// Enforce returns ErrMandatoryControls when a mandatory control failed.
+func (r Report) Enforce() error {
+	missing := r.Missing()
+	if len(missing) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(missing))
+	for _, check := range missing {
+		ids = append(ids, check.ID)
+	}
+	return ErrMandatoryControls{Controls: ids}
+}
+
+type ErrMandatoryControls struct {
+	Controls []string
+}
+
+func (e ErrMandatoryControls) Error() string {
+	return fmt.Sprintf("mandatory security controls missing in production mode: %s", strings.Join(e.Controls, ", "))
+}
+
+type ErrNoReport struct{}
+
+func (ErrNoReport) Error() string {
+	return "no security posture report has been recorded"
+}
@@ -0,0 +1,57 @@
+package security
+
+import (
+	"context"
+	"sync"
+)
+
+// maxMemoryReports bounds the reports kept when no database is configured.
+const maxMemoryReports = 100
+
+// Repository persists the posture report of every server start.
+type Repository interface {
+	Save(ctx context.Context, report Report) error
+	// Latest returns the most recent report, or ErrNoReport.
+	Latest(ctx context.Context) (Report, error)
+	// List returns up to limit reports, most recent first.
+	List(ctx context.Context, limit int) ([]Report, error)
+}
+
+// MemoryRepository keeps reports in process memory; it is used when no database is configured.
+type MemoryRepository struct {
+	mu      sync.RWMutex
+	reports []Report
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{}
+}
+
+func (r *MemoryRepository) Save(ctx context.Context, report Report) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reports = append(r.reports, report)
+	if len(r.reports) > maxMemoryReports {
+		r.reports = r.reports[len(r.reports)-maxMemoryReports:]
+	}
+	return nil
+}
+
+func (r *MemoryRepository) Latest(ctx context.Context) (Report, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.reports) == 0 {
+		return Report{}, ErrNoReport{}
+	}
+	return r.reports[len(r.reports)-1], nil
+}
+
+func (r *MemoryRepository) List(ctx context.Context, limit int) ([]Report, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	reports := make([]Report, 0, min(limit, len(r.reports)))
+	for i := len(r.reports) - 1; i >= 0 && len(reports) < limit; i-- {
+		reports = append(reports, r.reports[i])
+	}
+	return reports, nil
+}
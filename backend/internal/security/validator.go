@@ -0,0 +1,221 @@
+package security
+
+import (
+	"crypto/fips140"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pramodksahoo/kubechat/backend/config"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+)
+
+const (
+	CheckTLS                = "tls"
+	CheckCORS               = "cors"
+	CheckAuth               = "auth"
+	CheckExternalProviders  = "external_providers"
+	CheckEncryptionAtRest   = "encryption_at_rest"
+	CheckFIPS               = "fips"
+	CheckDefaultCredentials = "default_credentials"
+
+	// minSigningKeyLength is the shortest signing key not reported as weak.
+	minSigningKeyLength = 32
+)
+
+// defaultPasswords are passwords shipped by images and tutorials that should never reach production.
+var defaultPasswords = []string{"postgres", "password", "admin", "changeme", "secret", "kubechat"}
+
+// Validator builds the posture report from the server configuration.
+type Validator struct {
+	cfg      *config.AppConfig
+	instance string
+	fips     func() bool
+	now      func() time.Time
+}
+
+func NewValidator(cfg *config.AppConfig) *Validator {
+	instance, _ := os.Hostname()
+	return &Validator{
+		cfg:      cfg,
+		instance: instance,
+		fips:     fips140.Enabled,
+		now:      func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// Validate evaluates every control against the configuration.
+func (v *Validator) Validate() Report {
+	cfg := v.cfg
+	report := Report{
+		ID:                uuid.NewString(),
+		Version:           cfg.Version,
+		Instance:          v.instance,
+		Production:        cfg.Security.Production,
+		TLS:               cfg.IsSecure,
+		CORSOrigins:       corsOrigins(cfg),
+		AuthModes:         authModes(cfg),
+		ExternalProviders: externalProviders(cfg),
+		EncryptionAtRest:  cfg.Security.StorageEncrypted,
+		FIPS:              v.fips(),
+		GeneratedAt:       v.now(),
+	}
+
+	report.Checks = []Check{
+		check(CheckTLS, "TLS", true, report.TLS,
+			"the API is served over HTTPS",
+			"the API is served over plain HTTP; set --certFile and --keyFile"),
+		check(CheckCORS, "CORS", true, !slices.Contains(report.CORSOrigins, "*"),
+			"browsers may call the API from "+strings.Join(report.CORSOrigins, ", "),
+			"browsers may call the API with credentials from any origin; list the UI origins in --cors-allowed-origins"),
+		check(CheckAuth, "Authentication", false, false,
+			"",
+			fmt.Sprintf("callers are identified by the %s header (%s); it must be set by an authenticating proxy", identity.Header, strings.Join(report.AuthModes, ", "))),
+		check(CheckExternalProviders, "External providers", false, len(report.ExternalProviders) == 0,
+			"no data is sent to providers outside the deployment",
+			"prompts or outputs are sent to "+strings.Join(report.ExternalProviders, ", ")),
+		check(CheckEncryptionAtRest, "Encryption at rest", true, report.EncryptionAtRest,
+			"storage is declared to encrypt data at rest",
+			"storage is not declared to encrypt data at rest ("+strings.Join(storedIn(cfg), ", ")+"); enable encryption and set --storage-encrypted"),
+		check(CheckFIPS, "FIPS 140-3 mode", false, report.FIPS,
+			"the Go cryptographic module runs in FIPS 140-3 mode",
+			"the Go cryptographic module does not run in FIPS 140-3 mode; start with GODEBUG=fips140=on to enable it"),
+	}
+	weak := defaultCredentials(cfg)
+	report.Checks = append(report.Checks, check(CheckDefaultCredentials, "Default credentials", true, len(weak) == 0,
+		"no default or weak credentials were found",
+		"default or weak credentials in use: "+strings.Join(weak, ", ")))
+
+	report.Status = StatusPass
+	for _, c := range report.Checks {
+		if statusRank[c.Status] > statusRank[report.Status] {
+			report.Status = c.Status
+		}
+	}
+	return report
+}
+
+func check(id, title string, mandatory, ok bool, passed, missing string) Check {
+	c := Check{ID: id, Title: title, Mandatory: mandatory, Status: StatusPass, Detail: passed}
+	if !ok {
+		c.Status = StatusWarn
+		if mandatory {
+			c.Status = StatusFail
+		}
+		c.Detail = missing
+	}
+	return c
+}
+
+func corsOrigins(cfg *config.AppConfig) []string {
+	if len(cfg.Security.CORSAllowedOrigins) == 0 {
+		return []string{"*"}
+	}
+	return cfg.Security.CORSAllowedOrigins
+}
+
+func authModes(cfg *config.AppConfig) []string {
+	modes := []string{"trusted-header"}
+	if cfg.ImpersonateUsers {
+		modes = append(modes, "kubernetes-impersonation")
+	}
+	if len(cfg.AdminUsers) > 0 {
+		modes = append(modes, "admin-impersonation")
+	}
+	if len(cfg.BreakGlassUsers) > 0 {
+		modes = append(modes, "break-glass")
+	}
+	return modes
+}
+
+func externalProviders(cfg *config.AppConfig) []string {
+	providers := make([]string, 0)
+	if cfg.AI.AnthropicAPIKey != "" {
+		providers = append(providers, "anthropic")
+	}
+	if cfg.AI.GoogleAPIKey != "" {
+		providers = append(providers, "gemini")
+	}
+	if cfg.AI.OllamaURL != "" && !isLocal(cfg.AI.OllamaURL) {
+		providers = append(providers, "ollama (remote)")
+	}
+	if strings.HasPrefix(cfg.AttachmentStore, "s3://") {
+		providers = append(providers, "s3 attachment store")
+	}
+	return providers
+}
+
+// isLocal reports whether rawURL points at this host.
+func isLocal(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// storedIn names where the server keeps data.
+func storedIn(cfg *config.AppConfig) []string {
+	places := []string{}
+	if cfg.Security.DatabaseURL != "" {
+		places = append(places, "PostgreSQL database")
+	}
+	if cfg.AttachmentStore != "" {
+		places = append(places, "attachment store "+strings.SplitN(cfg.AttachmentStore, ":", 2)[0])
+	} else {
+		places = append(places, "local attachment directory")
+	}
+	return places
+}
+
+// defaultCredentials names the credentials that are defaults or too weak, without revealing them.
+func defaultCredentials(cfg *config.AppConfig) []string {
+	var weak []string
+	if user, password, ok := databaseCredentials(cfg.Security.DatabaseURL); ok && (password == "" || password == user || slices.Contains(defaultPasswords, password)) {
+		weak = append(weak, "database password")
+	}
+	if key := cfg.ExportSigningKey; key != "" && len(key) < minSigningKeyLength {
+		weak = append(weak, "export signing key shorter than 32 characters")
+	}
+	if key := cfg.RequestSigningKey; key != "" && len(key) < minSigningKeyLength {
+		weak = append(weak, "request signing key shorter than 32 characters")
+	}
+	return weak
+}
+
+// databaseCredentials returns the user and password of a URL or key=value connection string.
+func databaseCredentials(dsn string) (string, string, bool) {
+	if dsn == "" {
+		return "", "", false
+	}
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		password, ok := u.User.Password()
+		return u.User.Username(), password, ok
+	}
+	var user, password string
+	found := false
+	for _, field := range strings.Fields(dsn) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, "'")
+		switch key {
+		case "user":
+			user = value
+		case "password":
+			password, found = value, true
+		}
+	}
+	return user, password, found
+}
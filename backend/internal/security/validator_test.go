@@ -0,0 +1,90 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/config"
+)
+
+func newTestValidator(cfg *config.AppConfig) *Validator {
+	v := NewValidator(cfg)
+	v.instance = "replica-1"
+	v.fips = func() bool { return false }
+	v.now = func() time.Time { return time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC) }
+	return v
+}
+
+func checkByID(report Report, id string) Check {
+	for _, check := range report.Checks {
+		if check.ID == id {
+			return check
+		}
+	}
+	return Check{}
+}
+
+func TestDefaultConfigurationMissesMandatoryControls(t *testing.T) {
+	cfg := config.NewAppConfig("1.0.0", "[::]:7080", 100, 200, false)
+	cfg.AI.OllamaURL = "http://localhost:11434"
+	cfg.AI.AnthropicAPIKey = "sk-test"
+	cfg.Security.DatabaseURL = "postgres://postgres:postgres@db:5432/kubechat"
+	cfg.Security.Production = true
+
+	report := newTestValidator(cfg).Validate()
+	if report.Status != StatusFail || report.TLS || report.Instance != "replica-1" {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	for _, id := range []string{CheckTLS, CheckCORS, CheckEncryptionAtRest, CheckDefaultCredentials} {
+		if check := checkByID(report, id); check.Status != StatusFail || !check.Mandatory {
+			t.Fatalf("expected %s to fail, got %+v", id, check)
+		}
+	}
+	if check := checkByID(report, CheckFIPS); check.Status != StatusWarn {
+		t.Fatalf("expected FIPS to only warn, got %+v", check)
+	}
+	if !slices.Equal(report.ExternalProviders, []string{"anthropic"}) {
+		t.Fatalf("expected only the hosted provider to be external, got %v", report.ExternalProviders)
+	}
+	if detail := checkByID(report, CheckDefaultCredentials).Detail; strings.Contains(detail, "postgres@") {
+		t.Fatalf("expected credentials not to be revealed, got %q", detail)
+	}
+
+	var missing ErrMandatoryControls
+	if err := report.Enforce(); !errors.As(err, &missing) || len(missing.Controls) != 4 {
+		t.Fatalf("expected four missing controls, got %v", err)
+	}
+}
+
+func TestHardenedConfigurationPassesMandatoryControls(t *testing.T) {
+	cfg := config.NewAppConfig("1.0.0", "[::]:7443", 100, 200, true)
+	cfg.AdminUsers = []string{"alice"}
+	cfg.ExportSigningKey = strings.Repeat("k", 48)
+	cfg.Security = config.SecurityConfig{
+		CORSAllowedOrigins: []string{"https://kubechat.example.com"},
+		StorageEncrypted:   true,
+		Production:         true,
+		DatabaseURL:        "host=db user=kubechat password='s3cret-and-long' dbname=kubechat",
+	}
+
+	report := newTestValidator(cfg).Validate()
+	if err := report.Enforce(); err != nil {
+		t.Fatalf("expected the mandatory controls to pass, got %v", err)
+	}
+	if report.Status != StatusWarn || !slices.Equal(report.AuthModes, []string{"trusted-header", "admin-impersonation"}) {
+		t.Fatalf("expected only advisory warnings, got %+v", report)
+	}
+
+	repo := NewMemoryRepository()
+	if _, err := repo.Latest(context.Background()); !errors.As(err, &ErrNoReport{}) {
+		t.Fatalf("expected no report before the first start, got %v", err)
+	}
+	repo.Save(context.Background(), report)
+	if latest, err := repo.Latest(context.Background()); err != nil || latest.ID != report.ID {
+		t.Fatalf("expected the report to be kept, got %+v, %v", latest, err)
+	}
+}
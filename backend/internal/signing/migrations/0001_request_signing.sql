@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS signed_request_nonces (
+    nonce TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS signed_request_nonces_expires_idx ON signed_request_nonces (expires_at);
+
+CREATE TABLE IF NOT EXISTS signed_request_audit (
+    id TEXT PRIMARY KEY,
+    "user" TEXT NOT NULL,
+    method TEXT NOT NULL,
+    path TEXT NOT NULL,
+    outcome TEXT NOT NULL,
+    severity TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS signed_request_audit_user_idx ON signed_request_audit ("user", at);
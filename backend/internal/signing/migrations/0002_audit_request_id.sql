@@ -0,0 +1 @@
+ALTER TABLE signed_request_audit ADD COLUMN IF NOT EXISTS request_id TEXT NOT NULL DEFAULT '';
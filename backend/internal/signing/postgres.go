@@ -0,0 +1,112 @@
+package signing
+
+import (
+	"context"
+	"embed"
+	"io/fs"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pramodksahoo/kubechat/backend/internal/database"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// PostgresRepository shares used nonces between replicas, so a request signed once is accepted
+// by at most one of them.
+type PostgresRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresRepository applies the request signing schema migrations and returns a repository backed by db.
+func NewPostgresRepository(ctx context.Context, db *sqlx.DB) (*PostgresRepository, error) {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Migrate(ctx, db, "signing", sub); err != nil {
+		return nil, err
+	}
+	return &PostgresRepository{db: db}, nil
+}
+
+type auditRow struct {
+	ID        string    `db:"id"`
+	User      string    `db:"user"`
+	Method    string    `db:"method"`
+	Path      string    `db:"path"`
+	Outcome   string    `db:"outcome"`
+	Severity  string    `db:"severity"`
+	Reason    string    `db:"reason"`
+	RequestID string    `db:"request_id"`
+	At        time.Time `db:"at"`
+}
+
+func (r *PostgresRepository) ClaimNonce(ctx context.Context, nonce string, expires time.Time) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM signed_request_nonces WHERE expires_at < $1`, time.Now().UTC()); err != nil {
+		return err
+	}
+	result, err := r.db.ExecContext(ctx, `INSERT INTO signed_request_nonces (nonce, expires_at) VALUES ($1, $2) ON CONFLICT (nonce) DO NOTHING`, nonce, expires)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrReplayed{Nonce: nonce}
+	}
+	return nil
+}
+
+func (r *PostgresRepository) AppendAudit(ctx context.Context, entry AuditEntry) error {
+	_, err := r.db.NamedExecContext(ctx, `INSERT INTO signed_request_audit (id, "user", method, path, outcome, severity, reason, request_id, at)
+		VALUES (:id, :user, :method, :path, :outcome, :severity, :reason, :request_id, :at)`, auditRow{
+		ID:        entry.ID,
+		User:      entry.User,
+		Method:    entry.Method,
+		Path:      entry.Path,
+		Outcome:   string(entry.Outcome),
+		Severity:  string(entry.Severity),
+		Reason:    entry.Reason,
+		RequestID: entry.RequestID,
+		At:        entry.At,
+	})
+	return err
+}
+
+func (r *PostgresRepository) ListAudit(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	query := `SELECT id, "user", method, path, outcome, severity, reason, request_id, at FROM signed_request_audit WHERE TRUE`
+	var args []any
+	if filter.User != "" {
+		args = append(args, filter.User)
+		query += ` AND "user" = $` + strconv.Itoa(len(args))
+	}
+	if filter.Severity != "" {
+		args = append(args, string(filter.Severity))
+		query += ` AND severity = $` + strconv.Itoa(len(args))
+	}
+	query += ` ORDER BY at DESC`
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += ` LIMIT $` + strconv.Itoa(len(args))
+	}
+	var rows []auditRow
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, err
+	}
+	entries := make([]AuditEntry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, AuditEntry{
+			ID:        row.ID,
+			User:      row.User,
+			Method:    row.Method,
+			Path:      row.Path,
+			Outcome:   Outcome(row.Outcome),
+			Severity:  Severity(row.Severity),
+			Reason:    row.Reason,
+			RequestID: row.RequestID,
+			At:        row.At.UTC(),
+		})
+	}
+	return entries, nil
+}
@@ -0,0 +1,67 @@
+package signing
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Repository remembers used nonces and keeps the signed-request audit log.
+type Repository interface {
+	// ClaimNonce records nonce until expires and returns ErrReplayed when it is already recorded.
+	ClaimNonce(ctx context.Context, nonce string, expires time.Time) error
+	AppendAudit(ctx context.Context, entry AuditEntry) error
+	// ListAudit returns matching entries, newest first.
+	ListAudit(ctx context.Context, filter AuditFilter) ([]AuditEntry, error)
+}
+
+// MemoryRepository keeps nonces and the audit log in process memory; it is used when no database
+// is configured. Replicas then only reject nonces they have seen themselves.
+type MemoryRepository struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+	audit  []AuditEntry
+	now    func() time.Time
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{nonces: make(map[string]time.Time), now: time.Now}
+}
+
+func (r *MemoryRepository) ClaimNonce(ctx context.Context, nonce string, expires time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	for seen, until := range r.nonces {
+		if until.Before(now) {
+			delete(r.nonces, seen)
+		}
+	}
+	if _, ok := r.nonces[nonce]; ok {
+		return ErrReplayed{Nonce: nonce}
+	}
+	r.nonces[nonce] = expires
+	return nil
+}
+
+func (r *MemoryRepository) AppendAudit(ctx context.Context, entry AuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.audit = append(r.audit, entry)
+	return nil
+}
+
+func (r *MemoryRepository) ListAudit(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := make([]AuditEntry, 0)
+	for i := len(r.audit) - 1; i >= 0; i-- {
+		if filter.matches(r.audit[i]) {
+			entries = append(entries, r.audit[i])
+			if filter.Limit > 0 && len(entries) == filter.Limit {
+				break
+			}
+		}
+	}
+	return entries, nil
+}
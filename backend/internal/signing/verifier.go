@@ -0,0 +1,113 @@
+package signing
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/pramodksahoo/kubechat/backend/internal/audit"
+	"github.com/pramodksahoo/kubechat/backend/internal/logging"
+)
+
+// Verifier checks signed requests and records every attempt in the audit log.
+type Verifier struct {
+	key    []byte
+	repo   Repository
+	ledger Ledger
+	logger *log.Logger
+	now    func() time.Time
+}
+
+// Ledger chains the attempts so that a refused signature cannot be quietly erased from the log.
+type Ledger interface {
+	Append(ctx context.Context, source audit.Source, entry any) error
+}
+
+func NewVerifier(key []byte, repo Repository, logger *log.Logger) *Verifier {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Verifier{key: key, repo: repo, logger: logger, now: time.Now}
+}
+
+// SetLedger adds every audited attempt to ledger.
+func (v *Verifier) SetLedger(ledger Ledger) {
+	v.ledger = ledger
+}
+
+// Verify accepts req when its signature matches, its timestamp is within MaxSkew and its nonce
+// was not used before. Every outcome is audited under the ID of the request that made the attempt;
+// refusals are logged as security events.
+func (v *Verifier) Verify(ctx context.Context, req Request) error {
+	err := v.verify(ctx, req)
+	logger := logging.FromContext(ctx, v.logger)
+	entry := AuditEntry{
+		ID:        uuid.NewString(),
+		User:      req.User,
+		Method:    req.Method,
+		Path:      req.URI,
+		Outcome:   OutcomeAccepted,
+		Severity:  SeverityCritical,
+		RequestID: logging.RequestID(ctx),
+		At:        v.now().UTC(),
+	}
+	if err != nil {
+		var invalid ErrInvalidSignature
+		var replayed ErrReplayed
+		if !errors.As(err, &invalid) && !errors.As(err, &replayed) {
+			// The nonce store failed; refuse without blaming the caller.
+			logger.Error("failed to verify signed request", "user", req.User, "path", req.URI, "error", err)
+			return err
+		}
+		entry.Outcome, entry.Severity, entry.Reason = OutcomeRejected, SeverityAlert, err.Error()
+		logger.Warn("signed request rejected", "event", "security", "severity", SeverityAlert, "user", req.User, "method", req.Method, "path", req.URI, "reason", err)
+	} else {
+		logger.Warn("signed request accepted", "event", "security", "severity", SeverityCritical, "user", req.User, "method", req.Method, "path", req.URI)
+	}
+	if auditErr := v.repo.AppendAudit(ctx, entry); auditErr != nil {
+		logger.Error("failed to record signed request", "user", req.User, "path", req.URI, "error", auditErr)
+	} else if v.ledger != nil {
+		if auditErr := v.ledger.Append(ctx, audit.SourceSignedRequests, entry); auditErr != nil {
+			logger.Error("failed to chain signed request audit entry", "user", req.User, "path", req.URI, "error", auditErr)
+		}
+	}
+	return err
+}
+
+func (v *Verifier) verify(ctx context.Context, req Request) error {
+	if req.Timestamp == "" || req.Nonce == "" || req.Signature == "" {
+		return ErrInvalidSignature{Reason: "the " + HeaderTimestamp + ", " + HeaderNonce + " and " + HeaderSignature + " headers are required"}
+	}
+	if len(req.Nonce) < minNonceLength {
+		return ErrInvalidSignature{Reason: "nonce must be at least " + strconv.Itoa(minNonceLength) + " characters"}
+	}
+	seconds, err := strconv.ParseInt(req.Timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature{Reason: "timestamp must be Unix seconds"}
+	}
+	signedAt := time.Unix(seconds, 0)
+	now := v.now()
+	if signedAt.Before(now.Add(-MaxSkew)) || signedAt.After(now.Add(MaxSkew)) {
+		return ErrInvalidSignature{Reason: "timestamp is outside the allowed clock skew"}
+	}
+	got, err := hex.DecodeString(req.Signature)
+	if err != nil {
+		return ErrInvalidSignature{Reason: "signature must be hex encoded"}
+	}
+	want, _ := hex.DecodeString(Sign(v.key, req))
+	if !hmac.Equal(got, want) {
+		return ErrInvalidSignature{Reason: "signature does not match the request"}
+	}
+	// The nonce is claimed only for authentic requests so forged ones cannot burn it.
+	return v.repo.ClaimNonce(ctx, req.Nonce, signedAt.Add(MaxSkew))
+}
+
+// Audit lists recorded signed-request attempts, newest first.
+func (v *Verifier) Audit(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	return v.repo.ListAudit(ctx, filter)
+}
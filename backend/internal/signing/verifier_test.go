@@ -0,0 +1,86 @@
+package signing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pramodksahoo/kubechat/backend/internal/logging"
+)
+
+func TestVerifierRejectsTamperedStaleAndReplayedRequests(t *testing.T) {
+	ctx := context.Background()
+	key := []byte("0123456789abcdef0123456789abcdef")
+	now := time.Now().UTC().Truncate(time.Second)
+	verifier := NewVerifier(key, NewMemoryRepository(), log.NewWithOptions(io.Discard, log.Options{}))
+	verifier.now = func() time.Time { return now }
+
+	signed := func(req Request) Request {
+		req.Signature = Sign(key, req)
+		return req
+	}
+	req := signed(Request{
+		Method:    "DELETE",
+		URI:       "/api/v1/admin/retention/holds/h1",
+		User:      "alice",
+		Timestamp: strconv.FormatInt(now.Unix(), 10),
+		Nonce:     "4f1c2a9e7b3d5f60",
+	})
+	if err := verifier.Verify(ctx, req); err != nil {
+		t.Fatalf("expected a valid signature to be accepted: %v", err)
+	}
+	if err := verifier.Verify(ctx, req); !errors.As(err, &ErrReplayed{}) {
+		t.Fatalf("expected a reused nonce to be refused, got %v", err)
+	}
+
+	var invalid ErrInvalidSignature
+	forged := signed(Request{Method: "DELETE", URI: req.URI, User: "alice", Timestamp: req.Timestamp, Nonce: "8d2e6b1f0c4a9375"})
+	forged.User = "mallory"
+	if err := verifier.Verify(ctx, forged); !errors.As(err, &invalid) {
+		t.Fatalf("expected a request relayed for another user to be refused, got %v", err)
+	}
+	// The forged attempt must not burn the nonce for the genuine request.
+	forged.User = "alice"
+	if err := verifier.Verify(ctx, forged); err != nil {
+		t.Fatalf("expected the genuine request to be accepted: %v", err)
+	}
+	stale := signed(Request{Method: "DELETE", URI: req.URI, User: "alice", Timestamp: strconv.FormatInt(now.Add(-MaxSkew-time.Second).Unix(), 10), Nonce: "1a2b3c4d5e6f7081"})
+	if err := verifier.Verify(ctx, stale); !errors.As(err, &invalid) {
+		t.Fatalf("expected a stale timestamp to be refused, got %v", err)
+	}
+	if err := verifier.Verify(ctx, Request{Method: "DELETE", URI: req.URI, User: "alice"}); !errors.As(err, &invalid) {
+		t.Fatalf("expected an unsigned request to be refused, got %v", err)
+	}
+
+	alerts, err := verifier.Audit(ctx, AuditFilter{Severity: SeverityAlert})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(alerts) != 4 || alerts[0].Outcome != OutcomeRejected || alerts[0].Reason == "" {
+		t.Fatalf("expected every refusal audited as an alert, got %+v", alerts)
+	}
+	accepted, _ := verifier.Audit(ctx, AuditFilter{Severity: SeverityCritical})
+	if len(accepted) != 2 || accepted[0].User != "alice" {
+		t.Fatalf("expected accepted requests audited as critical, got %+v", accepted)
+	}
+}
+
+func TestVerifierAuditsTheRequestID(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	verifier := NewVerifier(key, NewMemoryRepository(), log.NewWithOptions(io.Discard, log.Options{}))
+	ctx := logging.NewContext(context.Background(), logging.RequestIDKey, "req-42")
+
+	req := Request{Method: "DELETE", URI: "/api/v1/admin/retention/holds/h1", User: "alice", Timestamp: strconv.FormatInt(time.Now().Unix(), 10), Nonce: "4f1c2a9e7b3d5f60"}
+	req.Signature = Sign(key, req)
+	if err := verifier.Verify(ctx, req); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	entries, err := verifier.Audit(ctx, AuditFilter{User: "alice"})
+	if err != nil || len(entries) != 1 || entries[0].RequestID != "req-42" {
+		t.Fatalf("expected the attempt audited under its request ID, got %+v (%v)", entries, err)
+	}
+}
@@ -0,0 +1,196 @@
+package telemetry
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+var (
+	httpDurationBuckets     = prometheus.DefBuckets
+	providerDurationBuckets = []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 20, 30, 60}
+	commandDurationBuckets  = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+)
+
+// register registers collector with registerer, or returns the collector already registered
+// under the same description, so routes can be configured more than once per process.
+func register[T prometheus.Collector](registerer prometheus.Registerer, collector T) T {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+	if err := registerer.Register(collector); err != nil {
+		var already prometheus.AlreadyRegisteredError
+		if !errors.As(err, &already) {
+			panic(err)
+		}
+		if existing, ok := already.ExistingCollector.(T); ok {
+			return existing
+		}
+		panic(err)
+	}
+	return collector
+}
+
+// HTTPMetrics records the latency of API requests by route template, not by path, so object
+// names never become label values.
+type HTTPMetrics struct {
+	duration *prometheus.HistogramVec
+}
+
+func NewHTTPMetrics(registerer prometheus.Registerer) *HTTPMetrics {
+	return &HTTPMetrics{duration: register(registerer, prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Latency of HTTP requests served by the gateway",
+			Buckets: httpDurationBuckets,
+		},
+		[]string{"method", "route", "status"},
+	))}
+}
+
+func (m *HTTPMetrics) ObserveRequest(method, route string, status int, d time.Duration) {
+	if m == nil {
+		return
+	}
+	if route == "" {
+		route = "unmatched"
+	}
+	m.duration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(d.Seconds())
+}
+
+// ModelMetrics records model provider calls: their latency and outcome, the tokens they used and
+// how often the fallback chain moved on to the next provider.
+type ModelMetrics struct {
+	duration  *prometheus.HistogramVec
+	tokens    *prometheus.CounterVec
+	fallbacks *prometheus.CounterVec
+}
+
+func NewModelMetrics(registerer prometheus.Registerer) *ModelMetrics {
+	return &ModelMetrics{
+		duration: register(registerer, prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "nlp_provider_request_duration_seconds",
+				Help:    "Latency of model provider calls",
+				Buckets: providerDurationBuckets,
+			},
+			[]string{"provider", "model", "outcome"},
+		)),
+		tokens: register(registerer, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "nlp_tokens_total",
+				Help: "Tokens consumed by model provider calls",
+			},
+			[]string{"provider", "model", "type"},
+		)),
+		fallbacks: register(registerer, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "nlp_provider_fallbacks_total",
+				Help: "Requests passed to the next provider after a provider failed",
+			},
+			[]string{"from", "to"},
+		)),
+	}
+}
+
+func (m *ModelMetrics) ObserveProviderCall(provider, model string, promptTokens, completionTokens int, d time.Duration, failed bool) {
+	if m == nil {
+		return
+	}
+	if model == "" {
+		model = "default"
+	}
+	outcome := "success"
+	if failed {
+		outcome = "failure"
+	}
+	m.duration.WithLabelValues(provider, model, outcome).Observe(d.Seconds())
+	m.tokens.WithLabelValues(provider, model, "prompt").Add(float64(promptTokens))
+	m.tokens.WithLabelValues(provider, model, "completion").Add(float64(completionTokens))
+}
+
+func (m *ModelMetrics) ObserveFallback(from, to string) {
+	if m == nil {
+		return
+	}
+	m.fallbacks.WithLabelValues(from, to).Inc()
+}
+
+// CommandMetrics records how long command executions take and how they end.
+type CommandMetrics struct {
+	duration *prometheus.HistogramVec
+}
+
+func NewCommandMetrics(registerer prometheus.Registerer) *CommandMetrics {
+	return &CommandMetrics{duration: register(registerer, prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "command_execution_duration_seconds",
+			Help:    "Duration of command executions by operation and final status",
+			Buckets: commandDurationBuckets,
+		},
+		[]string{"operation", "status"},
+	))}
+}
+
+func (m *CommandMetrics) ObserveExecution(operation, status string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.duration.WithLabelValues(operation, status).Observe(d.Seconds())
+}
+
+// CacheMetrics counts response cache lookups by route template and outcome.
+type CacheMetrics struct {
+	lookups *prometheus.CounterVec
+}
+
+func NewCacheMetrics(registerer prometheus.Registerer) *CacheMetrics {
+	return &CacheMetrics{lookups: register(registerer, prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_response_cache_lookups_total",
+			Help: "Response cache lookups by route and outcome: hit, miss or bypass",
+		},
+		[]string{"route", "outcome"},
+	))}
+}
+
+func (m *CacheMetrics) ObserveCacheLookup(route, outcome string) {
+	if m == nil {
+		return
+	}
+	m.lookups.WithLabelValues(route, outcome).Inc()
+}
+
+// NewWebSocketConnections returns the gauge of open WebSocket connections, labelled by the
+// endpoint serving them.
+func NewWebSocketConnections(registerer prometheus.Registerer) *prometheus.GaugeVec {
+	return register(registerer, prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "websocket_connections",
+			Help: "Open WebSocket connections",
+		},
+		[]string{"endpoint"},
+	))
+}
+
+// RegisterQueueDepth exposes the number of items waiting in the named queue, read from depth at
+// scrape time.
+func RegisterQueueDepth(registerer prometheus.Registerer, queue string, depth func() int) {
+	register(registerer, prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name:        "queue_depth",
+			Help:        "Items waiting in a background queue",
+			ConstLabels: prometheus.Labels{"queue": queue},
+		},
+		func() float64 { return float64(depth()) },
+	))
+}
+
+// RegisterDBStats exposes the connection pool statistics of db as the go_sql_* metrics.
+func RegisterDBStats(registerer prometheus.Registerer, db *sql.DB, name string) {
+	register(registerer, collectors.NewDBStatsCollector(db, name))
+}
@@ -0,0 +1,270 @@
+package upgrades
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// listPageSize bounds each list request made while scanning a cluster.
+const listPageSize = 500
+
+// ClientFunc returns the clients of a config/context pair.
+type ClientFunc func(config, cluster string) (kubernetes.Interface, dynamic.Interface, error)
+
+type ErrInvalidTarget struct {
+	Target string
+	Reason string
+}
+
+func (e ErrInvalidTarget) Error() string {
+	return fmt.Sprintf("invalid target version %q: %s", e.Target, e.Reason)
+}
+
+// Cluster is a config/context pair to advise on; Name is what users call it, such as "prod".
+type Cluster struct {
+	Name    string
+	Config  string
+	Context string
+}
+
+// AffectedObject is an object whose writers still use a deprecated API.
+type AffectedObject struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Managers are the field managers, such as helm or kubectl, that last wrote the object
+	// through the deprecated API.
+	Managers []string `json:"managers,omitempty"`
+	// LastApplied reports that the object's kubectl last-applied manifest uses the deprecated
+	// API, so applying that manifest again fails once the API is removed.
+	LastApplied bool `json:"lastApplied,omitempty"`
+}
+
+// Finding is a deprecated API the cluster serves or its objects were written through.
+type Finding struct {
+	Deprecation
+	// Removed reports that the target version no longer serves the API; otherwise it is only
+	// deprecated there.
+	Removed bool             `json:"removed"`
+	Served  bool             `json:"served"`
+	Objects []AffectedObject `json:"objects,omitempty"`
+}
+
+// Report answers what breaks when a cluster is upgraded to Target.
+type Report struct {
+	Cluster   string    `json:"cluster"`
+	Config    string    `json:"config"`
+	Context   string    `json:"context"`
+	Version   string    `json:"version,omitempty"`
+	Target    string    `json:"target,omitempty"`
+	Findings  []Finding `json:"findings"`
+	Summary   string    `json:"summary,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+type Advisor struct {
+	clients ClientFunc
+	clock   func() time.Time
+}
+
+func NewAdvisor(clients ClientFunc) *Advisor {
+	return &Advisor{clients: clients, clock: func() time.Time { return time.Now().UTC() }}
+}
+
+// Check reads the cluster's version and scans it for APIs removed or deprecated by target. An
+// empty target means the next minor version.
+func (a *Advisor) Check(ctx context.Context, cluster Cluster, target string) (Report, error) {
+	report := Report{Cluster: cluster.Name, Config: cluster.Config, Context: cluster.Context, Findings: []Finding{}, CheckedAt: a.clock()}
+	client, dynamicClient, err := a.clients(cluster.Config, cluster.Context)
+	if err != nil {
+		return Report{}, err
+	}
+	info, err := client.Discovery().ServerVersion()
+	if err != nil {
+		return Report{}, fmt.Errorf("read the version of %s: %w", cluster.Name, err)
+	}
+	current, err := version.ParseGeneric(info.GitVersion)
+	if err != nil {
+		return Report{}, fmt.Errorf("parse the version of %s: %w", cluster.Name, err)
+	}
+	current = version.MajorMinor(current.Major(), current.Minor())
+	to, err := parseTarget(target, current)
+	if err != nil {
+		return Report{}, err
+	}
+	report.Version = info.GitVersion
+	report.Target = to.String()
+
+	served := servedResources(client)
+	for _, deprecation := range Deprecations {
+		// The cluster already runs without APIs removed at or before its version.
+		if !deprecation.removedIn().GreaterThan(current) || deprecation.deprecatedIn().GreaterThan(to) {
+			continue
+		}
+		finding := Finding{Deprecation: deprecation, Removed: to.AtLeast(deprecation.removedIn()), Served: served(deprecation.resource())}
+		finding.Objects, err = affectedObjects(ctx, dynamicClient, served, deprecation)
+		if err != nil {
+			return Report{}, fmt.Errorf("scan %s %s: %w", deprecation.APIVersion, deprecation.Kind, err)
+		}
+		if finding.Served || len(finding.Objects) > 0 {
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+	report.Summary = summarize(report)
+	return report, nil
+}
+
+// CheckAll checks every cluster concurrently. A cluster that cannot be checked gets a report
+// carrying the error instead of failing the others.
+func (a *Advisor) CheckAll(ctx context.Context, clusters []Cluster, target string) []Report {
+	reports := make([]Report, len(clusters))
+	var wg sync.WaitGroup
+	for i, cluster := range clusters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report, err := a.Check(ctx, cluster, target)
+			if err != nil {
+				report = Report{Cluster: cluster.Name, Config: cluster.Config, Context: cluster.Context, Findings: []Finding{}, Error: err.Error(), CheckedAt: a.clock()}
+			}
+			reports[i] = report
+		}()
+	}
+	wg.Wait()
+	return reports
+}
+
+func parseTarget(target string, current *version.Version) (*version.Version, error) {
+	if strings.TrimSpace(target) == "" {
+		return current.AddMinor(1), nil
+	}
+	to, err := version.ParseGeneric(strings.TrimSpace(target))
+	if err != nil {
+		return nil, ErrInvalidTarget{Target: target, Reason: "expected a version such as 1.31"}
+	}
+	to = version.MajorMinor(to.Major(), to.Minor())
+	if to.LessThan(current) {
+		return nil, ErrInvalidTarget{Target: target, Reason: fmt.Sprintf("the cluster already runs %s", current)}
+	}
+	return to, nil
+}
+
+// servedResources returns a lookup of the resources the cluster serves, caching discovery per
+// group version.
+func servedResources(client kubernetes.Interface) func(schema.GroupVersionResource) bool {
+	cache := map[schema.GroupVersion][]string{}
+	return func(gvr schema.GroupVersionResource) bool {
+		gv := gvr.GroupVersion()
+		resources, ok := cache[gv]
+		if !ok {
+			list, err := client.Discovery().ServerResourcesForGroupVersion(gv.String())
+			if err == nil {
+				for _, resource := range list.APIResources {
+					resources = append(resources, resource.Name)
+				}
+			}
+			cache[gv] = resources
+		}
+		return slices.Contains(resources, gvr.Resource)
+	}
+}
+
+// affectedObjects lists the objects of a deprecated API's kind whose managed fields or
+// last-applied manifest use the deprecated version. Objects are read through the replacement
+// when the cluster serves it, since a removed version can no longer be listed after upgrading.
+func affectedObjects(ctx context.Context, client dynamic.Interface, served func(schema.GroupVersionResource) bool, deprecation Deprecation) ([]AffectedObject, error) {
+	gvr := deprecation.resource()
+	if replacement, ok := deprecation.replacement(); ok && served(replacement) {
+		gvr = replacement
+	} else if !served(gvr) {
+		return nil, nil
+	}
+
+	var affected []AffectedObject
+	opts := metav1.ListOptions{Limit: listPageSize}
+	for {
+		list, err := client.Resource(gvr).List(ctx, opts)
+		if apierrors.IsNotFound(err) || apierrors.IsForbidden(err) {
+			return affected, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range list.Items {
+			object := AffectedObject{Namespace: obj.GetNamespace(), Name: obj.GetName()}
+			for _, field := range obj.GetManagedFields() {
+				if field.APIVersion == deprecation.APIVersion && !slices.Contains(object.Managers, field.Manager) {
+					object.Managers = append(object.Managers, field.Manager)
+				}
+			}
+			object.LastApplied = lastAppliedVersion(obj.GetAnnotations()) == deprecation.APIVersion
+			if len(object.Managers) > 0 || object.LastApplied {
+				affected = append(affected, object)
+			}
+		}
+		if opts.Continue = list.GetContinue(); opts.Continue == "" {
+			return affected, nil
+		}
+	}
+}
+
+func lastAppliedVersion(annotations map[string]string) string {
+	raw := annotations[corev1.LastAppliedConfigAnnotation]
+	if raw == "" {
+		return ""
+	}
+	var manifest struct {
+		APIVersion string `json:"apiVersion"`
+	}
+	if err := json.Unmarshal([]byte(raw), &manifest); err != nil {
+		return ""
+	}
+	return manifest.APIVersion
+}
+
+// summarize answers the upgrade question in one sentence, such as "Upgrading prod from 1.30 to
+// 1.32 removes 2 APIs still used by 5 objects."
+func summarize(report Report) string {
+	var removed, deprecated, objects int
+	for _, finding := range report.Findings {
+		if !finding.Removed {
+			deprecated++
+			continue
+		}
+		if len(finding.Objects) > 0 {
+			removed++
+			objects += len(finding.Objects)
+		}
+	}
+	current := version.MustParseGeneric(report.Version)
+	summary := fmt.Sprintf("Upgrading %s from %d.%d to %s", report.Cluster, current.Major(), current.Minor(), report.Target)
+	if removed == 0 {
+		summary += " removes no API in use."
+	} else {
+		summary += fmt.Sprintf(" removes %s still used by %s.", plural(removed, "API"), plural(objects, "object"))
+	}
+	if deprecated > 0 {
+		summary += fmt.Sprintf(" It also deprecates %s the cluster still serves or uses.", plural(deprecated, "API"))
+	}
+	return summary
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
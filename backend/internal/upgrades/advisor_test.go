@@ -0,0 +1,90 @@
+package upgrades
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apimachineryversion "k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func ingress(name, lastApplied string, managers ...metav1.ManagedFieldsEntry) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("networking.k8s.io/v1")
+	obj.SetKind("Ingress")
+	obj.SetNamespace("shop")
+	obj.SetName(name)
+	obj.SetManagedFields(managers)
+	if lastApplied != "" {
+		obj.SetAnnotations(map[string]string{"kubectl.kubernetes.io/last-applied-configuration": `{"apiVersion":"` + lastApplied + `","kind":"Ingress"}`})
+	}
+	return obj
+}
+
+func newTestAdvisor() *Advisor {
+	client := fake.NewSimpleClientset()
+	discovery := client.Discovery().(*fakediscovery.FakeDiscovery)
+	discovery.FakedServerVersion = &apimachineryversion.Info{GitVersion: "v1.21.14-eks-18ef993"}
+	discovery.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "extensions/v1beta1", APIResources: []metav1.APIResource{{Name: "ingresses", Kind: "Ingress", Namespaced: true}}},
+		{GroupVersion: "networking.k8s.io/v1beta1", APIResources: []metav1.APIResource{{Name: "ingresses", Kind: "Ingress", Namespaced: true}}},
+		{GroupVersion: "networking.k8s.io/v1", APIResources: []metav1.APIResource{{Name: "ingresses", Kind: "Ingress", Namespaced: true}}},
+		{GroupVersion: "batch/v1beta1", APIResources: []metav1.APIResource{{Name: "cronjobs", Kind: "CronJob", Namespaced: true}}},
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		{Group: "networking.k8s.io", Version: "v1", Resource: "ingresses"}: "IngressList",
+		{Group: "batch", Version: "v1beta1", Resource: "cronjobs"}:         "CronJobList",
+	},
+		ingress("legacy", "", metav1.ManagedFieldsEntry{Manager: "helm", APIVersion: "extensions/v1beta1"}),
+		ingress("chart", "networking.k8s.io/v1beta1"),
+		ingress("current", "networking.k8s.io/v1", metav1.ManagedFieldsEntry{Manager: "kubectl", APIVersion: "networking.k8s.io/v1"}),
+	)
+	return NewAdvisor(func(config, cluster string) (kubernetes.Interface, dynamic.Interface, error) {
+		if cluster != "prod" {
+			return nil, nil, errors.New("cluster is not loaded")
+		}
+		return client, dynamicClient, nil
+	})
+}
+
+func TestAdvisorFindsObjectsUsingRemovedAPIs(t *testing.T) {
+	advisor := newTestAdvisor()
+	report, err := advisor.Check(context.Background(), Cluster{Name: "prod", Config: "c", Context: "prod"}, "1.22")
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	affected := map[string][]AffectedObject{}
+	for _, finding := range report.Findings {
+		if finding.Removed {
+			affected[finding.APIVersion] = finding.Objects
+		}
+	}
+	if objects := affected["extensions/v1beta1"]; len(objects) != 1 || objects[0].Name != "legacy" || !slices.Equal(objects[0].Managers, []string{"helm"}) {
+		t.Fatalf("expected the helm-managed ingress to use extensions/v1beta1, got %+v", objects)
+	}
+	if objects := affected["networking.k8s.io/v1beta1"]; len(objects) != 1 || objects[0].Name != "chart" || !objects[0].LastApplied {
+		t.Fatalf("expected the last-applied manifest to use networking.k8s.io/v1beta1, got %+v", objects)
+	}
+	want := "Upgrading prod from 1.21 to 1.22 removes 2 APIs still used by 2 objects. It also deprecates 1 API the cluster still serves or uses."
+	if report.Summary != want {
+		t.Fatalf("unexpected summary %q", report.Summary)
+	}
+
+	if _, err := advisor.Check(context.Background(), Cluster{Name: "prod", Context: "prod"}, "1.20"); !errors.As(err, &ErrInvalidTarget{}) {
+		t.Fatalf("expected a downgrade target to be rejected, got %v", err)
+	}
+	reports := advisor.CheckAll(context.Background(), []Cluster{{Name: "prod", Context: "prod"}, {Name: "staging", Context: "staging"}}, "")
+	if reports[0].Target != "1.22" || reports[1].Error == "" {
+		t.Fatalf("expected the next minor by default and a per-cluster error, got %+v", reports)
+	}
+}
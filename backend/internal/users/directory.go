@@ -0,0 +1,364 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/pramodksahoo/kubechat/backend/internal/audit"
+	"github.com/pramodksahoo/kubechat/backend/internal/mfa"
+	"github.com/pramodksahoo/kubechat/backend/internal/roles"
+)
+
+const (
+	// touchInterval bounds how often a user's activity is written to the store.
+	touchInterval = time.Minute
+	// stateTTL bounds how long a lock made on another replica takes to apply here.
+	stateTTL = 30 * time.Second
+)
+
+// Roles reads and changes the role bindings of users.
+type Roles interface {
+	Access(ctx context.Context, user string, groups []string) (roles.Access, error)
+	Bindings(ctx context.Context) ([]roles.Binding, error)
+	Assign(ctx context.Context, actor string, req roles.BindingRequest) (roles.Binding, error)
+	Revoke(ctx context.Context, actor, id string) error
+}
+
+// Credentials removes the second factor a user enrolled.
+type Credentials interface {
+	Remove(ctx context.Context, actor, user string) error
+}
+
+// Ledger records admin changes to users in the tamper-evident audit ledger.
+type Ledger interface {
+	Append(ctx context.Context, source audit.Source, entry any) error
+}
+
+// Directory records who uses KubeChat and lets admins lock users out, reset their credentials
+// and manage their roles.
+type Directory struct {
+	repo        Repository
+	admins      []string
+	roles       Roles
+	credentials Credentials
+	ledger      Ledger
+	logger      *log.Logger
+	now         func() time.Time
+
+	mu     sync.Mutex
+	active map[string]*activity
+}
+
+// activity is what the directory remembers of a user between requests.
+type activity struct {
+	user      User
+	loadedAt  time.Time
+	writtenAt time.Time
+	// sessions are keyed by client and user agent.
+	sessions map[string]*Session
+}
+
+// NewDirectory returns a directory in which admins, the configured KubeChat admins, can never be
+// locked out.
+func NewDirectory(repo Repository, admins []string, logger *log.Logger) *Directory {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Directory{
+		repo:   repo,
+		admins: admins,
+		logger: logger,
+		now:    func() time.Time { return time.Now().UTC() },
+		active: make(map[string]*activity),
+	}
+}
+
+func (d *Directory) SetRoles(roles Roles) {
+	d.roles = roles
+}
+
+func (d *Directory) SetCredentials(credentials Credentials) {
+	d.credentials = credentials
+}
+
+func (d *Directory) SetLedger(ledger Ledger) {
+	d.ledger = ledger
+}
+
+// Seen records a request from user and refuses it with ErrLocked when the user is locked. The
+// user's record and session are written at most once a minute, and whenever a session starts.
+func (d *Directory) Seen(ctx context.Context, name string, groups []string, client, userAgent string) error {
+	now := d.now()
+	d.mu.Lock()
+	a := d.active[name]
+	stale := a == nil || now.Sub(a.loadedAt) > stateTTL
+	d.mu.Unlock()
+	if stale {
+		user, err := d.repo.GetUser(ctx, name)
+		if errors.As(err, &ErrUserNotFound{}) {
+			user, err = User{Name: name, FirstSeen: now}, nil
+		}
+		if err != nil {
+			return err
+		}
+		d.mu.Lock()
+		if a = d.active[name]; a == nil {
+			a = &activity{sessions: make(map[string]*Session)}
+			d.active[name] = a
+		}
+		// Keep the activity recorded here since the last write; the rest comes from the store.
+		user.LastSeen, user.LastLogin = later(user.LastSeen, a.user.LastSeen), later(user.LastLogin, a.user.LastLogin)
+		a.user, a.loadedAt = user, now
+		d.mu.Unlock()
+	}
+
+	d.mu.Lock()
+	if a.user.Locked {
+		d.mu.Unlock()
+		return ErrLocked{Name: name}
+	}
+	d.prune(a, now)
+	key := client + "\x00" + userAgent
+	session := a.sessions[key]
+	write := now.Sub(a.writtenAt) >= touchInterval
+	if session == nil {
+		session = &Session{ID: uuid.NewString(), User: name, Client: client, UserAgent: userAgent, StartedAt: now}
+		a.sessions[key] = session
+		a.user.LastLogin = now
+		write = true
+	}
+	session.LastSeen = now
+	a.user.LastSeen = now
+	if len(groups) > 0 {
+		a.user.Groups = groups
+	}
+	if !write {
+		d.mu.Unlock()
+		return nil
+	}
+	a.writtenAt = now
+	user, current := a.user, *session
+	d.mu.Unlock()
+
+	if err := d.repo.SaveActivity(ctx, user); err != nil {
+		d.logger.Warn("failed to record user activity", "user", name, "error", err)
+		return nil
+	}
+	if err := d.repo.SaveSession(ctx, current); err != nil {
+		d.logger.Warn("failed to record user session", "user", name, "error", err)
+	}
+	return nil
+}
+
+// prune forgets the user's idle sessions.
+func (d *Directory) prune(a *activity, now time.Time) {
+	for key, session := range a.sessions {
+		if now.Sub(session.LastSeen) > SessionIdle {
+			delete(a.sessions, key)
+		}
+	}
+}
+
+func later(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+func (d *Directory) List(ctx context.Context, filter Filter) ([]User, error) {
+	filter.Query = strings.TrimSpace(filter.Query)
+	return d.repo.ListUsers(ctx, filter)
+}
+
+// Get returns the user with their roles and active sessions.
+func (d *Directory) Get(ctx context.Context, name string) (Details, error) {
+	user, err := d.repo.GetUser(ctx, name)
+	if err != nil {
+		return Details{}, err
+	}
+	details := Details{User: user, Admin: slices.Contains(d.admins, name), Bindings: []roles.Binding{}}
+	if d.roles != nil {
+		access, err := d.roles.Access(ctx, name, user.Groups)
+		if err != nil {
+			return Details{}, err
+		}
+		details.Admin, details.Bindings, details.Permissions = access.Admin, access.Bindings, access.Permissions
+	}
+	if details.Sessions, err = d.repo.ListSessions(ctx, name, d.now().Add(-SessionIdle)); err != nil {
+		return Details{}, err
+	}
+	return details, nil
+}
+
+// Lock refuses every further request from user until an admin unlocks them. Users who have not
+// used KubeChat yet can be locked ahead of their first request; configured admins cannot be.
+func (d *Directory) Lock(ctx context.Context, actor, name, reason string) (User, error) {
+	if slices.Contains(d.admins, name) {
+		return User{}, ErrInvalidRequest{Reason: "configured admins cannot be locked"}
+	}
+	if name == actor {
+		return User{}, ErrInvalidRequest{Reason: "admins cannot lock themselves"}
+	}
+	now := d.now()
+	return d.update(ctx, actor, name, true, ActionLocked, reason, func(user *User) {
+		user.Locked, user.LockedBy, user.LockedAt, user.LockReason = true, actor, &now, strings.TrimSpace(reason)
+	})
+}
+
+func (d *Directory) Unlock(ctx context.Context, actor, name string) (User, error) {
+	return d.update(ctx, actor, name, false, ActionUnlocked, "", func(user *User) {
+		user.Locked, user.LockedBy, user.LockedAt, user.LockReason = false, "", nil, ""
+	})
+}
+
+// ResetCredentials resets the credentials KubeChat holds for user: it removes their second factor,
+// so they have to enroll again, and ends their sessions, so their next request counts as a new
+// login. Passwords belong to the identity provider in front of KubeChat and are reset there.
+func (d *Directory) ResetCredentials(ctx context.Context, actor, name string) (User, error) {
+	if _, err := d.repo.GetUser(ctx, name); err != nil {
+		return User{}, err
+	}
+	if d.credentials != nil {
+		if err := d.credentials.Remove(ctx, actor, name); err != nil && !errors.As(err, &mfa.ErrNotEnrolled{}) {
+			return User{}, err
+		}
+	}
+	if err := d.repo.DeleteSessions(ctx, name); err != nil {
+		return User{}, err
+	}
+	now := d.now()
+	return d.update(ctx, actor, name, false, ActionReset, "", func(user *User) {
+		user.ResetBy, user.ResetAt = actor, &now
+	})
+}
+
+// update applies change to the stored user and audits it. Only locks may create a user.
+func (d *Directory) update(ctx context.Context, actor, name string, create bool, action Action, detail string, change func(*User)) (User, error) {
+	if strings.TrimSpace(name) == "" {
+		return User{}, ErrInvalidRequest{Reason: "user is required"}
+	}
+	user, err := d.repo.GetUser(ctx, name)
+	if create && errors.As(err, &ErrUserNotFound{}) {
+		user, err = User{Name: name}, nil
+	}
+	if err != nil {
+		return User{}, err
+	}
+	change(&user)
+	if err := d.repo.SaveUser(ctx, user); err != nil {
+		return User{}, err
+	}
+	d.mu.Lock()
+	if a := d.active[name]; a != nil {
+		a.user.Locked, a.user.LockedBy, a.user.LockedAt, a.user.LockReason = user.Locked, user.LockedBy, user.LockedAt, user.LockReason
+		a.user.ResetBy, a.user.ResetAt = user.ResetBy, user.ResetAt
+		if action == ActionReset {
+			a.sessions = make(map[string]*Session)
+		}
+	}
+	d.mu.Unlock()
+	d.record(ctx, actor, name, action, detail)
+	return user, nil
+}
+
+// AssignRole binds role to user, in namespace or in every namespace when it is empty.
+func (d *Directory) AssignRole(ctx context.Context, actor, name string, role roles.Role, namespace string) (roles.Binding, error) {
+	if d.roles == nil {
+		return roles.Binding{}, ErrInvalidRequest{Reason: "roles are not available"}
+	}
+	binding, err := d.roles.Assign(ctx, actor, roles.BindingRequest{Role: role, Subject: name, SubjectKind: roles.SubjectUser, Namespace: namespace})
+	if err != nil {
+		return roles.Binding{}, err
+	}
+	d.record(ctx, actor, name, ActionRoleAssigned, describe(binding))
+	return binding, nil
+}
+
+// RevokeRole removes one of user's own role bindings; bindings the user holds through a group
+// are managed on the group.
+func (d *Directory) RevokeRole(ctx context.Context, actor, name, id string) error {
+	if d.roles == nil {
+		return ErrInvalidRequest{Reason: "roles are not available"}
+	}
+	bindings, err := d.roles.Bindings(ctx)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(bindings, func(b roles.Binding) bool {
+		return b.ID == id && b.SubjectKind == roles.SubjectUser && b.Subject == name
+	})
+	if i < 0 {
+		return roles.ErrBindingNotFound{ID: id}
+	}
+	if err := d.roles.Revoke(ctx, actor, id); err != nil {
+		return err
+	}
+	d.record(ctx, actor, name, ActionRoleRevoked, describe(bindings[i]))
+	return nil
+}
+
+func describe(binding roles.Binding) string {
+	if binding.Namespace == "" {
+		return string(binding.Role)
+	}
+	return string(binding.Role) + " in " + binding.Namespace
+}
+
+func (d *Directory) record(ctx context.Context, actor, name string, action Action, detail string) {
+	d.logger.Info("user changed", "actor", actor, "user", name, "action", action, "detail", detail)
+	if d.ledger == nil {
+		return
+	}
+	change := Change{Actor: actor, User: name, Action: action, Detail: detail, At: d.now()}
+	if err := d.ledger.Append(ctx, audit.SourceUsers, change); err != nil {
+		d.logger.Error("failed to audit user change", "user", name, "action", action, "error", err)
+	}
+}
+
+// Export returns what the directory keeps about user.
+func (d *Directory) Export(ctx context.Context, name string) (User, []Session, error) {
+	user, err := d.repo.GetUser(ctx, name)
+	if err != nil {
+		return User{}, nil, err
+	}
+	sessions, err := d.repo.ListSessions(ctx, name, time.Time{})
+	return user, sessions, err
+}
+
+// Forget removes user and their sessions. A locked user stays locked and keeps only their name
+// and the lock, so erasure cannot be used to lift a lock.
+func (d *Directory) Forget(ctx context.Context, name string) (int, error) {
+	user, err := d.repo.GetUser(ctx, name)
+	if errors.As(err, &ErrUserNotFound{}) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	sessions, err := d.repo.ListSessions(ctx, name, time.Time{})
+	if err != nil {
+		return 0, err
+	}
+	if user.Locked {
+		err = d.repo.SaveUser(ctx, User{Name: name, Locked: true, LockedBy: user.LockedBy, LockedAt: user.LockedAt})
+		if err == nil {
+			err = d.repo.DeleteSessions(ctx, name)
+		}
+	} else {
+		err = d.repo.DeleteUser(ctx, name)
+	}
+	if err != nil {
+		return 0, err
+	}
+	d.mu.Lock()
+	delete(d.active, name)
+	d.mu.Unlock()
+	return 1 + len(sessions), nil
+}
@@ -0,0 +1,165 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/audit"
+	"github.com/pramodksahoo/kubechat/backend/internal/mfa"
+	"github.com/pramodksahoo/kubechat/backend/internal/roles"
+)
+
+type recordingLedger struct {
+	changes []Change
+}
+
+func (l *recordingLedger) Append(ctx context.Context, source audit.Source, entry any) error {
+	if source == audit.SourceUsers {
+		l.changes = append(l.changes, entry.(Change))
+	}
+	return nil
+}
+
+type removedFactors []string
+
+func (r *removedFactors) Remove(ctx context.Context, actor, user string) error {
+	if user == "bob" {
+		return mfa.ErrNotEnrolled{User: user}
+	}
+	*r = append(*r, user)
+	return nil
+}
+
+func TestDirectoryLocksResetsAndBindsUsers(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	repo := NewMemoryRepository()
+	directory := NewDirectory(repo, []string{"root"}, nil)
+	directory.now = func() time.Time { return now }
+	ledger := &recordingLedger{}
+	factors := &removedFactors{}
+	bindings := roles.NewService(roles.NewMemoryRepository(), []string{"root"}, roles.RoleViewer, nil)
+	directory.SetLedger(ledger)
+	directory.SetCredentials(factors)
+	directory.SetRoles(bindings)
+
+	if err := directory.Seen(ctx, "alice", []string{"sre"}, "10.0.0.1", "browser"); err != nil {
+		t.Fatalf("seen: %v", err)
+	}
+	now = now.Add(10 * time.Minute)
+	if err := directory.Seen(ctx, "alice", nil, "10.0.0.1", "browser"); err != nil {
+		t.Fatalf("seen: %v", err)
+	}
+	details, err := directory.Get(ctx, "alice")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if len(details.Sessions) != 1 || !details.LastLogin.Equal(now.Add(-10*time.Minute)) || !details.LastSeen.Equal(now) || len(details.Groups) != 1 {
+		t.Fatalf("expected one session started at the first request, got %+v", details)
+	}
+	now = now.Add(SessionIdle + time.Minute)
+	if err := directory.Seen(ctx, "alice", nil, "10.0.0.1", "browser"); err != nil {
+		t.Fatalf("seen: %v", err)
+	}
+	if details, _ := directory.Get(ctx, "alice"); len(details.Sessions) != 1 || !details.LastLogin.Equal(now) {
+		t.Fatalf("expected an idle gap to start a new session, got %+v", details)
+	}
+
+	if _, err := directory.Lock(ctx, "admin", "root", ""); !errors.As(err, &ErrInvalidRequest{}) {
+		t.Fatalf("expected configured admins not to be lockable, got %v", err)
+	}
+	if _, err := directory.Lock(ctx, "admin", "alice", "left the team"); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	if err := directory.Seen(ctx, "alice", nil, "10.0.0.1", "browser"); !errors.As(err, &ErrLocked{}) {
+		t.Fatalf("expected a locked user to be refused, got %v", err)
+	}
+	locked := true
+	if list, err := directory.List(ctx, Filter{Query: "ALI", Locked: &locked}); err != nil || len(list) != 1 || list[0].LockReason != "left the team" {
+		t.Fatalf("unexpected search result %+v (%v)", list, err)
+	}
+	if _, err := directory.Unlock(ctx, "admin", "alice"); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if err := directory.Seen(ctx, "alice", nil, "10.0.0.1", "browser"); err != nil {
+		t.Fatalf("expected an unlocked user to be let in, got %v", err)
+	}
+
+	if _, err := directory.ResetCredentials(ctx, "admin", "alice"); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if _, err := directory.ResetCredentials(ctx, "admin", "bob"); !errors.As(err, &ErrUserNotFound{}) {
+		t.Fatalf("expected an unknown user to be reported, got %v", err)
+	}
+	details, _ = directory.Get(ctx, "alice")
+	if len(*factors) != 1 || len(details.Sessions) != 0 || details.ResetBy != "admin" {
+		t.Fatalf("expected the second factor removed and sessions ended, got %v and %+v", *factors, details)
+	}
+
+	binding, err := directory.AssignRole(ctx, "admin", "alice", roles.RoleOperator, "shop")
+	if err != nil {
+		t.Fatalf("assign: %v", err)
+	}
+	if details, _ := directory.Get(ctx, "alice"); len(details.Bindings) != 1 || details.Bindings[0].Namespace != "shop" {
+		t.Fatalf("expected the binding in the user's details, got %+v", details.Bindings)
+	}
+	if err := directory.RevokeRole(ctx, "admin", "carol", binding.ID); !errors.As(err, &roles.ErrBindingNotFound{}) {
+		t.Fatalf("expected another user's binding to be refused, got %v", err)
+	}
+	if err := directory.RevokeRole(ctx, "admin", "alice", binding.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+
+	var actions []Action
+	for _, change := range ledger.changes {
+		actions = append(actions, change.Action)
+	}
+	want := []Action{ActionLocked, ActionUnlocked, ActionReset, ActionRoleAssigned, ActionRoleRevoked}
+	if len(actions) != len(want) {
+		t.Fatalf("expected changes %v to be audited, got %v", want, actions)
+	}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Fatalf("expected changes %v to be audited, got %v", want, actions)
+		}
+	}
+
+	if _, err := directory.Lock(ctx, "admin", "alice", "again"); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	if n, err := directory.Forget(ctx, "alice"); err != nil || n == 0 {
+		t.Fatalf("forget: %d (%v)", n, err)
+	}
+	user, err := repo.GetUser(ctx, "alice")
+	if err != nil || !user.Locked || len(user.Groups) != 0 || user.LockReason != "" {
+		t.Fatalf("expected erasure to keep only the lock, got %+v (%v)", user, err)
+	}
+}
+
+func TestDirectoryActivityKeepsLocksMadeOnOtherReplicas(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	repo := NewMemoryRepository()
+	replica, other := NewDirectory(repo, nil, nil), NewDirectory(repo, nil, nil)
+	replica.now = func() time.Time { return now }
+	other.now = func() time.Time { return now }
+
+	if err := replica.Seen(ctx, "bob", nil, "10.0.0.1", "browser"); err != nil {
+		t.Fatalf("seen: %v", err)
+	}
+	now = now.Add(5 * time.Second)
+	if _, err := other.Lock(ctx, "admin", "bob", "compromised laptop"); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	// The replica still has bob cached as unlocked, and a new session writes his activity at once.
+	now = now.Add(5 * time.Second)
+	if err := replica.Seen(ctx, "bob", nil, "10.0.0.2", "cli"); err != nil {
+		t.Fatalf("seen: %v", err)
+	}
+	user, err := repo.GetUser(ctx, "bob")
+	if err != nil || !user.Locked || user.LockReason != "compromised laptop" || !user.LastLogin.Equal(now) {
+		t.Fatalf("expected the activity to be recorded without undoing the lock, got %+v: %v", user, err)
+	}
+}
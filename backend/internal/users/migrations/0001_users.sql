@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS users (
+    name TEXT PRIMARY KEY,
+    locked BOOLEAN NOT NULL DEFAULT FALSE,
+    body JSONB NOT NULL,
+    last_seen TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id TEXT PRIMARY KEY,
+    "user" TEXT NOT NULL,
+    client TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMPTZ NOT NULL,
+    last_seen TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS user_sessions_user_idx ON user_sessions ("user", last_seen);
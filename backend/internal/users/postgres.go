@@ -0,0 +1,203 @@
+package users
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pramodksahoo/kubechat/backend/internal/database"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// PostgresRepository stores each user as a JSON document next to the columns used for lookups,
+// and activity sessions as plain rows.
+type PostgresRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostgresRepository applies the user schema migrations and returns a repository backed by db.
+func NewPostgresRepository(ctx context.Context, db *sqlx.DB) (*PostgresRepository, error) {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Migrate(ctx, db, "users", sub); err != nil {
+		return nil, err
+	}
+	return &PostgresRepository{db: db}, nil
+}
+
+type userRow struct {
+	Name     string    `db:"name"`
+	Locked   bool      `db:"locked"`
+	Body     []byte    `db:"body"`
+	LastSeen time.Time `db:"last_seen"`
+}
+
+type sessionRow struct {
+	ID        string    `db:"id"`
+	User      string    `db:"user"`
+	Client    string    `db:"client"`
+	UserAgent string    `db:"user_agent"`
+	StartedAt time.Time `db:"started_at"`
+	LastSeen  time.Time `db:"last_seen"`
+}
+
+func (r *PostgresRepository) SaveUser(ctx context.Context, user User) error {
+	body, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.NamedExecContext(ctx, `INSERT INTO users (name, locked, body, last_seen)
+		VALUES (:name, :locked, :body, :last_seen)
+		ON CONFLICT (name) DO UPDATE SET locked = EXCLUDED.locked, body = EXCLUDED.body, last_seen = EXCLUDED.last_seen`, userRow{
+		Name:     user.Name,
+		Locked:   user.Locked,
+		Body:     body,
+		LastSeen: user.LastSeen,
+	})
+	return err
+}
+
+// SaveActivity merges only the activity fields into the stored body; the locked column and the
+// lock and reset fields are left to SaveUser.
+func (r *PostgresRepository) SaveActivity(ctx context.Context, user User) error {
+	body, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.NamedExecContext(ctx, `INSERT INTO users (name, locked, body, last_seen)
+		VALUES (:name, :locked, :body, :last_seen)
+		ON CONFLICT (name) DO UPDATE SET last_seen = EXCLUDED.last_seen,
+			body = users.body || jsonb_build_object(
+				'lastSeen', EXCLUDED.body->'lastSeen',
+				'lastLogin', EXCLUDED.body->'lastLogin',
+				'groups', COALESCE(EXCLUDED.body->'groups', users.body->'groups'))`, userRow{
+		Name:     user.Name,
+		Locked:   user.Locked,
+		Body:     body,
+		LastSeen: user.LastSeen,
+	})
+	return err
+}
+
+func (r *PostgresRepository) GetUser(ctx context.Context, name string) (User, error) {
+	var body []byte
+	err := r.db.GetContext(ctx, &body, `SELECT body FROM users WHERE name = $1`, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, ErrUserNotFound{Name: name}
+	}
+	if err != nil {
+		return User{}, err
+	}
+	var user User
+	return user, json.Unmarshal(body, &user)
+}
+
+func (r *PostgresRepository) ListUsers(ctx context.Context, filter Filter) ([]User, error) {
+	var (
+		conditions []string
+		args       []any
+	)
+	if filter.Query != "" {
+		args = append(args, "%"+escapeLike(filter.Query)+"%")
+		conditions = append(conditions, `name ILIKE $`+strconv.Itoa(len(args)))
+	}
+	if filter.Locked != nil {
+		args = append(args, *filter.Locked)
+		conditions = append(conditions, `locked = $`+strconv.Itoa(len(args)))
+	}
+	query := `SELECT body FROM users`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, ` AND `)
+	}
+	query += ` ORDER BY name`
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += ` LIMIT $` + strconv.Itoa(len(args))
+	}
+	var bodies [][]byte
+	if err := r.db.SelectContext(ctx, &bodies, query, args...); err != nil {
+		return nil, err
+	}
+	users := make([]User, 0, len(bodies))
+	for _, body := range bodies {
+		var user User
+		if err := json.Unmarshal(body, &user); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func (r *PostgresRepository) DeleteUser(ctx context.Context, name string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	result, err := tx.ExecContext(ctx, `DELETE FROM users WHERE name = $1`, name)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrUserNotFound{Name: name}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_sessions WHERE "user" = $1`, name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PostgresRepository) SaveSession(ctx context.Context, session Session) error {
+	_, err := r.db.NamedExecContext(ctx, `INSERT INTO user_sessions (id, "user", client, user_agent, started_at, last_seen)
+		VALUES (:id, :user, :client, :user_agent, :started_at, :last_seen)
+		ON CONFLICT (id) DO UPDATE SET last_seen = EXCLUDED.last_seen`, sessionRow{
+		ID:        session.ID,
+		User:      session.User,
+		Client:    session.Client,
+		UserAgent: session.UserAgent,
+		StartedAt: session.StartedAt,
+		LastSeen:  session.LastSeen,
+	})
+	return err
+}
+
+func (r *PostgresRepository) ListSessions(ctx context.Context, user string, since time.Time) ([]Session, error) {
+	var rows []sessionRow
+	if err := r.db.SelectContext(ctx, &rows, `SELECT id, "user", client, user_agent, started_at, last_seen
+		FROM user_sessions WHERE "user" = $1 AND last_seen >= $2 ORDER BY last_seen DESC`, user, since); err != nil {
+		return nil, err
+	}
+	sessions := make([]Session, 0, len(rows))
+	for _, row := range rows {
+		sessions = append(sessions, Session{
+			ID:        row.ID,
+			User:      row.User,
+			Client:    row.Client,
+			UserAgent: row.UserAgent,
+			StartedAt: row.StartedAt.UTC(),
+			LastSeen:  row.LastSeen.UTC(),
+		})
+	}
+	return sessions, nil
+}
+
+func (r *PostgresRepository) DeleteSessions(ctx context.Context, user string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM user_sessions WHERE "user" = $1`, user)
+	return err
+}
@@ -0,0 +1,130 @@
+package users
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Repository persists users and their activity sessions.
+type Repository interface {
+	SaveUser(ctx context.Context, user User) error
+	// SaveActivity stores user when they are new, and otherwise only their last seen and last login
+	// times and groups, so that it never undoes a lock or reset made meanwhile.
+	SaveActivity(ctx context.Context, user User) error
+	GetUser(ctx context.Context, name string) (User, error)
+	// ListUsers returns matching users by name.
+	ListUsers(ctx context.Context, filter Filter) ([]User, error)
+	// DeleteUser removes a user and their sessions.
+	DeleteUser(ctx context.Context, name string) error
+	SaveSession(ctx context.Context, session Session) error
+	// ListSessions returns the user's sessions seen since the given time, most recent first.
+	ListSessions(ctx context.Context, user string, since time.Time) ([]Session, error)
+	DeleteSessions(ctx context.Context, user string) error
+}
+
+// MemoryRepository keeps users in process memory; it is used when no database is configured.
+type MemoryRepository struct {
+	mu       sync.RWMutex
+	users    map[string]User
+	sessions map[string]Session
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{users: make(map[string]User), sessions: make(map[string]Session)}
+}
+
+func (r *MemoryRepository) SaveUser(ctx context.Context, user User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[user.Name] = user
+	return nil
+}
+
+func (r *MemoryRepository) SaveActivity(ctx context.Context, user User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if stored, ok := r.users[user.Name]; ok {
+		stored.LastSeen, stored.LastLogin = user.LastSeen, user.LastLogin
+		if len(user.Groups) > 0 {
+			stored.Groups = user.Groups
+		}
+		user = stored
+	}
+	r.users[user.Name] = user
+	return nil
+}
+
+func (r *MemoryRepository) GetUser(ctx context.Context, name string) (User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	user, ok := r.users[name]
+	if !ok {
+		return User{}, ErrUserNotFound{Name: name}
+	}
+	return user, nil
+}
+
+func (r *MemoryRepository) ListUsers(ctx context.Context, filter Filter) ([]User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	users := make([]User, 0)
+	for _, user := range r.users {
+		if filter.matches(user) {
+			users = append(users, user)
+		}
+	}
+	slices.SortFunc(users, func(a, b User) int { return strings.Compare(a.Name, b.Name) })
+	if filter.Limit > 0 && len(users) > filter.Limit {
+		users = users[:filter.Limit]
+	}
+	return users, nil
+}
+
+func (r *MemoryRepository) DeleteUser(ctx context.Context, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[name]; !ok {
+		return ErrUserNotFound{Name: name}
+	}
+	delete(r.users, name)
+	r.deleteSessions(name)
+	return nil
+}
+
+func (r *MemoryRepository) SaveSession(ctx context.Context, session Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[session.ID] = session
+	return nil
+}
+
+func (r *MemoryRepository) ListSessions(ctx context.Context, user string, since time.Time) ([]Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	sessions := make([]Session, 0)
+	for _, session := range r.sessions {
+		if session.User == user && !session.LastSeen.Before(since) {
+			sessions = append(sessions, session)
+		}
+	}
+	slices.SortFunc(sessions, func(a, b Session) int { return b.LastSeen.Compare(a.LastSeen) })
+	return sessions, nil
+}
+
+func (r *MemoryRepository) DeleteSessions(ctx context.Context, user string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deleteSessions(user)
+	return nil
+}
+
+func (r *MemoryRepository) deleteSessions(user string) {
+	for id, session := range r.sessions {
+		if session.User == user {
+			delete(r.sessions, id)
+		}
+	}
+}
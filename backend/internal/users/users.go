@@ -0,0 +1,112 @@
+// Package users keeps a directory of the people who have used KubeChat, their activity sessions
+// and whether an admin locked them out. KubeChat holds no passwords: users are authenticated by
+// the identity proxy in front of it and first appear here with their first request.
+package users
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/roles"
+)
+
+// SessionIdle ends an activity session after this long without requests; the user's next request
+// starts a new session and counts as a login.
+const SessionIdle = 30 * time.Minute
+
+// User is a person known to KubeChat.
+type User struct {
+	Name      string    `json:"name"`
+	Groups    []string  `json:"groups,omitempty"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	// LastLogin is when the user's latest activity session started.
+	LastLogin  time.Time  `json:"lastLogin"`
+	Locked     bool       `json:"locked"`
+	LockedBy   string     `json:"lockedBy,omitempty"`
+	LockedAt   *time.Time `json:"lockedAt,omitempty"`
+	LockReason string     `json:"lockReason,omitempty"`
+	// ResetAt is when an admin last reset the user's credentials.
+	ResetBy string     `json:"resetBy,omitempty"`
+	ResetAt *time.Time `json:"resetAt,omitempty"`
+}
+
+// Session is a run of requests from one client without a gap longer than SessionIdle.
+type Session struct {
+	ID        string    `json:"id"`
+	User      string    `json:"user"`
+	Client    string    `json:"client"`
+	UserAgent string    `json:"userAgent,omitempty"`
+	StartedAt time.Time `json:"startedAt"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// Details is a user with their roles and active sessions.
+type Details struct {
+	User
+	Admin    bool            `json:"admin"`
+	Bindings []roles.Binding `json:"bindings"`
+	// Permissions are granted in every namespace; namespace bindings add to them.
+	Permissions []roles.Permission `json:"permissions"`
+	Sessions    []Session          `json:"sessions"`
+}
+
+// Filter selects users. Query matches part of a name, ignoring case.
+type Filter struct {
+	Query  string
+	Locked *bool
+	Limit  int
+}
+
+func (f Filter) matches(user User) bool {
+	if f.Query != "" && !strings.Contains(strings.ToLower(user.Name), strings.ToLower(f.Query)) {
+		return false
+	}
+	return f.Locked == nil || *f.Locked == user.Locked
+}
+
+// Action names an audited change to a user.
+type Action string
+
+const (
+	ActionLocked       Action = "locked"
+	ActionUnlocked     Action = "unlocked"
+	ActionReset        Action = "credentials_reset"
+	ActionRoleAssigned Action = "role_assigned"
+	ActionRoleRevoked  Action = "role_revoked"
+)
+
+// Change is the audit ledger entry for a change an admin made to a user.
+type Change struct {
+	Actor  string    `json:"actor"`
+	User   string    `json:"user"`
+	Action Action    `json:"action"`
+	Detail string    `json:"detail,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+type ErrUserNotFound struct {
+	Name string
+}
+
+func (e ErrUserNotFound) Error() string {
+	return fmt.Sprintf("user %s not found", e.Name)
+}
+
+// ErrLocked refuses requests from a locked user.
+type ErrLocked struct {
+	Name string
+}
+
+func (e ErrLocked) Error() string {
+	return fmt.Sprintf("user %s is locked; ask a KubeChat admin to unlock it", e.Name)
+}
+
+type ErrInvalidRequest struct {
+	Reason string
+}
+
+func (e ErrInvalidRequest) Error() string {
+	return e.Reason
+}
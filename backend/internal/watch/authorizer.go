@@ -0,0 +1,137 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pramodksahoo/kubechat/backend/internal/roles"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// decisionTTL is how long an access decision is reused before the cluster is asked again.
+const decisionTTL = time.Minute
+
+// Access asks whether a user may watch a resource in a namespace; an empty namespace means all of them.
+type Access struct {
+	Config    string
+	Cluster   string
+	User      string
+	Groups    []string
+	Group     string
+	Resource  string
+	Namespace string
+}
+
+// Authorizer decides whether subscribers may watch what they subscribed to.
+type Authorizer interface {
+	Allowed(ctx context.Context, access Access) (bool, error)
+}
+
+func access(sub *Subscription, namespace string) Access {
+	return Access{
+		Config:    sub.Config,
+		Cluster:   sub.Cluster,
+		User:      sub.User,
+		Groups:    sub.groups,
+		Group:     sub.Resource.Group,
+		Resource:  sub.Resource.Resource,
+		Namespace: namespace,
+	}
+}
+
+// ClientFunc resolves the service account clientset of a cluster.
+type ClientFunc func(config, cluster string) kubernetes.Interface
+
+// RBACAuthorizer asks the cluster with SubjectAccessReviews whether the subscriber's own identity,
+// not KubeChat's service account, may watch. Decisions are cached briefly because cluster-wide
+// subscriptions check every namespace their events come from.
+type RBACAuthorizer struct {
+	clients ClientFunc
+	now     func() time.Time
+
+	mu        sync.Mutex
+	decisions map[string]decision
+}
+
+type decision struct {
+	allowed bool
+	expires time.Time
+}
+
+func NewRBACAuthorizer(clients ClientFunc) *RBACAuthorizer {
+	return &RBACAuthorizer{clients: clients, now: time.Now, decisions: make(map[string]decision)}
+}
+
+func (a *RBACAuthorizer) Allowed(ctx context.Context, access Access) (bool, error) {
+	groups := slices.Clone(access.Groups)
+	slices.Sort(groups)
+	key := strings.Join([]string{access.Config, access.Cluster, access.User, strings.Join(groups, ","), access.Group, access.Resource, access.Namespace}, "\x00")
+	now := a.now()
+
+	a.mu.Lock()
+	cached, ok := a.decisions[key]
+	a.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.allowed, nil
+	}
+
+	review, err := a.clients(access.Config, access.Cluster).AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   access.User,
+			Groups: access.Groups,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Verb:      "watch",
+				Group:     access.Group,
+				Resource:  access.Resource,
+				Namespace: access.Namespace,
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("review watch access to %s: %w", access.Resource, err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for k, d := range a.decisions {
+		if !now.Before(d.expires) {
+			delete(a.decisions, k)
+		}
+	}
+	a.decisions[key] = decision{allowed: review.Status.Allowed, expires: now.Add(decisionTTL)}
+	return review.Status.Allowed, nil
+}
+
+// NamespacePolicy checks KubeChat's own namespace rules; an empty namespace stands for every
+// namespace.
+type NamespacePolicy interface {
+	CheckNamespace(ctx context.Context, user string, groups []string, namespace string) error
+}
+
+// PolicyAuthorizer narrows another authorizer to the namespaces KubeChat's namespace rules let the
+// subscriber use, so cluster-wide subscriptions only carry events from those namespaces.
+type PolicyAuthorizer struct {
+	next   Authorizer
+	policy NamespacePolicy
+}
+
+func NewPolicyAuthorizer(next Authorizer, policy NamespacePolicy) *PolicyAuthorizer {
+	return &PolicyAuthorizer{next: next, policy: policy}
+}
+
+func (a *PolicyAuthorizer) Allowed(ctx context.Context, access Access) (bool, error) {
+	if err := a.policy.CheckNamespace(ctx, access.User, access.Groups, access.Namespace); err != nil {
+		if errors.As(err, &roles.ErrForbidden{}) {
+			return false, nil
+		}
+		return false, err
+	}
+	return a.next.Allowed(ctx, access)
+}
@@ -0,0 +1,366 @@
+// Package watch pushes add, update and delete events of Kubernetes resources to subscribed
+// clients. Subscriptions share one informer per cluster and resource, are limited per user and
+// only deliver objects the subscriber may watch according to the cluster's RBAC.
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// DefaultMaxPerUser bounds the subscriptions one user may hold across all connections.
+	DefaultMaxPerUser = 20
+	// DefaultMaxTotal bounds the subscriptions held by every user together.
+	DefaultMaxTotal = 1000
+	// eventBuffer is how many events may queue for a subscriber before it is closed as too slow.
+	eventBuffer = 256
+)
+
+var (
+	ErrTooManySubscriptions = errors.New("subscription limit reached")
+	// ErrSlowConsumer closes a subscription whose events queued faster than they were read.
+	ErrSlowConsumer = errors.New("subscriber fell behind; subscribe again to resynchronize")
+)
+
+type ErrInvalidRequest struct {
+	Reason string
+}
+
+func (e ErrInvalidRequest) Error() string {
+	return e.Reason
+}
+
+type ErrForbidden struct {
+	User      string
+	Resource  string
+	Namespace string
+}
+
+func (e ErrForbidden) Error() string {
+	if e.Namespace == "" {
+		return fmt.Sprintf("%s cannot watch %s in any namespace", e.User, e.Resource)
+	}
+	return fmt.Sprintf("%s cannot watch %s in namespace %s", e.User, e.Resource, e.Namespace)
+}
+
+// InformerFunc returns the shared dynamic informer factory of a cluster, or nil when the cluster is not connected.
+type InformerFunc func(config, cluster string) dynamicinformer.DynamicSharedInformerFactory
+
+// MapperFunc returns the REST mapper used to resolve the kinds clients subscribe to.
+type MapperFunc func(config, cluster string) (meta.RESTMapper, error)
+
+type EventType string
+
+const (
+	EventAdded    EventType = "ADDED"
+	EventModified EventType = "MODIFIED"
+	EventDeleted  EventType = "DELETED"
+)
+
+// Request selects the objects a subscription receives events for.
+type Request struct {
+	User    string   `json:"-"`
+	Groups  []string `json:"-"`
+	Config  string   `json:"config"`
+	Cluster string   `json:"cluster"`
+	// Resource is a kind such as Deployment or a resource such as deployments.apps.
+	Resource string `json:"resource"`
+	// Namespace limits the subscription to one namespace; empty watches every namespace the user may watch.
+	Namespace string `json:"namespace,omitempty"`
+	Selector  string `json:"selector,omitempty"`
+}
+
+type Event struct {
+	Type   EventType      `json:"type"`
+	Object map[string]any `json:"object"`
+}
+
+// Subscription delivers events until it is closed by the client, the hub or a slow read.
+type Subscription struct {
+	ID        string                      `json:"id"`
+	User      string                      `json:"user"`
+	Config    string                      `json:"config"`
+	Cluster   string                      `json:"cluster"`
+	Resource  schema.GroupVersionResource `json:"resource"`
+	Namespace string                      `json:"namespace,omitempty"`
+	Selector  string                      `json:"selector,omitempty"`
+	CreatedAt time.Time                   `json:"createdAt"`
+
+	groups       []string
+	selector     labels.Selector
+	clusterWide  bool
+	events       chan Event
+	done         chan struct{}
+	closed       atomic.Bool
+	err          error
+	registration cache.ResourceEventHandlerRegistration
+	informer     cache.SharedIndexInformer
+}
+
+// Events yields events until Done is closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Done is closed when the subscription ends; Err then says why.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err is nil for subscriptions the client closed.
+func (s *Subscription) Err() error {
+	return s.err
+}
+
+// Hub manages the subscriptions of every connected client.
+type Hub struct {
+	informers  InformerFunc
+	mappers    MapperFunc
+	authorizer Authorizer
+	logger     *log.Logger
+	stop       <-chan struct{}
+	now        func() time.Time
+
+	maxPerUser int
+	maxTotal   int
+
+	mu            sync.Mutex
+	subscriptions map[string]*Subscription
+	perUser       map[string]int
+}
+
+// Limits bound subscriptions; zero values take the defaults.
+type Limits struct {
+	PerUser int
+	Total   int
+}
+
+// NewHub serves subscriptions from the informers, which run until stop is closed.
+func NewHub(informers InformerFunc, mappers MapperFunc, authorizer Authorizer, limits Limits, stop <-chan struct{}, logger *log.Logger) *Hub {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if limits.PerUser <= 0 {
+		limits.PerUser = DefaultMaxPerUser
+	}
+	if limits.Total <= 0 {
+		limits.Total = DefaultMaxTotal
+	}
+	return &Hub{
+		informers:     informers,
+		mappers:       mappers,
+		authorizer:    authorizer,
+		logger:        logger,
+		stop:          stop,
+		now:           func() time.Time { return time.Now().UTC() },
+		maxPerUser:    limits.PerUser,
+		maxTotal:      limits.Total,
+		subscriptions: make(map[string]*Subscription),
+		perUser:       make(map[string]int),
+	}
+}
+
+// Subscribe starts delivering events for the objects req selects. The current objects arrive
+// first as ADDED events.
+func (h *Hub) Subscribe(ctx context.Context, req Request) (*Subscription, error) {
+	if req.Config == "" || req.Cluster == "" || strings.TrimSpace(req.Resource) == "" {
+		return nil, ErrInvalidRequest{Reason: "config, cluster and resource are required"}
+	}
+	selector, err := labels.Parse(req.Selector)
+	if err != nil {
+		return nil, ErrInvalidRequest{Reason: "invalid label selector: " + err.Error()}
+	}
+	factory := h.informers(req.Config, req.Cluster)
+	if factory == nil {
+		return nil, ErrInvalidRequest{Reason: fmt.Sprintf("cluster %s/%s is not connected", req.Config, req.Cluster)}
+	}
+	gvr, namespaced, err := h.resolve(req)
+	if err != nil {
+		return nil, err
+	}
+	if !namespaced {
+		req.Namespace = ""
+	}
+
+	sub := &Subscription{
+		ID:        uuid.NewString(),
+		User:      req.User,
+		Config:    req.Config,
+		Cluster:   req.Cluster,
+		Resource:  gvr,
+		Namespace: req.Namespace,
+		Selector:  selector.String(),
+		CreatedAt: h.now(),
+		groups:    req.Groups,
+		selector:  selector,
+		events:    make(chan Event, eventBuffer),
+		done:      make(chan struct{}),
+	}
+	// Cluster-wide subscriptions of users who may not watch every namespace are filtered per object.
+	allowed, err := h.authorizer.Allowed(ctx, access(sub, req.Namespace))
+	if err != nil {
+		return nil, err
+	}
+	if !allowed && (req.Namespace != "" || !namespaced) {
+		return nil, ErrForbidden{User: req.User, Resource: gvr.GroupResource().String(), Namespace: req.Namespace}
+	}
+	sub.clusterWide = allowed
+
+	if err := h.reserve(sub); err != nil {
+		return nil, err
+	}
+	sub.informer = factory.ForResource(gvr).Informer()
+	registration, err := sub.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj any) { h.deliver(sub, EventAdded, obj) },
+		UpdateFunc: func(_, obj any) { h.deliver(sub, EventModified, obj) },
+		DeleteFunc: func(obj any) { h.deliver(sub, EventDeleted, obj) },
+	})
+	if err != nil {
+		h.release(sub)
+		return nil, err
+	}
+	sub.registration = registration
+	factory.Start(h.stop)
+	h.logger.Info("watch subscription started", "id", sub.ID, "user", sub.User, "resource", gvr.String(), "namespace", sub.Namespace)
+	return sub, nil
+}
+
+// Unsubscribe ends a subscription; ending one that already ended does nothing.
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.close(sub, nil)
+}
+
+// Subscriptions lists the open subscriptions of user, or of every user when user is empty.
+func (h *Hub) Subscriptions(user string) []*Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	subs := make([]*Subscription, 0)
+	for _, sub := range h.subscriptions {
+		if user == "" || sub.User == user {
+			subs = append(subs, sub)
+		}
+	}
+	return subs
+}
+
+func (h *Hub) resolve(req Request) (schema.GroupVersionResource, bool, error) {
+	mapper, err := h.mappers(req.Config, req.Cluster)
+	if err != nil {
+		return schema.GroupVersionResource{}, false, err
+	}
+	mapping, err := mappingFor(mapper, strings.TrimSpace(req.Resource))
+	if meta.IsNoMatchError(err) {
+		return schema.GroupVersionResource{}, false, ErrInvalidRequest{Reason: fmt.Sprintf("unknown resource %q", req.Resource)}
+	}
+	if err != nil {
+		return schema.GroupVersionResource{}, false, err
+	}
+	return mapping.Resource, mapping.Scope.Name() == meta.RESTScopeNameNamespace, nil
+}
+
+// mappingFor resolves a kind such as Deployment, or a resource such as deployments.apps when
+// name is all lower case.
+func mappingFor(mapper meta.RESTMapper, name string) (*meta.RESTMapping, error) {
+	if name != strings.ToLower(name) {
+		return mapper.RESTMapping(schema.ParseGroupKind(name))
+	}
+	gvk, err := mapper.KindFor(schema.ParseGroupResource(name).WithVersion(""))
+	if err != nil {
+		return nil, err
+	}
+	return mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+}
+
+func (h *Hub) reserve(sub *Subscription) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.subscriptions) >= h.maxTotal {
+		return ErrTooManySubscriptions
+	}
+	if h.perUser[sub.User] >= h.maxPerUser {
+		return fmt.Errorf("%w: %s already holds %d subscriptions", ErrTooManySubscriptions, sub.User, h.maxPerUser)
+	}
+	h.subscriptions[sub.ID] = sub
+	h.perUser[sub.User]++
+	return nil
+}
+
+func (h *Hub) release(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscriptions[sub.ID]; !ok {
+		return
+	}
+	delete(h.subscriptions, sub.ID)
+	if h.perUser[sub.User]--; h.perUser[sub.User] <= 0 {
+		delete(h.perUser, sub.User)
+	}
+}
+
+func (h *Hub) close(sub *Subscription, err error) {
+	if !sub.closed.CompareAndSwap(false, true) {
+		return
+	}
+	sub.err = err
+	h.release(sub)
+	if sub.registration != nil {
+		if removeErr := sub.informer.RemoveEventHandler(sub.registration); removeErr != nil {
+			h.logger.Warn("failed to remove watch handler", "id", sub.ID, "error", removeErr)
+		}
+	}
+	close(sub.done)
+	if err != nil {
+		h.logger.Warn("watch subscription closed", "id", sub.ID, "user", sub.User, "error", err)
+	}
+}
+
+// deliver queues an informer notification for sub when the object matches its namespace,
+// selector and the subscriber's permissions.
+func (h *Hub) deliver(sub *Subscription, eventType EventType, obj any) {
+	if sub.closed.Load() {
+		return
+	}
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	object, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	if sub.Namespace != "" && object.GetNamespace() != sub.Namespace {
+		return
+	}
+	if !sub.selector.Matches(labels.Set(object.GetLabels())) {
+		return
+	}
+	if !sub.clusterWide {
+		allowed, err := h.authorizer.Allowed(context.Background(), access(sub, object.GetNamespace()))
+		if err != nil {
+			h.logger.Warn("failed to authorize watch event", "id", sub.ID, "namespace", object.GetNamespace(), "error", err)
+			return
+		}
+		if !allowed {
+			return
+		}
+	}
+	select {
+	case sub.events <- Event{Type: eventType, Object: object.DeepCopy().Object}:
+	default:
+		go h.close(sub, ErrSlowConsumer)
+	}
+}
@@ -0,0 +1,166 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pramodksahoo/kubechat/backend/internal/roles"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+var configMaps = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+func configMap(namespace, name string, labels map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	obj.SetLabels(labels)
+	return obj
+}
+
+// namespaceAuthorizer allows watching only in the listed namespaces, never cluster-wide.
+type namespaceAuthorizer map[string]bool
+
+func (a namespaceAuthorizer) Allowed(ctx context.Context, access Access) (bool, error) {
+	return a[access.Namespace], nil
+}
+
+func newTestHub(t *testing.T, authorizer Authorizer, limits Limits, objects ...runtime.Object) (*Hub, *dynamicfake.FakeDynamicClient) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{configMaps: "ConfigMapList"}, objects...)
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(client, 0)
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Version: "v1"}})
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+	hub := NewHub(func(config, cluster string) dynamicinformer.DynamicSharedInformerFactory {
+		if cluster != "prod" {
+			return nil
+		}
+		return factory
+	}, func(config, cluster string) (meta.RESTMapper, error) {
+		return mapper, nil
+	}, authorizer, limits, stop, log.NewWithOptions(io.Discard, log.Options{}))
+	return hub, client
+}
+
+func next(t *testing.T, sub *Subscription) Event {
+	t.Helper()
+	select {
+	case event := <-sub.Events():
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for an event")
+		return Event{}
+	}
+}
+
+func TestSubscriptionFiltersByPermissionAndSelector(t *testing.T) {
+	hub, client := newTestHub(t, namespaceAuthorizer{"shop": true}, Limits{},
+		configMap("shop", "settings", map[string]string{"app": "api"}),
+		configMap("billing", "secrets", map[string]string{"app": "api"}),
+	)
+	ctx := context.Background()
+
+	sub, err := hub.Subscribe(ctx, Request{User: "alice", Config: "kubeconfig", Cluster: "prod", Resource: "configmaps", Selector: "app=api"})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer hub.Unsubscribe(sub)
+	if event := next(t, sub); event.Type != EventAdded || event.Object["metadata"].(map[string]any)["name"] != "settings" {
+		t.Fatalf("expected only the permitted namespace's object, got %+v", event)
+	}
+
+	if _, err := client.Resource(configMaps).Namespace("shop").Create(ctx, configMap("shop", "ignored", map[string]string{"app": "web"}), metav1.CreateOptions{}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := client.Resource(configMaps).Namespace("shop").Create(ctx, configMap("shop", "flags", map[string]string{"app": "api"}), metav1.CreateOptions{}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if event := next(t, sub); event.Object["metadata"].(map[string]any)["name"] != "flags" {
+		t.Fatalf("expected the selector to skip other objects, got %+v", event)
+	}
+	if err := client.Resource(configMaps).Namespace("shop").Delete(ctx, "flags", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if event := next(t, sub); event.Type != EventDeleted {
+		t.Fatalf("expected a delete event, got %+v", event)
+	}
+
+	var forbidden ErrForbidden
+	if _, err := hub.Subscribe(ctx, Request{User: "alice", Config: "kubeconfig", Cluster: "prod", Resource: "ConfigMap", Namespace: "billing"}); !errors.As(err, &forbidden) {
+		t.Fatalf("expected a forbidden namespace to be refused, got %v", err)
+	}
+	var invalid ErrInvalidRequest
+	if _, err := hub.Subscribe(ctx, Request{User: "alice", Config: "kubeconfig", Cluster: "staging", Resource: "ConfigMap"}); !errors.As(err, &invalid) {
+		t.Fatalf("expected an unknown cluster to be refused, got %v", err)
+	}
+}
+
+func TestSubscriptionLimits(t *testing.T) {
+	hub, _ := newTestHub(t, namespaceAuthorizer{"shop": true}, Limits{PerUser: 1, Total: 2})
+	ctx := context.Background()
+	req := Request{User: "alice", Config: "kubeconfig", Cluster: "prod", Resource: "ConfigMap", Namespace: "shop"}
+
+	first, err := hub.Subscribe(ctx, req)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if _, err := hub.Subscribe(ctx, req); !errors.Is(err, ErrTooManySubscriptions) {
+		t.Fatalf("expected the per-user limit, got %v", err)
+	}
+	req.User = "bob"
+	if _, err := hub.Subscribe(ctx, req); err != nil {
+		t.Fatalf("expected another user to subscribe, got %v", err)
+	}
+	req.User = "carol"
+	if _, err := hub.Subscribe(ctx, req); !errors.Is(err, ErrTooManySubscriptions) {
+		t.Fatalf("expected the total limit, got %v", err)
+	}
+
+	hub.Unsubscribe(first)
+	<-first.Done()
+	if first.Err() != nil || len(hub.Subscriptions("alice")) != 0 {
+		t.Fatalf("expected unsubscribing to free the slot")
+	}
+	if _, err := hub.Subscribe(ctx, req); err != nil {
+		t.Fatalf("expected a freed slot to be reused, got %v", err)
+	}
+}
+
+func TestPolicyAuthorizerFiltersDeniedNamespaces(t *testing.T) {
+	policy := roles.NewService(roles.NewMemoryRepository(), nil, roles.RoleViewer, nil)
+	if _, err := policy.AddNamespaceRule(context.Background(), "root", roles.NamespaceRuleRequest{Effect: roles.NamespaceDeny, Pattern: "bill*"}); err != nil {
+		t.Fatalf("add rule: %v", err)
+	}
+	authorizer := NewPolicyAuthorizer(namespaceAuthorizer{"": true, "shop": true, "billing": true}, policy)
+	hub, _ := newTestHub(t, authorizer, Limits{},
+		configMap("billing", "secrets", nil),
+		configMap("shop", "settings", nil),
+	)
+	ctx := context.Background()
+
+	sub, err := hub.Subscribe(ctx, Request{User: "alice", Config: "kubeconfig", Cluster: "prod", Resource: "configmaps"})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer hub.Unsubscribe(sub)
+	if event := next(t, sub); event.Object["metadata"].(map[string]any)["namespace"] != "shop" {
+		t.Fatalf("expected events from the denied namespace to be dropped, got %+v", event)
+	}
+	var forbidden ErrForbidden
+	if _, err := hub.Subscribe(ctx, Request{User: "alice", Config: "kubeconfig", Cluster: "prod", Resource: "configmaps", Namespace: "billing"}); !errors.As(err, &forbidden) {
+		t.Fatalf("expected the denied namespace to be refused, got %v", err)
+	}
+}
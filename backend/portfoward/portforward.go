@@ -3,7 +3,6 @@ package portforward
 import (
 	"context"
 	"fmt"
-	"log"
 	"net"
 	"net/http"
 	"os"
@@ -11,6 +10,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/charmbracelet/log"
 	"github.com/google/uuid"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -150,7 +150,7 @@ func (p *PortForwarder) Start(cfg *rest.Config, clientset kubernetes.Interface,
 
 	go func() {
 		if err := fw.ForwardPorts(); err != nil {
-			log.Printf("Port forward error for namespace:%s kind:%s name:%s err:%s", namespace, kind, name, err.Error())
+			log.Error("port forward failed", "namespace", namespace, "kind", kind, "name", name, "error", err)
 		}
 	}()
 
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/anomaly"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+)
+
+// ActivityObserver looks for suspicious patterns in what users do.
+type ActivityObserver interface {
+	Observe(ctx context.Context, activity anomaly.Activity) []anomaly.Event
+}
+
+// AnomalyMiddleware reports every request of a known user to observer, attributed to the admin
+// behind an impersonation. It runs after the request is served and never changes the response.
+func AnomalyMiddleware(observer ActivityObserver) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			err := next(c)
+			if user := identity.Actor(c); user != identity.Anonymous {
+				observer.Observe(c.Request().Context(), anomaly.Activity{
+					User:   user,
+					At:     time.Now().UTC(),
+					Source: anomaly.SourceRequest,
+					IP:     c.RealIP(),
+					Method: c.Request().Method,
+					Path:   c.Path(),
+				})
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/capabilities"
+)
+
+// CapabilityChecker reports whether an optional subsystem is usable.
+type CapabilityChecker interface {
+	Require(name capabilities.Name) error
+}
+
+// CapabilityMiddleware answers 503 with the capability_disabled code when a request needs a
+// subsystem that failed to initialize. Cluster-scoped routes need Kubernetes; rules name what
+// other route groups need. It runs before the cluster middlewares, so a server without any
+// kubeconfig explains how to add one instead of rejecting the cluster parameters.
+func CapabilityMiddleware(checker CapabilityChecker, rules []capabilities.Rule) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			required, ok := capabilities.RequiredCapability(rules, c.Path())
+			if !ok && !shouldSkip(c) {
+				required, ok = capabilities.Kubernetes, true
+			}
+			if !ok {
+				return next(c)
+			}
+			err := checker.Require(required)
+			var disabled capabilities.ErrDisabled
+			if errors.As(err, &disabled) {
+				return c.JSON(http.StatusServiceUnavailable, disabled.Response())
+			}
+			if err != nil {
+				log.Error("failed to check capability", "capability", required, "path", c.Path(), "error", err)
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to check capability"})
+			}
+			return next(c)
+		}
+	}
+}
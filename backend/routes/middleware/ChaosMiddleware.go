@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ChaosExperimentHeader names the chaos experiment that throttled a request.
+const ChaosExperimentHeader = "X-Chaos-Experiment"
+
+// ClusterThrottles reports whether a chaos experiment is throttling a cluster's API.
+type ClusterThrottles interface {
+	Throttle(cluster string) (experiment string, retryAfter time.Duration, throttled bool)
+}
+
+// ChaosMiddleware answers cluster API requests with 429 Too Many Requests while a chaos
+// experiment throttles the cluster, as an overloaded API server would.
+func ChaosMiddleware(throttles ClusterThrottles) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			cluster := c.QueryParam("cluster")
+			if shouldSkip(c) || cluster == "" {
+				return next(c)
+			}
+			experiment, retryAfter, throttled := throttles.Throttle(cluster)
+			if !throttled {
+				return next(c)
+			}
+			c.Response().Header().Set(ChaosExperimentHeader, experiment)
+			c.Response().Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(retryAfter.Seconds())))))
+			return c.JSON(http.StatusTooManyRequests, map[string]string{"error": "cluster API throttled by chaos experiment " + experiment})
+		}
+	}
+}
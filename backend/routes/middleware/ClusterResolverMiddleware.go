@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ClusterResolver maps a cluster reference to the config and context that address it.
+type ClusterResolver interface {
+	Resolve(ctx context.Context, ref string) (config, cluster string, err error)
+}
+
+// ClusterResolverMiddleware fills in ?config= for requests that only name a cluster, so
+// registered clusters can be addressed by name. Unknown clusters are left to the cluster
+// middlewares to reject.
+func ClusterResolverMiddleware(resolver ClusterResolver) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			query := c.Request().URL.Query()
+			if query.Get("config") != "" || query.Get("cluster") == "" {
+				return next(c)
+			}
+			config, cluster, err := resolver.Resolve(c.Request().Context(), query.Get("cluster"))
+			if err != nil {
+				return next(c)
+			}
+			query.Set("config", config)
+			query.Set("cluster", cluster)
+			c.Request().URL.RawQuery = query.Encode()
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+	"github.com/pramodksahoo/kubechat/backend/internal/impersonation"
+)
+
+// ImpersonationSessions authorizes impersonated requests and records them in the audit log.
+type ImpersonationSessions interface {
+	Authorize(ctx context.Context, id, actor string) (impersonation.Session, error)
+	RecordRequest(ctx context.Context, session impersonation.Session, method, path string, status int, requestID string) error
+}
+
+// ImpersonationMiddleware runs requests carrying an impersonation session header as the session's
+// subject and audits each of them under both the admin and the subject. Sessions cannot be used
+// to manage impersonation itself, so an admin can never consent on the subject's behalf.
+func ImpersonationMiddleware(sessions ImpersonationSessions) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			id := strings.TrimSpace(c.Request().Header.Get(identity.ImpersonationHeader))
+			if id == "" {
+				return next(c)
+			}
+			path := c.Request().URL.Path
+			if strings.HasPrefix(path, "/api/v1/impersonations") || strings.HasPrefix(path, "/api/v1/admin/impersonations") {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": "impersonation cannot be managed while impersonating"})
+			}
+			session, err := sessions.Authorize(c.Request().Context(), id, identity.User(c))
+			if err != nil {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+			}
+			identity.ActAs(c, session.Subject)
+			c.Response().Header().Set(identity.ImpersonationHeader, session.ID)
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+			requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+			if err := sessions.RecordRequest(c.Request().Context(), session, c.Request().Method, path, c.Response().Status, requestID); err != nil {
+				log.Error("failed to audit impersonated request", "session", session.ID, "actor", session.Actor, "subject", session.Subject, "path", path, "error", err)
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+	"github.com/pramodksahoo/kubechat/backend/internal/logging"
+)
+
+// LoggingMiddleware carries the request ID and caller of every request in its context, so
+// entries logged while serving it name both, and logs the request once it has been served. It
+// runs after the request ID middleware.
+func LoggingMiddleware(logger *log.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			started := time.Now()
+			req := c.Request()
+			ctx := logging.NewContext(req.Context(),
+				logging.RequestIDKey, c.Response().Header().Get(echo.HeaderXRequestID),
+				logging.UserKey, identity.User(c),
+			)
+			c.SetRequest(req.WithContext(ctx))
+
+			err := next(c)
+			status := responseStatus(c, err)
+			keyvals := []any{
+				"method", req.Method,
+				"route", c.Path(),
+				"uri", req.RequestURI,
+				"status", status,
+				"latency", time.Since(started),
+				"remote_ip", c.RealIP(),
+			}
+			if err != nil {
+				keyvals = append(keyvals, "error", err)
+			}
+			entry := logging.FromContext(c.Request().Context(), logger)
+			if status >= http.StatusInternalServerError {
+				entry.Error("request", keyvals...)
+			} else {
+				entry.Info("request", keyvals...)
+			}
+			return err
+		}
+	}
+}
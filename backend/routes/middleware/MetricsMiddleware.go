@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RequestObserver records the latency of served requests.
+type RequestObserver interface {
+	ObserveRequest(method, route string, status int, d time.Duration)
+}
+
+// MetricsMiddleware reports every request's latency by method, route template and status.
+func MetricsMiddleware(observer RequestObserver) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			started := time.Now()
+			err := next(c)
+			observer.ObserveRequest(c.Request().Method, c.Path(), responseStatus(c, err), time.Since(started))
+			return err
+		}
+	}
+}
+
+// responseStatus is the status of the response to c, or for a handler error the status the
+// error handler will answer it with.
+func responseStatus(c echo.Context, err error) int {
+	if err == nil {
+		return c.Response().Status
+	}
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+	"github.com/pramodksahoo/kubechat/backend/internal/roles"
+)
+
+// NamespacePolicy checks KubeChat's namespace rules.
+type NamespacePolicy interface {
+	CheckNamespace(ctx context.Context, user string, groups []string, namespace string) error
+}
+
+// NamespacePolicyMiddleware refuses requests naming a namespace, in ?namespace= or the route, that
+// the namespace rules do not let the caller use. It covers the Kubernetes resource routes and log
+// streams; commands and watch subscriptions name their namespace in the body and are checked by
+// their services.
+func NamespacePolicyMiddleware(policy NamespacePolicy) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			namespace := strings.TrimSpace(c.QueryParam("namespace"))
+			if namespace == "" {
+				namespace = c.Param("namespace")
+			}
+			if namespace == "" {
+				return next(c)
+			}
+			user := identity.User(c)
+			err := policy.CheckNamespace(c.Request().Context(), user, identity.Groups(c), namespace)
+			if errors.As(err, &roles.ErrForbidden{}) {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+			}
+			if err != nil {
+				log.Error("failed to check namespace rules", "user", user, "namespace", namespace, "error", err)
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to check namespace rules"})
+			}
+			return next(c)
+		}
+	}
+}
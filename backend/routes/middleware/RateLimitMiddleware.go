@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+	"github.com/pramodksahoo/kubechat/backend/internal/ratelimit"
+)
+
+// RequestLimiter spends one request of a caller's allowance for a path.
+type RequestLimiter interface {
+	Allow(ctx context.Context, path string, caller func(ratelimit.KeyBy) string) (ratelimit.Group, ratelimit.Decision)
+}
+
+// RateLimitMiddleware refuses requests over the caller's allowance with 429 and reports the
+// allowance of every limited request in RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and
+// RateLimit-Policy headers. Callers are the user named by one of the trusted proxies, else the
+// client IP that echo's IP extractor reports. Identity headers from anyone else are ignored, so
+// changing them does not buy a fresh allowance.
+func RateLimitMiddleware(limiter RequestLimiter, proxies []*net.IPNet) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			group, decision := limiter.Allow(c.Request().Context(), c.Request().URL.Path, func(by ratelimit.KeyBy) string {
+				return rateLimitCaller(c, by, proxies)
+			})
+			if group.Requests <= 0 {
+				return next(c)
+			}
+			header := c.Response().Header()
+			header.Set("RateLimit-Limit", strconv.Itoa(group.Requests))
+			header.Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+			header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.Reset)))
+			header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", group.Requests, ceilSeconds(group.Period.Duration)))
+			if !decision.Allowed {
+				header.Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(decision.RetryAfter))))
+				return c.JSON(http.StatusTooManyRequests, map[string]string{"error": "rate limit exceeded for " + group.Name + " requests"})
+			}
+			return next(c)
+		}
+	}
+}
+
+func rateLimitCaller(c echo.Context, by ratelimit.KeyBy, proxies []*net.IPNet) string {
+	if by == ratelimit.KeyByUser && fromProxy(c.Request().RemoteAddr, proxies) {
+		if user := identity.User(c); user != identity.Anonymous {
+			return "user:" + user
+		}
+	}
+	return "ip:" + c.RealIP()
+}
+
+// fromProxy reports whether the request's peer address is in one of proxies.
+func fromProxy(remoteAddr string, proxies []*net.IPNet) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && slices.ContainsFunc(proxies, func(proxy *net.IPNet) bool { return proxy.Contains(ip) })
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
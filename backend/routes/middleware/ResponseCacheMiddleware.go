@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/httpcache"
+)
+
+// ResponseCache keeps the responses of read-only endpoints.
+type ResponseCache interface {
+	Key(ctx context.Context, scope httpcache.Scope, request string) (string, error)
+	Get(ctx context.Context, route, key string) (httpcache.Entry, bool)
+	Put(ctx context.Context, key string, entry httpcache.Entry)
+}
+
+// ResponseCacheMiddleware serves GET requests from cache, keyed by path and query and scoped by
+// the config, cluster and namespace query parameters. Successful responses are cached and carry
+// an ETag; requests whose If-None-Match names it get 304 without a body. X-Cache tells whether
+// the response was a HIT or a MISS, and Cache-Control: no-cache skips the cached response.
+func ResponseCacheMiddleware(cache ResponseCache) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if req.Method != http.MethodGet {
+				return next(c)
+			}
+			ctx := req.Context()
+			scope := httpcache.Scope{Config: c.QueryParam("config"), Cluster: c.QueryParam("cluster"), Namespace: c.QueryParam("namespace")}
+			key, err := cache.Key(ctx, scope, req.URL.Path+"?"+req.URL.Query().Encode())
+			if err != nil {
+				return next(c)
+			}
+			if !strings.Contains(req.Header.Get(echo.HeaderCacheControl), "no-cache") {
+				if entry, ok := cache.Get(ctx, c.Path(), key); ok {
+					return serveCached(c, entry, "HIT")
+				}
+			}
+
+			original := c.Response().Writer
+			capture := &cacheWriter{ResponseWriter: original, status: http.StatusOK}
+			c.Response().Writer = capture
+			if err := next(c); err != nil {
+				// Let echo's error handler write the response through the capture.
+				c.Error(err)
+			}
+			c.Response().Writer = original
+			if capture.status != http.StatusOK {
+				original.WriteHeader(capture.status)
+				original.Write(capture.body.Bytes())
+				return nil
+			}
+			entry := httpcache.Entry{
+				Status:      capture.status,
+				ContentType: capture.Header().Get(echo.HeaderContentType),
+				ETag:        httpcache.ETag(capture.body.Bytes()),
+				Body:        capture.body.Bytes(),
+			}
+			cache.Put(ctx, key, entry)
+			return serveCached(c, entry, "MISS")
+		}
+	}
+}
+
+// serveCached writes entry, or 304 when the request already holds it. A response the handler
+// committed while it was captured is written straight to the connection.
+func serveCached(c echo.Context, entry httpcache.Entry, outcome string) error {
+	res := c.Response()
+	res.Header().Set("ETag", entry.ETag)
+	res.Header().Set("X-Cache", outcome)
+	status, body := entry.Status, entry.Body
+	if etagMatches(c.Request().Header.Get("If-None-Match"), entry.ETag) {
+		status, body = http.StatusNotModified, nil
+		res.Header().Del(echo.HeaderContentType)
+	} else {
+		res.Header().Set(echo.HeaderContentType, entry.ContentType)
+	}
+	if res.Committed {
+		res.Status = status
+		res.Writer.WriteHeader(status)
+		_, err := res.Writer.Write(body)
+		return err
+	}
+	res.WriteHeader(status)
+	_, err := res.Write(body)
+	return err
+}
+
+// etagMatches reports whether an If-None-Match header names etag, comparing tags weakly.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+type cacheWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *cacheWriter) WriteHeader(status int) { w.status = status }
+
+func (w *cacheWriter) Write(p []byte) (int, error) { return w.body.Write(p) }
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+	"github.com/pramodksahoo/kubechat/backend/internal/roles"
+)
+
+// RoleAuthorizer checks the caller's KubeChat roles.
+type RoleAuthorizer interface {
+	Authorize(ctx context.Context, user string, groups []string, permission roles.Permission, namespace string) error
+	AuthorizeAny(ctx context.Context, user string, groups []string, permission roles.Permission) error
+}
+
+// RoleMiddleware refuses requests to route groups the caller's roles do not grant. Requests
+// naming ?namespace= are checked in that namespace; others pass with the permission in any
+// namespace and are narrowed by the service handling them. Routes requiring roles.PermissionNone
+// pass for everyone. It runs after impersonation, so an admin acting as a user gets the user's
+// roles.
+func RoleMiddleware(authorizer RoleAuthorizer, rules []roles.Rule) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			permission, ok := roles.RequiredPermission(rules, c.Request().Method, c.Path())
+			if !ok || permission == roles.PermissionNone {
+				return next(c)
+			}
+			ctx, user, groups := c.Request().Context(), identity.User(c), identity.Groups(c)
+			var err error
+			if namespace := strings.TrimSpace(c.QueryParam("namespace")); namespace != "" {
+				err = authorizer.Authorize(ctx, user, groups, permission, namespace)
+			} else {
+				err = authorizer.AuthorizeAny(ctx, user, groups, permission)
+			}
+			if errors.As(err, &roles.ErrForbidden{}) {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+			}
+			if err != nil {
+				log.Error("failed to check roles", "user", user, "permission", permission, "error", err)
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to check roles"})
+			}
+			return next(c)
+		}
+	}
+}
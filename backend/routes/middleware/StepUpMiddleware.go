@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+	"github.com/pramodksahoo/kubechat/backend/internal/mfa"
+)
+
+// StepUpVerifier checks that a user verified a second factor recently and audits the check.
+type StepUpVerifier interface {
+	RequireStepUp(ctx context.Context, user, method, path string) error
+}
+
+// StepUpCondition reports whether a request is risky enough to need a fresh MFA assertion.
+type StepUpCondition func(c echo.Context) (bool, error)
+
+// StepUpMiddleware refuses requests the condition marks as high risk unless the caller verified
+// an MFA code within the step-up window. The check applies to the person making the request, so
+// an admin impersonating a user needs their own assertion.
+func StepUpMiddleware(verifier StepUpVerifier, condition StepUpCondition) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			required, err := condition(c)
+			if err != nil {
+				log.Error("failed to decide whether MFA step-up is required", "path", c.Path(), "error", err)
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to check MFA"})
+			}
+			if !required {
+				return next(c)
+			}
+			actor := identity.Actor(c)
+			err = verifier.RequireStepUp(c.Request().Context(), actor, c.Request().Method, c.Request().URL.Path)
+			var stepUp mfa.ErrStepUpRequired
+			if errors.As(err, &stepUp) {
+				return c.JSON(http.StatusForbidden, map[string]any{"error": err.Error(), "stepUpRequired": true, "enrolled": stepUp.Enrolled})
+			}
+			if err != nil {
+				log.Error("failed to check MFA step-up", "user", actor, "path", c.Path(), "error", err)
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to check MFA"})
+			}
+			return next(c)
+		}
+	}
+}
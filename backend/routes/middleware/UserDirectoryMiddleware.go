@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+	"github.com/pramodksahoo/kubechat/backend/internal/users"
+)
+
+// UserDirectory records who uses KubeChat and refuses locked users.
+type UserDirectory interface {
+	Seen(ctx context.Context, user string, groups []string, client, userAgent string) error
+}
+
+// UserDirectoryMiddleware records each request in the user directory and refuses requests from
+// locked users. It runs before impersonation, so it sees the caller rather than the subject they
+// act as; anonymous requests are not recorded.
+func UserDirectoryMiddleware(directory UserDirectory) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user := identity.User(c)
+			if user == identity.Anonymous {
+				return next(c)
+			}
+			err := directory.Seen(c.Request().Context(), user, identity.Groups(c), c.RealIP(), c.Request().UserAgent())
+			if errors.As(err, &users.ErrLocked{}) {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+			}
+			if err != nil {
+				log.Error("failed to check user directory", "user", user, "error", err)
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to check user"})
+			}
+			return next(c)
+		}
+	}
+}
@@ -10,5 +10,26 @@ func shouldSkip(c echo.Context) bool {
 	return strings.Contains(c.Path(), "api/v1/app") ||
 		c.Path() == "" ||
 		c.Path() == "/" ||
-		c.Path() == "/healthz"
+		c.Path() == "/healthz" ||
+		c.Path() == "/status" ||
+		c.Path() == "/api/v1/openapi.json" ||
+		c.Path() == "/api/v1/reports/handoff/stream" ||
+		strings.HasPrefix(c.Path(), "/api/v1/commands/executors") ||
+		strings.HasPrefix(c.Path(), "/api/v1/commands/approvals") ||
+		c.Path() == "/api/v1/commands/quota" ||
+		strings.HasPrefix(c.Path(), "/api/v1/commands/change-windows") ||
+		strings.HasPrefix(c.Path(), "/api/v1/admin") ||
+		strings.HasPrefix(c.Path(), "/api/v1/audit") ||
+		c.Path() == "/api/v1/capabilities" ||
+		strings.HasPrefix(c.Path(), "/api/v1/clusters") ||
+		strings.HasPrefix(c.Path(), "/api/v1/dashboards") ||
+		strings.HasPrefix(c.Path(), "/api/v1/exports") ||
+		strings.HasPrefix(c.Path(), "/api/v1/impersonations") ||
+		strings.HasPrefix(c.Path(), "/api/v1/kubechat") ||
+		strings.HasPrefix(c.Path(), "/api/v1/mfa") ||
+		strings.HasPrefix(c.Path(), "/api/v1/nlp") ||
+		strings.HasPrefix(c.Path(), "/api/v1/privacy") ||
+		strings.HasPrefix(c.Path(), "/api/v1/security/alerts") ||
+		strings.HasPrefix(c.Path(), "/api/v1/upgrades") ||
+		strings.HasPrefix(c.Path(), "/api/v1/watch")
 }
@@ -0,0 +1,499 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/config"
+	"github.com/pramodksahoo/kubechat/backend/container"
+	"github.com/pramodksahoo/kubechat/backend/handlers/portforward"
+	"github.com/pramodksahoo/kubechat/backend/handlers/workloads/deployments"
+	"github.com/pramodksahoo/kubechat/backend/internal/ai"
+	"github.com/pramodksahoo/kubechat/backend/internal/alerting"
+	"github.com/pramodksahoo/kubechat/backend/internal/anomaly"
+	alertingapi "github.com/pramodksahoo/kubechat/backend/internal/api/alerting"
+	auditapi "github.com/pramodksahoo/kubechat/backend/internal/api/audit"
+	chatapi "github.com/pramodksahoo/kubechat/backend/internal/api/chat"
+	commandsapi "github.com/pramodksahoo/kubechat/backend/internal/api/commands"
+	complianceapi "github.com/pramodksahoo/kubechat/backend/internal/api/compliance"
+	dashboardsapi "github.com/pramodksahoo/kubechat/backend/internal/api/dashboards"
+	helmapi "github.com/pramodksahoo/kubechat/backend/internal/api/helm"
+	iacapi "github.com/pramodksahoo/kubechat/backend/internal/api/iac"
+	loggingapi "github.com/pramodksahoo/kubechat/backend/internal/api/logging"
+	mfaapi "github.com/pramodksahoo/kubechat/backend/internal/api/mfa"
+	nlpapi "github.com/pramodksahoo/kubechat/backend/internal/api/nlp"
+	privacyapi "github.com/pramodksahoo/kubechat/backend/internal/api/privacy"
+	promptapi "github.com/pramodksahoo/kubechat/backend/internal/api/prompts"
+	planrepository "github.com/pramodksahoo/kubechat/backend/internal/api/repository"
+	retentionapi "github.com/pramodksahoo/kubechat/backend/internal/api/retention"
+	sandboxapi "github.com/pramodksahoo/kubechat/backend/internal/api/sandboxes"
+	usersapi "github.com/pramodksahoo/kubechat/backend/internal/api/users"
+	"github.com/pramodksahoo/kubechat/backend/internal/audit"
+	"github.com/pramodksahoo/kubechat/backend/internal/breaker"
+	"github.com/pramodksahoo/kubechat/backend/internal/capabilities"
+	"github.com/pramodksahoo/kubechat/backend/internal/chaos"
+	"github.com/pramodksahoo/kubechat/backend/internal/chat"
+	"github.com/pramodksahoo/kubechat/backend/internal/chatfiles"
+	"github.com/pramodksahoo/kubechat/backend/internal/clusters"
+	"github.com/pramodksahoo/kubechat/backend/internal/commands"
+	"github.com/pramodksahoo/kubechat/backend/internal/compliance"
+	"github.com/pramodksahoo/kubechat/backend/internal/correlation"
+	"github.com/pramodksahoo/kubechat/backend/internal/costs"
+	"github.com/pramodksahoo/kubechat/backend/internal/credentials"
+	"github.com/pramodksahoo/kubechat/backend/internal/customresources"
+	"github.com/pramodksahoo/kubechat/backend/internal/dashboards"
+	"github.com/pramodksahoo/kubechat/backend/internal/diagnostics"
+	"github.com/pramodksahoo/kubechat/backend/internal/exports"
+	"github.com/pramodksahoo/kubechat/backend/internal/health"
+	"github.com/pramodksahoo/kubechat/backend/internal/helm"
+	"github.com/pramodksahoo/kubechat/backend/internal/iac"
+	"github.com/pramodksahoo/kubechat/backend/internal/impersonation"
+	"github.com/pramodksahoo/kubechat/backend/internal/inventory"
+	"github.com/pramodksahoo/kubechat/backend/internal/logs"
+	"github.com/pramodksahoo/kubechat/backend/internal/mfa"
+	"github.com/pramodksahoo/kubechat/backend/internal/openapi"
+	"github.com/pramodksahoo/kubechat/backend/internal/privacy"
+	"github.com/pramodksahoo/kubechat/backend/internal/prometheus"
+	"github.com/pramodksahoo/kubechat/backend/internal/promptcache"
+	"github.com/pramodksahoo/kubechat/backend/internal/reports"
+	"github.com/pramodksahoo/kubechat/backend/internal/retention"
+	"github.com/pramodksahoo/kubechat/backend/internal/rightsizing"
+	"github.com/pramodksahoo/kubechat/backend/internal/roles"
+	"github.com/pramodksahoo/kubechat/backend/internal/sandbox"
+	"github.com/pramodksahoo/kubechat/backend/internal/security"
+	"github.com/pramodksahoo/kubechat/backend/internal/signing"
+	"github.com/pramodksahoo/kubechat/backend/internal/upgrades"
+	"github.com/pramodksahoo/kubechat/backend/internal/users"
+	"github.com/pramodksahoo/kubechat/backend/internal/watch"
+)
+
+const openAPIPath = "api/v1/openapi.json"
+
+var clusterQuery = []string{"config", "cluster"}
+
+type successResponse struct {
+	Success any `json:"success"`
+}
+
+// chatfilesRejection is the body returned when an attachment fails its content scan.
+type chatfilesRejection struct {
+	Error    string              `json:"error"`
+	Findings []chatfiles.Finding `json:"findings"`
+}
+
+// guardrailRejection is the body returned when the NLP guardrail refuses a prompt or its plan.
+type guardrailRejection struct {
+	Error   string   `json:"error"`
+	Reasons []string `json:"reasons"`
+}
+
+// budgetRejection is the body returned when a prompt is refused past a hard model budget limit.
+type budgetRejection struct {
+	Error    string  `json:"error"`
+	Budget   string  `json:"budget"`
+	Spent    float64 `json:"spent"`
+	Limit    float64 `json:"limit"`
+	Currency string  `json:"currency"`
+}
+
+// signatureRejection is the body returned when a sensitive admin request is not validly signed.
+type signatureRejection struct {
+	Error             string `json:"error"`
+	SignatureRequired bool   `json:"signatureRequired"`
+}
+
+type usageReport struct {
+	Usage []ai.UsageTotals `json:"usage"`
+}
+
+type resourceRef struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+type deleteFailures struct {
+	Failures []struct {
+		Namespace string `json:"namespace"`
+		Name      string `json:"name"`
+		Message   string `json:"message"`
+	} `json:"failures"`
+}
+
+type decisionRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// approvalLinkResponses documents the pages approval links answer browsers with, and the JSON
+// bodies API clients get instead.
+func approvalLinkResponses(body any) map[int]any {
+	responses := map[int]any{http.StatusOK: openapi.Page{JSON: body}}
+	for _, status := range []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusGone, http.StatusInternalServerError} {
+		responses[status] = openapi.Page{JSON: openapi.ErrorResponse{}}
+	}
+	return responses
+}
+
+type commandQuotaResponse struct {
+	Usage   commands.CommandUsage      `json:"usage"`
+	Freezes []commands.NamespaceFreeze `json:"freezes"`
+}
+
+type providersResponse struct {
+	Providers []ai.ProviderInfo `json:"providers"`
+}
+
+type modelsResponse struct {
+	Providers []ai.ProviderModels `json:"providers"`
+}
+
+// apiOperations annotates every route that is not covered by resourceOperation.
+var apiOperations = []struct {
+	method, path string
+	op           openapi.Operation
+}{
+	{http.MethodGet, "/healthz", openapi.Operation{Summary: "Liveness probe", Tags: []string{"system"}, Responses: map[int]any{http.StatusOK: openapi.PlainText{}}}},
+	{http.MethodGet, "/metrics", openapi.Operation{Summary: "Prometheus metrics", Tags: []string{"system"}, Responses: map[int]any{http.StatusOK: openapi.PlainText{}}}},
+	{http.MethodGet, "/status", openapi.Operation{Summary: "Public component status and uptime", Tags: []string{"system"}, Responses: map[int]any{http.StatusOK: health.Summary{}}}},
+	{http.MethodGet, "api/v1/capabilities", openapi.Operation{Summary: "Optional subsystems and, for disabled ones, why and how to enable them", Tags: []string{"system"}, Responses: map[int]any{http.StatusOK: map[string][]capabilities.Status{}}}},
+	{http.MethodGet, openAPIPath, openapi.Operation{Summary: "OpenAPI document for this server", Tags: []string{"system"}, Responses: map[int]any{http.StatusOK: json.RawMessage{}}}},
+	{http.MethodGet, "api/v1/security/posture", openapi.Operation{Summary: "Security posture the server started with", Tags: []string{"security"}, Responses: map[int]any{http.StatusOK: security.Report{}}}},
+	{http.MethodGet, "api/v1/security/posture/history", openapi.Operation{Summary: "Security posture reports of earlier starts, most recent first", Tags: []string{"security"}, Query: []string{"limit"}, Responses: map[int]any{http.StatusOK: map[string][]security.Report{}}}},
+
+	{http.MethodGet, "api/v1/nlp/providers", openapi.Operation{Summary: "Model providers in fallback order with token usage", Tags: []string{"nlp"}, Responses: map[int]any{http.StatusOK: providersResponse{}}}},
+	{http.MethodGet, "api/v1/nlp/providers/weights", openapi.Operation{Summary: "Live adaptive routing weights of the model providers with the latency, error rate and cost behind them", Tags: []string{"nlp"}, Responses: map[int]any{http.StatusOK: ai.AdaptiveStatus{}}}},
+	{http.MethodGet, "api/v1/nlp/models", openapi.Operation{Summary: "Models each provider serves with context window, output limit and function-calling support", Tags: []string{"nlp"}, Responses: map[int]any{http.StatusOK: modelsResponse{}}}},
+	{http.MethodGet, "api/v1/nlp/providers/:name/canary", openapi.Operation{Summary: "Compare a provider's baseline and canary models", Tags: []string{"nlp"}, Responses: map[int]any{http.StatusOK: ai.CanaryStatus{}}}},
+	{http.MethodPut, "api/v1/nlp/providers/:name/canary", openapi.Operation{Summary: "Start or adjust a canary model rollout", Tags: []string{"nlp"}, Request: ai.CanaryConfig{}, Responses: map[int]any{http.StatusOK: ai.CanaryStatus{}}}},
+	{http.MethodPost, "api/v1/nlp/providers/:name/canary/promote", openapi.Operation{Summary: "Promote the canary model to the provider default", Tags: []string{"nlp"}, Responses: map[int]any{http.StatusOK: ai.CanaryStatus{}}}},
+	{http.MethodPost, "api/v1/nlp/providers/:name/canary/abort", openapi.Operation{Summary: "Abort a canary rollout", Tags: []string{"nlp"}, Responses: map[int]any{http.StatusOK: ai.CanaryStatus{}}}},
+	{http.MethodGet, "api/v1/nlp/cache/preference", openapi.Operation{Summary: "Whether the caller's prompts are served from the prompt cache", Tags: []string{"nlp"}, Responses: map[int]any{http.StatusOK: promptcache.Preference{}}}},
+	{http.MethodPut, "api/v1/nlp/cache/preference", openapi.Operation{Summary: "Opt in to or out of the prompt cache", Tags: []string{"nlp"}, Request: promptcache.Preference{}, Responses: map[int]any{http.StatusOK: promptcache.Preference{}}}},
+	{http.MethodGet, "api/v1/nlp/quota", openapi.Operation{Summary: "The caller's model token balance and throttling state", Tags: []string{"nlp"}, Responses: map[int]any{http.StatusOK: ai.QuotaBalance{}}}},
+	{http.MethodGet, "api/v1/admin/nlp/quota", openapi.Operation{Summary: "Model quota policies and every user's balance", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: ai.QuotaOverview{}}}},
+	{http.MethodPut, "api/v1/admin/nlp/quota", openapi.Operation{Summary: "Set the default model quota bucket size and refill rate", Tags: []string{"admin"}, Request: ai.QuotaPolicy{}, Responses: map[int]any{http.StatusOK: ai.QuotaOverview{}}}},
+	{http.MethodPut, "api/v1/admin/nlp/quota/users/:user", openapi.Operation{Summary: "Override the model quota of one user", Tags: []string{"admin"}, Request: ai.QuotaPolicy{}, Responses: map[int]any{http.StatusOK: ai.QuotaBalance{}}}},
+	{http.MethodDelete, "api/v1/admin/nlp/quota/users/:user", openapi.Operation{Summary: "Return a user to the default model quota", Tags: []string{"admin"}, Responses: map[int]any{http.StatusNoContent: nil}}},
+	{http.MethodGet, "api/v1/nlp/keys", openapi.Operation{Summary: "The model provider API keys the caller stored for their own requests", Tags: []string{"nlp"}, Responses: map[int]any{http.StatusOK: map[string][]credentials.Key{}}}},
+	{http.MethodGet, "api/v1/nlp/keys/backends", openapi.Operation{Summary: "Where the caller's own API keys may be kept", Tags: []string{"nlp"}, Responses: map[int]any{http.StatusOK: map[string][]string{}}}},
+	{http.MethodPut, "api/v1/nlp/keys/:provider", openapi.Operation{Summary: "Store the caller's own API key for a model provider, tried before KubeChat's", Tags: []string{"nlp"}, Request: credentials.KeyRequest{}, Responses: map[int]any{http.StatusOK: credentials.Key{}, http.StatusBadRequest: nil, http.StatusForbidden: nil}}},
+	{http.MethodDelete, "api/v1/nlp/keys/:provider", openapi.Operation{Summary: "Remove the caller's own API key for a model provider", Tags: []string{"nlp"}, Responses: map[int]any{http.StatusNoContent: nil, http.StatusForbidden: nil, http.StatusNotFound: nil}}},
+	{http.MethodPost, "api/v1/admin/nlp/keys/migrate", openapi.Operation{Summary: "Move every user's own API keys from one secret backend to another", Tags: []string{"admin"}, Request: credentials.MigrationRequest{}, Responses: map[int]any{http.StatusOK: credentials.Migration{}, http.StatusBadRequest: nil}}},
+	{http.MethodPost, "api/v1/admin/nlp/keys/rewrap", openapi.Operation{Summary: "Re-wrap the data keys of users' own API keys with the current KMS key version", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: credentials.Rewrap{}}}},
+	{http.MethodPost, "api/v1/admin/nlp/keys/validate", openapi.Operation{Summary: "Check users' own API keys with their providers and summarize their strength and issues; large batches run as a job", Tags: []string{"admin"}, Request: credentials.ValidationRequest{}, Responses: map[int]any{http.StatusOK: credentials.ValidationJob{}, http.StatusAccepted: credentials.ValidationJob{}, http.StatusBadRequest: nil}}},
+	{http.MethodGet, "api/v1/admin/nlp/keys/validate/:id", openapi.Operation{Summary: "A validation of users' own API keys, with its summary once completed", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: credentials.ValidationJob{}, http.StatusNotFound: nil}}},
+	{http.MethodGet, "api/v1/admin/nlp/keys/policies", openapi.Operation{Summary: "The policies users' own API keys must comply with, per provider", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: map[string][]credentials.Policy{}}}},
+	{http.MethodPut, "api/v1/admin/nlp/keys/policies/:provider", openapi.Operation{Summary: "Set the policy for a provider's keys, or * for providers without one", Tags: []string{"admin"}, Request: credentials.Policy{}, Responses: map[int]any{http.StatusOK: credentials.Policy{}, http.StatusBadRequest: nil}}},
+	{http.MethodDelete, "api/v1/admin/nlp/keys/policies/:provider", openapi.Operation{Summary: "Remove the policy for a provider's keys", Tags: []string{"admin"}, Responses: map[int]any{http.StatusNoContent: nil, http.StatusNotFound: nil}}},
+	{http.MethodGet, "api/v1/nlp/usage", openapi.Operation{Summary: "The caller's model token usage per session and model", Tags: []string{"nlp"}, Query: []string{"session", "model"}, Responses: map[int]any{http.StatusOK: usageReport{}}}},
+	{http.MethodGet, "api/v1/admin/nlp/usage", openapi.Operation{Summary: "Model token usage per user, session and model", Tags: []string{"admin"}, Query: []string{"user", "session", "model"}, Responses: map[int]any{http.StatusOK: usageReport{}}}},
+	{http.MethodGet, "api/v1/admin/nlp/billing/:month/:year", openapi.Operation{Summary: "A calendar month of model usage and cost per user and model", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: costs.Bill{}, http.StatusBadRequest: nil}}},
+	{http.MethodPost, "api/v1/admin/nlp/billing/backfill", openapi.Operation{Summary: "Rebuild the daily model usage rollups of a range of days", Tags: []string{"admin"}, Request: nlpapi.BackfillRequest{}, Responses: map[int]any{http.StatusOK: costs.Backfill{}, http.StatusBadRequest: nil}}},
+	{http.MethodGet, "api/v1/admin/nlp/prices", openapi.Operation{Summary: "Model prices in effect and every published price book version", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: costs.PriceBookOverview{}}}},
+	{http.MethodPost, "api/v1/admin/nlp/prices", openapi.Operation{Summary: "Publish a version of the model prices", Tags: []string{"admin"}, Request: costs.PriceVersionRequest{}, Responses: map[int]any{http.StatusCreated: costs.PriceVersion{}, http.StatusBadRequest: nil}}},
+	{http.MethodGet, "api/v1/admin/nlp/prices/versions/:version", openapi.Operation{Summary: "One published version of the model prices", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: costs.PriceVersion{}, http.StatusNotFound: nil}}},
+	{http.MethodGet, "api/v1/admin/nlp/budgets", openapi.Operation{Summary: "Monthly model budgets with this month's spend", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: map[string][]costs.BudgetStatus{}}}},
+	{http.MethodPut, "api/v1/admin/nlp/budgets/:name", openapi.Operation{Summary: "Create or replace a monthly model budget", Tags: []string{"admin"}, Request: costs.BudgetRequest{}, Responses: map[int]any{http.StatusOK: costs.Budget{}, http.StatusBadRequest: nil}}},
+	{http.MethodDelete, "api/v1/admin/nlp/budgets/:name", openapi.Operation{Summary: "Delete a monthly model budget", Tags: []string{"admin"}, Responses: map[int]any{http.StatusNoContent: nil, http.StatusNotFound: nil}}},
+	{http.MethodGet, "api/v1/admin/nlp/allocation-rules", openapi.Operation{Summary: "Rules charging users' model spend to departments and projects", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: map[string][]costs.AllocationRule{}}}},
+	{http.MethodPut, "api/v1/admin/nlp/allocation-rules/:name", openapi.Operation{Summary: "Create or replace an allocation rule", Tags: []string{"admin"}, Request: costs.AllocationRuleRequest{}, Responses: map[int]any{http.StatusOK: costs.AllocationRule{}, http.StatusBadRequest: nil}}},
+	{http.MethodDelete, "api/v1/admin/nlp/allocation-rules/:name", openapi.Operation{Summary: "Delete an allocation rule", Tags: []string{"admin"}, Responses: map[int]any{http.StatusNoContent: nil, http.StatusNotFound: nil}}},
+	{http.MethodGet, "api/v1/admin/nlp/chargeback", openapi.Operation{Summary: "Monthly chargeback reports generated so far, newest first", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: map[string][]costs.ChargebackSummary{}}}},
+	{http.MethodGet, "api/v1/admin/nlp/chargeback/:month/:year", openapi.Operation{Summary: "A month of model spend charged to departments, projects or users, as JSON or CSV", Tags: []string{"admin"}, Query: []string{"group_by", "format"}, Responses: map[int]any{http.StatusOK: costs.ChargebackReport{}, http.StatusBadRequest: nil}}},
+	{http.MethodPost, "api/v1/nlp/attachments", openapi.Operation{Summary: "Attach a manifest or small text file for prompts to reference", Tags: []string{"nlp"}, Form: []string{"file"}, Responses: map[int]any{http.StatusCreated: chatfiles.File{}, http.StatusUnprocessableEntity: chatfilesRejection{}}}},
+	{http.MethodGet, "api/v1/nlp/attachments", openapi.Operation{Summary: "The caller's chat attachments that have not expired", Tags: []string{"nlp"}, Responses: map[int]any{http.StatusOK: map[string][]chatfiles.File{}}}},
+	{http.MethodGet, "api/v1/nlp/attachments/:id", openapi.Operation{Summary: "Get a chat attachment", Tags: []string{"nlp"}, Responses: map[int]any{http.StatusOK: chatfiles.File{}}}},
+	{http.MethodGet, "api/v1/nlp/attachments/:id/content", openapi.Operation{Summary: "Download a chat attachment", Tags: []string{"nlp"}, Responses: map[int]any{http.StatusOK: openapi.Binary{}}}},
+	{http.MethodDelete, "api/v1/nlp/attachments/:id", openapi.Operation{Summary: "Delete a chat attachment before it expires", Tags: []string{"nlp"}, Responses: map[int]any{http.StatusNoContent: nil}}},
+	{http.MethodGet, "api/v1/nlp/sessions", openapi.Operation{Summary: "The caller's chat sessions, most recently active first", Tags: []string{"nlp"}, Responses: map[int]any{http.StatusOK: map[string][]chat.Session{}}}},
+	{http.MethodPost, "api/v1/nlp/sessions", openapi.Operation{Summary: "Start a chat session for prompts to continue", Tags: []string{"nlp"}, Request: chatapi.CreateSessionRequest{}, Responses: map[int]any{http.StatusCreated: chat.Session{}}}},
+	{http.MethodGet, "api/v1/nlp/sessions/:id", openapi.Operation{Summary: "Get a chat session with its messages to resume it", Tags: []string{"nlp"}, Responses: map[int]any{http.StatusOK: chat.Conversation{}}}},
+	{http.MethodDelete, "api/v1/nlp/sessions/:id", openapi.Operation{Summary: "Delete a chat session and its messages", Tags: []string{"nlp"}, Responses: map[int]any{http.StatusNoContent: nil}}},
+	{http.MethodGet, "api/v1/nlp/sessions/:id/context", openapi.Operation{Summary: "The cluster, namespace and resources a chat session currently refers to", Tags: []string{"nlp"}, Responses: map[int]any{http.StatusOK: map[string]*chat.ActiveContext{}}}},
+	{http.MethodDelete, "api/v1/nlp/sessions/:id/context", openapi.Operation{Summary: "Clear a chat session's context so follow-up prompts start afresh", Tags: []string{"nlp"}, Responses: map[int]any{http.StatusNoContent: nil}}},
+	{http.MethodGet, "api/v1/nlp/retention", openapi.Operation{Summary: "How long the caller's idle chat sessions are kept", Tags: []string{"nlp"}, Responses: map[int]any{http.StatusOK: chat.Retention{}}}},
+	{http.MethodPut, "api/v1/nlp/retention", openapi.Operation{Summary: "Change how long the caller's idle chat sessions are kept", Tags: []string{"nlp"}, Request: chatapi.RetentionRequest{}, Responses: map[int]any{http.StatusOK: chat.Retention{}}}},
+	{http.MethodGet, "api/v1/admin/breakers", openapi.Operation{Summary: "Circuit breakers of every upstream", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: map[string][]breaker.Status{}}}},
+	{http.MethodGet, "api/v1/admin/breakers/:upstream", openapi.Operation{Summary: "Circuit breaker of one upstream", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: breaker.Status{}}}},
+	{http.MethodPost, "api/v1/admin/breakers/:upstream/open", openapi.Operation{Summary: "Hold an upstream's circuit breaker open until it is reset", Tags: []string{"admin"}, Request: breaker.OverrideRequest{}, Responses: map[int]any{http.StatusOK: breaker.Status{}}}},
+	{http.MethodPost, "api/v1/admin/breakers/:upstream/reset", openapi.Operation{Summary: "Close an upstream's circuit breaker and forget its failures", Tags: []string{"admin"}, Request: breaker.OverrideRequest{}, Responses: map[int]any{http.StatusOK: breaker.Status{}}}},
+	{http.MethodGet, "api/v1/admin/chaos", openapi.Operation{Summary: "Running and recently finished chaos experiments", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: map[string][]chaos.Experiment{}}}},
+	{http.MethodPost, "api/v1/admin/chaos", openapi.Operation{Summary: "Simulate provider failures, provider latency or cluster API throttling for a bounded window", Tags: []string{"admin"}, Request: chaos.ExperimentInput{}, Responses: map[int]any{http.StatusCreated: chaos.Experiment{}}}},
+	{http.MethodDelete, "api/v1/admin/chaos/:id", openapi.Operation{Summary: "Stop a chaos experiment early", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: chaos.Experiment{}}}},
+	{http.MethodGet, "api/v1/admin/log-levels", openapi.Operation{Summary: "The log level of every module", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: map[string]map[string]string{}}}},
+	{http.MethodPut, "api/v1/admin/log-levels/:module", openapi.Operation{Summary: "Change a module's log level until the server restarts", Tags: []string{"admin"}, Request: loggingapi.LevelRequest{}, Responses: map[int]any{http.StatusOK: map[string]map[string]string{}}}},
+	{http.MethodPost, "api/v1/admin/impersonations", openapi.Operation{Summary: "Request to impersonate a user, with their consent or by breaking glass; breaking glass must be signed", Tags: []string{"admin"}, Request: impersonation.RequestInput{}, Responses: map[int]any{http.StatusCreated: impersonation.Session{}, http.StatusUnauthorized: signatureRejection{}}}},
+	{http.MethodGet, "api/v1/admin/impersonations", openapi.Operation{Summary: "All impersonation sessions", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: map[string][]impersonation.Session{}}}},
+	{http.MethodGet, "api/v1/impersonations", openapi.Operation{Summary: "Impersonation sessions the caller requested or was asked to consent to", Tags: []string{"impersonation"}, Responses: map[int]any{http.StatusOK: map[string][]impersonation.Session{}}}},
+	{http.MethodGet, "api/v1/impersonations/audit", openapi.Operation{Summary: "Impersonation audit log naming both the admin and the user", Tags: []string{"impersonation"}, Query: []string{"session", "user", "limit"}, Responses: map[int]any{http.StatusOK: map[string][]impersonation.AuditEntry{}}}},
+	{http.MethodPost, "api/v1/impersonations/:id/consent", openapi.Operation{Summary: "Consent to a pending impersonation request", Tags: []string{"impersonation"}, Responses: map[int]any{http.StatusOK: impersonation.Session{}}}},
+	{http.MethodPost, "api/v1/impersonations/:id/deny", openapi.Operation{Summary: "Deny a pending impersonation request", Tags: []string{"impersonation"}, Responses: map[int]any{http.StatusOK: impersonation.Session{}}}},
+	{http.MethodDelete, "api/v1/impersonations/:id", openapi.Operation{Summary: "End an impersonation session", Tags: []string{"impersonation"}, Responses: map[int]any{http.StatusOK: impersonation.Session{}}}},
+	{http.MethodGet, "api/v1/mfa", openapi.Operation{Summary: "The caller's MFA enrollment and current step-up assertion", Tags: []string{"mfa"}, Responses: map[int]any{http.StatusOK: mfa.Status{}}}},
+	{http.MethodPost, "api/v1/mfa/enrollment", openapi.Operation{Summary: "Start enrolling a TOTP authenticator; the secret is shown once", Tags: []string{"mfa"}, Responses: map[int]any{http.StatusCreated: mfa.Secret{}}}},
+	{http.MethodPost, "api/v1/mfa/enrollment/confirm", openapi.Operation{Summary: "Confirm the enrollment with a code from the authenticator", Tags: []string{"mfa"}, Request: mfaapi.CodeRequest{}, Responses: map[int]any{http.StatusOK: mfa.Enrollment{}}}},
+	{http.MethodDelete, "api/v1/mfa/enrollment", openapi.Operation{Summary: "Remove the caller's authenticator; requires a fresh verification", Tags: []string{"mfa"}, Responses: map[int]any{http.StatusNoContent: nil}}},
+	{http.MethodPost, "api/v1/mfa/verify", openapi.Operation{Summary: "Verify a code to satisfy step-up checks on high-risk actions such as approving dangerous commands", Tags: []string{"mfa"}, Request: mfaapi.CodeRequest{}, Responses: map[int]any{http.StatusOK: mfa.Assertion{}}}},
+	{http.MethodGet, "api/v1/mfa/audit", openapi.Operation{Summary: "The caller's MFA audit entries, including step-up checks", Tags: []string{"mfa"}, Query: []string{"limit"}, Responses: map[int]any{http.StatusOK: map[string][]mfa.AuditEntry{}}}},
+	{http.MethodGet, "api/v1/admin/mfa/audit", openapi.Operation{Summary: "MFA audit log", Tags: []string{"admin"}, Query: []string{"user", "limit"}, Responses: map[int]any{http.StatusOK: map[string][]mfa.AuditEntry{}}}},
+	{http.MethodDelete, "api/v1/admin/mfa/enrollments/:user", openapi.Operation{Summary: "Reset a user's authenticator, such as after a lost device", Tags: []string{"admin"}, Responses: map[int]any{http.StatusNoContent: nil}}},
+	{http.MethodGet, "api/v1/kubechat/roles", openapi.Operation{Summary: "KubeChat roles and the permissions each grants", Tags: []string{"roles"}, Responses: map[int]any{http.StatusOK: []roles.RoleInfo{}}}},
+	{http.MethodGet, "api/v1/kubechat/roles/me", openapi.Operation{Summary: "The caller's role bindings and cluster-wide permissions", Tags: []string{"roles"}, Responses: map[int]any{http.StatusOK: roles.Access{}}}},
+	{http.MethodGet, "api/v1/admin/roles/bindings", openapi.Operation{Summary: "All role bindings", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: []roles.Binding{}}}},
+	{http.MethodPost, "api/v1/admin/roles/bindings", openapi.Operation{Summary: "Bind a role to a user or group, cluster-wide or in one namespace", Tags: []string{"admin"}, Request: roles.BindingRequest{}, Responses: map[int]any{http.StatusCreated: roles.Binding{}}}},
+	{http.MethodDelete, "api/v1/admin/roles/bindings/:id", openapi.Operation{Summary: "Remove a role binding", Tags: []string{"admin"}, Responses: map[int]any{http.StatusNoContent: nil}}},
+	{http.MethodGet, "api/v1/admin/roles/namespaces", openapi.Operation{Summary: "The configured default namespaces and the namespace rules that allow or deny namespaces to users, role holders or everyone", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: roles.NamespacePolicy{}}}},
+	{http.MethodPost, "api/v1/admin/roles/namespaces", openapi.Operation{Summary: "Allow or deny the namespaces matching a pattern such as kubechat-* to a user, the holders of a role, or everyone", Tags: []string{"admin"}, Request: roles.NamespaceRuleRequest{}, Responses: map[int]any{http.StatusCreated: roles.NamespaceRule{}}}},
+	{http.MethodDelete, "api/v1/admin/roles/namespaces/:id", openapi.Operation{Summary: "Remove a namespace rule", Tags: []string{"admin"}, Responses: map[int]any{http.StatusNoContent: nil}}},
+	{http.MethodPost, "api/v1/admin/roles/reload", openapi.Operation{Summary: "Reload role bindings and namespace rules changed on another replica or in the database", Tags: []string{"admin"}, Responses: map[int]any{http.StatusNoContent: nil}}},
+	{http.MethodGet, "api/v1/admin/users", openapi.Operation{Summary: "Users KubeChat has seen, searched by name with ?q= and narrowed with ?locked=", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: map[string][]users.User{}}}},
+	{http.MethodGet, "api/v1/admin/users/:user", openapi.Operation{Summary: "A user with their last login, roles and active sessions", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: users.Details{}}}},
+	{http.MethodPost, "api/v1/admin/users/:user/lock", openapi.Operation{Summary: "Lock a user out of KubeChat", Tags: []string{"admin"}, Request: usersapi.LockRequest{}, Responses: map[int]any{http.StatusOK: users.User{}}}},
+	{http.MethodPost, "api/v1/admin/users/:user/unlock", openapi.Operation{Summary: "Unlock a user", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: users.User{}}}},
+	{http.MethodPost, "api/v1/admin/users/:user/reset", openapi.Operation{Summary: "Reset a user's second factor and end their sessions; passwords are reset at the identity provider", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: users.User{}}}},
+	{http.MethodPost, "api/v1/admin/users/:user/roles", openapi.Operation{Summary: "Bind a role to a user", Tags: []string{"admin"}, Request: usersapi.RoleRequest{}, Responses: map[int]any{http.StatusCreated: roles.Binding{}}}},
+	{http.MethodDelete, "api/v1/admin/users/:user/roles/:id", openapi.Operation{Summary: "Remove one of a user's role bindings", Tags: []string{"admin"}, Responses: map[int]any{http.StatusNoContent: nil}}},
+	{http.MethodPost, "api/v1/prompts", openapi.Operation{Summary: "Generate a plan from a prompt", Tags: []string{"plans"}, Request: promptapi.PromptRequest{}, Responses: map[int]any{http.StatusCreated: promptapi.PromptResponse{}, http.StatusPaymentRequired: budgetRejection{}, http.StatusUnprocessableEntity: guardrailRejection{}}}},
+	{http.MethodGet, "api/v1/plans/:id", openapi.Operation{Summary: "Get a plan", Tags: []string{"plans"}, Responses: map[int]any{http.StatusOK: planrepository.PlanRecord{}}}},
+	{http.MethodPatch, "api/v1/plans/:id", openapi.Operation{Summary: "Update a plan", Tags: []string{"plans"}, Request: promptapi.PlanUpdateRequest{}, Responses: map[int]any{http.StatusOK: planrepository.PlanRecord{}}}},
+	{http.MethodGet, "api/v1/plans/:id/stream", openapi.Operation{Summary: "Stream plan updates", Tags: []string{"plans"}, Responses: map[int]any{http.StatusOK: openapi.EventStream{}}}},
+
+	{http.MethodPost, "api/v1/app/apply", openapi.Operation{Summary: "Apply YAML manifests", Tags: []string{"app"}, Query: clusterQuery, Form: []string{"yaml"}, Responses: map[int]any{http.StatusOK: successResponse{}}}},
+	{http.MethodGet, "api/v1/app/config", openapi.Operation{Summary: "Application configuration", Tags: []string{"app"}, Responses: map[int]any{http.StatusOK: config.AppConfig{}}}},
+	{http.MethodPost, "api/v1/app/config/kubeconfigs", openapi.Operation{Summary: "Upload a kubeconfig", Tags: []string{"app"}, Form: []string{"file"}, Responses: map[int]any{http.StatusOK: successResponse{}}}},
+	{http.MethodPost, "api/v1/app/config/kubeconfigs-bearer", openapi.Operation{Summary: "Add a cluster with a bearer token", Tags: []string{"app"}, Form: []string{"serverIP", "name", "token"}, Responses: map[int]any{http.StatusOK: successResponse{}}}},
+	{http.MethodPost, "api/v1/app/config/kubeconfigs-certificate", openapi.Operation{Summary: "Add a cluster with client certificates", Tags: []string{"app"}, Form: []string{"serverIP", "name", "clientCertData", "clientKeyData"}, Responses: map[int]any{http.StatusOK: successResponse{}}}},
+	{http.MethodGet, "api/v1/app/config/reload", openapi.Operation{Summary: "Reload kubeconfigs", Tags: []string{"app"}, Responses: map[int]any{http.StatusTemporaryRedirect: nil}}},
+	{http.MethodDelete, "api/v1/app/config/kubeconfigs/:uuid", openapi.Operation{Summary: "Remove an uploaded kubeconfig", Tags: []string{"app"}, Responses: map[int]any{http.StatusOK: successResponse{}}}},
+
+	{http.MethodGet, "api/v1/portforwards", openapi.Operation{Summary: "Stream active port forwards", Tags: []string{"portforwards"}, Query: clusterQuery, Responses: map[int]any{http.StatusOK: openapi.EventStream{}}}},
+	{http.MethodPost, "api/v1/portforwards", openapi.Operation{Summary: "Start a port forward", Tags: []string{"portforwards"}, Query: clusterQuery, Request: portforward.PortForwardRequest{}, Responses: map[int]any{http.StatusOK: struct {
+		ID        string `json:"id"`
+		LocalPort int    `json:"localPort"`
+	}{}}}},
+	{http.MethodDelete, "api/v1/portforwards", openapi.Operation{Summary: "Stop port forwards", Tags: []string{"portforwards"}, Query: clusterQuery, Request: []struct {
+		ID string `json:"id"`
+	}{}, Responses: map[int]any{http.StatusOK: deleteFailures{}}}},
+
+	{http.MethodGet, "api/v1/changes", openapi.Operation{Summary: "Resource changes within a window", Tags: []string{"changes"}, Query: []string{"config", "cluster", "since"}, Responses: map[int]any{http.StatusOK: inventory.Changelog{}}}},
+	{http.MethodGet, "api/v1/kubernetes/restarts", openapi.Operation{Summary: "Containers with high restart counts, their last termination reason and trend", Tags: []string{"changes"}, Query: []string{"config", "cluster", "namespace", "min", "window"}, Responses: map[int]any{http.StatusOK: inventory.RestartReport{}, http.StatusNotModified: nil}}},
+	{http.MethodGet, "api/v1/kubernetes/rightsizing", openapi.Operation{Summary: "CPU and memory request recommendations per deployment from recorded usage, with the patch command applying them", Tags: []string{"changes"}, Query: []string{"config", "cluster", "namespace", "deployment", "window"}, Responses: map[int]any{http.StatusOK: rightsizing.Report{}, http.StatusNotModified: nil, http.StatusServiceUnavailable: capabilities.Response{}}}},
+	{http.MethodGet, "api/v1/logs", openapi.Operation{Summary: "Search pod logs with the log query DSL", Tags: []string{"logs"}, Query: []string{"config", "cluster", "namespace", "pod", "selector", "q"}, Responses: map[int]any{http.StatusOK: logs.Result{}}}},
+	{http.MethodGet, "api/v1/logs/stream", openapi.Operation{Summary: "Follow pod logs as they are written", Tags: []string{"logs"}, Query: []string{"config", "cluster", "namespace", "pod", "selector", "container", "q", "since", "tail"}, Responses: map[int]any{http.StatusOK: openapi.EventStream{}}}},
+	{http.MethodGet, "api/v1/logs/ws", openapi.Operation{Summary: "Follow pod logs over a WebSocket", Tags: []string{"logs"}, Query: []string{"config", "cluster", "namespace", "pod", "selector", "container", "q", "since", "tail"}, Responses: map[int]any{http.StatusSwitchingProtocols: nil}}},
+	{http.MethodGet, "api/v1/watch", openapi.Operation{Summary: "Subscribe to resource add, update and delete events over a WebSocket", Tags: []string{"watch"}, Responses: map[int]any{http.StatusSwitchingProtocols: nil}}},
+	{http.MethodGet, "api/v1/watch/subscriptions", openapi.Operation{Summary: "The caller's open watch subscriptions", Tags: []string{"watch"}, Responses: map[int]any{http.StatusOK: map[string][]watch.Subscription{}}}},
+	{http.MethodGet, "api/v1/reports/handoff", openapi.Operation{Summary: "On-call handoff report", Tags: []string{"reports"}, Query: []string{"config", "cluster", "workspace", "window", "latest"}, Responses: map[int]any{http.StatusOK: reports.HandoffReport{}}}},
+	{http.MethodGet, "api/v1/reports/handoff/stream", openapi.Operation{Summary: "Stream scheduled handoff reports", Tags: []string{"reports"}, Responses: map[int]any{http.StatusOK: openapi.EventStream{}}}},
+
+	{http.MethodGet, "api/v1/dashboards/sources", openapi.Operation{Summary: "Data sources available to dashboard widgets", Tags: []string{"dashboards"}, Responses: map[int]any{http.StatusOK: []dashboards.SourceInfo{}}}},
+	{http.MethodGet, "api/v1/dashboards/widgets", openapi.Operation{Summary: "List widgets visible in the workspace", Tags: []string{"dashboards"}, Responses: map[int]any{http.StatusOK: []dashboards.Widget{}}}},
+	{http.MethodPost, "api/v1/dashboards/widgets", openapi.Operation{Summary: "Save a query as a widget", Tags: []string{"dashboards"}, Request: dashboardsapi.WidgetRequest{}, Responses: map[int]any{http.StatusCreated: dashboards.Widget{}}}},
+	{http.MethodGet, "api/v1/dashboards/widgets/:id", openapi.Operation{Summary: "Get a widget", Tags: []string{"dashboards"}, Responses: map[int]any{http.StatusOK: dashboards.Widget{}}}},
+	{http.MethodPut, "api/v1/dashboards/widgets/:id", openapi.Operation{Summary: "Update a widget", Tags: []string{"dashboards"}, Request: dashboardsapi.WidgetRequest{}, Responses: map[int]any{http.StatusOK: dashboards.Widget{}}}},
+	{http.MethodDelete, "api/v1/dashboards/widgets/:id", openapi.Operation{Summary: "Delete a widget", Tags: []string{"dashboards"}, Responses: map[int]any{http.StatusNoContent: nil}}},
+	{http.MethodGet, "api/v1/dashboards/widgets/:id/data", openapi.Operation{Summary: "Run a widget's query; var.<name> parameters set variables", Tags: []string{"dashboards"}, Responses: map[int]any{http.StatusOK: dashboards.PanelResult{}}}},
+	{http.MethodGet, "api/v1/dashboards", openapi.Operation{Summary: "List dashboards visible in the workspace", Tags: []string{"dashboards"}, Responses: map[int]any{http.StatusOK: []dashboards.Dashboard{}}}},
+	{http.MethodPost, "api/v1/dashboards", openapi.Operation{Summary: "Create a dashboard", Tags: []string{"dashboards"}, Request: dashboardsapi.DashboardRequest{}, Responses: map[int]any{http.StatusCreated: dashboards.Dashboard{}}}},
+	{http.MethodGet, "api/v1/dashboards/:id", openapi.Operation{Summary: "Get a dashboard", Tags: []string{"dashboards"}, Responses: map[int]any{http.StatusOK: dashboards.Dashboard{}}}},
+	{http.MethodPut, "api/v1/dashboards/:id", openapi.Operation{Summary: "Update a dashboard", Tags: []string{"dashboards"}, Request: dashboardsapi.DashboardRequest{}, Responses: map[int]any{http.StatusOK: dashboards.Dashboard{}}}},
+	{http.MethodDelete, "api/v1/dashboards/:id", openapi.Operation{Summary: "Delete a dashboard", Tags: []string{"dashboards"}, Responses: map[int]any{http.StatusNoContent: nil}}},
+	{http.MethodGet, "api/v1/dashboards/:id/data", openapi.Operation{Summary: "Render every panel of a dashboard; var.<name> parameters override variables", Tags: []string{"dashboards"}, Responses: map[int]any{http.StatusOK: dashboards.Rendered{}}}},
+	{http.MethodGet, "api/v1/clusters", openapi.Operation{Summary: "List registered clusters and contexts from kubeconfig files with their last health check", Tags: []string{"clusters"}, Responses: map[int]any{http.StatusOK: []clusters.Cluster{}}}},
+	{http.MethodPost, "api/v1/clusters", openapi.Operation{Summary: "Register a cluster with a kubeconfig, bearer token or OIDC credentials", Tags: []string{"clusters"}, Request: clusters.Spec{}, Responses: map[int]any{http.StatusCreated: clusters.Cluster{}}}},
+	{http.MethodGet, "api/v1/clusters/:id", openapi.Operation{Summary: "Get a registered cluster", Tags: []string{"clusters"}, Responses: map[int]any{http.StatusOK: clusters.Cluster{}}}},
+	{http.MethodPut, "api/v1/clusters/:id", openapi.Operation{Summary: "Update a registered cluster's labels or credentials", Tags: []string{"clusters"}, Request: clusters.Spec{}, Responses: map[int]any{http.StatusOK: clusters.Cluster{}}}},
+	{http.MethodDelete, "api/v1/clusters/:id", openapi.Operation{Summary: "Remove a registered cluster and its credentials", Tags: []string{"clusters"}, Responses: map[int]any{http.StatusNoContent: nil}}},
+	{http.MethodGet, "api/v1/clusters/:id/health", openapi.Operation{Summary: "Probe a registered cluster's API server", Tags: []string{"clusters"}, Responses: map[int]any{http.StatusOK: clusters.Health{}}}},
+	{http.MethodGet, "api/v1/upgrades/advice", openapi.Operation{Summary: "Deprecated and removed APIs every connected cluster still uses, for an upgrade to target (default: each cluster's next minor version)", Tags: []string{"clusters"}, Query: []string{"target"}, Responses: map[int]any{http.StatusOK: []upgrades.Report{}}}},
+	{http.MethodGet, "api/v1/upgrades/advice/:cluster", openapi.Operation{Summary: "What breaks when a cluster, given by ID or name, is upgraded to target, with the affected objects and their field managers", Tags: []string{"clusters"}, Query: []string{"target"}, Responses: map[int]any{http.StatusOK: upgrades.Report{}}}},
+	{http.MethodGet, "api/v1/diagnostics/playbooks", openapi.Operation{Summary: "The built-in troubleshooting playbooks and the evidence each gathers", Tags: []string{"diagnostics"}, Responses: map[int]any{http.StatusOK: map[string][]diagnostics.Playbook{}}}},
+	{http.MethodGet, "api/v1/diagnostics/clusters/:cluster", openapi.Operation{Summary: "Run troubleshooting playbooks against a cluster, given by ID or name, and report findings with their evidence, likely cause and recommendations", Tags: []string{"diagnostics"}, Query: []string{"namespace", "target", "playbooks"}, Responses: map[int]any{http.StatusOK: diagnostics.Report{}}}},
+	{http.MethodGet, "api/v1/crdindex/clusters/:cluster", openapi.Operation{Summary: "The custom resource definitions installed in a cluster, given by ID or name, with their names, printer columns and spec fields as prompts see them", Tags: []string{"customresources"}, Query: []string{"refresh"}, Responses: map[int]any{http.StatusOK: map[string][]customresources.Definition{}}}},
+	{http.MethodGet, "api/v1/prometheus/usage", openapi.Operation{Summary: "Answer a CPU or memory usage question, such as how much CPU is checkout using, from Prometheus as a table and, over a time range, series", Tags: []string{"prometheus"}, Query: []string{"question", "namespace"}, Responses: map[int]any{http.StatusOK: prometheus.Answer{}}}},
+	{http.MethodGet, "api/v1/prometheus/query", openapi.Operation{Summary: "Run a PromQL query now or over a range up to now, within the range, step and series limits", Tags: []string{"prometheus"}, Query: []string{"query", "range", "step"}, Responses: map[int]any{http.StatusOK: prometheus.Result{}}}},
+	{http.MethodGet, "api/v1/admin/retention", openapi.Operation{Summary: "Retention policies per data category with legal holds and the last enforcement", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: retention.Overview{}}}},
+	{http.MethodPut, "api/v1/admin/retention/:category", openapi.Operation{Summary: "Set the retention window of a data category", Tags: []string{"admin"}, Request: retentionapi.PolicyRequest{}, Responses: map[int]any{http.StatusOK: retention.Policy{}}}},
+	{http.MethodPost, "api/v1/admin/retention/holds", openapi.Operation{Summary: "Place a legal hold on a category, one subject in it or individual records", Tags: []string{"admin"}, Request: retentionapi.HoldRequest{}, Responses: map[int]any{http.StatusCreated: retention.Hold{}}}},
+	{http.MethodDelete, "api/v1/admin/retention/holds/:id", openapi.Operation{Summary: "Release a legal hold; the request must be signed", Tags: []string{"admin"}, Responses: map[int]any{http.StatusNoContent: nil, http.StatusUnauthorized: signatureRejection{}}}},
+	{http.MethodGet, "api/v1/admin/signed-requests/audit", openapi.Operation{Summary: "Accepted and refused attempts at signed admin operations", Tags: []string{"admin"}, Query: []string{"user", "severity", "limit"}, Responses: map[int]any{http.StatusOK: map[string][]signing.AuditEntry{}}}},
+	{http.MethodGet, "api/v1/admin/audit/integrity", openapi.Operation{Summary: "Outcome of the last verification of the audit ledger's hash chain", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: audit.Verification{}}}},
+	{http.MethodPost, "api/v1/admin/audit/integrity/verify", openapi.Operation{Summary: "Verify the audit ledger's hash chain now", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: audit.Verification{}}}},
+	{http.MethodGet, "api/v1/admin/audit/ledger", openapi.Operation{Summary: "Page through the hash-chained audit ledger in order", Tags: []string{"admin"}, Query: []string{"after", "limit"}, Responses: map[int]any{http.StatusOK: map[string][]audit.Record{}}}},
+	{http.MethodPost, "api/v1/admin/audit/anchors", openapi.Operation{Summary: "Accept the audit ledger as it stands after investigating a tamper alert; must be signed", Tags: []string{"admin"}, Request: auditapi.AnchorRequest{}, Responses: map[int]any{http.StatusCreated: audit.Anchor{}, http.StatusUnauthorized: signatureRejection{}}}},
+	{http.MethodGet, "api/v1/admin/audit/alerts/stream", openapi.Operation{Summary: "Stream tamper alerts raised by audit ledger verification", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: openapi.EventStream{}}}},
+	{http.MethodGet, "api/v1/admin/audit/archives", openapi.Operation{Summary: "Audit ledger archives moved to object storage by retention, newest first", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: map[string][]audit.Archive{}}}},
+	{http.MethodPost, "api/v1/admin/audit/archives/:id/restore", openapi.Operation{Summary: "Download and verify an audit archive and make its records queryable", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: audit.Archive{}}}},
+	{http.MethodDelete, "api/v1/admin/audit/archives/:id/restore", openapi.Operation{Summary: "Drop the queryable records of a restored audit archive; the archive stays in storage", Tags: []string{"admin"}, Responses: map[int]any{http.StatusNoContent: nil}}},
+	{http.MethodGet, "api/v1/admin/audit/restored", openapi.Operation{Summary: "Page through records restored from audit archives", Tags: []string{"admin"}, Query: []string{"archive", "source", "after", "limit"}, Responses: map[int]any{http.StatusOK: map[string][]audit.RestoredRecord{}}}},
+	{http.MethodPost, "api/v1/admin/retention/enforce", openapi.Operation{Summary: "Enforce retention for every category now", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: []retention.Run{}}}},
+	{http.MethodGet, "api/v1/admin/retention/dry-run", openapi.Operation{Summary: "What enforcing retention would remove now in every category, without deleting anything", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: []retention.Forecast{}}}},
+	{http.MethodGet, "api/v1/admin/compliance", openapi.Operation{Summary: "Compliance frameworks and the controls their reports evaluate", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: complianceapi.Catalog{}}}},
+	{http.MethodGet, "api/v1/admin/compliance/reports/:framework", openapi.Operation{Summary: "Evaluate the SOX, HIPAA or SOC 2 controls over a period with per-control evidence", Tags: []string{"admin"}, Query: []string{"from", "to"}, Responses: map[int]any{http.StatusOK: compliance.Report{}}}},
+	{http.MethodGet, "api/v1/admin/compliance/access-reviews", openapi.Operation{Summary: "Access reviews of the role bindings, newest first", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: []compliance.AccessReview{}}}},
+	{http.MethodPost, "api/v1/admin/compliance/access-reviews", openapi.Operation{Summary: "Record that the caller reviewed every current role binding", Tags: []string{"admin"}, Request: complianceapi.ReviewRequest{}, Responses: map[int]any{http.StatusCreated: compliance.AccessReview{}}}},
+	{http.MethodGet, "api/v1/admin/security/events", openapi.Operation{Summary: "Security events raised by the anomaly rules, newest first", Tags: []string{"admin"}, Query: []string{"user", "rule", "limit"}, Responses: map[int]any{http.StatusOK: map[string][]anomaly.Event{}}}},
+	{http.MethodGet, "api/v1/admin/security/events/stream", openapi.Operation{Summary: "Stream security events as the anomaly rules raise them", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: openapi.EventStream{}}}},
+	{http.MethodGet, "api/v1/admin/security/anomaly-rules", openapi.Operation{Summary: "The anomaly rules in effect", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: anomaly.Rules{}}}},
+	{http.MethodGet, "api/v1/security/alerts", openapi.Operation{Summary: "Security alerts with the status of their deliveries, newest first", Tags: []string{"security"}, Query: []string{"type", "severity", "limit"}, Responses: map[int]any{http.StatusOK: map[string][]alerting.Record{}}}},
+	{http.MethodGet, "api/v1/security/alerts/channels", openapi.Operation{Summary: "Alert channels and the routes alerts take to them", Tags: []string{"security"}, Responses: map[int]any{http.StatusOK: alertingapi.Channels{}}}},
+	{http.MethodPost, "api/v1/security/alerts/test", openapi.Operation{Summary: "Send a test alert to a channel", Tags: []string{"security"}, Request: alertingapi.TestRequest{}, Responses: map[int]any{http.StatusOK: alerting.Delivery{}}}},
+	{http.MethodGet, "api/v1/exports/sources", openapi.Operation{Summary: "Data sources that can be exported", Tags: []string{"exports"}, Responses: map[int]any{http.StatusOK: []exports.SourceInfo{}}}},
+	{http.MethodPost, "api/v1/exports", openapi.Operation{Summary: "Queue an export job as csv, jsonl, a pdf report or an xlsx workbook", Tags: []string{"exports"}, Request: exports.Request{}, Responses: map[int]any{http.StatusAccepted: exports.Job{}}}},
+	{http.MethodGet, "api/v1/exports", openapi.Operation{Summary: "List your export jobs", Tags: []string{"exports"}, Responses: map[int]any{http.StatusOK: []exports.Job{}}}},
+	{http.MethodGet, "api/v1/exports/:id", openapi.Operation{Summary: "Get an export job with a fresh download link once finished", Tags: []string{"exports"}, Responses: map[int]any{http.StatusOK: exports.Job{}}}},
+	{http.MethodDelete, "api/v1/exports/:id", openapi.Operation{Summary: "Delete an export job and its file", Tags: []string{"exports"}, Responses: map[int]any{http.StatusNoContent: nil}}},
+	{http.MethodGet, "api/v1/exports/:id/download", openapi.Operation{Summary: "Download an export through a signed link", Tags: []string{"exports"}, Query: []string{"expires", "signature"}, Responses: map[int]any{http.StatusOK: openapi.Binary{}}}},
+
+	{http.MethodGet, "api/v1/privacy/export", openapi.Operation{Summary: "Download a zip archive of everything kept about a user, the caller by default", Tags: []string{"privacy"}, Query: []string{"user"}, Responses: map[int]any{http.StatusOK: openapi.Binary{}}}},
+	{http.MethodGet, "api/v1/privacy/erasures", openapi.Operation{Summary: "Erasure requests, all of them for admins and the caller's own otherwise", Tags: []string{"privacy"}, Responses: map[int]any{http.StatusOK: []privacy.Erasure{}}}},
+	{http.MethodPost, "api/v1/privacy/erasures", openapi.Operation{Summary: "Request erasure of a user's personal data, pending an admin's approval", Tags: []string{"privacy"}, Request: privacyapi.ErasureRequest{}, Responses: map[int]any{http.StatusCreated: privacy.Erasure{}}}},
+	{http.MethodGet, "api/v1/privacy/erasures/:id", openapi.Operation{Summary: "Get an erasure request with what it erased", Tags: []string{"privacy"}, Responses: map[int]any{http.StatusOK: privacy.Erasure{}}}},
+	{http.MethodPost, "api/v1/privacy/erasures/:id/approve", openapi.Operation{Summary: "Approve an erasure request, deleting and pseudonymizing the user's data", Tags: []string{"privacy"}, Responses: map[int]any{http.StatusOK: privacy.Erasure{}}}},
+	{http.MethodPost, "api/v1/privacy/erasures/:id/reject", openapi.Operation{Summary: "Reject a pending erasure request", Tags: []string{"privacy"}, Request: privacyapi.RejectRequest{}, Responses: map[int]any{http.StatusOK: privacy.Erasure{}}}},
+
+	{http.MethodPost, "api/v1/sandboxes", openapi.Operation{Summary: "Provision a sandbox", Tags: []string{"sandboxes"}, Query: clusterQuery, Request: sandboxapi.CreateSandboxRequest{}, Responses: map[int]any{http.StatusCreated: sandbox.Sandbox{}}}},
+	{http.MethodGet, "api/v1/sandboxes", openapi.Operation{Summary: "List sandboxes", Tags: []string{"sandboxes"}, Responses: map[int]any{http.StatusOK: []sandbox.Sandbox{}}}},
+	{http.MethodGet, "api/v1/sandboxes/:id", openapi.Operation{Summary: "Get a sandbox", Tags: []string{"sandboxes"}, Responses: map[int]any{http.StatusOK: sandbox.Sandbox{}}}},
+	{http.MethodPost, "api/v1/sandboxes/:id/verify", openapi.Operation{Summary: "Verify a sandbox", Tags: []string{"sandboxes"}, Responses: map[int]any{http.StatusOK: sandbox.Sandbox{}}}},
+	{http.MethodPost, "api/v1/sandboxes/:id/promote", openapi.Operation{Summary: "Promote a verified sandbox", Tags: []string{"sandboxes"}, Responses: map[int]any{http.StatusOK: sandbox.Sandbox{}}}},
+	{http.MethodDelete, "api/v1/sandboxes/:id", openapi.Operation{Summary: "Delete a sandbox", Tags: []string{"sandboxes"}, Responses: map[int]any{http.StatusNoContent: nil}}},
+
+	{http.MethodPost, "api/v1/commands/preview", openapi.Operation{Summary: "Preview a command's permissions, safety level and kubectl equivalent", Tags: []string{"commands"}, Query: clusterQuery, Request: commands.Request{}, Responses: map[int]any{http.StatusOK: commands.Preview{}}}},
+	{http.MethodPost, "api/v1/commands/executions", openapi.Operation{Summary: "Submit a command, or with dryRun return the changes a server-side dry run reports without running it", Tags: []string{"commands"}, Query: clusterQuery, Request: commands.Request{}, Responses: map[int]any{http.StatusOK: commands.DryRunResult{}, http.StatusCreated: commands.Execution{}, http.StatusAccepted: commands.Execution{}, http.StatusTooManyRequests: map[string]string{}}}},
+	{http.MethodGet, "api/v1/commands/executions", openapi.Operation{Summary: "List the caller's executions", Tags: []string{"commands"}, Query: []string{"limit", "offset"}, Responses: map[int]any{http.StatusOK: []commands.Execution{}}}},
+	{http.MethodGet, "api/v1/commands/executions/:id", openapi.Operation{Summary: "Get one of the caller's executions, or anyone's with roles:manage", Tags: []string{"commands"}, Responses: map[int]any{http.StatusOK: commands.Execution{}}}},
+	{http.MethodDelete, "api/v1/commands/executions/:id", openapi.Operation{Summary: "Delete an execution record; it is hidden and kept until retention purges it", Tags: []string{"commands"}, Responses: map[int]any{http.StatusNoContent: nil}}},
+	{http.MethodPost, "api/v1/commands/executions/:id/approve", openapi.Operation{Summary: "Approve a pending execution; approving a dangerous command needs a fresh MFA verification", Tags: []string{"approvals"}, Request: decisionRequest{}, Responses: map[int]any{http.StatusOK: commands.Execution{}}}},
+	{http.MethodPost, "api/v1/commands/executions/:id/reject", openapi.Operation{Summary: "Reject a pending execution", Tags: []string{"approvals"}, Request: decisionRequest{}, Responses: map[int]any{http.StatusOK: commands.Execution{}}}},
+	{http.MethodPost, "api/v1/commands/executions/:id/rollback", openapi.Operation{Summary: "Roll back an execution, or preview with dryRun", Tags: []string{"commands"}, Query: []string{"dryRun"}, Responses: map[int]any{http.StatusOK: openapi.AnyOf{commands.Execution{}, commands.RollbackPreview{}}}}},
+	{http.MethodPost, "api/v1/commands/executions/:id/override-freeze", openapi.Operation{Summary: "Run an execution queued by a change window now; needs the approver role and a reason", Tags: []string{"approvals"}, Request: decisionRequest{}, Responses: map[int]any{http.StatusOK: commands.Execution{}}}},
+	{http.MethodGet, "api/v1/commands/executions/:id/approval", openapi.Operation{Summary: "Approval state and history", Tags: []string{"approvals"}, Responses: map[int]any{http.StatusOK: commandsapi.ApprovalResponse{}}}},
+	{http.MethodGet, "api/v1/commands/executions/:id/explanation", openapi.Operation{Summary: "Explain what a command does, what it touches, whether it can be undone and the permissions it needs", Tags: []string{"commands"}, Responses: map[int]any{http.StatusOK: commands.Explanation{}}}},
+	{http.MethodGet, "api/v1/commands/executions/:id/attachments/:attachmentId", openapi.Operation{Summary: "Download an execution output attachment", Tags: []string{"commands"}, Responses: map[int]any{http.StatusOK: openapi.Binary{}}}},
+	{http.MethodGet, "api/v1/commands/approvals", openapi.Operation{Summary: "List approvals", Tags: []string{"approvals"}, Query: []string{"status"}, Responses: map[int]any{http.StatusOK: []commands.Approval{}}}},
+	{http.MethodGet, "api/v1/commands/approvals/pending", openapi.Operation{Summary: "List pending approvals", Tags: []string{"approvals"}, Responses: map[int]any{http.StatusOK: []commands.Approval{}}}},
+	{http.MethodGet, "api/v1/commands/approvals/assigned", openapi.Operation{Summary: "Pending approvals the caller may decide, including those routed by a delegation", Tags: []string{"approvals"}, Responses: map[int]any{http.StatusOK: []commands.AssignedApproval{}}}},
+	{http.MethodGet, "api/v1/commands/approvals/links/:token", openapi.Operation{Summary: "Describe the decision a signed approval link records; browsers get a confirmation page", Tags: []string{"approvals"}, Responses: approvalLinkResponses(commandsapi.ApprovalLinkResponse{})}},
+	{http.MethodPost, "api/v1/commands/approvals/links/:token", openapi.Operation{Summary: "Record the decision of a signed approval link as its recipient, once; approving a dangerous command needs a fresh MFA verification", Tags: []string{"approvals"}, Request: decisionRequest{}, Responses: approvalLinkResponses(commands.Execution{})}},
+	{http.MethodGet, "api/v1/commands/delegations", openapi.Operation{Summary: "Approval delegations the caller made or received", Tags: []string{"approvals"}, Responses: map[int]any{http.StatusOK: []commands.ApprovalDelegation{}}}},
+	{http.MethodPost, "api/v1/commands/delegations", openapi.Operation{Summary: "Delegate the caller's approval authority for a time window", Tags: []string{"approvals"}, Request: commands.DelegationRequest{}, Responses: map[int]any{http.StatusCreated: commands.ApprovalDelegation{}}}},
+	{http.MethodDelete, "api/v1/commands/delegations/:id", openapi.Operation{Summary: "Revoke an approval delegation early", Tags: []string{"approvals"}, Responses: map[int]any{http.StatusOK: commands.ApprovalDelegation{}}}},
+	{http.MethodPost, "api/v1/commands/plans", openapi.Operation{Summary: "Run several commands in order as one plan, stopping at the first failure; a plan needing approval is approved as a whole", Tags: []string{"commands"}, Query: clusterQuery, Request: commands.PlanRequest{}, Responses: map[int]any{http.StatusCreated: commands.Execution{}, http.StatusAccepted: commands.Execution{}, http.StatusTooManyRequests: map[string]string{}}}},
+	{http.MethodGet, "api/v1/commands/schedules", openapi.Operation{Summary: "The caller's scheduled commands", Tags: []string{"commands"}, Responses: map[int]any{http.StatusOK: []commands.CommandSchedule{}}}},
+	{http.MethodPost, "api/v1/commands/schedules", openapi.Operation{Summary: "Schedule a command once at runAt or repeatedly on a cron expression; each firing is checked and approved like a new submission", Tags: []string{"commands"}, Query: clusterQuery, Request: commands.ScheduleRequest{}, Responses: map[int]any{http.StatusCreated: commands.CommandSchedule{}}}},
+	{http.MethodGet, "api/v1/commands/schedules/:id", openapi.Operation{Summary: "Get a scheduled command", Tags: []string{"commands"}, Responses: map[int]any{http.StatusOK: commands.CommandSchedule{}}}},
+	{http.MethodDelete, "api/v1/commands/schedules/:id", openapi.Operation{Summary: "Cancel a scheduled command", Tags: []string{"commands"}, Responses: map[int]any{http.StatusOK: commands.CommandSchedule{}}}},
+	{http.MethodGet, "api/v1/commands/namespace-template", openapi.Operation{Summary: "The template applied to namespaces created from the caller's workspace", Tags: []string{"commands"}, Responses: map[int]any{http.StatusOK: commands.NamespaceTemplate{}}}},
+	{http.MethodGet, "api/v1/commands/quota", openapi.Operation{Summary: "The caller's command usage against their limits and the namespace freezes in effect", Tags: []string{"commands"}, Responses: map[int]any{http.StatusOK: commandQuotaResponse{}}}},
+	{http.MethodGet, "api/v1/admin/commands/quota", openapi.Operation{Summary: "Command limits, namespace freezes and every user's usage", Tags: []string{"admin"}, Responses: map[int]any{http.StatusOK: commands.CommandQuotaOverview{}}}},
+	{http.MethodPut, "api/v1/admin/commands/quota", openapi.Operation{Summary: "Set the default destructive-per-hour and concurrent command limits", Tags: []string{"admin"}, Request: commands.CommandLimits{}, Responses: map[int]any{http.StatusOK: commands.CommandQuotaOverview{}}}},
+	{http.MethodPut, "api/v1/admin/commands/quota/users/:user", openapi.Operation{Summary: "Override the command limits of one user", Tags: []string{"admin"}, Request: commands.CommandLimits{}, Responses: map[int]any{http.StatusOK: commands.CommandUsage{}}}},
+	{http.MethodDelete, "api/v1/admin/commands/quota/users/:user", openapi.Operation{Summary: "Return a user to the default command limits", Tags: []string{"admin"}, Responses: map[int]any{http.StatusNoContent: nil}}},
+	{http.MethodPost, "api/v1/admin/commands/freezes", openapi.Operation{Summary: "Freeze a namespace for changes, optionally until a time", Tags: []string{"admin"}, Request: commands.NamespaceFreeze{}, Responses: map[int]any{http.StatusCreated: commands.NamespaceFreeze{}}}},
+	{http.MethodDelete, "api/v1/admin/commands/freezes/:namespace", openapi.Operation{Summary: "Lift a namespace change freeze", Tags: []string{"admin"}, Query: []string{"cluster"}, Responses: map[int]any{http.StatusNoContent: nil}}},
+	{http.MethodGet, "api/v1/commands/change-windows", openapi.Operation{Summary: "Change windows that freeze changes to clusters or namespaces", Tags: []string{"commands"}, Responses: map[int]any{http.StatusOK: []commands.ChangeWindow{}}}},
+	{http.MethodGet, "api/v1/commands/change-windows/calendar", openapi.Operation{Summary: "Freezes between from and to, RFC 3339 times defaulting to the next seven days", Tags: []string{"commands"}, Query: []string{"from", "to"}, Responses: map[int]any{http.StatusOK: []commands.FreezeOccurrence{}}}},
+	{http.MethodPost, "api/v1/admin/commands/change-windows", openapi.Operation{Summary: "Define a one-off or recurring change window that rejects or queues changes", Tags: []string{"admin"}, Request: commands.ChangeWindow{}, Responses: map[int]any{http.StatusCreated: commands.ChangeWindow{}}}},
+	{http.MethodDelete, "api/v1/admin/commands/change-windows/:id", openapi.Operation{Summary: "Delete a change window", Tags: []string{"admin"}, Responses: map[int]any{http.StatusNoContent: nil}}},
+	{http.MethodGet, "api/v1/commands/safety-policy", openapi.Operation{Summary: "Safety rules in force, where they were loaded from and the last reload error", Tags: []string{"commands"}, Responses: map[int]any{http.StatusOK: commands.SafetyPolicyStatus{}}}},
+	{http.MethodGet, "api/v1/audit/resources/:kind/:namespace/:name", openapi.Operation{Summary: "Every action taken against one object, including refused generations; use _ as the namespace of cluster-scoped objects", Tags: []string{"audit"}, Query: []string{"cluster", "limit", "offset"}, Responses: map[int]any{http.StatusOK: map[string][]commands.Execution{}}}},
+	{http.MethodGet, "api/v1/audit/correlations/:id", openapi.Operation{Summary: "The chat messages, plan, executions, object changes and rollbacks sharing a correlation ID; an execution ID resolves to its correlation", Tags: []string{"audit"}, Responses: map[int]any{http.StatusOK: correlation.Trail{}}}},
+	{http.MethodPost, "api/v1/commands/executors", openapi.Operation{Summary: "Register a webhook executor", Tags: []string{"commands"}, Request: commandsapi.RegisterExecutorRequest{}, Responses: map[int]any{http.StatusCreated: commands.WebhookExecutor{}}}},
+	{http.MethodGet, "api/v1/commands/executors", openapi.Operation{Summary: "List webhook executors", Tags: []string{"commands"}, Responses: map[int]any{http.StatusOK: []commands.WebhookExecutor{}}}},
+	{http.MethodDelete, "api/v1/commands/executors/:operation", openapi.Operation{Summary: "Remove a webhook executor", Tags: []string{"commands"}, Responses: map[int]any{http.StatusNoContent: nil}}},
+	{http.MethodGet, "api/v1/iac/ownership", openapi.Operation{Summary: "Detect Terraform or Crossplane ownership", Tags: []string{"iac"}, Query: []string{"config", "cluster", "kind", "namespace", "name"}, Responses: map[int]any{http.StatusOK: iac.Ownership{}}}},
+	{http.MethodGet, "api/v1/helm/releases", openapi.Operation{Summary: "Latest revision of each Helm release", Tags: []string{"helm"}, Query: []string{"config", "cluster", "namespace"}, Responses: map[int]any{http.StatusOK: map[string][]helm.Release{}}}},
+	{http.MethodGet, "api/v1/helm/releases/:name/history", openapi.Operation{Summary: "Revisions of a Helm release, oldest first", Tags: []string{"helm"}, Query: []string{"config", "cluster", "namespace"}, Responses: map[int]any{http.StatusOK: map[string][]helm.Release{}}}},
+	{http.MethodGet, "api/v1/helm/releases/:name/values", openapi.Operation{Summary: "Values supplied for a revision of a Helm release, the latest by default", Tags: []string{"helm"}, Query: []string{"config", "cluster", "namespace", "revision"}, Responses: map[int]any{http.StatusOK: helmapi.ValuesResponse{}}}},
+	{http.MethodGet, "api/v1/helm/releases/:name/manifest", openapi.Operation{Summary: "Manifest a revision of a Helm release installed, the latest by default", Tags: []string{"helm"}, Query: []string{"config", "cluster", "namespace", "revision"}, Responses: map[int]any{http.StatusOK: helmapi.ManifestResponse{}}}},
+	{http.MethodPost, "api/v1/helm/releases/:name/upgrade/preview", openapi.Operation{Summary: "Diff a rendered manifest with a Helm release, with the safety level and access review of upgrading to it", Tags: []string{"helm"}, Query: []string{"namespace"}, Request: helmapi.ChangeRequest{}, Responses: map[int]any{http.StatusOK: commands.HelmPreview{}}}},
+	{http.MethodPost, "api/v1/helm/releases/:name/rollback/preview", openapi.Operation{Summary: "Diff an earlier revision with a Helm release, with the safety level and access review of rolling back to it", Tags: []string{"helm"}, Query: []string{"namespace"}, Request: helmapi.ChangeRequest{}, Responses: map[int]any{http.StatusOK: commands.HelmPreview{}}}},
+	{http.MethodPost, "api/v1/helm/releases/:name/upgrade", openapi.Operation{Summary: "Submit an upgrade of a Helm release to a rendered manifest", Tags: []string{"helm"}, Query: []string{"namespace"}, Request: helmapi.ChangeRequest{}, Responses: map[int]any{http.StatusCreated: commands.Execution{}, http.StatusAccepted: commands.Execution{}}}},
+	{http.MethodPost, "api/v1/helm/releases/:name/rollback", openapi.Operation{Summary: "Submit a rollback of a Helm release to an earlier revision", Tags: []string{"helm"}, Query: []string{"namespace"}, Request: helmapi.ChangeRequest{}, Responses: map[int]any{http.StatusCreated: commands.Execution{}, http.StatusAccepted: commands.Execution{}}}},
+	{http.MethodPost, "api/v1/iac/suggestions", openapi.Operation{Summary: "Suggest an IaC change instead of a live edit", Tags: []string{"iac"}, Query: clusterQuery, Request: iacapi.SuggestionRequest{}, Responses: map[int]any{http.StatusOK: iacapi.SuggestionResponse{}}}},
+
+	{http.MethodGet, "api/v1/customresources", openapi.Operation{Summary: "Stream custom resources", Tags: []string{"customresources"}, Query: []string{"config", "cluster", "kind", "group", "version", "resource"}, Responses: map[int]any{http.StatusOK: openapi.EventStream{}}}},
+	{http.MethodDelete, "api/v1/customresources", openapi.Operation{Summary: "Delete custom resources", Tags: []string{"customresources"}, Query: []string{"config", "cluster", "group", "version", "resource"}, Request: []resourceRef{}, Responses: map[int]any{http.StatusOK: deleteFailures{}}}},
+	{http.MethodGet, "api/v1/customresources/:name", openapi.Operation{Summary: "Stream a cluster-scoped custom resource", Tags: []string{"customresources"}, Query: []string{"config", "cluster", "group", "version", "resource"}, Responses: map[int]any{http.StatusOK: openapi.EventStream{}}}},
+	{http.MethodGet, "api/v1/customresources/:name/yaml", openapi.Operation{Summary: "Stream a cluster-scoped custom resource as YAML", Tags: []string{"customresources"}, Query: []string{"config", "cluster", "group", "version", "resource"}, Responses: map[int]any{http.StatusOK: openapi.EventStream{}}}},
+	{http.MethodGet, "api/v1/customresources/:namespace/:name", openapi.Operation{Summary: "Stream a namespaced custom resource", Tags: []string{"customresources"}, Query: []string{"config", "cluster", "group", "version", "resource"}, Responses: map[int]any{http.StatusOK: openapi.EventStream{}}}},
+	{http.MethodGet, "api/v1/customresources/:namespace/:name/yaml", openapi.Operation{Summary: "Stream a namespaced custom resource as YAML", Tags: []string{"customresources"}, Query: []string{"config", "cluster", "group", "version", "resource"}, Responses: map[int]any{http.StatusOK: openapi.EventStream{}}}},
+	{http.MethodGet, "api/v1/customresourcedefinitions", openapi.Operation{Summary: "Stream custom resource definitions", Tags: []string{"customresourcedefinitions"}, Query: clusterQuery, Responses: map[int]any{http.StatusOK: openapi.EventStream{}}}},
+	{http.MethodGet, "api/v1/customresourcedefinitions/:name", openapi.Operation{Summary: "Stream a custom resource definition", Tags: []string{"customresourcedefinitions"}, Query: clusterQuery, Responses: map[int]any{http.StatusOK: openapi.EventStream{}}}},
+	{http.MethodGet, "api/v1/customresourcedefinitions/:name/yaml", openapi.Operation{Summary: "Stream a custom resource definition as YAML", Tags: []string{"customresourcedefinitions"}, Query: clusterQuery, Responses: map[int]any{http.StatusOK: openapi.EventStream{}}}},
+	{http.MethodGet, "api/v1/customresourcedefinitions/:name/events", openapi.Operation{Summary: "Stream events for a custom resource definition", Tags: []string{"customresourcedefinitions"}, Query: clusterQuery, Responses: map[int]any{http.StatusOK: openapi.EventStream{}}}},
+	{http.MethodDelete, "api/v1/customresourcedefinitions", openapi.Operation{Summary: "Delete custom resource definitions", Tags: []string{"customresourcedefinitions"}, Query: clusterQuery, Request: []resourceRef{}, Responses: map[int]any{http.StatusOK: deleteFailures{}}}},
+
+	{http.MethodGet, "api/v1/mcp/sse", openapi.Operation{Summary: "MCP server-sent events transport", Tags: []string{"mcp"}, Responses: map[int]any{http.StatusOK: openapi.EventStream{}}}},
+	{http.MethodPost, "api/v1/mcp/message", openapi.Operation{Summary: "MCP JSON-RPC message", Tags: []string{"mcp"}, Request: json.RawMessage{}, Responses: map[int]any{http.StatusOK: json.RawMessage{}, http.StatusAccepted: nil}}},
+}
+
+// resourceRouteSuffixes maps the naming convention of the Kubernetes resource routes
+// ("deploymentsList", "podsLogs", ...) to their operations.
+var resourceRouteSuffixes = map[string]func(resource string) openapi.Operation{
+	"List": func(resource string) openapi.Operation {
+		return openapi.Operation{Summary: "Stream " + resource, Query: clusterQuery, Responses: map[int]any{http.StatusOK: openapi.EventStream{}}}
+	},
+	"Details": func(resource string) openapi.Operation {
+		return openapi.Operation{Summary: "Stream one of " + resource, Query: []string{"config", "cluster", "namespace"}, Responses: map[int]any{http.StatusOK: openapi.EventStream{}}}
+	},
+	"Yaml": func(resource string) openapi.Operation {
+		return openapi.Operation{Summary: "Stream one of " + resource + " as YAML", Query: []string{"config", "cluster", "namespace"}, Responses: map[int]any{http.StatusOK: openapi.EventStream{}}}
+	},
+	"Events": func(resource string) openapi.Operation {
+		return openapi.Operation{Summary: "Stream events for one of " + resource, Query: []string{"config", "cluster", "namespace"}, Responses: map[int]any{http.StatusOK: openapi.EventStream{}}}
+	},
+	"Pods": func(resource string) openapi.Operation {
+		return openapi.Operation{Summary: "Stream pods of one of " + resource, Query: []string{"config", "cluster", "namespace"}, Responses: map[int]any{http.StatusOK: openapi.EventStream{}}}
+	},
+	"Logs": func(resource string) openapi.Operation {
+		return openapi.Operation{Summary: "Stream logs of one of " + resource, Query: []string{"config", "cluster", "namespace", "container"}, Responses: map[int]any{http.StatusOK: openapi.EventStream{}}}
+	},
+	"Delete": func(resource string) openapi.Operation {
+		return openapi.Operation{Summary: "Delete " + resource, Query: clusterQuery, Request: []resourceRef{}, Responses: map[int]any{http.StatusOK: deleteFailures{}}}
+	},
+	"Scale": func(resource string) openapi.Operation {
+		return openapi.Operation{Summary: "Scale one of " + resource, Query: []string{"config", "cluster", "namespace"}, Request: deployments.DeploymentReplicas{}, Responses: map[int]any{http.StatusOK: successResponse{}}}
+	},
+}
+
+func resourceOperation(route *echo.Route) (openapi.Operation, bool) {
+	for suffix, describe := range resourceRouteSuffixes {
+		resource, ok := strings.CutSuffix(route.Name, suffix)
+		if !ok || resource == "" || strings.ContainsAny(resource, "./") {
+			continue
+		}
+		op := describe(resource)
+		op.Tags = []string{strings.ToLower(resource)}
+		return op, true
+	}
+	return openapi.Operation{}, false
+}
+
+// openAPIRoutes serves the document generated from every route registered on e and,
+// when enabled, validates responses against it. It must run after all other routes are registered.
+func openAPIRoutes(e *echo.Echo, appContainer container.Container) {
+	registry := openapi.NewRegistry(openapi.Info{Title: "Kubechat API", Version: appContainer.Config().Version})
+	for _, annotation := range apiOperations {
+		registry.Annotate(annotation.method, annotation.path, annotation.op)
+	}
+	registry.Derive(resourceOperation)
+
+	var document *openapi.Document
+	e.GET(openAPIPath, func(c echo.Context) error {
+		return c.JSON(http.StatusOK, document)
+	})
+
+	document, undocumented := registry.Build(e.Routes())
+	for _, route := range undocumented {
+		log.Warn("route has no OpenAPI annotation", "route", route)
+	}
+
+	if mode := openapi.ContractMode(appContainer.Config().OpenAPIContract); mode != openapi.ContractOff && mode != "" {
+		log.Info("validating responses against the OpenAPI document", "mode", mode)
+		e.Use(openapi.ContractMiddleware(document, mode, nil))
+	}
+}
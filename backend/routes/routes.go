@@ -1,8 +1,17 @@
 package routes
 
 import (
+	"context"
 	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
 	"net/http"
+	"os"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/pramodksahoo/kubechat/backend/handlers/accesscontrol/clusterroles"
@@ -21,6 +30,7 @@ import (
 	"github.com/pramodksahoo/kubechat/backend/handlers/crds/crds"
 	"github.com/pramodksahoo/kubechat/backend/handlers/crds/resources"
 	"github.com/pramodksahoo/kubechat/backend/handlers/events"
+	"github.com/pramodksahoo/kubechat/backend/handlers/helpers"
 	"github.com/pramodksahoo/kubechat/backend/handlers/mcp"
 	"github.com/pramodksahoo/kubechat/backend/handlers/network/endpoints"
 	"github.com/pramodksahoo/kubechat/backend/handlers/network/ingresses"
@@ -31,14 +41,99 @@ import (
 	"github.com/pramodksahoo/kubechat/backend/handlers/storage/persistentvolumes"
 	"github.com/pramodksahoo/kubechat/backend/handlers/storage/storageclasses"
 	cronjobs "github.com/pramodksahoo/kubechat/backend/handlers/workloads/cronJobs"
+	"github.com/pramodksahoo/kubechat/backend/internal/ai"
+	"github.com/pramodksahoo/kubechat/backend/internal/alerting"
+	"github.com/pramodksahoo/kubechat/backend/internal/anomaly"
+	alertingapi "github.com/pramodksahoo/kubechat/backend/internal/api/alerting"
+	anomalyapi "github.com/pramodksahoo/kubechat/backend/internal/api/anomaly"
+	auditapi "github.com/pramodksahoo/kubechat/backend/internal/api/audit"
+	breakersapi "github.com/pramodksahoo/kubechat/backend/internal/api/breakers"
+	capabilitiesapi "github.com/pramodksahoo/kubechat/backend/internal/api/capabilities"
+	changesapi "github.com/pramodksahoo/kubechat/backend/internal/api/changes"
+	chaosapi "github.com/pramodksahoo/kubechat/backend/internal/api/chaos"
+	chatapi "github.com/pramodksahoo/kubechat/backend/internal/api/chat"
+	chatfilesapi "github.com/pramodksahoo/kubechat/backend/internal/api/chatfiles"
+	clustersapi "github.com/pramodksahoo/kubechat/backend/internal/api/clusters"
+	commandsapi "github.com/pramodksahoo/kubechat/backend/internal/api/commands"
+	complianceapi "github.com/pramodksahoo/kubechat/backend/internal/api/compliance"
+	correlationapi "github.com/pramodksahoo/kubechat/backend/internal/api/correlation"
+	credentialsapi "github.com/pramodksahoo/kubechat/backend/internal/api/credentials"
+	customresourcesapi "github.com/pramodksahoo/kubechat/backend/internal/api/customresources"
+	dashboardsapi "github.com/pramodksahoo/kubechat/backend/internal/api/dashboards"
+	diagnosticsapi "github.com/pramodksahoo/kubechat/backend/internal/api/diagnostics"
+	exportsapi "github.com/pramodksahoo/kubechat/backend/internal/api/exports"
+	helmapi "github.com/pramodksahoo/kubechat/backend/internal/api/helm"
+	iacapi "github.com/pramodksahoo/kubechat/backend/internal/api/iac"
+	impersonationapi "github.com/pramodksahoo/kubechat/backend/internal/api/impersonation"
+	loggingapi "github.com/pramodksahoo/kubechat/backend/internal/api/logging"
+	logsapi "github.com/pramodksahoo/kubechat/backend/internal/api/logs"
+	mfaapi "github.com/pramodksahoo/kubechat/backend/internal/api/mfa"
+	nlpapi "github.com/pramodksahoo/kubechat/backend/internal/api/nlp"
+	privacyapi "github.com/pramodksahoo/kubechat/backend/internal/api/privacy"
+	prometheusapi "github.com/pramodksahoo/kubechat/backend/internal/api/prometheus"
 	promptapi "github.com/pramodksahoo/kubechat/backend/internal/api/prompts"
+	reportsapi "github.com/pramodksahoo/kubechat/backend/internal/api/reports"
 	planrepository "github.com/pramodksahoo/kubechat/backend/internal/api/repository"
+	restartsapi "github.com/pramodksahoo/kubechat/backend/internal/api/restarts"
+	retentionapi "github.com/pramodksahoo/kubechat/backend/internal/api/retention"
+	rightsizingapi "github.com/pramodksahoo/kubechat/backend/internal/api/rightsizing"
+	rolesapi "github.com/pramodksahoo/kubechat/backend/internal/api/roles"
+	sandboxapi "github.com/pramodksahoo/kubechat/backend/internal/api/sandboxes"
+	securityapi "github.com/pramodksahoo/kubechat/backend/internal/api/security"
+	signingapi "github.com/pramodksahoo/kubechat/backend/internal/api/signing"
+	statusapi "github.com/pramodksahoo/kubechat/backend/internal/api/status"
+	upgradesapi "github.com/pramodksahoo/kubechat/backend/internal/api/upgrades"
+	usersapi "github.com/pramodksahoo/kubechat/backend/internal/api/users"
+	watchapi "github.com/pramodksahoo/kubechat/backend/internal/api/watch"
+	"github.com/pramodksahoo/kubechat/backend/internal/attachments"
+	"github.com/pramodksahoo/kubechat/backend/internal/audit"
+	"github.com/pramodksahoo/kubechat/backend/internal/breaker"
+	"github.com/pramodksahoo/kubechat/backend/internal/capabilities"
+	"github.com/pramodksahoo/kubechat/backend/internal/chaos"
+	"github.com/pramodksahoo/kubechat/backend/internal/chat"
+	"github.com/pramodksahoo/kubechat/backend/internal/chatfiles"
+	"github.com/pramodksahoo/kubechat/backend/internal/clusters"
+	"github.com/pramodksahoo/kubechat/backend/internal/commands"
+	"github.com/pramodksahoo/kubechat/backend/internal/compliance"
+	"github.com/pramodksahoo/kubechat/backend/internal/correlation"
+	"github.com/pramodksahoo/kubechat/backend/internal/costs"
+	"github.com/pramodksahoo/kubechat/backend/internal/credentials"
+	"github.com/pramodksahoo/kubechat/backend/internal/customresources"
+	"github.com/pramodksahoo/kubechat/backend/internal/dashboards"
+	"github.com/pramodksahoo/kubechat/backend/internal/diagnostics"
+	"github.com/pramodksahoo/kubechat/backend/internal/exports"
+	"github.com/pramodksahoo/kubechat/backend/internal/guardrail"
+	"github.com/pramodksahoo/kubechat/backend/internal/health"
+	"github.com/pramodksahoo/kubechat/backend/internal/httpcache"
+	"github.com/pramodksahoo/kubechat/backend/internal/iac"
+	"github.com/pramodksahoo/kubechat/backend/internal/impersonation"
+	"github.com/pramodksahoo/kubechat/backend/internal/inventory"
+	"github.com/pramodksahoo/kubechat/backend/internal/logging"
+	"github.com/pramodksahoo/kubechat/backend/internal/logs"
+	"github.com/pramodksahoo/kubechat/backend/internal/mfa"
 	planbuilder "github.com/pramodksahoo/kubechat/backend/internal/plan"
+	"github.com/pramodksahoo/kubechat/backend/internal/privacy"
+	promusage "github.com/pramodksahoo/kubechat/backend/internal/prometheus"
+	"github.com/pramodksahoo/kubechat/backend/internal/promptcache"
+	"github.com/pramodksahoo/kubechat/backend/internal/ratelimit"
+	"github.com/pramodksahoo/kubechat/backend/internal/redis"
+	"github.com/pramodksahoo/kubechat/backend/internal/reports"
+	"github.com/pramodksahoo/kubechat/backend/internal/retention"
+	"github.com/pramodksahoo/kubechat/backend/internal/rightsizing"
+	approles "github.com/pramodksahoo/kubechat/backend/internal/roles"
+	"github.com/pramodksahoo/kubechat/backend/internal/sandbox"
+	"github.com/pramodksahoo/kubechat/backend/internal/security"
+	"github.com/pramodksahoo/kubechat/backend/internal/signing"
 	"github.com/pramodksahoo/kubechat/backend/internal/telemetry"
+	"github.com/pramodksahoo/kubechat/backend/internal/upgrades"
+	"github.com/pramodksahoo/kubechat/backend/internal/users"
+	"github.com/pramodksahoo/kubechat/backend/internal/watch"
 	appmiddleware "github.com/pramodksahoo/kubechat/backend/routes/middleware"
 
+	"github.com/charmbracelet/log"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/pramodksahoo/kubechat/backend/config"
 	"github.com/pramodksahoo/kubechat/backend/container"
 	"github.com/pramodksahoo/kubechat/backend/handlers/app"
 	configmaps "github.com/pramodksahoo/kubechat/backend/handlers/config/configMaps"
@@ -53,23 +148,71 @@ import (
 	statefulset "github.com/pramodksahoo/kubechat/backend/handlers/workloads/statefulsets"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/r3labs/sse/v2"
+	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
 //go:embed static/* static/**/*
 var embeddedFiles embed.FS
 
+// ConfigureRoutes is the composition root: it builds every service from the container's
+// configuration and registers its routes, leaving out the subsystems the configuration disables.
 func ConfigureRoutes(e *echo.Echo, appContainer container.Container) {
+	cfg := appContainer.Config()
 	e.HideBanner = true
-	setCORSConfig(e)
+	setCORSConfig(e, cfg.Security.CORSAllowedOrigins)
 
 	e.Pre(middleware.RemoveTrailingSlash())
-	e.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{
-		Format: "[${time_rfc3339}] ${status} ${method} ${uri} (${remote_ip}) ${error} ${latency_human}\n",
-		Output: e.Logger.Output(),
-	}))
+	e.Use(appmiddleware.MetricsMiddleware(telemetry.NewHTTPMetrics(prometheus.DefaultRegisterer)))
+	e.Use(appmiddleware.TracingMiddleware())
 	e.Use(middleware.Recover())
 	e.Use(middleware.RequestID())
+	e.Use(appmiddleware.LoggingMiddleware(logging.Module("http")))
+	sharedRedis := redisClient(appContainer)
+	proxies := trustedProxies(cfg.Security.TrustedProxies)
+	e.IPExtractor = ipExtractor(proxies)
+	e.Use(appmiddleware.RateLimitMiddleware(rateLimiter(appContainer, sharedRedis), proxies))
+	dispatcher := alertingRoutes(e, appContainer)
+	ledger, archiver := auditLedger(appContainer, dispatcher)
+	signer := signingRoutes(e, appContainer)
+	if signer != nil {
+		signer.SetLedger(ledger)
+	}
+	auditRoutes(e, appContainer, ledger, archiver, signer)
+	directory := userRoutes(e, appContainer)
+	directory.SetLedger(ledger)
+	e.Use(appmiddleware.UserDirectoryMiddleware(directory))
+	roleService := roleRoutes(e, appContainer)
+	impersonator := impersonationRoutes(e, appContainer, signer, roleService)
+	impersonator.SetLedger(ledger)
+	e.Use(appmiddleware.ImpersonationMiddleware(impersonator))
+	detector := anomalyRoutes(e, appContainer, ledger, dispatcher)
+	e.Use(appmiddleware.AnomalyMiddleware(detector))
+	e.Use(appmiddleware.RoleMiddleware(roleService, approles.DefaultRules))
+	e.Use(appmiddleware.NamespacePolicyMiddleware(roleService))
+	stepUp := mfaRoutes(e, appContainer)
+	stepUp.SetLedger(ledger)
+	directory.SetRoles(roleService)
+	directory.SetCredentials(stepUp)
+	capabilityRegistry := capabilityRoutes(e, appContainer)
+	e.Use(appmiddleware.CapabilityMiddleware(capabilityRegistry, capabilities.DefaultRules))
+	clusterManager := clusterRoutes(e, appContainer)
+	e.Use(appmiddleware.ClusterResolverMiddleware(clusterManager))
 	e.Use(appmiddleware.ClusterQueryParamMiddleware(appContainer))
+	faults := chaosRoutes(e, appContainer)
+	logLevels := loggingapi.NewLevelsController(logging.Module("admin"))
+	e.GET("api/v1/admin/log-levels", logLevels.List)
+	e.PUT("api/v1/admin/log-levels/:module", logLevels.Set)
+	e.Use(appmiddleware.ChaosMiddleware(faults))
 	e.Use(appmiddleware.ClusterConnectivityMiddleware(appContainer))
 	e.Use(appmiddleware.ClusterCacheMiddleware(appContainer))
 	e.Use(middleware.StaticWithConfig(middleware.StaticConfig{
@@ -82,12 +225,91 @@ func ConfigureRoutes(e *echo.Echo, appContainer container.Container) {
 	})
 
 	e.GET("/metrics", echo.WrapHandler(promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{})))
+	if db := appContainer.DB(); db != nil {
+		telemetry.RegisterDBStats(prometheus.DefaultRegisterer, db.DB, "kubechat")
+	}
 
 	planCatalog := planbuilder.NewConfigClusterCatalog(appContainer)
 	metricsRecorder := telemetry.NewPlanMetrics(prometheus.DefaultRegisterer)
 	planRepo := planrepository.NewPlanRepository(appContainer.Cache(), 24*time.Hour)
 	sseServer := appContainer.SSE()
-	promptController := promptapi.NewPromptController(planbuilder.NewDefaultBuilder(planCatalog), metricsRecorder, planRepo, nil)
+	defaultBuilder := planbuilder.NewDefaultBuilder(planCatalog)
+	defaultBuilder.SetResourceCatalog(customResourceIndexRoutes(e, appContainer, clusterManager))
+	var planBuilder planbuilder.Builder = defaultBuilder
+	modelChain := modelProviders(appContainer)
+	modelChain.SetFaults(faults)
+	modelChain.SetMetrics(telemetry.NewModelMetrics(prometheus.DefaultRegisterer))
+	modelCatalog := ai.NewModelCatalog(modelChain.Members()...)
+	modelChain.SetModelCatalog(modelCatalog)
+	if appContainer.Config().AI.AdaptiveRouting {
+		if err := modelChain.SetAdaptive(ai.DefaultAdaptivePolicy); err != nil {
+			log.Fatal("invalid adaptive routing policy", "error", err)
+		}
+	}
+	modelChain.SetBreakers(breakerRoutes(e, appContainer, sharedRedis, ledger, modelChain))
+	if len(modelChain.Members()) == 0 {
+		capabilityRegistry.Disable(capabilities.Models, "no model provider is configured",
+			"set ANTHROPIC_API_KEY or GOOGLE_API_KEY, or pass --risk-review-model to use a local Ollama model")
+	} else {
+		capabilityRegistry.Enable(capabilities.Models)
+	}
+	appContainer.Lifecycle().Go("model catalog", func(ctx context.Context) { modelCatalog.Run(ctx, time.Hour) })
+	if model := appContainer.Config().AI.RiskReviewModel; model != "" {
+		reviewer := ai.NewModelRiskReviewer(modelChain, model)
+		planBuilder = planbuilder.NewReviewingBuilder(planBuilder, reviewer)
+	}
+	var promptStore promptcache.Store
+	if sharedRedis != nil {
+		promptStore = promptcache.NewRedisStore(sharedRedis)
+	}
+	promptCache := promptcache.NewBuilder(planBuilder, planCatalog, promptStore, appContainer.Config().AI.CacheTTL, logging.Module("promptcache"))
+	planBuilder = promptCache
+	cacheController := nlpapi.NewCacheController(promptCache, nil)
+	e.GET("api/v1/nlp/cache/preference", cacheController.Get)
+	e.PUT("api/v1/nlp/cache/preference", cacheController.Put)
+	// The guardrail wraps every other builder so prompts are checked before any model sees them.
+	guard := guardrail.NewBuilder(planBuilder, guardrail.DefaultPolicy, nil)
+	planBuilder = guard
+	e.GET("api/v1/nlp/providers", nlpapi.NewProvidersController(modelChain).Handle)
+	e.GET("api/v1/nlp/providers/weights", nlpapi.NewAdaptiveController(modelChain).Handle)
+	e.GET("api/v1/nlp/models", nlpapi.NewModelsController(modelCatalog).Handle)
+	canaryController := nlpapi.NewCanaryController(modelChain, nil)
+	e.GET("api/v1/nlp/providers/:name/canary", canaryController.Get)
+	e.PUT("api/v1/nlp/providers/:name/canary", canaryController.Put)
+	e.POST("api/v1/nlp/providers/:name/canary/promote", canaryController.Promote)
+	e.POST("api/v1/nlp/providers/:name/canary/abort", canaryController.Abort)
+	quota, err := ai.NewQuota(ai.QuotaPolicy{
+		Capacity:        appContainer.Config().AI.QuotaCapacity,
+		RefillPerMinute: appContainer.Config().AI.QuotaRefillPerMinute,
+	})
+	if err != nil {
+		log.Fatal("invalid model quota", "error", err)
+	}
+	modelChain.SetQuota(quota)
+	quotaController := nlpapi.NewQuotaController(quota, nil)
+	e.GET("api/v1/nlp/quota", quotaController.Get)
+	e.GET("api/v1/admin/nlp/quota", quotaController.Overview)
+	e.PUT("api/v1/admin/nlp/quota", quotaController.SetDefault)
+	e.PUT("api/v1/admin/nlp/quota/users/:user", quotaController.SetUser)
+	e.DELETE("api/v1/admin/nlp/quota/users/:user", quotaController.RemoveUser)
+	userKeys := userKeyRoutes(e, appContainer)
+	if userKeys != nil {
+		modelChain.SetUserKeys(userKeys)
+	}
+	budgets, allocator := costRoutes(e, appContainer, ledger, dispatcher, modelChain)
+	monitor := statusRoutes(e, appContainer, modelChain)
+	promptController := promptapi.NewPromptController(planBuilder, metricsRecorder, planRepo, nil)
+	chatFiles := chatAttachmentRoutes(e, appContainer)
+	promptController.SetAttachments(chatFiles)
+	chatService := chatSessionRoutes(e, appContainer)
+	promptController.SetSessions(chatService)
+	promptController.SetBudgets(budgets)
+	if cfg.Enabled(config.SubsystemDiagnostics) {
+		promptController.SetDiagnostics(diagnosticsRoutes(e, appContainer, clusterManager))
+	}
+	if cfg.PrometheusURL != "" {
+		promptController.SetUsage(prometheusRoutes(e, appContainer))
+	}
 	planQueryController := promptapi.NewPlanQueryController(planRepo, nil)
 	planUpdateController := promptapi.NewPlanUpdateController(planRepo, sseServer, nil)
 
@@ -128,13 +350,1480 @@ func ConfigureRoutes(e *echo.Echo, appContainer container.Container) {
 	e.POST("api/v1/portforwards", portforward.NewPortForwardingHandler(appContainer, base.Create))
 	e.DELETE("api/v1/portforwards", portforward.NewPortForwardingHandler(appContainer, base.Delete))
 
+	tracker := inventory.NewTracker(func(config, cluster string) kubernetes.Interface {
+		return appContainer.ClientSet(config, cluster)
+	}, 48*time.Hour, nil, nil)
+	appContainer.Lifecycle().Go("inventory tracker", func(ctx context.Context) { tracker.Run(ctx, 15*time.Minute) })
+	rightSizer := rightsizing.NewSampler(func(config, cluster string) (kubernetes.Interface, metricsclient.Interface) {
+		if metrics := appContainer.MetricClient(config, cluster); metrics != nil {
+			return appContainer.ClientSet(config, cluster), metrics
+		}
+		return appContainer.ClientSet(config, cluster), nil
+	}, 7*24*time.Hour, nil)
+	appContainer.Lifecycle().Go("right-sizing sampler", func(ctx context.Context) { rightSizer.Run(ctx, 5*time.Minute) })
+
+	if cfg.Enabled(config.SubsystemSandboxes) {
+		sandboxRoutes(e, appContainer)
+	}
+	mappers := restMappers(appContainer)
+	commandService := commandRoutes(e, appContainer, clusterManager, mappers, stepUp, roleService)
+	commandService.SetApprovalNotifier(commands.NewAlertNotifier(dispatcher, logging.Module("commands")))
+	if cfg.Enabled(config.SubsystemWatch) {
+		watchRoutes(e, appContainer, mappers, roleService)
+	}
+	commandService.SetManifestSource(chatFiles)
+	commandService.SetPlanReviews(planReviews{plans: planRepo})
+	commandService.SetRoleChecker(roleService)
+	commandService.SetLedger(ledger)
+	commandService.SetObserver(anomaly.CommandObserver(detector))
+	commandService.SetArchiveStore(archiveStore(appContainer))
+	// Executions carry the events of the objects they read or changed, explained by a model when
+	// one is configured.
+	var eventSummarizer commands.EventSummarizer
+	if len(modelChain.Members()) > 0 {
+		eventSummarizer = ai.NewEventSummarizer(modelChain, "")
+	}
+	commandService.SetEventCorrelation(eventSummarizer)
+	guard.SetAuditor(commandService)
+	e.GET("api/v1/audit/correlations/:id", correlationapi.NewCorrelationController(correlation.NewTracer(chatService, planRepo, commandService), nil).Trace)
+	e.GET("api/v1/changes", changesapi.NewChangesController(tracker, nil).Handle)
+	var cached []echo.MiddlewareFunc
+	if cache := responseCache(appContainer, sharedRedis); cache != nil {
+		commandService.SetInvalidator(cache)
+		cached = append(cached, appmiddleware.ResponseCacheMiddleware(cache))
+	}
+	e.GET("api/v1/kubernetes/restarts", restartsapi.NewRestartsController(tracker, nil).Handle, cached...).Name = "containerRestarts"
+	e.GET("api/v1/kubernetes/rightsizing", rightsizingapi.NewRecommendationsController(rightSizer, nil).Handle, cached...).Name = "workloadRightSizing"
+	logSearcher := logs.NewSearcher(func(config, cluster string) kubernetes.Interface {
+		return appContainer.ClientSet(config, cluster)
+	})
+	e.GET("api/v1/logs", logsapi.NewSearchController(logSearcher, nil).Handle)
+	logFollower := logsapi.NewFollowController(logSearcher, appContainer.SocketUpgrader(), nil)
+	logFollower.SetConnections(telemetry.NewWebSocketConnections(prometheus.DefaultRegisterer).WithLabelValues("logs"))
+	e.GET("api/v1/logs/stream", logFollower.Stream)
+	e.GET("api/v1/logs/ws", logFollower.Socket)
+	if cfg.Enabled(config.SubsystemReports) {
+		reportRoutes(e, appContainer, tracker, rightSizer)
+	}
+	retentionTargets := []retention.Target{
+		retention.ExecutionsTarget(commandService),
+		retention.HealthHistoryTarget(monitor.History()),
+		retention.ChangeHistoryTarget(tracker),
+	}
+	if archiver != nil {
+		retentionTargets = append(retentionTargets, retention.AuditLedgerTarget(archiver))
+	}
+	retentionRoutes(e, appContainer, signer, retentionTargets...)
+	privacyHolders := []privacy.Holder{
+		privacy.ChatSessionsHolder(chatService),
+		privacy.ExecutionsHolder(commandService),
+		privacy.AuditLedgerHolder(ledger),
+		privacy.UserDirectoryHolder(directory),
+	}
+	if userKeys != nil {
+		privacyHolders = append(privacyHolders, privacy.UserKeysHolder(userKeys))
+	}
+	privacyRoutes(e, appContainer, ledger, roleService, privacyHolders...)
+	analyzer := complianceRoutes(e, appContainer, ledger, roleService, stepUp, impersonator, commandService)
+	analytics := privacy.Policy{
+		AggregateOnly: appContainer.Config().AnalyticsAggregateOnly,
+		MinGroupSize:  appContainer.Config().AnalyticsMinGroupSize,
+	}
+	if cfg.Enabled(config.SubsystemDashboards) {
+		dashboardRoutes(e, appContainer,
+			dashboards.HealthSource(monitor),
+			dashboards.RestartsSource(tracker),
+			dashboards.ChangesSource(tracker),
+			dashboards.ExecutionsSource(commandService, analytics),
+			dashboards.ModelCostSource(modelChain, analytics),
+		)
+	}
+	if cfg.Enabled(config.SubsystemExports) {
+		exportRoutes(e, appContainer, analytics, roleService,
+			exports.ExecutionsSource(commandService),
+			exports.ExecutionCountsSource(commandService, analytics),
+			exports.ModelCostSource(modelChain, analytics),
+			exports.ChangesSource(tracker),
+			exports.AuditLedgerSource(ledger),
+			exports.ComplianceFindingsSource(analyzer),
+			exports.ChargebackSource(allocator),
+		)
+	}
+	securityRoutes(e, appContainer)
+	if cfg.Enabled(config.SubsystemUpgrades) {
+		upgradeRoutes(e, appContainer, clusterManager)
+	}
 	accessControlRoutes(e, appContainer)
 	workloadRoutes(e, appContainer)
 	configRoutes(e, appContainer)
 	storageRoutes(e, appContainer)
 	servicesRoutes(e, appContainer)
 	customResources(e, appContainer)
-	mcp.Server(e, appContainer)
+	if cfg.Enabled(config.SubsystemMCP) {
+		mcp.Server(e, appContainer)
+	}
+	openAPIRoutes(e, appContainer)
+}
+
+// modelProviders builds the model fallback chain: the local Ollama model when risk review is
+// enabled, then each hosted provider with credentials in the environment.
+func modelProviders(appContainer container.Container) *ai.FallbackChain {
+	aiCfg := appContainer.Config().AI
+	var providers []ai.Provider
+	if aiCfg.RiskReviewModel != "" {
+		providers = append(providers, ai.NewOllamaProvider(aiCfg.OllamaURL, aiCfg.RiskReviewModel, nil))
+	}
+	if aiCfg.AnthropicAPIKey != "" {
+		providers = append(providers, ai.NewAnthropicProvider("", aiCfg.AnthropicAPIKey, aiCfg.AnthropicModel, nil))
+	}
+	if aiCfg.GoogleAPIKey != "" {
+		providers = append(providers, ai.NewGeminiProvider("", aiCfg.GoogleAPIKey, aiCfg.GeminiModel, nil))
+	}
+	if aiCfg.OpenAIAPIKey != "" {
+		providers = append(providers, ai.NewOpenAIProvider("", aiCfg.OpenAIAPIKey, aiCfg.OpenAIModel, nil))
+	}
+	return ai.NewFallbackChain(providers...)
+}
+
+// rateLimiter limits requests under the configured policy, shared through Redis when configured.
+// trustedProxies parses the CIDR ranges of the reverse proxies in front of KubeChat.
+func trustedProxies(ranges []string) []*net.IPNet {
+	var proxies []*net.IPNet
+	for _, cidr := range ranges {
+		_, proxy, err := net.ParseCIDR(cidr)
+		if err != nil {
+			log.Fatal("invalid trusted proxy range", "range", cidr, "error", err)
+		}
+		proxies = append(proxies, proxy)
+	}
+	return proxies
+}
+
+// ipExtractor takes the client IP from X-Forwarded-For only as far back as the trusted proxies
+// reach, and from the connection itself when there are none.
+func ipExtractor(proxies []*net.IPNet) echo.IPExtractor {
+	if len(proxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, proxy := range proxies {
+		options = append(options, echo.TrustIPRange(proxy))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
+func rateLimiter(appContainer container.Container, client *redis.Client) *ratelimit.Limiter {
+	policy := ratelimit.DefaultPolicy
+	if path := appContainer.Config().RateLimitPolicyFile; path != "" {
+		loaded, err := ratelimit.LoadPolicy(path)
+		if err != nil {
+			log.Fatal("failed to load rate limit policy", "path", path, "error", err)
+		}
+		policy = loaded
+	}
+	var store ratelimit.Store
+	if client != nil {
+		store = ratelimit.NewRedisStore(client)
+	}
+	return ratelimit.NewLimiter(policy, store, logging.Module("ratelimit"))
+}
+
+// responseCache keeps the responses of expensive Kubernetes reads for a short time, shared through
+// Redis when it is configured, or returns nil when caching is off.
+func responseCache(appContainer container.Container, client *redis.Client) *httpcache.Cache {
+	ttl := appContainer.Config().KubernetesCacheTTL
+	if ttl <= 0 {
+		return nil
+	}
+	var store httpcache.Store
+	if client != nil {
+		store = httpcache.NewRedisStore(client)
+	}
+	cache := httpcache.NewCache(ttl, store, logging.Module("httpcache"))
+	cache.SetMetrics(telemetry.NewCacheMetrics(prometheus.DefaultRegisterer))
+	return cache
+}
+
+// redisClient connects to the Redis server replicas share rate limits and circuit breakers
+// through, or returns nil when none is configured.
+func redisClient(appContainer container.Container) *redis.Client {
+	redisURL := appContainer.Config().RedisURL
+	if redisURL == "" {
+		return nil
+	}
+	client, err := redis.NewClient(redisURL)
+	if err != nil {
+		log.Fatal("invalid redis URL", "error", err)
+	}
+	if err := client.Ping(context.Background()); err != nil {
+		log.Warn("redis unavailable, limiting requests and tripping breakers per replica until it is reachable", "error", err)
+	}
+	return client
+}
+
+// breakerRoutes lets admins inspect, force open and reset the circuit breakers of the model
+// providers, and returns the breakers for the model chain. Overrides are recorded in the ledger.
+func breakerRoutes(e *echo.Echo, appContainer container.Container, client *redis.Client, ledger *audit.Ledger, modelChain *ai.FallbackChain) *breaker.Registry {
+	cfg := appContainer.Config()
+	var store breaker.Store
+	if client != nil {
+		store = breaker.NewRedisStore(client)
+	}
+	registry := breaker.NewRegistry(breaker.Settings{FailureThreshold: cfg.BreakerFailures, Window: cfg.BreakerWindow, OpenFor: cfg.BreakerOpenFor}, store, logging.Module("breaker"))
+	registry.SetLedger(ledger)
+	for _, provider := range modelChain.Members() {
+		registry.Register(ai.BreakerUpstream(provider.Name()))
+	}
+	controller := breakersapi.NewBreakerController(registry, logging.Module("breaker"))
+	e.GET("api/v1/admin/breakers", controller.List)
+	e.GET("api/v1/admin/breakers/:upstream", controller.Get)
+	e.POST("api/v1/admin/breakers/:upstream/open", controller.Open)
+	e.POST("api/v1/admin/breakers/:upstream/reset", controller.Reset)
+	return registry
+}
+
+// statusRoutes exposes the unauthenticated public status summary. Responses are
+// cached and requests are limited per client IP by the status group of the rate limit policy.
+func statusRoutes(e *echo.Echo, appContainer container.Container, modelChain *ai.FallbackChain) *health.Monitor {
+	checkers := []health.Checker{health.APICheck()}
+	if db := appContainer.DB(); db != nil {
+		checkers = append(checkers, health.DatabaseCheck(db))
+	}
+	for _, provider := range modelChain.Members() {
+		if checker, ok := provider.(ai.HealthChecker); ok {
+			checkers = append(checkers, health.CheckFunc{Component: "provider:" + provider.Name(), Fn: checker.HealthCheck})
+		}
+	}
+	monitor := health.NewMonitor(health.NewHistory(7*24*time.Hour), nil, checkers...)
+	appContainer.Lifecycle().Go("health monitor", func(ctx context.Context) { monitor.Run(ctx, 30*time.Second) })
+
+	e.GET("/status", statusapi.NewStatusController(monitor, 15*time.Second).Handle)
+	return monitor
+}
+
+func sandboxRoutes(e *echo.Echo, appContainer container.Container) {
+	sandboxManager := sandbox.NewManager(func(config, cluster string) kubernetes.Interface {
+		return appContainer.ClientSet(config, cluster)
+	}, 30*time.Minute, nil)
+	appContainer.Lifecycle().GoSingleton("sandbox reaper", func(ctx context.Context) { sandboxManager.Run(ctx, time.Minute) })
+
+	sandboxController := sandboxapi.NewSandboxController(sandboxManager, nil)
+	e.POST("api/v1/sandboxes", sandboxController.Create)
+	e.GET("api/v1/sandboxes", sandboxController.List)
+	e.GET("api/v1/sandboxes/:id", sandboxController.Get)
+	e.POST("api/v1/sandboxes/:id/verify", sandboxController.Verify)
+	e.POST("api/v1/sandboxes/:id/promote", sandboxController.Promote)
+	e.DELETE("api/v1/sandboxes/:id", sandboxController.Delete)
+}
+
+// planReviews tells the command service which plan drafts the second-opinion risk review disputed.
+// Submissions that do not name a stored draft carry no review.
+type planReviews struct {
+	plans *planrepository.PlanRepository
+}
+
+func (p planReviews) RequiresApproval(ctx context.Context, draftID string) (bool, error) {
+	record, err := p.plans.Get(ctx, draftID)
+	var missing planrepository.ErrPlanNotFound
+	if errors.As(err, &missing) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return record.Plan.RequiresApproval, nil
+}
+
+// kubeConfigCatalog exposes the kubeconfigs loaded by the app config to the cluster manager.
+type kubeConfigCatalog struct {
+	appContainer container.Container
+}
+
+func (k kubeConfigCatalog) Contexts() []clusters.Context {
+	var contexts []clusters.Context
+	for configName, kubeCfg := range k.appContainer.Config().KubeConfig {
+		if kubeCfg == nil {
+			continue
+		}
+		for clusterName, cluster := range kubeCfg.Clusters {
+			if cluster == nil {
+				continue
+			}
+			kc := clusters.Context{Config: configName, Context: clusterName, Connected: cluster.IsConnected()}
+			if cluster.RestConfig != nil {
+				kc.Server = cluster.RestConfig.Host
+			}
+			contexts = append(contexts, kc)
+		}
+	}
+	return contexts
+}
+
+func (k kubeConfigCatalog) Client(configName, clusterName string) (kubernetes.Interface, bool) {
+	kubeCfg := k.appContainer.Config().KubeConfig[configName]
+	if kubeCfg == nil || kubeCfg.Clusters[clusterName] == nil {
+		return nil, false
+	}
+	client := kubeCfg.Clusters[clusterName].GetClientSet()
+	return client, client != nil
+}
+
+func (k kubeConfigCatalog) Put(id string, kubeconfig []byte) error {
+	return k.appContainer.Config().PutKubeConfig(id, kubeconfig)
+}
+
+func (k kubeConfigCatalog) Remove(id string) error {
+	if err := k.appContainer.Config().RemoveKubeConfig(id); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func clusterRoutes(e *echo.Echo, appContainer container.Container) *clusters.Manager {
+	var repo clusters.Repository = clusters.NewMemoryRepository()
+	if db := appContainer.DB(); db != nil {
+		pgRepo, err := clusters.NewPostgresRepository(context.Background(), db)
+		if err != nil {
+			log.Fatal("failed to prepare cluster store", "error", err)
+		}
+		repo = pgRepo
+	}
+	manager := clusters.NewManager(repo, kubeConfigCatalog{appContainer: appContainer}, nil)
+	if err := manager.Restore(context.Background()); err != nil {
+		log.Error("failed to restore registered clusters", "error", err)
+	}
+	appContainer.Lifecycle().Go("cluster health", func(ctx context.Context) { manager.Run(ctx, time.Minute) })
+
+	controller := clustersapi.NewClusterController(manager, nil)
+	e.GET("api/v1/clusters", controller.List)
+	e.POST("api/v1/clusters", controller.Create)
+	e.GET("api/v1/clusters/:id", controller.Get)
+	e.PUT("api/v1/clusters/:id", controller.Update)
+	e.DELETE("api/v1/clusters/:id", controller.Delete)
+	e.GET("api/v1/clusters/:id/health", controller.Health)
+	return manager
+}
+
+// upgradeRoutes answers what breaks when clusters are upgraded, from their versions and the
+// deprecated APIs their objects were written through.
+func upgradeRoutes(e *echo.Echo, appContainer container.Container, clusterManager *clusters.Manager) {
+	advisor := upgrades.NewAdvisor(func(config, cluster string) (kubernetes.Interface, dynamic.Interface, error) {
+		kubeConfig := appContainer.Config().KubeConfig[config]
+		if kubeConfig == nil || kubeConfig.Clusters[cluster] == nil {
+			return nil, nil, fmt.Errorf("cluster %s/%s is not loaded", config, cluster)
+		}
+		return appContainer.ClientSet(config, cluster), appContainer.DynamicClient(config, cluster), nil
+	})
+	controller := upgradesapi.NewAdviceController(advisor, clusterManager, nil)
+	e.GET("api/v1/upgrades/advice", controller.List)
+	e.GET("api/v1/upgrades/advice/:cluster", controller.Get)
+}
+
+// diagnosticsRoutes serves the troubleshooting playbooks and returns their runner, which also
+// answers diagnostic prompts.
+func diagnosticsRoutes(e *echo.Echo, appContainer container.Container, clusterManager *clusters.Manager) *diagnostics.Runner {
+	runner := diagnostics.NewRunner(func(config, cluster string) (kubernetes.Interface, metricsclient.Interface, error) {
+		kubeConfig := appContainer.Config().KubeConfig[config]
+		if kubeConfig == nil || kubeConfig.Clusters[cluster] == nil {
+			return nil, nil, fmt.Errorf("cluster %s/%s is not loaded", config, cluster)
+		}
+		if metrics := appContainer.MetricClient(config, cluster); metrics != nil {
+			return appContainer.ClientSet(config, cluster), metrics, nil
+		}
+		return appContainer.ClientSet(config, cluster), nil, nil
+	}, clusterManager)
+	controller := diagnosticsapi.NewPlaybookController(runner, clusterManager, nil)
+	e.GET("api/v1/diagnostics/playbooks", controller.List)
+	e.GET("api/v1/diagnostics/clusters/:cluster", controller.Run)
+	return runner
+}
+
+// customResourceIndexRoutes serves the custom resource definitions indexed for each cluster and
+// returns the index, which also lets plans read the custom resources prompts name.
+func customResourceIndexRoutes(e *echo.Echo, appContainer container.Container, clusterManager *clusters.Manager) *customresources.Index {
+	index := customresources.NewIndex(func(config, cluster string) (apiextensionsclientset.Interface, error) {
+		restConfig := appContainer.RestConfig(config, cluster)
+		if restConfig == nil {
+			return nil, fmt.Errorf("cluster %s/%s is not loaded", config, cluster)
+		}
+		return apiextensionsclientset.NewForConfig(restConfig)
+	}, clusterManager, 5*time.Minute)
+	controller := customresourcesapi.NewDefinitionController(index, clusterManager, nil)
+	e.GET("api/v1/crdindex/clusters/:cluster", controller.List)
+	return index
+}
+
+// prometheusRoutes serves resource usage queries against the configured Prometheus and returns the
+// answerer that also answers usage prompts.
+func prometheusRoutes(e *echo.Echo, appContainer container.Container) *promusage.Answerer {
+	limits := promusage.DefaultLimits
+	limits.MaxRange = appContainer.Config().PrometheusMaxRange
+	client, err := promusage.NewClient(appContainer.Config().PrometheusURL, limits, nil)
+	if err != nil {
+		log.Fatal("invalid Prometheus URL", "error", err)
+	}
+	answerer := promusage.NewAnswerer(client)
+	controller := prometheusapi.NewQueryController(client, answerer, nil)
+	e.GET("api/v1/prometheus/usage", controller.Usage)
+	e.GET("api/v1/prometheus/query", controller.Query)
+	return answerer
+}
+
+func commandRoutes(e *echo.Echo, appContainer container.Container, clusterManager *clusters.Manager, mappers commands.MapperFunc, stepUp *mfa.Service, authorizer commandsapi.Authorizer) *commands.Service {
+	var repo commands.Repository = commands.NewMemoryRepository()
+	if db := appContainer.DB(); db != nil {
+		pgRepo, err := commands.NewPostgresRepository(context.Background(), db)
+		if err != nil {
+			log.Fatal("failed to prepare command execution store", "error", err)
+		}
+		repo = pgRepo
+	}
+	service := commands.NewService(repo, func(config, cluster string) kubernetes.Interface {
+		return appContainer.ClientSet(config, cluster)
+	}, logging.Module("commands"))
+	service.SetMetrics(telemetry.NewCommandMetrics(prometheus.DefaultRegisterer))
+	webhooks := commands.NewWebhookRegistry(nil)
+	service.SetWebhookRegistry(webhooks)
+	service.SetClusterResolver(clusterManager)
+	if appContainer.Config().ImpersonateUsers {
+		service.SetImpersonation(func(config, cluster string, subject commands.Subject) (kubernetes.Interface, error) {
+			restConfig, err := impersonatedConfig(appContainer, config, cluster, subject)
+			if err != nil {
+				return nil, err
+			}
+			return kubernetes.NewForConfig(restConfig)
+		})
+	}
+	service.SetDynamicClients(func(config, cluster string, subject *commands.Subject) (dynamic.Interface, error) {
+		if subject == nil {
+			return appContainer.DynamicClient(config, cluster), nil
+		}
+		restConfig, err := impersonatedConfig(appContainer, config, cluster, *subject)
+		if err != nil {
+			return nil, err
+		}
+		return dynamic.NewForConfig(restConfig)
+	}, mappers)
+	service.SetMetricsClients(func(config, cluster string, subject *commands.Subject) (metricsclient.Interface, error) {
+		if subject != nil {
+			restConfig, err := impersonatedConfig(appContainer, config, cluster, *subject)
+			if err != nil {
+				return nil, err
+			}
+			return metricsclient.NewForConfig(restConfig)
+		}
+		if metrics := appContainer.MetricClient(config, cluster); metrics != nil {
+			return metrics, nil
+		}
+		return nil, fmt.Errorf("cluster %s/%s has no metrics client", config, cluster)
+	})
+	if path := appContainer.Config().ApprovalPolicyFile; path != "" {
+		policies, err := commands.LoadApprovalPolicies(path)
+		if err != nil {
+			log.Fatal("failed to load approval policies", "path", path, "error", err)
+		}
+		service.SetApprovalPolicies(policies)
+	}
+	if path := appContainer.Config().ResourcePolicyFile; path != "" {
+		policy, err := commands.LoadResourcePolicy(path)
+		if err != nil {
+			log.Fatal("failed to load resource policy", "path", path, "error", err)
+		}
+		service.SetResourcePolicy(policy)
+	}
+	if url := appContainer.Config().OPADecisionURL; url != "" {
+		authorizer, err := commands.NewOPAAuthorizer(url, appContainer.Config().OPACacheTTL, nil)
+		if err != nil {
+			log.Fatal("invalid OPA decision URL", "error", err)
+		}
+		service.SetAuthorizer(authorizer)
+	}
+	if path := appContainer.Config().NamespaceTemplateFile; path != "" {
+		templates, err := commands.LoadNamespaceTemplates(path)
+		if err != nil {
+			log.Fatal("failed to load namespace templates", "path", path, "error", err)
+		}
+		service.SetNamespaceTemplates(templates)
+	}
+	if cluster := appContainer.Config().SafetyPolicyCluster; cluster != "" {
+		loader, err := commands.NewSafetyPolicyLoader(service, func(ctx context.Context) (kubernetes.Interface, dynamic.Interface, error) {
+			config, name, err := clusterManager.Resolve(ctx, cluster)
+			if err != nil {
+				return nil, nil, err
+			}
+			return appContainer.ClientSet(config, name), appContainer.DynamicClient(config, name), nil
+		}, appContainer.Config().SafetyPolicyConfigMap, nil)
+		if err != nil {
+			log.Fatal("invalid safety policy source", "error", err)
+		}
+		appContainer.Lifecycle().Go("safety policy loader", func(ctx context.Context) { loader.Run(ctx, 30*time.Second) })
+	}
+	service.SetAttachmentStore(attachmentStore(appContainer), appContainer.Config().AttachmentThreshold)
+	appContainer.Lifecycle().GoSingleton("approval processor", func(ctx context.Context) { service.Run(ctx, time.Minute) })
+	appContainer.Lifecycle().GoSingleton("command scheduler", func(ctx context.Context) { service.RunSchedules(ctx, 15*time.Second) })
+	appContainer.Lifecycle().GoSingleton("change window release", func(ctx context.Context) { service.RunQueued(ctx, time.Minute) })
+
+	terraformState, err := iac.LoadStateIndex(appContainer.Config().TerraformStateFiles...)
+	if err != nil {
+		log.Fatal("failed to load terraform state", "error", err)
+	}
+	ownership := iac.NewDetector(func(config, cluster string) kubernetes.Interface {
+		return appContainer.ClientSet(config, cluster)
+	}, terraformState)
+	service.SetDriftGuard(iac.NewCommandGuard(ownership))
+	commandQuota, err := commands.NewCommandQuota(commands.CommandLimits{
+		DestructivePerHour: appContainer.Config().CommandDestructivePerHour,
+		MaxConcurrent:      appContainer.Config().CommandMaxConcurrent,
+	})
+	if err != nil {
+		log.Fatal("invalid command limits", "error", err)
+	}
+	service.SetCommandQuota(commandQuota)
+
+	executionController := commandsapi.NewExecutionController(service, nil)
+	executionController.SetAuthorizer(authorizer)
+	e.POST("api/v1/commands/preview", executionController.Preview)
+	e.POST("api/v1/commands/executions", executionController.Create)
+	e.GET("api/v1/commands/executions", executionController.List)
+	e.GET("api/v1/commands/executions/:id", executionController.Get)
+	e.DELETE("api/v1/commands/executions/:id", executionController.Delete)
+	var approve []echo.MiddlewareFunc
+	if appContainer.Config().MFAStepUpWindow > 0 {
+		approve = append(approve, appmiddleware.StepUpMiddleware(stepUp, func(c echo.Context) (bool, error) {
+			execution, err := service.Get(c.Request().Context(), c.Param("id"))
+			if errors.As(err, &commands.ErrExecutionNotFound{}) {
+				return false, nil
+			}
+			return execution.SafetyLevel == commands.SafetyDangerous, err
+		}))
+	}
+	e.POST("api/v1/commands/executions/:id/approve", executionController.Approve, approve...)
+	// Approval links are only sent when the address approvers reach the server at is known.
+	if publicURL := appContainer.Config().PublicURL; publicURL != "" {
+		links, err := commands.NewApprovalLinks([]byte(appContainer.Config().ApprovalLinkKey), publicURL)
+		if err != nil {
+			log.Warn("approval links disabled; set KUBECHAT_APPROVAL_LINK_KEY to send them", "error", err)
+		} else {
+			service.SetApprovalLinks(links)
+		}
+	}
+	linkController := commandsapi.NewApprovalLinkController(service, nil)
+	var decide []echo.MiddlewareFunc
+	if appContainer.Config().MFAStepUpWindow > 0 {
+		decide = append(decide, appmiddleware.StepUpMiddleware(stepUp, func(c echo.Context) (bool, error) {
+			link, execution, _, err := service.ApprovalByLink(c.Request().Context(), c.Param("token"))
+			if err != nil {
+				// The handler refuses the link itself.
+				return false, nil
+			}
+			return link.Action == commands.LinkApprove && execution.SafetyLevel == commands.SafetyDangerous, nil
+		}))
+	}
+	e.GET("api/v1/commands/approvals/links/:token", linkController.Get)
+	e.POST("api/v1/commands/approvals/links/:token", linkController.Decide, decide...)
+	e.POST("api/v1/commands/executions/:id/reject", executionController.Reject)
+	e.POST("api/v1/commands/executions/:id/rollback", executionController.Rollback)
+	e.POST("api/v1/commands/executions/:id/override-freeze", executionController.OverrideFreeze)
+	e.GET("api/v1/commands/executions/:id/approval", executionController.Approval)
+	e.GET("api/v1/commands/executions/:id/explanation", executionController.Explain)
+	e.GET("api/v1/commands/executions/:id/attachments/:attachmentId", executionController.Attachment)
+	e.GET("api/v1/commands/approvals", executionController.Approvals)
+	e.GET("api/v1/commands/approvals/pending", executionController.PendingApprovals)
+	e.GET("api/v1/commands/approvals/assigned", executionController.AssignedApprovals)
+	e.GET("api/v1/commands/delegations", executionController.Delegations)
+	e.POST("api/v1/commands/delegations", executionController.Delegate)
+	e.DELETE("api/v1/commands/delegations/:id", executionController.RevokeDelegation)
+	e.POST("api/v1/commands/plans", executionController.CreatePlan)
+	e.GET("api/v1/commands/schedules", executionController.Schedules)
+	e.POST("api/v1/commands/schedules", executionController.Schedule)
+	e.GET("api/v1/commands/schedules/:id", executionController.GetSchedule)
+	e.DELETE("api/v1/commands/schedules/:id", executionController.CancelSchedule)
+	e.GET("api/v1/commands/change-windows", executionController.ChangeWindows)
+	e.GET("api/v1/commands/change-windows/calendar", executionController.ChangeWindowCalendar)
+	e.POST("api/v1/admin/commands/change-windows", executionController.DefineChangeWindow)
+	e.DELETE("api/v1/admin/commands/change-windows/:id", executionController.DeleteChangeWindow)
+	e.GET("api/v1/commands/safety-policy", executionController.SafetyPolicy)
+	e.GET("api/v1/commands/namespace-template", executionController.NamespaceTemplate)
+	e.GET("api/v1/audit/resources/:kind/:namespace/:name", executionController.ResourceHistory)
+
+	quotaController := commandsapi.NewQuotaController(commandQuota, nil)
+	e.GET("api/v1/commands/quota", quotaController.Get)
+	e.GET("api/v1/admin/commands/quota", quotaController.Overview)
+	e.PUT("api/v1/admin/commands/quota", quotaController.SetDefault)
+	e.PUT("api/v1/admin/commands/quota/users/:user", quotaController.SetUser)
+	e.DELETE("api/v1/admin/commands/quota/users/:user", quotaController.RemoveUser)
+	e.POST("api/v1/admin/commands/freezes", quotaController.Freeze)
+	e.DELETE("api/v1/admin/commands/freezes/:namespace", quotaController.Unfreeze)
+
+	ownershipController := iacapi.NewOwnershipController(ownership, nil)
+	e.GET("api/v1/iac/ownership", ownershipController.Ownership)
+	e.POST("api/v1/iac/suggestions", ownershipController.Suggest)
+
+	executorController := commandsapi.NewExecutorController(webhooks, nil)
+	e.POST("api/v1/commands/executors", executorController.Register)
+	e.GET("api/v1/commands/executors", executorController.List)
+	e.DELETE("api/v1/commands/executors/:operation", executorController.Delete)
+
+	releaseController := helmapi.NewReleaseController(service, nil)
+	e.GET("api/v1/helm/releases", releaseController.List)
+	e.GET("api/v1/helm/releases/:name/history", releaseController.History)
+	e.GET("api/v1/helm/releases/:name/values", releaseController.Values)
+	e.GET("api/v1/helm/releases/:name/manifest", releaseController.Manifest)
+	e.POST("api/v1/helm/releases/:name/upgrade/preview", releaseController.PreviewUpgrade)
+	e.POST("api/v1/helm/releases/:name/rollback/preview", releaseController.PreviewRollback)
+	e.POST("api/v1/helm/releases/:name/upgrade", releaseController.Upgrade)
+	e.POST("api/v1/helm/releases/:name/rollback", releaseController.Rollback)
+	return service
+}
+
+// impersonatedConfig copies the cluster's rest config so that requests are made as subject.
+func impersonatedConfig(appContainer container.Container, config, cluster string, subject commands.Subject) (*rest.Config, error) {
+	restConfig := appContainer.RestConfig(config, cluster)
+	if restConfig == nil {
+		return nil, fmt.Errorf("cluster %s/%s is not loaded", config, cluster)
+	}
+	restConfig = rest.CopyConfig(restConfig)
+	restConfig.Impersonate = rest.ImpersonationConfig{UserName: subject.User, Groups: subject.Groups}
+	return restConfig, nil
+}
+
+// attachmentStore opens the configured store for large files, defaulting to a local directory.
+func attachmentStore(appContainer container.Container) attachments.Store {
+	storeURL := appContainer.Config().AttachmentStore
+	if storeURL == "" {
+		storeURL = config.DefaultAttachmentDir()
+	}
+	store, err := attachments.Open(storeURL)
+	if err != nil {
+		log.Fatal("failed to open attachment store", "error", err)
+	}
+	return store
+}
+
+// archiveStore opens the store retention archives records to before deleting them, defaulting
+// to the attachment store.
+func archiveStore(appContainer container.Container) attachments.Store {
+	storeURL := appContainer.Config().AuditArchiveStore
+	if storeURL == "" {
+		return attachmentStore(appContainer)
+	}
+	store, err := attachments.Open(storeURL)
+	if err != nil {
+		log.Fatal("failed to open archive store", "error", err)
+	}
+	return store
+}
+
+func exportRoutes(e *echo.Echo, appContainer container.Container, policy privacy.Policy, authorizer exportsapi.Authorizer, sources ...exports.Source) {
+	manager := exports.NewManager(attachmentStore(appContainer), exports.NewSigner([]byte(appContainer.Config().ExportSigningKey)), logging.Module("exports"), sources...)
+	manager.SetPolicy(policy)
+	telemetry.RegisterQueueDepth(prometheus.DefaultRegisterer, "exports", manager.QueueDepth)
+	appContainer.Lifecycle().Go("export worker", func(ctx context.Context) { manager.Run(ctx, 10*time.Minute) })
+
+	controller := exportsapi.NewExportController(manager, nil)
+	controller.SetAuthorizer(authorizer)
+	e.GET("api/v1/exports/sources", controller.Sources)
+	e.POST("api/v1/exports", controller.Create)
+	e.GET("api/v1/exports", controller.List)
+	e.GET("api/v1/exports/:id", controller.Get)
+	e.DELETE("api/v1/exports/:id", controller.Delete)
+	e.GET("api/v1/exports/:id/download", controller.Download)
+}
+
+func dashboardRoutes(e *echo.Echo, appContainer container.Container, sources ...dashboards.Source) {
+	var repo dashboards.Repository = dashboards.NewMemoryRepository()
+	if db := appContainer.DB(); db != nil {
+		pgRepo, err := dashboards.NewPostgresRepository(context.Background(), db)
+		if err != nil {
+			log.Fatal("failed to prepare dashboard store", "error", err)
+		}
+		repo = pgRepo
+	}
+	controller := dashboardsapi.NewDashboardController(dashboards.NewService(repo, nil, sources...), nil)
+	e.GET("api/v1/dashboards/sources", controller.Sources)
+	e.GET("api/v1/dashboards/widgets", controller.ListWidgets)
+	e.POST("api/v1/dashboards/widgets", controller.CreateWidget)
+	e.GET("api/v1/dashboards/widgets/:id", controller.GetWidget)
+	e.PUT("api/v1/dashboards/widgets/:id", controller.UpdateWidget)
+	e.DELETE("api/v1/dashboards/widgets/:id", controller.DeleteWidget)
+	e.GET("api/v1/dashboards/widgets/:id/data", controller.RunWidget)
+	e.GET("api/v1/dashboards", controller.ListDashboards)
+	e.POST("api/v1/dashboards", controller.CreateDashboard)
+	e.GET("api/v1/dashboards/:id", controller.GetDashboard)
+	e.PUT("api/v1/dashboards/:id", controller.UpdateDashboard)
+	e.DELETE("api/v1/dashboards/:id", controller.DeleteDashboard)
+	e.GET("api/v1/dashboards/:id/data", controller.Render)
+}
+
+// chatAttachmentRoutes accepts manifests and small files for prompts to reference, and sweeps
+// them from the attachment store once they expire.
+func chatAttachmentRoutes(e *echo.Echo, appContainer container.Container) *chatfiles.Service {
+	var repo chatfiles.Repository = chatfiles.NewMemoryRepository()
+	if db := appContainer.DB(); db != nil {
+		pgRepo, err := chatfiles.NewPostgresRepository(context.Background(), db)
+		if err != nil {
+			log.Fatal("failed to prepare chat attachment store", "error", err)
+		}
+		repo = pgRepo
+	}
+	scanners := []chatfiles.Scanner{chatfiles.SecretScanner{}, chatfiles.SignatureScanner{}}
+	if address := appContainer.Config().ClamdAddress; address != "" {
+		scanners = append(scanners, chatfiles.ClamdScanner{Address: address})
+	}
+	maxSize := appContainer.Config().ChatAttachmentMaxSize
+	service := chatfiles.NewService(repo, attachmentStore(appContainer), chatfiles.Options{
+		MaxSize: maxSize,
+		TTL:     appContainer.Config().ChatAttachmentTTL,
+	}, nil, scanners...)
+	appContainer.Lifecycle().GoSingleton("chat attachment sweeper", func(ctx context.Context) { service.Run(ctx, 15*time.Minute) })
+
+	controller := chatfilesapi.NewAttachmentController(service, maxSize, nil)
+	e.POST("api/v1/nlp/attachments", controller.Upload)
+	e.GET("api/v1/nlp/attachments", controller.List)
+	e.GET("api/v1/nlp/attachments/:id", controller.Get)
+	e.GET("api/v1/nlp/attachments/:id/content", controller.Content)
+	e.DELETE("api/v1/nlp/attachments/:id", controller.Delete)
+	return service
+}
+
+// costRoutes prices model calls with the versioned price book, records their usage and rolls it
+// up daily into the totals monthly bills are built from. It returns the budgets that cap the
+// monthly spend, whose enforcement is recorded in the ledger, and the allocator that charges the
+// spend to departments and projects, whose monthly reports are announced through the dispatcher.
+func costRoutes(e *echo.Echo, appContainer container.Container, ledger *audit.Ledger, dispatcher *alerting.Dispatcher, modelChain *ai.FallbackChain) (*costs.BudgetManager, *costs.Allocator) {
+	memory := costs.NewMemoryRepository()
+	var (
+		repo           costs.Repository           = memory
+		priceRepo      costs.PriceRepository      = memory
+		budgetRepo     costs.BudgetRepository     = memory
+		allocationRepo costs.AllocationRepository = memory
+	)
+	if db := appContainer.DB(); db != nil {
+		pgRepo, err := costs.NewPostgresRepository(context.Background(), db)
+		if err != nil {
+			log.Fatal("failed to prepare model usage store", "error", err)
+		}
+		repo, priceRepo, budgetRepo, allocationRepo = pgRepo, pgRepo, pgRepo, pgRepo
+	}
+	currency := appContainer.Config().AI.CostCurrency
+	prices := costs.NewPriceBook(priceRepo, currency, ai.DefaultPrices, logging.Module("costs"))
+	if err := prices.Load(context.Background()); err != nil {
+		log.Fatal("failed to load the model price book", "error", err)
+	}
+	appContainer.Lifecycle().Go("price book reload", func(ctx context.Context) { prices.Run(ctx, time.Minute) })
+	modelChain.SetPrices(prices)
+	service := costs.NewService(repo, logging.Module("costs"))
+	service.SetCurrency(currency)
+	modelChain.SetUsageRecorder(service)
+	appContainer.Lifecycle().GoSingleton("model usage rollups", func(ctx context.Context) { service.Run(ctx, 15*time.Minute) })
+	budgets := costs.NewBudgetManager(budgetRepo, repo, currency, logging.Module("costs"))
+	budgets.SetLedger(ledger)
+	if err := budgets.Load(context.Background()); err != nil {
+		log.Fatal("failed to load model budgets", "error", err)
+	}
+	appContainer.Lifecycle().Go("model budget reload", func(ctx context.Context) { budgets.Run(ctx, time.Minute) })
+	modelChain.SetBudgets(budgets)
+	allocator := costs.NewAllocator(allocationRepo, service, logging.Module("costs"))
+	allocator.SetNotifier(costs.NewAlertNotifier(dispatcher, appContainer.Config().PublicURL, logging.Module("costs")))
+	if err := allocator.Load(context.Background()); err != nil {
+		log.Fatal("failed to load allocation rules", "error", err)
+	}
+	appContainer.Lifecycle().Go("allocation rule reload", func(ctx context.Context) { allocator.Run(ctx, time.Minute) })
+	appContainer.Lifecycle().GoSingleton("monthly chargeback report", func(ctx context.Context) { allocator.RunMonthly(ctx, 15*time.Minute) })
+
+	controller := nlpapi.NewUsageController(service, nil)
+	e.GET("api/v1/nlp/usage", controller.Get)
+	e.GET("api/v1/admin/nlp/usage", controller.Overview)
+	e.GET("api/v1/admin/nlp/billing/:month/:year", controller.Bill)
+	e.POST("api/v1/admin/nlp/billing/backfill", controller.Backfill)
+	priceController := nlpapi.NewPriceBookController(prices, nil)
+	e.GET("api/v1/admin/nlp/prices", priceController.Get)
+	e.POST("api/v1/admin/nlp/prices", priceController.Publish)
+	e.GET("api/v1/admin/nlp/prices/versions/:version", priceController.GetVersion)
+	budgetController := nlpapi.NewBudgetController(budgets, nil)
+	e.GET("api/v1/admin/nlp/budgets", budgetController.List)
+	e.PUT("api/v1/admin/nlp/budgets/:name", budgetController.Put)
+	e.DELETE("api/v1/admin/nlp/budgets/:name", budgetController.Delete)
+	chargebackController := nlpapi.NewChargebackController(allocator, nil)
+	e.GET("api/v1/admin/nlp/allocation-rules", chargebackController.Rules)
+	e.PUT("api/v1/admin/nlp/allocation-rules/:name", chargebackController.PutRule)
+	e.DELETE("api/v1/admin/nlp/allocation-rules/:name", chargebackController.DeleteRule)
+	e.GET("api/v1/admin/nlp/chargeback", chargebackController.Reports)
+	e.GET("api/v1/admin/nlp/chargeback/:month/:year", chargebackController.Report)
+	return budgets, allocator
+}
+
+// chatSessionRoutes keeps users' conversations and sweeps sessions idle beyond their owner's retention.
+func chatSessionRoutes(e *echo.Echo, appContainer container.Container) *chat.Service {
+	var repo chat.Repository = chat.NewMemoryRepository()
+	if db := appContainer.DB(); db != nil {
+		pgRepo, err := chat.NewPostgresRepository(context.Background(), db)
+		if err != nil {
+			log.Fatal("failed to prepare chat session store", "error", err)
+		}
+		repo = pgRepo
+	}
+	service := chat.NewService(repo, chat.Options{
+		Retention:     appContainer.Config().ChatRetention,
+		ContextTokens: appContainer.Config().ChatContextTokens,
+	}, logging.Module("chat"))
+	appContainer.Lifecycle().GoSingleton("chat session sweeper", func(ctx context.Context) { service.Run(ctx, time.Hour) })
+
+	controller := chatapi.NewSessionController(service, nil)
+	e.GET("api/v1/nlp/sessions", controller.List)
+	e.POST("api/v1/nlp/sessions", controller.Create)
+	e.GET("api/v1/nlp/sessions/:id", controller.Get)
+	e.DELETE("api/v1/nlp/sessions/:id", controller.Delete)
+	e.GET("api/v1/nlp/sessions/:id/context", controller.GetContext)
+	e.DELETE("api/v1/nlp/sessions/:id/context", controller.ClearContext)
+	e.GET("api/v1/nlp/retention", controller.GetRetention)
+	e.PUT("api/v1/nlp/retention", controller.SetRetention)
+	return service
+}
+
+// anomalyRoutes returns the engine that looks for suspicious activity and serves the security
+// events it raises. Events are published to SSE subscribers and, when they score at least the
+// rules' alert score, dispatched as alerts.
+func anomalyRoutes(e *echo.Echo, appContainer container.Container, ledger *audit.Ledger, dispatcher *alerting.Dispatcher) *anomaly.Engine {
+	cfg := appContainer.Config()
+	rules := anomaly.DefaultRules()
+	if cfg.AnomalyRulesFile != "" {
+		loaded, err := anomaly.LoadRules(cfg.AnomalyRulesFile)
+		if err != nil {
+			log.Fatal("failed to load anomaly rules", "error", err)
+		}
+		rules = loaded
+	}
+	var repo anomaly.Repository = anomaly.NewMemoryRepository()
+	if db := appContainer.DB(); db != nil {
+		pgRepo, err := anomaly.NewPostgresRepository(context.Background(), db)
+		if err != nil {
+			log.Fatal("failed to prepare security event store", "error", err)
+		}
+		repo = pgRepo
+	}
+	engine := anomaly.NewEngine(rules, repo, logging.Module("anomaly"))
+	engine.SetLedger(ledger)
+	if cfg.GeoIPFile != "" {
+		locator, err := anomaly.LoadGeoTable(cfg.GeoIPFile)
+		if err != nil {
+			log.Fatal("failed to load geodata", "error", err)
+		}
+		engine.SetLocator(locator)
+	}
+	appContainer.Lifecycle().Go("security events", func(ctx context.Context) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-engine.Events():
+				payload, err := json.Marshal(event)
+				if err != nil {
+					continue
+				}
+				appContainer.SSE().CreateStream(anomaly.EventStreamID)
+				appContainer.SSE().Publish(anomaly.EventStreamID, &sse.Event{Event: []byte("security_event"), Data: payload})
+				if event.Score >= rules.AlertScore {
+					if _, err := dispatcher.Dispatch(ctx, event.Alert()); err != nil {
+						log.Error("failed to record security alert", "id", event.ID, "error", err)
+					}
+				}
+			}
+		}
+	})
+
+	controller := anomalyapi.NewEventsController(engine, nil)
+	e.GET("api/v1/admin/security/events", controller.Events)
+	e.GET("api/v1/admin/security/events/stream", func(c echo.Context) error {
+		appContainer.SSE().CreateStream(anomaly.EventStreamID)
+		helpers.ServeStream(c, appContainer.SSE(), anomaly.EventStreamID)
+		return nil
+	})
+	e.GET("api/v1/admin/security/anomaly-rules", controller.Rules)
+	return engine
+}
+
+// alertingRoutes returns the dispatcher that routes security alerts to the configured channels
+// and serves the alerts with the status of their deliveries. Without --alerting-config alerts
+// are recorded but sent nowhere.
+func alertingRoutes(e *echo.Echo, appContainer container.Container) *alerting.Dispatcher {
+	var config alerting.Config
+	if path := appContainer.Config().AlertingConfigFile; path != "" {
+		loaded, err := alerting.LoadConfig(path)
+		if err != nil {
+			log.Fatal("failed to load alerting config", "error", err)
+		}
+		config = loaded
+	}
+	channels, err := config.BuildChannels(os.LookupEnv, nil)
+	if err != nil {
+		log.Fatal("failed to prepare alert channels", "error", err)
+	}
+	var repo alerting.Repository = alerting.NewMemoryRepository()
+	if db := appContainer.DB(); db != nil {
+		pgRepo, err := alerting.NewPostgresRepository(context.Background(), db)
+		if err != nil {
+			log.Fatal("failed to prepare alert store", "error", err)
+		}
+		repo = pgRepo
+	}
+	dispatcher := alerting.NewDispatcher(config, channels, repo, logging.Module("alerting"))
+
+	controller := alertingapi.NewAlertsController(dispatcher, nil)
+	e.GET("api/v1/security/alerts", controller.Alerts)
+	e.GET("api/v1/security/alerts/channels", controller.Channels)
+	e.POST("api/v1/security/alerts/test", controller.Test)
+	return dispatcher
+}
+
+// securityRoutes records the posture the server started with and serves it with earlier reports.
+func securityRoutes(e *echo.Echo, appContainer container.Container) {
+	var repo security.Repository = security.NewMemoryRepository()
+	if db := appContainer.DB(); db != nil {
+		pgRepo, err := security.NewPostgresRepository(context.Background(), db)
+		if err != nil {
+			log.Fatal("failed to prepare security posture store", "error", err)
+		}
+		repo = pgRepo
+	}
+	report := security.NewValidator(appContainer.Config()).Validate()
+	if err := repo.Save(context.Background(), report); err != nil {
+		log.Error("failed to record security posture", "error", err)
+	}
+
+	controller := securityapi.NewPostureController(repo, nil)
+	e.GET("api/v1/security/posture", controller.Get)
+	e.GET("api/v1/security/posture/history", controller.History)
+}
+
+// restMappers returns a function resolving each cluster's REST mapper. Mappers are created once per
+// cluster so discovery results are cached and shared.
+func restMappers(appContainer container.Container) func(config, cluster string) (meta.RESTMapper, error) {
+	var mappers sync.Map
+	return func(config, cluster string) (meta.RESTMapper, error) {
+		key := config + "/" + cluster
+		if mapper, ok := mappers.Load(key); ok {
+			return mapper.(meta.RESTMapper), nil
+		}
+		mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(appContainer.DiscoveryClient(config, cluster)))
+		actual, _ := mappers.LoadOrStore(key, mapper)
+		return actual.(meta.RESTMapper), nil
+	}
+}
+
+// watchRoutes pushes resource events to WebSocket subscribers from shared informers.
+func watchRoutes(e *echo.Echo, appContainer container.Container, mappers func(config, cluster string) (meta.RESTMapper, error), namespaces watch.NamespacePolicy) {
+	hub := watch.NewHub(func(config, cluster string) dynamicinformer.DynamicSharedInformerFactory {
+		kubeConfig := appContainer.Config().KubeConfig[config]
+		if kubeConfig == nil || kubeConfig.Clusters[cluster] == nil {
+			return nil
+		}
+		return appContainer.DynamicSharedInformerFactory(config, cluster)
+	}, mappers, watch.NewPolicyAuthorizer(watch.NewRBACAuthorizer(func(config, cluster string) kubernetes.Interface {
+		return appContainer.ClientSet(config, cluster)
+	}), namespaces), watch.Limits{
+		PerUser: appContainer.Config().WatchSubscriptionsPerUser,
+		Total:   appContainer.Config().WatchSubscriptionsTotal,
+	}, appContainer.Lifecycle().Context().Done(), logging.Module("watch"))
+
+	controller := watchapi.NewWatchController(hub, appContainer.SocketUpgrader(), logging.Module("watch"))
+	controller.SetConnections(telemetry.NewWebSocketConnections(prometheus.DefaultRegisterer).WithLabelValues("watch"))
+	e.GET("api/v1/watch", controller.Socket)
+	e.GET("api/v1/watch/subscriptions", controller.Subscriptions)
+}
+
+// chaosRoutes lets admins rehearse provider outages and cluster API throttling, and returns the
+// experiments consulted by the model chain and the cluster middleware.
+func chaosRoutes(e *echo.Echo, appContainer container.Container) *chaos.Service {
+	service := chaos.NewService(nil)
+	controller := chaosapi.NewChaosController(service, nil)
+	e.GET("api/v1/admin/chaos", controller.List)
+	e.POST("api/v1/admin/chaos", controller.Start)
+	e.DELETE("api/v1/admin/chaos/:id", controller.Stop)
+	return service
+}
+
+// impersonationRoutes lets admins act as a user with consent or by breaking glass, and returns the
+// sessions used to authorize impersonated requests. Breaking glass must be signed.
+func impersonationRoutes(e *echo.Echo, appContainer container.Container, signer *signing.Verifier, authorizer impersonationapi.Authorizer) *impersonation.Service {
+	var repo impersonation.Repository = impersonation.NewMemoryRepository()
+	if db := appContainer.DB(); db != nil {
+		pgRepo, err := impersonation.NewPostgresRepository(context.Background(), db)
+		if err != nil {
+			log.Fatal("failed to prepare impersonation store", "error", err)
+		}
+		repo = pgRepo
+	}
+	service := impersonation.NewService(repo, impersonation.Policy{
+		BreakGlass: appContainer.Config().BreakGlassUsers,
+	}, logging.Module("impersonation"))
+	controller := impersonationapi.NewImpersonationController(service, nil)
+	controller.SetAuthorizer(authorizer)
+	var breakGlass []echo.MiddlewareFunc
+	if signer != nil {
+		breakGlass = append(breakGlass, appmiddleware.SignedRequestMiddleware(signer, func(c echo.Context, body []byte) bool {
+			var in impersonation.RequestInput
+			// Bodies that do not decode are refused by the handler before anything starts.
+			return json.Unmarshal(body, &in) == nil && in.BreakGlass
+		}))
+	}
+	e.POST("api/v1/admin/impersonations", controller.Request, breakGlass...)
+	e.GET("api/v1/admin/impersonations", controller.ListAll)
+	e.GET("api/v1/impersonations", controller.List)
+	e.GET("api/v1/impersonations/audit", controller.Audit)
+	e.POST("api/v1/impersonations/:id/consent", controller.Consent)
+	e.POST("api/v1/impersonations/:id/deny", controller.Deny)
+	e.DELETE("api/v1/impersonations/:id", controller.End)
+	return service
+}
+
+// signingRoutes returns the verifier of signed admin requests, or nil when no request signing key
+// is configured, and serves its audit log.
+func signingRoutes(e *echo.Echo, appContainer container.Container) *signing.Verifier {
+	key := appContainer.Config().RequestSigningKey
+	if key == "" {
+		log.Warn("KUBECHAT_REQUEST_SIGNING_KEY is not set; legal hold release, break-glass impersonation and audit ledger re-anchoring are accepted unsigned")
+		return nil
+	}
+	var repo signing.Repository = signing.NewMemoryRepository()
+	if db := appContainer.DB(); db != nil {
+		pgRepo, err := signing.NewPostgresRepository(context.Background(), db)
+		if err != nil {
+			log.Fatal("failed to prepare request signing store", "error", err)
+		}
+		repo = pgRepo
+	}
+	verifier := signing.NewVerifier([]byte(key), repo, logging.Module("signing"))
+	e.GET("api/v1/admin/signed-requests/audit", signingapi.NewSignedRequestController(verifier, nil).Audit)
+	return verifier
+}
+
+// auditLedger returns the tamper-evident ledger the audit trails are chained into, verified in the
+// background with tamper alerts published to SSE subscribers and dispatched, and the archiver
+// retention moves old records out with. There is no archiver without KUBECHAT_AUDIT_ARCHIVE_KEY.
+func auditLedger(appContainer container.Container, dispatcher *alerting.Dispatcher) (*audit.Ledger, *audit.Archiver) {
+	type repository interface {
+		audit.Repository
+		audit.ArchiveRepository
+	}
+	var repo repository = audit.NewMemoryRepository()
+	if db := appContainer.DB(); db != nil {
+		pgRepo, err := audit.NewPostgresRepository(context.Background(), db)
+		if err != nil {
+			log.Fatal("failed to prepare audit ledger", "error", err)
+		}
+		repo = pgRepo
+	}
+	ledger := audit.NewLedger(repo, logging.Module("audit"))
+	appContainer.Lifecycle().GoSingleton("audit ledger verifier", func(ctx context.Context) { ledger.Run(ctx, audit.VerifyInterval) })
+	appContainer.Lifecycle().Go("tamper alerts", func(ctx context.Context) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case alert := <-ledger.Alerts():
+				payload, err := json.Marshal(alert)
+				if err != nil {
+					continue
+				}
+				appContainer.SSE().CreateStream(audit.AlertStreamID)
+				appContainer.SSE().Publish(audit.AlertStreamID, &sse.Event{Event: []byte("tamper_alert"), Data: payload})
+				if _, err := dispatcher.Dispatch(ctx, alert.Alert()); err != nil {
+					log.Error("failed to record tamper alert", "seq", alert.Seq, "error", err)
+				}
+			}
+		}
+	})
+
+	cfg := appContainer.Config()
+	if cfg.AuditArchiveKey == "" {
+		return ledger, nil
+	}
+	archiver, err := audit.NewArchiver(ledger, repo, archiveStore(appContainer), cfg.AuditArchiveKey, logging.Module("audit"))
+	if err != nil {
+		log.Fatal("failed to prepare audit archiver", "error", err)
+	}
+	archiver.SetLifetime(cfg.AuditArchiveLifetime)
+	return ledger, archiver
+}
+
+// auditRoutes lets admins check and page through the audit ledger and re-anchor it after an
+// investigation. Re-anchoring accepts tampered history, so it must be signed. With an archiver,
+// archived records can be restored and queried.
+func auditRoutes(e *echo.Echo, appContainer container.Container, ledger *audit.Ledger, archiver *audit.Archiver, signer *signing.Verifier) {
+	controller := auditapi.NewLedgerController(ledger, nil)
+	e.GET("api/v1/admin/audit/integrity", controller.Integrity)
+	e.POST("api/v1/admin/audit/integrity/verify", controller.Verify)
+	e.GET("api/v1/admin/audit/ledger", controller.Records)
+	var signed []echo.MiddlewareFunc
+	if signer != nil {
+		signed = append(signed, appmiddleware.SignedRequestMiddleware(signer, nil))
+	}
+	e.POST("api/v1/admin/audit/anchors", controller.Reanchor, signed...)
+	e.GET("api/v1/admin/audit/alerts/stream", func(c echo.Context) error {
+		appContainer.SSE().CreateStream(audit.AlertStreamID)
+		helpers.ServeStream(c, appContainer.SSE(), audit.AlertStreamID)
+		return nil
+	})
+	if archiver == nil {
+		return
+	}
+	archives := auditapi.NewArchiveController(archiver, nil)
+	e.GET("api/v1/admin/audit/archives", archives.List)
+	e.POST("api/v1/admin/audit/archives/:id/restore", archives.Restore)
+	e.DELETE("api/v1/admin/audit/archives/:id/restore", archives.Evict)
+	e.GET("api/v1/admin/audit/restored", archives.Records)
+}
+
+// capabilityRoutes reports which optional subsystems are usable and returns the registry the
+// capability middleware checks. Kubernetes is checked on every request since kubeconfigs can be
+// added while the server runs.
+func capabilityRoutes(e *echo.Echo, appContainer container.Container) *capabilities.Registry {
+	registry := capabilities.NewRegistry()
+	registry.Register(capabilities.Kubernetes, func() error {
+		for _, kubeConfig := range appContainer.Config().KubeConfig {
+			if len(kubeConfig.Clusters) > 0 {
+				return nil
+			}
+		}
+		return capabilities.ErrDisabled{
+			Capability:  capabilities.Kubernetes,
+			Reason:      "no kubeconfig with clusters is loaded",
+			Remediation: "add a kubeconfig from the settings page or ~/.kube, or run KubeChat in a cluster with a service account",
+		}
+	})
+	if appContainer.DB() == nil {
+		registry.Disable(capabilities.Persistence, "no database is configured, so data is kept in memory until restart",
+			"pass --database-url to keep data across restarts and share it between replicas")
+	} else {
+		registry.Enable(capabilities.Persistence)
+	}
+	e.GET("api/v1/capabilities", capabilitiesapi.NewCapabilitiesController(registry).Handle)
+	return registry
+}
+
+// mfaRoutes lets users enroll an authenticator and verify codes, and returns the service that
+// checks step-up assertions on high-risk routes.
+func mfaRoutes(e *echo.Echo, appContainer container.Container) *mfa.Service {
+	var repo mfa.Repository = mfa.NewMemoryRepository()
+	if db := appContainer.DB(); db != nil {
+		pgRepo, err := mfa.NewPostgresRepository(context.Background(), db)
+		if err != nil {
+			log.Fatal("failed to prepare MFA store", "error", err)
+		}
+		repo = pgRepo
+	}
+	service := mfa.NewService(repo, "KubeChat", appContainer.Config().MFAStepUpWindow, logging.Module("mfa"))
+	controller := mfaapi.NewMFAController(service, nil)
+	e.GET("api/v1/mfa", controller.Status)
+	e.POST("api/v1/mfa/enrollment", controller.Enroll)
+	e.POST("api/v1/mfa/enrollment/confirm", controller.Confirm)
+	e.DELETE("api/v1/mfa/enrollment", controller.Remove)
+	e.POST("api/v1/mfa/verify", controller.Verify)
+	e.GET("api/v1/mfa/audit", controller.Audit)
+	e.GET("api/v1/admin/mfa/audit", controller.AdminAudit)
+	e.DELETE("api/v1/admin/mfa/enrollments/:user", controller.Reset)
+	return service
+}
+
+// userRoutes lets admins find, lock and reset the users KubeChat has seen and returns the directory
+// that records them.
+func userRoutes(e *echo.Echo, appContainer container.Container) *users.Directory {
+	var repo users.Repository = users.NewMemoryRepository()
+	if db := appContainer.DB(); db != nil {
+		pgRepo, err := users.NewPostgresRepository(context.Background(), db)
+		if err != nil {
+			log.Fatal("failed to prepare user directory store", "error", err)
+		}
+		repo = pgRepo
+	}
+	directory := users.NewDirectory(repo, appContainer.Config().AdminUsers, logging.Module("users"))
+	controller := usersapi.NewUserController(directory, nil)
+	e.GET("api/v1/admin/users", controller.List)
+	e.GET("api/v1/admin/users/:user", controller.Get)
+	e.POST("api/v1/admin/users/:user/lock", controller.Lock)
+	e.POST("api/v1/admin/users/:user/unlock", controller.Unlock)
+	e.POST("api/v1/admin/users/:user/reset", controller.Reset)
+	e.POST("api/v1/admin/users/:user/roles", controller.AssignRole)
+	e.DELETE("api/v1/admin/users/:user/roles/:id", controller.RevokeRole)
+	return directory
+}
+
+// roleRoutes manages KubeChat's own role bindings and returns the service that authorizes
+// requests against them.
+func roleRoutes(e *echo.Echo, appContainer container.Container) *approles.Service {
+	var repo approles.Repository = approles.NewMemoryRepository()
+	if db := appContainer.DB(); db != nil {
+		pgRepo, err := approles.NewPostgresRepository(context.Background(), db)
+		if err != nil {
+			log.Fatal("failed to prepare role binding store", "error", err)
+		}
+		repo = pgRepo
+	}
+	defaultRole := approles.Role(appContainer.Config().DefaultRole)
+	if defaultRole != "" && !defaultRole.Valid() {
+		log.Fatal("invalid default role", "role", defaultRole)
+	}
+	service := approles.NewService(repo, appContainer.Config().AdminUsers, defaultRole, logging.Module("roles"))
+	if err := service.SetNamespaceDefaults(appContainer.Config().AllowedNamespaces); err != nil {
+		log.Fatal("invalid allowed namespaces", "error", err)
+	}
+	controller := rolesapi.NewRoleController(service, nil)
+	e.GET("api/v1/kubechat/roles", controller.Roles)
+	e.GET("api/v1/kubechat/roles/me", controller.Me)
+	e.GET("api/v1/admin/roles/bindings", controller.Bindings)
+	e.POST("api/v1/admin/roles/bindings", controller.Assign)
+	e.DELETE("api/v1/admin/roles/bindings/:id", controller.Revoke)
+	e.GET("api/v1/admin/roles/namespaces", controller.NamespacePolicy)
+	e.POST("api/v1/admin/roles/namespaces", controller.AddNamespaceRule)
+	e.DELETE("api/v1/admin/roles/namespaces/:id", controller.RemoveNamespaceRule)
+	e.POST("api/v1/admin/roles/reload", controller.Reload)
+	return service
+}
+
+// complianceRoutes reports how KubeChat's own controls meet SOX, HIPAA and SOC 2 requirements and
+// records the access reviews one of them asks for.
+func complianceRoutes(e *echo.Echo, appContainer container.Container, ledger *audit.Ledger, bindings *approles.Service, factors *mfa.Service, sessions *impersonation.Service, executions *commands.Service) *compliance.Analyzer {
+	cfg := appContainer.Config()
+	var repo compliance.Repository = compliance.NewMemoryRepository()
+	if db := appContainer.DB(); db != nil {
+		pgRepo, err := compliance.NewPostgresRepository(context.Background(), db)
+		if err != nil {
+			log.Fatal("failed to prepare compliance store", "error", err)
+		}
+		repo = pgRepo
+	}
+	reviews := compliance.NewAccessReviews(repo, bindings, logging.Module("compliance"))
+	reviews.SetLedger(ledger)
+	analyzer := compliance.NewAnalyzer(
+		compliance.MFAControl(bindings, append(slices.Clone(cfg.AdminUsers), cfg.BreakGlassUsers...), factors),
+		compliance.ApprovalControl(executions),
+		compliance.SessionControl(sessions, time.Hour, cfg.MFAStepUpWindow),
+		compliance.IntegrityControl(ledger, audit.VerifyInterval),
+		compliance.AccessReviewControl(reviews, bindings, compliance.DefaultReviewInterval),
+	)
+
+	controller := complianceapi.NewComplianceController(analyzer, reviews, nil)
+	e.GET("api/v1/admin/compliance", controller.Catalog)
+	e.GET("api/v1/admin/compliance/reports/:framework", controller.Report)
+	e.GET("api/v1/admin/compliance/access-reviews", controller.Reviews)
+	e.POST("api/v1/admin/compliance/access-reviews", controller.RecordReview)
+	return analyzer
+}
+
+// userKeyRoutes lets users store their own model provider API keys, which the model chain then
+// prefers for their requests. It returns nil when no key to seal them with is configured.
+func userKeyRoutes(e *echo.Echo, appContainer container.Container) *credentials.Keys {
+	cfg := appContainer.Config().AI
+	if cfg.UserKeysKey == "" {
+		return nil
+	}
+	var store credentials.Store = credentials.NewMemoryStore()
+	var policies credentials.PolicyStore = credentials.NewMemoryPolicyStore()
+	if cfg.UserKeysNamespace != "" {
+		restConfig, err := rest.InClusterConfig()
+		if err != nil {
+			restConfig, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+				clientcmd.NewDefaultClientConfigLoadingRules(), &clientcmd.ConfigOverrides{}).ClientConfig()
+		}
+		if err != nil {
+			log.Fatal("failed to load the configuration for user API key Secrets", "error", err)
+		}
+		client, err := kubernetes.NewForConfig(restConfig)
+		if err != nil {
+			log.Fatal("failed to create the client for user API key Secrets", "error", err)
+		}
+		store = credentials.NewSecretStore(client, cfg.UserKeysNamespace)
+		policies = credentials.NewConfigMapPolicyStore(client, cfg.UserKeysNamespace)
+	}
+	keys, err := credentials.NewKeys(store, cfg.UserKeysKey, nil, logging.Module("credentials"))
+	if err != nil {
+		log.Fatal("failed to prepare user API keys", "error", err)
+	}
+	var backends []credentials.Backend
+	if cfg.VaultAddress != "" {
+		vault, err := credentials.NewVaultBackend(credentials.VaultConfig{
+			Address: cfg.VaultAddress,
+			Path:    cfg.VaultPath,
+			Token:   cfg.VaultToken,
+			Role:    cfg.VaultRole,
+		}, nil)
+		if err != nil {
+			log.Fatal("failed to prepare the Vault backend for user API keys", "error", err)
+		}
+		backends = append(backends, vault)
+	}
+	if cfg.AWSSecretsRegion != "" {
+		secretsManager, err := credentials.NewAWSSecretsBackend(credentials.AWSSecretsConfig{
+			Region: cfg.AWSSecretsRegion,
+			Prefix: cfg.AWSSecretsPrefix,
+			Credentials: attachments.Credentials{
+				AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+				SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+				SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+			},
+		}, nil)
+		if err != nil {
+			log.Fatal("failed to prepare the AWS Secrets Manager backend for user API keys", "error", err)
+		}
+		backends = append(backends, secretsManager)
+	}
+	keys.SetBackends(cfg.UserKeysBackend, backends...)
+	keys.SetPolicies(policies)
+	current, previous := userKeysKMS(cfg)
+	keys.SetKMS(current, previous...)
+	keys.SetBackground(appContainer.Lifecycle())
+	appContainer.Lifecycle().GoSingleton("user key rewrap", func(ctx context.Context) { keys.Run(ctx, 6*time.Hour) })
+
+	controller := credentialsapi.NewKeysController(keys, nil)
+	e.GET("api/v1/nlp/keys", controller.List)
+	e.GET("api/v1/nlp/keys/backends", controller.Backends)
+	e.PUT("api/v1/nlp/keys/:provider", controller.Put)
+	e.DELETE("api/v1/nlp/keys/:provider", controller.Delete)
+	e.POST("api/v1/admin/nlp/keys/migrate", controller.Migrate)
+	e.POST("api/v1/admin/nlp/keys/rewrap", controller.Rewrap)
+	e.POST("api/v1/admin/nlp/keys/validate", controller.Validate)
+	e.GET("api/v1/admin/nlp/keys/validate/:id", controller.Validation)
+	e.GET("api/v1/admin/nlp/keys/policies", controller.Policies)
+	e.PUT("api/v1/admin/nlp/keys/policies/:provider", controller.PutPolicy)
+	e.DELETE("api/v1/admin/nlp/keys/policies/:provider", controller.DeletePolicy)
+	return keys
+}
+
+// userKeysKMS opens every configured KMS, returning the one wrapping new data keys first, nil for
+// the local key. The others still unwrap the data keys they wrapped.
+func userKeysKMS(cfg config.AIConfig) (credentials.KMS, []credentials.KMS) {
+	opened := map[string]credentials.KMS{}
+	if cfg.AWSKMSKey != "" {
+		kms, err := credentials.NewAWSKMS(credentials.AWSKMSConfig{
+			KeyID:  cfg.AWSKMSKey,
+			Region: cfg.AWSKMSRegion,
+			Credentials: attachments.Credentials{
+				AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+				SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+				SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+			},
+		}, nil)
+		if err != nil {
+			log.Fatal("failed to prepare AWS KMS for user API keys", "error", err)
+		}
+		opened[kms.Name()] = kms
+	}
+	if cfg.GCPKMSKey != "" {
+		kms, err := credentials.NewGCPKMS(credentials.GCPKMSConfig{Key: cfg.GCPKMSKey}, nil)
+		if err != nil {
+			log.Fatal("failed to prepare Cloud KMS for user API keys", "error", err)
+		}
+		opened[kms.Name()] = kms
+	}
+	if cfg.VaultAddress != "" && cfg.VaultTransitKey != "" {
+		kms, err := credentials.NewVaultTransit(credentials.VaultConfig{
+			Address: cfg.VaultAddress,
+			Path:    cfg.VaultTransitKey,
+			Token:   cfg.VaultToken,
+			Role:    cfg.VaultRole,
+		}, nil)
+		if err != nil {
+			log.Fatal("failed to prepare Vault transit for user API keys", "error", err)
+		}
+		opened[kms.Name()] = kms
+	}
+	current := opened[cfg.UserKeysKMS]
+	var previous []credentials.KMS
+	for name, kms := range opened {
+		if name != cfg.UserKeysKMS {
+			previous = append(previous, kms)
+		}
+	}
+	return current, previous
+}
+
+// privacyRoutes serves data subject requests: users export their personal data and request its
+// erasure, which runs once another admin approves it.
+func privacyRoutes(e *echo.Echo, appContainer container.Container, ledger *audit.Ledger, authorizer privacyapi.Authorizer, holders ...privacy.Holder) {
+	var repo privacy.Repository = privacy.NewMemoryRepository()
+	if db := appContainer.DB(); db != nil {
+		pgRepo, err := privacy.NewPostgresRepository(context.Background(), db)
+		if err != nil {
+			log.Fatal("failed to prepare privacy store", "error", err)
+		}
+		repo = pgRepo
+	}
+	manager := privacy.NewManager(repo, logging.Module("privacy"), holders...)
+	manager.SetLedger(ledger)
+
+	controller := privacyapi.NewPrivacyController(manager, nil)
+	controller.SetAuthorizer(authorizer)
+	e.GET("api/v1/privacy/export", controller.Export)
+	e.GET("api/v1/privacy/erasures", controller.Erasures)
+	e.POST("api/v1/privacy/erasures", controller.RequestErasure)
+	e.GET("api/v1/privacy/erasures/:id", controller.Erasure)
+	e.POST("api/v1/privacy/erasures/:id/approve", controller.Approve)
+	e.POST("api/v1/privacy/erasures/:id/reject", controller.Reject)
+}
+
+func retentionRoutes(e *echo.Echo, appContainer container.Container, signer *signing.Verifier, targets ...retention.Target) {
+	var repo retention.Repository = retention.NewMemoryRepository()
+	if db := appContainer.DB(); db != nil {
+		pgRepo, err := retention.NewPostgresRepository(context.Background(), db)
+		if err != nil {
+			log.Fatal("failed to prepare retention store", "error", err)
+		}
+		repo = pgRepo
+	}
+	manager := retention.NewManager(repo, nil, targets...)
+	appContainer.Lifecycle().GoSingleton("retention enforcer", func(ctx context.Context) { manager.Run(ctx, time.Hour) })
+
+	controller := retentionapi.NewRetentionController(manager, nil)
+	// Shortening a policy, enforcing it and releasing a hold all let records be purged.
+	var signed []echo.MiddlewareFunc
+	if signer != nil {
+		signed = append(signed, appmiddleware.SignedRequestMiddleware(signer, nil))
+	}
+	e.GET("api/v1/admin/retention", controller.Get)
+	e.PUT("api/v1/admin/retention/:category", controller.SetPolicy, signed...)
+	e.POST("api/v1/admin/retention/holds", controller.AddHold)
+	e.DELETE("api/v1/admin/retention/holds/:id", controller.ReleaseHold, signed...)
+	e.POST("api/v1/admin/retention/enforce", controller.Enforce, signed...)
+	e.GET("api/v1/admin/retention/dry-run", controller.DryRun)
+}
+
+func reportRoutes(e *echo.Echo, appContainer container.Container, tracker *inventory.Tracker, rightSizer *rightsizing.Sampler) {
+	generator := reports.NewHandoffGenerator(
+		reports.NewFailingWorkloadsSource(func(config, cluster string) kubernetes.Interface {
+			return appContainer.ClientSet(config, cluster)
+		}),
+		reports.NewRecentChangesSource(tracker),
+		reports.NewRightSizingSource(rightSizer),
+	)
+	scheduler := reports.NewScheduler(generator, func() []reports.Scope {
+		return connectedClusterScopes(appContainer)
+	}, reports.NewSSEDeliverer(appContainer.SSE()), nil)
+	if interval := appContainer.Config().HandoffReportInterval; interval > 0 {
+		appContainer.Lifecycle().GoSingleton("handoff scheduler", func(ctx context.Context) { scheduler.Run(ctx, interval) })
+	}
+
+	e.GET("api/v1/reports/handoff", reportsapi.NewHandoffController(generator, scheduler, nil).Handle)
+	e.GET("api/v1/reports/handoff/stream", func(c echo.Context) error {
+		appContainer.SSE().CreateStream(reports.HandoffStreamID)
+		helpers.ServeStream(c, appContainer.SSE(), reports.HandoffStreamID)
+		return nil
+	})
+}
+
+func connectedClusterScopes(appContainer container.Container) []reports.Scope {
+	var scopes []reports.Scope
+	for configName, kubeCfg := range appContainer.Config().KubeConfig {
+		if kubeCfg == nil {
+			continue
+		}
+		for clusterName, cluster := range kubeCfg.Clusters {
+			if cluster != nil && cluster.IsConnected() {
+				scopes = append(scopes, reports.Scope{Config: configName, Cluster: clusterName})
+			}
+		}
+	}
+	return scopes
 }
 
 func customResources(e *echo.Echo, appContainer container.Container) {
@@ -358,11 +2047,15 @@ func accessControlRoutes(e *echo.Echo, appContainer container.Container) {
 	e.DELETE("api/v1/clusterrolebindings", clusterrolebindings.NewClusterRoleBindingsRouteHandler(appContainer, base.Delete)).Name = "clusterrolebindingsDelete"
 }
 
-func setCORSConfig(e *echo.Echo) {
+// setCORSConfig allows browsers to call the API from origins, or from any origin when none are given.
+func setCORSConfig(e *echo.Echo, origins []string) {
+	if len(origins) == 0 {
+		origins = []string{"*"}
+	}
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowCredentials:                         true,
-		UnsafeWildcardOriginWithAllowCredentials: true,
-		AllowOrigins:                             []string{"*"},
+		UnsafeWildcardOriginWithAllowCredentials: slices.Contains(origins, "*"),
+		AllowOrigins:                             origins,
 		AllowHeaders: []string{
 			echo.HeaderConnection,
 			echo.HeaderContentType,
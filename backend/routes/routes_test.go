@@ -0,0 +1,130 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/pramodksahoo/kubechat/backend/config"
+	"github.com/pramodksahoo/kubechat/backend/container"
+	"github.com/pramodksahoo/kubechat/backend/internal/identity"
+	"github.com/pramodksahoo/kubechat/backend/internal/ratelimit"
+	approles "github.com/pramodksahoo/kubechat/backend/internal/roles"
+	appmiddleware "github.com/pramodksahoo/kubechat/backend/routes/middleware"
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// configureTestRoutes registers every route of a server started with args.
+func configureTestRoutes(t *testing.T, args ...string) *echo.Echo {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	flags := pflag.NewFlagSet("kubechat", pflag.ContinueOnError)
+	config.RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(flags, "test", func(string) (string, bool) { return "", false })
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	appContainer := container.NewContainer(config.NewEnv(), cfg, nil)
+	t.Cleanup(func() { appContainer.Lifecycle().Shutdown(context.Background()) })
+
+	e := echo.New()
+	ConfigureRoutes(e, appContainer)
+	return e
+}
+
+func TestConfigureRoutesLeavesOutDisabledSubsystems(t *testing.T) {
+	e := configureTestRoutes(t, "--disable", "exports,watch")
+
+	registered := map[string]bool{}
+	for _, route := range e.Routes() {
+		registered[route.Method+" "+route.Path] = true
+	}
+	for _, route := range []string{"GET /api/v1/dashboards", "POST /api/v1/prompts", "GET /api/v1/sandboxes"} {
+		if !registered[route] {
+			t.Errorf("expected %s to be registered", route)
+		}
+	}
+	for _, route := range []string{"POST /api/v1/exports", "GET /api/v1/watch"} {
+		if registered[route] {
+			t.Errorf("expected %s of a disabled subsystem not to be registered", route)
+		}
+	}
+}
+
+func TestEveryAPIRouteHasARoleRule(t *testing.T) {
+	e := configureTestRoutes(t)
+	for _, route := range e.Routes() {
+		if !strings.HasPrefix(route.Path, "/api/v1/") {
+			continue
+		}
+		if _, ok := approles.RequiredPermission(approles.DefaultRules, route.Method, route.Path); !ok {
+			t.Errorf("%s %s matches no role rule", route.Method, route.Path)
+		}
+	}
+}
+
+func TestRateLimitIgnoresRotatedIdentityHeaders(t *testing.T) {
+	e := echo.New()
+	proxies := trustedProxies([]string{"10.0.0.0/8"})
+	e.IPExtractor = ipExtractor(proxies)
+	limiter := ratelimit.NewLimiter(ratelimit.Policy{Default: ratelimit.Limit{Requests: 2, Period: metav1.Duration{Duration: time.Minute}}}, nil, log.New(io.Discard))
+	e.Use(appmiddleware.RateLimitMiddleware(limiter, proxies))
+	e.GET("/api/v1/dashboards", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	get := func(remote string, headers map[string]string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/dashboards", nil)
+		req.RemoteAddr = remote
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// A client reaching KubeChat directly is limited by its address whatever it claims to be.
+	for i := range 3 {
+		code := get("203.0.113.7:4000", map[string]string{
+			identity.Header:              fmt.Sprintf("user-%d", i),
+			echo.HeaderAuthorization:     fmt.Sprintf("Bearer key-%d", i),
+			echo.HeaderXForwardedFor:     fmt.Sprintf("198.51.100.%d", i),
+			identity.ImpersonationHeader: fmt.Sprintf("session-%d", i),
+		})
+		want := http.StatusOK
+		if i == 2 {
+			want = http.StatusTooManyRequests
+		}
+		if code != want {
+			t.Fatalf("request %d from a rotating direct client: expected %d, got %d", i, want, code)
+		}
+	}
+
+	// Behind a trusted proxy the user it names is limited, wherever they connect from.
+	for i := range 3 {
+		code := get("10.0.0.5:4000", map[string]string{
+			identity.Header:          "alice",
+			echo.HeaderXForwardedFor: fmt.Sprintf("198.51.100.%d", i),
+		})
+		want := http.StatusOK
+		if i == 2 {
+			want = http.StatusTooManyRequests
+		}
+		if code != want {
+			t.Fatalf("request %d from alice through the proxy: expected %d, got %d", i, want, code)
+		}
+	}
+	if code := get("10.0.0.5:4000", map[string]string{identity.Header: "bob", echo.HeaderXForwardedFor: "198.51.100.0"}); code != http.StatusOK {
+		t.Fatalf("expected bob to have a separate allowance behind the proxy, got %d", code)
+	}
+}